@@ -14,15 +14,22 @@
 package serverapi
 
 import (
+	"bytes"
+	"context"
+	"errors"
 	"io"
+	"net"
 	"net/http"
 	"net/url"
 	"strings"
+	"sync"
+	"time"
 
 	"github.com/pingcap/log"
 	"github.com/tikv/pd/pkg/errs"
 	"github.com/tikv/pd/server"
 	"github.com/tikv/pd/server/config"
+	"github.com/tikv/pd/server/rbac"
 	"github.com/urfave/negroni"
 	"go.uber.org/zap"
 )
@@ -37,6 +44,22 @@ const (
 const (
 	errRedirectFailed      = "redirect failed"
 	errRedirectToNotLeader = "redirect to not leader"
+	errLeaderUnavailable   = "leader unavailable"
+)
+
+const (
+	// forwardRetryInterval is the interval between the retries to forward a
+	// request to the leader.
+	forwardRetryInterval = 200 * time.Millisecond
+	// breakerFailureThreshold is the number of the consecutive failed forwards
+	// to open the circuit breaker.
+	breakerFailureThreshold = 5
+	// breakerOpenDuration is how long the requests are rejected without trying
+	// to forward them after the circuit breaker opens.
+	breakerOpenDuration = 10 * time.Second
+	// maxForwardBodySize is the max size of the body of a request forwarded
+	// by the follower gateway, which is buffered to be resent.
+	maxForwardBodySize = 16 << 20
 )
 
 type runtimeServiceValidator struct {
@@ -79,10 +102,84 @@ func IsServiceAllowed(s *server.Server, group server.ServiceGroup) bool {
 	return false
 }
 
-type redirector struct {
+// adminPathPrefixes are the paths which only the admins can access.
+var adminPathPrefixes = []string{
+	"/pd/api/v1/admin",
+	"/pd/api/v1/rbac",
+	"/pd/api/v1/plugin",
+}
+
+// adminDeletePathPrefixes are the paths which only the admins can delete,
+// since they are destructive.
+var adminDeletePathPrefixes = []string{
+	"/pd/api/v1/store/",
+	"/pd/api/v1/stores/remove-tombstone",
+	"/pd/api/v1/members/",
+	"/pd/api/v1/config/placement-rule/",
+	"/pd/api/v1/gc/safepoint/",
+}
+
+// requiredRole returns the role required by the request.
+func requiredRole(r *http.Request) string {
+	for _, prefix := range adminPathPrefixes {
+		if strings.HasPrefix(r.URL.Path, prefix) {
+			return rbac.RoleAdmin
+		}
+	}
+	switch r.Method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions:
+		return rbac.RoleViewer
+	case http.MethodDelete:
+		for _, prefix := range adminDeletePathPrefixes {
+			if strings.HasPrefix(r.URL.Path, prefix) {
+				return rbac.RoleAdmin
+			}
+		}
+	}
+	return rbac.RoleOperator
+}
+
+type rbacAuthorizer struct {
 	s *server.Server
 }
 
+// NewRBACAuthorizer rejects the request if the identity is not granted the
+// required role. It is checked before the request is redirected, and the
+// leader trusts the certificate of the members.
+func NewRBACAuthorizer(s *server.Server) negroni.Handler {
+	return &rbacAuthorizer{s: s}
+}
+
+func (h *rbacAuthorizer) ServeHTTP(w http.ResponseWriter, r *http.Request, next http.HandlerFunc) {
+	manager := h.s.GetRBACManager()
+	if manager == nil {
+		next(w, r)
+		return
+	}
+	var id rbac.Identity
+	if r.TLS != nil && len(r.TLS.PeerCertificates) > 0 {
+		id.CommonName = r.TLS.PeerCertificates[0].Subject.CommonName
+	}
+	id.Token = server.ParseBearerToken(r.Header.Get("Authorization"))
+	err := manager.Authorize(id, requiredRole(r))
+	switch {
+	case err == nil:
+		next(w, r)
+	case errs.ErrRBACUnauthenticated.Equal(err):
+		http.Error(w, err.Error(), http.StatusUnauthorized)
+	case errs.ErrRBACPermissionDenied.Equal(err):
+		log.Warn("rbac denies the request", zap.String("method", r.Method), zap.String("path", r.URL.Path), zap.String("common-name", id.CommonName), errs.ZapError(err))
+		http.Error(w, err.Error(), http.StatusForbidden)
+	default:
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	}
+}
+
+type redirector struct {
+	s       *server.Server
+	breaker circuitBreaker
+}
+
 // NewRedirector redirects request to the leader if needs to be handled in the leader.
 func NewRedirector(s *server.Server) negroni.Handler {
 	return &redirector{s: s}
@@ -91,6 +188,21 @@ func NewRedirector(s *server.Server) negroni.Handler {
 func (h *redirector) ServeHTTP(w http.ResponseWriter, r *http.Request, next http.HandlerFunc) {
 	allowFollowerHandle := len(r.Header.Get(AllowFollowerHandle)) > 0
 	isLeader := h.s.GetMember().IsLeader()
+	var gatewayCfg *config.PDServerConfig
+	if !isLeader {
+		gatewayCfg = h.s.GetGatewayConfig()
+		if gatewayCfg.EnableFollowerGateway && gatewayCfg.GetGatewayAction(r.Method, r.URL.Path) == config.GatewayLocal {
+			allowFollowerHandle = true
+			// The handler forwards the request if it turns out to need the
+			// leader, see ForwardToLeader.
+			retries := gatewayCfg.GatewayForwardRetries
+			r = r.WithContext(context.WithValue(r.Context(), gatewayForwardKey{}, func(w http.ResponseWriter, r *http.Request) {
+				w.Header().Del(FollowerHandle)
+				r.Header.Set(RedirectorHeader, h.s.Name())
+				h.forward(w, r, retries)
+			}))
+		}
+	}
 	if !h.s.IsClosed() && (allowFollowerHandle || isLeader) {
 		if !isLeader {
 			w.Header().Add(FollowerHandle, "true")
@@ -108,6 +220,11 @@ func (h *redirector) ServeHTTP(w http.ResponseWriter, r *http.Request, next http
 
 	r.Header.Set(RedirectorHeader, h.s.Name())
 
+	if gatewayCfg != nil && gatewayCfg.EnableFollowerGateway {
+		h.forward(w, r, gatewayCfg.GatewayForwardRetries)
+		return
+	}
+
 	leader := h.s.GetMember().GetLeader()
 	if leader == nil {
 		http.Error(w, "no leader", http.StatusServiceUnavailable)
@@ -123,6 +240,103 @@ func (h *redirector) ServeHTTP(w http.ResponseWriter, r *http.Request, next http
 	NewCustomReverseProxies(client, urls).ServeHTTP(w, r)
 }
 
+type gatewayForwardKey struct{}
+
+// ForwardToLeader forwards the request which the follower gateway is handling
+// locally to the leader, such as when the handler needs the running cluster,
+// which only the leader has. It returns false if the request is not handled
+// by the follower gateway.
+func ForwardToLeader(w http.ResponseWriter, r *http.Request) bool {
+	forward, ok := r.Context().Value(gatewayForwardKey{}).(func(http.ResponseWriter, *http.Request))
+	if !ok {
+		return false
+	}
+	forward(w, r)
+	return true
+}
+
+// forward forwards the request to the leader for the follower gateway. It
+// retries with the latest leader, and rejects the requests for a while after
+// too many consecutive failures.
+func (h *redirector) forward(w http.ResponseWriter, r *http.Request, retries int) {
+	if !h.breaker.allow() {
+		http.Error(w, errLeaderUnavailable, http.StatusServiceUnavailable)
+		return
+	}
+	body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxForwardBodySize))
+	r.Body.Close()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	for i := 0; i <= retries; i++ {
+		if i > 0 {
+			select {
+			case <-r.Context().Done():
+				http.Error(w, r.Context().Err().Error(), http.StatusServiceUnavailable)
+				return
+			case <-time.After(forwardRetryInterval):
+			}
+		}
+		leader := h.s.GetMember().GetLeader()
+		if leader == nil {
+			continue
+		}
+		urls, err := config.ParseUrls(strings.Join(leader.GetClientUrls(), ","))
+		if err != nil {
+			continue
+		}
+		p := &customReverseProxies{client: h.s.GetHTTPClient(), urls: urls}
+		result := p.tryServe(w, r, body)
+		if result == forwardServed {
+			h.breaker.succeed()
+			return
+		}
+		if result == forwardFailed {
+			// The leader may have applied the request, so it is not retried.
+			break
+		}
+	}
+	if h.breaker.fail() {
+		log.Warn("too many failures to forward the requests to the leader, reject the requests for a while",
+			zap.String("server", h.s.Name()), zap.Duration("duration", breakerOpenDuration))
+	}
+	http.Error(w, errRedirectFailed, http.StatusInternalServerError)
+}
+
+// circuitBreaker opens after breakerFailureThreshold consecutive failures and
+// rejects the requests for breakerOpenDuration. After that, a failure opens it
+// again until a success closes it.
+type circuitBreaker struct {
+	sync.Mutex
+	failures  int
+	openUntil time.Time
+}
+
+func (b *circuitBreaker) allow() bool {
+	b.Lock()
+	defer b.Unlock()
+	return !time.Now().Before(b.openUntil)
+}
+
+func (b *circuitBreaker) succeed() {
+	b.Lock()
+	defer b.Unlock()
+	b.failures = 0
+}
+
+// fail records a failure and returns true if the breaker opens.
+func (b *circuitBreaker) fail() bool {
+	b.Lock()
+	defer b.Unlock()
+	b.failures++
+	if b.failures < breakerFailureThreshold {
+		return false
+	}
+	b.openUntil = time.Now().Add(breakerOpenDuration)
+	return true
+}
+
 type customReverseProxies struct {
 	urls   []url.URL
 	client *http.Client
@@ -140,7 +354,34 @@ func NewCustomReverseProxies(dialClient *http.Client, urls []url.URL) http.Handl
 }
 
 func (p *customReverseProxies) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if p.tryServe(w, r, nil) != forwardServed {
+		http.Error(w, errRedirectFailed, http.StatusInternalServerError)
+	}
+}
+
+// forwardResult is the result of forwarding a request.
+type forwardResult int
+
+const (
+	// forwardServed means the response is written.
+	forwardServed forwardResult = iota
+	// forwardRetryable means nothing is written, and the request is safe to
+	// be forwarded again.
+	forwardRetryable
+	// forwardFailed means nothing is written, but the request may have been
+	// handled, so it is not safe to be forwarded again.
+	forwardFailed
+)
+
+// tryServe tries the urls in order until any of them responds. The next url is
+// tried only if the request is not sent or it is safe to be sent again. The
+// body is resent for each url if it is not nil.
+func (p *customReverseProxies) tryServe(w http.ResponseWriter, r *http.Request, body []byte) forwardResult {
+	retryable := isSafeMethod(r.Method)
 	for _, url := range p.urls {
+		if body != nil {
+			r.Body = io.NopCloser(bytes.NewReader(body))
+		}
 		r.RequestURI = ""
 		r.URL.Host = url.Host
 		r.URL.Scheme = url.Scheme
@@ -148,27 +389,46 @@ func (p *customReverseProxies) ServeHTTP(w http.ResponseWriter, r *http.Request)
 		resp, err := p.client.Do(r)
 		if err != nil {
 			log.Error("request failed", errs.ZapError(errs.ErrSendRequest, err))
-			continue
+			if retryable || isDialError(err) {
+				continue
+			}
+			return forwardFailed
 		}
 
 		b, err := io.ReadAll(resp.Body)
 		resp.Body.Close()
 		if err != nil {
 			log.Error("read failed", errs.ZapError(errs.ErrIORead, err))
-			continue
+			if retryable {
+				continue
+			}
+			return forwardFailed
 		}
 
 		copyHeader(w.Header(), resp.Header)
 		w.WriteHeader(resp.StatusCode)
+		// The header is written, so the request cannot be served again.
 		if _, err := w.Write(b); err != nil {
 			log.Error("write failed", errs.ZapError(errs.ErrWriteHTTPBody, err))
-			continue
 		}
-
-		return
+		return forwardServed
 	}
+	// Either the request is safe to be sent again, or none of the urls can be
+	// dialed and the request is not sent.
+	return forwardRetryable
+}
 
-	http.Error(w, errRedirectFailed, http.StatusInternalServerError)
+// isSafeMethod returns whether the requests of the method do not change the
+// state of the server, so they can be sent again after failures.
+func isSafeMethod(method string) bool {
+	return method == http.MethodGet || method == http.MethodHead || method == http.MethodOptions
+}
+
+// isDialError returns whether the error is returned before the request is
+// sent, because the connection cannot be established.
+func isDialError(err error) bool {
+	var opErr *net.OpError
+	return errors.As(err, &opErr) && opErr.Op == "dial"
 }
 
 func copyHeader(dst, src http.Header) {
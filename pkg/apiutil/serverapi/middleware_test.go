@@ -0,0 +1,73 @@
+// Copyright 2021 TiKV Project Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package serverapi
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	. "github.com/pingcap/check"
+)
+
+func Test(t *testing.T) {
+	TestingT(t)
+}
+
+var _ = Suite(&testForwardSuite{})
+
+type testForwardSuite struct{}
+
+func (s *testForwardSuite) TestTryServe(c *C) {
+	var hits int32
+	// The server drops the connection after reading the request, like a leader
+	// crashing after applying it.
+	broken := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&hits, 1)
+		conn, _, err := w.(http.Hijacker).Hijack()
+		c.Assert(err, IsNil)
+		conn.Close()
+	}))
+	defer broken.Close()
+	ok := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusCreated)
+	}))
+	defer ok.Close()
+	brokenURL, _ := url.Parse(broken.URL)
+	okURL, _ := url.Parse(ok.URL)
+	p := &customReverseProxies{client: &http.Client{}, urls: []url.URL{*brokenURL, *okURL}}
+
+	// The mutations are not sent again after they may have been handled.
+	r := httptest.NewRequest(http.MethodPost, "/pd/api/v1/schedulers", strings.NewReader("{}"))
+	w := httptest.NewRecorder()
+	c.Assert(p.tryServe(w, r, []byte("{}")), Equals, forwardFailed)
+	c.Assert(atomic.LoadInt32(&hits), Equals, int32(1))
+
+	// The safe requests are sent to the next url.
+	r = httptest.NewRequest(http.MethodGet, "/pd/api/v1/schedulers", nil)
+	w = httptest.NewRecorder()
+	c.Assert(p.tryServe(w, r, nil), Equals, forwardServed)
+	c.Assert(w.Code, Equals, http.StatusCreated)
+	c.Assert(atomic.LoadInt32(&hits), Equals, int32(2))
+
+	// The mutations are sent to the next url if the request is not sent.
+	broken.Close()
+	r = httptest.NewRequest(http.MethodPost, "/pd/api/v1/schedulers", strings.NewReader("{}"))
+	w = httptest.NewRecorder()
+	c.Assert(p.tryServe(w, r, []byte("{}")), Equals, forwardServed)
+	c.Assert(w.Code, Equals, http.StatusCreated)
+}
@@ -41,6 +41,7 @@ func NewHandler(_ context.Context, svr *server.Server) (http.Handler, server.Ser
 		IndentJSON: true,
 	})
 	autoScalingHandler.Handle(autoScalingPrefix, negroni.New(
+		serverapi.NewRBACAuthorizer(svr),
 		serverapi.NewRedirector(svr),
 		negroni.Wrap(NewHTTPHandler(svr, rd))),
 	)
@@ -26,6 +26,14 @@ var (
 	recordPrefix = []byte("_r")
 )
 
+const (
+	// rawKeyspacePrefix and txnKeyspacePrefix are the modes of the keys of
+	// the keyspaces in API v2, which are followed by the 3-byte keyspace ID.
+	rawKeyspacePrefix = 'r'
+	txnKeyspacePrefix = 'x'
+	keyspacePrefixLen = 4
+)
+
 const (
 	signMask uint64 = 0x8000000000000000
 
@@ -73,6 +81,19 @@ func (k Key) MetaOrTable() (bool, int64) {
 	return false, 0
 }
 
+// KeyspaceID returns the keyspace ID of the key in API v2, and false if the
+// key doesn't belong to a keyspace. The txn keys are memcomparable-encoded,
+// which keeps the first 8 bytes, so the prefix is read as it is. It only
+// checks the prefix, so a raw key in API v1 starting with the same byte is
+// misread, and the caller should only use it when the keys are known to be
+// in the API v2 layout.
+func (k Key) KeyspaceID() (uint32, bool) {
+	if len(k) < keyspacePrefixLen || (k[0] != rawKeyspacePrefix && k[0] != txnKeyspacePrefix) {
+		return 0, false
+	}
+	return uint32(k[1])<<16 | uint32(k[2])<<8 | uint32(k[3]), true
+}
+
 var pads = make([]byte, encGroupSize)
 
 // EncodeBytes guarantees the encoded value is in ascending order for comparison,
@@ -52,3 +52,20 @@ func (s *testCodecSuite) TestTableID(c *C) {
 	key = EncodeBytes([]byte("t\x80\x00\x00\x00\x00\x00\xff"))
 	c.Assert(key.TableID(), Equals, int64(0))
 }
+
+func (s *testCodecSuite) TestKeyspaceID(c *C) {
+	id, ok := Key("r\x00\x01\x02abc").KeyspaceID()
+	c.Assert(ok, IsTrue)
+	c.Assert(id, Equals, uint32(0x102))
+
+	id, ok = EncodeBytes([]byte("x\x01\x00\x00")).KeyspaceID()
+	c.Assert(ok, IsTrue)
+	c.Assert(id, Equals, uint32(0x10000))
+
+	_, ok = Key("r\x00\x01").KeyspaceID()
+	c.Assert(ok, IsFalse)
+	_, ok = EncodeBytes([]byte("t\x80\x00\x00\x00\x00\x00\x00\xff")).KeyspaceID()
+	c.Assert(ok, IsFalse)
+	_, ok = Key("").KeyspaceID()
+	c.Assert(ok, IsFalse)
+}
@@ -97,10 +97,94 @@ var (
 	ErrBuildRuleList = errors.Normalize("build rule list failed, %s", errors.RFCCodeText("PD:placement:ErrBuildRuleList"))
 )
 
+// tiering errors
+var (
+	ErrTieringPolicyContent  = errors.Normalize("invalid tiering policy, %s", errors.RFCCodeText("PD:tiering:ErrTieringPolicyContent"))
+	ErrTieringPolicyNotFound = errors.Normalize("tiering policy %s not found", errors.RFCCodeText("PD:tiering:ErrTieringPolicyNotFound"))
+)
+
+// affinity group errors
+var (
+	ErrAffinityGroupContent  = errors.Normalize("invalid affinity group, %s", errors.RFCCodeText("PD:affinity:ErrAffinityGroupContent"))
+	ErrAffinityGroupNotFound = errors.Normalize("affinity group %s not found", errors.RFCCodeText("PD:affinity:ErrAffinityGroupNotFound"))
+)
+
+// region label errors
+var (
+	ErrRegionLabelRuleContent  = errors.Normalize("invalid region label rule, %s", errors.RFCCodeText("PD:labeler:ErrRegionLabelRuleContent"))
+	ErrRegionLabelRuleNotFound = errors.Normalize("region label rule %s not found", errors.RFCCodeText("PD:labeler:ErrRegionLabelRuleNotFound"))
+)
+
+// key range lock errors
+var (
+	ErrKeyRangeLockContent  = errors.Normalize("invalid key range lock, %s", errors.RFCCodeText("PD:keyrange:ErrKeyRangeLockContent"))
+	ErrKeyRangeLocked       = errors.Normalize("key range is locked by %s", errors.RFCCodeText("PD:keyrange:ErrKeyRangeLocked"))
+	ErrKeyRangeLockNotFound = errors.Normalize("key range lock %s not found", errors.RFCCodeText("PD:keyrange:ErrKeyRangeLockNotFound"))
+)
+
+// job errors
+var (
+	ErrJobContent     = errors.Normalize("invalid job, %s", errors.RFCCodeText("PD:job:ErrJobContent"))
+	ErrJobNotFound    = errors.Normalize("job %d not found", errors.RFCCodeText("PD:job:ErrJobNotFound"))
+	ErrJobNotCanceled = errors.Normalize("job %d cannot be canceled since it is %s", errors.RFCCodeText("PD:job:ErrJobNotCanceled"))
+)
+
+// diagnosis errors
+var (
+	ErrDiagnosisCapture        = errors.Normalize("failed to capture the diagnosis bundle", errors.RFCCodeText("PD:diagnosis:ErrDiagnosisCapture"))
+	ErrDiagnosisCapturing      = errors.Normalize("a diagnosis bundle is being captured", errors.RFCCodeText("PD:diagnosis:ErrDiagnosisCapturing"))
+	ErrDiagnosisBundleNotFound = errors.Normalize("diagnosis bundle %s not found", errors.RFCCodeText("PD:diagnosis:ErrDiagnosisBundleNotFound"))
+)
+
+// heartbeat replay errors
+var (
+	ErrHeartbeatRecord           = errors.Normalize("failed to record the heartbeats", errors.RFCCodeText("PD:replay:ErrHeartbeatRecord"))
+	ErrHeartbeatRecording        = errors.Normalize("the heartbeats are being recorded", errors.RFCCodeText("PD:replay:ErrHeartbeatRecording"))
+	ErrHeartbeatNotRecording     = errors.Normalize("the heartbeats are not being recorded", errors.RFCCodeText("PD:replay:ErrHeartbeatNotRecording"))
+	ErrHeartbeatRecordNotFound   = errors.Normalize("heartbeat record %s not found", errors.RFCCodeText("PD:replay:ErrHeartbeatRecordNotFound"))
+	ErrHeartbeatRecordCorruption = errors.Normalize("heartbeat record is corrupted, %s", errors.RFCCodeText("PD:replay:ErrHeartbeatRecordCorruption"))
+)
+
+// heatmap errors
+var (
+	ErrHeatmapStatTag = errors.Normalize("unknown heatmap statistics %s", errors.RFCCodeText("PD:heatmap:ErrHeatmapStatTag"))
+)
+
+// stats exporter errors
+var (
+	ErrStatsExport = errors.Normalize("failed to export the stats to %s", errors.RFCCodeText("PD:statsexporter:ErrStatsExport"))
+)
+
+// meta snapshot errors
+var (
+	ErrMetaSnapshot          = errors.Normalize("failed to access the meta snapshot storage %s", errors.RFCCodeText("PD:metasnapshot:ErrMetaSnapshot"))
+	ErrMetaSnapshotCorrupted = errors.Normalize("meta snapshot %s is corrupted, %s", errors.RFCCodeText("PD:metasnapshot:ErrMetaSnapshotCorrupted"))
+)
+
 // cluster errors
 var (
-	ErrNotBootstrapped = errors.Normalize("TiKV cluster not bootstrapped, please start TiKV first", errors.RFCCodeText("PD:cluster:ErrNotBootstrapped"))
-	ErrStoreIsUp       = errors.Normalize("store is still up, please remove store gracefully", errors.RFCCodeText("PD:cluster:ErrStoreIsUp"))
+	ErrNotBootstrapped           = errors.Normalize("TiKV cluster not bootstrapped, please start TiKV first", errors.RFCCodeText("PD:cluster:ErrNotBootstrapped"))
+	ErrAlreadyBootstrapped       = errors.Normalize("cluster is already bootstrapped", errors.RFCCodeText("PD:cluster:ErrAlreadyBootstrapped"))
+	ErrStoreIsUp                 = errors.Normalize("store is still up, please remove store gracefully", errors.RFCCodeText("PD:cluster:ErrStoreIsUp"))
+	ErrTopologyTargetContent     = errors.Normalize("invalid topology target, %s", errors.RFCCodeText("PD:cluster:ErrTopologyTargetContent"))
+	ErrTopologyPlanNotFound      = errors.Normalize("topology plan not found", errors.RFCCodeText("PD:cluster:ErrTopologyPlanNotFound"))
+	ErrTopologyPlanStepRunning   = errors.Normalize("step %d of topology plan is still running", errors.RFCCodeText("PD:cluster:ErrTopologyPlanStepRunning"))
+	ErrStoreFencingTokenMismatch = errors.Normalize("the fencing token of store %d mismatches, the store ID may be reused by a stale node", errors.RFCCodeText("PD:cluster:ErrStoreFencingTokenMismatch"))
+	ErrStoreFencingTokenMissing  = errors.Normalize("store %d omits the fencing token it has presented before, the store ID may be reused by a stale node", errors.RFCCodeText("PD:cluster:ErrStoreFencingTokenMissing"))
+	ErrStoreFencingTokenGenerate = errors.Normalize("failed to generate the fencing token", errors.RFCCodeText("PD:cluster:ErrStoreFencingTokenGenerate"))
+	ErrStoreArchiveNotFound      = errors.Normalize("the archive of store %d not found", errors.RFCCodeText("PD:cluster:ErrStoreArchiveNotFound"))
+	ErrStoreArchiveRestore       = errors.Normalize("failed to restore the archive of store %d, %s", errors.RFCCodeText("PD:cluster:ErrStoreArchiveRestore"))
+	ErrConformanceReportRunning  = errors.Normalize("a conformance report is being generated", errors.RFCCodeText("PD:cluster:ErrConformanceReportRunning"))
+	ErrConformanceReportNotFound = errors.Normalize("conformance report %d not found", errors.RFCCodeText("PD:cluster:ErrConformanceReportNotFound"))
+	ErrStoreRemovalBlocked       = errors.Normalize("store %d cannot be removed safely, %s", errors.RFCCodeText("PD:cluster:ErrStoreRemovalBlocked"))
+	ErrClusterDataMismatch       = errors.Normalize("the metadata does not match cluster %d, %s. It may be restored from another cluster, use --allow-cluster-mismatch to skip the check if it is expected", errors.RFCCodeText("PD:cluster:ErrClusterDataMismatch"))
+	ErrStoreVersionDowngrade     = errors.Normalize("the version %s of store %d is lower than the cluster version %s, which is rejected by the upgrade guardrail", errors.RFCCodeText("PD:cluster:ErrStoreVersionDowngrade"))
+	ErrUpgradeInProgress         = errors.Normalize("the upgrade to %s is in progress", errors.RFCCodeText("PD:cluster:ErrUpgradeInProgress"))
+	ErrUpgradeNotInProgress      = errors.Normalize("no upgrade is in progress", errors.RFCCodeText("PD:cluster:ErrUpgradeNotInProgress"))
+	ErrUpgradeNotFinished        = errors.Normalize("%d stores are not upgraded to %s yet", errors.RFCCodeText("PD:cluster:ErrUpgradeNotFinished"))
+	ErrUpgradeTargetVersion      = errors.Normalize("the target version %s is lower than the cluster version %s", errors.RFCCodeText("PD:cluster:ErrUpgradeTargetVersion"))
+	ErrInvalidAlertRule          = errors.Normalize("invalid alert rule, %s", errors.RFCCodeText("PD:cluster:ErrInvalidAlertRule"))
+	ErrAlertRuleNotFound         = errors.Normalize("alert rule %s not found", errors.RFCCodeText("PD:cluster:ErrAlertRuleNotFound"))
 )
 
 // versioninfo errors
@@ -130,12 +214,29 @@ var (
 
 // server errors
 var (
-	ErrServiceRegistered     = errors.Normalize("service with path [%s] already registered", errors.RFCCodeText("PD:server:ErrServiceRegistered"))
-	ErrAPIInformationInvalid = errors.Normalize("invalid api information, group %s version %s", errors.RFCCodeText("PD:server:ErrAPIInformationInvalid"))
-	ErrClientURLEmpty        = errors.Normalize("client url empty", errors.RFCCodeText("PD:server:ErrClientEmpty"))
-	ErrLeaderNil             = errors.Normalize("leader is nil", errors.RFCCodeText("PD:server:ErrLeaderNil"))
-	ErrCancelStartEtcd       = errors.Normalize("etcd start canceled", errors.RFCCodeText("PD:server:ErrCancelStartEtcd"))
-	ErrConfigItem            = errors.Normalize("cannot set invalid configuration", errors.RFCCodeText("PD:server:ErrConfiguration"))
+	ErrServiceRegistered       = errors.Normalize("service with path [%s] already registered", errors.RFCCodeText("PD:server:ErrServiceRegistered"))
+	ErrAPIInformationInvalid   = errors.Normalize("invalid api information, group %s version %s", errors.RFCCodeText("PD:server:ErrAPIInformationInvalid"))
+	ErrClientURLEmpty          = errors.Normalize("client url empty", errors.RFCCodeText("PD:server:ErrClientEmpty"))
+	ErrLeaderNil               = errors.Normalize("leader is nil", errors.RFCCodeText("PD:server:ErrLeaderNil"))
+	ErrCancelStartEtcd         = errors.Normalize("etcd start canceled", errors.RFCCodeText("PD:server:ErrCancelStartEtcd"))
+	ErrConfigItem              = errors.Normalize("cannot set invalid configuration", errors.RFCCodeText("PD:server:ErrConfiguration"))
+	ErrServerDegraded          = errors.Normalize("PD is in the degraded mode because of the memory pressure, please retry later", errors.RFCCodeText("PD:server:ErrServerDegraded"))
+	ErrStartReadAPI            = errors.Normalize("start the listener of the read-only API failed", errors.RFCCodeText("PD:server:ErrStartReadAPI"))
+	ErrMetaArchiveVersion      = errors.Normalize("unsupported version %d of the meta archive", errors.RFCCodeText("PD:server:ErrMetaArchiveVersion"))
+	ErrInvalidMetaArchive      = errors.Normalize("invalid meta archive: %s", errors.RFCCodeText("PD:server:ErrInvalidMetaArchive"))
+	ErrRestoreMetaBootstrapped = errors.Normalize("the meta can only be restored into a cluster which is not bootstrapped", errors.RFCCodeText("PD:server:ErrRestoreMetaBootstrapped"))
+	ErrStartEmbedded           = errors.Normalize("start the embedded server failed", errors.RFCCodeText("PD:server:ErrStartEmbedded"))
+	ErrServerClosed            = errors.Normalize("server is closed", errors.RFCCodeText("PD:server:ErrServerClosed"))
+	ErrSafeModeBlocked         = errors.Normalize("the operation is blocked by the safe mode, confirm it with the unlock token in the %s header", errors.RFCCodeText("PD:server:ErrSafeModeBlocked"))
+	ErrSafeModeToken           = errors.Normalize("the unlock token of the safe mode is mismatched", errors.RFCCodeText("PD:server:ErrSafeModeToken"))
+)
+
+// rbac errors
+var (
+	ErrRBACUnauthenticated  = errors.Normalize("the identity of the request is unknown", errors.RFCCodeText("PD:rbac:ErrRBACUnauthenticated"))
+	ErrRBACPermissionDenied = errors.Normalize("the %s role is required, but the role of the identity is %s", errors.RFCCodeText("PD:rbac:ErrRBACPermissionDenied"))
+	ErrRBACNoAdmin          = errors.Normalize("at least one admin binding is required when RBAC is enabled", errors.RFCCodeText("PD:rbac:ErrRBACNoAdmin"))
+	ErrRBACInvalidBinding   = errors.Normalize("invalid role binding: %s", errors.RFCCodeText("PD:rbac:ErrRBACInvalidBinding"))
 )
 
 // logutil errors
@@ -148,6 +249,11 @@ var (
 	ErrBytesToUint64 = errors.Normalize("invalid data, must 8 bytes, but %d", errors.RFCCodeText("PD:typeutil:ErrBytesToUint64"))
 )
 
+// netutil errors
+var (
+	ErrInvalidAddress = errors.Normalize("invalid address %s, %s", errors.RFCCodeText("PD:netutil:ErrInvalidAddress"))
+)
+
 // The third-party project error.
 // url errors
 var (
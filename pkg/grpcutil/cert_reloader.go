@@ -0,0 +1,244 @@
+// Copyright 2021 TiKV Project Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package grpcutil
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/pem"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/pingcap/errors"
+	"github.com/pingcap/log"
+	"github.com/tikv/pd/pkg/errs"
+	"go.uber.org/zap"
+)
+
+// DefaultCertReloadInterval is the interval of checking the certificate files.
+const DefaultCertReloadInterval = time.Minute
+
+// fileStamp identifies the version of a file.
+type fileStamp struct {
+	modTime time.Time
+	size    int64
+}
+
+// CertReloader keeps the certificate, the key and the trusted CAs of a
+// TLSConfig up to date with the files, so they can be rotated without a
+// restart. The files are checked periodically, and the new ones take effect
+// for the new connections. The old ones are kept if the new ones are invalid,
+// such as when they are partially written.
+type CertReloader struct {
+	cfg       TLSConfig
+	allowedCN string
+
+	mu struct {
+		sync.RWMutex
+		cert   *tls.Certificate
+		caPool *x509.CertPool
+		stamps map[string]fileStamp
+	}
+}
+
+// NewCertReloader creates a CertReloader and loads the files. It returns nil
+// if TLS is not enabled.
+func NewCertReloader(cfg TLSConfig) (*CertReloader, error) {
+	if len(cfg.CertPath) == 0 && len(cfg.KeyPath) == 0 {
+		return nil, nil
+	}
+	allowedCN, err := cfg.GetOneAllowedCN()
+	if err != nil {
+		return nil, err
+	}
+	r := &CertReloader{cfg: cfg, allowedCN: allowedCN}
+	if _, err := r.Reload(); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+func (r *CertReloader) files() []string {
+	files := []string{r.cfg.CertPath, r.cfg.KeyPath}
+	if r.cfg.CAPath != "" {
+		files = append(files, r.cfg.CAPath)
+	}
+	return files
+}
+
+// Reload loads the files if any of them is changed, and returns whether they
+// are reloaded. The loaded ones are kept if it fails.
+func (r *CertReloader) Reload() (bool, error) {
+	stamps := make(map[string]fileStamp)
+	for _, file := range r.files() {
+		info, err := os.Stat(file)
+		if err != nil {
+			tlsReloadCounter.WithLabelValues("failure").Inc()
+			return false, errs.ErrSecurityConfig.Wrap(err).GenWithStackByCause()
+		}
+		stamps[file] = fileStamp{modTime: info.ModTime(), size: info.Size()}
+	}
+	r.mu.RLock()
+	changed := !sameStamps(r.mu.stamps, stamps)
+	r.mu.RUnlock()
+	if !changed {
+		return false, nil
+	}
+
+	cert, caPool, caNotAfter, err := r.load()
+	if err != nil {
+		tlsReloadCounter.WithLabelValues("failure").Inc()
+		return false, err
+	}
+	r.mu.Lock()
+	r.mu.cert, r.mu.caPool, r.mu.stamps = cert, caPool, stamps
+	r.mu.Unlock()
+	tlsReloadCounter.WithLabelValues("success").Inc()
+	tlsExpiryGauge.WithLabelValues("cert").Set(float64(cert.Leaf.NotAfter.Unix()))
+	if caPool != nil {
+		tlsExpiryGauge.WithLabelValues("ca").Set(float64(caNotAfter.Unix()))
+	}
+	log.Info("tls certificates are loaded", zap.String("cert-path", r.cfg.CertPath),
+		zap.String("ca-path", r.cfg.CAPath), zap.Time("cert-not-after", cert.Leaf.NotAfter))
+	return true, nil
+}
+
+func sameStamps(a, b map[string]fileStamp) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for file, stamp := range b {
+		if old, ok := a[file]; !ok || !old.modTime.Equal(stamp.modTime) || old.size != stamp.size {
+			return false
+		}
+	}
+	return true
+}
+
+// load loads the certificate and the CAs, and returns when the first CA
+// expires.
+func (r *CertReloader) load() (*tls.Certificate, *x509.CertPool, time.Time, error) {
+	cert, err := tls.LoadX509KeyPair(r.cfg.CertPath, r.cfg.KeyPath)
+	if err != nil {
+		return nil, nil, time.Time{}, errs.ErrSecurityConfig.Wrap(err).GenWithStackByCause()
+	}
+	if cert.Leaf, err = x509.ParseCertificate(cert.Certificate[0]); err != nil {
+		return nil, nil, time.Time{}, errs.ErrSecurityConfig.Wrap(err).GenWithStackByCause()
+	}
+	if r.cfg.CAPath == "" {
+		return &cert, nil, time.Time{}, nil
+	}
+	data, err := os.ReadFile(r.cfg.CAPath)
+	if err != nil {
+		return nil, nil, time.Time{}, errs.ErrSecurityConfig.Wrap(err).GenWithStackByCause()
+	}
+	caPool := x509.NewCertPool()
+	var caNotAfter time.Time
+	for block, rest := pem.Decode(data); block != nil; block, rest = pem.Decode(rest) {
+		if block.Type != "CERTIFICATE" {
+			continue
+		}
+		ca, err := x509.ParseCertificate(block.Bytes)
+		if err != nil {
+			return nil, nil, time.Time{}, errs.ErrSecurityConfig.Wrap(err).GenWithStackByCause()
+		}
+		caPool.AddCert(ca)
+		if caNotAfter.IsZero() || ca.NotAfter.Before(caNotAfter) {
+			caNotAfter = ca.NotAfter
+		}
+	}
+	if caNotAfter.IsZero() {
+		return nil, nil, time.Time{}, errs.ErrSecurityConfig.FastGenByArgs("no certificate is found in " + r.cfg.CAPath)
+	}
+	return &cert, caPool, caNotAfter, nil
+}
+
+func (r *CertReloader) getCert() *tls.Certificate {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.mu.cert
+}
+
+func (r *CertReloader) getCAPool() *x509.CertPool {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.mu.caPool
+}
+
+// CommonName returns the common name of the latest certificate, or empty if
+// the reloader is nil.
+func (r *CertReloader) CommonName() string {
+	if r == nil {
+		return ""
+	}
+	return r.getCert().Leaf.Subject.CommonName
+}
+
+// Run checks the files periodically until the context is done.
+func (r *CertReloader) Run(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if _, err := r.Reload(); err != nil {
+				log.Warn("failed to reload tls certificates, keep the loaded ones", errs.ZapError(err))
+			}
+		}
+	}
+}
+
+// ClientTLSConfig returns the TLS config of the clients, which always uses the
+// latest certificate and CAs. It returns nil if the reloader is nil.
+func (r *CertReloader) ClientTLSConfig() *tls.Config {
+	if r == nil {
+		return nil
+	}
+	return &tls.Config{
+		MinVersion: tls.VersionTLS12,
+		GetClientCertificate: func(*tls.CertificateRequestInfo) (*tls.Certificate, error) {
+			return r.getCert(), nil
+		},
+		// The server certificate is verified in VerifyConnection with the
+		// latest CAs instead, because RootCAs cannot be changed.
+		InsecureSkipVerify: true,
+		VerifyConnection:   r.verifyConnection,
+	}
+}
+
+func (r *CertReloader) verifyConnection(cs tls.ConnectionState) error {
+	if len(cs.PeerCertificates) == 0 {
+		return errors.New("no certificate is provided by the server")
+	}
+	opts := x509.VerifyOptions{
+		DNSName:       cs.ServerName,
+		Roots:         r.getCAPool(),
+		Intermediates: x509.NewCertPool(),
+	}
+	for _, cert := range cs.PeerCertificates[1:] {
+		opts.Intermediates.AddCert(cert)
+	}
+	chains, err := cs.PeerCertificates[0].Verify(opts)
+	if err != nil {
+		return err
+	}
+	if r.allowedCN != "" && chains[0][0].Subject.CommonName != r.allowedCN {
+		return errors.Errorf("certificate CN %s is not allowed", chains[0][0].Subject.CommonName)
+	}
+	return nil
+}
@@ -0,0 +1,116 @@
+// Copyright 2021 TiKV Project Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package grpcutil
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	. "github.com/pingcap/check"
+)
+
+func Test(t *testing.T) {
+	TestingT(t)
+}
+
+var _ = Suite(&testCertReloaderSuite{})
+
+type testCertReloaderSuite struct{}
+
+const (
+	testCertDir        = "../../tests/client/cert"
+	testExpiredCertDir = "../../tests/client/cert-expired"
+)
+
+func copyCertFiles(c *C, from, to string) TLSConfig {
+	for _, name := range []string{"ca.pem", "pd-server.pem", "pd-server-key.pem"} {
+		data, err := os.ReadFile(filepath.Join(from, name))
+		c.Assert(err, IsNil)
+		c.Assert(os.WriteFile(filepath.Join(to, name), data, 0600), IsNil)
+	}
+	return TLSConfig{
+		CAPath:   filepath.Join(to, "ca.pem"),
+		CertPath: filepath.Join(to, "pd-server.pem"),
+		KeyPath:  filepath.Join(to, "pd-server-key.pem"),
+	}
+}
+
+func loadConnectionState(c *C, dir string) tls.ConnectionState {
+	cert, err := tls.LoadX509KeyPair(filepath.Join(dir, "pd-server.pem"), filepath.Join(dir, "pd-server-key.pem"))
+	c.Assert(err, IsNil)
+	leaf, err := x509.ParseCertificate(cert.Certificate[0])
+	c.Assert(err, IsNil)
+	return tls.ConnectionState{ServerName: "127.0.0.1", PeerCertificates: []*x509.Certificate{leaf}}
+}
+
+func (s *testCertReloaderSuite) TestReload(c *C) {
+	r, err := NewCertReloader(TLSConfig{})
+	c.Assert(err, IsNil)
+	c.Assert(r, IsNil)
+	c.Assert(r.ClientTLSConfig(), IsNil)
+
+	dir := c.MkDir()
+	cfg := copyCertFiles(c, testCertDir, dir)
+	r, err = NewCertReloader(cfg)
+	c.Assert(err, IsNil)
+	tlsCfg := r.ClientTLSConfig()
+	c.Assert(tlsCfg.VerifyConnection(loadConnectionState(c, testCertDir)), IsNil)
+	cert, err := tlsCfg.GetClientCertificate(nil)
+	c.Assert(err, IsNil)
+	validNotAfter := cert.Leaf.NotAfter
+	// Nothing is reloaded if the files are not changed.
+	reloaded, err := r.Reload()
+	c.Assert(err, IsNil)
+	c.Assert(reloaded, IsFalse)
+
+	// The invalid files are not loaded.
+	c.Assert(os.WriteFile(cfg.CertPath, []byte("invalid"), 0600), IsNil)
+	_, err = r.Reload()
+	c.Assert(err, NotNil)
+	cert, err = tlsCfg.GetClientCertificate(nil)
+	c.Assert(err, IsNil)
+	c.Assert(cert.Leaf.NotAfter.Equal(validNotAfter), IsTrue)
+
+	// The rotated files take effect on the config in use.
+	copyCertFiles(c, testExpiredCertDir, dir)
+	future := time.Now().Add(time.Minute)
+	for _, file := range []string{cfg.CAPath, cfg.CertPath, cfg.KeyPath} {
+		c.Assert(os.Chtimes(file, future, future), IsNil)
+	}
+	reloaded, err = r.Reload()
+	c.Assert(err, IsNil)
+	c.Assert(reloaded, IsTrue)
+	cert, err = tlsCfg.GetClientCertificate(nil)
+	c.Assert(err, IsNil)
+	c.Assert(cert.Leaf.NotAfter.Before(validNotAfter), IsTrue)
+	// The server certificate is verified with the new CA.
+	c.Assert(tlsCfg.VerifyConnection(loadConnectionState(c, testCertDir)), NotNil)
+}
+
+func (s *testCertReloaderSuite) TestAllowedCN(c *C) {
+	cfg := copyCertFiles(c, testCertDir, c.MkDir())
+	cfg.CertAllowedCN = []string{"pd-server"}
+	r, err := NewCertReloader(cfg)
+	c.Assert(err, IsNil)
+	c.Assert(r.ClientTLSConfig().VerifyConnection(loadConnectionState(c, testCertDir)), IsNil)
+
+	cfg.CertAllowedCN = []string{"tikv-server"}
+	r, err = NewCertReloader(cfg)
+	c.Assert(err, IsNil)
+	c.Assert(r.ClientTLSConfig().VerifyConnection(loadConnectionState(c, testCertDir)), NotNil)
+}
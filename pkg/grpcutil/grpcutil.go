@@ -29,6 +29,32 @@ import (
 // ForwardMetadataKey is used to record the forwarded host of PD.
 const ForwardMetadataKey = "pd-forwarded-host"
 
+// FencingTokenMetadataKey is used to pass the fencing token of a store. PD
+// returns the token in the header of the PutStore which issues it, and the
+// store presents it in the metadata of the following PutStore and
+// StoreHeartbeat requests.
+const FencingTokenMetadataKey = "pd-store-fencing-token"
+
+// StalePeersMetadataKey is used to publish the peers removed by PD in the
+// header of the StoreHeartbeat response, which are encoded in JSON.
+const StalePeersMetadataKey = "pd-stale-peers"
+
+// StalePeersAckMetadataKey is used to acknowledge the removed peers in the
+// metadata of the StoreHeartbeat request. The value is the latest version of
+// the removed peers the store has cleaned up.
+const StalePeersAckMetadataKey = "pd-stale-peers-ack"
+
+// SnapshotHintsMetadataKey is used to suggest the leaders on a store to send
+// the snapshots of the new learners from the followers in the same zones as
+// the learners in the header of the StoreHeartbeat response, which are encoded
+// in JSON.
+const SnapshotHintsMetadataKey = "pd-snapshot-hints"
+
+// RoutingHintMetadataKey is used to hint the neighbors of a region split or
+// merged recently in the header of the GetRegion responses, which are encoded
+// in JSON, so the clients can invalidate their region caches proactively.
+const RoutingHintMetadataKey = "pd-routing-hint"
+
 // TLSConfig is the configuration for supporting tls.
 type TLSConfig struct {
 	// CAPath is the path of file that contains list of trusted SSL CAs. if set, following four settings shouldn't be empty
@@ -0,0 +1,39 @@
+// Copyright 2021 TiKV Project Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package grpcutil
+
+import "github.com/prometheus/client_golang/prometheus"
+
+var (
+	tlsExpiryGauge = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Namespace: "pd",
+			Subsystem: "tls",
+			Name:      "cert_expiry_timestamp_seconds",
+			Help:      "The unix time when the loaded certificate expires.",
+		}, []string{"type"})
+
+	tlsReloadCounter = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace: "pd",
+			Subsystem: "tls",
+			Name:      "cert_reload_total",
+			Help:      "Counter of loading the certificate files.",
+		}, []string{"result"})
+)
+
+func init() {
+	prometheus.MustRegister(tlsExpiryGauge)
+	prometheus.MustRegister(tlsReloadCounter)
+}
@@ -30,11 +30,36 @@ func (mc *Cluster) SetMaxMergeRegionKeys(v int) {
 	mc.updateScheduleConfig(func(s *config.ScheduleConfig) { s.MaxMergeRegionKeys = uint64(v) })
 }
 
+// SetStoreWarmUpDuration updates the StoreWarmUpDuration configuration.
+func (mc *Cluster) SetStoreWarmUpDuration(v time.Duration) {
+	mc.updateScheduleConfig(func(s *config.ScheduleConfig) { s.StoreWarmUpDuration = typeutil.NewDuration(v) })
+}
+
+// SetStoreSuspectGracePeriod updates the StoreSuspectGracePeriod configuration.
+func (mc *Cluster) SetStoreSuspectGracePeriod(v time.Duration) {
+	mc.updateScheduleConfig(func(s *config.ScheduleConfig) { s.StoreSuspectGracePeriod = typeutil.NewDuration(v) })
+}
+
 // SetSplitMergeInterval updates the SplitMergeInterval configuration.
 func (mc *Cluster) SetSplitMergeInterval(v time.Duration) {
 	mc.updateScheduleConfig(func(s *config.ScheduleConfig) { s.SplitMergeInterval = typeutil.NewDuration(v) })
 }
 
+// SetEnableStoreFairQueue updates the EnableStoreFairQueue configuration.
+func (mc *Cluster) SetEnableStoreFairQueue(v bool) {
+	mc.updateScheduleConfig(func(s *config.ScheduleConfig) { s.EnableStoreFairQueue = v })
+}
+
+// SetEnableKeyspaceFairQueue updates the EnableKeyspaceFairQueue configuration.
+func (mc *Cluster) SetEnableKeyspaceFairQueue(v bool) {
+	mc.updateScheduleConfig(func(s *config.ScheduleConfig) { s.EnableKeyspaceFairQueue = v })
+}
+
+// SetKeyspaceWeights updates the KeyspaceWeights configuration.
+func (mc *Cluster) SetKeyspaceWeights(v []config.KeyspaceWeight) {
+	mc.updateScheduleConfig(func(s *config.ScheduleConfig) { s.KeyspaceWeights = v })
+}
+
 // SetEnableOneWayMerge updates the EnableOneWayMerge configuration.
 func (mc *Cluster) SetEnableOneWayMerge(v bool) {
 	mc.updateScheduleConfig(func(s *config.ScheduleConfig) { s.EnableOneWayMerge = v })
@@ -70,6 +95,21 @@ func (mc *Cluster) SetEnableReplaceOfflineReplica(v bool) {
 	mc.updateScheduleConfig(func(s *config.ScheduleConfig) { s.EnableReplaceOfflineReplica = v })
 }
 
+// SetEnableSplitLeaderTransfer updates the EnableSplitLeaderTransfer configuration.
+func (mc *Cluster) SetEnableSplitLeaderTransfer(v bool) {
+	mc.updateScheduleConfig(func(s *config.ScheduleConfig) { s.EnableSplitLeaderTransfer = v })
+}
+
+// SetEnableTransferLeaderReadyCheck updates the EnableTransferLeaderReadyCheck configuration.
+func (mc *Cluster) SetEnableTransferLeaderReadyCheck(v bool) {
+	mc.updateScheduleConfig(func(s *config.ScheduleConfig) { s.EnableTransferLeaderReadyCheck = v })
+}
+
+// SetEnableParallelMakeUpReplica updates the EnableParallelMakeUpReplica configuration.
+func (mc *Cluster) SetEnableParallelMakeUpReplica(v bool) {
+	mc.updateScheduleConfig(func(s *config.ScheduleConfig) { s.EnableParallelMakeUpReplica = v })
+}
+
 // SetLeaderSchedulePolicy updates the LeaderSchedulePolicy configuration.
 func (mc *Cluster) SetLeaderSchedulePolicy(v string) {
 	mc.updateScheduleConfig(func(s *config.ScheduleConfig) { s.LeaderSchedulePolicy = v })
@@ -110,6 +150,11 @@ func (mc *Cluster) SetHotRegionCacheHitsThreshold(v int) {
 	mc.updateScheduleConfig(func(s *config.ScheduleConfig) { s.HotRegionCacheHitsThreshold = uint64(v) })
 }
 
+// SetLeaderConstraints updates the LeaderConstraints configuration.
+func (mc *Cluster) SetLeaderConstraints(v []config.LeaderConstraint) {
+	mc.updateScheduleConfig(func(s *config.ScheduleConfig) { s.LeaderConstraints = v })
+}
+
 // SetEnablePlacementRules updates the EnablePlacementRules configuration.
 func (mc *Cluster) SetEnablePlacementRules(v bool) {
 	mc.updateReplicationConfig(func(r *config.ReplicationConfig) { r.EnablePlacementRules = v })
@@ -133,6 +178,11 @@ func (mc *Cluster) SetIsolationLevel(v string) {
 	mc.updateReplicationConfig(func(r *config.ReplicationConfig) { r.IsolationLevel = v })
 }
 
+// SetEnableSystemRangeIsolation updates the EnableSystemRangeIsolation configuration.
+func (mc *Cluster) SetEnableSystemRangeIsolation(v bool) {
+	mc.updateReplicationConfig(func(r *config.ReplicationConfig) { r.EnableSystemRangeIsolation = v })
+}
+
 func (mc *Cluster) updateScheduleConfig(f func(*config.ScheduleConfig)) {
 	s := mc.GetScheduleConfig().Clone()
 	f(s)
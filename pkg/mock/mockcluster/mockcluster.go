@@ -29,6 +29,9 @@ import (
 	"github.com/tikv/pd/server/core"
 	"github.com/tikv/pd/server/core/storelimit"
 	"github.com/tikv/pd/server/kv"
+	"github.com/tikv/pd/server/schedule/affinity"
+	"github.com/tikv/pd/server/schedule/keyrange"
+	"github.com/tikv/pd/server/schedule/labeler"
 	"github.com/tikv/pd/server/schedule/placement"
 	"github.com/tikv/pd/server/statistics"
 	"github.com/tikv/pd/server/versioninfo"
@@ -45,11 +48,14 @@ type Cluster struct {
 	*core.BasicCluster
 	*mockid.IDAllocator
 	*placement.RuleManager
+	*labeler.RegionLabeler
 	*statistics.HotStat
 	*config.PersistOptions
 	ID               uint64
 	suspectRegions   map[uint64]struct{}
 	disabledFeatures map[versioninfo.Feature]struct{}
+	keyRangeManager  *keyrange.Manager
+	affinityManager  *affinity.Manager
 }
 
 // NewCluster creates a new Cluster
@@ -66,6 +72,10 @@ func NewCluster(ctx context.Context, opts *config.PersistOptions) *Cluster {
 	if clus.PersistOptions.GetReplicationConfig().EnablePlacementRules {
 		clus.initRuleManager()
 	}
+	// It should never fail with an empty memory storage.
+	clus.RegionLabeler, _ = labeler.NewRegionLabeler(core.NewStorage(kv.NewMemoryKV()))
+	clus.keyRangeManager, _ = keyrange.NewManager(core.NewStorage(kv.NewMemoryKV()))
+	clus.affinityManager, _ = affinity.NewManager(core.NewStorage(kv.NewMemoryKV()))
 	return clus
 }
 
@@ -168,6 +178,21 @@ func (mc *Cluster) GetRuleManager() *placement.RuleManager {
 	return mc.RuleManager
 }
 
+// GetRegionLabeler returns the region labeler of the cluster.
+func (mc *Cluster) GetRegionLabeler() *labeler.RegionLabeler {
+	return mc.RegionLabeler
+}
+
+// GetKeyRangeManager returns the key range lock manager of the cluster.
+func (mc *Cluster) GetKeyRangeManager() *keyrange.Manager {
+	return mc.keyRangeManager
+}
+
+// GetAffinityManager returns the affinity group manager of the cluster.
+func (mc *Cluster) GetAffinityManager() *affinity.Manager {
+	return mc.affinityManager
+}
+
 // SetStoreUp sets store state to be up.
 func (mc *Cluster) SetStoreUp(storeID uint64) {
 	store := mc.GetStore(storeID)
@@ -403,6 +428,32 @@ func (mc *Cluster) AddRegionLeaderWithReadInfo(
 	return items
 }
 
+// AddRegionLeaderWithReadQuery adds region leader with specified read info and read query info.
+func (mc *Cluster) AddRegionLeaderWithReadQuery(
+	regionID uint64, leaderID uint64,
+	readBytes, readKeys, readQuery uint64,
+	reportInterval uint64,
+	followerIds []uint64, filledNums ...int) []*statistics.HotPeerStat {
+	r := mc.newMockRegionInfo(regionID, leaderID, followerIds...)
+	// The query stats are not kept by Clone, so they are set at once.
+	r = r.Clone(core.SetReadBytes(readBytes), core.SetReadKeys(readKeys),
+		core.SetQueryStats(&pdpb.QueryStats{Get: readQuery}), core.SetReportInterval(reportInterval))
+	filledNum := mc.HotCache.GetFilledPeriod(statistics.ReadFlow)
+	if len(filledNums) > 0 {
+		filledNum = filledNums[0]
+	}
+
+	var items []*statistics.HotPeerStat
+	for i := 0; i < filledNum; i++ {
+		items = mc.CheckRegionLeaderRead(r)
+		for _, item := range items {
+			mc.HotCache.Update(item)
+		}
+	}
+	mc.PutRegion(r)
+	return items
+}
+
 // AddLeaderRegionWithWriteInfo adds region with specified leader and peers write info.
 func (mc *Cluster) AddLeaderRegionWithWriteInfo(
 	regionID uint64, leaderID uint64,
@@ -30,6 +30,11 @@ func (alloc *IDAllocator) Alloc() (uint64, error) {
 	return atomic.AddUint64(&alloc.base, 1), nil
 }
 
+// AllocN returns the first one of n contiguous new ids.
+func (alloc *IDAllocator) AllocN(n uint64) (uint64, error) {
+	return atomic.AddUint64(&alloc.base, n) - n + 1, nil
+}
+
 // Rebase implements the IDAllocator interface.
 func (alloc *IDAllocator) Rebase() error {
 	return nil
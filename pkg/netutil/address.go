@@ -0,0 +1,127 @@
+// Copyright 2021 TiKV Project Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package netutil
+
+import (
+	"net"
+	"strings"
+
+	"github.com/tikv/pd/pkg/errs"
+)
+
+// splitAddr splits the address into the scheme (with "://"), the host and
+// the port. The port is empty if the address has no port. An IPv6 host with
+// a port must be in brackets, otherwise ok is false.
+func splitAddr(addr string) (scheme, host, port string, ok bool) {
+	rest := addr
+	if i := strings.Index(addr, "://"); i >= 0 {
+		scheme, rest = addr[:i+3], addr[i+3:]
+	}
+	if h, p, err := net.SplitHostPort(rest); err == nil {
+		return scheme, h, p, true
+	}
+	if strings.HasPrefix(rest, "[") && strings.HasSuffix(rest, "]") {
+		return scheme, rest[1 : len(rest)-1], "", true
+	}
+	// A bare IPv6 address is accepted without the port only.
+	if strings.Count(rest, ":") > 1 && parseIP(rest) == nil {
+		return "", "", "", false
+	}
+	return scheme, rest, "", true
+}
+
+// parseIP parses the IP literal, which may have an IPv6 zone.
+func parseIP(host string) net.IP {
+	if i := strings.LastIndex(host, "%"); i >= 0 && strings.Contains(host, ":") {
+		host = host[:i]
+	}
+	return net.ParseIP(host)
+}
+
+// IsIPHost returns whether the host is an IP literal rather than a host name.
+func IsIPHost(host string) bool {
+	return parseIP(host) != nil
+}
+
+// CanonicalHost returns the canonical form of the host. The IP literals are
+// formatted by net.IP, so an IPv4-mapped IPv6 address becomes IPv4 and an
+// IPv6 address is compressed in lower case, with the zone kept. The host
+// names are in lower case without the trailing dot.
+func CanonicalHost(host string) string {
+	if ip := parseIP(host); ip != nil {
+		s := ip.String()
+		if i := strings.LastIndex(host, "%"); i >= 0 && ip.To4() == nil {
+			s += host[i:]
+		}
+		return s
+	}
+	return strings.TrimSuffix(strings.ToLower(host), ".")
+}
+
+// NormalizeAddr returns the canonical form of the address, which may have a
+// scheme and a port. The host is canonicalized by CanonicalHost, and an IPv6
+// host is in brackets if there is a port. It fails if an IPv6 host with a
+// port is not in brackets, which is ambiguous.
+func NormalizeAddr(addr string) (string, error) {
+	if addr == "" {
+		return "", nil
+	}
+	scheme, host, port, ok := splitAddr(addr)
+	if !ok {
+		return "", errs.ErrInvalidAddress.FastGenByArgs(addr, "IPv6 host with a port should be in brackets")
+	}
+	host = CanonicalHost(host)
+	if port == "" {
+		return scheme + host, nil
+	}
+	return scheme + net.JoinHostPort(host, port), nil
+}
+
+// AddrHost returns the canonical host of the address, or an empty string if
+// the address can't be parsed.
+func AddrHost(addr string) string {
+	_, host, _, ok := splitAddr(addr)
+	if !ok {
+		return ""
+	}
+	return CanonicalHost(host)
+}
+
+func isLoopbackOrUnspecified(host string) bool {
+	if ip := parseIP(host); ip != nil {
+		return ip.IsLoopback() || ip.IsUnspecified()
+	}
+	return strings.EqualFold(host, "localhost")
+}
+
+// ResolveLoopBackAddr replaces the host of the address with the host of the
+// backAddress if it is a loopback or an unspecified address of either IPv4
+// or IPv6, such as 127.0.0.1, [::1], 0.0.0.0 and [::], so that the address
+// can be reached from other nodes. The port and the scheme of the address
+// are kept.
+func ResolveLoopBackAddr(address, backAddress string) string {
+	scheme, host, port, ok := splitAddr(address)
+	if !ok || !isLoopbackOrUnspecified(host) {
+		return address
+	}
+	_, backHost, _, ok := splitAddr(backAddress)
+	if !ok || backHost == "" {
+		return address
+	}
+	backHost = CanonicalHost(backHost)
+	if port == "" {
+		return scheme + backHost
+	}
+	return scheme + net.JoinHostPort(backHost, port)
+}
@@ -0,0 +1,81 @@
+// Copyright 2021 TiKV Project Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package netutil
+
+import (
+	"testing"
+
+	. "github.com/pingcap/check"
+	"github.com/tikv/pd/pkg/errs"
+)
+
+func TestNetUtil(t *testing.T) {
+	TestingT(t)
+}
+
+var _ = Suite(&testAddressSuite{})
+
+type testAddressSuite struct{}
+
+func (s *testAddressSuite) TestNormalizeAddr(c *C) {
+	testCases := []struct {
+		addr     string
+		expected string
+	}{
+		{"", ""},
+		{"127.0.0.1:20160", "127.0.0.1:20160"},
+		{"TiKV-1.PD.svc.:20160", "tikv-1.pd.svc:20160"},
+		{"[2001:DB8:0:0::1]:20160", "[2001:db8::1]:20160"},
+		{"[::ffff:10.0.0.1]:20160", "10.0.0.1:20160"},
+		{"[fe80::1%eth0]:20160", "[fe80::1%eth0]:20160"},
+		{"2001:db8::1", "2001:db8::1"},
+		{"http://[0:0::1]:2379", "http://[::1]:2379"},
+		{"https://LOCALHOST:2379", "https://localhost:2379"},
+	}
+	for _, t := range testCases {
+		addr, err := NormalizeAddr(t.addr)
+		c.Assert(err, IsNil)
+		c.Assert(addr, Equals, t.expected)
+	}
+	_, err := NormalizeAddr("2001:db8::1:20160:x")
+	c.Assert(errs.ErrInvalidAddress.Equal(err), IsTrue)
+}
+
+func (s *testAddressSuite) TestAddrHost(c *C) {
+	c.Assert(AddrHost("[2001:db8::1]:20160"), Equals, "2001:db8::1")
+	c.Assert(AddrHost("Store-1:20160"), Equals, "store-1")
+	c.Assert(IsIPHost(AddrHost("[2001:db8::1]:20160")), IsTrue)
+	c.Assert(IsIPHost(AddrHost("10.0.0.1:20160")), IsTrue)
+	c.Assert(IsIPHost(AddrHost("store-1:20160")), IsFalse)
+}
+
+func (s *testAddressSuite) TestResolveLoopBackAddr(c *C) {
+	testCases := []struct {
+		address     string
+		backAddress string
+		expected    string
+	}{
+		{"127.0.0.1:20180", "10.0.0.1:20160", "10.0.0.1:20180"},
+		{"0.0.0.0:20180", "store-1:20160", "store-1:20180"},
+		{"[::1]:20180", "[2001:db8::1]:20160", "[2001:db8::1]:20180"},
+		{"[::]:20180", "10.0.0.1:20160", "10.0.0.1:20180"},
+		{"localhost:20180", "[2001:db8::1]:20160", "[2001:db8::1]:20180"},
+		{"http://[::]:2379", "http://[2001:db8::1]:2380", "http://[2001:db8::1]:2379"},
+		{"10.0.0.2:20180", "10.0.0.1:20160", "10.0.0.2:20180"},
+		{"127.0.0.1:20180", "", "127.0.0.1:20180"},
+	}
+	for _, t := range testCases {
+		c.Assert(ResolveLoopBackAddr(t.address, t.backAddress), Equals, t.expected)
+	}
+}
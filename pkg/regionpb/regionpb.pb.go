@@ -0,0 +1,470 @@
+// Code generated by protoc-gen-gogo. DO NOT EDIT.
+// source: regionpb.proto
+
+package regionpb
+
+import (
+	context "context"
+	fmt "fmt"
+	proto "github.com/gogo/protobuf/proto"
+	pdpb "github.com/pingcap/kvproto/pkg/pdpb"
+	grpc "google.golang.org/grpc"
+	codes "google.golang.org/grpc/codes"
+	status "google.golang.org/grpc/status"
+	math "math"
+)
+
+// Reference imports to suppress errors if they are not otherwise used.
+var _ = proto.Marshal
+var _ = fmt.Errorf
+var _ = math.Inf
+
+// This is a compile-time assertion to ensure that this generated file
+// is compatible with the proto package it is being compiled against.
+// A compilation error at this line likely means your copy of the
+// proto package needs to be updated.
+const _ = proto.GoGoProtoPackageIsVersion3 // please upgrade the proto package
+
+// BatchGetRegionRequest is the request of BatchGetRegion. The keys should be
+// sorted in ascending order.
+type BatchGetRegionRequest struct {
+	Header               *pdpb.RequestHeader `protobuf:"bytes,1,opt,name=header,proto3" json:"header,omitempty"`
+	Keys                 [][]byte            `protobuf:"bytes,2,rep,name=keys,proto3" json:"keys,omitempty"`
+	XXX_NoUnkeyedLiteral struct{}            `json:"-"`
+	XXX_unrecognized     []byte              `json:"-"`
+	XXX_sizecache        int32               `json:"-"`
+}
+
+func (m *BatchGetRegionRequest) Reset()         { *m = BatchGetRegionRequest{} }
+func (m *BatchGetRegionRequest) String() string { return proto.CompactTextString(m) }
+func (*BatchGetRegionRequest) ProtoMessage()    {}
+func (*BatchGetRegionRequest) Descriptor() ([]byte, []int) {
+	return fileDescriptor_9946ecaea39d365e, []int{0}
+}
+func (m *BatchGetRegionRequest) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_BatchGetRegionRequest.Unmarshal(m, b)
+}
+func (m *BatchGetRegionRequest) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_BatchGetRegionRequest.Marshal(b, m, deterministic)
+}
+func (m *BatchGetRegionRequest) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_BatchGetRegionRequest.Merge(m, src)
+}
+func (m *BatchGetRegionRequest) XXX_Size() int {
+	return xxx_messageInfo_BatchGetRegionRequest.Size(m)
+}
+func (m *BatchGetRegionRequest) XXX_DiscardUnknown() {
+	xxx_messageInfo_BatchGetRegionRequest.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_BatchGetRegionRequest proto.InternalMessageInfo
+
+func (m *BatchGetRegionRequest) GetHeader() *pdpb.RequestHeader {
+	if m != nil {
+		return m.Header
+	}
+	return nil
+}
+
+func (m *BatchGetRegionRequest) GetKeys() [][]byte {
+	if m != nil {
+		return m.Keys
+	}
+	return nil
+}
+
+// BatchGetRegionResponse is the response of BatchGetRegion. Each region that
+// contains any of the keys is returned once, in the order of the keys. The
+// buckets of the regions are not returned, as neither the pinned kvproto nor
+// the region cache has them.
+type BatchGetRegionResponse struct {
+	Header               *pdpb.ResponseHeader `protobuf:"bytes,1,opt,name=header,proto3" json:"header,omitempty"`
+	Regions              []*pdpb.Region       `protobuf:"bytes,2,rep,name=regions,proto3" json:"regions,omitempty"`
+	XXX_NoUnkeyedLiteral struct{}             `json:"-"`
+	XXX_unrecognized     []byte               `json:"-"`
+	XXX_sizecache        int32                `json:"-"`
+}
+
+func (m *BatchGetRegionResponse) Reset()         { *m = BatchGetRegionResponse{} }
+func (m *BatchGetRegionResponse) String() string { return proto.CompactTextString(m) }
+func (*BatchGetRegionResponse) ProtoMessage()    {}
+func (*BatchGetRegionResponse) Descriptor() ([]byte, []int) {
+	return fileDescriptor_9946ecaea39d365e, []int{1}
+}
+func (m *BatchGetRegionResponse) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_BatchGetRegionResponse.Unmarshal(m, b)
+}
+func (m *BatchGetRegionResponse) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_BatchGetRegionResponse.Marshal(b, m, deterministic)
+}
+func (m *BatchGetRegionResponse) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_BatchGetRegionResponse.Merge(m, src)
+}
+func (m *BatchGetRegionResponse) XXX_Size() int {
+	return xxx_messageInfo_BatchGetRegionResponse.Size(m)
+}
+func (m *BatchGetRegionResponse) XXX_DiscardUnknown() {
+	xxx_messageInfo_BatchGetRegionResponse.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_BatchGetRegionResponse proto.InternalMessageInfo
+
+func (m *BatchGetRegionResponse) GetHeader() *pdpb.ResponseHeader {
+	if m != nil {
+		return m.Header
+	}
+	return nil
+}
+
+func (m *BatchGetRegionResponse) GetRegions() []*pdpb.Region {
+	if m != nil {
+		return m.Regions
+	}
+	return nil
+}
+
+// ScanRegionsStreamRequest is the request of the streaming ScanRegions. The
+// regions in [cursor, end_key) are scanned, so a broken scan can be resumed
+// with the cursor of the last received chunk. The filters are combined with
+// AND, and the zero values mean no filter.
+type ScanRegionsStreamRequest struct {
+	Header *pdpb.RequestHeader `protobuf:"bytes,1,opt,name=header,proto3" json:"header,omitempty"`
+	Cursor []byte              `protobuf:"bytes,2,opt,name=cursor,proto3" json:"cursor,omitempty"`
+	EndKey []byte              `protobuf:"bytes,3,opt,name=end_key,json=endKey,proto3" json:"end_key,omitempty"`
+	// chunk_size is the number of regions scanned for a chunk.
+	ChunkSize            int32    `protobuf:"varint,4,opt,name=chunk_size,json=chunkSize,proto3" json:"chunk_size,omitempty"`
+	HasDownPeer          bool     `protobuf:"varint,5,opt,name=has_down_peer,json=hasDownPeer,proto3" json:"has_down_peer,omitempty"`
+	HasPendingPeer       bool     `protobuf:"varint,6,opt,name=has_pending_peer,json=hasPendingPeer,proto3" json:"has_pending_peer,omitempty"`
+	StoreId              uint64   `protobuf:"varint,7,opt,name=store_id,json=storeId,proto3" json:"store_id,omitempty"`
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
+}
+
+func (m *ScanRegionsStreamRequest) Reset()         { *m = ScanRegionsStreamRequest{} }
+func (m *ScanRegionsStreamRequest) String() string { return proto.CompactTextString(m) }
+func (*ScanRegionsStreamRequest) ProtoMessage()    {}
+func (*ScanRegionsStreamRequest) Descriptor() ([]byte, []int) {
+	return fileDescriptor_9946ecaea39d365e, []int{2}
+}
+func (m *ScanRegionsStreamRequest) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_ScanRegionsStreamRequest.Unmarshal(m, b)
+}
+func (m *ScanRegionsStreamRequest) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_ScanRegionsStreamRequest.Marshal(b, m, deterministic)
+}
+func (m *ScanRegionsStreamRequest) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_ScanRegionsStreamRequest.Merge(m, src)
+}
+func (m *ScanRegionsStreamRequest) XXX_Size() int {
+	return xxx_messageInfo_ScanRegionsStreamRequest.Size(m)
+}
+func (m *ScanRegionsStreamRequest) XXX_DiscardUnknown() {
+	xxx_messageInfo_ScanRegionsStreamRequest.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_ScanRegionsStreamRequest proto.InternalMessageInfo
+
+func (m *ScanRegionsStreamRequest) GetHeader() *pdpb.RequestHeader {
+	if m != nil {
+		return m.Header
+	}
+	return nil
+}
+
+func (m *ScanRegionsStreamRequest) GetCursor() []byte {
+	if m != nil {
+		return m.Cursor
+	}
+	return nil
+}
+
+func (m *ScanRegionsStreamRequest) GetEndKey() []byte {
+	if m != nil {
+		return m.EndKey
+	}
+	return nil
+}
+
+func (m *ScanRegionsStreamRequest) GetChunkSize() int32 {
+	if m != nil {
+		return m.ChunkSize
+	}
+	return 0
+}
+
+func (m *ScanRegionsStreamRequest) GetHasDownPeer() bool {
+	if m != nil {
+		return m.HasDownPeer
+	}
+	return false
+}
+
+func (m *ScanRegionsStreamRequest) GetHasPendingPeer() bool {
+	if m != nil {
+		return m.HasPendingPeer
+	}
+	return false
+}
+
+func (m *ScanRegionsStreamRequest) GetStoreId() uint64 {
+	if m != nil {
+		return m.StoreId
+	}
+	return 0
+}
+
+// ScanRegionsStreamResponse is a chunk of the streaming ScanRegions. cursor
+// is where the next chunk starts, and finished is true for the last chunk.
+// Like BatchGetRegion, the buckets of the regions are not returned.
+type ScanRegionsStreamResponse struct {
+	Header               *pdpb.ResponseHeader `protobuf:"bytes,1,opt,name=header,proto3" json:"header,omitempty"`
+	Regions              []*pdpb.Region       `protobuf:"bytes,2,rep,name=regions,proto3" json:"regions,omitempty"`
+	Cursor               []byte               `protobuf:"bytes,3,opt,name=cursor,proto3" json:"cursor,omitempty"`
+	Finished             bool                 `protobuf:"varint,4,opt,name=finished,proto3" json:"finished,omitempty"`
+	XXX_NoUnkeyedLiteral struct{}             `json:"-"`
+	XXX_unrecognized     []byte               `json:"-"`
+	XXX_sizecache        int32                `json:"-"`
+}
+
+func (m *ScanRegionsStreamResponse) Reset()         { *m = ScanRegionsStreamResponse{} }
+func (m *ScanRegionsStreamResponse) String() string { return proto.CompactTextString(m) }
+func (*ScanRegionsStreamResponse) ProtoMessage()    {}
+func (*ScanRegionsStreamResponse) Descriptor() ([]byte, []int) {
+	return fileDescriptor_9946ecaea39d365e, []int{3}
+}
+func (m *ScanRegionsStreamResponse) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_ScanRegionsStreamResponse.Unmarshal(m, b)
+}
+func (m *ScanRegionsStreamResponse) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_ScanRegionsStreamResponse.Marshal(b, m, deterministic)
+}
+func (m *ScanRegionsStreamResponse) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_ScanRegionsStreamResponse.Merge(m, src)
+}
+func (m *ScanRegionsStreamResponse) XXX_Size() int {
+	return xxx_messageInfo_ScanRegionsStreamResponse.Size(m)
+}
+func (m *ScanRegionsStreamResponse) XXX_DiscardUnknown() {
+	xxx_messageInfo_ScanRegionsStreamResponse.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_ScanRegionsStreamResponse proto.InternalMessageInfo
+
+func (m *ScanRegionsStreamResponse) GetHeader() *pdpb.ResponseHeader {
+	if m != nil {
+		return m.Header
+	}
+	return nil
+}
+
+func (m *ScanRegionsStreamResponse) GetRegions() []*pdpb.Region {
+	if m != nil {
+		return m.Regions
+	}
+	return nil
+}
+
+func (m *ScanRegionsStreamResponse) GetCursor() []byte {
+	if m != nil {
+		return m.Cursor
+	}
+	return nil
+}
+
+func (m *ScanRegionsStreamResponse) GetFinished() bool {
+	if m != nil {
+		return m.Finished
+	}
+	return false
+}
+
+func init() {
+	proto.RegisterType((*BatchGetRegionRequest)(nil), "pd.regions.BatchGetRegionRequest")
+	proto.RegisterType((*BatchGetRegionResponse)(nil), "pd.regions.BatchGetRegionResponse")
+	proto.RegisterType((*ScanRegionsStreamRequest)(nil), "pd.regions.ScanRegionsStreamRequest")
+	proto.RegisterType((*ScanRegionsStreamResponse)(nil), "pd.regions.ScanRegionsStreamResponse")
+}
+
+func init() { proto.RegisterFile("regionpb.proto", fileDescriptor_9946ecaea39d365e) }
+
+var fileDescriptor_9946ecaea39d365e = []byte{
+	// 400 bytes of a gzipped FileDescriptorProto
+	0x1f, 0x8b, 0x08, 0x00, 0x00, 0x00, 0x00, 0x00, 0x02, 0xff, 0xb4, 0x53, 0xed, 0xca, 0xd3, 0x30,
+	0x18, 0x25, 0xfb, 0x68, 0xeb, 0xd3, 0x39, 0x24, 0xea, 0xcc, 0x0a, 0x42, 0x2d, 0x2a, 0x05, 0xa5,
+	0xc8, 0xbc, 0x83, 0x21, 0xa8, 0xf8, 0x67, 0x64, 0x3f, 0x14, 0x11, 0x4a, 0xd7, 0x3c, 0xae, 0x65,
+	0x98, 0xd4, 0xa4, 0x63, 0x6c, 0xbf, 0xbc, 0x19, 0xef, 0xc3, 0x4b, 0x13, 0xd3, 0x56, 0xb7, 0x31,
+	0xdf, 0x17, 0x5e, 0x78, 0xff, 0xe5, 0x39, 0xe7, 0xe4, 0x90, 0x1c, 0xce, 0x03, 0x63, 0x8d, 0xeb,
+	0x52, 0xc9, 0x6a, 0x95, 0x54, 0x5a, 0xd5, 0x8a, 0x42, 0x25, 0x92, 0x06, 0x32, 0x01, 0x54, 0xa2,
+	0xc3, 0xa3, 0x4f, 0xf0, 0x70, 0x9e, 0xd5, 0x79, 0xf1, 0x16, 0x6b, 0x6e, 0x69, 0x8e, 0xdf, 0xb7,
+	0x68, 0x6a, 0xfa, 0x02, 0x9c, 0x02, 0x33, 0x81, 0x9a, 0x91, 0x90, 0xc4, 0xfe, 0xec, 0x7e, 0x62,
+	0x6f, 0xb5, 0xf4, 0x3b, 0x4b, 0xf1, 0x56, 0x42, 0x29, 0x0c, 0x36, 0xb8, 0x37, 0xac, 0x17, 0xf6,
+	0xe3, 0x11, 0xb7, 0xe7, 0x48, 0xc2, 0xe4, 0xdc, 0xd9, 0x54, 0x4a, 0x1a, 0xa4, 0x2f, 0xcf, 0xac,
+	0x1f, 0x74, 0xd6, 0x0d, 0x7f, 0xe6, 0xfd, 0x1c, 0xdc, 0xf6, 0xe1, 0xd6, 0xde, 0x9f, 0x8d, 0x3a,
+	0xb9, 0x35, 0xed, 0xc8, 0xe8, 0x47, 0x0f, 0xd8, 0x32, 0xcf, 0x64, 0x83, 0x9b, 0x65, 0xad, 0x31,
+	0xfb, 0x76, 0xa3, 0xdf, 0x4c, 0xc0, 0xc9, 0xb7, 0xda, 0x28, 0xcd, 0x7a, 0x21, 0x89, 0x47, 0xbc,
+	0x9d, 0xe8, 0x23, 0x70, 0x51, 0x8a, 0x74, 0x83, 0x7b, 0xd6, 0x6f, 0x08, 0x94, 0xe2, 0x03, 0xee,
+	0xe9, 0x63, 0x80, 0xbc, 0xd8, 0xca, 0x4d, 0x6a, 0xca, 0x03, 0xb2, 0x41, 0x48, 0xe2, 0x21, 0xbf,
+	0x63, 0x91, 0x65, 0x79, 0x40, 0x1a, 0xc1, 0xdd, 0x22, 0x33, 0xa9, 0x50, 0x3b, 0x99, 0x56, 0x88,
+	0x9a, 0x0d, 0x43, 0x12, 0x7b, 0xdc, 0x2f, 0x32, 0xf3, 0x46, 0xed, 0xe4, 0x02, 0x51, 0xd3, 0x18,
+	0xee, 0xfd, 0xd1, 0x54, 0x28, 0x45, 0x29, 0xd7, 0x8d, 0xcc, 0xb1, 0xb2, 0x71, 0x91, 0x99, 0x45,
+	0x03, 0x5b, 0xe5, 0x14, 0x3c, 0x53, 0x2b, 0x8d, 0x69, 0x29, 0x98, 0x1b, 0x92, 0x78, 0xc0, 0x5d,
+	0x3b, 0xbf, 0x17, 0xd1, 0x4f, 0x02, 0xd3, 0x0b, 0x11, 0xdc, 0x66, 0xec, 0x47, 0x61, 0xf5, 0x4f,
+	0xc2, 0x0a, 0xc0, 0xfb, 0x5a, 0xca, 0xd2, 0x14, 0x28, 0x6c, 0x22, 0x1e, 0xff, 0x3b, 0xcf, 0x7e,
+	0x11, 0x70, 0xdb, 0x37, 0xd2, 0x8f, 0x30, 0x3e, 0xad, 0x09, 0x7d, 0x92, 0xfc, 0xeb, 0x6a, 0x72,
+	0xb1, 0x9c, 0x41, 0x74, 0x95, 0xa4, 0xfd, 0xee, 0x17, 0xf0, 0x8f, 0xb2, 0xa0, 0x4f, 0x8f, 0xaf,
+	0xfc, 0xaf, 0x27, 0xc1, 0xb3, 0x6b, 0x54, 0x8d, 0xf7, 0x2b, 0x32, 0x87, 0xcf, 0x5e, 0xb7, 0x61,
+	0x2b, 0xc7, 0xae, 0xd2, 0xeb, 0xdf, 0x03, 0x00, 0x43, 0x19, 0x9c, 0xbc, 0x74, 0x03, 0x00, 0x00,
+}
+
+// Reference imports to suppress errors if they are not otherwise used.
+var _ context.Context
+var _ grpc.ClientConn
+
+// This is a compile-time assertion to ensure that this generated file
+// is compatible with the grpc package it is being compiled against.
+const _ = grpc.SupportPackageIsVersion4
+
+// RegionsClient is the client API for Regions service.
+//
+// For semantics around ctx use and closing/ending streaming RPCs, please refer to https://godoc.org/google.golang.org/grpc#ClientConn.NewStream.
+type RegionsClient interface {
+	// BatchGetRegion gets the regions and their leaders of a batch of keys
+	// with a single scan of the region tree.
+	BatchGetRegion(ctx context.Context, in *BatchGetRegionRequest, opts ...grpc.CallOption) (*BatchGetRegionResponse, error)
+	// ScanRegions streams the regions in chunks, so that the tools can scan
+	// all regions without huge responses.
+	ScanRegions(ctx context.Context, in *ScanRegionsStreamRequest, opts ...grpc.CallOption) (Regions_ScanRegionsClient, error)
+}
+
+type regionsClient struct {
+	cc *grpc.ClientConn
+}
+
+func NewRegionsClient(cc *grpc.ClientConn) RegionsClient {
+	return &regionsClient{cc}
+}
+
+func (c *regionsClient) BatchGetRegion(ctx context.Context, in *BatchGetRegionRequest, opts ...grpc.CallOption) (*BatchGetRegionResponse, error) {
+	out := new(BatchGetRegionResponse)
+	err := c.cc.Invoke(ctx, "/pd.regions.Regions/BatchGetRegion", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *regionsClient) ScanRegions(ctx context.Context, in *ScanRegionsStreamRequest, opts ...grpc.CallOption) (Regions_ScanRegionsClient, error) {
+	stream, err := c.cc.NewStream(ctx, &_Regions_serviceDesc.Streams[0], "/pd.regions.Regions/ScanRegions", opts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &regionsScanRegionsClient{stream}
+	if err := x.ClientStream.SendMsg(in); err != nil {
+		return nil, err
+	}
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+type Regions_ScanRegionsClient interface {
+	Recv() (*ScanRegionsStreamResponse, error)
+	grpc.ClientStream
+}
+
+type regionsScanRegionsClient struct {
+	grpc.ClientStream
+}
+
+func (x *regionsScanRegionsClient) Recv() (*ScanRegionsStreamResponse, error) {
+	m := new(ScanRegionsStreamResponse)
+	if err := x.ClientStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+// RegionsServer is the server API for Regions service.
+type RegionsServer interface {
+	// BatchGetRegion gets the regions and their leaders of a batch of keys
+	// with a single scan of the region tree.
+	BatchGetRegion(context.Context, *BatchGetRegionRequest) (*BatchGetRegionResponse, error)
+	// ScanRegions streams the regions in chunks, so that the tools can scan
+	// all regions without huge responses.
+	ScanRegions(*ScanRegionsStreamRequest, Regions_ScanRegionsServer) error
+}
+
+// UnimplementedRegionsServer can be embedded to have forward compatible implementations.
+type UnimplementedRegionsServer struct {
+}
+
+func (*UnimplementedRegionsServer) BatchGetRegion(ctx context.Context, req *BatchGetRegionRequest) (*BatchGetRegionResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method BatchGetRegion not implemented")
+}
+func (*UnimplementedRegionsServer) ScanRegions(req *ScanRegionsStreamRequest, srv Regions_ScanRegionsServer) error {
+	return status.Errorf(codes.Unimplemented, "method ScanRegions not implemented")
+}
+
+func RegisterRegionsServer(s *grpc.Server, srv RegionsServer) {
+	s.RegisterService(&_Regions_serviceDesc, srv)
+}
+
+func _Regions_BatchGetRegion_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(BatchGetRegionRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(RegionsServer).BatchGetRegion(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/pd.regions.Regions/BatchGetRegion",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(RegionsServer).BatchGetRegion(ctx, req.(*BatchGetRegionRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Regions_ScanRegions_Handler(srv interface{}, stream grpc.ServerStream) error {
+	m := new(ScanRegionsStreamRequest)
+	if err := stream.RecvMsg(m); err != nil {
+		return err
+	}
+	return srv.(RegionsServer).ScanRegions(m, &regionsScanRegionsServer{stream})
+}
+
+type Regions_ScanRegionsServer interface {
+	Send(*ScanRegionsStreamResponse) error
+	grpc.ServerStream
+}
+
+type regionsScanRegionsServer struct {
+	grpc.ServerStream
+}
+
+func (x *regionsScanRegionsServer) Send(m *ScanRegionsStreamResponse) error {
+	return x.ServerStream.SendMsg(m)
+}
+
+var _Regions_serviceDesc = grpc.ServiceDesc{
+	ServiceName: "pd.regions.Regions",
+	HandlerType: (*RegionsServer)(nil),
+	Methods: []grpc.MethodDesc{
+		{
+			MethodName: "BatchGetRegion",
+			Handler:    _Regions_BatchGetRegion_Handler,
+		},
+	},
+	Streams: []grpc.StreamDesc{
+		{
+			StreamName:    "ScanRegions",
+			Handler:       _Regions_ScanRegions_Handler,
+			ServerStreams: true,
+		},
+	},
+	Metadata: "regionpb.proto",
+}
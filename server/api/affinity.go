@@ -0,0 +1,112 @@
+// Copyright 2021 TiKV Project Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package api
+
+import (
+	"net/http"
+
+	"github.com/gorilla/mux"
+	"github.com/tikv/pd/pkg/apiutil"
+	"github.com/tikv/pd/pkg/errs"
+	"github.com/tikv/pd/server"
+	"github.com/tikv/pd/server/schedule/affinity"
+	"github.com/unrolled/render"
+)
+
+type affinityHandler struct {
+	svr *server.Server
+	rd  *render.Render
+}
+
+func newAffinityHandler(svr *server.Server, rd *render.Render) *affinityHandler {
+	return &affinityHandler{
+		svr: svr,
+		rd:  rd,
+	}
+}
+
+// @Tags affinity
+// @Summary List all affinity groups of cluster.
+// @Produce json
+// @Success 200 {array} affinity.Group
+// @Router /config/affinity-groups [get]
+func (h *affinityHandler) GetAll(w http.ResponseWriter, r *http.Request) {
+	cluster := getCluster(r)
+	h.rd.JSON(w, http.StatusOK, cluster.GetAffinityManager().GetGroups())
+}
+
+// @Tags affinity
+// @Summary Get affinity group by id.
+// @Param id path string true "Group Id"
+// @Produce json
+// @Success 200 {object} affinity.Group
+// @Failure 404 {string} string "The group does not exist."
+// @Router /config/affinity-group/{id} [get]
+func (h *affinityHandler) Get(w http.ResponseWriter, r *http.Request) {
+	cluster := getCluster(r)
+	id := mux.Vars(r)["id"]
+	group := cluster.GetAffinityManager().GetGroup(id)
+	if group == nil {
+		h.rd.JSON(w, http.StatusNotFound, errs.ErrAffinityGroupNotFound.FastGenByArgs(id).Error())
+		return
+	}
+	h.rd.JSON(w, http.StatusOK, group)
+}
+
+// @Tags affinity
+// @Summary Update or create an affinity group. The stores of the group are chosen by PD.
+// @Accept json
+// @Param group body affinity.Group true "Parameters of affinity group"
+// @Produce json
+// @Success 200 {string} string "Update affinity group successfully."
+// @Failure 400 {string} string "The input is invalid."
+// @Failure 500 {string} string "PD server failed to proceed the request."
+// @Router /config/affinity-group [post]
+func (h *affinityHandler) Set(w http.ResponseWriter, r *http.Request) {
+	cluster := getCluster(r)
+	var group affinity.Group
+	if err := apiutil.ReadJSONRespondError(h.rd, w, r.Body, &group); err != nil {
+		return
+	}
+	if err := cluster.GetAffinityManager().SetGroup(&group); err != nil {
+		if errs.ErrAffinityGroupContent.Equal(err) || errs.ErrHexDecodingString.Equal(err) {
+			h.rd.JSON(w, http.StatusBadRequest, err.Error())
+		} else {
+			h.rd.JSON(w, http.StatusInternalServerError, err.Error())
+		}
+		return
+	}
+	h.rd.JSON(w, http.StatusOK, "Update affinity group successfully.")
+}
+
+// @Tags affinity
+// @Summary Delete affinity group by id. The regions are left where they are.
+// @Param id path string true "Group Id"
+// @Produce json
+// @Success 200 {string} string "Delete affinity group successfully."
+// @Failure 404 {string} string "The group does not exist."
+// @Failure 500 {string} string "PD server failed to proceed the request."
+// @Router /config/affinity-group/{id} [delete]
+func (h *affinityHandler) Delete(w http.ResponseWriter, r *http.Request) {
+	cluster := getCluster(r)
+	if err := cluster.GetAffinityManager().DeleteGroup(mux.Vars(r)["id"]); err != nil {
+		if errs.ErrAffinityGroupNotFound.Equal(err) {
+			h.rd.JSON(w, http.StatusNotFound, err.Error())
+		} else {
+			h.rd.JSON(w, http.StatusInternalServerError, err.Error())
+		}
+		return
+	}
+	h.rd.JSON(w, http.StatusOK, "Delete affinity group successfully.")
+}
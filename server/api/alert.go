@@ -0,0 +1,91 @@
+// Copyright 2021 TiKV Project Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package api
+
+import (
+	"net/http"
+
+	"github.com/gorilla/mux"
+	"github.com/tikv/pd/pkg/apiutil"
+	"github.com/tikv/pd/pkg/errs"
+	"github.com/tikv/pd/server"
+	"github.com/tikv/pd/server/cluster"
+	"github.com/unrolled/render"
+)
+
+type alertHandler struct {
+	svr *server.Server
+	rd  *render.Render
+}
+
+func newAlertHandler(svr *server.Server, rd *render.Render) *alertHandler {
+	return &alertHandler{
+		svr: svr,
+		rd:  rd,
+	}
+}
+
+// @Tags alert
+// @Summary List the alert rules with the states of their alerts.
+// @Produce json
+// @Success 200 {array} cluster.AlertStatus
+// @Router /alerts [get]
+func (h *alertHandler) List(w http.ResponseWriter, r *http.Request) {
+	h.rd.JSON(w, http.StatusOK, getCluster(r).GetAlerts())
+}
+
+// @Tags alert
+// @Summary Create or update an alert rule. The alert fires once the stat compared with the threshold holds for the duration, and the notifications are published as the cluster events and posted to the webhook.
+// @Accept json
+// @Param body body cluster.AlertRule true "The alert rule"
+// @Produce json
+// @Success 200 {string} string "The alert rule is set."
+// @Failure 400 {string} string "The input is invalid."
+// @Failure 500 {string} string "PD server failed to proceed the request."
+// @Router /alerts [post]
+func (h *alertHandler) Set(w http.ResponseWriter, r *http.Request) {
+	var rule cluster.AlertRule
+	if err := apiutil.ReadJSONRespondError(h.rd, w, r.Body, &rule); err != nil {
+		return
+	}
+	if err := getCluster(r).SetAlertRule(&rule); err != nil {
+		if errs.ErrInvalidAlertRule.Equal(err) {
+			h.rd.JSON(w, http.StatusBadRequest, err.Error())
+			return
+		}
+		h.rd.JSON(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	h.rd.JSON(w, http.StatusOK, "The alert rule is set.")
+}
+
+// @Tags alert
+// @Summary Delete an alert rule.
+// @Param name path string true "The name of the alert rule"
+// @Produce json
+// @Success 200 {string} string "The alert rule is deleted."
+// @Failure 404 {string} string "The alert rule is not found."
+// @Failure 500 {string} string "PD server failed to proceed the request."
+// @Router /alerts/{name} [delete]
+func (h *alertHandler) Delete(w http.ResponseWriter, r *http.Request) {
+	if err := getCluster(r).DeleteAlertRule(mux.Vars(r)["name"]); err != nil {
+		if errs.ErrAlertRuleNotFound.Equal(err) {
+			h.rd.JSON(w, http.StatusNotFound, err.Error())
+			return
+		}
+		h.rd.JSON(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	h.rd.JSON(w, http.StatusOK, "The alert rule is deleted.")
+}
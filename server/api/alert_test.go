@@ -0,0 +1,73 @@
+// Copyright 2021 TiKV Project Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+
+	. "github.com/pingcap/check"
+	"github.com/tikv/pd/server"
+	"github.com/tikv/pd/server/cluster"
+)
+
+var _ = Suite(&testAlertSuite{})
+
+type testAlertSuite struct {
+	svr       *server.Server
+	cleanup   cleanUpFunc
+	urlPrefix string
+}
+
+func (s *testAlertSuite) SetUpSuite(c *C) {
+	s.svr, s.cleanup = mustNewServer(c)
+	mustWaitLeader(c, []*server.Server{s.svr})
+
+	addr := s.svr.GetAddr()
+	s.urlPrefix = fmt.Sprintf("%s%s/api/v1", addr, apiPrefix)
+
+	mustBootstrapCluster(c, s.svr)
+}
+
+func (s *testAlertSuite) TearDownSuite(c *C) {
+	s.cleanup()
+}
+
+func (s *testAlertSuite) TestAlertRules(c *C) {
+	data, err := json.Marshal(map[string]interface{}{"name": "down", "stat": cluster.AlertDownPeerRegionCount, "op": ">", "threshold": 10, "for": "10m"})
+	c.Assert(err, IsNil)
+	c.Assert(postJSON(testDialClient, s.urlPrefix+"/alerts", data), IsNil)
+	data, err = json.Marshal(map[string]interface{}{"name": "invalid", "stat": "unknown", "op": ">"})
+	c.Assert(err, IsNil)
+	c.Assert(postJSON(testDialClient, s.urlPrefix+"/alerts", data), NotNil)
+
+	var alerts []*cluster.AlertStatus
+	c.Assert(readJSON(testDialClient, s.urlPrefix+"/alerts", &alerts), IsNil)
+	c.Assert(alerts, HasLen, 1)
+	c.Assert(alerts[0].Rule.Name, Equals, "down")
+	c.Assert(alerts[0].Rule.Threshold, Equals, 10.0)
+	c.Assert(alerts[0].State, Equals, cluster.AlertInactive)
+
+	resp, err := doDelete(testDialClient, s.urlPrefix+"/alerts/down")
+	c.Assert(err, IsNil)
+	resp.Body.Close()
+	c.Assert(resp.StatusCode, Equals, http.StatusOK)
+	resp, err = doDelete(testDialClient, s.urlPrefix+"/alerts/down")
+	c.Assert(err, IsNil)
+	resp.Body.Close()
+	c.Assert(resp.StatusCode, Equals, http.StatusNotFound)
+	c.Assert(readJSON(testDialClient, s.urlPrefix+"/alerts", &alerts), IsNil)
+	c.Assert(alerts, HasLen, 0)
+}
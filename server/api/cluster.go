@@ -16,7 +16,10 @@ package api
 import (
 	"net/http"
 
+	"github.com/tikv/pd/pkg/apiutil"
+	"github.com/tikv/pd/pkg/errs"
 	"github.com/tikv/pd/server"
+	"github.com/tikv/pd/server/config"
 	"github.com/unrolled/render"
 )
 
@@ -55,3 +58,166 @@ func (h *clusterHandler) GetClusterStatus(w http.ResponseWriter, r *http.Request
 	}
 	h.rd.JSON(w, http.StatusOK, status)
 }
+
+// @Tags cluster
+// @Summary Get whether the storage is degraded because of the slowness. The region saves become asynchronous and may be dropped, and the periodic persists of the store meta are suppressed in the degraded state.
+// @Produce json
+// @Success 200 {object} cluster.StorageDegradedStatus
+// @Router /cluster/storage-status [get]
+func (h *clusterHandler) GetStorageStatus(w http.ResponseWriter, r *http.Request) {
+	h.rd.JSON(w, http.StatusOK, getCluster(r).GetStorageDegradedStatus())
+}
+
+// @Tags cluster
+// @Summary Get the estimated memory used by each kind of the objects cached by the cluster in bytes.
+// @Produce json
+// @Success 200 {object} cluster.MemoryUsage
+// @Router /cluster/memory [get]
+func (h *clusterHandler) GetMemoryUsage(w http.ResponseWriter, r *http.Request) {
+	h.rd.JSON(w, http.StatusOK, getCluster(r).GetMemoryUsage())
+}
+
+// @Tags cluster
+// @Summary Get the byte rates over which the hot regions of the tables are split, which are tuned down by the times the tables re-heat.
+// @Produce json
+// @Success 200 {array} cluster.HotSplitThreshold
+// @Router /cluster/hot-split-thresholds [get]
+func (h *clusterHandler) GetHotSplitThresholds(w http.ResponseWriter, r *http.Request) {
+	h.rd.JSON(w, http.StatusOK, getCluster(r).GetHotSplitThresholds())
+}
+
+// @Tags cluster
+// @Summary Get the progress of collecting the cluster information before the scheduling starts.
+// @Produce json
+// @Success 200 {object} cluster.PrepareStatus
+// @Failure 500 {string} string "PD server failed to proceed the request."
+// @Router /cluster/prepare [get]
+func (h *clusterHandler) GetPrepareStatus(w http.ResponseWriter, r *http.Request) {
+	h.rd.JSON(w, http.StatusOK, getCluster(r).GetPrepareStatus())
+}
+
+// @Tags cluster
+// @Summary Declare the number of the stores the cluster is expected to have. The scheduling does not start until so many stores are up. 0 means it is not declared.
+// @Accept json
+// @Param body body object true "json params"
+// @Produce json
+// @Success 200 {string} string "The expected store count is updated."
+// @Failure 400 {string} string "The input is invalid."
+// @Failure 500 {string} string "PD server failed to proceed the request."
+// @Router /cluster/prepare [post]
+func (h *clusterHandler) SetExpectedStoreCount(w http.ResponseWriter, r *http.Request) {
+	var input struct {
+		ExpectedStoreCount *uint64 `json:"expected-store-count"`
+	}
+	if err := apiutil.ReadJSONRespondError(h.rd, w, r.Body, &input); err != nil {
+		return
+	}
+	if input.ExpectedStoreCount == nil {
+		h.rd.JSON(w, http.StatusBadRequest, "missing expected-store-count")
+		return
+	}
+	cfg := h.svr.GetScheduleConfig()
+	cfg.ExpectedStoreCount = *input.ExpectedStoreCount
+	if err := h.svr.SetScheduleConfig(*cfg); err != nil {
+		h.rd.JSON(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	h.rd.JSON(w, http.StatusOK, "The expected store count is updated.")
+}
+
+// @Tags cluster
+// @Summary Halt the scheduling in the mode, which is pause-new, drain or abort-all, or resume it with the empty mode. The mode is reflected in the cluster status.
+// @Accept json
+// @Param body body object true "json params"
+// @Produce json
+// @Success 200 {string} string "The halt mode is updated."
+// @Failure 400 {string} string "The input is invalid."
+// @Failure 500 {string} string "PD server failed to proceed the request."
+// @Router /cluster/halt [post]
+func (h *clusterHandler) SetHaltScheduling(w http.ResponseWriter, r *http.Request) {
+	var input struct {
+		Mode *string `json:"mode"`
+	}
+	if err := apiutil.ReadJSONRespondError(h.rd, w, r.Body, &input); err != nil {
+		return
+	}
+	if input.Mode == nil {
+		h.rd.JSON(w, http.StatusBadRequest, "missing mode")
+		return
+	}
+	if err := config.ValidateHaltScheduling(*input.Mode); err != nil {
+		h.rd.JSON(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	cfg := h.svr.GetScheduleConfig()
+	cfg.HaltScheduling = *input.Mode
+	if err := h.svr.SetScheduleConfig(*cfg); err != nil {
+		h.rd.JSON(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	h.rd.JSON(w, http.StatusOK, "The halt mode is updated.")
+}
+
+// @Tags cluster
+// @Summary Get the status of the upgrade, including the stores not upgraded to the target version of the upgrade window.
+// @Produce json
+// @Success 200 {object} cluster.UpgradeStatus
+// @Router /cluster/upgrade [get]
+func (h *clusterHandler) GetUpgradeStatus(w http.ResponseWriter, r *http.Request) {
+	h.rd.JSON(w, http.StatusOK, getCluster(r).GetUpgradeStatus())
+}
+
+// @Tags cluster
+// @Summary Begin an upgrade window to the target version. The stores with the versions lower than the cluster version are rejected until the upgrade is finished.
+// @Accept json
+// @Param body body object true "json params"
+// @Produce json
+// @Success 200 {string} string "The upgrade is started."
+// @Failure 400 {string} string "The input is invalid."
+// @Failure 409 {string} string "An upgrade is in progress."
+// @Failure 500 {string} string "PD server failed to proceed the request."
+// @Router /cluster/upgrade/begin [post]
+func (h *clusterHandler) BeginUpgrade(w http.ResponseWriter, r *http.Request) {
+	var input struct {
+		TargetVersion string `json:"target-version"`
+	}
+	if err := apiutil.ReadJSONRespondError(h.rd, w, r.Body, &input); err != nil {
+		return
+	}
+	if input.TargetVersion == "" {
+		h.rd.JSON(w, http.StatusBadRequest, "missing target-version")
+		return
+	}
+	err := getCluster(r).BeginUpgrade(input.TargetVersion)
+	switch {
+	case err == nil:
+		h.rd.JSON(w, http.StatusOK, "The upgrade is started.")
+	case errs.ErrUpgradeInProgress.Equal(err):
+		h.rd.JSON(w, http.StatusConflict, err.Error())
+	case errs.ErrUpgradeTargetVersion.Equal(err), errs.ErrSemverNewVersion.Equal(err):
+		h.rd.JSON(w, http.StatusBadRequest, err.Error())
+	default:
+		h.rd.JSON(w, http.StatusInternalServerError, err.Error())
+	}
+}
+
+// @Tags cluster
+// @Summary Finish the upgrade window. It fails if there are stores not upgraded to the target version, unless it is forced.
+// @Param force query string false "finish the upgrade even if there are stores not upgraded" Enums(true, false)
+// @Produce json
+// @Success 200 {string} string "The upgrade is finished."
+// @Failure 409 {string} string "No upgrade is in progress, or there are stores not upgraded."
+// @Failure 500 {string} string "PD server failed to proceed the request."
+// @Router /cluster/upgrade/finish [post]
+func (h *clusterHandler) FinishUpgrade(w http.ResponseWriter, r *http.Request) {
+	force := r.URL.Query().Get("force") == "true"
+	err := getCluster(r).FinishUpgrade(force)
+	switch {
+	case err == nil:
+		h.rd.JSON(w, http.StatusOK, "The upgrade is finished.")
+	case errs.ErrUpgradeNotInProgress.Equal(err), errs.ErrUpgradeNotFinished.Equal(err):
+		h.rd.JSON(w, http.StatusConflict, err.Error())
+	default:
+		h.rd.JSON(w, http.StatusInternalServerError, err.Error())
+	}
+}
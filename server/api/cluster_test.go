@@ -91,3 +91,52 @@ func (s *testClusterSuite) testGetClusterStatus(c *C) {
 	c.Assert(status.RaftBootstrapTime.After(now), IsTrue)
 	c.Assert(status.IsInitialized, IsTrue)
 }
+
+func (s *testClusterSuite) TestPrepare(c *C) {
+	url := fmt.Sprintf("%s/cluster/prepare", s.urlPrefix)
+	c.Assert(postJSON(testDialClient, url, []byte(`{"expected-store-count": 3}`)), IsNil)
+	c.Assert(s.svr.GetScheduleConfig().ExpectedStoreCount, Equals, uint64(3))
+	status := &cluster.PrepareStatus{}
+	c.Assert(readJSON(testDialClient, url, status), IsNil)
+	c.Assert(status.ExpectedStoreCount, Equals, uint64(3))
+
+	c.Assert(postJSON(testDialClient, url, []byte(`{}`)), NotNil)
+	c.Assert(postJSON(testDialClient, url, []byte(`{"expected-store-count": 0}`)), IsNil)
+	c.Assert(s.svr.GetScheduleConfig().ExpectedStoreCount, Equals, uint64(0))
+}
+
+func (s *testClusterSuite) TestHaltScheduling(c *C) {
+	url := fmt.Sprintf("%s/cluster/halt", s.urlPrefix)
+	c.Assert(postJSON(testDialClient, url, []byte(`{}`)), NotNil)
+	c.Assert(postJSON(testDialClient, url, []byte(`{"mode": "stop"}`)), NotNil)
+	c.Assert(postJSON(testDialClient, url, []byte(`{"mode": "drain"}`)), IsNil)
+	c.Assert(s.svr.GetScheduleConfig().HaltScheduling, Equals, config.HaltDrain)
+	status := cluster.Status{}
+	c.Assert(readJSON(testDialClient, fmt.Sprintf("%s/cluster/status", s.urlPrefix), &status), IsNil)
+	c.Assert(status.HaltStatus, DeepEquals, &cluster.HaltStatus{Mode: config.HaltDrain, Drained: true})
+
+	c.Assert(postJSON(testDialClient, url, []byte(`{"mode": ""}`)), IsNil)
+	status = cluster.Status{}
+	c.Assert(readJSON(testDialClient, fmt.Sprintf("%s/cluster/status", s.urlPrefix), &status), IsNil)
+	c.Assert(status.HaltStatus, IsNil)
+}
+
+func (s *testClusterSuite) TestUpgrade(c *C) {
+	url := fmt.Sprintf("%s/cluster/upgrade", s.urlPrefix)
+	c.Assert(postJSON(testDialClient, url+"/begin", []byte(`{}`)), NotNil)
+	c.Assert(postJSON(testDialClient, url+"/begin", []byte(`{"target-version": "v99.0.0"}`)), IsNil)
+	c.Assert(postJSON(testDialClient, url+"/begin", []byte(`{"target-version": "v99.1.0"}`)), NotNil)
+	status := &cluster.UpgradeStatus{}
+	c.Assert(readJSON(testDialClient, url, status), IsNil)
+	c.Assert(status.GuardrailEnabled, IsTrue)
+	c.Assert(status.Window.TargetVersion, Equals, "99.0.0")
+	c.Assert(len(status.PendingStores) > 0, IsTrue)
+
+	// The stores are not upgraded yet.
+	c.Assert(postJSON(testDialClient, url+"/finish", nil), NotNil)
+	c.Assert(postJSON(testDialClient, url+"/finish?force=true", nil), IsNil)
+	status = &cluster.UpgradeStatus{}
+	c.Assert(readJSON(testDialClient, url, status), IsNil)
+	c.Assert(status.Window, IsNil)
+	c.Assert(postJSON(testDialClient, url+"/finish", nil), NotNil)
+}
@@ -71,6 +71,30 @@ func (h *confHandler) GetDefault(w http.ResponseWriter, r *http.Request) {
 	h.rd.JSON(w, http.StatusOK, config)
 }
 
+// @Tags config
+// @Summary Get the effective schedule, replication and PD server configs.
+// @Param explain query boolean false "Whether to report the source and the expiry of each value"
+// @Produce json
+// @Success 200 {object} map[string]interface{}
+// @Failure 500 {string} string "PD server failed to proceed the request."
+// @Router /config/effective [get]
+func (h *confHandler) GetEffective(w http.ResponseWriter, r *http.Request) {
+	items, err := h.svr.GetEffectiveConfig()
+	if err != nil {
+		h.rd.JSON(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	if explain, _ := strconv.ParseBool(r.URL.Query().Get("explain")); explain {
+		h.rd.JSON(w, http.StatusOK, items)
+		return
+	}
+	values := make(map[string]interface{}, len(items))
+	for k, item := range items {
+		values[k] = item.Value
+	}
+	h.rd.JSON(w, http.StatusOK, values)
+}
+
 // FIXME: details of input json body params
 // @Tags config
 // @Summary Update a config item.
@@ -344,6 +368,54 @@ func (h *confHandler) GetSchedule(w http.ResponseWriter, r *http.Request) {
 	h.rd.JSON(w, http.StatusOK, h.svr.GetScheduleConfig())
 }
 
+// @Tags config
+// @Summary Get the status of the maintenance windows.
+// @Produce json
+// @Success 200 {object} config.MaintenanceWindowStatus
+// @Router /config/maintenance-window [get]
+func (h *confHandler) GetMaintenanceWindow(w http.ResponseWriter, r *http.Request) {
+	h.rd.JSON(w, http.StatusOK, h.svr.GetPersistOptions().GetMaintenanceWindowStatus())
+}
+
+// @Tags config
+// @Summary Get the leader constraints with the leader distribution of their stores, which tells whether they are violated.
+// @Produce json
+// @Success 200 {array} leaderconstraint.Status
+// @Router /config/leader-constraints [get]
+func (h *confHandler) GetLeaderConstraints(w http.ResponseWriter, r *http.Request) {
+	rc := getCluster(r)
+	h.rd.JSON(w, http.StatusOK, rc.GetLeaderConstraintStatuses())
+}
+
+// @Tags config
+// @Summary Replace the leader constraints.
+// @Accept json
+// @Param body body []config.LeaderConstraint true "The leader constraints"
+// @Produce json
+// @Success 200 {string} string "The config is updated."
+// @Failure 400 {string} string "The input is invalid."
+// @Failure 500 {string} string "PD server failed to proceed the request."
+// @Router /config/leader-constraints [post]
+func (h *confHandler) SetLeaderConstraints(w http.ResponseWriter, r *http.Request) {
+	var constraints []config.LeaderConstraint
+	if err := apiutil.ReadJSONRespondError(h.rd, w, r.Body, &constraints); err != nil {
+		return
+	}
+	for i := range constraints {
+		if err := constraints[i].Validate(); err != nil {
+			h.rd.JSON(w, http.StatusBadRequest, err.Error())
+			return
+		}
+	}
+	cfg := h.svr.GetScheduleConfig()
+	cfg.LeaderConstraints = constraints
+	if err := h.svr.SetScheduleConfig(*cfg); err != nil {
+		h.rd.JSON(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	h.rd.JSON(w, http.StatusOK, "The config is updated.")
+}
+
 // @Tags config
 // @Summary Update a schedule config item.
 // @Accept json
@@ -23,6 +23,7 @@ import (
 	"github.com/tikv/pd/pkg/typeutil"
 	"github.com/tikv/pd/server"
 	"github.com/tikv/pd/server/config"
+	"github.com/tikv/pd/server/schedule/leaderconstraint"
 	"github.com/tikv/pd/server/versioninfo"
 )
 
@@ -42,6 +43,8 @@ func (s *testConfigSuite) SetUpSuite(c *C) {
 
 	addr := s.svr.GetAddr()
 	s.urlPrefix = fmt.Sprintf("%s%s/api/v1", addr, apiPrefix)
+
+	mustBootstrapCluster(c, s.svr)
 }
 
 func (s *testConfigSuite) TearDownSuite(c *C) {
@@ -163,6 +166,63 @@ func (s *testConfigSuite) TestConfigSchedule(c *C) {
 	c.Assert(*sc, DeepEquals, *sc1)
 }
 
+func (s *testConfigSuite) TestConfigMaintenanceWindow(c *C) {
+	addr := fmt.Sprintf("%s/config", s.urlPrefix)
+	statusAddr := fmt.Sprintf("%s/config/maintenance-window", s.urlPrefix)
+	status := &config.MaintenanceWindowStatus{}
+	c.Assert(readJSON(testDialClient, statusAddr, status), IsNil)
+	c.Assert(status.Active, IsFalse)
+
+	// The window is invalid.
+	postData, err := json.Marshal(map[string]interface{}{
+		"maintenance-windows": []map[string]interface{}{{"schedule": "0 25 * * *", "duration": "1h"}},
+	})
+	c.Assert(err, IsNil)
+	c.Assert(postJSON(testDialClient, addr, postData), NotNil)
+
+	postData, err = json.Marshal(map[string]interface{}{
+		"maintenance-windows": []map[string]interface{}{{"schedule": "* * * * *", "duration": "1m", "merge-schedule-limit": 64}},
+	})
+	c.Assert(err, IsNil)
+	c.Assert(postJSON(testDialClient, addr, postData), IsNil)
+	c.Assert(readJSON(testDialClient, statusAddr, status), IsNil)
+	c.Assert(status.Active, IsTrue)
+	c.Assert(status.Window.MergeScheduleLimit, Equals, uint64(64))
+	c.Assert(s.svr.GetPersistOptions().GetMergeScheduleLimit(), Equals, uint64(64))
+
+	postData, err = json.Marshal(map[string]interface{}{"maintenance-windows": []interface{}{}})
+	c.Assert(err, IsNil)
+	c.Assert(postJSON(testDialClient, addr, postData), IsNil)
+	c.Assert(readJSON(testDialClient, statusAddr, status), IsNil)
+	c.Assert(status.Active, IsFalse)
+}
+
+func (s *testConfigSuite) TestConfigLeaderConstraints(c *C) {
+	addr := fmt.Sprintf("%s/config/leader-constraints", s.urlPrefix)
+	var statuses []*leaderconstraint.Status
+	c.Assert(readJSON(testDialClient, addr, &statuses), IsNil)
+	c.Assert(statuses, HasLen, 0)
+
+	constraints := []config.LeaderConstraint{{LabelKey: "zone", LabelValue: "z1", MaxRatio: 0.4, Mode: config.LeaderConstraintHard}}
+	postData, err := json.Marshal(constraints)
+	c.Assert(err, IsNil)
+	c.Assert(postJSON(testDialClient, addr, postData), IsNil)
+	c.Assert(readJSON(testDialClient, addr, &statuses), IsNil)
+	c.Assert(statuses, HasLen, 1)
+	c.Assert(statuses[0].LeaderConstraint, DeepEquals, constraints[0])
+	c.Assert(statuses[0].Violated, IsFalse)
+	c.Assert(s.svr.GetScheduleConfig().LeaderConstraints, DeepEquals, constraints)
+
+	// The invalid constraints are rejected.
+	constraints[0].MaxRatio = 0
+	postData, err = json.Marshal(constraints)
+	c.Assert(err, IsNil)
+	c.Assert(postJSON(testDialClient, addr, postData), NotNil)
+
+	c.Assert(postJSON(testDialClient, addr, []byte("[]")), IsNil)
+	c.Assert(s.svr.GetScheduleConfig().LeaderConstraints, HasLen, 0)
+}
+
 func (s *testConfigSuite) TestConfigReplication(c *C) {
 	addr := fmt.Sprintf("%s/config/replicate", s.urlPrefix)
 	rc := &config.ReplicationConfig{}
@@ -324,3 +384,25 @@ func (s *testConfigSuite) TestConfigTTL(c *C) {
 	c.Assert(err, Not(IsNil))
 	c.Assert(err.Error(), Equals, "\"unsupported ttl config schedule.invalid-ttl-config\"\n")
 }
+
+func (s *testConfigSuite) TestConfigEffective(c *C) {
+	addr := fmt.Sprintf("%s/config?ttlSecond=5", s.urlPrefix)
+	postData, err := json.Marshal(map[string]interface{}{"schedule.max-merge-region-keys": 999})
+	c.Assert(err, IsNil)
+	err = postJSON(testDialClient, addr, postData)
+	c.Assert(err, IsNil)
+
+	values := make(map[string]interface{})
+	err = readJSON(testDialClient, fmt.Sprintf("%s/config/effective", s.urlPrefix), &values)
+	c.Assert(err, IsNil)
+	c.Assert(values["schedule.max-merge-region-keys"], Equals, float64(999))
+
+	items := make(map[string]*config.EffectiveItem)
+	err = readJSON(testDialClient, fmt.Sprintf("%s/config/effective?explain=true", s.urlPrefix), &items)
+	c.Assert(err, IsNil)
+	item := items["schedule.max-merge-region-keys"]
+	c.Assert(item.Source, Equals, config.SourceTTL)
+	c.Assert(item.Value, Equals, float64(999))
+	c.Assert(item.Expire, NotNil)
+	c.Assert(items["schedule.patrol-region-interval"].Source, Equals, config.SourceDefault)
+}
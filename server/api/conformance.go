@@ -0,0 +1,166 @@
+// Copyright 2021 TiKV Project Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package api
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/gorilla/mux"
+	"github.com/tikv/pd/pkg/errs"
+	"github.com/tikv/pd/server"
+	"github.com/tikv/pd/server/cluster"
+	"github.com/unrolled/render"
+)
+
+type conformanceHandler struct {
+	svr *server.Server
+	rd  *render.Render
+}
+
+func newConformanceHandler(svr *server.Server, rd *render.Render) *conformanceHandler {
+	return &conformanceHandler{
+		svr: svr,
+		rd:  rd,
+	}
+}
+
+// @Tags conformance
+// @Summary List the IDs of the persisted conformance reports, the oldest first.
+// @Produce json
+// @Success 200 {array} uint64
+// @Failure 500 {string} string "PD server failed to proceed the request."
+// @Router /conformance/reports [get]
+func (h *conformanceHandler) List(w http.ResponseWriter, r *http.Request) {
+	ids, err := getCluster(r).GetConformanceReportIDs()
+	if err != nil {
+		h.rd.JSON(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	if ids == nil {
+		ids = []uint64{}
+	}
+	h.rd.JSON(w, http.StatusOK, ids)
+}
+
+// @Tags conformance
+// @Summary Scan all regions against the placement rules and the label policies now, and persist the report.
+// @Produce json
+// @Success 200 {object} cluster.ConformanceReport
+// @Failure 409 {string} string "Another report is being generated."
+// @Failure 500 {string} string "PD server failed to proceed the request."
+// @Router /conformance/reports [post]
+func (h *conformanceHandler) Generate(w http.ResponseWriter, r *http.Request) {
+	report, err := getCluster(r).GenerateConformanceReport()
+	if errs.ErrConformanceReportRunning.Equal(err) {
+		h.rd.JSON(w, http.StatusConflict, err.Error())
+		return
+	}
+	if err != nil {
+		h.rd.JSON(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	h.rd.JSON(w, http.StatusOK, report)
+}
+
+// @Tags conformance
+// @Summary Get a conformance report.
+// @Param id path string true "The ID of the report, or latest"
+// @Produce json
+// @Success 200 {object} cluster.ConformanceReport
+// @Failure 400 {string} string "The input is invalid."
+// @Failure 404 {string} string "The report does not exist."
+// @Failure 500 {string} string "PD server failed to proceed the request."
+// @Router /conformance/reports/{id} [get]
+func (h *conformanceHandler) Get(w http.ResponseWriter, r *http.Request) {
+	rc := getCluster(r)
+	ids, err := rc.GetConformanceReportIDs()
+	if err != nil {
+		h.rd.JSON(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	id, ok := h.parseReportID(w, mux.Vars(r)["id"], ids, 1)
+	if !ok {
+		return
+	}
+	report, err := rc.GetConformanceReport(id)
+	if errs.ErrConformanceReportNotFound.Equal(err) {
+		h.rd.JSON(w, http.StatusNotFound, err.Error())
+		return
+	}
+	if err != nil {
+		h.rd.JSON(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	h.rd.JSON(w, http.StatusOK, report)
+}
+
+// @Tags conformance
+// @Summary Compare two conformance reports.
+// @Param from query string false "The ID of the earlier report, the one before the latest by default"
+// @Param to query string false "The ID of the later report, the latest by default"
+// @Produce json
+// @Success 200 {object} cluster.ConformanceReportDiff
+// @Failure 400 {string} string "The input is invalid."
+// @Failure 404 {string} string "The report does not exist."
+// @Failure 500 {string} string "PD server failed to proceed the request."
+// @Router /conformance/reports/diff [get]
+func (h *conformanceHandler) Diff(w http.ResponseWriter, r *http.Request) {
+	rc := getCluster(r)
+	ids, err := rc.GetConformanceReportIDs()
+	if err != nil {
+		h.rd.JSON(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	fromID, ok := h.parseReportID(w, r.URL.Query().Get("from"), ids, 2)
+	if !ok {
+		return
+	}
+	toID, ok := h.parseReportID(w, r.URL.Query().Get("to"), ids, 1)
+	if !ok {
+		return
+	}
+	var reports []*cluster.ConformanceReport
+	for _, id := range []uint64{fromID, toID} {
+		report, err := rc.GetConformanceReport(id)
+		if errs.ErrConformanceReportNotFound.Equal(err) {
+			h.rd.JSON(w, http.StatusNotFound, err.Error())
+			return
+		}
+		if err != nil {
+			h.rd.JSON(w, http.StatusInternalServerError, err.Error())
+			return
+		}
+		reports = append(reports, report)
+	}
+	h.rd.JSON(w, http.StatusOK, cluster.DiffConformanceReports(reports[0], reports[1]))
+}
+
+// parseReportID parses the report ID. An empty value or "latest" means the
+// nth latest report.
+func (h *conformanceHandler) parseReportID(w http.ResponseWriter, value string, ids []uint64, nth int) (uint64, bool) {
+	if value != "" && value != "latest" {
+		id, err := strconv.ParseUint(value, 10, 64)
+		if err != nil {
+			h.rd.JSON(w, http.StatusBadRequest, errs.ErrStrconvParseUint.Wrap(err).GenWithStackByCause().Error())
+			return 0, false
+		}
+		return id, true
+	}
+	if len(ids) < nth {
+		h.rd.JSON(w, http.StatusNotFound, "not enough conformance reports")
+		return 0, false
+	}
+	return ids[len(ids)-nth], true
+}
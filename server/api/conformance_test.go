@@ -0,0 +1,82 @@
+// Copyright 2021 TiKV Project Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+
+	. "github.com/pingcap/check"
+	"github.com/tikv/pd/server"
+	"github.com/tikv/pd/server/cluster"
+)
+
+var _ = Suite(&testConformanceSuite{})
+
+type testConformanceSuite struct {
+	svr       *server.Server
+	cleanup   cleanUpFunc
+	urlPrefix string
+}
+
+func (s *testConformanceSuite) SetUpSuite(c *C) {
+	s.svr, s.cleanup = mustNewServer(c)
+	mustWaitLeader(c, []*server.Server{s.svr})
+	addr := s.svr.GetAddr()
+	s.urlPrefix = fmt.Sprintf("%s%s/api/v1/conformance/reports", addr, apiPrefix)
+	mustBootstrapCluster(c, s.svr)
+}
+
+func (s *testConformanceSuite) TearDownSuite(c *C) {
+	s.cleanup()
+}
+
+func (s *testConformanceSuite) TestConformanceReport(c *C) {
+	var ids []uint64
+	c.Assert(readJSON(testDialClient, s.urlPrefix, &ids), IsNil)
+	c.Assert(ids, HasLen, 0)
+	resp, err := testDialClient.Get(s.urlPrefix + "/latest")
+	c.Assert(err, IsNil)
+	resp.Body.Close()
+	c.Assert(resp.StatusCode, Equals, http.StatusNotFound)
+
+	var report cluster.ConformanceReport
+	c.Assert(postJSON(testDialClient, s.urlPrefix, nil, func(res []byte, _ int) {
+		c.Assert(json.Unmarshal(res, &report), IsNil)
+	}), IsNil)
+	c.Assert(report.RegionCount, Equals, 1)
+	c.Assert(report.Classes, HasLen, 4)
+
+	c.Assert(readJSON(testDialClient, s.urlPrefix, &ids), IsNil)
+	c.Assert(ids, DeepEquals, []uint64{report.ID})
+	var latest cluster.ConformanceReport
+	c.Assert(readJSON(testDialClient, s.urlPrefix+"/latest", &latest), IsNil)
+	c.Assert(latest.ID, Equals, report.ID)
+	c.Assert(readJSON(testDialClient, fmt.Sprintf("%s/%d", s.urlPrefix, report.ID), &latest), IsNil)
+	c.Assert(latest.RegionCount, Equals, 1)
+	resp, err = testDialClient.Get(s.urlPrefix + "/abc")
+	c.Assert(err, IsNil)
+	resp.Body.Close()
+	c.Assert(resp.StatusCode, Equals, http.StatusBadRequest)
+
+	// Two reports are needed to compare by default.
+	resp, err = testDialClient.Get(s.urlPrefix + "/diff")
+	c.Assert(err, IsNil)
+	resp.Body.Close()
+	c.Assert(resp.StatusCode, Equals, http.StatusNotFound)
+	var diff cluster.ConformanceReportDiff
+	c.Assert(readJSON(testDialClient, fmt.Sprintf("%s/diff?from=%d&to=%d", s.urlPrefix, report.ID, report.ID), &diff), IsNil)
+	c.Assert(diff.ViolatedRegionCountDelta, Equals, 0)
+}
@@ -0,0 +1,113 @@
+// Copyright 2021 TiKV Project Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package api
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/tikv/pd/pkg/errs"
+	"github.com/tikv/pd/server"
+	"github.com/tikv/pd/server/diagnosis"
+	"github.com/unrolled/render"
+)
+
+type diagnosisHandler struct {
+	svr *server.Server
+	rd  *render.Render
+}
+
+func newDiagnosisHandler(svr *server.Server, rd *render.Render) *diagnosisHandler {
+	return &diagnosisHandler{
+		svr: svr,
+		rd:  rd,
+	}
+}
+
+// @Tags admin
+// @Summary Capture a diagnosis bundle with the heap, goroutine and CPU profiles, a runtime trace, the config and the queue depths.
+// @Param cpu_seconds query integer false "The duration of the CPU profile in seconds, 10 by default"
+// @Produce json
+// @Success 200 {string} string "The name of the captured bundle."
+// @Failure 400 {string} string "The input is invalid."
+// @Failure 409 {string} string "Another bundle is being captured."
+// @Failure 500 {string} string "PD server failed to proceed the request."
+// @Router /admin/diagnosis [post]
+func (h *diagnosisHandler) Capture(w http.ResponseWriter, r *http.Request) {
+	cpuDuration := diagnosis.DefaultCPUDuration
+	if str := r.URL.Query().Get("cpu_seconds"); str != "" {
+		seconds, err := strconv.Atoi(str)
+		if err != nil || seconds <= 0 || time.Duration(seconds)*time.Second > diagnosis.MaxCPUDuration {
+			h.rd.JSON(w, http.StatusBadRequest, "invalid cpu_seconds, it should be in (0, 60]")
+			return
+		}
+		cpuDuration = time.Duration(seconds) * time.Second
+	}
+	name, err := h.svr.GetDiagnosisCapturer().Capture(diagnosis.ManualReason, cpuDuration)
+	if errs.ErrDiagnosisCapturing.Equal(err) {
+		h.rd.JSON(w, http.StatusConflict, err.Error())
+		return
+	}
+	if err != nil {
+		h.rd.JSON(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	h.rd.JSON(w, http.StatusOK, name)
+}
+
+// @Tags admin
+// @Summary List the diagnosis bundles on disk, the newest first.
+// @Produce json
+// @Success 200 {array} diagnosis.BundleInfo
+// @Failure 500 {string} string "PD server failed to proceed the request."
+// @Router /admin/diagnosis [get]
+func (h *diagnosisHandler) List(w http.ResponseWriter, r *http.Request) {
+	infos, err := h.svr.GetDiagnosisCapturer().List()
+	if err != nil {
+		h.rd.JSON(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	h.rd.JSON(w, http.StatusOK, infos)
+}
+
+// @Tags admin
+// @Summary Download a diagnosis bundle.
+// @Param name path string true "The name of the bundle"
+// @Produce application/zip
+// @Success 200 {file} file "The zip archive of the bundle."
+// @Failure 404 {string} string "The bundle is not found."
+// @Failure 500 {string} string "PD server failed to proceed the request."
+// @Router /admin/diagnosis/{name} [get]
+func (h *diagnosisHandler) Download(w http.ResponseWriter, r *http.Request) {
+	name := mux.Vars(r)["name"]
+	f, err := h.svr.GetDiagnosisCapturer().Open(name)
+	if errs.ErrDiagnosisBundleNotFound.Equal(err) {
+		h.rd.JSON(w, http.StatusNotFound, err.Error())
+		return
+	}
+	if err != nil {
+		h.rd.JSON(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	defer f.Close()
+	fi, err := f.Stat()
+	if err != nil {
+		h.rd.JSON(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	w.Header().Set("Content-Disposition", "attachment; filename="+name)
+	http.ServeContent(w, r, name, fi.ModTime(), f)
+}
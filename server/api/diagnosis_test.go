@@ -0,0 +1,57 @@
+// Copyright 2021 TiKV Project Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+
+	. "github.com/pingcap/check"
+	"github.com/tikv/pd/server"
+	"github.com/tikv/pd/server/diagnosis"
+)
+
+var _ = Suite(&testDiagnosisSuite{})
+
+type testDiagnosisSuite struct{}
+
+func (s *testDiagnosisSuite) TestDiagnosis(c *C) {
+	svr, cleanup := mustNewServer(c)
+	defer cleanup()
+	mustWaitLeader(c, []*server.Server{svr})
+	url := fmt.Sprintf("%s%s/api/v1/admin/diagnosis", svr.GetAddr(), apiPrefix)
+
+	c.Assert(postJSON(testDialClient, url+"?cpu_seconds=0", nil), NotNil)
+	var name string
+	c.Assert(postJSON(testDialClient, url+"?cpu_seconds=1", nil, func(res []byte, _ int) {
+		c.Assert(json.Unmarshal(res, &name), IsNil)
+	}), IsNil)
+	c.Assert(diagnosis.IsBundleName(name), IsTrue)
+
+	var infos []*diagnosis.BundleInfo
+	c.Assert(readJSON(testDialClient, url, &infos), IsNil)
+	c.Assert(infos, HasLen, 1)
+	c.Assert(infos[0].Name, Equals, name)
+
+	resp, err := testDialClient.Get(url + "/" + name)
+	c.Assert(err, IsNil)
+	resp.Body.Close()
+	c.Assert(resp.StatusCode, Equals, http.StatusOK)
+	c.Assert(resp.Header.Get("Content-Type"), Equals, "application/zip")
+	resp, err = testDialClient.Get(url + "/diagnosis-1.zip")
+	c.Assert(err, IsNil)
+	resp.Body.Close()
+	c.Assert(resp.StatusCode, Equals, http.StatusNotFound)
+}
@@ -0,0 +1,42 @@
+// Copyright 2021 TiKV Project Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package api
+
+import (
+	"net/http"
+
+	"github.com/tikv/pd/server"
+	"github.com/unrolled/render"
+)
+
+type doctorHandler struct {
+	svr *server.Server
+	rd  *render.Render
+}
+
+func newDoctorHandler(svr *server.Server, rd *render.Render) *doctorHandler {
+	return &doctorHandler{
+		svr: svr,
+		rd:  rd,
+	}
+}
+
+// @Tags doctor
+// @Summary Check the common misconfigurations of the cluster, like the location labels, the isolation of the replicas, the store limits of the huge stores and the backlog of the down peers.
+// @Produce json
+// @Success 200 {object} cluster.DoctorReport
+// @Router /doctor [get]
+func (h *doctorHandler) Get(w http.ResponseWriter, r *http.Request) {
+	h.rd.JSON(w, http.StatusOK, getCluster(r).Diagnose())
+}
@@ -0,0 +1,87 @@
+// Copyright 2021 TiKV Project Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+
+	"github.com/unrolled/render"
+)
+
+type eventsHandler struct {
+	rd *render.Render
+}
+
+func newEventsHandler(rd *render.Render) *eventsHandler {
+	return &eventsHandler{
+		rd: rd,
+	}
+}
+
+func parseSince(r *http.Request) (uint64, error) {
+	sinceStr := r.URL.Query().Get("since")
+	if sinceStr == "" {
+		return 0, nil
+	}
+	return strconv.ParseUint(sinceStr, 10, 64)
+}
+
+// @Tags events
+// @Summary List the cluster lifecycle events whose ID is greater than `since`.
+// @Param since query integer false "The ID of the last received event"
+// @Produce json
+// @Success 200 {array} events.Event
+// @Failure 400 {string} string "The input is invalid."
+// @Router /events [get]
+func (h *eventsHandler) List(w http.ResponseWriter, r *http.Request) {
+	since, err := parseSince(r)
+	if err != nil {
+		h.rd.JSON(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	h.rd.JSON(w, http.StatusOK, getCluster(r).GetEventBus().Since(since))
+}
+
+// @Tags events
+// @Summary Watch the cluster lifecycle events whose ID is greater than `since`. The events are streamed as JSON lines until the client disconnects or falls behind.
+// @Param since query integer false "The ID of the last received event"
+// @Produce json
+// @Success 200 {object} events.Event
+// @Failure 400 {string} string "The input is invalid."
+// @Failure 500 {string} string "Streaming is not supported."
+// @Router /events/watch [get]
+func (h *eventsHandler) Watch(w http.ResponseWriter, r *http.Request) {
+	since, err := parseSince(r)
+	if err != nil {
+		h.rd.JSON(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		h.rd.JSON(w, http.StatusInternalServerError, "streaming is not supported")
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	flusher.Flush()
+	encoder := json.NewEncoder(w)
+	for event := range getCluster(r).GetEventBus().Watch(r.Context(), since) {
+		if err := encoder.Encode(event); err != nil {
+			return
+		}
+		flusher.Flush()
+	}
+}
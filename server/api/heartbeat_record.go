@@ -0,0 +1,153 @@
+// Copyright 2021 TiKV Project Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package api
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/tikv/pd/pkg/errs"
+	"github.com/tikv/pd/server"
+	"github.com/tikv/pd/server/replay"
+	"github.com/unrolled/render"
+)
+
+type heartbeatRecordHandler struct {
+	svr *server.Server
+	rd  *render.Render
+}
+
+func newHeartbeatRecordHandler(svr *server.Server, rd *render.Render) *heartbeatRecordHandler {
+	return &heartbeatRecordHandler{
+		svr: svr,
+		rd:  rd,
+	}
+}
+
+// @Tags admin
+// @Summary Start recording the sampled store and region heartbeats to replay.
+// @Param sample_rate query number false "The ratio of the recorded regions in (0, 1], 1 by default"
+// @Param seconds query integer false "The duration of the recording in seconds, 600 by default"
+// @Produce json
+// @Success 200 {string} string "The name of the record file."
+// @Failure 400 {string} string "The input is invalid."
+// @Failure 409 {string} string "The heartbeats are being recorded."
+// @Failure 500 {string} string "PD server failed to proceed the request."
+// @Router /admin/heartbeat-record [post]
+func (h *heartbeatRecordHandler) Start(w http.ResponseWriter, r *http.Request) {
+	sampleRate := 1.0
+	if str := r.URL.Query().Get("sample_rate"); str != "" {
+		rate, err := strconv.ParseFloat(str, 64)
+		if err != nil || rate <= 0 || rate > 1 {
+			h.rd.JSON(w, http.StatusBadRequest, "invalid sample_rate, it should be in (0, 1]")
+			return
+		}
+		sampleRate = rate
+	}
+	duration := replay.DefaultRecordDuration
+	if str := r.URL.Query().Get("seconds"); str != "" {
+		seconds, err := strconv.Atoi(str)
+		if err != nil || seconds <= 0 || time.Duration(seconds)*time.Second > replay.MaxRecordDuration {
+			h.rd.JSON(w, http.StatusBadRequest, "invalid seconds, it should be in (0, 3600]")
+			return
+		}
+		duration = time.Duration(seconds) * time.Second
+	}
+	stores := getCluster(r).GetMetaStores()
+	name, err := h.svr.GetHeartbeatRecorder().Start(sampleRate, duration, stores)
+	if errs.ErrHeartbeatRecording.Equal(err) {
+		h.rd.JSON(w, http.StatusConflict, err.Error())
+		return
+	}
+	if err != nil {
+		h.rd.JSON(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	h.rd.JSON(w, http.StatusOK, name)
+}
+
+// @Tags admin
+// @Summary Stop recording the heartbeats.
+// @Produce json
+// @Success 200 {object} replay.RecordInfo
+// @Failure 404 {string} string "The heartbeats are not being recorded."
+// @Failure 500 {string} string "PD server failed to proceed the request."
+// @Router /admin/heartbeat-record [delete]
+func (h *heartbeatRecordHandler) Stop(w http.ResponseWriter, r *http.Request) {
+	info, err := h.svr.GetHeartbeatRecorder().Stop()
+	if errs.ErrHeartbeatNotRecording.Equal(err) {
+		h.rd.JSON(w, http.StatusNotFound, err.Error())
+		return
+	}
+	if err != nil {
+		h.rd.JSON(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	h.rd.JSON(w, http.StatusOK, info)
+}
+
+// @Tags admin
+// @Summary Get the status of the running recording.
+// @Produce json
+// @Success 200 {object} replay.RecordStatus
+// @Router /admin/heartbeat-record/status [get]
+func (h *heartbeatRecordHandler) Status(w http.ResponseWriter, r *http.Request) {
+	h.rd.JSON(w, http.StatusOK, h.svr.GetHeartbeatRecorder().Status())
+}
+
+// @Tags admin
+// @Summary List the heartbeat record files on disk, the newest first.
+// @Produce json
+// @Success 200 {array} replay.RecordInfo
+// @Failure 500 {string} string "PD server failed to proceed the request."
+// @Router /admin/heartbeat-record [get]
+func (h *heartbeatRecordHandler) List(w http.ResponseWriter, r *http.Request) {
+	infos, err := h.svr.GetHeartbeatRecorder().List()
+	if err != nil {
+		h.rd.JSON(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	h.rd.JSON(w, http.StatusOK, infos)
+}
+
+// @Tags admin
+// @Summary Download a heartbeat record file.
+// @Param name path string true "The name of the record file"
+// @Produce application/octet-stream
+// @Success 200 {file} file "The record file."
+// @Failure 404 {string} string "The record file is not found."
+// @Failure 500 {string} string "PD server failed to proceed the request."
+// @Router /admin/heartbeat-record/{name} [get]
+func (h *heartbeatRecordHandler) Download(w http.ResponseWriter, r *http.Request) {
+	name := mux.Vars(r)["name"]
+	f, err := h.svr.GetHeartbeatRecorder().Open(name)
+	if errs.ErrHeartbeatRecordNotFound.Equal(err) {
+		h.rd.JSON(w, http.StatusNotFound, err.Error())
+		return
+	}
+	if err != nil {
+		h.rd.JSON(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	defer f.Close()
+	fi, err := f.Stat()
+	if err != nil {
+		h.rd.JSON(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	w.Header().Set("Content-Disposition", "attachment; filename="+name)
+	http.ServeContent(w, r, name, fi.ModTime(), f)
+}
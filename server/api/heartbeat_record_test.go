@@ -0,0 +1,73 @@
+// Copyright 2021 TiKV Project Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+
+	. "github.com/pingcap/check"
+	"github.com/tikv/pd/server"
+	"github.com/tikv/pd/server/replay"
+)
+
+var _ = Suite(&testHeartbeatRecordSuite{})
+
+type testHeartbeatRecordSuite struct{}
+
+func (s *testHeartbeatRecordSuite) TestHeartbeatRecord(c *C) {
+	svr, cleanup := mustNewServer(c)
+	defer cleanup()
+	mustWaitLeader(c, []*server.Server{svr})
+	mustBootstrapCluster(c, svr)
+	url := fmt.Sprintf("%s%s/api/v1/admin/heartbeat-record", svr.GetAddr(), apiPrefix)
+
+	c.Assert(postJSON(testDialClient, url+"?sample_rate=2", nil), NotNil)
+	c.Assert(postJSON(testDialClient, url+"?seconds=0", nil), NotNil)
+	var name string
+	c.Assert(postJSON(testDialClient, url+"?sample_rate=0.5&seconds=60", nil, func(res []byte, _ int) {
+		c.Assert(json.Unmarshal(res, &name), IsNil)
+	}), IsNil)
+	c.Assert(replay.IsRecordName(name), IsTrue)
+	c.Assert(postJSON(testDialClient, url, nil), NotNil)
+
+	var status replay.RecordStatus
+	c.Assert(readJSON(testDialClient, url+"/status", &status), IsNil)
+	c.Assert(status.Recording, IsTrue)
+	c.Assert(status.Name, Equals, name)
+	c.Assert(status.SampleRate, Equals, 0.5)
+
+	resp, err := doDelete(testDialClient, url)
+	c.Assert(err, IsNil)
+	resp.Body.Close()
+	c.Assert(resp.StatusCode, Equals, http.StatusOK)
+	resp, err = doDelete(testDialClient, url)
+	c.Assert(err, IsNil)
+	resp.Body.Close()
+	c.Assert(resp.StatusCode, Equals, http.StatusNotFound)
+
+	var infos []*replay.RecordInfo
+	c.Assert(readJSON(testDialClient, url, &infos), IsNil)
+	c.Assert(infos, HasLen, 1)
+	c.Assert(infos[0].Name, Equals, name)
+	resp, err = testDialClient.Get(url + "/" + name)
+	c.Assert(err, IsNil)
+	resp.Body.Close()
+	c.Assert(resp.StatusCode, Equals, http.StatusOK)
+	resp, err = testDialClient.Get(url + "/heartbeat-1.rec")
+	c.Assert(err, IsNil)
+	resp.Body.Close()
+	c.Assert(resp.StatusCode, Equals, http.StatusNotFound)
+}
@@ -0,0 +1,85 @@
+// Copyright 2021 TiKV Project Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package api
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/tikv/pd/server"
+	"github.com/tikv/pd/server/heatmap"
+	"github.com/unrolled/render"
+)
+
+type heatmapHandler struct {
+	svr *server.Server
+	rd  *render.Render
+}
+
+func newHeatmapHandler(svr *server.Server, rd *render.Render) *heatmapHandler {
+	return &heatmapHandler{
+		svr: svr,
+		rd:  rd,
+	}
+}
+
+func parseUnixTime(r *http.Request, name string, def time.Time) (time.Time, error) {
+	str := r.URL.Query().Get(name)
+	if str == "" {
+		return def, nil
+	}
+	ts, err := strconv.ParseInt(str, 10, 64)
+	if err != nil {
+		return time.Time{}, err
+	}
+	return time.Unix(ts, 0), nil
+}
+
+// @Tags heatmap
+// @Summary Get the key heatmap of the regions rolled up in [start_time, end_time].
+// @Param start_time query integer false "Start Unix timestamp, one hour ago by default"
+// @Param end_time query integer false "End Unix timestamp, now by default"
+// @Param type query string false "The statistics type" Enums(written_bytes, read_bytes, written_keys, read_keys)
+// @Produce json
+// @Success 200 {object} heatmap.Matrix
+// @Failure 400 {string} string "The input is invalid."
+// @Failure 500 {string} string "PD server failed to proceed the request."
+// @Router /heatmap [get]
+func (h *heatmapHandler) Get(w http.ResponseWriter, r *http.Request) {
+	now := time.Now()
+	start, err := parseUnixTime(r, "start_time", now.Add(-time.Hour))
+	if err != nil {
+		h.rd.JSON(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	end, err := parseUnixTime(r, "end_time", now)
+	if err != nil {
+		h.rd.JSON(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	tag := heatmap.WrittenBytes
+	if typ := r.URL.Query().Get("type"); typ != "" {
+		if tag, err = heatmap.ParseStatTag(typ); err != nil {
+			h.rd.JSON(w, http.StatusBadRequest, err.Error())
+			return
+		}
+	}
+	matrix, err := h.svr.GetHeatmapAggregator().GetMatrix(tag, start, end)
+	if err != nil {
+		h.rd.JSON(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	h.rd.JSON(w, http.StatusOK, matrix)
+}
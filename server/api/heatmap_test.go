@@ -0,0 +1,42 @@
+// Copyright 2021 TiKV Project Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package api
+
+import (
+	"fmt"
+
+	. "github.com/pingcap/check"
+	"github.com/tikv/pd/server"
+	"github.com/tikv/pd/server/heatmap"
+)
+
+var _ = Suite(&testHeatmapSuite{})
+
+type testHeatmapSuite struct{}
+
+func (s *testHeatmapSuite) TestHeatmap(c *C) {
+	svr, cleanup := mustNewServer(c)
+	defer cleanup()
+	mustWaitLeader(c, []*server.Server{svr})
+	url := fmt.Sprintf("%s%s/api/v1/heatmap", svr.GetAddr(), apiPrefix)
+
+	var matrix heatmap.Matrix
+	c.Assert(readJSON(testDialClient, url+"?type=read_keys", &matrix), IsNil)
+	c.Assert(matrix.Tag, Equals, heatmap.ReadKeys)
+	c.Assert(matrix.TimeAxis, HasLen, 0)
+	c.Assert(matrix.Data, HasLen, 0)
+
+	c.Assert(readJSON(testDialClient, url+"?type=unknown", &matrix), NotNil)
+	c.Assert(readJSON(testDialClient, url+"?start_time=abc", &matrix), NotNil)
+}
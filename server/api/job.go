@@ -0,0 +1,135 @@
+// Copyright 2021 TiKV Project Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+
+	"github.com/gorilla/mux"
+	"github.com/tikv/pd/pkg/apiutil"
+	"github.com/tikv/pd/pkg/errs"
+	"github.com/tikv/pd/server"
+	"github.com/tikv/pd/server/job"
+	"github.com/unrolled/render"
+)
+
+type jobHandler struct {
+	svr *server.Server
+	rd  *render.Render
+}
+
+func newJobHandler(svr *server.Server, rd *render.Render) *jobHandler {
+	return &jobHandler{
+		svr: svr,
+		rd:  rd,
+	}
+}
+
+type jobInput struct {
+	Type string          `json:"type"`
+	Args json.RawMessage `json:"args"`
+}
+
+// @Tags job
+// @Summary List the jobs of PD, such as scatter-range and split-batch.
+// @Param type query string false "The type of the jobs"
+// @Param state query string false "The state of the jobs" Enums(pending, running, succeeded, failed, canceled)
+// @Produce json
+// @Success 200 {array} job.Job
+// @Router /jobs [get]
+func (h *jobHandler) List(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	jobs := getCluster(r).GetJobManager().GetJobs(query.Get("type"), job.State(query.Get("state")))
+	h.rd.JSON(w, http.StatusOK, jobs)
+}
+
+// @Tags job
+// @Summary Get a job by id.
+// @Param id path integer true "The ID of the job"
+// @Produce json
+// @Success 200 {object} job.Job
+// @Failure 400 {string} string "The input is invalid."
+// @Failure 404 {string} string "The job does not exist."
+// @Router /jobs/{id} [get]
+func (h *jobHandler) Get(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.ParseUint(mux.Vars(r)["id"], 10, 64)
+	if err != nil {
+		h.rd.JSON(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	j := getCluster(r).GetJobManager().GetJob(id)
+	if j == nil {
+		h.rd.JSON(w, http.StatusNotFound, errs.ErrJobNotFound.FastGenByArgs(id).Error())
+		return
+	}
+	h.rd.JSON(w, http.StatusOK, j)
+}
+
+// @Tags job
+// @Summary Submit a job. It is run in the background, and its state can be queried by the ID.
+// @Accept json
+// @Param body body jobInput true "The type and the arguments of the job"
+// @Produce json
+// @Success 200 {object} job.Job
+// @Failure 400 {string} string "The input is invalid."
+// @Failure 500 {string} string "PD server failed to proceed the request."
+// @Router /jobs [post]
+func (h *jobHandler) Submit(w http.ResponseWriter, r *http.Request) {
+	var input jobInput
+	if err := apiutil.ReadJSONRespondError(h.rd, w, r.Body, &input); err != nil {
+		return
+	}
+	j, err := getCluster(r).GetJobManager().Submit(input.Type, input.Args)
+	if err != nil {
+		if errs.ErrJobContent.Equal(err) {
+			h.rd.JSON(w, http.StatusBadRequest, err.Error())
+		} else {
+			h.rd.JSON(w, http.StatusInternalServerError, err.Error())
+		}
+		return
+	}
+	h.rd.JSON(w, http.StatusOK, j)
+}
+
+// @Tags job
+// @Summary Cancel an unfinished job.
+// @Param id path integer true "The ID of the job"
+// @Produce json
+// @Success 200 {string} string "The job is canceled."
+// @Failure 400 {string} string "The input is invalid."
+// @Failure 404 {string} string "The job does not exist."
+// @Failure 409 {string} string "The job has finished."
+// @Failure 500 {string} string "PD server failed to proceed the request."
+// @Router /jobs/{id} [delete]
+func (h *jobHandler) Cancel(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.ParseUint(mux.Vars(r)["id"], 10, 64)
+	if err != nil {
+		h.rd.JSON(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	if err := getCluster(r).GetJobManager().Cancel(id); err != nil {
+		switch {
+		case errs.ErrJobNotFound.Equal(err):
+			h.rd.JSON(w, http.StatusNotFound, err.Error())
+		case errs.ErrJobNotCanceled.Equal(err):
+			h.rd.JSON(w, http.StatusConflict, err.Error())
+		default:
+			h.rd.JSON(w, http.StatusInternalServerError, err.Error())
+		}
+		return
+	}
+	h.rd.JSON(w, http.StatusOK, "The job is canceled.")
+}
@@ -0,0 +1,94 @@
+// Copyright 2021 TiKV Project Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package api
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+
+	. "github.com/pingcap/check"
+	"github.com/tikv/pd/pkg/testutil"
+	"github.com/tikv/pd/server"
+	"github.com/tikv/pd/server/job"
+)
+
+var _ = Suite(&testJobSuite{})
+
+type testJobSuite struct {
+	svr       *server.Server
+	cleanup   cleanUpFunc
+	urlPrefix string
+}
+
+func (s *testJobSuite) SetUpSuite(c *C) {
+	s.svr, s.cleanup = mustNewServer(c)
+	mustWaitLeader(c, []*server.Server{s.svr})
+	addr := s.svr.GetAddr()
+	s.urlPrefix = fmt.Sprintf("%s%s/api/v1/jobs", addr, apiPrefix)
+	mustBootstrapCluster(c, s.svr)
+}
+
+func (s *testJobSuite) TearDownSuite(c *C) {
+	s.cleanup()
+}
+
+func (s *testJobSuite) TestJob(c *C) {
+	resp, err := testDialClient.Post(s.urlPrefix, "application/json", bytes.NewBufferString(`{"type": "unknown"}`))
+	c.Assert(err, IsNil)
+	resp.Body.Close()
+	c.Assert(resp.StatusCode, Equals, http.StatusBadRequest)
+
+	var j job.Job
+	body := []byte(`{"type": "scatter-range", "args": {"start_key": "", "end_key": "", "retry_limit": 0}}`)
+	c.Assert(postJSON(testDialClient, s.urlPrefix, body, func(res []byte, _ int) {
+		c.Assert(json.Unmarshal(res, &j), IsNil)
+	}), IsNil)
+	c.Assert(j.Type, Equals, "scatter-range")
+	c.Assert(j.Owner, Equals, s.svr.Name())
+
+	// The only region is not replicated, so it fails to be scattered, while
+	// the job succeeds with the failure recorded in the checkpoint.
+	url := fmt.Sprintf("%s/%d", s.urlPrefix, j.ID)
+	testutil.WaitUntil(c, func(c *C) bool {
+		c.Assert(readJSON(testDialClient, url, &j), IsNil)
+		return j.State.IsFinished()
+	})
+	c.Assert(j.State, Equals, job.Succeeded)
+	c.Assert(j.Progress, Equals, 1.0)
+	var checkpoint map[string]interface{}
+	c.Assert(json.Unmarshal(j.Checkpoint, &checkpoint), IsNil)
+	c.Assert(checkpoint["failed"], Equals, 1.0)
+
+	var jobs []*job.Job
+	c.Assert(readJSON(testDialClient, s.urlPrefix+"?state=succeeded", &jobs), IsNil)
+	c.Assert(jobs, HasLen, 1)
+	c.Assert(readJSON(testDialClient, s.urlPrefix+"?type=split-batch", &jobs), IsNil)
+	c.Assert(jobs, HasLen, 0)
+
+	for _, t := range []struct {
+		url  string
+		code int
+	}{
+		{url, http.StatusConflict},
+		{s.urlPrefix + "/100000", http.StatusNotFound},
+		{s.urlPrefix + "/abc", http.StatusBadRequest},
+	} {
+		resp, err = doDelete(testDialClient, t.url)
+		c.Assert(err, IsNil)
+		resp.Body.Close()
+		c.Assert(resp.StatusCode, Equals, t.code)
+	}
+}
@@ -0,0 +1,132 @@
+// Copyright 2021 TiKV Project Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package api
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/tikv/pd/pkg/apiutil"
+	"github.com/tikv/pd/pkg/errs"
+	"github.com/tikv/pd/server"
+	"github.com/unrolled/render"
+)
+
+type keyRangeLockHandler struct {
+	svr *server.Server
+	rd  *render.Render
+}
+
+func newKeyRangeLockHandler(svr *server.Server, rd *render.Render) *keyRangeLockHandler {
+	return &keyRangeLockHandler{
+		svr: svr,
+		rd:  rd,
+	}
+}
+
+type keyRangeLockInput struct {
+	Owner       string `json:"owner"`
+	StartKeyHex string `json:"start_key"`
+	EndKeyHex   string `json:"end_key"`
+	// TTL is in seconds, 60 by default and 600 at most.
+	TTL int64 `json:"ttl"`
+}
+
+// @Tags key_range_lock
+// @Summary List all key range locks which are not expired.
+// @Produce json
+// @Success 200 {array} keyrange.Lock
+// @Router /keyrange/locks [get]
+func (h *keyRangeLockHandler) List(w http.ResponseWriter, r *http.Request) {
+	h.rd.JSON(w, http.StatusOK, getCluster(r).GetKeyRangeManager().GetLocks())
+}
+
+// @Tags key_range_lock
+// @Summary Lock a key range. While it is held, the regions in the range are not merged, split or balanced by PD. The lock should be kept alive before it expires.
+// @Accept json
+// @Param body body keyRangeLockInput true "The owner, the hex encoded range and the TTL in seconds of the lock"
+// @Produce json
+// @Success 200 {object} keyrange.Lock
+// @Failure 400 {string} string "The input is invalid."
+// @Failure 409 {string} string "The range is locked by another owner."
+// @Failure 500 {string} string "PD server failed to proceed the request."
+// @Router /keyrange/locks [post]
+func (h *keyRangeLockHandler) Acquire(w http.ResponseWriter, r *http.Request) {
+	var input keyRangeLockInput
+	if err := apiutil.ReadJSONRespondError(h.rd, w, r.Body, &input); err != nil {
+		return
+	}
+	lock, err := getCluster(r).GetKeyRangeManager().Acquire(input.Owner, input.StartKeyHex, input.EndKeyHex, time.Duration(input.TTL)*time.Second)
+	if err != nil {
+		h.handleErr(w, err)
+		return
+	}
+	h.rd.JSON(w, http.StatusOK, lock)
+}
+
+// @Tags key_range_lock
+// @Summary Keep a key range lock alive for the TTL from now.
+// @Accept json
+// @Param id path string true "The ID of the lock"
+// @Param body body object false "json params, e.g. {\"ttl\": 60}"
+// @Produce json
+// @Success 200 {object} keyrange.Lock
+// @Failure 400 {string} string "The input is invalid."
+// @Failure 404 {string} string "The lock does not exist or has expired."
+// @Failure 500 {string} string "PD server failed to proceed the request."
+// @Router /keyrange/locks/{id}/keepalive [post]
+func (h *keyRangeLockHandler) KeepAlive(w http.ResponseWriter, r *http.Request) {
+	var input keyRangeLockInput
+	if r.ContentLength != 0 {
+		if err := apiutil.ReadJSONRespondError(h.rd, w, r.Body, &input); err != nil {
+			return
+		}
+	}
+	lock, err := getCluster(r).GetKeyRangeManager().KeepAlive(mux.Vars(r)["id"], time.Duration(input.TTL)*time.Second)
+	if err != nil {
+		h.handleErr(w, err)
+		return
+	}
+	h.rd.JSON(w, http.StatusOK, lock)
+}
+
+// @Tags key_range_lock
+// @Summary Release a key range lock.
+// @Param id path string true "The ID of the lock"
+// @Produce json
+// @Success 200 {string} string "The lock is released."
+// @Failure 404 {string} string "The lock does not exist or has expired."
+// @Failure 500 {string} string "PD server failed to proceed the request."
+// @Router /keyrange/locks/{id} [delete]
+func (h *keyRangeLockHandler) Release(w http.ResponseWriter, r *http.Request) {
+	if err := getCluster(r).GetKeyRangeManager().Release(mux.Vars(r)["id"]); err != nil {
+		h.handleErr(w, err)
+		return
+	}
+	h.rd.JSON(w, http.StatusOK, "The lock is released.")
+}
+
+func (h *keyRangeLockHandler) handleErr(w http.ResponseWriter, err error) {
+	switch {
+	case errs.ErrKeyRangeLockContent.Equal(err) || errs.ErrHexDecodingString.Equal(err):
+		h.rd.JSON(w, http.StatusBadRequest, err.Error())
+	case errs.ErrKeyRangeLocked.Equal(err):
+		h.rd.JSON(w, http.StatusConflict, err.Error())
+	case errs.ErrKeyRangeLockNotFound.Equal(err):
+		h.rd.JSON(w, http.StatusNotFound, err.Error())
+	default:
+		h.rd.JSON(w, http.StatusInternalServerError, err.Error())
+	}
+}
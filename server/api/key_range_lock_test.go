@@ -0,0 +1,87 @@
+// Copyright 2021 TiKV Project Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package api
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+
+	. "github.com/pingcap/check"
+	"github.com/tikv/pd/server"
+	"github.com/tikv/pd/server/schedule/keyrange"
+)
+
+var _ = Suite(&testKeyRangeLockSuite{})
+
+type testKeyRangeLockSuite struct {
+	svr       *server.Server
+	cleanup   cleanUpFunc
+	urlPrefix string
+}
+
+func (s *testKeyRangeLockSuite) SetUpSuite(c *C) {
+	s.svr, s.cleanup = mustNewServer(c)
+	mustWaitLeader(c, []*server.Server{s.svr})
+	addr := s.svr.GetAddr()
+	s.urlPrefix = fmt.Sprintf("%s%s/api/v1/keyrange/locks", addr, apiPrefix)
+	mustBootstrapCluster(c, s.svr)
+}
+
+func (s *testKeyRangeLockSuite) TearDownSuite(c *C) {
+	s.cleanup()
+}
+
+func (s *testKeyRangeLockSuite) TestKeyRangeLock(c *C) {
+	var lock keyrange.Lock
+	c.Assert(postJSON(testDialClient, s.urlPrefix, []byte(`{"owner": "br", "start_key": "61", "end_key": "63", "ttl": 30}`), func(res []byte, _ int) {
+		c.Assert(json.Unmarshal(res, &lock), IsNil)
+	}), IsNil)
+	c.Assert(lock.Owner, Equals, "br")
+
+	for _, t := range []struct {
+		body string
+		code int
+	}{
+		{`{"owner": "lightning", "start_key": "62", "end_key": ""}`, http.StatusConflict},
+		{`{"owner": "lightning", "start_key": "zz", "end_key": ""}`, http.StatusBadRequest},
+		{`{"owner": "lightning", "start_key": "63", "end_key": "", "ttl": 3600}`, http.StatusBadRequest},
+	} {
+		resp, err := testDialClient.Post(s.urlPrefix, "application/json", bytes.NewBufferString(t.body))
+		c.Assert(err, IsNil)
+		resp.Body.Close()
+		c.Assert(resp.StatusCode, Equals, t.code)
+	}
+
+	var locks []*keyrange.Lock
+	c.Assert(readJSON(testDialClient, s.urlPrefix, &locks), IsNil)
+	c.Assert(locks, HasLen, 1)
+	c.Assert(locks[0].ID, Equals, lock.ID)
+
+	var renewed keyrange.Lock
+	c.Assert(postJSON(testDialClient, fmt.Sprintf("%s/%s/keepalive", s.urlPrefix, lock.ID), []byte(`{"ttl": 60}`), func(res []byte, _ int) {
+		c.Assert(json.Unmarshal(res, &renewed), IsNil)
+	}), IsNil)
+	c.Assert(renewed.Expire.After(lock.Expire), IsTrue)
+	c.Assert(postJSON(testDialClient, fmt.Sprintf("%s/%s/keepalive", s.urlPrefix, lock.ID), nil), IsNil)
+
+	resp, err := doDelete(testDialClient, fmt.Sprintf("%s/%s", s.urlPrefix, lock.ID))
+	c.Assert(err, IsNil)
+	c.Assert(resp.StatusCode, Equals, http.StatusOK)
+	resp, err = doDelete(testDialClient, fmt.Sprintf("%s/%s", s.urlPrefix, lock.ID))
+	c.Assert(err, IsNil)
+	c.Assert(resp.StatusCode, Equals, http.StatusNotFound)
+	c.Assert(postJSON(testDialClient, fmt.Sprintf("%s/%s/keepalive", s.urlPrefix, lock.ID), nil), NotNil)
+}
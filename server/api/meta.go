@@ -0,0 +1,80 @@
+// Copyright 2021 TiKV Project Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package api
+
+import (
+	"net/http"
+
+	"github.com/tikv/pd/pkg/apiutil"
+	"github.com/tikv/pd/pkg/errs"
+	"github.com/tikv/pd/server"
+	"github.com/unrolled/render"
+)
+
+type metaHandler struct {
+	svr *server.Server
+	rd  *render.Render
+}
+
+func newMetaHandler(svr *server.Server, rd *render.Render) *metaHandler {
+	return &metaHandler{
+		svr: svr,
+		rd:  rd,
+	}
+}
+
+// @Tags meta
+// @Summary Dump the PD-owned meta into an archive, including the config, the placement rules, the region labels and the scheduler configs.
+// @Produce json
+// @Success 200 {object} server.MetaArchive
+// @Failure 500 {string} string "PD server failed to proceed the request."
+// @Router /meta/backup [get]
+func (h *metaHandler) Backup(w http.ResponseWriter, r *http.Request) {
+	archive, err := h.svr.BackupMeta()
+	if err != nil {
+		h.rd.JSON(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	h.rd.JSON(w, http.StatusOK, archive)
+}
+
+// @Tags meta
+// @Summary Restore the PD-owned meta from an archive in a transaction. The cluster must not be bootstrapped.
+// @Accept json
+// @Param body body server.MetaArchive true "The meta archive"
+// @Param dry-run query string false "Show the changes without applying them" Enums(true, false)
+// @Produce json
+// @Success 200 {object} server.MetaRestoreResult
+// @Failure 400 {string} string "The archive is invalid."
+// @Failure 409 {string} string "The cluster is bootstrapped."
+// @Failure 500 {string} string "PD server failed to proceed the request."
+// @Router /meta/restore [post]
+func (h *metaHandler) Restore(w http.ResponseWriter, r *http.Request) {
+	var archive server.MetaArchive
+	if err := apiutil.ReadJSONRespondError(h.rd, w, r.Body, &archive); err != nil {
+		return
+	}
+	dryRun := r.URL.Query().Get("dry-run") == "true"
+	result, err := h.svr.RestoreMeta(&archive, dryRun)
+	switch {
+	case errs.ErrMetaArchiveVersion.Equal(err) || errs.ErrInvalidMetaArchive.Equal(err):
+		h.rd.JSON(w, http.StatusBadRequest, err.Error())
+	case errs.ErrRestoreMetaBootstrapped.Equal(err):
+		h.rd.JSON(w, http.StatusConflict, err.Error())
+	case err != nil:
+		h.rd.JSON(w, http.StatusInternalServerError, err.Error())
+	default:
+		h.rd.JSON(w, http.StatusOK, result)
+	}
+}
@@ -0,0 +1,100 @@
+// Copyright 2021 TiKV Project Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package api
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+
+	. "github.com/pingcap/check"
+	"github.com/tikv/pd/server"
+)
+
+var _ = Suite(&testMetaSuite{})
+
+type testMetaSuite struct{}
+
+func (s *testMetaSuite) postRestore(c *C, urlPrefix string, query string, data []byte) int {
+	resp, err := testDialClient.Post(urlPrefix+"/meta/restore"+query, "application/json", bytes.NewBuffer(data))
+	c.Assert(err, IsNil)
+	defer resp.Body.Close()
+	return resp.StatusCode
+}
+
+func (s *testMetaSuite) TestBackupRestore(c *C) {
+	source, cleanSource := mustNewServer(c)
+	defer cleanSource()
+	mustBootstrapCluster(c, source)
+	target, cleanTarget := mustNewServer(c)
+	defer cleanTarget()
+	sourcePrefix := fmt.Sprintf("%s%s/api/v1", source.GetAddr(), apiPrefix)
+	targetPrefix := fmt.Sprintf("%s%s/api/v1", target.GetAddr(), apiPrefix)
+
+	err := postJSON(testDialClient, sourcePrefix+"/config", []byte(`{"leader-schedule-limit":7}`))
+	c.Assert(err, IsNil)
+	rule := `{"id":"r1","labels":[{"key":"k","value":"v"}],"start_key":"61","end_key":"63"}`
+	err = postJSON(testDialClient, sourcePrefix+"/config/region-label/rule", []byte(rule))
+	c.Assert(err, IsNil)
+
+	var archive server.MetaArchive
+	err = readJSON(testDialClient, sourcePrefix+"/meta/backup", &archive)
+	c.Assert(err, IsNil)
+	c.Assert(archive.Version, Equals, server.MetaArchiveVersion)
+	c.Assert(archive.ClusterID, Equals, source.ClusterID())
+	c.Assert(archive.Sections["region-labels"], HasKey, "region_label/r1")
+	c.Assert(archive.Sections["rules"], Not(HasLen), 0)
+	data, err := json.Marshal(archive)
+	c.Assert(err, IsNil)
+
+	// The dry run shows the changes without applying them.
+	var result server.MetaRestoreResult
+	err = postJSON(testDialClient, targetPrefix+"/meta/restore?dry-run=true", data, func(res []byte, code int) {
+		c.Assert(code, Equals, http.StatusOK)
+		c.Assert(json.Unmarshal(res, &result), IsNil)
+	})
+	c.Assert(err, IsNil)
+	c.Assert(result.DryRun, IsTrue)
+	diffs := make(map[string]string)
+	for _, d := range result.Diffs {
+		diffs[d.Key] = d.Type
+	}
+	c.Assert(diffs["region_label/r1"], Equals, server.MetaDiffAdd)
+	c.Assert(diffs, HasKey, "config")
+	c.Assert(target.GetScheduleConfig().LeaderScheduleLimit, Not(Equals), uint64(7))
+
+	err = postJSON(testDialClient, targetPrefix+"/meta/restore", data)
+	c.Assert(err, IsNil)
+	c.Assert(target.GetScheduleConfig().LeaderScheduleLimit, Equals, uint64(7))
+	var restored server.MetaArchive
+	err = readJSON(testDialClient, targetPrefix+"/meta/backup", &restored)
+	c.Assert(err, IsNil)
+	c.Assert(restored.Sections, DeepEquals, archive.Sections)
+
+	// The restored meta is loaded when the cluster is bootstrapped.
+	mustBootstrapCluster(c, target)
+	c.Assert(target.GetRaftCluster().GetRegionLabeler().GetLabelRule("r1"), NotNil)
+	c.Assert(s.postRestore(c, targetPrefix, "", data), Equals, http.StatusConflict)
+
+	archive.Version = server.MetaArchiveVersion + 1
+	data, err = json.Marshal(archive)
+	c.Assert(err, IsNil)
+	c.Assert(s.postRestore(c, targetPrefix, "?dry-run=true", data), Equals, http.StatusBadRequest)
+	archive.Version = server.MetaArchiveVersion
+	archive.Sections["rules"]["gc/safe_point"] = "1"
+	data, err = json.Marshal(archive)
+	c.Assert(err, IsNil)
+	c.Assert(s.postRestore(c, targetPrefix, "?dry-run=true", data), Equals, http.StatusBadRequest)
+}
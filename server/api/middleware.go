@@ -17,6 +17,7 @@ import (
 	"context"
 	"net/http"
 
+	"github.com/tikv/pd/pkg/apiutil/serverapi"
 	"github.com/tikv/pd/pkg/errs"
 	"github.com/tikv/pd/server"
 	"github.com/tikv/pd/server/cluster"
@@ -39,6 +40,11 @@ func (m clusterMiddleware) Middleware(h http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		rc := m.s.GetRaftCluster()
 		if rc == nil {
+			// Only the leader runs the cluster, so the follower gateway
+			// forwards the request to it.
+			if serverapi.ForwardToLeader(w, r) {
+				return
+			}
 			m.rd.JSON(w, http.StatusInternalServerError, errs.ErrNotBootstrapped.FastGenByArgs().Error())
 			return
 		}
@@ -52,3 +58,44 @@ type clusterCtxKey struct{}
 func getCluster(r *http.Request) *cluster.RaftCluster {
 	return r.Context().Value(clusterCtxKey{}).(*cluster.RaftCluster)
 }
+
+// leaderMiddleware forwards the requests which the follower gateway handles
+// locally to the leader, for the handlers which are not in the cluster router
+// but still need the running cluster, or the options which are only up to
+// date on the leader.
+type leaderMiddleware struct {
+	s *server.Server
+}
+
+func newLeaderMiddleware(s *server.Server) leaderMiddleware {
+	return leaderMiddleware{s: s}
+}
+
+func (m leaderMiddleware) Middleware(h http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if m.s.GetRaftCluster() == nil && serverapi.ForwardToLeader(w, r) {
+			return
+		}
+		h(w, r)
+	}
+}
+
+// degradationMiddleware rejects the requests when PD is in the degraded mode.
+type degradationMiddleware struct {
+	s  *server.Server
+	rd *render.Render
+}
+
+func newDegradationMiddleware(s *server.Server, rd *render.Render) degradationMiddleware {
+	return degradationMiddleware{s: s, rd: rd}
+}
+
+func (m degradationMiddleware) Middleware(h http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if m.s.GetDegradationController().IsDegraded() {
+			m.rd.JSON(w, http.StatusServiceUnavailable, errs.ErrServerDegraded.FastGenByArgs().Error())
+			return
+		}
+		h(w, r)
+	}
+}
@@ -107,6 +107,51 @@ func (h *operatorHandler) List(w http.ResponseWriter, r *http.Request) {
 	h.r.JSON(w, http.StatusOK, results)
 }
 
+// @Tags operator
+// @Summary Get the status of the waiting operators of each store, which is empty if the store fair queue is disabled.
+// @Produce json
+// @Success 200 {array} schedule.WaitingStoreStatus
+// @Failure 500 {string} string "PD server failed to proceed the request."
+// @Router /operators/waiting-queue [get]
+func (h *operatorHandler) GetWaitingQueue(w http.ResponseWriter, r *http.Request) {
+	status, err := h.GetWaitingQueueStatus()
+	if err != nil {
+		h.r.JSON(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	h.r.JSON(w, http.StatusOK, status)
+}
+
+// @Tags operator
+// @Summary Get the status of the waiting operators of each keyspace, which is empty if the keyspace fair queue is disabled.
+// @Produce json
+// @Success 200 {array} schedule.WaitingKeyspaceStatus
+// @Failure 500 {string} string "PD server failed to proceed the request."
+// @Router /operators/waiting-keyspaces [get]
+func (h *operatorHandler) GetWaitingKeyspaces(w http.ResponseWriter, r *http.Request) {
+	status, err := h.GetWaitingKeyspaceStatus()
+	if err != nil {
+		h.r.JSON(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	h.r.JSON(w, http.StatusOK, status)
+}
+
+// @Tags operator
+// @Summary Get the regions on which the operators have reverted each other recently, and the conflicting operators.
+// @Produce json
+// @Success 200 {array} schedule.OperatorConflict
+// @Failure 500 {string} string "PD server failed to proceed the request."
+// @Router /operators/conflicts [get]
+func (h *operatorHandler) GetConflicts(w http.ResponseWriter, r *http.Request) {
+	conflicts, err := h.GetOperatorConflicts()
+	if err != nil {
+		h.r.JSON(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	h.r.JSON(w, http.StatusOK, conflicts)
+}
+
 // FIXME: details of input json body params
 // @Tags operator
 // @Summary Create an operator.
@@ -0,0 +1,134 @@
+// Copyright 2021 TiKV Project Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package api
+
+import (
+	"net/http"
+
+	"github.com/gorilla/mux"
+	"github.com/tikv/pd/pkg/apiutil"
+	"github.com/tikv/pd/pkg/errs"
+	"github.com/tikv/pd/server"
+	"github.com/unrolled/render"
+)
+
+type rbacHandler struct {
+	svr *server.Server
+	rd  *render.Render
+}
+
+func newRBACHandler(svr *server.Server, rd *render.Render) *rbacHandler {
+	return &rbacHandler{
+		svr: svr,
+		rd:  rd,
+	}
+}
+
+func (h *rbacHandler) respondError(w http.ResponseWriter, err error) {
+	if errs.ErrRBACNoAdmin.Equal(err) || errs.ErrRBACInvalidBinding.Equal(err) {
+		h.rd.JSON(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	h.rd.JSON(w, http.StatusInternalServerError, err.Error())
+}
+
+// @Tags rbac
+// @Summary Get the state of RBAC and the role bindings without the tokens.
+// @Produce json
+// @Success 200 {object} rbac.Config
+// @Failure 500 {string} string "PD server failed to proceed the request."
+// @Router /rbac [get]
+func (h *rbacHandler) Get(w http.ResponseWriter, r *http.Request) {
+	config, err := h.svr.GetRBACManager().GetConfig()
+	if err != nil {
+		h.rd.JSON(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	h.rd.JSON(w, http.StatusOK, config)
+}
+
+type rbacEnabledInput struct {
+	Enabled bool `json:"enabled"`
+}
+
+// @Tags rbac
+// @Summary Enable or disable RBAC. It cannot be enabled without an admin binding.
+// @Accept json
+// @Param body body rbacEnabledInput true "json params"
+// @Produce json
+// @Success 200 {string} string "RBAC is updated."
+// @Failure 400 {string} string "The input is invalid."
+// @Failure 500 {string} string "PD server failed to proceed the request."
+// @Router /rbac/enabled [post]
+func (h *rbacHandler) SetEnabled(w http.ResponseWriter, r *http.Request) {
+	var input rbacEnabledInput
+	if err := apiutil.ReadJSONRespondError(h.rd, w, r.Body, &input); err != nil {
+		return
+	}
+	if err := h.svr.GetRBACManager().SetEnabled(input.Enabled); err != nil {
+		h.respondError(w, err)
+		return
+	}
+	h.rd.JSON(w, http.StatusOK, "RBAC is updated.")
+}
+
+type rbacBindingInput struct {
+	// Kind is cert or token.
+	Kind string `json:"kind"`
+	// Name is the common name of the certificate, or the name of the token.
+	Name string `json:"name"`
+	// Role is viewer, operator or admin.
+	Role string `json:"role"`
+	// Token is required for a token binding. Only its hash is persisted.
+	Token string `json:"token,omitempty"`
+}
+
+// @Tags rbac
+// @Summary Grant a role to a certificate or a token, replacing its former binding.
+// @Accept json
+// @Param body body rbacBindingInput true "json params"
+// @Produce json
+// @Success 200 {string} string "The role binding is updated."
+// @Failure 400 {string} string "The input is invalid."
+// @Failure 500 {string} string "PD server failed to proceed the request."
+// @Router /rbac/binding [post]
+func (h *rbacHandler) SetBinding(w http.ResponseWriter, r *http.Request) {
+	var input rbacBindingInput
+	if err := apiutil.ReadJSONRespondError(h.rd, w, r.Body, &input); err != nil {
+		return
+	}
+	if err := h.svr.GetRBACManager().SetBinding(input.Kind, input.Name, input.Role, input.Token); err != nil {
+		h.respondError(w, err)
+		return
+	}
+	h.rd.JSON(w, http.StatusOK, "The role binding is updated.")
+}
+
+// @Tags rbac
+// @Summary Delete the role binding. The last admin binding cannot be deleted when RBAC is enabled.
+// @Param kind path string true "cert or token"
+// @Param name path string true "The name of the binding"
+// @Produce json
+// @Success 200 {string} string "The role binding is deleted."
+// @Failure 400 {string} string "The input is invalid."
+// @Failure 500 {string} string "PD server failed to proceed the request."
+// @Router /rbac/binding/{kind}/{name} [delete]
+func (h *rbacHandler) DeleteBinding(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	if err := h.svr.GetRBACManager().DeleteBinding(vars["kind"], vars["name"]); err != nil {
+		h.respondError(w, err)
+		return
+	}
+	h.rd.JSON(w, http.StatusOK, "The role binding is deleted.")
+}
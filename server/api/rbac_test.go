@@ -0,0 +1,102 @@
+// Copyright 2021 TiKV Project Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package api
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+
+	. "github.com/pingcap/check"
+	"github.com/tikv/pd/server"
+	"github.com/tikv/pd/server/rbac"
+)
+
+var _ = Suite(&testRBACSuite{})
+
+type testRBACSuite struct {
+	svr       *server.Server
+	cleanup   cleanUpFunc
+	urlPrefix string
+}
+
+func (s *testRBACSuite) SetUpSuite(c *C) {
+	s.svr, s.cleanup = mustNewServer(c)
+	mustWaitLeader(c, []*server.Server{s.svr})
+
+	addr := s.svr.GetAddr()
+	s.urlPrefix = fmt.Sprintf("%s%s/api/v1", addr, apiPrefix)
+
+	mustBootstrapCluster(c, s.svr)
+}
+
+func (s *testRBACSuite) TearDownSuite(c *C) {
+	s.cleanup()
+}
+
+func (s *testRBACSuite) request(c *C, method, path, token string, input interface{}) int {
+	var body []byte
+	if input != nil {
+		var err error
+		body, err = json.Marshal(input)
+		c.Assert(err, IsNil)
+	}
+	req, err := http.NewRequest(method, s.urlPrefix+path, bytes.NewBuffer(body))
+	c.Assert(err, IsNil)
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	resp, err := testDialClient.Do(req)
+	c.Assert(err, IsNil)
+	resp.Body.Close()
+	return resp.StatusCode
+}
+
+func (s *testRBACSuite) TestRBAC(c *C) {
+	c.Assert(s.request(c, http.MethodPost, "/rbac/enabled", "", rbacEnabledInput{Enabled: true}), Equals, http.StatusBadRequest)
+	for _, b := range []rbacBindingInput{
+		{Kind: rbac.KindToken, Name: "viewer", Role: rbac.RoleViewer, Token: "viewer-token"},
+		{Kind: rbac.KindToken, Name: "operator", Role: rbac.RoleOperator, Token: "operator-token"},
+		{Kind: rbac.KindToken, Name: "admin", Role: rbac.RoleAdmin, Token: "admin-token"},
+	} {
+		c.Assert(s.request(c, http.MethodPost, "/rbac/binding", "", b), Equals, http.StatusOK)
+	}
+	c.Assert(s.request(c, http.MethodPost, "/rbac/enabled", "", rbacEnabledInput{Enabled: true}), Equals, http.StatusOK)
+
+	c.Assert(s.request(c, http.MethodGet, "/stores", "", nil), Equals, http.StatusUnauthorized)
+	c.Assert(s.request(c, http.MethodGet, "/stores", "wrong", nil), Equals, http.StatusUnauthorized)
+	c.Assert(s.request(c, http.MethodGet, "/stores", "viewer-token", nil), Equals, http.StatusOK)
+	c.Assert(s.request(c, http.MethodPost, "/config", "viewer-token", map[string]interface{}{}), Equals, http.StatusForbidden)
+	c.Assert(s.request(c, http.MethodPost, "/config", "operator-token", map[string]interface{}{}), Equals, http.StatusOK)
+	c.Assert(s.request(c, http.MethodDelete, "/stores/remove-tombstone", "operator-token", nil), Equals, http.StatusForbidden)
+	c.Assert(s.request(c, http.MethodDelete, "/stores/remove-tombstone", "admin-token", nil), Equals, http.StatusOK)
+	c.Assert(s.request(c, http.MethodGet, "/rbac", "operator-token", nil), Equals, http.StatusForbidden)
+
+	var config rbac.Config
+	req, err := http.NewRequest(http.MethodGet, s.urlPrefix+"/rbac", nil)
+	c.Assert(err, IsNil)
+	req.Header.Set("Authorization", "Bearer admin-token")
+	resp, err := testDialClient.Do(req)
+	c.Assert(err, IsNil)
+	c.Assert(json.NewDecoder(resp.Body).Decode(&config), IsNil)
+	resp.Body.Close()
+	c.Assert(config.Enabled, IsTrue)
+	c.Assert(config.Bindings, HasLen, 3)
+
+	// The last admin cannot be deleted.
+	c.Assert(s.request(c, http.MethodDelete, "/rbac/binding/token/admin", "admin-token", nil), Equals, http.StatusBadRequest)
+	c.Assert(s.request(c, http.MethodPost, "/rbac/enabled", "admin-token", rbacEnabledInput{Enabled: false}), Equals, http.StatusOK)
+	c.Assert(s.request(c, http.MethodGet, "/stores", "", nil), Equals, http.StatusOK)
+}
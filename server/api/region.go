@@ -30,6 +30,7 @@ import (
 	log "github.com/sirupsen/logrus"
 	"github.com/tikv/pd/pkg/apiutil"
 	"github.com/tikv/pd/server"
+	"github.com/tikv/pd/server/cluster"
 	"github.com/tikv/pd/server/core"
 	"github.com/tikv/pd/server/schedule/operator"
 	"github.com/tikv/pd/server/statistics"
@@ -215,6 +216,29 @@ func (h *regionHandler) GetRegionByID(w http.ResponseWriter, r *http.Request) {
 	h.rd.JSON(w, http.StatusOK, NewRegionInfo(regionInfo))
 }
 
+// @Tags region
+// @Summary Get the lineage of a region, which tells where the region goes after it is split or merged.
+// @Param id path integer true "Region Id"
+// @Produce json
+// @Success 200 {object} cluster.RegionHistory
+// @Failure 400 {string} string "The input is invalid."
+// @Failure 404 {string} string "The region has no history."
+// @Router /region/id/{id}/history [get]
+func (h *regionHandler) GetRegionHistory(w http.ResponseWriter, r *http.Request) {
+	rc := getCluster(r)
+	regionID, err := strconv.ParseUint(mux.Vars(r)["id"], 10, 64)
+	if err != nil {
+		h.rd.JSON(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	history := rc.GetRegionHistory(regionID)
+	if history == nil {
+		h.rd.JSON(w, http.StatusNotFound, "region has no history")
+		return
+	}
+	h.rd.JSON(w, http.StatusOK, history)
+}
+
 // @Tags region
 // @Summary Search for a region by a key.
 // @Param key path string true "Region key"
@@ -347,6 +371,31 @@ func (h *regionsHandler) GetMissPeerRegions(w http.ResponseWriter, r *http.Reque
 	h.rd.JSON(w, http.StatusOK, regionsInfo)
 }
 
+// @Tags region
+// @Summary List all regions in the class of how they fit the placement rules.
+// @Param class path string true "The rule fit class" Enums(fully-fit, miss-peer, wrong-role, orphan-peer, isolation-violated)
+// @Produce json
+// @Success 200 {object} RegionsInfo
+// @Failure 400 {string} string "The input is invalid."
+// @Failure 500 {string} string "PD server failed to proceed the request."
+// @Router /regions/check/rule-fit/{class} [get]
+func (h *regionsHandler) GetRuleFitRegions(w http.ResponseWriter, r *http.Request) {
+	class := mux.Vars(r)["class"]
+	typ, ok := statistics.RuleFitClasses[class]
+	if !ok {
+		h.rd.JSON(w, http.StatusBadRequest, fmt.Sprintf("unknown rule fit class %s", class))
+		return
+	}
+	handler := h.svr.GetHandler()
+	regions, err := handler.GetRegionsByType(typ)
+	if err != nil {
+		h.rd.JSON(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	regionsInfo := convertToAPIRegions(regions)
+	h.rd.JSON(w, http.StatusOK, regionsInfo)
+}
+
 // @Tags region
 // @Summary List all regions that has extra peer.
 // @Produce json
@@ -432,6 +481,57 @@ func (h *regionsHandler) GetOfflinePeer(w http.ResponseWriter, r *http.Request)
 	h.rd.JSON(w, http.StatusOK, regionsInfo)
 }
 
+// @Tags region
+// @Summary List the overlaps and the gaps of the regions found by the last region consistency check.
+// @Produce json
+// @Success 200 {array} core.RegionInconsistency
+// @Router /regions/check/inconsistency [get]
+func (h *regionsHandler) GetRegionInconsistencies(w http.ResponseWriter, r *http.Request) {
+	rc := getCluster(r)
+	h.rd.JSON(w, http.StatusOK, rc.GetRegionInconsistencies())
+}
+
+// @Tags region
+// @Summary Check the overlaps and the gaps of the regions immediately. The overlapped regions are removed from the cache if the repair is enabled.
+// @Produce json
+// @Success 200 {array} core.RegionInconsistency
+// @Router /regions/check/inconsistency [post]
+func (h *regionsHandler) CheckRegionConsistency(w http.ResponseWriter, r *http.Request) {
+	rc := getCluster(r)
+	h.rd.JSON(w, http.StatusOK, rc.CheckRegionConsistency())
+}
+
+// @Tags region
+// @Summary List the regions in trouble for longer than the threshold, such as the regions with down peers, with pending peers or without a leader. The regions to triage first are listed first.
+// @Produce json
+// @Success 200 {array} cluster.WatchedRegion
+// @Router /regions/watchlist [get]
+func (h *regionsHandler) GetRegionWatchlist(w http.ResponseWriter, r *http.Request) {
+	rc := getCluster(r)
+	h.rd.JSON(w, http.StatusOK, rc.GetRegionWatchlist())
+}
+
+// @Tags region
+// @Summary Get the per-stage breakdown of the cost of processing the region heartbeats, and the regions with the costliest heartbeats.
+// @Produce json
+// @Success 200 {object} cluster.HeartbeatProfile
+// @Router /regions/heartbeat-profile [get]
+func (h *regionsHandler) GetHeartbeatProfile(w http.ResponseWriter, r *http.Request) {
+	rc := getCluster(r)
+	h.rd.JSON(w, http.StatusOK, rc.GetHeartbeatProfile())
+}
+
+// @Tags region
+// @Summary Reset the profile of the region heartbeats.
+// @Produce json
+// @Success 200 {string} string "The profile is reset."
+// @Router /regions/heartbeat-profile [delete]
+func (h *regionsHandler) ResetHeartbeatProfile(w http.ResponseWriter, r *http.Request) {
+	rc := getCluster(r)
+	rc.ResetHeartbeatProfile()
+	h.rd.JSON(w, http.StatusOK, "The profile is reset.")
+}
+
 // @Tags region
 // @Summary List all empty regions.
 // @Produce json
@@ -694,7 +794,7 @@ func (h *regionsHandler) AccelerateRegionsScheduleInRange(w http.ResponseWriter,
 		for _, region := range regions {
 			regionsIDList = append(regionsIDList, region.GetID())
 		}
-		rc.AddSuspectRegions(regionsIDList...)
+		rc.AddPrioritySuspectRegions(cluster.SuspectAccelerated, regionsIDList...)
 	}
 	h.rd.Text(w, http.StatusOK, fmt.Sprintf("Accelerate regions scheduling in a given range [%s,%s)", rawStartKey, rawEndKey))
 }
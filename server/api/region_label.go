@@ -0,0 +1,166 @@
+// Copyright 2021 TiKV Project Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package api
+
+import (
+	"io"
+	"net/http"
+
+	"github.com/gorilla/mux"
+	"github.com/tikv/pd/pkg/apiutil"
+	"github.com/tikv/pd/pkg/errs"
+	"github.com/tikv/pd/server"
+	"github.com/tikv/pd/server/schedule/labeler"
+	"github.com/unrolled/render"
+)
+
+type regionLabelHandler struct {
+	svr *server.Server
+	rd  *render.Render
+}
+
+func newRegionLabelHandler(svr *server.Server, rd *render.Render) *regionLabelHandler {
+	return &regionLabelHandler{
+		svr: svr,
+		rd:  rd,
+	}
+}
+
+// @Tags region_label
+// @Summary List all label rules of cluster.
+// @Produce json
+// @Success 200 {array} labeler.LabelRule
+// @Router /config/region-label/rules [get]
+func (h *regionLabelHandler) GetAllRules(w http.ResponseWriter, r *http.Request) {
+	cluster := getCluster(r)
+	h.rd.JSON(w, http.StatusOK, cluster.GetRegionLabeler().GetAllLabelRules())
+}
+
+// @Tags region_label
+// @Summary Get label rule by id.
+// @Param id path string true "Rule Id"
+// @Produce json
+// @Success 200 {object} labeler.LabelRule
+// @Failure 404 {string} string "The rule does not exist."
+// @Router /config/region-label/rule/{id} [get]
+func (h *regionLabelHandler) GetRule(w http.ResponseWriter, r *http.Request) {
+	cluster := getCluster(r)
+	id := mux.Vars(r)["id"]
+	rule := cluster.GetRegionLabeler().GetLabelRule(id)
+	if rule == nil {
+		h.rd.JSON(w, http.StatusNotFound, errs.ErrRegionLabelRuleNotFound.FastGenByArgs(id).Error())
+		return
+	}
+	h.rd.JSON(w, http.StatusOK, rule)
+}
+
+// @Tags region_label
+// @Summary Update or create a label rule. The "cost-center" label attributes the operators of the regions to a tenant.
+// @Accept json
+// @Param rule body labeler.LabelRule true "Parameters of label rule"
+// @Param validate query string false "Check the rule without applying it" Enums(true, false)
+// @Param force query string false "Apply the rule even if it conflicts with the existing rules" Enums(true, false)
+// @Produce json
+// @Success 200 {string} string "Update label rule successfully."
+// @Success 200 {object} labeler.LabelRuleCheck "The result of the validation."
+// @Failure 400 {object} labeler.LabelRuleCheck "The input is invalid."
+// @Failure 409 {object} labeler.LabelRuleCheck "The rule conflicts with the existing rules."
+// @Failure 500 {string} string "PD server failed to proceed the request."
+// @Router /config/region-label/rule [post]
+func (h *regionLabelHandler) SetRule(w http.ResponseWriter, r *http.Request) {
+	cluster := getCluster(r)
+	data, err := io.ReadAll(r.Body)
+	r.Body.Close()
+	if err != nil {
+		h.rd.JSON(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	validate := r.URL.Query().Get("validate") == "true"
+	rule, fieldErr := labeler.DecodeLabelRule(data)
+	if fieldErr != nil {
+		check := &labeler.LabelRuleCheck{Errors: []*labeler.FieldError{fieldErr}}
+		if validate {
+			h.rd.JSON(w, http.StatusOK, check)
+		} else {
+			h.rd.JSON(w, http.StatusBadRequest, check)
+		}
+		return
+	}
+	check := cluster.GetRegionLabeler().CheckLabelRule(rule)
+	if validate {
+		h.rd.JSON(w, http.StatusOK, check)
+		return
+	}
+	if len(check.Errors) > 0 {
+		h.rd.JSON(w, http.StatusBadRequest, check)
+		return
+	}
+	if len(check.Conflicts) > 0 && r.URL.Query().Get("force") != "true" {
+		h.rd.JSON(w, http.StatusConflict, check)
+		return
+	}
+	if err := cluster.GetRegionLabeler().SetLabelRule(rule); err != nil {
+		if errs.ErrRegionLabelRuleContent.Equal(err) {
+			h.rd.JSON(w, http.StatusBadRequest, err.Error())
+		} else {
+			h.rd.JSON(w, http.StatusInternalServerError, err.Error())
+		}
+		return
+	}
+	h.rd.JSON(w, http.StatusOK, "Update label rule successfully.")
+}
+
+// @Tags region_label
+// @Summary Delete label rule by id.
+// @Param id path string true "Rule Id"
+// @Produce json
+// @Success 200 {string} string "Delete label rule successfully."
+// @Failure 404 {string} string "The rule does not exist."
+// @Failure 500 {string} string "PD server failed to proceed the request."
+// @Router /config/region-label/rule/{id} [delete]
+func (h *regionLabelHandler) DeleteRule(w http.ResponseWriter, r *http.Request) {
+	cluster := getCluster(r)
+	if err := cluster.GetRegionLabeler().DeleteLabelRule(mux.Vars(r)["id"]); err != nil {
+		if errs.ErrRegionLabelRuleNotFound.Equal(err) {
+			h.rd.JSON(w, http.StatusNotFound, err.Error())
+		} else {
+			h.rd.JSON(w, http.StatusInternalServerError, err.Error())
+		}
+		return
+	}
+	h.rd.JSON(w, http.StatusOK, "Delete label rule successfully.")
+}
+
+// @Tags region_label
+// @Summary Get the labels of a region.
+// @Param id path integer true "Region Id"
+// @Produce json
+// @Success 200 {array} labeler.RegionLabel
+// @Failure 400 {string} string "The input is invalid."
+// @Failure 404 {string} string "The region does not exist."
+// @Router /region/id/{id}/labels [get]
+func (h *regionLabelHandler) GetRegionLabels(w http.ResponseWriter, r *http.Request) {
+	cluster := getCluster(r)
+	regionID, err := apiutil.ParseUint64VarsField(mux.Vars(r), "id")
+	if err != nil {
+		h.rd.JSON(w, http.StatusBadRequest, "invalid region id")
+		return
+	}
+	region := cluster.GetRegion(regionID)
+	if region == nil {
+		h.rd.JSON(w, http.StatusNotFound, server.ErrRegionNotFound(regionID).Error())
+		return
+	}
+	h.rd.JSON(w, http.StatusOK, cluster.GetRegionLabeler().GetRegionLabels(region))
+}
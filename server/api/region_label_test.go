@@ -0,0 +1,98 @@
+// Copyright 2021 TiKV Project Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package api
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+
+	. "github.com/pingcap/check"
+	"github.com/tikv/pd/server"
+	"github.com/tikv/pd/server/schedule/labeler"
+)
+
+var _ = Suite(&testRegionLabelSuite{})
+
+type testRegionLabelSuite struct {
+	svr       *server.Server
+	cleanup   cleanUpFunc
+	urlPrefix string
+}
+
+func (s *testRegionLabelSuite) SetUpSuite(c *C) {
+	s.svr, s.cleanup = mustNewServer(c)
+	mustWaitLeader(c, []*server.Server{s.svr})
+
+	addr := s.svr.GetAddr()
+	s.urlPrefix = fmt.Sprintf("%s%s/api/v1/config/region-label", addr, apiPrefix)
+
+	mustBootstrapCluster(c, s.svr)
+}
+
+func (s *testRegionLabelSuite) TearDownSuite(c *C) {
+	s.cleanup()
+}
+
+func (s *testRegionLabelSuite) postRule(c *C, query string, data string) (int, *labeler.LabelRuleCheck) {
+	resp, err := testDialClient.Post(s.urlPrefix+"/rule"+query, "application/json", bytes.NewBufferString(data))
+	c.Assert(err, IsNil)
+	defer resp.Body.Close()
+	res, err := io.ReadAll(resp.Body)
+	c.Assert(err, IsNil)
+	check := &labeler.LabelRuleCheck{}
+	if json.Unmarshal(res, check) != nil {
+		check = nil
+	}
+	return resp.StatusCode, check
+}
+
+func (s *testRegionLabelSuite) TestSetRule(c *C) {
+	code, _ := s.postRule(c, "", `{"id":"r1","labels":[{"key":"cost-center","value":"t1"}],"start_key":"61","end_key":"63"}`)
+	c.Assert(code, Equals, http.StatusOK)
+
+	// The invalid fields are reported with their positions.
+	code, check := s.postRule(c, "", `{"id":"r2","labels":[{"key":"k","value":""}],"start_key":"zz","end_key":""}`)
+	c.Assert(code, Equals, http.StatusBadRequest)
+	c.Assert(check.Errors, HasLen, 2)
+	c.Assert(check.Errors[0].Field, Equals, "labels[0].value")
+	c.Assert(check.Errors[1].Field, Equals, "start_key")
+	code, check = s.postRule(c, "", `{"id":"r2","labels":[],"unknown":1}`)
+	c.Assert(code, Equals, http.StatusBadRequest)
+	c.Assert(check.Errors[0].Field, Equals, "unknown")
+	code, check = s.postRule(c, "", `{"id":"r2","labels":1}`)
+	c.Assert(code, Equals, http.StatusBadRequest)
+	c.Assert(check.Errors[0].Field, Equals, "labels")
+	c.Assert(check.Errors[0].Offset, Greater, int64(0))
+
+	// The conflicts are checked without applying the rule.
+	rule := `{"id":"r2","labels":[{"key":"cost-center","value":"t2"}],"start_key":"62","end_key":""}`
+	code, check = s.postRule(c, "?validate=true", rule)
+	c.Assert(code, Equals, http.StatusOK)
+	c.Assert(check.Valid, IsFalse)
+	c.Assert(check.Conflicts, DeepEquals, []*labeler.RuleConflict{{ID: "r1", Key: "cost-center", Value: "t1", StartKeyHex: "62", EndKeyHex: "63"}})
+	c.Assert(s.svr.GetRaftCluster().GetRegionLabeler().GetLabelRule("r2"), IsNil)
+	code, _ = s.postRule(c, "", rule)
+	c.Assert(code, Equals, http.StatusConflict)
+	c.Assert(s.svr.GetRaftCluster().GetRegionLabeler().GetLabelRule("r2"), IsNil)
+	code, _ = s.postRule(c, "?force=true", rule)
+	c.Assert(code, Equals, http.StatusOK)
+	c.Assert(s.svr.GetRaftCluster().GetRegionLabeler().GetLabelRule("r2"), NotNil)
+
+	code, check = s.postRule(c, "?validate=true", `{"id":"r3","labels":[{"key":"k","value":"v"}],"start_key":"","end_key":""}`)
+	c.Assert(code, Equals, http.StatusOK)
+	c.Assert(check.Valid, IsTrue)
+}
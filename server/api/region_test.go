@@ -19,6 +19,7 @@ import (
 	"encoding/json"
 	"fmt"
 	"math/rand"
+	"net/http"
 	"net/url"
 	"sort"
 	"testing"
@@ -28,6 +29,7 @@ import (
 	"github.com/pingcap/kvproto/pkg/metapb"
 	"github.com/pingcap/kvproto/pkg/pdpb"
 	"github.com/tikv/pd/server"
+	"github.com/tikv/pd/server/cluster"
 	"github.com/tikv/pd/server/core"
 )
 
@@ -148,6 +150,23 @@ func (s *testRegionSuite) TestRegion(c *C) {
 	c.Assert(r2, DeepEquals, NewRegionInfo(r))
 }
 
+func (s *testRegionSuite) TestRegionInconsistency(c *C) {
+	url := fmt.Sprintf("%s/regions/check/inconsistency", s.urlPrefix)
+	var checked, last []*core.RegionInconsistency
+	c.Assert(postJSON(testDialClient, url, nil, func(res []byte, _ int) {
+		c.Assert(json.Unmarshal(res, &checked), IsNil)
+	}), IsNil)
+	c.Assert(readJSON(testDialClient, url, &last), IsNil)
+	c.Assert(last, DeepEquals, checked)
+}
+
+func (s *testRegionSuite) TestRegionWatchlist(c *C) {
+	url := fmt.Sprintf("%s/regions/watchlist", s.urlPrefix)
+	var watchlist []*cluster.WatchedRegion
+	c.Assert(readJSON(testDialClient, url, &watchlist), IsNil)
+	c.Assert(watchlist, HasLen, 0)
+}
+
 func (s *testRegionSuite) TestRegionCheck(c *C) {
 	r := newTestRegionInfo(2, 1, []byte("a"), []byte("b"))
 	downPeer := &metapb.Peer{Id: 13, StoreId: 2}
@@ -200,6 +219,14 @@ func (s *testRegionSuite) TestRegionCheck(c *C) {
 	c.Assert(readJSON(testDialClient, url, &r7), IsNil)
 	histKeys := []*histItem{{Start: 1000, End: 1999, Count: 1}}
 	c.Assert(r7, DeepEquals, histKeys)
+
+	url = fmt.Sprintf("%s/regions/check/rule-fit/%s", s.urlPrefix, "miss-peer")
+	r8 := &RegionsInfo{}
+	c.Assert(readJSON(testDialClient, url, r8), IsNil)
+	c.Assert(r8.Count, Equals, 1)
+	c.Assert(r8.Regions[0].ID, Equals, r.GetID())
+	url = fmt.Sprintf("%s/regions/check/rule-fit/%s", s.urlPrefix, "unknown")
+	c.Assert(readJSON(testDialClient, url, r8), NotNil)
 }
 
 func (s *testRegionSuite) TestRegions(c *C) {
@@ -531,3 +558,90 @@ func BenchmarkHexRegionKeyStr(b *testing.B) {
 		_ = core.HexRegionKeyStr(key)
 	}
 }
+
+var _ = Suite(&testHeartbeatProfileSuite{})
+
+type testHeartbeatProfileSuite struct {
+	svr       *server.Server
+	cleanup   cleanUpFunc
+	urlPrefix string
+}
+
+func (s *testHeartbeatProfileSuite) SetUpSuite(c *C) {
+	s.svr, s.cleanup = mustNewServer(c)
+	mustWaitLeader(c, []*server.Server{s.svr})
+
+	addr := s.svr.GetAddr()
+	s.urlPrefix = fmt.Sprintf("%s%s/api/v1", addr, apiPrefix)
+
+	mustBootstrapCluster(c, s.svr)
+}
+
+func (s *testHeartbeatProfileSuite) TearDownSuite(c *C) {
+	s.cleanup()
+}
+
+func (s *testHeartbeatProfileSuite) TestHeartbeatProfile(c *C) {
+	url := fmt.Sprintf("%s/regions/heartbeat-profile", s.urlPrefix)
+	resp, err := doDelete(testDialClient, url)
+	c.Assert(err, IsNil)
+	resp.Body.Close()
+	r := newTestRegionInfo(100, 1, []byte("x"), []byte("y"))
+	mustRegionHeartbeat(c, s.svr, r)
+
+	profile := &cluster.HeartbeatProfile{}
+	c.Assert(readJSON(testDialClient, url, profile), IsNil)
+	c.Assert(profile.Count, Equals, uint64(1))
+	c.Assert(profile.Stages, HasLen, 6)
+	c.Assert(profile.TopRegions, HasLen, 1)
+	c.Assert(profile.TopRegions[0].RegionID, Equals, r.GetID())
+	c.Assert(profile.TopRegions[0].Stages, HasLen, 6)
+
+	resp, err = doDelete(testDialClient, url)
+	c.Assert(err, IsNil)
+	resp.Body.Close()
+	profile = &cluster.HeartbeatProfile{}
+	c.Assert(readJSON(testDialClient, url, profile), IsNil)
+	c.Assert(profile.Count, Equals, uint64(0))
+	c.Assert(profile.TopRegions, HasLen, 0)
+}
+
+var _ = Suite(&testRegionHistorySuite{})
+
+type testRegionHistorySuite struct {
+	svr       *server.Server
+	cleanup   cleanUpFunc
+	urlPrefix string
+}
+
+func (s *testRegionHistorySuite) SetUpSuite(c *C) {
+	s.svr, s.cleanup = mustNewServer(c)
+	mustWaitLeader(c, []*server.Server{s.svr})
+
+	addr := s.svr.GetAddr()
+	s.urlPrefix = fmt.Sprintf("%s%s/api/v1", addr, apiPrefix)
+
+	mustBootstrapCluster(c, s.svr)
+}
+
+func (s *testRegionHistorySuite) TearDownSuite(c *C) {
+	s.cleanup()
+}
+
+func (s *testRegionHistorySuite) TestRegionHistory(c *C) {
+	mustRegionHeartbeat(c, s.svr, newTestRegionInfo(100, 1, []byte("a"), []byte("c")))
+	url := fmt.Sprintf("%s/region/id/%d/history", s.urlPrefix, 101)
+	resp, err := testDialClient.Get(url)
+	c.Assert(err, IsNil)
+	resp.Body.Close()
+	c.Assert(resp.StatusCode, Equals, http.StatusNotFound)
+
+	// Region 101 is split from region 100.
+	mustRegionHeartbeat(c, s.svr, newTestRegionInfo(101, 1, []byte("a"), []byte("b"), core.SetRegionVersion(2)))
+	history := &cluster.RegionHistory{}
+	c.Assert(readJSON(testDialClient, url, history), IsNil)
+	c.Assert(history.RegionID, Equals, uint64(101))
+	c.Assert(history.Events, HasLen, 1)
+	c.Assert(history.Events[0].Type, Equals, cluster.RegionSplitFrom)
+	c.Assert(history.Ancestors[0], Equals, uint64(100))
+}
@@ -57,18 +57,27 @@ func createRouter(prefix string, svr *server.Server) *mux.Router {
 
 	clusterRouter := apiRouter.NewRoute().Subrouter()
 	clusterRouter.Use(newClusterMiddleware(svr).Middleware)
+	leader := newLeaderMiddleware(svr)
 
 	operatorHandler := newOperatorHandler(handler, rd)
-	apiRouter.HandleFunc("/operators", operatorHandler.List).Methods("GET")
+	apiRouter.HandleFunc("/operators", leader.Middleware(operatorHandler.List)).Methods("GET")
 	apiRouter.HandleFunc("/operators", operatorHandler.Post).Methods("POST")
-	apiRouter.HandleFunc("/operators/{region_id}", operatorHandler.Get).Methods("GET")
+	apiRouter.HandleFunc("/operators/waiting-queue", leader.Middleware(operatorHandler.GetWaitingQueue)).Methods("GET")
+	apiRouter.HandleFunc("/operators/waiting-keyspaces", leader.Middleware(operatorHandler.GetWaitingKeyspaces)).Methods("GET")
+	apiRouter.HandleFunc("/operators/conflicts", leader.Middleware(operatorHandler.GetConflicts)).Methods("GET")
+	apiRouter.HandleFunc("/operators/{region_id}", leader.Middleware(operatorHandler.Get)).Methods("GET")
 	apiRouter.HandleFunc("/operators/{region_id}", operatorHandler.Delete).Methods("DELETE")
 
 	schedulerHandler := newSchedulerHandler(svr, rd)
-	apiRouter.HandleFunc("/schedulers", schedulerHandler.List).Methods("GET")
+	apiRouter.HandleFunc("/schedulers", leader.Middleware(schedulerHandler.List)).Methods("GET")
 	apiRouter.HandleFunc("/schedulers", schedulerHandler.Post).Methods("POST")
 	apiRouter.HandleFunc("/schedulers/{name}", schedulerHandler.Delete).Methods("DELETE")
 	apiRouter.HandleFunc("/schedulers/{name}", schedulerHandler.PauseOrResume).Methods("POST")
+	apiRouter.HandleFunc("/schedulers/{name}/window", schedulerHandler.SetWindow).Methods("POST")
+	apiRouter.HandleFunc("/schedulers/{name}/shadow", schedulerHandler.SetShadow).Methods("POST")
+	apiRouter.HandleFunc("/schedulers/{name}/shadow", leader.Middleware(schedulerHandler.GetShadowStatus)).Methods("GET")
+	apiRouter.HandleFunc("/schedulers/{name}/cancel-operators", schedulerHandler.CancelOperators).Methods("POST")
+	apiRouter.HandleFunc("/schedulers/{name}/preview", leader.Middleware(schedulerHandler.Preview)).Methods("GET")
 
 	schedulerConfigHandler := newSchedulerConfigHandler(svr, rd)
 	apiRouter.PathPrefix("/scheduler-config").Handler(schedulerConfigHandler)
@@ -76,83 +85,178 @@ func createRouter(prefix string, svr *server.Server) *mux.Router {
 	clusterHandler := newClusterHandler(svr, rd)
 	apiRouter.Handle("/cluster", clusterHandler).Methods("GET")
 	apiRouter.HandleFunc("/cluster/status", clusterHandler.GetClusterStatus).Methods("GET")
+	clusterRouter.HandleFunc("/cluster/prepare", clusterHandler.GetPrepareStatus).Methods("GET")
+	clusterRouter.HandleFunc("/cluster/prepare", clusterHandler.SetExpectedStoreCount).Methods("POST")
+	clusterRouter.HandleFunc("/cluster/storage-status", clusterHandler.GetStorageStatus).Methods("GET")
+	clusterRouter.HandleFunc("/cluster/memory", clusterHandler.GetMemoryUsage).Methods("GET")
+	clusterRouter.HandleFunc("/cluster/hot-split-thresholds", clusterHandler.GetHotSplitThresholds).Methods("GET")
+	clusterRouter.HandleFunc("/cluster/halt", clusterHandler.SetHaltScheduling).Methods("POST")
+	clusterRouter.HandleFunc("/cluster/upgrade", clusterHandler.GetUpgradeStatus).Methods("GET")
+	clusterRouter.HandleFunc("/cluster/upgrade/begin", clusterHandler.BeginUpgrade).Methods("POST")
+	clusterRouter.HandleFunc("/cluster/upgrade/finish", clusterHandler.FinishUpgrade).Methods("POST")
 
 	confHandler := newConfHandler(svr, rd)
-	apiRouter.HandleFunc("/config", confHandler.Get).Methods("GET")
+	apiRouter.HandleFunc("/config", leader.Middleware(confHandler.Get)).Methods("GET")
 	apiRouter.HandleFunc("/config", confHandler.Post).Methods("POST")
 	apiRouter.HandleFunc("/config/default", confHandler.GetDefault).Methods("GET")
-	apiRouter.HandleFunc("/config/schedule", confHandler.GetSchedule).Methods("GET")
+	apiRouter.HandleFunc("/config/effective", leader.Middleware(confHandler.GetEffective)).Methods("GET")
+	apiRouter.HandleFunc("/config/schedule", leader.Middleware(confHandler.GetSchedule)).Methods("GET")
 	apiRouter.HandleFunc("/config/schedule", confHandler.SetSchedule).Methods("POST")
-	apiRouter.HandleFunc("/config/replicate", confHandler.GetReplication).Methods("GET")
+	apiRouter.HandleFunc("/config/maintenance-window", leader.Middleware(confHandler.GetMaintenanceWindow)).Methods("GET")
+	clusterRouter.HandleFunc("/config/leader-constraints", confHandler.GetLeaderConstraints).Methods("GET")
+	clusterRouter.HandleFunc("/config/leader-constraints", confHandler.SetLeaderConstraints).Methods("POST")
+	apiRouter.HandleFunc("/config/replicate", leader.Middleware(confHandler.GetReplication)).Methods("GET")
 	apiRouter.HandleFunc("/config/replicate", confHandler.SetReplication).Methods("POST")
-	apiRouter.HandleFunc("/config/label-property", confHandler.GetLabelProperty).Methods("GET")
+	apiRouter.HandleFunc("/config/label-property", leader.Middleware(confHandler.GetLabelProperty)).Methods("GET")
 	apiRouter.HandleFunc("/config/label-property", confHandler.SetLabelProperty).Methods("POST")
-	apiRouter.HandleFunc("/config/cluster-version", confHandler.GetClusterVersion).Methods("GET")
+	apiRouter.HandleFunc("/config/cluster-version", leader.Middleware(confHandler.GetClusterVersion)).Methods("GET")
 	apiRouter.HandleFunc("/config/cluster-version", confHandler.SetClusterVersion).Methods("POST")
-	apiRouter.HandleFunc("/config/replication-mode", confHandler.GetReplicationMode).Methods("GET")
+	apiRouter.HandleFunc("/config/replication-mode", leader.Middleware(confHandler.GetReplicationMode)).Methods("GET")
 	apiRouter.HandleFunc("/config/replication-mode", confHandler.SetReplicationMode).Methods("POST")
 
+	// The destructive operations are blocked in the safe mode. The placement
+	// rules can be removed by deleting them, or by replacing them in batch.
+	safeMode := newSafeModeMiddleware(svr, rd)
+
 	rulesHandler := newRulesHandler(svr, rd)
 	clusterRouter.HandleFunc("/config/rules", rulesHandler.GetAll).Methods("GET")
-	clusterRouter.HandleFunc("/config/rules", rulesHandler.SetAll).Methods("POST")
-	clusterRouter.HandleFunc("/config/rules/batch", rulesHandler.Batch).Methods("POST")
+	clusterRouter.HandleFunc("/config/rules", safeMode.Middleware(rulesHandler.SetAll)).Methods("POST")
+	clusterRouter.HandleFunc("/config/rules/batch", safeMode.Middleware(rulesHandler.Batch)).Methods("POST")
 	clusterRouter.HandleFunc("/config/rules/group/{group}", rulesHandler.GetAllByGroup).Methods("GET")
 	clusterRouter.HandleFunc("/config/rules/region/{region}", rulesHandler.GetAllByRegion).Methods("GET")
 	clusterRouter.HandleFunc("/config/rules/key/{key}", rulesHandler.GetAllByKey).Methods("GET")
 	clusterRouter.HandleFunc("/config/rule/{group}/{id}", rulesHandler.Get).Methods("GET")
 	clusterRouter.HandleFunc("/config/rule", rulesHandler.Set).Methods("POST")
-	clusterRouter.HandleFunc("/config/rule/{group}/{id}", rulesHandler.Delete).Methods("DELETE")
+	clusterRouter.HandleFunc("/config/rule/{group}/{id}", safeMode.Middleware(rulesHandler.Delete)).Methods("DELETE")
 
 	clusterRouter.HandleFunc("/config/rule_group/{id}", rulesHandler.GetGroupConfig).Methods("GET")
 	clusterRouter.HandleFunc("/config/rule_group", rulesHandler.SetGroupConfig).Methods("POST")
-	clusterRouter.HandleFunc("/config/rule_group/{id}", rulesHandler.DeleteGroupConfig).Methods("DELETE")
+	clusterRouter.HandleFunc("/config/rule_group/{id}", safeMode.Middleware(rulesHandler.DeleteGroupConfig)).Methods("DELETE")
 	clusterRouter.HandleFunc("/config/rule_groups", rulesHandler.GetAllGroupConfigs).Methods("GET")
 
 	clusterRouter.HandleFunc("/config/placement-rule", rulesHandler.GetAllGroupBundles).Methods("GET")
-	clusterRouter.HandleFunc("/config/placement-rule", rulesHandler.SetAllGroupBundles).Methods("POST")
+	clusterRouter.HandleFunc("/config/placement-rule", safeMode.Middleware(rulesHandler.SetAllGroupBundles)).Methods("POST")
+	clusterRouter.HandleFunc("/config/placement-rule-estimate", rulesHandler.EstimateAllGroupBundles).Methods("POST")
 	// {group} can be a regular expression, we should enable path encode to
 	// support special characters.
 	escapeRouter := clusterRouter.NewRoute().Subrouter().UseEncodedPath()
 	clusterRouter.HandleFunc("/config/placement-rule/{group}", rulesHandler.GetGroupBundle).Methods("GET")
-	clusterRouter.HandleFunc("/config/placement-rule/{group}", rulesHandler.SetGroupBundle).Methods("POST")
-	escapeRouter.HandleFunc("/config/placement-rule/{group}", rulesHandler.DeleteGroupBundle).Methods("DELETE")
+	clusterRouter.HandleFunc("/config/placement-rule/{group}", safeMode.Middleware(rulesHandler.SetGroupBundle)).Methods("POST")
+	escapeRouter.HandleFunc("/config/placement-rule/{group}", safeMode.Middleware(rulesHandler.DeleteGroupBundle)).Methods("DELETE")
+
+	tieringHandler := newTieringHandler(svr, rd)
+	clusterRouter.HandleFunc("/config/tiering-policies", tieringHandler.GetAll).Methods("GET")
+	clusterRouter.HandleFunc("/config/tiering-policy", tieringHandler.Set).Methods("POST")
+	clusterRouter.HandleFunc("/config/tiering-policy/{id}", tieringHandler.Get).Methods("GET")
+	clusterRouter.HandleFunc("/config/tiering-policy/{id}", tieringHandler.Delete).Methods("DELETE")
+
+	affinityHandler := newAffinityHandler(svr, rd)
+	clusterRouter.HandleFunc("/config/affinity-groups", affinityHandler.GetAll).Methods("GET")
+	clusterRouter.HandleFunc("/config/affinity-group", affinityHandler.Set).Methods("POST")
+	clusterRouter.HandleFunc("/config/affinity-group/{id}", affinityHandler.Get).Methods("GET")
+	clusterRouter.HandleFunc("/config/affinity-group/{id}", affinityHandler.Delete).Methods("DELETE")
+
+	regionLabelHandler := newRegionLabelHandler(svr, rd)
+	clusterRouter.HandleFunc("/config/region-label/rules", regionLabelHandler.GetAllRules).Methods("GET")
+	clusterRouter.HandleFunc("/config/region-label/rule", regionLabelHandler.SetRule).Methods("POST")
+	clusterRouter.HandleFunc("/config/region-label/rule/{id}", regionLabelHandler.GetRule).Methods("GET")
+	clusterRouter.HandleFunc("/config/region-label/rule/{id}", regionLabelHandler.DeleteRule).Methods("DELETE")
+	clusterRouter.HandleFunc("/region/id/{id}/labels", regionLabelHandler.GetRegionLabels).Methods("GET")
+
+	keyRangeLockHandler := newKeyRangeLockHandler(svr, rd)
+	clusterRouter.HandleFunc("/keyrange/locks", keyRangeLockHandler.List).Methods("GET")
+	clusterRouter.HandleFunc("/keyrange/locks", keyRangeLockHandler.Acquire).Methods("POST")
+	clusterRouter.HandleFunc("/keyrange/locks/{id}/keepalive", keyRangeLockHandler.KeepAlive).Methods("POST")
+	clusterRouter.HandleFunc("/keyrange/locks/{id}", keyRangeLockHandler.Release).Methods("DELETE")
+
+	jobHandler := newJobHandler(svr, rd)
+	clusterRouter.HandleFunc("/jobs", jobHandler.List).Methods("GET")
+	clusterRouter.HandleFunc("/jobs", jobHandler.Submit).Methods("POST")
+	clusterRouter.HandleFunc("/jobs/{id}", jobHandler.Get).Methods("GET")
+	clusterRouter.HandleFunc("/jobs/{id}", jobHandler.Cancel).Methods("DELETE")
+
+	topologyHandler := newTopologyHandler(svr, rd)
+	clusterRouter.HandleFunc("/topology/plan", topologyHandler.GetPlan).Methods("GET")
+	clusterRouter.HandleFunc("/topology/plan", topologyHandler.CreatePlan).Methods("POST")
+	clusterRouter.HandleFunc("/topology/plan", topologyHandler.DeletePlan).Methods("DELETE")
+	clusterRouter.HandleFunc("/topology/plan/step", topologyHandler.ExecuteStep).Methods("POST")
+
+	doctorHandler := newDoctorHandler(svr, rd)
+	clusterRouter.HandleFunc("/doctor", doctorHandler.Get).Methods("GET")
+
+	conformanceHandler := newConformanceHandler(svr, rd)
+	clusterRouter.HandleFunc("/conformance/reports", conformanceHandler.List).Methods("GET")
+	clusterRouter.HandleFunc("/conformance/reports", conformanceHandler.Generate).Methods("POST")
+	clusterRouter.HandleFunc("/conformance/reports/diff", conformanceHandler.Diff).Methods("GET")
+	clusterRouter.HandleFunc("/conformance/reports/{id}", conformanceHandler.Get).Methods("GET")
+
+	eventsHandler := newEventsHandler(rd)
+	clusterRouter.HandleFunc("/events", eventsHandler.List).Methods("GET")
+	clusterRouter.HandleFunc("/events/watch", eventsHandler.Watch).Methods("GET")
+
+	alertHandler := newAlertHandler(svr, rd)
+	clusterRouter.HandleFunc("/alerts", alertHandler.List).Methods("GET")
+	clusterRouter.HandleFunc("/alerts", alertHandler.Set).Methods("POST")
+	clusterRouter.HandleFunc("/alerts/{name}", alertHandler.Delete).Methods("DELETE")
 
 	storeHandler := newStoreHandler(handler, rd)
 	clusterRouter.HandleFunc("/store/{id}", storeHandler.Get).Methods("GET")
-	clusterRouter.HandleFunc("/store/{id}", storeHandler.Delete).Methods("DELETE")
+	clusterRouter.HandleFunc("/store/{id}", safeMode.Middleware(storeHandler.Delete)).Methods("DELETE")
 	clusterRouter.HandleFunc("/store/{id}/state", storeHandler.SetState).Methods("POST")
+	clusterRouter.HandleFunc("/store/{id}/offline-check", storeHandler.CheckOffline).Methods("GET")
 	clusterRouter.HandleFunc("/store/{id}/label", storeHandler.SetLabels).Methods("POST")
 	clusterRouter.HandleFunc("/store/{id}/weight", storeHandler.SetWeight).Methods("POST")
+	clusterRouter.HandleFunc("/store/{id}/read-only", storeHandler.SetReadOnly).Methods("POST")
+	clusterRouter.HandleFunc("/store/{id}/resource-tags", storeHandler.SetResourceTags).Methods("POST")
+	clusterRouter.HandleFunc("/store/{id}/fencing-token", storeHandler.ResetFencingToken).Methods("DELETE")
+	clusterRouter.HandleFunc("/store/{id}/archive", storeHandler.GetArchive).Methods("GET")
+	clusterRouter.HandleFunc("/store/{id}/restore-archive", storeHandler.RestoreArchive).Methods("POST")
+	clusterRouter.HandleFunc("/store/{id}/timeline", storeHandler.GetTimeline).Methods("GET")
 	clusterRouter.HandleFunc("/store/{id}/limit", storeHandler.SetLimit).Methods("POST")
 	storesHandler := newStoresHandler(handler, rd)
 	clusterRouter.Handle("/stores", storesHandler).Methods("GET")
-	clusterRouter.HandleFunc("/stores/remove-tombstone", storesHandler.RemoveTombStone).Methods("DELETE")
+	clusterRouter.HandleFunc("/stores/remove-tombstone", safeMode.Middleware(storesHandler.RemoveTombStone)).Methods("DELETE")
 	clusterRouter.HandleFunc("/stores/limit", storesHandler.GetAllLimit).Methods("GET")
 	clusterRouter.HandleFunc("/stores/limit", storesHandler.SetAllLimit).Methods("POST")
 	clusterRouter.HandleFunc("/stores/limit/scene", storesHandler.SetStoreLimitScene).Methods("POST")
 	clusterRouter.HandleFunc("/stores/limit/scene", storesHandler.GetStoreLimitScene).Methods("GET")
+	clusterRouter.HandleFunc("/stores/stale-peers", storesHandler.GetStalePeers).Methods("GET")
+	clusterRouter.HandleFunc("/stores/capacity-forecast", storesHandler.GetCapacityForecast).Methods("GET")
+	clusterRouter.HandleFunc("/stores/resource-tags", storesHandler.SetResourceTags).Methods("POST")
+
+	storeRestartHandler := newStoreRestartHandler(svr, rd)
+	clusterRouter.HandleFunc("/stores/{id}/prepare-restart", storeRestartHandler.Prepare).Methods("POST")
+	clusterRouter.HandleFunc("/stores/{id}/prepare-restart", storeRestartHandler.GetStatus).Methods("GET")
+	clusterRouter.HandleFunc("/stores/{id}/resume-restart", storeRestartHandler.Resume).Methods("POST")
+
+	storeConfigHandler := newStoreConfigHandler(rd)
+	clusterRouter.HandleFunc("/stores/config", storeConfigHandler.GetAll).Methods("GET")
+	clusterRouter.HandleFunc("/store/{id}/config", storeConfigHandler.Get).Methods("GET")
 
 	labelsHandler := newLabelsHandler(svr, rd)
 	clusterRouter.HandleFunc("/labels", labelsHandler.Get).Methods("GET")
 	clusterRouter.HandleFunc("/labels/stores", labelsHandler.GetStores).Methods("GET")
 
 	hotStatusHandler := newHotStatusHandler(handler, rd)
-	apiRouter.HandleFunc("/hotspot/regions/write", hotStatusHandler.GetHotWriteRegions).Methods("GET")
-	apiRouter.HandleFunc("/hotspot/regions/read", hotStatusHandler.GetHotReadRegions).Methods("GET")
-	apiRouter.HandleFunc("/hotspot/stores", hotStatusHandler.GetHotStores).Methods("GET")
+	apiRouter.HandleFunc("/hotspot/regions/write", leader.Middleware(hotStatusHandler.GetHotWriteRegions)).Methods("GET")
+	apiRouter.HandleFunc("/hotspot/regions/read", leader.Middleware(hotStatusHandler.GetHotReadRegions)).Methods("GET")
+	apiRouter.HandleFunc("/hotspot/stores", leader.Middleware(hotStatusHandler.GetHotStores)).Methods("GET")
 
 	regionHandler := newRegionHandler(svr, rd)
 	clusterRouter.HandleFunc("/region/id/{id}", regionHandler.GetRegionByID).Methods("GET")
+	clusterRouter.HandleFunc("/region/id/{id}/history", regionHandler.GetRegionHistory).Methods("GET")
 	clusterRouter.UseEncodedPath().HandleFunc("/region/key/{key}", regionHandler.GetRegionByKey).Methods("GET")
 
 	srd := createStreamingRender()
+	// The APIs scanning regions are rejected in the degraded mode.
+	degradation := newDegradationMiddleware(svr, rd)
 	regionsAllHandler := newRegionsHandler(svr, srd)
-	clusterRouter.HandleFunc("/regions", regionsAllHandler.GetAll).Methods("GET")
+	clusterRouter.HandleFunc("/regions", degradation.Middleware(regionsAllHandler.GetAll)).Methods("GET")
 
 	regionsHandler := newRegionsHandler(svr, rd)
-	clusterRouter.HandleFunc("/regions/key", regionsHandler.ScanRegions).Methods("GET")
+	clusterRouter.HandleFunc("/regions/key", degradation.Middleware(regionsHandler.ScanRegions)).Methods("GET")
 	clusterRouter.HandleFunc("/regions/count", regionsHandler.GetRegionCount).Methods("GET")
-	clusterRouter.HandleFunc("/regions/store/{id}", regionsHandler.GetStoreRegions).Methods("GET")
+	clusterRouter.HandleFunc("/regions/store/{id}", degradation.Middleware(regionsHandler.GetStoreRegions)).Methods("GET")
 	clusterRouter.HandleFunc("/regions/writeflow", regionsHandler.GetTopWriteFlow).Methods("GET")
 	clusterRouter.HandleFunc("/regions/readflow", regionsHandler.GetTopReadFlow).Methods("GET")
 	clusterRouter.HandleFunc("/regions/confver", regionsHandler.GetTopConfVer).Methods("GET")
@@ -165,6 +269,12 @@ func createRouter(prefix string, svr *server.Server) *mux.Router {
 	clusterRouter.HandleFunc("/regions/check/learner-peer", regionsHandler.GetLearnerPeerRegions).Methods("GET")
 	clusterRouter.HandleFunc("/regions/check/empty-region", regionsHandler.GetEmptyRegion).Methods("GET")
 	clusterRouter.HandleFunc("/regions/check/offline-peer", regionsHandler.GetOfflinePeer).Methods("GET")
+	clusterRouter.HandleFunc("/regions/check/inconsistency", regionsHandler.GetRegionInconsistencies).Methods("GET")
+	clusterRouter.HandleFunc("/regions/check/inconsistency", regionsHandler.CheckRegionConsistency).Methods("POST")
+	clusterRouter.HandleFunc("/regions/watchlist", regionsHandler.GetRegionWatchlist).Methods("GET")
+	clusterRouter.HandleFunc("/regions/heartbeat-profile", regionsHandler.GetHeartbeatProfile).Methods("GET")
+	clusterRouter.HandleFunc("/regions/heartbeat-profile", regionsHandler.ResetHeartbeatProfile).Methods("DELETE")
+	clusterRouter.HandleFunc("/regions/check/rule-fit/{class}", regionsHandler.GetRuleFitRegions).Methods("GET")
 
 	clusterRouter.HandleFunc("/regions/check/hist-size", regionsHandler.GetSizeHistogram).Methods("GET")
 	clusterRouter.HandleFunc("/regions/check/hist-keys", regionsHandler.GetKeysHistogram).Methods("GET")
@@ -189,9 +299,13 @@ func createRouter(prefix string, svr *server.Server) *mux.Router {
 
 	statsHandler := newStatsHandler(svr, rd)
 	clusterRouter.HandleFunc("/stats/region", statsHandler.Region).Methods("GET")
+	clusterRouter.HandleFunc("/stats/placement-drift", statsHandler.PlacementDrift).Methods("GET")
 
 	trendHandler := newTrendHandler(svr, rd)
-	apiRouter.HandleFunc("/trend", trendHandler.Handle).Methods("GET")
+	apiRouter.HandleFunc("/trend", leader.Middleware(trendHandler.Handle)).Methods("GET")
+
+	heatmapHandler := newHeatmapHandler(svr, rd)
+	apiRouter.HandleFunc("/heatmap", leader.Middleware(heatmapHandler.Get)).Methods("GET")
 
 	adminHandler := newAdminHandler(svr, rd)
 	clusterRouter.HandleFunc("/admin/cache/region/{id}", adminHandler.HandleDropCacheRegion).Methods("DELETE")
@@ -199,9 +313,31 @@ func createRouter(prefix string, svr *server.Server) *mux.Router {
 	apiRouter.HandleFunc("/admin/persist-file/{file_name}", adminHandler.persistFile).Methods("POST")
 	clusterRouter.HandleFunc("/admin/replication_mode/wait-async", adminHandler.UpdateWaitAsyncTime).Methods("POST")
 
+	safeModeHandler := newSafeModeHandler(svr, rd)
+	apiRouter.HandleFunc("/admin/safe-mode", safeModeHandler.Get).Methods("GET")
+	apiRouter.HandleFunc("/admin/safe-mode", safeModeHandler.Set).Methods("POST")
+
+	rbacHandler := newRBACHandler(svr, rd)
+	apiRouter.HandleFunc("/rbac", rbacHandler.Get).Methods("GET")
+	apiRouter.HandleFunc("/rbac/enabled", rbacHandler.SetEnabled).Methods("POST")
+	apiRouter.HandleFunc("/rbac/binding", rbacHandler.SetBinding).Methods("POST")
+	apiRouter.HandleFunc("/rbac/binding/{kind}/{name}", rbacHandler.DeleteBinding).Methods("DELETE")
+
 	logHandler := newLogHandler(svr, rd)
 	apiRouter.HandleFunc("/admin/log", logHandler.Handle).Methods("POST")
 
+	diagnosisHandler := newDiagnosisHandler(svr, rd)
+	apiRouter.HandleFunc("/admin/diagnosis", diagnosisHandler.Capture).Methods("POST")
+	apiRouter.HandleFunc("/admin/diagnosis", diagnosisHandler.List).Methods("GET")
+	apiRouter.HandleFunc("/admin/diagnosis/{name}", diagnosisHandler.Download).Methods("GET")
+
+	heartbeatRecordHandler := newHeartbeatRecordHandler(svr, rd)
+	clusterRouter.HandleFunc("/admin/heartbeat-record", heartbeatRecordHandler.Start).Methods("POST")
+	apiRouter.HandleFunc("/admin/heartbeat-record", heartbeatRecordHandler.Stop).Methods("DELETE")
+	apiRouter.HandleFunc("/admin/heartbeat-record", heartbeatRecordHandler.List).Methods("GET")
+	apiRouter.HandleFunc("/admin/heartbeat-record/status", heartbeatRecordHandler.Status).Methods("GET")
+	apiRouter.HandleFunc("/admin/heartbeat-record/{name}", heartbeatRecordHandler.Download).Methods("GET")
+
 	replicationModeHandler := newReplicationModeHandler(svr, rd)
 	clusterRouter.HandleFunc("/replication_mode/status", replicationModeHandler.GetStatus)
 
@@ -237,6 +373,10 @@ func createRouter(prefix string, svr *server.Server) *mux.Router {
 	apiRouter.Handle("/debug/pprof/goroutine", pprof.Handler("goroutine"))
 	apiRouter.Handle("/debug/pprof/threadcreate", pprof.Handler("threadcreate"))
 
+	metaHandler := newMetaHandler(svr, rd)
+	apiRouter.HandleFunc("/meta/backup", metaHandler.Backup).Methods("GET")
+	apiRouter.HandleFunc("/meta/restore", metaHandler.Restore).Methods("POST")
+
 	// service GC safepoint API
 	serviceGCSafepointHandler := newServiceGCSafepointHandler(svr, rd)
 	apiRouter.HandleFunc("/gc/safepoint", serviceGCSafepointHandler.List).Methods("GET")
@@ -448,6 +448,39 @@ func (h *ruleHandler) SetAllGroupBundles(w http.ResponseWriter, r *http.Request)
 	h.rd.JSON(w, http.StatusOK, "Update rules and groups successfully.")
 }
 
+// @Tags rule
+// @Summary Estimate the replica changes of the stores once the cluster converges to the proposed rules and groups.
+// @Param partial query bool false "if partially update rules" default(false)
+// @Produce json
+// @Success 200 {object} placement.RuleChangeEstimate
+// @Failure 400 {string} string "The input is invalid."
+// @Failure 412 {string} string "Placement rules feature is disabled."
+// @Failure 500 {string} string "PD server failed to proceed the request."
+// @Router /config/placement-rule-estimate [post]
+func (h *ruleHandler) EstimateAllGroupBundles(w http.ResponseWriter, r *http.Request) {
+	cluster := getCluster(r)
+	if !cluster.GetOpts().IsPlacementRulesEnabled() {
+		h.rd.JSON(w, http.StatusPreconditionFailed, errPlacementDisabled.Error())
+		return
+	}
+	var groups []placement.GroupBundle
+	if err := apiutil.ReadJSONRespondError(h.rd, w, r.Body, &groups); err != nil {
+		return
+	}
+	_, partial := r.URL.Query()["partial"]
+	estimate, err := cluster.GetRuleManager().SetKeyType(h.svr.GetConfig().PDServerCfg.KeyType).
+		EstimateGroupBundles(cluster, cluster.GetRegions(), groups, !partial)
+	if err != nil {
+		if errs.ErrRuleContent.Equal(err) || errs.ErrHexDecodingString.Equal(err) {
+			h.rd.JSON(w, http.StatusBadRequest, err.Error())
+		} else {
+			h.rd.JSON(w, http.StatusInternalServerError, err.Error())
+		}
+		return
+	}
+	h.rd.JSON(w, http.StatusOK, estimate)
+}
+
 // @Tags rule
 // @Summary Get group config and all rules belong to the group.
 // @Param group path string true "The name of group"
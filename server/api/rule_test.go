@@ -817,6 +817,35 @@ func (s *testRuleSuite) TestBundleBadRequest(c *C) {
 	}
 }
 
+func (s *testRuleSuite) TestBundleEstimate(c *C) {
+	b := placement.GroupBundle{
+		ID: "pd",
+		Rules: []*placement.Rule{
+			{GroupID: "pd", ID: "default", Role: "voter", Count: 2},
+		},
+	}
+	data, err := json.Marshal([]placement.GroupBundle{b})
+	c.Assert(err, IsNil)
+	var estimate placement.RuleChangeEstimate
+	err = postJSON(testDialClient, s.urlPrefix+"/placement-rule-estimate", data, func(res []byte, _ int) {
+		c.Assert(json.Unmarshal(res, &estimate), IsNil)
+	})
+	c.Assert(err, IsNil)
+	// The only store has the peer of the region, so the second voter can not be placed.
+	c.Assert(estimate.RegionCount, Equals, 1)
+	c.Assert(estimate.Stores, HasLen, 0)
+	c.Assert(estimate.UnplaceableReplicas, Equals, 1)
+
+	// The rules are not changed.
+	var bundles []placement.GroupBundle
+	c.Assert(readJSON(testDialClient, s.urlPrefix+"/placement-rule", &bundles), IsNil)
+	c.Assert(bundles, HasLen, 1)
+	c.Assert(bundles[0].Rules[0].Count, Equals, 3)
+
+	err = postJSON(testDialClient, s.urlPrefix+"/placement-rule-estimate", []byte(`[{"group_id":"foo", "rules": [{"group_id":"bar", "id":"baz", "role":"voter", "count":1}]}]`))
+	c.Assert(err, NotNil)
+}
+
 func compareBundle(c *C, b1, b2 placement.GroupBundle) {
 	c.Assert(b1.ID, Equals, b2.ID)
 	c.Assert(b1.Index, Equals, b2.Index)
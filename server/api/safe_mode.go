@@ -0,0 +1,116 @@
+// Copyright 2021 TiKV Project Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package api
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/tikv/pd/pkg/apiutil"
+	"github.com/tikv/pd/pkg/errs"
+	"github.com/tikv/pd/server"
+	"github.com/unrolled/render"
+)
+
+type safeModeHandler struct {
+	svr *server.Server
+	rd  *render.Render
+}
+
+func newSafeModeHandler(svr *server.Server, rd *render.Render) *safeModeHandler {
+	return &safeModeHandler{
+		svr: svr,
+		rd:  rd,
+	}
+}
+
+type safeModeStatus struct {
+	Enabled    bool      `json:"enabled"`
+	UpdateTime time.Time `json:"update-time"`
+}
+
+// @Tags admin
+// @Summary Get the state of the safe mode.
+// @Produce json
+// @Success 200 {object} safeModeStatus
+// @Failure 500 {string} string "PD server failed to proceed the request."
+// @Router /admin/safe-mode [get]
+func (h *safeModeHandler) Get(w http.ResponseWriter, r *http.Request) {
+	state, err := h.svr.GetSafeMode()
+	if err != nil {
+		h.rd.JSON(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	h.rd.JSON(w, http.StatusOK, safeModeStatus{Enabled: state.Enabled, UpdateTime: state.UpdateTime})
+}
+
+type safeModeInput struct {
+	Enabled bool   `json:"enabled"`
+	Token   string `json:"token"`
+}
+
+// @Tags admin
+// @Summary Enable or disable the safe mode. The destructive operations are blocked in the safe mode unless the unlock token is provided in the PD-Safe-Mode-Token header.
+// @Accept json
+// @Param body body safeModeInput true "json params, the token is the new unlock token when enabling, and it must match the current one if the safe mode is enabled"
+// @Produce json
+// @Success 200 {string} string "The safe mode is updated."
+// @Failure 400 {string} string "The input is invalid."
+// @Failure 403 {string} string "The token is mismatched."
+// @Failure 500 {string} string "PD server failed to proceed the request."
+// @Router /admin/safe-mode [post]
+func (h *safeModeHandler) Set(w http.ResponseWriter, r *http.Request) {
+	var input safeModeInput
+	if err := apiutil.ReadJSONRespondError(h.rd, w, r.Body, &input); err != nil {
+		return
+	}
+	if input.Token == "" {
+		h.rd.JSON(w, http.StatusBadRequest, "token should not be empty")
+		return
+	}
+	if err := h.svr.SetSafeMode(input.Enabled, input.Token); err != nil {
+		if errs.ErrSafeModeToken.Equal(err) {
+			h.rd.JSON(w, http.StatusForbidden, err.Error())
+			return
+		}
+		h.rd.JSON(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	h.rd.JSON(w, http.StatusOK, "The safe mode is updated.")
+}
+
+// safeModeMiddleware blocks the destructive operations in the safe mode
+// unless they are confirmed with the unlock token.
+type safeModeMiddleware struct {
+	s  *server.Server
+	rd *render.Render
+}
+
+func newSafeModeMiddleware(s *server.Server, rd *render.Render) safeModeMiddleware {
+	return safeModeMiddleware{s: s, rd: rd}
+}
+
+func (m safeModeMiddleware) Middleware(h http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if err := m.s.CheckSafeMode(r.Header.Get(server.SafeModeTokenHeader)); err != nil {
+			if errs.ErrSafeModeBlocked.Equal(err) {
+				m.rd.JSON(w, http.StatusForbidden, err.Error())
+				return
+			}
+			m.rd.JSON(w, http.StatusInternalServerError, err.Error())
+			return
+		}
+		h(w, r)
+	}
+}
@@ -0,0 +1,134 @@
+// Copyright 2021 TiKV Project Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+
+	. "github.com/pingcap/check"
+	"github.com/tikv/pd/server"
+	"github.com/tikv/pd/server/schedule/placement"
+)
+
+var _ = Suite(&testSafeModeSuite{})
+
+type testSafeModeSuite struct {
+	svr       *server.Server
+	cleanup   cleanUpFunc
+	urlPrefix string
+}
+
+func (s *testSafeModeSuite) SetUpSuite(c *C) {
+	s.svr, s.cleanup = mustNewServer(c)
+	mustWaitLeader(c, []*server.Server{s.svr})
+
+	addr := s.svr.GetAddr()
+	s.urlPrefix = fmt.Sprintf("%s%s/api/v1", addr, apiPrefix)
+
+	mustBootstrapCluster(c, s.svr)
+}
+
+func (s *testSafeModeSuite) TearDownSuite(c *C) {
+	s.cleanup()
+}
+
+func (s *testSafeModeSuite) setSafeMode(enabled bool, token string) error {
+	data, err := json.Marshal(safeModeInput{Enabled: enabled, Token: token})
+	if err != nil {
+		return err
+	}
+	return postJSON(testDialClient, s.urlPrefix+"/admin/safe-mode", data)
+}
+
+func (s *testSafeModeSuite) removeTombstone(c *C, token string) int {
+	req, err := http.NewRequest(http.MethodDelete, s.urlPrefix+"/stores/remove-tombstone", nil)
+	c.Assert(err, IsNil)
+	if token != "" {
+		req.Header.Set(server.SafeModeTokenHeader, token)
+	}
+	resp, err := testDialClient.Do(req)
+	c.Assert(err, IsNil)
+	resp.Body.Close()
+	return resp.StatusCode
+}
+
+func (s *testSafeModeSuite) TestSafeMode(c *C) {
+	var status safeModeStatus
+	c.Assert(readJSON(testDialClient, s.urlPrefix+"/admin/safe-mode", &status), IsNil)
+	c.Assert(status.Enabled, IsFalse)
+	c.Assert(s.removeTombstone(c, ""), Equals, http.StatusOK)
+
+	c.Assert(s.setSafeMode(true, ""), NotNil)
+	c.Assert(s.setSafeMode(true, "unlock"), IsNil)
+	c.Assert(readJSON(testDialClient, s.urlPrefix+"/admin/safe-mode", &status), IsNil)
+	c.Assert(status.Enabled, IsTrue)
+
+	// The destructive operations are blocked without the unlock token.
+	c.Assert(s.removeTombstone(c, ""), Equals, http.StatusForbidden)
+	c.Assert(s.removeTombstone(c, "wrong"), Equals, http.StatusForbidden)
+	c.Assert(s.removeTombstone(c, "unlock"), Equals, http.StatusOK)
+	resp, err := doDelete(testDialClient, s.urlPrefix+"/store/1")
+	c.Assert(err, IsNil)
+	c.Assert(resp.StatusCode, Equals, http.StatusForbidden)
+
+	// The token cannot be replaced or disabled without the unlock token.
+	c.Assert(s.setSafeMode(true, "other"), NotNil)
+	c.Assert(s.setSafeMode(false, "other"), NotNil)
+	c.Assert(s.setSafeMode(false, "unlock"), IsNil)
+	c.Assert(readJSON(testDialClient, s.urlPrefix+"/admin/safe-mode", &status), IsNil)
+	c.Assert(status.Enabled, IsFalse)
+	c.Assert(s.removeTombstone(c, ""), Equals, http.StatusOK)
+}
+
+func (s *testSafeModeSuite) doRequest(c *C, method, url, body, token string) int {
+	req, err := http.NewRequest(method, s.urlPrefix+url, strings.NewReader(body))
+	c.Assert(err, IsNil)
+	if token != "" {
+		req.Header.Set(server.SafeModeTokenHeader, token)
+	}
+	resp, err := testDialClient.Do(req)
+	c.Assert(err, IsNil)
+	resp.Body.Close()
+	return resp.StatusCode
+}
+
+func (s *testSafeModeSuite) TestSafeModeRules(c *C) {
+	c.Assert(s.setSafeMode(true, "unlock"), IsNil)
+	defer func() { c.Assert(s.setSafeMode(false, "unlock"), IsNil) }()
+
+	// The requests which remove or replace the rules are blocked.
+	requests := []struct {
+		method, url, body string
+	}{
+		{http.MethodPost, "/config/rules", "[]"},
+		{http.MethodPost, "/config/rules/batch", `[{"action":"del","rule":{"group_id":"pd","id":"default"}}]`},
+		{http.MethodDelete, "/config/rule/pd/default", ""},
+		{http.MethodDelete, "/config/rule_group/pd", ""},
+		{http.MethodPost, "/config/placement-rule", "[]"},
+		{http.MethodPost, "/config/placement-rule/pd", `{"group_id":"pd"}`},
+		{http.MethodDelete, "/config/placement-rule/pd", ""},
+	}
+	for _, r := range requests {
+		c.Assert(s.doRequest(c, r.method, r.url, r.body, ""), Equals, http.StatusForbidden, Commentf("%s %s", r.method, r.url))
+	}
+	var rules []*placement.Rule
+	c.Assert(readJSON(testDialClient, s.urlPrefix+"/config/rules", &rules), IsNil)
+	c.Assert(rules, HasLen, 1)
+
+	// They are allowed with the unlock token.
+	c.Assert(s.doRequest(c, http.MethodDelete, "/config/rule_group/foo", "", "unlock"), Equals, http.StatusOK)
+}
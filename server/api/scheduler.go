@@ -17,6 +17,7 @@ import (
 	"encoding/json"
 	"fmt"
 	"net/http"
+	"strconv"
 	"strings"
 
 	"github.com/gorilla/mux"
@@ -25,12 +26,16 @@ import (
 	"github.com/tikv/pd/pkg/apiutil"
 	"github.com/tikv/pd/pkg/errs"
 	"github.com/tikv/pd/server"
+	"github.com/tikv/pd/server/cluster"
 	"github.com/tikv/pd/server/schedulers"
 	"github.com/unrolled/render"
 	"go.uber.org/zap"
 )
 
-const schedulerConfigPrefix = "pd/api/v1/scheduler-config"
+const (
+	schedulerConfigPrefix        = "pd/api/v1/scheduler-config"
+	defaultSchedulerPreviewCount = 10
+)
 
 type schedulerHandler struct {
 	*server.Handler
@@ -218,10 +223,18 @@ func (h *schedulerHandler) addEvictOrGrant(w http.ResponseWriter, input map[stri
 		h.r.JSON(w, http.StatusBadRequest, "missing store id")
 		return
 	}
+	if err := h.addOrUpdateEvictOrGrant(name, storeID); err != nil {
+		h.r.JSON(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+}
+
+// addOrUpdateEvictOrGrant creates the scheduler with the store, or adds the
+// store to the scheduler if it already exists.
+func (h *schedulerHandler) addOrUpdateEvictOrGrant(name string, storeID float64) error {
 	if exist, err := h.Handler.IsSchedulerExisted(name); !exist {
 		if err != nil && !errors.ErrorEqual(err, errs.ErrSchedulerNotFound.FastGenByArgs()) {
-			h.r.JSON(w, http.StatusInternalServerError, err.Error())
-			return
+			return err
 		}
 		switch name {
 		case schedulers.EvictLeaderName:
@@ -229,22 +242,19 @@ func (h *schedulerHandler) addEvictOrGrant(w http.ResponseWriter, input map[stri
 		case schedulers.GrantLeaderName:
 			err = h.AddGrantLeaderScheduler(uint64(storeID))
 		}
-		if err != nil {
-			h.r.JSON(w, http.StatusInternalServerError, err.Error())
-			return
-		}
-	} else {
-		if err := h.redirectSchedulerUpdate(name, storeID); err != nil {
-			h.r.JSON(w, http.StatusInternalServerError, err.Error())
-			return
-		}
-		log.Info("update scheduler", zap.String("scheduler-name", name), zap.Uint64("store-id", uint64(storeID)))
+		return err
 	}
+	if err := h.redirectSchedulerUpdate(name, storeID); err != nil {
+		return err
+	}
+	log.Info("update scheduler", zap.String("scheduler-name", name), zap.Uint64("store-id", uint64(storeID)))
+	return nil
 }
 
 // @Tags scheduler
 // @Summary Delete a scheduler.
 // @Param name path string true "The name of the scheduler."
+// @Param cancel-operators query boolean false "Whether to cancel the operators created by the scheduler."
 // @Produce json
 // @Success 200 {string} string "The scheduler is removed."
 // @Failure 404 {string} string "The scheduler is not found."
@@ -264,6 +274,9 @@ func (h *schedulerHandler) Delete(w http.ResponseWriter, r *http.Request) {
 			h.handleErr(w, err)
 			return
 		}
+		if !h.cancelOperatorsIfRequired(w, r, name) {
+			return
+		}
 	}
 	h.r.JSON(w, http.StatusOK, "The scheduler is removed.")
 }
@@ -294,6 +307,7 @@ func (h *schedulerHandler) redirectSchedulerDelete(w http.ResponseWriter, name,
 // @Accept json
 // @Param name path string true "The name of the scheduler."
 // @Param body body object true "json params"
+// @Param cancel-operators query boolean false "Whether to cancel the operators created by the scheduler when it is paused."
 // @Produce json
 // @Success 200 {string} string "Pause or resume the scheduler successfully."
 // @Failure 400 {string} string "Bad format request."
@@ -315,9 +329,156 @@ func (h *schedulerHandler) PauseOrResume(w http.ResponseWriter, r *http.Request)
 		h.r.JSON(w, http.StatusInternalServerError, err.Error())
 		return
 	}
+	if t > 0 && !h.cancelOperatorsIfRequired(w, r, name) {
+		return
+	}
 	h.r.JSON(w, http.StatusOK, "Pause or resume the scheduler successfully.")
 }
 
+// cancelOperatorsIfRequired cancels the operators of the scheduler if the
+// request asks to, and returns false if it fails and the response is written.
+func (h *schedulerHandler) cancelOperatorsIfRequired(w http.ResponseWriter, r *http.Request, name string) bool {
+	if _, ok := r.URL.Query()["cancel-operators"]; !ok {
+		return true
+	}
+	if _, err := h.CancelSchedulerOperators(name); err != nil {
+		h.r.JSON(w, http.StatusInternalServerError, err.Error())
+		return false
+	}
+	return true
+}
+
+// @Tags scheduler
+// @Summary Cancel the operators created by a scheduler gracefully. The running operators are stopped once it is safe to, and the learners they added for promotion are removed.
+// @Param name path string true "The name of the scheduler, or \"all\" for all the schedulers."
+// @Produce json
+// @Success 200 {string} string "The operators are canceled."
+// @Failure 500 {string} string "PD server failed to proceed the request."
+// @Router /schedulers/{name}/cancel-operators [post]
+func (h *schedulerHandler) CancelOperators(w http.ResponseWriter, r *http.Request) {
+	name := mux.Vars(r)["name"]
+	canceled, err := h.CancelSchedulerOperators(name)
+	if err != nil {
+		h.r.JSON(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	h.r.JSON(w, http.StatusOK, fmt.Sprintf("%d operators are canceled.", canceled))
+}
+
+// FIXME: details of input json body params
+// @Tags scheduler
+// @Summary Set the daily window in which the scheduler is allowed to schedule, in the local time of PD. An empty window removes the limit.
+// @Accept json
+// @Param name path string true "The name of the scheduler."
+// @Param body body object true "json params, e.g. {\"window\": \"00:00-06:00\"}"
+// @Produce json
+// @Success 200 {string} string "Set the scheduler window successfully."
+// @Failure 400 {string} string "Bad format request."
+// @Failure 404 {string} string "The scheduler is not found."
+// @Failure 500 {string} string "PD server failed to proceed the request."
+// @Router /schedulers/{name}/window [post]
+func (h *schedulerHandler) SetWindow(w http.ResponseWriter, r *http.Request) {
+	var input map[string]string
+	if err := apiutil.ReadJSONRespondError(h.r, w, r.Body, &input); err != nil {
+		return
+	}
+	window, ok := input["window"]
+	if !ok {
+		h.r.JSON(w, http.StatusBadRequest, "missing window")
+		return
+	}
+	if err := h.SetSchedulerWindow(mux.Vars(r)["name"], window); err != nil {
+		switch {
+		case errs.ErrSchedulerConfig.Equal(err):
+			h.r.JSON(w, http.StatusBadRequest, err.Error())
+		case errors.ErrorEqual(err, errs.ErrSchedulerNotFound.FastGenByArgs()):
+			h.r.JSON(w, http.StatusNotFound, err.Error())
+		default:
+			h.r.JSON(w, http.StatusInternalServerError, err.Error())
+		}
+		return
+	}
+	h.r.JSON(w, http.StatusOK, "Set the scheduler window successfully.")
+}
+
+// FIXME: details of input json body params
+// @Tags scheduler
+// @Summary Switch the shadow mode of the scheduler, in which it computes the operators every tick, but never dispatches them.
+// @Accept json
+// @Param name path string true "The name of the scheduler."
+// @Param body body object true "json params, e.g. {\"shadow\": true}"
+// @Produce json
+// @Success 200 {string} string "Set the scheduler shadow mode successfully."
+// @Failure 400 {string} string "Bad format request."
+// @Failure 404 {string} string "The scheduler is not found."
+// @Failure 500 {string} string "PD server failed to proceed the request."
+// @Router /schedulers/{name}/shadow [post]
+func (h *schedulerHandler) SetShadow(w http.ResponseWriter, r *http.Request) {
+	var input map[string]bool
+	if err := apiutil.ReadJSONRespondError(h.r, w, r.Body, &input); err != nil {
+		return
+	}
+	shadow, ok := input["shadow"]
+	if !ok {
+		h.r.JSON(w, http.StatusBadRequest, "missing shadow")
+		return
+	}
+	if err := h.SetSchedulerShadow(mux.Vars(r)["name"], shadow); err != nil {
+		h.handleErr(w, err)
+		return
+	}
+	h.r.JSON(w, http.StatusOK, "Set the scheduler shadow mode successfully.")
+}
+
+// @Tags scheduler
+// @Summary Get the operators the scheduler has produced in the shadow mode.
+// @Param name path string true "The name of the scheduler."
+// @Produce json
+// @Success 200 {object} cluster.SchedulerShadowStatus
+// @Failure 404 {string} string "The scheduler is not found."
+// @Failure 500 {string} string "PD server failed to proceed the request."
+// @Router /schedulers/{name}/shadow [get]
+func (h *schedulerHandler) GetShadowStatus(w http.ResponseWriter, r *http.Request) {
+	status, err := h.GetSchedulerShadowStatus(mux.Vars(r)["name"])
+	if err != nil {
+		h.handleErr(w, err)
+		return
+	}
+	h.r.JSON(w, http.StatusOK, status)
+}
+
+// @Tags scheduler
+// @Summary Preview the operators the scheduler would produce next against a snapshot of the cluster, without executing them.
+// @Param name path string true "The name of the scheduler."
+// @Param count query integer false "The max number of the operators, 10 by default and 100 at most."
+// @Produce json
+// @Success 200 {object} cluster.SchedulerPreview
+// @Failure 400 {string} string "The input is invalid."
+// @Failure 404 {string} string "The scheduler is not found."
+// @Failure 500 {string} string "PD server failed to proceed the request."
+// @Router /schedulers/{name}/preview [get]
+func (h *schedulerHandler) Preview(w http.ResponseWriter, r *http.Request) {
+	count := defaultSchedulerPreviewCount
+	if value := r.URL.Query().Get("count"); value != "" {
+		var err error
+		count, err = strconv.Atoi(value)
+		if err != nil {
+			h.r.JSON(w, http.StatusBadRequest, errs.ErrStrconvParseInt.Wrap(err).GenWithStackByCause().Error())
+			return
+		}
+		if count <= 0 || count > cluster.MaxSchedulerPreviewCount {
+			h.r.JSON(w, http.StatusBadRequest, fmt.Sprintf("count should be in [1, %d]", cluster.MaxSchedulerPreviewCount))
+			return
+		}
+	}
+	preview, err := h.PreviewScheduler(mux.Vars(r)["name"], count)
+	if err != nil {
+		h.handleErr(w, err)
+		return
+	}
+	h.r.JSON(w, http.StatusOK, preview)
+}
+
 type schedulerConfigHandler struct {
 	svr *server.Server
 	rd  *render.Render
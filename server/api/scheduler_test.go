@@ -16,12 +16,14 @@ package api
 import (
 	"encoding/json"
 	"fmt"
+	"net/http"
 	"time"
 
 	. "github.com/pingcap/check"
 	"github.com/pingcap/failpoint"
 	"github.com/pingcap/kvproto/pkg/metapb"
 	"github.com/tikv/pd/server"
+	"github.com/tikv/pd/server/cluster"
 	"github.com/tikv/pd/server/config"
 	_ "github.com/tikv/pd/server/schedulers"
 )
@@ -397,6 +399,108 @@ func (s *testScheduleSuite) TestDisable(c *C) {
 	s.deleteScheduler(name, c)
 }
 
+func (s *testScheduleSuite) TestShadow(c *C) {
+	name := "shuffle-region-scheduler"
+	body, err := json.Marshal(map[string]interface{}{"name": name})
+	c.Assert(err, IsNil)
+	s.addScheduler(name, name, body, nil, c)
+	defer s.deleteScheduler(name, c)
+
+	shadowURL := fmt.Sprintf("%s/%s/shadow", s.urlPrefix, name)
+	c.Assert(postJSON(testDialClient, shadowURL, []byte(`{"shadow": true}`)), IsNil)
+	var status cluster.SchedulerShadowStatus
+	c.Assert(readJSON(testDialClient, shadowURL, &status), IsNil)
+	c.Assert(status.Scheduler, Equals, name)
+	c.Assert(status.Shadow, IsTrue)
+	u := fmt.Sprintf("%s%s/api/v1/config/schedule", s.svr.GetAddr(), apiPrefix)
+	var scheduleConfig config.ScheduleConfig
+	c.Assert(readJSON(testDialClient, u, &scheduleConfig), IsNil)
+	var shadow bool
+	for _, cfg := range scheduleConfig.Schedulers {
+		if cfg.Type == "shuffle-region" {
+			shadow = cfg.Shadow
+		}
+	}
+	c.Assert(shadow, IsTrue)
+
+	c.Assert(postJSON(testDialClient, shadowURL, []byte(`{"shadow": "yes"}`)), NotNil)
+	c.Assert(postJSON(testDialClient, shadowURL, []byte(`{}`)), NotNil)
+	c.Assert(postJSON(testDialClient, fmt.Sprintf("%s/%s/shadow", s.urlPrefix, "unknown"), []byte(`{"shadow": true}`)), NotNil)
+	c.Assert(readJSON(testDialClient, fmt.Sprintf("%s/%s/shadow", s.urlPrefix, "unknown"), &status), NotNil)
+	c.Assert(postJSON(testDialClient, shadowURL, []byte(`{"shadow": false}`)), IsNil)
+	c.Assert(readJSON(testDialClient, shadowURL, &status), IsNil)
+	c.Assert(status.Shadow, IsFalse)
+}
+
+func (s *testScheduleSuite) TestWindow(c *C) {
+	name := "shuffle-region-scheduler"
+	body, err := json.Marshal(map[string]interface{}{"name": name})
+	c.Assert(err, IsNil)
+	s.addScheduler(name, name, body, nil, c)
+	defer s.deleteScheduler(name, c)
+
+	windowURL := fmt.Sprintf("%s/%s/window", s.urlPrefix, name)
+	c.Assert(postJSON(testDialClient, windowURL, []byte(`{"window": "01:00-02:00"}`)), IsNil)
+	u := fmt.Sprintf("%s%s/api/v1/config/schedule", s.svr.GetAddr(), apiPrefix)
+	var scheduleConfig config.ScheduleConfig
+	c.Assert(readJSON(testDialClient, u, &scheduleConfig), IsNil)
+	var window string
+	for _, cfg := range scheduleConfig.Schedulers {
+		if cfg.Type == "shuffle-region" {
+			window = cfg.Window
+		}
+	}
+	c.Assert(window, Equals, "01:00-02:00")
+
+	c.Assert(postJSON(testDialClient, windowURL, []byte(`{"window": "1am"}`)), NotNil)
+	c.Assert(postJSON(testDialClient, fmt.Sprintf("%s/%s/window", s.urlPrefix, "unknown"), []byte(`{"window": ""}`)), NotNil)
+	c.Assert(postJSON(testDialClient, windowURL, []byte(`{"window": ""}`)), IsNil)
+}
+
+func (s *testScheduleSuite) TestCancelOperators(c *C) {
+	name := "shuffle-region-scheduler"
+	body, err := json.Marshal(map[string]interface{}{"name": name})
+	c.Assert(err, IsNil)
+	s.addScheduler(name, name, body, nil, c)
+
+	c.Assert(postJSON(testDialClient, fmt.Sprintf("%s/%s/cancel-operators", s.urlPrefix, name), nil), IsNil)
+	c.Assert(postJSON(testDialClient, fmt.Sprintf("%s/all/cancel-operators", s.urlPrefix), nil), IsNil)
+	c.Assert(postJSON(testDialClient, fmt.Sprintf("%s/%s?cancel-operators=true", s.urlPrefix, name), []byte(`{"delay": 30}`)), IsNil)
+	_, err = doDelete(testDialClient, fmt.Sprintf("%s/%s?cancel-operators=true", s.urlPrefix, name))
+	c.Assert(err, IsNil)
+	for _, scheduler := range s.svr.GetRaftCluster().GetSchedulers() {
+		c.Assert(scheduler, Not(Equals), name)
+	}
+}
+
+func (s *testScheduleSuite) TestPreview(c *C) {
+	name := "shuffle-region-scheduler"
+	body, err := json.Marshal(map[string]interface{}{"name": name})
+	c.Assert(err, IsNil)
+	s.addScheduler(name, name, body, nil, c)
+	defer s.deleteScheduler(name, c)
+
+	previewURL := fmt.Sprintf("%s/%s/preview", s.urlPrefix, name)
+	var preview cluster.SchedulerPreview
+	c.Assert(readJSON(testDialClient, previewURL+"?count=2", &preview), IsNil)
+	c.Assert(preview.Scheduler, Equals, name)
+	c.Assert(len(preview.Operators) <= 2, IsTrue)
+	for _, t := range []struct {
+		url  string
+		code int
+	}{
+		{previewURL + "?count=abc", http.StatusBadRequest},
+		{previewURL + "?count=0", http.StatusBadRequest},
+		{previewURL + "?count=101", http.StatusBadRequest},
+		{fmt.Sprintf("%s/%s/preview", s.urlPrefix, "unknown"), http.StatusNotFound},
+	} {
+		resp, err := testDialClient.Get(t.url)
+		c.Assert(err, IsNil)
+		resp.Body.Close()
+		c.Assert(resp.StatusCode, Equals, t.code)
+	}
+}
+
 func (s *testScheduleSuite) addScheduler(name, createdName string, body []byte, extraTest func(string, *C), c *C) {
 	if createdName == "" {
 		createdName = name
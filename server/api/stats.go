@@ -45,3 +45,13 @@ func (h *statsHandler) Region(w http.ResponseWriter, r *http.Request) {
 	stats := rc.GetRegionStats([]byte(startKey), []byte(endKey))
 	h.rd.JSON(w, http.StatusOK, stats)
 }
+
+// @Tags stats
+// @Summary Get the placement drift, which is the fraction of the regions not fully fitting the placement rules weighted by size. It is refreshed periodically.
+// @Produce json
+// @Success 200 {object} statistics.PlacementDrift
+// @Router /stats/placement-drift [get]
+func (h *statsHandler) PlacementDrift(w http.ResponseWriter, r *http.Request) {
+	rc := getCluster(r)
+	h.rd.JSON(w, http.StatusOK, rc.GetPlacementDrift())
+}
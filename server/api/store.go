@@ -17,6 +17,7 @@ import (
 	"fmt"
 	"net/http"
 	"net/url"
+	"regexp"
 	"strconv"
 	"strings"
 	"time"
@@ -57,9 +58,12 @@ type StoreStatus struct {
 	SendingSnapCount   uint32             `json:"sending_snap_count,omitempty"`
 	ReceivingSnapCount uint32             `json:"receiving_snap_count,omitempty"`
 	IsBusy             bool               `json:"is_busy,omitempty"`
+	IsReadOnly         bool               `json:"is_read_only,omitempty"`
+	ResourceTags       map[string]string  `json:"resource_tags,omitempty"`
 	StartTS            *time.Time         `json:"start_ts,omitempty"`
 	LastHeartbeatTS    *time.Time         `json:"last_heartbeat_ts,omitempty"`
 	Uptime             *typeutil.Duration `json:"uptime,omitempty"`
+	SuspectUntil       *time.Time         `json:"suspect_until,omitempty"`
 }
 
 // StoreInfo contains information about a store.
@@ -94,6 +98,8 @@ func newStoreInfo(opt *config.ScheduleConfig, store *core.StoreInfo) *StoreInfo
 			SendingSnapCount:   store.GetSendingSnapCount(),
 			ReceivingSnapCount: store.GetReceivingSnapCount(),
 			IsBusy:             store.IsBusy(),
+			IsReadOnly:         store.IsReadOnly(),
+			ResourceTags:       store.GetResourceTags(),
 		},
 	}
 
@@ -101,6 +107,13 @@ func newStoreInfo(opt *config.ScheduleConfig, store *core.StoreInfo) *StoreInfo
 		startTS := store.GetStartTime()
 		s.Status.StartTS = &startTS
 	}
+	if store.IsSuspect() {
+		suspectUntil := store.GetSuspectUntil()
+		if store.IsRestarting() && store.GetRestartDeadline().After(suspectUntil) {
+			suspectUntil = store.GetRestartDeadline()
+		}
+		s.Status.SuspectUntil = &suspectUntil
+	}
 	if lastHeartbeat := store.GetLastHeartbeatTS(); !lastHeartbeat.IsZero() {
 		s.Status.LastHeartbeatTS = &lastHeartbeat
 	}
@@ -233,6 +246,31 @@ func (h *storeHandler) SetState(w http.ResponseWriter, r *http.Request) {
 	h.rd.JSON(w, http.StatusOK, "The store's state is updated.")
 }
 
+// @Tags store
+// @Summary List the regions which block the store from being offline safely, with the reasons.
+// @Param id path integer true "Store Id"
+// @Produce json
+// @Success 200 {object} cluster.StoreRemovalCheck
+// @Failure 400 {string} string "The input is invalid."
+// @Failure 404 {string} string "The store does not exist."
+// @Router /store/{id}/offline-check [get]
+func (h *storeHandler) CheckOffline(w http.ResponseWriter, r *http.Request) {
+	rc := getCluster(r)
+	vars := mux.Vars(r)
+	storeID, errParse := apiutil.ParseUint64VarsField(vars, "id")
+	if errParse != nil {
+		apiutil.ErrorResp(h.rd, w, errcode.NewInvalidInputErr(errParse))
+		return
+	}
+
+	check, err := rc.CheckStoreRemoval(storeID)
+	if err != nil {
+		h.responseStoreErr(w, err, storeID)
+		return
+	}
+	h.rd.JSON(w, http.StatusOK, check)
+}
+
 func (h *storeHandler) responseStoreErr(w http.ResponseWriter, err error, storeID uint64) {
 	if errors.ErrorEqual(err, errs.ErrStoreNotFound.FastGenByArgs(storeID)) {
 		h.rd.JSON(w, http.StatusNotFound, err.Error())
@@ -348,6 +386,193 @@ func (h *storeHandler) SetWeight(w http.ResponseWriter, r *http.Request) {
 	h.rd.JSON(w, http.StatusOK, "The store's label is updated.")
 }
 
+// @Tags store
+// @Summary Set whether the store is read-only. A read-only store keeps its replicas and serves reads, but it is not selected as the target of transfer leader or add peer.
+// @Param id path integer true "Store Id"
+// @Param body body object true "json params, e.g. {\"read-only\": true}"
+// @Produce json
+// @Success 200 {string} string "The store's read-only state is updated."
+// @Failure 400 {string} string "The input is invalid."
+// @Failure 500 {string} string "PD server failed to proceed the request."
+// @Router /store/{id}/read-only [post]
+func (h *storeHandler) SetReadOnly(w http.ResponseWriter, r *http.Request) {
+	rc := getCluster(r)
+	vars := mux.Vars(r)
+	storeID, errParse := apiutil.ParseUint64VarsField(vars, "id")
+	if errParse != nil {
+		apiutil.ErrorResp(h.rd, w, errcode.NewInvalidInputErr(errParse))
+		return
+	}
+
+	var input map[string]interface{}
+	if err := apiutil.ReadJSONRespondError(h.rd, w, r.Body, &input); err != nil {
+		return
+	}
+	readOnly, ok := input["read-only"].(bool)
+	if !ok {
+		h.rd.JSON(w, http.StatusBadRequest, "bad format read-only")
+		return
+	}
+
+	if err := rc.SetStoreReadOnly(storeID, readOnly); err != nil {
+		h.rd.JSON(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	h.rd.JSON(w, http.StatusOK, "The store's read-only state is updated.")
+}
+
+// @Tags store
+// @Summary Reset the fencing token of the store, so that a new token is issued when the store registers itself again. It is used when the store is redeployed with the same store ID deliberately.
+// @Param id path integer true "Store Id"
+// @Produce json
+// @Success 200 {string} string "The store's fencing token is reset."
+// @Failure 400 {string} string "The input is invalid."
+// @Failure 404 {string} string "The store does not exist."
+// @Failure 500 {string} string "PD server failed to proceed the request."
+// @Router /store/{id}/fencing-token [delete]
+func (h *storeHandler) ResetFencingToken(w http.ResponseWriter, r *http.Request) {
+	rc := getCluster(r)
+	vars := mux.Vars(r)
+	storeID, errParse := apiutil.ParseUint64VarsField(vars, "id")
+	if errParse != nil {
+		apiutil.ErrorResp(h.rd, w, errcode.NewInvalidInputErr(errParse))
+		return
+	}
+
+	if err := rc.ResetStoreFencingToken(storeID); err != nil {
+		if errs.ErrStoreNotFound.Equal(err) {
+			h.rd.JSON(w, http.StatusNotFound, err.Error())
+			return
+		}
+		h.rd.JSON(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	h.rd.JSON(w, http.StatusOK, "The store's fencing token is reset.")
+}
+
+// @Tags store
+// @Summary Replace the resource tags of the store, such as {"disk": "nvme"}. The resource tags are separated from the location labels, and they can be used by the label constraints of the placement rules and the store limit profiles.
+// @Param id path integer true "Store Id"
+// @Param body body object true "json params, e.g. {\"disk\": \"nvme\", \"cpu\": \"32c\"}"
+// @Produce json
+// @Success 200 {string} string "The store's resource tags are updated."
+// @Failure 400 {string} string "The input is invalid."
+// @Failure 500 {string} string "PD server failed to proceed the request."
+// @Router /store/{id}/resource-tags [post]
+func (h *storeHandler) SetResourceTags(w http.ResponseWriter, r *http.Request) {
+	rc := getCluster(r)
+	vars := mux.Vars(r)
+	storeID, errParse := apiutil.ParseUint64VarsField(vars, "id")
+	if errParse != nil {
+		apiutil.ErrorResp(h.rd, w, errcode.NewInvalidInputErr(errParse))
+		return
+	}
+
+	var tags map[string]string
+	if err := apiutil.ReadJSONRespondError(h.rd, w, r.Body, &tags); err != nil {
+		return
+	}
+
+	if err := rc.SetStoreResourceTags(storeID, tags); err != nil {
+		h.rd.JSON(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	h.rd.JSON(w, http.StatusOK, "The store's resource tags are updated.")
+}
+
+// @Tags store
+// @Summary Get the archive of a removed tombstone store.
+// @Param id path integer true "Store Id"
+// @Produce json
+// @Success 200 {object} core.StoreArchive
+// @Failure 400 {string} string "The input is invalid."
+// @Failure 404 {string} string "The store archive is not found."
+// @Failure 500 {string} string "PD server failed to proceed the request."
+// @Router /store/{id}/archive [get]
+func (h *storeHandler) GetArchive(w http.ResponseWriter, r *http.Request) {
+	rc := getCluster(r)
+	vars := mux.Vars(r)
+	storeID, errParse := apiutil.ParseUint64VarsField(vars, "id")
+	if errParse != nil {
+		apiutil.ErrorResp(h.rd, w, errcode.NewInvalidInputErr(errParse))
+		return
+	}
+
+	archive, err := rc.GetStoreArchive(storeID)
+	if errs.ErrStoreArchiveNotFound.Equal(err) {
+		h.rd.JSON(w, http.StatusNotFound, err.Error())
+		return
+	}
+	if err != nil {
+		h.rd.JSON(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	h.rd.JSON(w, http.StatusOK, archive)
+}
+
+// @Tags store
+// @Summary Get the history of the state transitions of a store.
+// @Param id path integer true "Store Id"
+// @Produce json
+// @Success 200 {object} cluster.StoreTimeline
+// @Failure 400 {string} string "The input is invalid."
+// @Failure 404 {string} string "The store is not found."
+// @Failure 500 {string} string "PD server failed to proceed the request."
+// @Router /store/{id}/timeline [get]
+func (h *storeHandler) GetTimeline(w http.ResponseWriter, r *http.Request) {
+	rc := getCluster(r)
+	vars := mux.Vars(r)
+	storeID, errParse := apiutil.ParseUint64VarsField(vars, "id")
+	if errParse != nil {
+		apiutil.ErrorResp(h.rd, w, errcode.NewInvalidInputErr(errParse))
+		return
+	}
+
+	timeline, err := rc.GetStoreTimeline(storeID)
+	if errs.ErrStoreNotFound.Equal(err) {
+		h.rd.JSON(w, http.StatusNotFound, err.Error())
+		return
+	}
+	if err != nil {
+		h.rd.JSON(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	h.rd.JSON(w, http.StatusOK, timeline)
+}
+
+// @Tags store
+// @Summary Restore a removed tombstone store from its archive with the same meta and weights.
+// @Param id path integer true "Store Id"
+// @Produce json
+// @Success 200 {string} string "The store is restored."
+// @Failure 400 {string} string "The input is invalid."
+// @Failure 404 {string} string "The store archive is not found."
+// @Failure 500 {string} string "PD server failed to proceed the request."
+// @Router /store/{id}/restore-archive [post]
+func (h *storeHandler) RestoreArchive(w http.ResponseWriter, r *http.Request) {
+	rc := getCluster(r)
+	vars := mux.Vars(r)
+	storeID, errParse := apiutil.ParseUint64VarsField(vars, "id")
+	if errParse != nil {
+		apiutil.ErrorResp(h.rd, w, errcode.NewInvalidInputErr(errParse))
+		return
+	}
+
+	err := rc.RestoreStoreArchive(storeID)
+	if errs.ErrStoreArchiveNotFound.Equal(err) {
+		h.rd.JSON(w, http.StatusNotFound, err.Error())
+		return
+	}
+	if err != nil {
+		h.rd.JSON(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	h.rd.JSON(w, http.StatusOK, "The store is restored.")
+}
+
 // FIXME: details of input json body params
 // @Tags store
 // @Summary Set the store's limit.
@@ -607,6 +832,61 @@ func (h *storesHandler) GetStoreLimitScene(w http.ResponseWriter, r *http.Reques
 	h.rd.JSON(w, http.StatusOK, scene)
 }
 
+// @Tags store
+// @Summary List the peers removed by PD which are not acknowledged by their stores yet.
+// @Produce json
+// @Success 200 {array} cluster.StoreStalePeers
+// @Router /stores/stale-peers [get]
+func (h *storesHandler) GetStalePeers(w http.ResponseWriter, r *http.Request) {
+	rc := getCluster(r)
+	h.rd.JSON(w, http.StatusOK, rc.GetStalePeers())
+}
+
+// @Tags store
+// @Summary List the capacity forecasts of the stores, which are extrapolated from the trends of their available capacity.
+// @Produce json
+// @Success 200 {array} statistics.CapacityForecast
+// @Router /stores/capacity-forecast [get]
+func (h *storesHandler) GetCapacityForecast(w http.ResponseWriter, r *http.Request) {
+	rc := getCluster(r)
+	h.rd.JSON(w, http.StatusOK, rc.GetCapacityForecasts())
+}
+
+type resourceTagsInput struct {
+	// Address is the regular expression matching the addresses of the stores.
+	Address string            `json:"address"`
+	Tags    map[string]string `json:"tags"`
+}
+
+// @Tags store
+// @Summary Merge the resource tags into the stores whose addresses match the regular expression. A tag with an empty value is removed.
+// @Accept json
+// @Param body body resourceTagsInput true "json params, e.g. {\"address\": \"^10\\.0\\.1\\.\", \"tags\": {\"disk\": \"nvme\"}}"
+// @Produce json
+// @Success 200 {array} uint64 "The IDs of the stores updated."
+// @Failure 400 {string} string "The input is invalid."
+// @Failure 500 {string} string "PD server failed to proceed the request."
+// @Router /stores/resource-tags [post]
+func (h *storesHandler) SetResourceTags(w http.ResponseWriter, r *http.Request) {
+	var input resourceTagsInput
+	if err := apiutil.ReadJSONRespondError(h.rd, w, r.Body, &input); err != nil {
+		return
+	}
+	if _, err := regexp.Compile(input.Address); err != nil {
+		h.rd.JSON(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	storeIDs, err := getCluster(r).SetResourceTagsByAddress(input.Address, input.Tags)
+	if err != nil {
+		h.rd.JSON(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	if storeIDs == nil {
+		storeIDs = []uint64{}
+	}
+	h.rd.JSON(w, http.StatusOK, storeIDs)
+}
+
 // @Tags store
 // @Summary Get stores in the cluster.
 // @Param state query array true "Specify accepted store states."
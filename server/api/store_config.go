@@ -0,0 +1,65 @@
+// Copyright 2021 TiKV Project Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package api
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/gorilla/mux"
+	"github.com/pingcap/errcode"
+	"github.com/tikv/pd/pkg/apiutil"
+	"github.com/unrolled/render"
+)
+
+type storeConfigHandler struct {
+	rd *render.Render
+}
+
+func newStoreConfigHandler(rd *render.Render) *storeConfigHandler {
+	return &storeConfigHandler{
+		rd: rd,
+	}
+}
+
+// @Tags store
+// @Summary Get the configs of all stores observed by PD. The versions are maintained by the current PD leader, and they restart from 1 after the leader changes.
+// @Produce json
+// @Success 200 {array} storeconfig.Config
+// @Router /stores/config [get]
+func (h *storeConfigHandler) GetAll(w http.ResponseWriter, r *http.Request) {
+	h.rd.JSON(w, http.StatusOK, getCluster(r).GetStoreConfigManager().GetConfigs())
+}
+
+// @Tags store
+// @Summary Get the config of a store observed by PD. The version is maintained by the current PD leader, and it restarts from 1 after the leader changes.
+// @Param id path integer true "Store Id"
+// @Produce json
+// @Success 200 {object} storeconfig.Config
+// @Failure 400 {string} string "The input is invalid."
+// @Failure 404 {string} string "The config of the store is not synchronized yet."
+// @Router /store/{id}/config [get]
+func (h *storeConfigHandler) Get(w http.ResponseWriter, r *http.Request) {
+	storeID, errParse := apiutil.ParseUint64VarsField(mux.Vars(r), "id")
+	if errParse != nil {
+		apiutil.ErrorResp(h.rd, w, errcode.NewInvalidInputErr(errParse))
+		return
+	}
+	cfg := getCluster(r).GetStoreConfigManager().GetConfig(storeID)
+	if cfg == nil {
+		h.rd.JSON(w, http.StatusNotFound, fmt.Sprintf("the config of store %d is not synchronized yet", storeID))
+		return
+	}
+	h.rd.JSON(w, http.StatusOK, cfg)
+}
@@ -0,0 +1,180 @@
+// Copyright 2021 TiKV Project Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package api
+
+import (
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/pingcap/errcode"
+	"github.com/pingcap/log"
+	"github.com/tikv/pd/pkg/apiutil"
+	"github.com/tikv/pd/pkg/errs"
+	"github.com/tikv/pd/server"
+	"github.com/tikv/pd/server/schedulers"
+	"github.com/unrolled/render"
+	"go.uber.org/zap"
+)
+
+const (
+	defaultRestartWindow = 10 * time.Minute
+	maxRestartWindow     = time.Hour
+)
+
+// StoreRestartStatus shows whether a store is ready to be stopped.
+type StoreRestartStatus struct {
+	StoreID         uint64    `json:"store_id"`
+	LeaderCount     int       `json:"leader_count"`
+	RestartDeadline time.Time `json:"restart_deadline"`
+	SafeToStop      bool      `json:"safe_to_stop"`
+}
+
+type storeRestartHandler struct {
+	scheduler *schedulerHandler
+	rd        *render.Render
+}
+
+func newStoreRestartHandler(svr *server.Server, rd *render.Render) *storeRestartHandler {
+	return &storeRestartHandler{
+		scheduler: newSchedulerHandler(svr, rd),
+		rd:        rd,
+	}
+}
+
+// FIXME: details of input json body params
+// @Tags store
+// @Summary Prepare to restart the store. The leaders are transferred away, and the down peers on the store are not replaced within the restart window.
+// @Param id path integer true "Store Id"
+// @Param body body object false "json params, e.g. {\"window\": \"10m\"}"
+// @Produce json
+// @Success 200 {object} StoreRestartStatus
+// @Failure 400 {string} string "The input is invalid."
+// @Failure 404 {string} string "The store does not exist."
+// @Failure 500 {string} string "PD server failed to proceed the request."
+// @Router /stores/{id}/prepare-restart [post]
+func (h *storeRestartHandler) Prepare(w http.ResponseWriter, r *http.Request) {
+	rc := getCluster(r)
+	storeID, errParse := apiutil.ParseUint64VarsField(mux.Vars(r), "id")
+	if errParse != nil {
+		apiutil.ErrorResp(h.rd, w, errcode.NewInvalidInputErr(errParse))
+		return
+	}
+	store := rc.GetStore(storeID)
+	if store == nil {
+		h.rd.JSON(w, http.StatusNotFound, errs.ErrStoreNotFound.FastGenByArgs(storeID).Error())
+		return
+	}
+	if !store.IsUp() {
+		h.rd.JSON(w, http.StatusBadRequest, fmt.Sprintf("store %d is not up", storeID))
+		return
+	}
+
+	window := defaultRestartWindow
+	if r.ContentLength > 0 {
+		var input map[string]interface{}
+		if err := apiutil.ReadJSONRespondError(h.rd, w, r.Body, &input); err != nil {
+			return
+		}
+		if v, ok := input["window"]; ok {
+			windowStr, ok := v.(string)
+			if !ok {
+				h.rd.JSON(w, http.StatusBadRequest, "bad format window")
+				return
+			}
+			var err error
+			if window, err = time.ParseDuration(windowStr); err != nil || window <= 0 || window > maxRestartWindow {
+				h.rd.JSON(w, http.StatusBadRequest, fmt.Sprintf("window should be a duration in (0, %s]", maxRestartWindow))
+				return
+			}
+		}
+	}
+
+	// The leaders may have been evicted by the operator before, and then the
+	// eviction should be kept after the window ends.
+	evictLeader := !rc.IsEvictingLeader(storeID)
+	if evictLeader {
+		if err := h.scheduler.addOrUpdateEvictOrGrant(schedulers.EvictLeaderName, float64(storeID)); err != nil {
+			h.rd.JSON(w, http.StatusInternalServerError, err.Error())
+			return
+		}
+	}
+	if err := rc.StartStoreRestart(storeID, time.Now().Add(window), evictLeader); err != nil {
+		// No window records the eviction, so it would never be undone.
+		if evictLeader {
+			if err := rc.StopEvictingLeader(storeID); err != nil {
+				log.Warn("failed to stop evicting the leaders of the store", zap.Uint64("store-id", storeID), errs.ZapError(err))
+			}
+		}
+		h.rd.JSON(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	h.GetStatus(w, r)
+}
+
+// @Tags store
+// @Summary Get whether the store is safe to be stopped after preparing to restart it.
+// @Param id path integer true "Store Id"
+// @Produce json
+// @Success 200 {object} StoreRestartStatus
+// @Failure 400 {string} string "The input is invalid."
+// @Failure 404 {string} string "The store does not exist."
+// @Router /stores/{id}/prepare-restart [get]
+func (h *storeRestartHandler) GetStatus(w http.ResponseWriter, r *http.Request) {
+	rc := getCluster(r)
+	storeID, errParse := apiutil.ParseUint64VarsField(mux.Vars(r), "id")
+	if errParse != nil {
+		apiutil.ErrorResp(h.rd, w, errcode.NewInvalidInputErr(errParse))
+		return
+	}
+	store := rc.GetStore(storeID)
+	if store == nil {
+		h.rd.JSON(w, http.StatusNotFound, errs.ErrStoreNotFound.FastGenByArgs(storeID).Error())
+		return
+	}
+	h.rd.JSON(w, http.StatusOK, &StoreRestartStatus{
+		StoreID:         storeID,
+		LeaderCount:     store.GetLeaderCount(),
+		RestartDeadline: store.GetRestartDeadline(),
+		SafeToStop:      store.IsRestarting() && store.GetLeaderCount() == 0,
+	})
+}
+
+// @Tags store
+// @Summary Resume the store after it is restarted. The leaders are allowed to be transferred back, and the down peers on the store are replaced as usual. It is also done automatically when the restart window expires.
+// @Param id path integer true "Store Id"
+// @Produce json
+// @Success 200 {string} string "The store is resumed."
+// @Failure 400 {string} string "The input is invalid."
+// @Failure 404 {string} string "The store does not exist."
+// @Failure 500 {string} string "PD server failed to proceed the request."
+// @Router /stores/{id}/resume-restart [post]
+func (h *storeRestartHandler) Resume(w http.ResponseWriter, r *http.Request) {
+	rc := getCluster(r)
+	storeID, errParse := apiutil.ParseUint64VarsField(mux.Vars(r), "id")
+	if errParse != nil {
+		apiutil.ErrorResp(h.rd, w, errcode.NewInvalidInputErr(errParse))
+		return
+	}
+	if err := rc.EndStoreRestart(storeID); err != nil {
+		if errs.ErrStoreNotFound.Equal(err) {
+			h.rd.JSON(w, http.StatusNotFound, err.Error())
+		} else {
+			h.rd.JSON(w, http.StatusInternalServerError, err.Error())
+		}
+		return
+	}
+	h.rd.JSON(w, http.StatusOK, "The store is resumed.")
+}
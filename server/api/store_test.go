@@ -29,6 +29,7 @@ import (
 	"github.com/pingcap/kvproto/pkg/metapb"
 	"github.com/pingcap/kvproto/pkg/pdpb"
 	"github.com/tikv/pd/server"
+	"github.com/tikv/pd/server/cluster"
 	"github.com/tikv/pd/server/config"
 	"github.com/tikv/pd/server/core"
 )
@@ -235,6 +236,50 @@ func (s *testStoreSuite) TestStoreLabel(c *C) {
 	s.stores[0].Labels = info.Store.Labels
 }
 
+func (s *testStoreSuite) TestStoreResourceTags(c *C) {
+	err := postJSON(testDialClient, s.urlPrefix+"/store/1/resource-tags", []byte(`{"cpu":"32c"}`))
+	c.Assert(err, IsNil)
+
+	var storeIDs []uint64
+	err = postJSON(testDialClient, s.urlPrefix+"/stores/resource-tags", []byte(`{"address":"^tikv[147]$","tags":{"disk":"nvme"}}`), func(res []byte, code int) {
+		c.Assert(code, Equals, http.StatusOK)
+		c.Assert(json.Unmarshal(res, &storeIDs), IsNil)
+	})
+	c.Assert(err, IsNil)
+	// The tombstone store 7 is skipped.
+	c.Assert(storeIDs, DeepEquals, []uint64{1, 4})
+
+	var info StoreInfo
+	err = readJSON(testDialClient, s.urlPrefix+"/store/1", &info)
+	c.Assert(err, IsNil)
+	c.Assert(info.Status.ResourceTags, DeepEquals, map[string]string{"cpu": "32c", "disk": "nvme"})
+
+	err = postJSON(testDialClient, s.urlPrefix+"/stores/resource-tags", []byte(`{"address":"(","tags":{"disk":"nvme"}}`))
+	c.Assert(err, NotNil)
+
+	err = postJSON(testDialClient, s.urlPrefix+"/stores/resource-tags", []byte(`{"address":"^tikv[14]$","tags":{"cpu":"","disk":""}}`))
+	c.Assert(err, IsNil)
+	info = StoreInfo{}
+	err = readJSON(testDialClient, s.urlPrefix+"/store/1", &info)
+	c.Assert(err, IsNil)
+	c.Assert(info.Status.ResourceTags, HasLen, 0)
+}
+
+func (s *testStoreSuite) TestStoreResetFencingToken(c *C) {
+	rc := s.svr.GetRaftCluster()
+	token, err := rc.PutStoreWithFencingToken(s.stores[1], "")
+	c.Assert(err, IsNil)
+	c.Assert(rc.CheckStoreFencingToken(4, token), IsNil)
+
+	code := requestStatusBody(c, testDialClient, http.MethodDelete, s.urlPrefix+"/store/4/fencing-token")
+	c.Assert(code, Equals, http.StatusOK)
+	c.Assert(rc.GetStore(4).GetFencingToken(), Equals, "")
+	c.Assert(rc.CheckStoreFencingToken(4, ""), IsNil)
+
+	code = requestStatusBody(c, testDialClient, http.MethodDelete, s.urlPrefix+"/store/100/fencing-token")
+	c.Assert(code, Equals, http.StatusNotFound)
+}
+
 func (s *testStoreSuite) TestStoreDelete(c *C) {
 	table := []struct {
 		id     int
@@ -290,6 +335,33 @@ func (s *testStoreSuite) TestStoreDelete(c *C) {
 	s.SetUpSuite(c)
 }
 
+func (s *testStoreSuite) TestStoreOfflineCheck(c *C) {
+	region := newTestRegionInfo(100, 4, []byte("a"), []byte("b"),
+		core.WithAddPeer(&metapb.Peer{Id: 101, StoreId: 1, Role: metapb.PeerRole_IncomingVoter}))
+	mustRegionHeartbeat(c, s.svr, region)
+
+	url := fmt.Sprintf("%s/store/4", s.urlPrefix)
+	check := &cluster.StoreRemovalCheck{}
+	c.Assert(readJSON(testDialClient, url+"/offline-check", check), IsNil)
+	c.Assert(check.StoreID, Equals, uint64(4))
+	c.Assert(check.Safe, IsFalse)
+	c.Assert(check.Total, Equals, 1)
+	c.Assert(check.Blockers[0].RegionID, Equals, uint64(100))
+	c.Assert(check.Blockers[0].Reasons[0], Equals, cluster.RemovalBlockedJointConsensus)
+
+	// The store cannot be offline until the region leaves the joint state.
+	status := requestStatusBody(c, testDialClient, http.MethodDelete, url)
+	c.Assert(status, Equals, http.StatusBadRequest)
+	// The physically destroyed store can be removed with force.
+	status = requestStatusBody(c, testDialClient, http.MethodDelete, url+"?force=true")
+	c.Assert(status, Equals, http.StatusOK)
+	status = requestStatusBody(c, testDialClient, http.MethodGet, fmt.Sprintf("%s/store/100/offline-check", s.urlPrefix))
+	c.Assert(status, Equals, http.StatusNotFound)
+	// reset the region
+	s.cleanup()
+	s.SetUpSuite(c)
+}
+
 func (s *testStoreSuite) TestStoreSetState(c *C) {
 	url := fmt.Sprintf("%s/store/1", s.urlPrefix)
 	info := StoreInfo{}
@@ -328,6 +400,16 @@ func (s *testStoreSuite) TestStoreSetState(c *C) {
 	err = readJSON(testDialClient, url, &info)
 	c.Assert(err, IsNil)
 	c.Assert(info.Store.State, Equals, metapb.StoreState_Up)
+
+	// The state transitions are in the timeline.
+	timeline := &cluster.StoreTimeline{}
+	err = readJSON(testDialClient, url+"/timeline", timeline)
+	c.Assert(err, IsNil)
+	n := len(timeline.Events)
+	c.Assert(n >= 2, IsTrue)
+	c.Assert(timeline.Events[n-2].State, Equals, cluster.StoreTimelineOffline)
+	c.Assert(timeline.Events[n-1].State, Equals, cluster.StoreTimelineUp)
+	c.Assert(requestStatusBody(c, testDialClient, http.MethodGet, s.urlPrefix+"/store/10086/timeline"), Equals, http.StatusNotFound)
 }
 
 func (s *testStoreSuite) TestUrlStoreFilter(c *C) {
@@ -491,3 +573,38 @@ func (s *testStoreSuite) TestStoreLimitTTL(c *C) {
 	c.Assert(s.svr.GetPersistOptions().GetStoreLimit(uint64(2)).AddPeer, Not(Equals), float64(997))
 	c.Assert(s.svr.GetPersistOptions().GetStoreLimit(uint64(2)).RemovePeer, Not(Equals), float64(996))
 }
+
+func (s *testStoreSuite) TestStoreRestart(c *C) {
+	rc := s.svr.GetRaftCluster()
+	status := &StoreRestartStatus{}
+	prepareURL := fmt.Sprintf("%s/stores/%d/prepare-restart", s.urlPrefix, 1)
+	resumeURL := fmt.Sprintf("%s/stores/%d/resume-restart", s.urlPrefix, 1)
+
+	c.Assert(postJSON(testDialClient, prepareURL, []byte(`{"window": "2h"}`)), NotNil)
+	c.Assert(postJSON(testDialClient, prepareURL, []byte(`{"window": "10m"}`)), IsNil)
+	c.Assert(readJSON(testDialClient, prepareURL, status), IsNil)
+	c.Assert(status.StoreID, Equals, uint64(1))
+	c.Assert(status.RestartDeadline.After(time.Now()), IsTrue)
+	c.Assert(status.SafeToStop, IsTrue)
+	c.Assert(rc.GetStore(1).IsRestarting(), IsTrue)
+	c.Assert(rc.IsEvictingLeader(1), IsTrue)
+
+	// The eviction started by the window is stopped after resuming.
+	c.Assert(postJSON(testDialClient, resumeURL, nil), IsNil)
+	c.Assert(readJSON(testDialClient, prepareURL, status), IsNil)
+	c.Assert(status.RestartDeadline.IsZero(), IsTrue)
+	c.Assert(status.SafeToStop, IsFalse)
+	c.Assert(rc.IsEvictingLeader(1), IsFalse)
+
+	// The eviction started by the operator is kept.
+	schedulerURL := fmt.Sprintf("%s/schedulers", s.urlPrefix)
+	c.Assert(postJSON(testDialClient, schedulerURL, []byte(`{"name": "evict-leader-scheduler", "store_id": 1}`)), IsNil)
+	c.Assert(postJSON(testDialClient, prepareURL, nil), IsNil)
+	c.Assert(postJSON(testDialClient, resumeURL, nil), IsNil)
+	c.Assert(rc.GetStore(1).IsRestarting(), IsFalse)
+	c.Assert(rc.IsEvictingLeader(1), IsTrue)
+	_, err := doDelete(testDialClient, fmt.Sprintf("%s/%s", schedulerURL, "evict-leader-scheduler"))
+	c.Assert(err, IsNil)
+
+	c.Assert(postJSON(testDialClient, fmt.Sprintf("%s/stores/%d/resume-restart", s.urlPrefix, 100), nil), NotNil)
+}
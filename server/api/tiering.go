@@ -0,0 +1,117 @@
+// Copyright 2021 TiKV Project Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package api
+
+import (
+	"net/http"
+
+	"github.com/gorilla/mux"
+	"github.com/tikv/pd/pkg/apiutil"
+	"github.com/tikv/pd/pkg/errs"
+	"github.com/tikv/pd/server"
+	"github.com/tikv/pd/server/schedule/tiering"
+	"github.com/unrolled/render"
+)
+
+type tieringHandler struct {
+	svr *server.Server
+	rd  *render.Render
+}
+
+func newTieringHandler(svr *server.Server, rd *render.Render) *tieringHandler {
+	return &tieringHandler{
+		svr: svr,
+		rd:  rd,
+	}
+}
+
+// @Tags tiering
+// @Summary List all tiering policies of cluster.
+// @Produce json
+// @Success 200 {array} tiering.Policy
+// @Router /config/tiering-policies [get]
+func (h *tieringHandler) GetAll(w http.ResponseWriter, r *http.Request) {
+	cluster := getCluster(r)
+	h.rd.JSON(w, http.StatusOK, cluster.GetTieringManager().GetPolicies())
+}
+
+// @Tags tiering
+// @Summary Get tiering policy by id.
+// @Param id path string true "Policy Id"
+// @Produce json
+// @Success 200 {object} tiering.Policy
+// @Failure 404 {string} string "The policy does not exist."
+// @Router /config/tiering-policy/{id} [get]
+func (h *tieringHandler) Get(w http.ResponseWriter, r *http.Request) {
+	cluster := getCluster(r)
+	id := mux.Vars(r)["id"]
+	policy := cluster.GetTieringManager().GetPolicy(id)
+	if policy == nil {
+		h.rd.JSON(w, http.StatusNotFound, errs.ErrTieringPolicyNotFound.FastGenByArgs(id).Error())
+		return
+	}
+	h.rd.JSON(w, http.StatusOK, policy)
+}
+
+// @Tags tiering
+// @Summary Update or create a tiering policy.
+// @Accept json
+// @Param policy body tiering.Policy true "Parameters of tiering policy"
+// @Produce json
+// @Success 200 {string} string "Update tiering policy successfully."
+// @Failure 400 {string} string "The input is invalid."
+// @Failure 412 {string} string "Placement rules feature is disabled."
+// @Failure 500 {string} string "PD server failed to proceed the request."
+// @Router /config/tiering-policy [post]
+func (h *tieringHandler) Set(w http.ResponseWriter, r *http.Request) {
+	cluster := getCluster(r)
+	if !cluster.GetOpts().IsPlacementRulesEnabled() {
+		h.rd.JSON(w, http.StatusPreconditionFailed, errPlacementDisabled.Error())
+		return
+	}
+	var policy tiering.Policy
+	if err := apiutil.ReadJSONRespondError(h.rd, w, r.Body, &policy); err != nil {
+		return
+	}
+	if err := cluster.GetTieringManager().SetPolicy(&policy); err != nil {
+		if errs.ErrTieringPolicyContent.Equal(err) || errs.ErrHexDecodingString.Equal(err) {
+			h.rd.JSON(w, http.StatusBadRequest, err.Error())
+		} else {
+			h.rd.JSON(w, http.StatusInternalServerError, err.Error())
+		}
+		return
+	}
+	h.rd.JSON(w, http.StatusOK, "Update tiering policy successfully.")
+}
+
+// @Tags tiering
+// @Summary Delete tiering policy by id. The range is moved back to its original placement if it is cold.
+// @Param id path string true "Policy Id"
+// @Produce json
+// @Success 200 {string} string "Delete tiering policy successfully."
+// @Failure 404 {string} string "The policy does not exist."
+// @Failure 500 {string} string "PD server failed to proceed the request."
+// @Router /config/tiering-policy/{id} [delete]
+func (h *tieringHandler) Delete(w http.ResponseWriter, r *http.Request) {
+	cluster := getCluster(r)
+	if err := cluster.GetTieringManager().DeletePolicy(mux.Vars(r)["id"]); err != nil {
+		if errs.ErrTieringPolicyNotFound.Equal(err) {
+			h.rd.JSON(w, http.StatusNotFound, err.Error())
+		} else {
+			h.rd.JSON(w, http.StatusInternalServerError, err.Error())
+		}
+		return
+	}
+	h.rd.JSON(w, http.StatusOK, "Delete tiering policy successfully.")
+}
@@ -0,0 +1,120 @@
+// Copyright 2021 TiKV Project Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package api
+
+import (
+	"net/http"
+
+	"github.com/tikv/pd/pkg/apiutil"
+	"github.com/tikv/pd/pkg/errs"
+	"github.com/tikv/pd/server"
+	"github.com/tikv/pd/server/cluster"
+	"github.com/unrolled/render"
+)
+
+type topologyHandler struct {
+	svr *server.Server
+	rd  *render.Render
+}
+
+func newTopologyHandler(svr *server.Server, rd *render.Render) *topologyHandler {
+	return &topologyHandler{
+		svr: svr,
+		rd:  rd,
+	}
+}
+
+// @Tags topology
+// @Summary Get the current topology plan and its progress.
+// @Produce json
+// @Success 200 {object} cluster.TopologyPlan
+// @Failure 404 {string} string "The plan does not exist."
+// @Router /topology/plan [get]
+func (h *topologyHandler) GetPlan(w http.ResponseWriter, r *http.Request) {
+	plan := getCluster(r).GetTopologyPlan()
+	if plan == nil {
+		h.rd.JSON(w, http.StatusNotFound, errs.ErrTopologyPlanNotFound.FastGenByArgs().Error())
+		return
+	}
+	h.rd.JSON(w, http.StatusOK, plan)
+}
+
+// @Tags topology
+// @Summary Create a rebalancing plan for the target topology. It replaces the current plan.
+// @Accept json
+// @Param body body cluster.TopologyTarget true "The target topology"
+// @Produce json
+// @Success 200 {object} cluster.TopologyPlan
+// @Failure 400 {string} string "The input is invalid."
+// @Failure 500 {string} string "PD server failed to proceed the request."
+// @Router /topology/plan [post]
+func (h *topologyHandler) CreatePlan(w http.ResponseWriter, r *http.Request) {
+	var target cluster.TopologyTarget
+	if err := apiutil.ReadJSONRespondError(h.rd, w, r.Body, &target); err != nil {
+		return
+	}
+	plan, err := getCluster(r).PlanTopologyChange(&target)
+	if err != nil {
+		if errs.ErrTopologyTargetContent.Equal(err) {
+			h.rd.JSON(w, http.StatusBadRequest, err.Error())
+		} else {
+			h.rd.JSON(w, http.StatusInternalServerError, err.Error())
+		}
+		return
+	}
+	h.rd.JSON(w, http.StatusOK, plan)
+}
+
+// @Tags topology
+// @Summary Abort the current topology plan. The executed steps are not rolled back.
+// @Produce json
+// @Success 200 {string} string "The plan is deleted."
+// @Failure 404 {string} string "The plan does not exist."
+// @Failure 500 {string} string "PD server failed to proceed the request."
+// @Router /topology/plan [delete]
+func (h *topologyHandler) DeletePlan(w http.ResponseWriter, r *http.Request) {
+	if err := getCluster(r).DeleteTopologyPlan(); err != nil {
+		if errs.ErrTopologyPlanNotFound.Equal(err) {
+			h.rd.JSON(w, http.StatusNotFound, err.Error())
+		} else {
+			h.rd.JSON(w, http.StatusInternalServerError, err.Error())
+		}
+		return
+	}
+	h.rd.JSON(w, http.StatusOK, "The plan is deleted.")
+}
+
+// @Tags topology
+// @Summary Execute the next step of the current topology plan after the previous one reaches its checkpoint.
+// @Produce json
+// @Success 200 {object} cluster.TopologyStep
+// @Failure 404 {string} string "The plan does not exist."
+// @Failure 409 {string} string "The previous step is still running."
+// @Failure 500 {string} string "PD server failed to proceed the request."
+// @Router /topology/plan/step [post]
+func (h *topologyHandler) ExecuteStep(w http.ResponseWriter, r *http.Request) {
+	step, err := getCluster(r).ExecuteTopologyPlanStep()
+	switch {
+	case errs.ErrTopologyPlanNotFound.Equal(err):
+		h.rd.JSON(w, http.StatusNotFound, err.Error())
+	case errs.ErrTopologyPlanStepRunning.Equal(err):
+		h.rd.JSON(w, http.StatusConflict, err.Error())
+	case err != nil:
+		h.rd.JSON(w, http.StatusInternalServerError, err.Error())
+	case step == nil:
+		h.rd.JSON(w, http.StatusOK, "All steps of the plan are finished.")
+	default:
+		h.rd.JSON(w, http.StatusOK, step)
+	}
+}
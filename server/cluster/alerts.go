@@ -0,0 +1,421 @@
+// Copyright 2021 TiKV Project Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cluster
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"path"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/pingcap/log"
+	"github.com/tikv/pd/pkg/errs"
+	"github.com/tikv/pd/pkg/logutil"
+	"github.com/tikv/pd/pkg/typeutil"
+	"github.com/tikv/pd/server/events"
+	"github.com/tikv/pd/server/statistics"
+	"go.uber.org/zap"
+)
+
+const (
+	alertRulesPath = "alert_rules"
+	// maxAlertRules is the max number of the alert rules.
+	maxAlertRules = 64
+	// alertWebhookTimeout is the timeout of sending a notification to a
+	// webhook.
+	alertWebhookTimeout = 5 * time.Second
+)
+
+// The stats which the alert rules are defined over.
+const (
+	AlertDownPeerRegionCount    = "down-peer-region-count"
+	AlertPendingPeerRegionCount = "pending-peer-region-count"
+	AlertMissPeerRegionCount    = "miss-peer-region-count"
+	AlertOfflinePeerRegionCount = "offline-peer-region-count"
+	AlertDisconnectedStoreCount = "disconnected-store-count"
+	AlertDownStoreCount         = "down-store-count"
+	AlertOfflineStoreCount      = "offline-store-count"
+	// AlertOfflineStoreMaxSeconds is how long the store which has been
+	// offline for the longest time is offline, so the stuck offline stores
+	// can be alerted.
+	AlertOfflineStoreMaxSeconds = "offline-store-max-seconds"
+)
+
+var alertRegionStats = map[string]statistics.RegionStatisticType{
+	AlertDownPeerRegionCount:    statistics.DownPeer,
+	AlertPendingPeerRegionCount: statistics.PendingPeer,
+	AlertMissPeerRegionCount:    statistics.MissPeer,
+	AlertOfflinePeerRegionCount: statistics.OfflinePeer,
+}
+
+var alertStoreStats = map[string]struct{}{
+	AlertDisconnectedStoreCount: {},
+	AlertDownStoreCount:         {},
+	AlertOfflineStoreCount:      {},
+	AlertOfflineStoreMaxSeconds: {},
+}
+
+// The comparisons of the alert rules.
+var alertComparisons = map[string]func(value, threshold float64) bool{
+	">":  func(value, threshold float64) bool { return value > threshold },
+	">=": func(value, threshold float64) bool { return value >= threshold },
+	"<":  func(value, threshold float64) bool { return value < threshold },
+	"<=": func(value, threshold float64) bool { return value <= threshold },
+}
+
+// The states of the alerts.
+const (
+	AlertInactive = "inactive"
+	AlertPending  = "pending"
+	AlertFiring   = "firing"
+	AlertResolved = "resolved"
+)
+
+// AlertRule is a condition over the stats of PD. The alert fires once the
+// condition holds for the duration, and it is resolved once the condition
+// does not hold.
+type AlertRule struct {
+	Name      string  `json:"name"`
+	Stat      string  `json:"stat"`
+	Op        string  `json:"op"`
+	Threshold float64 `json:"threshold"`
+	// For is how long the condition holds before the alert fires.
+	For typeutil.Duration `json:"for"`
+	// Webhook is the URL the notifications are posted to. The notifications
+	// are always published as the cluster events.
+	Webhook string `json:"webhook,omitempty"`
+}
+
+// Validate checks if the alert rule is valid.
+func (r *AlertRule) Validate() error {
+	if r.Name == "" || strings.Contains(r.Name, "/") {
+		return errs.ErrInvalidAlertRule.FastGenByArgs(fmt.Sprintf("invalid name %q", r.Name))
+	}
+	if _, ok := alertRegionStats[r.Stat]; !ok {
+		if _, ok := alertStoreStats[r.Stat]; !ok {
+			return errs.ErrInvalidAlertRule.FastGenByArgs(fmt.Sprintf("unknown stat %q", r.Stat))
+		}
+	}
+	if _, ok := alertComparisons[r.Op]; !ok {
+		return errs.ErrInvalidAlertRule.FastGenByArgs(fmt.Sprintf("unknown op %q", r.Op))
+	}
+	if r.For.Duration < 0 {
+		return errs.ErrInvalidAlertRule.FastGenByArgs("the duration is negative")
+	}
+	if r.Webhook != "" {
+		u, err := url.Parse(r.Webhook)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			return errs.ErrInvalidAlertRule.FastGenByArgs(fmt.Sprintf("invalid webhook %q", r.Webhook))
+		}
+	}
+	return nil
+}
+
+// AlertStatus is the state of the alert of a rule.
+type AlertStatus struct {
+	Rule  *AlertRule `json:"rule"`
+	State string     `json:"state"`
+	Value float64    `json:"value"`
+	// Since is when the condition starts to hold. It is zero if the alert is
+	// inactive.
+	Since time.Time `json:"since"`
+}
+
+// AlertNotification is sent when an alert fires or is resolved.
+type AlertNotification struct {
+	Rule      string    `json:"rule"`
+	Stat      string    `json:"stat"`
+	Op        string    `json:"op"`
+	Threshold float64   `json:"threshold"`
+	State     string    `json:"state"`
+	Value     float64   `json:"value"`
+	Time      time.Time `json:"time"`
+}
+
+func (n *AlertNotification) String() string {
+	return fmt.Sprintf("alert %s is %s, %s is %v which should not be %s %v", n.Rule, n.State, n.Stat, n.Value, n.Op, n.Threshold)
+}
+
+type alertState struct {
+	value  float64
+	since  time.Time
+	firing bool
+}
+
+// alertEngine evaluates the user-defined alert rules over the stats of PD in
+// the metrics collection job, so the clusters without the Prometheus alerting
+// are still alerted. The rules are persisted, but the states of the alerts
+// are not, which means a firing alert fires again after the leader changes.
+type alertEngine struct {
+	sync.RWMutex
+	cluster *RaftCluster
+	rules   map[string]*AlertRule
+	states  map[string]*alertState
+	// notify sends the notification to the webhook. It is replaced in tests.
+	notify func(webhook string, n *AlertNotification)
+}
+
+func newAlertEngine(cluster *RaftCluster) *alertEngine {
+	return &alertEngine{
+		cluster: cluster,
+		rules:   make(map[string]*AlertRule),
+		states:  make(map[string]*alertState),
+		notify:  sendAlertWebhook,
+	}
+}
+
+func (e *alertEngine) load() error {
+	e.Lock()
+	defer e.Unlock()
+	return e.cluster.storage.LoadRangeByPrefix(alertRulesPath+"/", func(k, v string) {
+		rule := &AlertRule{}
+		if err := json.Unmarshal([]byte(v), rule); err != nil {
+			log.Error("failed to unmarshal alert rule", zap.String("key", k), errs.ZapError(errs.ErrJSONUnmarshal.Wrap(err).FastGenWithCause()))
+			return
+		}
+		e.rules[rule.Name] = rule
+	})
+}
+
+func (e *alertEngine) setRule(rule *AlertRule) error {
+	if err := rule.Validate(); err != nil {
+		return err
+	}
+	e.Lock()
+	defer e.Unlock()
+	if _, ok := e.rules[rule.Name]; !ok && len(e.rules) >= maxAlertRules {
+		return errs.ErrInvalidAlertRule.FastGenByArgs(fmt.Sprintf("there are %d rules already", maxAlertRules))
+	}
+	if err := e.cluster.storage.SaveJSON(alertRulesPath, rule.Name, rule); err != nil {
+		return err
+	}
+	r := *rule
+	e.rules[rule.Name] = &r
+	// The alert is evaluated again with the new condition.
+	delete(e.states, rule.Name)
+	alertFiringGauge.DeleteLabelValues(rule.Name)
+	log.Info("alert rule is set", zap.String("name", rule.Name), zap.String("stat", rule.Stat),
+		zap.String("op", rule.Op), zap.Float64("threshold", rule.Threshold), zap.Duration("for", rule.For.Duration))
+	return nil
+}
+
+func (e *alertEngine) deleteRule(name string) error {
+	e.Lock()
+	defer e.Unlock()
+	if _, ok := e.rules[name]; !ok {
+		return errs.ErrAlertRuleNotFound.FastGenByArgs(name)
+	}
+	if err := e.cluster.storage.Remove(path.Join(alertRulesPath, name)); err != nil {
+		return err
+	}
+	delete(e.rules, name)
+	delete(e.states, name)
+	alertFiringGauge.DeleteLabelValues(name)
+	log.Info("alert rule is deleted", zap.String("name", name))
+	return nil
+}
+
+// collectStats returns the values of the stats used by the rules.
+func (e *alertEngine) collectStats(rules []*AlertRule, now time.Time) map[string]float64 {
+	c := e.cluster
+	stats := make(map[string]float64)
+	needStores := false
+	for _, rule := range rules {
+		if _, ok := stats[rule.Stat]; ok {
+			continue
+		}
+		if typ, ok := alertRegionStats[rule.Stat]; ok {
+			stats[rule.Stat] = float64(len(c.GetRegionStatsByType(typ)))
+		} else {
+			needStores = true
+		}
+	}
+	if !needStores {
+		return stats
+	}
+	var disconnected, down, offline, offlineMax float64
+	maxDownTime := c.opt.GetMaxStoreDownTime()
+	for _, store := range c.GetStores() {
+		if store.IsTombstone() {
+			continue
+		}
+		if store.IsOffline() {
+			offline++
+			if timeline := c.storeTimeline.get(store.GetID()); timeline != nil {
+				if n := len(timeline.Events); n > 0 && timeline.Events[n-1].State == StoreTimelineOffline {
+					if d := now.Sub(timeline.Events[n-1].Time).Seconds(); d > offlineMax {
+						offlineMax = d
+					}
+				}
+			}
+		}
+		if store.GetMeta().GetLastHeartbeat() == 0 {
+			continue
+		}
+		if store.DownTime() >= maxDownTime {
+			down++
+		} else if store.IsDisconnected() {
+			disconnected++
+		}
+	}
+	stats[AlertDisconnectedStoreCount] = disconnected
+	stats[AlertDownStoreCount] = down
+	stats[AlertOfflineStoreCount] = offline
+	stats[AlertOfflineStoreMaxSeconds] = offlineMax
+	return stats
+}
+
+// evaluate evaluates the rules, and notifies the alerts which fire or are
+// resolved.
+func (e *alertEngine) evaluate(now time.Time) {
+	e.RLock()
+	rules := make([]*AlertRule, 0, len(e.rules))
+	for _, rule := range e.rules {
+		rules = append(rules, rule)
+	}
+	e.RUnlock()
+	if len(rules) == 0 {
+		return
+	}
+	stats := e.collectStats(rules, now)
+
+	var notifications []*AlertNotification
+	var webhooks []string
+	e.Lock()
+	for _, rule := range rules {
+		if e.rules[rule.Name] != rule {
+			// The rule is changed during the evaluation.
+			continue
+		}
+		value := stats[rule.Stat]
+		state, ok := e.states[rule.Name]
+		if !ok {
+			state = &alertState{}
+			e.states[rule.Name] = state
+		}
+		state.value = value
+		n := &AlertNotification{Rule: rule.Name, Stat: rule.Stat, Op: rule.Op, Threshold: rule.Threshold, Value: value, Time: now}
+		if alertComparisons[rule.Op](value, rule.Threshold) {
+			if state.since.IsZero() {
+				state.since = now
+			}
+			if state.firing || now.Sub(state.since) < rule.For.Duration {
+				continue
+			}
+			state.firing = true
+			n.State = AlertFiring
+			alertFiringGauge.WithLabelValues(rule.Name).Set(1)
+		} else {
+			state.since = time.Time{}
+			if !state.firing {
+				continue
+			}
+			state.firing = false
+			n.State = AlertResolved
+			alertFiringGauge.WithLabelValues(rule.Name).Set(0)
+		}
+		notifications = append(notifications, n)
+		webhooks = append(webhooks, rule.Webhook)
+	}
+	e.Unlock()
+
+	for i, n := range notifications {
+		log.Warn("alert state is changed", zap.String("rule", n.Rule), zap.String("state", n.State), zap.Float64("value", n.Value))
+		typ := events.AlertFired
+		if n.State == AlertResolved {
+			typ = events.AlertResolved
+		}
+		e.cluster.eventBus.Publish(typ, 0, n.String())
+		if webhooks[i] != "" {
+			e.notify(webhooks[i], n)
+		}
+	}
+}
+
+func (e *alertEngine) getStatuses() []*AlertStatus {
+	e.RLock()
+	defer e.RUnlock()
+	statuses := make([]*AlertStatus, 0, len(e.rules))
+	for name, rule := range e.rules {
+		r := *rule
+		status := &AlertStatus{Rule: &r, State: AlertInactive}
+		if state, ok := e.states[name]; ok {
+			status.Value = state.value
+			status.Since = state.since
+			switch {
+			case state.firing:
+				status.State = AlertFiring
+			case !state.since.IsZero():
+				status.State = AlertPending
+			}
+		}
+		statuses = append(statuses, status)
+	}
+	sort.Slice(statuses, func(i, j int) bool { return statuses[i].Rule.Name < statuses[j].Rule.Name })
+	return statuses
+}
+
+func (e *alertEngine) resetMetrics() {
+	alertFiringGauge.Reset()
+}
+
+var alertWebhookClient = &http.Client{Timeout: alertWebhookTimeout}
+
+// sendAlertWebhook posts the notification to the webhook in the background,
+// so a slow webhook does not delay the metrics collection.
+func sendAlertWebhook(webhook string, n *AlertNotification) {
+	data, err := json.Marshal(n)
+	if err != nil {
+		log.Error("failed to marshal alert notification", zap.String("rule", n.Rule), errs.ZapError(errs.ErrJSONMarshal.Wrap(err).FastGenWithCause()))
+		return
+	}
+	go func() {
+		defer logutil.LogPanic()
+		resp, err := alertWebhookClient.Post(webhook, "application/json", bytes.NewReader(data))
+		if err != nil {
+			alertWebhookCounter.WithLabelValues("failed").Inc()
+			log.Warn("failed to send alert notification", zap.String("rule", n.Rule), zap.String("webhook", webhook), errs.ZapError(err))
+			return
+		}
+		resp.Body.Close()
+		if resp.StatusCode/100 != 2 {
+			alertWebhookCounter.WithLabelValues("failed").Inc()
+			log.Warn("alert notification is rejected", zap.String("rule", n.Rule), zap.String("webhook", webhook), zap.Int("status", resp.StatusCode))
+			return
+		}
+		alertWebhookCounter.WithLabelValues("sent").Inc()
+	}()
+}
+
+// SetAlertRule creates or updates an alert rule.
+func (c *RaftCluster) SetAlertRule(rule *AlertRule) error {
+	return c.alerts.setRule(rule)
+}
+
+// DeleteAlertRule deletes an alert rule.
+func (c *RaftCluster) DeleteAlertRule(name string) error {
+	return c.alerts.deleteRule(name)
+}
+
+// GetAlerts returns the states of the alerts of all rules ordered by the
+// names.
+func (c *RaftCluster) GetAlerts() []*AlertStatus {
+	return c.alerts.getStatuses()
+}
@@ -0,0 +1,254 @@
+// Copyright 2021 TiKV Project Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cluster
+
+import (
+	"bytes"
+	"math/big"
+	"time"
+
+	"github.com/pingcap/kvproto/pkg/pdpb"
+	"github.com/pingcap/log"
+	"github.com/tikv/pd/pkg/errs"
+	"github.com/tikv/pd/pkg/logutil"
+	"github.com/tikv/pd/server/config"
+	"github.com/tikv/pd/server/core"
+	"github.com/tikv/pd/server/schedule"
+	"go.uber.org/zap"
+)
+
+const (
+	appendHotspotCheckInterval = 10 * time.Second
+	appendHotspotScatterGroup  = "append-hotspot"
+)
+
+// appendHotspotFront is the write front of a key prefix, which is the hot
+// region with the largest start key in the prefix.
+type appendHotspotFront struct {
+	startKey []byte
+	// step is the span of the keys which the front advances by each time,
+	// and it is nil until the front has advanced.
+	step *big.Int
+	// pending is the start keys of the ranges pre-split ahead of the front,
+	// which are scattered after they are split.
+	pending map[string]struct{}
+}
+
+// appendHotspotController pre-splits the write fronts of the key prefixes
+// written in the append-only way, like the keys of an auto-increment primary
+// key, and scatters the new ranges ahead of the writes. Otherwise the writes
+// are always served by the stores of the last region, until it is split and
+// balanced afterwards.
+type appendHotspotController struct {
+	cluster      *RaftCluster
+	opController *schedule.OperatorController
+	scatterer    *schedule.RegionScatterer
+	fronts       map[string]*appendHotspotFront
+}
+
+func newAppendHotspotController(cluster *RaftCluster, opController *schedule.OperatorController, scatterer *schedule.RegionScatterer) *appendHotspotController {
+	return &appendHotspotController{
+		cluster:      cluster,
+		opController: opController,
+		scatterer:    scatterer,
+		fronts:       make(map[string]*appendHotspotFront),
+	}
+}
+
+// runAppendHotspotController checks the append hotspot rules periodically.
+func (c *coordinator) runAppendHotspotController() {
+	defer logutil.LogPanic()
+	defer c.wg.Done()
+	ticker := time.NewTicker(appendHotspotCheckInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-c.ctx.Done():
+			log.Info("append hotspot controller has been stopped")
+			return
+		case <-ticker.C:
+			c.appendHotspots.check()
+		}
+	}
+}
+
+func (c *appendHotspotController) check() {
+	rules := c.cluster.GetOpts().GetAppendHotspotRules()
+	if len(rules) == 0 {
+		c.fronts = make(map[string]*appendHotspotFront)
+		return
+	}
+	hotRegions := make(map[uint64]struct{})
+	for _, stats := range c.cluster.RegionWriteStats() {
+		for _, stat := range stats {
+			if stat.IsLeader() {
+				hotRegions[stat.RegionID] = struct{}{}
+			}
+		}
+	}
+	fronts := make(map[string]*appendHotspotFront, len(rules))
+	for _, rule := range rules {
+		front := c.fronts[rule.KeyPrefix]
+		if front == nil {
+			front = &appendHotspotFront{pending: make(map[string]struct{})}
+		}
+		c.checkRule(rule, front, hotRegions)
+		fronts[rule.KeyPrefix] = front
+	}
+	c.fronts = fronts
+}
+
+// checkRule finds the write front of the rule, scatters the ranges split
+// ahead of it, and pre-splits the last region if there are not enough ranges
+// ahead of it.
+func (c *appendHotspotController) checkRule(rule config.AppendHotspotRule, front *appendHotspotFront, hotRegions map[uint64]struct{}) {
+	startKey := rule.GetKeyPrefix()
+	endKey := prefixEndKey(startKey)
+	var frontRegion *core.RegionInfo
+	for id := range hotRegions {
+		region := c.cluster.GetRegion(id)
+		if region == nil || !regionOverlapsRange(region, startKey, endKey) {
+			continue
+		}
+		if frontRegion == nil || bytes.Compare(region.GetStartKey(), frontRegion.GetStartKey()) > 0 {
+			frontRegion = region
+		}
+	}
+	if frontRegion == nil {
+		return
+	}
+	if front.startKey != nil && bytes.Compare(frontRegion.GetStartKey(), front.startKey) > 0 {
+		front.step = keySpan(front.startKey, frontRegion.GetStartKey())
+		appendHotspotCounter.WithLabelValues("advance").Inc()
+	}
+	front.startKey = frontRegion.GetStartKey()
+
+	count := rule.GetPreSplitCount()
+	var ahead []*core.RegionInfo
+	if len(frontRegion.GetEndKey()) > 0 && (len(endKey) == 0 || bytes.Compare(frontRegion.GetEndKey(), endKey) < 0) {
+		ahead = c.cluster.ScanRegions(frontRegion.GetEndKey(), endKey, count)
+	}
+	for _, region := range ahead {
+		if _, ok := front.pending[string(region.GetStartKey())]; ok {
+			c.scatter(region)
+			delete(front.pending, string(region.GetStartKey()))
+		}
+	}
+	if len(ahead) >= count {
+		return
+	}
+
+	last := frontRegion
+	if len(ahead) > 0 {
+		last = ahead[len(ahead)-1]
+	}
+	if front.step == nil {
+		appendHotspotCounter.WithLabelValues("no-step").Inc()
+		return
+	}
+	if c.opController.GetOperator(last.GetID()) != nil {
+		return
+	}
+	keys := preSplitKeys(last, endKey, front.step, count-len(ahead))
+	if len(keys) == 0 {
+		return
+	}
+	op, err := schedule.CreateSplitRegionOperator("append-hotspot-split", c.cluster, last, 0, pdpb.CheckPolicy_USEKEY, keys)
+	if err != nil {
+		log.Debug("fail to create append hotspot split operator", errs.ZapError(err))
+		return
+	}
+	if !c.opController.AddOperator(op) {
+		return
+	}
+	for _, key := range keys {
+		front.pending[string(key)] = struct{}{}
+	}
+	appendHotspotCounter.WithLabelValues("split").Inc()
+	log.Info("pre-split the append hotspot", zap.String("key-prefix", rule.KeyPrefix), zap.Uint64("region-id", last.GetID()), zap.Int("count", len(keys)))
+}
+
+func (c *appendHotspotController) scatter(region *core.RegionInfo) {
+	op, err := c.scatterer.Scatter(region, appendHotspotScatterGroup)
+	if err != nil {
+		log.Debug("fail to scatter the append hotspot range", zap.Uint64("region-id", region.GetID()), errs.ZapError(err))
+		return
+	}
+	if op != nil && c.opController.AddOperator(op) {
+		appendHotspotCounter.WithLabelValues("scatter").Inc()
+	}
+}
+
+// prefixEndKey returns the smallest key larger than all keys with the prefix,
+// or nil if there is no such key.
+func prefixEndKey(prefix []byte) []byte {
+	end := append([]byte(nil), prefix...)
+	for i := len(end) - 1; i >= 0; i-- {
+		if end[i] < 0xff {
+			end[i]++
+			return end[:i+1]
+		}
+	}
+	return nil
+}
+
+func regionOverlapsRange(region *core.RegionInfo, startKey, endKey []byte) bool {
+	return (len(endKey) == 0 || bytes.Compare(region.GetStartKey(), endKey) < 0) &&
+		(len(region.GetEndKey()) == 0 || bytes.Compare(region.GetEndKey(), startKey) > 0)
+}
+
+// keyToInt converts the key padded with zeros to the length to a number.
+func keyToInt(key []byte, length int) *big.Int {
+	padded := make([]byte, length)
+	copy(padded, key)
+	return new(big.Int).SetBytes(padded)
+}
+
+// keySpan returns the span from the start key to the end key as numbers.
+func keySpan(startKey, endKey []byte) *big.Int {
+	length := len(startKey)
+	if len(endKey) > length {
+		length = len(endKey)
+	}
+	return new(big.Int).Sub(keyToInt(endKey, length), keyToInt(startKey, length))
+}
+
+// preSplitKeys returns at most count keys in the region and before the end
+// key, which are the steps after the start key of the region.
+func preSplitKeys(region *core.RegionInfo, endKey []byte, step *big.Int, count int) [][]byte {
+	if step.Sign() <= 0 {
+		return nil
+	}
+	if len(region.GetEndKey()) > 0 && (len(endKey) == 0 || bytes.Compare(region.GetEndKey(), endKey) < 0) {
+		endKey = region.GetEndKey()
+	}
+	length := len(region.GetStartKey())
+	if stepLength := (step.BitLen() + 7) / 8; stepLength > length {
+		length = stepLength
+	}
+	next := keyToInt(region.GetStartKey(), length)
+	var keys [][]byte
+	for i := 0; i < count; i++ {
+		next = new(big.Int).Add(next, step)
+		if (next.BitLen()+7)/8 > length {
+			break
+		}
+		key := next.FillBytes(make([]byte, length))
+		if len(endKey) > 0 && bytes.Compare(key, endKey) >= 0 {
+			break
+		}
+		keys = append(keys, key)
+	}
+	return keys
+}
@@ -0,0 +1,79 @@
+// Copyright 2021 TiKV Project Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cluster
+
+import (
+	"time"
+	"unsafe"
+
+	"github.com/pingcap/log"
+	"github.com/tikv/pd/server/statistics"
+	"go.uber.org/zap"
+)
+
+var hotPeerStatSize = int64(unsafe.Sizeof(statistics.HotPeerStat{}))
+
+// MemoryUsage is the estimated memory used by the objects cached by the
+// cluster in bytes. The estimations tell which part of the cache grows,
+// rather than the exact memory usage.
+type MemoryUsage struct {
+	Regions       int64 `json:"regions"`
+	Stores        int64 `json:"stores"`
+	HotCache      int64 `json:"hot_cache"`
+	RegionHistory int64 `json:"region_history"`
+	RegionFits    int64 `json:"region_fits"`
+	Total         int64 `json:"total"`
+}
+
+// GetMemoryUsage returns the estimated memory used by the cached objects.
+func (c *RaftCluster) GetMemoryUsage() *MemoryUsage {
+	usage := &MemoryUsage{}
+	usage.Regions, usage.Stores = c.core.GetMemoryUsage()
+	for _, kind := range []statistics.FlowKind{statistics.WriteFlow, statistics.ReadFlow} {
+		for _, peers := range c.hotStat.RegionStats(kind, 0) {
+			usage.HotCache += int64(len(peers)) * hotPeerStatSize
+		}
+	}
+	usage.RegionHistory = c.regionHistory.memoryUsage()
+	if c.ruleManager != nil {
+		usage.RegionFits = c.ruleManager.GetRegionFitsMemoryUsage()
+	}
+	usage.Total = usage.Regions + usage.Stores + usage.HotCache + usage.RegionHistory + usage.RegionFits
+	return usage
+}
+
+// EvictColdCaches drops the auxiliary cached objects which are not used for
+// the ttl, which are the lineage of the regions and the cached fits of the
+// regions. They are rebuilt when needed, so it is safe to evict them under
+// the memory pressure.
+func (c *RaftCluster) EvictColdCaches(ttl time.Duration) {
+	before := time.Now().Add(-ttl)
+	histories := c.regionHistory.evict(before)
+	var fits int
+	if c.ruleManager != nil {
+		fits = c.ruleManager.EvictRegionFits(before)
+	}
+	cacheEvictedCounter.WithLabelValues("region-history").Add(float64(histories))
+	cacheEvictedCounter.WithLabelValues("region-fits").Add(float64(fits))
+	log.Info("evicted the cold caches", zap.Duration("ttl", ttl), zap.Int("region-histories", histories), zap.Int("region-fits", fits))
+}
+
+func (c *RaftCluster) collectCacheMemoryMetrics() {
+	usage := c.GetMemoryUsage()
+	cacheMemoryGauge.WithLabelValues("regions").Set(float64(usage.Regions))
+	cacheMemoryGauge.WithLabelValues("stores").Set(float64(usage.Stores))
+	cacheMemoryGauge.WithLabelValues("hot-cache").Set(float64(usage.HotCache))
+	cacheMemoryGauge.WithLabelValues("region-history").Set(float64(usage.RegionHistory))
+	cacheMemoryGauge.WithLabelValues("region-fits").Set(float64(usage.RegionFits))
+}
@@ -0,0 +1,49 @@
+// Copyright 2021 TiKV Project Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cluster
+
+import (
+	"github.com/tikv/pd/server/core"
+	"github.com/tikv/pd/server/statistics"
+)
+
+// getCapacityLevel returns the level of the predicted days to full of the
+// store with the configured thresholds.
+func (c *RaftCluster) getCapacityLevel(store *core.StoreInfo) string {
+	switch {
+	case store.IsPredictedFull(c.opt.GetCapacityForecastCriticalDays()):
+		return statistics.CapacityLevelCritical
+	case store.IsPredictedFull(c.opt.GetCapacityForecastWarningDays()):
+		return statistics.CapacityLevelWarning
+	default:
+		return statistics.CapacityLevelNormal
+	}
+}
+
+// GetCapacityForecasts returns the capacity forecasts of the stores which are
+// not tombstone, with the levels of the configured thresholds.
+func (c *RaftCluster) GetCapacityForecasts() []*statistics.CapacityForecast {
+	warningDays, criticalDays := c.opt.GetCapacityForecastWarningDays(), c.opt.GetCapacityForecastCriticalDays()
+	forecasts := c.capacityForecaster.GetForecasts()
+	res := make([]*statistics.CapacityForecast, 0, len(forecasts))
+	for _, forecast := range forecasts {
+		if store := c.GetStore(forecast.StoreID); store == nil || store.IsTombstone() {
+			continue
+		}
+		f := *forecast
+		f.Level = f.GetLevel(warningDays, criticalDays)
+		res = append(res, &f)
+	}
+	return res
+}
@@ -15,9 +15,14 @@ package cluster
 
 import (
 	"context"
+	"crypto/rand"
+	"encoding/hex"
 	"fmt"
+	"io"
 	"net/http"
+	"sort"
 	"strconv"
+	"strings"
 	"sync"
 	"time"
 
@@ -35,24 +40,41 @@ import (
 	"github.com/tikv/pd/pkg/etcdutil"
 	"github.com/tikv/pd/pkg/keyutil"
 	"github.com/tikv/pd/pkg/logutil"
+	"github.com/tikv/pd/pkg/netutil"
 	"github.com/tikv/pd/pkg/typeutil"
 	"github.com/tikv/pd/server/config"
 	"github.com/tikv/pd/server/core"
 	"github.com/tikv/pd/server/core/storelimit"
+	"github.com/tikv/pd/server/events"
 	"github.com/tikv/pd/server/id"
+	"github.com/tikv/pd/server/job"
 	syncer "github.com/tikv/pd/server/region_syncer"
 	"github.com/tikv/pd/server/replication"
 	"github.com/tikv/pd/server/schedule"
+	"github.com/tikv/pd/server/schedule/affinity"
 	"github.com/tikv/pd/server/schedule/checker"
 	"github.com/tikv/pd/server/schedule/hbstream"
+	"github.com/tikv/pd/server/schedule/keyrange"
+	"github.com/tikv/pd/server/schedule/labeler"
+	"github.com/tikv/pd/server/schedule/operator"
 	"github.com/tikv/pd/server/schedule/placement"
+	"github.com/tikv/pd/server/schedule/tiering"
+	"github.com/tikv/pd/server/schedulers"
 	"github.com/tikv/pd/server/statistics"
+	"github.com/tikv/pd/server/storeconfig"
 	"github.com/tikv/pd/server/versioninfo"
 	"go.etcd.io/etcd/clientv3"
 	"go.uber.org/zap"
 )
 
-var backgroundJobInterval = 10 * time.Second
+var (
+	backgroundJobInterval   = 10 * time.Second
+	storeConfigSyncInterval = time.Minute
+	// metricsCollectionInterval is the base interval of collecting the
+	// metrics, which backs off adaptively.
+	metricsCollectionInterval = 10 * time.Second
+	healthStatusInterval      = 10 * time.Second
+)
 
 const (
 	clientTimeout              = 3 * time.Second
@@ -61,6 +83,8 @@ const (
 	// since the once the store is add or remove, we shouldn't return an error even if the store limit is failed to persist.
 	persistLimitRetryTimes = 5
 	persistLimitWaitTime   = 100 * time.Millisecond
+	// storeArchiveTTL is how long the archive of a removed tombstone store is kept.
+	storeArchiveTTL = 7 * 24 * time.Hour
 )
 
 // Server is the interface for cluster.
@@ -109,20 +133,61 @@ type RaftCluster struct {
 	coordinator      *coordinator
 	suspectRegions   *cache.TTLUint64 // suspectRegions are regions that may need fix
 	suspectKeyRanges *cache.TTLString // suspect key-range regions that may need fix
+	// suspectRegionPersister persists the high priority suspect regions.
+	suspectRegionPersister *suspectRegionPersister
 
 	wg           sync.WaitGroup
 	quit         chan struct{}
 	regionSyncer *syncer.RegionSyncer
 
-	ruleManager *placement.RuleManager
-	etcdClient  *clientv3.Client
-	httpClient  *http.Client
+	ruleManager     *placement.RuleManager
+	tieringManager  *tiering.Manager
+	regionLabeler   *labeler.RegionLabeler
+	keyRangeManager *keyrange.Manager
+	affinityManager *affinity.Manager
+	jobManager      *job.Manager
+	topologyPlanner *topologyPlanner
+	eventBus        *events.Bus
+	etcdClient      *clientv3.Client
+	httpClient      *http.Client
+
+	// regionConsistencyChecker checks the overlaps and the gaps of the regions.
+	regionConsistencyChecker *regionConsistencyChecker
+	// conformanceChecker generates the placement conformance reports.
+	conformanceChecker *conformanceChecker
+	// regionWatchlist tracks the regions in trouble for too long.
+	regionWatchlist *regionWatchlist
+	// heartbeatProfiler aggregates the costs of the region heartbeats.
+	heartbeatProfiler *heartbeatProfiler
+	// stalePeers tracks the removed peers until the stores acknowledge them.
+	stalePeers *stalePeerTracker
+	// storeTimeline records the state transitions of the stores.
+	storeTimeline *storeTimelineTracker
+	// alerts evaluates the user-defined alert rules.
+	alerts *alertEngine
+	// upgradeWindow is the upgrade in progress, nil if there is none.
+	upgradeWindow *UpgradeWindow
+	// regionHistory is the index of the lineage of the regions.
+	regionHistory *regionHistory
+	// regionLoader tracks loading the regions when the cluster starts.
+	regionLoader *regionLoader
+	// storageBreaker degrades the persists when the storage is slow.
+	storageBreaker *storageBreaker
+	// regionDeleter deletes the overlapped regions from storage.
+	regionDeleter *regionDeleter
+	// capacityForecaster predicts when the stores are full.
+	capacityForecaster *statistics.CapacityForecaster
 
 	replicationMode *replication.ModeManager
 	traceRegionFlow bool
 
 	// It's used to manage components.
 	componentManager *component.Manager
+
+	// It's used to observe the configs of stores of all engines.
+	storeConfigManager *storeconfig.Manager
+	// It's used to fetch the pending compaction bytes of the stores.
+	compactionFetcher compactionPressureFetcher
 }
 
 // Status saves some state information.
@@ -130,6 +195,17 @@ type Status struct {
 	RaftBootstrapTime time.Time `json:"raft_bootstrap_time,omitempty"`
 	IsInitialized     bool      `json:"is_initialized"`
 	ReplicationStatus string    `json:"replication_status"`
+	// HaltStatus is nil if the scheduling is not halted.
+	HaltStatus *HaltStatus `json:"halt_status,omitempty"`
+}
+
+// HaltStatus is the status of the halted scheduling.
+type HaltStatus struct {
+	Mode string `json:"mode"`
+	// OutstandingOperators is the number of the waiting and running
+	// operators, and the in-flight operators are drained once it is 0.
+	OutstandingOperators int  `json:"outstanding_operators"`
+	Drained              bool `json:"drained"`
 }
 
 // NewRaftCluster create a new cluster.
@@ -159,13 +235,32 @@ func (c *RaftCluster) LoadClusterStatus() (*Status, error) {
 	if c.replicationMode != nil {
 		replicationStatus = c.replicationMode.GetReplicationStatus().String()
 	}
+	var haltStatus *HaltStatus
+	if c.running {
+		haltStatus = c.getHaltStatusLocked()
+	}
 	return &Status{
 		RaftBootstrapTime: bootstrapTime,
 		IsInitialized:     isInitialized,
 		ReplicationStatus: replicationStatus,
+		HaltStatus:        haltStatus,
 	}, nil
 }
 
+func (c *RaftCluster) getHaltStatusLocked() *HaltStatus {
+	mode := c.opt.GetHaltScheduling()
+	if mode == "" {
+		return nil
+	}
+	oc := c.coordinator.opController
+	outstanding := len(oc.GetOperators()) + len(oc.GetWaitingOperators())
+	return &HaltStatus{
+		Mode:                 mode,
+		OutstandingOperators: outstanding,
+		Drained:              outstanding == 0,
+	}
+}
+
 func (c *RaftCluster) isInitialized() bool {
 	if c.core.GetRegionCount() > 1 {
 		return true
@@ -207,10 +302,28 @@ func (c *RaftCluster) InitCluster(id id.Allocator, opt *config.PersistOptions, s
 	c.labelLevelStats = statistics.NewLabelStatistics()
 	c.hotStat = statistics.NewHotStat(c.ctx, c.quit)
 	c.prepareChecker = newPrepareChecker()
+	c.regionLoader = newRegionLoader()
 	c.changedRegions = make(chan *core.RegionInfo, defaultChangedRegionsLimit)
 	c.suspectRegions = cache.NewIDTTL(c.ctx, time.Minute, 3*time.Minute)
+	c.suspectRegionPersister = newSuspectRegionPersister(c)
 	c.suspectKeyRanges = cache.NewStringTTL(c.ctx, time.Minute, 3*time.Minute)
 	c.traceRegionFlow = opt.GetPDServerConfig().TraceRegionFlow
+	c.topologyPlanner = newTopologyPlanner(c)
+	c.regionConsistencyChecker = newRegionConsistencyChecker(c)
+	c.conformanceChecker = newConformanceChecker(c)
+	c.regionWatchlist = newRegionWatchlist(c)
+	c.heartbeatProfiler = newHeartbeatProfiler()
+	c.stalePeers = newStalePeerTracker(c)
+	c.storeTimeline = newStoreTimelineTracker(c)
+	c.alerts = newAlertEngine(c)
+	c.upgradeWindow = nil
+	c.regionHistory = newRegionHistory()
+	c.storageBreaker = newStorageBreaker(func() time.Duration {
+		return opt.GetPDServerConfig().StorageSlowThreshold.Duration
+	})
+	c.regionDeleter = newRegionDeleter()
+	c.capacityForecaster = statistics.NewCapacityForecaster()
+	c.eventBus = events.NewBus(storage, events.DefaultCapacity)
 }
 
 // Start starts a cluster.
@@ -231,8 +344,23 @@ func (c *RaftCluster) Start(s Server) error {
 	if cluster == nil {
 		return nil
 	}
+	if err = c.checkClusterData(); err != nil {
+		if !s.GetConfig().AllowClusterMismatch {
+			return err
+		}
+		log.Warn("the metadata mismatch is allowed", errs.ZapError(err))
+	}
+	if err = c.storage.SaveRegionStorageClusterID(c.clusterID); err != nil {
+		return err
+	}
+
+	if err = c.eventBus.Load(); err != nil {
+		return err
+	}
+	c.eventBus.Publish(events.LeaderChanged, 0, fmt.Sprintf("%s becomes the leader", s.GetConfig().Name))
 
 	c.ruleManager = placement.NewRuleManager(c.storage, c)
+	c.ruleManager.SetChangeObserver(c.onRulesChanged)
 	if c.opt.IsPlacementRulesEnabled() {
 		err = c.ruleManager.Initialize(c.opt.GetMaxReplicas(), c.opt.GetLocationLabels())
 		if err != nil {
@@ -240,6 +368,65 @@ func (c *RaftCluster) Start(s Server) error {
 		}
 	}
 
+	c.tieringManager = tiering.NewManager(c.storage, c.ruleManager)
+	if err = c.tieringManager.Load(); err != nil {
+		return err
+	}
+
+	c.regionLabeler, err = labeler.NewRegionLabeler(c.storage)
+	if err != nil {
+		return err
+	}
+
+	c.keyRangeManager, err = keyrange.NewManager(c.storage)
+	if err != nil {
+		return err
+	}
+
+	c.affinityManager, err = affinity.NewManager(c.storage)
+	if err != nil {
+		return err
+	}
+
+	c.jobManager = job.NewManager(c.storage, s.GetConfig().Name, c.AllocID)
+	c.registerJobRunners()
+	if err = c.jobManager.Load(); err != nil {
+		return err
+	}
+	c.checkSystemRanges()
+
+	if err = c.topologyPlanner.load(); err != nil {
+		return err
+	}
+
+	if err = c.conformanceChecker.load(); err != nil {
+		return err
+	}
+
+	if err = c.regionWatchlist.load(); err != nil {
+		return err
+	}
+
+	if err = c.stalePeers.load(); err != nil {
+		return err
+	}
+
+	if err = c.storeTimeline.load(); err != nil {
+		return err
+	}
+
+	if err = c.alerts.load(); err != nil {
+		return err
+	}
+
+	if err = c.loadUpgradeWindowLocked(); err != nil {
+		return err
+	}
+
+	if err = c.loadSuspectRegionsLocked(); err != nil {
+		return err
+	}
+
 	c.componentManager = component.NewManager(c.storage)
 	_, err = c.storage.LoadComponent(&c.componentManager)
 	if err != nil {
@@ -252,22 +439,66 @@ func (c *RaftCluster) Start(s Server) error {
 	}
 
 	c.coordinator = newCoordinator(c.ctx, cluster, s.GetHBStreams())
-	c.regionStats = statistics.NewRegionStatistics(c.opt, c.ruleManager)
+	c.loadCommandQueues()
+	c.regionStats = statistics.NewRegionStatistics(c.opt, c.ruleManager, c.core)
 	c.limiter = NewStoreLimiter(s.GetPersistOptions())
+	scheme := "http"
+	if s.GetConfig().Security.CertPath != "" {
+		scheme = "https"
+	}
+	c.storeConfigManager = storeconfig.NewManager(c.httpClient, scheme)
+	c.compactionFetcher = newHTTPCompactionPressureFetcher(c.httpClient, scheme)
 
-	c.wg.Add(4)
+	c.wg.Add(12)
 	go c.runCoordinator()
 	failpoint.Inject("highFrequencyClusterJobs", func() {
 		backgroundJobInterval = 100 * time.Microsecond
+		metricsCollectionInterval = 100 * time.Microsecond
+		healthStatusInterval = 100 * time.Microsecond
 	})
 	go c.runBackgroundJobs(backgroundJobInterval)
+	go c.runMetricsCollectionJob(metricsCollectionInterval)
+	go c.runHealthStatusJob(healthStatusInterval)
 	go c.syncRegions()
 	go c.runReplicationMode()
+	go c.runStoreConfigSync()
+	go c.runCompactionPressureSync()
+	go c.runEventBus()
+	go c.runAsyncRegionSaves()
+	go c.runRegionDeleter()
+	go c.runJobs()
+	if !c.regionLoader.isDone() {
+		c.wg.Add(1)
+		go c.loadRestRegions()
+	}
 	c.running = true
 
 	return nil
 }
 
+// loadCommandQueues loads the command queues persisted by the previous leader.
+// A loaded command is only redelivered if it is still the current step of a
+// running operator of the region, which is unchanged since the command is
+// sent.
+func (c *RaftCluster) loadCommandQueues() {
+	hbStreams := c.coordinator.hbStreams
+	if hbStreams == nil {
+		return
+	}
+	hbStreams.SetCommandValidator(func(msg *pdpb.RegionHeartbeatResponse) bool {
+		region := c.GetRegion(msg.GetRegionId())
+		return region != nil && c.coordinator.opController.CheckRecoveredCommand(region, msg)
+	})
+	count, err := hbStreams.LoadCommands(c.storage, time.Now())
+	if err != nil {
+		log.Warn("failed to load the command queues", errs.ZapError(err))
+		return
+	}
+	if count > 0 {
+		log.Info("command queues are reloaded", zap.Int("count", count))
+	}
+}
+
 // LoadClusterInfo loads cluster related info.
 func (c *RaftCluster) LoadClusterInfo() (*RaftCluster, error) {
 	c.meta = &metapb.Cluster{}
@@ -290,14 +521,26 @@ func (c *RaftCluster) LoadClusterInfo() (*RaftCluster, error) {
 
 	start = time.Now()
 
-	// used to load region from kv storage to cache storage.
-	if err := c.storage.LoadRegionsOnce(c.core.CheckAndPutRegion); err != nil {
-		return nil, err
+	if c.opt.GetPDServerConfig().EnablePrioritizedRegionLoading {
+		// The other regions are loaded in the background after the cluster
+		// starts.
+		if err := c.loadPriorityRegions(); err != nil {
+			return nil, err
+		}
+		log.Info("load priority regions",
+			zap.Int("count", c.core.GetRegionCount()),
+			zap.Duration("cost", time.Since(start)),
+		)
+	} else {
+		// used to load region from kv storage to cache storage.
+		if err := c.storage.LoadRegionsOnce(c.core.CheckAndPutRegion); err != nil {
+			return nil, err
+		}
+		log.Info("load regions",
+			zap.Int("count", c.core.GetRegionCount()),
+			zap.Duration("cost", time.Since(start)),
+		)
 	}
-	log.Info("load regions",
-		zap.Int("count", c.core.GetRegionCount()),
-		zap.Duration("cost", time.Since(start)),
-	)
 	for _, store := range c.GetStores() {
 		c.hotStat.GetOrCreateRollingStoreStats(store.GetID())
 	}
@@ -314,14 +557,25 @@ func (c *RaftCluster) runBackgroundJobs(interval time.Duration) {
 	for {
 		select {
 		case <-c.quit:
-			log.Info("metrics are reset")
-			c.resetMetrics()
 			log.Info("background jobs has been stopped")
 			return
 		case <-ticker.C:
 			c.checkStores()
-			c.collectMetrics()
+			c.storeTimeline.tick(time.Now())
 			c.coordinator.opController.PruneHistory()
+			c.tieringManager.Check(c, time.Now())
+			c.checkStoreRestartWindows()
+			c.checkSuspectStores()
+			c.regionConsistencyChecker.tick(time.Now())
+			c.conformanceChecker.tick(time.Now())
+			c.regionWatchlist.tick(time.Now())
+			c.regionHistory.gc(time.Now())
+			c.suspectRegionPersister.flush(time.Now())
+			if c.coordinator.hbStreams != nil {
+				c.coordinator.hbStreams.FlushCommands(c.storage)
+			}
+			c.persistWarmUpRegions(time.Now())
+			c.checkSystemRanges()
 		}
 	}
 }
@@ -341,6 +595,10 @@ func (c *RaftCluster) runCoordinator() {
 func (c *RaftCluster) syncRegions() {
 	defer logutil.LogPanic()
 	defer c.wg.Done()
+	// There is no region syncer if PD runs in the embedded mode.
+	if c.regionSyncer == nil {
+		return
+	}
 	c.regionSyncer.RunServer(c.changedRegionNotifier(), c.quit)
 }
 
@@ -350,6 +608,39 @@ func (c *RaftCluster) runReplicationMode() {
 	c.replicationMode.Run(c.quit)
 }
 
+func (c *RaftCluster) runEventBus() {
+	defer logutil.LogPanic()
+	defer c.wg.Done()
+	c.eventBus.Run(c.quit)
+}
+
+// runStoreConfigSync periodically synchronizes the configs of the stores.
+func (c *RaftCluster) runStoreConfigSync() {
+	defer logutil.LogPanic()
+	defer c.wg.Done()
+
+	ticker := time.NewTicker(storeConfigSyncInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-c.quit:
+			log.Info("store config sync has been stopped")
+			return
+		case <-ticker.C:
+			ctx, cancel := context.WithCancel(c.ctx)
+			go func() {
+				select {
+				case <-c.quit:
+					cancel()
+				case <-ctx.Done():
+				}
+			}()
+			c.storeConfigManager.Sync(ctx, c.GetStores())
+			cancel()
+		}
+	}
+}
+
 // Stop stops the cluster.
 func (c *RaftCluster) Stop() {
 	c.Lock()
@@ -463,6 +754,7 @@ func (c *RaftCluster) RemoveSuspectRegion(id uint64) {
 	c.Lock()
 	defer c.Unlock()
 	c.suspectRegions.Remove(id)
+	c.suspectRegionPersister.remove(id)
 }
 
 // AddSuspectKeyRange adds the key range with the its ruleID as the key
@@ -508,15 +800,30 @@ func (c *RaftCluster) HandleStoreHeartbeat(stats *pdpb.StoreStats) error {
 	if store == nil {
 		return errors.Errorf("store %v not found", storeID)
 	}
-	newStore := store.Clone(core.SetStoreStats(stats), core.SetLastHeartbeatTS(time.Now()))
+	now := time.Now()
+	forecast := c.capacityForecaster.Observe(storeID, now, stats.GetCapacity(), stats.GetAvailable())
+	newStore := store.Clone(core.SetStoreStats(stats), core.SetLastHeartbeatTS(now), core.SetDaysToFull(forecast.DaysToFull))
 	if newStore.IsLowSpace(c.opt.GetLowSpaceRatio()) {
 		log.Warn("store does not have enough disk space",
 			zap.Uint64("store-id", newStore.GetID()),
 			zap.Uint64("capacity", newStore.GetCapacity()),
 			zap.Uint64("available", newStore.GetAvailable()))
 	}
-	if newStore.NeedPersist() && c.storage != nil {
-		if err := c.storage.SaveStore(newStore.GetMeta()); err != nil {
+	if level := c.getCapacityLevel(newStore); level != statistics.CapacityLevelNormal && level != c.getCapacityLevel(store) {
+		log.Warn("store is predicted to be full soon",
+			zap.Uint64("store-id", newStore.GetID()),
+			zap.String("level", level),
+			zap.Float64("days-to-full", forecast.DaysToFull),
+			zap.Float64("growth-bytes-per-day", forecast.GrowthPerDay),
+			zap.Uint64("available", newStore.GetAvailable()))
+	}
+	// The periodic persist is not critical, so it is suppressed when the
+	// storage is slow.
+	if newStore.NeedPersist() && c.storage != nil && c.storageBreaker.allowStorePersist() {
+		start := time.Now()
+		err := c.storage.SaveStore(newStore.GetMeta())
+		c.storageBreaker.observe(time.Since(start))
+		if err != nil {
 			log.Error("failed to persist store", zap.Uint64("store-id", newStore.GetID()), errs.ZapError(err))
 		} else {
 			newStore = newStore.Clone(core.SetLastPersistTime(time.Now()))
@@ -576,20 +883,17 @@ func (c *RaftCluster) processRegionHeartbeat(region *core.RegionInfo) error {
 	storage := c.storage
 	coreCluster := c.core
 	hotStat := c.hotStat
+	profiler := c.heartbeatProfiler
+	regionStats := c.regionStats
 	c.RUnlock()
 
+	tracer := newHeartbeatTracer()
+	defer profiler.observe(region.GetID(), tracer)
 	origin, err := coreCluster.PreCheckPutRegion(region)
 	if err != nil {
+		tracer.onStageEnd(stagePreCheck)
 		return err
 	}
-	hotStat.CheckWriteAsync(statistics.NewCheckExpiredItemTask(region))
-	hotStat.CheckReadAsync(statistics.NewCheckExpiredItemTask(region))
-	reportInterval := region.GetInterval()
-	interval := reportInterval.GetEndTimestamp() - reportInterval.GetStartTimestamp()
-	for _, peer := range region.GetPeers() {
-		peerInfo := core.NewPeerInfo(peer, region.GetWriteLoads(), interval)
-		hotStat.CheckWriteAsync(statistics.NewCheckPeerTask(peerInfo, region))
-	}
 
 	// Save to storage if meta is updated.
 	// Save to cache if meta or leader is updated, or contains any down/pending peer.
@@ -662,6 +966,17 @@ func (c *RaftCluster) processRegionHeartbeat(region *core.RegionInfo) error {
 			saveCache = true
 		}
 	}
+	tracer.onStageEnd(stagePreCheck)
+
+	hotStat.CheckWriteAsync(statistics.NewCheckExpiredItemTask(region))
+	hotStat.CheckReadAsync(statistics.NewCheckExpiredItemTask(region))
+	reportInterval := region.GetInterval()
+	interval := reportInterval.GetEndTimestamp() - reportInterval.GetStartTimestamp()
+	for _, peer := range region.GetPeers() {
+		peerInfo := core.NewPeerInfo(peer, region.GetWriteLoads(), interval)
+		hotStat.CheckWriteAsync(statistics.NewCheckPeerTask(peerInfo, region))
+	}
+	tracer.onStageEnd(stageStatsTasks)
 
 	if !saveKV && !saveCache && !isNew {
 		return nil
@@ -670,8 +985,18 @@ func (c *RaftCluster) processRegionHeartbeat(region *core.RegionInfo) error {
 	failpoint.Inject("concurrentRegionHeartbeat", func() {
 		time.Sleep(500 * time.Millisecond)
 	})
+	tracer.skip()
+
+	// Fitting the region to the placement rules is costly, so the rule fit
+	// is classified before taking the lock.
+	var ruleFit statistics.RegionStatisticType
+	if regionStats != nil {
+		ruleFit = regionStats.ClassifyRuleFit(region)
+	}
+	tracer.onStageEnd(stageRuleFit)
 
 	var overlaps []*core.RegionInfo
+	// The time waiting for the lock is counted in the stage of saving the cache.
 	c.Lock()
 	if saveCache {
 		// To prevent a concurrent heartbeat of another region from overriding the up-to-date region info by a stale one,
@@ -688,7 +1013,11 @@ func (c *RaftCluster) processRegionHeartbeat(region *core.RegionInfo) error {
 				c.regionStats.ClearDefunctRegion(item.GetID())
 			}
 			c.labelLevelStats.ClearDefunctRegion(item.GetID())
+			if c.ruleManager != nil {
+				c.ruleManager.RemoveRegionFit(item.GetID())
+			}
 		}
+		c.regionHistory.observe(origin, region, overlaps)
 
 		// Update related stores.
 		storeMap := make(map[uint64]struct{})
@@ -705,42 +1034,55 @@ func (c *RaftCluster) processRegionHeartbeat(region *core.RegionInfo) error {
 		}
 		regionEventCounter.WithLabelValues("update_cache").Inc()
 	}
+	tracer.onStageEnd(stageSaveCache)
 
 	if isNew {
 		c.prepareChecker.collect(region)
 	}
 
 	if c.regionStats != nil {
-		c.regionStats.Observe(region, c.getRegionStoresLocked(region))
+		c.regionStats.ObserveWithRuleFit(region, c.getRegionStoresLocked(region), ruleFit)
 	}
 
 	changedRegions := c.changedRegions
 
 	c.Unlock()
+	tracer.onStageEnd(stageRegionStats)
 
 	if storage != nil {
 		// If there are concurrent heartbeats from the same region, the last write will win even if
 		// writes to storage in the critical area. So don't use mutex to protect it.
 		// Not successfully saved to storage is not fatal, it only leads to longer warm-up
 		// after restart. Here we only log the error then go on updating cache.
-		for _, item := range overlaps {
-			if err := storage.DeleteRegion(item.GetMeta()); err != nil {
-				log.Error("failed to delete region from storage",
-					zap.Uint64("region-id", item.GetID()),
-					logutil.ZapRedactStringer("region-meta", core.RegionToHexMeta(item.GetMeta())),
-					errs.ZapError(err))
-			}
-		}
+		// The overlapped regions are deleted in the background.
+		c.regionDeleter.add(overlaps)
 		if saveKV {
-			if err := storage.SaveRegion(region.GetMeta()); err != nil {
+			// The save is asynchronous and may be dropped if the storage is slow.
+			var (
+				dropped bool
+				err     error
+			)
+			c.regionDeleter.save(region.GetID(), func() {
+				dropped, err = c.storageBreaker.saveRegion(storage, region.GetMeta())
+			})
+			if err != nil {
 				log.Error("failed to save region to storage",
 					zap.Uint64("region-id", region.GetID()),
 					logutil.ZapRedactStringer("region-meta", core.RegionToHexMeta(region.GetMeta())),
 					errs.ZapError(err))
 			}
-			regionEventCounter.WithLabelValues("update_kv").Inc()
+			if dropped {
+				regionEventCounter.WithLabelValues("drop_kv").Inc()
+			} else {
+				regionEventCounter.WithLabelValues("update_kv").Inc()
+			}
 		}
 	}
+	tracer.onStageEnd(stageSaveKV)
+
+	if len(overlaps) > 0 && c.opt.IsStalePeerGCEnabled() {
+		c.stalePeers.onRegionsOverlapped(region, overlaps)
+	}
 
 	if saveKV || needSync {
 		select {
@@ -794,6 +1136,12 @@ func (c *RaftCluster) ScanRegions(startKey, endKey []byte, limit int) []*core.Re
 	return c.core.ScanRange(startKey, endKey, limit)
 }
 
+// GetRegionsByKeys gets the regions that contain the sorted keys. Each region
+// is returned once.
+func (c *RaftCluster) GetRegionsByKeys(keys [][]byte) []*core.RegionInfo {
+	return c.core.SearchRegions(keys)
+}
+
 // GetRegion searches for a region by ID.
 func (c *RaftCluster) GetRegion(regionID uint64) *core.RegionInfo {
 	return c.core.GetRegion(regionID)
@@ -890,6 +1238,9 @@ func (c *RaftCluster) DropCacheRegion(id uint64) {
 	defer c.RUnlock()
 	if region := c.GetRegion(id); region != nil {
 		c.core.RemoveRegion(region)
+		if c.ruleManager != nil {
+			c.ruleManager.RemoveRegionFit(id)
+		}
 	}
 }
 
@@ -938,12 +1289,14 @@ func (c *RaftCluster) UpdateStoreLabels(storeID uint64, labels []*metapb.StoreLa
 	newStore := proto.Clone(store.GetMeta()).(*metapb.Store)
 	newStore.Labels = labels
 	// PutStore will perform label merge.
-	return c.putStoreImpl(newStore, force)
+	_, err := c.putStoreImpl(newStore, force, "", false)
+	return err
 }
 
-// PutStore puts a store.
+// PutStore puts a store. It is not a registration of the store itself, so the
+// fencing token is neither checked nor issued.
 func (c *RaftCluster) PutStore(store *metapb.Store) error {
-	if err := c.putStoreImpl(store, false); err != nil {
+	if _, err := c.putStoreImpl(store, false, "", false); err != nil {
 		return err
 	}
 	c.OnStoreVersionChange()
@@ -951,36 +1304,160 @@ func (c *RaftCluster) PutStore(store *metapb.Store) error {
 	return nil
 }
 
-// putStoreImpl puts a store.
-// If 'force' is true, then overwrite the store's labels.
-func (c *RaftCluster) putStoreImpl(store *metapb.Store, force bool) error {
+// PutStoreWithFencingToken puts a store which registers itself with the
+// fencing token it presents. A fencing token is issued when the store has
+// none, that is, when it is registered for the first time, registered before
+// the token is introduced, or its token is reset by ResetStoreFencingToken.
+// It returns the token only when it is issued, so the token is not handed out
+// to any other caller. The store is rejected if it presents a different token,
+// or omits the token after it has presented it once, which means that it is a
+// stale node reusing the store ID. The stores which never present the token
+// are not fenced for the compatibility.
+func (c *RaftCluster) PutStoreWithFencingToken(store *metapb.Store, token string) (string, error) {
+	issued, err := c.putStoreImpl(store, false, token, true)
+	if err != nil {
+		return "", err
+	}
+	c.OnStoreVersionChange()
+	c.AddStoreLimit(store)
+	return issued, nil
+}
+
+// CheckStoreFencingToken checks the fencing token presented by a store, and
+// binds the token to the store the first time it is presented.
+func (c *RaftCluster) CheckStoreFencingToken(storeID uint64, token string) error {
+	store := c.GetStore(storeID)
+	if store == nil {
+		return errs.ErrStoreNotFound.FastGenByArgs(storeID)
+	}
+	if err := checkFencingToken(store, token); err != nil {
+		return err
+	}
+	if token == "" || store.IsFencingTokenBound() {
+		return nil
+	}
+	c.Lock()
+	defer c.Unlock()
+	store = c.GetStore(storeID)
+	if store == nil {
+		return errs.ErrStoreNotFound.FastGenByArgs(storeID)
+	}
+	// Check again as the token may be reset in the meantime.
+	if err := checkFencingToken(store, token); err != nil {
+		return err
+	}
+	if store.GetFencingToken() == "" || store.IsFencingTokenBound() {
+		return nil
+	}
+	store, err := c.bindFencingTokenLocked(store)
+	if err != nil {
+		return err
+	}
+	return c.putStoreLocked(store)
+}
+
+// ResetStoreFencingToken resets the fencing token of a store, so that a new
+// token is issued when the store registers itself again. It is used when the
+// store has lost its token deliberately, such as it is redeployed with the
+// same store ID.
+func (c *RaftCluster) ResetStoreFencingToken(storeID uint64) error {
+	c.Lock()
+	defer c.Unlock()
+
+	store := c.GetStore(storeID)
+	if store == nil {
+		return errs.ErrStoreNotFound.FastGenByArgs(storeID)
+	}
+	if err := c.storage.RemoveStoreFencingToken(storeID); err != nil {
+		return err
+	}
+	log.Warn("store fencing token is reset", zap.Uint64("store-id", storeID))
+	return c.putStoreLocked(store.Clone(core.SetFencingToken(""), core.SetFencingTokenBound(false)))
+}
+
+func checkFencingToken(store *core.StoreInfo, token string) error {
+	if store.GetFencingToken() == "" {
+		return nil
+	}
+	if token == "" {
+		if store.IsFencingTokenBound() {
+			return errs.ErrStoreFencingTokenMissing.FastGenByArgs(store.GetID())
+		}
+		return nil
+	}
+	if token != store.GetFencingToken() {
+		return errs.ErrStoreFencingTokenMismatch.FastGenByArgs(store.GetID())
+	}
+	return nil
+}
+
+func (c *RaftCluster) bindFencingTokenLocked(store *core.StoreInfo) (*core.StoreInfo, error) {
+	if c.storage != nil {
+		if err := c.storage.SaveStoreFencingTokenBound(store.GetID()); err != nil {
+			return nil, err
+		}
+	}
+	return store.Clone(core.SetFencingTokenBound(true)), nil
+}
+
+func newFencingToken() (string, error) {
+	b := make([]byte, 16)
+	if _, err := io.ReadFull(rand.Reader, b); err != nil {
+		return "", errs.ErrStoreFencingTokenGenerate.Wrap(err).GenWithStackByArgs()
+	}
+	return hex.EncodeToString(b), nil
+}
+
+// putStoreImpl puts a store and returns the fencing token if it is issued.
+// If 'force' is true, then overwrite the store's labels. If 'fenced' is true,
+// the store registers itself with the token, see PutStoreWithFencingToken.
+func (c *RaftCluster) putStoreImpl(store *metapb.Store, force bool, token string, fenced bool) (string, error) {
 	c.Lock()
 	defer c.Unlock()
 
 	if store.GetId() == 0 {
-		return errors.Errorf("invalid put store %v", store)
+		return "", errors.Errorf("invalid put store %v", store)
 	}
 
 	if err := c.checkStoreVersion(store); err != nil {
-		return err
+		return "", err
 	}
 
+	store, err := normalizeStoreAddresses(store)
+	if err != nil {
+		return "", err
+	}
 	// Store address can not be the same as other stores.
 	for _, s := range c.GetStores() {
 		// It's OK to start a new store on the same address if the old store has been removed or physically destroyed.
 		if s.IsTombstone() || s.IsPhysicallyDestroyed() {
 			continue
 		}
-		if s.GetID() != store.GetId() && s.GetAddress() == store.GetAddress() {
-			return errors.Errorf("duplicated store address: %v, already registered by %v", store, s.GetMeta())
+		if s.GetID() != store.GetId() && sameStoreAddress(s.GetAddress(), store.GetAddress()) {
+			return "", errors.Errorf("duplicated store address: %v, already registered by %v", store, s.GetMeta())
 		}
 	}
 
 	s := c.GetStore(store.GetId())
-	if s == nil {
-		// Add a new store.
-		s = core.NewStoreInfo(store)
+	if err := c.checkStoreAddressKind(s, store); err != nil {
+		return "", err
+	}
+	isNew := s == nil
+	if isNew {
+		// Add a new store, and record the join time for the warm-up of it.
+		joinTime := time.Now()
+		if c.storage != nil {
+			if err := c.storage.SaveStoreJoinTime(store.GetId(), joinTime); err != nil {
+				return "", err
+			}
+		}
+		s = core.NewStoreInfo(store, core.SetJoinTime(joinTime))
 	} else {
+		if fenced {
+			if err := checkFencingToken(s, token); err != nil {
+				return "", err
+			}
+		}
 		// Use the given labels to update the store.
 		labels := store.GetLabels()
 		if !force {
@@ -997,9 +1474,35 @@ func (c *RaftCluster) putStoreImpl(store *metapb.Store, force bool) error {
 		)
 	}
 	if err := c.checkStoreLabels(s); err != nil {
-		return err
+		return "", err
 	}
-	return c.putStoreLocked(s)
+	// Issue the fencing token when the store registers itself for the first
+	// time, or it is registered before the token is introduced or reset.
+	var issued string
+	if fenced && s.GetFencingToken() == "" {
+		newToken, err := newFencingToken()
+		if err != nil {
+			return "", err
+		}
+		if c.storage != nil {
+			if err := c.storage.SaveStoreFencingToken(s.GetID(), newToken); err != nil {
+				return "", err
+			}
+		}
+		s = s.Clone(core.SetFencingToken(newToken))
+		issued = newToken
+	} else if fenced && token != "" && !s.IsFencingTokenBound() {
+		if s, err = c.bindFencingTokenLocked(s); err != nil {
+			return "", err
+		}
+	}
+	if err := c.putStoreLocked(s); err != nil {
+		return "", err
+	}
+	if isNew {
+		c.eventBus.Publish(events.StoreStateChanged, s.GetID(), fmt.Sprintf("store %d joins the cluster as %s", s.GetID(), s.GetState()))
+	}
+	return issued, nil
 }
 
 func (c *RaftCluster) checkStoreVersion(store *metapb.Store) error {
@@ -1011,7 +1514,7 @@ func (c *RaftCluster) checkStoreVersion(store *metapb.Store) error {
 	if !versioninfo.IsCompatible(clusterVersion, *v) {
 		return errors.Errorf("version should compatible with version  %s, got %s", clusterVersion, v)
 	}
-	return nil
+	return c.checkUpgradeGuardrailLocked(store, v)
 }
 
 func (c *RaftCluster) checkStoreLabels(s *core.StoreInfo) error {
@@ -1065,6 +1568,12 @@ func (c *RaftCluster) RemoveStore(storeID uint64, physicallyDestroyed bool) erro
 		return errs.ErrStoreDestroyed.FastGenByArgs(storeID)
 	}
 
+	if !physicallyDestroyed {
+		if err := c.checkReplicaBeforeOfflineStore(storeID); err != nil {
+			return err
+		}
+	}
+
 	newStore := store.Clone(core.OfflineStore(physicallyDestroyed))
 	log.Warn("store has been offline",
 		zap.Uint64("store-id", newStore.GetID()),
@@ -1072,6 +1581,8 @@ func (c *RaftCluster) RemoveStore(storeID uint64, physicallyDestroyed bool) erro
 		zap.Bool("physically-destroyed", newStore.IsPhysicallyDestroyed()))
 	err := c.putStoreLocked(newStore)
 	if err == nil {
+		c.eventBus.Publish(events.StoreStateChanged, storeID,
+			fmt.Sprintf("store %d changes from %s to Offline, physically destroyed: %v", storeID, store.GetState(), physicallyDestroyed))
 		// TODO: if the persist operation encounters error, the "Unlimited" will be rollback.
 		// And considering the store state has changed, RemoveStore is actually successful.
 		_ = c.SetStoreLimit(storeID, storelimit.RemovePeer, storelimit.Unlimited)
@@ -1109,9 +1620,12 @@ func (c *RaftCluster) buryStore(storeID uint64) error {
 	err := c.putStoreLocked(newStore)
 	c.onStoreVersionChangeLocked()
 	if err == nil {
+		c.eventBus.Publish(events.StoreStateChanged, storeID,
+			fmt.Sprintf("store %d changes from %s to Tombstone", storeID, store.GetState()))
 		// clean up the residual information.
 		c.RemoveStoreLimit(storeID)
 		c.hotStat.RemoveRollingStoreStats(storeID)
+		c.capacityForecaster.RemoveStore(storeID)
 	}
 	return err
 }
@@ -1158,7 +1672,11 @@ func (c *RaftCluster) UpStore(storeID uint64) error {
 	log.Warn("store has been up",
 		zap.Uint64("store-id", storeID),
 		zap.String("store-address", newStore.GetAddress()))
-	return c.putStoreLocked(newStore)
+	if err := c.putStoreLocked(newStore); err != nil {
+		return err
+	}
+	c.eventBus.Publish(events.StoreStateChanged, storeID, fmt.Sprintf("store %d changes from %s to Up", storeID, store.GetState()))
+	return nil
 }
 
 // SetStoreWeight sets up a store's leader/region balance weight.
@@ -1183,6 +1701,159 @@ func (c *RaftCluster) SetStoreWeight(storeID uint64, leaderWeight, regionWeight
 	return c.putStoreLocked(newStore)
 }
 
+// StartStoreRestart starts or extends the restart window of a store. The down
+// peers on the store are not fixed before the deadline. evictLeader indicates
+// whether the store is added to the evict-leader scheduler by the window.
+func (c *RaftCluster) StartStoreRestart(storeID uint64, deadline time.Time, evictLeader bool) error {
+	c.Lock()
+	defer c.Unlock()
+
+	store := c.GetStore(storeID)
+	if store == nil {
+		return errs.ErrStoreNotFound.FastGenByArgs(storeID)
+	}
+	if store.IsTombstone() {
+		return errs.ErrStoreTombstone.FastGenByArgs(storeID)
+	}
+	restart, err := c.storage.LoadStoreRestart(storeID)
+	if err != nil {
+		return err
+	}
+	if restart != nil && restart.EvictLeader {
+		// The window is extended, keep the eviction started by it.
+		evictLeader = true
+	}
+	if err := c.storage.SaveStoreRestart(storeID, &core.StoreRestart{Deadline: deadline, EvictLeader: evictLeader}); err != nil {
+		return err
+	}
+	log.Info("store restart window is started",
+		zap.Uint64("store-id", storeID),
+		zap.Time("deadline", deadline),
+		zap.Bool("evict-leader", evictLeader))
+	c.core.PutStore(store.Clone(core.SetRestartDeadline(deadline)))
+	return nil
+}
+
+// EndStoreRestart ends the restart window of a store, and stops evicting leaders
+// from the store if the eviction is started by the window.
+func (c *RaftCluster) EndStoreRestart(storeID uint64) error {
+	c.Lock()
+	store := c.GetStore(storeID)
+	if store == nil {
+		c.Unlock()
+		return errs.ErrStoreNotFound.FastGenByArgs(storeID)
+	}
+	restart, err := c.storage.LoadStoreRestart(storeID)
+	if err == nil {
+		err = c.storage.SaveStoreRestart(storeID, nil)
+	}
+	if err != nil {
+		c.Unlock()
+		return err
+	}
+	log.Info("store restart window is ended", zap.Uint64("store-id", storeID))
+	c.core.PutStore(store.Clone(core.SetRestartDeadline(time.Time{})))
+	c.Unlock()
+
+	if restart == nil || !restart.EvictLeader {
+		return nil
+	}
+	return c.StopEvictingLeader(storeID)
+}
+
+// StopEvictingLeader removes the store from the evict-leader scheduler. It
+// must be called without the lock of the cluster held, because the scheduler
+// takes the lock when it is removed with the last store.
+func (c *RaftCluster) StopEvictingLeader(storeID uint64) error {
+	evicter := c.getLeaderEvicter()
+	if evicter == nil {
+		return nil
+	}
+	err := evicter.RemoveStore(storeID)
+	// The store may have been removed from the scheduler manually.
+	if err != nil && !errs.ErrScheduleConfigNotExist.Equal(err) && !errors.ErrorEqual(err, errs.ErrSchedulerNotFound.FastGenByArgs()) {
+		return err
+	}
+	return nil
+}
+
+// IsEvictingLeader returns whether the leaders of the store are being evicted
+// by the evict-leader scheduler.
+func (c *RaftCluster) IsEvictingLeader(storeID uint64) bool {
+	evicter := c.getLeaderEvicter()
+	return evicter != nil && evicter.HasStore(storeID)
+}
+
+// leaderEvicter is implemented by the evict-leader scheduler.
+type leaderEvicter interface {
+	HasStore(storeID uint64) bool
+	RemoveStore(storeID uint64) error
+}
+
+func (c *RaftCluster) getLeaderEvicter() leaderEvicter {
+	c.RLock()
+	co := c.coordinator
+	c.RUnlock()
+	if co == nil {
+		return nil
+	}
+	evicter, _ := co.getScheduler(schedulers.EvictLeaderName).(leaderEvicter)
+	return evicter
+}
+
+// checkStoreRestartWindows ends the expired restart windows.
+func (c *RaftCluster) checkStoreRestartWindows() {
+	for _, store := range c.GetStores() {
+		if store.GetRestartDeadline().IsZero() || store.IsRestarting() {
+			continue
+		}
+		if err := c.EndStoreRestart(store.GetID()); err != nil {
+			log.Warn("failed to end the expired store restart window", zap.Uint64("store-id", store.GetID()), errs.ZapError(err))
+		}
+	}
+}
+
+// checkSuspectStores marks the down stores as suspect within the grace period
+// after the max down time, during which they are expected to come back soon.
+func (c *RaftCluster) checkSuspectStores() {
+	c.Lock()
+	defer c.Unlock()
+	maxDownTime, grace := c.opt.GetMaxStoreDownTime(), c.opt.GetStoreSuspectGracePeriod()
+	for _, store := range c.GetStores() {
+		var suspectUntil time.Time
+		if grace > 0 && !store.IsTombstone() && store.DownTime() >= maxDownTime {
+			suspectUntil = store.GetLastHeartbeatTS().Add(maxDownTime + grace)
+		}
+		if store.GetSuspectUntil().Equal(suspectUntil) {
+			continue
+		}
+		log.Info("store suspect time is updated", zap.Uint64("store-id", store.GetID()), zap.Time("suspect-until", suspectUntil))
+		c.core.PutStore(store.Clone(core.SetSuspectUntil(suspectUntil)))
+	}
+}
+
+// SetStoreReadOnly sets whether a store is read-only. A read-only store keeps
+// its replicas, but it is not selected as the target of transfer leader or
+// add peer.
+func (c *RaftCluster) SetStoreReadOnly(storeID uint64, readOnly bool) error {
+	c.Lock()
+	defer c.Unlock()
+
+	store := c.GetStore(storeID)
+	if store == nil {
+		return errs.ErrStoreNotFound.FastGenByArgs(storeID)
+	}
+
+	if err := c.storage.SaveStoreReadOnly(storeID, readOnly); err != nil {
+		return err
+	}
+
+	log.Warn("store read-only state is changed",
+		zap.Uint64("store-id", storeID),
+		zap.Bool("read-only", readOnly))
+	return c.putStoreLocked(store.Clone(core.SetStoreReadOnly(readOnly)))
+}
+
 func (c *RaftCluster) putStoreLocked(store *core.StoreInfo) error {
 	if c.storage != nil {
 		if err := c.storage.SaveStore(store.GetMeta()); err != nil {
@@ -1191,6 +1862,7 @@ func (c *RaftCluster) putStoreLocked(store *core.StoreInfo) error {
 	}
 	c.core.PutStore(store)
 	c.hotStat.GetOrCreateRollingStoreStats(store.GetID())
+	c.storeTimeline.observe(store, time.Now())
 	return nil
 }
 
@@ -1266,26 +1938,90 @@ func (c *RaftCluster) RemoveTombStoneRecords() error {
 
 func (c *RaftCluster) deleteStoreLocked(store *core.StoreInfo) error {
 	if c.storage != nil {
+		now := time.Now()
+		archive := &core.StoreArchive{
+			Store:        store.GetMeta(),
+			Stats:        store.GetStoreStats(),
+			LeaderWeight: store.GetLeaderWeight(),
+			RegionWeight: store.GetRegionWeight(),
+			ArchiveTime:  now,
+			ExpireTime:   now.Add(storeArchiveTTL),
+		}
+		if err := c.storage.SaveStoreArchive(archive); err != nil {
+			return err
+		}
 		if err := c.storage.DeleteStore(store.GetMeta()); err != nil {
 			return err
 		}
+		if err := c.storage.RemoveExpiredStoreArchives(now); err != nil {
+			log.Warn("failed to remove the expired store archives", errs.ZapError(err))
+		}
 	}
 	c.core.DeleteStore(store)
+	c.stalePeers.removeStore(store.GetID())
+	c.storeTimeline.removeStore(store.GetID())
 	return nil
 }
 
-func (c *RaftCluster) collectMetrics() {
-	statsMap := statistics.NewStoreStatisticsMap(c.opt)
-	stores := c.GetStores()
-	for _, s := range stores {
-		statsMap.Observe(s, c.hotStat.StoresStats)
+// GetStoreArchive returns the archive of a removed tombstone store.
+func (c *RaftCluster) GetStoreArchive(storeID uint64) (*core.StoreArchive, error) {
+	archive, err := c.storage.LoadStoreArchive(storeID, time.Now())
+	if err != nil {
+		return nil, err
 	}
-	statsMap.Collect()
+	if archive == nil {
+		return nil, errs.ErrStoreArchiveNotFound.FastGenByArgs(storeID)
+	}
+	return archive, nil
+}
+
+// RestoreStoreArchive re-materializes a removed tombstone store from its
+// archive with the same meta and weights, so that the host can be added back
+// with the same store ID. The archive is removed after being restored.
+func (c *RaftCluster) RestoreStoreArchive(storeID uint64) error {
+	c.Lock()
+	defer c.Unlock()
 
-	c.coordinator.collectSchedulerMetrics()
-	c.coordinator.collectHotSpotMetrics()
-	c.collectClusterMetrics()
-	c.collectHealthStatus()
+	if c.GetStore(storeID) != nil {
+		return errs.ErrStoreArchiveRestore.FastGenByArgs(storeID, "the store exists")
+	}
+	archive, err := c.storage.LoadStoreArchive(storeID, time.Now())
+	if err != nil {
+		return err
+	}
+	if archive == nil {
+		return errs.ErrStoreArchiveNotFound.FastGenByArgs(storeID)
+	}
+	for _, s := range c.GetStores() {
+		if !s.IsTombstone() && sameStoreAddress(s.GetAddress(), archive.Store.GetAddress()) {
+			return errs.ErrStoreArchiveRestore.FastGenByArgs(storeID, fmt.Sprintf("the address is used by store %d", s.GetID()))
+		}
+	}
+
+	meta := proto.Clone(archive.Store).(*metapb.Store)
+	meta.State = metapb.StoreState_Up
+	if err := c.storage.SaveStoreWeight(storeID, archive.LeaderWeight, archive.RegionWeight); err != nil {
+		return err
+	}
+	store := core.NewStoreInfo(meta,
+		core.SetLeaderWeight(archive.LeaderWeight),
+		core.SetRegionWeight(archive.RegionWeight),
+	)
+	if err := c.putStoreLocked(store); err != nil {
+		return err
+	}
+	log.Info("restore store from the archive", zap.Stringer("store", meta))
+	return c.storage.DeleteStoreArchive(storeID)
+}
+
+func (c *RaftCluster) collectMetrics() {
+	observeMetricsCollector("store", c.collectStoreMetrics)
+	observeMetricsCollector("scheduler", c.coordinator.collectSchedulerMetrics)
+	observeMetricsCollector("hot-spot", c.coordinator.collectHotSpotMetrics)
+	observeMetricsCollector("cluster", c.collectClusterMetrics)
+	observeMetricsCollector("leader-constraint", c.collectLeaderConstraintMetrics)
+	observeMetricsCollector("alert", func() { c.alerts.evaluate(time.Now()) })
+	observeMetricsCollector("cache-memory", c.collectCacheMemoryMetrics)
 }
 
 func (c *RaftCluster) resetMetrics() {
@@ -1295,7 +2031,18 @@ func (c *RaftCluster) resetMetrics() {
 	c.coordinator.resetSchedulerMetrics()
 	c.coordinator.resetHotSpotMetrics()
 	c.resetClusterMetrics()
-	c.resetHealthStatus()
+	leaderConstraintGauge.Reset()
+	c.alerts.resetMetrics()
+	cacheMemoryGauge.Reset()
+}
+
+func (c *RaftCluster) collectStoreMetrics() {
+	statsMap := statistics.NewStoreStatisticsMap(c.opt)
+	stores := c.GetStores()
+	for _, s := range stores {
+		statsMap.Observe(s, c.hotStat.StoresStats)
+	}
+	statsMap.Collect()
 }
 
 func (c *RaftCluster) collectClusterMetrics() {
@@ -1355,6 +2102,17 @@ func (c *RaftCluster) GetRegionStatsByType(typ statistics.RegionStatisticType) [
 	return c.regionStats.GetRegionStatsByType(typ)
 }
 
+// GetPlacementDrift returns how far the regions are from fitting the placement
+// rules, which is refreshed when the metrics are collected.
+func (c *RaftCluster) GetPlacementDrift() statistics.PlacementDrift {
+	c.RLock()
+	defer c.RUnlock()
+	if c.regionStats == nil {
+		return statistics.PlacementDrift{}
+	}
+	return c.regionStats.GetPlacementDrift()
+}
+
 // GetOfflineRegionStatsByType gets the status of the offline region by types.
 func (c *RaftCluster) GetOfflineRegionStatsByType(typ statistics.RegionStatisticType) []*core.RegionInfo {
 	c.RLock()
@@ -1484,6 +2242,38 @@ func (c *RaftCluster) isPrepared() bool {
 	return c.prepareChecker.check(c)
 }
 
+// PrepareStatus is the progress of collecting the cluster information before
+// the scheduling starts.
+type PrepareStatus struct {
+	Prepared bool `json:"prepared"`
+	// ExpectedStoreCount is 0 if it is not declared.
+	ExpectedStoreCount  uint64 `json:"expected_store_count"`
+	ServingStoreCount   int    `json:"serving_store_count"`
+	RegionCount         int    `json:"region_count"`
+	ReportedRegionCount int    `json:"reported_region_count"`
+	// RegionLoadState is the state of loading the regions from the storage,
+	// and LoadedRegionCount is the number of the regions loaded in the
+	// prioritized loading.
+	RegionLoadState   string `json:"region_load_state"`
+	LoadedRegionCount int    `json:"loaded_region_count,omitempty"`
+}
+
+// GetPrepareStatus returns the progress of collecting the cluster information.
+func (c *RaftCluster) GetPrepareStatus() *PrepareStatus {
+	c.RLock()
+	defer c.RUnlock()
+	loadState, loaded := c.regionLoader.getState()
+	return &PrepareStatus{
+		Prepared:            c.prepareChecker.check(c),
+		ExpectedStoreCount:  c.opt.GetExpectedStoreCount(),
+		ServingStoreCount:   c.prepareChecker.countServingStores(c),
+		RegionCount:         c.core.GetRegionCount(),
+		ReportedRegionCount: c.prepareChecker.sum,
+		RegionLoadState:     loadState,
+		LoadedRegionCount:   loaded,
+	}
+}
+
 // GetStoresLoads returns load stats of all stores.
 func (c *RaftCluster) GetStoresLoads() map[uint64][]float64 {
 	c.RLock()
@@ -1510,6 +2300,7 @@ func (c *RaftCluster) RegionWriteStats() map[uint64][]*statistics.HotPeerStat {
 
 // TODO: remove me.
 // only used in test.
+//
 //nolint:unused
 func (c *RaftCluster) putRegion(region *core.RegionInfo) error {
 	c.Lock()
@@ -1530,11 +2321,71 @@ func (c *RaftCluster) GetRuleManager() *placement.RuleManager {
 	return c.ruleManager
 }
 
+// GetStoreConfigManager returns the store config manager reference.
+func (c *RaftCluster) GetStoreConfigManager() *storeconfig.Manager {
+	c.RLock()
+	defer c.RUnlock()
+	return c.storeConfigManager
+}
+
+// GetEventBus returns the event bus reference.
+func (c *RaftCluster) GetEventBus() *events.Bus {
+	c.RLock()
+	defer c.RUnlock()
+	return c.eventBus
+}
+
+// onRulesChanged is called by the rule manager with its lock held.
+func (c *RaftCluster) onRulesChanged(rules [][2]string, groups []string) {
+	keys := make([]string, 0, len(rules))
+	for _, key := range rules {
+		keys = append(keys, key[0]+"/"+key[1])
+	}
+	sort.Strings(keys)
+	sort.Strings(groups)
+	c.eventBus.Publish(events.RuleChanged, 0,
+		fmt.Sprintf("rules [%s] and rule groups [%s] are changed", strings.Join(keys, ", "), strings.Join(groups, ", ")))
+}
+
+// GetTieringManager returns the tiering manager reference.
+func (c *RaftCluster) GetTieringManager() *tiering.Manager {
+	c.RLock()
+	defer c.RUnlock()
+	return c.tieringManager
+}
+
+// GetRegionLabeler returns the region labeler reference.
+func (c *RaftCluster) GetRegionLabeler() *labeler.RegionLabeler {
+	c.RLock()
+	defer c.RUnlock()
+	return c.regionLabeler
+}
+
+// GetKeyRangeManager returns the key range lock manager reference.
+func (c *RaftCluster) GetKeyRangeManager() *keyrange.Manager {
+	c.RLock()
+	defer c.RUnlock()
+	return c.keyRangeManager
+}
+
+// GetAffinityManager returns the affinity group manager reference.
+func (c *RaftCluster) GetAffinityManager() *affinity.Manager {
+	c.RLock()
+	defer c.RUnlock()
+	return c.affinityManager
+}
+
 // FitRegion tries to fit the region with placement rules.
 func (c *RaftCluster) FitRegion(region *core.RegionInfo) *placement.RegionFit {
-	return c.GetRuleManager().FitRegion(c, region)
+	// Fit with the basic cluster, which tracks the changes of the store
+	// labels, so the fits are cached.
+	return c.GetRuleManager().FitRegion(c.core, region)
 }
 
+// expectedStoreWaitTimeout is the max time to wait for the expected stores, so
+// a wrong declaration does not stop the scheduling forever.
+const expectedStoreWaitTimeout = 30 * time.Minute
+
 type prepareChecker struct {
 	reactiveRegions map[uint64]int
 	start           time.Time
@@ -1550,8 +2401,22 @@ func newPrepareChecker() *prepareChecker {
 }
 
 // Before starting up the scheduler, we need to take the proportion of the regions on each store into consideration.
+// If the expected number of the stores is declared, we need to wait for them to be up as well.
 func (checker *prepareChecker) check(c *RaftCluster) bool {
-	if checker.isPrepared || time.Since(checker.start) > collectTimeout {
+	if checker.isPrepared {
+		return true
+	}
+	// The scheduling does not start with part of the regions.
+	if !c.regionLoader.isDone() {
+		return false
+	}
+	elapsed := time.Since(checker.start)
+	if expected := c.opt.GetExpectedStoreCount(); expected > 0 && elapsed <= expectedStoreWaitTimeout {
+		if uint64(checker.countServingStores(c)) < expected {
+			return false
+		}
+	}
+	if elapsed > collectTimeout {
 		return true
 	}
 	// The number of active regions should be more than total region of all stores * collectFactor
@@ -1572,6 +2437,18 @@ func (checker *prepareChecker) check(c *RaftCluster) bool {
 	return true
 }
 
+// countServingStores returns the number of the stores which are up and send
+// heartbeats.
+func (checker *prepareChecker) countServingStores(c *RaftCluster) int {
+	count := 0
+	for _, store := range c.GetStores() {
+		if store.IsUp() && !store.IsDisconnected() {
+			count++
+		}
+	}
+	return count
+}
+
 func (checker *prepareChecker) collect(region *core.RegionInfo) {
 	for _, p := range region.GetPeers() {
 		checker.reactiveRegions[p.GetStoreId()]++
@@ -1644,6 +2521,40 @@ func (c *RaftCluster) RemoveScheduler(name string) error {
 	return c.coordinator.removeScheduler(name)
 }
 
+// SetSchedulerWindow sets the daily window in which a scheduler is allowed to
+// schedule. An empty window removes the limit.
+func (c *RaftCluster) SetSchedulerWindow(name, window string) error {
+	c.RLock()
+	defer c.RUnlock()
+	return c.coordinator.setSchedulerWindow(name, window)
+}
+
+// SetSchedulerShadow switches the shadow mode of a scheduler, in which it
+// computes the operators every tick without dispatching them.
+func (c *RaftCluster) SetSchedulerShadow(name string, shadow bool) error {
+	c.RLock()
+	defer c.RUnlock()
+	return c.coordinator.setSchedulerShadow(name, shadow)
+}
+
+// GetSchedulerShadowStatus returns the operators a scheduler has produced in
+// the shadow mode.
+func (c *RaftCluster) GetSchedulerShadowStatus(name string) (*SchedulerShadowStatus, error) {
+	c.RLock()
+	defer c.RUnlock()
+	return c.coordinator.getSchedulerShadowStatus(name)
+}
+
+// CancelSchedulerOperators cancels the operators created by the scheduler
+// gracefully, and returns the number of them. The operators of the checkers
+// are never canceled by it.
+func (c *RaftCluster) CancelSchedulerOperators(name string) int {
+	if operator.IsCheckerOwner(name) {
+		return 0
+	}
+	return c.coordinator.opController.CancelOperatorsByOwner(name)
+}
+
 // PauseOrResumeScheduler pauses or resumes a scheduler.
 func (c *RaftCluster) PauseOrResumeScheduler(name string, t int64) error {
 	c.RLock()
@@ -1651,6 +2562,15 @@ func (c *RaftCluster) PauseOrResumeScheduler(name string, t int64) error {
 	return c.coordinator.pauseOrResumeScheduler(name, t)
 }
 
+// PreviewScheduler returns the operators the scheduler would produce next
+// without executing them.
+func (c *RaftCluster) PreviewScheduler(name string, count int) (*SchedulerPreview, error) {
+	c.RLock()
+	co := c.coordinator
+	c.RUnlock()
+	return co.previewScheduler(name, count)
+}
+
 // IsSchedulerPaused checks if a scheduler is paused.
 func (c *RaftCluster) IsSchedulerPaused(name string) (bool, error) {
 	c.RLock()
@@ -1705,6 +2625,11 @@ func (c *RaftCluster) AddStoreLimit(store *metapb.Store) {
 			RemovePeer: config.DefaultTiFlashStoreLimit.GetDefaultStoreLimit(storelimit.RemovePeer),
 		}
 	}
+	if s := c.GetStore(storeID); s != nil {
+		if profile := cfg.GetStoreLimitProfile(s.GetResourceTags()); profile != nil {
+			sc = profile.StoreLimitConfig
+		}
+	}
 
 	cfg.StoreLimit[storeID] = sc
 	c.opt.SetScheduleConfig(cfg)
@@ -1795,6 +2720,7 @@ func CheckHealth(client *http.Client, members []*pdpb.Member) map[uint64]*pdpb.M
 	healthMembers := make(map[uint64]*pdpb.Member)
 	for _, member := range members {
 		for _, cURL := range member.ClientUrls {
+			cURL = healthCheckURL(cURL, member.GetPeerUrls())
 			ctx, cancel := context.WithTimeout(context.Background(), clientTimeout)
 			req, err := http.NewRequestWithContext(ctx, "GET", fmt.Sprintf("%s%s", cURL, healthURL), nil)
 			if err != nil {
@@ -1817,6 +2743,56 @@ func CheckHealth(client *http.Client, members []*pdpb.Member) map[uint64]*pdpb.M
 	return healthMembers
 }
 
+// healthCheckURL returns the client URL in the canonical form, whose loopback
+// or unspecified host is replaced with the host of the peer URL, so that the
+// IPv6 URLs and the wildcard listeners can be reached.
+func healthCheckURL(cURL string, peerURLs []string) string {
+	if normalized, err := netutil.NormalizeAddr(cURL); err == nil {
+		cURL = normalized
+	}
+	if len(peerURLs) > 0 {
+		cURL = netutil.ResolveLoopBackAddr(cURL, peerURLs[0])
+	}
+	return cURL
+}
+
+// CheckBootstrapRequest checks whether the bootstrap request is valid.
+func CheckBootstrapRequest(clusterID uint64, req *pdpb.BootstrapRequest) error {
+	// TODO: do more check for request fields validation.
+
+	storeMeta := req.GetStore()
+	if storeMeta == nil {
+		return errors.Errorf("missing store meta for bootstrap %d", clusterID)
+	} else if storeMeta.GetId() == 0 {
+		return errors.New("invalid zero store id")
+	}
+
+	regionMeta := req.GetRegion()
+	if regionMeta == nil {
+		return errors.Errorf("missing region meta for bootstrap %d", clusterID)
+	} else if len(regionMeta.GetStartKey()) > 0 || len(regionMeta.GetEndKey()) > 0 {
+		// first region start/end key must be empty
+		return errors.Errorf("invalid first region key range, must all be empty for bootstrap %d", clusterID)
+	} else if regionMeta.GetId() == 0 {
+		return errors.New("invalid zero region id")
+	}
+
+	peers := regionMeta.GetPeers()
+	if len(peers) != 1 {
+		return errors.Errorf("invalid first region peer count %d, must be 1 for bootstrap %d", len(peers), clusterID)
+	}
+
+	peer := peers[0]
+	if peer.GetStoreId() != storeMeta.GetId() {
+		return errors.Errorf("invalid peer store id %d != %d for bootstrap %d", peer.GetStoreId(), storeMeta.GetId(), clusterID)
+	}
+	if peer.GetId() == 0 {
+		return errors.New("invalid zero peer id")
+	}
+
+	return nil
+}
+
 // GetMembers return a slice of Members.
 func GetMembers(etcdClient *clientv3.Client) ([]*pdpb.Member, error) {
 	listResp, err := etcdutil.ListEtcdMembers(etcdClient)
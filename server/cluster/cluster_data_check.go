@@ -0,0 +1,63 @@
+// Copyright 2021 TiKV Project Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cluster
+
+import (
+	"fmt"
+
+	"github.com/tikv/pd/pkg/errs"
+)
+
+// checkClusterData cross-checks the loaded metadata to detect that etcd or the
+// region storage is restored from another cluster, which corrupts the metadata
+// silently, such as by allocating the IDs in use again.
+func (c *RaftCluster) checkClusterData() error {
+	mismatch := func(format string, args ...interface{}) error {
+		return errs.ErrClusterDataMismatch.FastGenByArgs(c.clusterID, fmt.Sprintf(format, args...))
+	}
+	if id := c.meta.GetId(); id != c.clusterID {
+		return mismatch("the cluster meta belongs to cluster %d", id)
+	}
+	id, ok, err := c.storage.LoadRegionStorageClusterID()
+	if err != nil {
+		return err
+	}
+	if ok && id != c.clusterID {
+		return mismatch("the region storage belongs to cluster %d", id)
+	}
+
+	var maxID uint64
+	updateMaxID := func(id uint64) {
+		if id > maxID {
+			maxID = id
+		}
+	}
+	for _, store := range c.GetMetaStores() {
+		updateMaxID(store.GetId())
+	}
+	for _, region := range c.GetRegions() {
+		updateMaxID(region.GetID())
+		for _, peer := range region.GetPeers() {
+			updateMaxID(peer.GetId())
+		}
+	}
+	allocID, ok, err := c.storage.LoadAllocID()
+	if err != nil {
+		return err
+	}
+	if ok && allocID < maxID {
+		return mismatch("ID %d is in use but the allocated ID is %d", maxID, allocID)
+	}
+	return nil
+}
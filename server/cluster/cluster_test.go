@@ -17,6 +17,7 @@ import (
 	"context"
 	"fmt"
 	"math/rand"
+	"strings"
 	"sync"
 	"testing"
 	"time"
@@ -28,10 +29,15 @@ import (
 	"github.com/pingcap/kvproto/pkg/pdpb"
 	"github.com/tikv/pd/pkg/errs"
 	"github.com/tikv/pd/pkg/mock/mockid"
+	"github.com/tikv/pd/pkg/typeutil"
 	"github.com/tikv/pd/server/config"
 	"github.com/tikv/pd/server/core"
+	"github.com/tikv/pd/server/core/storelimit"
+	"github.com/tikv/pd/server/events"
 	"github.com/tikv/pd/server/id"
 	"github.com/tikv/pd/server/kv"
+	"github.com/tikv/pd/server/schedule/labeler"
+	"github.com/tikv/pd/server/schedule/operator"
 	"github.com/tikv/pd/server/schedule/opt"
 	"github.com/tikv/pd/server/schedule/placement"
 	"github.com/tikv/pd/server/statistics"
@@ -255,6 +261,389 @@ func (s *testClusterInfoSuite) TestSetOfflineStore(c *C) {
 	}
 }
 
+func (s *testClusterInfoSuite) TestHeartbeatProfiler(c *C) {
+	_, opt, err := newTestScheduleConfig()
+	c.Assert(err, IsNil)
+	cluster := newTestRaftCluster(s.ctx, mockid.NewIDAllocator(), opt, core.NewStorage(kv.NewMemoryKV()), core.NewBasicCluster())
+	region := core.NewRegionInfo(newTestRegionMeta(1), nil)
+	c.Assert(cluster.processRegionHeartbeat(region), IsNil)
+	profile := cluster.GetHeartbeatProfile()
+	c.Assert(profile.Count, Equals, uint64(1))
+	c.Assert(profile.Stages, HasLen, int(heartbeatStageCount))
+	c.Assert(profile.TopRegions, HasLen, 1)
+	c.Assert(profile.TopRegions[0].RegionID, Equals, uint64(1))
+
+	// Only the regions with the costliest heartbeats are kept.
+	cluster.ResetHeartbeatProfile()
+	profiler := cluster.heartbeatProfiler
+	observe := func(regionID uint64, saveCache, saveKV time.Duration) {
+		tracer := &heartbeatTracer{}
+		tracer.costs[stageSaveCache] = saveCache
+		tracer.costs[stageSaveKV] = saveKV
+		profiler.observe(regionID, tracer)
+	}
+	for i := 1; i <= maxProfiledRegions; i++ {
+		observe(uint64(i), time.Duration(i)*time.Millisecond, 0)
+	}
+	observe(maxProfiledRegions+1, 0, 0)
+	observe(maxProfiledRegions+2, 0, time.Second)
+	observe(maxProfiledRegions, time.Millisecond, 0)
+	profile = cluster.GetHeartbeatProfile()
+	c.Assert(profile.Count, Equals, uint64(maxProfiledRegions+3))
+	c.Assert(profile.TopRegions, HasLen, maxProfiledRegions)
+	top := profile.TopRegions[0]
+	c.Assert(top.RegionID, Equals, uint64(maxProfiledRegions+2))
+	c.Assert(top.Stages[heartbeatStageNames[stageSaveKV]].Duration, Equals, time.Second)
+	top = profile.TopRegions[1]
+	c.Assert(top.RegionID, Equals, uint64(maxProfiledRegions))
+	c.Assert(top.Count, Equals, uint64(2))
+	c.Assert(top.Max.Duration, Equals, maxProfiledRegions*time.Millisecond)
+	c.Assert(profile.TopRegions[maxProfiledRegions-1].RegionID, Equals, uint64(2))
+	c.Assert(profile.Stages[0].Stage, Equals, heartbeatStageNames[stageSaveCache])
+}
+
+func (s *testClusterInfoSuite) TestStalePeers(c *C) {
+	_, opt, err := newTestScheduleConfig()
+	c.Assert(err, IsNil)
+	cfg := opt.GetScheduleConfig().Clone()
+	cfg.EnableStalePeerGC = true
+	opt.SetScheduleConfig(cfg)
+	storage := core.NewStorage(kv.NewMemoryKV())
+	cluster := newTestRaftCluster(s.ctx, mockid.NewIDAllocator(), opt, storage, core.NewBasicCluster())
+	for _, store := range newTestStores(4, "2.0.0") {
+		c.Assert(cluster.putStoreLocked(store), IsNil)
+	}
+	newRegion := func(id uint64, start, end string, version uint64, storeIDs ...uint64) *core.RegionInfo {
+		meta := &metapb.Region{
+			Id:          id,
+			StartKey:    []byte(start),
+			EndKey:      []byte(end),
+			RegionEpoch: &metapb.RegionEpoch{Version: version, ConfVer: 1},
+		}
+		for _, storeID := range storeIDs {
+			meta.Peers = append(meta.Peers, &metapb.Peer{Id: id*10 + storeID, StoreId: storeID})
+		}
+		return core.NewRegionInfo(meta, meta.Peers[0])
+	}
+	c.Assert(cluster.processRegionHeartbeat(newRegion(1, "a", "b", 1, 1, 2, 3)), IsNil)
+	c.Assert(cluster.processRegionHeartbeat(newRegion(2, "b", "c", 1, 1, 2, 4)), IsNil)
+	c.Assert(cluster.SyncStalePeers(4, 0, false), IsNil)
+
+	// Region 3 overlaps both regions, and only the peer of region 2 on store 4
+	// is removed.
+	c.Assert(cluster.processRegionHeartbeat(newRegion(3, "a", "c", 2, 1, 2, 3)), IsNil)
+	stores := cluster.GetStalePeers()
+	c.Assert(stores, HasLen, 1)
+	c.Assert(stores[0].StoreID, Equals, uint64(4))
+	c.Assert(stores[0].Overdue, Equals, 0)
+	publish := cluster.SyncStalePeers(4, 0, false)
+	c.Assert(publish.Version, Equals, uint64(1))
+	c.Assert(publish.Peers, HasLen, 1)
+	peer := publish.Peers[0]
+	c.Assert(peer.RegionID, Equals, uint64(2))
+	c.Assert(peer.PeerID, Equals, uint64(24))
+	c.Assert(peer.StartKey, Equals, core.HexRegionKeyStr([]byte("b")))
+	c.Assert(cluster.SyncStalePeers(1, 0, false), IsNil)
+
+	// The removed peers are reloaded after the leader changes.
+	reloaded := newStalePeerTracker(cluster)
+	c.Assert(reloaded.load(), IsNil)
+	stores = reloaded.list(0)
+	c.Assert(stores, HasLen, 1)
+	c.Assert(stores[0].Version, Equals, uint64(1))
+	c.Assert(stores[0].Peers[0].PeerID, Equals, uint64(24))
+	c.Assert(stores[0].Overdue, Equals, 1)
+
+	// The acknowledged peers are dropped.
+	c.Assert(cluster.processRegionHeartbeat(newRegion(4, "a", "d", 3, 1, 2, 4)), IsNil)
+	publish = cluster.SyncStalePeers(4, 0, false)
+	c.Assert(publish.Version, Equals, uint64(1))
+	publish = cluster.SyncStalePeers(3, 0, false)
+	c.Assert(publish.Version, Equals, uint64(1))
+	c.Assert(publish.Peers[0].RegionID, Equals, uint64(3))
+	publish = cluster.SyncStalePeers(4, 1, true)
+	c.Assert(publish, IsNil)
+	stores = cluster.GetStalePeers()
+	c.Assert(stores, HasLen, 1)
+	c.Assert(stores[0].StoreID, Equals, uint64(3))
+
+	// Nothing is published if it is disabled.
+	cfg.EnableStalePeerGC = false
+	opt.SetScheduleConfig(cfg)
+	c.Assert(cluster.SyncStalePeers(3, 0, false), IsNil)
+}
+
+func (s *testClusterInfoSuite) TestPersistSuspectRegions(c *C) {
+	_, opt, err := newTestScheduleConfig()
+	c.Assert(err, IsNil)
+	storage := core.NewStorage(kv.NewMemoryKV())
+	cluster := newTestRaftCluster(s.ctx, mockid.NewIDAllocator(), opt, storage, core.NewBasicCluster())
+	cluster.AddPrioritySuspectRegions(SuspectSplit, 1, 2)
+	cluster.AddPrioritySuspectRegions(SuspectAccelerated, 3)
+	// The regions without the reason are not persisted.
+	cluster.AddSuspectRegions(4)
+	cluster.RemoveSuspectRegion(2)
+	now := time.Now()
+	cluster.suspectRegionPersister.flush(now)
+
+	// The persisted regions are reloaded after the leader changes.
+	reloaded := newTestRaftCluster(s.ctx, mockid.NewIDAllocator(), opt, storage, core.NewBasicCluster())
+	c.Assert(reloaded.loadSuspectRegionsLocked(), IsNil)
+	c.Assert(reloaded.GetSuspectRegions(), HasLen, 2)
+	regions := reloaded.GetPersistedSuspectRegions()
+	c.Assert(regions, HasLen, 2)
+	c.Assert(regions[0].RegionID, Equals, uint64(1))
+	c.Assert(regions[0].Reason, Equals, SuspectSplit)
+	c.Assert(regions[1].RegionID, Equals, uint64(3))
+	c.Assert(regions[1].Reason, Equals, SuspectAccelerated)
+
+	// The regions which are not suspected any more are dropped.
+	reloaded.suspectRegions.Remove(1)
+	reloaded.suspectRegionPersister.flush(now)
+	c.Assert(reloaded.GetPersistedSuspectRegions(), HasLen, 1)
+
+	// The expired regions are not reloaded.
+	persister := newSuspectRegionPersister(reloaded)
+	regions, err = persister.load(now.Add(persistedSuspectRegionTTL))
+	c.Assert(err, IsNil)
+	c.Assert(regions, HasLen, 0)
+	regions, err = persister.load(now)
+	c.Assert(err, IsNil)
+	c.Assert(regions, HasLen, 1)
+	c.Assert(regions[0].RegionID, Equals, uint64(3))
+}
+
+func (s *testClusterInfoSuite) TestStoreTimeline(c *C) {
+	_, opt, err := newTestScheduleConfig()
+	c.Assert(err, IsNil)
+	storage := core.NewStorage(kv.NewMemoryKV())
+	cluster := newTestRaftCluster(s.ctx, mockid.NewIDAllocator(), opt, storage, core.NewBasicCluster())
+	store := newTestStores(1, "2.0.0")[0]
+	c.Assert(cluster.putStoreLocked(store), IsNil)
+	states := func(timeline *StoreTimeline) []string {
+		var res []string
+		for _, e := range timeline.Events {
+			res = append(res, e.State)
+		}
+		return res
+	}
+	timeline, err := cluster.GetStoreTimeline(1)
+	c.Assert(err, IsNil)
+	c.Assert(states(timeline), DeepEquals, []string{StoreTimelineUp})
+	_, err = cluster.GetStoreTimeline(2)
+	c.Assert(errs.ErrStoreNotFound.Equal(err), IsTrue)
+
+	// Disconnected and Down are derived from the heartbeats.
+	now := time.Now()
+	c.Assert(cluster.putStoreLocked(store.Clone(core.SetLastHeartbeatTS(now.Add(-time.Minute)))), IsNil)
+	c.Assert(cluster.putStoreLocked(store.Clone(core.SetLastHeartbeatTS(now.Add(-time.Minute)))), IsNil)
+	c.Assert(cluster.putStoreLocked(store.Clone(core.SetLastHeartbeatTS(now.Add(-2*opt.GetMaxStoreDownTime())))), IsNil)
+	c.Assert(cluster.putStoreLocked(store.Clone(core.SetLastHeartbeatTS(now), core.OfflineStore(false))), IsNil)
+	timeline, err = cluster.GetStoreTimeline(1)
+	c.Assert(err, IsNil)
+	c.Assert(states(timeline), DeepEquals, []string{StoreTimelineUp, StoreTimelineDisconnected, StoreTimelineDown, StoreTimelineOffline})
+
+	// The timeline is reloaded after the leader changes.
+	reloaded := newStoreTimelineTracker(cluster)
+	c.Assert(reloaded.load(), IsNil)
+	c.Assert(states(reloaded.get(1)), DeepEquals, states(timeline))
+
+	// Only the latest transitions are kept.
+	for i := 0; i < maxStoreTimelineEvents; i++ {
+		c.Assert(cluster.putStoreLocked(store.Clone(core.SetLastHeartbeatTS(now), core.UpStore())), IsNil)
+		c.Assert(cluster.putStoreLocked(store.Clone(core.SetLastHeartbeatTS(now), core.OfflineStore(false))), IsNil)
+	}
+	timeline, err = cluster.GetStoreTimeline(1)
+	c.Assert(err, IsNil)
+	c.Assert(timeline.Events, HasLen, maxStoreTimelineEvents)
+	c.Assert(timeline.Events[0].State, Equals, StoreTimelineUp)
+
+	cluster.storeTimeline.removeStore(1)
+	reloaded = newStoreTimelineTracker(cluster)
+	c.Assert(reloaded.load(), IsNil)
+	c.Assert(reloaded.get(1), IsNil)
+}
+
+func (s *testClusterInfoSuite) TestAlerts(c *C) {
+	_, opt, err := newTestScheduleConfig()
+	c.Assert(err, IsNil)
+	storage := core.NewStorage(kv.NewMemoryKV())
+	cluster := newTestRaftCluster(s.ctx, mockid.NewIDAllocator(), opt, storage, core.NewBasicCluster())
+	var notified []*AlertNotification
+	cluster.alerts.notify = func(webhook string, n *AlertNotification) {
+		c.Assert(webhook, Equals, "http://127.0.0.1:8080/alert")
+		notified = append(notified, n)
+	}
+	stores := newTestStores(3, "5.0.0")
+	for _, store := range stores {
+		c.Assert(cluster.putStoreLocked(store), IsNil)
+	}
+
+	c.Assert(errs.ErrInvalidAlertRule.Equal(cluster.SetAlertRule(&AlertRule{Name: "a/b", Stat: AlertOfflineStoreCount, Op: ">"})), IsTrue)
+	c.Assert(errs.ErrInvalidAlertRule.Equal(cluster.SetAlertRule(&AlertRule{Name: "unknown", Stat: "unknown", Op: ">"})), IsTrue)
+	c.Assert(errs.ErrInvalidAlertRule.Equal(cluster.SetAlertRule(&AlertRule{Name: "op", Stat: AlertOfflineStoreCount, Op: "!="})), IsTrue)
+	c.Assert(errs.ErrInvalidAlertRule.Equal(cluster.SetAlertRule(&AlertRule{Name: "webhook", Stat: AlertOfflineStoreCount, Op: ">", Webhook: "ftp://a"})), IsTrue)
+	c.Assert(cluster.SetAlertRule(&AlertRule{
+		Name:    "offline",
+		Stat:    AlertOfflineStoreCount,
+		Op:      ">",
+		For:     typeutil.NewDuration(10 * time.Minute),
+		Webhook: "http://127.0.0.1:8080/alert",
+	}), IsNil)
+	c.Assert(cluster.SetAlertRule(&AlertRule{Name: "stuck", Stat: AlertOfflineStoreMaxSeconds, Op: ">=", Threshold: 7200}), IsNil)
+	states := func() map[string]string {
+		res := make(map[string]string)
+		for _, status := range cluster.GetAlerts() {
+			res[status.Rule.Name] = status.State
+		}
+		return res
+	}
+	now := time.Now()
+	cluster.alerts.evaluate(now)
+	c.Assert(states(), DeepEquals, map[string]string{"offline": AlertInactive, "stuck": AlertInactive})
+
+	// The alert fires once the condition holds for the duration.
+	c.Assert(cluster.putStoreLocked(stores[0].Clone(core.OfflineStore(false))), IsNil)
+	cluster.alerts.evaluate(now)
+	c.Assert(states(), DeepEquals, map[string]string{"offline": AlertPending, "stuck": AlertInactive})
+	cluster.alerts.evaluate(now.Add(10 * time.Minute))
+	c.Assert(states(), DeepEquals, map[string]string{"offline": AlertFiring, "stuck": AlertInactive})
+	c.Assert(notified, HasLen, 1)
+	c.Assert(notified[0].State, Equals, AlertFiring)
+	c.Assert(notified[0].Value, Equals, 1.0)
+	cluster.alerts.evaluate(now.Add(time.Hour))
+	c.Assert(notified, HasLen, 1)
+	// The stuck offline store is alerted without the webhook.
+	cluster.alerts.evaluate(now.Add(3 * time.Hour))
+	c.Assert(states(), DeepEquals, map[string]string{"offline": AlertFiring, "stuck": AlertFiring})
+	c.Assert(notified, HasLen, 1)
+
+	// The alerts are resolved once the condition does not hold.
+	c.Assert(cluster.putStoreLocked(stores[0].Clone(core.UpStore())), IsNil)
+	cluster.alerts.evaluate(now.Add(4 * time.Hour))
+	c.Assert(states(), DeepEquals, map[string]string{"offline": AlertInactive, "stuck": AlertInactive})
+	c.Assert(notified, HasLen, 2)
+	c.Assert(notified[1].State, Equals, AlertResolved)
+	var types []events.Type
+	for _, event := range cluster.GetEventBus().Since(0) {
+		types = append(types, event.Type)
+	}
+	c.Assert(types, DeepEquals, []events.Type{events.AlertFired, events.AlertFired, events.AlertResolved, events.AlertResolved})
+
+	// The rules are reloaded after the leader changes.
+	reloaded := newAlertEngine(cluster)
+	c.Assert(reloaded.load(), IsNil)
+	c.Assert(reloaded.getStatuses(), HasLen, 2)
+	c.Assert(cluster.DeleteAlertRule("offline"), IsNil)
+	c.Assert(errs.ErrAlertRuleNotFound.Equal(cluster.DeleteAlertRule("offline")), IsTrue)
+	reloaded = newAlertEngine(cluster)
+	c.Assert(reloaded.load(), IsNil)
+	c.Assert(reloaded.getStatuses(), HasLen, 1)
+}
+
+func (s *testClusterInfoSuite) TestCheckClusterData(c *C) {
+	_, opt, err := newTestScheduleConfig()
+	c.Assert(err, IsNil)
+	regionStorage, err := core.NewRegionStorage(s.ctx, c.MkDir(), nil)
+	c.Assert(err, IsNil)
+	defer regionStorage.Close()
+	storage := core.NewStorage(kv.NewMemoryKV(), core.WithRegionStorage(regionStorage))
+	storage.SwitchToRegionStorage()
+	cluster := newTestRaftCluster(s.ctx, mockid.NewIDAllocator(), opt, storage, core.NewBasicCluster())
+	cluster.clusterID = 1
+	cluster.meta = &metapb.Cluster{Id: 1}
+	c.Assert(cluster.checkClusterData(), IsNil)
+	for _, store := range newTestStores(3, "2.0.0") {
+		c.Assert(cluster.putStoreLocked(store), IsNil)
+	}
+	// Region i has the peers 10*i+1, 10*i+2 and 10*i+3.
+	for i := uint64(1); i <= 4; i++ {
+		region := newTestRegionMeta(i)
+		for j := uint64(1); j <= 3; j++ {
+			region.Peers = append(region.Peers, &metapb.Peer{Id: 10*i + j, StoreId: j})
+		}
+		c.Assert(cluster.putRegion(core.NewRegionInfo(region, region.Peers[0])), IsNil)
+	}
+	c.Assert(storage.Save("alloc_id", string(typeutil.Uint64ToBytes(43))), IsNil)
+	c.Assert(cluster.checkClusterData(), IsNil)
+
+	// The allocated ID falls behind.
+	c.Assert(storage.Save("alloc_id", string(typeutil.Uint64ToBytes(40))), IsNil)
+	err = cluster.checkClusterData()
+	c.Assert(errs.ErrClusterDataMismatch.Equal(err), IsTrue)
+	c.Assert(err, ErrorMatches, ".*ID 43 is in use but the allocated ID is 40.*")
+	c.Assert(storage.Save("alloc_id", string(typeutil.Uint64ToBytes(1000))), IsNil)
+
+	// The region storage belongs to another cluster.
+	c.Assert(storage.SaveRegionStorageClusterID(1), IsNil)
+	c.Assert(cluster.checkClusterData(), IsNil)
+	c.Assert(storage.SaveRegionStorageClusterID(2), IsNil)
+	err = cluster.checkClusterData()
+	c.Assert(errs.ErrClusterDataMismatch.Equal(err), IsTrue)
+	c.Assert(err, ErrorMatches, ".*the region storage belongs to cluster 2.*")
+	c.Assert(storage.SaveRegionStorageClusterID(1), IsNil)
+
+	// The cluster meta belongs to another cluster.
+	cluster.meta = &metapb.Cluster{Id: 2}
+	err = cluster.checkClusterData()
+	c.Assert(errs.ErrClusterDataMismatch.Equal(err), IsTrue)
+	c.Assert(err, ErrorMatches, ".*the cluster meta belongs to cluster 2.*")
+}
+
+func (s *testClusterInfoSuite) TestCheckStoreRemoval(c *C) {
+	_, opt, err := newTestScheduleConfig()
+	c.Assert(err, IsNil)
+	cluster := newTestRaftCluster(s.ctx, mockid.NewIDAllocator(), opt, core.NewStorage(kv.NewMemoryKV()), core.NewBasicCluster())
+	for _, store := range newTestStores(4, "2.0.0") {
+		c.Assert(cluster.putStoreLocked(store.Clone(core.SetLastHeartbeatTS(time.Now()))), IsNil)
+	}
+	newRegion := func(regionID uint64, roles ...metapb.PeerRole) *core.RegionInfo {
+		meta := &metapb.Region{Id: regionID, StartKey: []byte{byte(regionID)}, EndKey: []byte{byte(regionID + 1)}, RegionEpoch: &metapb.RegionEpoch{}}
+		for i, role := range roles {
+			meta.Peers = append(meta.Peers, &metapb.Peer{Id: regionID*10 + uint64(i), StoreId: uint64(i + 1), Role: role})
+		}
+		return core.NewRegionInfo(meta, meta.Peers[0])
+	}
+	voter, incoming, demoting := metapb.PeerRole_Voter, metapb.PeerRole_IncomingVoter, metapb.PeerRole_DemotingVoter
+	c.Assert(cluster.putRegion(newRegion(1, voter, voter, voter)), IsNil)
+	check, err := cluster.CheckStoreRemoval(1)
+	c.Assert(err, IsNil)
+	c.Assert(check.Safe, IsTrue)
+	c.Assert(check.Blockers, HasLen, 0)
+
+	// The region is in joint consensus on store 3.
+	c.Assert(cluster.putRegion(newRegion(2, voter, voter, incoming, demoting)), IsNil)
+	check, err = cluster.CheckStoreRemoval(3)
+	c.Assert(err, IsNil)
+	c.Assert(check.Safe, IsFalse)
+	c.Assert(check.Total, Equals, 1)
+	c.Assert(check.Blockers[0].RegionID, Equals, uint64(2))
+	c.Assert(check.Blockers[0].Reasons, DeepEquals, []string{RemovalBlockedJointConsensus})
+	c.Assert(errs.ErrStoreRemovalBlocked.Equal(cluster.RemoveStore(3, false)), IsTrue)
+	c.Assert(cluster.GetStore(3).IsUp(), IsTrue)
+
+	// The voter on store 2 is down, so removing store 1 loses the quorum.
+	c.Assert(cluster.putRegion(newRegion(3, voter, voter, voter).Clone(core.WithDownPeers([]*pdpb.PeerStats{
+		{Peer: &metapb.Peer{Id: 31, StoreId: 2}, DownSeconds: 3600},
+	}))), IsNil)
+	check, err = cluster.CheckStoreRemoval(1)
+	c.Assert(err, IsNil)
+	c.Assert(check.Total, Equals, 2)
+	reasons := make(map[uint64][]string)
+	for _, blocker := range check.Blockers {
+		reasons[blocker.RegionID] = blocker.Reasons
+	}
+	c.Assert(reasons[2], DeepEquals, []string{RemovalBlockedJointConsensus})
+	c.Assert(reasons[3], DeepEquals, []string{RemovalBlockedQuorumAtRisk})
+
+	// The physically destroyed store is not blocked.
+	c.Assert(cluster.RemoveStore(3, true), IsNil)
+	c.Assert(cluster.GetStore(3).IsOffline(), IsTrue)
+
+	_, err = cluster.CheckStoreRemoval(5)
+	c.Assert(errs.ErrStoreNotFound.Equal(err), IsTrue)
+}
+
 func (s *testClusterInfoSuite) TestReuseAddress(c *C) {
 	_, opt, err := newTestScheduleConfig()
 	c.Assert(err, IsNil)
@@ -292,6 +681,334 @@ func (s *testClusterInfoSuite) TestReuseAddress(c *C) {
 	}
 }
 
+func (s *testClusterInfoSuite) TestStoreAddress(c *C) {
+	_, opt, err := newTestScheduleConfig()
+	c.Assert(err, IsNil)
+	cluster := newTestRaftCluster(s.ctx, mockid.NewIDAllocator(), opt, core.NewStorage(kv.NewMemoryKV()), core.NewBasicCluster())
+	newStore := func(id uint64, addr string) *metapb.Store {
+		return &metapb.Store{Id: id, Address: addr, Version: "2.0.0", DeployPath: getTestDeployPath(id)}
+	}
+	// The address is persisted in the canonical form.
+	c.Assert(cluster.PutStore(newStore(1, "[2001:DB8:0::1]:20160")), IsNil)
+	c.Assert(cluster.GetStore(1).GetAddress(), Equals, "[2001:db8::1]:20160")
+	// The same address in different forms is duplicated.
+	c.Assert(cluster.PutStore(newStore(2, "[2001:db8::0:1]:20160")), NotNil)
+	c.Assert(cluster.PutStore(newStore(2, "2001:db8::1:20160")), NotNil)
+	c.Assert(cluster.PutStore(newStore(2, "Store-2:20160")), IsNil)
+
+	// A store can't switch between a host name and an IP literal.
+	c.Assert(cluster.PutStore(newStore(1, "store-1:20160")), NotNil)
+	c.Assert(cluster.PutStore(newStore(2, "10.0.0.2:20160")), NotNil)
+	c.Assert(cluster.PutStore(newStore(2, "store-2.local:20160")), IsNil)
+	// It's allowed during the migration.
+	cfg := opt.GetScheduleConfig().Clone()
+	cfg.EnableStoreAddressMigration = true
+	opt.SetScheduleConfig(cfg)
+	c.Assert(cluster.PutStore(newStore(1, "store-1:20160")), IsNil)
+	c.Assert(cluster.GetStore(1).GetAddress(), Equals, "store-1:20160")
+}
+
+func (s *testClusterInfoSuite) TestCheckHealthURL(c *C) {
+	c.Assert(healthCheckURL("http://[::]:2379", []string{"http://[2001:db8::1]:2380"}), Equals, "http://[2001:db8::1]:2379")
+	c.Assert(healthCheckURL("http://[2001:DB8::0:1]:2379", nil), Equals, "http://[2001:db8::1]:2379")
+	c.Assert(healthCheckURL("http://10.0.0.1:2379", []string{"http://10.0.0.2:2380"}), Equals, "http://10.0.0.1:2379")
+}
+
+func (s *testClusterInfoSuite) TestStoreArchive(c *C) {
+	_, opt, err := newTestScheduleConfig()
+	c.Assert(err, IsNil)
+	storage := core.NewStorage(kv.NewMemoryKV())
+	cluster := newTestRaftCluster(s.ctx, mockid.NewIDAllocator(), opt, storage, core.NewBasicCluster())
+	store := newTestStores(1, "2.0.0")[0]
+	c.Assert(cluster.putStoreLocked(store.Clone(core.TombstoneStore())), IsNil)
+	c.Assert(cluster.SetStoreWeight(1, 2, 3), IsNil)
+	_, err = cluster.GetStoreArchive(1)
+	c.Assert(errs.ErrStoreArchiveNotFound.Equal(err), IsTrue)
+
+	// The tombstone store is archived when it is removed.
+	c.Assert(cluster.RemoveTombStoneRecords(), IsNil)
+	c.Assert(cluster.GetStore(1), IsNil)
+	archive, err := cluster.GetStoreArchive(1)
+	c.Assert(err, IsNil)
+	c.Assert(archive.Store.GetAddress(), Equals, store.GetAddress())
+	c.Assert(archive.LeaderWeight, Equals, 2.0)
+	c.Assert(archive.RegionWeight, Equals, 3.0)
+
+	// The store is restored with the same meta and weights.
+	c.Assert(cluster.RestoreStoreArchive(1), IsNil)
+	restored := cluster.GetStore(1)
+	c.Assert(restored.IsUp(), IsTrue)
+	c.Assert(restored.GetAddress(), Equals, store.GetAddress())
+	c.Assert(restored.GetLeaderWeight(), Equals, 2.0)
+	c.Assert(restored.GetRegionWeight(), Equals, 3.0)
+	c.Assert(errs.ErrStoreArchiveNotFound.Equal(cluster.RestoreStoreArchive(2)), IsTrue)
+	_, err = cluster.GetStoreArchive(1)
+	c.Assert(errs.ErrStoreArchiveNotFound.Equal(err), IsTrue)
+
+	// The expired archives are removed.
+	c.Assert(cluster.putStoreLocked(restored.Clone(core.TombstoneStore())), IsNil)
+	c.Assert(cluster.RemoveTombStoneRecords(), IsNil)
+	c.Assert(storage.RemoveExpiredStoreArchives(time.Now().Add(storeArchiveTTL+time.Minute)), IsNil)
+	archive, err = storage.LoadStoreArchive(1, time.Now())
+	c.Assert(err, IsNil)
+	c.Assert(archive, IsNil)
+}
+
+func (s *testClusterInfoSuite) TestStoreFencingToken(c *C) {
+	_, opt, err := newTestScheduleConfig()
+	c.Assert(err, IsNil)
+	storage := core.NewStorage(kv.NewMemoryKV())
+	cluster := newTestRaftCluster(s.ctx, mockid.NewIDAllocator(), opt, storage, core.NewBasicCluster())
+	store := newTestStores(1, "2.0.0")[0].GetMeta()
+
+	// The token is issued when the store registers itself, and it is not
+	// handed out again.
+	token, err := cluster.PutStoreWithFencingToken(store, "")
+	c.Assert(err, IsNil)
+	c.Assert(token, Not(Equals), "")
+	c.Assert(cluster.GetStore(1).GetFencingToken(), Equals, token)
+	newToken, err := cluster.PutStoreWithFencingToken(store, "")
+	c.Assert(err, IsNil)
+	c.Assert(newToken, Equals, "")
+
+	// The token is bound once the store presents it, after which an empty
+	// token is rejected.
+	c.Assert(cluster.GetStore(1).IsFencingTokenBound(), IsFalse)
+	c.Assert(cluster.CheckStoreFencingToken(1, ""), IsNil)
+	newToken, err = cluster.PutStoreWithFencingToken(store, token)
+	c.Assert(err, IsNil)
+	c.Assert(newToken, Equals, "")
+	c.Assert(cluster.GetStore(1).IsFencingTokenBound(), IsTrue)
+	c.Assert(cluster.CheckStoreFencingToken(1, token), IsNil)
+	c.Assert(errs.ErrStoreFencingTokenMissing.Equal(cluster.CheckStoreFencingToken(1, "")), IsTrue)
+	_, err = cluster.PutStoreWithFencingToken(store, "")
+	c.Assert(errs.ErrStoreFencingTokenMissing.Equal(err), IsTrue)
+
+	// A stale node reusing the store ID is rejected.
+	_, err = cluster.PutStoreWithFencingToken(store, "stale")
+	c.Assert(errs.ErrStoreFencingTokenMismatch.Equal(err), IsTrue)
+	c.Assert(errs.ErrStoreFencingTokenMismatch.Equal(cluster.CheckStoreFencingToken(1, "stale")), IsTrue)
+	c.Assert(cluster.CheckStoreFencingToken(2, token), NotNil)
+
+	// Putting the store not by itself is not fenced.
+	c.Assert(cluster.PutStore(store), IsNil)
+	c.Assert(cluster.GetStore(1).GetFencingToken(), Equals, token)
+
+	// The token and the binding are persisted with the store.
+	stores := core.NewStoresInfo()
+	c.Assert(storage.LoadStores(stores.SetStore), IsNil)
+	c.Assert(stores.GetStore(1).GetFencingToken(), Equals, token)
+	c.Assert(stores.GetStore(1).IsFencingTokenBound(), IsTrue)
+
+	// The store binds the token by the heartbeat as well.
+	store2 := newTestStores(2, "2.0.0")[1].GetMeta()
+	token2, err := cluster.PutStoreWithFencingToken(store2, "")
+	c.Assert(err, IsNil)
+	c.Assert(cluster.CheckStoreFencingToken(2, token2), IsNil)
+	c.Assert(errs.ErrStoreFencingTokenMissing.Equal(cluster.CheckStoreFencingToken(2, "")), IsTrue)
+
+	// A new token is issued after the token is reset deliberately.
+	c.Assert(cluster.ResetStoreFencingToken(1), IsNil)
+	c.Assert(cluster.CheckStoreFencingToken(1, ""), IsNil)
+	newToken, err = cluster.PutStoreWithFencingToken(store, "")
+	c.Assert(err, IsNil)
+	c.Assert(newToken, Not(Equals), "")
+	c.Assert(newToken, Not(Equals), token)
+	c.Assert(errs.ErrStoreFencingTokenMismatch.Equal(cluster.CheckStoreFencingToken(1, token)), IsTrue)
+	c.Assert(errs.ErrStoreNotFound.Equal(cluster.ResetStoreFencingToken(3)), IsTrue)
+}
+
+func (s *testClusterInfoSuite) TestStoreJoinTime(c *C) {
+	_, opt, err := newTestScheduleConfig()
+	c.Assert(err, IsNil)
+	storage := core.NewStorage(kv.NewMemoryKV())
+	cluster := newTestRaftCluster(s.ctx, mockid.NewIDAllocator(), opt, storage, core.NewBasicCluster())
+	store := newTestStores(1, "2.0.0")[0].GetMeta()
+
+	// The join time is recorded when the store is registered, and it is kept
+	// when the store is put again.
+	c.Assert(cluster.PutStore(store), IsNil)
+	joinTime := cluster.GetStore(1).GetJoinTime()
+	c.Assert(joinTime.IsZero(), IsFalse)
+	c.Assert(cluster.PutStore(store), IsNil)
+	c.Assert(cluster.GetStore(1).GetJoinTime().Equal(joinTime), IsTrue)
+
+	stores := core.NewStoresInfo()
+	c.Assert(storage.LoadStores(stores.SetStore), IsNil)
+	c.Assert(stores.GetStore(1).GetJoinTime().Equal(joinTime), IsTrue)
+}
+
+func (s *testClusterInfoSuite) TestStoreResourceTags(c *C) {
+	_, opt, err := newTestScheduleConfig()
+	c.Assert(err, IsNil)
+	cfg := opt.GetScheduleConfig().Clone()
+	cfg.StoreLimitProfiles = []config.StoreLimitProfile{{
+		ResourceTags:     map[string]string{"disk": "nvme"},
+		StoreLimitConfig: config.StoreLimitConfig{AddPeer: 60, RemovePeer: 40},
+	}}
+	opt.SetScheduleConfig(cfg)
+	storage := core.NewStorage(kv.NewMemoryKV())
+	cluster := newTestRaftCluster(s.ctx, mockid.NewIDAllocator(), opt, storage, core.NewBasicCluster())
+	for _, store := range newTestStores(12, "2.0.0") {
+		c.Assert(cluster.PutStore(store.GetMeta()), IsNil)
+	}
+
+	c.Assert(cluster.SetStoreResourceTags(1, map[string]string{"cpu": "32c"}), IsNil)
+	c.Assert(cluster.GetStore(1).GetResourceTag("cpu"), Equals, "32c")
+	c.Assert(cluster.SetStoreResourceTags(100, map[string]string{"cpu": "32c"}), NotNil)
+
+	// Stores 1, 10, 11 and 12 match the address.
+	storeIDs, err := cluster.SetResourceTagsByAddress(`:1\d*$`, map[string]string{"disk": "nvme"})
+	c.Assert(err, IsNil)
+	c.Assert(storeIDs, DeepEquals, []uint64{1, 10, 11, 12})
+	c.Assert(cluster.GetStore(1).GetResourceTags(), DeepEquals, map[string]string{"cpu": "32c", "disk": "nvme"})
+	c.Assert(cluster.GetStore(2).GetResourceTags(), HasLen, 0)
+	_, err = cluster.SetResourceTagsByAddress("(", nil)
+	c.Assert(err, NotNil)
+
+	// The store limit of the profile is applied.
+	c.Assert(opt.GetStoreLimit(10).AddPeer, Equals, 60.0)
+	c.Assert(opt.GetStoreLimit(10).RemovePeer, Equals, 40.0)
+	c.Assert(opt.GetStoreLimit(2).AddPeer, Equals, config.DefaultStoreLimit.GetDefaultStoreLimit(storelimit.AddPeer))
+
+	// A tag with an empty value is removed.
+	_, err = cluster.SetResourceTagsByAddress(`:1$`, map[string]string{"disk": ""})
+	c.Assert(err, IsNil)
+	c.Assert(cluster.GetStore(1).GetResourceTags(), DeepEquals, map[string]string{"cpu": "32c"})
+
+	stores := core.NewStoresInfo()
+	c.Assert(storage.LoadStores(stores.SetStore), IsNil)
+	c.Assert(stores.GetStore(1).GetResourceTags(), DeepEquals, map[string]string{"cpu": "32c"})
+	c.Assert(stores.GetStore(10).GetResourceTag("disk"), Equals, "nvme")
+	c.Assert(stores.GetStore(2).GetResourceTags(), HasLen, 0)
+}
+
+func (s *testClusterInfoSuite) TestStorageBreaker(c *C) {
+	_, opt, err := newTestScheduleConfig()
+	c.Assert(err, IsNil)
+	storage := core.NewStorage(kv.NewMemoryKV())
+	cluster := newTestRaftCluster(s.ctx, mockid.NewIDAllocator(), opt, storage, core.NewBasicCluster())
+	b := cluster.storageBreaker
+	threshold := opt.GetPDServerConfig().StorageSlowThreshold.Duration
+	c.Assert(threshold, Equals, time.Second)
+
+	// The storage is degraded after the consecutive slow writes.
+	for i := 0; i < storageSlowWrites-1; i++ {
+		b.observe(threshold)
+	}
+	b.observe(0)
+	b.observe(threshold)
+	c.Assert(b.isDegraded(), IsFalse)
+	for i := 0; i < storageSlowWrites; i++ {
+		b.observe(2 * threshold)
+	}
+	c.Assert(cluster.GetStorageDegradedStatus().Degraded, IsTrue)
+
+	// The region saves are queued, and dropped when the queue is full.
+	region := &metapb.Region{Id: 1}
+	for i := 0; i < storageAsyncRegionLimit; i++ {
+		dropped, err := b.saveRegion(storage, region)
+		c.Assert(err, IsNil)
+		c.Assert(dropped, IsFalse)
+	}
+	dropped, err := b.saveRegion(storage, region)
+	c.Assert(err, IsNil)
+	c.Assert(dropped, IsTrue)
+	c.Assert(b.allowStorePersist(), IsFalse)
+	status := cluster.GetStorageDegradedStatus()
+	c.Assert(status.PendingRegions, Equals, storageAsyncRegionLimit)
+	c.Assert(status.DroppedRegions, Equals, uint64(1))
+	c.Assert(status.SuppressedStores, Equals, uint64(1))
+	ok, err := storage.LoadRegion(1, &metapb.Region{})
+	c.Assert(err, IsNil)
+	c.Assert(ok, IsFalse)
+
+	// The storage recovers after the consecutive fast writes.
+	for i := 0; i < storageFastWrites; i++ {
+		b.observe(0)
+	}
+	c.Assert(b.isDegraded(), IsFalse)
+	c.Assert(b.allowStorePersist(), IsTrue)
+	dropped, err = b.saveRegion(storage, region)
+	c.Assert(err, IsNil)
+	c.Assert(dropped, IsFalse)
+	ok, err = storage.LoadRegion(1, &metapb.Region{})
+	c.Assert(err, IsNil)
+	c.Assert(ok, IsTrue)
+
+	// 0 disables the breaker.
+	for i := 0; i < storageSlowWrites; i++ {
+		b.observe(2 * threshold)
+	}
+	c.Assert(b.isDegraded(), IsTrue)
+	pdServerCfg := opt.GetPDServerConfig().Clone()
+	pdServerCfg.StorageSlowThreshold.Duration = 0
+	opt.SetPDServerConfig(pdServerCfg)
+	b.observe(2 * threshold)
+	c.Assert(b.isDegraded(), IsFalse)
+}
+
+func (s *testClusterInfoSuite) TestSuspectStore(c *C) {
+	_, opt, err := newTestScheduleConfig()
+	c.Assert(err, IsNil)
+	cluster := newTestRaftCluster(s.ctx, mockid.NewIDAllocator(), opt, core.NewStorage(kv.NewMemoryKV()), core.NewBasicCluster())
+	stores := newTestStores(2, "2.0.0")
+	lastHeartbeat := time.Now().Add(-time.Hour)
+	c.Assert(cluster.putStoreLocked(stores[0].Clone(core.SetLastHeartbeatTS(lastHeartbeat))), IsNil)
+	c.Assert(cluster.putStoreLocked(stores[1].Clone(core.SetLastHeartbeatTS(time.Now()))), IsNil)
+
+	// The grace period is disabled by default.
+	cluster.checkSuspectStores()
+	c.Assert(cluster.GetStore(1).IsSuspect(), IsFalse)
+
+	cfg := opt.GetScheduleConfig().Clone()
+	cfg.MaxStoreDownTime = typeutil.NewDuration(30 * time.Minute)
+	cfg.StoreSuspectGracePeriod = typeutil.NewDuration(time.Hour)
+	opt.SetScheduleConfig(cfg)
+	cluster.checkSuspectStores()
+	c.Assert(cluster.GetStore(1).IsSuspect(), IsTrue)
+	c.Assert(cluster.GetStore(1).GetSuspectUntil().Equal(lastHeartbeat.Add(90*time.Minute)), IsTrue)
+	c.Assert(cluster.GetStore(2).IsSuspect(), IsFalse)
+
+	// The store comes back.
+	c.Assert(cluster.putStoreLocked(cluster.GetStore(1).Clone(core.SetLastHeartbeatTS(time.Now()))), IsNil)
+	cluster.checkSuspectStores()
+	c.Assert(cluster.GetStore(1).IsSuspect(), IsFalse)
+	c.Assert(cluster.GetStore(1).GetSuspectUntil().IsZero(), IsTrue)
+}
+
+func (s *testClusterInfoSuite) TestRegionConsistency(c *C) {
+	_, opt, err := newTestScheduleConfig()
+	c.Assert(err, IsNil)
+	cluster := newTestRaftCluster(s.ctx, mockid.NewIDAllocator(), opt, core.NewStorage(kv.NewMemoryKV()), core.NewBasicCluster())
+	for _, region := range newTestRegions(3, 1) {
+		cluster.core.PutRegion(region)
+	}
+
+	// The regions cover [00, 03), so the both ends are the gaps.
+	now := time.Now()
+	cluster.regionConsistencyChecker.tick(now)
+	inconsistencies := cluster.GetRegionInconsistencies()
+	c.Assert(inconsistencies, DeepEquals, []*core.RegionInconsistency{
+		{Type: core.RegionGap, StartKey: "", EndKey: "00", Regions: []uint64{0}},
+		{Type: core.RegionGap, StartKey: "03", EndKey: "", Regions: []uint64{2}},
+	})
+	// The regions are not checked again within the interval.
+	cluster.core.RemoveRegion(cluster.GetRegion(1))
+	cluster.regionConsistencyChecker.tick(now.Add(time.Minute))
+	c.Assert(cluster.GetRegionInconsistencies(), HasLen, 2)
+	c.Assert(cluster.CheckRegionConsistency(), HasLen, 3)
+
+	// Only the overlapped regions are removed by the repair.
+	cfg := opt.GetScheduleConfig().Clone()
+	cfg.EnableRegionCacheRepair = true
+	opt.SetScheduleConfig(cfg)
+	c.Assert(cluster.CheckRegionConsistency(), HasLen, 3)
+	c.Assert(cluster.GetRegionCount(), Equals, 2)
+	cluster.regionConsistencyChecker.repair([]*core.RegionInconsistency{{Type: core.RegionOverlap, Regions: []uint64{0, 2}}})
+	c.Assert(cluster.GetRegionCount(), Equals, 0)
+}
+
 func getTestDeployPath(storeID uint64) string {
 	return fmt.Sprintf("test/store%d", storeID)
 }
@@ -353,6 +1070,67 @@ func (s *testClusterInfoSuite) TestDeleteStoreUpdatesClusterVersion(c *C) {
 	c.Assert(cluster.GetClusterVersion(), Equals, "5.0.0")
 }
 
+func (s *testClusterInfoSuite) TestUpgradeGuardrail(c *C) {
+	_, opt, err := newTestScheduleConfig()
+	c.Assert(err, IsNil)
+	storage := core.NewStorage(kv.NewMemoryKV())
+	cluster := newTestRaftCluster(s.ctx, mockid.NewIDAllocator(), opt, storage, core.NewBasicCluster())
+	for _, store := range newTestStores(3, "5.0.1") {
+		c.Assert(cluster.PutStore(store.GetMeta()), IsNil)
+	}
+	c.Assert(cluster.GetClusterVersion(), Equals, "5.0.1")
+	oldStore := newTestStores(4, "5.0.0")[3].GetMeta()
+
+	// The stores with the lower versions are not rejected by default.
+	c.Assert(cluster.PutStore(oldStore), IsNil)
+	c.Assert(cluster.RemoveStore(4, true), IsNil)
+	c.Assert(cluster.buryStore(4), IsNil)
+	cfg := opt.GetScheduleConfig().Clone()
+	cfg.EnableUpgradeGuardrail = true
+	opt.SetScheduleConfig(cfg)
+	oldStore.Id = 5
+	c.Assert(errs.ErrStoreVersionDowngrade.Equal(cluster.PutStore(oldStore)), IsTrue)
+	cfg.EnableUpgradeGuardrail = false
+	opt.SetScheduleConfig(cfg)
+	c.Assert(cluster.GetUpgradeStatus().GuardrailEnabled, IsFalse)
+
+	c.Assert(errs.ErrUpgradeTargetVersion.Equal(cluster.BeginUpgrade("5.0.0")), IsTrue)
+	c.Assert(errs.ErrUpgradeNotInProgress.Equal(cluster.FinishUpgrade(false)), IsTrue)
+	c.Assert(cluster.BeginUpgrade("5.1.0"), IsNil)
+	c.Assert(errs.ErrUpgradeInProgress.Equal(cluster.BeginUpgrade("5.2.0")), IsTrue)
+	status := cluster.GetUpgradeStatus()
+	c.Assert(status.GuardrailEnabled, IsTrue)
+	c.Assert(status.Window.TargetVersion, Equals, "5.1.0")
+	c.Assert(status.PendingStores, HasLen, 3)
+	c.Assert(errs.ErrStoreVersionDowngrade.Equal(cluster.PutStore(oldStore)), IsTrue)
+
+	// The window survives the leader changes.
+	reloaded := newTestRaftCluster(s.ctx, mockid.NewIDAllocator(), opt, storage, core.NewBasicCluster())
+	c.Assert(reloaded.loadUpgradeWindowLocked(), IsNil)
+	c.Assert(reloaded.upgradeWindow.TargetVersion, Equals, "5.1.0")
+
+	// Upgrade 2 stores, and the last one is pending.
+	for _, store := range newTestStores(2, "5.1.0") {
+		c.Assert(cluster.PutStore(store.GetMeta()), IsNil)
+	}
+	status = cluster.GetUpgradeStatus()
+	c.Assert(status.PendingStores, HasLen, 1)
+	c.Assert(status.PendingStores[0].StoreID, Equals, uint64(3))
+	c.Assert(errs.ErrUpgradeNotFinished.Equal(cluster.FinishUpgrade(false)), IsTrue)
+	c.Assert(cluster.PutStore(newTestStores(3, "5.1.0")[2].GetMeta()), IsNil)
+	c.Assert(cluster.GetClusterVersion(), Equals, "5.1.0")
+	c.Assert(cluster.FinishUpgrade(false), IsNil)
+	c.Assert(cluster.GetUpgradeStatus().Window, IsNil)
+	reloaded = newTestRaftCluster(s.ctx, mockid.NewIDAllocator(), opt, storage, core.NewBasicCluster())
+	c.Assert(reloaded.loadUpgradeWindowLocked(), IsNil)
+	c.Assert(reloaded.upgradeWindow, IsNil)
+
+	// The upgrade can be finished by force.
+	c.Assert(cluster.BeginUpgrade("5.2.0"), IsNil)
+	c.Assert(errs.ErrUpgradeNotFinished.Equal(cluster.FinishUpgrade(false)), IsTrue)
+	c.Assert(cluster.FinishUpgrade(true), IsNil)
+}
+
 func (s *testClusterInfoSuite) TestRegionHeartbeatHotStat(c *C) {
 	_, opt, err := newTestScheduleConfig()
 	c.Assert(err, IsNil)
@@ -609,6 +1387,7 @@ func (s *testClusterInfoSuite) TestRegionHeartbeat(c *C) {
 			core.WithNewRegionID(regions[n-1].GetID()+1),
 		)
 		c.Assert(cluster.processRegionHeartbeat(overlapRegion), IsNil)
+		cluster.flushRegionDeletions()
 		region = &metapb.Region{}
 		ok, err = storage.LoadRegion(regions[n-1].GetID(), region)
 		c.Assert(ok, IsFalse)
@@ -623,6 +1402,66 @@ func (s *testClusterInfoSuite) TestRegionHeartbeat(c *C) {
 	}
 }
 
+func (s *testClusterInfoSuite) TestRegionDeleter(c *C) {
+	storage := core.NewStorage(kv.NewMemoryKV())
+	regions := newTestRegions(10, 3)
+	for _, region := range regions {
+		c.Assert(storage.SaveRegion(region.GetMeta()), IsNil)
+	}
+	alive := func(regionID uint64) bool { return regionID == 5 }
+
+	d := newRegionDeleter()
+	d.add(regions[:4])
+	// The duplicated deletions are coalesced.
+	d.add(regions[2:6])
+	c.Assert(d.pending, HasLen, 6)
+	d.cancel(3)
+	c.Assert(d.pending, HasLen, 5)
+	c.Assert(d.flush(storage, alive), IsTrue)
+	c.Assert(d.flush(storage, alive), IsFalse)
+	c.Assert(d.pending, HasLen, 0)
+	for _, region := range regions {
+		ok, err := storage.LoadRegion(region.GetID(), &metapb.Region{})
+		c.Assert(err, IsNil)
+		// Only the canceled, alive and not queued regions are left.
+		c.Assert(ok, Equals, region.GetID() == 3 || region.GetID() >= 5)
+	}
+
+	// The failed deletions are retried later.
+	d.add(regions[6:8])
+	batch := d.takeBatch(time.Now())
+	c.Assert(batch, HasLen, 2)
+	now := time.Now()
+	d.retry(batch, now)
+	c.Assert(d.takeBatch(now), HasLen, 0)
+	c.Assert(d.takeBatch(now.Add(regionDeleteRetryInterval)), HasLen, 2)
+	// Give up after too many retries.
+	for i := 0; i < regionDeleteRetryLimit; i++ {
+		d.retry(batch, now)
+		batch = d.takeBatch(now.Add(time.Hour))
+	}
+	c.Assert(d.pending, HasLen, 0)
+
+	// A heartbeat saving the region during the flush waits for the deletion,
+	// so the region is not deleted after it is saved.
+	d.add(regions[8:9])
+	saved := make(chan error, 1)
+	c.Assert(d.flush(storage, func(regionID uint64) bool {
+		go d.save(regionID, func() { saved <- storage.SaveRegion(regions[8].GetMeta()) })
+		select {
+		case err := <-saved:
+			c.Error("the region is saved during the flush")
+			saved <- err
+		case <-time.After(50 * time.Millisecond):
+		}
+		return false
+	}), IsTrue)
+	c.Assert(<-saved, IsNil)
+	ok, err := storage.LoadRegion(regions[8].GetID(), &metapb.Region{})
+	c.Assert(err, IsNil)
+	c.Assert(ok, IsTrue)
+}
+
 func (s *testClusterInfoSuite) TestRegionFlowChanged(c *C) {
 	_, opt, err := newTestScheduleConfig()
 	c.Assert(err, IsNil)
@@ -776,6 +1615,452 @@ func (s *testClusterInfoSuite) TestRegionSplitAndMerge(c *C) {
 	}
 }
 
+func (s *testClusterInfoSuite) TestAskBatchSplit(c *C) {
+	_, opt, err := newTestScheduleConfig()
+	c.Assert(err, IsNil)
+	storage := core.NewStorage(kv.NewMemoryKV())
+	cluster := newTestRaftCluster(s.ctx, mockid.NewIDAllocator(), opt, storage, core.NewBasicCluster())
+	cluster.ruleManager = placement.NewRuleManager(storage, cluster)
+	cluster.coordinator = newCoordinator(s.ctx, cluster, nil)
+	region := newTestRegions(1, 3)[0]
+	c.Assert(cluster.putRegion(region), IsNil)
+
+	// The IDs of the new regions and peers are contiguous.
+	resp, err := cluster.HandleAskBatchSplit(&pdpb.AskBatchSplitRequest{Region: region.GetMeta(), SplitCount: 2})
+	c.Assert(err, IsNil)
+	c.Assert(resp.GetIds(), HasLen, 2)
+	id := resp.GetIds()[0].GetNewRegionId()
+	for _, split := range resp.GetIds() {
+		c.Assert(split.GetNewRegionId(), Equals, id)
+		c.Assert(split.GetNewPeerIds(), DeepEquals, []uint64{id + 1, id + 2, id + 3})
+		id += 4
+	}
+	next, err := cluster.id.Alloc()
+	c.Assert(err, IsNil)
+	c.Assert(next, Equals, id)
+
+	resp, err = cluster.HandleAskBatchSplit(&pdpb.AskBatchSplitRequest{Region: region.GetMeta()})
+	c.Assert(err, IsNil)
+	c.Assert(resp.GetIds(), HasLen, 0)
+}
+
+func (s *testClusterInfoSuite) TestRegionHistory(c *C) {
+	_, opt, err := newTestScheduleConfig()
+	c.Assert(err, IsNil)
+	cluster := newTestRaftCluster(s.ctx, mockid.NewIDAllocator(), opt, core.NewStorage(kv.NewMemoryKV()), core.NewBasicCluster())
+	heartbeat := func(id uint64, start, end string, version uint64) {
+		peer := &metapb.Peer{Id: id + 100, StoreId: 1}
+		region := core.NewRegionInfo(&metapb.Region{
+			Id:          id,
+			StartKey:    []byte(start),
+			EndKey:      []byte(end),
+			Peers:       []*metapb.Peer{peer},
+			RegionEpoch: &metapb.RegionEpoch{ConfVer: 1, Version: version},
+		}, peer)
+		c.Assert(cluster.processRegionHeartbeat(region), IsNil)
+	}
+	eventTypes := func(history *RegionHistory) []string {
+		var types []string
+		for _, e := range history.Events {
+			types = append(types, fmt.Sprintf("%s %d", e.Type, e.RegionID))
+		}
+		return types
+	}
+
+	heartbeat(1, "", "", 1)
+	c.Assert(cluster.GetRegionHistory(1), IsNil)
+	// The parent reports the split first.
+	heartbeat(1, "m", "", 2)
+	heartbeat(2, "", "m", 2)
+	// The child reports the split first.
+	heartbeat(3, "", "f", 3)
+	heartbeat(2, "f", "m", 3)
+	// Region 3 is merged into region 2.
+	heartbeat(2, "", "m", 4)
+
+	history := cluster.GetRegionHistory(3)
+	c.Assert(eventTypes(history), DeepEquals, []string{"split-from 2", "merged-into 2"})
+	c.Assert(history.Ancestors, DeepEquals, []uint64{2, 1})
+	c.Assert(history.Successor, Equals, uint64(2))
+	history = cluster.GetRegionHistory(2)
+	c.Assert(eventTypes(history), DeepEquals, []string{"split-from 1", "split-into 3", "merged-from 3"})
+	c.Assert(history.Ancestors, DeepEquals, []uint64{1})
+	c.Assert(history.Successor, Equals, uint64(0))
+	c.Assert(eventTypes(cluster.GetRegionHistory(1)), DeepEquals, []string{"split-into 2"})
+	c.Assert(cluster.GetRegionHistory(4), IsNil)
+
+	cluster.regionHistory.gc(time.Now().Add(regionHistoryKeepTime + time.Minute))
+	c.Assert(cluster.GetRegionHistory(3), IsNil)
+	c.Assert(cluster.regionHistory.shrinks, HasLen, 0)
+}
+
+func (s *testClusterInfoSuite) TestEvictColdCaches(c *C) {
+	_, opt, err := newTestScheduleConfig()
+	c.Assert(err, IsNil)
+	storage := core.NewStorage(kv.NewMemoryKV())
+	cluster := newTestRaftCluster(s.ctx, mockid.NewIDAllocator(), opt, storage, core.NewBasicCluster())
+	cluster.ruleManager = placement.NewRuleManager(storage, cluster)
+	c.Assert(cluster.ruleManager.Initialize(opt.GetMaxReplicas(), opt.GetLocationLabels()), IsNil)
+	for _, store := range newTestStores(3, "2.0.0") {
+		c.Assert(cluster.putStoreLocked(store), IsNil)
+	}
+	heartbeat := func(id uint64, start, end string, version uint64) *core.RegionInfo {
+		peer := &metapb.Peer{Id: id + 100, StoreId: 1}
+		region := core.NewRegionInfo(&metapb.Region{
+			Id:          id,
+			StartKey:    []byte(start),
+			EndKey:      []byte(end),
+			Peers:       []*metapb.Peer{peer},
+			RegionEpoch: &metapb.RegionEpoch{ConfVer: 1, Version: version},
+		}, peer)
+		c.Assert(cluster.processRegionHeartbeat(region), IsNil)
+		return region
+	}
+
+	heartbeat(1, "", "", 1)
+	heartbeat(1, "m", "", 2)
+	region := heartbeat(2, "", "m", 2)
+	cluster.FitRegion(region)
+	usage := cluster.GetMemoryUsage()
+	c.Assert(usage.Regions, Greater, int64(0))
+	c.Assert(usage.Stores, Greater, int64(0))
+	c.Assert(usage.RegionHistory, Greater, int64(0))
+	c.Assert(usage.RegionFits, Greater, int64(0))
+	c.Assert(usage.Total, Equals, usage.Regions+usage.Stores+usage.HotCache+usage.RegionHistory+usage.RegionFits)
+
+	// The caches used recently are kept.
+	cluster.EvictColdCaches(time.Hour)
+	c.Assert(cluster.GetMemoryUsage(), DeepEquals, usage)
+	cluster.EvictColdCaches(-time.Second)
+	c.Assert(cluster.GetRegionHistory(2), IsNil)
+	usage = cluster.GetMemoryUsage()
+	c.Assert(usage.RegionHistory, Equals, int64(0))
+	c.Assert(usage.RegionFits, Equals, int64(0))
+	c.Assert(usage.Regions, Greater, int64(0))
+}
+
+func (s *testClusterInfoSuite) TestPrioritizedRegionLoading(c *C) {
+	_, opt, err := newTestScheduleConfig()
+	c.Assert(err, IsNil)
+	pdServerCfg := opt.GetPDServerConfig().Clone()
+	pdServerCfg.EnablePrioritizedRegionLoading = true
+	opt.SetPDServerConfig(pdServerCfg)
+	rc := opt.GetReplicationConfig().Clone()
+	rc.SystemRanges = []string{"03:05"}
+	opt.SetReplicationConfig(rc)
+	storage := core.NewStorage(kv.NewMemoryKV())
+	c.Assert(storage.SaveMeta(&metapb.Cluster{MaxPeerCount: 3}), IsNil)
+	regions := newTestRegions(10, 3)
+	for _, region := range regions {
+		c.Assert(storage.SaveRegion(region.GetMeta()), IsNil)
+	}
+	c.Assert(storage.Save(warmUpRegionsPath, "[3,4,100]"), IsNil)
+	cluster := newTestRaftCluster(s.ctx, mockid.NewIDAllocator(), opt, storage, core.NewBasicCluster())
+
+	// Only the warm-up regions are loaded before the cluster starts.
+	raftCluster, err := cluster.LoadClusterInfo()
+	c.Assert(err, IsNil)
+	c.Assert(raftCluster, NotNil)
+	c.Assert(cluster.GetRegionCount(), Equals, 2)
+	status := cluster.GetPrepareStatus()
+	c.Assert(status.RegionLoadState, Equals, RegionLoadBackground)
+	c.Assert(status.LoadedRegionCount, Equals, 2)
+	c.Assert(status.Prepared, IsFalse)
+
+	// The region reported by the heartbeat is not overwritten.
+	cluster.core.PutRegion(regions[5].Clone(core.SetApproximateSize(100)))
+	cluster.quit = make(chan struct{})
+	cluster.wg.Add(1)
+	cluster.loadRestRegions()
+	c.Assert(cluster.GetRegionCount(), Equals, 10)
+	c.Assert(cluster.GetRegion(5).GetApproximateSize(), Equals, int64(100))
+	status = cluster.GetPrepareStatus()
+	c.Assert(status.RegionLoadState, Equals, RegionLoadDone)
+	c.Assert(status.LoadedRegionCount, Equals, 9)
+
+	// The regions in the system ranges are persisted to be loaded first.
+	cluster.persistWarmUpRegions(time.Now())
+	ids, err := cluster.loadWarmUpRegions()
+	c.Assert(err, IsNil)
+	c.Assert(ids, DeepEquals, []uint64{3, 4})
+}
+
+func (s *testClusterInfoSuite) TestRoutingHint(c *C) {
+	_, opt, err := newTestScheduleConfig()
+	c.Assert(err, IsNil)
+	cluster := newTestRaftCluster(s.ctx, mockid.NewIDAllocator(), opt, core.NewStorage(kv.NewMemoryKV()), core.NewBasicCluster())
+	heartbeat := func(id uint64, start, end string, version uint64) {
+		peer := &metapb.Peer{Id: id + 100, StoreId: 1}
+		region := core.NewRegionInfo(&metapb.Region{
+			Id:          id,
+			StartKey:    []byte(start),
+			EndKey:      []byte(end),
+			Peers:       []*metapb.Peer{peer},
+			RegionEpoch: &metapb.RegionEpoch{ConfVer: 1, Version: version},
+		}, peer)
+		c.Assert(cluster.processRegionHeartbeat(region), IsNil)
+	}
+	hintIDs := func(hint *RoutingHint) []uint64 {
+		var ids []uint64
+		for _, r := range hint.Regions {
+			ids = append(ids, r.ID)
+		}
+		return ids
+	}
+
+	heartbeat(1, "", "x", 1)
+	heartbeat(9, "x", "", 1)
+	c.Assert(cluster.GetRoutingHint(cluster.GetRegion(1)), IsNil)
+	// Region 1 is split into 3 regions.
+	heartbeat(1, "m", "x", 2)
+	heartbeat(2, "", "m", 2)
+	heartbeat(3, "", "f", 3)
+	heartbeat(2, "f", "m", 3)
+
+	hint := cluster.GetRoutingHint(cluster.GetRegion(2))
+	c.Assert(hint, NotNil)
+	c.Assert(hintIDs(hint), DeepEquals, []uint64{3, 2, 1})
+	c.Assert(hint.Regions[0].StartKey, HasLen, 0)
+	c.Assert(hint.Regions[2].EndKey, DeepEquals, []byte("x"))
+	c.Assert(hint.Watermark, Equals, uint64(3))
+	c.Assert(hintIDs(cluster.GetRoutingHint(cluster.GetRegion(3))), DeepEquals, []uint64{3, 2, 1})
+	// The region not changed recently is not hinted.
+	c.Assert(cluster.GetRoutingHint(cluster.GetRegion(9)), IsNil)
+
+	for _, events := range cluster.regionHistory.regions {
+		for _, e := range events {
+			e.Time = e.Time.Add(-2 * routingHintWindow)
+		}
+	}
+	c.Assert(cluster.GetRoutingHint(cluster.GetRegion(2)), IsNil)
+}
+
+func (s *testClusterInfoSuite) TestMetricsCollectionInterval(c *C) {
+	base := 10 * time.Second
+	c.Assert(nextMetricsCollectionInterval(base, time.Second, 1000), Equals, base)
+	// It backs off when the last round takes long.
+	c.Assert(nextMetricsCollectionInterval(base, 5*time.Second, 1000), Equals, 20*time.Second)
+	// It backs off when the cluster has many regions.
+	c.Assert(nextMetricsCollectionInterval(base, time.Second, 2*metricsCollectionRegionStep), Equals, 30*time.Second)
+	c.Assert(nextMetricsCollectionInterval(base, time.Minute, 1000), Equals, maxMetricsCollectionInterval)
+}
+
+func (s *testClusterInfoSuite) TestSystemRangeRules(c *C) {
+	_, opt, err := newTestScheduleConfig()
+	c.Assert(err, IsNil)
+	storage := core.NewStorage(kv.NewMemoryKV())
+	cluster := newTestRaftCluster(s.ctx, mockid.NewIDAllocator(), opt, storage, core.NewBasicCluster())
+	cluster.ruleManager = placement.NewRuleManager(storage, cluster)
+	cluster.regionLabeler, err = labeler.NewRegionLabeler(storage)
+	c.Assert(err, IsNil)
+	for _, store := range newTestStores(3, "5.0.0") {
+		store = store.Clone(core.SetStoreLabels([]*metapb.StoreLabel{{Key: "zone", Value: "meta"}}))
+		c.Assert(cluster.PutStore(store.GetMeta()), IsNil)
+	}
+
+	// Nothing is generated if the isolation is disabled.
+	c.Assert(cluster.syncSystemRangeRules(), IsNil)
+	c.Assert(cluster.regionLabeler.GetAllLabelRules(), HasLen, 0)
+
+	cfg := opt.GetReplicationConfig().Clone()
+	cfg.EnableSystemRangeIsolation = true
+	cfg.SystemRanges = typeutil.StringSlice{"6D:6E", "7a:"}
+	cfg.SystemRangeStoreLabels = typeutil.StringSlice{"zone=meta"}
+	opt.SetReplicationConfig(cfg)
+	// The label rules are generated without the placement rules.
+	c.Assert(cluster.syncSystemRangeRules(), IsNil)
+	c.Assert(cluster.regionLabeler.GetAllLabelRules(), HasLen, 2)
+	region := core.NewRegionInfo(&metapb.Region{Id: 1, StartKey: []byte("m1"), EndKey: []byte("m2")}, nil)
+	c.Assert(cluster.regionLabeler.GetRegionLabel(region, labeler.SystemRangeLabel), Equals, "true")
+
+	opt.SetPlacementRuleEnabled(true)
+	c.Assert(cluster.ruleManager.Initialize(opt.GetMaxReplicas(), opt.GetLocationLabels()), IsNil)
+	c.Assert(cluster.syncSystemRangeRules(), IsNil)
+	bundle := cluster.ruleManager.GetGroupBundle(SystemRangeRuleGroup)
+	c.Assert(bundle.Override, IsTrue)
+	c.Assert(bundle.Rules, HasLen, 2)
+	c.Assert(bundle.Rules[0].StartKeyHex, Equals, "6d")
+	c.Assert(bundle.Rules[0].Count, Equals, int(opt.GetMaxReplicas()))
+	c.Assert(bundle.Rules[0].LabelConstraints, DeepEquals, []placement.LabelConstraint{{Key: "zone", Op: placement.In, Values: []string{"meta"}}})
+	c.Assert(bundle.Rules[1].EndKeyHex, Equals, "")
+	rules := cluster.ruleManager.GetRulesForApplyRegion(region)
+	c.Assert(rules, HasLen, 1)
+	c.Assert(rules[0].GroupID, Equals, SystemRangeRuleGroup)
+	// Nothing is written if the rules are not changed.
+	c.Assert(sameGroupBundle(cluster.ruleManager.GetGroupBundle(SystemRangeRuleGroup), bundle), IsTrue)
+
+	// The stale rules are removed.
+	cfg = opt.GetReplicationConfig().Clone()
+	cfg.SystemRanges = typeutil.StringSlice{"6d:6e"}
+	opt.SetReplicationConfig(cfg)
+	c.Assert(cluster.syncSystemRangeRules(), IsNil)
+	c.Assert(cluster.ruleManager.GetGroupBundle(SystemRangeRuleGroup).Rules, HasLen, 1)
+	c.Assert(cluster.regionLabeler.GetAllLabelRules(), HasLen, 1)
+
+	cfg = opt.GetReplicationConfig().Clone()
+	cfg.EnableSystemRangeIsolation = false
+	opt.SetReplicationConfig(cfg)
+	c.Assert(cluster.syncSystemRangeRules(), IsNil)
+	c.Assert(cluster.ruleManager.GetRuleGroup(SystemRangeRuleGroup), IsNil)
+	c.Assert(cluster.ruleManager.GetGroupBundle(SystemRangeRuleGroup).Rules, HasLen, 0)
+	c.Assert(cluster.regionLabeler.GetAllLabelRules(), HasLen, 0)
+}
+
+type mockCompactionPressureFetcher map[uint64]uint64
+
+func (f mockCompactionPressureFetcher) Fetch(ctx context.Context, store *core.StoreInfo) (uint64, error) {
+	bytes, ok := f[store.GetID()]
+	if !ok {
+		return 0, errors.New("unreachable")
+	}
+	return bytes, nil
+}
+
+func (s *testClusterInfoSuite) TestCompactionPressure(c *C) {
+	_, opt, err := newTestScheduleConfig()
+	c.Assert(err, IsNil)
+	cluster := newTestRaftCluster(s.ctx, mockid.NewIDAllocator(), opt, core.NewStorage(kv.NewMemoryKV()), core.NewBasicCluster())
+	for _, store := range newTestStores(3, "5.0.0") {
+		c.Assert(cluster.PutStore(store.GetMeta()), IsNil)
+		c.Assert(cluster.HandleStoreHeartbeat(&pdpb.StoreStats{StoreId: store.GetID()}), IsNil)
+	}
+	fetcher := mockCompactionPressureFetcher{1: 200, 2: 50}
+	cluster.compactionFetcher = fetcher
+
+	// Nothing is fetched if the threshold is 0.
+	cluster.syncCompactionPressure(s.ctx)
+	c.Assert(cluster.GetStore(1).GetPendingCompactionBytes(), Equals, uint64(0))
+
+	cfg := opt.GetScheduleConfig().Clone()
+	cfg.CompactionPressureThreshold = 100
+	opt.SetScheduleConfig(cfg)
+	cluster.syncCompactionPressure(s.ctx)
+	c.Assert(cluster.GetStore(1).IsCompactionPressured(100), IsTrue)
+	c.Assert(cluster.GetStore(2).GetPendingCompactionBytes(), Equals, uint64(50))
+	c.Assert(cluster.GetStore(3).GetPendingCompactionBytes(), Equals, uint64(0))
+	// The bytes are kept after the heartbeats.
+	c.Assert(cluster.HandleStoreHeartbeat(&pdpb.StoreStats{StoreId: 1}), IsNil)
+	c.Assert(cluster.GetStore(1).GetPendingCompactionBytes(), Equals, uint64(200))
+
+	// The bytes are cleared if they fail to fetch.
+	delete(fetcher, 1)
+	cluster.syncCompactionPressure(s.ctx)
+	c.Assert(cluster.GetStore(1).GetPendingCompactionBytes(), Equals, uint64(0))
+	c.Assert(cluster.GetStore(2).GetPendingCompactionBytes(), Equals, uint64(50))
+	cfg.CompactionPressureThreshold = 0
+	opt.SetScheduleConfig(cfg)
+	cluster.syncCompactionPressure(s.ctx)
+	c.Assert(cluster.GetStore(2).GetPendingCompactionBytes(), Equals, uint64(0))
+}
+
+func (s *testClusterInfoSuite) TestRegionWatchlist(c *C) {
+	_, opt, err := newTestScheduleConfig()
+	c.Assert(err, IsNil)
+	storage := core.NewStorage(kv.NewMemoryKV())
+	cluster := newTestRaftCluster(s.ctx, mockid.NewIDAllocator(), opt, storage, core.NewBasicCluster())
+	cluster.ruleManager = placement.NewRuleManager(storage, cluster)
+	c.Assert(cluster.ruleManager.Initialize(opt.GetMaxReplicas(), opt.GetLocationLabels()), IsNil)
+	cluster.regionStats = statistics.NewRegionStatistics(cluster.GetOpts(), cluster.ruleManager, cluster.core)
+	for _, store := range newTestStores(3, "5.0.0") {
+		c.Assert(cluster.PutStore(store.GetMeta()), IsNil)
+		c.Assert(cluster.HandleStoreHeartbeat(&pdpb.StoreStats{StoreId: store.GetID()}), IsNil)
+	}
+	peers := []*metapb.Peer{{Id: 4, StoreId: 1}, {Id: 5, StoreId: 2}, {Id: 6, StoreId: 3}}
+	region := core.NewRegionInfo(&metapb.Region{
+		Id:          1,
+		Peers:       peers,
+		RegionEpoch: &metapb.RegionEpoch{ConfVer: 1, Version: 1},
+	}, peers[0])
+	c.Assert(cluster.processRegionHeartbeat(region), IsNil)
+	downRegion := region.Clone(
+		core.WithDownPeers([]*pdpb.PeerStats{{Peer: peers[1], DownSeconds: 3600}, {Peer: peers[2], DownSeconds: 3600}}),
+		core.WithPendingPeers([]*metapb.Peer{peers[2]}),
+	)
+	c.Assert(cluster.processRegionHeartbeat(downRegion), IsNil)
+
+	// Nothing is watched if the threshold is 0.
+	cfg := opt.GetScheduleConfig().Clone()
+	cfg.TroubleRegionThreshold = typeutil.NewDuration(0)
+	opt.SetScheduleConfig(cfg)
+	now := time.Now()
+	cluster.regionWatchlist.tick(now)
+	cluster.regionWatchlist.tick(now.Add(time.Hour))
+	c.Assert(cluster.GetRegionWatchlist(), HasLen, 0)
+
+	cfg.TroubleRegionThreshold = typeutil.NewDuration(time.Minute)
+	opt.SetScheduleConfig(cfg)
+	cluster.regionWatchlist.tick(now)
+	c.Assert(cluster.GetRegionWatchlist(), HasLen, 0)
+	cluster.regionWatchlist.tick(now.Add(30 * time.Second))
+	c.Assert(cluster.GetRegionWatchlist(), HasLen, 0)
+	cluster.regionWatchlist.tick(now.Add(time.Minute))
+	watchlist := cluster.GetRegionWatchlist()
+	c.Assert(watchlist, HasLen, 1)
+	c.Assert(watchlist[0].RegionID, Equals, uint64(1))
+	c.Assert(watchlist[0].Since.Equal(now), IsTrue)
+	c.Assert(watchlist[0].Troubles, DeepEquals, []RegionTrouble{TroubleDownPeer, TroublePendingPeer})
+	c.Assert(watchlist[0].DownPeers, Equals, 2)
+	c.Assert(watchlist[0].QuorumAtRisk, IsTrue)
+	c.Assert(watchlist[0].Priority, Equals, 10+1+quorumAtRiskWeight)
+	c.Assert(watchlist[0].Diagnosis, Equals, DiagnosisNoOperator)
+
+	// The watched regions are kept after the leader changes.
+	w := newRegionWatchlist(cluster)
+	c.Assert(w.load(), IsNil)
+	loaded := w.list()
+	c.Assert(loaded, HasLen, 1)
+	c.Assert(watchedRegionEqual(loaded[0], watchlist[0]), IsTrue)
+
+	// The region is removed once it recovers.
+	c.Assert(cluster.processRegionHeartbeat(region.Clone(core.WithIncConfVer())), IsNil)
+	cluster.regionWatchlist.tick(now.Add(2 * time.Minute))
+	c.Assert(cluster.GetRegionWatchlist(), HasLen, 0)
+	w = newRegionWatchlist(cluster)
+	c.Assert(w.load(), IsNil)
+	c.Assert(w.list(), HasLen, 0)
+}
+
+func (s *testClusterInfoSuite) TestRegionWatchlistAttempts(c *C) {
+	r := &WatchedRegion{RegionID: 1}
+	op := operator.NewOperator("test", "test", 1, &metapb.RegionEpoch{}, operator.OpRegion, operator.RemovePeer{FromStore: 2})
+	recordAttempt(r, op)
+	c.Assert(r.Attempts, HasLen, 1)
+	c.Assert(diagnose(r, nil), Equals, DiagnosisOperatorRunning)
+	c.Assert(op.Start(), IsTrue)
+	c.Assert(op.Cancel(), IsTrue)
+	recordAttempt(r, op)
+	c.Assert(r.Attempts, HasLen, 1)
+	c.Assert(r.Attempts[0].Reason, Equals, "operator "+r.Attempts[0].Status)
+	c.Assert(diagnose(r, nil), Equals, DiagnosisOperatorFailed)
+
+	for i := 0; i < maxWatchedRegionAttempts+1; i++ {
+		op := operator.NewOperator(fmt.Sprintf("test-%d", i), "test", 1, &metapb.RegionEpoch{}, operator.OpRegion, operator.RemovePeer{FromStore: 2})
+		recordAttempt(r, op)
+	}
+	c.Assert(r.Attempts, HasLen, maxWatchedRegionAttempts)
+	c.Assert(r.Attempts[maxWatchedRegionAttempts-1].Desc, Equals, fmt.Sprintf("test-%d", maxWatchedRegionAttempts))
+}
+
+func (s *testClusterInfoSuite) TestParsePendingCompactionBytes(c *C) {
+	metrics := `# HELP tikv_engine_pending_compaction_bytes Pending compaction bytes
+# TYPE tikv_engine_pending_compaction_bytes gauge
+tikv_engine_pending_compaction_bytes{cf="default",db="kv"} 1024
+tikv_engine_pending_compaction_bytes{cf="write",db="kv"} 2048
+tikv_engine_pending_compaction_bytes{cf="default",db="raft"} 4096
+# HELP tikv_store_size_bytes Size of storage
+# TYPE tikv_store_size_bytes gauge
+tikv_store_size_bytes{type="capacity"} 1e+12
+`
+	bytes, err := parsePendingCompactionBytes(strings.NewReader(metrics))
+	c.Assert(err, IsNil)
+	c.Assert(bytes, Equals, uint64(3072))
+	bytes, err = parsePendingCompactionBytes(strings.NewReader("tikv_store_size_bytes{type=\"capacity\"} 1e+12\n"))
+	c.Assert(err, IsNil)
+	c.Assert(bytes, Equals, uint64(0))
+	_, err = parsePendingCompactionBytes(strings.NewReader("bad metrics"))
+	c.Assert(err, NotNil)
+}
+
 func (s *testClusterInfoSuite) TestOfflineAndMerge(c *C) {
 	_, opt, err := newTestScheduleConfig()
 	c.Assert(err, IsNil)
@@ -789,7 +2074,7 @@ func (s *testClusterInfoSuite) TestOfflineAndMerge(c *C) {
 			panic(err)
 		}
 	}
-	cluster.regionStats = statistics.NewRegionStatistics(cluster.GetOpts(), cluster.ruleManager)
+	cluster.regionStats = statistics.NewRegionStatistics(cluster.GetOpts(), cluster.ruleManager, cluster.core)
 
 	// Put 3 stores.
 	for _, store := range newTestStores(4, "5.0.0") {
@@ -1095,6 +2380,63 @@ func newTestRaftCluster(ctx context.Context, id id.Allocator, opt *config.Persis
 	return rc
 }
 
+func (s *testClusterInfoSuite) TestDiagnose(c *C) {
+	_, opt, err := newTestScheduleConfig()
+	c.Assert(err, IsNil)
+	storage := core.NewStorage(kv.NewMemoryKV())
+	cluster := newTestRaftCluster(s.ctx, mockid.NewIDAllocator(), opt, storage, core.NewBasicCluster())
+	cluster.ruleManager = placement.NewRuleManager(storage, cluster)
+	c.Assert(cluster.ruleManager.Initialize(opt.GetMaxReplicas(), opt.GetLocationLabels()), IsNil)
+	cluster.regionStats = statistics.NewRegionStatistics(cluster.GetOpts(), cluster.ruleManager, cluster.core)
+	checks := func() map[string]string {
+		res := make(map[string]string)
+		for _, finding := range cluster.Diagnose().Findings {
+			res[finding.Check] = finding.Severity
+		}
+		return res
+	}
+	c.Assert(checks(), DeepEquals, map[string]string{DoctorCheckReplicaIsolation: DoctorSeverityCritical})
+
+	rc := opt.GetReplicationConfig().Clone()
+	rc.LocationLabels = []string{"zone", "host"}
+	opt.SetReplicationConfig(rc)
+	for i, store := range newTestStores(4, "5.0.0") {
+		meta := store.GetMeta()
+		meta.Labels = []*metapb.StoreLabel{{Key: "zone", Value: fmt.Sprintf("z%d", i%2)}}
+		if i < 3 {
+			meta.Labels = append(meta.Labels, &metapb.StoreLabel{Key: "host", Value: fmt.Sprintf("h%d", i)})
+		}
+		c.Assert(cluster.PutStore(meta), IsNil)
+		c.Assert(cluster.HandleStoreHeartbeat(&pdpb.StoreStats{StoreId: store.GetID(), Capacity: 8 << 40, Available: 4 << 40}), IsNil)
+	}
+	peers := []*metapb.Peer{{Id: 5, StoreId: 1}, {Id: 6, StoreId: 2}, {Id: 7, StoreId: 3}}
+	region := core.NewRegionInfo(&metapb.Region{
+		Id:          1,
+		Peers:       peers,
+		RegionEpoch: &metapb.RegionEpoch{ConfVer: 1, Version: 1},
+	}, peers[0], core.WithDownPeers([]*pdpb.PeerStats{{Peer: peers[2], DownSeconds: 3600}}))
+	c.Assert(cluster.processRegionHeartbeat(region), IsNil)
+
+	c.Assert(checks(), DeepEquals, map[string]string{
+		DoctorCheckLocationLabels:   DoctorSeverityWarning,
+		DoctorCheckReplicaIsolation: DoctorSeverityCritical,
+		DoctorCheckStoreLimit:       DoctorSeverityWarning,
+		DoctorCheckDownPeerBacklog:  DoctorSeverityWarning,
+	})
+
+	// The problems are fixed except that the down peer is not repaired.
+	rc.LocationLabels = []string{"zone"}
+	rc.MaxReplicas = 2
+	opt.SetReplicationConfig(rc)
+	for storeID := uint64(1); storeID <= 4; storeID++ {
+		opt.SetStoreLimit(storeID, storelimit.AddPeer, 30)
+	}
+	cfg := opt.GetScheduleConfig().Clone()
+	cfg.ReplicaScheduleLimit = 0
+	opt.SetScheduleConfig(cfg)
+	c.Assert(checks(), DeepEquals, map[string]string{DoctorCheckDownPeerBacklog: DoctorSeverityCritical})
+}
+
 // Create n stores (0..n).
 func newTestStores(n uint64, version string) []*core.StoreInfo {
 	stores := make([]*core.StoreInfo, 0, n)
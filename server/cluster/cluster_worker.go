@@ -105,26 +105,31 @@ func (c *RaftCluster) HandleAskBatchSplit(request *pdpb.AskBatchSplitRequest) (*
 	splitIDs := make([]*pdpb.SplitID, 0, splitCount)
 	recordRegions := make([]uint64, 0, splitCount+1)
 
-	for i := 0; i < int(splitCount); i++ {
-		newRegionID, err := c.id.Alloc()
+	// Allocate the IDs of all new regions and peers at once, so they are
+	// contiguous, and each region is followed by its peers.
+	peerCount := uint64(len(reqRegion.GetPeers()))
+	if splitCount > 0 {
+		id, err := c.id.AllocN(uint64(splitCount) * (peerCount + 1))
 		if err != nil {
-			return nil, errs.ErrSchedulerNotFound.FastGenByArgs()
+			return nil, err
 		}
-
-		peerIDs := make([]uint64, len(request.Region.Peers))
-		for i := 0; i < len(peerIDs); i++ {
-			if peerIDs[i], err = c.id.Alloc(); err != nil {
-				return nil, err
+		for i := 0; i < int(splitCount); i++ {
+			newRegionID := id
+			id++
+			peerIDs := make([]uint64, peerCount)
+			for j := range peerIDs {
+				peerIDs[j] = id
+				id++
 			}
-		}
 
-		recordRegions = append(recordRegions, newRegionID)
-		splitIDs = append(splitIDs, &pdpb.SplitID{
-			NewRegionId: newRegionID,
-			NewPeerIds:  peerIDs,
-		})
+			recordRegions = append(recordRegions, newRegionID)
+			splitIDs = append(splitIDs, &pdpb.SplitID{
+				NewRegionId: newRegionID,
+				NewPeerIds:  peerIDs,
+			})
 
-		log.Info("alloc ids for region split", zap.Uint64("region-id", newRegionID), zap.Uint64s("peer-ids", peerIDs))
+			log.Info("alloc ids for region split", zap.Uint64("region-id", newRegionID), zap.Uint64s("peer-ids", peerIDs))
+		}
 	}
 
 	recordRegions = append(recordRegions, reqRegion.GetId())
@@ -136,7 +141,7 @@ func (c *RaftCluster) HandleAskBatchSplit(request *pdpb.AskBatchSplitRequest) (*
 	// If region splits during the scheduling process, regions with abnormal
 	// status may be left, and these regions need to be checked with higher
 	// priority.
-	c.AddSuspectRegions(recordRegions...)
+	c.AddPrioritySuspectRegions(SuspectSplit, recordRegions...)
 
 	resp := &pdpb.AskBatchSplitResponse{Ids: splitIDs}
 
@@ -0,0 +1,202 @@
+// Copyright 2021 TiKV Project Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cluster
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/pingcap/errors"
+	"github.com/pingcap/log"
+	"github.com/prometheus/common/expfmt"
+	"github.com/tikv/pd/pkg/logutil"
+	"github.com/tikv/pd/pkg/netutil"
+	"github.com/tikv/pd/server/core"
+	"github.com/tikv/pd/server/schedule/filter"
+	"github.com/tikv/pd/server/storeconfig"
+	"go.uber.org/zap"
+)
+
+const (
+	compactionPressureSyncInterval = 15 * time.Second
+	compactionPressureFetchTimeout = 3 * time.Second
+	// compactionPressureSyncConcurrency is the maximum number of stores
+	// fetched at the same time.
+	compactionPressureSyncConcurrency = 16
+	// pendingCompactionBytesMetric is the metric of TiKV about the pending
+	// compaction bytes of each column family.
+	pendingCompactionBytesMetric = "tikv_engine_pending_compaction_bytes"
+	// kvEngineLabelValue is the value of the "db" label of the KV engine.
+	// The raft engine is not counted.
+	kvEngineLabelValue = "kv"
+)
+
+// compactionPressureFetcher fetches the pending compaction bytes of a store.
+type compactionPressureFetcher interface {
+	Fetch(ctx context.Context, store *core.StoreInfo) (uint64, error)
+}
+
+// httpCompactionPressureFetcher fetches the pending compaction bytes from the
+// `/metrics` API of the TiKV status server.
+type httpCompactionPressureFetcher struct {
+	client *http.Client
+	scheme string
+}
+
+func newHTTPCompactionPressureFetcher(client *http.Client, scheme string) compactionPressureFetcher {
+	return &httpCompactionPressureFetcher{client: client, scheme: scheme}
+}
+
+func (f *httpCompactionPressureFetcher) Fetch(ctx context.Context, store *core.StoreInfo) (uint64, error) {
+	if store.GetMeta().GetStatusAddress() == "" {
+		return 0, errors.Errorf("store %d has no status address", store.GetID())
+	}
+	statusAddress := netutil.ResolveLoopBackAddr(store.GetMeta().GetStatusAddress(), store.GetAddress())
+	url := fmt.Sprintf("%s://%s/metrics", f.scheme, statusAddress)
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return 0, errors.WithStack(err)
+	}
+	resp, err := f.client.Do(req)
+	if err != nil {
+		return 0, errors.WithStack(err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(resp.Body)
+		return 0, errors.Errorf("failed to fetch metrics from %s, status: %d, body: %s", url, resp.StatusCode, body)
+	}
+	return parsePendingCompactionBytes(resp.Body)
+}
+
+// parsePendingCompactionBytes sums the pending compaction bytes of all column
+// families of the KV engine in the metrics of the text format.
+func parsePendingCompactionBytes(r io.Reader) (uint64, error) {
+	var parser expfmt.TextParser
+	families, err := parser.TextToMetricFamilies(r)
+	if err != nil {
+		return 0, errors.WithStack(err)
+	}
+	family, ok := families[pendingCompactionBytesMetric]
+	if !ok {
+		return 0, nil
+	}
+	var total float64
+	for _, m := range family.GetMetric() {
+		isKV := false
+		for _, l := range m.GetLabel() {
+			if l.GetName() == "db" && l.GetValue() == kvEngineLabelValue {
+				isKV = true
+				break
+			}
+		}
+		if isKV {
+			total += m.GetGauge().GetValue()
+		}
+	}
+	return uint64(total), nil
+}
+
+// runCompactionPressureSync periodically fetches the pending compaction bytes
+// of the stores, so that the stores under compaction pressure are avoided when
+// adding peers.
+func (c *RaftCluster) runCompactionPressureSync() {
+	defer logutil.LogPanic()
+	defer c.wg.Done()
+
+	ticker := time.NewTicker(compactionPressureSyncInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-c.quit:
+			log.Info("compaction pressure sync has been stopped")
+			return
+		case <-ticker.C:
+			ctx, cancel := context.WithCancel(c.ctx)
+			go func() {
+				select {
+				case <-c.quit:
+					cancel()
+				case <-ctx.Done():
+				}
+			}()
+			c.syncCompactionPressure(ctx)
+			cancel()
+		}
+	}
+}
+
+// syncCompactionPressure fetches the pending compaction bytes of the TiKV
+// stores which are up. Nothing is fetched if the threshold is 0, and the
+// fetched bytes are cleared. The bytes of a store are cleared as well if it
+// fails to fetch, so a stale pressure does not last.
+func (c *RaftCluster) syncCompactionPressure(ctx context.Context) {
+	stores := c.GetStores()
+	pressures := make(map[uint64]uint64, len(stores))
+	if c.opt.GetCompactionPressureThreshold() > 0 {
+		var (
+			wg sync.WaitGroup
+			mu sync.Mutex
+		)
+		sem := make(chan struct{}, compactionPressureSyncConcurrency)
+		for _, store := range stores {
+			if !store.IsUp() || store.IsDisconnected() || storeconfig.GetEngine(store) != filter.EngineTiKV {
+				continue
+			}
+			wg.Add(1)
+			sem <- struct{}{}
+			go func(store *core.StoreInfo) {
+				defer func() {
+					<-sem
+					wg.Done()
+				}()
+				fetchCtx, cancel := context.WithTimeout(ctx, compactionPressureFetchTimeout)
+				bytes, err := c.compactionFetcher.Fetch(fetchCtx, store)
+				cancel()
+				if err != nil {
+					log.Debug("failed to fetch the pending compaction bytes", zap.Uint64("store-id", store.GetID()), zap.Error(err))
+					return
+				}
+				mu.Lock()
+				pressures[store.GetID()] = bytes
+				mu.Unlock()
+			}(store)
+		}
+		wg.Wait()
+	}
+	c.updateCompactionPressure(pressures)
+}
+
+// updateCompactionPressure updates the pending compaction bytes of the stores.
+// The stores not in the map are set to 0.
+func (c *RaftCluster) updateCompactionPressure(pressures map[uint64]uint64) {
+	c.Lock()
+	defer c.Unlock()
+	threshold := c.opt.GetCompactionPressureThreshold()
+	for _, store := range c.GetStores() {
+		bytes := pressures[store.GetID()]
+		if store.GetPendingCompactionBytes() == bytes {
+			continue
+		}
+		if pressured := bytes >= threshold && threshold > 0; pressured != store.IsCompactionPressured(threshold) {
+			log.Info("store compaction pressure is changed", zap.Uint64("store-id", store.GetID()),
+				zap.Bool("pressured", pressured), zap.Uint64("pending-compaction-bytes", bytes))
+		}
+		c.core.PutStore(store.Clone(core.SetPendingCompactionBytes(bytes)))
+	}
+}
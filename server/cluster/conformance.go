@@ -0,0 +1,441 @@
+// Copyright 2021 TiKV Project Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cluster
+
+import (
+	"encoding/json"
+	"fmt"
+	"path"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/pingcap/kvproto/pkg/metapb"
+	"github.com/pingcap/log"
+	"github.com/tikv/pd/pkg/errs"
+	"github.com/tikv/pd/pkg/logutil"
+	"github.com/tikv/pd/pkg/typeutil"
+	"github.com/tikv/pd/server/core"
+	"github.com/tikv/pd/server/core/storelimit"
+	"github.com/tikv/pd/server/schedule/placement"
+	"go.uber.org/zap"
+)
+
+const (
+	conformanceReportPath = "conformance_report"
+	// maxConformanceReports is the number of the reports kept in the storage,
+	// which is a week of the daily reports.
+	maxConformanceReports = 7
+	// maxConformanceOffenders is the number of the worst offenders in a report.
+	maxConformanceOffenders = 20
+	conformanceScanLimit    = 1024
+)
+
+// ConformanceViolation is the class of the violations of the placement
+// policies.
+type ConformanceViolation string
+
+const (
+	// ViolationMissPeer means a rule has fewer peers than its count.
+	ViolationMissPeer ConformanceViolation = "miss-peer"
+	// ViolationExtraPeer means a peer is not matched by any rule.
+	ViolationExtraPeer ConformanceViolation = "extra-peer"
+	// ViolationWrongRole means a peer has a different role from its rule.
+	ViolationWrongRole ConformanceViolation = "wrong-role"
+	// ViolationIsolation means the peers of a rule are not isolated at the
+	// isolation level.
+	ViolationIsolation ConformanceViolation = "isolation-level"
+)
+
+var conformanceViolations = []ConformanceViolation{ViolationMissPeer, ViolationExtraPeer, ViolationWrongRole, ViolationIsolation}
+
+// ConformanceClassStat is the statistics of a violation class.
+type ConformanceClassStat struct {
+	Class   ConformanceViolation `json:"class"`
+	Regions int                  `json:"regions"`
+	Peers   int                  `json:"peers"`
+	// EstimatedFixDuration is how long it takes to fix the peers with the
+	// store limits of all up stores.
+	EstimatedFixDuration typeutil.Duration `json:"estimated_fix_duration"`
+}
+
+// ConformanceOffender is a region violating the placement policies.
+type ConformanceOffender struct {
+	RegionID   uint64                       `json:"region_id"`
+	StartKey   string                       `json:"start_key"`
+	EndKey     string                       `json:"end_key"`
+	Violations map[ConformanceViolation]int `json:"violations"`
+	// Peers is the number of the peers to be fixed, which ranks the offenders.
+	Peers int `json:"peers"`
+}
+
+// ConformanceReport is the result of scanning all regions against the
+// placement rules and the label policies.
+type ConformanceReport struct {
+	ID                  uint64                  `json:"id"`
+	StartTime           time.Time               `json:"start_time"`
+	FinishTime          time.Time               `json:"finish_time"`
+	RegionCount         int                     `json:"region_count"`
+	ViolatedRegionCount int                     `json:"violated_region_count"`
+	Classes             []*ConformanceClassStat `json:"classes"`
+	WorstOffenders      []*ConformanceOffender  `json:"worst_offenders"`
+	// EstimatedFixDuration is the sum of the estimations of all classes.
+	EstimatedFixDuration typeutil.Duration `json:"estimated_fix_duration"`
+}
+
+// ConformanceClassDiff is the change of a violation class between two reports.
+type ConformanceClassDiff struct {
+	Class        ConformanceViolation `json:"class"`
+	RegionsDelta int                  `json:"regions_delta"`
+	PeersDelta   int                  `json:"peers_delta"`
+}
+
+// ConformanceReportDiff is the change from a report to a later one.
+type ConformanceReportDiff struct {
+	From                     uint64                  `json:"from"`
+	To                       uint64                  `json:"to"`
+	RegionCountDelta         int                     `json:"region_count_delta"`
+	ViolatedRegionCountDelta int                     `json:"violated_region_count_delta"`
+	Classes                  []*ConformanceClassDiff `json:"classes"`
+	// NewOffenders are the worst offenders only in the later report.
+	NewOffenders []uint64 `json:"new_offenders"`
+	// FixedOffenders are the worst offenders only in the earlier report.
+	FixedOffenders []uint64 `json:"fixed_offenders"`
+}
+
+// DiffConformanceReports returns the change from the report from to the
+// report to.
+func DiffConformanceReports(from, to *ConformanceReport) *ConformanceReportDiff {
+	diff := &ConformanceReportDiff{
+		From:                     from.ID,
+		To:                       to.ID,
+		RegionCountDelta:         to.RegionCount - from.RegionCount,
+		ViolatedRegionCountDelta: to.ViolatedRegionCount - from.ViolatedRegionCount,
+	}
+	classes := make(map[ConformanceViolation]*ConformanceClassDiff)
+	for _, class := range conformanceViolations {
+		classes[class] = &ConformanceClassDiff{Class: class}
+		diff.Classes = append(diff.Classes, classes[class])
+	}
+	for _, s := range from.Classes {
+		if d := classes[s.Class]; d != nil {
+			d.RegionsDelta -= s.Regions
+			d.PeersDelta -= s.Peers
+		}
+	}
+	for _, s := range to.Classes {
+		if d := classes[s.Class]; d != nil {
+			d.RegionsDelta += s.Regions
+			d.PeersDelta += s.Peers
+		}
+	}
+	offenders := make(map[uint64]int)
+	for _, o := range from.WorstOffenders {
+		offenders[o.RegionID]--
+	}
+	for _, o := range to.WorstOffenders {
+		offenders[o.RegionID]++
+	}
+	for id, n := range offenders {
+		if n > 0 {
+			diff.NewOffenders = append(diff.NewOffenders, id)
+		} else if n < 0 {
+			diff.FixedOffenders = append(diff.FixedOffenders, id)
+		}
+	}
+	sort.Slice(diff.NewOffenders, func(i, j int) bool { return diff.NewOffenders[i] < diff.NewOffenders[j] })
+	sort.Slice(diff.FixedOffenders, func(i, j int) bool { return diff.FixedOffenders[i] < diff.FixedOffenders[j] })
+	return diff
+}
+
+// conformanceChecker generates the conformance reports periodically. The
+// reports are persisted so that they can be compared across the runs and the
+// leader changes.
+type conformanceChecker struct {
+	sync.Mutex
+	cluster *RaftCluster
+	running bool
+	// lastStart is the start time of the latest report.
+	lastStart time.Time
+}
+
+func newConformanceChecker(cluster *RaftCluster) *conformanceChecker {
+	return &conformanceChecker{cluster: cluster}
+}
+
+func (cc *conformanceChecker) load() error {
+	ids, err := cc.listReportIDs()
+	if err != nil || len(ids) == 0 {
+		return err
+	}
+	report, err := cc.getReport(ids[len(ids)-1])
+	if err != nil {
+		return err
+	}
+	cc.Lock()
+	defer cc.Unlock()
+	cc.lastStart = report.StartTime
+	return nil
+}
+
+// tick generates a report in the background if the interval has passed since
+// the latest one. It waits for the region cache to be prepared, otherwise the
+// regions not reported yet would be missed.
+func (cc *conformanceChecker) tick(now time.Time) {
+	interval := cc.cluster.opt.GetConformanceReportInterval()
+	cc.Lock()
+	skip := interval == 0 || cc.running || now.Sub(cc.lastStart) < interval
+	cc.Unlock()
+	if skip || !cc.cluster.isPrepared() {
+		return
+	}
+	go func() {
+		defer logutil.LogPanic()
+		if _, err := cc.run(now); err != nil && !errs.ErrConformanceReportRunning.Equal(err) {
+			log.Warn("failed to generate the conformance report", errs.ZapError(err))
+		}
+	}()
+}
+
+// run scans all regions and persists the report.
+func (cc *conformanceChecker) run(now time.Time) (*ConformanceReport, error) {
+	cc.Lock()
+	if cc.running {
+		cc.Unlock()
+		return nil, errs.ErrConformanceReportRunning.FastGenByArgs()
+	}
+	cc.running = true
+	cc.Unlock()
+	defer func() {
+		cc.Lock()
+		cc.running = false
+		cc.Unlock()
+	}()
+
+	report := cc.generate(now)
+	if err := cc.saveReport(report); err != nil {
+		return nil, err
+	}
+	cc.Lock()
+	cc.lastStart = now
+	cc.Unlock()
+	log.Info("conformance report is generated",
+		zap.Uint64("id", report.ID),
+		zap.Int("regions", report.RegionCount),
+		zap.Int("violated-regions", report.ViolatedRegionCount),
+		zap.Duration("estimated-fix-duration", report.EstimatedFixDuration.Duration))
+	return report, nil
+}
+
+func (cc *conformanceChecker) generate(now time.Time) *ConformanceReport {
+	c := cc.cluster
+	report := &ConformanceReport{ID: uint64(now.Unix()), StartTime: now}
+	stats := make(map[ConformanceViolation]*ConformanceClassStat)
+	for _, class := range conformanceViolations {
+		stats[class] = &ConformanceClassStat{Class: class}
+		report.Classes = append(report.Classes, stats[class])
+	}
+	var offenders []*ConformanceOffender
+	var startKey []byte
+	for {
+		regions := c.ScanRegions(startKey, nil, conformanceScanLimit)
+		for _, region := range regions {
+			report.RegionCount++
+			violations := cc.checkRegion(region)
+			if len(violations) == 0 {
+				continue
+			}
+			report.ViolatedRegionCount++
+			o := &ConformanceOffender{
+				RegionID:   region.GetID(),
+				StartKey:   core.HexRegionKeyStr(region.GetStartKey()),
+				EndKey:     core.HexRegionKeyStr(region.GetEndKey()),
+				Violations: violations,
+			}
+			for class, peers := range violations {
+				stats[class].Regions++
+				stats[class].Peers += peers
+				o.Peers += peers
+			}
+			offenders = append(offenders, o)
+		}
+		if len(regions) < conformanceScanLimit {
+			break
+		}
+		startKey = regions[len(regions)-1].GetEndKey()
+		if len(startKey) == 0 {
+			break
+		}
+	}
+	sort.Slice(offenders, func(i, j int) bool {
+		if offenders[i].Peers != offenders[j].Peers {
+			return offenders[i].Peers > offenders[j].Peers
+		}
+		return offenders[i].RegionID < offenders[j].RegionID
+	})
+	if len(offenders) > maxConformanceOffenders {
+		offenders = offenders[:maxConformanceOffenders]
+	}
+	report.WorstOffenders = offenders
+
+	// The missing peers and the isolation need new peers, while the extra
+	// peers and the role changes are handled with the removals.
+	addRate, removeRate := cc.totalStoreLimits()
+	for _, s := range report.Classes {
+		rate := removeRate
+		if s.Class == ViolationMissPeer || s.Class == ViolationIsolation {
+			rate = addRate
+		}
+		s.EstimatedFixDuration = estimateDuration(s.Peers, rate)
+		report.EstimatedFixDuration.Duration += s.EstimatedFixDuration.Duration
+	}
+	report.FinishTime = time.Now()
+	return report
+}
+
+// checkRegion returns the number of the peers to be fixed of each violation
+// class. The default rule is built from the replication config if the
+// placement rules are disabled.
+func (cc *conformanceChecker) checkRegion(region *core.RegionInfo) map[ConformanceViolation]int {
+	c := cc.cluster
+	var fit *placement.RegionFit
+	if c.opt.IsPlacementRulesEnabled() {
+		fit = c.ruleManager.FitRegion(c, region)
+	} else {
+		fit = placement.FitRegion(c, region, []*placement.Rule{{
+			GroupID:        "pd",
+			ID:             "default",
+			Role:           placement.Voter,
+			Count:          c.opt.GetMaxReplicas(),
+			LocationLabels: c.opt.GetLocationLabels(),
+			IsolationLevel: c.opt.GetIsolationLevel(),
+		}})
+	}
+	violations := make(map[ConformanceViolation]int)
+	for _, rf := range fit.RuleFits {
+		if n := rf.Rule.Count - len(rf.Peers); n > 0 {
+			violations[ViolationMissPeer] += n
+		}
+		if n := len(rf.PeersWithDifferentRole); n > 0 {
+			violations[ViolationWrongRole] += n
+		}
+		if n := cc.countNotIsolated(rf.Peers, rf.Rule.IsolationLevel); n > 0 {
+			violations[ViolationIsolation] += n
+		}
+	}
+	if n := len(fit.OrphanPeers); n > 0 {
+		violations[ViolationExtraPeer] += n
+	}
+	return violations
+}
+
+// countNotIsolated returns the number of the peers sharing the label value at
+// the isolation level with another peer, or without the label.
+func (cc *conformanceChecker) countNotIsolated(peers []*metapb.Peer, isolationLevel string) int {
+	if isolationLevel == "" {
+		return 0
+	}
+	var n int
+	seen := make(map[string]struct{})
+	for _, p := range peers {
+		var value string
+		if store := cc.cluster.GetStore(p.GetStoreId()); store != nil {
+			value = store.GetLabelValue(isolationLevel)
+		}
+		if _, ok := seen[value]; ok || value == "" {
+			n++
+			continue
+		}
+		seen[value] = struct{}{}
+	}
+	return n
+}
+
+func (cc *conformanceChecker) totalStoreLimits() (add, remove float64) {
+	c := cc.cluster
+	for _, s := range c.GetStores() {
+		if !s.IsUp() {
+			continue
+		}
+		add += c.opt.GetStoreLimitByType(s.GetID(), storelimit.AddPeer)
+		remove += c.opt.GetStoreLimitByType(s.GetID(), storelimit.RemovePeer)
+	}
+	return add, remove
+}
+
+func conformanceReportKey(id uint64) string {
+	return fmt.Sprintf("%020d", id)
+}
+
+func (cc *conformanceChecker) saveReport(report *ConformanceReport) error {
+	storage := cc.cluster.storage
+	if err := storage.SaveJSON(conformanceReportPath, conformanceReportKey(report.ID), report); err != nil {
+		return err
+	}
+	ids, err := cc.listReportIDs()
+	if err != nil {
+		return err
+	}
+	for len(ids) > maxConformanceReports {
+		if err := storage.Remove(path.Join(conformanceReportPath, conformanceReportKey(ids[0]))); err != nil {
+			return err
+		}
+		ids = ids[1:]
+	}
+	return nil
+}
+
+// listReportIDs returns the IDs of the persisted reports, the oldest first.
+func (cc *conformanceChecker) listReportIDs() ([]uint64, error) {
+	var ids []uint64
+	err := cc.cluster.storage.LoadRangeByPrefix(conformanceReportPath+"/", func(k, v string) {
+		if id, err := strconv.ParseUint(k, 10, 64); err == nil {
+			ids = append(ids, id)
+		}
+	})
+	return ids, err
+}
+
+func (cc *conformanceChecker) getReport(id uint64) (*ConformanceReport, error) {
+	v, err := cc.cluster.storage.Load(path.Join(conformanceReportPath, conformanceReportKey(id)))
+	if err != nil {
+		return nil, err
+	}
+	if v == "" {
+		return nil, errs.ErrConformanceReportNotFound.FastGenByArgs(id)
+	}
+	report := &ConformanceReport{}
+	if err := json.Unmarshal([]byte(v), report); err != nil {
+		return nil, errs.ErrJSONUnmarshal.Wrap(err).FastGenWithCause()
+	}
+	return report, nil
+}
+
+// GenerateConformanceReport scans all regions against the placement rules and
+// the label policies, and persists the report.
+func (c *RaftCluster) GenerateConformanceReport() (*ConformanceReport, error) {
+	return c.conformanceChecker.run(time.Now())
+}
+
+// GetConformanceReportIDs returns the IDs of the persisted conformance
+// reports, the oldest first.
+func (c *RaftCluster) GetConformanceReportIDs() ([]uint64, error) {
+	return c.conformanceChecker.listReportIDs()
+}
+
+// GetConformanceReport returns the conformance report with the ID.
+func (c *RaftCluster) GetConformanceReport(id uint64) (*ConformanceReport, error) {
+	return c.conformanceChecker.getReport(id)
+}
@@ -0,0 +1,144 @@
+// Copyright 2021 TiKV Project Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cluster
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	. "github.com/pingcap/check"
+	"github.com/pingcap/kvproto/pkg/metapb"
+	"github.com/tikv/pd/pkg/errs"
+	"github.com/tikv/pd/pkg/mock/mockid"
+	"github.com/tikv/pd/server/core"
+	"github.com/tikv/pd/server/core/storelimit"
+	"github.com/tikv/pd/server/kv"
+	"github.com/tikv/pd/server/schedule/placement"
+)
+
+var _ = Suite(&testConformanceSuite{})
+
+type testConformanceSuite struct {
+	ctx    context.Context
+	cancel context.CancelFunc
+}
+
+func (s *testConformanceSuite) SetUpTest(c *C) {
+	s.ctx, s.cancel = context.WithCancel(context.Background())
+}
+
+func (s *testConformanceSuite) TearDownTest(c *C) {
+	s.cancel()
+}
+
+func newConformanceTestRegion(id uint64, start, end string, storeIDs ...uint64) *core.RegionInfo {
+	meta := &metapb.Region{Id: id, StartKey: []byte(start), EndKey: []byte(end), RegionEpoch: &metapb.RegionEpoch{}}
+	for _, storeID := range storeIDs {
+		meta.Peers = append(meta.Peers, &metapb.Peer{Id: id*10 + storeID, StoreId: storeID})
+	}
+	return core.NewRegionInfo(meta, meta.Peers[0])
+}
+
+func (s *testConformanceSuite) TestConformanceReport(c *C) {
+	_, opt, err := newTestScheduleConfig()
+	c.Assert(err, IsNil)
+	opt.SetAllStoresLimit(storelimit.AddPeer, 15)
+	opt.SetAllStoresLimit(storelimit.RemovePeer, 15)
+	replication := opt.GetReplicationConfig().Clone()
+	replication.LocationLabels = []string{"zone"}
+	replication.IsolationLevel = "zone"
+	opt.SetReplicationConfig(replication)
+	// The default rule is built from the replication config.
+	opt.SetPlacementRuleEnabled(false)
+	cluster := newTestRaftCluster(s.ctx, mockid.NewIDAllocator(), opt, core.NewStorage(kv.NewMemoryKV()), core.NewBasicCluster())
+	for i, zone := range []string{"z1", "z1", "z2", "z3"} {
+		cluster.core.PutStore(core.NewStoreInfo(&metapb.Store{
+			Id:      uint64(i + 1),
+			Address: fmt.Sprintf("127.0.0.1:%d", i+1),
+			Labels:  []*metapb.StoreLabel{{Key: "zone", Value: zone}},
+		}))
+	}
+	cluster.core.PutRegion(newConformanceTestRegion(1, "", "a", 1, 3, 4))
+	// The stores 1 and 2 are in the same zone.
+	cluster.core.PutRegion(newConformanceTestRegion(2, "a", "b", 1, 2, 3))
+	cluster.core.PutRegion(newConformanceTestRegion(3, "b", "c", 1, 3))
+	cluster.core.PutRegion(newConformanceTestRegion(4, "c", "", 1, 2, 3, 4))
+
+	now := time.Now()
+	report, err := cluster.conformanceChecker.run(now)
+	c.Assert(err, IsNil)
+	c.Assert(report.RegionCount, Equals, 4)
+	c.Assert(report.ViolatedRegionCount, Equals, 3)
+	classes := make(map[ConformanceViolation]*ConformanceClassStat)
+	for _, s := range report.Classes {
+		classes[s.Class] = s
+	}
+	c.Assert(classes[ViolationMissPeer].Regions, Equals, 1)
+	c.Assert(classes[ViolationExtraPeer].Regions, Equals, 1)
+	c.Assert(classes[ViolationWrongRole].Regions, Equals, 0)
+	c.Assert(classes[ViolationIsolation].Regions, Equals, 1)
+	// 1 peer with 4 stores of 15 peers per minute.
+	c.Assert(classes[ViolationMissPeer].EstimatedFixDuration.Duration, Equals, time.Second)
+	c.Assert(report.EstimatedFixDuration.Duration, Equals, 3*time.Second)
+	c.Assert(report.WorstOffenders, HasLen, 3)
+	for i, o := range report.WorstOffenders {
+		c.Assert(o.RegionID, Equals, uint64(i+2))
+		c.Assert(o.Peers, Equals, 1)
+	}
+	c.Assert(report.WorstOffenders[1].Violations, DeepEquals, map[ConformanceViolation]int{ViolationMissPeer: 1})
+
+	// The reports are persisted and can be compared.
+	cluster.core.PutRegion(newConformanceTestRegion(3, "b", "c", 1, 3, 4))
+	later, err := cluster.conformanceChecker.run(now.Add(time.Hour))
+	c.Assert(err, IsNil)
+	ids, err := cluster.GetConformanceReportIDs()
+	c.Assert(err, IsNil)
+	c.Assert(ids, DeepEquals, []uint64{report.ID, later.ID})
+	loaded, err := cluster.GetConformanceReport(report.ID)
+	c.Assert(err, IsNil)
+	c.Assert(loaded.ViolatedRegionCount, Equals, 3)
+	_, err = cluster.GetConformanceReport(1)
+	c.Assert(errs.ErrConformanceReportNotFound.Equal(err), IsTrue)
+	diff := DiffConformanceReports(loaded, later)
+	c.Assert(diff.ViolatedRegionCountDelta, Equals, -1)
+	c.Assert(diff.Classes[0].Class, Equals, ViolationMissPeer)
+	c.Assert(diff.Classes[0].RegionsDelta, Equals, -1)
+	c.Assert(diff.NewOffenders, HasLen, 0)
+	c.Assert(diff.FixedOffenders, DeepEquals, []uint64{3})
+
+	// Only the latest reports are kept.
+	for i := 2; i <= maxConformanceReports+1; i++ {
+		_, err = cluster.conformanceChecker.run(now.Add(time.Duration(i) * time.Hour))
+		c.Assert(err, IsNil)
+	}
+	ids, err = cluster.GetConformanceReportIDs()
+	c.Assert(err, IsNil)
+	c.Assert(ids, HasLen, maxConformanceReports)
+	c.Assert(ids[0], Equals, uint64(now.Add(2*time.Hour).Unix()))
+
+	// The start time of the latest report is restored.
+	checker := newConformanceChecker(cluster)
+	c.Assert(checker.load(), IsNil)
+	c.Assert(checker.lastStart.Unix(), Equals, now.Add(time.Duration(maxConformanceReports+1)*time.Hour).Unix())
+
+	// The default placement rule has no isolation level.
+	opt.SetPlacementRuleEnabled(true)
+	cluster.ruleManager = placement.NewRuleManager(cluster.storage, cluster)
+	c.Assert(cluster.ruleManager.Initialize(3, []string{"zone"}), IsNil)
+	report, err = cluster.conformanceChecker.run(now.Add(100 * time.Hour))
+	c.Assert(err, IsNil)
+	c.Assert(report.ViolatedRegionCount, Equals, 1)
+	c.Assert(report.WorstOffenders[0].Violations, DeepEquals, map[ConformanceViolation]int{ViolationExtraPeer: 1})
+}
@@ -46,6 +46,9 @@ const (
 	maxLoadConfigRetries      = 10
 
 	patrolScanRegionLimit = 128 // It takes about 14 minutes to iterate 1 million regions.
+	// patrolPriorityRegionLimit is the max count of the regions labeled with
+	// the checker priority classes checked before a patrol round.
+	patrolPriorityRegionLimit = 1024
 	// PluginLoad means action for load plugin
 	PluginLoad = "PluginLoad"
 	// PluginUnload means action for unload plugin
@@ -67,23 +70,32 @@ type coordinator struct {
 	opController    *schedule.OperatorController
 	hbStreams       *hbstream.HeartbeatStreams
 	pluginInterface *schedule.PluginInterface
+	appendHotspots  *appendHotspotController
+	hotSplits       *hotSplitController
+	// schedulerBudget limits the operators created by the schedulers in a
+	// patrol round.
+	schedulerBudget *schedule.PatrolBudget
 }
 
 // newCoordinator creates a new coordinator.
 func newCoordinator(ctx context.Context, cluster *RaftCluster, hbStreams *hbstream.HeartbeatStreams) *coordinator {
 	ctx, cancel := context.WithCancel(ctx)
 	opController := schedule.NewOperatorController(ctx, cluster, hbStreams)
+	regionScatterer := schedule.NewRegionScatterer(ctx, cluster, opController.GetPlacementIntents())
 	return &coordinator{
 		ctx:             ctx,
 		cancel:          cancel,
 		cluster:         cluster,
 		checkers:        schedule.NewCheckerController(ctx, cluster, cluster.ruleManager, opController),
-		regionScatterer: schedule.NewRegionScatterer(ctx, cluster),
+		regionScatterer: regionScatterer,
 		regionSplitter:  schedule.NewRegionSplitter(cluster, schedule.NewSplitRegionsHandler(cluster, opController)),
 		schedulers:      make(map[string]*scheduleController),
 		opController:    opController,
 		hbStreams:       hbStreams,
 		pluginInterface: schedule.NewPluginInterface(),
+		appendHotspots:  newAppendHotspotController(cluster, opController, regionScatterer),
+		hotSplits:       newHotSplitController(cluster, opController),
+		schedulerBudget: schedule.NewPatrolBudget((*config.PersistOptions).GetPatrolSchedulerOperatorLimit),
 	}
 }
 
@@ -108,12 +120,27 @@ func (c *coordinator) patrolRegions() {
 			return
 		}
 
+		// The checkers do not run when the scheduling is halted, and the
+		// outstanding operators are canceled in abort-all.
+		if mode := c.cluster.GetOpts().GetHaltScheduling(); mode != "" {
+			if mode == config.HaltAbortAll {
+				c.opController.CancelAllOperators()
+			}
+			continue
+		}
+
 		// Check suspect regions first.
 		c.checkSuspectRegions()
 		// Check suspect key ranges
 		c.checkSuspectKeyRanges()
 		// Check regions in the waiting list
 		c.checkWaitingRegions()
+		// Check regions waiting to remove the orphan peers
+		c.checkOrphanWaitingRegions()
+		// Check the prioritized regions before a new round.
+		if len(key) == 0 {
+			c.checkPriorityRegions()
+		}
 
 		regions := c.cluster.ScanRegions(key, nil, patrolScanRegionLimit)
 		if len(regions) == 0 {
@@ -127,27 +154,16 @@ func (c *coordinator) patrolRegions() {
 			if c.opController.GetOperator(region.GetID()) != nil {
 				continue
 			}
-
-			ops := c.checkers.CheckRegion(region)
-
 			key = region.GetEndKey()
-			if len(ops) == 0 {
-				continue
-			}
-
-			if !c.opController.ExceedStoreLimit(ops...) {
-				c.opController.AddWaitingOperator(ops...)
-				c.checkers.RemoveWaitingRegion(region.GetID())
-				c.cluster.RemoveSuspectRegion(region.GetID())
-			} else {
-				c.checkers.AddWaitingRegion(region)
-			}
+			c.patrolRegion(region)
 		}
 		// Updates the label level isolation statistics.
 		c.cluster.updateRegionsLabelLevelStats(regions)
 		if len(key) == 0 {
 			patrolCheckRegionsGauge.Set(time.Since(start).Seconds())
 			start = time.Now()
+			c.checkers.ResetPatrolBudget()
+			c.schedulerBudget.Reset()
 		}
 		failpoint.Inject("break-patrol", func() {
 			failpoint.Break()
@@ -155,6 +171,78 @@ func (c *coordinator) patrolRegions() {
 	}
 }
 
+// checkOrphanWaitingRegions checks the regions waiting to remove the orphan
+// peers, as long as the cap allows.
+func (c *coordinator) checkOrphanWaitingRegions() {
+	items := c.checkers.GetOrphanWaitingRegions()
+	orphanWaitingListGauge.Set(float64(len(items)))
+	for _, item := range items {
+		if c.checkers.IsOrphanRemovalThrottled() {
+			return
+		}
+		id := item.Key
+		// It is put back if it is throttled again.
+		c.checkers.RemoveOrphanWaitingRegion(id)
+		if region := c.cluster.GetRegion(id); region != nil && c.opController.GetOperator(id) == nil {
+			c.patrolRegion(region)
+		}
+	}
+}
+
+// patrolRegion checks the region, and adds the operators if the store limit
+// allows, or puts it into the waiting list.
+func (c *coordinator) patrolRegion(region *core.RegionInfo) {
+	ops := c.checkers.CheckRegion(region)
+	if len(ops) == 0 {
+		return
+	}
+	if !c.opController.ExceedStoreLimit(ops...) {
+		c.addCheckerOperators(ops)
+		c.checkers.RemoveWaitingRegion(region.GetID())
+		c.cluster.RemoveSuspectRegion(region.GetID())
+	} else {
+		c.checkers.AddWaitingRegion(region)
+	}
+}
+
+// addCheckerOperators adds the operators created by the checkers, and charges
+// the added ones to the budget of the patrol round.
+func (c *coordinator) addCheckerOperators(ops []*operator.Operator) {
+	added := c.opController.AddWaitingOperator(ops...)
+	c.checkers.ChargePatrolBudget(ops[:added])
+}
+
+// checkPriorityRegions checks the regions labeled with the checker priority
+// classes, the higher classes first, so that they are repaired before the
+// other regions.
+func (c *coordinator) checkPriorityRegions() {
+	l := c.cluster.GetRegionLabeler()
+	if l == nil {
+		return
+	}
+	checked := 0
+	for _, r := range l.GetCheckerPriorityRanges() {
+		key := r.StartKey
+		for checked < patrolPriorityRegionLimit {
+			regions := c.cluster.ScanRegions(key, r.EndKey, patrolScanRegionLimit)
+			if len(regions) == 0 {
+				break
+			}
+			for _, region := range regions {
+				checked++
+				if c.opController.GetOperator(region.GetID()) == nil {
+					c.patrolRegion(region)
+				}
+			}
+			key = regions[len(regions)-1].GetEndKey()
+			if len(key) == 0 {
+				break
+			}
+		}
+	}
+	patrolPriorityRegionsGauge.Set(float64(checked))
+}
+
 func (c *coordinator) checkSuspectRegions() {
 	for _, id := range c.cluster.GetSuspectRegions() {
 		region := c.cluster.GetRegion(id)
@@ -172,7 +260,7 @@ func (c *coordinator) checkSuspectRegions() {
 		}
 
 		if !c.opController.ExceedStoreLimit(ops...) {
-			c.opController.AddWaitingOperator(ops...)
+			c.addCheckerOperators(ops)
 			c.cluster.RemoveSuspectRegion(region.GetID())
 		}
 	}
@@ -225,7 +313,7 @@ func (c *coordinator) checkWaitingRegions() {
 		}
 
 		if !c.opController.ExceedStoreLimit(ops...) {
-			c.opController.AddWaitingOperator(ops...)
+			c.addCheckerOperators(ops)
 			c.checkers.RemoveWaitingRegion(region.GetID())
 		}
 	}
@@ -317,6 +405,8 @@ func (c *coordinator) run() {
 		log.Info("create scheduler with independent configuration", zap.String("scheduler-name", s.GetName()))
 		if err = c.addScheduler(s); err != nil {
 			log.Error("can not add scheduler with independent configuration", zap.String("scheduler-name", s.GetName()), zap.Strings("scheduler-args", cfg.Args), errs.ZapError(err))
+		} else {
+			c.initSchedulerConfig(s.GetName(), cfg)
 		}
 	}
 
@@ -340,6 +430,7 @@ func (c *coordinator) run() {
 		if err = c.addScheduler(s, schedulerCfg.Args...); err != nil && !errors.ErrorEqual(err, errs.ErrSchedulerExisted.FastGenByArgs()) {
 			log.Error("can not add scheduler", zap.String("scheduler-name", s.GetName()), zap.Strings("scheduler-args", schedulerCfg.Args), errs.ZapError(err))
 		} else {
+			c.initSchedulerConfig(s.GetName(), schedulerCfg)
 			// Only records the valid scheduler config.
 			scheduleCfg.Schedulers[k] = schedulerCfg
 			k++
@@ -353,10 +444,12 @@ func (c *coordinator) run() {
 		log.Error("cannot persist schedule config", errs.ZapError(err))
 	}
 
-	c.wg.Add(2)
+	c.wg.Add(4)
 	// Starts to patrol regions.
 	go c.patrolRegions()
 	go c.drivePushOperator()
+	go c.runAppendHotspotController()
+	go c.runHotSplitController()
 }
 
 // LoadPlugin load user plugin
@@ -464,6 +557,15 @@ func (c *coordinator) getSchedulers() []string {
 	return names
 }
 
+func (c *coordinator) getScheduler(name string) schedule.Scheduler {
+	c.RLock()
+	defer c.RUnlock()
+	if s, ok := c.schedulers[name]; ok {
+		return s.Scheduler
+	}
+	return nil
+}
+
 func (c *coordinator) getSchedulerHandlers() map[string]http.Handler {
 	c.RLock()
 	defer c.RUnlock()
@@ -481,7 +583,7 @@ func (c *coordinator) collectSchedulerMetrics() {
 		var allowScheduler float64
 		// If the scheduler is not allowed to schedule, it will disappear in Grafana panel.
 		// See issue #1341.
-		if !s.IsPaused() {
+		if !s.IsPaused() && s.IsInWindow(time.Now()) {
 			allowScheduler = 1
 		}
 		schedulerStatusGauge.WithLabelValues(s.GetName(), "allow").Set(allowScheduler)
@@ -636,27 +738,90 @@ func (c *coordinator) removeScheduler(name string) error {
 
 func (c *coordinator) removeOptScheduler(o *config.PersistOptions, name string) error {
 	v := o.GetScheduleConfig().Clone()
+	i, err := c.findOptScheduler(v, name)
+	if err != nil || i < 0 {
+		return err
+	}
+	if config.IsDefaultScheduler(v.Schedulers[i].Type) {
+		v.Schedulers[i].Disable = true
+	} else {
+		v.Schedulers = append(v.Schedulers[:i], v.Schedulers[i+1:]...)
+	}
+	o.SetScheduleConfig(v)
+	return nil
+}
+
+// findOptScheduler returns the index of the scheduler in the schedule config,
+// or -1 if it is not found.
+func (c *coordinator) findOptScheduler(v *config.ScheduleConfig, name string) (int, error) {
 	for i, schedulerCfg := range v.Schedulers {
 		// To create a temporary scheduler is just used to get scheduler's name
 		decoder := schedule.ConfigSliceDecoder(schedulerCfg.Type, schedulerCfg.Args)
 		tmp, err := schedule.CreateScheduler(schedulerCfg.Type, schedule.NewOperatorController(c.ctx, nil, nil), core.NewStorage(kv.NewMemoryKV()), decoder)
 		if err != nil {
-			return err
+			return -1, err
 		}
 		if tmp.GetName() == name {
-			if config.IsDefaultScheduler(tmp.GetType()) {
-				schedulerCfg.Disable = true
-				v.Schedulers[i] = schedulerCfg
-			} else {
-				v.Schedulers = append(v.Schedulers[:i], v.Schedulers[i+1:]...)
-			}
-			o.SetScheduleConfig(v)
-			return nil
+			return i, nil
 		}
 	}
+	return -1, nil
+}
+
+// setSchedulerWindow sets the daily window in which the scheduler is allowed
+// to schedule. An empty window removes the limit.
+func (c *coordinator) setSchedulerWindow(name, window string) error {
+	w, err := config.ParseSchedulerWindow(window)
+	if err != nil {
+		return err
+	}
+	c.Lock()
+	defer c.Unlock()
+	if c.cluster == nil {
+		return errs.ErrNotBootstrapped.FastGenByArgs()
+	}
+	s, ok := c.schedulers[name]
+	if !ok {
+		return errs.ErrSchedulerNotFound.FastGenByArgs()
+	}
+	opt := c.cluster.opt
+	v := opt.GetScheduleConfig().Clone()
+	i, err := c.findOptScheduler(v, name)
+	if err != nil {
+		return err
+	}
+	if i < 0 {
+		return errs.ErrSchedulerNotFound.FastGenByArgs()
+	}
+	v.Schedulers[i].Window = window
+	opt.SetScheduleConfig(v)
+	if err := opt.Persist(c.cluster.storage); err != nil {
+		log.Error("the option can not persist scheduler config", errs.ZapError(err))
+		return err
+	}
+	s.setWindow(w)
+	log.Info("scheduler window is changed", zap.String("scheduler-name", name), zap.String("window", window))
 	return nil
 }
 
+// initSchedulerConfig sets the window and the shadow mode of a scheduler
+// created from the config.
+func (c *coordinator) initSchedulerConfig(name string, cfg config.SchedulerConfig) {
+	c.RLock()
+	defer c.RUnlock()
+	s, ok := c.schedulers[name]
+	if !ok {
+		return
+	}
+	s.shadow.setShadow(cfg.Shadow)
+	w, err := config.ParseSchedulerWindow(cfg.Window)
+	if err != nil {
+		log.Error("invalid scheduler window", zap.String("scheduler-name", name), zap.String("window", cfg.Window), errs.ZapError(err))
+		return
+	}
+	s.setWindow(w)
+}
+
 func (c *coordinator) pauseOrResumeScheduler(name string, t int64) error {
 	c.Lock()
 	defer c.Unlock()
@@ -744,11 +909,19 @@ func (c *coordinator) runScheduler(s *scheduleController) {
 		select {
 		case <-timer.C:
 			timer.Reset(s.GetInterval())
-			if !s.AllowSchedule() {
+			if !s.AllowSchedule() || !c.schedulerBudget.Allow(c.cluster.GetOpts(), s.GetName()) {
 				continue
 			}
 			if op := s.Schedule(); len(op) > 0 {
+				if s.shadow.isShadow() {
+					s.shadow.record(s.GetName(), op)
+					continue
+				}
+				for _, o := range op {
+					o.SetOwner(s.GetName())
+				}
 				added := c.opController.AddWaitingOperator(op...)
+				c.schedulerBudget.Charge(c.cluster, op[:added])
 				log.Debug("add operator", zap.Int("added", added), zap.Int("total", len(op)), zap.String("scheduler", s.GetName()))
 			}
 
@@ -770,6 +943,10 @@ type scheduleController struct {
 	ctx          context.Context
 	cancel       context.CancelFunc
 	delayUntil   int64
+	// window is a *config.SchedulerWindow.
+	window atomic.Value
+	// shadow records the operators produced in the shadow mode.
+	shadow shadowRecorder
 }
 
 // newScheduleController creates a new scheduleController.
@@ -812,7 +989,18 @@ func (s *scheduleController) GetInterval() time.Duration {
 
 // AllowSchedule returns if a scheduler is allowed to schedule.
 func (s *scheduleController) AllowSchedule() bool {
-	return s.Scheduler.IsScheduleAllowed(s.cluster) && !s.IsPaused()
+	return s.cluster.GetOpts().GetHaltScheduling() == "" &&
+		s.Scheduler.IsScheduleAllowed(s.cluster) && !s.IsPaused() && s.IsInWindow(time.Now())
+}
+
+func (s *scheduleController) setWindow(w *config.SchedulerWindow) {
+	s.window.Store(w)
+}
+
+// IsInWindow returns if the time is in the schedule window of the scheduler.
+func (s *scheduleController) IsInWindow(t time.Time) bool {
+	w, _ := s.window.Load().(*config.SchedulerWindow)
+	return w.Contains(t)
 }
 
 // isPaused returns if a scheduler is paused.
@@ -15,6 +15,8 @@ package cluster
 
 import (
 	"context"
+	"encoding/hex"
+	"math/big"
 	"math/rand"
 	"sync"
 	"testing"
@@ -25,6 +27,8 @@ import (
 	"github.com/pingcap/kvproto/pkg/eraftpb"
 	"github.com/pingcap/kvproto/pkg/metapb"
 	"github.com/pingcap/kvproto/pkg/pdpb"
+	"github.com/tikv/pd/pkg/codec"
+	"github.com/tikv/pd/pkg/errs"
 	"github.com/tikv/pd/pkg/mock/mockhbstream"
 	"github.com/tikv/pd/pkg/testutil"
 	"github.com/tikv/pd/pkg/typeutil"
@@ -34,6 +38,7 @@ import (
 	"github.com/tikv/pd/server/kv"
 	"github.com/tikv/pd/server/schedule"
 	"github.com/tikv/pd/server/schedule/hbstream"
+	"github.com/tikv/pd/server/schedule/labeler"
 	"github.com/tikv/pd/server/schedule/operator"
 	"github.com/tikv/pd/server/schedule/opt"
 	"github.com/tikv/pd/server/schedulers"
@@ -249,7 +254,7 @@ func dispatchHeartbeat(co *coordinator, region *core.RegionInfo, stream opt.Hear
 
 func (s *testCoordinatorSuite) TestCollectMetrics(c *C) {
 	tc, co, cleanup := prepare(nil, func(tc *testCluster) {
-		tc.regionStats = statistics.NewRegionStatistics(tc.GetOpts(), nil)
+		tc.regionStats = statistics.NewRegionStatistics(tc.GetOpts(), nil, nil)
 	}, func(co *coordinator) { co.run() }, c)
 	defer cleanup()
 
@@ -355,6 +360,40 @@ func (s *testCoordinatorSuite) TestCheckRegion(c *C) {
 	s.checkRegion(c, tc, co, 1, 0)
 }
 
+func (s *testCoordinatorSuite) TestCheckPriorityRegions(c *C) {
+	tc, co, cleanup := prepare(nil, func(tc *testCluster) {
+		var err error
+		tc.regionLabeler, err = labeler.NewRegionLabeler(tc.storage)
+		c.Assert(err, IsNil)
+	}, nil, c)
+	defer cleanup()
+
+	for i := uint64(1); i <= 4; i++ {
+		c.Assert(tc.addRegionStore(i, 0), IsNil)
+	}
+	for i := uint64(1); i <= 3; i++ {
+		c.Assert(tc.addLeaderRegion(i, 1), IsNil)
+	}
+	for id, class := range map[uint64]string{2: labeler.CheckerPriorityHigh, 3: labeler.CheckerPriorityCritical} {
+		region := tc.GetRegion(id)
+		c.Assert(tc.regionLabeler.SetLabelRule(&labeler.LabelRule{
+			ID:          class,
+			Labels:      []labeler.RegionLabel{{Key: labeler.CheckerPriorityLabel, Value: class}},
+			StartKeyHex: hex.EncodeToString(region.GetStartKey()),
+			EndKeyHex:   hex.EncodeToString(region.GetEndKey()),
+		}), IsNil)
+	}
+
+	// Only the prioritized regions are checked.
+	co.checkPriorityRegions()
+	c.Assert(co.opController.GetOperator(1), IsNil)
+	for _, id := range []uint64{2, 3} {
+		op := co.opController.GetOperator(id)
+		c.Assert(op, NotNil)
+		c.Assert(op.GetPriorityLevel(), Equals, core.UrgentPriority)
+	}
+}
+
 func (s *testCoordinatorSuite) TestCheckerIsBusy(c *C) {
 	tc, co, cleanup := prepare(func(cfg *config.ScheduleConfig) {
 		cfg.ReplicaScheduleLimit = 0 // ensure replica checker is busy
@@ -625,6 +664,39 @@ func (s *testCoordinatorSuite) TestShouldRunWithNonLeaderRegions(c *C) {
 	c.Assert(tc.GetRegion(10).GetLeader().GetStoreId(), Equals, uint64(0))
 }
 
+func (s *testCoordinatorSuite) TestShouldRunWithExpectedStores(c *C) {
+	tc, co, cleanup := prepare(func(cfg *config.ScheduleConfig) { cfg.ExpectedStoreCount = 4 }, nil, nil, c)
+	defer cleanup()
+
+	c.Assert(tc.addLeaderStore(1, 3), IsNil)
+	c.Assert(tc.addLeaderStore(2, 0), IsNil)
+	c.Assert(tc.addLeaderStore(3, 0), IsNil)
+	for i := uint64(1); i <= 3; i++ {
+		c.Assert(tc.LoadRegion(i, 1, 2, 3), IsNil)
+		r := tc.GetRegion(i)
+		c.Assert(tc.processRegionHeartbeat(r.Clone(core.WithLeader(r.GetPeers()[0]))), IsNil)
+	}
+	// All regions are reported, but the expected stores are not up yet.
+	c.Assert(co.shouldRun(), IsFalse)
+	status := tc.GetPrepareStatus()
+	c.Assert(status.Prepared, IsFalse)
+	c.Assert(status.ExpectedStoreCount, Equals, uint64(4))
+	c.Assert(status.ServingStoreCount, Equals, 3)
+	c.Assert(status.ReportedRegionCount, Equals, 3)
+
+	c.Assert(tc.addLeaderStore(4, 0), IsNil)
+	c.Assert(co.shouldRun(), IsTrue)
+	c.Assert(tc.GetPrepareStatus().Prepared, IsTrue)
+
+	// It does not wait for the expected stores forever.
+	tc.prepareChecker = newPrepareChecker()
+	tc.prepareChecker.start = time.Now().Add(-expectedStoreWaitTimeout - time.Second)
+	cfg := tc.opt.GetScheduleConfig().Clone()
+	cfg.ExpectedStoreCount = 5
+	tc.opt.SetScheduleConfig(cfg)
+	c.Assert(co.shouldRun(), IsTrue)
+}
+
 func (s *testCoordinatorSuite) TestAddScheduler(c *C) {
 	tc, co, cleanup := prepare(nil, nil, func(co *coordinator) { co.run() }, c)
 	defer cleanup()
@@ -660,6 +732,7 @@ func (s *testCoordinatorSuite) TestAddScheduler(c *C) {
 
 	// Transfer all leaders to store 1.
 	waitOperator(c, co, 2)
+	c.Assert(oc.GetOperator(2).Owner(), Equals, gls.GetName())
 	region2 := tc.GetRegion(2)
 	c.Assert(dispatchHeartbeat(co, region2, stream), IsNil)
 	region2 = waitTransferLeader(c, stream, region2, 1)
@@ -674,6 +747,26 @@ func (s *testCoordinatorSuite) TestAddScheduler(c *C) {
 	waitNoResponse(c, stream)
 }
 
+func (s *testCoordinatorSuite) TestRegionStatsSnapshot(c *C) {
+	tc, co, cleanup := prepare(nil, nil, func(co *coordinator) { co.run() }, c)
+	defer cleanup()
+	tc.RaftCluster.coordinator = co
+
+	c.Assert(tc.addLeaderStore(2, 0), IsNil)
+	c.Assert(tc.addLeaderStore(1, 1), IsNil)
+	c.Assert(tc.addLeaderRegion(1, 1, 2), IsNil)
+	snapshot := tc.GetRegionStatsSnapshot()
+	c.Assert(snapshot.RegionCount, Equals, 1)
+	c.Assert(snapshot.States, HasLen, len(regionStateNames))
+	c.Assert(snapshot.Stores, HasLen, 2)
+	c.Assert(snapshot.Stores[0].StoreID, Equals, uint64(1))
+	c.Assert(snapshot.Stores[0].LeaderCount, Equals, 1)
+	c.Assert(snapshot.Stores[0].LeaderSize, Equals, int64(10))
+	c.Assert(snapshot.Stores[1].StoreID, Equals, uint64(2))
+	c.Assert(snapshot.HotRead, HasLen, 0)
+	c.Assert(snapshot.HotWrite, HasLen, 0)
+}
+
 func (s *testCoordinatorSuite) TestPersistScheduler(c *C) {
 	tc, co, cleanup := prepare(nil, nil, func(co *coordinator) { co.run() }, c)
 	hbStreams := co.hbStreams
@@ -771,6 +864,129 @@ func (s *testCoordinatorSuite) TestPersistScheduler(c *C) {
 	c.Assert(co.schedulers, HasLen, 3)
 }
 
+func (s *testCoordinatorSuite) TestSchedulerShadow(c *C) {
+	tc, co, cleanup := prepare(nil, nil, func(co *coordinator) { co.run() }, c)
+	defer cleanup()
+	c.Assert(co.removeScheduler(schedulers.BalanceLeaderName), IsNil)
+	c.Assert(co.removeScheduler(schedulers.BalanceRegionName), IsNil)
+	c.Assert(co.removeScheduler(schedulers.HotRegionName), IsNil)
+	c.Assert(tc.addLeaderStore(1, 1), IsNil)
+	c.Assert(tc.addLeaderStore(2, 1), IsNil)
+	c.Assert(tc.addLeaderStore(3, 1), IsNil)
+
+	oc := co.opController
+	gls, err := schedule.CreateScheduler(schedulers.GrantLeaderType, oc, tc.storage, schedule.ConfigSliceDecoder(schedulers.GrantLeaderType, []string{"1"}))
+	c.Assert(err, IsNil)
+	c.Assert(co.addScheduler(gls, "1"), IsNil)
+	c.Assert(errs.ErrSchedulerNotFound.Equal(co.setSchedulerShadow("unknown", true)), IsTrue)
+	c.Assert(co.setSchedulerShadow(gls.GetName(), true), IsNil)
+	var shadow bool
+	for _, cfg := range tc.opt.GetScheduleConfig().Schedulers {
+		if cfg.Type == schedulers.GrantLeaderType {
+			shadow = cfg.Shadow
+		}
+	}
+	c.Assert(shadow, IsTrue)
+
+	// The operators are recorded but not dispatched.
+	c.Assert(tc.addLeaderRegion(2, 2, 1, 3), IsNil)
+	testutil.WaitUntil(c, func(c *C) bool {
+		status, err := co.getSchedulerShadowStatus(gls.GetName())
+		c.Assert(err, IsNil)
+		return status.Total > 0
+	})
+	status, err := co.getSchedulerShadowStatus(gls.GetName())
+	c.Assert(err, IsNil)
+	c.Assert(status.Shadow, IsTrue)
+	c.Assert(status.Recent[0].RegionID, Equals, uint64(2))
+	c.Assert(status.Counts[status.Recent[0].Desc], Greater, uint64(0))
+	c.Assert(oc.GetOperator(2), IsNil)
+
+	// The operators are dispatched after leaving the shadow mode.
+	c.Assert(co.setSchedulerShadow(gls.GetName(), false), IsNil)
+	waitOperator(c, co, 2)
+	c.Assert(oc.GetOperator(2).Owner(), Equals, gls.GetName())
+	status, err = co.getSchedulerShadowStatus(gls.GetName())
+	c.Assert(err, IsNil)
+	c.Assert(status.Shadow, IsFalse)
+}
+
+func (s *testCoordinatorSuite) TestSchedulerWindow(c *C) {
+	tc, co, cleanup := prepare(nil, nil, func(co *coordinator) { co.run() }, c)
+	hbStreams := co.hbStreams
+	defer cleanup()
+	c.Assert(tc.addLeaderStore(1, 1), IsNil)
+
+	now := time.Now()
+	window := now.Add(2*time.Hour).Format("15:04") + "-" + now.Add(3*time.Hour).Format("15:04")
+	c.Assert(errs.ErrSchedulerConfig.Equal(co.setSchedulerWindow(schedulers.BalanceLeaderName, "2am-3am")), IsTrue)
+	c.Assert(errs.ErrSchedulerNotFound.Equal(co.setSchedulerWindow("unknown", window)), IsTrue)
+	c.Assert(co.setSchedulerWindow(schedulers.BalanceLeaderName, window), IsNil)
+	sc := co.schedulers[schedulers.BalanceLeaderName]
+	c.Assert(sc.IsInWindow(now), IsFalse)
+	c.Assert(sc.IsInWindow(now.Add(150*time.Minute)), IsTrue)
+	c.Assert(sc.AllowSchedule(), IsFalse)
+	c.Assert(co.schedulers[schedulers.BalanceRegionName].IsInWindow(now), IsTrue)
+
+	// The window is kept after PD restarts.
+	co.stop()
+	co.wg.Wait()
+	_, newOpt, err := newTestScheduleConfig()
+	c.Assert(err, IsNil)
+	c.Assert(newOpt.Reload(tc.storage), IsNil)
+	tc.RaftCluster.opt = newOpt
+	co = newCoordinator(s.ctx, tc.RaftCluster, hbStreams)
+	co.run()
+	c.Assert(co.schedulers[schedulers.BalanceLeaderName].IsInWindow(now), IsFalse)
+
+	// An empty window removes the limit.
+	c.Assert(co.setSchedulerWindow(schedulers.BalanceLeaderName, ""), IsNil)
+	c.Assert(co.schedulers[schedulers.BalanceLeaderName].IsInWindow(now), IsTrue)
+	co.stop()
+	co.wg.Wait()
+}
+
+func (s *testCoordinatorSuite) TestPreviewScheduler(c *C) {
+	tc, co, cleanup := prepare(func(cfg *config.ScheduleConfig) { cfg.TolerantSizeRatio = 1 }, nil, nil, c)
+	defer cleanup()
+	c.Assert(tc.addLeaderStore(1, 6), IsNil)
+	c.Assert(tc.addLeaderStore(2, 0), IsNil)
+	c.Assert(tc.addLeaderStore(3, 0), IsNil)
+	for id := uint64(1); id <= 6; id++ {
+		c.Assert(tc.addLeaderRegion(id, 1, 2, 3), IsNil)
+	}
+	bl, err := schedule.CreateScheduler(schedulers.BalanceLeaderType, co.opController, tc.storage, schedule.ConfigSliceDecoder(schedulers.BalanceLeaderType, []string{"", ""}))
+	c.Assert(err, IsNil)
+	// The scheduler is not run to keep the operators unchanged.
+	co.schedulers[bl.GetName()] = newScheduleController(co, bl)
+
+	_, err = co.previewScheduler("unknown", 1)
+	c.Assert(errs.ErrSchedulerNotFound.Equal(err), IsTrue)
+	preview, err := co.previewScheduler(bl.GetName(), 1)
+	c.Assert(err, IsNil)
+	c.Assert(preview.Allowed, IsTrue)
+	c.Assert(preview.Operators, HasLen, 1)
+
+	// The operators are applied to the snapshot one by one, so the leaders
+	// become 3, 2, 1 and are balanced within the tolerance in the end.
+	preview, err = co.previewScheduler(bl.GetName(), 10)
+	c.Assert(err, IsNil)
+	c.Assert(preview.Operators, HasLen, 3)
+	regions := make(map[uint64]struct{})
+	for _, op := range preview.Operators {
+		c.Assert(op.Step(0).(operator.TransferLeader).FromStore, Equals, uint64(1))
+		regions[op.RegionID()] = struct{}{}
+	}
+	c.Assert(regions, HasLen, 3)
+
+	// Nothing is changed in the cluster.
+	c.Assert(co.opController.GetOperators(), HasLen, 0)
+	c.Assert(tc.GetStore(1).GetLeaderCount(), Equals, 6)
+	for id := uint64(1); id <= 6; id++ {
+		c.Assert(tc.GetRegion(id).GetLeader().GetStoreId(), Equals, uint64(1))
+	}
+}
+
 func (s *testCoordinatorSuite) TestRemoveScheduler(c *C) {
 	tc, co, cleanup := prepare(func(cfg *config.ScheduleConfig) {
 		cfg.ReplicaScheduleLimit = 0
@@ -822,6 +1038,222 @@ func (s *testCoordinatorSuite) TestRemoveScheduler(c *C) {
 	co.wg.Wait()
 }
 
+func (s *testCoordinatorSuite) TestAppendHotspot(c *C) {
+	rule := config.AppendHotspotRule{KeyPrefix: hex.EncodeToString([]byte("t1_")), PreSplitCount: 2}
+	tc, co, cleanup := prepare(func(cfg *config.ScheduleConfig) {
+		cfg.AppendHotspotRules = []config.AppendHotspotRule{rule}
+	}, nil, nil, c)
+	defer cleanup()
+
+	for storeID := uint64(1); storeID <= 4; storeID++ {
+		c.Assert(tc.addRegionStore(storeID, 10), IsNil)
+	}
+	putRegion := func(regionID uint64, startKey, endKey string) {
+		meta := &metapb.Region{
+			Id:          regionID,
+			StartKey:    []byte(startKey),
+			EndKey:      []byte(endKey),
+			RegionEpoch: &metapb.RegionEpoch{Version: 1, ConfVer: 1},
+		}
+		for storeID := uint64(1); storeID <= 3; storeID++ {
+			peer, _ := tc.AllocPeer(storeID)
+			meta.Peers = append(meta.Peers, peer)
+		}
+		c.Assert(tc.putRegion(core.NewRegionInfo(meta, meta.Peers[0], core.SetApproximateSize(10))), IsNil)
+	}
+	putRegion(1, "", "t1_")
+	putRegion(2, "t1_", "t1_\x10")
+	putRegion(3, "t1_\x10", "")
+
+	// The step is unknown until the write front advances.
+	front := &appendHotspotFront{pending: make(map[string]struct{})}
+	co.appendHotspots.checkRule(rule, front, map[uint64]struct{}{2: {}})
+	c.Assert(front.startKey, DeepEquals, []byte("t1_"))
+	c.Assert(front.step, IsNil)
+	c.Assert(co.opController.GetOperator(3), IsNil)
+
+	// The last region is pre-split by the steps of the front.
+	co.appendHotspots.checkRule(rule, front, map[uint64]struct{}{2: {}, 3: {}})
+	c.Assert(front.step.Int64(), Equals, int64(0x10))
+	op := co.opController.GetOperator(3)
+	c.Assert(op, NotNil)
+	c.Assert(op.Kind()&operator.OpSplit, Equals, operator.OpSplit)
+	c.Assert(op.Step(0).(operator.SplitRegion).SplitKeys, DeepEquals, [][]byte{[]byte("t1_\x20"), []byte("t1_\x30")})
+	c.Assert(front.pending, HasLen, 2)
+
+	// The new ranges are scattered after they are split, and there are enough
+	// ranges ahead of the front.
+	co.opController.RemoveOperator(op)
+	putRegion(3, "t1_\x10", "t1_\x20")
+	putRegion(4, "t1_\x20", "t1_\x30")
+	putRegion(5, "t1_\x30", "")
+	co.appendHotspots.checkRule(rule, front, map[uint64]struct{}{3: {}})
+	c.Assert(front.pending, HasLen, 0)
+	c.Assert(co.opController.GetOperator(5) == nil || co.opController.GetOperator(5).Kind()&operator.OpSplit == 0, IsTrue)
+
+	// The keys are not beyond the key prefix.
+	region := tc.GetRegion(5)
+	c.Assert(preSplitKeys(region, prefixEndKey([]byte("t1_")), big.NewInt(0x10), 4), HasLen, 4)
+	c.Assert(preSplitKeys(region, []byte("t1_\x50"), big.NewInt(0x10), 4), HasLen, 1)
+	c.Assert(prefixEndKey([]byte("t1_")), DeepEquals, []byte("t1`"))
+	c.Assert(prefixEndKey([]byte{0xff}), IsNil)
+}
+
+func (s *testCoordinatorSuite) TestHotSplit(c *C) {
+	tc, co, cleanup := prepare(func(cfg *config.ScheduleConfig) {
+		cfg.HotSplitMaxThreshold = 1000
+		cfg.HotSplitMinThreshold = 100
+	}, nil, nil, c)
+	defer cleanup()
+
+	for storeID := uint64(1); storeID <= 3; storeID++ {
+		c.Assert(tc.addRegionStore(storeID, 10), IsNil)
+	}
+	tableKey := func(tableID int64) []byte {
+		return codec.EncodeBytes(codec.GenerateTableKey(tableID))
+	}
+	putRegion := func(regionID uint64, startKey, endKey []byte) {
+		meta := &metapb.Region{
+			Id:          regionID,
+			StartKey:    startKey,
+			EndKey:      endKey,
+			RegionEpoch: &metapb.RegionEpoch{Version: 1, ConfVer: 1},
+		}
+		for storeID := uint64(1); storeID <= 3; storeID++ {
+			peer, _ := tc.AllocPeer(storeID)
+			meta.Peers = append(meta.Peers, peer)
+		}
+		c.Assert(tc.putRegion(core.NewRegionInfo(meta, meta.Peers[0], core.SetApproximateSize(10))), IsNil)
+	}
+	putRegion(1, nil, tableKey(1))
+	putRegion(2, tableKey(1), tableKey(2))
+	putRegion(3, tableKey(2), nil)
+
+	// The regions out of the tables are not recorded, and the load is below
+	// the max threshold of a table never re-heated.
+	hs := co.hotSplits
+	now := time.Now()
+	hs.update(map[uint64]float64{1: 2000, 2: 600}, 100, 1000, now)
+	c.Assert(hs.getThresholds(), DeepEquals, []*HotSplitThreshold{{TableID: 1, Threshold: 1000, LastHot: now}})
+	c.Assert(co.opController.GetOperator(1), IsNil)
+	c.Assert(co.opController.GetOperator(2), IsNil)
+
+	// The table keeping hot does not re-heat.
+	now = now.Add(time.Minute)
+	hs.update(map[uint64]float64{2: 600}, 100, 1000, now)
+	c.Assert(hs.getThresholds()[0].Reheats, Equals, 0)
+
+	// The table re-heats after cooling down, and the region is split by the
+	// lowered threshold.
+	now = now.Add(2 * hotSplitCoolDown)
+	hs.update(map[uint64]float64{2: 600}, 100, 1000, now)
+	c.Assert(hs.getThresholds(), DeepEquals, []*HotSplitThreshold{{TableID: 1, Threshold: 500, Reheats: 1, LastHot: now}})
+	op := co.opController.GetOperator(2)
+	c.Assert(op, NotNil)
+	c.Assert(op.Kind()&operator.OpSplit, Equals, operator.OpSplit)
+	c.Assert(op.Step(0).(operator.SplitRegion).Policy, Equals, pdpb.CheckPolicy_APPROXIMATE)
+
+	// The history survives the leader changes.
+	hs.persist(now)
+	reloaded := newHotSplitController(tc.RaftCluster, co.opController)
+	reloaded.load(now)
+	c.Assert(reloaded.histories, HasLen, 1)
+	c.Assert(reloaded.histories[1].Reheats, HasLen, 1)
+
+	// The re-heats out of the history window are dropped.
+	now = now.Add(hotSplitHistoryWindow)
+	hs.update(map[uint64]float64{2: 600}, 100, 1000, now)
+	c.Assert(hs.getThresholds()[0].Reheats, Equals, 1)
+	c.Assert(hs.histories[1].Reheats[0], Equals, now)
+
+	c.Assert(hotSplitThreshold(2, 100, 1000), Equals, 250.0)
+	c.Assert(hotSplitThreshold(10, 100, 1000), Equals, 100.0)
+}
+
+func (s *testCoordinatorSuite) TestPatrolBudget(c *C) {
+	tc, co, cleanup := prepare(func(cfg *config.ScheduleConfig) {
+		cfg.PatrolCheckerOperatorLimit = 2
+	}, nil, nil, c)
+	defer cleanup()
+
+	for storeID := uint64(1); storeID <= 3; storeID++ {
+		c.Assert(tc.addRegionStore(storeID, 10), IsNil)
+		// The operators are added, so the store limit should not be the bottleneck.
+		tc.SetStoreLimit(storeID, storelimit.AddPeer, 6000)
+	}
+	// The regions lack the replicas.
+	for regionID := uint64(1); regionID <= 4; regionID++ {
+		c.Assert(tc.addLeaderRegion(regionID, 1), IsNil)
+	}
+	checkRegions := func() int {
+		created := 0
+		for regionID := uint64(1); regionID <= 4; regionID++ {
+			co.patrolRegion(tc.GetRegion(regionID))
+			if op := co.opController.GetOperator(regionID); op != nil {
+				c.Assert(op.Owner(), Equals, operator.CheckerOwner("rule-checker"))
+				c.Assert(co.opController.RemoveOperator(op), IsTrue)
+				created++
+			}
+		}
+		return created
+	}
+	c.Assert(checkRegions(), Equals, 2)
+	c.Assert(co.checkers.GetWaitingRegions(), HasLen, 2)
+	c.Assert(checkRegions(), Equals, 0)
+	co.checkers.ResetPatrolBudget()
+
+	// The operators rejected by the operator controller are not charged.
+	cfg := tc.opt.GetScheduleConfig().Clone()
+	cfg.SchedulerMaxWaitingOperator = 0
+	tc.opt.SetScheduleConfig(cfg)
+	c.Assert(checkRegions(), Equals, 0)
+	cfg.SchedulerMaxWaitingOperator = 5
+	tc.opt.SetScheduleConfig(cfg)
+	c.Assert(checkRegions(), Equals, 2)
+
+	// Each operator adds a peer of 10MB.
+	cfg.PatrolCheckerOperatorLimit = 0
+	cfg.PatrolInfluenceLimit = 15
+	tc.opt.SetScheduleConfig(cfg)
+	co.checkers.ResetPatrolBudget()
+	c.Assert(checkRegions(), Equals, 2)
+	cfg.PatrolInfluenceLimit = 0
+	tc.opt.SetScheduleConfig(cfg)
+	c.Assert(checkRegions(), Equals, 4)
+
+	// Only the added operators of a batch are charged.
+	cfg.PatrolCheckerOperatorLimit = 2
+	tc.opt.SetScheduleConfig(cfg)
+	co.checkers.ResetPatrolBudget()
+	added := newTestOperator(1, tc.GetRegion(1).GetRegionEpoch(), operator.OpReplica|operator.OpRegion, operator.AddLearner{ToStore: 2, PeerID: 100})
+	stale := newTestOperator(2, &metapb.RegionEpoch{Version: 100}, operator.OpReplica|operator.OpRegion, operator.AddLearner{ToStore: 2, PeerID: 101})
+	for _, op := range []*operator.Operator{added, stale} {
+		op.SetOwner(operator.CheckerOwner("rule-checker"))
+	}
+	co.addCheckerOperators([]*operator.Operator{added, stale})
+	c.Assert(co.opController.GetWaitingOperators(), HasLen, 1)
+	co.opController.PromoteWaitingOperator()
+	c.Assert(co.opController.GetOperator(1), Equals, added)
+	c.Assert(co.opController.GetOperator(2), IsNil)
+	// The operators of the checkers are not taken for the ones of a scheduler.
+	c.Assert(co.opController.CancelOperatorsByOwner("rule-checker"), Equals, 0)
+	c.Assert(added.IsCancelRequested(), IsFalse)
+	c.Assert(co.opController.RemoveOperator(added), IsTrue)
+	c.Assert(checkRegions(), Equals, 1)
+
+	// The schedulers have their own budget.
+	cfg.PatrolSchedulerOperatorLimit = 1
+	tc.opt.SetScheduleConfig(cfg)
+	c.Assert(co.schedulerBudget.Allow(tc.opt, "s1"), IsTrue)
+	op := newTestOperator(1, tc.GetRegion(1).GetRegionEpoch(), operator.OpLeader)
+	op.SetOwner("s1")
+	co.schedulerBudget.Charge(tc, []*operator.Operator{op})
+	c.Assert(co.schedulerBudget.Allow(tc.opt, "s1"), IsFalse)
+	c.Assert(co.schedulerBudget.Allow(tc.opt, "s2"), IsTrue)
+	co.schedulerBudget.Reset()
+	c.Assert(co.schedulerBudget.Allow(tc.opt, "s1"), IsTrue)
+}
+
 func (s *testCoordinatorSuite) TestRestart(c *C) {
 	tc, co, cleanup := prepare(func(cfg *config.ScheduleConfig) {
 		// Turn off balance, we test add replica only.
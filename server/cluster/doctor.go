@@ -0,0 +1,218 @@
+// Copyright 2021 TiKV Project Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cluster
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/tikv/pd/server/config"
+	"github.com/tikv/pd/server/core"
+	"github.com/tikv/pd/server/core/storelimit"
+	"github.com/tikv/pd/server/statistics"
+)
+
+// The severities of the doctor findings.
+const (
+	DoctorSeverityWarning  = "warning"
+	DoctorSeverityCritical = "critical"
+)
+
+// The checks of the doctor.
+const (
+	DoctorCheckLocationLabels   = "location-labels"
+	DoctorCheckReplicaIsolation = "replica-isolation"
+	DoctorCheckStoreLimit       = "store-limit"
+	DoctorCheckDownPeerBacklog  = "down-peer-backlog"
+)
+
+// hugeStoreCapacity is the capacity above which the default store limits
+// make the stores take too long to be replenished or drained.
+const hugeStoreCapacity = 4 << 40
+
+// DoctorFinding is a misconfiguration or a problem found by the doctor.
+type DoctorFinding struct {
+	Check    string `json:"check"`
+	Severity string `json:"severity"`
+	Message  string `json:"message"`
+	// Suggestion tells how to fix the problem.
+	Suggestion string `json:"suggestion"`
+}
+
+// DoctorReport is the result of the checks of the doctor.
+type DoctorReport struct {
+	Time     time.Time        `json:"time"`
+	Checks   []string         `json:"checks"`
+	Findings []*DoctorFinding `json:"findings"`
+}
+
+// Diagnose checks the common misconfigurations of the cluster, which are the
+// checklist of the support.
+func (c *RaftCluster) Diagnose() *DoctorReport {
+	report := &DoctorReport{
+		Time:   time.Now(),
+		Checks: []string{DoctorCheckLocationLabels, DoctorCheckReplicaIsolation, DoctorCheckStoreLimit, DoctorCheckDownPeerBacklog},
+	}
+	var stores []*core.StoreInfo
+	for _, store := range c.GetStores() {
+		if store.IsUp() && !store.IsDisconnected() {
+			stores = append(stores, store)
+		}
+	}
+	report.Findings = append(report.Findings, c.checkLocationLabels(stores)...)
+	report.Findings = append(report.Findings, c.checkReplicaIsolation(stores)...)
+	report.Findings = append(report.Findings, c.checkStoreLimits(stores)...)
+	report.Findings = append(report.Findings, c.checkDownPeerBacklog()...)
+	if report.Findings == nil {
+		report.Findings = []*DoctorFinding{}
+	}
+	return report
+}
+
+// checkLocationLabels finds the stores lacking the location labels, which
+// are placed as if they are in the same location.
+func (c *RaftCluster) checkLocationLabels(stores []*core.StoreInfo) []*DoctorFinding {
+	labels := c.opt.GetLocationLabels()
+	if len(labels) == 0 {
+		if len(stores) > c.opt.GetMaxReplicas() {
+			return []*DoctorFinding{{
+				Check:      DoctorCheckLocationLabels,
+				Severity:   DoctorSeverityWarning,
+				Message:    "the location labels are not configured, so the replicas are not isolated by the topology",
+				Suggestion: "label the stores with their locations, and set replication.location-labels, e.g. [\"zone\", \"host\"]",
+			}}
+		}
+		return nil
+	}
+	missing := make(map[string][]string)
+	for _, store := range stores {
+		for _, label := range labels {
+			if store.GetLabelValue(label) == "" {
+				missing[label] = append(missing[label], fmt.Sprint(store.GetID()))
+			}
+		}
+	}
+	var findings []*DoctorFinding
+	for _, label := range labels {
+		if ids := missing[label]; len(ids) > 0 {
+			findings = append(findings, &DoctorFinding{
+				Check:      DoctorCheckLocationLabels,
+				Severity:   DoctorSeverityWarning,
+				Message:    fmt.Sprintf("the stores [%s] lack the location label %s", strings.Join(ids, ", "), label),
+				Suggestion: fmt.Sprintf("set the label %s of the stores, or remove it from replication.location-labels", label),
+			})
+		}
+	}
+	return findings
+}
+
+// checkReplicaIsolation finds if there are not enough stores or top level
+// locations for the replicas, so losing one of them loses the majority.
+func (c *RaftCluster) checkReplicaIsolation(stores []*core.StoreInfo) []*DoctorFinding {
+	maxReplicas := c.opt.GetMaxReplicas()
+	if len(stores) < maxReplicas {
+		return []*DoctorFinding{{
+			Check:      DoctorCheckReplicaIsolation,
+			Severity:   DoctorSeverityCritical,
+			Message:    fmt.Sprintf("there are %d available stores, fewer than the %d replicas", len(stores), maxReplicas),
+			Suggestion: "add stores or reduce replication.max-replicas",
+		}}
+	}
+	labels := c.opt.GetLocationLabels()
+	if len(labels) == 0 || maxReplicas < 3 {
+		return nil
+	}
+	top := labels[0]
+	locations := make(map[string]struct{})
+	for _, store := range stores {
+		if value := store.GetLabelValue(top); value != "" {
+			locations[value] = struct{}{}
+		}
+	}
+	if len(locations) > 1 && len(locations) < maxReplicas {
+		severity := DoctorSeverityWarning
+		// A location holds the majority of the replicas.
+		if (maxReplicas+len(locations)-1)/len(locations) > maxReplicas/2 {
+			severity = DoctorSeverityCritical
+		}
+		return []*DoctorFinding{{
+			Check:      DoctorCheckReplicaIsolation,
+			Severity:   severity,
+			Message:    fmt.Sprintf("there are %d %ss for the %d replicas, so a %s holds more than one replica", len(locations), top, maxReplicas, top),
+			Suggestion: fmt.Sprintf("deploy the stores in at least %d %ss", maxReplicas, top),
+		}}
+	}
+	return nil
+}
+
+// checkStoreLimits finds the huge stores with the default store limits, which
+// take days to be replenished or drained.
+func (c *RaftCluster) checkStoreLimits(stores []*core.StoreInfo) []*DoctorFinding {
+	var ids []string
+	for _, store := range stores {
+		if store.GetCapacity() < hugeStoreCapacity {
+			continue
+		}
+		limit := c.opt.GetStoreLimit(store.GetID())
+		if limit.AddPeer <= config.DefaultStoreLimit.GetDefaultStoreLimit(storelimit.AddPeer) &&
+			limit.RemovePeer <= config.DefaultStoreLimit.GetDefaultStoreLimit(storelimit.RemovePeer) {
+			ids = append(ids, fmt.Sprint(store.GetID()))
+		}
+	}
+	if len(ids) == 0 {
+		return nil
+	}
+	return []*DoctorFinding{{
+		Check:      DoctorCheckStoreLimit,
+		Severity:   DoctorSeverityWarning,
+		Message:    fmt.Sprintf("the stores [%s] are larger than %dTiB, but their store limits are the defaults", strings.Join(ids, ", "), hugeStoreCapacity>>40),
+		Suggestion: "raise the store limits of the stores, e.g. `store limit <store_id> 30`",
+	}}
+}
+
+// checkDownPeerBacklog finds the regions whose down peers are not repaired
+// after the max store down time.
+func (c *RaftCluster) checkDownPeerBacklog() []*DoctorFinding {
+	maxDownTime := c.opt.GetMaxStoreDownTime()
+	var backlog []uint64
+	for _, region := range c.GetRegionStatsByType(statistics.DownPeer) {
+		for _, peer := range region.GetDownPeers() {
+			if time.Duration(peer.GetDownSeconds())*time.Second > maxDownTime {
+				backlog = append(backlog, region.GetID())
+				break
+			}
+		}
+	}
+	if len(backlog) == 0 {
+		return nil
+	}
+	sort.Slice(backlog, func(i, j int) bool { return backlog[i] < backlog[j] })
+	severity, suggestion := DoctorSeverityWarning, "check if there are enough stores to place the replicas, and the store limits of them"
+	if c.opt.GetReplicaScheduleLimit() == 0 {
+		severity, suggestion = DoctorSeverityCritical, "the replica-schedule-limit is 0, set it to a positive value to repair the regions"
+	}
+	const maxListed = 10
+	listed := backlog
+	if len(listed) > maxListed {
+		listed = listed[:maxListed]
+	}
+	return []*DoctorFinding{{
+		Check:      DoctorCheckDownPeerBacklog,
+		Severity:   severity,
+		Message:    fmt.Sprintf("%d regions have down peers for longer than %s, e.g. %v", len(backlog), maxDownTime, listed),
+		Suggestion: suggestion,
+	}}
+}
@@ -0,0 +1,260 @@
+// Copyright 2021 TiKV Project Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cluster
+
+import (
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/tikv/pd/pkg/typeutil"
+)
+
+// maxProfiledRegions is the number of the regions with the costliest
+// heartbeats kept by the profiler.
+const maxProfiledRegions = 100
+
+// heartbeatStage is a stage of processing a region heartbeat.
+type heartbeatStage int
+
+const (
+	// stagePreCheck checks the heartbeat against the cached region, and
+	// decides what to update.
+	stagePreCheck heartbeatStage = iota
+	// stageStatsTasks dispatches the tasks of the hot statistics.
+	stageStatsTasks
+	// stageRuleFit fits the region to the placement rules.
+	stageRuleFit
+	// stageSaveCache puts the region into the cache and the region tree, and
+	// updates the statuses of the related stores.
+	stageSaveCache
+	// stageRegionStats observes the region for the region statistics.
+	stageRegionStats
+	// stageSaveKV saves the region to the storage.
+	stageSaveKV
+	heartbeatStageCount
+)
+
+var heartbeatStageNames = [heartbeatStageCount]string{
+	stagePreCheck:    "pre-check",
+	stageStatsTasks:  "stats-tasks",
+	stageRuleFit:     "rule-fit",
+	stageSaveCache:   "save-cache",
+	stageRegionStats: "region-stats",
+	stageSaveKV:      "save-kv",
+}
+
+// heartbeatTracer measures the stages of processing a region heartbeat. The
+// cost of a stage is the time elapsed since the previous stage ends.
+type heartbeatTracer struct {
+	last  time.Time
+	costs [heartbeatStageCount]time.Duration
+}
+
+func newHeartbeatTracer() *heartbeatTracer {
+	return &heartbeatTracer{last: time.Now()}
+}
+
+// onStageEnd records the cost of the stage that just ends.
+func (t *heartbeatTracer) onStageEnd(stage heartbeatStage) {
+	now := time.Now()
+	t.costs[stage] += now.Sub(t.last)
+	t.last = now
+}
+
+// skip ignores the time elapsed since the previous stage ends, which is not
+// spent on any stage.
+func (t *heartbeatTracer) skip() {
+	t.last = time.Now()
+}
+
+func (t *heartbeatTracer) total() time.Duration {
+	var total time.Duration
+	for _, cost := range t.costs {
+		total += cost
+	}
+	return total
+}
+
+// HeartbeatStageProfile is the aggregated cost of a stage.
+type HeartbeatStageProfile struct {
+	Stage   string            `json:"stage"`
+	Total   typeutil.Duration `json:"total"`
+	Average typeutil.Duration `json:"average"`
+	Max     typeutil.Duration `json:"max"`
+	// Ratio is the proportion of the stage in the total cost.
+	Ratio float64 `json:"ratio"`
+}
+
+// RegionHeartbeatCost is the cost of the heartbeats of a region.
+type RegionHeartbeatCost struct {
+	RegionID uint64 `json:"region_id"`
+	// Count is the number of the heartbeats processed since the region is
+	// profiled.
+	Count uint64            `json:"count"`
+	Total typeutil.Duration `json:"total"`
+	Max   typeutil.Duration `json:"max"`
+	// Stages is the cost of each stage of the costliest heartbeat.
+	Stages map[string]typeutil.Duration `json:"stages"`
+
+	costs [heartbeatStageCount]time.Duration
+}
+
+// HeartbeatProfile is the per-stage breakdown of the cost of processing the
+// region heartbeats.
+type HeartbeatProfile struct {
+	Since time.Time `json:"since"`
+	Count uint64    `json:"count"`
+	// Stages are sorted by the total cost in descending order.
+	Stages []*HeartbeatStageProfile `json:"stages"`
+	// TopRegions are the regions with the costliest heartbeats, which are
+	// sorted by the max cost in descending order.
+	TopRegions []*RegionHeartbeatCost `json:"top_regions"`
+}
+
+type stageStats struct {
+	total time.Duration
+	max   time.Duration
+}
+
+// heartbeatProfiler aggregates the costs of the region heartbeats.
+type heartbeatProfiler struct {
+	sync.Mutex
+	since   time.Time
+	count   uint64
+	stages  [heartbeatStageCount]stageStats
+	regions map[uint64]*RegionHeartbeatCost
+}
+
+func newHeartbeatProfiler() *heartbeatProfiler {
+	return &heartbeatProfiler{
+		since:   time.Now(),
+		regions: make(map[uint64]*RegionHeartbeatCost),
+	}
+}
+
+func (p *heartbeatProfiler) observe(regionID uint64, t *heartbeatTracer) {
+	for stage, cost := range t.costs {
+		regionHeartbeatStageHistogram.WithLabelValues(heartbeatStageNames[stage]).Observe(cost.Seconds())
+	}
+	total := t.total()
+
+	p.Lock()
+	defer p.Unlock()
+	p.count++
+	for stage, cost := range t.costs {
+		s := &p.stages[stage]
+		s.total += cost
+		if cost > s.max {
+			s.max = cost
+		}
+	}
+	region, ok := p.regions[regionID]
+	if !ok {
+		if len(p.regions) >= maxProfiledRegions && !p.evictCheaperLocked(total) {
+			return
+		}
+		region = &RegionHeartbeatCost{RegionID: regionID}
+		p.regions[regionID] = region
+	}
+	region.Count++
+	region.Total.Duration += total
+	if total >= region.Max.Duration {
+		region.Max = typeutil.NewDuration(total)
+		region.costs = t.costs
+	}
+}
+
+// evictCheaperLocked evicts the profiled region with the cheapest costliest
+// heartbeat if it is cheaper than the cost.
+func (p *heartbeatProfiler) evictCheaperLocked(cost time.Duration) bool {
+	var cheapest *RegionHeartbeatCost
+	for _, region := range p.regions {
+		if cheapest == nil || region.Max.Duration < cheapest.Max.Duration {
+			cheapest = region
+		}
+	}
+	if cheapest == nil || cheapest.Max.Duration >= cost {
+		return false
+	}
+	delete(p.regions, cheapest.RegionID)
+	return true
+}
+
+func (p *heartbeatProfiler) getProfile() *HeartbeatProfile {
+	p.Lock()
+	defer p.Unlock()
+	profile := &HeartbeatProfile{
+		Since:      p.since,
+		Count:      p.count,
+		Stages:     make([]*HeartbeatStageProfile, 0, heartbeatStageCount),
+		TopRegions: make([]*RegionHeartbeatCost, 0, len(p.regions)),
+	}
+	var total time.Duration
+	for _, s := range p.stages {
+		total += s.total
+	}
+	for stage, s := range p.stages {
+		stageProfile := &HeartbeatStageProfile{
+			Stage: heartbeatStageNames[stage],
+			Total: typeutil.NewDuration(s.total),
+			Max:   typeutil.NewDuration(s.max),
+		}
+		if p.count > 0 {
+			stageProfile.Average = typeutil.NewDuration(s.total / time.Duration(p.count))
+		}
+		if total > 0 {
+			stageProfile.Ratio = float64(s.total) / float64(total)
+		}
+		profile.Stages = append(profile.Stages, stageProfile)
+	}
+	sort.SliceStable(profile.Stages, func(i, j int) bool {
+		return profile.Stages[i].Total.Duration > profile.Stages[j].Total.Duration
+	})
+	for _, region := range p.regions {
+		r := *region
+		r.Stages = make(map[string]typeutil.Duration, heartbeatStageCount)
+		for stage, cost := range region.costs {
+			r.Stages[heartbeatStageNames[stage]] = typeutil.NewDuration(cost)
+		}
+		profile.TopRegions = append(profile.TopRegions, &r)
+	}
+	sort.Slice(profile.TopRegions, func(i, j int) bool {
+		if profile.TopRegions[i].Max.Duration != profile.TopRegions[j].Max.Duration {
+			return profile.TopRegions[i].Max.Duration > profile.TopRegions[j].Max.Duration
+		}
+		return profile.TopRegions[i].RegionID < profile.TopRegions[j].RegionID
+	})
+	return profile
+}
+
+func (p *heartbeatProfiler) reset() {
+	p.Lock()
+	defer p.Unlock()
+	p.since = time.Now()
+	p.count = 0
+	p.stages = [heartbeatStageCount]stageStats{}
+	p.regions = make(map[uint64]*RegionHeartbeatCost)
+}
+
+// GetHeartbeatProfile returns the per-stage breakdown of the cost of
+// processing the region heartbeats since the profiler is reset.
+func (c *RaftCluster) GetHeartbeatProfile() *HeartbeatProfile {
+	return c.heartbeatProfiler.getProfile()
+}
+
+// ResetHeartbeatProfile resets the profiler of the region heartbeats.
+func (c *RaftCluster) ResetHeartbeatProfile() {
+	c.heartbeatProfiler.reset()
+}
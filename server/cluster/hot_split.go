@@ -0,0 +1,327 @@
+// Copyright 2021 TiKV Project Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cluster
+
+import (
+	"encoding/json"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/pingcap/kvproto/pkg/pdpb"
+	"github.com/pingcap/log"
+	"github.com/tikv/pd/pkg/codec"
+	"github.com/tikv/pd/pkg/errs"
+	"github.com/tikv/pd/pkg/logutil"
+	"github.com/tikv/pd/server/schedule"
+	"github.com/tikv/pd/server/statistics"
+	"go.uber.org/zap"
+)
+
+const (
+	hotSplitCheckInterval = 30 * time.Second
+	hotSplitHistoryPath   = "hot_split_history"
+	// hotSplitCoolDown is how long a table has no hot regions before it is
+	// cooled down, so it re-heats if its regions are hot again.
+	hotSplitCoolDown = 10 * time.Minute
+	// hotSplitHistoryWindow is how long a re-heat is counted in tuning the
+	// threshold of a table.
+	hotSplitHistoryWindow = 24 * time.Hour
+	// hotSplitPersistInterval is how often the history is persisted when only
+	// the last hot time of the tables is changed.
+	hotSplitPersistInterval = 5 * time.Minute
+	// hotSplitRegionCoolDown is how long a region is not split again, until
+	// the hot statistics of it are refreshed after the split.
+	hotSplitRegionCoolDown = 5 * time.Minute
+	maxHotSplitReheats     = 16
+	maxHotSplitsPerCheck   = 4
+)
+
+// hotSplitHistory is the history of a table being hot, which is persisted to
+// survive the leader changes.
+type hotSplitHistory struct {
+	TableID int64       `json:"table_id"`
+	LastHot time.Time   `json:"last_hot"`
+	Reheats []time.Time `json:"reheats,omitempty"`
+}
+
+// HotSplitThreshold is the tuned byte rate over which the hot regions of a
+// table are split.
+type HotSplitThreshold struct {
+	TableID   int64     `json:"table_id"`
+	Threshold float64   `json:"threshold"`
+	Reheats   int       `json:"reheats"`
+	LastHot   time.Time `json:"last_hot"`
+}
+
+// hotSplitController splits the leaders of the hot regions whose byte rates
+// are over the thresholds of their tables. The threshold of a table starts at
+// the max threshold, and is halved each time the table re-heats within the
+// history window, so the ranges which become hot again and again are split
+// earlier, instead of waiting for the same load every time.
+type hotSplitController struct {
+	sync.RWMutex
+	cluster      *RaftCluster
+	opController *schedule.OperatorController
+	histories    map[int64]*hotSplitHistory
+	splitAt      map[uint64]time.Time
+	loaded       bool
+	dirty        bool
+	lastPersist  time.Time
+}
+
+func newHotSplitController(cluster *RaftCluster, opController *schedule.OperatorController) *hotSplitController {
+	return &hotSplitController{
+		cluster:      cluster,
+		opController: opController,
+		histories:    make(map[int64]*hotSplitHistory),
+		splitAt:      make(map[uint64]time.Time),
+	}
+}
+
+// runHotSplitController checks the hot regions periodically.
+func (c *coordinator) runHotSplitController() {
+	defer logutil.LogPanic()
+	defer c.wg.Done()
+	ticker := time.NewTicker(hotSplitCheckInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-c.ctx.Done():
+			log.Info("hot split controller has been stopped")
+			return
+		case <-ticker.C:
+			c.hotSplits.check(time.Now())
+		}
+	}
+}
+
+func (c *hotSplitController) check(now time.Time) {
+	minThreshold, maxThreshold := c.cluster.GetOpts().GetHotSplitThresholdBounds()
+	if maxThreshold <= 0 {
+		return
+	}
+	c.load(now)
+	loads := make(map[uint64]float64)
+	collect := func(stats map[uint64][]*statistics.HotPeerStat, kind statistics.RegionStatKind) {
+		for _, peers := range stats {
+			for _, stat := range peers {
+				if stat.IsLeader() {
+					loads[stat.RegionID] += stat.GetLoad(kind)
+				}
+			}
+		}
+	}
+	collect(c.cluster.RegionWriteStats(), statistics.RegionWriteBytes)
+	collect(c.cluster.RegionReadStats(), statistics.RegionReadBytes)
+	c.update(loads, minThreshold, maxThreshold, now)
+	c.persist(now)
+}
+
+// update records the tables of the hot regions, and splits the regions whose
+// loads are over the thresholds of their tables.
+func (c *hotSplitController) update(loads map[uint64]float64, minThreshold, maxThreshold float64, now time.Time) {
+	c.Lock()
+	defer c.Unlock()
+	tables := make(map[uint64]int64, len(loads))
+	for id := range loads {
+		region := c.cluster.GetRegion(id)
+		if region == nil {
+			continue
+		}
+		tableID := codec.Key(region.GetStartKey()).TableID()
+		if tableID == 0 {
+			continue
+		}
+		tables[id] = tableID
+	}
+	for _, tableID := range tables {
+		h, ok := c.histories[tableID]
+		if !ok {
+			h = &hotSplitHistory{TableID: tableID}
+			c.histories[tableID] = h
+			c.dirty = true
+		} else if now.Sub(h.LastHot) >= hotSplitCoolDown {
+			h.Reheats = append(h.Reheats, now)
+			if len(h.Reheats) > maxHotSplitReheats {
+				h.Reheats = h.Reheats[len(h.Reheats)-maxHotSplitReheats:]
+			}
+			c.dirty = true
+			hotSplitCounter.WithLabelValues("reheat").Inc()
+			log.Info("table re-heats", zap.Int64("table-id", tableID), zap.Int("reheats", len(h.Reheats)))
+		}
+		h.LastHot = now
+	}
+	c.prune(now)
+
+	ids := make([]uint64, 0, len(tables))
+	for id := range tables {
+		ids = append(ids, id)
+	}
+	sort.Slice(ids, func(i, j int) bool { return loads[ids[i]] > loads[ids[j]] })
+	var count int
+	for _, id := range ids {
+		if count >= maxHotSplitsPerCheck {
+			break
+		}
+		h := c.histories[tables[id]]
+		threshold := hotSplitThreshold(len(h.Reheats), minThreshold, maxThreshold)
+		if loads[id] <= threshold {
+			continue
+		}
+		if c.splitRegion(id, now) {
+			log.Info("split the hot region", zap.Uint64("region-id", id), zap.Int64("table-id", h.TableID), zap.Float64("load", loads[id]), zap.Float64("threshold", threshold))
+			count++
+		}
+	}
+}
+
+func (c *hotSplitController) splitRegion(id uint64, now time.Time) bool {
+	if t, ok := c.splitAt[id]; ok && now.Sub(t) < hotSplitRegionCoolDown {
+		return false
+	}
+	region := c.cluster.GetRegion(id)
+	if region == nil || c.opController.GetOperator(id) != nil {
+		return false
+	}
+	op, err := schedule.CreateSplitRegionOperator("hot-split", c.cluster, region, 0, pdpb.CheckPolicy_APPROXIMATE, nil)
+	if err != nil {
+		log.Debug("fail to create hot split operator", errs.ZapError(err))
+		return false
+	}
+	if !c.opController.AddOperator(op) {
+		return false
+	}
+	c.splitAt[id] = now
+	hotSplitCounter.WithLabelValues("split").Inc()
+	return true
+}
+
+// prune drops the re-heats out of the history window, and the tables which
+// have not been hot within it. It is called with the lock held.
+func (c *hotSplitController) prune(now time.Time) {
+	for tableID, h := range c.histories {
+		var i int
+		for i < len(h.Reheats) && now.Sub(h.Reheats[i]) >= hotSplitHistoryWindow {
+			i++
+		}
+		if i > 0 {
+			h.Reheats = h.Reheats[i:]
+			c.dirty = true
+		}
+		if len(h.Reheats) == 0 && now.Sub(h.LastHot) >= hotSplitHistoryWindow {
+			delete(c.histories, tableID)
+			c.dirty = true
+		}
+	}
+	for id, t := range c.splitAt {
+		if now.Sub(t) >= hotSplitRegionCoolDown {
+			delete(c.splitAt, id)
+		}
+	}
+}
+
+// load loads the persisted history once after the leader changes.
+func (c *hotSplitController) load(now time.Time) {
+	c.Lock()
+	defer c.Unlock()
+	if c.loaded {
+		return
+	}
+	value, err := c.cluster.storage.Load(hotSplitHistoryPath)
+	if err != nil {
+		log.Warn("failed to load hot split history", errs.ZapError(err))
+		return
+	}
+	c.loaded = true
+	c.lastPersist = now
+	if value == "" {
+		return
+	}
+	var histories []*hotSplitHistory
+	if err := json.Unmarshal([]byte(value), &histories); err != nil {
+		log.Error("failed to unmarshal hot split history", errs.ZapError(errs.ErrJSONUnmarshal.Wrap(err).FastGenWithCause()))
+		return
+	}
+	for _, h := range histories {
+		c.histories[h.TableID] = h
+	}
+	c.prune(now)
+}
+
+// persist saves the history if the re-heats are changed, or the last hot
+// time of the tables is not saved for a while.
+func (c *hotSplitController) persist(now time.Time) {
+	c.Lock()
+	defer c.Unlock()
+	if !c.dirty && now.Sub(c.lastPersist) < hotSplitPersistInterval {
+		return
+	}
+	histories := make([]*hotSplitHistory, 0, len(c.histories))
+	for _, h := range c.histories {
+		histories = append(histories, h)
+	}
+	sort.Slice(histories, func(i, j int) bool { return histories[i].TableID < histories[j].TableID })
+	value, err := json.Marshal(histories)
+	if err != nil {
+		log.Error("failed to marshal hot split history", errs.ZapError(errs.ErrJSONMarshal.Wrap(err).FastGenWithCause()))
+		return
+	}
+	if err := c.cluster.storage.Save(hotSplitHistoryPath, string(value)); err != nil {
+		log.Warn("failed to save hot split history", zap.Int("count", len(histories)), errs.ZapError(err))
+		return
+	}
+	c.dirty = false
+	c.lastPersist = now
+}
+
+// getThresholds returns the tuned thresholds of the tables in the history.
+func (c *hotSplitController) getThresholds() []*HotSplitThreshold {
+	minThreshold, maxThreshold := c.cluster.GetOpts().GetHotSplitThresholdBounds()
+	c.RLock()
+	defer c.RUnlock()
+	thresholds := make([]*HotSplitThreshold, 0, len(c.histories))
+	for _, h := range c.histories {
+		thresholds = append(thresholds, &HotSplitThreshold{
+			TableID:   h.TableID,
+			Threshold: hotSplitThreshold(len(h.Reheats), minThreshold, maxThreshold),
+			Reheats:   len(h.Reheats),
+			LastHot:   h.LastHot,
+		})
+	}
+	sort.Slice(thresholds, func(i, j int) bool { return thresholds[i].TableID < thresholds[j].TableID })
+	return thresholds
+}
+
+// hotSplitThreshold halves the max threshold by the re-heats, but not below
+// the min threshold.
+func hotSplitThreshold(reheats int, minThreshold, maxThreshold float64) float64 {
+	threshold := maxThreshold
+	for i := 0; i < reheats && threshold > minThreshold; i++ {
+		threshold /= 2
+	}
+	if threshold < minThreshold {
+		threshold = minThreshold
+	}
+	return threshold
+}
+
+// GetHotSplitThresholds returns the tuned byte rates over which the hot
+// regions of the tables are split.
+func (c *RaftCluster) GetHotSplitThresholds() []*HotSplitThreshold {
+	c.RLock()
+	co := c.coordinator
+	c.RUnlock()
+	return co.hotSplits.getThresholds()
+}
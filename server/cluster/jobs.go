@@ -0,0 +1,225 @@
+// Copyright 2021 TiKV Project Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cluster
+
+import (
+	"bytes"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/pingcap/log"
+	"github.com/tikv/pd/pkg/errs"
+	"github.com/tikv/pd/pkg/logutil"
+	"github.com/tikv/pd/server/core"
+	"github.com/tikv/pd/server/job"
+)
+
+const (
+	// ScatterRangeJob is the type of the jobs scattering the regions in a key
+	// range batch by batch.
+	ScatterRangeJob = "scatter-range"
+	// SplitBatchJob is the type of the jobs splitting the regions by a batch
+	// of keys.
+	SplitBatchJob = "split-batch"
+
+	scatterJobBatchSize = 128
+	splitJobBatchSize   = 64
+	defaultJobRetry     = 5
+)
+
+var jobInterval = time.Second
+
+func (c *RaftCluster) registerJobRunners() {
+	c.jobManager.RegisterRunner(ScatterRangeJob, &scatterRangeRunner{cluster: c})
+	c.jobManager.RegisterRunner(SplitBatchJob, &splitBatchRunner{cluster: c})
+}
+
+func (c *RaftCluster) runJobs() {
+	defer logutil.LogPanic()
+	defer c.wg.Done()
+
+	ticker := time.NewTicker(jobInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-c.quit:
+			log.Info("jobs have been stopped")
+			return
+		case <-ticker.C:
+			c.jobManager.RunOnce()
+		}
+	}
+}
+
+// GetJobManager returns the job manager reference.
+func (c *RaftCluster) GetJobManager() *job.Manager {
+	c.RLock()
+	defer c.RUnlock()
+	return c.jobManager
+}
+
+func unmarshalJobField(data json.RawMessage, v interface{}) error {
+	if err := json.Unmarshal(data, v); err != nil {
+		return errs.ErrJSONUnmarshal.Wrap(err).FastGenWithCause()
+	}
+	return nil
+}
+
+func decodeJobKey(key string) ([]byte, error) {
+	k, err := hex.DecodeString(key)
+	if err != nil {
+		return nil, errs.ErrHexDecodingString.FastGenByArgs(key)
+	}
+	return k, nil
+}
+
+func jobProgress(processed, total int) float64 {
+	if total == 0 || processed >= total {
+		return 1
+	}
+	return float64(processed) / float64(total)
+}
+
+// ScatterRangeArgs are the arguments of a scatter-range job. The keys are
+// in hex format.
+type ScatterRangeArgs struct {
+	StartKey   string `json:"start_key"`
+	EndKey     string `json:"end_key"`
+	Group      string `json:"group,omitempty"`
+	RetryLimit int    `json:"retry_limit,omitempty"`
+}
+
+type scatterRangeCheckpoint struct {
+	NextKey   string `json:"next_key"`
+	Total     int    `json:"total"`
+	Scattered int    `json:"scattered"`
+	Failed    int    `json:"failed"`
+}
+
+// scatterRangeRunner scatters a batch of regions in each step.
+type scatterRangeRunner struct {
+	cluster *RaftCluster
+}
+
+func (r *scatterRangeRunner) Step(j *job.Job) (bool, error) {
+	args := ScatterRangeArgs{RetryLimit: defaultJobRetry}
+	if err := unmarshalJobField(j.Args, &args); err != nil {
+		return false, err
+	}
+	startKey, err := decodeJobKey(args.StartKey)
+	if err != nil {
+		return false, err
+	}
+	endKey, err := decodeJobKey(args.EndKey)
+	if err != nil {
+		return false, err
+	}
+	var cp scatterRangeCheckpoint
+	if len(j.Checkpoint) == 0 {
+		cp.NextKey = args.StartKey
+		cp.Total = len(r.cluster.ScanRegions(startKey, endKey, -1))
+	} else if err := unmarshalJobField(j.Checkpoint, &cp); err != nil {
+		return false, err
+	}
+	nextKey, err := decodeJobKey(cp.NextKey)
+	if err != nil {
+		return false, err
+	}
+
+	regions := r.cluster.ScanRegions(nextKey, endKey, scatterJobBatchSize)
+	if len(regions) == 0 {
+		return true, nil
+	}
+	regionMap := make(map[uint64]*core.RegionInfo, len(regions))
+	for _, region := range regions {
+		regionMap[region.GetID()] = region
+	}
+	failures := make(map[uint64]error)
+	ops, err := r.cluster.GetRegionScatter().ScatterRegions(regionMap, failures, args.Group, args.RetryLimit)
+	if err != nil {
+		return false, err
+	}
+	for _, op := range ops {
+		if !r.cluster.GetOperatorController().AddOperator(op) {
+			failures[op.RegionID()] = fmt.Errorf("region %v failed to add operator", op.RegionID())
+		}
+	}
+	cp.Scattered += len(regions) - len(failures)
+	cp.Failed += len(failures)
+	lastKey := regions[len(regions)-1].GetEndKey()
+	cp.NextKey = hex.EncodeToString(lastKey)
+	data, err := json.Marshal(cp)
+	if err != nil {
+		return false, errs.ErrJSONMarshal.Wrap(err).FastGenWithCause()
+	}
+	j.Checkpoint = data
+	j.Progress = jobProgress(cp.Scattered+cp.Failed, cp.Total)
+	return len(lastKey) == 0 || (len(endKey) > 0 && bytes.Compare(lastKey, endKey) >= 0), nil
+}
+
+// SplitBatchArgs are the arguments of a split-batch job. The keys are in
+// hex format.
+type SplitBatchArgs struct {
+	SplitKeys  []string `json:"split_keys"`
+	RetryLimit int      `json:"retry_limit,omitempty"`
+}
+
+type splitBatchCheckpoint struct {
+	NextIndex  int      `json:"next_index"`
+	NewRegions []uint64 `json:"new_regions,omitempty"`
+}
+
+// splitBatchRunner splits the regions by a batch of keys in each step.
+type splitBatchRunner struct {
+	cluster *RaftCluster
+}
+
+func (r *splitBatchRunner) Step(j *job.Job) (bool, error) {
+	args := SplitBatchArgs{RetryLimit: defaultJobRetry}
+	if err := unmarshalJobField(j.Args, &args); err != nil {
+		return false, err
+	}
+	var cp splitBatchCheckpoint
+	if len(j.Checkpoint) > 0 {
+		if err := unmarshalJobField(j.Checkpoint, &cp); err != nil {
+			return false, err
+		}
+	}
+	end := cp.NextIndex + splitJobBatchSize
+	if end > len(args.SplitKeys) {
+		end = len(args.SplitKeys)
+	}
+	keys := make([][]byte, 0, end-cp.NextIndex)
+	for _, k := range args.SplitKeys[cp.NextIndex:end] {
+		key, err := decodeJobKey(k)
+		if err != nil {
+			return false, err
+		}
+		keys = append(keys, key)
+	}
+	if len(keys) > 0 {
+		_, newRegions := r.cluster.GetRegionSplitter().SplitRegions(r.cluster.ctx, keys, args.RetryLimit)
+		cp.NewRegions = append(cp.NewRegions, newRegions...)
+	}
+	cp.NextIndex = end
+	data, err := json.Marshal(cp)
+	if err != nil {
+		return false, errs.ErrJSONMarshal.Wrap(err).FastGenWithCause()
+	}
+	j.Checkpoint = data
+	j.Progress = jobProgress(cp.NextIndex, len(args.SplitKeys))
+	return cp.NextIndex >= len(args.SplitKeys), nil
+}
@@ -0,0 +1,31 @@
+// Copyright 2021 TiKV Project Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cluster
+
+import "github.com/tikv/pd/server/schedule/leaderconstraint"
+
+// GetLeaderConstraintStatuses returns the leader distribution of the leader
+// constraints, which tells whether they are violated.
+func (c *RaftCluster) GetLeaderConstraintStatuses() []*leaderconstraint.Status {
+	return leaderconstraint.NewState(c.opt.GetLeaderConstraints(), c.GetStores()).GetStatuses()
+}
+
+func (c *RaftCluster) collectLeaderConstraintMetrics() {
+	leaderConstraintGauge.Reset()
+	for _, status := range c.GetLeaderConstraintStatuses() {
+		constraint := status.LabelKey + "=" + status.LabelValue
+		leaderConstraintGauge.WithLabelValues(constraint, "ratio").Set(status.Ratio)
+		leaderConstraintGauge.WithLabelValues(constraint, "max_ratio").Set(status.MaxRatio)
+	}
+}
@@ -24,6 +24,14 @@ var (
 			Help:      "Status of the cluster.",
 		}, []string{"name"})
 
+	regionInconsistencyGauge = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Namespace: "pd",
+			Subsystem: "cluster",
+			Name:      "region_inconsistency",
+			Help:      "The number of the inconsistencies of the region metadata found by the last check.",
+		}, []string{"type"})
+
 	regionEventCounter = prometheus.NewCounterVec(
 		prometheus.CounterOpts{
 			Namespace: "pd",
@@ -32,6 +40,23 @@ var (
 			Help:      "Counter of the region event",
 		}, []string{"event"})
 
+	regionHeartbeatStageHistogram = prometheus.NewHistogramVec(
+		prometheus.HistogramOpts{
+			Namespace: "pd",
+			Subsystem: "cluster",
+			Name:      "region_heartbeat_stage_duration_seconds",
+			Help:      "Bucketed histogram of processing time (s) of each stage of handled region heartbeats.",
+			Buckets:   prometheus.ExponentialBuckets(0.00001, 2, 20), // 10us ~ 5s
+		}, []string{"stage"})
+
+	stalePeerGauge = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Namespace: "pd",
+			Subsystem: "cluster",
+			Name:      "stale_peers",
+			Help:      "The number of the removed peers not acknowledged by the store.",
+		}, []string{"store"})
+
 	schedulerStatusGauge = prometheus.NewGaugeVec(
 		prometheus.GaugeOpts{
 			Namespace: "pd",
@@ -40,6 +65,14 @@ var (
 			Help:      "Status of the scheduler.",
 		}, []string{"kind", "type"})
 
+	schedulerShadowOperatorCounter = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace: "pd",
+			Subsystem: "scheduler",
+			Name:      "shadow_operators",
+			Help:      "Counter of the operators produced by the schedulers in the shadow mode.",
+		}, []string{"scheduler", "type"})
+
 	hotSpotStatusGauge = prometheus.NewGaugeVec(
 		prometheus.GaugeOpts{
 			Namespace: "pd",
@@ -56,6 +89,14 @@ var (
 			Help:      "Time spent of patrol checks region.",
 		})
 
+	patrolPriorityRegionsGauge = prometheus.NewGauge(
+		prometheus.GaugeOpts{
+			Namespace: "pd",
+			Subsystem: "checker",
+			Name:      "patrol_priority_regions",
+			Help:      "The count of the prioritized regions checked before a patrol round.",
+		})
+
 	clusterStateCPUGauge = prometheus.NewGauge(
 		prometheus.GaugeOpts{
 			Namespace: "pd",
@@ -78,15 +119,165 @@ var (
 			Name:      "region_waiting_list",
 			Help:      "Number of region in waiting list",
 		})
+
+	orphanWaitingListGauge = prometheus.NewGauge(
+		prometheus.GaugeOpts{
+			Namespace: "pd",
+			Subsystem: "checker",
+			Name:      "orphan_waiting_list",
+			Help:      "Number of region waiting to remove the orphan peers",
+		})
+
+	storageDegradedGauge = prometheus.NewGauge(
+		prometheus.GaugeOpts{
+			Namespace: "pd",
+			Subsystem: "cluster",
+			Name:      "storage_degraded",
+			Help:      "Whether the storage is degraded because of the slowness.",
+		})
+
+	storageDegradedCounter = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace: "pd",
+			Subsystem: "cluster",
+			Name:      "storage_degraded_transitions_total",
+			Help:      "Counter of entering and leaving the degraded state of the storage.",
+		}, []string{"type"})
+
+	storageDroppedCounter = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace: "pd",
+			Subsystem: "cluster",
+			Name:      "storage_dropped_writes_total",
+			Help:      "Counter of the writes dropped or suppressed when the storage is degraded.",
+		}, []string{"type"})
+
+	regionDeleteBacklogGauge = prometheus.NewGauge(
+		prometheus.GaugeOpts{
+			Namespace: "pd",
+			Subsystem: "cluster",
+			Name:      "region_delete_backlog",
+			Help:      "The number of the overlapped regions waiting to be deleted from storage.",
+		})
+
+	regionDeleteCounter = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace: "pd",
+			Subsystem: "cluster",
+			Name:      "region_delete_total",
+			Help:      "Counter of the deletions of the overlapped regions from storage.",
+		}, []string{"type"})
+
+	leaderConstraintGauge = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Namespace: "pd",
+			Subsystem: "cluster",
+			Name:      "leader_constraint",
+			Help:      "The leader ratio and the max ratio of the leader constraints.",
+		}, []string{"constraint", "type"})
+
+	metricsCollectorDuration = prometheus.NewHistogramVec(
+		prometheus.HistogramOpts{
+			Namespace: "pd",
+			Subsystem: "cluster",
+			Name:      "metrics_collector_duration_seconds",
+			Help:      "Bucketed histogram of the time spent by each metrics collector.",
+			Buckets:   prometheus.ExponentialBuckets(0.001, 2, 16), // 1ms ~ 32s
+		}, []string{"collector"})
+
+	metricsCollectionIntervalGauge = prometheus.NewGauge(
+		prometheus.GaugeOpts{
+			Namespace: "pd",
+			Subsystem: "cluster",
+			Name:      "metrics_collection_interval_seconds",
+			Help:      "The current interval of collecting the metrics.",
+		})
+
+	appendHotspotCounter = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace: "pd",
+			Subsystem: "cluster",
+			Name:      "append_hotspot_total",
+			Help:      "Counter of the events of the append hotspot controller.",
+		}, []string{"type"})
+
+	hotSplitCounter = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace: "pd",
+			Subsystem: "cluster",
+			Name:      "hot_split_total",
+			Help:      "Counter of the events of the hot split controller.",
+		}, []string{"type"})
+
+	persistedSuspectRegionsGauge = prometheus.NewGauge(
+		prometheus.GaugeOpts{
+			Namespace: "pd",
+			Subsystem: "checker",
+			Name:      "persisted_suspect_regions",
+			Help:      "Number of the persisted suspect regions.",
+		})
+
+	alertFiringGauge = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Namespace: "pd",
+			Subsystem: "cluster",
+			Name:      "alert_firing",
+			Help:      "Whether the alert of each rule is firing.",
+		}, []string{"rule"})
+
+	alertWebhookCounter = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace: "pd",
+			Subsystem: "cluster",
+			Name:      "alert_webhook_total",
+			Help:      "Counter of the alert notifications sent to the webhooks.",
+		}, []string{"result"})
+
+	cacheMemoryGauge = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Namespace: "pd",
+			Subsystem: "cluster",
+			Name:      "cache_memory_bytes",
+			Help:      "The estimated memory used by each kind of the cached objects.",
+		}, []string{"type"})
+
+	cacheEvictedCounter = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace: "pd",
+			Subsystem: "cluster",
+			Name:      "cache_evicted_total",
+			Help:      "Counter of the cold cached objects evicted.",
+		}, []string{"type"})
 )
 
 func init() {
 	prometheus.MustRegister(regionEventCounter)
+	prometheus.MustRegister(regionHeartbeatStageHistogram)
+	prometheus.MustRegister(stalePeerGauge)
+	prometheus.MustRegister(regionInconsistencyGauge)
 	prometheus.MustRegister(healthStatusGauge)
 	prometheus.MustRegister(schedulerStatusGauge)
+	prometheus.MustRegister(schedulerShadowOperatorCounter)
 	prometheus.MustRegister(hotSpotStatusGauge)
 	prometheus.MustRegister(patrolCheckRegionsGauge)
+	prometheus.MustRegister(patrolPriorityRegionsGauge)
 	prometheus.MustRegister(clusterStateCPUGauge)
 	prometheus.MustRegister(clusterStateCurrent)
 	prometheus.MustRegister(regionWaitingListGauge)
+	prometheus.MustRegister(orphanWaitingListGauge)
+	prometheus.MustRegister(storageDegradedGauge)
+	prometheus.MustRegister(storageDegradedCounter)
+	prometheus.MustRegister(storageDroppedCounter)
+	prometheus.MustRegister(regionDeleteBacklogGauge)
+	prometheus.MustRegister(regionDeleteCounter)
+	prometheus.MustRegister(leaderConstraintGauge)
+	prometheus.MustRegister(metricsCollectorDuration)
+	prometheus.MustRegister(metricsCollectionIntervalGauge)
+	prometheus.MustRegister(appendHotspotCounter)
+	prometheus.MustRegister(hotSplitCounter)
+	prometheus.MustRegister(persistedSuspectRegionsGauge)
+	prometheus.MustRegister(alertFiringGauge)
+	prometheus.MustRegister(alertWebhookCounter)
+	prometheus.MustRegister(cacheMemoryGauge)
+	prometheus.MustRegister(cacheEvictedCounter)
 }
@@ -0,0 +1,100 @@
+// Copyright 2021 TiKV Project Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cluster
+
+import (
+	"time"
+
+	"github.com/pingcap/log"
+	"github.com/tikv/pd/pkg/logutil"
+)
+
+const (
+	// metricsCollectionCostFactor makes a round of collecting the metrics
+	// take at most a quarter of the interval.
+	metricsCollectionCostFactor = 4
+	// metricsCollectionRegionStep is the number of the regions for which the
+	// interval grows by the base interval, since the cost of the collection is
+	// proportional to the regions.
+	metricsCollectionRegionStep = 1000000
+	// maxMetricsCollectionInterval is the max interval of collecting the
+	// metrics after backing off.
+	maxMetricsCollectionInterval = time.Minute
+)
+
+// nextMetricsCollectionInterval returns the interval before the next round of
+// collecting the metrics. It backs off from the base interval when the last
+// round takes long or the cluster has many regions.
+func nextMetricsCollectionInterval(base, cost time.Duration, regionCount int) time.Duration {
+	interval := base * time.Duration(1+regionCount/metricsCollectionRegionStep)
+	if backoff := cost * metricsCollectionCostFactor; backoff > interval {
+		interval = backoff
+	}
+	if interval > maxMetricsCollectionInterval {
+		interval = maxMetricsCollectionInterval
+	}
+	return interval
+}
+
+// observeMetricsCollector runs the collector and records the time it takes.
+func observeMetricsCollector(name string, collect func()) {
+	start := time.Now()
+	collect()
+	metricsCollectorDuration.WithLabelValues(name).Observe(time.Since(start).Seconds())
+}
+
+// runMetricsCollectionJob collects the metrics of the stores, the regions and
+// the schedulers at the adaptive interval.
+func (c *RaftCluster) runMetricsCollectionJob(base time.Duration) {
+	defer logutil.LogPanic()
+	defer c.wg.Done()
+
+	timer := time.NewTimer(base)
+	defer timer.Stop()
+	for {
+		select {
+		case <-c.quit:
+			log.Info("metrics are reset")
+			c.resetMetrics()
+			log.Info("metrics collection job has been stopped")
+			return
+		case <-timer.C:
+			start := time.Now()
+			c.collectMetrics()
+			interval := nextMetricsCollectionInterval(base, time.Since(start), c.GetRegionCount())
+			metricsCollectionIntervalGauge.Set(interval.Seconds())
+			timer.Reset(interval)
+		}
+	}
+}
+
+// runHealthStatusJob collects the health status of the members, which is not
+// delayed by collecting the other metrics.
+func (c *RaftCluster) runHealthStatusJob(interval time.Duration) {
+	defer logutil.LogPanic()
+	defer c.wg.Done()
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-c.quit:
+			c.resetHealthStatus()
+			log.Info("health status job has been stopped")
+			return
+		case <-ticker.C:
+			observeMetricsCollector("health", c.collectHealthStatus)
+		}
+	}
+}
@@ -0,0 +1,122 @@
+// Copyright 2021 TiKV Project Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cluster
+
+import (
+	"sync"
+	"time"
+
+	"github.com/pingcap/log"
+	"github.com/tikv/pd/server/core"
+	"go.uber.org/zap"
+)
+
+// regionConsistencyCheckInterval is the interval of checking the overlaps and
+// the gaps of the regions, which scans all regions in the cache.
+const regionConsistencyCheckInterval = 5 * time.Minute
+
+// regionConsistencyChecker checks the region metadata in the cache
+// periodically. The overlaps and the gaps may be left by the partial updates,
+// and the overlapped regions can be removed from the cache to be refreshed by
+// the next heartbeats.
+type regionConsistencyChecker struct {
+	sync.RWMutex
+	cluster         *RaftCluster
+	lastCheck       time.Time
+	inconsistencies []*core.RegionInconsistency
+}
+
+func newRegionConsistencyChecker(cluster *RaftCluster) *regionConsistencyChecker {
+	return &regionConsistencyChecker{cluster: cluster}
+}
+
+// tick checks the regions if the interval has passed since the last check.
+func (rc *regionConsistencyChecker) tick(now time.Time) {
+	rc.RLock()
+	skip := now.Sub(rc.lastCheck) < regionConsistencyCheckInterval
+	rc.RUnlock()
+	if !skip {
+		rc.check(now)
+	}
+}
+
+func (rc *regionConsistencyChecker) check(now time.Time) []*core.RegionInconsistency {
+	c := rc.cluster
+	inconsistencies := c.core.CheckRegionConsistency()
+	counts := make(map[core.RegionInconsistencyType]int)
+	for _, i := range inconsistencies {
+		counts[i.Type]++
+	}
+	for _, typ := range []core.RegionInconsistencyType{core.RegionOverlap, core.RegionGap} {
+		regionInconsistencyGauge.WithLabelValues(string(typ)).Set(float64(counts[typ]))
+	}
+	if len(inconsistencies) > 0 {
+		log.Warn("region metadata is inconsistent",
+			zap.Int("overlaps", counts[core.RegionOverlap]),
+			zap.Int("gaps", counts[core.RegionGap]))
+	}
+	if c.opt.IsRegionCacheRepairEnabled() {
+		rc.repair(inconsistencies)
+	}
+
+	rc.Lock()
+	defer rc.Unlock()
+	rc.lastCheck = now
+	rc.inconsistencies = inconsistencies
+	return inconsistencies
+}
+
+// repair removes the overlapped regions from the cache, so that the stores
+// report the current state of them with the next heartbeats. The gaps are not
+// repaired, because they are filled once the missing regions are reported.
+func (rc *regionConsistencyChecker) repair(inconsistencies []*core.RegionInconsistency) {
+	c := rc.cluster
+	removed := make(map[uint64]struct{})
+	for _, i := range inconsistencies {
+		if i.Type != core.RegionOverlap {
+			continue
+		}
+		for _, id := range i.Regions {
+			if _, ok := removed[id]; ok {
+				continue
+			}
+			removed[id] = struct{}{}
+			if region := c.core.GetRegion(id); region != nil {
+				c.core.RemoveRegion(region)
+				if c.ruleManager != nil {
+					c.ruleManager.RemoveRegionFit(id)
+				}
+				log.Info("overlapped region is removed from the cache", zap.Uint64("region-id", id))
+			}
+		}
+	}
+}
+
+func (rc *regionConsistencyChecker) getInconsistencies() []*core.RegionInconsistency {
+	rc.RLock()
+	defer rc.RUnlock()
+	return rc.inconsistencies
+}
+
+// GetRegionInconsistencies returns the overlaps and the gaps of the regions
+// found by the last region consistency check.
+func (c *RaftCluster) GetRegionInconsistencies() []*core.RegionInconsistency {
+	return c.regionConsistencyChecker.getInconsistencies()
+}
+
+// CheckRegionConsistency checks the overlaps and the gaps of the regions
+// immediately, and repairs the cache if it is enabled.
+func (c *RaftCluster) CheckRegionConsistency() []*core.RegionInconsistency {
+	return c.regionConsistencyChecker.check(time.Now())
+}
@@ -0,0 +1,221 @@
+// Copyright 2021 TiKV Project Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cluster
+
+import (
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/pingcap/kvproto/pkg/metapb"
+	"github.com/pingcap/log"
+	"github.com/tikv/pd/pkg/errs"
+	"github.com/tikv/pd/pkg/logutil"
+	"github.com/tikv/pd/server/core"
+	"go.uber.org/zap"
+)
+
+const (
+	// regionDeleteBatchSize is the max number of the regions deleted at once.
+	regionDeleteBatchSize = 256
+	// regionDeleteBacklogLimit is the max number of the regions waiting to be
+	// deleted. The deletions beyond it are dropped.
+	regionDeleteBacklogLimit = 100000
+	// regionDeleteRetryLimit is the max number of the retries of a deletion.
+	regionDeleteRetryLimit = 10
+)
+
+var regionDeleteRetryInterval = time.Second
+
+type regionDeletion struct {
+	region  *metapb.Region
+	retries int
+	retryAt time.Time
+}
+
+// regionDeleter deletes the regions overlapped by the newer ones from storage
+// in the background, so the heartbeats are not blocked by the deletions. The
+// deletions of the same region are coalesced, and the failed ones are retried
+// with backoff.
+type regionDeleter struct {
+	sync.Mutex
+	pending map[uint64]*regionDeletion
+	notify  chan struct{}
+	// storageMu serializes the deletions with the saves of the heartbeats.
+	// A region is checked and deleted while holding it, so a region saved
+	// again after the check is not deleted.
+	storageMu sync.Mutex
+}
+
+func newRegionDeleter() *regionDeleter {
+	return &regionDeleter{
+		pending: make(map[uint64]*regionDeletion),
+		notify:  make(chan struct{}, 1),
+	}
+}
+
+// add queues the deletions of the regions.
+func (d *regionDeleter) add(regions []*core.RegionInfo) {
+	if len(regions) == 0 {
+		return
+	}
+	d.Lock()
+	for _, region := range regions {
+		if del, ok := d.pending[region.GetID()]; ok {
+			del.region = region.GetMeta()
+			regionDeleteCounter.WithLabelValues("coalesced").Inc()
+			continue
+		}
+		if len(d.pending) >= regionDeleteBacklogLimit {
+			regionDeleteCounter.WithLabelValues("dropped").Inc()
+			log.Warn("region deletion is dropped as the backlog is full", zap.Uint64("region-id", region.GetID()))
+			continue
+		}
+		d.pending[region.GetID()] = &regionDeletion{region: region.GetMeta()}
+	}
+	regionDeleteBacklogGauge.Set(float64(len(d.pending)))
+	d.Unlock()
+
+	select {
+	case d.notify <- struct{}{}:
+	default:
+	}
+}
+
+// save cancels the deletion of the region and saves it, which is not
+// interleaved with the deletions.
+func (d *regionDeleter) save(regionID uint64, save func()) {
+	d.storageMu.Lock()
+	defer d.storageMu.Unlock()
+	d.cancel(regionID)
+	save()
+}
+
+// cancel cancels the deletion of the region, which is saved again.
+func (d *regionDeleter) cancel(regionID uint64) {
+	d.Lock()
+	defer d.Unlock()
+	if _, ok := d.pending[regionID]; ok {
+		delete(d.pending, regionID)
+		regionDeleteBacklogGauge.Set(float64(len(d.pending)))
+	}
+}
+
+// takeBatch takes the deletions which are due, in the order of the region IDs
+// so that the consecutive ones can be deleted at once.
+func (d *regionDeleter) takeBatch(now time.Time) []*regionDeletion {
+	d.Lock()
+	defer d.Unlock()
+	batch := make([]*regionDeletion, 0, regionDeleteBatchSize)
+	for _, del := range d.pending {
+		if !del.retryAt.After(now) {
+			batch = append(batch, del)
+		}
+	}
+	sort.Slice(batch, func(i, j int) bool { return batch[i].region.GetId() < batch[j].region.GetId() })
+	if len(batch) > regionDeleteBatchSize {
+		batch = batch[:regionDeleteBatchSize]
+	}
+	for _, del := range batch {
+		delete(d.pending, del.region.GetId())
+	}
+	regionDeleteBacklogGauge.Set(float64(len(d.pending)))
+	return batch
+}
+
+// retry puts the failed deletions back unless they are queued again or have
+// been retried too many times.
+func (d *regionDeleter) retry(batch []*regionDeletion, now time.Time) {
+	d.Lock()
+	defer d.Unlock()
+	for _, del := range batch {
+		if _, ok := d.pending[del.region.GetId()]; ok {
+			continue
+		}
+		del.retries++
+		if del.retries > regionDeleteRetryLimit {
+			regionDeleteCounter.WithLabelValues("give-up").Inc()
+			log.Error("give up deleting region from storage",
+				zap.Uint64("region-id", del.region.GetId()),
+				logutil.ZapRedactStringer("region-meta", core.RegionToHexMeta(del.region)))
+			continue
+		}
+		del.retryAt = now.Add(time.Duration(del.retries) * regionDeleteRetryInterval)
+		d.pending[del.region.GetId()] = del
+	}
+	regionDeleteBacklogGauge.Set(float64(len(d.pending)))
+}
+
+// flush deletes a batch of the regions which are not alive. It returns whether
+// the batch is deleted, in which case there may be more to delete. The regions
+// are checked and deleted under storageMu, so a heartbeat can't save a region
+// in between.
+func (d *regionDeleter) flush(storage *core.Storage, isAlive func(regionID uint64) bool) bool {
+	batch := d.takeBatch(time.Now())
+	if len(batch) == 0 {
+		return false
+	}
+	d.storageMu.Lock()
+	defer d.storageMu.Unlock()
+	dead := batch[:0]
+	regions := make([]*metapb.Region, 0, len(batch))
+	for _, del := range batch {
+		// The region may be reported again after it is overlapped, e.g. the
+		// heartbeat of the region split from it comes first.
+		if isAlive(del.region.GetId()) {
+			regionDeleteCounter.WithLabelValues("skipped").Inc()
+			continue
+		}
+		dead = append(dead, del)
+		regions = append(regions, del.region)
+	}
+	if err := storage.DeleteRegions(regions); err != nil {
+		regionDeleteCounter.WithLabelValues("failed").Add(float64(len(regions)))
+		log.Error("failed to delete regions from storage", zap.Int("count", len(regions)), errs.ZapError(err))
+		d.retry(dead, time.Now())
+		return false
+	}
+	regionDeleteCounter.WithLabelValues("deleted").Add(float64(len(regions)))
+	return true
+}
+
+// flushRegionDeletions deletes the queued regions until there is nothing due
+// or a deletion fails.
+func (c *RaftCluster) flushRegionDeletions() {
+	if c.storage == nil {
+		return
+	}
+	isAlive := func(regionID uint64) bool { return c.GetRegion(regionID) != nil }
+	for c.regionDeleter.flush(c.storage, isAlive) {
+	}
+}
+
+func (c *RaftCluster) runRegionDeleter() {
+	defer logutil.LogPanic()
+	defer c.wg.Done()
+
+	ticker := time.NewTicker(regionDeleteRetryInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-c.quit:
+			c.flushRegionDeletions()
+			log.Info("region deleter has been stopped")
+			return
+		case <-c.regionDeleter.notify:
+		case <-ticker.C:
+		}
+		c.flushRegionDeletions()
+	}
+}
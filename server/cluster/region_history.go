@@ -0,0 +1,284 @@
+// Copyright 2021 TiKV Project Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cluster
+
+import (
+	"bytes"
+	"sync"
+	"time"
+	"unsafe"
+
+	"github.com/tikv/pd/server/core"
+)
+
+const (
+	// regionHistoryKeepTime is how long the lineage of a region is kept since
+	// its last change.
+	regionHistoryKeepTime = 24 * time.Hour
+	// maxRegionHistoryEvents is the max number of the events kept for a region.
+	maxRegionHistoryEvents = 32
+	// regionShrinkKeepTime is how long the key range a region gives up is kept
+	// to find the regions split from it.
+	regionShrinkKeepTime = 10 * time.Minute
+	// maxRegionLineageDepth is the max number of the regions followed to find
+	// the ancestors or the successor of a region.
+	maxRegionLineageDepth = 64
+)
+
+// The types of the region history events. The related region of the event is
+// the other region involved.
+const (
+	// RegionSplitFrom means the region is split from the related one.
+	RegionSplitFrom = "split-from"
+	// RegionSplitInto means the related region is split from the region.
+	RegionSplitInto = "split-into"
+	// RegionMergedInto means the region is merged into the related one.
+	RegionMergedInto = "merged-into"
+	// RegionMergedFrom means the related region is merged into the region.
+	RegionMergedFrom = "merged-from"
+	// RegionOverlappedBy means the region is overlapped by the related one,
+	// which is neither a split nor a merge.
+	RegionOverlappedBy = "overlapped-by"
+	// RegionOverlaps means the region overlaps the related one.
+	RegionOverlaps = "overlaps"
+)
+
+// RegionHistoryEvent is a change of the key range of a region.
+type RegionHistoryEvent struct {
+	Time     time.Time `json:"time"`
+	Type     string    `json:"type"`
+	RegionID uint64    `json:"region_id"`
+	// StartKey and EndKey are the key range of the region known by PD at the
+	// event.
+	StartKey string `json:"start_key"`
+	EndKey   string `json:"end_key"`
+	Version  uint64 `json:"version"`
+}
+
+// RegionHistory is the lineage of a region.
+type RegionHistory struct {
+	RegionID uint64                `json:"region_id"`
+	Events   []*RegionHistoryEvent `json:"events"`
+	// Ancestors are the regions the region is split from, the nearest first.
+	Ancestors []uint64 `json:"ancestors,omitempty"`
+	// Successor is the region the key range of the region goes to by the
+	// merges, which is empty if the region is not merged.
+	Successor uint64 `json:"successor,omitempty"`
+}
+
+// regionShrink is the key range a region gives up by a split.
+type regionShrink struct {
+	// region is the region after it gives up the key range.
+	region   *core.RegionInfo
+	startKey []byte
+	endKey   []byte
+	time     time.Time
+}
+
+// regionHistory is the index from the region ID to the lineage of the region,
+// which tells where the region goes after it is merged. The lineage is kept
+// in memory, and dropped once the region has not changed for a while.
+type regionHistory struct {
+	sync.RWMutex
+	regions map[uint64][]*RegionHistoryEvent
+	shrinks []*regionShrink
+}
+
+func newRegionHistory() *regionHistory {
+	return &regionHistory{regions: make(map[uint64][]*RegionHistoryEvent)}
+}
+
+// containsRange returns whether [start, end) contains [subStart, subEnd). An
+// empty end key means the end of the key space.
+func containsRange(start, end, subStart, subEnd []byte) bool {
+	if bytes.Compare(subStart, start) < 0 {
+		return false
+	}
+	if len(end) == 0 {
+		return true
+	}
+	return len(subEnd) > 0 && bytes.Compare(subEnd, end) <= 0
+}
+
+func (h *regionHistory) addEventLocked(region *core.RegionInfo, typ string, relatedID uint64, now time.Time) {
+	events := append(h.regions[region.GetID()], &RegionHistoryEvent{
+		Time:     now,
+		Type:     typ,
+		RegionID: relatedID,
+		StartKey: core.HexRegionKeyStr(region.GetStartKey()),
+		EndKey:   core.HexRegionKeyStr(region.GetEndKey()),
+		Version:  region.GetRegionEpoch().GetVersion(),
+	})
+	if len(events) > maxRegionHistoryEvents {
+		events = append(events[:0:0], events[len(events)-maxRegionHistoryEvents:]...)
+	}
+	h.regions[region.GetID()] = events
+}
+
+// observe records the lineage of the region from the cached one and the
+// regions it overlaps. A region is split from a region whose key range
+// contains it, either overlapping it or given up by the split earlier. A
+// region whose key range grows absorbs the regions it overlaps by the merge.
+func (h *regionHistory) observe(origin, region *core.RegionInfo, overlaps []*core.RegionInfo) {
+	if origin != nil && origin.GetRegionEpoch().GetVersion() == region.GetRegionEpoch().GetVersion() && len(overlaps) == 0 {
+		return
+	}
+	now := time.Now()
+	start, end := region.GetStartKey(), region.GetEndKey()
+	h.Lock()
+	defer h.Unlock()
+	if origin != nil {
+		originStart, originEnd := origin.GetStartKey(), origin.GetEndKey()
+		if bytes.Compare(start, originStart) > 0 {
+			h.shrinks = append(h.shrinks, &regionShrink{region: region, startKey: originStart, endKey: start, time: now})
+		}
+		if len(end) > 0 && (len(originEnd) == 0 || bytes.Compare(end, originEnd) < 0) {
+			h.shrinks = append(h.shrinks, &regionShrink{region: region, startKey: end, endKey: originEnd, time: now})
+		}
+	}
+	grows := origin != nil && !containsRange(origin.GetStartKey(), origin.GetEndKey(), start, end)
+	for _, item := range overlaps {
+		switch {
+		case origin == nil && containsRange(item.GetStartKey(), item.GetEndKey(), start, end):
+			h.addEventLocked(region, RegionSplitFrom, item.GetID(), now)
+			h.addEventLocked(item, RegionSplitInto, region.GetID(), now)
+		case grows && containsRange(start, end, item.GetStartKey(), item.GetEndKey()):
+			h.addEventLocked(item, RegionMergedInto, region.GetID(), now)
+			h.addEventLocked(region, RegionMergedFrom, item.GetID(), now)
+		default:
+			h.addEventLocked(item, RegionOverlappedBy, region.GetID(), now)
+			h.addEventLocked(region, RegionOverlaps, item.GetID(), now)
+		}
+	}
+	// The region missing from the cache may be a parent overlapped by its
+	// child earlier, which is not a new region.
+	if origin != nil || len(overlaps) > 0 || len(h.regions[region.GetID()]) > 0 {
+		return
+	}
+	for i := len(h.shrinks) - 1; i >= 0; i-- {
+		s := h.shrinks[i]
+		if s.region.GetID() != region.GetID() && containsRange(s.startKey, s.endKey, start, end) {
+			h.addEventLocked(region, RegionSplitFrom, s.region.GetID(), now)
+			h.addEventLocked(s.region, RegionSplitInto, region.GetID(), now)
+			return
+		}
+	}
+}
+
+// gc drops the lineage of the regions which have not changed for a while.
+func (h *regionHistory) gc(now time.Time) {
+	h.Lock()
+	defer h.Unlock()
+	for id, events := range h.regions {
+		if now.Sub(events[len(events)-1].Time) > regionHistoryKeepTime {
+			delete(h.regions, id)
+		}
+	}
+	i := 0
+	for i < len(h.shrinks) && now.Sub(h.shrinks[i].time) > regionShrinkKeepTime {
+		i++
+	}
+	h.shrinks = append(h.shrinks[:0:0], h.shrinks[i:]...)
+}
+
+// evict drops the lineage of the regions which have not changed since the
+// time, and returns the number of them.
+func (h *regionHistory) evict(before time.Time) int {
+	h.Lock()
+	defer h.Unlock()
+	var evicted int
+	for id, events := range h.regions {
+		if events[len(events)-1].Time.Before(before) {
+			delete(h.regions, id)
+			evicted++
+		}
+	}
+	return evicted
+}
+
+var regionHistoryEventSize = int64(unsafe.Sizeof(RegionHistoryEvent{}) + unsafe.Sizeof(uintptr(0)))
+
+// memoryUsage returns the estimated memory used by the lineage of the regions.
+func (h *regionHistory) memoryUsage() int64 {
+	h.RLock()
+	defer h.RUnlock()
+	var size int64
+	for _, events := range h.regions {
+		for _, e := range events {
+			size += regionHistoryEventSize + int64(len(e.Type)+len(e.StartKey)+len(e.EndKey))
+		}
+	}
+	return size
+}
+
+// lastEventLocked returns the latest event of the region in the types.
+func (h *regionHistory) lastEventLocked(regionID uint64, types ...string) *RegionHistoryEvent {
+	events := h.regions[regionID]
+	for i := len(events) - 1; i >= 0; i-- {
+		for _, typ := range types {
+			if events[i].Type == typ {
+				return events[i]
+			}
+		}
+	}
+	return nil
+}
+
+// get returns the lineage of the region, or nil if the region has no history.
+func (h *regionHistory) get(regionID uint64) *RegionHistory {
+	h.RLock()
+	defer h.RUnlock()
+	events, ok := h.regions[regionID]
+	if !ok {
+		return nil
+	}
+	history := &RegionHistory{RegionID: regionID}
+	for _, e := range events {
+		ev := *e
+		history.Events = append(history.Events, &ev)
+	}
+	visited := map[uint64]struct{}{regionID: {}}
+	for id := regionID; len(history.Ancestors) < maxRegionLineageDepth; {
+		e := h.lastEventLocked(id, RegionSplitFrom)
+		if e == nil {
+			break
+		}
+		if _, ok := visited[e.RegionID]; ok {
+			break
+		}
+		visited[e.RegionID] = struct{}{}
+		history.Ancestors = append(history.Ancestors, e.RegionID)
+		id = e.RegionID
+	}
+	visited = map[uint64]struct{}{regionID: {}}
+	for id, depth := regionID, 0; depth < maxRegionLineageDepth; depth++ {
+		e := h.lastEventLocked(id, RegionMergedInto, RegionOverlappedBy)
+		if e == nil {
+			break
+		}
+		if _, ok := visited[e.RegionID]; ok {
+			break
+		}
+		visited[e.RegionID] = struct{}{}
+		history.Successor = e.RegionID
+		id = e.RegionID
+	}
+	return history
+}
+
+// GetRegionHistory returns the lineage of the region, or nil if the region
+// has no history.
+func (c *RaftCluster) GetRegionHistory(regionID uint64) *RegionHistory {
+	return c.regionHistory.get(regionID)
+}
@@ -0,0 +1,250 @@
+// Copyright 2021 TiKV Project Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cluster
+
+import (
+	"context"
+	"encoding/hex"
+	"encoding/json"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/pingcap/kvproto/pkg/metapb"
+	"github.com/pingcap/log"
+	"github.com/tikv/pd/pkg/errs"
+	"github.com/tikv/pd/pkg/logutil"
+	"github.com/tikv/pd/server/config"
+	"github.com/tikv/pd/server/core"
+	"github.com/tikv/pd/server/statistics"
+	"go.uber.org/zap"
+)
+
+const (
+	warmUpRegionsPath = "warm_up_regions"
+	// maxWarmUpRegions is the max number of the regions loaded first when
+	// the leader starts.
+	maxWarmUpRegions = 100000
+	// warmUpRegionsPersistInterval is the interval to persist the regions
+	// loaded first.
+	warmUpRegionsPersistInterval = 5 * time.Minute
+	// regionLoadRetryInterval is the interval to retry loading the regions in
+	// the background after it fails.
+	regionLoadRetryInterval = time.Second
+)
+
+// The states of loading the regions when the leader starts.
+const (
+	// RegionLoadPriority means the regions in the system ranges and the hot
+	// regions are being loaded, and the heartbeats are not handled yet.
+	RegionLoadPriority = "loading-priority"
+	// RegionLoadBackground means the heartbeats are handled, and the other
+	// regions are being loaded in the background.
+	RegionLoadBackground = "loading-background"
+	// RegionLoadDone means all regions are loaded.
+	RegionLoadDone = "loaded"
+)
+
+// regionLoader tracks loading the regions from the storage when the leader
+// starts. With the prioritized loading, the leader starts to handle the
+// heartbeats once the regions persisted by the last leader as the warm-up
+// regions are loaded, rather than all regions.
+type regionLoader struct {
+	sync.RWMutex
+	state       string
+	loaded      int
+	lastPersist time.Time
+}
+
+func newRegionLoader() *regionLoader {
+	return &regionLoader{state: RegionLoadDone}
+}
+
+func (l *regionLoader) setState(state string) {
+	l.Lock()
+	defer l.Unlock()
+	l.state = state
+}
+
+func (l *regionLoader) add(n int) {
+	l.Lock()
+	defer l.Unlock()
+	l.loaded += n
+}
+
+func (l *regionLoader) getState() (string, int) {
+	l.RLock()
+	defer l.RUnlock()
+	return l.state, l.loaded
+}
+
+func (l *regionLoader) isDone() bool {
+	state, _ := l.getState()
+	return state == RegionLoadDone
+}
+
+// putLoadedRegion puts the region loaded from the storage unless a newer one
+// is cached, and returns the overlapped regions to delete from the storage.
+func (c *RaftCluster) putLoadedRegion(region *core.RegionInfo) []*core.RegionInfo {
+	overlaps, ok := c.core.PutRegionIfAbsent(region)
+	if ok {
+		c.regionLoader.add(1)
+	}
+	return overlaps
+}
+
+// loadPriorityRegions loads the warm-up regions persisted by the last leader.
+// The others are loaded by loadRestRegions after the cluster starts.
+func (c *RaftCluster) loadPriorityRegions() error {
+	c.regionLoader.setState(RegionLoadPriority)
+	ids, err := c.loadWarmUpRegions()
+	if err != nil {
+		return err
+	}
+	for _, id := range ids {
+		region := &metapb.Region{}
+		ok, err := c.storage.LoadRegion(id, region)
+		if err != nil {
+			return err
+		}
+		if !ok {
+			continue
+		}
+		for _, item := range c.putLoadedRegion(core.NewRegionInfo(region, nil)) {
+			if err := c.storage.DeleteRegion(item.GetMeta()); err != nil {
+				return err
+			}
+		}
+	}
+	c.regionLoader.setState(RegionLoadBackground)
+	return nil
+}
+
+// loadRestRegions loads all regions in the background, skipping the ones
+// already cached, and retries until it succeeds or the cluster stops.
+func (c *RaftCluster) loadRestRegions() {
+	defer logutil.LogPanic()
+	defer c.wg.Done()
+
+	ctx, cancel := context.WithCancel(c.ctx)
+	defer cancel()
+	go func() {
+		select {
+		case <-c.quit:
+			cancel()
+		case <-ctx.Done():
+		}
+	}()
+
+	start := time.Now()
+	for {
+		err := c.storage.LoadRegionsOnceWithContext(ctx, c.putLoadedRegion)
+		if err == nil {
+			break
+		}
+		if ctx.Err() != nil {
+			log.Info("loading regions in the background is stopped")
+			return
+		}
+		log.Warn("failed to load regions in the background", errs.ZapError(err))
+		select {
+		case <-ctx.Done():
+			log.Info("loading regions in the background is stopped")
+			return
+		case <-time.After(regionLoadRetryInterval):
+		}
+	}
+	c.regionLoader.setState(RegionLoadDone)
+	_, loaded := c.regionLoader.getState()
+	log.Info("load regions in the background",
+		zap.Int("loaded", loaded),
+		zap.Int("count", c.core.GetRegionCount()),
+		zap.Duration("cost", time.Since(start)),
+	)
+	// The IDs are checked with the loaded regions only when the cluster starts.
+	if err := c.checkClusterData(); err != nil {
+		log.Error("the metadata mismatches after all regions are loaded", errs.ZapError(err))
+	}
+}
+
+func (c *RaftCluster) loadWarmUpRegions() ([]uint64, error) {
+	value, err := c.storage.Load(warmUpRegionsPath)
+	if err != nil || value == "" {
+		return nil, err
+	}
+	var ids []uint64
+	if err := json.Unmarshal([]byte(value), &ids); err != nil {
+		return nil, errs.ErrJSONUnmarshal.Wrap(err).FastGenWithCause()
+	}
+	return ids, nil
+}
+
+// collectWarmUpRegions returns the regions in the system ranges and the hot
+// regions, which are loaded first when the leader starts.
+func (c *RaftCluster) collectWarmUpRegions() []uint64 {
+	ids := make(map[uint64]struct{})
+	for _, s := range c.opt.GetSystemRanges() {
+		startKeyHex, endKeyHex, err := config.ParseSystemRange(s)
+		if err != nil {
+			continue
+		}
+		startKey, _ := hex.DecodeString(startKeyHex)
+		endKey, _ := hex.DecodeString(endKeyHex)
+		for _, region := range c.core.ScanRange(startKey, endKey, maxWarmUpRegions) {
+			ids[region.GetID()] = struct{}{}
+		}
+	}
+	for _, kind := range []statistics.FlowKind{statistics.WriteFlow, statistics.ReadFlow} {
+		for _, peers := range c.hotStat.RegionStats(kind, c.opt.GetHotRegionCacheHitsThreshold()) {
+			for _, peer := range peers {
+				ids[peer.RegionID] = struct{}{}
+			}
+		}
+	}
+	regions := make([]uint64, 0, len(ids))
+	for id := range ids {
+		regions = append(regions, id)
+	}
+	sort.Slice(regions, func(i, j int) bool { return regions[i] < regions[j] })
+	if len(regions) > maxWarmUpRegions {
+		regions = regions[:maxWarmUpRegions]
+	}
+	return regions
+}
+
+// persistWarmUpRegions persists the warm-up regions periodically if the
+// prioritized loading is enabled and all regions are loaded.
+func (c *RaftCluster) persistWarmUpRegions(now time.Time) {
+	l := c.regionLoader
+	if !c.opt.GetPDServerConfig().EnablePrioritizedRegionLoading || !l.isDone() {
+		return
+	}
+	l.Lock()
+	if now.Sub(l.lastPersist) < warmUpRegionsPersistInterval {
+		l.Unlock()
+		return
+	}
+	l.lastPersist = now
+	l.Unlock()
+
+	regions := c.collectWarmUpRegions()
+	value, err := json.Marshal(regions)
+	if err != nil {
+		log.Error("failed to marshal warm-up regions", errs.ZapError(errs.ErrJSONMarshal.Wrap(err).FastGenWithCause()))
+		return
+	}
+	if err := c.storage.Save(warmUpRegionsPath, string(value)); err != nil {
+		log.Warn("failed to save warm-up regions", zap.Int("count", len(regions)), errs.ZapError(err))
+	}
+}
@@ -0,0 +1,109 @@
+// Copyright 2021 TiKV Project Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cluster
+
+import (
+	"sort"
+	"time"
+
+	"github.com/tikv/pd/server/statistics"
+)
+
+// regionStateNames are the names of the region states in a snapshot.
+var regionStateNames = map[statistics.RegionStatisticType]string{
+	statistics.MissPeer:    "miss-peer",
+	statistics.ExtraPeer:   "extra-peer",
+	statistics.DownPeer:    "down-peer",
+	statistics.PendingPeer: "pending-peer",
+	statistics.OfflinePeer: "offline-peer",
+	statistics.LearnerPeer: "learner-peer",
+	statistics.EmptyRegion: "empty-region",
+}
+
+// RegionStatsSnapshot is a snapshot of the region statistics of the cluster.
+type RegionStatsSnapshot struct {
+	Time        int64 `json:"time"`
+	RegionCount int   `json:"region_count"`
+	// States is the number of the regions in each abnormal state.
+	States   map[string]int      `json:"states"`
+	Stores   []*StoreRegionStats `json:"stores"`
+	HotRead  []*StoreHotSummary  `json:"hot_read"`
+	HotWrite []*StoreHotSummary  `json:"hot_write"`
+}
+
+// StoreRegionStats is the sizes of the regions on a store.
+type StoreRegionStats struct {
+	StoreID     uint64 `json:"store_id"`
+	RegionCount int    `json:"region_count"`
+	RegionSize  int64  `json:"region_size"`
+	LeaderCount int    `json:"leader_count"`
+	LeaderSize  int64  `json:"leader_size"`
+}
+
+// StoreHotSummary is the summary of the hot leaders on a store.
+type StoreHotSummary struct {
+	StoreID   uint64  `json:"store_id"`
+	Count     int     `json:"count"`
+	BytesRate float64 `json:"bytes_rate"`
+	KeysRate  float64 `json:"keys_rate"`
+	QueryRate float64 `json:"query_rate"`
+}
+
+// GetRegionStatsSnapshot returns a snapshot of the region statistics.
+func (c *RaftCluster) GetRegionStatsSnapshot() *RegionStatsSnapshot {
+	snapshot := &RegionStatsSnapshot{
+		Time:        time.Now().Unix(),
+		RegionCount: c.GetRegionCount(),
+		States:      make(map[string]int, len(regionStateNames)),
+	}
+	for typ, name := range regionStateNames {
+		snapshot.States[name] = len(c.GetRegionStatsByType(typ))
+	}
+	for _, store := range c.GetStores() {
+		if store.IsTombstone() {
+			continue
+		}
+		snapshot.Stores = append(snapshot.Stores, &StoreRegionStats{
+			StoreID:     store.GetID(),
+			RegionCount: store.GetRegionCount(),
+			RegionSize:  store.GetRegionSize(),
+			LeaderCount: store.GetLeaderCount(),
+			LeaderSize:  store.GetLeaderSize(),
+		})
+	}
+	sort.Slice(snapshot.Stores, func(i, j int) bool { return snapshot.Stores[i].StoreID < snapshot.Stores[j].StoreID })
+	snapshot.HotRead = summarizeHotPeers(c.GetHotReadRegions())
+	snapshot.HotWrite = summarizeHotPeers(c.GetHotWriteRegions())
+	return snapshot
+}
+
+// summarizeHotPeers summarizes the hot leaders, which are empty if the hot
+// region scheduler is not running.
+func summarizeHotPeers(infos *statistics.StoreHotPeersInfos) []*StoreHotSummary {
+	summaries := []*StoreHotSummary{}
+	if infos == nil {
+		return summaries
+	}
+	for storeID, stat := range infos.AsLeader {
+		summaries = append(summaries, &StoreHotSummary{
+			StoreID:   storeID,
+			Count:     stat.Count,
+			BytesRate: stat.TotalBytesRate,
+			KeysRate:  stat.TotalKeysRate,
+			QueryRate: stat.TotalQueryRate,
+		})
+	}
+	sort.Slice(summaries, func(i, j int) bool { return summaries[i].StoreID < summaries[j].StoreID })
+	return summaries
+}
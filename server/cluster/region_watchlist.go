@@ -0,0 +1,361 @@
+// Copyright 2021 TiKV Project Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cluster
+
+import (
+	"encoding/json"
+	"path"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/pingcap/log"
+	"github.com/tikv/pd/pkg/cache"
+	"github.com/tikv/pd/pkg/errs"
+	"github.com/tikv/pd/server/core"
+	"github.com/tikv/pd/server/schedule/operator"
+	"github.com/tikv/pd/server/statistics"
+	"go.uber.org/zap"
+)
+
+const (
+	regionWatchlistPath = "region_watchlist"
+	// maxWatchedRegionAttempts is the number of the latest operators kept for
+	// a watched region.
+	maxWatchedRegionAttempts = 5
+)
+
+// RegionTrouble is an abnormal state of a region.
+type RegionTrouble string
+
+const (
+	// TroubleNoLeader means the leader of the region is on a disconnected
+	// store, so the region may have no leader now.
+	TroubleNoLeader RegionTrouble = "no-leader"
+	// TroubleDownPeer means the region has down peers.
+	TroubleDownPeer RegionTrouble = "down-peer"
+	// TroublePendingPeer means the region has pending peers.
+	TroublePendingPeer RegionTrouble = "pending-peer"
+)
+
+// troubleWeights rank the watched regions. A region without a leader is
+// unavailable, and a region with down peers may lose its quorum.
+var troubleWeights = map[RegionTrouble]int{
+	TroubleNoLeader:    100,
+	TroubleDownPeer:    10,
+	TroublePendingPeer: 1,
+}
+
+// quorumAtRiskWeight is added to the priority if half of the voters are down.
+const quorumAtRiskWeight = 50
+
+// RegionDiagnosis is why a watched region is not recovered yet.
+type RegionDiagnosis string
+
+const (
+	// DiagnosisOperatorRunning means an operator is fixing the region.
+	DiagnosisOperatorRunning RegionDiagnosis = "operator-running"
+	// DiagnosisOperatorFailed means the latest operator of the region failed.
+	DiagnosisOperatorFailed RegionDiagnosis = "operator-failed"
+	// DiagnosisWaitingForLimit means the checker is waiting for the schedule
+	// limit to create an operator.
+	DiagnosisWaitingForLimit RegionDiagnosis = "waiting-for-limit"
+	// DiagnosisNoOperator means no operator is created for the region, which
+	// usually needs a manual intervention.
+	DiagnosisNoOperator RegionDiagnosis = "no-operator"
+)
+
+// OperatorAttempt is an operator created for a watched region.
+type OperatorAttempt struct {
+	Desc       string    `json:"desc"`
+	Kind       string    `json:"kind"`
+	CreateTime time.Time `json:"create_time"`
+	Status     string    `json:"status"`
+	// Reason is why the operator failed. It is empty if the operator is
+	// running or succeeds.
+	Reason string `json:"reason,omitempty"`
+}
+
+// WatchedRegion is a region which is in trouble for longer than the
+// threshold.
+type WatchedRegion struct {
+	RegionID     uint64          `json:"region_id"`
+	StartKey     string          `json:"start_key"`
+	EndKey       string          `json:"end_key"`
+	Troubles     []RegionTrouble `json:"troubles"`
+	DownPeers    int             `json:"down_peers"`
+	PendingPeers int             `json:"pending_peers"`
+	// QuorumAtRisk is whether at least half of the voters are down.
+	QuorumAtRisk bool `json:"quorum_at_risk"`
+	// Since is when the region is found in trouble.
+	Since     time.Time          `json:"since"`
+	Priority  int                `json:"priority"`
+	Diagnosis RegionDiagnosis    `json:"diagnosis"`
+	Attempts  []*OperatorAttempt `json:"attempts"`
+}
+
+func (w *WatchedRegion) clone() *WatchedRegion {
+	nw := *w
+	nw.Troubles = append(w.Troubles[:0:0], w.Troubles...)
+	nw.Attempts = make([]*OperatorAttempt, 0, len(w.Attempts))
+	for _, a := range w.Attempts {
+		na := *a
+		nw.Attempts = append(nw.Attempts, &na)
+	}
+	return &nw
+}
+
+// regionWatchlist tracks the regions in trouble. A region is watched once it
+// is in trouble for longer than the threshold, and it is removed once it
+// recovers. The watched regions are persisted, so the time they are found in
+// trouble is kept after the leader changes.
+type regionWatchlist struct {
+	sync.RWMutex
+	cluster *RaftCluster
+	// suspects are the regions in trouble which are not watched yet, with the
+	// time they are found in trouble.
+	suspects map[uint64]time.Time
+	watched  map[uint64]*WatchedRegion
+}
+
+func newRegionWatchlist(cluster *RaftCluster) *regionWatchlist {
+	return &regionWatchlist{
+		cluster:  cluster,
+		suspects: make(map[uint64]time.Time),
+		watched:  make(map[uint64]*WatchedRegion),
+	}
+}
+
+func regionWatchlistKey(regionID uint64) string {
+	return strconv.FormatUint(regionID, 10)
+}
+
+func (w *regionWatchlist) load() error {
+	w.Lock()
+	defer w.Unlock()
+	return w.cluster.storage.LoadRangeByPrefix(regionWatchlistPath+"/", func(k, v string) {
+		r := &WatchedRegion{}
+		if err := json.Unmarshal([]byte(v), r); err != nil {
+			log.Error("failed to unmarshal watched region", zap.String("key", k), errs.ZapError(errs.ErrJSONUnmarshal.Wrap(err).FastGenWithCause()))
+			return
+		}
+		w.watched[r.RegionID] = r
+	})
+}
+
+// regionTroubles is the troubles of a region found in a tick.
+type regionTroubles struct {
+	region   *core.RegionInfo
+	troubles map[RegionTrouble]struct{}
+}
+
+// collectTroubles returns the regions in trouble.
+func (w *regionWatchlist) collectTroubles() map[uint64]*regionTroubles {
+	c := w.cluster
+	found := make(map[uint64]*regionTroubles)
+	add := func(region *core.RegionInfo, trouble RegionTrouble) {
+		t, ok := found[region.GetID()]
+		if !ok {
+			t = &regionTroubles{region: region, troubles: make(map[RegionTrouble]struct{})}
+			found[region.GetID()] = t
+		}
+		t.troubles[trouble] = struct{}{}
+	}
+	for _, region := range c.GetRegionStatsByType(statistics.DownPeer) {
+		add(region, TroubleDownPeer)
+	}
+	for _, region := range c.GetRegionStatsByType(statistics.PendingPeer) {
+		add(region, TroublePendingPeer)
+	}
+	for _, store := range c.GetStores() {
+		if store.IsTombstone() || !store.IsDisconnected() {
+			continue
+		}
+		for _, region := range c.core.GetStoreRegions(store.GetID()) {
+			if region.GetLeader().GetStoreId() == store.GetID() {
+				add(region, TroubleNoLeader)
+			}
+		}
+	}
+	return found
+}
+
+// tick updates the watchlist with the regions in trouble now.
+func (w *regionWatchlist) tick(now time.Time) {
+	threshold := w.cluster.opt.GetTroubleRegionThreshold()
+	if threshold == 0 {
+		return
+	}
+	found := w.collectTroubles()
+
+	w.Lock()
+	defer w.Unlock()
+	for id := range w.suspects {
+		if _, ok := found[id]; !ok {
+			delete(w.suspects, id)
+		}
+	}
+	for id, r := range w.watched {
+		if _, ok := found[id]; ok {
+			continue
+		}
+		if err := w.cluster.storage.Remove(path.Join(regionWatchlistPath, regionWatchlistKey(id))); err != nil {
+			log.Warn("failed to remove watched region", zap.Uint64("region-id", id), errs.ZapError(err))
+			continue
+		}
+		delete(w.watched, id)
+		log.Info("region recovers from trouble", zap.Uint64("region-id", id), zap.Duration("duration", now.Sub(r.Since)))
+	}
+	for id, t := range found {
+		r, ok := w.watched[id]
+		if !ok {
+			since, ok := w.suspects[id]
+			if !ok {
+				w.suspects[id] = now
+				continue
+			}
+			if now.Sub(since) < threshold {
+				continue
+			}
+			delete(w.suspects, id)
+			r = &WatchedRegion{RegionID: id, Since: since}
+			log.Warn("region is in trouble for too long", zap.Uint64("region-id", id), zap.Duration("duration", now.Sub(since)))
+		}
+		nr := r.clone()
+		w.update(nr, t)
+		if ok && watchedRegionEqual(r, nr) {
+			continue
+		}
+		if err := w.cluster.storage.SaveJSON(regionWatchlistPath, regionWatchlistKey(id), nr); err != nil {
+			log.Warn("failed to save watched region", zap.Uint64("region-id", id), errs.ZapError(err))
+			continue
+		}
+		w.watched[id] = nr
+	}
+}
+
+// update refreshes the troubles, the attempts and the diagnosis of the
+// watched region.
+func (w *regionWatchlist) update(r *WatchedRegion, t *regionTroubles) {
+	region := t.region
+	r.StartKey = core.HexRegionKeyStr(region.GetStartKey())
+	r.EndKey = core.HexRegionKeyStr(region.GetEndKey())
+	r.Troubles = r.Troubles[:0]
+	r.Priority = 0
+	for _, trouble := range []RegionTrouble{TroubleNoLeader, TroubleDownPeer, TroublePendingPeer} {
+		if _, ok := t.troubles[trouble]; ok {
+			r.Troubles = append(r.Troubles, trouble)
+			r.Priority += troubleWeights[trouble]
+		}
+	}
+	r.DownPeers = len(region.GetDownPeers())
+	r.PendingPeers = len(region.GetPendingPeers())
+	downVoters := 0
+	for _, p := range region.GetDownPeers() {
+		if region.GetStoreVoter(p.GetPeer().GetStoreId()) != nil {
+			downVoters++
+		}
+	}
+	r.QuorumAtRisk = downVoters > 0 && downVoters*2 >= len(region.GetVoters())
+	if r.QuorumAtRisk {
+		r.Priority += quorumAtRiskWeight
+	}
+
+	co := w.cluster.coordinator
+	if co == nil {
+		r.Diagnosis = DiagnosisNoOperator
+		return
+	}
+	if s := co.opController.GetOperatorStatus(region.GetID()); s != nil && s.Op != nil {
+		recordAttempt(r, s.Op)
+	}
+	r.Diagnosis = diagnose(r, co.checkers.GetWaitingRegions())
+}
+
+// recordAttempt adds the operator to the attempts, or updates its status if
+// it is already recorded.
+func recordAttempt(r *WatchedRegion, op *operator.Operator) {
+	attempt := &OperatorAttempt{
+		Desc:       op.Desc(),
+		Kind:       op.Kind().String(),
+		CreateTime: op.GetCreateTime(),
+		Status:     operator.OpStatusToString(op.Status()),
+	}
+	if status := op.Status(); operator.IsEndStatus(status) && status != operator.SUCCESS {
+		attempt.Reason = op.AdditionalInfos["reject-reason"]
+		if attempt.Reason == "" {
+			attempt.Reason = "operator " + attempt.Status
+		}
+	}
+	if n := len(r.Attempts); n > 0 && r.Attempts[n-1].Desc == attempt.Desc && r.Attempts[n-1].CreateTime.Equal(attempt.CreateTime) {
+		r.Attempts[n-1] = attempt
+		return
+	}
+	r.Attempts = append(r.Attempts, attempt)
+	if len(r.Attempts) > maxWatchedRegionAttempts {
+		r.Attempts = r.Attempts[len(r.Attempts)-maxWatchedRegionAttempts:]
+	}
+}
+
+func diagnose(r *WatchedRegion, waiting []*cache.Item) RegionDiagnosis {
+	if n := len(r.Attempts); n > 0 {
+		last := r.Attempts[n-1]
+		if last.Reason != "" {
+			return DiagnosisOperatorFailed
+		}
+		if last.Status != operator.OpStatusToString(operator.SUCCESS) {
+			return DiagnosisOperatorRunning
+		}
+	}
+	for _, item := range waiting {
+		if item.Key == r.RegionID {
+			return DiagnosisWaitingForLimit
+		}
+	}
+	return DiagnosisNoOperator
+}
+
+func watchedRegionEqual(a, b *WatchedRegion) bool {
+	ja, _ := json.Marshal(a)
+	jb, _ := json.Marshal(b)
+	return string(ja) == string(jb)
+}
+
+// list returns the watched regions, the higher priority first, and the longer
+// in trouble first for the same priority.
+func (w *regionWatchlist) list() []*WatchedRegion {
+	w.RLock()
+	defer w.RUnlock()
+	regions := make([]*WatchedRegion, 0, len(w.watched))
+	for _, r := range w.watched {
+		regions = append(regions, r.clone())
+	}
+	sort.Slice(regions, func(i, j int) bool {
+		if regions[i].Priority != regions[j].Priority {
+			return regions[i].Priority > regions[j].Priority
+		}
+		if !regions[i].Since.Equal(regions[j].Since) {
+			return regions[i].Since.Before(regions[j].Since)
+		}
+		return regions[i].RegionID < regions[j].RegionID
+	})
+	return regions
+}
+
+// GetRegionWatchlist returns the regions in trouble for longer than the
+// threshold, sorted by the priority to triage.
+func (c *RaftCluster) GetRegionWatchlist() []*WatchedRegion {
+	return c.regionWatchlist.list()
+}
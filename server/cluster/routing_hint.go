@@ -0,0 +1,109 @@
+// Copyright 2021 TiKV Project Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cluster
+
+import (
+	"time"
+
+	"github.com/tikv/pd/server/core"
+)
+
+const (
+	// routingHintWindow is how long the split or the merge of a region is
+	// hinted to the clients looking it up.
+	routingHintWindow = time.Minute
+	// maxRoutingHintNeighbors is the max number of the neighbors hinted on
+	// each side of a region.
+	maxRoutingHintNeighbors = 8
+)
+
+// RoutingHintRegion is a region in a routing hint.
+type RoutingHintRegion struct {
+	ID       uint64 `json:"id"`
+	StartKey []byte `json:"start_key"`
+	EndKey   []byte `json:"end_key"`
+	ConfVer  uint64 `json:"conf_ver"`
+	Version  uint64 `json:"version"`
+}
+
+// RoutingHint is the contiguous regions split or merged recently around a
+// region. The regions cached by the clients in the key range of the hint are
+// stale if they are not in the hint, so they can be invalidated before the
+// requests to them fail.
+type RoutingHint struct {
+	// Regions are ordered by the keys, including the region looked up.
+	Regions []*RoutingHintRegion `json:"regions"`
+	// Watermark is the max version of the regions. If a cached region in the
+	// key range has a version not less than it, the hint is older than the
+	// cache and should be ignored.
+	Watermark uint64 `json:"watermark"`
+}
+
+// changedSince returns whether the key range of the region has changed since
+// the time.
+func (h *regionHistory) changedSince(regionID uint64, since time.Time) bool {
+	h.RLock()
+	defer h.RUnlock()
+	events := h.regions[regionID]
+	return len(events) > 0 && !events[len(events)-1].Time.Before(since)
+}
+
+// GetRoutingHint returns the routing hint of the region, or nil if neither the
+// region nor its neighbors are split or merged recently.
+func (c *RaftCluster) GetRoutingHint(region *core.RegionInfo) *RoutingHint {
+	since := time.Now().Add(-routingHintWindow)
+	if !c.regionHistory.changedSince(region.GetID(), since) {
+		return nil
+	}
+	var left, right []*core.RegionInfo
+	for r := region; len(left) < maxRoutingHintNeighbors; {
+		prev, _ := c.GetAdjacentRegions(r)
+		if prev == nil || !c.regionHistory.changedSince(prev.GetID(), since) {
+			break
+		}
+		left, r = append(left, prev), prev
+	}
+	for r := region; len(right) < maxRoutingHintNeighbors; {
+		_, next := c.GetAdjacentRegions(r)
+		if next == nil || !c.regionHistory.changedSince(next.GetID(), since) {
+			break
+		}
+		right, r = append(right, next), next
+	}
+	if len(left) == 0 && len(right) == 0 {
+		return nil
+	}
+
+	regions := make([]*core.RegionInfo, 0, len(left)+len(right)+1)
+	for i := len(left) - 1; i >= 0; i-- {
+		regions = append(regions, left[i])
+	}
+	regions = append(regions, region)
+	regions = append(regions, right...)
+	hint := &RoutingHint{Regions: make([]*RoutingHintRegion, 0, len(regions))}
+	for _, r := range regions {
+		epoch := r.GetRegionEpoch()
+		hint.Regions = append(hint.Regions, &RoutingHintRegion{
+			ID:       r.GetID(),
+			StartKey: r.GetStartKey(),
+			EndKey:   r.GetEndKey(),
+			ConfVer:  epoch.GetConfVer(),
+			Version:  epoch.GetVersion(),
+		})
+		if epoch.GetVersion() > hint.Watermark {
+			hint.Watermark = epoch.GetVersion()
+		}
+	}
+	return hint
+}
@@ -0,0 +1,199 @@
+// Copyright 2021 TiKV Project Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cluster
+
+import (
+	"github.com/pingcap/kvproto/pkg/metapb"
+	"github.com/tikv/pd/pkg/errs"
+	"github.com/tikv/pd/server/core"
+	"github.com/tikv/pd/server/kv"
+	"github.com/tikv/pd/server/schedule"
+	"github.com/tikv/pd/server/schedule/operator"
+)
+
+// MaxSchedulerPreviewCount is the max number of the operators in a preview.
+const MaxSchedulerPreviewCount = 100
+
+// SchedulerPreview is the operators a scheduler would produce next.
+type SchedulerPreview struct {
+	Scheduler string `json:"scheduler"`
+	// Allowed is whether the scheduler is allowed to schedule now, which is
+	// false if the schedule limit is reached. The operators are previewed
+	// anyway.
+	Allowed   bool                 `json:"allowed"`
+	Operators []*operator.Operator `json:"operators"`
+}
+
+// previewCluster is a snapshot of the cluster to run a scheduler against. It
+// shares the options, the rules and the statistics with the cluster, and
+// copies the stores and the regions, so the operators can be applied to it
+// to make the next ones different. The peer IDs allocated in it are only
+// meaningful in the preview.
+type previewCluster struct {
+	*RaftCluster
+	lastID uint64
+}
+
+func newPreviewCluster(c *RaftCluster) *previewCluster {
+	c.RLock()
+	snapshot := &RaftCluster{
+		ctx:             c.ctx,
+		core:            core.NewBasicCluster(),
+		opt:             c.opt,
+		storage:         core.NewStorage(kv.NewMemoryKV()),
+		ruleManager:     c.ruleManager,
+		regionLabeler:   c.regionLabeler,
+		keyRangeManager: c.keyRangeManager,
+		affinityManager: c.affinityManager,
+		hotStat:         c.hotStat,
+	}
+	c.RUnlock()
+	for _, store := range c.GetStores() {
+		snapshot.core.PutStore(store)
+	}
+	for _, region := range c.GetRegions() {
+		snapshot.core.PutRegion(region)
+	}
+	return &previewCluster{RaftCluster: snapshot}
+}
+
+// AllocID allocates the IDs only used in the preview.
+func (c *previewCluster) AllocID() (uint64, error) {
+	c.lastID++
+	return c.lastID, nil
+}
+
+// RemoveScheduler does nothing, the scheduler in the preview is temporary.
+func (c *previewCluster) RemoveScheduler(name string) error {
+	return nil
+}
+
+// AddSuspectRegions does nothing, the regions in the preview are not real.
+func (c *previewCluster) AddSuspectRegions(ids ...uint64) {}
+
+// applyOperator applies all steps of the operator to the region as if it was
+// finished.
+func (c *previewCluster) applyOperator(op *operator.Operator) {
+	origin := c.core.GetRegion(op.RegionID())
+	if origin == nil {
+		return
+	}
+	region := origin
+	for i := 0; i < op.Len(); i++ {
+		region = applyPreviewStep(region, op.Step(i))
+	}
+	c.core.PutRegion(region)
+	for id := range region.GetStoreIds() {
+		c.updateStoreStatusLocked(id)
+	}
+	for id := range origin.GetStoreIds() {
+		c.updateStoreStatusLocked(id)
+	}
+}
+
+func applyPreviewStep(region *core.RegionInfo, step operator.OpStep) *core.RegionInfo {
+	switch s := step.(type) {
+	case operator.TransferLeader:
+		if peer := region.GetStorePeer(s.ToStore); peer != nil {
+			return region.Clone(core.WithLeader(peer))
+		}
+	case operator.AddPeer:
+		return withPreviewPeer(region, s.ToStore, s.PeerID, metapb.PeerRole_Voter)
+	case operator.AddLightPeer:
+		return withPreviewPeer(region, s.ToStore, s.PeerID, metapb.PeerRole_Voter)
+	case operator.AddLearner:
+		return withPreviewPeer(region, s.ToStore, s.PeerID, metapb.PeerRole_Learner)
+	case operator.AddLightLearner:
+		return withPreviewPeer(region, s.ToStore, s.PeerID, metapb.PeerRole_Learner)
+	case operator.PromoteLearner:
+		return withPreviewPeer(region, s.ToStore, s.PeerID, metapb.PeerRole_Voter)
+	case operator.DemoteFollower:
+		return withPreviewPeer(region, s.ToStore, s.PeerID, metapb.PeerRole_Learner)
+	case operator.ChangePeerV2Enter:
+		for _, pl := range s.PromoteLearners {
+			region = withPreviewPeer(region, pl.ToStore, pl.PeerID, metapb.PeerRole_Voter)
+		}
+		for _, dv := range s.DemoteVoters {
+			region = withPreviewPeer(region, dv.ToStore, dv.PeerID, metapb.PeerRole_Learner)
+		}
+	case operator.RemovePeer:
+		if region.GetStorePeer(s.FromStore) != nil && region.GetLeader().GetStoreId() != s.FromStore {
+			return region.Clone(core.WithRemoveStorePeer(s.FromStore))
+		}
+	}
+	// The merge and the split are not applied.
+	return region
+}
+
+// withPreviewPeer puts the peer with the role on the store.
+func withPreviewPeer(region *core.RegionInfo, storeID, peerID uint64, role metapb.PeerRole) *core.RegionInfo {
+	peer := &metapb.Peer{Id: peerID, StoreId: storeID, Role: role}
+	if region.GetStorePeer(storeID) == nil {
+		return region.Clone(core.WithAddPeer(peer))
+	}
+	return region.Clone(core.WithRemoveStorePeer(storeID), core.WithAddPeer(peer))
+}
+
+// previewScheduler runs a temporary scheduler with the same config as the
+// named one against a snapshot of the cluster, and returns at most count
+// operators it would produce. The running operators are applied to the
+// snapshot first, and each operator produced is applied before the next
+// round. Nothing is added to the operator controller.
+func (c *coordinator) previewScheduler(name string, count int) (*SchedulerPreview, error) {
+	c.RLock()
+	s, ok := c.schedulers[name]
+	c.RUnlock()
+	if !ok {
+		return nil, errs.ErrSchedulerNotFound.FastGenByArgs()
+	}
+	data, err := s.EncodeConfig()
+	if err != nil {
+		return nil, err
+	}
+	snapshot := newPreviewCluster(c.cluster)
+	for _, op := range c.opController.GetOperators() {
+		snapshot.applyOperator(op)
+	}
+	oc := schedule.NewOperatorController(c.ctx, snapshot, nil)
+	scheduler, err := schedule.CreateScheduler(s.GetType(), oc, snapshot.storage, schedule.ConfigJSONDecoder(data))
+	if err != nil {
+		return nil, err
+	}
+	if err := scheduler.Prepare(snapshot); err != nil {
+		return nil, err
+	}
+	defer scheduler.Cleanup(snapshot)
+
+	preview := &SchedulerPreview{
+		Scheduler: name,
+		Allowed:   s.AllowSchedule(),
+		Operators: []*operator.Operator{},
+	}
+	for retry := 0; len(preview.Operators) < count && retry < maxScheduleRetries; {
+		ops := scheduler.Schedule(snapshot)
+		if len(ops) == 0 {
+			retry++
+			continue
+		}
+		retry = 0
+		for _, op := range ops {
+			snapshot.applyOperator(op)
+		}
+		preview.Operators = append(preview.Operators, ops...)
+	}
+	if len(preview.Operators) > count {
+		preview.Operators = preview.Operators[:count]
+	}
+	return preview, nil
+}
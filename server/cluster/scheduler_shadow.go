@@ -0,0 +1,180 @@
+// Copyright 2021 TiKV Project Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cluster
+
+import (
+	"sync"
+	"time"
+
+	"github.com/pingcap/log"
+	"github.com/tikv/pd/pkg/errs"
+	"github.com/tikv/pd/server/schedule/operator"
+	"go.uber.org/zap"
+)
+
+// maxRecentShadowOperators is the number of the latest operators kept for a
+// scheduler in the shadow mode.
+const maxRecentShadowOperators = 100
+
+// ShadowOperator is an operator produced by a scheduler in the shadow mode.
+type ShadowOperator struct {
+	Time     time.Time `json:"time"`
+	Desc     string    `json:"desc"`
+	Kind     string    `json:"kind"`
+	RegionID uint64    `json:"region_id"`
+	// Operator is the detail of the operator, including the steps.
+	Operator string `json:"operator"`
+}
+
+// SchedulerShadowStatus is the operators a scheduler has produced in the
+// shadow mode, which are never dispatched.
+type SchedulerShadowStatus struct {
+	Scheduler string `json:"scheduler"`
+	Shadow    bool   `json:"shadow"`
+	// Since is when the scheduler enters the shadow mode.
+	Since time.Time `json:"since,omitempty"`
+	Total uint64    `json:"total"`
+	// Counts is the number of the operators by the description, which is the
+	// same as the type of the operators in the live metrics.
+	Counts map[string]uint64 `json:"counts"`
+	// Recent are the latest operators, the newest first.
+	Recent []*ShadowOperator `json:"recent"`
+}
+
+// shadowRecorder records the operators produced by a scheduler in the shadow
+// mode.
+type shadowRecorder struct {
+	sync.RWMutex
+	shadow bool
+	since  time.Time
+	total  uint64
+	counts map[string]uint64
+	recent []*ShadowOperator
+}
+
+func (r *shadowRecorder) isShadow() bool {
+	r.RLock()
+	defer r.RUnlock()
+	return r.shadow
+}
+
+// setShadow switches the shadow mode. The recorded operators are reset when
+// the scheduler enters the shadow mode.
+func (r *shadowRecorder) setShadow(shadow bool) {
+	r.Lock()
+	defer r.Unlock()
+	if shadow == r.shadow {
+		return
+	}
+	r.shadow = shadow
+	if shadow {
+		r.since = time.Now()
+		r.total = 0
+		r.counts = make(map[string]uint64)
+		r.recent = nil
+	}
+}
+
+// record logs and counts the operators, and cancels them, so they are never
+// dispatched and the scheduler doesn't wait for them.
+func (r *shadowRecorder) record(scheduler string, ops []*operator.Operator) {
+	r.Lock()
+	defer r.Unlock()
+	now := time.Now()
+	for _, op := range ops {
+		op.Cancel()
+		log.Info("shadow scheduler produces operator", zap.String("scheduler", scheduler), zap.Stringer("operator", op))
+		schedulerShadowOperatorCounter.WithLabelValues(scheduler, op.Desc()).Inc()
+		r.total++
+		r.counts[op.Desc()]++
+		r.recent = append(r.recent, &ShadowOperator{
+			Time:     now,
+			Desc:     op.Desc(),
+			Kind:     op.Kind().String(),
+			RegionID: op.RegionID(),
+			Operator: op.String(),
+		})
+	}
+	if len(r.recent) > maxRecentShadowOperators {
+		r.recent = append(r.recent[:0:0], r.recent[len(r.recent)-maxRecentShadowOperators:]...)
+	}
+}
+
+func (r *shadowRecorder) status(scheduler string) *SchedulerShadowStatus {
+	r.RLock()
+	defer r.RUnlock()
+	status := &SchedulerShadowStatus{
+		Scheduler: scheduler,
+		Shadow:    r.shadow,
+		Since:     r.since,
+		Total:     r.total,
+		Counts:    make(map[string]uint64, len(r.counts)),
+		Recent:    make([]*ShadowOperator, 0, len(r.recent)),
+	}
+	for desc, count := range r.counts {
+		status.Counts[desc] = count
+	}
+	for i := len(r.recent) - 1; i >= 0; i-- {
+		op := *r.recent[i]
+		status.Recent = append(status.Recent, &op)
+	}
+	return status
+}
+
+// setSchedulerShadow switches the shadow mode of the scheduler, in which it
+// computes the operators every tick without dispatching them.
+func (c *coordinator) setSchedulerShadow(name string, shadow bool) error {
+	c.Lock()
+	defer c.Unlock()
+	if c.cluster == nil {
+		return errs.ErrNotBootstrapped.FastGenByArgs()
+	}
+	s, ok := c.schedulers[name]
+	if !ok {
+		return errs.ErrSchedulerNotFound.FastGenByArgs()
+	}
+	opt := c.cluster.opt
+	v := opt.GetScheduleConfig().Clone()
+	i, err := c.findOptScheduler(v, name)
+	if err != nil {
+		return err
+	}
+	if i < 0 {
+		return errs.ErrSchedulerNotFound.FastGenByArgs()
+	}
+	v.Schedulers[i].Shadow = shadow
+	opt.SetScheduleConfig(v)
+	if err := opt.Persist(c.cluster.storage); err != nil {
+		log.Error("the option can not persist scheduler config", errs.ZapError(err))
+		return err
+	}
+	s.shadow.setShadow(shadow)
+	log.Info("scheduler shadow mode is changed", zap.String("scheduler-name", name), zap.Bool("shadow", shadow))
+	return nil
+}
+
+// getSchedulerShadowStatus returns the operators the scheduler has produced
+// in the shadow mode.
+func (c *coordinator) getSchedulerShadowStatus(name string) (*SchedulerShadowStatus, error) {
+	c.RLock()
+	defer c.RUnlock()
+	if c.cluster == nil {
+		return nil, errs.ErrNotBootstrapped.FastGenByArgs()
+	}
+	s, ok := c.schedulers[name]
+	if !ok {
+		return nil, errs.ErrSchedulerNotFound.FastGenByArgs()
+	}
+	return s.shadow.status(name), nil
+}
@@ -0,0 +1,49 @@
+// Copyright 2021 TiKV Project Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cluster
+
+import (
+	"context"
+	"time"
+
+	"github.com/pingcap/kvproto/pkg/metapb"
+	"github.com/tikv/pd/server/config"
+	"github.com/tikv/pd/server/core"
+	"github.com/tikv/pd/server/kv"
+)
+
+// NewShadowCluster creates a cluster which only runs the heartbeat pipeline
+// for the replay. It keeps the metadata in memory instead of etcd, and has no
+// coordinator, so no operator is created or dispatched. The background jobs
+// of it are stopped when the context is canceled.
+func NewShadowCluster(ctx context.Context, opt *config.PersistOptions) *RaftCluster {
+	c := &RaftCluster{ctx: ctx, quit: make(chan struct{})}
+	c.InitCluster(nil, opt, core.NewStorage(kv.NewMemoryKV()), core.NewBasicCluster())
+	return c
+}
+
+// PutShadowStore puts the store into the cluster without the checks of
+// PutStore, which is used to restore the recorded stores in the shadow
+// cluster.
+func (c *RaftCluster) PutShadowStore(store *metapb.Store) {
+	c.Lock()
+	defer c.Unlock()
+	c.core.PutStore(core.NewStoreInfo(store, core.SetLastHeartbeatTS(time.Now())))
+}
+
+// HandleShadowRegionHeartbeat processes the region heartbeat like
+// HandleRegionHeartbeat, but doesn't dispatch the operators.
+func (c *RaftCluster) HandleShadowRegionHeartbeat(region *core.RegionInfo) error {
+	return c.processRegionHeartbeat(region)
+}
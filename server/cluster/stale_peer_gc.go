@@ -0,0 +1,267 @@
+// Copyright 2021 TiKV Project Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cluster
+
+import (
+	"encoding/json"
+	"path"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/pingcap/kvproto/pkg/metapb"
+	"github.com/pingcap/log"
+	"github.com/tikv/pd/pkg/errs"
+	"github.com/tikv/pd/server/core"
+	"go.uber.org/zap"
+)
+
+const (
+	stalePeersPath = "stale_peers"
+	// maxPublishedStalePeers is the max number of the removed peers published
+	// in a store heartbeat response.
+	maxPublishedStalePeers = 64
+)
+
+// StalePeer is a peer removed by PD which may linger on its store. It is
+// authoritative that the peer no longer serves the key range, so the store
+// can destroy it.
+type StalePeer struct {
+	// Version is the version of the removed peer in its store, which
+	// increases monotonically.
+	Version     uint64              `json:"version"`
+	RegionID    uint64              `json:"region_id"`
+	PeerID      uint64              `json:"peer_id"`
+	StartKey    string              `json:"start_key"`
+	EndKey      string              `json:"end_key"`
+	RegionEpoch *metapb.RegionEpoch `json:"region_epoch"`
+	RemoveTime  time.Time           `json:"remove_time"`
+}
+
+// StoreStalePeers is the removed peers of a store which are not acknowledged
+// yet.
+type StoreStalePeers struct {
+	StoreID uint64 `json:"store_id"`
+	// Version is the version of the latest removed peer of the store.
+	Version uint64 `json:"version"`
+	// AckedVersion is the latest version the store has acknowledged.
+	AckedVersion uint64       `json:"acked_version"`
+	AckTime      time.Time    `json:"ack_time,omitempty"`
+	Peers        []*StalePeer `json:"peers"`
+	// Overdue is the number of the peers which are not acknowledged for
+	// longer than the timeout.
+	Overdue int `json:"overdue"`
+}
+
+func (s *StoreStalePeers) clone() *StoreStalePeers {
+	ns := *s
+	ns.Peers = append(s.Peers[:0:0], s.Peers...)
+	return &ns
+}
+
+// StalePeersPublish is published in the header of the store heartbeat
+// response.
+type StalePeersPublish struct {
+	// Version is the version of the latest removed peer of the store. The
+	// store acknowledges it after destroying all the published peers, unless
+	// more peers are not published yet.
+	Version uint64       `json:"version"`
+	Peers   []*StalePeer `json:"peers"`
+}
+
+// stalePeerTracker tracks the peers removed by PD until their stores
+// acknowledge them. A peer is removed if its region is overlapped by a newer
+// region which has no peer on the same store, so the store keeps the data of
+// the key range which it is not responsible for. The removed peers are
+// persisted, so they are still published after the leader changes.
+type stalePeerTracker struct {
+	sync.RWMutex
+	cluster *RaftCluster
+	stores  map[uint64]*StoreStalePeers
+}
+
+func newStalePeerTracker(cluster *RaftCluster) *stalePeerTracker {
+	return &stalePeerTracker{
+		cluster: cluster,
+		stores:  make(map[uint64]*StoreStalePeers),
+	}
+}
+
+func stalePeersKey(storeID uint64) string {
+	return strconv.FormatUint(storeID, 10)
+}
+
+func (t *stalePeerTracker) load() error {
+	t.Lock()
+	defer t.Unlock()
+	return t.cluster.storage.LoadRangeByPrefix(stalePeersPath+"/", func(k, v string) {
+		s := &StoreStalePeers{}
+		if err := json.Unmarshal([]byte(v), s); err != nil {
+			log.Error("failed to unmarshal stale peers", zap.String("key", k), errs.ZapError(errs.ErrJSONUnmarshal.Wrap(err).FastGenWithCause()))
+			return
+		}
+		t.stores[s.StoreID] = s
+	})
+}
+
+// saveLocked persists the removed peers of the store, which are applied only
+// if they are saved successfully.
+func (t *stalePeerTracker) saveLocked(s *StoreStalePeers) error {
+	if err := t.cluster.storage.SaveJSON(stalePeersPath, stalePeersKey(s.StoreID), s); err != nil {
+		return err
+	}
+	t.stores[s.StoreID] = s
+	stalePeerGauge.WithLabelValues(stalePeersKey(s.StoreID)).Set(float64(len(s.Peers)))
+	return nil
+}
+
+// onRegionsOverlapped records the peers of the overlapped regions on the
+// stores which have no peer of the region.
+func (t *stalePeerTracker) onRegionsOverlapped(region *core.RegionInfo, overlaps []*core.RegionInfo) {
+	now := time.Now()
+	removed := make(map[uint64][]*StalePeer)
+	for _, item := range overlaps {
+		for _, peer := range item.GetPeers() {
+			storeID := peer.GetStoreId()
+			if region.GetStorePeer(storeID) != nil {
+				continue
+			}
+			if store := t.cluster.GetStore(storeID); store == nil || store.IsTombstone() {
+				continue
+			}
+			removed[storeID] = append(removed[storeID], &StalePeer{
+				RegionID:    item.GetID(),
+				PeerID:      peer.GetId(),
+				StartKey:    core.HexRegionKeyStr(item.GetStartKey()),
+				EndKey:      core.HexRegionKeyStr(item.GetEndKey()),
+				RegionEpoch: item.GetRegionEpoch(),
+				RemoveTime:  now,
+			})
+		}
+	}
+	if len(removed) == 0 {
+		return
+	}
+
+	t.Lock()
+	defer t.Unlock()
+	for storeID, peers := range removed {
+		s, ok := t.stores[storeID]
+		if ok {
+			s = s.clone()
+		} else {
+			s = &StoreStalePeers{StoreID: storeID}
+		}
+		for _, peer := range peers {
+			s.Version++
+			peer.Version = s.Version
+			s.Peers = append(s.Peers, peer)
+		}
+		if err := t.saveLocked(s); err != nil {
+			log.Warn("failed to save stale peers", zap.Uint64("store-id", storeID), errs.ZapError(err))
+			continue
+		}
+		log.Info("peers are removed from store", zap.Uint64("store-id", storeID), zap.Int("count", len(peers)), zap.Uint64("version", s.Version))
+	}
+}
+
+// sync drops the peers acknowledged by the store, and returns the removed
+// peers to publish to it.
+func (t *stalePeerTracker) sync(storeID uint64, ackedVersion uint64, acked bool) *StalePeersPublish {
+	t.Lock()
+	defer t.Unlock()
+	s, ok := t.stores[storeID]
+	if !ok {
+		return nil
+	}
+	if acked && ackedVersion > s.AckedVersion {
+		ns := s.clone()
+		if ackedVersion > ns.Version {
+			ackedVersion = ns.Version
+		}
+		ns.AckedVersion = ackedVersion
+		ns.AckTime = time.Now()
+		i := sort.Search(len(ns.Peers), func(i int) bool { return ns.Peers[i].Version > ackedVersion })
+		ns.Peers = ns.Peers[i:]
+		if err := t.saveLocked(ns); err != nil {
+			log.Warn("failed to save stale peers", zap.Uint64("store-id", storeID), errs.ZapError(err))
+		} else {
+			s = ns
+		}
+	}
+	if len(s.Peers) == 0 {
+		return nil
+	}
+	peers := s.Peers
+	if len(peers) > maxPublishedStalePeers {
+		peers = peers[:maxPublishedStalePeers]
+	}
+	return &StalePeersPublish{
+		Version: peers[len(peers)-1].Version,
+		Peers:   append(peers[:0:0], peers...),
+	}
+}
+
+// removeStore drops the removed peers of a store which is deleted.
+func (t *stalePeerTracker) removeStore(storeID uint64) {
+	t.Lock()
+	defer t.Unlock()
+	if _, ok := t.stores[storeID]; !ok {
+		return
+	}
+	if err := t.cluster.storage.Remove(path.Join(stalePeersPath, stalePeersKey(storeID))); err != nil {
+		log.Warn("failed to remove stale peers", zap.Uint64("store-id", storeID), errs.ZapError(err))
+		return
+	}
+	delete(t.stores, storeID)
+	stalePeerGauge.DeleteLabelValues(stalePeersKey(storeID))
+}
+
+// list returns the stores with the removed peers not acknowledged yet.
+func (t *stalePeerTracker) list(timeout time.Duration) []*StoreStalePeers {
+	t.RLock()
+	defer t.RUnlock()
+	now := time.Now()
+	stores := make([]*StoreStalePeers, 0, len(t.stores))
+	for _, s := range t.stores {
+		if len(s.Peers) == 0 {
+			continue
+		}
+		ns := s.clone()
+		for _, peer := range ns.Peers {
+			if now.Sub(peer.RemoveTime) > timeout {
+				ns.Overdue++
+			}
+		}
+		stores = append(stores, ns)
+	}
+	sort.Slice(stores, func(i, j int) bool { return stores[i].StoreID < stores[j].StoreID })
+	return stores
+}
+
+// SyncStalePeers handles the acknowledgment of the removed peers from a store
+// heartbeat, and returns the removed peers to publish to the store. It returns
+// nil if there is nothing to publish.
+func (c *RaftCluster) SyncStalePeers(storeID uint64, ackedVersion uint64, acked bool) *StalePeersPublish {
+	if !c.opt.IsStalePeerGCEnabled() {
+		return nil
+	}
+	return c.stalePeers.sync(storeID, ackedVersion, acked)
+}
+
+// GetStalePeers returns the removed peers not acknowledged by the stores.
+func (c *RaftCluster) GetStalePeers() []*StoreStalePeers {
+	return c.stalePeers.list(c.opt.GetStalePeerAckTimeout())
+}
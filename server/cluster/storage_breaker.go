@@ -0,0 +1,208 @@
+// Copyright 2021 TiKV Project Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cluster
+
+import (
+	"sync"
+	"time"
+
+	"github.com/pingcap/kvproto/pkg/metapb"
+	"github.com/pingcap/log"
+	"github.com/tikv/pd/pkg/errs"
+	"github.com/tikv/pd/pkg/logutil"
+	"github.com/tikv/pd/server/core"
+	"go.uber.org/zap"
+)
+
+const (
+	// storageSlowWrites is the number of the consecutive slow writes which
+	// make the storage degraded.
+	storageSlowWrites = 3
+	// storageFastWrites is the number of the consecutive fast writes which
+	// make the storage recover.
+	storageFastWrites = 5
+	// storageAsyncRegionLimit is the number of the region saves queued in the
+	// degraded state. The saves beyond it are dropped.
+	storageAsyncRegionLimit = 1024
+)
+
+// StorageDegradedStatus is the state of the circuit breaker of the storage.
+type StorageDegradedStatus struct {
+	Degraded bool      `json:"degraded"`
+	Since    time.Time `json:"since,omitempty"`
+	// SlowThreshold is the duration of a write regarded as slow.
+	SlowThreshold string `json:"slow_threshold"`
+	// PendingRegions is the number of the region saves queued.
+	PendingRegions int `json:"pending_regions"`
+	// DroppedRegions is the number of the region saves dropped since the
+	// storage is degraded.
+	DroppedRegions uint64 `json:"dropped_regions"`
+	// SuppressedStores is the number of the store meta persists suppressed
+	// since the storage is degraded.
+	SuppressedStores uint64 `json:"suppressed_stores"`
+}
+
+// storageBreaker is a circuit breaker detecting the persistent slowness of the
+// storage. Once a number of the consecutive writes are slow, it degrades the
+// storage: the region saves become asynchronous and are dropped when the queue
+// is full, which is tolerated since the regions are reported again by the
+// heartbeats, and the periodic persists of the store meta are suppressed. It
+// recovers after a number of the consecutive writes are fast again.
+type storageBreaker struct {
+	sync.Mutex
+	getThreshold     func() time.Duration
+	degraded         bool
+	since            time.Time
+	slowCount        int
+	fastCount        int
+	droppedRegions   uint64
+	suppressedStores uint64
+	regions          chan *metapb.Region
+}
+
+func newStorageBreaker(getThreshold func() time.Duration) *storageBreaker {
+	return &storageBreaker{
+		getThreshold: getThreshold,
+		regions:      make(chan *metapb.Region, storageAsyncRegionLimit),
+	}
+}
+
+// observe records the duration of a write, and switches the state.
+func (b *storageBreaker) observe(d time.Duration) {
+	threshold := b.getThreshold()
+	b.Lock()
+	defer b.Unlock()
+	if threshold <= 0 {
+		b.setDegradedLocked(false)
+		return
+	}
+	if d >= threshold {
+		b.slowCount++
+		b.fastCount = 0
+	} else {
+		b.fastCount++
+		b.slowCount = 0
+	}
+	if !b.degraded && b.slowCount >= storageSlowWrites {
+		log.Warn("storage is slow, region saves become asynchronous", zap.Duration("last-write", d), zap.Duration("threshold", threshold))
+		b.setDegradedLocked(true)
+	} else if b.degraded && b.fastCount >= storageFastWrites {
+		log.Info("storage recovers from the slowness",
+			zap.Duration("degraded-duration", time.Since(b.since)),
+			zap.Uint64("dropped-regions", b.droppedRegions),
+			zap.Uint64("suppressed-stores", b.suppressedStores))
+		b.setDegradedLocked(false)
+	}
+}
+
+func (b *storageBreaker) setDegradedLocked(degraded bool) {
+	if b.degraded == degraded {
+		return
+	}
+	b.degraded = degraded
+	if degraded {
+		b.since = time.Now()
+		b.droppedRegions, b.suppressedStores = 0, 0
+		storageDegradedGauge.Set(1)
+		storageDegradedCounter.WithLabelValues("enter").Inc()
+	} else {
+		b.since = time.Time{}
+		storageDegradedGauge.Set(0)
+		storageDegradedCounter.WithLabelValues("leave").Inc()
+	}
+	b.slowCount, b.fastCount = 0, 0
+}
+
+func (b *storageBreaker) isDegraded() bool {
+	b.Lock()
+	defer b.Unlock()
+	return b.degraded
+}
+
+// saveRegion saves the region synchronously, or queues it if the storage is
+// degraded. It returns whether the region is dropped.
+func (b *storageBreaker) saveRegion(storage *core.Storage, region *metapb.Region) (dropped bool, err error) {
+	if b.isDegraded() {
+		select {
+		case b.regions <- region:
+			return false, nil
+		default:
+			b.Lock()
+			b.droppedRegions++
+			b.Unlock()
+			storageDroppedCounter.WithLabelValues("region").Inc()
+			return true, nil
+		}
+	}
+	start := time.Now()
+	err = storage.SaveRegion(region)
+	b.observe(time.Since(start))
+	return false, err
+}
+
+// allowStorePersist returns whether the periodic persist of the store meta is
+// allowed, which is suppressed if the storage is degraded.
+func (b *storageBreaker) allowStorePersist() bool {
+	b.Lock()
+	defer b.Unlock()
+	if b.degraded {
+		b.suppressedStores++
+		storageDroppedCounter.WithLabelValues("store").Inc()
+		return false
+	}
+	return true
+}
+
+func (b *storageBreaker) getStatus() *StorageDegradedStatus {
+	threshold := b.getThreshold()
+	b.Lock()
+	defer b.Unlock()
+	return &StorageDegradedStatus{
+		Degraded:         b.degraded,
+		Since:            b.since,
+		SlowThreshold:    threshold.String(),
+		PendingRegions:   len(b.regions),
+		DroppedRegions:   b.droppedRegions,
+		SuppressedStores: b.suppressedStores,
+	}
+}
+
+// runAsyncRegionSaves saves the regions queued in the degraded state. The
+// durations of the saves are observed, so that the storage can recover.
+func (c *RaftCluster) runAsyncRegionSaves() {
+	defer logutil.LogPanic()
+	defer c.wg.Done()
+	for {
+		select {
+		case <-c.quit:
+			log.Info("async region saves have been stopped")
+			return
+		case region := <-c.storageBreaker.regions:
+			start := time.Now()
+			err := c.storage.SaveRegion(region)
+			c.storageBreaker.observe(time.Since(start))
+			if err != nil {
+				log.Error("failed to save region to storage asynchronously",
+					zap.Uint64("region-id", region.GetId()),
+					errs.ZapError(err))
+			}
+		}
+	}
+}
+
+// GetStorageDegradedStatus returns the state of the circuit breaker of the
+// storage.
+func (c *RaftCluster) GetStorageDegradedStatus() *StorageDegradedStatus {
+	return c.storageBreaker.getStatus()
+}
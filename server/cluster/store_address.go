@@ -0,0 +1,70 @@
+// Copyright 2021 TiKV Project Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cluster
+
+import (
+	"github.com/gogo/protobuf/proto"
+	"github.com/pingcap/errors"
+	"github.com/pingcap/kvproto/pkg/metapb"
+	"github.com/tikv/pd/pkg/netutil"
+	"github.com/tikv/pd/server/core"
+)
+
+// normalizeStoreAddresses returns a copy of the store with the addresses in
+// the canonical forms, so that the same address written in different ways,
+// such as the IPv6 addresses, is treated as the same.
+func normalizeStoreAddresses(store *metapb.Store) (*metapb.Store, error) {
+	store = proto.Clone(store).(*metapb.Store)
+	for _, addr := range []*string{&store.Address, &store.StatusAddress, &store.PeerAddress} {
+		normalized, err := netutil.NormalizeAddr(*addr)
+		if err != nil {
+			return nil, err
+		}
+		*addr = normalized
+	}
+	return store, nil
+}
+
+// sameStoreAddress returns whether the two addresses are the same in the
+// canonical forms. The addresses persisted before the normalization may be
+// not canonical.
+func sameStoreAddress(a, b string) bool {
+	if a == b {
+		return true
+	}
+	na, err := netutil.NormalizeAddr(a)
+	if err != nil {
+		return false
+	}
+	nb, err := netutil.NormalizeAddr(b)
+	if err != nil {
+		return false
+	}
+	return na == nb
+}
+
+// checkStoreAddressKind rejects a store switching between a host name and an
+// IP literal, which is likely a node registered in two ways and makes the
+// duplication check unreliable. It is allowed during the migration.
+func (c *RaftCluster) checkStoreAddressKind(origin *core.StoreInfo, store *metapb.Store) error {
+	if origin == nil || origin.GetAddress() == "" || c.opt.IsStoreAddressMigrationEnabled() {
+		return nil
+	}
+	oldHost, newHost := netutil.AddrHost(origin.GetAddress()), netutil.AddrHost(store.GetAddress())
+	if oldHost == "" || newHost == "" || netutil.IsIPHost(oldHost) == netutil.IsIPHost(newHost) {
+		return nil
+	}
+	return errors.Errorf("store %d is registered with the address %s, which can't be changed to %s between a host name and an IP literal unless enable-store-address-migration is set",
+		store.GetId(), origin.GetAddress(), store.GetAddress())
+}
@@ -0,0 +1,123 @@
+// Copyright 2021 TiKV Project Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cluster
+
+import (
+	"fmt"
+
+	"github.com/pingcap/kvproto/pkg/metapb"
+	"github.com/tikv/pd/pkg/errs"
+	"github.com/tikv/pd/server/core"
+)
+
+// The reasons why a region blocks a store from being removed safely.
+const (
+	// RemovalBlockedJointConsensus means the region is in joint consensus,
+	// whose membership change may still be in progress.
+	RemovalBlockedJointConsensus = "joint-consensus"
+	// RemovalBlockedQuorumAtRisk means the region cannot keep a quorum of
+	// healthy voters without the peer on the store.
+	RemovalBlockedQuorumAtRisk = "quorum-at-risk"
+)
+
+// maxRemovalBlockers is the max number of the blocking regions returned.
+const maxRemovalBlockers = 1000
+
+// RemovalBlocker is a region that blocks a store from being removed safely.
+type RemovalBlocker struct {
+	RegionID uint64   `json:"region_id"`
+	Reasons  []string `json:"reasons"`
+}
+
+// StoreRemovalCheck is the result of checking whether a store can be removed
+// safely.
+type StoreRemovalCheck struct {
+	StoreID uint64 `json:"store_id"`
+	Safe    bool   `json:"safe"`
+	// Total is the number of the blocking regions, which may be more than the
+	// returned ones.
+	Total    int               `json:"total"`
+	Blockers []*RemovalBlocker `json:"blockers"`
+}
+
+// CheckStoreRemoval lists the regions on the store which block it from being
+// removed safely, with the reasons.
+func (c *RaftCluster) CheckStoreRemoval(storeID uint64) (*StoreRemovalCheck, error) {
+	if c.GetStore(storeID) == nil {
+		return nil, errs.ErrStoreNotFound.FastGenByArgs(storeID)
+	}
+	check := &StoreRemovalCheck{StoreID: storeID, Blockers: []*RemovalBlocker{}}
+	for _, region := range c.core.GetStoreRegions(storeID) {
+		reasons := c.getRemovalBlockedReasons(region, storeID)
+		if len(reasons) == 0 {
+			continue
+		}
+		check.Total++
+		if len(check.Blockers) < maxRemovalBlockers {
+			check.Blockers = append(check.Blockers, &RemovalBlocker{RegionID: region.GetID(), Reasons: reasons})
+		}
+	}
+	check.Safe = check.Total == 0
+	return check, nil
+}
+
+// checkReplicaBeforeOfflineStore checks whether the regions on the store allow
+// it to be offline. The regions in joint consensus are treated conservatively,
+// because it is unknown which configuration the removal will apply to. The
+// store which is physically destroyed, i.e. removed with force, is not checked.
+func (c *RaftCluster) checkReplicaBeforeOfflineStore(storeID uint64) error {
+	for _, region := range c.core.GetStoreRegions(storeID) {
+		if core.IsInJointState(region.GetPeers()...) {
+			return errs.ErrStoreRemovalBlocked.FastGenByArgs(storeID,
+				fmt.Sprintf("region %d is in joint consensus, retry after it leaves the joint state or remove the store with force if it is physically destroyed", region.GetID()))
+		}
+	}
+	return nil
+}
+
+func (c *RaftCluster) getRemovalBlockedReasons(region *core.RegionInfo, storeID uint64) []string {
+	var reasons []string
+	if core.IsInJointState(region.GetPeers()...) {
+		reasons = append(reasons, RemovalBlockedJointConsensus)
+	}
+	// In joint consensus, both the incoming and the outgoing configurations
+	// need a quorum.
+	outgoing := func(p *metapb.Peer) bool {
+		return p.GetRole() == metapb.PeerRole_Voter || p.GetRole() == metapb.PeerRole_DemotingVoter
+	}
+	if !c.hasHealthyQuorum(region, storeID, core.IsVoterOrIncomingVoter) || !c.hasHealthyQuorum(region, storeID, outgoing) {
+		reasons = append(reasons, RemovalBlockedQuorumAtRisk)
+	}
+	return reasons
+}
+
+// hasHealthyQuorum returns whether the voters selected by isVoter still have a
+// quorum of healthy peers if the peer on the store is removed.
+func (c *RaftCluster) hasHealthyQuorum(region *core.RegionInfo, storeID uint64, isVoter func(*metapb.Peer) bool) bool {
+	voters, healthy := 0, 0
+	for _, peer := range region.GetPeers() {
+		if !isVoter(peer) {
+			continue
+		}
+		voters++
+		if peer.GetStoreId() == storeID || region.GetDownPeer(peer.GetId()) != nil || region.GetPendingPeer(peer.GetId()) != nil {
+			continue
+		}
+		if store := c.GetStore(peer.GetStoreId()); store == nil || !store.IsUp() || store.IsDisconnected() {
+			continue
+		}
+		healthy++
+	}
+	return voters == 0 || healthy*2 > voters
+}
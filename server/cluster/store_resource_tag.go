@@ -0,0 +1,114 @@
+// Copyright 2021 TiKV Project Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cluster
+
+import (
+	"regexp"
+	"sort"
+
+	"github.com/pingcap/errors"
+	"github.com/pingcap/log"
+	"github.com/tikv/pd/pkg/errs"
+	"github.com/tikv/pd/server/core"
+	"github.com/tikv/pd/server/core/storelimit"
+	"go.uber.org/zap"
+)
+
+// SetStoreResourceTags replaces the resource tags of a store. The resource
+// tags, such as `disk=nvme` or `cpu=32c`, describe the resources of the store
+// rather than the location, and they can be used by the label constraints of
+// the placement rules and the store limit profiles.
+func (c *RaftCluster) SetStoreResourceTags(storeID uint64, tags map[string]string) error {
+	c.Lock()
+	defer c.Unlock()
+	return c.setStoreResourceTagsLocked(storeID, tags)
+}
+
+// SetResourceTagsByAddress merges the resource tags into the stores whose
+// addresses match the pattern, and returns the IDs of the stores. A tag with
+// an empty value is removed. The tombstone stores are skipped.
+func (c *RaftCluster) SetResourceTagsByAddress(pattern string, tags map[string]string) ([]uint64, error) {
+	re, err := regexp.Compile(pattern)
+	if err != nil {
+		return nil, errors.Errorf("invalid address pattern %q: %v", pattern, err)
+	}
+	c.Lock()
+	defer c.Unlock()
+	var storeIDs []uint64
+	for _, store := range c.GetStores() {
+		if store.IsTombstone() || !re.MatchString(store.GetAddress()) {
+			continue
+		}
+		merged := make(map[string]string, len(store.GetResourceTags())+len(tags))
+		for k, v := range store.GetResourceTags() {
+			merged[k] = v
+		}
+		for k, v := range tags {
+			if v == "" {
+				delete(merged, k)
+			} else {
+				merged[k] = v
+			}
+		}
+		if err := c.setStoreResourceTagsLocked(store.GetID(), merged); err != nil {
+			return storeIDs, err
+		}
+		storeIDs = append(storeIDs, store.GetID())
+	}
+	sort.Slice(storeIDs, func(i, j int) bool { return storeIDs[i] < storeIDs[j] })
+	return storeIDs, nil
+}
+
+func (c *RaftCluster) setStoreResourceTagsLocked(storeID uint64, tags map[string]string) error {
+	store := c.GetStore(storeID)
+	if store == nil {
+		return errs.ErrStoreNotFound.FastGenByArgs(storeID)
+	}
+	for k := range tags {
+		if k == "" {
+			return errors.New("the key of the resource tag should not be empty")
+		}
+	}
+	if len(tags) == 0 {
+		tags = nil
+	}
+	if err := c.storage.SaveStoreResourceTags(storeID, tags); err != nil {
+		return err
+	}
+	log.Info("store resource tags are changed",
+		zap.Uint64("store-id", storeID),
+		zap.Any("resource-tags", tags))
+	c.core.PutStore(store.Clone(core.SetResourceTags(tags)))
+	c.applyStoreLimitProfile(storeID, tags)
+	return nil
+}
+
+// applyStoreLimitProfile sets the store limit of a store to the profile
+// matched by the resource tags, if there is one.
+func (c *RaftCluster) applyStoreLimitProfile(storeID uint64, tags map[string]string) {
+	profile := c.opt.GetScheduleConfig().GetStoreLimitProfile(tags)
+	if profile == nil {
+		return
+	}
+	c.opt.SetStoreLimit(storeID, storelimit.AddPeer, profile.AddPeer)
+	c.opt.SetStoreLimit(storeID, storelimit.RemovePeer, profile.RemovePeer)
+	if err := c.opt.Persist(c.storage); err != nil {
+		log.Error("persist store limit meet error", zap.Uint64("store-id", storeID), errs.ZapError(err))
+		return
+	}
+	log.Info("store limit is set by the profile",
+		zap.Uint64("store-id", storeID),
+		zap.Float64("add-peer", profile.AddPeer),
+		zap.Float64("remove-peer", profile.RemovePeer))
+}
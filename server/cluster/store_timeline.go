@@ -0,0 +1,185 @@
+// Copyright 2021 TiKV Project Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cluster
+
+import (
+	"encoding/json"
+	"path"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/pingcap/log"
+	"github.com/tikv/pd/pkg/errs"
+	"github.com/tikv/pd/server/core"
+	"go.uber.org/zap"
+)
+
+const (
+	storeTimelinePath = "store_timeline"
+	// maxStoreTimelineEvents is the max number of the state transitions kept
+	// for a store. The oldest ones are dropped.
+	maxStoreTimelineEvents = 64
+)
+
+// The states in the store timelines. Disconnected and Down are not persisted
+// in the store meta, they are derived from the heartbeats.
+const (
+	StoreTimelineUp           = "Up"
+	StoreTimelineDisconnected = "Disconnected"
+	StoreTimelineDown         = "Down"
+	StoreTimelineOffline      = "Offline"
+	StoreTimelineTombstone    = "Tombstone"
+)
+
+// StoreStateEvent is a state transition of a store.
+type StoreStateEvent struct {
+	State string    `json:"state"`
+	Time  time.Time `json:"time"`
+}
+
+// StoreTimeline is the history of the state transitions of a store.
+type StoreTimeline struct {
+	StoreID uint64             `json:"store_id"`
+	Events  []*StoreStateEvent `json:"events"`
+}
+
+func (t *StoreTimeline) clone() *StoreTimeline {
+	nt := *t
+	nt.Events = make([]*StoreStateEvent, 0, len(t.Events))
+	for _, e := range t.Events {
+		ne := *e
+		nt.Events = append(nt.Events, &ne)
+	}
+	return &nt
+}
+
+// storeTimelineTracker records the state transitions of the stores, so the
+// incidents can be correlated with the instability of the stores. The
+// timelines are persisted to survive the leader changes.
+type storeTimelineTracker struct {
+	sync.RWMutex
+	cluster *RaftCluster
+	stores  map[uint64]*StoreTimeline
+}
+
+func newStoreTimelineTracker(cluster *RaftCluster) *storeTimelineTracker {
+	return &storeTimelineTracker{
+		cluster: cluster,
+		stores:  make(map[uint64]*StoreTimeline),
+	}
+}
+
+func storeTimelineKey(storeID uint64) string {
+	return strconv.FormatUint(storeID, 10)
+}
+
+// getStoreTimelineState returns the state of the store in the timeline. A
+// store which has never sent the heartbeats is not regarded as disconnected.
+func getStoreTimelineState(store *core.StoreInfo, maxDownTime time.Duration) string {
+	switch {
+	case store.IsTombstone():
+		return StoreTimelineTombstone
+	case store.IsOffline():
+		return StoreTimelineOffline
+	case store.GetMeta().GetLastHeartbeat() == 0:
+		return StoreTimelineUp
+	case store.DownTime() >= maxDownTime:
+		return StoreTimelineDown
+	case store.IsDisconnected():
+		return StoreTimelineDisconnected
+	default:
+		return StoreTimelineUp
+	}
+}
+
+func (t *storeTimelineTracker) load() error {
+	t.Lock()
+	defer t.Unlock()
+	return t.cluster.storage.LoadRangeByPrefix(storeTimelinePath+"/", func(k, v string) {
+		timeline := &StoreTimeline{}
+		if err := json.Unmarshal([]byte(v), timeline); err != nil {
+			log.Error("failed to unmarshal store timeline", zap.String("key", k), errs.ZapError(errs.ErrJSONUnmarshal.Wrap(err).FastGenWithCause()))
+			return
+		}
+		t.stores[timeline.StoreID] = timeline
+	})
+}
+
+// observe records the state of the store if it is changed.
+func (t *storeTimelineTracker) observe(store *core.StoreInfo, now time.Time) {
+	state := getStoreTimelineState(store, t.cluster.opt.GetMaxStoreDownTime())
+	t.Lock()
+	defer t.Unlock()
+	old, ok := t.stores[store.GetID()]
+	if !ok {
+		old = &StoreTimeline{StoreID: store.GetID()}
+	}
+	if n := len(old.Events); n > 0 && old.Events[n-1].State == state {
+		return
+	}
+	timeline := old.clone()
+	timeline.Events = append(timeline.Events, &StoreStateEvent{State: state, Time: now})
+	if len(timeline.Events) > maxStoreTimelineEvents {
+		timeline.Events = timeline.Events[len(timeline.Events)-maxStoreTimelineEvents:]
+	}
+	if err := t.cluster.storage.SaveJSON(storeTimelinePath, storeTimelineKey(store.GetID()), timeline); err != nil {
+		log.Warn("failed to save store timeline", zap.Uint64("store-id", store.GetID()), zap.String("state", state), errs.ZapError(err))
+		return
+	}
+	t.stores[store.GetID()] = timeline
+	log.Info("store state is changed", zap.Uint64("store-id", store.GetID()), zap.String("state", state))
+}
+
+// tick observes the states of all stores, since Disconnected and Down are
+// not changed by any request.
+func (t *storeTimelineTracker) tick(now time.Time) {
+	for _, store := range t.cluster.GetStores() {
+		t.observe(store, now)
+	}
+}
+
+// removeStore drops the timeline of a store which is deleted.
+func (t *storeTimelineTracker) removeStore(storeID uint64) {
+	t.Lock()
+	defer t.Unlock()
+	if _, ok := t.stores[storeID]; !ok {
+		return
+	}
+	if err := t.cluster.storage.Remove(path.Join(storeTimelinePath, storeTimelineKey(storeID))); err != nil {
+		log.Warn("failed to remove store timeline", zap.Uint64("store-id", storeID), errs.ZapError(err))
+		return
+	}
+	delete(t.stores, storeID)
+}
+
+func (t *storeTimelineTracker) get(storeID uint64) *StoreTimeline {
+	t.RLock()
+	defer t.RUnlock()
+	if timeline, ok := t.stores[storeID]; ok {
+		return timeline.clone()
+	}
+	return nil
+}
+
+// GetStoreTimeline returns the state transitions of the store.
+func (c *RaftCluster) GetStoreTimeline(storeID uint64) (*StoreTimeline, error) {
+	if timeline := c.storeTimeline.get(storeID); timeline != nil {
+		return timeline, nil
+	}
+	if c.GetStore(storeID) == nil {
+		return nil, errs.ErrStoreNotFound.FastGenByArgs(storeID)
+	}
+	return &StoreTimeline{StoreID: storeID, Events: []*StoreStateEvent{}}, nil
+}
@@ -0,0 +1,187 @@
+// Copyright 2021 TiKV Project Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cluster
+
+import (
+	"encoding/json"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/pingcap/log"
+	"github.com/tikv/pd/pkg/errs"
+	"go.uber.org/zap"
+)
+
+const (
+	suspectRegionsPath = "suspect_regions"
+	// maxPersistedSuspectRegions is the max number of the persisted suspect
+	// regions. The others are only kept in memory.
+	maxPersistedSuspectRegions = 4096
+	// persistedSuspectRegionTTL is how long a persisted suspect region is
+	// reloaded after the leader changes.
+	persistedSuspectRegionTTL = 10 * time.Minute
+)
+
+// SuspectReason is why a region is suspected to need a fix.
+type SuspectReason string
+
+const (
+	// SuspectSplit means the region is split, which may leave it in an
+	// abnormal state if it is being scheduled.
+	SuspectSplit SuspectReason = "split"
+	// SuspectAccelerated means the scheduling of the region is accelerated
+	// by the user.
+	SuspectAccelerated SuspectReason = "accelerate-schedule"
+)
+
+// SuspectRegion is a persisted suspect region.
+type SuspectRegion struct {
+	RegionID uint64        `json:"region_id"`
+	Reason   SuspectReason `json:"reason"`
+	AddTime  time.Time     `json:"add_time"`
+}
+
+// suspectRegionPersister persists the high priority suspect regions, so they
+// are still checked first after the leader changes, instead of waiting for
+// the patrol to reach them.
+type suspectRegionPersister struct {
+	sync.Mutex
+	cluster *RaftCluster
+	regions map[uint64]*SuspectRegion
+	dirty   bool
+}
+
+func newSuspectRegionPersister(cluster *RaftCluster) *suspectRegionPersister {
+	return &suspectRegionPersister{
+		cluster: cluster,
+		regions: make(map[uint64]*SuspectRegion),
+	}
+}
+
+// load returns the persisted suspect regions added within the TTL.
+func (p *suspectRegionPersister) load(now time.Time) ([]*SuspectRegion, error) {
+	value, err := p.cluster.storage.Load(suspectRegionsPath)
+	if err != nil || value == "" {
+		return nil, err
+	}
+	var regions []*SuspectRegion
+	if err := json.Unmarshal([]byte(value), &regions); err != nil {
+		return nil, errs.ErrJSONUnmarshal.Wrap(err).FastGenWithCause()
+	}
+	p.Lock()
+	defer p.Unlock()
+	var loaded []*SuspectRegion
+	for _, r := range regions {
+		if now.Sub(r.AddTime) < persistedSuspectRegionTTL {
+			p.regions[r.RegionID] = r
+			loaded = append(loaded, r)
+		}
+	}
+	p.dirty = len(loaded) < len(regions)
+	return loaded, nil
+}
+
+func (p *suspectRegionPersister) add(reason SuspectReason, now time.Time, ids ...uint64) {
+	p.Lock()
+	defer p.Unlock()
+	for _, id := range ids {
+		if _, ok := p.regions[id]; !ok && len(p.regions) >= maxPersistedSuspectRegions {
+			continue
+		}
+		p.regions[id] = &SuspectRegion{RegionID: id, Reason: reason, AddTime: now}
+		p.dirty = true
+	}
+}
+
+func (p *suspectRegionPersister) remove(id uint64) {
+	p.Lock()
+	defer p.Unlock()
+	if _, ok := p.regions[id]; ok {
+		delete(p.regions, id)
+		p.dirty = true
+	}
+}
+
+// flush drops the regions which are no longer suspected, and persists the
+// others if they are changed.
+func (p *suspectRegionPersister) flush(now time.Time) {
+	suspects := make(map[uint64]struct{})
+	for _, id := range p.cluster.GetSuspectRegions() {
+		suspects[id] = struct{}{}
+	}
+	p.Lock()
+	defer p.Unlock()
+	regions := make([]*SuspectRegion, 0, len(p.regions))
+	for id, r := range p.regions {
+		if _, ok := suspects[id]; !ok || now.Sub(r.AddTime) >= persistedSuspectRegionTTL {
+			delete(p.regions, id)
+			p.dirty = true
+			continue
+		}
+		regions = append(regions, r)
+	}
+	persistedSuspectRegionsGauge.Set(float64(len(regions)))
+	if !p.dirty {
+		return
+	}
+	sort.Slice(regions, func(i, j int) bool { return regions[i].RegionID < regions[j].RegionID })
+	value, err := json.Marshal(regions)
+	if err != nil {
+		log.Error("failed to marshal suspect regions", errs.ZapError(errs.ErrJSONMarshal.Wrap(err).FastGenWithCause()))
+		return
+	}
+	if err := p.cluster.storage.Save(suspectRegionsPath, string(value)); err != nil {
+		log.Warn("failed to save suspect regions", zap.Int("count", len(regions)), errs.ZapError(err))
+		return
+	}
+	p.dirty = false
+}
+
+// AddPrioritySuspectRegions adds the regions to the suspect list with the
+// reason, and they are persisted to survive the leader changes.
+func (c *RaftCluster) AddPrioritySuspectRegions(reason SuspectReason, regionIDs ...uint64) {
+	c.AddSuspectRegions(regionIDs...)
+	c.suspectRegionPersister.add(reason, time.Now(), regionIDs...)
+}
+
+// GetPersistedSuspectRegions returns the persisted suspect regions.
+func (c *RaftCluster) GetPersistedSuspectRegions() []*SuspectRegion {
+	p := c.suspectRegionPersister
+	p.Lock()
+	defer p.Unlock()
+	regions := make([]*SuspectRegion, 0, len(p.regions))
+	for _, r := range p.regions {
+		nr := *r
+		regions = append(regions, &nr)
+	}
+	sort.Slice(regions, func(i, j int) bool { return regions[i].RegionID < regions[j].RegionID })
+	return regions
+}
+
+// loadSuspectRegionsLocked puts the persisted suspect regions back to the suspect
+// list. It is called with the lock of the cluster held.
+func (c *RaftCluster) loadSuspectRegionsLocked() error {
+	regions, err := c.suspectRegionPersister.load(time.Now())
+	if err != nil {
+		return err
+	}
+	for _, r := range regions {
+		c.suspectRegions.Put(r.RegionID, nil)
+	}
+	if len(regions) > 0 {
+		log.Info("suspect regions are reloaded", zap.Int("count", len(regions)))
+	}
+	return nil
+}
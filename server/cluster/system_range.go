@@ -0,0 +1,168 @@
+// Copyright 2021 TiKV Project Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cluster
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/pingcap/log"
+	"github.com/tikv/pd/pkg/errs"
+	"github.com/tikv/pd/server/config"
+	"github.com/tikv/pd/server/schedule/labeler"
+	"github.com/tikv/pd/server/schedule/placement"
+	"go.uber.org/zap"
+)
+
+const (
+	// SystemRangeRuleGroup is the built-in rule group of the system ranges.
+	SystemRangeRuleGroup = "system-range"
+	// systemRangeRuleGroupIndex makes the group applied after the other
+	// groups, so its rules override them.
+	systemRangeRuleGroupIndex  = 1 << 20
+	systemRangeLabelRulePrefix = "system-range-"
+)
+
+// buildSystemRangeRules generates the rule group and the label rules of the
+// system ranges from the config. The rule group is nil if the system ranges
+// are not isolated.
+func (c *RaftCluster) buildSystemRangeRules() (*placement.GroupBundle, []*labeler.LabelRule, error) {
+	if !c.opt.IsSystemRangeIsolationEnabled() {
+		return nil, nil, nil
+	}
+	var constraints []placement.LabelConstraint
+	for _, s := range c.opt.GetSystemRangeStoreLabels() {
+		label, err := config.ParseStoreLabel(s)
+		if err != nil {
+			return nil, nil, err
+		}
+		constraints = append(constraints, placement.LabelConstraint{Key: label.GetKey(), Op: placement.In, Values: []string{label.GetValue()}})
+	}
+	bundle := &placement.GroupBundle{ID: SystemRangeRuleGroup, Index: systemRangeRuleGroupIndex, Override: true}
+	var labelRules []*labeler.LabelRule
+	for i, s := range c.opt.GetSystemRanges() {
+		startKeyHex, endKeyHex, err := config.ParseSystemRange(s)
+		if err != nil {
+			return nil, nil, err
+		}
+		bundle.Rules = append(bundle.Rules, &placement.Rule{
+			GroupID:          SystemRangeRuleGroup,
+			ID:               fmt.Sprintf("range-%d", i),
+			StartKeyHex:      startKeyHex,
+			EndKeyHex:        endKeyHex,
+			Role:             placement.Voter,
+			Count:            int(c.opt.GetMaxReplicas()),
+			LabelConstraints: constraints,
+			LocationLabels:   c.opt.GetLocationLabels(),
+			IsolationLevel:   c.opt.GetIsolationLevel(),
+		})
+		labelRules = append(labelRules, &labeler.LabelRule{
+			ID:          fmt.Sprintf("%s%d", systemRangeLabelRulePrefix, i),
+			Labels:      []labeler.RegionLabel{{Key: labeler.SystemRangeLabel, Value: "true"}},
+			StartKeyHex: startKeyHex,
+			EndKeyHex:   endKeyHex,
+		})
+	}
+	return bundle, labelRules, nil
+}
+
+// syncSystemRangeRules makes the built-in rule group and the label rules of
+// the system ranges match the config. It is called periodically, so that the
+// changes of the config take effect without a restart. The rules are only
+// written when they are changed.
+func (c *RaftCluster) syncSystemRangeRules() error {
+	bundle, labelRules, err := c.buildSystemRangeRules()
+	if err != nil {
+		return err
+	}
+	if err := c.syncSystemRangeRuleGroup(bundle); err != nil {
+		return err
+	}
+	return c.syncSystemRangeLabelRules(labelRules)
+}
+
+func (c *RaftCluster) syncSystemRangeRuleGroup(bundle *placement.GroupBundle) error {
+	if !c.opt.IsPlacementRulesEnabled() || !c.ruleManager.IsInitialized() {
+		return nil
+	}
+	current := c.ruleManager.GetGroupBundle(SystemRangeRuleGroup)
+	if bundle == nil {
+		if c.ruleManager.GetRuleGroup(SystemRangeRuleGroup) == nil && len(current.Rules) == 0 {
+			return nil
+		}
+		log.Info("remove the rule group of the system ranges")
+		return c.ruleManager.DeleteGroupBundle(SystemRangeRuleGroup, false)
+	}
+	if sameGroupBundle(current, *bundle) {
+		return nil
+	}
+	log.Info("update the rule group of the system ranges", zap.Stringer("bundle", bundle))
+	return c.ruleManager.SetGroupBundle(*bundle)
+}
+
+func sameGroupBundle(a, b placement.GroupBundle) bool {
+	if a.ID != b.ID || a.Index != b.Index || a.Override != b.Override || len(a.Rules) != len(b.Rules) {
+		return false
+	}
+	rules := make(map[string]string, len(a.Rules))
+	for _, r := range a.Rules {
+		rules[r.ID] = r.String()
+	}
+	for _, r := range b.Rules {
+		if rules[r.ID] != r.String() {
+			return false
+		}
+	}
+	return true
+}
+
+func (c *RaftCluster) syncSystemRangeLabelRules(labelRules []*labeler.LabelRule) error {
+	expected := make(map[string]struct{}, len(labelRules))
+	for _, rule := range labelRules {
+		expected[rule.ID] = struct{}{}
+		if sameLabelRule(c.regionLabeler.GetLabelRule(rule.ID), rule) {
+			continue
+		}
+		if err := c.regionLabeler.SetLabelRule(rule); err != nil {
+			return err
+		}
+	}
+	for _, rule := range c.regionLabeler.GetAllLabelRules() {
+		if _, ok := expected[rule.ID]; ok || !strings.HasPrefix(rule.ID, systemRangeLabelRulePrefix) {
+			continue
+		}
+		if err := c.regionLabeler.DeleteLabelRule(rule.ID); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func sameLabelRule(a, b *labeler.LabelRule) bool {
+	if a == nil || a.StartKeyHex != b.StartKeyHex || a.EndKeyHex != b.EndKeyHex || len(a.Labels) != len(b.Labels) {
+		return false
+	}
+	for i := range a.Labels {
+		if a.Labels[i] != b.Labels[i] {
+			return false
+		}
+	}
+	return true
+}
+
+func (c *RaftCluster) checkSystemRanges() {
+	if err := c.syncSystemRangeRules(); err != nil {
+		log.Warn("failed to sync the rules of the system ranges", errs.ZapError(err))
+	}
+}
@@ -0,0 +1,461 @@
+// Copyright 2021 TiKV Project Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cluster
+
+import (
+	"encoding/json"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/gogo/protobuf/proto"
+	"github.com/pingcap/kvproto/pkg/metapb"
+	"github.com/pingcap/log"
+	"github.com/tikv/pd/pkg/errs"
+	"github.com/tikv/pd/pkg/typeutil"
+	"github.com/tikv/pd/server/config"
+	"github.com/tikv/pd/server/core"
+	"github.com/tikv/pd/server/core/storelimit"
+	"github.com/tikv/pd/server/schedule/placement"
+	"go.uber.org/zap"
+)
+
+const (
+	topologyPlanPath = "topology_plan"
+	// scaleOutFinishedRatio is the ratio of the expected region count a new
+	// store should reach before its scale-out step is treated as finished.
+	scaleOutFinishedRatio = 0.8
+)
+
+// TopologyStepKind is the kind of a topology plan step.
+type TopologyStepKind string
+
+const (
+	// TopologyStepScaleOut waits for a new store to join and be filled up.
+	TopologyStepScaleOut TopologyStepKind = "scale-out"
+	// TopologyStepSetLabels updates the labels of an existing store.
+	TopologyStepSetLabels TopologyStepKind = "set-labels"
+	// TopologyStepScaleIn takes a store offline and waits for it to be tombstone.
+	TopologyStepScaleIn TopologyStepKind = "scale-in"
+)
+
+// TopologyStepState is the state of a topology plan step.
+type TopologyStepState string
+
+const (
+	// TopologyStepPending means the step has not been executed yet.
+	TopologyStepPending TopologyStepState = "pending"
+	// TopologyStepRunning means the step has been executed and PD is waiting for its checkpoint.
+	TopologyStepRunning TopologyStepState = "running"
+	// TopologyStepFinished means the checkpoint of the step has been reached.
+	TopologyStepFinished TopologyStepState = "finished"
+)
+
+// StoreLabelChange describes the new labels of an existing store.
+type StoreLabelChange struct {
+	StoreID uint64               `json:"store_id"`
+	Labels  []*metapb.StoreLabel `json:"labels"`
+}
+
+// TopologyTarget describes the topology the cluster is going to change to.
+type TopologyTarget struct {
+	// AddStores are the addresses of the stores that are going to join.
+	AddStores []string `json:"add_stores,omitempty"`
+	// Labels are the label changes of the existing stores.
+	Labels []*StoreLabelChange `json:"labels,omitempty"`
+	// RemoveStores are the IDs of the stores that are going to leave.
+	RemoveStores []uint64 `json:"remove_stores,omitempty"`
+}
+
+// TopologyStep is a step of a topology plan.
+type TopologyStep struct {
+	Index   int                  `json:"index"`
+	Kind    TopologyStepKind     `json:"kind"`
+	StoreID uint64               `json:"store_id,omitempty"`
+	Address string               `json:"address,omitempty"`
+	Labels  []*metapb.StoreLabel `json:"labels,omitempty"`
+	// RegionCount is the number of region peers expected to be moved by this step.
+	RegionCount int `json:"region_count"`
+	// TargetRegionCount is the region count a new store is expected to reach.
+	TargetRegionCount int               `json:"target_region_count,omitempty"`
+	EstimatedDuration typeutil.Duration `json:"estimated_duration"`
+	State             TopologyStepState `json:"state"`
+	StartTime         time.Time         `json:"start_time,omitempty"`
+	FinishTime        time.Time         `json:"finish_time,omitempty"`
+}
+
+// TopologyPlan is a step-by-step rebalancing plan for a topology change.
+type TopologyPlan struct {
+	Target            *TopologyTarget   `json:"target"`
+	Steps             []*TopologyStep   `json:"steps"`
+	EstimatedDuration typeutil.Duration `json:"estimated_duration"`
+	CreateTime        time.Time         `json:"create_time"`
+}
+
+func (p *TopologyPlan) clone() *TopologyPlan {
+	plan := *p
+	plan.Steps = make([]*TopologyStep, 0, len(p.Steps))
+	for _, s := range p.Steps {
+		step := *s
+		plan.Steps = append(plan.Steps, &step)
+	}
+	return &plan
+}
+
+// topologyPlanner builds and executes the topology plan. Each step is executed
+// only after the checkpoint of the previous one is reached, and the progress is
+// persisted so that it can be resumed after the PD leader changes.
+type topologyPlanner struct {
+	sync.Mutex
+	cluster *RaftCluster
+	plan    *TopologyPlan
+}
+
+func newTopologyPlanner(cluster *RaftCluster) *topologyPlanner {
+	return &topologyPlanner{cluster: cluster}
+}
+
+func (p *topologyPlanner) load() error {
+	p.Lock()
+	defer p.Unlock()
+	v, err := p.cluster.storage.Load(topologyPlanPath)
+	if err != nil || v == "" {
+		return err
+	}
+	plan := &TopologyPlan{}
+	if err := json.Unmarshal([]byte(v), plan); err != nil {
+		return errs.ErrJSONUnmarshal.Wrap(err).FastGenWithCause()
+	}
+	p.plan = plan
+	return nil
+}
+
+func (p *topologyPlanner) savePlanLocked(plan *TopologyPlan) error {
+	if plan == nil {
+		return p.cluster.storage.Remove(topologyPlanPath)
+	}
+	value, err := json.Marshal(plan)
+	if err != nil {
+		return errs.ErrJSONMarshal.Wrap(err).FastGenWithCause()
+	}
+	return p.cluster.storage.Save(topologyPlanPath, string(value))
+}
+
+// buildPlan builds the plan of the given target with the current store stats
+// and store limits. New stores are filled up first, then labels are changed,
+// and the leaving stores are taken offline one by one at last.
+func (p *topologyPlanner) buildPlan(target *TopologyTarget, now time.Time) (*TopologyPlan, error) {
+	if len(target.AddStores) == 0 && len(target.Labels) == 0 && len(target.RemoveStores) == 0 {
+		return nil, errs.ErrTopologyTargetContent.FastGenByArgs("target is empty")
+	}
+	c := p.cluster
+	var storeCount, peerCount int
+	for _, s := range c.GetStores() {
+		if s.IsTombstone() {
+			continue
+		}
+		storeCount++
+		peerCount += s.GetRegionCount()
+	}
+
+	plan := &TopologyPlan{Target: target, CreateTime: now}
+	addStep := func(step *TopologyStep) {
+		step.Index = len(plan.Steps)
+		step.State = TopologyStepPending
+		plan.Steps = append(plan.Steps, step)
+		plan.EstimatedDuration.Duration += step.EstimatedDuration.Duration
+	}
+
+	added := make(map[string]struct{})
+	for _, address := range target.AddStores {
+		if _, ok := added[address]; ok || address == "" {
+			return nil, errs.ErrTopologyTargetContent.FastGenByArgs(fmt.Sprintf("invalid new store address %q", address))
+		}
+		added[address] = struct{}{}
+		if s := p.getStoreByAddress(address); s == nil {
+			storeCount++
+		}
+	}
+	removed := make(map[uint64]struct{})
+	for _, storeID := range target.RemoveStores {
+		s := c.GetStore(storeID)
+		if _, ok := removed[storeID]; ok || s == nil || s.IsTombstone() {
+			return nil, errs.ErrTopologyTargetContent.FastGenByArgs(fmt.Sprintf("invalid leaving store %d", storeID))
+		}
+		removed[storeID] = struct{}{}
+		storeCount--
+	}
+	if storeCount < c.opt.GetMaxReplicas() {
+		return nil, errs.ErrTopologyTargetContent.FastGenByArgs(
+			fmt.Sprintf("only %d stores are left, which is less than max-replicas %d", storeCount, c.opt.GetMaxReplicas()))
+	}
+	expected := peerCount / storeCount
+
+	for _, address := range target.AddStores {
+		step := &TopologyStep{Kind: TopologyStepScaleOut, Address: address, RegionCount: expected, TargetRegionCount: expected}
+		rate := config.DefaultStoreLimit.GetDefaultStoreLimit(storelimit.AddPeer)
+		if s := p.getStoreByAddress(address); s != nil {
+			step.StoreID = s.GetID()
+			rate = c.opt.GetStoreLimitByType(s.GetID(), storelimit.AddPeer)
+			if step.RegionCount -= s.GetRegionCount(); step.RegionCount < 0 {
+				step.RegionCount = 0
+			}
+		}
+		step.EstimatedDuration = estimateDuration(step.RegionCount, rate)
+		addStep(step)
+	}
+	for _, change := range target.Labels {
+		if s := c.GetStore(change.StoreID); s == nil || s.IsTombstone() {
+			return nil, errs.ErrTopologyTargetContent.FastGenByArgs(fmt.Sprintf("invalid store %d to change labels", change.StoreID))
+		}
+		if _, ok := removed[change.StoreID]; ok {
+			return nil, errs.ErrTopologyTargetContent.FastGenByArgs(fmt.Sprintf("store %d is leaving", change.StoreID))
+		}
+		step := &TopologyStep{Kind: TopologyStepSetLabels, StoreID: change.StoreID, Labels: change.Labels}
+		step.RegionCount = p.countMisplacedRegions(p.relabelStore(change.StoreID, change.Labels))
+		step.EstimatedDuration = estimateDuration(step.RegionCount, c.opt.GetStoreLimitByType(change.StoreID, storelimit.RemovePeer))
+		addStep(step)
+	}
+	for _, storeID := range target.RemoveStores {
+		s := c.GetStore(storeID)
+		step := &TopologyStep{Kind: TopologyStepScaleIn, StoreID: storeID, Address: s.GetAddress(), RegionCount: s.GetRegionCount()}
+		step.EstimatedDuration = estimateDuration(step.RegionCount, c.opt.GetStoreLimitByType(storeID, storelimit.RemovePeer))
+		addStep(step)
+	}
+	return plan, nil
+}
+
+// estimateDuration estimates how long it takes to move regionCount peers with
+// the given store limit, which is in peers per minute.
+func estimateDuration(regionCount int, ratePerMin float64) typeutil.Duration {
+	if regionCount <= 0 || ratePerMin <= 0 {
+		return typeutil.NewDuration(0)
+	}
+	return typeutil.NewDuration(time.Duration(float64(regionCount) / ratePerMin * float64(time.Minute)))
+}
+
+// relabelStore returns the store with the labels merged in the same way as
+// UpdateStoreLabels does.
+func (p *topologyPlanner) relabelStore(storeID uint64, labels []*metapb.StoreLabel) *core.StoreInfo {
+	s := p.cluster.GetStore(storeID)
+	s = s.Clone(core.SetStoreLabels(proto.Clone(s.GetMeta()).(*metapb.Store).GetLabels()))
+	return s.Clone(core.SetStoreLabels(s.MergeLabels(labels)))
+}
+
+// countMisplacedRegions counts the regions on the store which are expected to
+// be moved away if the store is the given one. With placement rules enabled, a
+// region is misplaced if it does not fit the rules, otherwise it is misplaced
+// if the store shares the location with another peer of the region.
+func (p *topologyPlanner) countMisplacedRegions(store *core.StoreInfo) int {
+	c := p.cluster
+	stores := relabeledStoreSet{StoreSet: c, store: store}
+	locationLabels := c.opt.GetLocationLabels()
+	var count int
+	for _, region := range c.GetStoreRegions(store.GetID()) {
+		if c.opt.IsPlacementRulesEnabled() && c.ruleManager != nil {
+			if !c.ruleManager.FitRegion(stores, region).IsSatisfied() {
+				count++
+			}
+			continue
+		}
+		if len(locationLabels) == 0 {
+			continue
+		}
+		for storeID := range region.GetStoreIds() {
+			if other := c.GetStore(storeID); storeID != store.GetID() && other != nil && other.CompareLocation(store, locationLabels) == -1 {
+				count++
+				break
+			}
+		}
+	}
+	return count
+}
+
+// relabeledStoreSet is a store set in which one store is replaced.
+type relabeledStoreSet struct {
+	placement.StoreSet
+	store *core.StoreInfo
+}
+
+func (s relabeledStoreSet) GetStores() []*core.StoreInfo {
+	stores := s.StoreSet.GetStores()
+	for i, store := range stores {
+		if store.GetID() == s.store.GetID() {
+			stores[i] = s.store
+		}
+	}
+	return stores
+}
+
+func (s relabeledStoreSet) GetStore(id uint64) *core.StoreInfo {
+	if id == s.store.GetID() {
+		return s.store
+	}
+	return s.StoreSet.GetStore(id)
+}
+
+func (p *topologyPlanner) getStoreByAddress(address string) *core.StoreInfo {
+	for _, s := range p.cluster.GetStores() {
+		if !s.IsTombstone() && sameStoreAddress(s.GetAddress(), address) {
+			return s
+		}
+	}
+	return nil
+}
+
+// refreshLocked checks the checkpoints of the running steps.
+func (p *topologyPlanner) refreshLocked(now time.Time) {
+	if p.plan == nil {
+		return
+	}
+	changed := false
+	for _, step := range p.plan.Steps {
+		if step.State != TopologyStepRunning {
+			continue
+		}
+		switch step.Kind {
+		case TopologyStepScaleOut:
+			s := p.getStoreByAddress(step.Address)
+			if s == nil || !s.IsUp() {
+				continue
+			}
+			step.StoreID = s.GetID()
+			if float64(s.GetRegionCount()) < float64(step.TargetRegionCount)*scaleOutFinishedRatio {
+				continue
+			}
+		case TopologyStepSetLabels:
+			// The region movement caused by the new labels is finished.
+			if s := p.cluster.GetStore(step.StoreID); s != nil && !s.IsTombstone() && p.countMisplacedRegions(s) > 0 {
+				continue
+			}
+		case TopologyStepScaleIn:
+			if s := p.cluster.GetStore(step.StoreID); s != nil && !s.IsTombstone() {
+				continue
+			}
+		}
+		step.State = TopologyStepFinished
+		step.FinishTime = now
+		changed = true
+	}
+	if changed {
+		if err := p.savePlanLocked(p.plan); err != nil {
+			log.Warn("failed to persist topology plan", errs.ZapError(err))
+		}
+	}
+}
+
+func (p *topologyPlanner) setPlan(target *TopologyTarget) (*TopologyPlan, error) {
+	p.Lock()
+	defer p.Unlock()
+	plan, err := p.buildPlan(target, time.Now())
+	if err != nil {
+		return nil, err
+	}
+	if err := p.savePlanLocked(plan); err != nil {
+		return nil, err
+	}
+	p.plan = plan
+	log.Info("topology plan is created", zap.Int("steps", len(plan.Steps)), zap.Duration("estimated-duration", plan.EstimatedDuration.Duration))
+	return plan.clone(), nil
+}
+
+func (p *topologyPlanner) getPlan() *TopologyPlan {
+	p.Lock()
+	defer p.Unlock()
+	if p.plan == nil {
+		return nil
+	}
+	p.refreshLocked(time.Now())
+	return p.plan.clone()
+}
+
+func (p *topologyPlanner) deletePlan() error {
+	p.Lock()
+	defer p.Unlock()
+	if p.plan == nil {
+		return errs.ErrTopologyPlanNotFound.FastGenByArgs()
+	}
+	if err := p.savePlanLocked(nil); err != nil {
+		return err
+	}
+	p.plan = nil
+	return nil
+}
+
+// executeNextStep executes the first pending step. It returns nil if all steps
+// are finished.
+func (p *topologyPlanner) executeNextStep() (*TopologyStep, error) {
+	p.Lock()
+	defer p.Unlock()
+	if p.plan == nil {
+		return nil, errs.ErrTopologyPlanNotFound.FastGenByArgs()
+	}
+	now := time.Now()
+	p.refreshLocked(now)
+	var step *TopologyStep
+	for _, s := range p.plan.Steps {
+		if s.State == TopologyStepRunning {
+			return nil, errs.ErrTopologyPlanStepRunning.FastGenByArgs(s.Index)
+		}
+		if s.State == TopologyStepPending {
+			step = s
+			break
+		}
+	}
+	if step == nil {
+		return nil, nil
+	}
+
+	var err error
+	switch step.Kind {
+	case TopologyStepSetLabels:
+		err = p.cluster.UpdateStoreLabels(step.StoreID, step.Labels, false)
+	case TopologyStepScaleIn:
+		err = p.cluster.RemoveStore(step.StoreID, false)
+	}
+	if err != nil {
+		return nil, err
+	}
+	step.State = TopologyStepRunning
+	step.StartTime = now
+	if err := p.savePlanLocked(p.plan); err != nil {
+		return nil, err
+	}
+	log.Info("topology plan step is executed", zap.Int("index", step.Index), zap.String("kind", string(step.Kind)), zap.Uint64("store-id", step.StoreID))
+	s := *step
+	return &s, nil
+}
+
+// PlanTopologyChange builds a rebalancing plan for the target topology and
+// replaces the current plan.
+func (c *RaftCluster) PlanTopologyChange(target *TopologyTarget) (*TopologyPlan, error) {
+	return c.topologyPlanner.setPlan(target)
+}
+
+// GetTopologyPlan returns the current topology plan with the latest progress.
+func (c *RaftCluster) GetTopologyPlan() *TopologyPlan {
+	return c.topologyPlanner.getPlan()
+}
+
+// ExecuteTopologyPlanStep executes the next step of the current topology plan
+// if the checkpoint of the previous step is reached.
+func (c *RaftCluster) ExecuteTopologyPlanStep() (*TopologyStep, error) {
+	return c.topologyPlanner.executeNextStep()
+}
+
+// DeleteTopologyPlan aborts the current topology plan. The steps that have
+// been executed are not rolled back.
+func (c *RaftCluster) DeleteTopologyPlan() error {
+	return c.topologyPlanner.deletePlan()
+}
@@ -0,0 +1,162 @@
+// Copyright 2021 TiKV Project Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cluster
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	. "github.com/pingcap/check"
+	"github.com/pingcap/kvproto/pkg/metapb"
+	"github.com/tikv/pd/pkg/errs"
+	"github.com/tikv/pd/pkg/mock/mockid"
+	"github.com/tikv/pd/server/core"
+	"github.com/tikv/pd/server/core/storelimit"
+	"github.com/tikv/pd/server/kv"
+)
+
+var _ = Suite(&testTopologyPlannerSuite{})
+
+type testTopologyPlannerSuite struct {
+	ctx    context.Context
+	cancel context.CancelFunc
+}
+
+func (s *testTopologyPlannerSuite) SetUpTest(c *C) {
+	s.ctx, s.cancel = context.WithCancel(context.Background())
+}
+
+func (s *testTopologyPlannerSuite) TearDownTest(c *C) {
+	s.cancel()
+}
+
+func (s *testTopologyPlannerSuite) TestPlan(c *C) {
+	_, opt, err := newTestScheduleConfig()
+	c.Assert(err, IsNil)
+	// The default store limits may be changed by other tests.
+	opt.SetAllStoresLimit(storelimit.AddPeer, 15)
+	opt.SetAllStoresLimit(storelimit.RemovePeer, 15)
+	storage := core.NewStorage(kv.NewMemoryKV())
+	cluster := newTestRaftCluster(s.ctx, mockid.NewIDAllocator(), opt, storage, core.NewBasicCluster())
+	for _, store := range newTestStores(4, "2.0.0") {
+		c.Assert(cluster.PutStore(store.GetMeta()), IsNil)
+		cluster.core.PutStore(cluster.GetStore(store.GetID()).Clone(core.SetRegionCount(30)))
+	}
+
+	_, err = cluster.PlanTopologyChange(&TopologyTarget{})
+	c.Assert(errs.ErrTopologyTargetContent.Equal(err), IsTrue)
+	_, err = cluster.PlanTopologyChange(&TopologyTarget{RemoveStores: []uint64{1, 2}})
+	c.Assert(errs.ErrTopologyTargetContent.Equal(err), IsTrue)
+	_, err = cluster.PlanTopologyChange(&TopologyTarget{RemoveStores: []uint64{5}})
+	c.Assert(errs.ErrTopologyTargetContent.Equal(err), IsTrue)
+	_, err = cluster.ExecuteTopologyPlanStep()
+	c.Assert(errs.ErrTopologyPlanNotFound.Equal(err), IsTrue)
+
+	labels := []*metapb.StoreLabel{{Key: "zone", Value: "z2"}}
+	plan, err := cluster.PlanTopologyChange(&TopologyTarget{
+		AddStores:    []string{"127.0.0.1:5"},
+		Labels:       []*StoreLabelChange{{StoreID: 2, Labels: labels}},
+		RemoveStores: []uint64{1},
+	})
+	c.Assert(err, IsNil)
+	c.Assert(plan.Steps, HasLen, 3)
+	c.Assert(plan.Steps[0].Kind, Equals, TopologyStepScaleOut)
+	c.Assert(plan.Steps[0].RegionCount, Equals, 30)
+	c.Assert(plan.Steps[1].Kind, Equals, TopologyStepSetLabels)
+	c.Assert(plan.Steps[2].Kind, Equals, TopologyStepScaleIn)
+	c.Assert(plan.Steps[2].RegionCount, Equals, 30)
+	// 30 peers with the default store limit 15 per minute.
+	c.Assert(plan.Steps[2].EstimatedDuration.Duration, Equals, 2*time.Minute)
+	c.Assert(plan.EstimatedDuration.Duration, Equals, 4*time.Minute)
+
+	// The scale-out step is finished after the new store is filled up.
+	step, err := cluster.ExecuteTopologyPlanStep()
+	c.Assert(err, IsNil)
+	c.Assert(step.State, Equals, TopologyStepRunning)
+	_, err = cluster.ExecuteTopologyPlanStep()
+	c.Assert(errs.ErrTopologyPlanStepRunning.Equal(err), IsTrue)
+	c.Assert(cluster.PutStore(&metapb.Store{Id: 5, Address: "127.0.0.1:5", Version: "2.0.0"}), IsNil)
+	cluster.core.PutStore(cluster.GetStore(5).Clone(core.SetRegionCount(24)))
+	c.Assert(cluster.GetTopologyPlan().Steps[0].State, Equals, TopologyStepFinished)
+
+	step, err = cluster.ExecuteTopologyPlanStep()
+	c.Assert(err, IsNil)
+	c.Assert(step.Kind, Equals, TopologyStepSetLabels)
+	c.Assert(step.State, Equals, TopologyStepRunning)
+	c.Assert(cluster.GetStore(2).GetLabelValue("zone"), Equals, "z2")
+	// No region needs to be moved.
+	c.Assert(cluster.GetTopologyPlan().Steps[1].State, Equals, TopologyStepFinished)
+
+	step, err = cluster.ExecuteTopologyPlanStep()
+	c.Assert(err, IsNil)
+	c.Assert(step.Kind, Equals, TopologyStepScaleIn)
+	c.Assert(cluster.GetStore(1).IsOffline(), IsTrue)
+
+	// The progress survives reloading.
+	planner := newTopologyPlanner(cluster)
+	c.Assert(planner.load(), IsNil)
+	c.Assert(planner.getPlan().Steps[2].State, Equals, TopologyStepRunning)
+
+	cluster.checkStores()
+	c.Assert(cluster.GetStore(1).IsTombstone(), IsTrue)
+	step, err = cluster.ExecuteTopologyPlanStep()
+	c.Assert(err, IsNil)
+	c.Assert(step, IsNil)
+
+	c.Assert(cluster.DeleteTopologyPlan(), IsNil)
+	c.Assert(cluster.GetTopologyPlan(), IsNil)
+	c.Assert(errs.ErrTopologyPlanNotFound.Equal(cluster.DeleteTopologyPlan()), IsTrue)
+}
+
+func (s *testTopologyPlannerSuite) TestSetLabelsCheckpoint(c *C) {
+	_, opt, err := newTestScheduleConfig()
+	c.Assert(err, IsNil)
+	opt.SetPlacementRuleEnabled(false)
+	cfg := opt.GetReplicationConfig().Clone()
+	cfg.LocationLabels = []string{"zone"}
+	opt.SetReplicationConfig(cfg)
+	cluster := newTestRaftCluster(s.ctx, mockid.NewIDAllocator(), opt, core.NewStorage(kv.NewMemoryKV()), core.NewBasicCluster())
+	for _, store := range newTestStores(4, "2.0.0") {
+		meta := store.GetMeta()
+		meta.Labels = []*metapb.StoreLabel{{Key: "zone", Value: fmt.Sprintf("z%d", store.GetID())}}
+		c.Assert(cluster.PutStore(meta), IsNil)
+	}
+	newRegion := func(storeIDs ...uint64) *core.RegionInfo {
+		peers := make([]*metapb.Peer, 0, len(storeIDs))
+		for _, storeID := range storeIDs {
+			peers = append(peers, &metapb.Peer{Id: 10 + storeID, StoreId: storeID})
+		}
+		return core.NewRegionInfo(&metapb.Region{Id: 1, Peers: peers, RegionEpoch: &metapb.RegionEpoch{ConfVer: 1, Version: 1}}, peers[0])
+	}
+	cluster.core.PutRegion(newRegion(1, 2, 3))
+
+	// Store 2 is going to share the zone with store 1.
+	plan, err := cluster.PlanTopologyChange(&TopologyTarget{
+		Labels: []*StoreLabelChange{{StoreID: 2, Labels: []*metapb.StoreLabel{{Key: "zone", Value: "z1"}}}},
+	})
+	c.Assert(err, IsNil)
+	c.Assert(plan.Steps[0].RegionCount, Equals, 1)
+	c.Assert(plan.Steps[0].EstimatedDuration.Duration, Greater, time.Duration(0))
+	c.Assert(cluster.GetStore(2).GetLabelValue("zone"), Equals, "z2")
+
+	step, err := cluster.ExecuteTopologyPlanStep()
+	c.Assert(err, IsNil)
+	c.Assert(step.State, Equals, TopologyStepRunning)
+	c.Assert(cluster.GetTopologyPlan().Steps[0].State, Equals, TopologyStepRunning)
+
+	// The checkpoint is reached after the peer is moved away.
+	cluster.core.PutRegion(newRegion(1, 4, 3))
+	c.Assert(cluster.GetTopologyPlan().Steps[0].State, Equals, TopologyStepFinished)
+}
@@ -0,0 +1,178 @@
+// Copyright 2021 TiKV Project Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cluster
+
+import (
+	"encoding/json"
+	"sort"
+	"time"
+
+	"github.com/coreos/go-semver/semver"
+	"github.com/pingcap/kvproto/pkg/metapb"
+	"github.com/pingcap/log"
+	"github.com/tikv/pd/pkg/errs"
+	"github.com/tikv/pd/server/versioninfo"
+	"go.uber.org/zap"
+)
+
+const upgradeWindowPath = "upgrade_window"
+
+// UpgradeWindow is an upgrade of the stores started by the API. The stores
+// with the versions lower than the cluster version are rejected until the
+// upgrade is finished.
+type UpgradeWindow struct {
+	TargetVersion string    `json:"target_version"`
+	StartTime     time.Time `json:"start_time"`
+}
+
+// PendingStore is a store which is not upgraded to the target version yet.
+type PendingStore struct {
+	StoreID uint64 `json:"store_id"`
+	Address string `json:"address"`
+	Version string `json:"version"`
+}
+
+// UpgradeStatus is the status of the upgrade of the cluster.
+type UpgradeStatus struct {
+	ClusterVersion   string         `json:"cluster_version"`
+	GuardrailEnabled bool           `json:"guardrail_enabled"`
+	Window           *UpgradeWindow `json:"window,omitempty"`
+	// PendingStores are the stores not upgraded to the target version of the
+	// window.
+	PendingStores []*PendingStore `json:"pending_stores,omitempty"`
+}
+
+// isUpgradeGuardedLocked returns if the stores with the versions lower than
+// the cluster version are rejected.
+func (c *RaftCluster) isUpgradeGuardedLocked() bool {
+	return c.opt.IsUpgradeGuardrailEnabled() || c.upgradeWindow != nil
+}
+
+func (c *RaftCluster) loadUpgradeWindowLocked() error {
+	value, err := c.storage.Load(upgradeWindowPath)
+	if err != nil || value == "" {
+		return err
+	}
+	window := &UpgradeWindow{}
+	if err := json.Unmarshal([]byte(value), window); err != nil {
+		return errs.ErrJSONUnmarshal.Wrap(err).FastGenWithCause()
+	}
+	c.upgradeWindow = window
+	return nil
+}
+
+// pendingStoresLocked returns the stores with the versions lower than the
+// target version.
+func (c *RaftCluster) pendingStoresLocked(target *semver.Version) []*PendingStore {
+	var stores []*PendingStore
+	for _, s := range c.GetStores() {
+		if s.IsTombstone() {
+			continue
+		}
+		if versioninfo.MustParseVersion(s.GetVersion()).LessThan(*target) {
+			stores = append(stores, &PendingStore{
+				StoreID: s.GetID(),
+				Address: s.GetAddress(),
+				Version: s.GetVersion(),
+			})
+		}
+	}
+	sort.Slice(stores, func(i, j int) bool { return stores[i].StoreID < stores[j].StoreID })
+	return stores
+}
+
+// BeginUpgrade starts an upgrade window to the target version. The stores
+// with the versions lower than the cluster version are rejected until the
+// upgrade is finished, so the cluster version cannot be held back by them.
+func (c *RaftCluster) BeginUpgrade(targetVersion string) error {
+	target, err := versioninfo.ParseVersion(targetVersion)
+	if err != nil {
+		return err
+	}
+	c.Lock()
+	defer c.Unlock()
+	if c.upgradeWindow != nil {
+		return errs.ErrUpgradeInProgress.FastGenByArgs(c.upgradeWindow.TargetVersion)
+	}
+	clusterVersion := c.opt.GetClusterVersion()
+	if target.LessThan(*clusterVersion) {
+		return errs.ErrUpgradeTargetVersion.FastGenByArgs(target, clusterVersion)
+	}
+	window := &UpgradeWindow{TargetVersion: target.String(), StartTime: time.Now()}
+	value, err := json.Marshal(window)
+	if err != nil {
+		return errs.ErrJSONMarshal.Wrap(err).FastGenWithCause()
+	}
+	if err := c.storage.Save(upgradeWindowPath, string(value)); err != nil {
+		return err
+	}
+	c.upgradeWindow = window
+	log.Info("upgrade is started",
+		zap.String("target-version", window.TargetVersion),
+		zap.Int("pending-stores", len(c.pendingStoresLocked(target))))
+	return nil
+}
+
+// FinishUpgrade finishes the upgrade window. It fails if there are stores not
+// upgraded to the target version yet, unless it is forced.
+func (c *RaftCluster) FinishUpgrade(force bool) error {
+	c.Lock()
+	defer c.Unlock()
+	if c.upgradeWindow == nil {
+		return errs.ErrUpgradeNotInProgress.FastGenByArgs()
+	}
+	target := versioninfo.MustParseVersion(c.upgradeWindow.TargetVersion)
+	pending := c.pendingStoresLocked(target)
+	if len(pending) > 0 && !force {
+		return errs.ErrUpgradeNotFinished.FastGenByArgs(len(pending), target)
+	}
+	if err := c.storage.Remove(upgradeWindowPath); err != nil {
+		return err
+	}
+	log.Info("upgrade is finished",
+		zap.String("target-version", c.upgradeWindow.TargetVersion),
+		zap.Duration("duration", time.Since(c.upgradeWindow.StartTime)),
+		zap.Int("pending-stores", len(pending)))
+	c.upgradeWindow = nil
+	return nil
+}
+
+// GetUpgradeStatus returns the status of the upgrade.
+func (c *RaftCluster) GetUpgradeStatus() *UpgradeStatus {
+	c.RLock()
+	defer c.RUnlock()
+	status := &UpgradeStatus{
+		ClusterVersion:   c.opt.GetClusterVersion().String(),
+		GuardrailEnabled: c.isUpgradeGuardedLocked(),
+	}
+	if c.upgradeWindow != nil {
+		window := *c.upgradeWindow
+		status.Window = &window
+		status.PendingStores = c.pendingStoresLocked(versioninfo.MustParseVersion(window.TargetVersion))
+	}
+	return status
+}
+
+// checkUpgradeGuardrailLocked rejects the store if its version is lower than
+// the cluster version when the upgrade is guarded.
+func (c *RaftCluster) checkUpgradeGuardrailLocked(store *metapb.Store, v *semver.Version) error {
+	if !c.isUpgradeGuardedLocked() {
+		return nil
+	}
+	clusterVersion := c.opt.GetClusterVersion()
+	if v.LessThan(*clusterVersion) {
+		return errs.ErrStoreVersionDowngrade.FastGenByArgs(v, store.GetId(), clusterVersion)
+	}
+	return nil
+}
@@ -16,10 +16,12 @@ package config
 import (
 	"bytes"
 	"crypto/tls"
+	"encoding/hex"
 	"encoding/json"
 	"flag"
 	"fmt"
 	"math"
+	"net/http"
 	"net/url"
 	"os"
 	"path/filepath"
@@ -65,6 +67,11 @@ type Config struct {
 	ForceNewCluster   bool   `json:"force-new-cluster"`
 	EnableGRPCGateway bool   `json:"enable-grpc-gateway"`
 
+	// AllowClusterMismatch skips the check that the metadata in etcd belongs
+	// to the cluster, such as after restoring etcd from another cluster on
+	// purpose.
+	AllowClusterMismatch bool `json:"allow-cluster-mismatch"`
+
 	InitialCluster      string `toml:"initial-cluster" json:"initial-cluster"`
 	InitialClusterState string `toml:"initial-cluster-state" json:"initial-cluster-state"`
 	InitialClusterToken string `toml:"initial-cluster-token" json:"initial-cluster-token"`
@@ -139,12 +146,18 @@ type Config struct {
 	// to check whether it would get enough votes to win
 	// an election, thus minimizing disruptions.
 	PreVote bool `toml:"enable-prevote"`
+	// HeartbeatStreamKeepAliveInterval is the interval to send the keepalive
+	// messages by the region heartbeat streams to detect the broken ones.
+	HeartbeatStreamKeepAliveInterval typeutil.Duration `toml:"heartbeat-stream-keepalive-interval" json:"heartbeat-stream-keepalive-interval"`
 
 	Security SecurityConfig `toml:"security" json:"security"`
 
 	LabelProperty LabelPropertyConfig `toml:"label-property" json:"label-property"`
 
 	configFile string
+	// fileDefinedKeys are the keys defined in the config file, such as
+	// `schedule.max-snapshot-count`.
+	fileDefinedKeys map[string]struct{}
 
 	// For all warnings during parsing.
 	WarningMsgs []string
@@ -163,6 +176,12 @@ type Config struct {
 	Dashboard DashboardConfig `toml:"dashboard" json:"dashboard"`
 
 	ReplicationMode ReplicationModeConfig `toml:"replication-mode" json:"replication-mode"`
+
+	StatsExporter StatsExporterConfig `toml:"stats-exporter" json:"stats-exporter"`
+
+	MetaSnapshot MetaSnapshotConfig `toml:"meta-snapshot" json:"meta-snapshot"`
+
+	ReadAPI ReadAPIConfig `toml:"read-api" json:"read-api"`
 }
 
 // NewConfig creates a new config.
@@ -195,6 +214,7 @@ func NewConfig() *Config {
 	fs.StringVar(&cfg.Security.CertPath, "cert", "", "path of file that contains X509 certificate in PEM format")
 	fs.StringVar(&cfg.Security.KeyPath, "key", "", "path of file that contains X509 key in PEM format")
 	fs.BoolVar(&cfg.ForceNewCluster, "force-new-cluster", false, "force to create a new one-member cluster")
+	fs.BoolVar(&cfg.AllowClusterMismatch, "allow-cluster-mismatch", false, "allow to start with the metadata which does not match the cluster")
 
 	return cfg
 }
@@ -222,7 +242,8 @@ const (
 
 	defaultMetricsPushInterval = 15 * time.Second
 
-	defaultHeartbeatStreamRebindInterval = time.Minute
+	defaultHeartbeatStreamRebindInterval    = time.Minute
+	defaultHeartbeatStreamKeepAliveInterval = time.Minute
 
 	defaultLeaderPriorityCheckInterval = time.Minute
 
@@ -239,6 +260,15 @@ const (
 
 	defaultDashboardAddress = "auto"
 
+	defaultDegradedMemoryRatio  = 0.8
+	defaultColdCacheTTL         = 10 * time.Minute
+	defaultStorageSlowThreshold = time.Second
+
+	defaultDiagnosisSlowHeartbeatThreshold = time.Second
+	defaultDiagnosisBundleLimit            = 5
+
+	defaultGatewayForwardRetries = 3
+
 	defaultDRWaitStoreTimeout = time.Minute
 	defaultDRWaitSyncTimeout  = time.Minute
 	defaultDRWaitAsyncTimeout = 2 * time.Minute
@@ -260,6 +290,8 @@ var (
 	defaultEnableTelemetry = true
 	defaultRuntimeServices = []string{}
 	defaultLocationLabels  = []string{}
+	// defaultSystemRanges is the meta range of TiDB, with the encoded keys.
+	defaultSystemRanges = []string{"6d00000000000000f8:6e00000000000000f8"}
 	// DefaultStoreLimit is the default store limit of add peer and remove peer.
 	DefaultStoreLimit = StoreLimit{AddPeer: 15, RemovePeer: 15}
 	// DefaultTiFlashStoreLimit is the default TiFlash store limit of add peer and remove peer.
@@ -402,6 +434,10 @@ func (c *Config) Parse(arguments []string) error {
 			msg := fmt.Sprintf("disable-telemetry in %s is deprecated, use enable-telemetry instead", c.configFile)
 			c.WarningMsgs = append(c.WarningMsgs, msg)
 		}
+		c.fileDefinedKeys = make(map[string]struct{})
+		for _, key := range meta.Keys() {
+			c.fileDefinedKeys[key.String()] = struct{}{}
+		}
 	}
 
 	// Parse again to replace with command line options.
@@ -576,6 +612,7 @@ func (c *Config) Adjust(meta *toml.MetaData, reloading bool) error {
 
 	c.adjustLog(configMetaData.Child("log"))
 	adjustDuration(&c.HeartbeatStreamBindInterval, defaultHeartbeatStreamRebindInterval)
+	adjustDuration(&c.HeartbeatStreamKeepAliveInterval, defaultHeartbeatStreamKeepAliveInterval)
 
 	adjustDuration(&c.LeaderPriorityCheckInterval, defaultLeaderPriorityCheckInterval)
 
@@ -589,6 +626,21 @@ func (c *Config) Adjust(meta *toml.MetaData, reloading bool) error {
 	c.Dashboard.adjust(configMetaData.Child("dashboard"))
 
 	c.ReplicationMode.adjust(configMetaData.Child("replication-mode"))
+	if err := c.ReplicationMode.DRAutoSync.Validate(); err != nil {
+		return err
+	}
+
+	if err := c.StatsExporter.adjust(); err != nil {
+		return err
+	}
+
+	if err := c.MetaSnapshot.adjust(); err != nil {
+		return err
+	}
+
+	if err := c.ReadAPI.adjust(configMetaData.Child("read-api")); err != nil {
+		return err
+	}
 
 	c.Security.Encryption.Adjust()
 
@@ -601,6 +653,13 @@ func (c *Config) adjustLog(meta *configMetaData) {
 	}
 }
 
+// IsDefinedInFile returns whether the key, such as
+// `schedule.max-snapshot-count`, is defined in the config file.
+func (c *Config) IsDefinedInFile(key string) bool {
+	_, ok := c.fileDefinedKeys[key]
+	return ok
+}
+
 // Clone returns a cloned configuration.
 func (c *Config) Clone() *Config {
 	cfg := *c
@@ -641,9 +700,120 @@ type ScheduleConfig struct {
 	EnableCrossTableMerge bool `toml:"enable-cross-table-merge" json:"enable-cross-table-merge,string"`
 	// PatrolRegionInterval is the interval for scanning region during patrol.
 	PatrolRegionInterval typeutil.Duration `toml:"patrol-region-interval" json:"patrol-region-interval"`
+	// PatrolCheckerOperatorLimit is the max number of the operators created by
+	// each checker in a patrol round. 0 means no limit.
+	PatrolCheckerOperatorLimit uint64 `toml:"patrol-checker-operator-limit" json:"patrol-checker-operator-limit"`
+	// PatrolSchedulerOperatorLimit is the max number of the operators created
+	// by each scheduler in a patrol round. 0 means no limit.
+	PatrolSchedulerOperatorLimit uint64 `toml:"patrol-scheduler-operator-limit" json:"patrol-scheduler-operator-limit"`
+	// PatrolInfluenceLimit is the max size in MB of the data moved by the
+	// operators created by the checkers in a patrol round, and the one by the
+	// schedulers. 0 means no limit.
+	PatrolInfluenceLimit uint64 `toml:"patrol-influence-limit" json:"patrol-influence-limit"`
 	// MaxStoreDownTime is the max duration after which
 	// a store will be considered to be down if it hasn't reported heartbeats.
 	MaxStoreDownTime typeutil.Duration `toml:"max-store-down-time" json:"max-store-down-time"`
+	// StoreSuspectGracePeriod is the duration after MaxStoreDownTime, during
+	// which a down store is suspected to come back soon, and its down peers are
+	// only fixed if the quorum of the regions is at risk.
+	StoreSuspectGracePeriod typeutil.Duration `toml:"store-suspect-grace-period" json:"store-suspect-grace-period"`
+	// StoreWarmUpDuration is the duration during which the add-peer limit of a
+	// newly joined store ramps up from a low initial value to the configured
+	// limit, so that the new stores are not flooded when scaling out. 0 means
+	// no warm-up.
+	StoreWarmUpDuration typeutil.Duration `toml:"store-warm-up-duration" json:"store-warm-up-duration"`
+	// EnableRegionCacheRepair is the option to remove the overlapped regions
+	// found by the region consistency check from the cache, so that they are
+	// refreshed by the next heartbeats of the stores.
+	EnableRegionCacheRepair bool `toml:"enable-region-cache-repair" json:"enable-region-cache-repair,string"`
+	// EnableStoreFairQueue is the option to queue the waiting operators by the
+	// stores they add peers to, and interleave the operators of different
+	// origins in a store with the weighted fairness.
+	EnableStoreFairQueue bool `toml:"enable-store-fair-queue" json:"enable-store-fair-queue,string"`
+	// ConformanceReportInterval is the interval of scanning all regions
+	// against the placement rules and the label policies to generate a
+	// conformance report. 0 means no report is generated periodically.
+	ConformanceReportInterval typeutil.Duration `toml:"conformance-report-interval" json:"conformance-report-interval"`
+	// EnableStoreAddressMigration is the option to allow the stores to change
+	// their addresses between the host names and the IP literals, which is
+	// rejected by default to avoid a node being registered in two ways.
+	EnableStoreAddressMigration bool `toml:"enable-store-address-migration" json:"enable-store-address-migration,string"`
+	// CompactionPressureThreshold is the pending compaction bytes of a store
+	// above which it is under compaction pressure. Such a store is the last
+	// resort to add peers to when repairing the replicas, and it is not the
+	// target of balancing the regions. 0 means the pressure is not checked.
+	CompactionPressureThreshold typeutil.ByteSize `toml:"compaction-pressure-threshold" json:"compaction-pressure-threshold"`
+	// CapacityForecastWarningDays is the predicted days to full of a store
+	// below which a warning is raised. 0 means no warning.
+	CapacityForecastWarningDays uint64 `toml:"capacity-forecast-warning-days" json:"capacity-forecast-warning-days"`
+	// CapacityForecastCriticalDays is the predicted days to full of a store
+	// below which it is critical. Such a store is not the target of balancing
+	// the regions, and the regions are moved away from it preemptively. 0
+	// means no store is critical.
+	CapacityForecastCriticalDays uint64 `toml:"capacity-forecast-critical-days" json:"capacity-forecast-critical-days"`
+	// TroubleRegionThreshold is how long a region has down peers, pending
+	// peers or no leader before it is put in the watchlist of the regions in
+	// trouble. 0 means no region is watched.
+	TroubleRegionThreshold typeutil.Duration `toml:"trouble-region-threshold" json:"trouble-region-threshold"`
+	// ExpectedStoreCount is the number of the stores the cluster is expected
+	// to have. The scheduling does not start until so many stores are up, so
+	// the regions are not balanced against a half-joined cluster. 0 means the
+	// scheduling starts once most of the regions are reported.
+	ExpectedStoreCount uint64 `toml:"expected-store-count" json:"expected-store-count"`
+	// HaltScheduling is the mode to halt the scheduling, which is one of
+	// pause-new, drain and abort-all. Empty means the scheduling is not
+	// halted.
+	HaltScheduling string `toml:"halt-scheduling" json:"halt-scheduling"`
+	// EnableStalePeerGC is the option to publish the peers removed by PD in
+	// the store heartbeat responses, so that the stores can clean up the peers
+	// lingering on them. The stores acknowledge the peers they have cleaned up.
+	EnableStalePeerGC bool `toml:"enable-stale-peer-gc" json:"enable-stale-peer-gc,string"`
+	// StalePeerAckTimeout is how long a removed peer is not acknowledged by
+	// its store before it is reported as overdue.
+	StalePeerAckTimeout typeutil.Duration `toml:"stale-peer-ack-timeout" json:"stale-peer-ack-timeout"`
+	// EnableUpgradeGuardrail is the option to reject the stores whose versions
+	// are lower than the cluster version, instead of letting them join a
+	// cluster which may already use the features they do not support.
+	EnableUpgradeGuardrail bool `toml:"enable-upgrade-guardrail" json:"enable-upgrade-guardrail,string"`
+	// MaintenanceWindows are the recurring windows for the bulk housekeeping,
+	// in which the schedule limits are raised to the limits of the window.
+	MaintenanceWindows []MaintenanceWindow `toml:"maintenance-windows" json:"maintenance-windows"`
+	// StoreLimitProfiles are the default store limits of the stores with the
+	// resource tags. The first profile matched is used.
+	StoreLimitProfiles []StoreLimitProfile `toml:"store-limit-profiles" json:"store-limit-profiles"`
+	// ZoneLabelKey is the store label key of the zones, which tells the moves
+	// across the zones. The zone transfer costs are ignored if it is empty.
+	ZoneLabelKey string `toml:"zone-label-key" json:"zone-label-key"`
+	// CrossZoneTransferCost is the cost weight of moving data across the
+	// zones, relative to 1 of moving data within a zone. The balance and hot
+	// region schedulers prefer the intra-zone moves when the scores are
+	// comparable.
+	CrossZoneTransferCost float64 `toml:"cross-zone-transfer-cost" json:"cross-zone-transfer-cost"`
+	// ZoneTransferCosts override the cost weights of the zone pairs.
+	ZoneTransferCosts []ZoneTransferCost `toml:"zone-transfer-costs" json:"zone-transfer-costs"`
+	// LeaderConstraints limit the ratio of the leaders on the stores with a
+	// label value, e.g. no more than 40% of the leaders in zone=z1.
+	LeaderConstraints []LeaderConstraint `toml:"leader-constraints" json:"leader-constraints"`
+	// AppendHotspotRules are the key prefixes written in the append-only way,
+	// whose write fronts are pre-split and scattered ahead of the writes.
+	AppendHotspotRules []AppendHotspotRule `toml:"append-hotspot-rules" json:"append-hotspot-rules"`
+	// HotSplitMaxThreshold is the byte rate over which the leader of a hot
+	// region is split by PD, for the tables which have not re-heated. The
+	// threshold of a table is halved each time its ranges heat again after
+	// cooling down, so the ranges which re-heat repeatedly are split earlier.
+	// 0 means the hot regions are not split by PD.
+	HotSplitMaxThreshold float64 `toml:"hot-split-max-threshold" json:"hot-split-max-threshold"`
+	// HotSplitMinThreshold is the lower bound which the threshold of a table
+	// is tuned down to.
+	HotSplitMinThreshold float64 `toml:"hot-split-min-threshold" json:"hot-split-min-threshold"`
+	// EnableKeyspaceFairQueue is the option to queue the waiting operators by
+	// the keyspaces of the regions, and promote the operators of different
+	// keyspaces with the weighted fairness, so the largest tenant cannot take
+	// all the scheduling.
+	EnableKeyspaceFairQueue bool `toml:"enable-keyspace-fair-queue" json:"enable-keyspace-fair-queue,string"`
+	// KeyspaceWeights are the weights of the keyspaces in the keyspace fair
+	// queue. The weight of a keyspace not listed is 1.
+	KeyspaceWeights []KeyspaceWeight `toml:"keyspace-weights" json:"keyspace-weights"`
 	// LeaderScheduleLimit is the max coexist leader schedules.
 	LeaderScheduleLimit uint64 `toml:"leader-schedule-limit" json:"leader-schedule-limit"`
 	// LeaderSchedulePolicy is the option to balance leader, there are some policies supported: ["count", "size"], default: "count"
@@ -652,6 +822,10 @@ type ScheduleConfig struct {
 	RegionScheduleLimit uint64 `toml:"region-schedule-limit" json:"region-schedule-limit"`
 	// ReplicaScheduleLimit is the max coexist replica schedules.
 	ReplicaScheduleLimit uint64 `toml:"replica-schedule-limit" json:"replica-schedule-limit"`
+	// OrphanPeerRemovalRate is the max count of the regions to remove the
+	// orphan peers per minute in the cluster, so that a mass rule change
+	// cannot saturate the remove-peer limits. 0 means no limit.
+	OrphanPeerRemovalRate uint64 `toml:"orphan-peer-removal-rate" json:"orphan-peer-removal-rate"`
 	// MergeScheduleLimit is the max coexist merge schedules.
 	MergeScheduleLimit uint64 `toml:"merge-schedule-limit" json:"merge-schedule-limit"`
 	// HotRegionScheduleLimit is the max coexist hot region schedules.
@@ -721,6 +895,15 @@ type ScheduleConfig struct {
 	EnableDebugMetrics bool `toml:"enable-debug-metrics" json:"enable-debug-metrics,string"`
 	// EnableJointConsensus is the option to enable using joint consensus as a operator step.
 	EnableJointConsensus bool `toml:"enable-joint-consensus" json:"enable-joint-consensus,string"`
+	// EnableSplitLeaderTransfer is the option to transfer the leader of one split half to the
+	// least-loaded follower store right after splitting a region.
+	EnableSplitLeaderTransfer bool `toml:"enable-split-leader-transfer" json:"enable-split-leader-transfer,string"`
+	// EnableTransferLeaderReadyCheck is the option to check if the target peer is ready
+	// before transferring the leader to it, and back off the unready targets for a while.
+	EnableTransferLeaderReadyCheck bool `toml:"enable-transfer-leader-ready-check" json:"enable-transfer-leader-ready-check,string"`
+	// EnableParallelMakeUpReplica is the option to add the missing replicas of a region
+	// in one operator, which adds the learners in parallel and promotes them with joint consensus.
+	EnableParallelMakeUpReplica bool `toml:"enable-parallel-make-up-replica" json:"enable-parallel-make-up-replica,string"`
 
 	// Schedulers support for loading customized schedulers
 	Schedulers SchedulerConfigs `toml:"schedulers" json:"schedulers-v2"` // json v2 is for the sake of compatible upgrade
@@ -750,6 +933,12 @@ func (c *ScheduleConfig) Clone() *ScheduleConfig {
 	cfg := *c
 	cfg.StoreLimit = storeLimit
 	cfg.Schedulers = schedulers
+	cfg.MaintenanceWindows = append(c.MaintenanceWindows[:0:0], c.MaintenanceWindows...)
+	cfg.StoreLimitProfiles = append(c.StoreLimitProfiles[:0:0], c.StoreLimitProfiles...)
+	cfg.ZoneTransferCosts = append(c.ZoneTransferCosts[:0:0], c.ZoneTransferCosts...)
+	cfg.LeaderConstraints = append(c.LeaderConstraints[:0:0], c.LeaderConstraints...)
+	cfg.AppendHotspotRules = append(c.AppendHotspotRules[:0:0], c.AppendHotspotRules...)
+	cfg.KeyspaceWeights = append(c.KeyspaceWeights[:0:0], c.KeyspaceWeights...)
 	cfg.SchedulersPayload = nil
 	return &cfg
 }
@@ -774,12 +963,19 @@ const (
 	defaultRegionScoreFormulaVersion = "v2"
 	// defaultHotRegionCacheHitsThreshold is the low hit number threshold of the
 	// hot region.
-	defaultHotRegionCacheHitsThreshold = 3
-	defaultSchedulerMaxWaitingOperator = 5
-	defaultLeaderSchedulePolicy        = "count"
-	defaultStoreLimitMode              = "manual"
-	defaultEnableJointConsensus        = true
-	defaultEnableCrossTableMerge       = true
+	defaultHotRegionCacheHitsThreshold  = 3
+	defaultSchedulerMaxWaitingOperator  = 5
+	defaultLeaderSchedulePolicy         = "count"
+	defaultStoreLimitMode               = "manual"
+	defaultEnableJointConsensus         = true
+	defaultEnableCrossTableMerge        = true
+	defaultConformanceReportInterval    = 24 * time.Hour
+	defaultTroubleRegionThreshold       = 10 * time.Minute
+	defaultStalePeerAckTimeout          = 10 * time.Minute
+	defaultCrossZoneTransferCost        = 2
+	defaultOrphanPeerRemovalRate        = 600
+	defaultCapacityForecastWarningDays  = 30
+	defaultCapacityForecastCriticalDays = 7
 )
 
 func (c *ScheduleConfig) adjust(meta *configMetaData, reloading bool) error {
@@ -798,6 +994,13 @@ func (c *ScheduleConfig) adjust(meta *configMetaData, reloading bool) error {
 	adjustDuration(&c.SplitMergeInterval, defaultSplitMergeInterval)
 	adjustDuration(&c.PatrolRegionInterval, defaultPatrolRegionInterval)
 	adjustDuration(&c.MaxStoreDownTime, defaultMaxStoreDownTime)
+	if !meta.IsDefined("conformance-report-interval") {
+		adjustDuration(&c.ConformanceReportInterval, defaultConformanceReportInterval)
+	}
+	if !meta.IsDefined("trouble-region-threshold") {
+		adjustDuration(&c.TroubleRegionThreshold, defaultTroubleRegionThreshold)
+	}
+	adjustDuration(&c.StalePeerAckTimeout, defaultStalePeerAckTimeout)
 	if !meta.IsDefined("leader-schedule-limit") {
 		adjustUint64(&c.LeaderScheduleLimit, defaultLeaderScheduleLimit)
 	}
@@ -810,6 +1013,15 @@ func (c *ScheduleConfig) adjust(meta *configMetaData, reloading bool) error {
 	if !meta.IsDefined("merge-schedule-limit") {
 		adjustUint64(&c.MergeScheduleLimit, defaultMergeScheduleLimit)
 	}
+	if !meta.IsDefined("orphan-peer-removal-rate") {
+		adjustUint64(&c.OrphanPeerRemovalRate, defaultOrphanPeerRemovalRate)
+	}
+	if !meta.IsDefined("capacity-forecast-warning-days") {
+		adjustUint64(&c.CapacityForecastWarningDays, defaultCapacityForecastWarningDays)
+	}
+	if !meta.IsDefined("capacity-forecast-critical-days") {
+		adjustUint64(&c.CapacityForecastCriticalDays, defaultCapacityForecastCriticalDays)
+	}
 	if !meta.IsDefined("hot-region-schedule-limit") {
 		adjustUint64(&c.HotRegionScheduleLimit, defaultHotRegionScheduleLimit)
 	}
@@ -834,6 +1046,7 @@ func (c *ScheduleConfig) adjust(meta *configMetaData, reloading bool) error {
 	if !meta.IsDefined("enable-cross-table-merge") {
 		c.EnableCrossTableMerge = defaultEnableCrossTableMerge
 	}
+	adjustFloat64(&c.CrossZoneTransferCost, defaultCrossZoneTransferCost)
 	adjustFloat64(&c.LowSpaceRatio, defaultLowSpaceRatio)
 	adjustFloat64(&c.HighSpaceRatio, defaultHighSpaceRatio)
 
@@ -926,6 +1139,68 @@ func (c *ScheduleConfig) Validate() error {
 		if !IsSchedulerRegistered(scheduleConfig.Type) {
 			return errors.Errorf("create func of %v is not registered, maybe misspelled", scheduleConfig.Type)
 		}
+		if _, err := ParseSchedulerWindow(scheduleConfig.Window); err != nil {
+			return err
+		}
+	}
+	for i := range c.MaintenanceWindows {
+		if err := c.MaintenanceWindows[i].Validate(); err != nil {
+			return err
+		}
+	}
+	for _, p := range c.StoreLimitProfiles {
+		if len(p.ResourceTags) == 0 {
+			return errors.New("resource-tags of store-limit-profiles should not be empty")
+		}
+		if p.AddPeer < 0 || p.RemovePeer < 0 {
+			return errors.New("store limit of store-limit-profiles should be nonnegative")
+		}
+	}
+	if c.CapacityForecastWarningDays > 0 && c.CapacityForecastCriticalDays > c.CapacityForecastWarningDays {
+		return errors.New("capacity-forecast-critical-days should not be larger than capacity-forecast-warning-days")
+	}
+	if c.CrossZoneTransferCost < 1 {
+		return errors.New("cross-zone-transfer-cost should not be less than 1")
+	}
+	if err := ValidateHaltScheduling(c.HaltScheduling); err != nil {
+		return err
+	}
+	for _, cost := range c.ZoneTransferCosts {
+		if cost.FromZone == "" || cost.ToZone == "" {
+			return errors.New("zones of zone-transfer-costs should not be empty")
+		}
+		if cost.Cost < 1 {
+			return errors.New("cost of zone-transfer-costs should not be less than 1")
+		}
+	}
+	for _, lc := range c.LeaderConstraints {
+		if err := lc.Validate(); err != nil {
+			return err
+		}
+	}
+	for _, rule := range c.AppendHotspotRules {
+		if err := rule.Validate(); err != nil {
+			return err
+		}
+	}
+	if c.HotSplitMaxThreshold < 0 || c.HotSplitMinThreshold < 0 {
+		return errors.New("hot-split-max-threshold and hot-split-min-threshold should be nonnegative")
+	}
+	if c.HotSplitMaxThreshold > 0 && c.HotSplitMinThreshold > c.HotSplitMaxThreshold {
+		return errors.New("hot-split-min-threshold should not be larger than hot-split-max-threshold")
+	}
+	keyspaces := make(map[uint32]struct{})
+	for _, w := range c.KeyspaceWeights {
+		if w.KeyspaceID > maxKeyspaceID {
+			return errors.Errorf("keyspace-id %d of keyspace-weights is out of range", w.KeyspaceID)
+		}
+		if _, ok := keyspaces[w.KeyspaceID]; ok {
+			return errors.Errorf("keyspace-id %d of keyspace-weights is duplicated", w.KeyspaceID)
+		}
+		keyspaces[w.KeyspaceID] = struct{}{}
+		if w.Weight <= 0 {
+			return errors.New("weight of keyspace-weights should be positive")
+		}
 	}
 	return nil
 }
@@ -962,6 +1237,173 @@ type StoreLimitConfig struct {
 	RemovePeer float64 `toml:"remove-peer" json:"remove-peer"`
 }
 
+// StoreLimitProfile is the default store limit of the stores which have all the
+// resource tags of the profile.
+type StoreLimitProfile struct {
+	ResourceTags map[string]string `toml:"resource-tags" json:"resource-tags"`
+	StoreLimitConfig
+}
+
+// Match returns whether the store with the resource tags matches the profile.
+func (p *StoreLimitProfile) Match(tags map[string]string) bool {
+	for k, v := range p.ResourceTags {
+		if tags[k] != v {
+			return false
+		}
+	}
+	return true
+}
+
+// GetStoreLimitProfile returns the first store limit profile matched by the
+// resource tags, or nil if there is none.
+func (c *ScheduleConfig) GetStoreLimitProfile(tags map[string]string) *StoreLimitProfile {
+	if len(tags) == 0 {
+		return nil
+	}
+	for i := range c.StoreLimitProfiles {
+		if c.StoreLimitProfiles[i].Match(tags) {
+			return &c.StoreLimitProfiles[i]
+		}
+	}
+	return nil
+}
+
+// ZoneTransferCost is the cost weight of moving data from a zone to another.
+type ZoneTransferCost struct {
+	FromZone string  `toml:"from-zone" json:"from-zone"`
+	ToZone   string  `toml:"to-zone" json:"to-zone"`
+	Cost     float64 `toml:"cost" json:"cost"`
+}
+
+// GetZoneTransferCost returns the cost weight of moving data from a zone to
+// another, which is 1 within a zone.
+func (c *ScheduleConfig) GetZoneTransferCost(fromZone, toZone string) float64 {
+	if fromZone == toZone {
+		return 1
+	}
+	for _, cost := range c.ZoneTransferCosts {
+		if cost.FromZone == fromZone && cost.ToZone == toZone {
+			return cost.Cost
+		}
+	}
+	return c.CrossZoneTransferCost
+}
+
+// The modes to halt the scheduling.
+const (
+	// HaltPauseNew stops the schedulers and the checkers creating the
+	// operators. The operators added manually and the in-flight ones still
+	// run.
+	HaltPauseNew = "pause-new"
+	// HaltDrain additionally rejects the operators added manually, and lets
+	// the in-flight operators run to finish.
+	HaltDrain = "drain"
+	// HaltAbortAll additionally cancels the outstanding operators once it is
+	// safe to stop them.
+	HaltAbortAll = "abort-all"
+)
+
+// ValidateHaltScheduling checks if the mode to halt the scheduling is valid.
+func ValidateHaltScheduling(mode string) error {
+	switch mode {
+	case "", HaltPauseNew, HaltDrain, HaltAbortAll:
+		return nil
+	}
+	return errors.Errorf("halt-scheduling should be empty, %s, %s or %s", HaltPauseNew, HaltDrain, HaltAbortAll)
+}
+
+// The modes of the leader constraints.
+const (
+	// LeaderConstraintSoft makes the balance-leader scheduler not move the
+	// leaders into the stores of a constraint beyond the max ratio, and move
+	// the leaders out of them first within its tolerance.
+	LeaderConstraintSoft = "soft"
+	// LeaderConstraintHard additionally makes the leader constraint checker
+	// move the leaders out of the stores of a violated constraint, regardless
+	// of the balance of the leaders.
+	LeaderConstraintHard = "hard"
+)
+
+// LeaderConstraint limits the ratio of the leaders on the stores with the
+// label value to all leaders of the cluster.
+type LeaderConstraint struct {
+	LabelKey   string  `toml:"label-key" json:"label-key"`
+	LabelValue string  `toml:"label-value" json:"label-value"`
+	MaxRatio   float64 `toml:"max-ratio" json:"max-ratio"`
+	// Mode is soft or hard, and it is soft if empty.
+	Mode string `toml:"mode" json:"mode,omitempty"`
+}
+
+// Validate checks if the leader constraint is valid.
+func (lc *LeaderConstraint) Validate() error {
+	if lc.LabelKey == "" || lc.LabelValue == "" {
+		return errors.New("label of leader-constraints should not be empty")
+	}
+	if lc.MaxRatio <= 0 || lc.MaxRatio > 1 {
+		return errors.New("max-ratio of leader-constraints should be in (0, 1]")
+	}
+	if lc.Mode != "" && lc.Mode != LeaderConstraintSoft && lc.Mode != LeaderConstraintHard {
+		return errors.Errorf("mode of leader-constraints should be %s or %s", LeaderConstraintSoft, LeaderConstraintHard)
+	}
+	return nil
+}
+
+// IsHard returns whether the leader constraint is in the hard mode.
+func (lc *LeaderConstraint) IsHard() bool {
+	return lc.Mode == LeaderConstraintHard
+}
+
+const (
+	defaultAppendHotspotPreSplitCount = 4
+	maxAppendHotspotPreSplitCount     = 64
+)
+
+// AppendHotspotRule makes the write front of the key prefix pre-split and
+// scattered, like the keys of an auto-increment primary key or a timestamp.
+type AppendHotspotRule struct {
+	// KeyPrefix is the hex of the key prefix.
+	KeyPrefix string `toml:"key-prefix" json:"key-prefix"`
+	// PreSplitCount is the number of the ranges kept ahead of the write
+	// front, and it is 4 if zero.
+	PreSplitCount int `toml:"pre-split-count" json:"pre-split-count,omitempty"`
+}
+
+// Validate checks if the append hotspot rule is valid.
+func (r *AppendHotspotRule) Validate() error {
+	prefix, err := hex.DecodeString(r.KeyPrefix)
+	if err != nil || len(prefix) == 0 {
+		return errors.New("key-prefix of append-hotspot-rules should be a non-empty hex string")
+	}
+	if r.PreSplitCount < 0 || r.PreSplitCount > maxAppendHotspotPreSplitCount {
+		return errors.Errorf("pre-split-count of append-hotspot-rules should be in [0, %d]", maxAppendHotspotPreSplitCount)
+	}
+	return nil
+}
+
+// GetKeyPrefix returns the decoded key prefix.
+func (r *AppendHotspotRule) GetKeyPrefix() []byte {
+	prefix, _ := hex.DecodeString(r.KeyPrefix)
+	return prefix
+}
+
+// GetPreSplitCount returns the number of the ranges kept ahead of the write
+// front.
+func (r *AppendHotspotRule) GetPreSplitCount() int {
+	if r.PreSplitCount == 0 {
+		return defaultAppendHotspotPreSplitCount
+	}
+	return r.PreSplitCount
+}
+
+// maxKeyspaceID is the max keyspace ID, which takes 3 bytes in the keys.
+const maxKeyspaceID = 1<<24 - 1
+
+// KeyspaceWeight is the weight of a keyspace in the keyspace fair queue.
+type KeyspaceWeight struct {
+	KeyspaceID uint32  `toml:"keyspace-id" json:"keyspace-id"`
+	Weight     float64 `toml:"weight" json:"weight"`
+}
+
 // SchedulerConfigs is a slice of customized scheduler configuration.
 type SchedulerConfigs []SchedulerConfig
 
@@ -971,6 +1413,56 @@ type SchedulerConfig struct {
 	Args        []string `toml:"args" json:"args"`
 	Disable     bool     `toml:"disable" json:"disable"`
 	ArgsPayload string   `toml:"args-payload" json:"args-payload"`
+	// Window is the daily time window in which the scheduler is allowed to
+	// schedule, e.g. "00:00-06:00". It is always allowed if it is empty.
+	Window string `toml:"window" json:"window,omitempty"`
+	// Shadow is whether the scheduler runs in the shadow mode, in which it
+	// computes the operators every tick, but never dispatches them.
+	Shadow bool `toml:"shadow" json:"shadow,omitempty"`
+}
+
+// SchedulerWindow is a daily time window in the local time of PD. The end can
+// be earlier than the start, which means the window crosses midnight.
+type SchedulerWindow struct {
+	start, end time.Duration
+}
+
+// ParseSchedulerWindow parses the window in the format of "HH:MM-HH:MM". It
+// returns nil if the window is empty.
+func ParseSchedulerWindow(window string) (*SchedulerWindow, error) {
+	if window == "" {
+		return nil, nil
+	}
+	parts := strings.Split(window, "-")
+	if len(parts) != 2 {
+		return nil, errs.ErrSchedulerConfig.FastGenByArgs(fmt.Sprintf("window %q should be in the format of HH:MM-HH:MM", window))
+	}
+	var offsets [2]time.Duration
+	for i, part := range parts {
+		t, err := time.Parse("15:04", strings.TrimSpace(part))
+		if err != nil {
+			return nil, errs.ErrSchedulerConfig.FastGenByArgs(fmt.Sprintf("window %q should be in the format of HH:MM-HH:MM", window))
+		}
+		offsets[i] = time.Duration(t.Hour())*time.Hour + time.Duration(t.Minute())*time.Minute
+	}
+	if offsets[0] == offsets[1] {
+		return nil, errs.ErrSchedulerConfig.FastGenByArgs(fmt.Sprintf("window %q should not be empty", window))
+	}
+	return &SchedulerWindow{start: offsets[0], end: offsets[1]}, nil
+}
+
+// Contains returns whether the time is in the window. A nil window contains
+// any time.
+func (w *SchedulerWindow) Contains(t time.Time) bool {
+	if w == nil {
+		return true
+	}
+	t = t.Local()
+	offset := time.Duration(t.Hour())*time.Hour + time.Duration(t.Minute())*time.Minute + time.Duration(t.Second())*time.Second
+	if w.start < w.end {
+		return offset >= w.start && offset < w.end
+	}
+	return offset >= w.start || offset < w.end
 }
 
 // DefaultSchedulers are the schedulers be created by default.
@@ -1017,13 +1509,29 @@ type ReplicationConfig struct {
 	// Even if a zone is down, PD will not try to make up replicas in other zone
 	// because other zones already have replicas on it.
 	IsolationLevel string `toml:"isolation-level" json:"isolation-level"`
+
+	// EnableSystemRangeIsolation protects the system ranges, such as the meta
+	// range of TiDB. The regions in them are placed by a built-in rule group
+	// on the stores selected by SystemRangeStoreLabels, checked with a higher
+	// priority, and not balanced. It needs the placement rules.
+	EnableSystemRangeIsolation bool `toml:"enable-system-range-isolation" json:"enable-system-range-isolation,string"`
+	// SystemRanges are the system ranges in the "start:end" form, whose keys
+	// are hex encoded.
+	SystemRanges typeutil.StringSlice `toml:"system-ranges" json:"system-ranges"`
+	// SystemRangeStoreLabels select the stores to place the system ranges, in
+	// the "key=value" form.
+	SystemRangeStoreLabels typeutil.StringSlice `toml:"system-range-store-labels" json:"system-range-store-labels"`
 }
 
 // Clone makes a deep copy of the config.
 func (c *ReplicationConfig) Clone() *ReplicationConfig {
 	locationLabels := append(c.LocationLabels[:0:0], c.LocationLabels...)
+	systemRanges := append(c.SystemRanges[:0:0], c.SystemRanges...)
+	systemRangeStoreLabels := append(c.SystemRangeStoreLabels[:0:0], c.SystemRangeStoreLabels...)
 	cfg := *c
 	cfg.LocationLabels = locationLabels
+	cfg.SystemRanges = systemRanges
+	cfg.SystemRangeStoreLabels = systemRangeStoreLabels
 	return &cfg
 }
 
@@ -1043,6 +1551,16 @@ func (c *ReplicationConfig) Validate() error {
 	if c.IsolationLevel != "" && !foundIsolationLevel {
 		return errors.New("isolation-level must be one of location-labels or empty")
 	}
+	for _, r := range c.SystemRanges {
+		if _, _, err := ParseSystemRange(r); err != nil {
+			return err
+		}
+	}
+	for _, l := range c.SystemRangeStoreLabels {
+		if _, err := ParseStoreLabel(l); err != nil {
+			return err
+		}
+	}
 	return nil
 }
 
@@ -1057,6 +1575,12 @@ func (c *ReplicationConfig) adjust(meta *configMetaData) error {
 	if !meta.IsDefined("location-labels") {
 		c.LocationLabels = defaultLocationLabels
 	}
+	if !meta.IsDefined("system-ranges") {
+		c.SystemRanges = append(c.SystemRanges[:0:0], defaultSystemRanges...)
+	}
+	if !meta.IsDefined("system-range-store-labels") {
+		c.SystemRangeStoreLabels = typeutil.StringSlice{}
+	}
 	return c.Validate()
 }
 
@@ -1081,6 +1605,103 @@ type PDServerConfig struct {
 	TraceRegionFlow bool `toml:"trace-region-flow" json:"trace-region-flow,string,omitempty"`
 	// FlowRoundByDigit used to discretization processing flow information.
 	FlowRoundByDigit int `toml:"flow-round-by-digit" json:"flow-round-by-digit"`
+	// MemoryLimit is the memory limit of PD. PD enters the degraded mode when
+	// its memory usage reaches MemoryLimit*DegradedMemoryRatio. 0 means no limit.
+	MemoryLimit typeutil.ByteSize `toml:"memory-limit" json:"memory-limit"`
+	// DegradedMemoryRatio is the ratio of MemoryLimit to enter the degraded mode.
+	DegradedMemoryRatio float64 `toml:"degraded-memory-ratio" json:"degraded-memory-ratio"`
+	// ColdCacheTTL is the duration an auxiliary cached object, such as the
+	// lineage or the cached fit of a region, is kept without being used once
+	// PD enters the degraded mode. 0 means never evict them.
+	ColdCacheTTL typeutil.Duration `toml:"cold-cache-ttl" json:"cold-cache-ttl"`
+	// EnablePrioritizedRegionLoading makes the leader load the regions in the
+	// system ranges and the hot regions first when it starts, and load the
+	// others in the background while the heartbeats are handled. The
+	// scheduling does not start until all regions are loaded.
+	EnablePrioritizedRegionLoading bool `toml:"enable-prioritized-region-loading" json:"enable-prioritized-region-loading,string"`
+	// StorageSlowThreshold is the duration of a write to the storage regarded
+	// as slow. The region saves become asynchronous and may be dropped, and
+	// the periodic persists of the store meta are suppressed when the writes
+	// are slow persistently. 0 means never.
+	StorageSlowThreshold typeutil.Duration `toml:"storage-slow-threshold" json:"storage-slow-threshold"`
+	// DiagnosisAutoCapture enables capturing a diagnosis bundle automatically
+	// when a region heartbeat is slower than DiagnosisSlowHeartbeatThreshold or
+	// PD enters the degraded mode.
+	DiagnosisAutoCapture bool `toml:"diagnosis-auto-capture" json:"diagnosis-auto-capture,string"`
+	// DiagnosisSlowHeartbeatThreshold is the handling duration of a region
+	// heartbeat to trigger capturing a diagnosis bundle.
+	DiagnosisSlowHeartbeatThreshold typeutil.Duration `toml:"diagnosis-slow-heartbeat-threshold" json:"diagnosis-slow-heartbeat-threshold"`
+	// DiagnosisBundleLimit is the maximum number of the diagnosis bundles kept on disk.
+	DiagnosisBundleLimit int `toml:"diagnosis-bundle-limit" json:"diagnosis-bundle-limit"`
+	// EnableFollowerGateway makes the followers handle the read APIs locally
+	// and forward the others to the leader, according to GatewayPolicies. The
+	// read APIs which need the running cluster are forwarded as well, since
+	// only the leader runs it.
+	EnableFollowerGateway bool `toml:"enable-follower-gateway" json:"enable-follower-gateway,string"`
+	// GatewayPolicies overrides the default policies of the follower gateway
+	// for the matched requests.
+	GatewayPolicies []GatewayPolicy `toml:"gateway-policies" json:"gateway-policies"`
+	// GatewayForwardRetries is the maximum number of retries to forward a
+	// request to the leader.
+	GatewayForwardRetries int `toml:"gateway-forward-retries" json:"gateway-forward-retries"`
+}
+
+// The actions of the gateway policies.
+const (
+	// GatewayLocal handles the request on the follower.
+	GatewayLocal = "local"
+	// GatewayForward forwards the request to the leader.
+	GatewayForward = "forward"
+)
+
+// GatewayPolicy is the policy of the follower gateway for the requests with
+// the path prefix and the methods.
+type GatewayPolicy struct {
+	// PathPrefix is the prefix of the request path, such as "/pd/api/v1/regions".
+	PathPrefix string `toml:"path-prefix" json:"path-prefix"`
+	// Methods are the HTTP methods of the requests. Empty means all methods.
+	Methods []string `toml:"methods" json:"methods,omitempty"`
+	// Action is either "local" or "forward".
+	Action string `toml:"action" json:"action"`
+}
+
+func (p *GatewayPolicy) match(method, path string) bool {
+	if !strings.HasPrefix(path, p.PathPrefix) {
+		return false
+	}
+	if len(p.Methods) == 0 {
+		return true
+	}
+	for _, m := range p.Methods {
+		if strings.EqualFold(m, method) {
+			return true
+		}
+	}
+	return false
+}
+
+// GetGatewayAction returns the action of the follower gateway for the request.
+// The policy with the longest matched path prefix wins, and the requests
+// matching no policy are handled locally if they are read-only. A request
+// handled locally is still forwarded if its handler needs the running
+// cluster, see serverapi.ForwardToLeader.
+func (c *PDServerConfig) GetGatewayAction(method, path string) string {
+	var matched *GatewayPolicy
+	for i := range c.GatewayPolicies {
+		p := &c.GatewayPolicies[i]
+		if p.match(method, path) && (matched == nil || len(p.PathPrefix) > len(matched.PathPrefix)) {
+			matched = p
+		}
+	}
+	if matched != nil {
+		return matched.Action
+	}
+	switch method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions:
+		return GatewayLocal
+	default:
+		return GatewayForward
+	}
 }
 
 func (c *PDServerConfig) adjust(meta *configMetaData) error {
@@ -1103,6 +1724,20 @@ func (c *PDServerConfig) adjust(meta *configMetaData) error {
 	if !meta.IsDefined("flow-round-by-digit") {
 		adjustInt(&c.FlowRoundByDigit, defaultFlowRoundByDigit)
 	}
+	if !meta.IsDefined("degraded-memory-ratio") {
+		adjustFloat64(&c.DegradedMemoryRatio, defaultDegradedMemoryRatio)
+	}
+	if !meta.IsDefined("cold-cache-ttl") {
+		adjustDuration(&c.ColdCacheTTL, defaultColdCacheTTL)
+	}
+	if !meta.IsDefined("storage-slow-threshold") {
+		adjustDuration(&c.StorageSlowThreshold, defaultStorageSlowThreshold)
+	}
+	adjustDuration(&c.DiagnosisSlowHeartbeatThreshold, defaultDiagnosisSlowHeartbeatThreshold)
+	adjustInt(&c.DiagnosisBundleLimit, defaultDiagnosisBundleLimit)
+	if !meta.IsDefined("gateway-forward-retries") {
+		adjustInt(&c.GatewayForwardRetries, defaultGatewayForwardRetries)
+	}
 	c.migrateConfigurationFromFile(meta)
 	return c.Validate()
 }
@@ -1135,8 +1770,13 @@ func (c *PDServerConfig) MigrateDeprecatedFlags() {
 // Clone returns a cloned PD server config.
 func (c *PDServerConfig) Clone() *PDServerConfig {
 	runtimeServices := append(c.RuntimeServices[:0:0], c.RuntimeServices...)
+	gatewayPolicies := append(c.GatewayPolicies[:0:0], c.GatewayPolicies...)
+	for i := range gatewayPolicies {
+		gatewayPolicies[i].Methods = append(gatewayPolicies[i].Methods[:0:0], gatewayPolicies[i].Methods...)
+	}
 	cfg := *c
 	cfg.RuntimeServices = runtimeServices
+	cfg.GatewayPolicies = gatewayPolicies
 	return &cfg
 }
 
@@ -1153,6 +1793,23 @@ func (c *PDServerConfig) Validate() error {
 	if c.FlowRoundByDigit < 0 {
 		return errs.ErrConfigItem.GenWithStack("flow round by digit cannot be negative number")
 	}
+	if c.DegradedMemoryRatio <= 0 || c.DegradedMemoryRatio > 1 {
+		return errs.ErrConfigItem.GenWithStack("degraded memory ratio should be in (0, 1]")
+	}
+	if c.DiagnosisBundleLimit < 0 {
+		return errs.ErrConfigItem.GenWithStack("diagnosis bundle limit cannot be negative number")
+	}
+	if c.GatewayForwardRetries < 0 {
+		return errs.ErrConfigItem.GenWithStack("gateway forward retries cannot be negative number")
+	}
+	for _, p := range c.GatewayPolicies {
+		if !strings.HasPrefix(p.PathPrefix, "/") {
+			return errs.ErrConfigItem.GenWithStack("gateway policy path prefix should start with '/'")
+		}
+		if p.Action != GatewayLocal && p.Action != GatewayForward {
+			return errs.ErrConfigItem.GenWithStack("gateway policy action should be 'local' or 'forward'")
+		}
+	}
 
 	return nil
 }
@@ -1347,6 +2004,137 @@ func (c *DashboardConfig) adjust(meta *configMetaData) {
 	c.EnableTelemetry = c.EnableTelemetry && !c.DisableTelemetry
 }
 
+// The sinks which the region statistics can be exported to.
+const (
+	StatsSinkFile  = "file"
+	StatsSinkS3    = "s3"
+	StatsSinkKafka = "kafka"
+)
+
+const defaultStatsExportInterval = 5 * time.Minute
+
+// StatsExporterConfig is the configuration for exporting the snapshots of the
+// region statistics to an external sink periodically, so that they can be
+// analyzed offline.
+type StatsExporterConfig struct {
+	// Sink is where the snapshots are exported to, which can be "file", "s3"
+	// or "kafka". The exporter is disabled if it is empty.
+	Sink     string            `toml:"sink" json:"sink"`
+	Interval typeutil.Duration `toml:"interval" json:"interval"`
+	// FilePath is the file which the snapshots are appended to, one JSON
+	// object per line.
+	FilePath string `toml:"file-path" json:"file-path"`
+	// S3Endpoint is the endpoint of an S3-compatible storage. AWS S3 is used
+	// if it is empty. The credentials are read from the environment.
+	S3Endpoint string `toml:"s3-endpoint" json:"s3-endpoint"`
+	S3Region   string `toml:"s3-region" json:"s3-region"`
+	S3Bucket   string `toml:"s3-bucket" json:"s3-bucket"`
+	S3Prefix   string `toml:"s3-prefix" json:"s3-prefix"`
+	// KafkaRESTURL is the address of the Kafka REST proxy, through which the
+	// snapshots are produced to KafkaTopic.
+	KafkaRESTURL string `toml:"kafka-rest-url" json:"kafka-rest-url"`
+	KafkaTopic   string `toml:"kafka-topic" json:"kafka-topic"`
+}
+
+func (c *StatsExporterConfig) adjust() error {
+	adjustDuration(&c.Interval, defaultStatsExportInterval)
+	switch c.Sink {
+	case "":
+	case StatsSinkFile:
+		if c.FilePath == "" {
+			return errors.New("stats-exporter.file-path is required by the file sink")
+		}
+	case StatsSinkS3:
+		if c.S3Bucket == "" {
+			return errors.New("stats-exporter.s3-bucket is required by the s3 sink")
+		}
+	case StatsSinkKafka:
+		if c.KafkaRESTURL == "" || c.KafkaTopic == "" {
+			return errors.New("stats-exporter.kafka-rest-url and stats-exporter.kafka-topic are required by the kafka sink")
+		}
+		if err := ValidateURLWithScheme(c.KafkaRESTURL); err != nil {
+			return err
+		}
+	default:
+		return errors.Errorf("unknown stats-exporter.sink %s", c.Sink)
+	}
+	return nil
+}
+
+const defaultMetaSnapshotInterval = time.Hour
+
+// MetaSnapshotConfig is the configuration for exporting the snapshots of the
+// PD meta, like the rules, the configs and the stores, to an object storage
+// periodically, so the meta can be restored if the etcd backups are lost.
+type MetaSnapshotConfig struct {
+	// Storage is the URL of the object storage, which is either
+	// "local:///path/to/dir" or "s3://bucket/prefix". The export is disabled
+	// if it is empty.
+	Storage  string            `toml:"storage" json:"storage"`
+	Interval typeutil.Duration `toml:"interval" json:"interval"`
+}
+
+func (c *MetaSnapshotConfig) adjust() error {
+	adjustDuration(&c.Interval, defaultMetaSnapshotInterval)
+	if c.Storage == "" {
+		return nil
+	}
+	u, err := url.Parse(c.Storage)
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	switch u.Scheme {
+	case "local":
+		if u.Path == "" {
+			return errors.New("meta-snapshot.storage should have the path of the local directory")
+		}
+	case "s3":
+		if u.Host == "" {
+			return errors.New("meta-snapshot.storage should have the bucket of s3")
+		}
+	default:
+		return errors.Errorf("unknown scheme of meta-snapshot.storage %s", c.Storage)
+	}
+	return nil
+}
+
+const (
+	defaultReadAPIMaxConnections = 1024
+	defaultReadAPIRateLimit      = 500
+)
+
+// ReadAPIConfig is the configuration for serving the read-only HTTP API on a
+// separate listener, so that the heavy reads of the dashboard and the
+// monitoring can't exhaust the connections needed by the control path of TiDB
+// and TiKV. The read-only API is also served on the client URLs as before.
+type ReadAPIConfig struct {
+	// ListenURL is the URL the read-only API listens on, such as
+	// "http://0.0.0.0:2381". The listener is disabled if it is empty.
+	ListenURL string `toml:"listen-url" json:"listen-url"`
+	// MaxConnections is the max number of the concurrent connections. 0 means
+	// no limit.
+	MaxConnections int `toml:"max-connections" json:"max-connections"`
+	// RateLimit is the max number of the requests per second. 0 means no
+	// limit.
+	RateLimit int `toml:"rate-limit" json:"rate-limit"`
+}
+
+func (c *ReadAPIConfig) adjust(meta *configMetaData) error {
+	if !meta.IsDefined("max-connections") {
+		c.MaxConnections = defaultReadAPIMaxConnections
+	}
+	if !meta.IsDefined("rate-limit") {
+		c.RateLimit = defaultReadAPIRateLimit
+	}
+	if c.MaxConnections < 0 || c.RateLimit < 0 {
+		return errors.New("read-api.max-connections and read-api.rate-limit should be nonnegative")
+	}
+	if c.ListenURL != "" {
+		return ValidateURLWithScheme(c.ListenURL)
+	}
+	return nil
+}
+
 // ReplicationModeConfig is the configuration for the replication policy.
 type ReplicationModeConfig struct {
 	ReplicationMode string                      `toml:"replication-mode" json:"replication-mode"` // can be 'dr-auto-sync' or 'majority', default value is 'majority'
@@ -1356,9 +2144,18 @@ type ReplicationModeConfig struct {
 // Clone returns a copy of replication mode config.
 func (c *ReplicationModeConfig) Clone() *ReplicationModeConfig {
 	cfg := *c
+	cfg.DRAutoSync.Ranges = append(c.DRAutoSync.Ranges[:0:0], c.DRAutoSync.Ranges...)
 	return &cfg
 }
 
+// Validate checks if the replication mode config is valid.
+func (c *ReplicationModeConfig) Validate() error {
+	if NormalizeReplicationMode(c.ReplicationMode) == "" {
+		return errors.Errorf("invalid replication mode: %v", c.ReplicationMode)
+	}
+	return c.DRAutoSync.Validate()
+}
+
 func (c *ReplicationModeConfig) adjust(meta *configMetaData) {
 	if !meta.IsDefined("replication-mode") || NormalizeReplicationMode(c.ReplicationMode) == "" {
 		c.ReplicationMode = "majority"
@@ -1386,6 +2183,60 @@ type DRAutoSyncReplicationConfig struct {
 	WaitStoreTimeout typeutil.Duration `toml:"wait-store-timeout" json:"wait-store-timeout"`
 	WaitSyncTimeout  typeutil.Duration `toml:"wait-sync-timeout" json:"wait-sync-timeout"`
 	WaitAsyncTimeout typeutil.Duration `toml:"wait-async-timeout" json:"wait-async-timeout"`
+	// Ranges are the key ranges whose states and recover progress are
+	// reported separately, like the ranges of the tables.
+	Ranges []DRAutoSyncRange `toml:"ranges" json:"ranges,omitempty"`
+}
+
+// Validate checks if the dr-auto-sync config is valid.
+func (c *DRAutoSyncReplicationConfig) Validate() error {
+	names := make(map[string]struct{}, len(c.Ranges))
+	for i := range c.Ranges {
+		if err := c.Ranges[i].Validate(); err != nil {
+			return err
+		}
+		if _, ok := names[c.Ranges[i].Name]; ok {
+			return errors.Errorf("duplicated name %s of dr-auto-sync ranges", c.Ranges[i].Name)
+		}
+		names[c.Ranges[i].Name] = struct{}{}
+	}
+	return nil
+}
+
+// DRAutoSyncRange is a key range whose state is reported separately in the
+// dr-auto-sync mode.
+type DRAutoSyncRange struct {
+	Name string `toml:"name" json:"name"`
+	// StartKey and EndKey are the hex of the keys. An empty EndKey means the
+	// range is not bounded.
+	StartKey string `toml:"start-key" json:"start-key"`
+	EndKey   string `toml:"end-key" json:"end-key"`
+}
+
+// Validate checks if the range is valid.
+func (r *DRAutoSyncRange) Validate() error {
+	if r.Name == "" {
+		return errors.New("name of dr-auto-sync ranges should not be empty")
+	}
+	start, err := hex.DecodeString(r.StartKey)
+	if err != nil {
+		return errors.Errorf("start-key of dr-auto-sync range %s should be a hex string", r.Name)
+	}
+	end, err := hex.DecodeString(r.EndKey)
+	if err != nil {
+		return errors.Errorf("end-key of dr-auto-sync range %s should be a hex string", r.Name)
+	}
+	if len(end) > 0 && bytes.Compare(start, end) >= 0 {
+		return errors.Errorf("start-key of dr-auto-sync range %s should be less than end-key", r.Name)
+	}
+	return nil
+}
+
+// GetKeys returns the decoded keys of the range.
+func (r *DRAutoSyncRange) GetKeys() (startKey, endKey []byte) {
+	startKey, _ = hex.DecodeString(r.StartKey)
+	endKey, _ = hex.DecodeString(r.EndKey)
+	return
 }
 
 func (c *DRAutoSyncReplicationConfig) adjust(meta *configMetaData) {
@@ -14,6 +14,7 @@
 package config
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
 	"math"
@@ -25,6 +26,8 @@ import (
 
 	"github.com/BurntSushi/toml"
 	. "github.com/pingcap/check"
+	"github.com/tikv/pd/pkg/cache"
+	"github.com/tikv/pd/pkg/typeutil"
 	"github.com/tikv/pd/server/core"
 	"github.com/tikv/pd/server/kv"
 )
@@ -159,6 +162,122 @@ func (s *testConfigSuite) TestValidation(c *C) {
 	c.Assert(cfg.QuotaBackendBytes, Equals, defaultQuotaBackendBytes)
 }
 
+func (s *testConfigSuite) TestSystemRange(c *C) {
+	cfg := NewConfig()
+	c.Assert(cfg.Adjust(nil, false), IsNil)
+	c.Assert(cfg.Replication.EnableSystemRangeIsolation, IsFalse)
+	c.Assert(cfg.Replication.SystemRanges, DeepEquals, typeutil.StringSlice(defaultSystemRanges))
+
+	startKeyHex, endKeyHex, err := ParseSystemRange("6D:6E")
+	c.Assert(err, IsNil)
+	c.Assert(startKeyHex, Equals, "6d")
+	c.Assert(endKeyHex, Equals, "6e")
+	_, endKeyHex, err = ParseSystemRange("6d:")
+	c.Assert(err, IsNil)
+	c.Assert(endKeyHex, Equals, "")
+	for _, r := range []string{"6d", "6x:6e", "6d:6x", "6e:6d"} {
+		_, _, err = ParseSystemRange(r)
+		c.Assert(err, NotNil)
+	}
+	label, err := ParseStoreLabel("zone=meta")
+	c.Assert(err, IsNil)
+	c.Assert(label.GetKey(), Equals, "zone")
+	c.Assert(label.GetValue(), Equals, "meta")
+	for _, l := range []string{"zone", "zone=", "=meta", "zone=a b"} {
+		_, err = ParseStoreLabel(l)
+		c.Assert(err, NotNil)
+	}
+
+	cfg.Replication.SystemRanges = typeutil.StringSlice{"6e:6d"}
+	c.Assert(cfg.Replication.Validate(), NotNil)
+	cfg.Replication.SystemRanges = typeutil.StringSlice{"6d:6e"}
+	cfg.Replication.SystemRangeStoreLabels = typeutil.StringSlice{"zone"}
+	c.Assert(cfg.Replication.Validate(), NotNil)
+	cfg.Replication.SystemRangeStoreLabels = typeutil.StringSlice{"zone=meta"}
+	c.Assert(cfg.Replication.Validate(), IsNil)
+}
+
+func (s *testConfigSuite) TestSchedulerWindow(c *C) {
+	for _, window := range []string{"00:00-24:00", "06:00", "06:00-06:00", "a-b"} {
+		_, err := ParseSchedulerWindow(window)
+		c.Assert(err, NotNil)
+	}
+	w, err := ParseSchedulerWindow("")
+	c.Assert(err, IsNil)
+	c.Assert(w.Contains(time.Now()), IsTrue)
+
+	day := time.Date(2021, 6, 1, 0, 0, 0, 0, time.Local)
+	w, err = ParseSchedulerWindow("01:30-06:00")
+	c.Assert(err, IsNil)
+	c.Assert(w.Contains(day.Add(time.Hour)), IsFalse)
+	c.Assert(w.Contains(day.Add(90*time.Minute)), IsTrue)
+	c.Assert(w.Contains(day.Add(6*time.Hour-time.Second)), IsTrue)
+	c.Assert(w.Contains(day.Add(6*time.Hour)), IsFalse)
+
+	// The window crosses midnight.
+	w, err = ParseSchedulerWindow("22:00-02:00")
+	c.Assert(err, IsNil)
+	c.Assert(w.Contains(day.Add(23*time.Hour)), IsTrue)
+	c.Assert(w.Contains(day.Add(time.Hour)), IsTrue)
+	c.Assert(w.Contains(day.Add(12*time.Hour)), IsFalse)
+}
+
+func (s *testConfigSuite) TestMaintenanceWindow(c *C) {
+	for _, schedule := range []string{"", "0 1 * *", "60 * * * *", "* 24 * * *", "* * 0 * *", "* * * 13 *", "* * * * 8", "5-1 * * * *", "*/0 * * * *", "a * * * *"} {
+		w := &MaintenanceWindow{Schedule: schedule, Duration: typeutil.NewDuration(time.Hour)}
+		c.Assert(w.Validate(), NotNil, Commentf("schedule %q", schedule))
+	}
+	for _, d := range []time.Duration{0, time.Second, 8 * 24 * time.Hour} {
+		w := &MaintenanceWindow{Schedule: "0 1 * * *", Duration: typeutil.NewDuration(d)}
+		c.Assert(w.Validate(), NotNil)
+	}
+
+	// 2021-06-01 is Tuesday.
+	day := time.Date(2021, 6, 1, 0, 0, 0, 0, time.Local)
+	w := &MaintenanceWindow{Schedule: "30 1,22 * * 1-5", Duration: typeutil.NewDuration(2 * time.Hour)}
+	c.Assert(w.Validate(), IsNil)
+	start, ok := w.StartBefore(day.Add(2 * time.Hour))
+	c.Assert(ok, IsTrue)
+	c.Assert(start.Equal(day.Add(90*time.Minute)), IsTrue)
+	_, ok = w.StartBefore(day.Add(time.Hour))
+	c.Assert(ok, IsFalse)
+	_, ok = w.StartBefore(day.Add(210 * time.Minute))
+	c.Assert(ok, IsFalse)
+	// The window crosses midnight.
+	start, ok = w.StartBefore(day.Add(24 * time.Hour))
+	c.Assert(ok, IsTrue)
+	c.Assert(start.Equal(day.Add(22*time.Hour+30*time.Minute)), IsTrue)
+	// Not on Sunday.
+	_, ok = w.StartBefore(day.Add(5*24*time.Hour + 2*time.Hour))
+	c.Assert(ok, IsFalse)
+
+	// The day matches either the day of month or the day of week.
+	w = &MaintenanceWindow{Schedule: "0 */6 1 * 0", Duration: typeutil.NewDuration(time.Hour)}
+	c.Assert(w.Validate(), IsNil)
+	_, ok = w.StartBefore(day.Add(6 * time.Hour))
+	c.Assert(ok, IsTrue)
+	_, ok = w.StartBefore(day.Add(24*time.Hour + 6*time.Hour))
+	c.Assert(ok, IsFalse)
+	_, ok = w.StartBefore(day.Add(5*24*time.Hour + 12*time.Hour))
+	c.Assert(ok, IsTrue)
+
+	cfg := NewConfig()
+	c.Assert(cfg.Adjust(nil, false), IsNil)
+	cfg.Schedule.MaintenanceWindows = []MaintenanceWindow{{Schedule: "* * * * *", Duration: typeutil.NewDuration(time.Minute), RegionScheduleLimit: 64, MergeScheduleLimit: 32}}
+	c.Assert(cfg.Schedule.Validate(), IsNil)
+	opt := NewPersistOptions(cfg)
+	c.Assert(opt.GetMaintenanceWindowStatus().Active, IsTrue)
+	c.Assert(opt.GetLeaderScheduleLimit(), Equals, cfg.Schedule.LeaderScheduleLimit)
+	c.Assert(opt.GetRegionScheduleLimit(), Equals, uint64(64))
+	c.Assert(opt.GetMergeScheduleLimit(), Equals, uint64(32))
+	clone := cfg.Schedule.Clone()
+	clone.MaintenanceWindows = nil
+	opt.SetScheduleConfig(clone)
+	c.Assert(opt.GetActiveMaintenanceWindow(), IsNil)
+	c.Assert(opt.GetRegionScheduleLimit(), Equals, cfg.Schedule.RegionScheduleLimit)
+	c.Assert(opt.GetMergeScheduleLimit(), Equals, cfg.Schedule.MergeScheduleLimit)
+}
+
 func (s *testConfigSuite) TestAdjust(c *C) {
 	cfgData := `
 name = ""
@@ -386,6 +505,47 @@ dashboard-address = "foo"
 	}
 }
 
+func (s *testConfigSuite) TestGatewayPolicies(c *C) {
+	cfgData := `
+[pd-server]
+enable-follower-gateway = true
+[[pd-server.gateway-policies]]
+path-prefix = "/pd/api/v1/regions"
+action = "forward"
+[[pd-server.gateway-policies]]
+path-prefix = "/pd/api/v1/regions/check"
+methods = ["get"]
+action = "local"
+[[pd-server.gateway-policies]]
+path-prefix = "/pd/api/v1/admin"
+methods = ["POST"]
+action = "local"
+`
+	cfg := NewConfig()
+	meta, err := toml.Decode(cfgData, &cfg)
+	c.Assert(err, IsNil)
+	c.Assert(cfg.Adjust(&meta, false), IsNil)
+	pdServer := &cfg.PDServerCfg
+	c.Assert(pdServer.EnableFollowerGateway, IsTrue)
+	c.Assert(pdServer.GatewayForwardRetries, Equals, defaultGatewayForwardRetries)
+	c.Assert(pdServer.GetGatewayAction("GET", "/pd/api/v1/version"), Equals, GatewayLocal)
+	c.Assert(pdServer.GetGatewayAction("GET", "/pd/api/v1/status"), Equals, GatewayLocal)
+	c.Assert(pdServer.GetGatewayAction("GET", "/pd/api/v1/stores"), Equals, GatewayLocal)
+	c.Assert(pdServer.GetGatewayAction("HEAD", "/pd/api/v1/operators"), Equals, GatewayLocal)
+	c.Assert(pdServer.GetGatewayAction("POST", "/pd/api/v1/config/schedule"), Equals, GatewayForward)
+	c.Assert(pdServer.GetGatewayAction("DELETE", "/pd/api/v1/store/1"), Equals, GatewayForward)
+	c.Assert(pdServer.GetGatewayAction("GET", "/pd/api/v1/regions/key"), Equals, GatewayForward)
+	c.Assert(pdServer.GetGatewayAction("GET", "/pd/api/v1/regions/check/miss-peer"), Equals, GatewayLocal)
+	c.Assert(pdServer.GetGatewayAction("POST", "/pd/api/v1/admin/log"), Equals, GatewayLocal)
+	c.Assert(pdServer.Clone(), DeepEquals, pdServer)
+
+	pdServer.GatewayPolicies[0].Action = "drop"
+	c.Assert(pdServer.Validate(), NotNil)
+	pdServer.GatewayPolicies[0].Action = GatewayLocal
+	pdServer.GatewayPolicies[0].PathPrefix = "pd"
+	c.Assert(pdServer.Validate(), NotNil)
+}
+
 func (s *testConfigSuite) TestDashboardConfig(c *C) {
 	cfgData := `
 [dashboard]
@@ -459,6 +619,75 @@ wait-store-timeout = "120s"
 	c.Assert(cfg.ReplicationMode.ReplicationMode, Equals, "majority")
 }
 
+func (s *testConfigSuite) TestReadAPIConfig(c *C) {
+	cfg := NewConfig()
+	meta, err := toml.Decode("", &cfg)
+	c.Assert(err, IsNil)
+	c.Assert(cfg.Adjust(&meta, false), IsNil)
+	c.Assert(cfg.ReadAPI.ListenURL, Equals, "")
+	c.Assert(cfg.ReadAPI.MaxConnections, Equals, defaultReadAPIMaxConnections)
+	c.Assert(cfg.ReadAPI.RateLimit, Equals, defaultReadAPIRateLimit)
+
+	cfgData := `
+[read-api]
+listen-url = "http://0.0.0.0:2381"
+max-connections = 0
+rate-limit = 100
+`
+	cfg = NewConfig()
+	meta, err = toml.Decode(cfgData, &cfg)
+	c.Assert(err, IsNil)
+	c.Assert(cfg.Adjust(&meta, false), IsNil)
+	c.Assert(cfg.ReadAPI.MaxConnections, Equals, 0)
+	c.Assert(cfg.ReadAPI.RateLimit, Equals, 100)
+
+	for _, cfgData := range []string{
+		"[read-api]\nlisten-url = \"0.0.0.0:2381\"",
+		"[read-api]\nmax-connections = -1",
+		"[read-api]\nrate-limit = -1",
+	} {
+		cfg = NewConfig()
+		meta, err = toml.Decode(cfgData, &cfg)
+		c.Assert(err, IsNil)
+		c.Assert(cfg.Adjust(&meta, false), NotNil)
+	}
+}
+
+func (s *testConfigSuite) TestStatsExporterConfig(c *C) {
+	cfgData := `
+[stats-exporter]
+sink = "kafka"
+interval = "1m"
+kafka-rest-url = "http://127.0.0.1:8082"
+kafka-topic = "region-stats"
+`
+	cfg := NewConfig()
+	meta, err := toml.Decode(cfgData, &cfg)
+	c.Assert(err, IsNil)
+	c.Assert(cfg.Adjust(&meta, false), IsNil)
+	c.Assert(cfg.StatsExporter.Sink, Equals, StatsSinkKafka)
+	c.Assert(cfg.StatsExporter.Interval.Duration, Equals, time.Minute)
+
+	for _, cfgData := range []string{
+		"[stats-exporter]\nsink = \"hdfs\"",
+		"[stats-exporter]\nsink = \"file\"",
+		"[stats-exporter]\nsink = \"s3\"",
+		"[stats-exporter]\nsink = \"kafka\"\nkafka-rest-url = \"127.0.0.1:8082\"\nkafka-topic = \"region-stats\"",
+	} {
+		cfg = NewConfig()
+		meta, err = toml.Decode(cfgData, &cfg)
+		c.Assert(err, IsNil)
+		c.Assert(cfg.Adjust(&meta, false), NotNil)
+	}
+
+	cfg = NewConfig()
+	meta, err = toml.Decode("", &cfg)
+	c.Assert(err, IsNil)
+	c.Assert(cfg.Adjust(&meta, false), IsNil)
+	c.Assert(cfg.StatsExporter.Sink, Equals, "")
+	c.Assert(cfg.StatsExporter.Interval.Duration, Equals, defaultStatsExportInterval)
+}
+
 func (s *testConfigSuite) TestConfigClone(c *C) {
 	cfg := &Config{}
 	cfg.Adjust(nil, false)
@@ -482,3 +711,143 @@ func (s *testConfigSuite) TestConfigClone(c *C) {
 	replicationMode.adjust(emptyConfigMetaData)
 	c.Assert(replicationMode.Clone(), DeepEquals, replicationMode)
 }
+
+func (s *testConfigSuite) TestExplainEffective(c *C) {
+	cfgData := `
+[schedule]
+leader-schedule-limit = 8
+`
+	file := path.Join(c.MkDir(), "pd.toml")
+	c.Assert(os.WriteFile(file, []byte(cfgData), 0644), IsNil)
+	cfg := NewConfig()
+	c.Assert(cfg.Parse([]string{"-config", file}), IsNil)
+	c.Assert(cfg.IsDefinedInFile("schedule.leader-schedule-limit"), IsTrue)
+	c.Assert(cfg.IsDefinedInFile("schedule.region-schedule-limit"), IsFalse)
+
+	opt := NewPersistOptions(cfg)
+	scheduleCfg := opt.GetScheduleConfig().Clone()
+	scheduleCfg.MaxSnapshotCount = 10
+	opt.SetScheduleConfig(scheduleCfg)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	opt.ttl = cache.NewStringTTL(ctx, time.Second, time.Minute)
+	opt.ttl.PutWithTTL("schedule.merge-schedule-limit", "0", time.Minute)
+	opt.ttl.PutWithTTL("add-peer-1", "30", time.Minute)
+
+	items, err := opt.ExplainEffective(cfg)
+	c.Assert(err, IsNil)
+	c.Assert(items["schedule.leader-schedule-limit"].Source, Equals, SourceFile)
+	c.Assert(items["schedule.leader-schedule-limit"].Value, Equals, float64(8))
+	c.Assert(items["schedule.region-schedule-limit"].Source, Equals, SourceDefault)
+	c.Assert(items["schedule.max-snapshot-count"].Source, Equals, SourcePersisted)
+	c.Assert(items["schedule.max-snapshot-count"].Value, Equals, float64(10))
+	c.Assert(items["replication.max-replicas"].Source, Equals, SourceDefault)
+	for _, key := range []string{"schedule.merge-schedule-limit", "schedule.store-limit.1.add-peer"} {
+		c.Assert(items[key].Source, Equals, SourceTTL)
+		c.Assert(items[key].Expire, NotNil)
+	}
+	c.Assert(items["schedule.merge-schedule-limit"].Value, Equals, float64(0))
+	c.Assert(items["schedule.store-limit.1.add-peer"].Value, Equals, float64(30))
+	c.Assert(items["schedule.merge-schedule-limit"].Expire.After(time.Now()), IsTrue)
+}
+
+func (s *testConfigSuite) TestZoneTransferCost(c *C) {
+	cfgData := `
+[schedule]
+zone-label-key = "zone"
+[[schedule.zone-transfer-costs]]
+from-zone = "z1"
+to-zone = "z2"
+cost = 5.0
+`
+	cfg := NewConfig()
+	meta, err := toml.Decode(cfgData, &cfg)
+	c.Assert(err, IsNil)
+	c.Assert(cfg.Adjust(&meta, false), IsNil)
+	c.Assert(cfg.Schedule.CrossZoneTransferCost, Equals, float64(defaultCrossZoneTransferCost))
+	c.Assert(cfg.Schedule.GetZoneTransferCost("z1", "z1"), Equals, 1.0)
+	c.Assert(cfg.Schedule.GetZoneTransferCost("z1", "z2"), Equals, 5.0)
+	c.Assert(cfg.Schedule.GetZoneTransferCost("z2", "z1"), Equals, float64(defaultCrossZoneTransferCost))
+
+	cfg.Schedule.ZoneTransferCosts[0].Cost = 0.5
+	c.Assert(cfg.Schedule.Validate(), NotNil)
+	cfg.Schedule.ZoneTransferCosts[0].Cost = 1
+	cfg.Schedule.CrossZoneTransferCost = 0.5
+	c.Assert(cfg.Schedule.Validate(), NotNil)
+}
+
+func (s *testConfigSuite) TestLeaderConstraints(c *C) {
+	cfgData := `
+[[schedule.leader-constraints]]
+label-key = "zone"
+label-value = "z1"
+max-ratio = 0.4
+mode = "hard"
+`
+	cfg := NewConfig()
+	meta, err := toml.Decode(cfgData, &cfg)
+	c.Assert(err, IsNil)
+	c.Assert(cfg.Adjust(&meta, false), IsNil)
+	c.Assert(cfg.Schedule.LeaderConstraints, HasLen, 1)
+	c.Assert(cfg.Schedule.LeaderConstraints[0].IsHard(), IsTrue)
+	c.Assert(cfg.Schedule.Validate(), IsNil)
+
+	cfg.Schedule.LeaderConstraints[0].MaxRatio = 1.5
+	c.Assert(cfg.Schedule.Validate(), NotNil)
+	cfg.Schedule.LeaderConstraints[0].MaxRatio = 0.4
+	cfg.Schedule.LeaderConstraints[0].Mode = "strict"
+	c.Assert(cfg.Schedule.Validate(), NotNil)
+	cfg.Schedule.LeaderConstraints[0].Mode = ""
+	cfg.Schedule.LeaderConstraints[0].LabelValue = ""
+	c.Assert(cfg.Schedule.Validate(), NotNil)
+}
+
+func (s *testConfigSuite) TestAppendHotspotRules(c *C) {
+	cfgData := `
+[[schedule.append-hotspot-rules]]
+key-prefix = "7480000000000000ff2a5f72"
+`
+	cfg := NewConfig()
+	meta, err := toml.Decode(cfgData, &cfg)
+	c.Assert(err, IsNil)
+	c.Assert(cfg.Adjust(&meta, false), IsNil)
+	c.Assert(cfg.Schedule.AppendHotspotRules, HasLen, 1)
+	rule := cfg.Schedule.AppendHotspotRules[0]
+	c.Assert(rule.GetKeyPrefix(), DeepEquals, []byte{0x74, 0x80, 0, 0, 0, 0, 0, 0, 0xff, 0x2a, 0x5f, 0x72})
+	c.Assert(rule.GetPreSplitCount(), Equals, defaultAppendHotspotPreSplitCount)
+	c.Assert(cfg.Schedule.Validate(), IsNil)
+
+	cfg.Schedule.AppendHotspotRules[0].PreSplitCount = maxAppendHotspotPreSplitCount + 1
+	c.Assert(cfg.Schedule.Validate(), NotNil)
+	cfg.Schedule.AppendHotspotRules[0].PreSplitCount = 8
+	cfg.Schedule.AppendHotspotRules[0].KeyPrefix = "t1_"
+	c.Assert(cfg.Schedule.Validate(), NotNil)
+	cfg.Schedule.AppendHotspotRules[0].KeyPrefix = ""
+	c.Assert(cfg.Schedule.Validate(), NotNil)
+}
+
+func (s *testConfigSuite) TestKeyspaceWeights(c *C) {
+	cfgData := `
+[schedule]
+enable-keyspace-fair-queue = true
+[[schedule.keyspace-weights]]
+keyspace-id = 1
+weight = 2.0
+`
+	cfg := NewConfig()
+	meta, err := toml.Decode(cfgData, &cfg)
+	c.Assert(err, IsNil)
+	c.Assert(cfg.Adjust(&meta, false), IsNil)
+	c.Assert(cfg.Schedule.EnableKeyspaceFairQueue, IsTrue)
+	c.Assert(cfg.Schedule.KeyspaceWeights, DeepEquals, []KeyspaceWeight{{KeyspaceID: 1, Weight: 2}})
+	c.Assert(cfg.Schedule.Validate(), IsNil)
+
+	cfg.Schedule.KeyspaceWeights = append(cfg.Schedule.KeyspaceWeights, KeyspaceWeight{KeyspaceID: 1, Weight: 1})
+	c.Assert(cfg.Schedule.Validate(), NotNil)
+	cfg.Schedule.KeyspaceWeights[1].KeyspaceID = 2
+	c.Assert(cfg.Schedule.Validate(), IsNil)
+	cfg.Schedule.KeyspaceWeights[1].Weight = 0
+	c.Assert(cfg.Schedule.Validate(), NotNil)
+	cfg.Schedule.KeyspaceWeights[1] = KeyspaceWeight{KeyspaceID: maxKeyspaceID + 1, Weight: 1}
+	c.Assert(cfg.Schedule.Validate(), NotNil)
+}
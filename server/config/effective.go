@@ -0,0 +1,141 @@
+// Copyright 2021 TiKV Project Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package config
+
+import (
+	"encoding/json"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/tikv/pd/pkg/errs"
+)
+
+// The sources of the effective config values.
+const (
+	// SourceDefault means the value is the default one.
+	SourceDefault = "default"
+	// SourceFile means the value is set in the config file.
+	SourceFile = "file"
+	// SourcePersisted means the value is changed at runtime, e.g. by pd-ctl,
+	// and persisted.
+	SourcePersisted = "persisted"
+	// SourceTTL means the value is overridden temporarily until it expires.
+	SourceTTL = "ttl"
+)
+
+// EffectiveItem is an effective config value and where it comes from.
+type EffectiveItem struct {
+	Value  interface{} `json:"value"`
+	Source string      `json:"source"`
+	// Expire is the time the TTL override expires at.
+	Expire *time.Time `json:"expire,omitempty"`
+}
+
+// ExplainEffective returns the effective schedule, replication and PD server
+// configs keyed by the names such as `schedule.max-snapshot-count`, along with
+// their sources. The startup config is the one built from the config file and
+// the defaults when the server starts.
+func (o *PersistOptions) ExplainEffective(startup *Config) (map[string]*EffectiveItem, error) {
+	effective, err := flattenConfigSections(o.GetScheduleConfig(), o.GetReplicationConfig(), o.GetPDServerConfig())
+	if err != nil {
+		return nil, err
+	}
+	initial, err := flattenConfigSections(&startup.Schedule, &startup.Replication, &startup.PDServerCfg)
+	if err != nil {
+		return nil, err
+	}
+	items := make(map[string]*EffectiveItem, len(effective))
+	for key, value := range effective {
+		item := &EffectiveItem{Value: value, Source: SourceDefault}
+		if init, ok := initial[key]; !ok || !reflect.DeepEqual(init, value) {
+			item.Source = SourcePersisted
+		} else if startup.IsDefinedInFile(key) {
+			item.Source = SourceFile
+		}
+		items[key] = item
+	}
+	o.explainTTL(items)
+	return items, nil
+}
+
+// explainTTL puts the TTL overrides into the items. The overrides of the
+// store limits, such as `add-peer-1`, are put as the store limit items, such
+// as `schedule.store-limit.1.add-peer`, while the ones of the default store
+// limits, such as `default-add-peer`, are put as they are, which only take
+// effect on the stores without their own limits.
+func (o *PersistOptions) explainTTL(items map[string]*EffectiveItem) {
+	if o.ttl == nil {
+		return
+	}
+	keys := o.ttl.GetAllID()
+	sort.Strings(keys)
+	for _, key := range keys {
+		v, expire, ok := o.ttl.GetWithExpire(key)
+		if !ok {
+			continue
+		}
+		name := key
+		for _, typ := range []string{"add-peer", "remove-peer"} {
+			if id := strings.TrimPrefix(key, typ+"-"); id != key {
+				name = "schedule.store-limit." + id + "." + typ
+			}
+		}
+		items[name] = &EffectiveItem{Value: parseTTLValue(v.(string)), Source: SourceTTL, Expire: &expire}
+	}
+}
+
+func parseTTLValue(v string) interface{} {
+	if f, err := strconv.ParseFloat(v, 64); err == nil {
+		return f
+	}
+	if b, err := strconv.ParseBool(v); err == nil {
+		return b
+	}
+	return v
+}
+
+func flattenConfigSections(schedule *ScheduleConfig, replication *ReplicationConfig, pdServer *PDServerConfig) (map[string]interface{}, error) {
+	items := make(map[string]interface{})
+	for prefix, section := range map[string]interface{}{
+		"schedule":    schedule,
+		"replication": replication,
+		"pd-server":   pdServer,
+	} {
+		data, err := json.Marshal(section)
+		if err != nil {
+			return nil, errs.ErrJSONMarshal.Wrap(err).FastGenWithCause()
+		}
+		var m map[string]interface{}
+		if err := json.Unmarshal(data, &m); err != nil {
+			return nil, errs.ErrJSONUnmarshal.Wrap(err).FastGenWithCause()
+		}
+		flattenConfig(prefix, m, items)
+	}
+	return items, nil
+}
+
+// flattenConfig flattens the nested objects. The arrays are kept as a whole.
+func flattenConfig(prefix string, m map[string]interface{}, items map[string]interface{}) {
+	for k, v := range m {
+		key := prefix + "." + k
+		if child, ok := v.(map[string]interface{}); ok && len(child) > 0 {
+			flattenConfig(key, child, items)
+			continue
+		}
+		items[key] = v
+	}
+}
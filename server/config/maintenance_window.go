@@ -0,0 +1,234 @@
+// Copyright 2021 TiKV Project Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package config
+
+import (
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/pingcap/errors"
+	"github.com/pingcap/log"
+	"github.com/tikv/pd/pkg/typeutil"
+	"go.uber.org/zap"
+)
+
+// maxMaintenanceWindowDuration is the max duration of a maintenance window.
+const maxMaintenanceWindowDuration = 7 * 24 * time.Hour
+
+// MaintenanceWindow is a recurring window for the bulk housekeeping, in which
+// the schedule limits are raised. Outside of the windows, the limits of the
+// schedule config keep the movement minimal.
+type MaintenanceWindow struct {
+	// Schedule is when the window starts in the cron format of
+	// "minute hour day-of-month month day-of-week", in the local time of PD.
+	// For example, "0 1 * * 1-5" starts at 01:00 on weekdays.
+	Schedule string            `toml:"schedule" json:"schedule"`
+	Duration typeutil.Duration `toml:"duration" json:"duration"`
+	// The limits in the window, 0 means the limit is not changed.
+	LeaderScheduleLimit uint64 `toml:"leader-schedule-limit" json:"leader-schedule-limit,omitempty"`
+	RegionScheduleLimit uint64 `toml:"region-schedule-limit" json:"region-schedule-limit,omitempty"`
+	MergeScheduleLimit  uint64 `toml:"merge-schedule-limit" json:"merge-schedule-limit,omitempty"`
+}
+
+// Validate checks the schedule and the duration of the window.
+func (w *MaintenanceWindow) Validate() error {
+	if _, err := parseCronSchedule(w.Schedule); err != nil {
+		return err
+	}
+	if w.Duration.Duration < time.Minute || w.Duration.Duration > maxMaintenanceWindowDuration {
+		return errors.Errorf("the duration of maintenance window %q should be in [1m, %s]", w.Schedule, maxMaintenanceWindowDuration)
+	}
+	return nil
+}
+
+// StartBefore returns the start of the window which covers the time, or false
+// if the time is not in the window.
+func (w *MaintenanceWindow) StartBefore(t time.Time) (time.Time, bool) {
+	schedule, err := parseCronSchedule(w.Schedule)
+	if err != nil {
+		return time.Time{}, false
+	}
+	t = t.Local().Truncate(time.Minute)
+	for d := time.Duration(0); d < w.Duration.Duration; d += time.Minute {
+		if start := t.Add(-d); schedule.match(start) {
+			return start, true
+		}
+	}
+	return time.Time{}, false
+}
+
+// MaintenanceWindowStatus is the status of the maintenance windows.
+type MaintenanceWindowStatus struct {
+	Active bool `json:"active"`
+	// Window is the active window, which is the first one if the windows
+	// overlap.
+	Window *MaintenanceWindow `json:"window,omitempty"`
+	Start  time.Time          `json:"start,omitempty"`
+	End    time.Time          `json:"end,omitempty"`
+}
+
+// activeMaintenanceWindow is the active maintenance window of a minute.
+type activeMaintenanceWindow struct {
+	cfg    *ScheduleConfig
+	minute int64
+	status *MaintenanceWindowStatus
+}
+
+// GetMaintenanceWindowStatus returns the status of the maintenance windows at
+// present. The status is cached for a minute unless the config changes.
+func (o *PersistOptions) GetMaintenanceWindowStatus() *MaintenanceWindowStatus {
+	cfg := o.GetScheduleConfig()
+	now := time.Now()
+	minute := now.Unix() / 60
+	last, _ := o.maintenanceWindow.Load().(*activeMaintenanceWindow)
+	if last != nil && last.cfg == cfg && last.minute == minute {
+		return last.status
+	}
+	status := &MaintenanceWindowStatus{}
+	for i := range cfg.MaintenanceWindows {
+		w := &cfg.MaintenanceWindows[i]
+		if start, ok := w.StartBefore(now); ok {
+			status.Active = true
+			status.Window = w
+			status.Start = start
+			status.End = start.Add(w.Duration.Duration)
+			break
+		}
+	}
+	o.maintenanceWindow.Store(&activeMaintenanceWindow{cfg: cfg, minute: minute, status: status})
+	if last != nil && (last.status.Active != status.Active || !last.status.Start.Equal(status.Start)) {
+		if status.Active {
+			log.Info("maintenance window starts", zap.String("schedule", status.Window.Schedule), zap.Time("end", status.End))
+		} else {
+			log.Info("maintenance window ends")
+		}
+	}
+	return status
+}
+
+// GetActiveMaintenanceWindow returns the active maintenance window, or nil if
+// there is none.
+func (o *PersistOptions) GetActiveMaintenanceWindow() *MaintenanceWindow {
+	return o.GetMaintenanceWindowStatus().Window
+}
+
+// cronField is the set of the values a field of a cron schedule matches.
+type cronField uint64
+
+func (f cronField) has(v int) bool {
+	return f&(1<<uint(v)) != 0
+}
+
+// cronSchedule is a parsed cron schedule.
+type cronSchedule struct {
+	minute, hour, dayOfMonth, month, dayOfWeek cronField
+	// The day matches either of the fields if both are restricted, which is
+	// the same as the cron.
+	anyDayOfMonth, anyDayOfWeek bool
+}
+
+var cronFieldRanges = [5]struct {
+	name     string
+	min, max int
+}{
+	{"minute", 0, 59},
+	{"hour", 0, 23},
+	{"day-of-month", 1, 31},
+	{"month", 1, 12},
+	{"day-of-week", 0, 7},
+}
+
+func parseCronSchedule(s string) (*cronSchedule, error) {
+	parts := strings.Fields(s)
+	if len(parts) != len(cronFieldRanges) {
+		return nil, errors.Errorf("maintenance window %q should be in the cron format of \"minute hour day-of-month month day-of-week\"", s)
+	}
+	var fields [5]cronField
+	for i, part := range parts {
+		r := cronFieldRanges[i]
+		f, err := parseCronField(part, r.min, r.max)
+		if err != nil {
+			return nil, errors.Errorf("invalid %s of maintenance window %q: %s", r.name, s, err)
+		}
+		fields[i] = f
+	}
+	// Both 0 and 7 are Sunday.
+	if fields[4].has(7) {
+		fields[4] |= 1
+	}
+	return &cronSchedule{
+		minute:        fields[0],
+		hour:          fields[1],
+		dayOfMonth:    fields[2],
+		month:         fields[3],
+		dayOfWeek:     fields[4],
+		anyDayOfMonth: parts[2] == "*",
+		anyDayOfWeek:  parts[4] == "*",
+	}, nil
+}
+
+// parseCronField parses a comma-separated list of "*", "v", "a-b", with an
+// optional step like "*/5" or "a-b/2".
+func parseCronField(s string, min, max int) (cronField, error) {
+	var f cronField
+	for _, item := range strings.Split(s, ",") {
+		step := 1
+		if i := strings.Index(item, "/"); i >= 0 {
+			v, err := strconv.Atoi(item[i+1:])
+			if err != nil || v <= 0 {
+				return 0, errors.Errorf("invalid step %q", item[i+1:])
+			}
+			step, item = v, item[:i]
+		}
+		lo, hi := min, max
+		if item != "*" {
+			bounds := strings.SplitN(item, "-", 2)
+			var err error
+			if lo, err = strconv.Atoi(bounds[0]); err != nil {
+				return 0, errors.Errorf("invalid value %q", bounds[0])
+			}
+			hi = lo
+			if len(bounds) == 2 {
+				if hi, err = strconv.Atoi(bounds[1]); err != nil {
+					return 0, errors.Errorf("invalid value %q", bounds[1])
+				}
+			}
+		}
+		if lo < min || hi > max || lo > hi {
+			return 0, errors.Errorf("%q should be in [%d, %d]", item, min, max)
+		}
+		for v := lo; v <= hi; v += step {
+			f |= 1 << uint(v)
+		}
+	}
+	return f, nil
+}
+
+func (s *cronSchedule) match(t time.Time) bool {
+	if !s.minute.has(t.Minute()) || !s.hour.has(t.Hour()) || !s.month.has(int(t.Month())) {
+		return false
+	}
+	dom, dow := s.dayOfMonth.has(t.Day()), s.dayOfWeek.has(int(t.Weekday()))
+	switch {
+	case s.anyDayOfMonth && s.anyDayOfWeek:
+		return true
+	case s.anyDayOfMonth:
+		return dow
+	case s.anyDayOfWeek:
+		return dom
+	default:
+		return dom || dow
+	}
+}
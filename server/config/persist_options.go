@@ -48,6 +48,8 @@ type PersistOptions struct {
 	replicationMode atomic.Value
 	labelProperty   atomic.Value
 	clusterVersion  unsafe.Pointer
+	// maintenanceWindow caches the active maintenance window of the minute.
+	maintenanceWindow atomic.Value
 }
 
 // NewPersistOptions creates a new PersistOptions instance.
@@ -143,6 +145,22 @@ func (o *PersistOptions) IsPlacementRulesEnabled() bool {
 	return o.GetReplicationConfig().EnablePlacementRules
 }
 
+// IsSystemRangeIsolationEnabled returns if the system ranges are isolated.
+func (o *PersistOptions) IsSystemRangeIsolationEnabled() bool {
+	return o.GetReplicationConfig().EnableSystemRangeIsolation
+}
+
+// GetSystemRanges returns the system ranges in the "start:end" form.
+func (o *PersistOptions) GetSystemRanges() []string {
+	return o.GetReplicationConfig().SystemRanges
+}
+
+// GetSystemRangeStoreLabels returns the labels to select the stores for the
+// system ranges.
+func (o *PersistOptions) GetSystemRangeStoreLabels() []string {
+	return o.GetReplicationConfig().SystemRangeStoreLabels
+}
+
 // SetPlacementRuleEnabled set PlacementRuleEnabled
 func (o *PersistOptions) SetPlacementRuleEnabled(enabled bool) {
 	v := o.GetReplicationConfig().Clone()
@@ -300,6 +318,115 @@ func (o *PersistOptions) GetPatrolRegionInterval() time.Duration {
 	return o.GetScheduleConfig().PatrolRegionInterval.Duration
 }
 
+// GetPatrolCheckerOperatorLimit returns the max number of the operators
+// created by each checker in a patrol round.
+func (o *PersistOptions) GetPatrolCheckerOperatorLimit() uint64 {
+	return o.GetScheduleConfig().PatrolCheckerOperatorLimit
+}
+
+// GetPatrolSchedulerOperatorLimit returns the max number of the operators
+// created by each scheduler in a patrol round.
+func (o *PersistOptions) GetPatrolSchedulerOperatorLimit() uint64 {
+	return o.GetScheduleConfig().PatrolSchedulerOperatorLimit
+}
+
+// GetPatrolInfluenceLimit returns the max size of the data moved by the
+// operators created in a patrol round.
+func (o *PersistOptions) GetPatrolInfluenceLimit() uint64 {
+	return o.GetScheduleConfig().PatrolInfluenceLimit
+}
+
+// GetStoreSuspectGracePeriod returns the grace period of a down store to be
+// suspected to come back soon.
+func (o *PersistOptions) GetStoreSuspectGracePeriod() time.Duration {
+	return o.GetScheduleConfig().StoreSuspectGracePeriod.Duration
+}
+
+// GetStoreWarmUpDuration returns the warm-up duration of a newly joined store.
+func (o *PersistOptions) GetStoreWarmUpDuration() time.Duration {
+	return o.GetScheduleConfig().StoreWarmUpDuration.Duration
+}
+
+// IsRegionCacheRepairEnabled returns if the overlapped regions found by the
+// region consistency check are removed from the cache.
+func (o *PersistOptions) IsRegionCacheRepairEnabled() bool {
+	return o.GetScheduleConfig().EnableRegionCacheRepair
+}
+
+// IsStoreFairQueueEnabled returns if the waiting operators are queued by the
+// stores with the weighted fairness.
+func (o *PersistOptions) IsStoreFairQueueEnabled() bool {
+	return o.GetScheduleConfig().EnableStoreFairQueue
+}
+
+// GetConformanceReportInterval returns the interval of generating the
+// conformance reports.
+func (o *PersistOptions) GetConformanceReportInterval() time.Duration {
+	return o.GetScheduleConfig().ConformanceReportInterval.Duration
+}
+
+// IsStoreAddressMigrationEnabled returns if the stores can change their
+// addresses between the host names and the IP literals.
+func (o *PersistOptions) IsStoreAddressMigrationEnabled() bool {
+	return o.GetScheduleConfig().EnableStoreAddressMigration
+}
+
+// GetCompactionPressureThreshold returns the pending compaction bytes above
+// which a store is under compaction pressure. 0 means the pressure is not
+// checked.
+func (o *PersistOptions) GetCompactionPressureThreshold() uint64 {
+	return uint64(o.GetScheduleConfig().CompactionPressureThreshold)
+}
+
+// GetCapacityForecastWarningDays returns the predicted days to full of a store
+// below which a warning is raised. 0 means no warning.
+func (o *PersistOptions) GetCapacityForecastWarningDays() float64 {
+	return float64(o.GetScheduleConfig().CapacityForecastWarningDays)
+}
+
+// GetCapacityForecastCriticalDays returns the predicted days to full of a store
+// below which the regions are moved away from it. 0 means no store is
+// critical.
+func (o *PersistOptions) GetCapacityForecastCriticalDays() float64 {
+	return float64(o.GetScheduleConfig().CapacityForecastCriticalDays)
+}
+
+// GetTroubleRegionThreshold returns how long a region is in trouble before it
+// is watched. 0 means no region is watched.
+func (o *PersistOptions) GetTroubleRegionThreshold() time.Duration {
+	return o.GetScheduleConfig().TroubleRegionThreshold.Duration
+}
+
+// IsStalePeerGCEnabled returns if the removed peers are published to the
+// stores to clean up.
+func (o *PersistOptions) IsStalePeerGCEnabled() bool {
+	return o.GetScheduleConfig().EnableStalePeerGC
+}
+
+// IsUpgradeGuardrailEnabled returns if the stores with the versions lower
+// than the cluster version are rejected.
+func (o *PersistOptions) IsUpgradeGuardrailEnabled() bool {
+	return o.GetScheduleConfig().EnableUpgradeGuardrail
+}
+
+// GetStalePeerAckTimeout returns how long a removed peer is not acknowledged
+// before it is overdue.
+func (o *PersistOptions) GetStalePeerAckTimeout() time.Duration {
+	return o.GetScheduleConfig().StalePeerAckTimeout.Duration
+}
+
+// GetExpectedStoreCount returns the number of the stores the cluster is
+// expected to have. 0 means it is not declared.
+func (o *PersistOptions) GetExpectedStoreCount() uint64 {
+	return o.GetScheduleConfig().ExpectedStoreCount
+}
+
+// GetHaltScheduling returns the mode to halt the scheduling. Empty means the
+// scheduling is not halted.
+func (o *PersistOptions) GetHaltScheduling() string {
+	return o.GetScheduleConfig().HaltScheduling
+}
+
 // GetMaxStoreDownTime returns the max down time of a store.
 func (o *PersistOptions) GetMaxStoreDownTime() time.Duration {
 	return o.GetScheduleConfig().MaxStoreDownTime.Duration
@@ -307,12 +434,26 @@ func (o *PersistOptions) GetMaxStoreDownTime() time.Duration {
 
 // GetLeaderScheduleLimit returns the limit for leader schedule.
 func (o *PersistOptions) GetLeaderScheduleLimit() uint64 {
-	return o.getTTLUintOr(leaderScheduleLimitKey, o.GetScheduleConfig().LeaderScheduleLimit)
+	limit := o.GetScheduleConfig().LeaderScheduleLimit
+	if w := o.GetActiveMaintenanceWindow(); w != nil && w.LeaderScheduleLimit > 0 {
+		limit = w.LeaderScheduleLimit
+	}
+	return o.getTTLUintOr(leaderScheduleLimitKey, limit)
 }
 
 // GetRegionScheduleLimit returns the limit for region schedule.
 func (o *PersistOptions) GetRegionScheduleLimit() uint64 {
-	return o.getTTLUintOr(regionScheduleLimitKey, o.GetScheduleConfig().RegionScheduleLimit)
+	limit := o.GetScheduleConfig().RegionScheduleLimit
+	if w := o.GetActiveMaintenanceWindow(); w != nil && w.RegionScheduleLimit > 0 {
+		limit = w.RegionScheduleLimit
+	}
+	return o.getTTLUintOr(regionScheduleLimitKey, limit)
+}
+
+// GetOrphanPeerRemovalRate returns the max count of the regions to remove the
+// orphan peers per minute.
+func (o *PersistOptions) GetOrphanPeerRemovalRate() uint64 {
+	return o.GetScheduleConfig().OrphanPeerRemovalRate
 }
 
 // GetReplicaScheduleLimit returns the limit for replica schedule.
@@ -322,7 +463,11 @@ func (o *PersistOptions) GetReplicaScheduleLimit() uint64 {
 
 // GetMergeScheduleLimit returns the limit for merge schedule.
 func (o *PersistOptions) GetMergeScheduleLimit() uint64 {
-	return o.getTTLUintOr(mergeScheduleLimitKey, o.GetScheduleConfig().MergeScheduleLimit)
+	limit := o.GetScheduleConfig().MergeScheduleLimit
+	if w := o.GetActiveMaintenanceWindow(); w != nil && w.MergeScheduleLimit > 0 {
+		limit = w.MergeScheduleLimit
+	}
+	return o.getTTLUintOr(mergeScheduleLimitKey, limit)
 }
 
 // GetHotRegionScheduleLimit returns the limit for hot region schedule.
@@ -405,6 +550,62 @@ func (o *PersistOptions) GetTolerantSizeRatio() float64 {
 	return o.GetScheduleConfig().TolerantSizeRatio
 }
 
+// GetZoneLabelKey returns the store label key of the zones.
+func (o *PersistOptions) GetZoneLabelKey() string {
+	return o.GetScheduleConfig().ZoneLabelKey
+}
+
+// GetZoneTransferCost returns the cost weight of moving data from a zone to
+// another.
+func (o *PersistOptions) GetZoneTransferCost(fromZone, toZone string) float64 {
+	return o.GetScheduleConfig().GetZoneTransferCost(fromZone, toZone)
+}
+
+// GetLeaderConstraints returns the leader constraints.
+func (o *PersistOptions) GetLeaderConstraints() []LeaderConstraint {
+	return o.GetScheduleConfig().LeaderConstraints
+}
+
+// GetAppendHotspotRules returns the append hotspot rules.
+func (o *PersistOptions) GetAppendHotspotRules() []AppendHotspotRule {
+	return o.GetScheduleConfig().AppendHotspotRules
+}
+
+// GetHotSplitThresholdBounds returns the bounds of the byte rate over which
+// a hot region is split by PD.
+func (o *PersistOptions) GetHotSplitThresholdBounds() (min, max float64) {
+	cfg := o.GetScheduleConfig()
+	return cfg.HotSplitMinThreshold, cfg.HotSplitMaxThreshold
+}
+
+// IsKeyspaceFairQueueEnabled returns if the waiting operators are queued by
+// the keyspaces of the regions.
+func (o *PersistOptions) IsKeyspaceFairQueueEnabled() bool {
+	return o.GetScheduleConfig().EnableKeyspaceFairQueue
+}
+
+// GetKeyspaceWeight returns the weight of the keyspace in the keyspace fair
+// queue.
+func (o *PersistOptions) GetKeyspaceWeight(keyspaceID uint32) float64 {
+	for _, w := range o.GetScheduleConfig().KeyspaceWeights {
+		if w.KeyspaceID == keyspaceID {
+			return w.Weight
+		}
+	}
+	return 1
+}
+
+// IsKeyspaceWeighted returns if the keyspace has a weight configured in the
+// keyspace fair queue.
+func (o *PersistOptions) IsKeyspaceWeighted(keyspaceID uint32) bool {
+	for _, w := range o.GetScheduleConfig().KeyspaceWeights {
+		if w.KeyspaceID == keyspaceID {
+			return true
+		}
+	}
+	return false
+}
+
 // GetLowSpaceRatio returns the low space ratio.
 func (o *PersistOptions) GetLowSpaceRatio() float64 {
 	return o.GetScheduleConfig().LowSpaceRatio
@@ -492,6 +693,21 @@ func (o *PersistOptions) IsUseJointConsensus() bool {
 	return o.GetScheduleConfig().EnableJointConsensus
 }
 
+// IsSplitLeaderTransferEnabled returns if transferring a split half's leader away is enabled.
+func (o *PersistOptions) IsSplitLeaderTransferEnabled() bool {
+	return o.GetScheduleConfig().EnableSplitLeaderTransfer
+}
+
+// IsTransferLeaderReadyCheckEnabled returns if checking the target peer is ready before transferring the leader is enabled.
+func (o *PersistOptions) IsTransferLeaderReadyCheckEnabled() bool {
+	return o.GetScheduleConfig().EnableTransferLeaderReadyCheck
+}
+
+// IsParallelMakeUpReplicaEnabled returns if adding the missing replicas in parallel is enabled.
+func (o *PersistOptions) IsParallelMakeUpReplicaEnabled() bool {
+	return o.GetScheduleConfig().EnableParallelMakeUpReplica
+}
+
 // GetHotRegionCacheHitsThreshold is a threshold to decide if a region is hot.
 func (o *PersistOptions) GetHotRegionCacheHitsThreshold() int {
 	return int(o.GetScheduleConfig().HotRegionCacheHitsThreshold)
@@ -514,11 +730,11 @@ func (o *PersistOptions) AddSchedulerCfg(tp string, args []string) {
 		// comparing args is to cover the case that there are schedulers in same type but not with same name
 		// such as two schedulers of type "evict-leader",
 		// one name is "evict-leader-scheduler-1" and the other is "evict-leader-scheduler-2"
-		if reflect.DeepEqual(schedulerCfg, SchedulerConfig{Type: tp, Args: args, Disable: false}) {
+		if reflect.DeepEqual(schedulerCfg, SchedulerConfig{Type: tp, Args: args, Disable: false, Window: schedulerCfg.Window, Shadow: schedulerCfg.Shadow}) {
 			return
 		}
 
-		if reflect.DeepEqual(schedulerCfg, SchedulerConfig{Type: tp, Args: args, Disable: true}) {
+		if reflect.DeepEqual(schedulerCfg, SchedulerConfig{Type: tp, Args: args, Disable: true, Window: schedulerCfg.Window, Shadow: schedulerCfg.Shadow}) {
 			schedulerCfg.Disable = false
 			v.Schedulers[i] = schedulerCfg
 			o.SetScheduleConfig(v)
@@ -14,8 +14,11 @@
 package config
 
 import (
+	"bytes"
+	"encoding/hex"
 	"net/url"
 	"regexp"
+	"strings"
 
 	"github.com/pingcap/errors"
 	"github.com/pingcap/kvproto/pkg/metapb"
@@ -51,6 +54,42 @@ func ValidateLabels(labels []*metapb.StoreLabel) error {
 	return nil
 }
 
+// ParseSystemRange parses the system range in the "start:end" form, whose
+// keys are hex encoded. The keys are returned in lower case. An empty end key
+// means the end of the key space.
+func ParseSystemRange(s string) (startKeyHex, endKeyHex string, err error) {
+	i := strings.Index(s, ":")
+	if i < 0 {
+		return "", "", errors.Errorf("system range %s should be in the \"start:end\" form", s)
+	}
+	startKeyHex, endKeyHex = s[:i], s[i+1:]
+	startKey, err := hex.DecodeString(startKeyHex)
+	if err != nil {
+		return "", "", errors.Errorf("start key of system range %s is not hex encoded", s)
+	}
+	endKey, err := hex.DecodeString(endKeyHex)
+	if err != nil {
+		return "", "", errors.Errorf("end key of system range %s is not hex encoded", s)
+	}
+	if len(endKey) > 0 && bytes.Compare(endKey, startKey) <= 0 {
+		return "", "", errors.Errorf("end key of system range %s should be greater than the start key", s)
+	}
+	return hex.EncodeToString(startKey), hex.EncodeToString(endKey), nil
+}
+
+// ParseStoreLabel parses the store label in the "key=value" form.
+func ParseStoreLabel(s string) (*metapb.StoreLabel, error) {
+	kv := strings.SplitN(s, "=", 2)
+	if len(kv) != 2 || kv[1] == "" {
+		return nil, errors.Errorf("store label %s should be in the \"key=value\" form", s)
+	}
+	label := &metapb.StoreLabel{Key: kv[0], Value: kv[1]}
+	if err := ValidateLabels([]*metapb.StoreLabel{label}); err != nil {
+		return nil, err
+	}
+	return label, nil
+}
+
 // ValidateURLWithScheme checks the format of the URL.
 func ValidateURLWithScheme(rawURL string) error {
 	u, err := url.ParseRequestURI(rawURL)
@@ -61,6 +61,22 @@ func (bc *BasicCluster) GetStore(storeID uint64) *StoreInfo {
 	return bc.Stores.GetStore(storeID)
 }
 
+// GetLabelVersion returns the version which is increased when the labels of
+// any store change, or a store is added or deleted.
+func (bc *BasicCluster) GetLabelVersion() uint64 {
+	bc.RLock()
+	defer bc.RUnlock()
+	return bc.Stores.GetLabelVersion()
+}
+
+// GetStoreLabelVersion returns the label version when the labels of the store
+// change. It returns 0 if the store does not exist.
+func (bc *BasicCluster) GetStoreLabelVersion(storeID uint64) uint64 {
+	bc.RLock()
+	defer bc.RUnlock()
+	return bc.Stores.GetStoreLabelVersion(storeID)
+}
+
 // GetRegion searches for a region by ID.
 func (bc *BasicCluster) GetRegion(regionID uint64) *RegionInfo {
 	bc.RLock()
@@ -212,6 +228,14 @@ func (bc *BasicCluster) GetRegionCount() int {
 	return bc.Regions.GetRegionCount()
 }
 
+// GetMemoryUsage returns the estimated memory used by the cached regions and
+// stores.
+func (bc *BasicCluster) GetMemoryUsage() (regions, stores int64) {
+	bc.RLock()
+	defer bc.RUnlock()
+	return bc.Regions.GetMemoryUsage(), bc.Stores.GetMemoryUsage()
+}
+
 // GetStoreCount returns the total count of storeInfo.
 func (bc *BasicCluster) GetStoreCount() int {
 	bc.RLock()
@@ -334,6 +358,25 @@ func (bc *BasicCluster) CheckAndPutRegion(region *RegionInfo) []*RegionInfo {
 	return bc.PutRegion(region)
 }
 
+// PutRegionIfAbsent puts the region loaded from the storage if it is not in
+// the cache and it is newer than the regions it overlaps, and returns the
+// overlapped regions and whether it is put. It is used to load the regions
+// while the heartbeats are handled, so the regions reported by the heartbeats
+// are never overwritten.
+func (bc *BasicCluster) PutRegionIfAbsent(region *RegionInfo) ([]*RegionInfo, bool) {
+	bc.Lock()
+	defer bc.Unlock()
+	if bc.Regions.GetRegion(region.GetID()) != nil {
+		return nil, false
+	}
+	for _, item := range bc.Regions.GetOverlaps(region) {
+		if region.GetRegionEpoch().GetVersion() <= item.GetRegionEpoch().GetVersion() {
+			return nil, false
+		}
+	}
+	return bc.Regions.SetRegion(region), true
+}
+
 // RemoveRegion removes RegionInfo from regionTree and regionMap.
 func (bc *BasicCluster) RemoveRegion(region *RegionInfo) {
 	bc.Lock()
@@ -355,6 +398,13 @@ func (bc *BasicCluster) SearchPrevRegion(regionKey []byte) *RegionInfo {
 	return bc.Regions.SearchPrevRegion(regionKey)
 }
 
+// SearchRegions searches the regions that contain the sorted keys.
+func (bc *BasicCluster) SearchRegions(keys [][]byte) []*RegionInfo {
+	bc.RLock()
+	defer bc.RUnlock()
+	return bc.Regions.SearchRegions(keys)
+}
+
 // ScanRange scans regions intersecting [start key, end key), returns at most
 // `limit` regions. limit <= 0 means no limit.
 func (bc *BasicCluster) ScanRange(startKey, endKey []byte, limit int) []*RegionInfo {
@@ -370,6 +420,13 @@ func (bc *BasicCluster) GetOverlaps(region *RegionInfo) []*RegionInfo {
 	return bc.Regions.GetOverlaps(region)
 }
 
+// CheckRegionConsistency checks the overlaps and the gaps of the regions.
+func (bc *BasicCluster) CheckRegionConsistency() []*RegionInconsistency {
+	bc.RLock()
+	defer bc.RUnlock()
+	return bc.Regions.CheckConsistency()
+}
+
 // RegionSetInformer provides access to a shared informer of regions.
 type RegionSetInformer interface {
 	GetRegionCount() int
@@ -21,8 +21,26 @@ const (
 	LowPriority PriorityLevel = iota
 	NormalPriority
 	HighPriority
+	// UrgentPriority is used by the repairs of the regions which are labeled
+	// with a checker priority class.
+	UrgentPriority
 )
 
+func (p PriorityLevel) String() string {
+	switch p {
+	case LowPriority:
+		return "low"
+	case NormalPriority:
+		return "normal"
+	case HighPriority:
+		return "high"
+	case UrgentPriority:
+		return "urgent"
+	default:
+		return "unknown"
+	}
+}
+
 // ScheduleKind distinguishes resources and schedule policy.
 type ScheduleKind struct {
 	Resource ResourceKind
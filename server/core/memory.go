@@ -0,0 +1,68 @@
+// Copyright 2021 TiKV Project Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package core
+
+import (
+	"unsafe"
+
+	"github.com/pingcap/kvproto/pkg/metapb"
+	"github.com/pingcap/kvproto/pkg/pdpb"
+)
+
+// The sizes of the objects to estimate the memory used by the cache. They are
+// the estimations to find which part of the cache grows, rather than the
+// exact memory usage.
+var (
+	pointerSize     = int64(unsafe.Sizeof(uintptr(0)))
+	regionInfoSize  = int64(unsafe.Sizeof(RegionInfo{}))
+	regionMetaSize  = int64(unsafe.Sizeof(metapb.Region{}) + unsafe.Sizeof(metapb.RegionEpoch{}))
+	peerSize        = int64(unsafe.Sizeof(metapb.Peer{}))
+	peerStatsSize   = int64(unsafe.Sizeof(pdpb.PeerStats{}))
+	queryStatsSize  = int64(unsafe.Sizeof(pdpb.QueryStats{}))
+	storeInfoSize   = int64(unsafe.Sizeof(StoreInfo{}) + unsafe.Sizeof(storeStats{}))
+	storeStatsSize  = int64(unsafe.Sizeof(pdpb.StoreStats{}))
+	recordPairSize  = int64(unsafe.Sizeof(pdpb.RecordPair{}))
+	regionIndexSize = int64(64) // an entry of the map or the trees of the regions
+)
+
+// estimateRegionMemory returns the estimated memory used by the region and
+// its entries in the indexes.
+func estimateRegionMemory(region *RegionInfo) int64 {
+	if region == nil {
+		return 0
+	}
+	peers := int64(len(region.meta.GetPeers()))
+	pendingPeers := int64(len(region.pendingPeers))
+	size := regionInfoSize + regionMetaSize + int64(len(region.GetStartKey())+len(region.GetEndKey()))
+	// Each peer is referenced by the meta and the voters or the learners.
+	size += peers * (peerSize + 2*pointerSize)
+	size += int64(len(region.downPeers))*(peerStatsSize+pointerSize) + pendingPeers*pointerSize
+	if region.QueryStats != nil {
+		size += queryStatsSize
+	}
+	// The region is indexed by the map, the tree and the sub trees of the
+	// stores of its peers.
+	size += (2 + peers + pendingPeers) * regionIndexSize
+	return size
+}
+
+// estimateStoreMemory returns the estimated memory used by the store.
+func estimateStoreMemory(store *StoreInfo) int64 {
+	size := storeInfoSize + int64(store.GetMeta().Size())
+	if stats := store.GetStoreStats(); stats != nil {
+		records := len(stats.GetCpuUsages()) + len(stats.GetReadIoRates()) + len(stats.GetWriteIoRates()) + len(stats.GetOpLatencies())
+		size += storeStatsSize + int64(records)*(recordPairSize+pointerSize)
+	}
+	return size
+}
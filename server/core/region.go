@@ -496,6 +496,8 @@ type RegionsInfo struct {
 	followers    map[uint64]*regionTree // storeID -> sub regionTree
 	learners     map[uint64]*regionTree // storeID -> sub regionTree
 	pendingPeers map[uint64]*regionTree // storeID -> sub regionTree
+	// memoryUsage is the estimated memory used by the regions.
+	memoryUsage int64
 }
 
 // NewRegionsInfo creates RegionsInfo with tree, regions, leaders and followers
@@ -526,9 +528,11 @@ func (r *RegionsInfo) SetRegion(region *RegionInfo) (overlaps []*RegionInfo) {
 	var rangeChanged bool  // This Region is new, or its range has changed.
 	var peersChanged bool  // This Region is new, or its peers have changed, including leader-change/pending/down.
 
+	r.memoryUsage += estimateRegionMemory(region)
 	if item = r.regions.Get(region.GetID()); item != nil {
 		// If this ID already exists, use the existing regionItem and pick out the origin.
 		origin = item.region
+		r.memoryUsage -= estimateRegionMemory(origin)
 		rangeChanged = !bytes.Equal(origin.GetStartKey(), region.GetStartKey()) ||
 			!bytes.Equal(origin.GetEndKey(), region.GetEndKey())
 		if rangeChanged {
@@ -617,6 +621,11 @@ func (r *RegionsInfo) SetRegion(region *RegionInfo) (overlaps []*RegionInfo) {
 	return
 }
 
+// GetMemoryUsage returns the estimated memory used by the regions.
+func (r *RegionsInfo) GetMemoryUsage() int64 {
+	return r.memoryUsage
+}
+
 // Len returns the RegionsInfo length
 func (r *RegionsInfo) Len() int {
 	return r.regions.Len()
@@ -659,6 +668,9 @@ func (r *RegionsInfo) GetOverlaps(region *RegionInfo) []*RegionInfo {
 
 // RemoveRegion removes RegionInfo from regionTree and regionMap
 func (r *RegionsInfo) RemoveRegion(region *RegionInfo) {
+	if item := r.regions.Get(region.GetID()); item != nil {
+		r.memoryUsage -= estimateRegionMemory(item.region)
+	}
 	// Remove from tree and regions.
 	r.tree.remove(region)
 	r.regions.Delete(region.GetID())
@@ -964,6 +976,16 @@ func (r *RegionsInfo) ScanRange(startKey, endKey []byte, limit int) []*RegionInf
 	return res
 }
 
+// SearchRegions searches the regions that contain the sorted keys. Each region
+// is returned once, in the order of the keys.
+func (r *RegionsInfo) SearchRegions(keys [][]byte) []*RegionInfo {
+	regions := r.tree.searchKeys(keys)
+	for i, region := range regions {
+		regions[i] = r.GetRegion(region.GetID())
+	}
+	return regions
+}
+
 // ScanRangeWithIterator scans from the first region containing or behind start key,
 // until iterator returns false.
 func (r *RegionsInfo) ScanRangeWithIterator(startKey []byte, iterator func(region *RegionInfo) bool) {
@@ -0,0 +1,110 @@
+// Copyright 2021 TiKV Project Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package core
+
+import (
+	"bytes"
+	"sort"
+)
+
+// RegionInconsistencyType is the type of the inconsistency of the region
+// metadata.
+type RegionInconsistencyType string
+
+const (
+	// RegionOverlap means that the key range is covered by more than one
+	// region, or a cached region is missing in the region tree.
+	RegionOverlap RegionInconsistencyType = "overlap"
+	// RegionGap means that the key range is not covered by any region.
+	RegionGap RegionInconsistencyType = "gap"
+)
+
+// RegionInconsistency is an inconsistency found in the region metadata.
+type RegionInconsistency struct {
+	Type RegionInconsistencyType `json:"type"`
+	// StartKey and EndKey are the hex encoded key range of the inconsistency.
+	StartKey string `json:"start_key"`
+	EndKey   string `json:"end_key"`
+	// Regions are the IDs of the overlapped regions, or the regions around
+	// the gap.
+	Regions []uint64 `json:"regions"`
+}
+
+func newRegionInconsistency(typ RegionInconsistencyType, start, end []byte, regions ...uint64) *RegionInconsistency {
+	return &RegionInconsistency{
+		Type:     typ,
+		StartKey: HexRegionKeyStr(start),
+		EndKey:   HexRegionKeyStr(end),
+		Regions:  regions,
+	}
+}
+
+// CheckConsistency checks whether the regions in the tree cover the whole key
+// space without overlaps, and whether all cached regions are in the tree. The
+// gaps are expected before all regions are reported, such as after the PD
+// leader changes.
+func (r *RegionsInfo) CheckConsistency() []*RegionInconsistency {
+	var (
+		res  []*RegionInconsistency
+		prev *RegionInfo
+	)
+	r.tree.scanRange(nil, func(region *RegionInfo) bool {
+		start, end := region.GetStartKey(), region.GetEndKey()
+		if prev == nil {
+			if len(start) > 0 {
+				res = append(res, newRegionInconsistency(RegionGap, nil, start, region.GetID()))
+			}
+			prev = region
+			return true
+		}
+		prevEnd := prev.GetEndKey()
+		switch c := bytes.Compare(prevEnd, start); {
+		case len(prevEnd) == 0 || c > 0:
+			overlapEnd := prevEnd
+			if len(end) > 0 && (len(prevEnd) == 0 || bytes.Compare(end, prevEnd) < 0) {
+				overlapEnd = end
+			}
+			res = append(res, newRegionInconsistency(RegionOverlap, start, overlapEnd, prev.GetID(), region.GetID()))
+			// Keep the region which covers more keys to check the following ones.
+			if len(prevEnd) > 0 && (len(end) == 0 || bytes.Compare(end, prevEnd) > 0) {
+				prev = region
+			}
+			return true
+		case c < 0:
+			res = append(res, newRegionInconsistency(RegionGap, prevEnd, start, prev.GetID(), region.GetID()))
+		}
+		prev = region
+		return true
+	})
+	if prev != nil && len(prev.GetEndKey()) > 0 {
+		res = append(res, newRegionInconsistency(RegionGap, prev.GetEndKey(), nil, prev.GetID()))
+	}
+
+	// The cached regions which are not in the tree are overlapped by others.
+	var missing []*RegionInconsistency
+	for id, item := range r.regions {
+		region := item.region
+		found := r.tree.search(region.GetStartKey())
+		if found != nil && found.GetID() == id {
+			continue
+		}
+		regions := []uint64{id}
+		if found != nil {
+			regions = append(regions, found.GetID())
+		}
+		missing = append(missing, newRegionInconsistency(RegionOverlap, region.GetStartKey(), region.GetEndKey(), regions...))
+	}
+	sort.Slice(missing, func(i, j int) bool { return missing[i].Regions[0] < missing[j].Regions[0] })
+	return append(res, missing...)
+}
@@ -16,6 +16,7 @@ package core
 import (
 	"context"
 	"math"
+	"sort"
 	"sync"
 	"time"
 
@@ -130,7 +131,34 @@ func deleteRegion(kv kv.Base, region *metapb.Region) error {
 	return kv.Remove(regionPath(region.GetId()))
 }
 
+func deleteRegions(base kv.Base, regions []*metapb.Region) error {
+	ids := make([]uint64, 0, len(regions))
+	for _, region := range regions {
+		ids = append(ids, region.GetId())
+	}
+	sort.Slice(ids, func(i, j int) bool { return ids[i] < ids[j] })
+	remover, canRemoveRange := base.(kv.RangeRemover)
+	for i := 0; i < len(ids); {
+		j := i + 1
+		for canRemoveRange && j < len(ids) && ids[j] <= ids[j-1]+1 && ids[j] < math.MaxUint64 {
+			j++
+		}
+		var err error
+		if j-i > 1 {
+			err = remover.RemoveRange(regionPath(ids[i]), regionPath(ids[j-1]+1))
+		} else {
+			err = base.Remove(regionPath(ids[i]))
+		}
+		if err != nil {
+			return err
+		}
+		i = j
+	}
+	return nil
+}
+
 func loadRegions(
+	ctx context.Context,
 	kv kv.Base,
 	encryptionKeyManager *encryptionkm.KeyManager,
 	f func(region *RegionInfo) []*RegionInfo,
@@ -143,6 +171,9 @@ func loadRegions(
 	// a variable rangeLimit to work around.
 	rangeLimit := maxKVRangeLimit
 	for {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
 		startKey := regionPath(nextID)
 		_, res, err := kv.LoadRange(startKey, endKey, rangeLimit)
 		if err != nil {
@@ -179,6 +179,31 @@ func (s *testRegionInfoSuite) TestRegionRoundingFlow(c *C) {
 	}
 }
 
+func (s *testRegionInfoSuite) TestRegionsMemoryUsage(c *C) {
+	regions := NewRegionsInfo()
+	newRegion := func(id uint64, start, end string) *RegionInfo {
+		peers := []*metapb.Peer{{Id: id*10 + 1, StoreId: 1}, {Id: id*10 + 2, StoreId: 2}}
+		return NewRegionInfo(&metapb.Region{Id: id, StartKey: []byte(start), EndKey: []byte(end), Peers: peers}, peers[0])
+	}
+	c.Assert(regions.GetMemoryUsage(), Equals, int64(0))
+	regions.SetRegion(newRegion(1, "a", "c"))
+	usage := regions.GetMemoryUsage()
+	c.Assert(usage, Greater, int64(0))
+	// Updating a region replaces its usage.
+	regions.SetRegion(newRegion(1, "a", "c").Clone(SetApproximateSize(10)))
+	c.Assert(regions.GetMemoryUsage(), Equals, usage)
+	regions.SetRegion(newRegion(1, "a", "c").Clone(WithPendingPeers([]*metapb.Peer{{Id: 12, StoreId: 2}})))
+	c.Assert(regions.GetMemoryUsage() > usage, IsTrue)
+	// The overlapped regions are removed.
+	regions.SetRegion(newRegion(2, "a", "b"))
+	regions.SetRegion(newRegion(3, "b", "c"))
+	c.Assert(regions.GetRegion(1), IsNil)
+	c.Assert(regions.GetMemoryUsage(), Equals, 2*usage)
+	regions.RemoveRegion(regions.GetRegion(2))
+	regions.RemoveRegion(regions.GetRegion(3))
+	c.Assert(regions.GetMemoryUsage(), Equals, int64(0))
+}
+
 var _ = Suite(&testRegionMapSuite{})
 
 type testRegionMapSuite struct{}
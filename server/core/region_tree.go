@@ -197,6 +197,32 @@ func (t *regionTree) scanRange(startKey []byte, f func(*RegionInfo) bool) {
 	})
 }
 
+// searchKeys returns the regions that contain the sorted keys with a single
+// scan. Each region is returned once, and the keys that are not covered by any
+// region are skipped.
+func (t *regionTree) searchKeys(keys [][]byte) []*RegionInfo {
+	if len(keys) == 0 {
+		return nil
+	}
+	var res []*RegionInfo
+	i := 0
+	t.scanRange(keys[0], func(region *RegionInfo) bool {
+		for i < len(keys) && bytes.Compare(keys[i], region.GetStartKey()) < 0 {
+			i++
+		}
+		found := false
+		for i < len(keys) && (len(region.GetEndKey()) == 0 || bytes.Compare(keys[i], region.GetEndKey()) < 0) {
+			found = true
+			i++
+		}
+		if found {
+			res = append(res, region)
+		}
+		return i < len(keys)
+	})
+	return res
+}
+
 func (t *regionTree) scanRanges() []*RegionInfo {
 	if t.length() == 0 {
 		return nil
@@ -223,6 +223,23 @@ func (s *testRegionSuite) TestRegionTree(c *C) {
 	c.Assert(tree.search([]byte("e")), Equals, regionE)
 }
 
+func (s *testRegionSuite) TestRegionTreeSearchKeys(c *C) {
+	tree := newRegionTree()
+	c.Assert(tree.searchKeys([][]byte{[]byte("a")}), HasLen, 0)
+
+	regionA := NewTestRegionInfo([]byte("a"), []byte("b"))
+	regionB := NewTestRegionInfo([]byte("b"), []byte("c"))
+	regionD := NewTestRegionInfo([]byte("d"), []byte{})
+	updateNewItem(tree, regionA)
+	updateNewItem(tree, regionB)
+	updateNewItem(tree, regionD)
+
+	keys := [][]byte{[]byte(""), []byte("a"), []byte("a1"), []byte("b"), []byte("c"), []byte("c1"), []byte("z")}
+	c.Assert(tree.searchKeys(keys), DeepEquals, []*RegionInfo{regionA, regionB, regionD})
+	c.Assert(tree.searchKeys([][]byte{[]byte("c")}), HasLen, 0)
+	c.Assert(tree.searchKeys([][]byte{[]byte("b1"), []byte("e")}), DeepEquals, []*RegionInfo{regionB, regionD})
+}
+
 func updateRegions(c *C, tree *regionTree, regions []*RegionInfo) {
 	for _, region := range regions {
 		updateNewItem(tree, region)
@@ -409,3 +426,39 @@ func BenchmarkRegionTreeUpdateUnordered(b *testing.B) {
 		updateNewItem(tree, items[i])
 	}
 }
+
+func (s *testRegionSuite) TestCheckConsistency(c *C) {
+	newRegion := func(id uint64, start, end string) *RegionInfo {
+		return NewRegionInfo(&metapb.Region{Id: id, StartKey: []byte(start), EndKey: []byte(end)}, nil)
+	}
+	regions := NewRegionsInfo()
+	c.Assert(regions.CheckConsistency(), HasLen, 0)
+	regions.SetRegion(newRegion(1, "", "b"))
+	regions.SetRegion(newRegion(2, "b", "d"))
+	regions.SetRegion(newRegion(3, "d", ""))
+	c.Assert(regions.CheckConsistency(), HasLen, 0)
+
+	regions.RemoveRegion(regions.GetRegion(2))
+	c.Assert(regions.CheckConsistency(), DeepEquals, []*RegionInconsistency{
+		{Type: RegionGap, StartKey: "62", EndKey: "64", Regions: []uint64{1, 3}},
+	})
+
+	// Put the overlapped region into the tree directly, and put a region into
+	// the cache only.
+	overlapped := newRegion(4, "a", "c")
+	regions.tree.tree.ReplaceOrInsert(&regionItem{region: overlapped})
+	regions.regions.AddNew(overlapped)
+	regions.regions.AddNew(newRegion(5, "x", "y"))
+	c.Assert(regions.CheckConsistency(), DeepEquals, []*RegionInconsistency{
+		{Type: RegionOverlap, StartKey: "61", EndKey: "62", Regions: []uint64{1, 4}},
+		{Type: RegionGap, StartKey: "63", EndKey: "64", Regions: []uint64{4, 3}},
+		{Type: RegionOverlap, StartKey: "78", EndKey: "79", Regions: []uint64{5, 3}},
+	})
+
+	regions = NewRegionsInfo()
+	regions.SetRegion(newRegion(1, "b", "d"))
+	c.Assert(regions.CheckConsistency(), DeepEquals, []*RegionInconsistency{
+		{Type: RegionGap, StartKey: "", EndKey: "62", Regions: []uint64{1}},
+		{Type: RegionGap, StartKey: "64", EndKey: "", Regions: []uint64{1}},
+	})
+}
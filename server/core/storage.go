@@ -14,6 +14,7 @@
 package core
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
 	"math"
@@ -27,9 +28,11 @@ import (
 	"github.com/gogo/protobuf/proto"
 	"github.com/pingcap/errors"
 	"github.com/pingcap/kvproto/pkg/metapb"
+	"github.com/pingcap/kvproto/pkg/pdpb"
 	"github.com/pingcap/log"
 	"github.com/tikv/pd/pkg/encryption"
 	"github.com/tikv/pd/pkg/errs"
+	"github.com/tikv/pd/pkg/typeutil"
 	"github.com/tikv/pd/server/encryptionkm"
 	"github.com/tikv/pd/server/kv"
 	"go.etcd.io/etcd/clientv3"
@@ -47,6 +50,15 @@ const (
 	customScheduleConfigPath   = "scheduler_config"
 	encryptionKeysPath         = "encryption_keys"
 	gcWorkerServiceSafePointID = "gc_worker"
+	// allocIDPath is where the ID allocator persists the end of its window.
+	allocIDPath = "alloc_id"
+	// regionStorageClusterIDPath is where the region storage keeps the ID of
+	// the cluster which the regions belong to.
+	regionStorageClusterIDPath = "cluster_id"
+	// safeModePath is where the state of the safe mode is kept.
+	safeModePath = "safe_mode"
+	// rbacPath is where the role bindings of RBAC are kept.
+	rbacPath = "rbac"
 )
 
 const (
@@ -136,6 +148,34 @@ func (s *Storage) storeRegionWeightPath(storeID uint64) string {
 	return path.Join(schedulePath, "store_weight", fmt.Sprintf("%020d", storeID), "region")
 }
 
+func (s *Storage) storeReadOnlyPath(storeID uint64) string {
+	return path.Join(schedulePath, "store_read_only", fmt.Sprintf("%020d", storeID))
+}
+
+func (s *Storage) storeResourceTagsPath(storeID uint64) string {
+	return path.Join(schedulePath, "store_resource_tags", fmt.Sprintf("%020d", storeID))
+}
+
+func (s *Storage) storeRestartPath(storeID uint64) string {
+	return path.Join(schedulePath, "store_restart", fmt.Sprintf("%020d", storeID))
+}
+
+func (s *Storage) storeFencingTokenPath(storeID uint64) string {
+	return path.Join(clusterPath, "store_fencing_token", fmt.Sprintf("%020d", storeID))
+}
+
+func (s *Storage) storeFencingTokenBoundPath(storeID uint64) string {
+	return path.Join(clusterPath, "store_fencing_token_bound", fmt.Sprintf("%020d", storeID))
+}
+
+func (s *Storage) storeJoinTimePath(storeID uint64) string {
+	return path.Join(clusterPath, "store_join_time", fmt.Sprintf("%020d", storeID))
+}
+
+func (s *Storage) storeArchivePath(storeID uint64) string {
+	return path.Join(clusterPath, "store_archive", fmt.Sprintf("%020d", storeID))
+}
+
 // EncryptionKeysPath returns the path to save encryption keys.
 func (s *Storage) EncryptionKeysPath() string {
 	return path.Join(encryptionKeysPath, "keys")
@@ -169,6 +209,46 @@ func (s *Storage) SaveMeta(meta *metapb.Cluster) error {
 	return saveProto(s.Base, clusterPath, meta)
 }
 
+// LoadAllocID loads the max ID that may have been allocated.
+func (s *Storage) LoadAllocID() (uint64, bool, error) {
+	value, err := s.Load(allocIDPath)
+	if err != nil || value == "" {
+		return 0, false, err
+	}
+	id, err := typeutil.BytesToUint64([]byte(value))
+	if err != nil {
+		return 0, false, err
+	}
+	return id, true, nil
+}
+
+// LoadRegionStorageClusterID loads the ID of the cluster which the regions in
+// the region storage belong to. It returns false if the region storage is not
+// used or the ID is not saved yet.
+func (s *Storage) LoadRegionStorageClusterID() (uint64, bool, error) {
+	if atomic.LoadInt32(&s.useRegionStorage) == 0 {
+		return 0, false, nil
+	}
+	value, err := s.regionStorage.Load(regionStorageClusterIDPath)
+	if err != nil || value == "" {
+		return 0, false, err
+	}
+	id, err := strconv.ParseUint(value, 10, 64)
+	if err != nil {
+		return 0, false, errs.ErrStrconvParseUint.Wrap(err).GenWithStackByArgs()
+	}
+	return id, true, nil
+}
+
+// SaveRegionStorageClusterID saves the ID of the cluster which the regions in
+// the region storage belong to.
+func (s *Storage) SaveRegionStorageClusterID(id uint64) error {
+	if atomic.LoadInt32(&s.useRegionStorage) == 0 {
+		return nil
+	}
+	return s.regionStorage.Save(regionStorageClusterIDPath, strconv.FormatUint(id, 10))
+}
+
 // LoadStore loads one store from storage.
 func (s *Storage) LoadStore(storeID uint64, store *metapb.Store) (bool, error) {
 	return loadProto(s.Base, s.storePath(storeID), store)
@@ -181,6 +261,18 @@ func (s *Storage) SaveStore(store *metapb.Store) error {
 
 // DeleteStore deletes one store from storage.
 func (s *Storage) DeleteStore(store *metapb.Store) error {
+	if err := s.Remove(s.storeReadOnlyPath(store.GetId())); err != nil {
+		return err
+	}
+	if err := s.Remove(s.storeRestartPath(store.GetId())); err != nil {
+		return err
+	}
+	if err := s.RemoveStoreFencingToken(store.GetId()); err != nil {
+		return err
+	}
+	if err := s.Remove(s.storeJoinTimePath(store.GetId())); err != nil {
+		return err
+	}
 	return s.Remove(s.storePath(store.GetId()))
 }
 
@@ -195,20 +287,26 @@ func (s *Storage) LoadRegion(regionID uint64, region *metapb.Region) (ok bool, e
 // LoadRegions loads all regions from storage to RegionsInfo.
 func (s *Storage) LoadRegions(f func(region *RegionInfo) []*RegionInfo) error {
 	if atomic.LoadInt32(&s.useRegionStorage) > 0 {
-		return loadRegions(s.regionStorage, s.encryptionKeyManager, f)
+		return loadRegions(context.Background(), s.regionStorage, s.encryptionKeyManager, f)
 	}
-	return loadRegions(s.Base, s.encryptionKeyManager, f)
+	return loadRegions(context.Background(), s.Base, s.encryptionKeyManager, f)
 }
 
 // LoadRegionsOnce loads all regions from storage to RegionsInfo.Only load one time from regionStorage.
 func (s *Storage) LoadRegionsOnce(f func(region *RegionInfo) []*RegionInfo) error {
+	return s.LoadRegionsOnceWithContext(context.Background(), f)
+}
+
+// LoadRegionsOnceWithContext is LoadRegionsOnce which stops loading when the
+// context is done. The regions are not regarded as loaded if it stops.
+func (s *Storage) LoadRegionsOnceWithContext(ctx context.Context, f func(region *RegionInfo) []*RegionInfo) error {
 	if atomic.LoadInt32(&s.useRegionStorage) == 0 {
-		return loadRegions(s.Base, s.encryptionKeyManager, f)
+		return loadRegions(ctx, s.Base, s.encryptionKeyManager, f)
 	}
 	s.mu.Lock()
 	defer s.mu.Unlock()
 	if s.regionLoaded == 0 {
-		if err := loadRegions(s.regionStorage, s.encryptionKeyManager, f); err != nil {
+		if err := loadRegions(ctx, s.regionStorage, s.encryptionKeyManager, f); err != nil {
 			return err
 		}
 		s.regionLoaded = 1
@@ -232,6 +330,15 @@ func (s *Storage) DeleteRegion(region *metapb.Region) error {
 	return deleteRegion(s.Base, region)
 }
 
+// DeleteRegions deletes the regions from storage. The regions with the
+// consecutive IDs are deleted at once if the storage supports it.
+func (s *Storage) DeleteRegions(regions []*metapb.Region) error {
+	if atomic.LoadInt32(&s.useRegionStorage) > 0 {
+		return deleteRegions(s.regionStorage, regions)
+	}
+	return deleteRegions(s.Base, regions)
+}
+
 // SaveConfig stores marshallable cfg to the configPath.
 func (s *Storage) SaveConfig(cfg interface{}) error {
 	value, err := json.Marshal(cfg)
@@ -340,6 +447,55 @@ func (s *Storage) LoadReplicationStatus(mode string, status interface{}) (bool,
 	return true, nil
 }
 
+// SaveSafeMode stores the state of the safe mode.
+func (s *Storage) SaveSafeMode(state interface{}) error {
+	value, err := json.Marshal(state)
+	if err != nil {
+		return errs.ErrJSONMarshal.Wrap(err).GenWithStackByArgs()
+	}
+	return s.Save(safeModePath, string(value))
+}
+
+// SaveRBAC stores the role bindings of RBAC.
+func (s *Storage) SaveRBAC(config interface{}) error {
+	value, err := json.Marshal(config)
+	if err != nil {
+		return errs.ErrJSONMarshal.Wrap(err).GenWithStackByArgs()
+	}
+	return s.Save(rbacPath, string(value))
+}
+
+// LoadRBAC loads the role bindings of RBAC.
+func (s *Storage) LoadRBAC(config interface{}) (bool, error) {
+	v, err := s.Load(rbacPath)
+	if err != nil {
+		return false, err
+	}
+	if v == "" {
+		return false, nil
+	}
+	if err := json.Unmarshal([]byte(v), config); err != nil {
+		return false, errs.ErrJSONUnmarshal.Wrap(err).GenWithStackByArgs()
+	}
+	return true, nil
+}
+
+// LoadSafeMode loads the state of the safe mode.
+func (s *Storage) LoadSafeMode(state interface{}) (bool, error) {
+	v, err := s.Load(safeModePath)
+	if err != nil {
+		return false, err
+	}
+	if v == "" {
+		return false, nil
+	}
+	err = json.Unmarshal([]byte(v), state)
+	if err != nil {
+		return false, errs.ErrJSONUnmarshal.Wrap(err).GenWithStackByArgs()
+	}
+	return true, nil
+}
+
 // SaveComponent stores marshallable components to the componentPath.
 func (s *Storage) SaveComponent(component interface{}) error {
 	value, err := json.Marshal(component)
@@ -388,7 +544,37 @@ func (s *Storage) LoadStores(f func(store *StoreInfo)) error {
 			if err != nil {
 				return err
 			}
-			newStoreInfo := NewStoreInfo(store, SetLeaderWeight(leaderWeight), SetRegionWeight(regionWeight))
+			readOnly, err := s.Load(s.storeReadOnlyPath(store.GetId()))
+			if err != nil {
+				return err
+			}
+			restart, err := s.LoadStoreRestart(store.GetId())
+			if err != nil {
+				return err
+			}
+			var restartDeadline time.Time
+			if restart != nil {
+				restartDeadline = restart.Deadline
+			}
+			fencingToken, err := s.Load(s.storeFencingTokenPath(store.GetId()))
+			if err != nil {
+				return err
+			}
+			fencingTokenBound, err := s.Load(s.storeFencingTokenBoundPath(store.GetId()))
+			if err != nil {
+				return err
+			}
+			joinTime, err := s.loadStoreJoinTime(store.GetId())
+			if err != nil {
+				return err
+			}
+			resourceTags, err := s.loadStoreResourceTags(store.GetId())
+			if err != nil {
+				return err
+			}
+			newStoreInfo := NewStoreInfo(store, SetLeaderWeight(leaderWeight), SetRegionWeight(regionWeight),
+				SetStoreReadOnly(readOnly == "true"), SetRestartDeadline(restartDeadline), SetFencingToken(fencingToken),
+				SetFencingTokenBound(fencingTokenBound == "true"), SetJoinTime(joinTime), SetResourceTags(resourceTags))
 
 			nextID = store.GetId() + 1
 			f(newStoreInfo)
@@ -409,6 +595,173 @@ func (s *Storage) SaveStoreWeight(storeID uint64, leader, region float64) error
 	return s.Save(s.storeRegionWeightPath(storeID), regionValue)
 }
 
+// SaveStoreReadOnly saves whether a store is read-only to storage.
+func (s *Storage) SaveStoreReadOnly(storeID uint64, readOnly bool) error {
+	if !readOnly {
+		return s.Remove(s.storeReadOnlyPath(storeID))
+	}
+	return s.Save(s.storeReadOnlyPath(storeID), strconv.FormatBool(readOnly))
+}
+
+// SaveStoreFencingToken saves the fencing token of a store to storage.
+func (s *Storage) SaveStoreFencingToken(storeID uint64, token string) error {
+	return s.Save(s.storeFencingTokenPath(storeID), token)
+}
+
+// SaveStoreFencingTokenBound saves that a store has presented its fencing
+// token to storage.
+func (s *Storage) SaveStoreFencingTokenBound(storeID uint64) error {
+	return s.Save(s.storeFencingTokenBoundPath(storeID), strconv.FormatBool(true))
+}
+
+// RemoveStoreFencingToken removes the fencing token of a store from storage.
+func (s *Storage) RemoveStoreFencingToken(storeID uint64) error {
+	if err := s.Remove(s.storeFencingTokenBoundPath(storeID)); err != nil {
+		return err
+	}
+	return s.Remove(s.storeFencingTokenPath(storeID))
+}
+
+// SaveStoreJoinTime saves the time when a store is registered to storage.
+func (s *Storage) SaveStoreJoinTime(storeID uint64, t time.Time) error {
+	return s.Save(s.storeJoinTimePath(storeID), strconv.FormatInt(t.UnixNano(), 10))
+}
+
+func (s *Storage) loadStoreJoinTime(storeID uint64) (time.Time, error) {
+	value, err := s.Load(s.storeJoinTimePath(storeID))
+	if err != nil || value == "" {
+		return time.Time{}, err
+	}
+	ts, err := strconv.ParseInt(value, 10, 64)
+	if err != nil {
+		return time.Time{}, errs.ErrStrconvParseInt.Wrap(err).GenWithStackByArgs()
+	}
+	return time.Unix(0, ts), nil
+}
+
+// SaveStoreResourceTags saves the resource tags of a store to storage. The tags
+// are removed if they are empty.
+func (s *Storage) SaveStoreResourceTags(storeID uint64, tags map[string]string) error {
+	if len(tags) == 0 {
+		return s.Remove(s.storeResourceTagsPath(storeID))
+	}
+	value, err := json.Marshal(tags)
+	if err != nil {
+		return errs.ErrJSONMarshal.Wrap(err).GenWithStackByArgs()
+	}
+	return s.Save(s.storeResourceTagsPath(storeID), string(value))
+}
+
+func (s *Storage) loadStoreResourceTags(storeID uint64) (map[string]string, error) {
+	value, err := s.Load(s.storeResourceTagsPath(storeID))
+	if err != nil || value == "" {
+		return nil, err
+	}
+	var tags map[string]string
+	if err := json.Unmarshal([]byte(value), &tags); err != nil {
+		return nil, errs.ErrJSONUnmarshal.Wrap(err).GenWithStackByArgs()
+	}
+	return tags, nil
+}
+
+// StoreRestart is the restart window of a store.
+type StoreRestart struct {
+	Deadline time.Time `json:"deadline"`
+	// EvictLeader is true if the store is added to the evict-leader scheduler
+	// by the window, so that it should be removed when the window ends.
+	EvictLeader bool `json:"evict_leader"`
+}
+
+// SaveStoreRestart saves the restart window of a store. The window is removed
+// if it is nil.
+func (s *Storage) SaveStoreRestart(storeID uint64, restart *StoreRestart) error {
+	if restart == nil {
+		return s.Remove(s.storeRestartPath(storeID))
+	}
+	value, err := json.Marshal(restart)
+	if err != nil {
+		return errs.ErrJSONMarshal.Wrap(err).GenWithStackByArgs()
+	}
+	return s.Save(s.storeRestartPath(storeID), string(value))
+}
+
+// LoadStoreRestart loads the restart window of a store. It returns nil if the
+// store is not in a restart window.
+func (s *Storage) LoadStoreRestart(storeID uint64) (*StoreRestart, error) {
+	value, err := s.Load(s.storeRestartPath(storeID))
+	if err != nil || value == "" {
+		return nil, err
+	}
+	restart := &StoreRestart{}
+	if err := json.Unmarshal([]byte(value), restart); err != nil {
+		return nil, errs.ErrJSONUnmarshal.Wrap(err).GenWithStackByArgs()
+	}
+	return restart, nil
+}
+
+// StoreArchive is the final record of a tombstone store archived when it is
+// removed, which is kept until ExpireTime.
+type StoreArchive struct {
+	Store        *metapb.Store    `json:"store"`
+	Stats        *pdpb.StoreStats `json:"stats,omitempty"`
+	LeaderWeight float64          `json:"leader_weight"`
+	RegionWeight float64          `json:"region_weight"`
+	ArchiveTime  time.Time        `json:"archive_time"`
+	ExpireTime   time.Time        `json:"expire_time"`
+}
+
+// SaveStoreArchive saves the archive of a store.
+func (s *Storage) SaveStoreArchive(archive *StoreArchive) error {
+	value, err := json.Marshal(archive)
+	if err != nil {
+		return errs.ErrJSONMarshal.Wrap(err).GenWithStackByArgs()
+	}
+	return s.Save(s.storeArchivePath(archive.Store.GetId()), string(value))
+}
+
+// LoadStoreArchive loads the archive of a store. It returns nil if the store
+// is not archived or the archive has expired.
+func (s *Storage) LoadStoreArchive(storeID uint64, now time.Time) (*StoreArchive, error) {
+	value, err := s.Load(s.storeArchivePath(storeID))
+	if err != nil || value == "" {
+		return nil, err
+	}
+	archive := &StoreArchive{}
+	if err := json.Unmarshal([]byte(value), archive); err != nil {
+		return nil, errs.ErrJSONUnmarshal.Wrap(err).GenWithStackByArgs()
+	}
+	if now.After(archive.ExpireTime) {
+		return nil, nil
+	}
+	return archive, nil
+}
+
+// DeleteStoreArchive deletes the archive of a store.
+func (s *Storage) DeleteStoreArchive(storeID uint64) error {
+	return s.Remove(s.storeArchivePath(storeID))
+}
+
+// RemoveExpiredStoreArchives removes the archives which have expired.
+func (s *Storage) RemoveExpiredStoreArchives(now time.Time) error {
+	prefix := path.Join(clusterPath, "store_archive") + "/"
+	var expired []string
+	err := s.LoadRangeByPrefix(prefix, func(k, v string) {
+		archive := &StoreArchive{}
+		if err := json.Unmarshal([]byte(v), archive); err != nil || now.After(archive.ExpireTime) {
+			expired = append(expired, prefix+k)
+		}
+	})
+	if err != nil {
+		return err
+	}
+	for _, key := range expired {
+		if err := s.Remove(key); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
 func (s *Storage) loadFloatWithDefaultValue(path string, def float64) (float64, error) {
 	res, err := s.Load(path)
 	if err != nil {
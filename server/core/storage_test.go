@@ -14,10 +14,12 @@
 package core
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
 	"math"
 	"path"
+	"sort"
 	"strings"
 	"time"
 
@@ -123,6 +125,50 @@ func (s *testKVSuite) TestStoreWeight(c *C) {
 	}
 }
 
+func (s *testKVSuite) TestStoreReadOnly(c *C) {
+	storage := NewStorage(kv.NewMemoryKV())
+	cache := NewStoresInfo()
+	const n = 3
+
+	mustSaveStores(c, storage, n)
+	c.Assert(storage.SaveStoreReadOnly(1, true), IsNil)
+	c.Assert(storage.SaveStoreReadOnly(2, true), IsNil)
+	c.Assert(storage.SaveStoreReadOnly(2, false), IsNil)
+	c.Assert(storage.LoadStores(cache.SetStore), IsNil)
+	readOnly := []bool{false, true, false}
+	for i := 0; i < n; i++ {
+		c.Assert(cache.GetStore(uint64(i)).IsReadOnly(), Equals, readOnly[i])
+	}
+
+	// The flag is removed with the store.
+	c.Assert(storage.DeleteStore(&metapb.Store{Id: 1}), IsNil)
+	value, err := storage.Load(storage.storeReadOnlyPath(1))
+	c.Assert(err, IsNil)
+	c.Assert(value, Equals, "")
+}
+
+func (s *testKVSuite) TestStoreRestart(c *C) {
+	storage := NewStorage(kv.NewMemoryKV())
+	cache := NewStoresInfo()
+	const n = 3
+
+	mustSaveStores(c, storage, n)
+	deadline := time.Now().Add(time.Minute).Round(time.Second)
+	c.Assert(storage.SaveStoreRestart(1, &StoreRestart{Deadline: deadline, EvictLeader: true}), IsNil)
+	restart, err := storage.LoadStoreRestart(1)
+	c.Assert(err, IsNil)
+	c.Assert(restart.Deadline.Equal(deadline), IsTrue)
+	c.Assert(restart.EvictLeader, IsTrue)
+	c.Assert(storage.LoadStores(cache.SetStore), IsNil)
+	c.Assert(cache.GetStore(0).IsRestarting(), IsFalse)
+	c.Assert(cache.GetStore(1).IsRestarting(), IsTrue)
+
+	c.Assert(storage.SaveStoreRestart(1, nil), IsNil)
+	restart, err = storage.LoadStoreRestart(1)
+	c.Assert(err, IsNil)
+	c.Assert(restart, IsNil)
+}
+
 func mustSaveRegions(c *C, s *Storage, n int) []*metapb.Region {
 	regions := make([]*metapb.Region, 0, n)
 	for i := 0; i < n; i++ {
@@ -170,6 +216,19 @@ func (s *testKVSuite) TestLoadRegionsToCache(c *C) {
 	c.Assert(cache.GetRegionCount(), Equals, n)
 }
 
+func (s *testKVSuite) TestLoadRegionsWithContext(c *C) {
+	storage := NewStorage(kv.NewMemoryKV())
+	cache := NewRegionsInfo()
+	mustSaveRegions(c, storage, 10)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	c.Assert(errors.Cause(storage.LoadRegionsOnceWithContext(ctx, cache.SetRegion)), Equals, context.Canceled)
+	c.Assert(cache.GetRegionCount(), Equals, 0)
+	c.Assert(storage.LoadRegionsOnceWithContext(context.Background(), cache.SetRegion), IsNil)
+	c.Assert(cache.GetRegionCount(), Equals, 10)
+}
+
 func (s *testKVSuite) TestLoadRegionsExceedRangeLimit(c *C) {
 	storage := NewStorage(&KVWithMaxRangeLimit{Base: kv.NewMemoryKV(), rangeLimit: 500})
 	cache := NewRegionsInfo()
@@ -183,6 +242,26 @@ func (s *testKVSuite) TestLoadRegionsExceedRangeLimit(c *C) {
 	}
 }
 
+func (s *testKVSuite) TestDeleteRegions(c *C) {
+	for _, base := range []kv.Base{kv.NewMemoryKV(), &KVWithMaxRangeLimit{Base: kv.NewMemoryKV(), rangeLimit: 500}} {
+		storage := NewStorage(base)
+		regions := mustSaveRegions(c, storage, 10)
+		var deleted []*metapb.Region
+		for _, id := range []uint64{8, 1, 2, 3, 5, 7, 2} {
+			deleted = append(deleted, regions[id])
+		}
+		c.Assert(storage.DeleteRegions(deleted), IsNil)
+		cache := NewRegionsInfo()
+		c.Assert(storage.LoadRegions(cache.SetRegion), IsNil)
+		var ids []uint64
+		for _, region := range cache.GetMetaRegions() {
+			ids = append(ids, region.GetId())
+		}
+		sort.Slice(ids, func(i, j int) bool { return ids[i] < ids[j] })
+		c.Assert(ids, DeepEquals, []uint64{0, 4, 6, 9})
+	}
+}
+
 func (s *testKVSuite) TestLoadGCSafePoint(c *C) {
 	storage := NewStorage(kv.NewMemoryKV())
 	testData := []uint64{0, 1, 2, 233, 2333, 23333333333, math.MaxUint64}
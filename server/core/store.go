@@ -15,6 +15,7 @@ package core
 
 import (
 	"math"
+	"sort"
 	"strings"
 	"time"
 
@@ -39,7 +40,16 @@ const (
 type StoreInfo struct {
 	meta *metapb.Store
 	*storeStats
-	pauseLeaderTransfer bool // not allow to be used as source or target of transfer leader
+	pauseLeaderTransfer bool              // not allow to be used as source or target of transfer leader
+	readOnly            bool              // not allow to be used as target of transfer leader or add peer
+	restartDeadline     time.Time         // the store is suspect before the deadline
+	suspectUntil        time.Time         // the store is suspected to come back soon before the time
+	fencingToken        string            // issued at registration and presented by the store afterwards
+	fencingTokenBound   bool              // the store has presented the token, so it must present it afterwards
+	joinTime            time.Time         // the time when the store is registered
+	resourceTags        map[string]string // the resource tags set by PD, separated from the labels
+	compactionBytes     uint64            // the pending compaction bytes fetched from the status server
+	daysToFull          float64           // the predicted days to full, 0 if the store is not filling
 	leaderCount         int
 	regionCount         int
 	leaderSize          int64
@@ -72,6 +82,15 @@ func (s *StoreInfo) Clone(opts ...StoreCreateOption) *StoreInfo {
 		meta:                meta,
 		storeStats:          s.storeStats,
 		pauseLeaderTransfer: s.pauseLeaderTransfer,
+		readOnly:            s.readOnly,
+		restartDeadline:     s.restartDeadline,
+		suspectUntil:        s.suspectUntil,
+		fencingToken:        s.fencingToken,
+		fencingTokenBound:   s.fencingTokenBound,
+		joinTime:            s.joinTime,
+		resourceTags:        s.resourceTags,
+		compactionBytes:     s.compactionBytes,
+		daysToFull:          s.daysToFull,
 		leaderCount:         s.leaderCount,
 		regionCount:         s.regionCount,
 		leaderSize:          s.leaderSize,
@@ -95,6 +114,15 @@ func (s *StoreInfo) ShallowClone(opts ...StoreCreateOption) *StoreInfo {
 		meta:                s.meta,
 		storeStats:          s.storeStats,
 		pauseLeaderTransfer: s.pauseLeaderTransfer,
+		readOnly:            s.readOnly,
+		restartDeadline:     s.restartDeadline,
+		suspectUntil:        s.suspectUntil,
+		fencingToken:        s.fencingToken,
+		fencingTokenBound:   s.fencingTokenBound,
+		joinTime:            s.joinTime,
+		resourceTags:        s.resourceTags,
+		compactionBytes:     s.compactionBytes,
+		daysToFull:          s.daysToFull,
 		leaderCount:         s.leaderCount,
 		regionCount:         s.regionCount,
 		leaderSize:          s.leaderSize,
@@ -118,6 +146,96 @@ func (s *StoreInfo) AllowLeaderTransfer() bool {
 	return !s.pauseLeaderTransfer
 }
 
+// IsRestarting returns if the store is prepared to be restarted and the
+// restart window is not expired. The down peers on a restarting store are
+// expected to come back soon, so they are only replaced if the quorum is at
+// risk.
+func (s *StoreInfo) IsRestarting() bool {
+	return time.Now().Before(s.restartDeadline)
+}
+
+// GetRestartDeadline returns the deadline of the restart window of the store.
+func (s *StoreInfo) GetRestartDeadline() time.Time {
+	return s.restartDeadline
+}
+
+// GetSuspectUntil returns the time until which the down store is suspected to
+// come back soon, which is set by the down detection.
+func (s *StoreInfo) GetSuspectUntil() time.Time {
+	return s.suspectUntil
+}
+
+// IsSuspect returns if the store is suspected to come back soon, because it is
+// restarting or it is down within the grace period. The down peers on a
+// suspect store are only fixed if the quorum is at risk.
+func (s *StoreInfo) IsSuspect() bool {
+	return s.IsRestarting() || time.Now().Before(s.suspectUntil)
+}
+
+// GetFencingToken returns the fencing token issued to the store when it is
+// registered. It is empty for the stores registered before the token is
+// introduced, until they are registered again.
+func (s *StoreInfo) GetFencingToken() string {
+	return s.fencingToken
+}
+
+// IsFencingTokenBound returns whether the store has presented its fencing
+// token. A bound store is rejected if it omits the token afterwards.
+func (s *StoreInfo) IsFencingTokenBound() bool {
+	return s.fencingTokenBound
+}
+
+// GetJoinTime returns the time when the store is registered. It is zero for
+// the stores registered before the time is recorded.
+func (s *StoreInfo) GetJoinTime() time.Time {
+	return s.joinTime
+}
+
+// GetPendingCompactionBytes returns the pending compaction bytes of the store,
+// which are fetched from its status server periodically. It is 0 if they are
+// not fetched.
+func (s *StoreInfo) GetPendingCompactionBytes() uint64 {
+	return s.compactionBytes
+}
+
+// IsCompactionPressured returns if the pending compaction bytes of the store
+// reach the threshold. A zero threshold means never.
+func (s *StoreInfo) IsCompactionPressured(threshold uint64) bool {
+	return threshold > 0 && s.compactionBytes >= threshold
+}
+
+// GetDaysToFull returns the predicted days before the store is full, which is
+// extrapolated from the trend of its available capacity. It is 0 if the store
+// is not filling or there are not enough samples.
+func (s *StoreInfo) GetDaysToFull() float64 {
+	return s.daysToFull
+}
+
+// IsPredictedFull returns if the store is predicted to be full within the
+// days. A zero days means never.
+func (s *StoreInfo) IsPredictedFull(days float64) bool {
+	return days > 0 && s.daysToFull > 0 && s.daysToFull < days
+}
+
+// IsReadOnly returns if the store is read-only. A read-only store keeps its
+// replicas and serves reads, but it is not selected as the target of transfer
+// leader or add peer.
+func (s *StoreInfo) IsReadOnly() bool {
+	return s.readOnly
+}
+
+// GetResourceTags returns the resource tags of the store, such as the disk
+// type or the number of the CPUs. Unlike the labels, they are set by PD rather
+// than reported by the store, and they do not describe the location.
+func (s *StoreInfo) GetResourceTags() map[string]string {
+	return s.resourceTags
+}
+
+// GetResourceTag returns the value of a resource tag (if exists).
+func (s *StoreInfo) GetResourceTag(key string) string {
+	return s.resourceTags[key]
+}
+
 // IsAvailable returns if the store bucket of limitation is available
 func (s *StoreInfo) IsAvailable(limitType storelimit.Type) bool {
 	if s.available != nil && s.available[limitType] != nil {
@@ -450,6 +568,12 @@ func (s *StoreInfo) GetLabelValue(key string) string {
 	return ""
 }
 
+// GetDiskClass returns the disk throughput class of the store, which is
+// specified by the DiskClassLabel label. It is empty if the label is not set.
+func (s *StoreInfo) GetDiskClass() string {
+	return strings.ToLower(s.GetLabelValue(DiskClassLabel))
+}
+
 // CompareLocation compares 2 stores' labels and returns at which level their
 // locations are different. It returns -1 if they are at the same location.
 func (s *StoreInfo) CompareLocation(other *StoreInfo, labels []string) int {
@@ -507,15 +631,76 @@ L:
 // StoresInfo contains information about all stores.
 type StoresInfo struct {
 	stores map[uint64]*StoreInfo
+	// labels is the labels and the resource tags of each store encoded in a
+	// string. It is compared to find the changes, since the labels may be
+	// modified in place.
+	labels map[uint64]string
+	// labelVersions is the label version when the labels or the resource
+	// tags of each store change.
+	labelVersions map[uint64]uint64
+	// labelVersion is increased when the labels or the resource tags of any
+	// store change, or a store is added or deleted.
+	labelVersion uint64
 }
 
 // NewStoresInfo create a StoresInfo with map of storeID to StoreInfo
 func NewStoresInfo() *StoresInfo {
 	return &StoresInfo{
-		stores: make(map[uint64]*StoreInfo),
+		stores:        make(map[uint64]*StoreInfo),
+		labels:        make(map[uint64]string),
+		labelVersions: make(map[uint64]uint64),
 	}
 }
 
+// encodeStoreLabels encodes the labels and the resource tags of a store.
+func encodeStoreLabels(store *StoreInfo) string {
+	var b strings.Builder
+	for _, l := range store.GetLabels() {
+		b.WriteString(l.GetKey())
+		b.WriteByte('=')
+		b.WriteString(l.GetValue())
+		b.WriteByte(';')
+	}
+	tags := store.GetResourceTags()
+	keys := make([]string, 0, len(tags))
+	for k := range tags {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		b.WriteString("$")
+		b.WriteString(k)
+		b.WriteByte('=')
+		b.WriteString(tags[k])
+		b.WriteByte(';')
+	}
+	return b.String()
+}
+
+// updateLabelVersion increases the label versions if the labels of the
+// store change.
+func (s *StoresInfo) updateLabelVersion(store *StoreInfo) {
+	labels := encodeStoreLabels(store)
+	if old, ok := s.labels[store.GetID()]; ok && old == labels {
+		return
+	}
+	s.labels[store.GetID()] = labels
+	s.labelVersion++
+	s.labelVersions[store.GetID()] = s.labelVersion
+}
+
+// GetLabelVersion returns the version which is increased when the labels of
+// any store change, or a store is added or deleted.
+func (s *StoresInfo) GetLabelVersion() uint64 {
+	return s.labelVersion
+}
+
+// GetStoreLabelVersion returns the label version when the labels of the
+// store change. It returns 0 if the store does not exist.
+func (s *StoresInfo) GetStoreLabelVersion(storeID uint64) uint64 {
+	return s.labelVersions[storeID]
+}
+
 // GetStore returns a copy of the StoreInfo with the specified storeID.
 func (s *StoresInfo) GetStore(storeID uint64) *StoreInfo {
 	store, ok := s.stores[storeID]
@@ -528,6 +713,7 @@ func (s *StoresInfo) GetStore(storeID uint64) *StoreInfo {
 // SetStore sets a StoreInfo with storeID.
 func (s *StoresInfo) SetStore(store *StoreInfo) {
 	s.stores[store.GetID()] = store
+	s.updateLabelVersion(store)
 }
 
 // PauseLeaderTransfer pauses a StoreInfo with storeID.
@@ -583,6 +769,20 @@ func (s *StoresInfo) GetMetaStores() []*metapb.Store {
 // DeleteStore deletes tombstone record form store
 func (s *StoresInfo) DeleteStore(store *StoreInfo) {
 	delete(s.stores, store.GetID())
+	if _, ok := s.labels[store.GetID()]; ok {
+		delete(s.labels, store.GetID())
+		delete(s.labelVersions, store.GetID())
+		s.labelVersion++
+	}
+}
+
+// GetMemoryUsage returns the estimated memory used by the stores.
+func (s *StoresInfo) GetMemoryUsage() int64 {
+	var size int64
+	for _, store := range s.stores {
+		size += estimateStoreMemory(store)
+	}
+	return size
 }
 
 // GetStoreCount returns the total count of storeInfo.
@@ -637,6 +837,16 @@ func (s *StoresInfo) UpdateStoreStatus(storeID uint64, leaderCount int, regionCo
 	}
 }
 
+// DiskClassLabel is the label key of the disk throughput class of a store.
+const DiskClassLabel = "disk-class"
+
+// The disk throughput classes of the stores.
+const (
+	DiskClassHDD  = "hdd"
+	DiskClassSSD  = "ssd"
+	DiskClassNVMe = "nvme"
+)
+
 // IsTiFlashStore used to judge flash store.
 // FIXME: remove the hack way
 func IsTiFlashStore(store *metapb.Store) bool {
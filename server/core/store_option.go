@@ -166,6 +166,70 @@ func SetRegionWeight(regionWeight float64) StoreCreateOption {
 	}
 }
 
+// SetStoreReadOnly sets whether the store is read-only.
+func SetStoreReadOnly(readOnly bool) StoreCreateOption {
+	return func(store *StoreInfo) {
+		store.readOnly = readOnly
+	}
+}
+
+// SetResourceTags sets the resource tags of the store.
+func SetResourceTags(tags map[string]string) StoreCreateOption {
+	return func(store *StoreInfo) {
+		store.resourceTags = tags
+	}
+}
+
+// SetRestartDeadline sets the deadline of the restart window of the store.
+func SetRestartDeadline(deadline time.Time) StoreCreateOption {
+	return func(store *StoreInfo) {
+		store.restartDeadline = deadline
+	}
+}
+
+// SetSuspectUntil sets the time until which the store is suspected to come
+// back soon.
+func SetSuspectUntil(t time.Time) StoreCreateOption {
+	return func(store *StoreInfo) {
+		store.suspectUntil = t
+	}
+}
+
+// SetFencingToken sets the fencing token of the store.
+func SetFencingToken(token string) StoreCreateOption {
+	return func(store *StoreInfo) {
+		store.fencingToken = token
+	}
+}
+
+// SetFencingTokenBound sets whether the store has presented its fencing token.
+func SetFencingTokenBound(bound bool) StoreCreateOption {
+	return func(store *StoreInfo) {
+		store.fencingTokenBound = bound
+	}
+}
+
+// SetJoinTime sets the time when the store is registered.
+func SetJoinTime(t time.Time) StoreCreateOption {
+	return func(store *StoreInfo) {
+		store.joinTime = t
+	}
+}
+
+// SetPendingCompactionBytes sets the pending compaction bytes of the store.
+func SetPendingCompactionBytes(bytes uint64) StoreCreateOption {
+	return func(store *StoreInfo) {
+		store.compactionBytes = bytes
+	}
+}
+
+// SetDaysToFull sets the predicted days before the store is full.
+func SetDaysToFull(days float64) StoreCreateOption {
+	return func(store *StoreInfo) {
+		store.daysToFull = days
+	}
+}
+
 // SetLastHeartbeatTS sets the time of last heartbeat for the store.
 func SetLastHeartbeatTS(lastHeartbeatTS time.Time) StoreCreateOption {
 	return func(store *StoreInfo) {
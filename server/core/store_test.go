@@ -126,3 +126,28 @@ func (s *testStoreSuite) TestLowSpaceRatio(c *C) {
 	store.rawStats.Available = store.rawStats.Capacity >> 2
 	c.Assert(store.IsLowSpace(0.8), Equals, false)
 }
+
+func (s *testStoreSuite) TestLabelVersion(c *C) {
+	stores := NewStoresInfo()
+	store := NewStoreInfoWithLabel(1, 0, map[string]string{"zone": "z1"})
+	stores.SetStore(store)
+	stores.SetStore(NewStoreInfoWithLabel(2, 0, map[string]string{"zone": "z2"}))
+	c.Assert(stores.GetLabelVersion(), Equals, uint64(2))
+	c.Assert(stores.GetStoreLabelVersion(1), Equals, uint64(1))
+
+	// The stats do not change the versions.
+	stores.SetStore(store.Clone(SetRegionCount(10)))
+	c.Assert(stores.GetLabelVersion(), Equals, uint64(2))
+	// The labels modified in place are found.
+	store.GetLabels()[0].Value = "z3"
+	stores.SetStore(store)
+	c.Assert(stores.GetLabelVersion(), Equals, uint64(3))
+	c.Assert(stores.GetStoreLabelVersion(1), Equals, uint64(3))
+	stores.SetStore(store.Clone(SetResourceTags(map[string]string{"tenant": "t1"})))
+	c.Assert(stores.GetStoreLabelVersion(1), Equals, uint64(4))
+	c.Assert(stores.GetStoreLabelVersion(2), Equals, uint64(2))
+
+	stores.DeleteStore(store)
+	c.Assert(stores.GetLabelVersion(), Equals, uint64(5))
+	c.Assert(stores.GetStoreLabelVersion(1), Equals, uint64(0))
+}
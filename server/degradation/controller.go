@@ -0,0 +1,145 @@
+// Copyright 2021 TiKV Project Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package degradation
+
+import (
+	"context"
+	"runtime"
+	"sync/atomic"
+	"time"
+
+	"github.com/pingcap/log"
+	"github.com/tikv/pd/pkg/logutil"
+	"github.com/tikv/pd/server/config"
+	"go.uber.org/zap"
+)
+
+const (
+	checkInterval = 5 * time.Second
+	// recoverRatioGap makes PD leave the degraded mode only after the memory
+	// usage drops well below the threshold, so that it does not flap.
+	recoverRatioGap = 0.1
+	// heartbeatConcurrency is the number of region heartbeats that are handled
+	// at the same time in the degraded mode.
+	heartbeatConcurrency = 4
+)
+
+// Controller switches PD to the degraded mode when its memory usage crosses
+// the threshold, and back when the pressure subsides. In the degraded mode,
+// the region heartbeats are handled with limited concurrency and the APIs
+// scanning regions are rejected.
+type Controller struct {
+	getConfig    func() *config.PDServerConfig
+	readMemory   func() uint64
+	degraded     int32
+	heartbeatSem chan struct{}
+	// onDegraded is called when PD enters the degraded mode.
+	onDegraded func()
+}
+
+// NewController creates a Controller with the config getter.
+func NewController(getConfig func() *config.PDServerConfig) *Controller {
+	return &Controller{
+		getConfig:    getConfig,
+		readMemory:   readMemory,
+		heartbeatSem: make(chan struct{}, heartbeatConcurrency),
+	}
+}
+
+// SetDegradedCallback sets the function called when PD enters the degraded
+// mode. It should be set before Run.
+func (c *Controller) SetDegradedCallback(f func()) {
+	c.onDegraded = f
+}
+
+// readMemory returns the memory obtained from the OS and not yet released.
+func readMemory() uint64 {
+	var ms runtime.MemStats
+	runtime.ReadMemStats(&ms)
+	return ms.Sys - ms.HeapReleased
+}
+
+// Run checks the memory usage periodically until the context is done.
+func (c *Controller) Run(ctx context.Context) {
+	defer logutil.LogPanic()
+	ticker := time.NewTicker(checkInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			c.check()
+		case <-ctx.Done():
+			c.setDegraded(false, 0, 0)
+			return
+		}
+	}
+}
+
+func (c *Controller) check() {
+	cfg := c.getConfig()
+	limit := uint64(cfg.MemoryLimit)
+	usage := c.readMemory()
+	memoryUsageGauge.Set(float64(usage))
+	if limit == 0 {
+		c.setDegraded(false, usage, limit)
+		return
+	}
+	if c.IsDegraded() {
+		if float64(usage) < float64(limit)*(cfg.DegradedMemoryRatio-recoverRatioGap) {
+			c.setDegraded(false, usage, limit)
+		}
+	} else if float64(usage) >= float64(limit)*cfg.DegradedMemoryRatio {
+		c.setDegraded(true, usage, limit)
+	}
+}
+
+func (c *Controller) setDegraded(degraded bool, usage, limit uint64) {
+	var v int32
+	if degraded {
+		v = 1
+	}
+	if atomic.SwapInt32(&c.degraded, v) == v {
+		return
+	}
+	degradedGauge.Set(float64(v))
+	if degraded {
+		degradedTransitionCounter.WithLabelValues("enter").Inc()
+		log.Warn("PD enters the degraded mode because of the memory pressure", zap.Uint64("memory-usage", usage), zap.Uint64("memory-limit", limit))
+		if c.onDegraded != nil {
+			c.onDegraded()
+		}
+	} else {
+		degradedTransitionCounter.WithLabelValues("leave").Inc()
+		log.Info("PD leaves the degraded mode", zap.Uint64("memory-usage", usage), zap.Uint64("memory-limit", limit))
+	}
+}
+
+// IsDegraded returns whether PD is in the degraded mode.
+func (c *Controller) IsDegraded() bool {
+	return atomic.LoadInt32(&c.degraded) == 1
+}
+
+// AcquireHeartbeat waits until the region heartbeat is allowed to be handled.
+// The returned function should be called after the heartbeat is handled.
+func (c *Controller) AcquireHeartbeat(ctx context.Context) (release func(), err error) {
+	if !c.IsDegraded() {
+		return func() {}, nil
+	}
+	select {
+	case c.heartbeatSem <- struct{}{}:
+		return func() { <-c.heartbeatSem }, nil
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
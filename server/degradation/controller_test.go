@@ -0,0 +1,91 @@
+// Copyright 2021 TiKV Project Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package degradation
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	. "github.com/pingcap/check"
+	"github.com/tikv/pd/server/config"
+)
+
+func TestDegradation(t *testing.T) {
+	TestingT(t)
+}
+
+var _ = Suite(&testControllerSuite{})
+
+type testControllerSuite struct{}
+
+func (s *testControllerSuite) TestCheck(c *C) {
+	cfg := &config.PDServerConfig{DegradedMemoryRatio: 0.8}
+	var usage uint64
+	ctl := NewController(func() *config.PDServerConfig { return cfg })
+	ctl.readMemory = func() uint64 { return usage }
+
+	// No limit.
+	usage = 1000
+	ctl.check()
+	c.Assert(ctl.IsDegraded(), IsFalse)
+
+	cfg.MemoryLimit = 100
+	usage = 79
+	ctl.check()
+	c.Assert(ctl.IsDegraded(), IsFalse)
+	usage = 80
+	ctl.check()
+	c.Assert(ctl.IsDegraded(), IsTrue)
+	// It does not leave the degraded mode until the pressure subsides.
+	usage = 75
+	ctl.check()
+	c.Assert(ctl.IsDegraded(), IsTrue)
+	usage = 69
+	ctl.check()
+	c.Assert(ctl.IsDegraded(), IsFalse)
+
+	// Removing the limit leaves the degraded mode.
+	usage = 90
+	ctl.check()
+	c.Assert(ctl.IsDegraded(), IsTrue)
+	cfg.MemoryLimit = 0
+	ctl.check()
+	c.Assert(ctl.IsDegraded(), IsFalse)
+}
+
+func (s *testControllerSuite) TestAcquireHeartbeat(c *C) {
+	ctl := NewController(func() *config.PDServerConfig { return &config.PDServerConfig{} })
+	// The heartbeats are not limited in the normal mode.
+	for i := 0; i < 2*heartbeatConcurrency; i++ {
+		_, err := ctl.AcquireHeartbeat(context.Background())
+		c.Assert(err, IsNil)
+	}
+
+	ctl.setDegraded(true, 0, 0)
+	releases := make([]func(), 0, heartbeatConcurrency)
+	for i := 0; i < heartbeatConcurrency; i++ {
+		release, err := ctl.AcquireHeartbeat(context.Background())
+		c.Assert(err, IsNil)
+		releases = append(releases, release)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	_, err := ctl.AcquireHeartbeat(ctx)
+	c.Assert(err, NotNil)
+	releases[0]()
+	release, err := ctl.AcquireHeartbeat(context.Background())
+	c.Assert(err, IsNil)
+	release()
+}
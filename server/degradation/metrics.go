@@ -0,0 +1,48 @@
+// Copyright 2021 TiKV Project Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package degradation
+
+import "github.com/prometheus/client_golang/prometheus"
+
+var (
+	memoryUsageGauge = prometheus.NewGauge(
+		prometheus.GaugeOpts{
+			Namespace: "pd",
+			Subsystem: "server",
+			Name:      "memory_usage_bytes",
+			Help:      "The memory obtained from the OS and not yet released by PD.",
+		})
+
+	degradedGauge = prometheus.NewGauge(
+		prometheus.GaugeOpts{
+			Namespace: "pd",
+			Subsystem: "server",
+			Name:      "degraded",
+			Help:      "Whether PD is in the degraded mode because of the memory pressure.",
+		})
+
+	degradedTransitionCounter = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace: "pd",
+			Subsystem: "server",
+			Name:      "degraded_transition_total",
+			Help:      "Counter of entering and leaving the degraded mode.",
+		}, []string{"type"})
+)
+
+func init() {
+	prometheus.MustRegister(memoryUsageGauge)
+	prometheus.MustRegister(degradedGauge)
+	prometheus.MustRegister(degradedTransitionCounter)
+}
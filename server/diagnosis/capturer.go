@@ -0,0 +1,294 @@
+// Copyright 2021 TiKV Project Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package diagnosis
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"runtime/pprof"
+	"runtime/trace"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/pingcap/log"
+	"github.com/tikv/pd/pkg/errs"
+	"github.com/tikv/pd/pkg/logutil"
+	"github.com/tikv/pd/server/config"
+	"go.uber.org/zap"
+)
+
+const (
+	bundlePrefix = "diagnosis-"
+	bundleSuffix = ".zip"
+	// DefaultCPUDuration is the default duration of the CPU profile in a bundle.
+	DefaultCPUDuration = 10 * time.Second
+	// MaxCPUDuration is the maximum duration of the CPU profile in a bundle.
+	MaxCPUDuration = time.Minute
+	// maxTraceDuration limits the runtime trace, which grows fast.
+	maxTraceDuration = time.Second
+	// autoCaptureCooldown is the minimum interval between the automatic captures.
+	autoCaptureCooldown = 10 * time.Minute
+	// ManualReason is the reason of the bundles captured by the API.
+	ManualReason = "manual"
+)
+
+// BundleInfo is the information of a diagnosis bundle on disk.
+type BundleInfo struct {
+	Name string    `json:"name"`
+	Size int64     `json:"size"`
+	Time time.Time `json:"time"`
+}
+
+// Capturer captures the diagnosis bundles, each of which is a zip archive
+// containing the heap, goroutine and CPU profiles, a runtime trace, the
+// current config and the queue depths of the runners. The bundles are kept in
+// a directory and the oldest ones are removed beyond the limit.
+type Capturer struct {
+	dir            string
+	getPDConfig    func() *config.PDServerConfig
+	getConfig      func() interface{}
+	getQueueDepths func() map[string]int
+
+	capturing int32
+	mu        sync.Mutex
+	// lastAuto is the time of the last automatic capture.
+	lastAuto time.Time
+}
+
+// NewCapturer creates a Capturer that keeps the bundles in the directory.
+func NewCapturer(dir string, getPDConfig func() *config.PDServerConfig, getConfig func() interface{}, getQueueDepths func() map[string]int) *Capturer {
+	return &Capturer{
+		dir:            dir,
+		getPDConfig:    getPDConfig,
+		getConfig:      getConfig,
+		getQueueDepths: getQueueDepths,
+	}
+}
+
+// Capture captures a bundle with the CPU profile lasting for cpuDuration, and
+// returns the name of the bundle.
+func (c *Capturer) Capture(reason string, cpuDuration time.Duration) (string, error) {
+	if !atomic.CompareAndSwapInt32(&c.capturing, 0, 1) {
+		return "", errs.ErrDiagnosisCapturing.FastGenByArgs()
+	}
+	defer atomic.StoreInt32(&c.capturing, 0)
+
+	if err := os.MkdirAll(c.dir, 0755); err != nil {
+		return "", errs.ErrDiagnosisCapture.Wrap(err).GenWithStackByCause()
+	}
+	now := time.Now()
+	name := fmt.Sprintf("%s%d%s", bundlePrefix, now.UnixNano(), bundleSuffix)
+	var buf bytes.Buffer
+	if err := c.writeBundle(&buf, reason, now, cpuDuration); err != nil {
+		return "", err
+	}
+	// Write to a temporary file first so that a broken bundle is never listed.
+	tmp := filepath.Join(c.dir, name+".tmp")
+	if err := os.WriteFile(tmp, buf.Bytes(), 0644); err != nil {
+		return "", errs.ErrDiagnosisCapture.Wrap(err).GenWithStackByCause()
+	}
+	if err := os.Rename(tmp, filepath.Join(c.dir, name)); err != nil {
+		return "", errs.ErrDiagnosisCapture.Wrap(err).GenWithStackByCause()
+	}
+	captureCounter.WithLabelValues(reasonLabel(reason)).Inc()
+	log.Info("diagnosis bundle is captured", zap.String("name", name), zap.String("reason", reason), zap.Int("size", buf.Len()))
+	c.removeExceeded(c.getPDConfig().DiagnosisBundleLimit)
+	return name, nil
+}
+
+func (c *Capturer) writeBundle(w io.Writer, reason string, now time.Time, cpuDuration time.Duration) error {
+	zw := zip.NewWriter(w)
+	add := func(name string, write func(io.Writer) error) error {
+		f, err := zw.CreateHeader(&zip.FileHeader{Name: name, Method: zip.Deflate, Modified: now})
+		if err != nil {
+			return err
+		}
+		return write(f)
+	}
+	writeJSON := func(v interface{}) func(io.Writer) error {
+		return func(w io.Writer) error {
+			enc := json.NewEncoder(w)
+			enc.SetIndent("", "  ")
+			return enc.Encode(v)
+		}
+	}
+
+	var cpu, tr bytes.Buffer
+	if err := captureCPUAndTrace(&cpu, &tr, cpuDuration); err != nil {
+		return errs.ErrDiagnosisCapture.Wrap(err).GenWithStackByCause()
+	}
+	entries := []struct {
+		name  string
+		write func(io.Writer) error
+	}{
+		{"reason.txt", func(w io.Writer) error {
+			_, err := fmt.Fprintf(w, "%s\n%s\n", now.Format(time.RFC3339), reason)
+			return err
+		}},
+		{"heap.pb.gz", func(w io.Writer) error { return pprof.Lookup("heap").WriteTo(w, 0) }},
+		{"goroutine.txt", func(w io.Writer) error { return pprof.Lookup("goroutine").WriteTo(w, 2) }},
+		{"cpu.pb.gz", func(w io.Writer) error { _, err := w.Write(cpu.Bytes()); return err }},
+		{"trace.out", func(w io.Writer) error { _, err := w.Write(tr.Bytes()); return err }},
+		{"config.json", writeJSON(c.getConfig())},
+		{"queues.json", writeJSON(c.getQueueDepths())},
+	}
+	for _, e := range entries {
+		if err := add(e.name, e.write); err != nil {
+			return errs.ErrDiagnosisCapture.Wrap(err).GenWithStackByCause()
+		}
+	}
+	if err := zw.Close(); err != nil {
+		return errs.ErrDiagnosisCapture.Wrap(err).GenWithStackByCause()
+	}
+	return nil
+}
+
+// captureCPUAndTrace profiles the CPU for the duration, and traces the
+// runtime at the beginning of it.
+func captureCPUAndTrace(cpu, tr io.Writer, d time.Duration) error {
+	if err := pprof.StartCPUProfile(cpu); err != nil {
+		return err
+	}
+	defer pprof.StopCPUProfile()
+	traceDuration := d
+	if traceDuration > maxTraceDuration {
+		traceDuration = maxTraceDuration
+	}
+	if err := trace.Start(tr); err != nil {
+		return err
+	}
+	time.Sleep(traceDuration)
+	trace.Stop()
+	time.Sleep(d - traceDuration)
+	return nil
+}
+
+// Trigger captures a bundle automatically if it is enabled and the last
+// automatic capture is not too recent. It does not block the caller.
+func (c *Capturer) Trigger(reason string) {
+	if !c.getPDConfig().DiagnosisAutoCapture {
+		return
+	}
+	c.mu.Lock()
+	if time.Since(c.lastAuto) < autoCaptureCooldown {
+		c.mu.Unlock()
+		return
+	}
+	c.lastAuto = time.Now()
+	c.mu.Unlock()
+	go func() {
+		defer logutil.LogPanic()
+		if _, err := c.Capture(reason, DefaultCPUDuration); err != nil {
+			log.Warn("failed to capture the diagnosis bundle", zap.String("reason", reason), errs.ZapError(err))
+		}
+	}()
+}
+
+// ObserveHeartbeat triggers a capture if the handling duration of a region
+// heartbeat crosses the threshold.
+func (c *Capturer) ObserveHeartbeat(d time.Duration) {
+	cfg := c.getPDConfig()
+	if !cfg.DiagnosisAutoCapture || d < cfg.DiagnosisSlowHeartbeatThreshold.Duration {
+		return
+	}
+	c.Trigger(fmt.Sprintf("slow region heartbeat: %s", d))
+}
+
+// List returns the bundles on disk, the newest first.
+func (c *Capturer) List() ([]*BundleInfo, error) {
+	entries, err := os.ReadDir(c.dir)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, errs.ErrIORead.Wrap(err).GenWithStackByCause()
+	}
+	var infos []*BundleInfo
+	for _, e := range entries {
+		if e.IsDir() || !IsBundleName(e.Name()) {
+			continue
+		}
+		fi, err := e.Info()
+		if err != nil {
+			continue
+		}
+		infos = append(infos, &BundleInfo{Name: e.Name(), Size: fi.Size(), Time: fi.ModTime()})
+	}
+	sort.Slice(infos, func(i, j int) bool { return infos[i].Name > infos[j].Name })
+	return infos, nil
+}
+
+// Open opens the bundle with the name.
+func (c *Capturer) Open(name string) (*os.File, error) {
+	if !IsBundleName(name) {
+		return nil, errs.ErrDiagnosisBundleNotFound.FastGenByArgs(name)
+	}
+	f, err := os.Open(filepath.Join(c.dir, name))
+	if os.IsNotExist(err) {
+		return nil, errs.ErrDiagnosisBundleNotFound.FastGenByArgs(name)
+	}
+	if err != nil {
+		return nil, errs.ErrIORead.Wrap(err).GenWithStackByCause()
+	}
+	return f, nil
+}
+
+// IsBundleName returns whether the name is a valid bundle name, which also
+// prevents the path traversal.
+func IsBundleName(name string) bool {
+	if !strings.HasPrefix(name, bundlePrefix) || !strings.HasSuffix(name, bundleSuffix) {
+		return false
+	}
+	id := strings.TrimSuffix(strings.TrimPrefix(name, bundlePrefix), bundleSuffix)
+	if id == "" {
+		return false
+	}
+	for _, r := range id {
+		if r < '0' || r > '9' {
+			return false
+		}
+	}
+	return true
+}
+
+func (c *Capturer) removeExceeded(limit int) {
+	infos, err := c.List()
+	if err != nil || len(infos) <= limit {
+		return
+	}
+	for _, info := range infos[limit:] {
+		if err := os.Remove(filepath.Join(c.dir, info.Name)); err != nil {
+			log.Warn("failed to remove the diagnosis bundle", zap.String("name", info.Name), errs.ZapError(err))
+		}
+	}
+}
+
+func reasonLabel(reason string) string {
+	switch {
+	case reason == ManualReason:
+		return "manual"
+	case strings.HasPrefix(reason, "slow region heartbeat"):
+		return "slow-heartbeat"
+	default:
+		return "memory"
+	}
+}
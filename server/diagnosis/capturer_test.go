@@ -0,0 +1,96 @@
+// Copyright 2021 TiKV Project Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package diagnosis
+
+import (
+	"archive/zip"
+	"os"
+	"path/filepath"
+	"sort"
+	"testing"
+	"time"
+
+	. "github.com/pingcap/check"
+	"github.com/tikv/pd/server/config"
+)
+
+func TestDiagnosis(t *testing.T) {
+	TestingT(t)
+}
+
+var _ = Suite(&testCapturerSuite{})
+
+type testCapturerSuite struct{}
+
+func (s *testCapturerSuite) TestCapture(c *C) {
+	dir := c.MkDir()
+	cfg := &config.PDServerConfig{DiagnosisBundleLimit: 2}
+	capturer := NewCapturer(dir, func() *config.PDServerConfig { return cfg },
+		func() interface{} { return cfg }, func() map[string]int { return map[string]int{"waiting-operators": 3} })
+
+	var names []string
+	for i := 0; i < 3; i++ {
+		name, err := capturer.Capture(ManualReason, 10*time.Millisecond)
+		c.Assert(err, IsNil)
+		names = append(names, name)
+	}
+
+	// The oldest bundle is removed beyond the limit.
+	infos, err := capturer.List()
+	c.Assert(err, IsNil)
+	c.Assert(infos, HasLen, 2)
+	c.Assert(infos[0].Name, Equals, names[2])
+	c.Assert(infos[1].Name, Equals, names[1])
+	_, err = capturer.Open(names[0])
+	c.Assert(err, NotNil)
+
+	f, err := capturer.Open(names[2])
+	c.Assert(err, IsNil)
+	fi, err := f.Stat()
+	c.Assert(err, IsNil)
+	zr, err := zip.NewReader(f, fi.Size())
+	c.Assert(err, IsNil)
+	var files []string
+	for _, zf := range zr.File {
+		files = append(files, zf.Name)
+	}
+	sort.Strings(files)
+	c.Assert(files, DeepEquals, []string{"config.json", "cpu.pb.gz", "goroutine.txt", "heap.pb.gz", "queues.json", "reason.txt", "trace.out"})
+	c.Assert(f.Close(), IsNil)
+
+	// The names out of the directory are rejected.
+	c.Assert(os.WriteFile(filepath.Join(dir, "other"), []byte("x"), 0644), IsNil)
+	for _, name := range []string{"other", "../" + names[2], "diagnosis-.zip", "diagnosis-1a.zip"} {
+		c.Assert(IsBundleName(name), IsFalse)
+		_, err = capturer.Open(name)
+		c.Assert(err, NotNil)
+	}
+}
+
+func (s *testCapturerSuite) TestTrigger(c *C) {
+	cfg := &config.PDServerConfig{DiagnosisBundleLimit: 2}
+	capturer := NewCapturer(c.MkDir(), func() *config.PDServerConfig { return cfg },
+		func() interface{} { return cfg }, func() map[string]int { return nil })
+
+	// Nothing is captured if the auto capture is disabled.
+	capturer.ObserveHeartbeat(time.Hour)
+	c.Assert(capturer.lastAuto.IsZero(), IsTrue)
+
+	cfg.DiagnosisAutoCapture = true
+	cfg.DiagnosisSlowHeartbeatThreshold.Duration = time.Second
+	capturer.ObserveHeartbeat(time.Millisecond)
+	c.Assert(capturer.lastAuto.IsZero(), IsTrue)
+	capturer.ObserveHeartbeat(2 * time.Second)
+	c.Assert(capturer.lastAuto.IsZero(), IsFalse)
+}
@@ -64,6 +64,14 @@ func NewLeadership(client *clientv3.Client, leaderKey, purpose string) *Leadersh
 	return leadership
 }
 
+// NewStandaloneLeadership creates a Leadership which is always held, for the
+// standalone PD which has no etcd to campaign. Its lease never expires.
+func NewStandaloneLeadership(leaderKey, purpose string) *Leadership {
+	leadership := NewLeadership(nil, leaderKey, purpose)
+	leadership.setLease(&lease{Purpose: purpose})
+	return leadership
+}
+
 // getLease gets the lease of leadership, only if leadership is valid,
 // i.e the owner is a true leader, the lease is not nil.
 func (ls *Leadership) getLease() *lease {
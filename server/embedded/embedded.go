@@ -0,0 +1,174 @@
+// Copyright 2021 TiKV Project Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package embedded runs a single PD in process, which keeps the metadata in
+// an in-memory kv instead of etcd. It is much lighter than a real PD cluster,
+// so the unit tests and the tools can check against the PD semantics quickly
+// through the Go APIs or the loopback gRPC server.
+package embedded
+
+import (
+	"context"
+	"net"
+	"sync"
+
+	"github.com/pingcap/kvproto/pkg/metapb"
+	"github.com/pingcap/kvproto/pkg/pdpb"
+	"github.com/pingcap/log"
+	"github.com/tikv/pd/pkg/errs"
+	"github.com/tikv/pd/server"
+	"github.com/tikv/pd/server/cluster"
+	"github.com/tikv/pd/server/config"
+	"github.com/tikv/pd/server/core"
+	"github.com/tikv/pd/server/id"
+	"github.com/tikv/pd/server/schedule/hbstream"
+	"github.com/tikv/pd/server/schedule/placement"
+	"google.golang.org/grpc"
+)
+
+// ConfigOption is used to change the config before the server starts.
+type ConfigOption func(cfg *config.Config)
+
+// Server is an in-process PD. It serves the gRPC PDServer of a standalone
+// server.Server, which is the only member and keeps everything in memory, so
+// the services involving multiple members, such as the region syncer and the
+// local TSO, are not available.
+type Server struct {
+	// svr is not embedded, as the methods of server.Server working with etcd
+	// are not available.
+	svr        *server.Server
+	grpcServer *grpc.Server
+	wg         sync.WaitGroup
+}
+
+// NewServer creates and starts an embedded server, whose gRPC server listens
+// on a random port of the loopback address. GetAddr returns its URL, e.g.
+// `http://127.0.0.1:12345`, which can be used as the PD address of the clients.
+func NewServer(ctx context.Context, opts ...ConfigOption) (*Server, error) {
+	cfg := config.NewConfig()
+	cfg.Name = "pd-embedded"
+	for _, opt := range opts {
+		opt(cfg)
+	}
+	if err := cfg.Adjust(nil, false); err != nil {
+		return nil, err
+	}
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		return nil, errs.ErrStartEmbedded.Wrap(err).GenWithStackByCause()
+	}
+	cfg.ClientUrls = "http://" + listener.Addr().String()
+	cfg.AdvertiseClientUrls = cfg.ClientUrls
+
+	svr := server.CreateStandaloneServer(ctx, cfg)
+	s := &Server{
+		svr:        svr,
+		grpcServer: grpc.NewServer(),
+	}
+	pdpb.RegisterPDServer(s.grpcServer, svr)
+	s.wg.Add(1)
+	go func() {
+		defer s.wg.Done()
+		if err := s.grpcServer.Serve(listener); err != nil {
+			log.Error("embedded gRPC server stops serving", errs.ZapError(err))
+		}
+	}()
+	return s, nil
+}
+
+// Close stops the gRPC server and the PD server.
+func (s *Server) Close() {
+	if s.IsClosed() {
+		return
+	}
+	s.grpcServer.Stop()
+	s.wg.Wait()
+	s.svr.Close()
+}
+
+// IsClosed returns whether the server is closed.
+func (s *Server) IsClosed() bool {
+	return s.svr.IsClosed()
+}
+
+// GetAddr returns the URL of the gRPC server.
+func (s *Server) GetAddr() string {
+	return s.svr.GetAddr()
+}
+
+// ClusterID returns the cluster ID.
+func (s *Server) ClusterID() uint64 {
+	return s.svr.ClusterID()
+}
+
+// GetConfig returns the config of the server.
+func (s *Server) GetConfig() *config.Config {
+	return s.svr.GetConfig()
+}
+
+// GetPersistOptions returns the schedule option.
+func (s *Server) GetPersistOptions() *config.PersistOptions {
+	return s.svr.GetPersistOptions()
+}
+
+// GetAllocator returns the ID allocator.
+func (s *Server) GetAllocator() id.Allocator {
+	return s.svr.GetAllocator()
+}
+
+// GetStorage returns the in-memory storage.
+func (s *Server) GetStorage() *core.Storage {
+	return s.svr.GetStorage()
+}
+
+// GetBasicCluster returns the basic cluster.
+func (s *Server) GetBasicCluster() *core.BasicCluster {
+	return s.svr.GetBasicCluster()
+}
+
+// GetHBStreams returns the heartbeat streams.
+func (s *Server) GetHBStreams() *hbstream.HeartbeatStreams {
+	return s.svr.GetHBStreams()
+}
+
+// GetRaftCluster returns the raft cluster, which is nil before the cluster is
+// bootstrapped.
+func (s *Server) GetRaftCluster() *cluster.RaftCluster {
+	return s.svr.GetRaftCluster()
+}
+
+// GetRuleManager returns the rule manager, which is nil before the cluster is
+// bootstrapped.
+func (s *Server) GetRuleManager() *placement.RuleManager {
+	if rc := s.GetRaftCluster(); rc != nil {
+		return rc.GetRuleManager()
+	}
+	return nil
+}
+
+// BootstrapCluster bootstraps the cluster with the first store and region,
+// and starts the raft cluster.
+func (s *Server) BootstrapCluster(store *metapb.Store, region *metapb.Region) error {
+	resp, err := s.svr.Bootstrap(context.Background(), &pdpb.BootstrapRequest{
+		Header: &pdpb.RequestHeader{ClusterId: s.ClusterID()},
+		Store:  store,
+		Region: region,
+	})
+	if err != nil {
+		return err
+	}
+	if resp.GetHeader().GetError().GetType() == pdpb.ErrorType_ALREADY_BOOTSTRAPPED {
+		return errs.ErrAlreadyBootstrapped.FastGenByArgs()
+	}
+	return nil
+}
@@ -0,0 +1,162 @@
+// Copyright 2021 TiKV Project Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package embedded
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	. "github.com/pingcap/check"
+	"github.com/pingcap/kvproto/pkg/metapb"
+	"github.com/pingcap/kvproto/pkg/pdpb"
+	"github.com/tikv/pd/pkg/testutil"
+	"github.com/tikv/pd/pkg/tsoutil"
+	"github.com/tikv/pd/server/config"
+	"go.uber.org/goleak"
+	"google.golang.org/grpc"
+)
+
+func TestEmbedded(t *testing.T) {
+	TestingT(t)
+}
+
+func TestMain(m *testing.M) {
+	goleak.VerifyTestMain(m, testutil.LeakOptions...)
+}
+
+var _ = Suite(&testEmbeddedSuite{})
+
+type testEmbeddedSuite struct {
+	ctx    context.Context
+	cancel context.CancelFunc
+	svr    *Server
+	conn   *grpc.ClientConn
+	client pdpb.PDClient
+}
+
+func (s *testEmbeddedSuite) SetUpTest(c *C) {
+	s.ctx, s.cancel = context.WithCancel(context.Background())
+	var err error
+	s.svr, err = NewServer(s.ctx, func(cfg *config.Config) {
+		cfg.Replication.MaxReplicas = 1
+	})
+	c.Assert(err, IsNil)
+	s.conn, err = grpc.Dial(strings.TrimPrefix(s.svr.GetAddr(), "http://"), grpc.WithInsecure())
+	c.Assert(err, IsNil)
+	s.client = pdpb.NewPDClient(s.conn)
+}
+
+func (s *testEmbeddedSuite) TearDownTest(c *C) {
+	s.conn.Close()
+	s.svr.Close()
+	s.cancel()
+}
+
+func (s *testEmbeddedSuite) header() *pdpb.RequestHeader {
+	return testutil.NewRequestHeader(s.svr.ClusterID())
+}
+
+func (s *testEmbeddedSuite) bootstrap(c *C) {
+	store := &metapb.Store{Id: 1, Address: "mock://tikv-1", Version: "5.0.0"}
+	region := &metapb.Region{Id: 2, Peers: []*metapb.Peer{{Id: 3, StoreId: 1}}, RegionEpoch: &metapb.RegionEpoch{ConfVer: 1, Version: 1}}
+	resp, err := s.client.Bootstrap(s.ctx, &pdpb.BootstrapRequest{Header: s.header(), Store: store, Region: region})
+	c.Assert(err, IsNil)
+	c.Assert(resp.GetHeader().GetError(), IsNil)
+}
+
+func (s *testEmbeddedSuite) TestBootstrap(c *C) {
+	members, err := s.client.GetMembers(s.ctx, &pdpb.GetMembersRequest{})
+	c.Assert(err, IsNil)
+	c.Assert(members.GetHeader().GetClusterId(), Equals, s.svr.ClusterID())
+	c.Assert(members.GetLeader().GetClientUrls(), DeepEquals, []string{s.svr.GetAddr()})
+
+	resp, err := s.client.IsBootstrapped(s.ctx, &pdpb.IsBootstrappedRequest{Header: s.header()})
+	c.Assert(err, IsNil)
+	c.Assert(resp.GetBootstrapped(), IsFalse)
+	c.Assert(s.svr.GetRaftCluster(), IsNil)
+	c.Assert(s.svr.GetRuleManager(), IsNil)
+
+	s.bootstrap(c)
+	resp, err = s.client.IsBootstrapped(s.ctx, &pdpb.IsBootstrappedRequest{Header: s.header()})
+	c.Assert(err, IsNil)
+	c.Assert(resp.GetBootstrapped(), IsTrue)
+	c.Assert(s.svr.GetRaftCluster(), NotNil)
+	c.Assert(s.svr.GetRuleManager().GetAllRules(), HasLen, 1)
+
+	bootstrapResp, err := s.client.Bootstrap(s.ctx, &pdpb.BootstrapRequest{Header: s.header()})
+	c.Assert(err, IsNil)
+	c.Assert(bootstrapResp.GetHeader().GetError().GetType(), Equals, pdpb.ErrorType_ALREADY_BOOTSTRAPPED)
+
+	// The cluster ID is checked.
+	_, err = s.client.AllocID(s.ctx, &pdpb.AllocIDRequest{Header: testutil.NewRequestHeader(s.svr.ClusterID() + 1)})
+	c.Assert(err, NotNil)
+}
+
+func (s *testEmbeddedSuite) TestHeartbeat(c *C) {
+	s.bootstrap(c)
+	rc := s.svr.GetRaftCluster()
+	_, err := s.client.PutStore(s.ctx, &pdpb.PutStoreRequest{
+		Header: s.header(),
+		Store:  &metapb.Store{Id: 4, Address: "mock://tikv-4", Version: "5.0.0"},
+	})
+	c.Assert(err, IsNil)
+	stores, err := s.client.GetAllStores(s.ctx, &pdpb.GetAllStoresRequest{Header: s.header()})
+	c.Assert(err, IsNil)
+	c.Assert(stores.GetStores(), HasLen, 2)
+
+	stream, err := s.client.RegionHeartbeat(s.ctx)
+	c.Assert(err, IsNil)
+	leader := &metapb.Peer{Id: 3, StoreId: 1}
+	region := &metapb.Region{
+		Id:          2,
+		StartKey:    []byte(""),
+		EndKey:      []byte("b"),
+		Peers:       []*metapb.Peer{leader},
+		RegionEpoch: &metapb.RegionEpoch{ConfVer: 1, Version: 2},
+	}
+	c.Assert(stream.Send(&pdpb.RegionHeartbeatRequest{Header: s.header(), Region: region, Leader: leader}), IsNil)
+	testutil.WaitUntil(c, func(c *C) bool {
+		resp, err := s.client.GetRegion(s.ctx, &pdpb.GetRegionRequest{Header: s.header(), RegionKey: []byte("a")})
+		c.Assert(err, IsNil)
+		return resp.GetRegion().GetRegionEpoch().GetVersion() == 2
+	})
+	c.Assert(rc.GetRegion(2).GetEndKey(), DeepEquals, []byte("b"))
+	c.Assert(stream.CloseSend(), IsNil)
+
+	split, err := s.client.AskBatchSplit(s.ctx, &pdpb.AskBatchSplitRequest{Header: s.header(), Region: region, SplitCount: 2})
+	c.Assert(err, IsNil)
+	c.Assert(split.GetHeader().GetError(), IsNil)
+	c.Assert(split.GetIds(), HasLen, 2)
+}
+
+func (s *testEmbeddedSuite) TestTso(c *C) {
+	stream, err := s.client.Tso(s.ctx)
+	c.Assert(err, IsNil)
+	defer stream.CloseSend()
+	var last uint64
+	for i := 0; i < 100; i++ {
+		c.Assert(stream.Send(&pdpb.TsoRequest{Header: s.header(), Count: 10000}), IsNil)
+		resp, err := stream.Recv()
+		c.Assert(err, IsNil)
+		c.Assert(resp.GetCount(), Equals, uint32(10000))
+		ts := tsoutil.GenerateTS(resp.GetTimestamp())
+		// The first timestamp of the batch is after the last one.
+		c.Assert(ts-10000+1 > last, IsTrue)
+		last = ts
+	}
+	c.Assert(stream.Send(&pdpb.TsoRequest{Header: s.header(), Count: 0}), IsNil)
+	_, err = stream.Recv()
+	c.Assert(err, NotNil)
+}
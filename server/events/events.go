@@ -0,0 +1,225 @@
+// Copyright 2021 TiKV Project Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package events
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/pingcap/log"
+	"github.com/tikv/pd/pkg/errs"
+	"github.com/tikv/pd/server/core"
+	"go.uber.org/zap"
+)
+
+const (
+	eventsPath = "events"
+	// DefaultCapacity is the default number of events kept by the bus.
+	DefaultCapacity = 1024
+	watchChanSize   = 256
+	persistChanSize = 1024
+)
+
+// Type is the type of a cluster lifecycle event.
+type Type string
+
+const (
+	// StoreStateChanged is published when a store changes its state, e.g. Up -> Offline -> Tombstone.
+	StoreStateChanged Type = "store-state-changed"
+	// LeaderChanged is published when a PD member becomes the leader.
+	LeaderChanged Type = "leader-changed"
+	// RuleChanged is published when placement rules or rule groups are changed.
+	RuleChanged Type = "rule-changed"
+	// AlertFired is published when the condition of an alert rule holds for
+	// its duration.
+	AlertFired Type = "alert-fired"
+	// AlertResolved is published when the condition of a fired alert rule
+	// does not hold.
+	AlertResolved Type = "alert-resolved"
+)
+
+// Event is a cluster lifecycle event.
+type Event struct {
+	ID      uint64    `json:"id"`
+	Type    Type      `json:"type"`
+	Time    time.Time `json:"time"`
+	StoreID uint64    `json:"store_id,omitempty"`
+	Message string    `json:"message"`
+}
+
+// Bus records the cluster lifecycle events in a ring buffer which is persisted
+// in the storage, so that external systems can follow the events without
+// parsing the logs. The event IDs are monotonically increasing.
+type Bus struct {
+	sync.RWMutex
+	storage  *core.Storage
+	capacity int
+	events   []*Event
+	nextID   uint64
+	watchers map[chan *Event]struct{}
+	// persistCh passes the events to the persisting goroutine, so that the
+	// publishers don't wait for the storage in their critical sections.
+	persistCh chan *Event
+}
+
+// NewBus creates a Bus which keeps at most capacity events.
+func NewBus(storage *core.Storage, capacity int) *Bus {
+	return &Bus{
+		storage:   storage,
+		capacity:  capacity,
+		nextID:    1,
+		watchers:  make(map[chan *Event]struct{}),
+		persistCh: make(chan *Event, persistChanSize),
+	}
+}
+
+// Run persists the published events until quit is closed. The events which
+// are still pending when quit is closed are persisted before it returns.
+func (b *Bus) Run(quit <-chan struct{}) {
+	for {
+		select {
+		case event := <-b.persistCh:
+			b.persist(event)
+		case <-quit:
+			for {
+				select {
+				case event := <-b.persistCh:
+					b.persist(event)
+				default:
+					return
+				}
+			}
+		}
+	}
+}
+
+func (b *Bus) persist(event *Event) {
+	if err := b.storage.SaveJSON(eventsPath, b.slotKey(event.ID), event); err != nil {
+		log.Warn("failed to persist event", zap.Uint64("id", event.ID), zap.String("type", string(event.Type)), errs.ZapError(err))
+	}
+}
+
+// Load loads the persisted events from the storage.
+func (b *Bus) Load() error {
+	b.Lock()
+	defer b.Unlock()
+	var events []*Event
+	var err error
+	if e := b.storage.LoadRangeByPrefix(eventsPath+"/", func(k, v string) {
+		event := &Event{}
+		if e := json.Unmarshal([]byte(v), event); e != nil {
+			err = errs.ErrJSONUnmarshal.Wrap(e).FastGenWithCause()
+			return
+		}
+		events = append(events, event)
+	}); e != nil {
+		return e
+	}
+	if err != nil {
+		return err
+	}
+	sort.Slice(events, func(i, j int) bool { return events[i].ID < events[j].ID })
+	if len(events) > b.capacity {
+		events = events[len(events)-b.capacity:]
+	}
+	b.events = events
+	if len(events) > 0 {
+		b.nextID = events[len(events)-1].ID + 1
+	}
+	return nil
+}
+
+// slotKey returns the key of the ring buffer slot the event is saved to.
+func (b *Bus) slotKey(id uint64) string {
+	return fmt.Sprintf("%020d", id%uint64(b.capacity))
+}
+
+// Publish records an event and notifies the watchers. The event is persisted
+// asynchronously, and it is still recorded in memory if it fails to be persisted.
+func (b *Bus) Publish(typ Type, storeID uint64, message string) {
+	b.Lock()
+	defer b.Unlock()
+	event := &Event{
+		ID:      b.nextID,
+		Type:    typ,
+		Time:    time.Now(),
+		StoreID: storeID,
+		Message: message,
+	}
+	b.nextID++
+	b.events = append(b.events, event)
+	if len(b.events) > b.capacity {
+		b.events = b.events[len(b.events)-b.capacity:]
+	}
+	select {
+	case b.persistCh <- event:
+	default:
+		log.Warn("too many events are waiting to be persisted, skip persisting", zap.Uint64("id", event.ID), zap.String("type", string(typ)))
+	}
+	for ch := range b.watchers {
+		select {
+		case ch <- event:
+		default:
+			// The watcher is too slow, close it and let it watch again from
+			// the last event it received.
+			delete(b.watchers, ch)
+			close(ch)
+		}
+	}
+}
+
+// Since returns the events whose ID is greater than the given one.
+func (b *Bus) Since(id uint64) []*Event {
+	b.RLock()
+	defer b.RUnlock()
+	return b.sinceLocked(id)
+}
+
+func (b *Bus) sinceLocked(id uint64) []*Event {
+	i := sort.Search(len(b.events), func(i int) bool { return b.events[i].ID > id })
+	events := make([]*Event, 0, len(b.events)-i)
+	for _, e := range b.events[i:] {
+		event := *e
+		events = append(events, &event)
+	}
+	return events
+}
+
+// Watch returns a channel which receives the events whose ID is greater than
+// the given one, including the events published later. The channel is closed
+// when the context is done or the watcher cannot keep up with the events.
+func (b *Bus) Watch(ctx context.Context, since uint64) <-chan *Event {
+	b.Lock()
+	defer b.Unlock()
+	history := b.sinceLocked(since)
+	ch := make(chan *Event, len(history)+watchChanSize)
+	for _, e := range history {
+		ch <- e
+	}
+	b.watchers[ch] = struct{}{}
+	go func() {
+		<-ctx.Done()
+		b.Lock()
+		defer b.Unlock()
+		if _, ok := b.watchers[ch]; ok {
+			delete(b.watchers, ch)
+			close(ch)
+		}
+	}()
+	return ch
+}
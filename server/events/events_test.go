@@ -0,0 +1,152 @@
+// Copyright 2021 TiKV Project Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package events
+
+import (
+	"context"
+	"net"
+	"sync/atomic"
+	"testing"
+
+	. "github.com/pingcap/check"
+	"github.com/tikv/pd/server/core"
+	"github.com/tikv/pd/server/kv"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func TestEvents(t *testing.T) {
+	TestingT(t)
+}
+
+var _ = Suite(&testEventsSuite{})
+
+type testEventsSuite struct{}
+
+func (s *testEventsSuite) TestRingBuffer(c *C) {
+	storage := core.NewStorage(kv.NewMemoryKV())
+	bus := NewBus(storage, 3)
+	quit := make(chan struct{})
+	done := make(chan struct{})
+	go func() {
+		bus.Run(quit)
+		close(done)
+	}()
+	for i := uint64(1); i <= 5; i++ {
+		bus.Publish(StoreStateChanged, i, "store is offline")
+	}
+	events := bus.Since(0)
+	c.Assert(events, HasLen, 3)
+	c.Assert(events[0].ID, Equals, uint64(3))
+	c.Assert(events[2].StoreID, Equals, uint64(5))
+	c.Assert(bus.Since(4), HasLen, 1)
+	c.Assert(bus.Since(5), HasLen, 0)
+
+	// The pending events are persisted before Run returns.
+	close(quit)
+	<-done
+
+	// The persisted ring buffer only keeps the latest events.
+	bus = NewBus(storage, 3)
+	c.Assert(bus.Load(), IsNil)
+	events = bus.Since(0)
+	c.Assert(events, HasLen, 3)
+	c.Assert(events[0].ID, Equals, uint64(3))
+	bus.Publish(RuleChanged, 0, "rule changed")
+	c.Assert(bus.Since(5)[0].ID, Equals, uint64(6))
+}
+
+func (s *testEventsSuite) TestWatch(c *C) {
+	bus := NewBus(core.NewStorage(kv.NewMemoryKV()), DefaultCapacity)
+	bus.Publish(LeaderChanged, 0, "pd1 becomes the leader")
+	bus.Publish(StoreStateChanged, 1, "store is offline")
+
+	ctx, cancel := context.WithCancel(context.Background())
+	ch := bus.Watch(ctx, 1)
+	c.Assert((<-ch).ID, Equals, uint64(2))
+	bus.Publish(StoreStateChanged, 1, "store is tombstone")
+	c.Assert((<-ch).ID, Equals, uint64(3))
+	cancel()
+	_, ok := <-ch
+	c.Assert(ok, IsFalse)
+
+	// A slow watcher is closed.
+	ch = bus.Watch(context.Background(), 3)
+	for i := 0; i <= watchChanSize; i++ {
+		bus.Publish(RuleChanged, 0, "rule changed")
+	}
+	var received int
+	for range ch {
+		received++
+	}
+	c.Assert(received, Equals, watchChanSize)
+	bus.RLock()
+	c.Assert(bus.watchers, HasLen, 0)
+	bus.RUnlock()
+}
+
+func (s *testEventsSuite) TestGRPCWatch(c *C) {
+	bus := NewBus(core.NewStorage(kv.NewMemoryKV()), DefaultCapacity)
+	bus.Publish(LeaderChanged, 0, "pd1 becomes the leader")
+	var available, denied atomic.Value
+	available.Store(false)
+	denied.Store(false)
+	gs := grpc.NewServer()
+	RegisterWatchService(gs, NewWatchService(func() *Bus {
+		if available.Load().(bool) {
+			return bus
+		}
+		return nil
+	}, func(context.Context) error {
+		if denied.Load().(bool) {
+			return status.Error(codes.PermissionDenied, "denied")
+		}
+		return nil
+	}))
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	c.Assert(err, IsNil)
+	go gs.Serve(l)
+	defer gs.Stop()
+	cc, err := grpc.Dial(l.Addr().String(), grpc.WithInsecure())
+	c.Assert(err, IsNil)
+	defer cc.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	client, err := NewWatchClient(ctx, cc, 0)
+	c.Assert(err, IsNil)
+	_, err = client.Recv()
+	c.Assert(status.Code(err), Equals, codes.Unavailable)
+
+	available.Store(true)
+	client, err = NewWatchClient(ctx, cc, 0)
+	c.Assert(err, IsNil)
+	event, err := client.Recv()
+	c.Assert(err, IsNil)
+	c.Assert(event.ID, Equals, uint64(1))
+	c.Assert(event.Type, Equals, LeaderChanged)
+	bus.Publish(StoreStateChanged, 1, "store is offline")
+	event, err = client.Recv()
+	c.Assert(err, IsNil)
+	c.Assert(event.ID, Equals, uint64(2))
+	c.Assert(event.StoreID, Equals, uint64(1))
+
+	// The watcher is rejected if it is not authorized.
+	denied.Store(true)
+	client, err = NewWatchClient(ctx, cc, 0)
+	c.Assert(err, IsNil)
+	_, err = client.Recv()
+	c.Assert(status.Code(err), Equals, codes.PermissionDenied)
+}
@@ -0,0 +1,134 @@
+// Copyright 2021 TiKV Project Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package events
+
+import (
+	"context"
+	"encoding/json"
+
+	"github.com/gogo/protobuf/types"
+	"github.com/tikv/pd/pkg/errs"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// The watch service is defined without a proto file: the request carries the
+// ID of the last received event and each response carries a JSON encoded Event,
+// which keeps the events consistent with the HTTP API.
+const (
+	watchServiceName = "pd.events.Events"
+	watchMethod      = "/" + watchServiceName + "/Watch"
+)
+
+type watchServer interface {
+	watch(since uint64, stream grpc.ServerStream) error
+}
+
+var watchServiceDesc = grpc.ServiceDesc{
+	ServiceName: watchServiceName,
+	HandlerType: (*watchServer)(nil),
+	Streams: []grpc.StreamDesc{{
+		StreamName:    "Watch",
+		Handler:       watchHandler,
+		ServerStreams: true,
+	}},
+	Metadata: "events",
+}
+
+// WatchService serves the gRPC watch of the events.
+type WatchService struct {
+	getBus    func() *Bus
+	authorize func(context.Context) error
+}
+
+// NewWatchService creates a WatchService. getBus returns nil if the events are
+// not available, e.g. the PD server is not the leader. authorize returns an
+// error if the watcher is not allowed to read the events, and it is not
+// checked if nil.
+func NewWatchService(getBus func() *Bus, authorize func(context.Context) error) *WatchService {
+	return &WatchService{getBus: getBus, authorize: authorize}
+}
+
+// RegisterWatchService registers the watch service to the gRPC server.
+func RegisterWatchService(s *grpc.Server, srv *WatchService) {
+	s.RegisterService(&watchServiceDesc, srv)
+}
+
+func watchHandler(srv interface{}, stream grpc.ServerStream) error {
+	req := &types.UInt64Value{}
+	if err := stream.RecvMsg(req); err != nil {
+		return err
+	}
+	return srv.(watchServer).watch(req.GetValue(), stream)
+}
+
+func (s *WatchService) watch(since uint64, stream grpc.ServerStream) error {
+	if s.authorize != nil {
+		if err := s.authorize(stream.Context()); err != nil {
+			return err
+		}
+	}
+	bus := s.getBus()
+	if bus == nil {
+		return status.Errorf(codes.Unavailable, "events are not available, the server may not be the leader")
+	}
+	ctx := stream.Context()
+	for event := range bus.Watch(ctx, since) {
+		data, err := json.Marshal(event)
+		if err != nil {
+			return status.Errorf(codes.Internal, errs.ErrJSONMarshal.Wrap(err).FastGenWithCause().Error())
+		}
+		if err := stream.SendMsg(&types.BytesValue{Value: data}); err != nil {
+			return err
+		}
+	}
+	if ctx.Err() != nil {
+		return ctx.Err()
+	}
+	return status.Errorf(codes.ResourceExhausted, "the watcher falls behind, please watch again from the last received event")
+}
+
+// WatchClient receives the events from the gRPC watch.
+type WatchClient struct {
+	stream grpc.ClientStream
+}
+
+// NewWatchClient starts to watch the events whose ID is greater than since.
+func NewWatchClient(ctx context.Context, cc *grpc.ClientConn, since uint64) (*WatchClient, error) {
+	stream, err := cc.NewStream(ctx, &watchServiceDesc.Streams[0], watchMethod)
+	if err != nil {
+		return nil, err
+	}
+	if err := stream.SendMsg(&types.UInt64Value{Value: since}); err != nil {
+		return nil, err
+	}
+	if err := stream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return &WatchClient{stream: stream}, nil
+}
+
+// Recv receives the next event.
+func (c *WatchClient) Recv() (*Event, error) {
+	resp := &types.BytesValue{}
+	if err := c.stream.RecvMsg(resp); err != nil {
+		return nil, err
+	}
+	event := &Event{}
+	if err := json.Unmarshal(resp.GetValue(), event); err != nil {
+		return nil, errs.ErrJSONUnmarshal.Wrap(err).FastGenWithCause()
+	}
+	return event, nil
+}
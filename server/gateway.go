@@ -0,0 +1,88 @@
+// Copyright 2021 TiKV Project Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package server
+
+import (
+	"sync"
+	"time"
+
+	"github.com/pingcap/log"
+	"github.com/tikv/pd/pkg/errs"
+	"github.com/tikv/pd/pkg/logutil"
+	"github.com/tikv/pd/server/config"
+)
+
+// gatewayConfigReloadInterval is the interval for a follower to reload the PD
+// server config from the storage for the follower gateway.
+const gatewayConfigReloadInterval = 10 * time.Second
+
+type gatewayConfigCache struct {
+	sync.RWMutex
+	cfg *config.PDServerConfig
+}
+
+// GetGatewayConfig returns the PD server config for the follower gateway. The
+// persisted options are only reloaded when a member becomes the leader, so a
+// follower uses the config reloaded by gatewayConfigLoop to see the changes
+// made on the leader.
+func (s *Server) GetGatewayConfig() *config.PDServerConfig {
+	if s.member.IsLeader() {
+		return s.persistOptions.GetPDServerConfig()
+	}
+	c := &s.gatewayConfig
+	c.RLock()
+	defer c.RUnlock()
+	if c.cfg == nil {
+		return s.persistOptions.GetPDServerConfig()
+	}
+	return c.cfg
+}
+
+// gatewayConfigLoop reloads the PD server config from the storage periodically
+// for the follower gateway, so the requests do not wait for the storage.
+func (s *Server) gatewayConfigLoop() {
+	defer logutil.LogPanic()
+	defer s.serverLoopWg.Done()
+
+	ticker := time.NewTicker(gatewayConfigReloadInterval)
+	defer ticker.Stop()
+	for {
+		if !s.IsClosed() && !s.member.IsLeader() {
+			s.reloadGatewayConfig()
+		}
+		select {
+		case <-ticker.C:
+		case <-s.serverLoopCtx.Done():
+			log.Info("server is closed, exit gateway config loop")
+			return
+		}
+	}
+}
+
+func (s *Server) reloadGatewayConfig() {
+	cfg := &config.Config{}
+	cfg.Adjust(nil, true)
+	isExist, err := s.storage.LoadConfig(cfg)
+	if err != nil {
+		log.Warn("failed to load the config for the follower gateway", errs.ZapError(err))
+		return
+	}
+	if !isExist {
+		return
+	}
+	c := &s.gatewayConfig
+	c.Lock()
+	defer c.Unlock()
+	c.cfg = &cfg.PDServerCfg
+}
@@ -15,6 +15,7 @@ package server
 
 import (
 	"context"
+	"encoding/json"
 	"fmt"
 	"io"
 	"math"
@@ -33,6 +34,7 @@ import (
 	"github.com/tikv/pd/pkg/tsoutil"
 	"github.com/tikv/pd/server/cluster"
 	"github.com/tikv/pd/server/core"
+	"github.com/tikv/pd/server/rbac"
 	"github.com/tikv/pd/server/tso"
 	"github.com/tikv/pd/server/versioninfo"
 	"go.uber.org/zap"
@@ -57,7 +59,7 @@ func (s *Server) GetMembers(context.Context, *pdpb.GetMembersRequest) (*pdpb.Get
 	if s.IsClosed() {
 		return nil, status.Errorf(codes.Unknown, "server not started")
 	}
-	members, err := cluster.GetMembers(s.GetClient())
+	members, err := s.listMembers()
 	if err != nil {
 		return nil, status.Errorf(codes.Unknown, err.Error())
 	}
@@ -183,6 +185,9 @@ func (s *Server) Tso(stream pdpb.PD_TsoServer) error {
 
 // Bootstrap implements gRPC PDServer.
 func (s *Server) Bootstrap(ctx context.Context, request *pdpb.BootstrapRequest) (*pdpb.BootstrapResponse, error) {
+	if err := s.checkRole(ctx, rbac.RoleAdmin); err != nil {
+		return nil, err
+	}
 	forwardedHost := getForwardedHost(ctx)
 	if !s.isLocalRequest(forwardedHost) {
 		client, err := s.getDelegateClient(ctx, forwardedHost)
@@ -349,9 +354,19 @@ func (s *Server) PutStore(ctx context.Context, request *pdpb.PutStoreRequest) (*
 		return nil, status.Errorf(codes.FailedPrecondition, "placement rules is disabled")
 	}
 
-	if err := rc.PutStore(store); err != nil {
+	token, err := rc.PutStoreWithFencingToken(store, getStoreFencingToken(ctx))
+	if err != nil {
+		if errs.ErrStoreFencingTokenMismatch.Equal(err) || errs.ErrStoreFencingTokenMissing.Equal(err) {
+			return nil, status.Errorf(codes.PermissionDenied, err.Error())
+		}
 		return nil, status.Errorf(codes.Unknown, err.Error())
 	}
+	// The token is only sent when it is issued.
+	if token != "" {
+		if err := grpc.SetHeader(ctx, metadata.Pairs(grpcutil.FencingTokenMetadataKey, token)); err != nil {
+			log.Warn("failed to send the fencing token", zap.Uint64("store-id", store.GetId()), errs.ZapError(err))
+		}
+	}
 
 	log.Info("put store ok", zap.Stringer("store", store))
 	CheckPDVersion(s.persistOptions)
@@ -440,6 +455,11 @@ func (s *Server) StoreHeartbeat(ctx context.Context, request *pdpb.StoreHeartbea
 		return nil, errors.Errorf("store %v not found", storeID)
 	}
 
+	if err := rc.CheckStoreFencingToken(storeID, getStoreFencingToken(ctx)); err != nil {
+		return nil, status.Errorf(codes.PermissionDenied, err.Error())
+	}
+
+	s.heartbeatRecorder.RecordStoreHeartbeat(request)
 	storeAddress := store.GetAddress()
 	storeLabel := strconv.FormatUint(storeID, 10)
 	start := time.Now()
@@ -451,6 +471,29 @@ func (s *Server) StoreHeartbeat(ctx context.Context, request *pdpb.StoreHeartbea
 
 	storeHeartbeatHandleDuration.WithLabelValues(storeAddress, storeLabel).Observe(time.Since(start).Seconds())
 
+	ackedVersion, acked, err := getStalePeersAck(ctx)
+	if err != nil {
+		return nil, status.Errorf(codes.InvalidArgument, err.Error())
+	}
+	if publish := rc.SyncStalePeers(storeID, ackedVersion, acked); publish != nil {
+		data, err := json.Marshal(publish)
+		if err != nil {
+			return nil, status.Errorf(codes.Unknown, err.Error())
+		}
+		if err := grpc.SetHeader(ctx, metadata.Pairs(grpcutil.StalePeersMetadataKey, string(data))); err != nil {
+			log.Warn("failed to publish the stale peers", zap.Uint64("store-id", storeID), errs.ZapError(err))
+		}
+	}
+	if hints := rc.GetOperatorController().GetSnapshotHints(storeID); len(hints) > 0 {
+		data, err := json.Marshal(hints)
+		if err != nil {
+			return nil, status.Errorf(codes.Unknown, err.Error())
+		}
+		if err := grpc.SetHeader(ctx, metadata.Pairs(grpcutil.SnapshotHintsMetadataKey, string(data))); err != nil {
+			log.Warn("failed to publish the snapshot hints", zap.Uint64("store-id", storeID), errs.ZapError(err))
+		}
+	}
+
 	return &pdpb.StoreHeartbeatResponse{
 		Header:            s.header(),
 		ReplicationStatus: rc.GetReplicationMode().GetReplicationStatus(),
@@ -613,16 +656,24 @@ func (s *Server) RegionHeartbeat(stream pdpb.PD_RegionHeartbeatServer) error {
 			s.hbStreams.SendErr(pdpb.ErrorType_UNKNOWN, msg, request.GetLeader())
 			continue
 		}
+		s.heartbeatRecorder.RecordRegionHeartbeat(request)
+		release, err := s.degradationController.AcquireHeartbeat(stream.Context())
+		if err != nil {
+			return errors.WithStack(err)
+		}
 		start := time.Now()
 
 		err = rc.HandleRegionHeartbeat(region)
+		release()
 		if err != nil {
 			regionHeartbeatCounter.WithLabelValues(storeAddress, storeLabel, "report", "err").Inc()
 			msg := err.Error()
 			s.hbStreams.SendErr(pdpb.ErrorType_UNKNOWN, msg, request.GetLeader())
 			continue
 		}
-		regionHeartbeatHandleDuration.WithLabelValues(storeAddress, storeLabel).Observe(time.Since(start).Seconds())
+		handleDuration := time.Since(start)
+		regionHeartbeatHandleDuration.WithLabelValues(storeAddress, storeLabel).Observe(handleDuration.Seconds())
+		s.diagnosisCapturer.ObserveHeartbeat(handleDuration)
 		regionHeartbeatCounter.WithLabelValues(storeAddress, storeLabel, "report", "ok").Inc()
 	}
 }
@@ -651,6 +702,7 @@ func (s *Server) GetRegion(ctx context.Context, request *pdpb.GetRegionRequest)
 	if region == nil {
 		return &pdpb.GetRegionResponse{Header: s.header()}, nil
 	}
+	sendRoutingHint(ctx, rc, region)
 	return &pdpb.GetRegionResponse{
 		Header:       s.header(),
 		Region:       region.GetMeta(),
@@ -685,6 +737,7 @@ func (s *Server) GetPrevRegion(ctx context.Context, request *pdpb.GetRegionReque
 	if region == nil {
 		return &pdpb.GetRegionResponse{Header: s.header()}, nil
 	}
+	sendRoutingHint(ctx, rc, region)
 	return &pdpb.GetRegionResponse{
 		Header:       s.header(),
 		Region:       region.GetMeta(),
@@ -718,6 +771,7 @@ func (s *Server) GetRegionByID(ctx context.Context, request *pdpb.GetRegionByIDR
 	if region == nil {
 		return &pdpb.GetRegionResponse{Header: s.header()}, nil
 	}
+	sendRoutingHint(ctx, rc, region)
 	return &pdpb.GetRegionResponse{
 		Header:       s.header(),
 		Region:       region.GetMeta(),
@@ -936,6 +990,9 @@ func (s *Server) GetClusterConfig(ctx context.Context, request *pdpb.GetClusterC
 
 // PutClusterConfig implements gRPC PDServer.
 func (s *Server) PutClusterConfig(ctx context.Context, request *pdpb.PutClusterConfigRequest) (*pdpb.PutClusterConfigResponse, error) {
+	if err := s.checkRole(ctx, rbac.RoleAdmin); err != nil {
+		return nil, err
+	}
 	forwardedHost := getForwardedHost(ctx)
 	if !s.isLocalRequest(forwardedHost) {
 		client, err := s.getDelegateClient(ctx, forwardedHost)
@@ -968,6 +1025,9 @@ func (s *Server) PutClusterConfig(ctx context.Context, request *pdpb.PutClusterC
 
 // ScatterRegion implements gRPC PDServer.
 func (s *Server) ScatterRegion(ctx context.Context, request *pdpb.ScatterRegionRequest) (*pdpb.ScatterRegionResponse, error) {
+	if err := s.checkRole(ctx, rbac.RoleOperator); err != nil {
+		return nil, err
+	}
 	forwardedHost := getForwardedHost(ctx)
 	if !s.isLocalRequest(forwardedHost) {
 		client, err := s.getDelegateClient(ctx, forwardedHost)
@@ -1080,6 +1140,9 @@ func (s *Server) SyncRegions(stream pdpb.PD_SyncRegionsServer) error {
 
 // UpdateGCSafePoint implements gRPC PDServer.
 func (s *Server) UpdateGCSafePoint(ctx context.Context, request *pdpb.UpdateGCSafePointRequest) (*pdpb.UpdateGCSafePointResponse, error) {
+	if err := s.checkRole(ctx, rbac.RoleOperator); err != nil {
+		return nil, err
+	}
 	forwardedHost := getForwardedHost(ctx)
 	if !s.isLocalRequest(forwardedHost) {
 		client, err := s.getDelegateClient(ctx, forwardedHost)
@@ -1128,6 +1191,9 @@ func (s *Server) UpdateGCSafePoint(ctx context.Context, request *pdpb.UpdateGCSa
 
 // UpdateServiceGCSafePoint update the safepoint for specific service
 func (s *Server) UpdateServiceGCSafePoint(ctx context.Context, request *pdpb.UpdateServiceGCSafePointRequest) (*pdpb.UpdateServiceGCSafePointResponse, error) {
+	if err := s.checkRole(ctx, rbac.RoleOperator); err != nil {
+		return nil, err
+	}
 	s.serviceSafePointLock.Lock()
 	defer s.serviceSafePointLock.Unlock()
 
@@ -1365,6 +1431,9 @@ func (s *Server) SyncMaxTS(ctx context.Context, request *pdpb.SyncMaxTSRequest)
 
 // SplitRegions split regions by the given split keys
 func (s *Server) SplitRegions(ctx context.Context, request *pdpb.SplitRegionsRequest) (*pdpb.SplitRegionsResponse, error) {
+	if err := s.checkRole(ctx, rbac.RoleOperator); err != nil {
+		return nil, err
+	}
 	forwardedHost := getForwardedHost(ctx)
 	if !s.isLocalRequest(forwardedHost) {
 		client, err := s.getDelegateClient(ctx, forwardedHost)
@@ -1438,11 +1507,7 @@ func (s *Server) validateInternalRequest(header *pdpb.RequestHeader, onlyAllowLe
 func (s *Server) getDelegateClient(ctx context.Context, forwardedHost string) (*grpc.ClientConn, error) {
 	client, ok := s.clientConns.Load(forwardedHost)
 	if !ok {
-		tlsConfig, err := s.GetTLSConfig().ToTLSConfig()
-		if err != nil {
-			return nil, err
-		}
-		cc, err := grpcutil.GetClientConn(ctx, forwardedHost, tlsConfig)
+		cc, err := grpcutil.GetClientConn(ctx, forwardedHost, s.GetClientTLSConfig())
 		if err != nil {
 			return nil, err
 		}
@@ -1463,6 +1528,53 @@ func getForwardedHost(ctx context.Context) string {
 	return ""
 }
 
+// getStalePeersAck returns the latest version of the removed peers
+// acknowledged by the store, and whether the store acknowledges them.
+func getStalePeersAck(ctx context.Context) (uint64, bool, error) {
+	md, ok := metadata.FromIncomingContext(ctx)
+	if !ok {
+		return 0, false, nil
+	}
+	v := md.Get(grpcutil.StalePeersAckMetadataKey)
+	if len(v) == 0 {
+		return 0, false, nil
+	}
+	version, err := strconv.ParseUint(v[0], 10, 64)
+	if err != nil {
+		return 0, false, errs.ErrStrconvParseUint.Wrap(err).GenWithStackByCause()
+	}
+	return version, true, nil
+}
+
+// sendRoutingHint sends the routing hint of the region in the header of the
+// response if its neighbors are split or merged recently.
+func sendRoutingHint(ctx context.Context, rc *cluster.RaftCluster, region *core.RegionInfo) {
+	hint := rc.GetRoutingHint(region)
+	if hint == nil {
+		return
+	}
+	data, err := json.Marshal(hint)
+	if err != nil {
+		log.Warn("failed to encode the routing hint", zap.Uint64("region-id", region.GetID()), errs.ZapError(err))
+		return
+	}
+	if err := grpc.SetHeader(ctx, metadata.Pairs(grpcutil.RoutingHintMetadataKey, string(data))); err != nil {
+		log.Warn("failed to send the routing hint", zap.Uint64("region-id", region.GetID()), errs.ZapError(err))
+	}
+}
+
+// getStoreFencingToken returns the fencing token presented by the store.
+func getStoreFencingToken(ctx context.Context) string {
+	md, ok := metadata.FromIncomingContext(ctx)
+	if !ok {
+		return ""
+	}
+	if t := md.Get(grpcutil.FencingTokenMetadataKey); len(t) > 0 {
+		return t[0]
+	}
+	return ""
+}
+
 func (s *Server) isLocalRequest(forwardedHost string) bool {
 	if forwardedHost == "" {
 		return true
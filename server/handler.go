@@ -240,6 +240,69 @@ func (h *Handler) PauseOrResumeScheduler(name string, t int64) error {
 	return err
 }
 
+// CancelSchedulerOperators cancels the operators created by a scheduler, or
+// by all the schedulers if the name is "all", and returns the number of them.
+func (h *Handler) CancelSchedulerOperators(name string) (int, error) {
+	c, err := h.GetRaftCluster()
+	if err != nil {
+		return 0, err
+	}
+	if name != "all" {
+		return c.CancelSchedulerOperators(name), nil
+	}
+	canceled := 0
+	for _, scheduler := range c.GetSchedulers() {
+		canceled += c.CancelSchedulerOperators(scheduler)
+	}
+	return canceled, nil
+}
+
+// SetSchedulerWindow sets the daily window in which a scheduler is allowed to
+// schedule. An empty window removes the limit.
+func (h *Handler) SetSchedulerWindow(name, window string) error {
+	c, err := h.GetRaftCluster()
+	if err != nil {
+		return err
+	}
+	if err = c.SetSchedulerWindow(name, window); err != nil {
+		log.Error("can not set scheduler window", zap.String("scheduler-name", name), zap.String("window", window), errs.ZapError(err))
+	}
+	return err
+}
+
+// SetSchedulerShadow switches the shadow mode of a scheduler, in which it
+// computes the operators every tick without dispatching them.
+func (h *Handler) SetSchedulerShadow(name string, shadow bool) error {
+	c, err := h.GetRaftCluster()
+	if err != nil {
+		return err
+	}
+	if err = c.SetSchedulerShadow(name, shadow); err != nil {
+		log.Error("can not set scheduler shadow mode", zap.String("scheduler-name", name), zap.Bool("shadow", shadow), errs.ZapError(err))
+	}
+	return err
+}
+
+// GetSchedulerShadowStatus returns the operators a scheduler has produced in
+// the shadow mode.
+func (h *Handler) GetSchedulerShadowStatus(name string) (*cluster.SchedulerShadowStatus, error) {
+	c, err := h.GetRaftCluster()
+	if err != nil {
+		return nil, err
+	}
+	return c.GetSchedulerShadowStatus(name)
+}
+
+// PreviewScheduler returns the operators the scheduler would produce next
+// without executing them.
+func (h *Handler) PreviewScheduler(name string, count int) (*cluster.SchedulerPreview, error) {
+	c, err := h.GetRaftCluster()
+	if err != nil {
+		return nil, err
+	}
+	return c.PreviewScheduler(name, count)
+}
+
 // AddBalanceLeaderScheduler adds a balance-leader-scheduler.
 func (h *Handler) AddBalanceLeaderScheduler() error {
 	return h.AddScheduler(schedulers.BalanceLeaderType)
@@ -359,6 +422,35 @@ func (h *Handler) GetWaitingOperators() ([]*operator.Operator, error) {
 	return c.GetWaitingOperators(), nil
 }
 
+// GetWaitingQueueStatus returns the status of the waiting operators of each store.
+func (h *Handler) GetWaitingQueueStatus() ([]*schedule.WaitingStoreStatus, error) {
+	c, err := h.GetOperatorController()
+	if err != nil {
+		return nil, err
+	}
+	return c.GetWaitingQueueStatus(), nil
+}
+
+// GetWaitingKeyspaceStatus returns the status of the waiting operators of each
+// keyspace.
+func (h *Handler) GetWaitingKeyspaceStatus() ([]*schedule.WaitingKeyspaceStatus, error) {
+	c, err := h.GetOperatorController()
+	if err != nil {
+		return nil, err
+	}
+	return c.GetWaitingKeyspaceStatus(), nil
+}
+
+// GetOperatorConflicts returns the regions on which the operators have
+// reverted each other recently.
+func (h *Handler) GetOperatorConflicts() ([]*schedule.OperatorConflict, error) {
+	c, err := h.GetOperatorController()
+	if err != nil {
+		return nil, err
+	}
+	return c.GetOperatorConflicts(), nil
+}
+
 // GetAdminOperators returns the running admin operators.
 func (h *Handler) GetAdminOperators() ([]*operator.Operator, error) {
 	return h.GetOperatorsOfKind(operator.OpAdmin)
@@ -721,7 +813,7 @@ func (h *Handler) AddSplitRegionOperator(regionID uint64, policyStr string, keys
 		}
 	}
 
-	op, err := operator.CreateSplitRegionOperator("admin-split-region", region, operator.OpAdmin, pdpb.CheckPolicy(policy), splitKeys)
+	op, err := schedule.CreateSplitRegionOperator("admin-split-region", c, region, operator.OpAdmin, pdpb.CheckPolicy(policy), splitKeys)
 	if err != nil {
 		return err
 	}
@@ -0,0 +1,356 @@
+// Copyright 2021 TiKV Project Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package heatmap
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"path"
+	"sort"
+	"time"
+
+	"github.com/pingcap/log"
+	"github.com/tikv/pd/pkg/errs"
+	"github.com/tikv/pd/server/core"
+	"github.com/tikv/pd/server/kv"
+)
+
+const (
+	heatmapPath = "heatmap"
+	// DefaultInterval is the default interval to roll up a column.
+	DefaultInterval = time.Minute
+	// DefaultRetention is the default time to keep the columns.
+	DefaultRetention = 24 * time.Hour
+	// DefaultMaxKeyBuckets is the default max number of the key buckets in a column or a matrix.
+	DefaultMaxKeyBuckets = 1024
+	scanLimit            = 1024
+	loadLimit            = 64
+)
+
+// StatTag is the type of the statistics shown in a heatmap.
+type StatTag string
+
+const (
+	// WrittenBytes is the written bytes of the regions.
+	WrittenBytes StatTag = "written_bytes"
+	// ReadBytes is the read bytes of the regions.
+	ReadBytes StatTag = "read_bytes"
+	// WrittenKeys is the written keys of the regions.
+	WrittenKeys StatTag = "written_keys"
+	// ReadKeys is the read keys of the regions.
+	ReadKeys StatTag = "read_keys"
+)
+
+// ParseStatTag parses the StatTag from a string.
+func ParseStatTag(s string) (StatTag, error) {
+	switch tag := StatTag(s); tag {
+	case WrittenBytes, ReadBytes, WrittenKeys, ReadKeys:
+		return tag, nil
+	}
+	return "", errs.ErrHeatmapStatTag.FastGenByArgs(s)
+}
+
+// Column is the statistics of the whole key space rolled up at a time point.
+// The key buckets are [Keys[i], Keys[i+1]), so len(Keys) is one more than the
+// length of the values. The keys are in hex format and the empty key means
+// the start or the end of the key space.
+type Column struct {
+	Time         int64    `json:"time"`
+	Keys         []string `json:"keys"`
+	WrittenBytes []uint64 `json:"written_bytes"`
+	ReadBytes    []uint64 `json:"read_bytes"`
+	WrittenKeys  []uint64 `json:"written_keys"`
+	ReadKeys     []uint64 `json:"read_keys"`
+}
+
+func (c *Column) values(tag StatTag) []uint64 {
+	switch tag {
+	case WrittenBytes:
+		return c.WrittenBytes
+	case ReadBytes:
+		return c.ReadBytes
+	case WrittenKeys:
+		return c.WrittenKeys
+	default:
+		return c.ReadKeys
+	}
+}
+
+// Matrix is the heatmap of one kind of statistics. Data[i][j] is the value of
+// the key bucket [KeyAxis[j], KeyAxis[j+1]) at TimeAxis[i].
+type Matrix struct {
+	Tag      StatTag    `json:"tag"`
+	TimeAxis []int64    `json:"time_axis"`
+	KeyAxis  []string   `json:"key_axis"`
+	Data     [][]uint64 `json:"data"`
+}
+
+// Aggregator rolls up the region statistics into the columns periodically and
+// saves them in the storage, so that the dashboards can get the key heatmap
+// from PD directly.
+type Aggregator struct {
+	storage       kv.Base
+	getRegions    func() *core.BasicCluster
+	interval      time.Duration
+	retention     time.Duration
+	maxKeyBuckets int
+}
+
+// NewAggregator creates an Aggregator which saves the columns to the storage.
+func NewAggregator(storage kv.Base, getRegions func() *core.BasicCluster) *Aggregator {
+	return &Aggregator{
+		storage:       storage,
+		getRegions:    getRegions,
+		interval:      DefaultInterval,
+		retention:     DefaultRetention,
+		maxKeyBuckets: DefaultMaxKeyBuckets,
+	}
+}
+
+// Run rolls up a column every interval until the context is done.
+func (a *Aggregator) Run(ctx context.Context) {
+	ticker := time.NewTicker(a.interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case now := <-ticker.C:
+			if err := a.rollUp(now); err != nil {
+				log.Warn("failed to roll up the heatmap", errs.ZapError(err))
+			}
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+func (a *Aggregator) rollUp(now time.Time) error {
+	rc := a.getRegions()
+	if rc == nil {
+		return nil
+	}
+	column := a.collect(rc, now)
+	if column == nil {
+		return nil
+	}
+	value, err := json.Marshal(column)
+	if err != nil {
+		return errs.ErrJSONMarshal.Wrap(err).GenWithStackByCause()
+	}
+	if err := a.storage.Save(columnPath(column.Time), string(value)); err != nil {
+		return err
+	}
+	return a.gc(now.Add(-a.retention))
+}
+
+// collect scans all regions and rolls up their statistics into a column.
+func (a *Aggregator) collect(rc *core.BasicCluster, now time.Time) *Column {
+	var (
+		keys                                           []string
+		writtenBytes, readBytes, writtenKeys, readKeys []uint64
+		startKey                                       []byte
+	)
+	for {
+		regions := rc.ScanRange(startKey, nil, scanLimit)
+		for _, region := range regions {
+			start := core.HexRegionKeyStr(region.GetStartKey())
+			if len(keys) == 0 {
+				keys = append(keys, start)
+			} else if keys[len(keys)-1] != start {
+				// There is a hole between the regions.
+				keys = append(keys, start)
+				writtenBytes, readBytes = append(writtenBytes, 0), append(readBytes, 0)
+				writtenKeys, readKeys = append(writtenKeys, 0), append(readKeys, 0)
+			}
+			keys = append(keys, core.HexRegionKeyStr(region.GetEndKey()))
+			writtenBytes = append(writtenBytes, region.GetBytesWritten())
+			readBytes = append(readBytes, region.GetBytesRead())
+			writtenKeys = append(writtenKeys, region.GetKeysWritten())
+			readKeys = append(readKeys, region.GetKeysRead())
+		}
+		if len(regions) < scanLimit {
+			break
+		}
+		startKey = regions[len(regions)-1].GetEndKey()
+		if len(startKey) == 0 {
+			break
+		}
+	}
+	if len(keys) == 0 {
+		return nil
+	}
+	step := bucketStep(keys, a.maxKeyBuckets)
+	return &Column{
+		Time:         now.Unix(),
+		Keys:         compressKeys(keys, step),
+		WrittenBytes: compressValues(writtenBytes, step),
+		ReadBytes:    compressValues(readBytes, step),
+		WrittenKeys:  compressValues(writtenKeys, step),
+		ReadKeys:     compressValues(readKeys, step),
+	}
+}
+
+// bucketStep returns how many adjacent buckets should be merged into one to
+// keep at most maxKeyBuckets buckets.
+func bucketStep(keys []string, maxKeyBuckets int) int {
+	return (len(keys) - 2 + maxKeyBuckets) / maxKeyBuckets
+}
+
+// compressKeys keeps one of every step boundaries and the last one.
+func compressKeys(keys []string, step int) []string {
+	if step <= 1 {
+		return keys
+	}
+	res := make([]string, 0, (len(keys)-1)/step+2)
+	for i := 0; i < len(keys)-1; i += step {
+		res = append(res, keys[i])
+	}
+	return append(res, keys[len(keys)-1])
+}
+
+// compressValues sums up every step values.
+func compressValues(values []uint64, step int) []uint64 {
+	if step <= 1 {
+		return values
+	}
+	res := make([]uint64, 0, (len(values)+step-1)/step)
+	for i := 0; i < len(values); i += step {
+		var sum uint64
+		for j := i; j < i+step && j < len(values); j++ {
+			sum += values[j]
+		}
+		res = append(res, sum)
+	}
+	return res
+}
+
+func columnPath(t int64) string {
+	return path.Join(heatmapPath, fmt.Sprintf("%020d", t))
+}
+
+// gc removes the columns rolled up before the deadline.
+func (a *Aggregator) gc(deadline time.Time) error {
+	for {
+		keys, _, err := a.storage.LoadRange(columnPath(0), columnPath(deadline.Unix()), loadLimit)
+		if err != nil {
+			return err
+		}
+		for _, key := range keys {
+			if err := a.storage.Remove(key); err != nil {
+				return err
+			}
+		}
+		if len(keys) < loadLimit {
+			return nil
+		}
+	}
+}
+
+// LoadColumns loads the columns rolled up in [start, end].
+func (a *Aggregator) LoadColumns(start, end time.Time) ([]*Column, error) {
+	var columns []*Column
+	nextKey, endKey := columnPath(start.Unix()), columnPath(end.Unix()+1)
+	for {
+		keys, values, err := a.storage.LoadRange(nextKey, endKey, loadLimit)
+		if err != nil {
+			return nil, err
+		}
+		for _, value := range values {
+			column := &Column{}
+			if err := json.Unmarshal([]byte(value), column); err != nil {
+				return nil, errs.ErrJSONUnmarshal.Wrap(err).GenWithStackByCause()
+			}
+			columns = append(columns, column)
+		}
+		if len(keys) < loadLimit {
+			return columns, nil
+		}
+		nextKey = keys[len(keys)-1] + "\x00"
+	}
+}
+
+// GetMatrix returns the heatmap of the statistics rolled up in [start, end].
+// The key buckets of the columns are aligned to the union of their boundaries,
+// and the value of a bucket is divided evenly into the aligned buckets it covers.
+func (a *Aggregator) GetMatrix(tag StatTag, start, end time.Time) (*Matrix, error) {
+	columns, err := a.LoadColumns(start, end)
+	if err != nil {
+		return nil, err
+	}
+	matrix := &Matrix{Tag: tag, TimeAxis: []int64{}, KeyAxis: []string{}, Data: [][]uint64{}}
+	if len(columns) == 0 {
+		return matrix, nil
+	}
+	matrix.KeyAxis = alignKeys(columns)
+	for _, column := range columns {
+		matrix.TimeAxis = append(matrix.TimeAxis, column.Time)
+		matrix.Data = append(matrix.Data, project(column.Keys, column.values(tag), matrix.KeyAxis))
+	}
+	step := bucketStep(matrix.KeyAxis, a.maxKeyBuckets)
+	matrix.KeyAxis = compressKeys(matrix.KeyAxis, step)
+	for i := range matrix.Data {
+		matrix.Data[i] = compressValues(matrix.Data[i], step)
+	}
+	return matrix, nil
+}
+
+// alignKeys returns the sorted union of the boundaries of the columns.
+func alignKeys(columns []*Column) []string {
+	set := make(map[string]struct{})
+	var hasEnd bool
+	for _, column := range columns {
+		for i, key := range column.Keys {
+			if key == "" && i > 0 {
+				hasEnd = true
+				continue
+			}
+			set[key] = struct{}{}
+		}
+	}
+	keys := make([]string, 0, len(set)+1)
+	for key := range set {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	if hasEnd {
+		keys = append(keys, "")
+	}
+	return keys
+}
+
+// project divides the values of the buckets split by keys into the buckets split by axis.
+func project(keys []string, values []uint64, axis []string) []uint64 {
+	res := make([]uint64, len(axis)-1)
+	j := 0
+	for i, value := range values {
+		start, end := keys[i], keys[i+1]
+		for j < len(axis)-1 && axis[j] < start {
+			j++
+		}
+		k := j
+		for k < len(axis)-1 && (end == "" || axis[k] < end) {
+			k++
+		}
+		if k == j {
+			continue
+		}
+		n := uint64(k - j)
+		for m := j; m < k; m++ {
+			res[m] = value / n
+		}
+		res[j] += value % n
+		j = k
+	}
+	return res
+}
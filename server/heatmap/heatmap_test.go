@@ -0,0 +1,104 @@
+// Copyright 2021 TiKV Project Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package heatmap
+
+import (
+	"testing"
+	"time"
+
+	. "github.com/pingcap/check"
+	"github.com/pingcap/kvproto/pkg/metapb"
+	"github.com/tikv/pd/server/core"
+	"github.com/tikv/pd/server/kv"
+)
+
+func TestHeatmap(t *testing.T) {
+	TestingT(t)
+}
+
+var _ = Suite(&testHeatmapSuite{})
+
+type testHeatmapSuite struct{}
+
+func newRegion(id uint64, start, end string, writtenBytes uint64) *core.RegionInfo {
+	return core.NewRegionInfo(&metapb.Region{
+		Id:          id,
+		StartKey:    []byte(start),
+		EndKey:      []byte(end),
+		RegionEpoch: &metapb.RegionEpoch{},
+	}, nil, core.SetWrittenBytes(writtenBytes), core.SetReadKeys(writtenBytes/10))
+}
+
+func (s *testHeatmapSuite) TestCollect(c *C) {
+	rc := core.NewBasicCluster()
+	rc.PutRegion(newRegion(1, "", "a", 10))
+	rc.PutRegion(newRegion(2, "a", "b", 20))
+	// There is a hole in ["b", "c").
+	rc.PutRegion(newRegion(3, "c", "", 30))
+	a := NewAggregator(kv.NewMemoryKV(), func() *core.BasicCluster { return rc })
+
+	column := a.collect(rc, time.Unix(100, 0))
+	c.Assert(column.Time, Equals, int64(100))
+	c.Assert(column.Keys, DeepEquals, []string{"", "61", "62", "63", ""})
+	c.Assert(column.WrittenBytes, DeepEquals, []uint64{10, 20, 0, 30})
+	c.Assert(column.ReadKeys, DeepEquals, []uint64{1, 2, 0, 3})
+
+	// The adjacent buckets are merged to keep at most maxKeyBuckets buckets.
+	a.maxKeyBuckets = 2
+	column = a.collect(rc, time.Unix(100, 0))
+	c.Assert(column.Keys, DeepEquals, []string{"", "62", ""})
+	c.Assert(column.WrittenBytes, DeepEquals, []uint64{30, 30})
+
+	c.Assert(a.collect(core.NewBasicCluster(), time.Unix(100, 0)), IsNil)
+}
+
+func (s *testHeatmapSuite) TestMatrix(c *C) {
+	rc := core.NewBasicCluster()
+	a := NewAggregator(kv.NewMemoryKV(), func() *core.BasicCluster { return rc })
+
+	rc.PutRegion(newRegion(1, "", "a", 10))
+	rc.PutRegion(newRegion(2, "a", "", 20))
+	c.Assert(a.rollUp(time.Unix(100, 0)), IsNil)
+	rc.PutRegion(newRegion(3, "", "b", 40))
+	rc.PutRegion(newRegion(4, "b", "", 6))
+	c.Assert(a.rollUp(time.Unix(160, 0)), IsNil)
+
+	// The buckets are aligned to the union of the boundaries.
+	matrix, err := a.GetMatrix(WrittenBytes, time.Unix(0, 0), time.Unix(200, 0))
+	c.Assert(err, IsNil)
+	c.Assert(matrix.TimeAxis, DeepEquals, []int64{100, 160})
+	c.Assert(matrix.KeyAxis, DeepEquals, []string{"", "61", "62", ""})
+	c.Assert(matrix.Data, DeepEquals, [][]uint64{{10, 10, 10}, {20, 20, 6}})
+
+	matrix, err = a.GetMatrix(WrittenBytes, time.Unix(150, 0), time.Unix(160, 0))
+	c.Assert(err, IsNil)
+	c.Assert(matrix.TimeAxis, DeepEquals, []int64{160})
+	c.Assert(matrix.KeyAxis, DeepEquals, []string{"", "62", ""})
+
+	a.maxKeyBuckets = 2
+	matrix, err = a.GetMatrix(ReadKeys, time.Unix(0, 0), time.Unix(200, 0))
+	c.Assert(err, IsNil)
+	c.Assert(matrix.KeyAxis, DeepEquals, []string{"", "62", ""})
+	c.Assert(matrix.Data, DeepEquals, [][]uint64{{2, 1}, {4, 0}})
+
+	// The expired columns are removed.
+	c.Assert(a.gc(time.Unix(150, 0)), IsNil)
+	columns, err := a.LoadColumns(time.Unix(0, 0), time.Unix(200, 0))
+	c.Assert(err, IsNil)
+	c.Assert(columns, HasLen, 1)
+	c.Assert(columns[0].Time, Equals, int64(160))
+
+	_, err = ParseStatTag("unknown")
+	c.Assert(err, NotNil)
+}
@@ -16,6 +16,7 @@ package id
 import (
 	"path"
 	"sync"
+	"sync/atomic"
 
 	"github.com/pingcap/log"
 	"github.com/tikv/pd/pkg/errs"
@@ -30,6 +31,9 @@ import (
 type Allocator interface {
 	// Alloc allocs a unique id.
 	Alloc() (uint64, error)
+	// AllocN allocs n contiguous unique ids and returns the first one, so the
+	// ids are [first, first+n). n should be positive.
+	AllocN(n uint64) (uint64, error)
 	// Rebase resets the base for the allocator from the persistent window boundary,
 	// which also resets the end of the allocator. (base, end) is the range that can
 	// be allocated in memory.
@@ -60,7 +64,7 @@ func (alloc *allocatorImpl) Alloc() (uint64, error) {
 	defer alloc.mu.Unlock()
 
 	if alloc.base == alloc.end {
-		if err := alloc.rebaseLocked(); err != nil {
+		if err := alloc.rebaseLocked(allocStep); err != nil {
 			return 0, err
 		}
 	}
@@ -70,6 +74,28 @@ func (alloc *allocatorImpl) Alloc() (uint64, error) {
 	return alloc.base, nil
 }
 
+// AllocN returns the first one of n contiguous new ids. The ids left in the
+// window are skipped if they are not enough.
+func (alloc *allocatorImpl) AllocN(n uint64) (uint64, error) {
+	alloc.mu.Lock()
+	defer alloc.mu.Unlock()
+
+	if alloc.end-alloc.base < n {
+		step := allocStep
+		if n > step {
+			step = n
+		}
+		if err := alloc.rebaseLocked(step); err != nil {
+			return 0, err
+		}
+	}
+
+	first := alloc.base + 1
+	alloc.base += n
+
+	return first, nil
+}
+
 // Rebase resets the base for the allocator from the persistent window boundary,
 // which also resets the end of the allocator. (base, end) is the range that can
 // be allocated in memory.
@@ -77,10 +103,10 @@ func (alloc *allocatorImpl) Rebase() error {
 	alloc.mu.Lock()
 	defer alloc.mu.Unlock()
 
-	return alloc.rebaseLocked()
+	return alloc.rebaseLocked(allocStep)
 }
 
-func (alloc *allocatorImpl) rebaseLocked() error {
+func (alloc *allocatorImpl) rebaseLocked(step uint64) error {
 	key := alloc.getAllocIDPath()
 	value, err := etcdutil.GetValue(alloc.client, key)
 	if err != nil {
@@ -105,7 +131,7 @@ func (alloc *allocatorImpl) rebaseLocked() error {
 		cmp = clientv3.Compare(clientv3.Value(key), "=", string(value))
 	}
 
-	end += allocStep
+	end += step
 	value = typeutil.Uint64ToBytes(end)
 	txn := kv.NewSlowLogTxn(alloc.client)
 	leaderPath := path.Join(alloc.rootPath, "leader")
@@ -121,10 +147,36 @@ func (alloc *allocatorImpl) rebaseLocked() error {
 	log.Info("idAllocator allocates a new id", zap.Uint64("alloc-id", end))
 	idGauge.WithLabelValues("idalloc").Set(float64(end))
 	alloc.end = end
-	alloc.base = end - allocStep
+	alloc.base = end - step
 	return nil
 }
 
 func (alloc *allocatorImpl) getAllocIDPath() string {
 	return path.Join(alloc.rootPath, "alloc_id")
 }
+
+// memoryAllocator allocates the IDs in memory, for the standalone PD which
+// persists nothing.
+type memoryAllocator struct {
+	base uint64
+}
+
+// NewMemoryAllocator creates a new ID Allocator which never persists the IDs.
+func NewMemoryAllocator() Allocator {
+	return &memoryAllocator{}
+}
+
+// Alloc returns a new id.
+func (alloc *memoryAllocator) Alloc() (uint64, error) {
+	return atomic.AddUint64(&alloc.base, 1), nil
+}
+
+// AllocN returns the first one of n contiguous new ids.
+func (alloc *memoryAllocator) AllocN(n uint64) (uint64, error) {
+	return atomic.AddUint64(&alloc.base, n) - n + 1, nil
+}
+
+// Rebase does nothing as the IDs are never persisted.
+func (alloc *memoryAllocator) Rebase() error {
+	return nil
+}
@@ -0,0 +1,325 @@
+// Copyright 2021 TiKV Project Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package job
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/pingcap/log"
+	"github.com/tikv/pd/pkg/errs"
+	"github.com/tikv/pd/server/core"
+	"go.uber.org/zap"
+)
+
+const (
+	jobPath = "job"
+	// finishedJobRetention is how long a finished job is kept, so its result
+	// can be queried.
+	finishedJobRetention = 24 * time.Hour
+)
+
+// State is the state of a job.
+type State string
+
+// The states of a job. A job starts as pending, becomes running once its first
+// step is run, and ends as succeeded, failed or canceled.
+const (
+	Pending   State = "pending"
+	Running   State = "running"
+	Succeeded State = "succeeded"
+	Failed    State = "failed"
+	Canceled  State = "canceled"
+)
+
+// IsFinished returns whether the state is a final one.
+func (s State) IsFinished() bool {
+	return s == Succeeded || s == Failed || s == Canceled
+}
+
+// Job is a long-running task of PD. It is run step by step by the Runner of
+// its type, and the checkpoint is persisted after each step, so that the job
+// can be resumed by the new leader after the PD leader changes.
+type Job struct {
+	ID   uint64 `json:"id"`
+	Type string `json:"type"`
+	// Args are the arguments of the job, which are interpreted by the Runner.
+	Args  json.RawMessage `json:"args,omitempty"`
+	State State           `json:"state"`
+	// Owner is the name of the PD member running the job.
+	Owner string `json:"owner"`
+	// Progress is in [0, 1].
+	Progress float64 `json:"progress"`
+	// Checkpoint records what has been done, which is maintained by the Runner.
+	Checkpoint json.RawMessage `json:"checkpoint,omitempty"`
+	Error      string          `json:"error,omitempty"`
+	CreateTime time.Time       `json:"create_time"`
+	StartTime  time.Time       `json:"start_time,omitempty"`
+	UpdateTime time.Time       `json:"update_time"`
+	FinishTime time.Time       `json:"finish_time,omitempty"`
+}
+
+func (j *Job) clone() *Job {
+	nj := *j
+	nj.Args = append(j.Args[:0:0], j.Args...)
+	nj.Checkpoint = append(j.Checkpoint[:0:0], j.Checkpoint...)
+	return &nj
+}
+
+// Runner runs the jobs of a type.
+type Runner interface {
+	// Step runs a bounded piece of the job from its checkpoint. It updates the
+	// checkpoint and the progress of the job, and returns whether the job is
+	// done. The job fails if an error is returned.
+	Step(job *Job) (done bool, err error)
+}
+
+// Manager maintains the jobs and drives them with the registered Runners.
+type Manager struct {
+	sync.RWMutex
+	storage *core.Storage
+	owner   string
+	allocID func() (uint64, error)
+	runners map[string]Runner
+	jobs    map[uint64]*Job
+	// now is replaceable in tests.
+	now func() time.Time
+}
+
+// NewManager creates a job manager. The owner is the name of the PD member
+// the jobs are run on.
+func NewManager(storage *core.Storage, owner string, allocID func() (uint64, error)) *Manager {
+	return &Manager{
+		storage: storage,
+		owner:   owner,
+		allocID: allocID,
+		runners: make(map[string]Runner),
+		jobs:    make(map[uint64]*Job),
+		now:     time.Now,
+	}
+}
+
+// RegisterRunner registers the Runner of a job type.
+func (m *Manager) RegisterRunner(typ string, runner Runner) {
+	m.Lock()
+	defer m.Unlock()
+	m.runners[typ] = runner
+}
+
+func jobKey(id uint64) string {
+	return fmt.Sprintf("%020d", id)
+}
+
+// Load loads the jobs from storage, and takes over the unfinished ones.
+func (m *Manager) Load() error {
+	m.Lock()
+	defer m.Unlock()
+	var toDelete []string
+	err := m.storage.LoadRangeByPrefix(jobPath+"/", func(k, v string) {
+		j := &Job{}
+		if err := json.Unmarshal([]byte(v), j); err != nil {
+			log.Error("failed to unmarshal job value", zap.String("job-key", k), errs.ZapError(errs.ErrJSONUnmarshal.Wrap(err).FastGenWithCause()))
+			toDelete = append(toDelete, k)
+			return
+		}
+		m.jobs[j.ID] = j
+	})
+	if err != nil {
+		return err
+	}
+	for _, k := range toDelete {
+		if err := m.storage.Remove(jobPath + "/" + k); err != nil {
+			return err
+		}
+	}
+	for _, j := range m.jobs {
+		if j.State.IsFinished() || j.Owner == m.owner {
+			continue
+		}
+		log.Info("job is taken over", zap.Uint64("job-id", j.ID), zap.String("type", j.Type),
+			zap.String("previous-owner", j.Owner), zap.String("owner", m.owner))
+		j.Owner = m.owner
+		if err := m.saveJobLocked(j); err != nil {
+			return err
+		}
+	}
+	m.updateMetricsLocked()
+	return nil
+}
+
+// Submit creates a pending job of the type.
+func (m *Manager) Submit(typ string, args json.RawMessage) (*Job, error) {
+	m.Lock()
+	defer m.Unlock()
+	if _, ok := m.runners[typ]; !ok {
+		return nil, errs.ErrJobContent.FastGenByArgs("unknown job type " + strconv.Quote(typ))
+	}
+	id, err := m.allocID()
+	if err != nil {
+		return nil, err
+	}
+	now := m.now()
+	j := &Job{
+		ID:         id,
+		Type:       typ,
+		Args:       append(args[:0:0], args...),
+		State:      Pending,
+		Owner:      m.owner,
+		CreateTime: now,
+		UpdateTime: now,
+	}
+	if err := m.saveJobLocked(j); err != nil {
+		return nil, err
+	}
+	log.Info("job is submitted", zap.Uint64("job-id", id), zap.String("type", typ), zap.ByteString("args", args))
+	m.updateMetricsLocked()
+	return j.clone(), nil
+}
+
+// GetJob returns the job with the given ID.
+func (m *Manager) GetJob(id uint64) *Job {
+	m.RLock()
+	defer m.RUnlock()
+	if j, ok := m.jobs[id]; ok {
+		return j.clone()
+	}
+	return nil
+}
+
+// GetJobs returns the jobs sorted by ID. The empty type or state matches all.
+func (m *Manager) GetJobs(typ string, state State) []*Job {
+	m.RLock()
+	defer m.RUnlock()
+	jobs := make([]*Job, 0, len(m.jobs))
+	for _, j := range m.jobs {
+		if (typ == "" || j.Type == typ) && (state == "" || j.State == state) {
+			jobs = append(jobs, j.clone())
+		}
+	}
+	sort.Slice(jobs, func(i, j int) bool { return jobs[i].ID < jobs[j].ID })
+	return jobs
+}
+
+// Cancel cancels an unfinished job. The step in progress is not interrupted,
+// but its result is discarded.
+func (m *Manager) Cancel(id uint64) error {
+	m.Lock()
+	defer m.Unlock()
+	j, ok := m.jobs[id]
+	if !ok {
+		return errs.ErrJobNotFound.FastGenByArgs(id)
+	}
+	if j.State.IsFinished() {
+		return errs.ErrJobNotCanceled.FastGenByArgs(id, j.State)
+	}
+	nj := j.clone()
+	m.finishLocked(nj, Canceled, "")
+	if err := m.saveJobLocked(nj); err != nil {
+		return err
+	}
+	log.Info("job is canceled", zap.Uint64("job-id", id), zap.String("type", j.Type))
+	m.updateMetricsLocked()
+	return nil
+}
+
+// RunOnce runs a step of each unfinished job, and removes the jobs finished
+// for a long time. The jobs without a registered Runner are left untouched.
+func (m *Manager) RunOnce() {
+	m.Lock()
+	if err := m.gcLocked(m.now()); err != nil {
+		log.Error("failed to remove finished jobs", errs.ZapError(err))
+	}
+	var jobs []*Job
+	for _, j := range m.jobs {
+		if !j.State.IsFinished() && m.runners[j.Type] != nil {
+			jobs = append(jobs, j.clone())
+		}
+	}
+	m.Unlock()
+	sort.Slice(jobs, func(i, k int) bool { return jobs[i].ID < jobs[k].ID })
+	for _, j := range jobs {
+		m.step(j)
+	}
+}
+
+func (m *Manager) step(j *Job) {
+	m.RLock()
+	runner := m.runners[j.Type]
+	m.RUnlock()
+	if j.State == Pending {
+		j.State, j.StartTime = Running, m.now()
+		log.Info("job starts", zap.Uint64("job-id", j.ID), zap.String("type", j.Type))
+	}
+	done, err := runner.Step(j)
+
+	m.Lock()
+	defer m.Unlock()
+	if cur, ok := m.jobs[j.ID]; !ok || cur.State.IsFinished() {
+		// It is canceled during the step.
+		return
+	}
+	j.UpdateTime = m.now()
+	if err != nil {
+		m.finishLocked(j, Failed, err.Error())
+		log.Warn("job failed", zap.Uint64("job-id", j.ID), zap.String("type", j.Type), errs.ZapError(err))
+	} else if done {
+		j.Progress = 1
+		m.finishLocked(j, Succeeded, "")
+		log.Info("job succeeded", zap.Uint64("job-id", j.ID), zap.String("type", j.Type),
+			zap.Duration("cost", j.FinishTime.Sub(j.StartTime)))
+	}
+	if err := m.saveJobLocked(j); err != nil {
+		log.Error("failed to persist job", zap.Uint64("job-id", j.ID), errs.ZapError(err))
+	}
+	m.updateMetricsLocked()
+}
+
+func (m *Manager) finishLocked(j *Job, state State, errMsg string) {
+	now := m.now()
+	j.State, j.Error = state, errMsg
+	j.UpdateTime, j.FinishTime = now, now
+	jobFinishedCounter.WithLabelValues(j.Type, string(state)).Inc()
+}
+
+func (m *Manager) gcLocked(now time.Time) error {
+	for id, j := range m.jobs {
+		if !j.State.IsFinished() || now.Sub(j.FinishTime) < finishedJobRetention {
+			continue
+		}
+		if err := m.storage.Remove(jobPath + "/" + jobKey(id)); err != nil {
+			return err
+		}
+		delete(m.jobs, id)
+	}
+	return nil
+}
+
+func (m *Manager) saveJobLocked(j *Job) error {
+	if err := m.storage.SaveJSON(jobPath, jobKey(j.ID), j); err != nil {
+		return err
+	}
+	m.jobs[j.ID] = j
+	return nil
+}
+
+func (m *Manager) updateMetricsLocked() {
+	jobGauge.Reset()
+	for _, j := range m.jobs {
+		jobGauge.WithLabelValues(j.Type, string(j.State)).Inc()
+	}
+}
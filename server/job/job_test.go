@@ -0,0 +1,129 @@
+// Copyright 2021 TiKV Project Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package job
+
+import (
+	"encoding/json"
+	"errors"
+	"strconv"
+	"testing"
+	"time"
+
+	. "github.com/pingcap/check"
+	"github.com/tikv/pd/pkg/errs"
+	"github.com/tikv/pd/server/core"
+	"github.com/tikv/pd/server/kv"
+)
+
+func TestJob(t *testing.T) {
+	TestingT(t)
+}
+
+var _ = Suite(&testJobSuite{})
+
+type testJobSuite struct {
+	lastID uint64
+}
+
+// countRunner counts to the number in the args, a step at a time.
+type countRunner struct {
+	fail bool
+}
+
+func (r *countRunner) Step(j *Job) (bool, error) {
+	if r.fail {
+		return false, errors.New("mock error")
+	}
+	var target, count int
+	if err := json.Unmarshal(j.Args, &target); err != nil {
+		return false, err
+	}
+	if len(j.Checkpoint) > 0 {
+		count, _ = strconv.Atoi(string(j.Checkpoint))
+	}
+	count++
+	j.Checkpoint = json.RawMessage(strconv.Itoa(count))
+	j.Progress = float64(count) / float64(target)
+	return count >= target, nil
+}
+
+func (s *testJobSuite) newTestManager(storage *core.Storage, owner string) *Manager {
+	m := NewManager(storage, owner, func() (uint64, error) {
+		s.lastID++
+		return s.lastID, nil
+	})
+	m.RegisterRunner("count", &countRunner{})
+	return m
+}
+
+func (s *testJobSuite) TestLifecycle(c *C) {
+	storage := core.NewStorage(kv.NewMemoryKV())
+	m := s.newTestManager(storage, "pd1")
+	c.Assert(m.Load(), IsNil)
+
+	_, err := m.Submit("unknown", nil)
+	c.Assert(errs.ErrJobContent.Equal(err), IsTrue)
+	j, err := m.Submit("count", json.RawMessage("2"))
+	c.Assert(err, IsNil)
+	c.Assert(j.State, Equals, Pending)
+	c.Assert(j.Owner, Equals, "pd1")
+
+	m.RunOnce()
+	j = m.GetJob(j.ID)
+	c.Assert(j.State, Equals, Running)
+	c.Assert(j.Progress, Equals, 0.5)
+	c.Assert(string(j.Checkpoint), Equals, "1")
+
+	// The job is resumed from the checkpoint by another member.
+	m2 := s.newTestManager(storage, "pd2")
+	c.Assert(m2.Load(), IsNil)
+	j = m2.GetJob(j.ID)
+	c.Assert(j.Owner, Equals, "pd2")
+	m2.RunOnce()
+	j = m2.GetJob(j.ID)
+	c.Assert(j.State, Equals, Succeeded)
+	c.Assert(j.Progress, Equals, 1.0)
+	c.Assert(string(j.Checkpoint), Equals, "2")
+	c.Assert(errs.ErrJobNotCanceled.Equal(m2.Cancel(j.ID)), IsTrue)
+	c.Assert(errs.ErrJobNotFound.Equal(m2.Cancel(100)), IsTrue)
+
+	j2, err := m2.Submit("count", json.RawMessage("10"))
+	c.Assert(err, IsNil)
+	c.Assert(m2.Cancel(j2.ID), IsNil)
+	m2.RunOnce()
+	c.Assert(m2.GetJob(j2.ID).State, Equals, Canceled)
+	c.Assert(m2.GetJobs("", ""), HasLen, 2)
+	c.Assert(m2.GetJobs("count", Canceled), HasLen, 1)
+	c.Assert(m2.GetJobs("other", ""), HasLen, 0)
+
+	// The finished jobs are removed after the retention.
+	m2.now = func() time.Time { return time.Now().Add(finishedJobRetention) }
+	m2.RunOnce()
+	c.Assert(m2.GetJobs("", ""), HasLen, 0)
+	m3 := s.newTestManager(storage, "pd3")
+	c.Assert(m3.Load(), IsNil)
+	c.Assert(m3.GetJobs("", ""), HasLen, 0)
+}
+
+func (s *testJobSuite) TestFailure(c *C) {
+	m := s.newTestManager(core.NewStorage(kv.NewMemoryKV()), "pd1")
+	m.RegisterRunner("fail", &countRunner{fail: true})
+	j, err := m.Submit("fail", nil)
+	c.Assert(err, IsNil)
+	m.RunOnce()
+	j = m.GetJob(j.ID)
+	c.Assert(j.State, Equals, Failed)
+	c.Assert(j.Error, Equals, "mock error")
+	c.Assert(j.FinishTime.IsZero(), IsFalse)
+}
@@ -0,0 +1,39 @@
+// Copyright 2021 TiKV Project Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package job
+
+import "github.com/prometheus/client_golang/prometheus"
+
+var (
+	jobGauge = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Namespace: "pd",
+			Subsystem: "job",
+			Name:      "jobs",
+			Help:      "The number of the jobs in each state.",
+		}, []string{"type", "state"})
+
+	jobFinishedCounter = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace: "pd",
+			Subsystem: "job",
+			Name:      "finished_count",
+			Help:      "Counter of the finished jobs.",
+		}, []string{"type", "state"})
+)
+
+func init() {
+	prometheus.MustRegister(jobGauge)
+	prometheus.MustRegister(jobFinishedCounter)
+}
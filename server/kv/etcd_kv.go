@@ -115,6 +115,24 @@ func (kv *etcdKVBase) Remove(key string) error {
 	return nil
 }
 
+// RemoveRange implements RangeRemover.
+func (kv *etcdKVBase) RemoveRange(key, endKey string) error {
+	key = path.Join(kv.rootPath, key)
+	endKey = path.Join(kv.rootPath, endKey)
+
+	txn := NewSlowLogTxn(kv.client)
+	resp, err := txn.Then(clientv3.OpDelete(key, clientv3.WithRange(endKey))).Commit()
+	if err != nil {
+		err = errs.ErrEtcdKVDelete.Wrap(err).GenWithStackByCause()
+		log.Error("remove range from etcd meet error", zap.String("key", key), zap.String("end-key", endKey), errs.ZapError(err))
+		return err
+	}
+	if !resp.Succeeded {
+		return errs.ErrEtcdTxnConflict.FastGenByArgs()
+	}
+	return nil
+}
+
 // SlowLogTxn wraps etcd transaction and log slow one.
 type SlowLogTxn struct {
 	clientv3.Txn
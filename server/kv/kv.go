@@ -20,3 +20,10 @@ type Base interface {
 	Save(key, value string) error
 	Remove(key string) error
 }
+
+// RangeRemover is implemented by the kv bases which can remove a range of
+// keys at once.
+type RangeRemover interface {
+	// RemoveRange deletes the key-value pairs in [key, endKey).
+	RemoveRange(key, endKey string) error
+}
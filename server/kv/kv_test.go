@@ -53,6 +53,7 @@ func (s *testKVSuite) TestEtcd(c *C) {
 	kv := NewEtcdKVBase(client, rootPath)
 	s.testReadWrite(c, kv)
 	s.testRange(c, kv)
+	s.testRemoveRange(c, kv)
 }
 
 func (s *testKVSuite) TestLevelDB(c *C) {
@@ -64,12 +65,14 @@ func (s *testKVSuite) TestLevelDB(c *C) {
 
 	s.testReadWrite(c, kv)
 	s.testRange(c, kv)
+	s.testRemoveRange(c, kv)
 }
 
 func (s *testKVSuite) TestMemKV(c *C) {
 	kv := NewMemoryKV()
 	s.testReadWrite(c, kv)
 	s.testRange(c, kv)
+	s.testRemoveRange(c, kv)
 }
 
 func (s *testKVSuite) testReadWrite(c *C, kv Base) {
@@ -125,6 +128,17 @@ func (s *testKVSuite) testRange(c *C, kv Base) {
 	}
 }
 
+func (s *testKVSuite) testRemoveRange(c *C, kv Base) {
+	keys := []string{"remove/a", "remove/b", "remove/c", "remove/d"}
+	for _, k := range keys {
+		c.Assert(kv.Save(k, k), IsNil)
+	}
+	c.Assert(kv.(RangeRemover).RemoveRange("remove/b", "remove/d"), IsNil)
+	ks, _, err := kv.LoadRange("remove/", clientv3.GetPrefixRangeEnd("remove/"), 100)
+	c.Assert(err, IsNil)
+	c.Assert(ks, DeepEquals, []string{"remove/a", "remove/d"})
+}
+
 func newTestSingleConfig() *embed.Config {
 	cfg := embed.NewConfig()
 	cfg.Name = "test_etcd"
@@ -76,6 +76,23 @@ func (kv *LeveldbKV) Remove(key string) error {
 	return errors.WithStack(kv.Delete([]byte(key), nil))
 }
 
+// RemoveRange implements RangeRemover.
+func (kv *LeveldbKV) RemoveRange(key, endKey string) error {
+	batch := new(leveldb.Batch)
+	iter := kv.NewIterator(&util.Range{Start: []byte(key), Limit: []byte(endKey)}, nil)
+	for iter.Next() {
+		batch.Delete(append([]byte(nil), iter.Key()...))
+	}
+	iter.Release()
+	if err := iter.Error(); err != nil {
+		return errors.WithStack(err)
+	}
+	if err := kv.Write(batch, nil); err != nil {
+		return errs.ErrLevelDBWrite.Wrap(err).GenWithStackByCause()
+	}
+	return nil
+}
+
 // SaveRegions stores some regions.
 func (kv *LeveldbKV) SaveRegions(regions map[string]*metapb.Region) error {
 	batch := new(leveldb.Batch)
@@ -69,6 +69,16 @@ func NewMember(etcd *embed.Etcd, client *clientv3.Client, id uint64) *Member {
 	}
 }
 
+// NewStandaloneMember creates the only member of a standalone PD, which runs
+// without etcd and is always the leader.
+func NewStandaloneMember(cfg *config.Config, id uint64, rootPath string) *Member {
+	m := &Member{id: id}
+	m.MemberInfo(cfg, cfg.Name, rootPath)
+	m.leadership = election.NewStandaloneLeadership(m.GetLeaderPath(), "pd leader election")
+	m.EnableLeader()
+	return m
+}
+
 // ID returns the unique etcd ID for this server in etcd cluster.
 func (m *Member) ID() uint64 {
 	return m.id
@@ -238,8 +248,12 @@ func (m *Member) MoveEtcdLeader(ctx context.Context, old, new uint64) error {
 	return nil
 }
 
-// GetEtcdLeader returns the etcd leader ID.
+// GetEtcdLeader returns the etcd leader ID. The standalone member leads
+// itself.
 func (m *Member) GetEtcdLeader() uint64 {
+	if m.etcd == nil {
+		return m.id
+	}
 	return m.etcd.Server.Lead()
 }
 
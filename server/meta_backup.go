@@ -0,0 +1,212 @@
+// Copyright 2021 TiKV Project Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package server
+
+import (
+	"encoding/json"
+	"fmt"
+	"path"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/pingcap/log"
+	"github.com/tikv/pd/pkg/errs"
+	"github.com/tikv/pd/server/config"
+	"go.etcd.io/etcd/clientv3"
+	"go.uber.org/zap"
+)
+
+// MetaArchiveVersion is the version of the format of the meta archive.
+const MetaArchiveVersion = 1
+
+// The types of the differences between the meta archive and the cluster.
+const (
+	MetaDiffAdd    = "add"
+	MetaDiffUpdate = "update"
+	MetaDiffDelete = "delete"
+)
+
+// metaSection is a kind of the PD-owned meta in the storage. The key of the
+// section is either a single key or a prefix.
+type metaSection struct {
+	name   string
+	key    string
+	prefix bool
+}
+
+// metaSections are the meta in the archive. The regions, the stores and the
+// states of the running cluster are not included, which are owned by TiKV.
+var metaSections = []metaSection{
+	{name: "config", key: "config"},
+	{name: "rules", key: "rules/", prefix: true},
+	{name: "rule-groups", key: "rule_group/", prefix: true},
+	{name: "region-labels", key: "region_label/", prefix: true},
+	{name: "scheduler-configs", key: "scheduler_config/", prefix: true},
+}
+
+// MetaArchive is a dump of the PD-owned meta of a cluster.
+type MetaArchive struct {
+	Version   int       `json:"version"`
+	ClusterID uint64    `json:"cluster_id"`
+	Time      time.Time `json:"time"`
+	// Sections are the values in the storage by the key, grouped by the kind of
+	// the meta.
+	Sections map[string]map[string]string `json:"sections"`
+}
+
+// MetaDiff is a key of the meta changed by the restore.
+type MetaDiff struct {
+	Section string `json:"section"`
+	Key     string `json:"key"`
+	Type    string `json:"type"`
+}
+
+// MetaRestoreResult is the result of restoring a meta archive.
+type MetaRestoreResult struct {
+	DryRun bool        `json:"dry_run"`
+	Diffs  []*MetaDiff `json:"diffs"`
+}
+
+func (s *Server) loadMetaSection(section metaSection) (map[string]string, error) {
+	kvs := make(map[string]string)
+	if !section.prefix {
+		value, err := s.storage.Load(section.key)
+		if err != nil {
+			return nil, err
+		}
+		if value != "" {
+			kvs[section.key] = value
+		}
+		return kvs, nil
+	}
+	err := s.storage.LoadRangeByPrefix(section.key, func(k, v string) {
+		kvs[section.key+k] = v
+	})
+	return kvs, err
+}
+
+// BackupMeta dumps the PD-owned meta into an archive.
+func (s *Server) BackupMeta() (*MetaArchive, error) {
+	archive := &MetaArchive{
+		Version:   MetaArchiveVersion,
+		ClusterID: s.clusterID,
+		Time:      time.Now(),
+		Sections:  make(map[string]map[string]string, len(metaSections)),
+	}
+	for _, section := range metaSections {
+		kvs, err := s.loadMetaSection(section)
+		if err != nil {
+			return nil, err
+		}
+		archive.Sections[section.name] = kvs
+	}
+	return archive, nil
+}
+
+// validateMetaArchive checks the version of the archive, and that the keys
+// belong to their sections and the values are valid.
+func validateMetaArchive(archive *MetaArchive) error {
+	if archive.Version != MetaArchiveVersion {
+		return errs.ErrMetaArchiveVersion.FastGenByArgs(archive.Version)
+	}
+	known := make(map[string]metaSection, len(metaSections))
+	for _, section := range metaSections {
+		known[section.name] = section
+	}
+	for name, kvs := range archive.Sections {
+		section, ok := known[name]
+		if !ok {
+			return errs.ErrInvalidMetaArchive.FastGenByArgs(fmt.Sprintf("unknown section %q", name))
+		}
+		for k, v := range kvs {
+			if section.prefix && (!strings.HasPrefix(k, section.key) || k == section.key) || !section.prefix && k != section.key {
+				return errs.ErrInvalidMetaArchive.FastGenByArgs(fmt.Sprintf("key %q is out of section %q", k, name))
+			}
+			if !json.Valid([]byte(v)) {
+				return errs.ErrInvalidMetaArchive.FastGenByArgs(fmt.Sprintf("the value of key %q is not json", k))
+			}
+		}
+	}
+	if v, ok := archive.Sections["config"]["config"]; ok {
+		if err := json.Unmarshal([]byte(v), &config.Config{}); err != nil {
+			return errs.ErrInvalidMetaArchive.FastGenByArgs(fmt.Sprintf("invalid config: %s", err))
+		}
+	}
+	return nil
+}
+
+// RestoreMeta replaces the PD-owned meta with the archive in a transaction,
+// and returns the keys changed. A section missing from the archive is kept.
+// The meta can only be restored into a cluster which is not bootstrapped, so
+// the restored meta is loaded when the cluster is bootstrapped. If dryRun is
+// true, the changes are returned without being applied.
+func (s *Server) RestoreMeta(archive *MetaArchive, dryRun bool) (*MetaRestoreResult, error) {
+	if err := validateMetaArchive(archive); err != nil {
+		return nil, err
+	}
+	if s.GetRaftCluster() != nil {
+		return nil, errs.ErrRestoreMetaBootstrapped.FastGenByArgs()
+	}
+	result := &MetaRestoreResult{DryRun: dryRun, Diffs: []*MetaDiff{}}
+	var ops []clientv3.Op
+	for _, section := range metaSections {
+		kvs, ok := archive.Sections[section.name]
+		if !ok {
+			continue
+		}
+		current, err := s.loadMetaSection(section)
+		if err != nil {
+			return nil, err
+		}
+		var diffs []*MetaDiff
+		for k, v := range kvs {
+			old, ok := current[k]
+			switch {
+			case !ok:
+				diffs = append(diffs, &MetaDiff{Section: section.name, Key: k, Type: MetaDiffAdd})
+			case old != v:
+				diffs = append(diffs, &MetaDiff{Section: section.name, Key: k, Type: MetaDiffUpdate})
+			default:
+				continue
+			}
+			ops = append(ops, clientv3.OpPut(path.Join(s.rootPath, k), v))
+		}
+		for k := range current {
+			if _, ok := kvs[k]; !ok {
+				diffs = append(diffs, &MetaDiff{Section: section.name, Key: k, Type: MetaDiffDelete})
+				ops = append(ops, clientv3.OpDelete(path.Join(s.rootPath, k)))
+			}
+		}
+		sort.Slice(diffs, func(i, j int) bool { return diffs[i].Key < diffs[j].Key })
+		result.Diffs = append(result.Diffs, diffs...)
+	}
+	if dryRun || len(ops) == 0 {
+		return result, nil
+	}
+	// The transaction fails if the cluster is bootstrapped in the meantime.
+	notBootstrapped := clientv3.Compare(clientv3.CreateRevision(s.GetClusterRootPath()), "=", 0)
+	resp, err := s.member.GetLeadership().LeaderTxn(notBootstrapped).Then(ops...).Commit()
+	if err != nil {
+		return nil, errs.ErrEtcdTxnInternal.Wrap(err).GenWithStackByCause()
+	}
+	if !resp.Succeeded {
+		return nil, errs.ErrEtcdTxnConflict.FastGenByArgs()
+	}
+	if err := s.reloadConfigFromKV(); err != nil {
+		return nil, err
+	}
+	log.Info("meta is restored", zap.Uint64("from-cluster-id", archive.ClusterID), zap.Int("changes", len(result.Diffs)))
+	return result, nil
+}
@@ -0,0 +1,208 @@
+// Copyright 2021 TiKV Project Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package metasnapshot
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/s3"
+	"github.com/pingcap/errors"
+	"github.com/tikv/pd/pkg/errs"
+)
+
+// Backend is the object storage which the snapshots are exported to.
+type Backend interface {
+	// Put writes the object of the name.
+	Put(ctx context.Context, name string, data []byte) error
+	// Get reads the object of the name.
+	Get(ctx context.Context, name string) ([]byte, error)
+	// List returns the sorted names of the objects with the prefix.
+	List(ctx context.Context, prefix string) ([]string, error)
+	// String returns the URL of the backend.
+	String() string
+}
+
+// NewBackend creates the backend of the URL, which is either
+// "local:///path/to/dir" or "s3://bucket/prefix". The endpoint and the region
+// of an S3-compatible storage are set by the "endpoint" and "region" queries,
+// and the credentials are read from the environment.
+func NewBackend(rawURL string) (Backend, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return nil, errs.ErrMetaSnapshot.Wrap(err).GenWithStackByArgs(rawURL)
+	}
+	switch u.Scheme {
+	case "local":
+		return &localBackend{dir: u.Path}, nil
+	case "s3":
+		return newS3Backend(u)
+	}
+	return nil, errs.ErrMetaSnapshot.Wrap(errors.Errorf("unknown scheme %s", u.Scheme)).GenWithStackByArgs(rawURL)
+}
+
+// localBackend keeps the objects as the files in a directory, which is
+// usually a mounted network file system.
+type localBackend struct {
+	dir string
+}
+
+func (b *localBackend) Put(_ context.Context, name string, data []byte) error {
+	file := filepath.Join(b.dir, filepath.FromSlash(name))
+	if err := os.MkdirAll(filepath.Dir(file), 0755); err != nil {
+		return errs.ErrMetaSnapshot.Wrap(err).GenWithStackByArgs(file)
+	}
+	// Write to a temporary file first, so a partial file is never seen.
+	tmp := file + ".tmp"
+	if err := os.WriteFile(tmp, data, 0644); err != nil {
+		return errs.ErrMetaSnapshot.Wrap(err).GenWithStackByArgs(file)
+	}
+	if err := os.Rename(tmp, file); err != nil {
+		return errs.ErrMetaSnapshot.Wrap(err).GenWithStackByArgs(file)
+	}
+	return nil
+}
+
+func (b *localBackend) Get(_ context.Context, name string) ([]byte, error) {
+	file := filepath.Join(b.dir, filepath.FromSlash(name))
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return nil, errs.ErrMetaSnapshot.Wrap(err).GenWithStackByArgs(file)
+	}
+	return data, nil
+}
+
+func (b *localBackend) List(_ context.Context, prefix string) ([]string, error) {
+	var names []string
+	err := filepath.Walk(b.dir, func(file string, info os.FileInfo, err error) error {
+		if err != nil {
+			if os.IsNotExist(err) && file == b.dir {
+				return nil
+			}
+			return err
+		}
+		if info.IsDir() || strings.HasSuffix(file, ".tmp") {
+			return nil
+		}
+		rel, err := filepath.Rel(b.dir, file)
+		if err != nil {
+			return err
+		}
+		if name := filepath.ToSlash(rel); strings.HasPrefix(name, prefix) {
+			names = append(names, name)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, errs.ErrMetaSnapshot.Wrap(err).GenWithStackByArgs(b.dir)
+	}
+	sort.Strings(names)
+	return names, nil
+}
+
+func (b *localBackend) String() string {
+	return "local://" + b.dir
+}
+
+// s3Backend keeps the objects in an S3-compatible storage.
+type s3Backend struct {
+	client *s3.S3
+	bucket string
+	prefix string
+}
+
+func newS3Backend(u *url.URL) (*s3Backend, error) {
+	if u.Host == "" {
+		return nil, errs.ErrMetaSnapshot.Wrap(errors.New("bucket is required")).GenWithStackByArgs(u.String())
+	}
+	awsCfg := &aws.Config{Region: aws.String(u.Query().Get("region"))}
+	if endpoint := u.Query().Get("endpoint"); endpoint != "" {
+		// The S3-compatible storages usually don't support the virtual hosted
+		// style.
+		awsCfg.Endpoint = aws.String(endpoint)
+		awsCfg.S3ForcePathStyle = aws.Bool(true)
+	}
+	sess, err := session.NewSession(awsCfg)
+	if err != nil {
+		return nil, errs.ErrMetaSnapshot.Wrap(err).GenWithStackByArgs(u.String())
+	}
+	return &s3Backend{client: s3.New(sess), bucket: u.Host, prefix: strings.Trim(u.Path, "/")}, nil
+}
+
+func (b *s3Backend) key(name string) string {
+	return path.Join(b.prefix, name)
+}
+
+func (b *s3Backend) Put(ctx context.Context, name string, data []byte) error {
+	_, err := b.client.PutObjectWithContext(ctx, &s3.PutObjectInput{
+		Bucket: aws.String(b.bucket),
+		Key:    aws.String(b.key(name)),
+		Body:   bytes.NewReader(data),
+	})
+	if err != nil {
+		return errs.ErrMetaSnapshot.Wrap(err).GenWithStackByArgs("s3://" + path.Join(b.bucket, b.key(name)))
+	}
+	return nil
+}
+
+func (b *s3Backend) Get(ctx context.Context, name string) ([]byte, error) {
+	resp, err := b.client.GetObjectWithContext(ctx, &s3.GetObjectInput{
+		Bucket: aws.String(b.bucket),
+		Key:    aws.String(b.key(name)),
+	})
+	if err != nil {
+		return nil, errs.ErrMetaSnapshot.Wrap(err).GenWithStackByArgs("s3://" + path.Join(b.bucket, b.key(name)))
+	}
+	defer resp.Body.Close()
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, errs.ErrMetaSnapshot.Wrap(err).GenWithStackByArgs("s3://" + path.Join(b.bucket, b.key(name)))
+	}
+	return data, nil
+}
+
+func (b *s3Backend) List(ctx context.Context, prefix string) ([]string, error) {
+	var root string
+	if b.prefix != "" {
+		root = b.prefix + "/"
+	}
+	var names []string
+	err := b.client.ListObjectsV2PagesWithContext(ctx, &s3.ListObjectsV2Input{
+		Bucket: aws.String(b.bucket),
+		Prefix: aws.String(root + prefix),
+	}, func(page *s3.ListObjectsV2Output, _ bool) bool {
+		for _, obj := range page.Contents {
+			names = append(names, strings.TrimPrefix(aws.StringValue(obj.Key), root))
+		}
+		return true
+	})
+	if err != nil {
+		return nil, errs.ErrMetaSnapshot.Wrap(err).GenWithStackByArgs(b.String())
+	}
+	sort.Strings(names)
+	return names, nil
+}
+
+func (b *s3Backend) String() string {
+	return "s3://" + path.Join(b.bucket, b.prefix)
+}
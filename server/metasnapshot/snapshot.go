@@ -0,0 +1,271 @@
+// Copyright 2021 TiKV Project Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package metasnapshot
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"path"
+	"strings"
+	"time"
+
+	"github.com/pingcap/log"
+	"github.com/tikv/pd/pkg/errs"
+	"github.com/tikv/pd/server/config"
+	"github.com/tikv/pd/server/core"
+	"go.uber.org/zap"
+)
+
+const (
+	manifestFile    = "manifest.json"
+	manifestVersion = 1
+	exportTimeout   = time.Minute
+	// snapshotNameLayout names the snapshots by the UTC time, so they are
+	// sorted by the time.
+	snapshotNameLayout = "20060102-150405.000"
+)
+
+// section is a group of the PD meta exported as a file. The keys and the
+// prefixes are the paths in core.Storage.
+type section struct {
+	name     string
+	keys     []string
+	prefixes []string
+}
+
+var sections = []section{
+	{
+		name:     "config",
+		keys:     []string{"config"},
+		prefixes: []string{"scheduler_config/", "replication_mode/"},
+	},
+	{
+		name:     "rules",
+		prefixes: []string{"rules/", "rule_group/"},
+	},
+	{
+		name: "stores",
+		keys: []string{"raft"},
+		prefixes: []string{
+			"raft/s/",
+			"schedule/store_weight/",
+			"schedule/store_read_only/",
+			"schedule/store_resource_tags/",
+		},
+	},
+}
+
+// Entry is a key-value pair of the PD meta.
+type Entry struct {
+	Key   string `json:"key"`
+	Value []byte `json:"value"`
+}
+
+// ManifestFile is a file of a snapshot with its checksum.
+type ManifestFile struct {
+	Name    string `json:"name"`
+	Size    int    `json:"size"`
+	Entries int    `json:"entries"`
+	SHA256  string `json:"sha256"`
+}
+
+// Manifest describes a snapshot. It is written after all files of the
+// snapshot, so a snapshot without the manifest is incomplete.
+type Manifest struct {
+	Version    int            `json:"version"`
+	ClusterID  uint64         `json:"cluster_id"`
+	CreateTime time.Time      `json:"create_time"`
+	Files      []ManifestFile `json:"files"`
+}
+
+func checksum(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+// Snapshotter exports the snapshots of the PD meta, like the rules, the
+// configs and the stores, to an object storage periodically. The snapshots
+// are the last resort to recover the meta if the etcd backups are lost.
+type Snapshotter struct {
+	backend   Backend
+	storage   *core.Storage
+	clusterID uint64
+	interval  time.Duration
+	// isLeader returns if the server is the leader, as only the leader
+	// exports the snapshots.
+	isLeader func() bool
+}
+
+// NewSnapshotter creates a Snapshotter with the config. It returns nil if
+// the export is disabled.
+func NewSnapshotter(cfg *config.MetaSnapshotConfig, storage *core.Storage, clusterID uint64, isLeader func() bool) (*Snapshotter, error) {
+	if cfg.Storage == "" {
+		return nil, nil
+	}
+	backend, err := NewBackend(cfg.Storage)
+	if err != nil {
+		return nil, err
+	}
+	return &Snapshotter{
+		backend:   backend,
+		storage:   storage,
+		clusterID: clusterID,
+		interval:  cfg.Interval.Duration,
+		isLeader:  isLeader,
+	}, nil
+}
+
+// Run exports the snapshots periodically until the context is done.
+func (s *Snapshotter) Run(ctx context.Context) {
+	ticker := time.NewTicker(s.interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if !s.isLeader() {
+				continue
+			}
+			name, err := s.Export(ctx)
+			if err != nil {
+				exportCounter.WithLabelValues("failure").Inc()
+				log.Warn("failed to export the meta snapshot", zap.Stringer("storage", s.backend), errs.ZapError(err))
+				continue
+			}
+			exportCounter.WithLabelValues("success").Inc()
+			log.Info("meta snapshot is exported", zap.Stringer("storage", s.backend), zap.String("name", name))
+		}
+	}
+}
+
+// Export exports a snapshot and returns its name.
+func (s *Snapshotter) Export(ctx context.Context) (string, error) {
+	ctx, cancel := context.WithTimeout(ctx, exportTimeout)
+	defer cancel()
+	now := time.Now()
+	name := now.UTC().Format(snapshotNameLayout)
+	manifest := &Manifest{Version: manifestVersion, ClusterID: s.clusterID, CreateTime: now}
+	for _, sec := range sections {
+		entries, err := s.loadSection(sec)
+		if err != nil {
+			return "", err
+		}
+		data, err := json.Marshal(entries)
+		if err != nil {
+			return "", errs.ErrJSONMarshal.Wrap(err).GenWithStackByCause()
+		}
+		file := sec.name + ".json"
+		if err := s.backend.Put(ctx, path.Join(name, file), data); err != nil {
+			return "", err
+		}
+		manifest.Files = append(manifest.Files, ManifestFile{Name: file, Size: len(data), Entries: len(entries), SHA256: checksum(data)})
+	}
+	data, err := json.Marshal(manifest)
+	if err != nil {
+		return "", errs.ErrJSONMarshal.Wrap(err).GenWithStackByCause()
+	}
+	if err := s.backend.Put(ctx, path.Join(name, manifestFile), data); err != nil {
+		return "", err
+	}
+	return name, nil
+}
+
+func (s *Snapshotter) loadSection(sec section) ([]Entry, error) {
+	entries := make([]Entry, 0)
+	for _, key := range sec.keys {
+		value, err := s.storage.Load(key)
+		if err != nil {
+			return nil, err
+		}
+		if value != "" {
+			entries = append(entries, Entry{Key: key, Value: []byte(value)})
+		}
+	}
+	for _, prefix := range sec.prefixes {
+		err := s.storage.LoadRangeByPrefix(prefix, func(k, v string) {
+			entries = append(entries, Entry{Key: prefix + k, Value: []byte(v)})
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+	return entries, nil
+}
+
+// ListSnapshots returns the names of the complete snapshots in the backend
+// from the oldest to the latest.
+func ListSnapshots(ctx context.Context, backend Backend) ([]string, error) {
+	objects, err := backend.List(ctx, "")
+	if err != nil {
+		return nil, err
+	}
+	var names []string
+	for _, object := range objects {
+		if strings.HasSuffix(object, "/"+manifestFile) {
+			names = append(names, strings.TrimSuffix(object, "/"+manifestFile))
+		}
+	}
+	return names, nil
+}
+
+// LoadSnapshot reads the snapshot and verifies its files with the manifest.
+func LoadSnapshot(ctx context.Context, backend Backend, name string) (*Manifest, []Entry, error) {
+	data, err := backend.Get(ctx, path.Join(name, manifestFile))
+	if err != nil {
+		return nil, nil, err
+	}
+	manifest := &Manifest{}
+	if err := json.Unmarshal(data, manifest); err != nil {
+		return nil, nil, errs.ErrMetaSnapshotCorrupted.Wrap(err).GenWithStackByArgs(name, manifestFile)
+	}
+	if manifest.Version != manifestVersion {
+		return nil, nil, errs.ErrMetaSnapshotCorrupted.GenWithStackByArgs(name, fmt.Sprintf("unsupported version %d", manifest.Version))
+	}
+	var entries []Entry
+	for _, f := range manifest.Files {
+		data, err := backend.Get(ctx, path.Join(name, f.Name))
+		if err != nil {
+			return nil, nil, err
+		}
+		if len(data) != f.Size || checksum(data) != f.SHA256 {
+			return nil, nil, errs.ErrMetaSnapshotCorrupted.GenWithStackByArgs(name, f.Name+" mismatches the checksum")
+		}
+		var fileEntries []Entry
+		if err := json.Unmarshal(data, &fileEntries); err != nil || len(fileEntries) != f.Entries {
+			return nil, nil, errs.ErrMetaSnapshotCorrupted.GenWithStackByArgs(name, f.Name)
+		}
+		entries = append(entries, fileEntries...)
+	}
+	return manifest, entries, nil
+}
+
+// Restore writes the meta in the snapshot to the storage. Nothing is written
+// unless all files of the snapshot are verified.
+func Restore(ctx context.Context, backend Backend, name string, storage *core.Storage) (*Manifest, error) {
+	manifest, entries, err := LoadSnapshot(ctx, backend, name)
+	if err != nil {
+		return nil, err
+	}
+	for _, entry := range entries {
+		if err := storage.Save(entry.Key, string(entry.Value)); err != nil {
+			return nil, err
+		}
+	}
+	log.Info("meta snapshot is restored", zap.Stringer("storage", backend), zap.String("name", name), zap.Int("entries", len(entries)))
+	return manifest, nil
+}
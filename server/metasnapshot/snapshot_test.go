@@ -0,0 +1,113 @@
+// Copyright 2021 TiKV Project Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package metasnapshot
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	. "github.com/pingcap/check"
+	"github.com/pingcap/kvproto/pkg/metapb"
+	"github.com/tikv/pd/pkg/typeutil"
+	"github.com/tikv/pd/server/config"
+	"github.com/tikv/pd/server/core"
+	"github.com/tikv/pd/server/kv"
+)
+
+func TestMetaSnapshot(t *testing.T) {
+	TestingT(t)
+}
+
+var _ = Suite(&testSnapshotSuite{})
+
+type testSnapshotSuite struct{}
+
+func (s *testSnapshotSuite) TestDisabled(c *C) {
+	snapshotter, err := NewSnapshotter(&config.MetaSnapshotConfig{}, nil, 1, nil)
+	c.Assert(err, IsNil)
+	c.Assert(snapshotter, IsNil)
+	_, err = NewBackend("ftp://127.0.0.1/snapshots")
+	c.Assert(err, NotNil)
+}
+
+func (s *testSnapshotSuite) TestExportAndRestore(c *C) {
+	ctx := context.Background()
+	dir := c.MkDir()
+	storage := core.NewStorage(kv.NewMemoryKV())
+	c.Assert(storage.SaveConfig(map[string]int{"max-replicas": 5}), IsNil)
+	c.Assert(storage.SaveRule("pd-default", map[string]string{"id": "default"}), IsNil)
+	c.Assert(storage.SaveRuleGroup("pd", map[string]string{"id": "pd"}), IsNil)
+	c.Assert(storage.SaveStore(&metapb.Store{Id: 1, Address: "tikv1"}), IsNil)
+	c.Assert(storage.SaveStoreWeight(1, 2, 3), IsNil)
+	// The regions are not exported.
+	c.Assert(storage.SaveRegion(&metapb.Region{Id: 2}), IsNil)
+
+	cfg := &config.MetaSnapshotConfig{Storage: "local://" + dir, Interval: typeutil.NewDuration(time.Hour)}
+	snapshotter, err := NewSnapshotter(cfg, storage, 1, func() bool { return true })
+	c.Assert(err, IsNil)
+	name, err := snapshotter.Export(ctx)
+	c.Assert(err, IsNil)
+	time.Sleep(time.Millisecond)
+	latest, err := snapshotter.Export(ctx)
+	c.Assert(err, IsNil)
+
+	backend, err := NewBackend(cfg.Storage)
+	c.Assert(err, IsNil)
+	names, err := ListSnapshots(ctx, backend)
+	c.Assert(err, IsNil)
+	c.Assert(names, DeepEquals, []string{name, latest})
+
+	restored := core.NewStorage(kv.NewMemoryKV())
+	manifest, err := Restore(ctx, backend, latest, restored)
+	c.Assert(err, IsNil)
+	c.Assert(manifest.ClusterID, Equals, uint64(1))
+	c.Assert(manifest.Files, HasLen, len(sections))
+	var cfgs map[string]int
+	ok, err := restored.LoadConfig(&cfgs)
+	c.Assert(err, IsNil)
+	c.Assert(ok, IsTrue)
+	c.Assert(cfgs["max-replicas"], Equals, 5)
+	var rules, groups int
+	c.Assert(restored.LoadRules(func(k, v string) { rules++ }), IsNil)
+	c.Assert(restored.LoadRuleGroups(func(k, v string) { groups++ }), IsNil)
+	c.Assert(rules, Equals, 1)
+	c.Assert(groups, Equals, 1)
+	var stores []*core.StoreInfo
+	c.Assert(restored.LoadStores(func(store *core.StoreInfo) { stores = append(stores, store) }), IsNil)
+	c.Assert(stores, HasLen, 1)
+	c.Assert(stores[0].GetAddress(), Equals, "tikv1")
+	c.Assert(stores[0].GetLeaderWeight(), Equals, 2.0)
+	ok, err = restored.LoadRegion(2, &metapb.Region{})
+	c.Assert(err, IsNil)
+	c.Assert(ok, IsFalse)
+
+	// The corrupted snapshot is not restored.
+	file := filepath.Join(dir, latest, "rules.json")
+	c.Assert(os.WriteFile(file, []byte("[]"), 0644), IsNil)
+	restored = core.NewStorage(kv.NewMemoryKV())
+	_, err = Restore(ctx, backend, latest, restored)
+	c.Assert(err, NotNil)
+	ok, err = restored.LoadConfig(&cfgs)
+	c.Assert(err, IsNil)
+	c.Assert(ok, IsFalse)
+
+	// The incomplete snapshot is not listed.
+	c.Assert(os.Remove(filepath.Join(dir, name, manifestFile)), IsNil)
+	names, err = ListSnapshots(ctx, backend)
+	c.Assert(err, IsNil)
+	c.Assert(names, DeepEquals, []string{latest})
+}
@@ -0,0 +1,84 @@
+// Copyright 2021 TiKV Project Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package server
+
+import (
+	"context"
+	"strings"
+
+	"github.com/pingcap/log"
+	"github.com/tikv/pd/pkg/errs"
+	"github.com/tikv/pd/server/rbac"
+	"go.uber.org/zap"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+)
+
+// RBACAuthorizationKey is the key of the gRPC metadata carrying the token,
+// which is the same as the HTTP header.
+const RBACAuthorizationKey = "authorization"
+
+// GetRBACManager returns the manager of the role bindings.
+func (s *Server) GetRBACManager() *rbac.Manager {
+	return s.rbacManager
+}
+
+// getMemberCommonName returns the common name of the latest certificate of
+// the members, or empty if TLS is not enabled. The standalone server has no
+// other members to trust.
+func (s *Server) getMemberCommonName() string {
+	return s.certReloader.CommonName()
+}
+
+// ParseBearerToken returns the token in the value of the authorization header
+// or metadata.
+func ParseBearerToken(authorization string) string {
+	const prefix = "Bearer "
+	if len(authorization) > len(prefix) && strings.EqualFold(authorization[:len(prefix)], prefix) {
+		return authorization[len(prefix):]
+	}
+	return ""
+}
+
+// checkRole returns an error if the gRPC request is not granted the role.
+// It is only checked for the management RPCs, the data path like the
+// heartbeats and TSO is protected by TLS.
+func (s *Server) checkRole(ctx context.Context, role string) error {
+	var id rbac.Identity
+	if p, ok := peer.FromContext(ctx); ok {
+		if info, ok := p.AuthInfo.(credentials.TLSInfo); ok && len(info.State.VerifiedChains) > 0 && len(info.State.VerifiedChains[0]) > 0 {
+			id.CommonName = info.State.VerifiedChains[0][0].Subject.CommonName
+		}
+	}
+	if md, ok := metadata.FromIncomingContext(ctx); ok {
+		if values := md.Get(RBACAuthorizationKey); len(values) > 0 {
+			id.Token = ParseBearerToken(values[0])
+		}
+	}
+	err := s.rbacManager.Authorize(id, role)
+	switch {
+	case err == nil:
+		return nil
+	case errs.ErrRBACUnauthenticated.Equal(err):
+		return status.Error(codes.Unauthenticated, err.Error())
+	case errs.ErrRBACPermissionDenied.Equal(err):
+		log.Warn("rbac denies the request", zap.String("common-name", id.CommonName), errs.ZapError(err))
+		return status.Error(codes.PermissionDenied, err.Error())
+	default:
+		return status.Error(codes.Unavailable, err.Error())
+	}
+}
@@ -0,0 +1,291 @@
+// Copyright 2021 TiKV Project Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package rbac
+
+import (
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"sync"
+	"time"
+
+	"github.com/pingcap/log"
+	"github.com/tikv/pd/pkg/errs"
+	"github.com/tikv/pd/server/core"
+	"go.uber.org/zap"
+)
+
+// The roles, each of which is granted all permissions of the former ones.
+const (
+	// RoleViewer can read the states of the cluster.
+	RoleViewer = "viewer"
+	// RoleOperator can change the scheduling and the configurations.
+	RoleOperator = "operator"
+	// RoleAdmin can also remove the stores, the members and the data, and
+	// manage the role bindings.
+	RoleAdmin = "admin"
+)
+
+var roleLevels = map[string]int{RoleViewer: 1, RoleOperator: 2, RoleAdmin: 3}
+
+// The kinds of the identities.
+const (
+	// KindCert identifies the clients by the common names of their TLS
+	// certificates.
+	KindCert = "cert"
+	// KindToken identifies the clients by the static tokens they present.
+	KindToken = "token"
+)
+
+// configReloadInterval is how long the role bindings are cached before they
+// are reloaded, since they may be changed by the other members.
+const configReloadInterval = 5 * time.Second
+
+// Binding grants a role to an identity.
+type Binding struct {
+	Kind string `json:"kind"`
+	// Name is the common name of the certificate, or the name of the token.
+	Name string `json:"name"`
+	Role string `json:"role"`
+	// TokenHash is the hex of the SHA-256 of the token. The token itself is
+	// not persisted.
+	TokenHash string `json:"token-hash,omitempty"`
+}
+
+// Config is the persisted state of RBAC.
+type Config struct {
+	Enabled  bool       `json:"enabled"`
+	Bindings []*Binding `json:"bindings"`
+}
+
+func (c *Config) hasAdmin() bool {
+	for _, b := range c.Bindings {
+		if b.Role == RoleAdmin {
+			return true
+		}
+	}
+	return false
+}
+
+// Identity is who sends a request.
+type Identity struct {
+	// CommonName is the common name of the verified client certificate.
+	CommonName string
+	// Token is the token presented by the client.
+	Token string
+}
+
+// HashToken returns the hex of the SHA-256 of the token.
+func HashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+// Manager authorizes the requests by the role bindings. When RBAC is
+// disabled, all requests are allowed.
+type Manager struct {
+	sync.Mutex
+	storage *core.Storage
+	// memberCommonName returns the common name of the certificate of the PD
+	// members, which is always an admin, so the requests forwarded by the
+	// members after they are authorized are not checked again. It is called
+	// on each request, since the certificate may be rotated.
+	memberCommonName func() string
+	config           *Config
+	loadTime         time.Time
+}
+
+// NewManager creates a Manager.
+func NewManager(storage *core.Storage, memberCommonName func() string) *Manager {
+	return &Manager{storage: storage, memberCommonName: memberCommonName}
+}
+
+func (m *Manager) getConfigLocked() (*Config, error) {
+	if m.config != nil && time.Since(m.loadTime) < configReloadInterval {
+		return m.config, nil
+	}
+	config := &Config{}
+	if _, err := m.storage.LoadRBAC(config); err != nil {
+		return nil, err
+	}
+	m.config, m.loadTime = config, time.Now()
+	return config, nil
+}
+
+func (m *Manager) saveConfigLocked(config *Config) error {
+	if err := m.storage.SaveRBAC(config); err != nil {
+		return err
+	}
+	m.config, m.loadTime = config, time.Now()
+	return nil
+}
+
+// GetConfig returns the state of RBAC without the token hashes.
+func (m *Manager) GetConfig() (*Config, error) {
+	m.Lock()
+	defer m.Unlock()
+	config, err := m.getConfigLocked()
+	if err != nil {
+		return nil, err
+	}
+	res := &Config{Enabled: config.Enabled, Bindings: make([]*Binding, 0, len(config.Bindings))}
+	for _, b := range config.Bindings {
+		res.Bindings = append(res.Bindings, &Binding{Kind: b.Kind, Name: b.Name, Role: b.Role})
+	}
+	return res, nil
+}
+
+// SetEnabled enables or disables RBAC. It cannot be enabled without an admin
+// binding, so the cluster is not locked out.
+func (m *Manager) SetEnabled(enabled bool) error {
+	m.Lock()
+	defer m.Unlock()
+	config, err := m.getConfigLocked()
+	if err != nil {
+		return err
+	}
+	if enabled && !config.hasAdmin() {
+		return errs.ErrRBACNoAdmin.FastGenByArgs()
+	}
+	if err := m.saveConfigLocked(&Config{Enabled: enabled, Bindings: config.Bindings}); err != nil {
+		return err
+	}
+	log.Info("rbac is updated", zap.Bool("enabled", enabled))
+	return nil
+}
+
+// SetBinding grants the role to the identity, replacing its former binding.
+// The token is required for a token binding.
+func (m *Manager) SetBinding(kind, name, role, token string) error {
+	if kind != KindCert && kind != KindToken {
+		return errs.ErrRBACInvalidBinding.FastGenByArgs("kind should be cert or token")
+	}
+	if name == "" {
+		return errs.ErrRBACInvalidBinding.FastGenByArgs("name should not be empty")
+	}
+	if _, ok := roleLevels[role]; !ok {
+		return errs.ErrRBACInvalidBinding.FastGenByArgs("role should be viewer, operator or admin")
+	}
+	binding := &Binding{Kind: kind, Name: name, Role: role}
+	if kind == KindToken {
+		if token == "" {
+			return errs.ErrRBACInvalidBinding.FastGenByArgs("token should not be empty")
+		}
+		binding.TokenHash = HashToken(token)
+	}
+
+	m.Lock()
+	defer m.Unlock()
+	config, err := m.getConfigLocked()
+	if err != nil {
+		return err
+	}
+	newConfig := &Config{Enabled: config.Enabled, Bindings: []*Binding{binding}}
+	for _, b := range config.Bindings {
+		if b.Kind != kind || b.Name != name {
+			newConfig.Bindings = append(newConfig.Bindings, b)
+		}
+	}
+	if newConfig.Enabled && !newConfig.hasAdmin() {
+		return errs.ErrRBACNoAdmin.FastGenByArgs()
+	}
+	if err := m.saveConfigLocked(newConfig); err != nil {
+		return err
+	}
+	log.Info("role binding is updated", zap.String("kind", kind), zap.String("name", name), zap.String("role", role))
+	return nil
+}
+
+// DeleteBinding deletes the binding of the identity. The last admin binding
+// cannot be deleted when RBAC is enabled.
+func (m *Manager) DeleteBinding(kind, name string) error {
+	m.Lock()
+	defer m.Unlock()
+	config, err := m.getConfigLocked()
+	if err != nil {
+		return err
+	}
+	newConfig := &Config{Enabled: config.Enabled}
+	for _, b := range config.Bindings {
+		if b.Kind != kind || b.Name != name {
+			newConfig.Bindings = append(newConfig.Bindings, b)
+		}
+	}
+	if newConfig.Enabled && !newConfig.hasAdmin() {
+		return errs.ErrRBACNoAdmin.FastGenByArgs()
+	}
+	if err := m.saveConfigLocked(newConfig); err != nil {
+		return err
+	}
+	log.Info("role binding is deleted", zap.String("kind", kind), zap.String("name", name))
+	return nil
+}
+
+// GetRole returns the highest role of the identity, or empty if it has no
+// role.
+func (m *Manager) GetRole(id Identity) (string, error) {
+	m.Lock()
+	config, err := m.getConfigLocked()
+	m.Unlock()
+	if err != nil {
+		return "", err
+	}
+	return config.getRole(id, m.memberCommonName()), nil
+}
+
+func (c *Config) getRole(id Identity, memberCommonName string) string {
+	if id.CommonName != "" && id.CommonName == memberCommonName {
+		return RoleAdmin
+	}
+	var role string
+	var tokenHash []byte
+	if id.Token != "" {
+		tokenHash = []byte(HashToken(id.Token))
+	}
+	for _, b := range c.Bindings {
+		var matched bool
+		switch b.Kind {
+		case KindCert:
+			matched = id.CommonName != "" && b.Name == id.CommonName
+		case KindToken:
+			matched = tokenHash != nil && subtle.ConstantTimeCompare(tokenHash, []byte(b.TokenHash)) == 1
+		}
+		if matched && roleLevels[b.Role] > roleLevels[role] {
+			role = b.Role
+		}
+	}
+	return role
+}
+
+// Authorize returns an error if RBAC is enabled and the identity is not
+// granted the required role.
+func (m *Manager) Authorize(id Identity, required string) error {
+	m.Lock()
+	config, err := m.getConfigLocked()
+	m.Unlock()
+	if err != nil {
+		return err
+	}
+	if !config.Enabled {
+		return nil
+	}
+	role := config.getRole(id, m.memberCommonName())
+	if role == "" {
+		return errs.ErrRBACUnauthenticated.FastGenByArgs()
+	}
+	if roleLevels[role] < roleLevels[required] {
+		return errs.ErrRBACPermissionDenied.FastGenByArgs(required, role)
+	}
+	return nil
+}
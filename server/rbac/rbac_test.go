@@ -0,0 +1,104 @@
+// Copyright 2021 TiKV Project Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package rbac
+
+import (
+	"testing"
+
+	. "github.com/pingcap/check"
+	"github.com/tikv/pd/pkg/errs"
+	"github.com/tikv/pd/server/core"
+	"github.com/tikv/pd/server/kv"
+)
+
+func TestRBAC(t *testing.T) {
+	TestingT(t)
+}
+
+var _ = Suite(&testRBACSuite{})
+
+type testRBACSuite struct{}
+
+func (s *testRBACSuite) TestAuthorize(c *C) {
+	storage := core.NewStorage(kv.NewMemoryKV())
+	memberCommonName := "pd-member"
+	m := NewManager(storage, func() string { return memberCommonName })
+	anonymous := Identity{}
+	viewer := Identity{CommonName: "dashboard"}
+	operator := Identity{Token: "operator-token"}
+	admin := Identity{Token: "admin-token"}
+
+	// All requests are allowed when RBAC is disabled.
+	c.Assert(m.Authorize(anonymous, RoleAdmin), IsNil)
+
+	// RBAC cannot be enabled without an admin.
+	c.Assert(errs.ErrRBACNoAdmin.Equal(m.SetEnabled(true)), IsTrue)
+	c.Assert(errs.ErrRBACInvalidBinding.Equal(m.SetBinding("user", "a", RoleAdmin, "")), IsTrue)
+	c.Assert(errs.ErrRBACInvalidBinding.Equal(m.SetBinding(KindToken, "a", RoleAdmin, "")), IsTrue)
+	c.Assert(errs.ErrRBACInvalidBinding.Equal(m.SetBinding(KindCert, "a", "root", "")), IsTrue)
+	c.Assert(m.SetBinding(KindCert, "dashboard", RoleViewer, ""), IsNil)
+	c.Assert(m.SetBinding(KindToken, "ops", RoleOperator, "operator-token"), IsNil)
+	c.Assert(m.SetBinding(KindToken, "root", RoleAdmin, "admin-token"), IsNil)
+	c.Assert(m.SetEnabled(true), IsNil)
+
+	c.Assert(errs.ErrRBACUnauthenticated.Equal(m.Authorize(anonymous, RoleViewer)), IsTrue)
+	c.Assert(errs.ErrRBACUnauthenticated.Equal(m.Authorize(Identity{Token: "wrong"}, RoleViewer)), IsTrue)
+	c.Assert(m.Authorize(viewer, RoleViewer), IsNil)
+	c.Assert(errs.ErrRBACPermissionDenied.Equal(m.Authorize(viewer, RoleOperator)), IsTrue)
+	c.Assert(m.Authorize(operator, RoleOperator), IsNil)
+	c.Assert(errs.ErrRBACPermissionDenied.Equal(m.Authorize(operator, RoleAdmin)), IsTrue)
+	c.Assert(m.Authorize(admin, RoleAdmin), IsNil)
+	// The members are trusted.
+	c.Assert(m.Authorize(Identity{CommonName: "pd-member"}, RoleAdmin), IsNil)
+	// The rotated certificate of the members is trusted at once.
+	memberCommonName = "pd-member-2"
+	c.Assert(m.Authorize(Identity{CommonName: "pd-member-2"}, RoleAdmin), IsNil)
+	c.Assert(errs.ErrRBACUnauthenticated.Equal(m.Authorize(Identity{CommonName: "pd-member"}, RoleAdmin)), IsTrue)
+	// The highest role of the identity is used.
+	c.Assert(m.Authorize(Identity{CommonName: "dashboard", Token: "admin-token"}, RoleAdmin), IsNil)
+
+	// The tokens are not exposed or persisted.
+	config, err := m.GetConfig()
+	c.Assert(err, IsNil)
+	c.Assert(config.Enabled, IsTrue)
+	c.Assert(config.Bindings, HasLen, 3)
+	for _, b := range config.Bindings {
+		c.Assert(b.TokenHash, Equals, "")
+	}
+	persisted := &Config{}
+	ok, err := storage.LoadRBAC(persisted)
+	c.Assert(ok, IsTrue)
+	c.Assert(err, IsNil)
+	c.Assert(persisted.Bindings[0].TokenHash, Equals, HashToken("admin-token"))
+
+	// The last admin cannot be removed or downgraded.
+	c.Assert(errs.ErrRBACNoAdmin.Equal(m.DeleteBinding(KindToken, "root")), IsTrue)
+	c.Assert(errs.ErrRBACNoAdmin.Equal(m.SetBinding(KindToken, "root", RoleViewer, "admin-token")), IsTrue)
+	c.Assert(m.Authorize(admin, RoleAdmin), IsNil)
+
+	// The role binding is replaced.
+	c.Assert(m.SetBinding(KindCert, "dashboard", RoleOperator, ""), IsNil)
+	c.Assert(m.Authorize(viewer, RoleOperator), IsNil)
+	c.Assert(m.DeleteBinding(KindCert, "dashboard"), IsNil)
+	c.Assert(errs.ErrRBACUnauthenticated.Equal(m.Authorize(viewer, RoleViewer)), IsTrue)
+
+	// The bindings are loaded by the other managers.
+	other := NewManager(storage, func() string { return "" })
+	c.Assert(other.Authorize(operator, RoleOperator), IsNil)
+	c.Assert(errs.ErrRBACUnauthenticated.Equal(other.Authorize(anonymous, RoleViewer)), IsTrue)
+
+	c.Assert(m.SetEnabled(false), IsNil)
+	c.Assert(m.DeleteBinding(KindToken, "root"), IsNil)
+	c.Assert(m.Authorize(anonymous, RoleAdmin), IsNil)
+}
@@ -0,0 +1,95 @@
+// Copyright 2021 TiKV Project Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package server
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+
+	"github.com/juju/ratelimit"
+	"github.com/pingcap/log"
+	"github.com/tikv/pd/pkg/errs"
+	"go.etcd.io/etcd/pkg/transport"
+	"go.uber.org/zap"
+)
+
+// newReadAPIHandler serves the read-only requests with the handlers, and
+// rejects the others. The requests beyond the rate limit are rejected too.
+func newReadAPIHandler(handlers map[string]http.Handler, rateLimit int) http.Handler {
+	mux := http.NewServeMux()
+	for path, handler := range handlers {
+		mux.Handle(path, handler)
+	}
+	var limiter *ratelimit.Bucket
+	if rateLimit > 0 {
+		limiter = ratelimit.NewBucketWithRate(float64(rateLimit), int64(rateLimit))
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method {
+		case http.MethodGet, http.MethodHead, http.MethodOptions:
+		default:
+			readAPIRejectedCounter.WithLabelValues("method").Inc()
+			w.Header().Set("Allow", "GET, HEAD, OPTIONS")
+			http.Error(w, "only the read-only requests are served on this listener", http.StatusMethodNotAllowed)
+			return
+		}
+		if limiter != nil && limiter.TakeAvailable(1) == 0 {
+			readAPIRejectedCounter.WithLabelValues("rate-limit").Inc()
+			http.Error(w, "too many requests to the read-only API", http.StatusTooManyRequests)
+			return
+		}
+		mux.ServeHTTP(w, r)
+	})
+}
+
+// startReadAPIServer serves the read-only API on the separate listener if it
+// is configured. The listener shares the TLS config with the client URLs.
+func (s *Server) startReadAPIServer() error {
+	cfg := s.cfg.ReadAPI
+	if cfg.ListenURL == "" || len(s.etcdCfg.UserHandlers) == 0 {
+		return nil
+	}
+	u, err := url.Parse(cfg.ListenURL)
+	if err != nil {
+		return errs.ErrURLParse.Wrap(err).GenWithStackByCause()
+	}
+	l, err := transport.NewListener(u.Host, u.Scheme, &s.etcdCfg.ClientTLSInfo)
+	if err != nil {
+		return errs.ErrStartReadAPI.Wrap(err).GenWithStackByCause()
+	}
+	if cfg.MaxConnections > 0 {
+		l = transport.LimitListener(l, cfg.MaxConnections)
+	}
+	s.readAPIServer = &http.Server{Handler: newReadAPIHandler(s.etcdCfg.UserHandlers, cfg.RateLimit)}
+	go func(srv *http.Server) {
+		if err := srv.Serve(l); err != nil && err != http.ErrServerClosed {
+			log.Error("the read-only API server stops unexpectedly", errs.ZapError(errs.ErrStartReadAPI, err))
+		}
+	}(s.readAPIServer)
+	log.Info("serve the read-only API", zap.String("url", cfg.ListenURL),
+		zap.Int("max-connections", cfg.MaxConnections), zap.Int("rate-limit", cfg.RateLimit))
+	return nil
+}
+
+func (s *Server) stopReadAPIServer() {
+	if s.readAPIServer == nil {
+		return
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), etcdTimeout)
+	defer cancel()
+	if err := s.readAPIServer.Shutdown(ctx); err != nil {
+		log.Warn("stop the read-only API server meet error", errs.ZapError(err))
+	}
+}
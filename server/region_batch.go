@@ -0,0 +1,95 @@
+// Copyright 2021 TiKV Project Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package server
+
+import (
+	"bytes"
+	"context"
+	"sort"
+
+	"github.com/pingcap/kvproto/pkg/metapb"
+	"github.com/pingcap/kvproto/pkg/pdpb"
+	"github.com/tikv/pd/pkg/grpcutil"
+	"github.com/tikv/pd/pkg/regionpb"
+	"github.com/tikv/pd/server/rbac"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// maxBatchGetRegionKeys is the maximum number of keys in a request.
+const maxBatchGetRegionKeys = 10240
+
+// regionService serves the region service generated in regionpb, which is
+// not a part of kvproto yet. The Server can't serve it directly, since its
+// ScanRegions is the one of the PD service.
+type regionService struct {
+	s *Server
+}
+
+// BatchGetRegion implements gRPC RegionsServer.
+func (r *regionService) BatchGetRegion(ctx context.Context, request *regionpb.BatchGetRegionRequest) (*regionpb.BatchGetRegionResponse, error) {
+	return r.s.BatchGetRegion(ctx, request)
+}
+
+// ScanRegions implements gRPC RegionsServer.
+func (r *regionService) ScanRegions(request *regionpb.ScanRegionsStreamRequest, stream regionpb.Regions_ScanRegionsServer) error {
+	return r.s.ScanRegionsStream(request, stream)
+}
+
+// BatchGetRegion gets the regions and their leaders of a batch of keys with a
+// single scan of the region tree.
+func (s *Server) BatchGetRegion(ctx context.Context, request *regionpb.BatchGetRegionRequest) (*regionpb.BatchGetRegionResponse, error) {
+	if err := s.checkRole(ctx, rbac.RoleViewer); err != nil {
+		return nil, err
+	}
+	forwardedHost := getForwardedHost(ctx)
+	if !s.isLocalRequest(forwardedHost) {
+		client, err := s.getDelegateClient(ctx, forwardedHost)
+		if err != nil {
+			return nil, err
+		}
+		ctx = grpcutil.ResetForwardContext(ctx)
+		return regionpb.NewRegionsClient(client).BatchGetRegion(ctx, request)
+	}
+
+	if err := s.validateRequest(request.Header); err != nil {
+		return nil, err
+	}
+	keys := request.Keys
+	if len(keys) > maxBatchGetRegionKeys {
+		return nil, status.Errorf(codes.InvalidArgument, "too many keys, the limit is %d", maxBatchGetRegionKeys)
+	}
+	if !sort.SliceIsSorted(keys, func(i, j int) bool { return bytes.Compare(keys[i], keys[j]) < 0 }) {
+		return nil, status.Errorf(codes.InvalidArgument, "keys should be sorted")
+	}
+
+	rc := s.GetRaftCluster()
+	if rc == nil {
+		return &regionpb.BatchGetRegionResponse{Header: s.notBootstrappedHeader()}, nil
+	}
+	resp := &regionpb.BatchGetRegionResponse{Header: s.header()}
+	for _, r := range rc.GetRegionsByKeys(keys) {
+		leader := r.GetLeader()
+		if leader == nil {
+			leader = &metapb.Peer{}
+		}
+		resp.Regions = append(resp.Regions, &pdpb.Region{
+			Region:       r.GetMeta(),
+			Leader:       leader,
+			DownPeers:    r.GetDownPeers(),
+			PendingPeers: r.GetPendingPeers(),
+		})
+	}
+	return resp, nil
+}
@@ -0,0 +1,79 @@
+// Copyright 2021 TiKV Project Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package server
+
+import (
+	"context"
+
+	. "github.com/pingcap/check"
+	"github.com/pingcap/kvproto/pkg/metapb"
+	"github.com/pingcap/kvproto/pkg/pdpb"
+	"github.com/tikv/pd/pkg/grpcutil"
+	"github.com/tikv/pd/pkg/regionpb"
+	"github.com/tikv/pd/server/core"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+var _ = Suite(&testRegionBatchSuite{})
+
+type testRegionBatchSuite struct{}
+
+func (s *testRegionBatchSuite) TestBatchGetRegion(c *C) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	svrs, cleanup := newTestServersWithCfgs(ctx, c, NewTestMultiConfig(c, 1))
+	defer cleanup()
+	svr := svrs[0]
+
+	store := &metapb.Store{Id: 1, Address: "tikv1"}
+	peer := &metapb.Peer{Id: 2, StoreId: 1}
+	_, err := svr.bootstrapCluster(&pdpb.BootstrapRequest{
+		Header: &pdpb.RequestHeader{ClusterId: svr.ClusterID()},
+		Store:  store,
+		Region: &metapb.Region{Id: 3, Peers: []*metapb.Peer{peer}},
+	})
+	c.Assert(err, IsNil)
+	rc := svr.GetRaftCluster()
+	// The regions are [, b), [b, d) and [e, ).
+	regionKeys := map[uint64][2]string{3: {"", "b"}, 11: {"b", "d"}, 21: {"e", ""}}
+	for _, id := range []uint64{3, 11, 21} {
+		region := &metapb.Region{
+			Id:          id,
+			StartKey:    []byte(regionKeys[id][0]),
+			EndKey:      []byte(regionKeys[id][1]),
+			RegionEpoch: &metapb.RegionEpoch{Version: 2},
+			Peers:       []*metapb.Peer{{Id: id + 1, StoreId: 1}},
+		}
+		c.Assert(rc.HandleRegionHeartbeat(core.NewRegionInfo(region, region.Peers[0])), IsNil)
+	}
+
+	cc, err := grpcutil.GetClientConn(ctx, svr.GetAddr(), nil)
+	c.Assert(err, IsNil)
+	defer cc.Close()
+	header := &pdpb.RequestHeader{ClusterId: svr.ClusterID()}
+	keys := [][]byte{[]byte(""), []byte("a"), []byte("c"), []byte("c1"), []byte("d"), []byte("x")}
+	resp, err := regionpb.NewRegionsClient(cc).BatchGetRegion(ctx, &regionpb.BatchGetRegionRequest{Header: header, Keys: keys})
+	c.Assert(err, IsNil)
+	c.Assert(resp.Header.GetError(), IsNil)
+	// The key "d" is in a hole, and the regions are deduplicated.
+	c.Assert(resp.Regions, HasLen, 3)
+	for i, id := range []uint64{3, 11, 21} {
+		c.Assert(resp.Regions[i].GetRegion().GetId(), Equals, id)
+		c.Assert(resp.Regions[i].GetLeader().GetStoreId(), Equals, uint64(1))
+	}
+
+	_, err = regionpb.NewRegionsClient(cc).BatchGetRegion(ctx, &regionpb.BatchGetRegionRequest{Header: header, Keys: [][]byte{[]byte("b"), []byte("a")}})
+	c.Assert(status.Code(err), Equals, codes.InvalidArgument)
+}
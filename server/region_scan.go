@@ -0,0 +1,106 @@
+// Copyright 2021 TiKV Project Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package server
+
+import (
+	"github.com/pingcap/kvproto/pkg/metapb"
+	"github.com/pingcap/kvproto/pkg/pdpb"
+	"github.com/tikv/pd/pkg/regionpb"
+	"github.com/tikv/pd/server/core"
+	"github.com/tikv/pd/server/rbac"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+const (
+	// defaultScanChunkSize is the default number of regions scanned for a chunk.
+	defaultScanChunkSize = 1024
+	// maxScanChunkSize is the maximum number of regions scanned for a chunk.
+	maxScanChunkSize = 10240
+)
+
+// ScanRegionsStream streams the regions in chunks, so that the tools can scan
+// all regions without huge responses.
+func (s *Server) ScanRegionsStream(request *regionpb.ScanRegionsStreamRequest, stream regionpb.Regions_ScanRegionsServer) error {
+	if err := s.checkRole(stream.Context(), rbac.RoleViewer); err != nil {
+		return err
+	}
+	if err := s.validateRequest(request.Header); err != nil {
+		return err
+	}
+	chunkSize := int(request.ChunkSize)
+	if chunkSize == 0 {
+		chunkSize = defaultScanChunkSize
+	}
+	if chunkSize < 0 || chunkSize > maxScanChunkSize {
+		return status.Errorf(codes.InvalidArgument, "invalid chunk size, it should be in (0, %d]", maxScanChunkSize)
+	}
+
+	rc := s.GetRaftCluster()
+	if rc == nil {
+		return stream.Send(&regionpb.ScanRegionsStreamResponse{Header: s.notBootstrappedHeader()})
+	}
+	cursor := request.Cursor
+	for {
+		select {
+		case <-stream.Context().Done():
+			return stream.Context().Err()
+		default:
+		}
+		regions := rc.ScanRegions(cursor, request.EndKey, chunkSize)
+		resp := &regionpb.ScanRegionsStreamResponse{Header: s.header()}
+		for _, r := range regions {
+			if !matchScanFilters(request, r) {
+				continue
+			}
+			leader := r.GetLeader()
+			if leader == nil {
+				leader = &metapb.Peer{}
+			}
+			resp.Regions = append(resp.Regions, &pdpb.Region{
+				Region:       r.GetMeta(),
+				Leader:       leader,
+				DownPeers:    r.GetDownPeers(),
+				PendingPeers: r.GetPendingPeers(),
+			})
+		}
+		if len(regions) > 0 {
+			cursor = regions[len(regions)-1].GetEndKey()
+		}
+		resp.Cursor = cursor
+		resp.Finished = len(regions) < chunkSize || len(cursor) == 0
+		// The chunks without any matched regions are skipped except the last one.
+		if len(resp.Regions) > 0 || resp.Finished {
+			if err := stream.Send(resp); err != nil {
+				return err
+			}
+		}
+		if resp.Finished {
+			return nil
+		}
+	}
+}
+
+func matchScanFilters(request *regionpb.ScanRegionsStreamRequest, region *core.RegionInfo) bool {
+	if request.HasDownPeer && len(region.GetDownPeers()) == 0 {
+		return false
+	}
+	if request.HasPendingPeer && len(region.GetPendingPeers()) == 0 {
+		return false
+	}
+	if request.StoreId != 0 && region.GetStorePeer(request.StoreId) == nil {
+		return false
+	}
+	return true
+}
@@ -0,0 +1,117 @@
+// Copyright 2021 TiKV Project Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package server
+
+import (
+	"context"
+
+	. "github.com/pingcap/check"
+	"github.com/pingcap/kvproto/pkg/metapb"
+	"github.com/pingcap/kvproto/pkg/pdpb"
+	"github.com/tikv/pd/pkg/grpcutil"
+	"github.com/tikv/pd/pkg/regionpb"
+	"github.com/tikv/pd/server/core"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+var _ = Suite(&testRegionScanSuite{})
+
+type testRegionScanSuite struct{}
+
+func scanAllChunks(ctx context.Context, c *C, cc *grpc.ClientConn, req *regionpb.ScanRegionsStreamRequest) []*regionpb.ScanRegionsStreamResponse {
+	stream, err := regionpb.NewRegionsClient(cc).ScanRegions(ctx, req)
+	c.Assert(err, IsNil)
+	var chunks []*regionpb.ScanRegionsStreamResponse
+	for {
+		resp, err := stream.Recv()
+		c.Assert(err, IsNil)
+		chunks = append(chunks, resp)
+		if resp.Finished {
+			return chunks
+		}
+	}
+}
+
+func (s *testRegionScanSuite) TestScanRegionsStream(c *C) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	svrs, cleanup := newTestServersWithCfgs(ctx, c, NewTestMultiConfig(c, 1))
+	defer cleanup()
+	svr := svrs[0]
+
+	store := &metapb.Store{Id: 1, Address: "tikv1"}
+	peer := &metapb.Peer{Id: 2, StoreId: 1}
+	_, err := svr.bootstrapCluster(&pdpb.BootstrapRequest{
+		Header: &pdpb.RequestHeader{ClusterId: svr.ClusterID()},
+		Store:  store,
+		Region: &metapb.Region{Id: 3, Peers: []*metapb.Peer{peer}},
+	})
+	c.Assert(err, IsNil)
+	rc := svr.GetRaftCluster()
+	// The regions are [, b), [b, d) and [e, ), and the region 11 has a pending peer.
+	regionKeys := map[uint64][2]string{3: {"", "b"}, 11: {"b", "d"}, 21: {"e", ""}}
+	for _, id := range []uint64{3, 11, 21} {
+		region := &metapb.Region{
+			Id:          id,
+			StartKey:    []byte(regionKeys[id][0]),
+			EndKey:      []byte(regionKeys[id][1]),
+			RegionEpoch: &metapb.RegionEpoch{Version: 2},
+			Peers:       []*metapb.Peer{{Id: id + 1, StoreId: 1}, {Id: id + 2, StoreId: 2}},
+		}
+		var opts []core.RegionCreateOption
+		if id == 11 {
+			opts = append(opts, core.WithPendingPeers(region.Peers[1:]))
+		}
+		c.Assert(rc.HandleRegionHeartbeat(core.NewRegionInfo(region, region.Peers[0], opts...)), IsNil)
+	}
+
+	cc, err := grpcutil.GetClientConn(ctx, svr.GetAddr(), nil)
+	c.Assert(err, IsNil)
+	defer cc.Close()
+	header := &pdpb.RequestHeader{ClusterId: svr.ClusterID()}
+
+	// Each chunk has at most one region.
+	chunks := scanAllChunks(ctx, c, cc, &regionpb.ScanRegionsStreamRequest{Header: header, ChunkSize: 1})
+	c.Assert(chunks, HasLen, 3)
+	for i, id := range []uint64{3, 11, 21} {
+		c.Assert(chunks[i].Regions, HasLen, 1)
+		c.Assert(chunks[i].Regions[0].GetRegion().GetId(), Equals, id)
+	}
+	c.Assert(chunks[1].Cursor, DeepEquals, []byte("d"))
+	c.Assert(chunks[1].Finished, IsFalse)
+
+	// The scan is resumed with the cursor.
+	chunks = scanAllChunks(ctx, c, cc, &regionpb.ScanRegionsStreamRequest{Header: header, Cursor: []byte("d")})
+	c.Assert(chunks, HasLen, 1)
+	c.Assert(chunks[0].Regions, HasLen, 1)
+	c.Assert(chunks[0].Regions[0].GetRegion().GetId(), Equals, uint64(21))
+
+	// The chunks without any matched regions are skipped.
+	chunks = scanAllChunks(ctx, c, cc, &regionpb.ScanRegionsStreamRequest{Header: header, ChunkSize: 1, HasPendingPeer: true})
+	c.Assert(chunks, HasLen, 2)
+	c.Assert(chunks[0].Regions[0].GetRegion().GetId(), Equals, uint64(11))
+	chunks = scanAllChunks(ctx, c, cc, &regionpb.ScanRegionsStreamRequest{Header: header, HasDownPeer: true})
+	c.Assert(chunks, HasLen, 1)
+	c.Assert(chunks[0].Regions, HasLen, 0)
+	chunks = scanAllChunks(ctx, c, cc, &regionpb.ScanRegionsStreamRequest{Header: header, StoreId: 2, EndKey: []byte("c")})
+	c.Assert(chunks, HasLen, 1)
+	c.Assert(chunks[0].Regions, HasLen, 2)
+
+	stream, err := regionpb.NewRegionsClient(cc).ScanRegions(ctx, &regionpb.ScanRegionsStreamRequest{Header: header, ChunkSize: -1})
+	c.Assert(err, IsNil)
+	_, err = stream.Recv()
+	c.Assert(status.Code(err), Equals, codes.InvalidArgument)
+}
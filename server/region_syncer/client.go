@@ -61,15 +61,10 @@ func (s *RegionSyncer) reset() {
 func (s *RegionSyncer) establish(addr string) (*grpc.ClientConn, error) {
 	s.reset()
 	ctx, cancel := context.WithCancel(s.server.LoopContext())
-	tlsCfg, err := s.tlsConfig.ToTLSConfig()
-	if err != nil {
-		cancel()
-		return nil, err
-	}
 	cc, err := grpcutil.GetClientConn(
 		ctx,
 		addr,
-		tlsCfg,
+		s.server.GetClientTLSConfig(),
 		grpc.WithDefaultCallOptions(grpc.MaxCallRecvMsgSize(msgSize)),
 		grpc.WithKeepaliveParams(keepalive.ClientParameters{
 			Time:    keepaliveTime,
@@ -173,6 +168,7 @@ func (s *RegionSyncer) StartSyncWithLeader(addr string) {
 				continue
 			}
 			log.Info("server starts to synchronize with leader", zap.String("server", s.server.Name()), zap.String("leader", s.server.GetLeader().GetName()), zap.Uint64("request-index", s.history.GetNextIndex()))
+			first := true
 			for {
 				resp, err := stream.Recv()
 				if err != nil {
@@ -180,9 +176,12 @@ func (s *RegionSyncer) StartSyncWithLeader(addr string) {
 					if err = stream.CloseSend(); err != nil {
 						log.Error("failed to terminate client stream", errs.ZapError(errs.ErrGRPCCloseSend, err))
 					}
+					s.staleCleaner.abort()
 					time.Sleep(time.Second)
 					break
 				}
+				s.checkFullSync(resp, first)
+				first = false
 				if s.history.GetNextIndex() != resp.GetStartIndex() {
 					log.Warn("server sync index not match the leader",
 						zap.String("server", s.server.Name()),
@@ -215,6 +214,7 @@ func (s *RegionSyncer) StartSyncWithLeader(addr string) {
 						region = core.NewRegionInfo(r, regionLeader)
 					}
 
+					s.staleCleaner.observe(region.GetID())
 					s.server.GetBasicCluster().CheckAndPutRegion(region)
 					err = s.server.GetStorage().SaveRegion(r)
 					if err == nil {
@@ -225,3 +225,24 @@ func (s *RegionSyncer) StartSyncWithLeader(addr string) {
 		}
 	}()
 }
+
+// checkFullSync tracks the full synchronization with the leader. The leader
+// does a full synchronization only at the beginning of a stream, with the
+// start index 0, and the batches are continuous. It finishes once a response
+// is not continuous or has no regions, such as a keepalive. The regions not
+// received are cleaned up asynchronously then.
+func (s *RegionSyncer) checkFullSync(resp *pdpb.SyncRegionResponse, first bool) {
+	if s.staleCleaner.isSyncing() && (len(resp.GetRegions()) == 0 || resp.GetStartIndex() != s.history.GetNextIndex()) {
+		if epoch, ok := s.staleCleaner.finish(); ok {
+			s.wg.Add(1)
+			go func() {
+				defer s.wg.Done()
+				s.staleCleaner.cleanup(epoch)
+			}()
+		}
+	}
+	if first && resp.GetStartIndex() == 0 && len(resp.GetRegions()) > 0 {
+		log.Info("server starts full synchronization with leader", zap.String("server", s.server.Name()))
+		s.staleCleaner.begin()
+	}
+}
@@ -125,6 +125,12 @@ func (h *historyBuffer) GetNextIndex() uint64 {
 	return h.index
 }
 
+func (h *historyBuffer) GetFirstIndex() uint64 {
+	h.RLock()
+	defer h.RUnlock()
+	return h.firstIndex()
+}
+
 func (h *historyBuffer) get(index uint64) *core.RegionInfo {
 	if index < h.nextIndex() && index >= h.firstIndex() {
 		pos := (h.head + int(index-h.firstIndex())) % h.size
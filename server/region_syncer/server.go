@@ -15,6 +15,7 @@ package syncer
 
 import (
 	"context"
+	"crypto/tls"
 	"io"
 	"sync"
 	"time"
@@ -25,7 +26,6 @@ import (
 	"github.com/pingcap/kvproto/pkg/pdpb"
 	"github.com/pingcap/log"
 	"github.com/tikv/pd/pkg/errs"
-	"github.com/tikv/pd/pkg/grpcutil"
 	"github.com/tikv/pd/server/core"
 	"go.uber.org/zap"
 	"google.golang.org/grpc/codes"
@@ -61,7 +61,7 @@ type Server interface {
 	GetStorage() *core.Storage
 	Name() string
 	GetRegions() []*core.RegionInfo
-	GetTLSConfig() *grpcutil.TLSConfig
+	GetClientTLSConfig() *tls.Config
 	GetBasicCluster() *core.BasicCluster
 }
 
@@ -74,11 +74,13 @@ type RegionSyncer struct {
 		regionSyncerCancel context.CancelFunc
 		closed             chan struct{}
 	}
-	server    Server
-	wg        sync.WaitGroup
-	history   *historyBuffer
-	limit     *ratelimit.Bucket
-	tlsConfig *grpcutil.TLSConfig
+	server  Server
+	wg      sync.WaitGroup
+	history *historyBuffer
+	limit   *ratelimit.Bucket
+	// staleCleaner is used by the follower to drop the stale regions after a
+	// full synchronization.
+	staleCleaner *staleRegionCleaner
 }
 
 // NewRegionSyncer returns a region syncer.
@@ -88,11 +90,11 @@ type RegionSyncer struct {
 // no longer etcd but go-leveldb.
 func NewRegionSyncer(s Server) *RegionSyncer {
 	syncer := &RegionSyncer{
-		server:    s,
-		history:   newHistoryBuffer(defaultHistoryBufferSize, s.GetStorage().GetRegionStorage()),
-		limit:     ratelimit.NewBucketWithRate(defaultBucketRate, defaultBucketCapacity),
-		tlsConfig: s.GetTLSConfig(),
+		server:  s,
+		history: newHistoryBuffer(defaultHistoryBufferSize, s.GetStorage().GetRegionStorage()),
+		limit:   ratelimit.NewBucketWithRate(defaultBucketRate, defaultBucketCapacity),
 	}
+	syncer.staleCleaner = newStaleRegionCleaner(s.GetBasicCluster(), s.GetStorage())
 	syncer.mu.streams = make(map[string]ServerStream)
 	syncer.mu.closed = make(chan struct{})
 	return syncer
@@ -174,12 +176,19 @@ func (s *RegionSyncer) syncHistoryRegion(request *pdpb.SyncRegionRequest, stream
 	startIndex := request.GetStartIndex()
 	name := request.GetMember().GetName()
 	records := s.history.RecordsFrom(startIndex)
-	if len(records) == 0 {
-		if s.history.GetNextIndex() == startIndex {
+	// The requested server without any history needs all regions, even if
+	// the history from index 0 is still kept, so it can drop the stale ones.
+	if len(records) == 0 || startIndex == 0 {
+		if startIndex != 0 && s.history.GetNextIndex() == startIndex {
 			log.Info("requested server has already in sync with server",
 				zap.String("requested-server", name), zap.String("server", s.server.Name()), zap.Uint64("last-index", startIndex))
 			return nil
 		}
+		if startIndex != 0 && startIndex < s.history.GetFirstIndex() {
+			log.Warn("requested server falls too far behind the history, do full synchronization",
+				zap.String("requested-server", name), zap.Uint64("index", startIndex), zap.Uint64("first-index", s.history.GetFirstIndex()))
+			startIndex = 0
+		}
 		// do full synchronization
 		if startIndex == 0 {
 			regions := s.server.GetRegions()
@@ -213,6 +222,7 @@ func (s *RegionSyncer) syncHistoryRegion(request *pdpb.SyncRegionRequest, stream
 				}
 				metas = metas[:0]
 				stats = stats[:0]
+				leaders = leaders[:0]
 			}
 			log.Info("requested server has completed full synchronization with server",
 				zap.String("requested-server", name), zap.String("server", s.server.Name()), zap.Duration("cost", time.Since(start)))
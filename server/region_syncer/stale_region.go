@@ -0,0 +1,135 @@
+// Copyright 2021 TiKV Project Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package syncer
+
+import (
+	"sync"
+	"time"
+
+	"github.com/pingcap/log"
+	"github.com/tikv/pd/pkg/errs"
+	"github.com/tikv/pd/server/core"
+	"go.uber.org/zap"
+)
+
+// staleRegionCleaner drops the regions lingering in the cache of a follower
+// after a full synchronization. Each full synchronization starts a new epoch,
+// in which the received regions are recorded. Once it finishes, the regions
+// not received in the epoch are removed asynchronously.
+type staleRegionCleaner struct {
+	sync.Mutex
+	basicCluster *core.BasicCluster
+	storage      *core.Storage
+	epoch        uint64
+	// syncing is whether a full synchronization is in progress.
+	syncing bool
+	// seen is the regions received since the full synchronization of the
+	// epoch starts. It is nil if there is no epoch to clean up.
+	seen map[uint64]struct{}
+}
+
+func newStaleRegionCleaner(basicCluster *core.BasicCluster, storage *core.Storage) *staleRegionCleaner {
+	return &staleRegionCleaner{basicCluster: basicCluster, storage: storage}
+}
+
+// begin starts a new epoch for a full synchronization. The cleanup of the
+// previous epoch is abandoned if it is still running.
+func (c *staleRegionCleaner) begin() {
+	c.Lock()
+	defer c.Unlock()
+	c.epoch++
+	c.syncing = true
+	c.seen = make(map[uint64]struct{})
+}
+
+// isSyncing returns whether a full synchronization is in progress.
+func (c *staleRegionCleaner) isSyncing() bool {
+	c.Lock()
+	defer c.Unlock()
+	return c.syncing
+}
+
+// observe records that the region is received. It should be called before
+// the region is put into the cache.
+func (c *staleRegionCleaner) observe(regionID uint64) {
+	c.Lock()
+	defer c.Unlock()
+	if c.seen != nil {
+		c.seen[regionID] = struct{}{}
+	}
+}
+
+// finish ends the full synchronization and returns the epoch to clean up.
+func (c *staleRegionCleaner) finish() (uint64, bool) {
+	c.Lock()
+	defer c.Unlock()
+	if !c.syncing {
+		return 0, false
+	}
+	c.syncing = false
+	return c.epoch, true
+}
+
+// abort abandons the epoch, such as when the stream is broken during a full
+// synchronization, because the regions received are incomplete.
+func (c *staleRegionCleaner) abort() {
+	c.Lock()
+	defer c.Unlock()
+	if c.syncing {
+		c.syncing = false
+		c.seen = nil
+	}
+}
+
+// cleanup removes the regions not received in the epoch from the cache and
+// the storage, and returns the number of the removed regions. The regions
+// received during the cleanup are kept.
+func (c *staleRegionCleaner) cleanup(epoch uint64) int {
+	start := time.Now()
+	removed := 0
+	for _, region := range c.basicCluster.GetRegions() {
+		if !c.removeIfStale(epoch, region.GetID()) {
+			continue
+		}
+		removed++
+	}
+	c.Lock()
+	if c.epoch == epoch && !c.syncing {
+		c.seen = nil
+	}
+	c.Unlock()
+	log.Info("stale regions are cleaned up after the full synchronization",
+		zap.Uint64("epoch", epoch), zap.Int("removed", removed), zap.Duration("cost", time.Since(start)))
+	return removed
+}
+
+func (c *staleRegionCleaner) removeIfStale(epoch uint64, regionID uint64) bool {
+	c.Lock()
+	defer c.Unlock()
+	if c.epoch != epoch || c.syncing || c.seen == nil {
+		return false
+	}
+	if _, ok := c.seen[regionID]; ok {
+		return false
+	}
+	region := c.basicCluster.GetRegion(regionID)
+	if region == nil {
+		return false
+	}
+	c.basicCluster.RemoveRegion(region)
+	if err := c.storage.DeleteRegion(region.GetMeta()); err != nil {
+		log.Warn("failed to delete the stale region", zap.Uint64("region-id", regionID), errs.ZapError(err))
+	}
+	return true
+}
@@ -0,0 +1,102 @@
+// Copyright 2021 TiKV Project Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package syncer
+
+import (
+	. "github.com/pingcap/check"
+	"github.com/pingcap/kvproto/pkg/metapb"
+	"github.com/tikv/pd/server/core"
+	"github.com/tikv/pd/server/kv"
+)
+
+var _ = Suite(&testStaleRegionCleaner{})
+
+type testStaleRegionCleaner struct{}
+
+func newTestSyncedRegion(id uint64) *core.RegionInfo {
+	peer := &metapb.Peer{Id: id + 100, StoreId: 1}
+	return core.NewRegionInfo(&metapb.Region{
+		Id:          id,
+		StartKey:    []byte{byte(id)},
+		EndKey:      []byte{byte(id + 1)},
+		RegionEpoch: &metapb.RegionEpoch{ConfVer: 1, Version: 1},
+		Peers:       []*metapb.Peer{peer},
+	}, peer)
+}
+
+func (t *testStaleRegionCleaner) TestCleanup(c *C) {
+	basicCluster := core.NewBasicCluster()
+	storage := core.NewStorage(kv.NewMemoryKV())
+	cleaner := newStaleRegionCleaner(basicCluster, storage)
+	put := func(id uint64) {
+		cleaner.observe(id)
+		region := newTestSyncedRegion(id)
+		basicCluster.CheckAndPutRegion(region)
+		c.Assert(storage.SaveRegion(region.GetMeta()), IsNil)
+	}
+	for id := uint64(1); id <= 5; id++ {
+		put(id)
+	}
+
+	// The regions 4 and 5 are not received in the full synchronization.
+	cleaner.begin()
+	c.Assert(cleaner.isSyncing(), IsTrue)
+	for id := uint64(1); id <= 3; id++ {
+		put(id)
+	}
+	epoch, ok := cleaner.finish()
+	c.Assert(ok, IsTrue)
+	c.Assert(cleaner.isSyncing(), IsFalse)
+	// The region received after the full synchronization is kept.
+	put(6)
+	c.Assert(cleaner.cleanup(epoch), Equals, 2)
+	c.Assert(basicCluster.GetRegionCount(), Equals, 4)
+	c.Assert(basicCluster.GetRegion(4), IsNil)
+	c.Assert(basicCluster.GetRegion(6), NotNil)
+	meta := &metapb.Region{}
+	ok, err := storage.LoadRegion(5, meta)
+	c.Assert(err, IsNil)
+	c.Assert(ok, IsFalse)
+	ok, err = storage.LoadRegion(3, meta)
+	c.Assert(err, IsNil)
+	c.Assert(ok, IsTrue)
+	// Nothing is tracked after the cleanup.
+	c.Assert(cleaner.seen, IsNil)
+	_, ok = cleaner.finish()
+	c.Assert(ok, IsFalse)
+}
+
+func (t *testStaleRegionCleaner) TestAbort(c *C) {
+	basicCluster := core.NewBasicCluster()
+	cleaner := newStaleRegionCleaner(basicCluster, core.NewStorage(kv.NewMemoryKV()))
+	for id := uint64(1); id <= 3; id++ {
+		basicCluster.CheckAndPutRegion(newTestSyncedRegion(id))
+	}
+
+	// Nothing is removed if the full synchronization is broken.
+	cleaner.begin()
+	cleaner.observe(1)
+	cleaner.abort()
+	_, ok := cleaner.finish()
+	c.Assert(ok, IsFalse)
+
+	// The cleanup of an old epoch is abandoned once a new one begins.
+	cleaner.begin()
+	cleaner.observe(1)
+	epoch, ok := cleaner.finish()
+	c.Assert(ok, IsTrue)
+	cleaner.begin()
+	c.Assert(cleaner.cleanup(epoch), Equals, 0)
+	c.Assert(basicCluster.GetRegionCount(), Equals, 3)
+}
@@ -0,0 +1,150 @@
+// Copyright 2021 TiKV Project Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package replay
+
+import (
+	"bufio"
+	"encoding/binary"
+	"io"
+	"time"
+
+	"github.com/gogo/protobuf/proto"
+	"github.com/pingcap/kvproto/pkg/metapb"
+	"github.com/pingcap/kvproto/pkg/pdpb"
+	"github.com/tikv/pd/pkg/errs"
+)
+
+// magic is written at the beginning of a record file.
+const magic = "PDHBREC1"
+
+// maxRecordSize limits the size of a message, which protects the reader from
+// allocating a huge buffer for a corrupted length.
+const maxRecordSize = 64 << 20
+
+// RecordType is the type of the message in a record.
+type RecordType byte
+
+const (
+	// StoreRecord is a metapb.Store. The stores are recorded at the beginning
+	// so that the heartbeats of them can be replayed.
+	StoreRecord RecordType = iota + 1
+	// StoreHeartbeatRecord is a pdpb.StoreHeartbeatRequest.
+	StoreHeartbeatRecord
+	// RegionHeartbeatRecord is a pdpb.RegionHeartbeatRequest.
+	RegionHeartbeatRecord
+)
+
+// Record is a message in a record file.
+type Record struct {
+	Type RecordType
+	Time time.Time
+	// Message is a *metapb.Store, *pdpb.StoreHeartbeatRequest or
+	// *pdpb.RegionHeartbeatRequest according to the type.
+	Message proto.Message
+}
+
+// Writer writes the records. Each record is the type, the time in unix
+// nanoseconds, the length of the message and the marshaled message.
+type Writer struct {
+	w   *bufio.Writer
+	buf [2*binary.MaxVarintLen64 + 1]byte
+}
+
+// NewWriter creates a Writer and writes the magic.
+func NewWriter(w io.Writer) (*Writer, error) {
+	bw := bufio.NewWriter(w)
+	if _, err := bw.WriteString(magic); err != nil {
+		return nil, errs.ErrHeartbeatRecord.Wrap(err).GenWithStackByCause()
+	}
+	return &Writer{w: bw}, nil
+}
+
+// Write writes a record.
+func (w *Writer) Write(typ RecordType, t time.Time, msg proto.Message) error {
+	data, err := proto.Marshal(msg)
+	if err != nil {
+		return errs.ErrHeartbeatRecord.Wrap(err).GenWithStackByCause()
+	}
+	w.buf[0] = byte(typ)
+	n := 1 + binary.PutVarint(w.buf[1:], t.UnixNano())
+	n += binary.PutUvarint(w.buf[n:], uint64(len(data)))
+	if _, err := w.w.Write(w.buf[:n]); err != nil {
+		return errs.ErrHeartbeatRecord.Wrap(err).GenWithStackByCause()
+	}
+	if _, err := w.w.Write(data); err != nil {
+		return errs.ErrHeartbeatRecord.Wrap(err).GenWithStackByCause()
+	}
+	return nil
+}
+
+// Flush flushes the buffered records.
+func (w *Writer) Flush() error {
+	if err := w.w.Flush(); err != nil {
+		return errs.ErrHeartbeatRecord.Wrap(err).GenWithStackByCause()
+	}
+	return nil
+}
+
+// Reader reads the records written by a Writer.
+type Reader struct {
+	r *bufio.Reader
+}
+
+// NewReader creates a Reader and checks the magic.
+func NewReader(r io.Reader) (*Reader, error) {
+	br := bufio.NewReader(r)
+	head := make([]byte, len(magic))
+	if _, err := io.ReadFull(br, head); err != nil || string(head) != magic {
+		return nil, errs.ErrHeartbeatRecordCorruption.FastGenByArgs("bad magic")
+	}
+	return &Reader{r: br}, nil
+}
+
+// Next reads the next record. It returns io.EOF at the end of the records.
+func (r *Reader) Next() (*Record, error) {
+	typ, err := r.r.ReadByte()
+	if err == io.EOF {
+		return nil, io.EOF
+	}
+	if err != nil {
+		return nil, errs.ErrIORead.Wrap(err).GenWithStackByCause()
+	}
+	ts, err := binary.ReadVarint(r.r)
+	if err != nil {
+		return nil, errs.ErrHeartbeatRecordCorruption.FastGenByArgs("truncated time")
+	}
+	size, err := binary.ReadUvarint(r.r)
+	if err != nil || size > maxRecordSize {
+		return nil, errs.ErrHeartbeatRecordCorruption.FastGenByArgs("bad length")
+	}
+	data := make([]byte, size)
+	if _, err := io.ReadFull(r.r, data); err != nil {
+		return nil, errs.ErrHeartbeatRecordCorruption.FastGenByArgs("truncated message")
+	}
+	var msg proto.Message
+	switch RecordType(typ) {
+	case StoreRecord:
+		msg = &metapb.Store{}
+	case StoreHeartbeatRecord:
+		msg = &pdpb.StoreHeartbeatRequest{}
+	case RegionHeartbeatRecord:
+		msg = &pdpb.RegionHeartbeatRequest{}
+	default:
+		return nil, errs.ErrHeartbeatRecordCorruption.FastGenByArgs("unknown record type")
+	}
+	if err := proto.Unmarshal(data, msg); err != nil {
+		return nil, errs.ErrHeartbeatRecordCorruption.FastGenByArgs(err.Error())
+	}
+	return &Record{Type: RecordType(typ), Time: time.Unix(0, ts), Message: msg}, nil
+}
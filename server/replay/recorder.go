@@ -0,0 +1,287 @@
+// Copyright 2021 TiKV Project Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package replay
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/gogo/protobuf/proto"
+	"github.com/pingcap/kvproto/pkg/metapb"
+	"github.com/pingcap/kvproto/pkg/pdpb"
+	"github.com/pingcap/log"
+	"github.com/tikv/pd/pkg/errs"
+	"go.uber.org/zap"
+)
+
+const (
+	recordPrefix = "heartbeat-"
+	recordSuffix = ".rec"
+	// DefaultRecordDuration is the default duration of a recording.
+	DefaultRecordDuration = 10 * time.Minute
+	// MaxRecordDuration is the maximum duration of a recording.
+	MaxRecordDuration = time.Hour
+	// sampleBase is the granularity of the sample rate.
+	sampleBase = 10000
+)
+
+// RecordInfo is the information of a record file on disk.
+type RecordInfo struct {
+	Name string    `json:"name"`
+	Size int64     `json:"size"`
+	Time time.Time `json:"time"`
+}
+
+// RecordStatus is the status of the running recording.
+type RecordStatus struct {
+	Recording  bool      `json:"recording"`
+	Name       string    `json:"name,omitempty"`
+	SampleRate float64   `json:"sample_rate,omitempty"`
+	Deadline   time.Time `json:"deadline,omitempty"`
+	Records    int       `json:"records"`
+}
+
+// Recorder records the sampled store and region heartbeats into the files in
+// a directory. The regions are sampled by their IDs, so all the heartbeats of
+// a sampled region are recorded, while the store heartbeats are always
+// recorded with the peer stats of the unsampled regions removed.
+type Recorder struct {
+	dir string
+	// recording is checked without the lock in the heartbeat paths.
+	recording int32
+
+	mu         sync.Mutex
+	file       *os.File
+	writer     *Writer
+	name       string
+	sampled    uint64
+	sampleRate float64
+	deadline   time.Time
+	records    int
+	timer      *time.Timer
+}
+
+// NewRecorder creates a Recorder that keeps the record files in the directory.
+func NewRecorder(dir string) *Recorder {
+	return &Recorder{dir: dir}
+}
+
+// Start starts recording for the duration, and returns the name of the record
+// file. The stores are recorded first so that their heartbeats can be
+// replayed.
+func (r *Recorder) Start(sampleRate float64, duration time.Duration, stores []*metapb.Store) (string, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.writer != nil {
+		return "", errs.ErrHeartbeatRecording.FastGenByArgs()
+	}
+	if err := os.MkdirAll(r.dir, 0755); err != nil {
+		return "", errs.ErrHeartbeatRecord.Wrap(err).GenWithStackByCause()
+	}
+	now := time.Now()
+	name := fmt.Sprintf("%s%d%s", recordPrefix, now.UnixNano(), recordSuffix)
+	f, err := os.Create(filepath.Join(r.dir, name))
+	if err != nil {
+		return "", errs.ErrHeartbeatRecord.Wrap(err).GenWithStackByCause()
+	}
+	w, err := NewWriter(f)
+	if err == nil {
+		for _, s := range stores {
+			if err = w.Write(StoreRecord, now, s); err != nil {
+				break
+			}
+		}
+	}
+	if err != nil {
+		f.Close()
+		os.Remove(f.Name())
+		return "", err
+	}
+	r.file, r.writer, r.name = f, w, name
+	r.sampleRate = sampleRate
+	r.sampled = uint64(sampleRate * sampleBase)
+	r.deadline = now.Add(duration)
+	r.records = 0
+	r.timer = time.AfterFunc(duration, func() {
+		r.mu.Lock()
+		defer r.mu.Unlock()
+		// The recording may be stopped and another one started meanwhile.
+		if r.writer == nil || r.name != name {
+			return
+		}
+		if _, err := r.stopLocked(); err != nil {
+			log.Warn("failed to stop recording the heartbeats", errs.ZapError(err))
+		}
+	})
+	atomic.StoreInt32(&r.recording, 1)
+	log.Info("start recording the heartbeats", zap.String("name", name), zap.Float64("sample-rate", sampleRate), zap.Duration("duration", duration))
+	return name, nil
+}
+
+// Stop stops the running recording and returns the record file.
+func (r *Recorder) Stop() (*RecordInfo, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.writer == nil {
+		return nil, errs.ErrHeartbeatNotRecording.FastGenByArgs()
+	}
+	return r.stopLocked()
+}
+
+func (r *Recorder) stopLocked() (*RecordInfo, error) {
+	atomic.StoreInt32(&r.recording, 0)
+	r.timer.Stop()
+	err := r.writer.Flush()
+	if cerr := r.file.Close(); err == nil && cerr != nil {
+		err = errs.ErrHeartbeatRecord.Wrap(cerr).GenWithStackByCause()
+	}
+	info := &RecordInfo{Name: r.name}
+	r.file, r.writer, r.timer = nil, nil, nil
+	if err != nil {
+		return nil, err
+	}
+	if fi, err := os.Stat(filepath.Join(r.dir, info.Name)); err == nil {
+		info.Size, info.Time = fi.Size(), fi.ModTime()
+	}
+	log.Info("stop recording the heartbeats", zap.String("name", info.Name), zap.Int("records", r.records), zap.Int64("size", info.Size))
+	return info, nil
+}
+
+// Status returns the status of the running recording.
+func (r *Recorder) Status() *RecordStatus {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.writer == nil {
+		return &RecordStatus{}
+	}
+	return &RecordStatus{
+		Recording:  true,
+		Name:       r.name,
+		SampleRate: r.sampleRate,
+		Deadline:   r.deadline,
+		Records:    r.records,
+	}
+}
+
+func (r *Recorder) isSampled(regionID uint64) bool {
+	return regionID%sampleBase < r.sampled
+}
+
+// RecordStoreHeartbeat records a store heartbeat if it is recording.
+func (r *Recorder) RecordStoreHeartbeat(req *pdpb.StoreHeartbeatRequest) {
+	if atomic.LoadInt32(&r.recording) == 0 {
+		return
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.writer == nil || req.GetStats() == nil {
+		return
+	}
+	stats := *req.GetStats()
+	stats.PeerStats = nil
+	for _, ps := range req.GetStats().GetPeerStats() {
+		if r.isSampled(ps.GetRegionId()) {
+			stats.PeerStats = append(stats.PeerStats, ps)
+		}
+	}
+	r.writeLocked(StoreHeartbeatRecord, &pdpb.StoreHeartbeatRequest{Header: req.GetHeader(), Stats: &stats})
+}
+
+// RecordRegionHeartbeat records a region heartbeat if it is recording and the
+// region is sampled.
+func (r *Recorder) RecordRegionHeartbeat(req *pdpb.RegionHeartbeatRequest) {
+	if atomic.LoadInt32(&r.recording) == 0 {
+		return
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.writer == nil || !r.isSampled(req.GetRegion().GetId()) {
+		return
+	}
+	r.writeLocked(RegionHeartbeatRecord, req)
+}
+
+func (r *Recorder) writeLocked(typ RecordType, msg proto.Message) {
+	if err := r.writer.Write(typ, time.Now(), msg); err != nil {
+		log.Warn("failed to record the heartbeat, stop recording", zap.String("name", r.name), errs.ZapError(err))
+		if _, err := r.stopLocked(); err != nil {
+			log.Warn("failed to stop recording the heartbeats", errs.ZapError(err))
+		}
+		return
+	}
+	r.records++
+}
+
+// List returns the record files on disk, the newest first.
+func (r *Recorder) List() ([]*RecordInfo, error) {
+	entries, err := os.ReadDir(r.dir)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, errs.ErrIORead.Wrap(err).GenWithStackByCause()
+	}
+	var infos []*RecordInfo
+	for _, e := range entries {
+		if e.IsDir() || !IsRecordName(e.Name()) {
+			continue
+		}
+		fi, err := e.Info()
+		if err != nil {
+			continue
+		}
+		infos = append(infos, &RecordInfo{Name: e.Name(), Size: fi.Size(), Time: fi.ModTime()})
+	}
+	sort.Slice(infos, func(i, j int) bool { return infos[i].Name > infos[j].Name })
+	return infos, nil
+}
+
+// Open opens the record file with the name.
+func (r *Recorder) Open(name string) (*os.File, error) {
+	if !IsRecordName(name) {
+		return nil, errs.ErrHeartbeatRecordNotFound.FastGenByArgs(name)
+	}
+	f, err := os.Open(filepath.Join(r.dir, name))
+	if os.IsNotExist(err) {
+		return nil, errs.ErrHeartbeatRecordNotFound.FastGenByArgs(name)
+	}
+	if err != nil {
+		return nil, errs.ErrIORead.Wrap(err).GenWithStackByCause()
+	}
+	return f, nil
+}
+
+// IsRecordName returns whether the name is a valid record name, which also
+// prevents the path traversal.
+func IsRecordName(name string) bool {
+	if !strings.HasPrefix(name, recordPrefix) || !strings.HasSuffix(name, recordSuffix) {
+		return false
+	}
+	id := strings.TrimSuffix(strings.TrimPrefix(name, recordPrefix), recordSuffix)
+	if id == "" {
+		return false
+	}
+	for _, c := range id {
+		if c < '0' || c > '9' {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,192 @@
+// Copyright 2021 TiKV Project Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package replay
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"testing"
+	"time"
+
+	. "github.com/pingcap/check"
+	"github.com/pingcap/kvproto/pkg/metapb"
+	"github.com/pingcap/kvproto/pkg/pdpb"
+	"github.com/tikv/pd/server/cluster"
+	"github.com/tikv/pd/server/config"
+)
+
+func TestReplay(t *testing.T) {
+	TestingT(t)
+}
+
+var _ = Suite(&testReplaySuite{})
+
+type testReplaySuite struct{}
+
+func newRegionHeartbeat(id uint64, start, end string, storeIDs ...uint64) *pdpb.RegionHeartbeatRequest {
+	region := &metapb.Region{
+		Id:          id,
+		StartKey:    []byte(start),
+		EndKey:      []byte(end),
+		RegionEpoch: &metapb.RegionEpoch{ConfVer: 1, Version: 1},
+	}
+	for i, storeID := range storeIDs {
+		region.Peers = append(region.Peers, &metapb.Peer{Id: id*10 + uint64(i), StoreId: storeID})
+	}
+	return &pdpb.RegionHeartbeatRequest{Region: region, Leader: region.Peers[0], ApproximateSize: 10}
+}
+
+func newStoreHeartbeat(storeID uint64, regionIDs ...uint64) *pdpb.StoreHeartbeatRequest {
+	stats := &pdpb.StoreStats{StoreId: storeID, Capacity: 100 << 30, Available: 50 << 30}
+	for _, id := range regionIDs {
+		stats.PeerStats = append(stats.PeerStats, &pdpb.PeerStat{RegionId: id})
+	}
+	return &pdpb.StoreHeartbeatRequest{Stats: stats}
+}
+
+func (s *testReplaySuite) TestReadWrite(c *C) {
+	var buf bytes.Buffer
+	w, err := NewWriter(&buf)
+	c.Assert(err, IsNil)
+	now := time.Unix(0, time.Now().UnixNano())
+	c.Assert(w.Write(StoreRecord, now, &metapb.Store{Id: 1}), IsNil)
+	c.Assert(w.Write(StoreHeartbeatRecord, now.Add(time.Second), newStoreHeartbeat(1, 2)), IsNil)
+	c.Assert(w.Write(RegionHeartbeatRecord, now.Add(2*time.Second), newRegionHeartbeat(2, "a", "b", 1)), IsNil)
+	c.Assert(w.Flush(), IsNil)
+	data := buf.Bytes()
+
+	r, err := NewReader(bytes.NewReader(data))
+	c.Assert(err, IsNil)
+	rec, err := r.Next()
+	c.Assert(err, IsNil)
+	c.Assert(rec.Type, Equals, StoreRecord)
+	c.Assert(rec.Time.Equal(now), IsTrue)
+	c.Assert(rec.Message.(*metapb.Store).GetId(), Equals, uint64(1))
+	rec, err = r.Next()
+	c.Assert(err, IsNil)
+	c.Assert(rec.Type, Equals, StoreHeartbeatRecord)
+	c.Assert(rec.Message.(*pdpb.StoreHeartbeatRequest).GetStats().GetPeerStats(), HasLen, 1)
+	rec, err = r.Next()
+	c.Assert(err, IsNil)
+	c.Assert(rec.Type, Equals, RegionHeartbeatRecord)
+	c.Assert(rec.Time.Equal(now.Add(2*time.Second)), IsTrue)
+	c.Assert(rec.Message.(*pdpb.RegionHeartbeatRequest).GetRegion().GetId(), Equals, uint64(2))
+	_, err = r.Next()
+	c.Assert(err, Equals, io.EOF)
+
+	// The truncated and the unknown files are rejected.
+	r, err = NewReader(bytes.NewReader(data[:len(data)-1]))
+	c.Assert(err, IsNil)
+	for err == nil {
+		_, err = r.Next()
+	}
+	c.Assert(err, Not(Equals), io.EOF)
+	_, err = NewReader(bytes.NewReader([]byte("not a record")))
+	c.Assert(err, NotNil)
+}
+
+func (s *testReplaySuite) TestRecorder(c *C) {
+	recorder := NewRecorder(c.MkDir())
+	// Nothing is recorded before starting.
+	recorder.RecordRegionHeartbeat(newRegionHeartbeat(1, "", "a", 1))
+	_, err := recorder.Stop()
+	c.Assert(err, NotNil)
+
+	name, err := recorder.Start(0.5, time.Minute, []*metapb.Store{{Id: 1}, {Id: 2}})
+	c.Assert(err, IsNil)
+	_, err = recorder.Start(1, time.Minute, nil)
+	c.Assert(err, NotNil)
+	// The regions 4999 and 5000 are split by the sample rate.
+	recorder.RecordRegionHeartbeat(newRegionHeartbeat(4999, "", "a", 1))
+	recorder.RecordRegionHeartbeat(newRegionHeartbeat(5000, "a", "", 1))
+	recorder.RecordStoreHeartbeat(newStoreHeartbeat(1, 4999, 5000))
+	status := recorder.Status()
+	c.Assert(status.Recording, IsTrue)
+	c.Assert(status.Name, Equals, name)
+	c.Assert(status.Records, Equals, 2)
+	info, err := recorder.Stop()
+	c.Assert(err, IsNil)
+	c.Assert(info.Name, Equals, name)
+	c.Assert(recorder.Status().Recording, IsFalse)
+
+	infos, err := recorder.List()
+	c.Assert(err, IsNil)
+	c.Assert(infos, HasLen, 1)
+	c.Assert(infos[0].Name, Equals, name)
+	_, err = recorder.Open("../" + name)
+	c.Assert(err, NotNil)
+	f, err := recorder.Open(name)
+	c.Assert(err, IsNil)
+	defer f.Close()
+	r, err := NewReader(f)
+	c.Assert(err, IsNil)
+	var types []RecordType
+	for {
+		rec, err := r.Next()
+		if err == io.EOF {
+			break
+		}
+		c.Assert(err, IsNil)
+		types = append(types, rec.Type)
+		switch msg := rec.Message.(type) {
+		case *pdpb.RegionHeartbeatRequest:
+			c.Assert(msg.GetRegion().GetId(), Equals, uint64(4999))
+		case *pdpb.StoreHeartbeatRequest:
+			c.Assert(msg.GetStats().GetPeerStats(), HasLen, 1)
+			c.Assert(msg.GetStats().GetPeerStats()[0].GetRegionId(), Equals, uint64(4999))
+		}
+	}
+	c.Assert(types, DeepEquals, []RecordType{StoreRecord, StoreRecord, RegionHeartbeatRecord, StoreHeartbeatRecord})
+
+	// The recording is stopped after the duration.
+	_, err = recorder.Start(1, 10*time.Millisecond, nil)
+	c.Assert(err, IsNil)
+	time.Sleep(100 * time.Millisecond)
+	c.Assert(recorder.Status().Recording, IsFalse)
+}
+
+func (s *testReplaySuite) TestReplay(c *C) {
+	var buf bytes.Buffer
+	w, err := NewWriter(&buf)
+	c.Assert(err, IsNil)
+	now := time.Now()
+	for id := uint64(1); id <= 3; id++ {
+		c.Assert(w.Write(StoreRecord, now, &metapb.Store{Id: id, Address: "mock"}), IsNil)
+	}
+	c.Assert(w.Write(RegionHeartbeatRecord, now, newRegionHeartbeat(1, "", "a", 1, 2, 3)), IsNil)
+	c.Assert(w.Write(RegionHeartbeatRecord, now, newRegionHeartbeat(2, "a", "", 1, 2, 4)), IsNil)
+	c.Assert(w.Write(StoreHeartbeatRecord, now.Add(10*time.Millisecond), newStoreHeartbeat(1, 1, 2)), IsNil)
+	// The store 4 is not recorded at the beginning.
+	c.Assert(w.Write(StoreHeartbeatRecord, now.Add(20*time.Millisecond), newStoreHeartbeat(4, 2)), IsNil)
+	c.Assert(w.Flush(), IsNil)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	rc := cluster.NewShadowCluster(ctx, config.NewTestOptions())
+	r, err := NewReader(bytes.NewReader(buf.Bytes()))
+	c.Assert(err, IsNil)
+	res, err := Replay(ctx, rc, r, 1)
+	c.Assert(err, IsNil)
+	c.Assert(res.Stores, Equals, 4)
+	c.Assert(res.RegionHeartbeats.Count, Equals, 2)
+	c.Assert(res.RegionHeartbeats.Errors, Equals, 0)
+	c.Assert(res.StoreHeartbeats.Count, Equals, 2)
+	c.Assert(res.StoreHeartbeats.Errors, Equals, 0)
+	// The recorded pace is kept.
+	c.Assert(res.Elapsed >= 20*time.Millisecond, IsTrue)
+	c.Assert(rc.GetRegionCount(), Equals, 2)
+	c.Assert(rc.GetStore(1).GetRegionCount(), Equals, 2)
+	c.Assert(rc.GetStore(4).GetCapacity(), Equals, uint64(100<<30))
+}
@@ -0,0 +1,139 @@
+// Copyright 2021 TiKV Project Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package replay
+
+import (
+	"context"
+	"io"
+	"sort"
+	"time"
+
+	"github.com/pingcap/kvproto/pkg/metapb"
+	"github.com/pingcap/kvproto/pkg/pdpb"
+	"github.com/tikv/pd/server/cluster"
+	"github.com/tikv/pd/server/core"
+)
+
+// LatencySummary is the summary of the handling durations of a kind of
+// heartbeats.
+type LatencySummary struct {
+	Count  int           `json:"count"`
+	Errors int           `json:"errors"`
+	Avg    time.Duration `json:"avg"`
+	P50    time.Duration `json:"p50"`
+	P99    time.Duration `json:"p99"`
+	Max    time.Duration `json:"max"`
+}
+
+// Report is the report of a replay.
+type Report struct {
+	Stores           int            `json:"stores"`
+	StoreHeartbeats  LatencySummary `json:"store_heartbeats"`
+	RegionHeartbeats LatencySummary `json:"region_heartbeats"`
+	// Elapsed is the wall time of the replay, including the waits to keep the
+	// recorded pace.
+	Elapsed time.Duration `json:"elapsed"`
+}
+
+type latencies struct {
+	durations []time.Duration
+	errors    int
+}
+
+func (l *latencies) observe(d time.Duration, err error) {
+	l.durations = append(l.durations, d)
+	if err != nil {
+		l.errors++
+	}
+}
+
+func (l *latencies) summary() LatencySummary {
+	s := LatencySummary{Count: len(l.durations), Errors: l.errors}
+	if s.Count == 0 {
+		return s
+	}
+	sort.Slice(l.durations, func(i, j int) bool { return l.durations[i] < l.durations[j] })
+	var total time.Duration
+	for _, d := range l.durations {
+		total += d
+	}
+	s.Avg = total / time.Duration(s.Count)
+	s.P50 = l.durations[s.Count*50/100]
+	s.P99 = l.durations[s.Count*99/100]
+	s.Max = l.durations[s.Count-1]
+	return s
+}
+
+// Replay replays the records through the shadow cluster, and measures the
+// handling durations of the heartbeats. The heartbeats are replayed as fast
+// as possible if speed is 0, otherwise the recorded intervals are kept and
+// divided by speed. The stores unknown to the cluster are put before their
+// heartbeats.
+func Replay(ctx context.Context, c *cluster.RaftCluster, r *Reader, speed float64) (*Report, error) {
+	var (
+		res              Report
+		stores, regions  latencies
+		start            = time.Now()
+		firstRecord      time.Time
+		flowRoundByDigit = c.GetOpts().GetPDServerConfig().FlowRoundByDigit
+	)
+	for {
+		rec, err := r.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		if speed > 0 && rec.Type != StoreRecord {
+			if firstRecord.IsZero() {
+				firstRecord = rec.Time
+			}
+			wait := time.Duration(float64(rec.Time.Sub(firstRecord))/speed) - time.Since(start)
+			if wait > 0 {
+				select {
+				case <-ctx.Done():
+					return nil, ctx.Err()
+				case <-time.After(wait):
+				}
+			}
+		}
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		switch msg := rec.Message.(type) {
+		case *metapb.Store:
+			c.PutShadowStore(msg)
+			res.Stores++
+		case *pdpb.StoreHeartbeatRequest:
+			storeID := msg.GetStats().GetStoreId()
+			if c.GetStore(storeID) == nil {
+				c.PutShadowStore(&metapb.Store{Id: storeID})
+				res.Stores++
+			}
+			t := time.Now()
+			err := c.HandleStoreHeartbeat(msg.GetStats())
+			stores.observe(time.Since(t), err)
+		case *pdpb.RegionHeartbeatRequest:
+			t := time.Now()
+			region := core.RegionFromHeartbeat(msg, core.WithFlowRoundByDigit(flowRoundByDigit))
+			err := c.HandleShadowRegionHeartbeat(region)
+			regions.observe(time.Since(t), err)
+		}
+	}
+	res.StoreHeartbeats = stores.summary()
+	res.RegionHeartbeats = regions.summary()
+	res.Elapsed = time.Since(start)
+	return &res, nil
+}
@@ -0,0 +1,189 @@
+// Copyright 2021 TiKV Project Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package replication
+
+import (
+	"bytes"
+	"encoding/hex"
+	"time"
+
+	"github.com/pingcap/log"
+	"github.com/tikv/pd/pkg/errs"
+	"github.com/tikv/pd/server/config"
+	"go.uber.org/zap"
+)
+
+// DRRangeStatus is the state of a key range in the dr-auto-sync mode. The
+// ranges are recovered separately in the sync_recover state, so it can be
+// found which ranges, like the tables, are still catching up.
+type DRRangeStatus struct {
+	Name            string  `json:"name"`
+	StartKey        string  `json:"start_key"`
+	EndKey          string  `json:"end_key"`
+	State           string  `json:"state"`
+	TotalRegions    int     `json:"total_regions,omitempty"`
+	SyncedRegions   int     `json:"synced_regions,omitempty"`
+	RecoverProgress float32 `json:"recover_progress,omitempty"`
+	// RecoverTime is when all regions of the range are recovered in the
+	// sync_recover state.
+	RecoverTime time.Time `json:"recover_time,omitempty"`
+}
+
+func (s *DRRangeStatus) sameRange(r *config.DRAutoSyncRange) bool {
+	return s.Name == r.Name && s.StartKey == r.StartKey && s.EndKey == r.EndKey
+}
+
+// drRangeRecover is the intermediate state of the recovery of a range.
+type drRangeRecover struct {
+	key   []byte // all regions in the range that have startKey < key are recovered
+	count int    // number of regions in the range that have startKey < key
+}
+
+// newDRRangeStatusesWithLock creates the statuses of the configured ranges
+// in the state.
+func (m *ModeManager) newDRRangeStatusesWithLock(state string) []DRRangeStatus {
+	ranges := m.config.DRAutoSync.Ranges
+	if len(ranges) == 0 {
+		return nil
+	}
+	statuses := make([]DRRangeStatus, 0, len(ranges))
+	for _, r := range ranges {
+		statuses = append(statuses, DRRangeStatus{Name: r.Name, StartKey: r.StartKey, EndKey: r.EndKey, State: state})
+	}
+	return statuses
+}
+
+// adjustDRRangesWithLock makes the statuses match the configured ranges. The
+// statuses of the unchanged ranges are kept, and the new ranges start in the
+// state of the cluster. It returns whether the statuses are changed.
+func (m *ModeManager) adjustDRRangesWithLock() bool {
+	ranges := m.config.DRAutoSync.Ranges
+	changed := len(ranges) != len(m.drAutoSync.Ranges)
+	statuses := make([]DRRangeStatus, 0, len(ranges))
+	for i := range ranges {
+		if i < len(m.drAutoSync.Ranges) && m.drAutoSync.Ranges[i].sameRange(&ranges[i]) {
+			statuses = append(statuses, m.drAutoSync.Ranges[i])
+			continue
+		}
+		changed = true
+		delete(m.drRangeRecovers, ranges[i].Name)
+		statuses = append(statuses, DRRangeStatus{Name: ranges[i].Name, StartKey: ranges[i].StartKey, EndKey: ranges[i].EndKey, State: m.drAutoSync.State})
+	}
+	if !changed {
+		return false
+	}
+	if len(statuses) == 0 {
+		statuses = nil
+	}
+	m.drAutoSync.Ranges = statuses
+	drRangeRecoverProgressGauge.Reset()
+	return true
+}
+
+// updateRangeProgress updates the recover progress of the ranges that are
+// not recovered yet, and switches them to the sync state once all their
+// regions are recovered.
+func (m *ModeManager) updateRangeProgress() {
+	m.RLock()
+	stateID := m.drAutoSync.StateID
+	statuses := append(m.drAutoSync.Ranges[:0:0], m.drAutoSync.Ranges...)
+	var recovered bool
+	for i := range statuses {
+		if statuses[i].State != drStateSyncRecover {
+			continue
+		}
+		m.updateRangeRecover(&statuses[i])
+		drRangeRecoverProgressGauge.WithLabelValues(statuses[i].Name).Set(float64(statuses[i].RecoverProgress))
+		if statuses[i].State == drStateSync {
+			recovered = true
+			log.Info("range is recovered", zap.String("replicate-mode", modeDRAutoSync), zap.String("range", statuses[i].Name))
+		}
+	}
+	m.RUnlock()
+
+	m.Lock()
+	defer m.Unlock()
+	// The state or the ranges may be changed while scanning.
+	if m.drAutoSync.StateID != stateID || len(m.drAutoSync.Ranges) != len(statuses) {
+		return
+	}
+	for i := range statuses {
+		if m.drAutoSync.Ranges[i].Name != statuses[i].Name {
+			return
+		}
+	}
+	m.drAutoSync.Ranges = statuses
+	if recovered {
+		if err := m.storage.SaveReplicationStatus(modeDRAutoSync, m.drAutoSync); err != nil {
+			log.Warn("failed to save the states of the ranges", zap.String("replicate-mode", modeDRAutoSync), errs.ZapError(err))
+		}
+	}
+}
+
+// updateRangeRecover scans the regions of the range from where it is
+// recovered to, and updates the progress of the status.
+func (m *ModeManager) updateRangeRecover(status *DRRangeStatus) {
+	rec, ok := m.drRangeRecovers[status.Name]
+	if !ok {
+		startKey, _ := hex.DecodeString(status.StartKey)
+		rec = &drRangeRecover{key: startKey}
+		m.drRangeRecovers[status.Name] = rec
+	}
+	endKey, _ := hex.DecodeString(status.EndKey)
+	reachEnd := func(key []byte) bool {
+		return len(key) == 0 || (len(endKey) > 0 && bytes.Compare(key, endKey) >= 0)
+	}
+
+	// prefix is true while the scanned regions are recovered contiguously
+	// from the recover key, so the recover key can be moved forward.
+	prefix := true
+	total, synced := rec.count, rec.count
+	for key := rec.key; ; {
+		regions := m.cluster.ScanRegions(key, endKey, regionScanBatchSize)
+		if len(regions) == 0 {
+			break
+		}
+		for _, r := range regions {
+			total++
+			if !m.checkRegionRecovered(r) {
+				prefix = false
+				continue
+			}
+			synced++
+			if prefix && bytes.Compare(r.GetStartKey(), rec.key) <= 0 {
+				rec.key = r.GetEndKey()
+				rec.count++
+			} else {
+				prefix = false
+			}
+		}
+		key = regions[len(regions)-1].GetEndKey()
+		if reachEnd(key) {
+			break
+		}
+	}
+
+	status.TotalRegions, status.SyncedRegions = total, synced
+	if rec.count > 0 && reachEnd(rec.key) {
+		status.State = drStateSync
+		status.RecoverProgress = 1.0
+		status.RecoverTime = time.Now()
+		return
+	}
+	// make sure progress less than 1
+	if total <= synced {
+		total = synced + 1
+	}
+	status.RecoverProgress = float32(synced) / float32(total)
+}
@@ -31,4 +31,18 @@ var (
 			Name:      "dr_recover_progress",
 			Help:      "Progress of sync_recover process",
 		})
+
+	drRangeRecoverProgressGauge = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Namespace: "pd",
+			Subsystem: "replication",
+			Name:      "dr_range_recover_progress",
+			Help:      "Progress of sync_recover process of the ranges",
+		}, []string{"range"})
 )
+
+func init() {
+	prometheus.MustRegister(drTickCounter)
+	prometheus.MustRegister(drRecoverProgressGauge)
+	prometheus.MustRegister(drRangeRecoverProgressGauge)
+}
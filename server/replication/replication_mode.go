@@ -76,6 +76,8 @@ type ModeManager struct {
 	drSampleRecoverCount int // number of regions that are recovered in sample
 	drSampleTotalRegion  int // number of regions in sample
 	drTotalRegion        int // number of all regions
+	// the recovery of the configured ranges, keyed by the names
+	drRangeRecovers map[string]*drRangeRecover
 
 	drMemberWaitAsyncTime map[uint64]time.Time // last sync time with follower nodes
 }
@@ -89,6 +91,7 @@ func NewReplicationModeManager(config config.ReplicationModeConfig, storage *cor
 		cluster:               cluster,
 		fileReplicater:        fileReplicater,
 		drMemberWaitAsyncTime: make(map[uint64]time.Time),
+		drRangeRecovers:       make(map[string]*drRangeRecover),
 	}
 	switch config.ReplicationMode {
 	case modeMajority:
@@ -127,6 +130,12 @@ func (m *ModeManager) UpdateConfig(config config.ReplicationModeConfig) error {
 		return err
 	}
 	m.config = config
+	if m.config.ReplicationMode == modeDRAutoSync && m.adjustDRRangesWithLock() {
+		if err := m.storage.SaveReplicationStatus(modeDRAutoSync, m.drAutoSync); err != nil {
+			log.Warn("failed to save the states of the ranges", zap.String("replicate-mode", modeDRAutoSync), errs.ZapError(err))
+			return err
+		}
+	}
 	return nil
 }
 
@@ -164,12 +173,13 @@ func (m *ModeManager) GetReplicationStatus() *pb.ReplicationStatus {
 type HTTPReplicationStatus struct {
 	Mode       string `json:"mode"`
 	DrAutoSync struct {
-		LabelKey        string  `json:"label_key"`
-		State           string  `json:"state"`
-		StateID         uint64  `json:"state_id,omitempty"`
-		TotalRegions    int     `json:"total_regions,omitempty"`
-		SyncedRegions   int     `json:"synced_regions,omitempty"`
-		RecoverProgress float32 `json:"recover_progress,omitempty"`
+		LabelKey        string          `json:"label_key"`
+		State           string          `json:"state"`
+		StateID         uint64          `json:"state_id,omitempty"`
+		TotalRegions    int             `json:"total_regions,omitempty"`
+		SyncedRegions   int             `json:"synced_regions,omitempty"`
+		RecoverProgress float32         `json:"recover_progress,omitempty"`
+		Ranges          []DRRangeStatus `json:"ranges,omitempty"`
 	} `json:"dr-auto-sync,omitempty"`
 }
 
@@ -188,6 +198,7 @@ func (m *ModeManager) GetReplicationStatusHTTP() *HTTPReplicationStatus {
 		status.DrAutoSync.RecoverProgress = m.drAutoSync.RecoverProgress
 		status.DrAutoSync.TotalRegions = m.drAutoSync.TotalRegions
 		status.DrAutoSync.SyncedRegions = m.drAutoSync.SyncedRegions
+		status.DrAutoSync.Ranges = append(m.drAutoSync.Ranges[:0:0], m.drAutoSync.Ranges...)
 	}
 	return &status
 }
@@ -211,6 +222,8 @@ type drAutoSyncStatus struct {
 	TotalRegions     int       `json:"total_regions,omitempty"`
 	SyncedRegions    int       `json:"synced_regions,omitempty"`
 	RecoverProgress  float32   `json:"recover_progress,omitempty"`
+	// Ranges are the states of the configured ranges.
+	Ranges []DRRangeStatus `json:"ranges,omitempty"`
 }
 
 func (m *ModeManager) loadDRAutoSync() error {
@@ -222,6 +235,9 @@ func (m *ModeManager) loadDRAutoSync() error {
 		// initialize
 		return m.drSwitchToSync()
 	}
+	if m.adjustDRRangesWithLock() {
+		return m.storage.SaveReplicationStatus(modeDRAutoSync, m.drAutoSync)
+	}
 	return nil
 }
 
@@ -254,7 +270,7 @@ func (m *ModeManager) drSwitchToAsyncWithLock() error {
 		log.Warn("failed to switch to async state", zap.String("replicate-mode", modeDRAutoSync), errs.ZapError(err))
 		return err
 	}
-	dr := drAutoSyncStatus{State: drStateAsync, StateID: id}
+	dr := drAutoSyncStatus{State: drStateAsync, StateID: id, Ranges: m.newDRRangeStatusesWithLock(drStateAsync)}
 	if err := m.drPersistStatus(dr); err != nil {
 		return err
 	}
@@ -279,7 +295,12 @@ func (m *ModeManager) drSwitchToSyncRecoverWithLock() error {
 		log.Warn("failed to switch to sync_recover state", zap.String("replicate-mode", modeDRAutoSync), errs.ZapError(err))
 		return err
 	}
-	dr := drAutoSyncStatus{State: drStateSyncRecover, StateID: id, RecoverStartTime: time.Now()}
+	dr := drAutoSyncStatus{
+		State:            drStateSyncRecover,
+		StateID:          id,
+		RecoverStartTime: time.Now(),
+		Ranges:           m.newDRRangeStatusesWithLock(drStateSyncRecover),
+	}
 	if err := m.drPersistStatus(dr); err != nil {
 		return err
 	}
@@ -289,6 +310,7 @@ func (m *ModeManager) drSwitchToSyncRecoverWithLock() error {
 	}
 	m.drAutoSync = dr
 	m.drRecoverKey, m.drRecoverCount = nil, 0
+	m.drRangeRecovers = make(map[string]*drRangeRecover)
 	log.Info("switched to sync_recover state", zap.String("replicate-mode", modeDRAutoSync))
 	return nil
 }
@@ -301,7 +323,7 @@ func (m *ModeManager) drSwitchToSync() error {
 		log.Warn("failed to switch to sync state", zap.String("replicate-mode", modeDRAutoSync), errs.ZapError(err))
 		return err
 	}
-	dr := drAutoSyncStatus{State: drStateSync, StateID: id}
+	dr := drAutoSyncStatus{State: drStateSync, StateID: id, Ranges: m.newDRRangeStatusesWithLock(drStateSync)}
 	if err := m.drPersistStatus(dr); err != nil {
 		return err
 	}
@@ -396,6 +418,7 @@ func (m *ModeManager) tickDR() {
 
 	if m.drGetState() == drStateSyncRecover {
 		m.updateProgress()
+		m.updateRangeProgress()
 		progress := m.estimateProgress()
 		drRecoverProgressGauge.Set(float64(progress))
 
@@ -494,6 +517,12 @@ func (m *ModeManager) checkRegionRecover(region *core.RegionInfo, startKey []byt
 			zap.Uint64("region-id", region.GetID()))
 		return false
 	}
+	return m.checkRegionRecovered(region)
+}
+
+// checkRegionRecovered returns if the region is replicated over the label in
+// the current state.
+func (m *ModeManager) checkRegionRecovered(region *core.RegionInfo) bool {
 	return region.GetReplicationStatus().GetStateId() == m.drAutoSync.StateID &&
 		region.GetReplicationStatus().GetState() == pb.RegionReplicationState_INTEGRITY_OVER_LABEL
 }
@@ -15,7 +15,9 @@ package replication
 
 import (
 	"context"
+	"encoding/hex"
 	"errors"
+	"fmt"
 	"testing"
 	"time"
 
@@ -359,6 +361,91 @@ func (s *testReplicationMode) TestRecoverProgress(c *C) {
 	c.Assert(rep.estimateProgress(), Equals, (float32(9)+float32(30-9)/2)/float32(30))
 }
 
+func (s *testReplicationMode) TestRangeRecoverProgress(c *C) {
+	regionScanBatchSize = 3
+
+	store := core.NewStorage(kv.NewMemoryKV())
+	conf := config.ReplicationModeConfig{ReplicationMode: modeDRAutoSync, DRAutoSync: config.DRAutoSyncReplicationConfig{
+		LabelKey:         "zone",
+		Primary:          "zone1",
+		DR:               "zone2",
+		PrimaryReplicas:  2,
+		DRReplicas:       1,
+		WaitStoreTimeout: typeutil.Duration{Duration: time.Minute},
+		WaitSyncTimeout:  typeutil.Duration{Duration: time.Minute},
+		Ranges: []config.DRAutoSyncRange{
+			{Name: "r1", EndKey: hex.EncodeToString([]byte(fmt.Sprintf("%20d", 6)))},
+			{Name: "r2", StartKey: hex.EncodeToString([]byte(fmt.Sprintf("%20d", 6)))},
+		},
+	}}
+	c.Assert(conf.Validate(), IsNil)
+	cluster := mockcluster.NewCluster(s.ctx, config.NewTestOptions())
+	cluster.AddLabelsStore(1, 1, map[string]string{})
+	rep, err := NewReplicationModeManager(conf, store, cluster, nil)
+	c.Assert(err, IsNil)
+	c.Assert(rep.GetReplicationStatusHTTP().DrAutoSync.Ranges, HasLen, 2)
+	c.Assert(rep.drAutoSync.Ranges[0].State, Equals, drStateSync)
+
+	c.Assert(rep.drSwitchToSyncRecover(), IsNil)
+	regions := s.genRegions(cluster, rep.drAutoSync.StateID, 10)
+	regions[6] = regions[6].Clone(core.SetReplicationStatus(&pb.RegionReplicationStatus{
+		State:   pb.RegionReplicationState_SIMPLE_MAJORITY,
+		StateId: rep.drAutoSync.StateID,
+	}))
+	for _, r := range regions {
+		cluster.PutRegion(r)
+	}
+	rep.updateRangeProgress()
+	ranges := rep.GetReplicationStatusHTTP().DrAutoSync.Ranges
+	c.Assert(ranges[0].State, Equals, drStateSync)
+	c.Assert(ranges[0].TotalRegions, Equals, 5)
+	c.Assert(ranges[0].SyncedRegions, Equals, 5)
+	c.Assert(ranges[0].RecoverProgress, Equals, float32(1.0))
+	c.Assert(ranges[1].State, Equals, drStateSyncRecover)
+	c.Assert(ranges[1].TotalRegions, Equals, 5)
+	c.Assert(ranges[1].SyncedRegions, Equals, 4)
+	c.Assert(ranges[1].RecoverProgress, Equals, float32(4)/float32(5))
+	c.Assert(rep.drRangeRecovers["r2"].count, Equals, 1)
+
+	// The recovered range is persisted.
+	rep2, err := NewReplicationModeManager(conf, store, cluster, nil)
+	c.Assert(err, IsNil)
+	c.Assert(rep2.drAutoSync.Ranges[0].State, Equals, drStateSync)
+	c.Assert(rep2.drAutoSync.Ranges[1].State, Equals, drStateSyncRecover)
+
+	// The unchanged ranges keep their states when the config is updated.
+	conf.DRAutoSync.Ranges = append(conf.DRAutoSync.Ranges[:1:1], config.DRAutoSyncRange{Name: "r3", StartKey: "00"})
+	c.Assert(rep.UpdateConfig(conf), IsNil)
+	ranges = rep.GetReplicationStatusHTTP().DrAutoSync.Ranges
+	c.Assert(ranges, HasLen, 2)
+	c.Assert(ranges[0].State, Equals, drStateSync)
+	c.Assert(ranges[1].Name, Equals, "r3")
+	c.Assert(ranges[1].State, Equals, drStateSyncRecover)
+
+	// All ranges are recovered once the regions are.
+	cluster.PutRegion(regions[6].Clone(core.SetReplicationStatus(&pb.RegionReplicationStatus{
+		State:   pb.RegionReplicationState_INTEGRITY_OVER_LABEL,
+		StateId: rep.drAutoSync.StateID,
+	})))
+	rep.updateRangeProgress()
+	ranges = rep.GetReplicationStatusHTTP().DrAutoSync.Ranges
+	c.Assert(ranges[1].State, Equals, drStateSync)
+	c.Assert(ranges[1].TotalRegions, Equals, 10)
+
+	// The ranges follow the state of the cluster.
+	c.Assert(rep.drSwitchToAsync(), IsNil)
+	for _, r := range rep.GetReplicationStatusHTTP().DrAutoSync.Ranges {
+		c.Assert(r.State, Equals, drStateAsync)
+	}
+
+	conf.DRAutoSync.Ranges = []config.DRAutoSyncRange{{Name: "r4", StartKey: "62", EndKey: "61"}}
+	c.Assert(conf.Validate(), NotNil)
+	conf.DRAutoSync.Ranges = []config.DRAutoSyncRange{{Name: "r4", StartKey: "6z"}}
+	c.Assert(conf.Validate(), NotNil)
+	conf.DRAutoSync.Ranges = []config.DRAutoSyncRange{{Name: "r4"}, {Name: "r4", StartKey: "61"}}
+	c.Assert(conf.Validate(), NotNil)
+}
+
 func (s *testReplicationMode) genRegions(cluster *mockcluster.Cluster, stateID uint64, n int) []*core.RegionInfo {
 	var regions []*core.RegionInfo
 	for i := 1; i <= n; i++ {
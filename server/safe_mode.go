@@ -0,0 +1,102 @@
+// Copyright 2021 TiKV Project Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package server
+
+import (
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"time"
+
+	"github.com/pingcap/log"
+	"github.com/tikv/pd/pkg/errs"
+	"go.uber.org/zap"
+)
+
+// SafeModeTokenHeader is the header carrying the unlock token, which confirms
+// a destructive operation in the safe mode.
+const SafeModeTokenHeader = "PD-Safe-Mode-Token"
+
+// SafeMode is the state of the safe mode. In the safe mode, the destructive
+// operations, like deleting a store, are blocked unless they are confirmed
+// with the unlock token, so the production clusters are protected from the
+// accidents of the tools.
+type SafeMode struct {
+	Enabled bool `json:"enabled"`
+	// TokenHash is the hex of the SHA-256 of the unlock token. The token
+	// itself is not persisted.
+	TokenHash  string    `json:"token-hash,omitempty"`
+	UpdateTime time.Time `json:"update-time"`
+}
+
+func hashSafeModeToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+// matchToken returns if the token is the unlock token of the safe mode.
+func (m *SafeMode) matchToken(token string) bool {
+	return subtle.ConstantTimeCompare([]byte(hashSafeModeToken(token)), []byte(m.TokenHash)) == 1
+}
+
+// GetSafeMode returns the state of the safe mode.
+func (s *Server) GetSafeMode() (*SafeMode, error) {
+	state := &SafeMode{}
+	if _, err := s.storage.LoadSafeMode(state); err != nil {
+		return nil, err
+	}
+	return state, nil
+}
+
+// SetSafeMode enables or disables the safe mode. When enabling it, the token
+// becomes the unlock token. If the safe mode is enabled already, the token
+// must match the current unlock token to disable it or to replace the token.
+func (s *Server) SetSafeMode(enabled bool, token string) error {
+	s.safeModeMu.Lock()
+	defer s.safeModeMu.Unlock()
+	state, err := s.GetSafeMode()
+	if err != nil {
+		return err
+	}
+	if state.Enabled && !state.matchToken(token) {
+		return errs.ErrSafeModeToken.FastGenByArgs()
+	}
+	newState := &SafeMode{Enabled: enabled, UpdateTime: time.Now()}
+	if enabled {
+		newState.TokenHash = hashSafeModeToken(token)
+	}
+	if err := s.storage.SaveSafeMode(newState); err != nil {
+		return err
+	}
+	log.Info("safe mode is updated", zap.Bool("enabled", enabled))
+	return nil
+}
+
+// CheckSafeMode returns an error if the cluster is in the safe mode and the
+// token does not match the unlock token, which means the destructive
+// operation should be blocked.
+func (s *Server) CheckSafeMode(token string) error {
+	state, err := s.GetSafeMode()
+	if err != nil {
+		return err
+	}
+	if !state.Enabled {
+		return nil
+	}
+	if !state.matchToken(token) {
+		return errs.ErrSafeModeBlocked.FastGenByArgs(SafeModeTokenHeader)
+	}
+	log.Info("destructive operation is confirmed in the safe mode")
+	return nil
+}
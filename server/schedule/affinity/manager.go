@@ -0,0 +1,310 @@
+// Copyright 2021 TiKV Project Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package affinity
+
+import (
+	"bytes"
+	"encoding/hex"
+	"encoding/json"
+	"sort"
+	"sync"
+
+	"github.com/pingcap/log"
+	"github.com/tikv/pd/pkg/errs"
+	"github.com/tikv/pd/server/core"
+	"go.uber.org/zap"
+)
+
+const affinityGroupPath = "affinity_group"
+
+// The modes of the affinity groups.
+const (
+	// LeaderMode co-locates the leaders of the regions only.
+	LeaderMode = "leader"
+	// ReplicaMode co-locates all voters of the regions, and the leaders.
+	ReplicaMode = "replica"
+)
+
+// KeyRange is a range of keys in hex format. An empty end key means the end
+// of the key space.
+type KeyRange struct {
+	StartKeyHex string `json:"start_key"`
+	EndKeyHex   string `json:"end_key"`
+
+	startKey, endKey []byte
+}
+
+func (r *KeyRange) adjust() (err error) {
+	if r.startKey, err = hex.DecodeString(r.StartKeyHex); err != nil {
+		return errs.ErrHexDecodingString.FastGenByArgs(r.StartKeyHex)
+	}
+	if r.endKey, err = hex.DecodeString(r.EndKeyHex); err != nil {
+		return errs.ErrHexDecodingString.FastGenByArgs(r.EndKeyHex)
+	}
+	if len(r.endKey) > 0 && bytes.Compare(r.endKey, r.startKey) <= 0 {
+		return errs.ErrAffinityGroupContent.FastGenByArgs("endKey should be greater than startKey")
+	}
+	return nil
+}
+
+func (r *KeyRange) overlaps(startKey, endKey []byte) bool {
+	return (len(endKey) == 0 || bytes.Compare(r.startKey, endKey) < 0) &&
+		(len(r.endKey) == 0 || bytes.Compare(startKey, r.endKey) < 0)
+}
+
+// Group is a set of key ranges whose regions should be co-located. In the
+// leader mode, the leaders of the regions are placed on the same store. In
+// the replica mode, the voters are placed on the same store set as well.
+type Group struct {
+	ID     string      `json:"id"`
+	Mode   string      `json:"mode"`
+	Ranges []*KeyRange `json:"ranges"`
+	// The following fields are maintained by PD. They are chosen from the
+	// first region of the group checked, and rechosen once any of the stores
+	// becomes unavailable.
+	LeaderStoreID uint64   `json:"leader_store_id,omitempty"`
+	StoreIDs      []uint64 `json:"store_ids,omitempty"`
+}
+
+func (g *Group) adjust() error {
+	if g.ID == "" {
+		return errs.ErrAffinityGroupContent.FastGenByArgs("id should not be empty")
+	}
+	switch g.Mode {
+	case "":
+		g.Mode = LeaderMode
+	case LeaderMode, ReplicaMode:
+	default:
+		return errs.ErrAffinityGroupContent.FastGenByArgs("mode should be " + LeaderMode + " or " + ReplicaMode)
+	}
+	if len(g.Ranges) == 0 {
+		return errs.ErrAffinityGroupContent.FastGenByArgs("ranges should not be empty")
+	}
+	for _, r := range g.Ranges {
+		if err := r.adjust(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (g *Group) clone() *Group {
+	ng := *g
+	ng.Ranges = make([]*KeyRange, 0, len(g.Ranges))
+	for _, r := range g.Ranges {
+		nr := *r
+		ng.Ranges = append(ng.Ranges, &nr)
+	}
+	ng.StoreIDs = append(g.StoreIDs[:0:0], g.StoreIDs...)
+	return &ng
+}
+
+// HasAnchor returns whether the stores of the group have been chosen.
+func (g *Group) HasAnchor() bool {
+	return g.LeaderStoreID != 0
+}
+
+// ContainsStore returns whether the store is in the store set of the group.
+func (g *Group) ContainsStore(storeID uint64) bool {
+	for _, id := range g.StoreIDs {
+		if id == storeID {
+			return true
+		}
+	}
+	return false
+}
+
+func (g *Group) overlaps(startKey, endKey []byte) bool {
+	for _, r := range g.Ranges {
+		if r.overlaps(startKey, endKey) {
+			return true
+		}
+	}
+	return false
+}
+
+// Manager maintains the affinity groups. The groups, as well as the stores
+// chosen for them, are persisted, so the regions are not moved again after
+// the leader changes.
+type Manager struct {
+	sync.RWMutex
+	storage *core.Storage
+	groups  map[string]*Group
+}
+
+// NewManager creates an affinity group manager and loads the groups from
+// storage.
+func NewManager(storage *core.Storage) (*Manager, error) {
+	m := &Manager{
+		storage: storage,
+		groups:  make(map[string]*Group),
+	}
+	if err := m.load(); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+func (m *Manager) load() error {
+	var toDelete []string
+	err := m.storage.LoadRangeByPrefix(affinityGroupPath+"/", func(k, v string) {
+		g := &Group{}
+		if err := json.Unmarshal([]byte(v), g); err != nil {
+			log.Error("failed to unmarshal affinity group value", zap.String("group-key", k), errs.ZapError(errs.ErrJSONUnmarshal.Wrap(err).FastGenWithCause()))
+			toDelete = append(toDelete, k)
+			return
+		}
+		if err := g.adjust(); err != nil {
+			log.Error("affinity group is in bad format", zap.String("group-key", k), errs.ZapError(err))
+			toDelete = append(toDelete, k)
+			return
+		}
+		m.groups[g.ID] = g
+	})
+	if err != nil {
+		return err
+	}
+	for _, k := range toDelete {
+		if err := m.storage.Remove(affinityGroupPath + "/" + k); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// GetGroup returns the group with the given ID.
+func (m *Manager) GetGroup(id string) *Group {
+	m.RLock()
+	defer m.RUnlock()
+	if g, ok := m.groups[id]; ok {
+		return g.clone()
+	}
+	return nil
+}
+
+// GetGroups returns all groups sorted by ID.
+func (m *Manager) GetGroups() []*Group {
+	m.RLock()
+	defer m.RUnlock()
+	groups := make([]*Group, 0, len(m.groups))
+	for _, g := range m.groups {
+		groups = append(groups, g.clone())
+	}
+	sort.Slice(groups, func(i, j int) bool { return groups[i].ID < groups[j].ID })
+	return groups
+}
+
+// SetGroup creates or updates a group. The stores chosen for an existing
+// group are kept if the mode is not changed.
+func (m *Manager) SetGroup(g *Group) error {
+	g = g.clone()
+	if err := g.adjust(); err != nil {
+		return err
+	}
+	m.Lock()
+	defer m.Unlock()
+	g.LeaderStoreID, g.StoreIDs = 0, nil
+	if old, ok := m.groups[g.ID]; ok && old.Mode == g.Mode {
+		g.LeaderStoreID, g.StoreIDs = old.LeaderStoreID, old.StoreIDs
+	}
+	if err := m.storage.SaveJSON(affinityGroupPath, g.ID, g); err != nil {
+		return err
+	}
+	m.groups[g.ID] = g
+	log.Info("affinity group is set", zap.String("group-id", g.ID), zap.String("mode", g.Mode), zap.Int("ranges", len(g.Ranges)))
+	return nil
+}
+
+// DeleteGroup removes a group. The regions are left where they are.
+func (m *Manager) DeleteGroup(id string) error {
+	m.Lock()
+	defer m.Unlock()
+	if _, ok := m.groups[id]; !ok {
+		return errs.ErrAffinityGroupNotFound.FastGenByArgs(id)
+	}
+	if err := m.storage.Remove(affinityGroupPath + "/" + id); err != nil {
+		return err
+	}
+	delete(m.groups, id)
+	log.Info("affinity group is deleted", zap.String("group-id", id))
+	return nil
+}
+
+// SetAnchor sets the stores the regions of the group are co-located on. A
+// zero leader store resets them, so they are chosen again.
+func (m *Manager) SetAnchor(id string, leaderStoreID uint64, storeIDs []uint64) error {
+	m.Lock()
+	defer m.Unlock()
+	old, ok := m.groups[id]
+	if !ok {
+		return errs.ErrAffinityGroupNotFound.FastGenByArgs(id)
+	}
+	g := old.clone()
+	g.LeaderStoreID = leaderStoreID
+	g.StoreIDs = append(storeIDs[:0:0], storeIDs...)
+	sort.Slice(g.StoreIDs, func(i, j int) bool { return g.StoreIDs[i] < g.StoreIDs[j] })
+	if err := m.storage.SaveJSON(affinityGroupPath, g.ID, g); err != nil {
+		return err
+	}
+	m.groups[id] = g
+	log.Info("affinity group stores are changed", zap.String("group-id", id),
+		zap.Uint64("leader-store", leaderStoreID), zap.Uint64s("stores", g.StoreIDs))
+	return nil
+}
+
+// GetRegionGroup returns the group the region belongs to. If the region
+// overlaps with several groups, the one with the smallest ID is returned.
+func (m *Manager) GetRegionGroup(region *core.RegionInfo) *Group {
+	m.RLock()
+	defer m.RUnlock()
+	g := m.getRegionGroupLocked(region)
+	if g == nil {
+		return nil
+	}
+	return g.clone()
+}
+
+func (m *Manager) getRegionGroupLocked(region *core.RegionInfo) *Group {
+	var found *Group
+	for _, g := range m.groups {
+		if (found == nil || g.ID < found.ID) && g.overlaps(region.GetStartKey(), region.GetEndKey()) {
+			found = g
+		}
+	}
+	return found
+}
+
+// IsLeaderAnchored returns whether the leader of the region on the store is
+// kept there by an affinity group, so it should not be transferred away.
+func (m *Manager) IsLeaderAnchored(region *core.RegionInfo, storeID uint64) bool {
+	m.RLock()
+	defer m.RUnlock()
+	g := m.getRegionGroupLocked(region)
+	return g != nil && g.HasAnchor() && g.LeaderStoreID == storeID
+}
+
+// IsPeerAnchored returns whether the peer of the region on the store is kept
+// there by an affinity group, so it should not be moved away.
+func (m *Manager) IsPeerAnchored(region *core.RegionInfo, storeID uint64) bool {
+	m.RLock()
+	defer m.RUnlock()
+	g := m.getRegionGroupLocked(region)
+	if g == nil || !g.HasAnchor() {
+		return false
+	}
+	if g.Mode == LeaderMode {
+		return g.LeaderStoreID == storeID
+	}
+	return g.ContainsStore(storeID)
+}
@@ -0,0 +1,114 @@
+// Copyright 2021 TiKV Project Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package affinity
+
+import (
+	"encoding/hex"
+	"testing"
+
+	. "github.com/pingcap/check"
+	"github.com/pingcap/errors"
+	"github.com/pingcap/kvproto/pkg/metapb"
+	"github.com/tikv/pd/pkg/errs"
+	"github.com/tikv/pd/server/core"
+	"github.com/tikv/pd/server/kv"
+)
+
+func TestAffinity(t *testing.T) {
+	TestingT(t)
+}
+
+var _ = Suite(&testManagerSuite{})
+
+type testManagerSuite struct{}
+
+func hexKey(key string) string {
+	return hex.EncodeToString([]byte(key))
+}
+
+func newRegion(startKey, endKey string) *core.RegionInfo {
+	return core.NewRegionInfo(&metapb.Region{Id: 1, StartKey: []byte(startKey), EndKey: []byte(endKey)}, nil)
+}
+
+func (s *testManagerSuite) TestValidation(c *C) {
+	m, err := NewManager(core.NewStorage(kv.NewMemoryKV()))
+	c.Assert(err, IsNil)
+	testCases := []struct {
+		group *Group
+		err   *errors.Error
+	}{
+		{&Group{Ranges: []*KeyRange{{StartKeyHex: hexKey("a")}}}, errs.ErrAffinityGroupContent},
+		{&Group{ID: "g", Mode: "unknown", Ranges: []*KeyRange{{StartKeyHex: hexKey("a")}}}, errs.ErrAffinityGroupContent},
+		{&Group{ID: "g"}, errs.ErrAffinityGroupContent},
+		{&Group{ID: "g", Ranges: []*KeyRange{{StartKeyHex: "xyz"}}}, errs.ErrHexDecodingString},
+		{&Group{ID: "g", Ranges: []*KeyRange{{StartKeyHex: hexKey("b"), EndKeyHex: hexKey("a")}}}, errs.ErrAffinityGroupContent},
+	}
+	for _, t := range testCases {
+		c.Assert(t.err.Equal(m.SetGroup(t.group)), IsTrue)
+	}
+	c.Assert(m.GetGroups(), HasLen, 0)
+	c.Assert(errs.ErrAffinityGroupNotFound.Equal(m.DeleteGroup("g")), IsTrue)
+	c.Assert(errs.ErrAffinityGroupNotFound.Equal(m.SetAnchor("g", 1, []uint64{1})), IsTrue)
+}
+
+func (s *testManagerSuite) TestGroup(c *C) {
+	storage := core.NewStorage(kv.NewMemoryKV())
+	m, err := NewManager(storage)
+	c.Assert(err, IsNil)
+	c.Assert(m.SetGroup(&Group{ID: "g2", Ranges: []*KeyRange{
+		{StartKeyHex: hexKey("a"), EndKeyHex: hexKey("c")},
+		{StartKeyHex: hexKey("x"), EndKeyHex: ""},
+	}}), IsNil)
+	c.Assert(m.SetGroup(&Group{ID: "g1", Mode: ReplicaMode, Ranges: []*KeyRange{
+		{StartKeyHex: hexKey("b"), EndKeyHex: hexKey("d")},
+	}}), IsNil)
+	c.Assert(m.GetGroup("g2").Mode, Equals, LeaderMode)
+
+	c.Assert(m.GetRegionGroup(newRegion("a", "aa")).ID, Equals, "g2")
+	c.Assert(m.GetRegionGroup(newRegion("y", "")).ID, Equals, "g2")
+	// The group with the smallest ID wins.
+	c.Assert(m.GetRegionGroup(newRegion("bb", "bc")).ID, Equals, "g1")
+	c.Assert(m.GetRegionGroup(newRegion("e", "f")), IsNil)
+
+	// No region is anchored before the stores are chosen.
+	region := newRegion("a", "aa")
+	c.Assert(m.IsLeaderAnchored(region, 1), IsFalse)
+	c.Assert(m.IsPeerAnchored(region, 1), IsFalse)
+	c.Assert(m.SetAnchor("g2", 1, []uint64{1}), IsNil)
+	c.Assert(m.SetAnchor("g1", 2, []uint64{3, 2, 1}), IsNil)
+	c.Assert(m.GetGroup("g1").StoreIDs, DeepEquals, []uint64{1, 2, 3})
+	c.Assert(m.IsLeaderAnchored(region, 1), IsTrue)
+	c.Assert(m.IsPeerAnchored(region, 1), IsTrue)
+	c.Assert(m.IsPeerAnchored(region, 2), IsFalse)
+	region = newRegion("c", "cc")
+	c.Assert(m.IsLeaderAnchored(region, 1), IsFalse)
+	c.Assert(m.IsLeaderAnchored(region, 2), IsTrue)
+	c.Assert(m.IsPeerAnchored(region, 3), IsTrue)
+
+	// The stores are kept unless the mode is changed.
+	c.Assert(m.SetGroup(&Group{ID: "g2", Ranges: []*KeyRange{{StartKeyHex: hexKey("a"), EndKeyHex: hexKey("b")}}}), IsNil)
+	c.Assert(m.GetGroup("g2").LeaderStoreID, Equals, uint64(1))
+	c.Assert(m.SetGroup(&Group{ID: "g1", Mode: LeaderMode, Ranges: []*KeyRange{{StartKeyHex: hexKey("b"), EndKeyHex: hexKey("d")}}}), IsNil)
+	c.Assert(m.GetGroup("g1").HasAnchor(), IsFalse)
+
+	// The groups are persisted.
+	m2, err := NewManager(storage)
+	c.Assert(err, IsNil)
+	c.Assert(m2.GetGroups(), DeepEquals, m.GetGroups())
+	c.Assert(m2.DeleteGroup("g2"), IsNil)
+	c.Assert(m2.GetGroups(), HasLen, 1)
+	m3, err := NewManager(storage)
+	c.Assert(err, IsNil)
+	c.Assert(m3.GetGroups(), HasLen, 1)
+}
@@ -0,0 +1,180 @@
+// Copyright 2021 TiKV Project Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package checker
+
+import (
+	"github.com/pingcap/kvproto/pkg/metapb"
+	"github.com/pingcap/log"
+	"github.com/tikv/pd/pkg/errs"
+	"github.com/tikv/pd/server/core"
+	"github.com/tikv/pd/server/schedule/affinity"
+	"github.com/tikv/pd/server/schedule/filter"
+	"github.com/tikv/pd/server/schedule/operator"
+	"github.com/tikv/pd/server/schedule/opt"
+	"go.uber.org/zap"
+)
+
+// AffinityChecker moves the regions of the affinity groups to the stores
+// chosen for the groups, so the related ranges are served by the same stores.
+type AffinityChecker struct {
+	cluster opt.Cluster
+}
+
+// NewAffinityChecker creates an affinity checker.
+func NewAffinityChecker(cluster opt.Cluster) *AffinityChecker {
+	return &AffinityChecker{
+		cluster: cluster,
+	}
+}
+
+// GetType returns AffinityChecker's type.
+func (c *AffinityChecker) GetType() string {
+	return "affinity-checker"
+}
+
+// Check verifies whether the region is co-located with its affinity group,
+// creating an Operator if need. A peer is moved at a time, and the leader is
+// transferred after the peers are in place.
+func (c *AffinityChecker) Check(region *core.RegionInfo) *operator.Operator {
+	checkerCounter.WithLabelValues("affinity_checker", "check").Inc()
+	m := c.cluster.GetAffinityManager()
+	if m == nil {
+		return nil
+	}
+	group := m.GetRegionGroup(region)
+	if group == nil {
+		return nil
+	}
+	if region.GetLeader() == nil || len(region.GetDownPeers()) > 0 || len(region.GetPendingPeers()) > 0 {
+		checkerCounter.WithLabelValues("affinity_checker", "unhealthy-region").Inc()
+		return nil
+	}
+	if !group.HasAnchor() || !c.isAnchorAvailable(group) {
+		c.chooseAnchor(group, region)
+		return nil
+	}
+
+	if group.Mode == affinity.ReplicaMode {
+		var sources []*metapb.Peer
+		for _, peer := range region.GetVoters() {
+			if !group.ContainsStore(peer.GetStoreId()) {
+				sources = append(sources, peer)
+			}
+		}
+		if len(sources) > 0 {
+			return c.movePeer(region, sources, group.StoreIDs)
+		}
+	} else if region.GetStoreVoter(group.LeaderStoreID) == nil {
+		// The leader can only be transferred to a voter, so a follower is
+		// moved to the store first.
+		var sources []*metapb.Peer
+		for _, peer := range region.GetFollowers() {
+			if peer.GetRole() == metapb.PeerRole_Voter {
+				sources = append(sources, peer)
+			}
+		}
+		return c.movePeer(region, sources, []uint64{group.LeaderStoreID})
+	}
+	return c.transferLeader(region, group.LeaderStoreID)
+}
+
+// chooseAnchor uses the distribution of the region as the stores of the
+// group, if all of them are available.
+func (c *AffinityChecker) chooseAnchor(group *affinity.Group, region *core.RegionInfo) {
+	leaderStoreID := region.GetLeader().GetStoreId()
+	storeIDs := []uint64{leaderStoreID}
+	if group.Mode == affinity.ReplicaMode {
+		storeIDs = storeIDs[:0]
+		for _, peer := range region.GetVoters() {
+			storeIDs = append(storeIDs, peer.GetStoreId())
+		}
+	}
+	for _, storeID := range storeIDs {
+		if !c.isStoreAvailable(storeID) {
+			checkerCounter.WithLabelValues("affinity_checker", "no-anchor").Inc()
+			return
+		}
+	}
+	if err := c.cluster.GetAffinityManager().SetAnchor(group.ID, leaderStoreID, storeIDs); err != nil {
+		log.Error("failed to set the stores of affinity group", zap.String("group-id", group.ID), errs.ZapError(err))
+		return
+	}
+	checkerCounter.WithLabelValues("affinity_checker", "choose-anchor").Inc()
+}
+
+func (c *AffinityChecker) isAnchorAvailable(group *affinity.Group) bool {
+	if !c.isStoreAvailable(group.LeaderStoreID) {
+		return false
+	}
+	for _, storeID := range group.StoreIDs {
+		if !c.isStoreAvailable(storeID) {
+			return false
+		}
+	}
+	return true
+}
+
+func (c *AffinityChecker) isStoreAvailable(storeID uint64) bool {
+	store := c.cluster.GetStore(storeID)
+	return store != nil && store.IsUp() && store.DownTime() < c.cluster.GetOpts().GetMaxStoreDownTime()
+}
+
+// movePeer moves one of the source peers to one of the target stores which
+// the region has no peer on.
+func (c *AffinityChecker) movePeer(region *core.RegionInfo, sources []*metapb.Peer, targets []uint64) *operator.Operator {
+	for _, peer := range sources {
+		source := c.cluster.GetStore(peer.GetStoreId())
+		if source == nil {
+			continue
+		}
+		filters := []filter.Filter{
+			&filter.StoreStateFilter{ActionScope: c.GetType(), MoveRegion: true},
+			filter.NewPlacementSafeguard(c.GetType(), c.cluster, region, source),
+		}
+		for _, storeID := range targets {
+			target := c.cluster.GetStore(storeID)
+			if target == nil || region.GetStorePeer(storeID) != nil || !filter.Target(c.cluster.GetOpts(), target, filters) {
+				continue
+			}
+			newPeer := &metapb.Peer{StoreId: storeID, Role: peer.GetRole()}
+			op, err := operator.CreateMovePeerOperator("affinity-move-peer", c.cluster, region, operator.OpRegion, peer.GetStoreId(), newPeer)
+			if err != nil {
+				log.Debug("fail to create affinity move peer operator", errs.ZapError(err))
+				continue
+			}
+			checkerCounter.WithLabelValues("affinity_checker", "new-operator").Inc()
+			return op
+		}
+	}
+	checkerCounter.WithLabelValues("affinity_checker", "no-target-store").Inc()
+	return nil
+}
+
+func (c *AffinityChecker) transferLeader(region *core.RegionInfo, storeID uint64) *operator.Operator {
+	if region.GetLeader().GetStoreId() == storeID || region.GetStoreVoter(storeID) == nil {
+		return nil
+	}
+	target := c.cluster.GetStore(storeID)
+	if !filter.Target(c.cluster.GetOpts(), target, []filter.Filter{&filter.StoreStateFilter{ActionScope: c.GetType(), TransferLeader: true}}) {
+		checkerCounter.WithLabelValues("affinity_checker", "no-target-store").Inc()
+		return nil
+	}
+	op, err := operator.CreateTransferLeaderOperator("affinity-transfer-leader", c.cluster, region, region.GetLeader().GetStoreId(), storeID, operator.OpLeader)
+	if err != nil {
+		log.Debug("fail to create affinity transfer leader operator", errs.ZapError(err))
+		return nil
+	}
+	checkerCounter.WithLabelValues("affinity_checker", "new-operator").Inc()
+	return op
+}
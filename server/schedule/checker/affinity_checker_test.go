@@ -0,0 +1,125 @@
+// Copyright 2021 TiKV Project Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package checker
+
+import (
+	"context"
+	"encoding/hex"
+
+	. "github.com/pingcap/check"
+	"github.com/pingcap/kvproto/pkg/metapb"
+	"github.com/tikv/pd/pkg/mock/mockcluster"
+	"github.com/tikv/pd/pkg/testutil"
+	"github.com/tikv/pd/server/config"
+	"github.com/tikv/pd/server/core"
+	"github.com/tikv/pd/server/schedule/affinity"
+	"github.com/tikv/pd/server/schedule/operator"
+	"github.com/tikv/pd/server/versioninfo"
+)
+
+var _ = Suite(&testAffinityCheckerSuite{})
+
+type testAffinityCheckerSuite struct {
+	cluster *mockcluster.Cluster
+	ac      *AffinityChecker
+	ctx     context.Context
+	cancel  context.CancelFunc
+}
+
+func (s *testAffinityCheckerSuite) SetUpTest(c *C) {
+	s.ctx, s.cancel = context.WithCancel(context.Background())
+	s.cluster = mockcluster.NewCluster(s.ctx, config.NewTestOptions())
+	s.cluster.DisableFeature(versioninfo.JointConsensus)
+	s.ac = NewAffinityChecker(s.cluster)
+	for id := uint64(1); id <= 5; id++ {
+		s.cluster.AddRegionStore(id, 10)
+	}
+}
+
+func (s *testAffinityCheckerSuite) TearDownTest(c *C) {
+	s.cancel()
+}
+
+func (s *testAffinityCheckerSuite) setGroup(c *C, id, mode, startKey, endKey string) {
+	c.Assert(s.cluster.GetAffinityManager().SetGroup(&affinity.Group{
+		ID:     id,
+		Mode:   mode,
+		Ranges: []*affinity.KeyRange{{StartKeyHex: hex.EncodeToString([]byte(startKey)), EndKeyHex: hex.EncodeToString([]byte(endKey))}},
+	}), IsNil)
+}
+
+func (s *testAffinityCheckerSuite) TestReplicaMode(c *C) {
+	s.setGroup(c, "g", affinity.ReplicaMode, "a", "c")
+	m := s.cluster.GetAffinityManager()
+
+	// The regions out of the groups are ignored.
+	s.cluster.AddLeaderRegionWithRange(1, "x", "y", 4, 2, 5)
+	c.Assert(s.ac.Check(s.cluster.GetRegion(1)), IsNil)
+
+	// The first region checked decides the stores.
+	s.cluster.AddLeaderRegionWithRange(2, "a", "b", 1, 2, 3)
+	c.Assert(s.ac.Check(s.cluster.GetRegion(2)), IsNil)
+	group := m.GetGroup("g")
+	c.Assert(group.LeaderStoreID, Equals, uint64(1))
+	c.Assert(group.StoreIDs, DeepEquals, []uint64{1, 2, 3})
+
+	s.cluster.AddLeaderRegionWithRange(3, "b", "c", 2, 3, 5)
+	op := s.ac.Check(s.cluster.GetRegion(3))
+	c.Assert(op, NotNil)
+	c.Assert(op.Desc(), Equals, "affinity-move-peer")
+	testutil.CheckTransferPeer(c, op, operator.OpRegion, 5, 1)
+
+	s.cluster.AddLeaderRegionWithRange(3, "b", "c", 2, 3, 1)
+	op = s.ac.Check(s.cluster.GetRegion(3))
+	c.Assert(op, NotNil)
+	c.Assert(op.Desc(), Equals, "affinity-transfer-leader")
+	testutil.CheckTransferLeader(c, op, operator.OpLeader, 2, 1)
+
+	s.cluster.AddLeaderRegionWithRange(3, "b", "c", 1, 3, 2)
+	c.Assert(s.ac.Check(s.cluster.GetRegion(3)), IsNil)
+
+	// The stores are chosen again once any of them is unavailable.
+	s.cluster.SetStoreOffline(3)
+	s.cluster.AddLeaderRegionWithRange(3, "b", "c", 4, 5, 2)
+	c.Assert(s.ac.Check(s.cluster.GetRegion(3)), IsNil)
+	group = m.GetGroup("g")
+	c.Assert(group.LeaderStoreID, Equals, uint64(4))
+	c.Assert(group.StoreIDs, DeepEquals, []uint64{2, 4, 5})
+}
+
+func (s *testAffinityCheckerSuite) TestLeaderMode(c *C) {
+	s.setGroup(c, "g", affinity.LeaderMode, "a", "")
+
+	s.cluster.AddLeaderRegionWithRange(1, "a", "b", 1, 2, 3)
+	c.Assert(s.ac.Check(s.cluster.GetRegion(1)), IsNil)
+	group := s.cluster.GetAffinityManager().GetGroup("g")
+	c.Assert(group.LeaderStoreID, Equals, uint64(1))
+	c.Assert(group.StoreIDs, DeepEquals, []uint64{1})
+
+	// A follower is moved to the store before the leader is transferred.
+	s.cluster.AddLeaderRegionWithRange(2, "b", "c", 4, 5)
+	op := s.ac.Check(s.cluster.GetRegion(2))
+	c.Assert(op, NotNil)
+	testutil.CheckTransferPeer(c, op, operator.OpRegion, 5, 1)
+
+	s.cluster.AddLeaderRegionWithRange(2, "b", "c", 4, 1)
+	op = s.ac.Check(s.cluster.GetRegion(2))
+	c.Assert(op, NotNil)
+	testutil.CheckTransferLeader(c, op, operator.OpLeader, 4, 1)
+
+	// The unhealthy regions are skipped.
+	region := s.cluster.GetRegion(2)
+	region = region.Clone(core.WithPendingPeers([]*metapb.Peer{region.GetStorePeer(1)}))
+	c.Assert(s.ac.Check(region), IsNil)
+}
@@ -33,6 +33,11 @@ func NewJointStateChecker(cluster opt.Cluster) *JointStateChecker {
 	}
 }
 
+// GetType returns JointStateChecker's type.
+func (c *JointStateChecker) GetType() string {
+	return "joint-state-checker"
+}
+
 // Check verifies a region's role, creating an Operator if need.
 func (c *JointStateChecker) Check(region *core.RegionInfo) *operator.Operator {
 	checkerCounter.WithLabelValues("joint_state_checker", "check").Inc()
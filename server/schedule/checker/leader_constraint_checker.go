@@ -0,0 +1,119 @@
+// Copyright 2021 TiKV Project Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package checker
+
+import (
+	"sync"
+	"time"
+
+	"github.com/pingcap/log"
+	"github.com/tikv/pd/pkg/errs"
+	"github.com/tikv/pd/server/config"
+	"github.com/tikv/pd/server/core"
+	"github.com/tikv/pd/server/schedule/filter"
+	"github.com/tikv/pd/server/schedule/leaderconstraint"
+	"github.com/tikv/pd/server/schedule/operator"
+	"github.com/tikv/pd/server/schedule/opt"
+)
+
+// leaderConstraintStateTTL is how long the leader distribution is reused,
+// because it needs to go through all stores. It is updated by the operators
+// created in the meantime.
+const leaderConstraintStateTTL = 10 * time.Second
+
+// LeaderConstraintChecker moves the leaders out of the stores beyond the max
+// ratio of the hard leader constraints.
+type LeaderConstraintChecker struct {
+	cluster opt.Cluster
+
+	mu         sync.Mutex
+	state      *leaderconstraint.State
+	updateTime time.Time
+}
+
+// NewLeaderConstraintChecker creates a leader constraint checker.
+func NewLeaderConstraintChecker(cluster opt.Cluster) *LeaderConstraintChecker {
+	return &LeaderConstraintChecker{
+		cluster: cluster,
+	}
+}
+
+// GetType returns LeaderConstraintChecker's type.
+func (c *LeaderConstraintChecker) GetType() string {
+	return "leader-constraint-checker"
+}
+
+// Check verifies whether the leader of the region is on the stores of a
+// violated hard leader constraint, creating an Operator to transfer it to a
+// follower out of them if need.
+func (c *LeaderConstraintChecker) Check(region *core.RegionInfo) *operator.Operator {
+	checkerCounter.WithLabelValues("leader_constraint_checker", "check").Inc()
+	constraints := c.cluster.GetOpts().GetLeaderConstraints()
+	if !hasHardLeaderConstraint(constraints) {
+		return nil
+	}
+	source := c.cluster.GetStore(region.GetLeader().GetStoreId())
+	if source == nil {
+		return nil
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.state == nil || time.Since(c.updateTime) > leaderConstraintStateTTL {
+		c.state = leaderconstraint.NewState(constraints, c.cluster.GetStores())
+		c.updateTime = time.Now()
+	}
+	if !c.state.IsViolated(source, true) {
+		return nil
+	}
+	checkerCounter.WithLabelValues("leader_constraint_checker", "violated").Inc()
+
+	filters := []filter.Filter{
+		&filter.StoreStateFilter{ActionScope: c.GetType(), TransferLeader: true},
+		filter.NewLeaderConstraintFilter(c.GetType(), c.state, source),
+	}
+	if leaderFilter := filter.NewPlacementLeaderSafeguard(c.GetType(), c.cluster, region, source); leaderFilter != nil {
+		filters = append(filters, leaderFilter)
+	}
+	var target *core.StoreInfo
+	for _, store := range filter.SelectTargetStores(c.cluster.GetFollowerStores(region), filters, c.cluster.GetOpts()) {
+		if c.state.IsViolated(store, false) {
+			continue
+		}
+		if target == nil || store.GetLeaderCount() < target.GetLeaderCount() {
+			target = store
+		}
+	}
+	if target == nil {
+		checkerCounter.WithLabelValues("leader_constraint_checker", "no-target-store").Inc()
+		return nil
+	}
+	op, err := operator.CreateTransferLeaderOperator("leader-constraint", c.cluster, region, source.GetID(), target.GetID(), operator.OpLeader)
+	if err != nil {
+		log.Debug("fail to create leader constraint operator", errs.ZapError(err))
+		return nil
+	}
+	c.state.Transfer(source, target)
+	checkerCounter.WithLabelValues("leader_constraint_checker", "new-operator").Inc()
+	return op
+}
+
+func hasHardLeaderConstraint(constraints []config.LeaderConstraint) bool {
+	for i := range constraints {
+		if constraints[i].IsHard() {
+			return true
+		}
+	}
+	return false
+}
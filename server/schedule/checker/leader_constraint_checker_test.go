@@ -0,0 +1,74 @@
+// Copyright 2021 TiKV Project Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package checker
+
+import (
+	"context"
+
+	. "github.com/pingcap/check"
+	"github.com/tikv/pd/pkg/mock/mockcluster"
+	"github.com/tikv/pd/pkg/testutil"
+	"github.com/tikv/pd/server/config"
+	"github.com/tikv/pd/server/schedule/operator"
+)
+
+var _ = Suite(&testLeaderConstraintCheckerSuite{})
+
+type testLeaderConstraintCheckerSuite struct {
+	cluster *mockcluster.Cluster
+	ctx     context.Context
+	cancel  context.CancelFunc
+}
+
+func (s *testLeaderConstraintCheckerSuite) SetUpTest(c *C) {
+	s.ctx, s.cancel = context.WithCancel(context.Background())
+	s.cluster = mockcluster.NewCluster(s.ctx, config.NewTestOptions())
+	zones := map[uint64]string{1: "z1", 2: "z1", 3: "z2", 4: "z3"}
+	leaderCounts := map[uint64]int{1: 6, 2: 0, 3: 2, 4: 2}
+	for id := uint64(1); id <= 4; id++ {
+		s.cluster.AddLabelsStore(id, 10, map[string]string{"zone": zones[id]})
+		s.cluster.UpdateLeaderCount(id, leaderCounts[id])
+	}
+	for id := uint64(1); id <= 3; id++ {
+		s.cluster.AddLeaderRegion(id, 1, 2, 3)
+	}
+	s.cluster.AddLeaderRegion(4, 3, 1, 4)
+}
+
+func (s *testLeaderConstraintCheckerSuite) TearDownTest(c *C) {
+	s.cancel()
+}
+
+func (s *testLeaderConstraintCheckerSuite) TestCheck(c *C) {
+	lc := NewLeaderConstraintChecker(s.cluster)
+	c.Assert(lc.Check(s.cluster.GetRegion(1)), IsNil)
+
+	// The soft constraints are not enforced by the checker.
+	constraint := config.LeaderConstraint{LabelKey: "zone", LabelValue: "z1", MaxRatio: 0.4, Mode: config.LeaderConstraintSoft}
+	s.cluster.SetLeaderConstraints([]config.LeaderConstraint{constraint})
+	c.Assert(lc.Check(s.cluster.GetRegion(1)), IsNil)
+
+	// The leaders are transferred out of z1 until 40% of the leaders are in
+	// it, and the followers in z1 are not the targets.
+	constraint.Mode = config.LeaderConstraintHard
+	s.cluster.SetLeaderConstraints([]config.LeaderConstraint{constraint})
+	c.Assert(lc.Check(s.cluster.GetRegion(4)), IsNil)
+	for id := uint64(1); id <= 2; id++ {
+		op := lc.Check(s.cluster.GetRegion(id))
+		c.Assert(op, NotNil)
+		c.Assert(op.Desc(), Equals, "leader-constraint")
+		testutil.CheckTransferLeader(c, op, operator.OpLeader, 1, 3)
+	}
+	c.Assert(lc.Check(s.cluster.GetRegion(3)), IsNil)
+}
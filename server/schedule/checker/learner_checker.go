@@ -33,6 +33,11 @@ func NewLearnerChecker(cluster opt.Cluster) *LearnerChecker {
 	}
 }
 
+// GetType returns LearnerChecker's type.
+func (l *LearnerChecker) GetType() string {
+	return "learner-checker"
+}
+
 // Check verifies a region's role, creating an Operator if need.
 func (l *LearnerChecker) Check(region *core.RegionInfo) *operator.Operator {
 	for _, p := range region.GetLearners() {
@@ -28,6 +28,7 @@ import (
 	"github.com/tikv/pd/server/schedule/operator"
 	"github.com/tikv/pd/server/schedule/opt"
 	"github.com/tikv/pd/server/schedule/placement"
+	"go.uber.org/zap"
 )
 
 const maxTargetRegionSize = 500
@@ -119,7 +120,7 @@ func (m *MergeChecker) Check(region *core.RegionInfo) []*operator.Operator {
 		target = next
 	}
 	if !m.opts.IsOneWayMergeEnabled() && m.checkTarget(region, prev) { // allow a region can be merged by two ways.
-		if target == nil || prev.GetApproximateSize() < next.GetApproximateSize() { // pick smaller
+		if target == nil || betterMergeTarget(region, prev, next) {
 			target = prev
 		}
 	}
@@ -134,15 +135,19 @@ func (m *MergeChecker) Check(region *core.RegionInfo) []*operator.Operator {
 		return nil
 	}
 
+	movedPeers := mergeMovedPeers(region, target)
 	log.Debug("try to merge region",
 		logutil.ZapRedactStringer("from", core.RegionToHexMeta(region.GetMeta())),
-		logutil.ZapRedactStringer("to", core.RegionToHexMeta(target.GetMeta())))
+		logutil.ZapRedactStringer("to", core.RegionToHexMeta(target.GetMeta())),
+		zap.Int64("moved-peers", movedPeers))
 	ops, err := operator.CreateMergeRegionOperator("merge-region", m.cluster, region, target, operator.OpMerge)
 	if err != nil {
 		log.Warn("create merge region operator failed", errs.ZapError(err))
 		return nil
 	}
 	checkerCounter.WithLabelValues("merge_checker", "new-operator").Inc()
+	mergeMovedPeersHistogram.Observe(float64(movedPeers))
+	mergeMovedSizeHistogram.Observe(float64(movedPeers * region.GetApproximateSize()))
 	if region.GetApproximateSize() > target.GetApproximateSize() ||
 		region.GetApproximateKeys() > target.GetApproximateKeys() {
 		checkerCounter.WithLabelValues("merge_checker", "larger-source").Inc()
@@ -150,6 +155,29 @@ func (m *MergeChecker) Check(region *core.RegionInfo) []*operator.Operator {
 	return ops
 }
 
+// betterMergeTarget returns true if merging the region into a costs less than
+// into b. The region has to move its peers which are not on the stores of the
+// target before merging, so the target sharing more stores is preferred, and
+// then the smaller one, which makes a smaller region after merging.
+func betterMergeTarget(region, a, b *core.RegionInfo) bool {
+	if movedA, movedB := mergeMovedPeers(region, a), mergeMovedPeers(region, b); movedA != movedB {
+		return movedA < movedB
+	}
+	return a.GetApproximateSize() < b.GetApproximateSize()
+}
+
+// mergeMovedPeers returns the number of the peers of the region to move to
+// merge it into the target.
+func mergeMovedPeers(region, target *core.RegionInfo) int64 {
+	var moved int64
+	for _, p := range region.GetPeers() {
+		if target.GetStorePeer(p.GetStoreId()) == nil {
+			moved++
+		}
+	}
+	return moved
+}
+
 func (m *MergeChecker) checkTarget(region, adjacent *core.RegionInfo) bool {
 	return adjacent != nil && !m.splitCache.Exists(adjacent.GetID()) && !m.cluster.IsRegionHot(adjacent) &&
 		AllowMerge(m.cluster, region, adjacent) && opt.IsRegionHealthy(m.cluster, adjacent) &&
@@ -214,6 +214,48 @@ func (s *testMergeCheckerSuite) TestBasic(c *C) {
 	c.Assert(ops, IsNil)
 }
 
+func (s *testMergeCheckerSuite) TestTargetSharedStores(c *C) {
+	s.cluster.SetSplitMergeInterval(0)
+	s.mc.startTime = time.Now().Add(-2 * time.Hour)
+
+	// The next region is larger, but the region doesn't need to move any peer
+	// to merge into it.
+	next := core.NewRegionInfo(
+		&metapb.Region{
+			Id:       4,
+			StartKey: []byte("x"),
+			EndKey:   []byte(""),
+			Peers: []*metapb.Peer{
+				{Id: 109, StoreId: 2},
+				{Id: 110, StoreId: 5},
+				{Id: 111, StoreId: 6},
+			},
+		},
+		&metapb.Peer{Id: 109, StoreId: 2},
+		core.SetApproximateSize(300),
+		core.SetApproximateKeys(300),
+	)
+	s.cluster.PutRegion(next)
+	c.Assert(mergeMovedPeers(s.regions[2], s.regions[1]), Equals, int64(2))
+	c.Assert(mergeMovedPeers(s.regions[2], next), Equals, int64(0))
+	ops := s.mc.Check(s.regions[2])
+	c.Assert(ops, NotNil)
+	c.Assert(ops[0].RegionID(), Equals, s.regions[2].GetID())
+	c.Assert(ops[1].RegionID(), Equals, next.GetID())
+	c.Assert(ops[0].Len(), Equals, 1)
+
+	// The previous region shares more stores.
+	next = next.Clone(core.SetPeers([]*metapb.Peer{
+		{Id: 109, StoreId: 1},
+		{Id: 110, StoreId: 3},
+		{Id: 111, StoreId: 4},
+	}), core.WithLeader(&metapb.Peer{Id: 109, StoreId: 1}))
+	s.cluster.PutRegion(next)
+	ops = s.mc.Check(s.regions[2])
+	c.Assert(ops, NotNil)
+	c.Assert(ops[1].RegionID(), Equals, s.regions[1].GetID())
+}
+
 func (s *testMergeCheckerSuite) checkSteps(c *C, op *operator.Operator, steps []operator.OpStep) {
 	c.Assert(op.Kind()&operator.OpMerge, Not(Equals), 0)
 	c.Assert(steps, NotNil)
@@ -23,8 +23,28 @@ var (
 			Name:      "event_count",
 			Help:      "Counter of checker events.",
 		}, []string{"type", "name"})
+
+	mergeMovedPeersHistogram = prometheus.NewHistogram(
+		prometheus.HistogramOpts{
+			Namespace: "pd",
+			Subsystem: "checker",
+			Name:      "merge_moved_peers",
+			Help:      "Bucketed histogram of the number of the peers to move for each merge.",
+			Buckets:   prometheus.LinearBuckets(0, 1, 6),
+		})
+
+	mergeMovedSizeHistogram = prometheus.NewHistogram(
+		prometheus.HistogramOpts{
+			Namespace: "pd",
+			Subsystem: "checker",
+			Name:      "merge_moved_size_mb",
+			Help:      "Bucketed histogram of the estimated size in MB to move for each merge.",
+			Buckets:   prometheus.ExponentialBuckets(1, 2, 10),
+		})
 )
 
 func init() {
 	prometheus.MustRegister(checkerCounter)
+	prometheus.MustRegister(mergeMovedPeersHistogram)
+	prometheus.MustRegister(mergeMovedSizeHistogram)
 }
@@ -0,0 +1,78 @@
+// Copyright 2021 TiKV Project Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package checker
+
+import (
+	"math"
+	"sync"
+
+	"github.com/juju/ratelimit"
+	"github.com/tikv/pd/pkg/cache"
+)
+
+const orphanWaitingListSize = 1000
+
+// orphanLimiter caps the rate of removing the orphan peers cluster-wide, so
+// that a mass rule change cannot saturate the remove-peer limits of the
+// stores needed by the urgent fixes. The regions whose orphan peers are not
+// removed because of the cap are kept in a separate waiting list.
+type orphanLimiter struct {
+	sync.Mutex
+	// rate is the regions per minute, which is 0 if the cap is disabled.
+	rate   uint64
+	bucket *ratelimit.Bucket
+
+	waitingList cache.Cache
+}
+
+func newOrphanLimiter() *orphanLimiter {
+	return &orphanLimiter{
+		waitingList: cache.NewDefaultCache(orphanWaitingListSize),
+	}
+}
+
+// allow takes a token for the region if the rate allows, or puts the region
+// into the waiting list otherwise.
+func (l *orphanLimiter) allow(regionID, rate uint64) bool {
+	l.Lock()
+	defer l.Unlock()
+	l.resetLocked(rate)
+	if l.bucket == nil || l.bucket.TakeAvailable(1) > 0 {
+		return true
+	}
+	l.waitingList.Put(regionID, nil)
+	return false
+}
+
+// isThrottled returns whether no region is allowed to remove the orphan peers
+// for now.
+func (l *orphanLimiter) isThrottled(rate uint64) bool {
+	l.Lock()
+	defer l.Unlock()
+	l.resetLocked(rate)
+	return l.bucket != nil && l.bucket.Available() <= 0
+}
+
+// resetLocked rebuilds the bucket if the rate changes. The bucket holds at
+// most the tokens of a second, to avoid the bursts.
+func (l *orphanLimiter) resetLocked(rate uint64) {
+	if rate == l.rate {
+		return
+	}
+	l.rate, l.bucket = rate, nil
+	if rate > 0 {
+		ratePerSec := float64(rate) / 60
+		l.bucket = ratelimit.NewBucketWithRate(ratePerSec, int64(math.Ceil(ratePerSec)))
+	}
+}
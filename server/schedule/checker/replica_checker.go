@@ -112,6 +112,10 @@ func (r *ReplicaChecker) checkDownPeer(region *core.RegionInfo) *operator.Operat
 		if stats.GetDownSeconds() < uint64(r.opts.GetMaxStoreDownTime().Seconds()) {
 			continue
 		}
+		if store.IsSuspect() && !isQuorumAtRisk(region, peer) {
+			checkerCounter.WithLabelValues("replica_checker", "skip-suspect-down-peer").Inc()
+			continue
+		}
 
 		return r.fixPeer(region, storeID, downStatus)
 	}
@@ -154,6 +158,11 @@ func (r *ReplicaChecker) checkMakeUpReplica(region *core.RegionInfo) *operator.O
 	}
 	log.Debug("region has fewer than max replicas", zap.Uint64("region-id", region.GetID()), zap.Int("peers", len(region.GetPeers())))
 	regionStores := r.cluster.GetRegionStores(region)
+	if missing := r.opts.GetMaxReplicas() - len(region.GetPeers()); missing > 1 && r.opts.IsParallelMakeUpReplicaEnabled() {
+		if op := r.makeUpReplicasInParallel(region, regionStores, missing); op != nil {
+			return op
+		}
+	}
 	target := r.strategy(region).SelectStoreToAdd(regionStores)
 	if target == 0 {
 		log.Debug("no store to add replica", zap.Uint64("region-id", region.GetID()))
@@ -170,6 +179,26 @@ func (r *ReplicaChecker) checkMakeUpReplica(region *core.RegionInfo) *operator.O
 	return op
 }
 
+// makeUpReplicasInParallel creates an operator adding all missing replicas at
+// once. It returns nil if fewer than two stores are available to add.
+func (r *ReplicaChecker) makeUpReplicasInParallel(region *core.RegionInfo, regionStores []*core.StoreInfo, missing int) *operator.Operator {
+	targets := r.strategy(region).SelectStoresToAdd(regionStores, missing)
+	if len(targets) < 2 {
+		return nil
+	}
+	peers := make([]*metapb.Peer, 0, len(targets))
+	for _, target := range targets {
+		peers = append(peers, &metapb.Peer{StoreId: target})
+	}
+	op, err := operator.CreateAddPeersOperator("make-up-replicas", r.cluster, region, peers, operator.OpReplica)
+	if err != nil {
+		log.Debug("create make-up-replicas operator fail", errs.ZapError(err))
+		return nil
+	}
+	checkerCounter.WithLabelValues("replica_checker", "parallel-make-up-replica").Inc()
+	return op
+}
+
 func (r *ReplicaChecker) checkRemoveExtraReplica(region *core.RegionInfo) *operator.Operator {
 	if !r.opts.IsRemoveExtraReplicaEnabled() {
 		return nil
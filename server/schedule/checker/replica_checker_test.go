@@ -278,6 +278,68 @@ func (s *testReplicaCheckerSuite) TestBasic(c *C) {
 	testutil.CheckTransferPeer(c, rc.Check(region), operator.OpReplica, 3, 1)
 }
 
+func (s *testReplicaCheckerSuite) TestCompactionPressure(c *C) {
+	opt := config.NewTestOptions()
+	tc := mockcluster.NewCluster(s.ctx, opt)
+	tc.DisableFeature(versioninfo.JointConsensus)
+	rc := NewReplicaChecker(tc, cache.NewDefaultCache(10))
+	cfg := tc.GetScheduleConfig().Clone()
+	cfg.CompactionPressureThreshold = 100
+	tc.SetScheduleConfig(cfg)
+
+	tc.AddRegionStore(1, 4)
+	tc.AddRegionStore(2, 3)
+	tc.AddRegionStore(3, 2)
+	tc.AddRegionStore(4, 1)
+	tc.AddLeaderRegion(1, 1, 2)
+	region := tc.GetRegion(1)
+	testutil.CheckAddPeer(c, rc.Check(region), operator.OpReplica, 4)
+
+	// The store under compaction pressure is deprioritized.
+	tc.PutStore(tc.GetStore(4).Clone(core.SetPendingCompactionBytes(100)))
+	testutil.CheckAddPeer(c, rc.Check(region), operator.OpReplica, 3)
+	// It is still selected if all stores are under compaction pressure.
+	tc.PutStore(tc.GetStore(3).Clone(core.SetPendingCompactionBytes(200)))
+	testutil.CheckAddPeer(c, rc.Check(region), operator.OpReplica, 4)
+}
+
+func (s *testReplicaCheckerSuite) TestParallelMakeUpReplica(c *C) {
+	opt := config.NewTestOptions()
+	tc := mockcluster.NewCluster(s.ctx, opt)
+	rc := NewReplicaChecker(tc, cache.NewDefaultCache(10))
+	tc.SetEnableParallelMakeUpReplica(true)
+	tc.AddRegionStore(1, 4)
+	tc.AddRegionStore(2, 3)
+	tc.AddRegionStore(3, 2)
+	tc.AddLeaderRegion(1, 1)
+
+	// The two learners are added without waiting for each other, and then
+	// promoted together.
+	op := rc.Check(tc.GetRegion(1))
+	c.Assert(op, NotNil)
+	c.Assert(op.Desc(), Equals, "make-up-replicas")
+	c.Assert(op.Len(), Equals, 4)
+	for i, store := range []uint64{2, 3} {
+		step := op.Step(i).(operator.AddLearner)
+		c.Assert(step.ToStore, Equals, store)
+		c.Assert(step.SkipWaitingForSnapshot, IsTrue)
+	}
+	c.Assert(op.Step(2), FitsTypeOf, operator.ChangePeerV2Enter{})
+	c.Assert(op.Step(2).(operator.ChangePeerV2Enter).PromoteLearners, HasLen, 2)
+	c.Assert(op.Step(3), FitsTypeOf, operator.ChangePeerV2Leave{})
+
+	// Fall back to adding one peer if only one store is available.
+	tc.SetStoreDown(3)
+	testutil.CheckAddPeer(c, rc.Check(tc.GetRegion(1)), operator.OpReplica, 2)
+
+	// Fall back to adding one peer without joint consensus.
+	tc.SetStoreUp(3)
+	tc.DisableFeature(versioninfo.JointConsensus)
+	op = rc.Check(tc.GetRegion(1))
+	c.Assert(op, NotNil)
+	c.Assert(op.Step(0).(operator.AddLearner).SkipWaitingForSnapshot, IsFalse)
+}
+
 func (s *testReplicaCheckerSuite) TestLostStore(c *C) {
 	opt := config.NewTestOptions()
 	tc := mockcluster.NewCluster(s.ctx, opt)
@@ -555,6 +617,31 @@ func (s *testReplicaCheckerSuite) TestFixDownPeer(c *C) {
 	c.Assert(rc.Check(region), IsNil)
 }
 
+func (s *testReplicaCheckerSuite) TestRestartingDownPeer(c *C) {
+	opt := config.NewTestOptions()
+	tc := mockcluster.NewCluster(s.ctx, opt)
+	tc.DisableFeature(versioninfo.JointConsensus)
+	rc := NewReplicaChecker(tc, cache.NewDefaultCache(10))
+
+	for i := uint64(1); i <= 4; i++ {
+		tc.AddRegionStore(i, 1)
+	}
+	tc.AddLeaderRegion(1, 1, 2, 3)
+	region := tc.GetRegion(1)
+	tc.SetStoreDown(3)
+	region = region.Clone(core.WithDownPeers([]*pdpb.PeerStats{
+		{Peer: region.GetStorePeer(3), DownSeconds: 6000},
+	}))
+
+	// The down peer is not replaced within the restart window.
+	tc.PutStore(tc.GetStore(3).Clone(core.SetRestartDeadline(time.Now().Add(time.Minute))))
+	c.Assert(rc.Check(region), IsNil)
+
+	// It is replaced as usual once the window expires.
+	tc.PutStore(tc.GetStore(3).Clone(core.SetRestartDeadline(time.Now().Add(-time.Minute))))
+	testutil.CheckTransferPeer(c, rc.Check(region), operator.OpRegion, 3, 4)
+}
+
 // See issue: https://github.com/tikv/pd/issues/3705
 func (s *testReplicaCheckerSuite) TestFixOfflinePeer(c *C) {
 	opt := config.NewTestOptions()
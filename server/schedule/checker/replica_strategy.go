@@ -69,9 +69,11 @@ func (s *ReplicaStrategy) SelectStoreToAdd(coLocationStores []*core.StoreInfo, e
 
 	isolationComparer := filter.IsolationComparer(s.locationLabels, coLocationStores)
 	strictStateFilter := &filter.StoreStateFilter{ActionScope: s.checkerName, MoveRegion: true}
+	compactionFilter := filter.NewCompactionPressureFilter(s.checkerName)
 	target := filter.NewCandidates(s.cluster.GetStores()).
 		FilterTarget(s.cluster.GetOpts(), filters...).
 		Sort(isolationComparer).Reverse().Top(isolationComparer).        // greater isolation score is better
+		PreferTarget(s.cluster.GetOpts(), compactionFilter).             // the stores under compaction pressure are the last resort
 		Sort(filter.RegionScoreComparer(s.cluster.GetOpts())).           // less region score is better
 		FilterTarget(s.cluster.GetOpts(), strictStateFilter).PickFirst() // the filter does not ignore temp states
 	if target == nil {
@@ -80,6 +82,29 @@ func (s *ReplicaStrategy) SelectStoreToAdd(coLocationStores []*core.StoreInfo, e
 	return target.GetID()
 }
 
+// SelectStoresToAdd returns at most n stores to add the replicas to a region.
+// Each store is selected with the previously selected ones added to
+// `coLocationStores`, so that the replicas are isolated from each other.
+func (s *ReplicaStrategy) SelectStoresToAdd(coLocationStores []*core.StoreInfo, n int) []uint64 {
+	var targets []uint64
+	selected := make(map[uint64]struct{})
+	coLocationStores = append(coLocationStores[:0:0], coLocationStores...)
+	for len(targets) < n {
+		target := s.SelectStoreToAdd(coLocationStores, filter.NewExcludedFilter(s.checkerName, nil, selected))
+		if target == 0 {
+			break
+		}
+		store := s.cluster.GetStore(target)
+		if store == nil {
+			break
+		}
+		targets = append(targets, target)
+		selected[target] = struct{}{}
+		coLocationStores = append(coLocationStores, store)
+	}
+	return targets
+}
+
 // SelectStoreToFix returns a store to replace down/offline old peer. The location
 // placement after scheduling is allowed to be worse than original.
 func (s *ReplicaStrategy) SelectStoreToFix(coLocationStores []*core.StoreInfo, old uint64) uint64 {
@@ -38,6 +38,7 @@ type RuleChecker struct {
 	name              string
 	regionWaitingList cache.Cache
 	record            *recorder
+	orphanLimiter     *orphanLimiter
 }
 
 // NewRuleChecker creates a checker instance.
@@ -48,6 +49,7 @@ func NewRuleChecker(cluster opt.Cluster, ruleManager *placement.RuleManager, reg
 		name:              "rule-checker",
 		regionWaitingList: regionWaitingList,
 		record:            newRecord(),
+		orphanLimiter:     newOrphanLimiter(),
 	}
 }
 
@@ -133,6 +135,11 @@ func (c *RuleChecker) fixRulePeer(region *core.RegionInfo, fit *placement.Region
 func (c *RuleChecker) addRulePeer(region *core.RegionInfo, rf *placement.RuleFit) (*operator.Operator, error) {
 	checkerCounter.WithLabelValues("rule_checker", "add-rule-peer").Inc()
 	ruleStores := c.getRuleFitStores(rf)
+	if missing := rf.Rule.Count - len(rf.Peers); missing > 1 && c.cluster.GetOpts().IsParallelMakeUpReplicaEnabled() {
+		if op := c.addRulePeersInParallel(region, rf, ruleStores, missing); op != nil {
+			return op, nil
+		}
+	}
 	store := c.strategy(region, rf.Rule).SelectStoreToAdd(ruleStores)
 	if store == 0 {
 		checkerCounter.WithLabelValues("rule_checker", "no-store-add").Inc()
@@ -148,6 +155,27 @@ func (c *RuleChecker) addRulePeer(region *core.RegionInfo, rf *placement.RuleFit
 	return op, nil
 }
 
+// addRulePeersInParallel creates an operator adding all missing peers of the
+// rule at once. It returns nil if fewer than two stores are available to add.
+func (c *RuleChecker) addRulePeersInParallel(region *core.RegionInfo, rf *placement.RuleFit, ruleStores []*core.StoreInfo, missing int) *operator.Operator {
+	stores := c.strategy(region, rf.Rule).SelectStoresToAdd(ruleStores, missing)
+	if len(stores) < 2 {
+		return nil
+	}
+	peers := make([]*metapb.Peer, 0, len(stores))
+	for _, store := range stores {
+		peers = append(peers, &metapb.Peer{StoreId: store, Role: rf.Rule.Role.MetaPeerRole()})
+	}
+	op, err := operator.CreateAddPeersOperator("add-rule-peers", c.cluster, region, peers, operator.OpReplica)
+	if err != nil {
+		log.Debug("create add-rule-peers operator fail", errs.ZapError(err))
+		return nil
+	}
+	checkerCounter.WithLabelValues("rule_checker", "parallel-add-rule-peer").Inc()
+	op.SetPriorityLevel(core.HighPriority)
+	return op
+}
+
 // The peer's store may in Offline or Down, need to be replace.
 func (c *RuleChecker) replaceUnexpectRulePeer(region *core.RegionInfo, rf *placement.RuleFit, fit *placement.RegionFit, peer *metapb.Peer, status string) (*operator.Operator, error) {
 	ruleStores := c.getRuleFitStores(rf)
@@ -276,11 +304,32 @@ func (c *RuleChecker) fixOrphanPeers(region *core.RegionInfo, fit *placement.Reg
 			return nil, nil
 		}
 	}
+	if !c.orphanLimiter.allow(region.GetID(), c.cluster.GetOpts().GetOrphanPeerRemovalRate()) {
+		checkerCounter.WithLabelValues("rule_checker", "remove-orphan-peer-throttled").Inc()
+		return nil, nil
+	}
 	checkerCounter.WithLabelValues("rule_checker", "remove-orphan-peer").Inc()
 	peer := fit.OrphanPeers[0]
 	return operator.CreateRemovePeerOperator("remove-orphan-peer", c.cluster, 0, region, peer.StoreId)
 }
 
+// GetOrphanWaitingRegions returns the regions waiting to remove the orphan
+// peers because of the cap of the rate.
+func (c *RuleChecker) GetOrphanWaitingRegions() []*cache.Item {
+	return c.orphanLimiter.waitingList.Elems()
+}
+
+// RemoveOrphanWaitingRegion removes the region from the orphan waiting list.
+func (c *RuleChecker) RemoveOrphanWaitingRegion(id uint64) {
+	c.orphanLimiter.waitingList.Remove(id)
+}
+
+// IsOrphanRemovalThrottled returns whether the removal of the orphan peers is
+// throttled by the cap for now.
+func (c *RuleChecker) IsOrphanRemovalThrottled() bool {
+	return c.orphanLimiter.isThrottled(c.cluster.GetOpts().GetOrphanPeerRemovalRate())
+}
+
 func (c *RuleChecker) isDownPeer(region *core.RegionInfo, peer *metapb.Peer) bool {
 	for _, stats := range region.GetDownPeers() {
 		if stats.GetPeer().GetId() != peer.GetId() {
@@ -298,11 +347,29 @@ func (c *RuleChecker) isDownPeer(region *core.RegionInfo, peer *metapb.Peer) boo
 		if stats.GetDownSeconds() < uint64(c.cluster.GetOpts().GetMaxStoreDownTime().Seconds()) {
 			continue
 		}
+		if store.IsSuspect() && !isQuorumAtRisk(region, peer) {
+			checkerCounter.WithLabelValues("rule_checker", "skip-suspect-down-peer").Inc()
+			continue
+		}
 		return true
 	}
 	return false
 }
 
+// isQuorumAtRisk returns true if the region can't tolerate one more failure
+// even if the down peer comes back, which means the other down voters are too
+// many to wait for the peer.
+func isQuorumAtRisk(region *core.RegionInfo, peer *metapb.Peer) bool {
+	voters := region.GetVoters()
+	healthy := 0
+	for _, v := range voters {
+		if v.GetId() == peer.GetId() || region.GetDownPeer(v.GetId()) == nil {
+			healthy++
+		}
+	}
+	return healthy <= len(voters)/2+1
+}
+
 func (c *RuleChecker) isOfflinePeer(peer *metapb.Peer) bool {
 	store := c.cluster.GetStore(peer.GetStoreId())
 	if store == nil {
@@ -16,6 +16,8 @@ package checker
 import (
 	"context"
 	"encoding/hex"
+	"fmt"
+	"time"
 
 	. "github.com/pingcap/check"
 	"github.com/pingcap/kvproto/pkg/metapb"
@@ -181,6 +183,33 @@ func (s *testRuleCheckerSuite) TestFixOrphanPeers(c *C) {
 	c.Assert(op.Step(0).(operator.RemovePeer).FromStore, Equals, uint64(4))
 }
 
+func (s *testRuleCheckerSuite) TestOrphanPeerRemovalRate(c *C) {
+	cfg := s.cluster.GetScheduleConfig().Clone()
+	cfg.OrphanPeerRemovalRate = 60
+	s.cluster.SetScheduleConfig(cfg)
+	for i := uint64(1); i <= 4; i++ {
+		s.cluster.AddLeaderStore(i, 1)
+	}
+	s.cluster.AddLeaderRegionWithRange(1, "", "a", 1, 2, 3, 4)
+	s.cluster.AddLeaderRegionWithRange(2, "a", "", 1, 2, 3, 4)
+
+	// Only a region is allowed in a second.
+	op := s.rc.Check(s.cluster.GetRegion(1))
+	c.Assert(op, NotNil)
+	c.Assert(op.Desc(), Equals, "remove-orphan-peer")
+	c.Assert(s.rc.IsOrphanRemovalThrottled(), IsTrue)
+	c.Assert(s.rc.Check(s.cluster.GetRegion(2)), IsNil)
+	items := s.rc.GetOrphanWaitingRegions()
+	c.Assert(items, HasLen, 1)
+	c.Assert(items[0].Key, Equals, uint64(2))
+
+	// The cap can be disabled.
+	cfg.OrphanPeerRemovalRate = 0
+	s.cluster.SetScheduleConfig(cfg)
+	c.Assert(s.rc.IsOrphanRemovalThrottled(), IsFalse)
+	c.Assert(s.rc.Check(s.cluster.GetRegion(2)), NotNil)
+}
+
 func (s *testRuleCheckerSuite) TestFixOrphanPeers2(c *C) {
 	// check orphan peers can only be handled when all rules are satisfied.
 	s.cluster.AddLabelsStore(1, 1, map[string]string{"foo": "bar"})
@@ -558,6 +587,10 @@ func (s *testRuleCheckerSuite) TestFixDownPeer(c *C) {
 	region = region.Clone(core.WithDownPeers([]*pdpb.PeerStats{
 		{Peer: region.GetStorePeer(4), DownSeconds: 6000},
 	}))
+	// The down peer on a restarting store is not replaced.
+	s.cluster.PutStore(s.cluster.GetStore(4).Clone(core.SetRestartDeadline(time.Now().Add(time.Minute))))
+	c.Assert(s.rc.Check(region), IsNil)
+	s.cluster.PutStore(s.cluster.GetStore(4).Clone(core.SetRestartDeadline(time.Time{})))
 	testutil.CheckTransferPeer(c, s.rc.Check(region), operator.OpRegion, 4, 5)
 
 	s.cluster.SetStoreDown(5)
@@ -568,6 +601,70 @@ func (s *testRuleCheckerSuite) TestFixDownPeer(c *C) {
 	c.Assert(s.rc.Check(region), IsNil)
 }
 
+func (s *testRuleCheckerSuite) TestFixSuspectDownPeer(c *C) {
+	for id := uint64(1); id <= 6; id++ {
+		s.cluster.AddLabelsStore(id, 1, map[string]string{"host": fmt.Sprintf("h%d", id)})
+	}
+	s.cluster.AddLeaderRegion(1, 1, 2, 3, 4, 5)
+	s.ruleManager.SetRule(&placement.Rule{
+		GroupID:        "pd",
+		ID:             "default",
+		Role:           placement.Voter,
+		Count:          5,
+		LocationLabels: []string{"host"},
+	})
+
+	s.cluster.SetStoreDown(5)
+	s.cluster.PutStore(s.cluster.GetStore(5).Clone(core.SetSuspectUntil(time.Now().Add(time.Minute))))
+	region := s.cluster.GetRegion(1)
+	down5 := &pdpb.PeerStats{Peer: region.GetStorePeer(5), DownSeconds: 6000}
+	// The quorum is safe if the suspect store comes back.
+	c.Assert(s.rc.Check(region.Clone(core.WithDownPeers([]*pdpb.PeerStats{down5}))), IsNil)
+	s.cluster.SetStoreDown(4)
+	down4 := &pdpb.PeerStats{Peer: region.GetStorePeer(4), DownSeconds: 6000}
+	c.Assert(s.rc.Check(region.Clone(core.WithDownPeers([]*pdpb.PeerStats{down4, down5}))), NotNil)
+	s.cluster.PutStore(s.cluster.GetStore(4).Clone(core.SetSuspectUntil(time.Now().Add(time.Minute))))
+	// The quorum is at risk with two down voters even if one of them comes back.
+	s.cluster.SetStoreDown(3)
+	down3 := &pdpb.PeerStats{Peer: region.GetStorePeer(3), DownSeconds: 6000}
+	s.cluster.PutStore(s.cluster.GetStore(3).Clone(core.SetSuspectUntil(time.Now().Add(time.Minute))))
+	op := s.rc.Check(region.Clone(core.WithDownPeers([]*pdpb.PeerStats{down3, down4, down5})))
+	c.Assert(op, NotNil)
+	c.Assert(op.Desc(), Equals, "replace-rule-down-peer")
+
+	// The suspect time is expired.
+	s.cluster.PutStore(s.cluster.GetStore(5).Clone(core.SetSuspectUntil(time.Now().Add(-time.Minute))))
+	testutil.CheckTransferPeer(c, s.rc.Check(region.Clone(core.WithDownPeers([]*pdpb.PeerStats{down5}))), operator.OpRegion, 5, 6)
+}
+
+func (s *testRuleCheckerSuite) TestSkipReadOnlyStore(c *C) {
+	s.cluster.AddLabelsStore(1, 1, map[string]string{"zone": "z1"})
+	s.cluster.AddLabelsStore(2, 1, map[string]string{"zone": "z1"})
+	s.cluster.AddLabelsStore(3, 1, map[string]string{"zone": "z2"})
+	s.cluster.AddLabelsStore(4, 1, map[string]string{"zone": "z3"})
+	s.cluster.AddLabelsStore(5, 1, map[string]string{"zone": "z3"})
+	s.cluster.AddLeaderRegion(1, 1, 3, 4)
+	s.ruleManager.SetRule(&placement.Rule{
+		GroupID:        "pd",
+		ID:             "test",
+		Index:          100,
+		Override:       true,
+		Role:           placement.Voter,
+		Count:          3,
+		LocationLabels: []string{"zone"},
+	})
+
+	s.cluster.SetStoreDown(4)
+	region := s.cluster.GetRegion(1).Clone(core.WithDownPeers([]*pdpb.PeerStats{
+		{Peer: s.cluster.GetRegion(1).GetStorePeer(4), DownSeconds: 6000},
+	}))
+	testutil.CheckTransferPeer(c, s.rc.Check(region), operator.OpRegion, 4, 5)
+
+	// The read-only store is not chosen as the target.
+	s.cluster.PutStore(s.cluster.GetStore(5).Clone(core.SetStoreReadOnly(true)))
+	testutil.CheckTransferPeer(c, s.rc.Check(region), operator.OpRegion, 4, 2)
+}
+
 // See issue: https://github.com/tikv/pd/issues/3705
 func (s *testRuleCheckerSuite) TestFixOfflinePeer(c *C) {
 	s.cluster.AddLabelsStore(1, 1, map[string]string{"zone": "z1"})
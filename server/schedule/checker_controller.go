@@ -20,6 +20,7 @@ import (
 	"github.com/tikv/pd/server/config"
 	"github.com/tikv/pd/server/core"
 	"github.com/tikv/pd/server/schedule/checker"
+	"github.com/tikv/pd/server/schedule/labeler"
 	"github.com/tikv/pd/server/schedule/operator"
 	"github.com/tikv/pd/server/schedule/opt"
 	"github.com/tikv/pd/server/schedule/placement"
@@ -30,15 +31,18 @@ const DefaultCacheSize = 1000
 
 // CheckerController is used to manage all checkers.
 type CheckerController struct {
-	cluster           opt.Cluster
-	opts              *config.PersistOptions
-	opController      *OperatorController
-	learnerChecker    *checker.LearnerChecker
-	replicaChecker    *checker.ReplicaChecker
-	ruleChecker       *checker.RuleChecker
-	mergeChecker      *checker.MergeChecker
-	jointStateChecker *checker.JointStateChecker
-	regionWaitingList cache.Cache
+	cluster                 opt.Cluster
+	opts                    *config.PersistOptions
+	opController            *OperatorController
+	learnerChecker          *checker.LearnerChecker
+	replicaChecker          *checker.ReplicaChecker
+	ruleChecker             *checker.RuleChecker
+	mergeChecker            *checker.MergeChecker
+	jointStateChecker       *checker.JointStateChecker
+	affinityChecker         *checker.AffinityChecker
+	leaderConstraintChecker *checker.LeaderConstraintChecker
+	regionWaitingList       cache.Cache
+	patrolBudget            *PatrolBudget
 }
 
 // NewCheckerController create a new CheckerController.
@@ -46,49 +50,124 @@ type CheckerController struct {
 func NewCheckerController(ctx context.Context, cluster opt.Cluster, ruleManager *placement.RuleManager, opController *OperatorController) *CheckerController {
 	regionWaitingList := cache.NewDefaultCache(DefaultCacheSize)
 	return &CheckerController{
-		cluster:           cluster,
-		opts:              cluster.GetOpts(),
-		opController:      opController,
-		learnerChecker:    checker.NewLearnerChecker(cluster),
-		replicaChecker:    checker.NewReplicaChecker(cluster, regionWaitingList),
-		ruleChecker:       checker.NewRuleChecker(cluster, ruleManager, regionWaitingList),
-		mergeChecker:      checker.NewMergeChecker(ctx, cluster),
-		jointStateChecker: checker.NewJointStateChecker(cluster),
-		regionWaitingList: regionWaitingList,
+		cluster:                 cluster,
+		opts:                    cluster.GetOpts(),
+		opController:            opController,
+		learnerChecker:          checker.NewLearnerChecker(cluster),
+		replicaChecker:          checker.NewReplicaChecker(cluster, regionWaitingList),
+		ruleChecker:             checker.NewRuleChecker(cluster, ruleManager, regionWaitingList),
+		mergeChecker:            checker.NewMergeChecker(ctx, cluster),
+		jointStateChecker:       checker.NewJointStateChecker(cluster),
+		affinityChecker:         checker.NewAffinityChecker(cluster),
+		leaderConstraintChecker: checker.NewLeaderConstraintChecker(cluster),
+		regionWaitingList:       regionWaitingList,
+		patrolBudget:            NewPatrolBudget((*config.PersistOptions).GetPatrolCheckerOperatorLimit),
 	}
 }
 
-// CheckRegion will check the region and add a new operator if needed.
+// CheckRegion will check the region and add a new operator if needed. The
+// operators are owned by the CheckerOwner of the checker creating them, so
+// they are not taken for the ones of a scheduler. They are not created once
+// the checker exhausts the budget of the patrol round, and the region is put
+// into the waiting list. The operators leaving the joint state and the urgent
+// ones are not limited.
 func (c *CheckerController) CheckRegion(region *core.RegionInfo) []*operator.Operator {
+	ops, checkerType := c.checkRegion(region)
+	if len(ops) == 0 {
+		return nil
+	}
+	owner := operator.CheckerOwner(checkerType)
+	for _, op := range ops {
+		op.SetOwner(owner)
+	}
+	if c.isPatrolBudgetExempt(ops) {
+		return ops
+	}
+	if !c.patrolBudget.Allow(c.opts, owner) {
+		c.regionWaitingList.Put(region.GetID(), nil)
+		return nil
+	}
+	return ops
+}
+
+// ChargePatrolBudget charges the operators returned by CheckRegion to the
+// budget of the patrol round after they are added. Only the added ones should
+// be passed.
+func (c *CheckerController) ChargePatrolBudget(ops []*operator.Operator) {
+	if len(ops) > 0 && !c.isPatrolBudgetExempt(ops) {
+		c.patrolBudget.Charge(c.cluster, ops)
+	}
+}
+
+func (c *CheckerController) isPatrolBudgetExempt(ops []*operator.Operator) bool {
+	if ops[0].Owner() == operator.CheckerOwner(c.jointStateChecker.GetType()) {
+		return true
+	}
+	for _, op := range ops {
+		if op.GetPriorityLevel() >= core.UrgentPriority {
+			return true
+		}
+	}
+	return false
+}
+
+// checkRegion returns the operators and the type of the checker creating
+// them.
+func (c *CheckerController) checkRegion(region *core.RegionInfo) ([]*operator.Operator, string) {
 	// If PD has restarted, it need to check learners added before and promote them.
 	// Don't check isRaftLearnerEnabled cause it maybe disable learner feature but there are still some learners to promote.
 	opController := c.opController
 
 	if op := c.jointStateChecker.Check(region); op != nil {
-		return []*operator.Operator{op}
+		return []*operator.Operator{op}, c.jointStateChecker.GetType()
 	}
 
 	if c.opts.IsPlacementRulesEnabled() {
 		if op := c.ruleChecker.Check(region); op != nil {
-			if opController.OperatorCount(operator.OpReplica) < c.opts.GetReplicaScheduleLimit() {
-				return []*operator.Operator{op}
+			bypassLimit := raiseRepairPriority(c.cluster, region, op)
+			// The system ranges are repaired first, regardless of the limit.
+			if !bypassLimit && isSystemRangeRegion(c.cluster, region) {
+				op.SetPriorityLevel(core.HighPriority)
+				bypassLimit = true
+			}
+			if bypassLimit || opController.OperatorCount(operator.OpReplica) < c.opts.GetReplicaScheduleLimit() {
+				return []*operator.Operator{op}, c.ruleChecker.GetType()
 			}
 			operator.OperatorLimitCounter.WithLabelValues(c.ruleChecker.GetType(), operator.OpReplica.String()).Inc()
 			c.regionWaitingList.Put(region.GetID(), nil)
 		}
 	} else {
 		if op := c.learnerChecker.Check(region); op != nil {
-			return []*operator.Operator{op}
+			return []*operator.Operator{op}, c.learnerChecker.GetType()
 		}
 		if op := c.replicaChecker.Check(region); op != nil {
-			if opController.OperatorCount(operator.OpReplica) < c.opts.GetReplicaScheduleLimit() {
-				return []*operator.Operator{op}
+			bypassLimit := raiseRepairPriority(c.cluster, region, op)
+			if bypassLimit || opController.OperatorCount(operator.OpReplica) < c.opts.GetReplicaScheduleLimit() {
+				return []*operator.Operator{op}, c.replicaChecker.GetType()
 			}
 			operator.OperatorLimitCounter.WithLabelValues(c.replicaChecker.GetType(), operator.OpReplica.String()).Inc()
 			c.regionWaitingList.Put(region.GetID(), nil)
 		}
 	}
 
+	if op := c.affinityChecker.Check(region); op != nil {
+		kind, limit := operator.OpRegion, c.opts.GetRegionScheduleLimit()
+		if op.Kind()&operator.OpRegion == 0 {
+			kind, limit = operator.OpLeader, c.opts.GetLeaderScheduleLimit()
+		}
+		if opController.OperatorCount(kind) < limit {
+			return []*operator.Operator{op}, c.affinityChecker.GetType()
+		}
+		operator.OperatorLimitCounter.WithLabelValues(c.affinityChecker.GetType(), kind.String()).Inc()
+	}
+
+	if op := c.leaderConstraintChecker.Check(region); op != nil {
+		if opController.OperatorCount(operator.OpLeader) < c.opts.GetLeaderScheduleLimit() {
+			return []*operator.Operator{op}, c.leaderConstraintChecker.GetType()
+		}
+		operator.OperatorLimitCounter.WithLabelValues(c.leaderConstraintChecker.GetType(), operator.OpLeader.String()).Inc()
+	}
+
 	if c.mergeChecker != nil {
 		allowed := opController.OperatorCount(operator.OpMerge) < c.opts.GetMergeScheduleLimit()
 		if !allowed {
@@ -96,11 +175,34 @@ func (c *CheckerController) CheckRegion(region *core.RegionInfo) []*operator.Ope
 		} else {
 			if ops := c.mergeChecker.Check(region); ops != nil {
 				// It makes sure that two operators can be added successfully altogether.
-				return ops
+				return ops, c.mergeChecker.GetType()
 			}
 		}
 	}
-	return nil
+	return nil, ""
+}
+
+// raiseRepairPriority raises the priority of the repair operator of the
+// region labeled with a checker priority class, and returns whether the
+// operator bypasses the replica schedule limit.
+func raiseRepairPriority(cluster opt.Cluster, region *core.RegionInfo, op *operator.Operator) bool {
+	l := cluster.GetRegionLabeler()
+	if l == nil {
+		return false
+	}
+	switch l.GetRegionLabel(region, labeler.CheckerPriorityLabel) {
+	case labeler.CheckerPriorityCritical:
+		op.SetPriorityLevel(core.UrgentPriority)
+		return true
+	case labeler.CheckerPriorityHigh:
+		op.SetPriorityLevel(core.UrgentPriority)
+	}
+	return false
+}
+
+// ResetPatrolBudget resets the budget when a patrol round finishes.
+func (c *CheckerController) ResetPatrolBudget() {
+	c.patrolBudget.Reset()
 }
 
 // GetMergeChecker returns the merge checker.
@@ -108,6 +210,23 @@ func (c *CheckerController) GetMergeChecker() *checker.MergeChecker {
 	return c.mergeChecker
 }
 
+// GetOrphanWaitingRegions returns the regions waiting to remove the orphan
+// peers.
+func (c *CheckerController) GetOrphanWaitingRegions() []*cache.Item {
+	return c.ruleChecker.GetOrphanWaitingRegions()
+}
+
+// RemoveOrphanWaitingRegion removes the region from the orphan waiting list.
+func (c *CheckerController) RemoveOrphanWaitingRegion(id uint64) {
+	c.ruleChecker.RemoveOrphanWaitingRegion(id)
+}
+
+// IsOrphanRemovalThrottled returns whether the removal of the orphan peers is
+// throttled for now.
+func (c *CheckerController) IsOrphanRemovalThrottled() bool {
+	return c.ruleChecker.IsOrphanRemovalThrottled()
+}
+
 // GetWaitingRegions returns the regions in the waiting list.
 func (c *CheckerController) GetWaitingRegions() []*cache.Item {
 	return c.regionWaitingList.Elems()
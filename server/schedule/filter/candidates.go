@@ -16,6 +16,7 @@ package filter
 import (
 	"math/rand"
 	"sort"
+	"strconv"
 
 	"github.com/tikv/pd/server/config"
 	"github.com/tikv/pd/server/core"
@@ -44,6 +45,41 @@ func (c *StoreCandidates) FilterTarget(opt *config.PersistOptions, filters ...Fi
 	return c
 }
 
+// PreferTarget keeps stores that can pass all target filters if there are any,
+// otherwise it keeps all stores, so that the filtered stores are the last
+// resort rather than excluded. The stores which are not kept are counted as
+// deprioritized in the filter metrics.
+func (c *StoreCandidates) PreferTarget(opt *config.PersistOptions, filters ...Filter) *StoreCandidates {
+	var preferred []*core.StoreInfo
+	deprioritized := make(map[*core.StoreInfo]Filter)
+	for _, store := range c.Stores {
+		if f := firstFailedTarget(opt, store, filters); f != nil {
+			deprioritized[store] = f
+			continue
+		}
+		preferred = append(preferred, store)
+	}
+	if len(preferred) == 0 {
+		return c
+	}
+	for store, f := range deprioritized {
+		targetID := strconv.FormatUint(store.GetID(), 10)
+		filterCounter.WithLabelValues("deprioritize-target", store.GetAddress(),
+			targetID, f.Scope(), f.Type(), "", targetID).Inc()
+	}
+	c.Stores = preferred
+	return c
+}
+
+func firstFailedTarget(opt *config.PersistOptions, store *core.StoreInfo, filters []Filter) Filter {
+	for _, f := range filters {
+		if !f.Target(opt, store) {
+			return f
+		}
+	}
+	return nil
+}
+
 // Sort sorts store list by given comparer in ascending order.
 func (c *StoreCandidates) Sort(less StoreComparer) *StoreCandidates {
 	sort.Slice(c.Stores, func(i, j int) bool { return less(c.Stores[i], c.Stores[j]) < 0 })
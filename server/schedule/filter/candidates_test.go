@@ -92,6 +92,34 @@ func (s *testCandidatesSuite) TestCandidates(c *C) {
 	s.check(c, cs, 33, 32, 31)
 }
 
+func (s *testCandidatesSuite) TestPreferTarget(c *C) {
+	cs := s.newCandidates(1, 2, 3, 4)
+	cs.PreferTarget(nil, idFilter(func(id uint64) bool { return id%2 == 0 }))
+	s.check(c, cs, 2, 4)
+	// All stores are kept if none of them passes.
+	cs.PreferTarget(nil, idFilter(func(id uint64) bool { return id > 100 }))
+	s.check(c, cs, 2, 4)
+
+	opt := config.NewTestOptions()
+	cfg := opt.GetScheduleConfig().Clone()
+	cfg.CompactionPressureThreshold = 100
+	opt.SetScheduleConfig(cfg)
+	stores := []*core.StoreInfo{
+		core.NewStoreInfo(&metapb.Store{Id: 1}, core.SetPendingCompactionBytes(100)),
+		core.NewStoreInfo(&metapb.Store{Id: 2}, core.SetPendingCompactionBytes(99)),
+	}
+	f := NewCompactionPressureFilter("test")
+	c.Assert(f.Target(opt, stores[0]), IsFalse)
+	c.Assert(f.Target(opt, stores[1]), IsTrue)
+	c.Assert(f.Source(opt, stores[0]), IsTrue)
+	cs = NewCandidates(stores).PreferTarget(opt, f)
+	s.check(c, cs, 2)
+	// The pressure is not checked if the threshold is 0.
+	cfg.CompactionPressureThreshold = 0
+	opt.SetScheduleConfig(cfg)
+	c.Assert(f.Target(opt, stores[0]), IsTrue)
+}
+
 func (s *testCandidatesSuite) newCandidates(ids ...uint64) *StoreCandidates {
 	stores := make([]*core.StoreInfo, 0, len(ids))
 	for _, id := range ids {
@@ -39,6 +39,24 @@ func RegionScoreComparer(opt *config.PersistOptions) StoreComparer {
 	}
 }
 
+// LeaderScoreComparer creates a StoreComparer to sort store by leader
+// score.
+func LeaderScoreComparer(opt *config.PersistOptions) StoreComparer {
+	return func(a, b *core.StoreInfo) int {
+		policy := opt.GetLeaderSchedulePolicy()
+		sa := a.LeaderScore(policy, 0)
+		sb := b.LeaderScore(policy, 0)
+		switch {
+		case sa > sb:
+			return 1
+		case sa < sb:
+			return -1
+		default:
+			return 0
+		}
+	}
+}
+
 // IsolationComparer creates a StoreComparer to sort store by isolation score.
 func IsolationComparer(locationLabels []string, regionStores []*core.StoreInfo) StoreComparer {
 	return func(a, b *core.StoreInfo) int {
@@ -23,6 +23,7 @@ import (
 	"github.com/tikv/pd/server/config"
 	"github.com/tikv/pd/server/core"
 	"github.com/tikv/pd/server/core/storelimit"
+	"github.com/tikv/pd/server/schedule/leaderconstraint"
 	"github.com/tikv/pd/server/schedule/opt"
 	"github.com/tikv/pd/server/schedule/placement"
 	"go.uber.org/zap"
@@ -187,6 +188,87 @@ func (f *storageThresholdFilter) Target(opt *config.PersistOptions, store *core.
 	return !store.IsLowSpace(opt.GetLowSpaceRatio())
 }
 
+type compactionPressureFilter struct{ scope string }
+
+// NewCompactionPressureFilter creates a Filter that filters all stores whose
+// pending compaction bytes reach the compaction pressure threshold.
+func NewCompactionPressureFilter(scope string) Filter {
+	return &compactionPressureFilter{scope: scope}
+}
+
+func (f *compactionPressureFilter) Scope() string {
+	return f.scope
+}
+
+func (f *compactionPressureFilter) Type() string {
+	return "compaction-pressure-filter"
+}
+
+func (f *compactionPressureFilter) Source(opt *config.PersistOptions, store *core.StoreInfo) bool {
+	return true
+}
+
+func (f *compactionPressureFilter) Target(opt *config.PersistOptions, store *core.StoreInfo) bool {
+	return !store.IsCompactionPressured(opt.GetCompactionPressureThreshold())
+}
+
+type capacityForecastFilter struct{ scope string }
+
+// NewCapacityForecastFilter creates a Filter that filters all stores predicted
+// to be full within the critical days of the capacity forecast.
+func NewCapacityForecastFilter(scope string) Filter {
+	return &capacityForecastFilter{scope: scope}
+}
+
+func (f *capacityForecastFilter) Scope() string {
+	return f.scope
+}
+
+func (f *capacityForecastFilter) Type() string {
+	return "capacity-forecast-filter"
+}
+
+func (f *capacityForecastFilter) Source(opt *config.PersistOptions, store *core.StoreInfo) bool {
+	return true
+}
+
+func (f *capacityForecastFilter) Target(opt *config.PersistOptions, store *core.StoreInfo) bool {
+	return !store.IsPredictedFull(opt.GetCapacityForecastCriticalDays())
+}
+
+type leaderConstraintFilter struct {
+	scope  string
+	state  *leaderconstraint.State
+	source *core.StoreInfo
+}
+
+// NewLeaderConstraintFilter creates a Filter that filters all stores which
+// would exceed the max ratio of a leader constraint if the leader is
+// transferred from the source store to them. It returns nil if there is no
+// leader constraint.
+func NewLeaderConstraintFilter(scope string, state *leaderconstraint.State, source *core.StoreInfo) Filter {
+	if state == nil {
+		return nil
+	}
+	return &leaderConstraintFilter{scope: scope, state: state, source: source}
+}
+
+func (f *leaderConstraintFilter) Scope() string {
+	return f.scope
+}
+
+func (f *leaderConstraintFilter) Type() string {
+	return "leader-constraint-filter"
+}
+
+func (f *leaderConstraintFilter) Source(opt *config.PersistOptions, store *core.StoreInfo) bool {
+	return true
+}
+
+func (f *leaderConstraintFilter) Target(opt *config.PersistOptions, store *core.StoreInfo) bool {
+	return f.state.AllowTransfer(f.source, store)
+}
+
 // distinctScoreFilter ensures that distinct score will not decrease.
 type distinctScoreFilter struct {
 	scope     string
@@ -348,6 +430,11 @@ func (f *StoreStateFilter) tooManyPendingPeers(opt *config.PersistOptions, store
 		store.GetPendingPeerCount() > int(opt.GetMaxPendingPeerCount())
 }
 
+func (f *StoreStateFilter) isReadOnly(opt *config.PersistOptions, store *core.StoreInfo) bool {
+	f.Reason = "read-only"
+	return store.IsReadOnly()
+}
+
 func (f *StoreStateFilter) hasRejectLeaderProperty(opts *config.PersistOptions, store *core.StoreInfo) bool {
 	f.Reason = "reject-leader"
 	return opts.CheckLabelProperty(opt.RejectLeader, store.GetLabels())
@@ -358,13 +445,13 @@ func (f *StoreStateFilter) hasRejectLeaderProperty(opts *config.PersistOptions,
 // N: the condition is expected to be true for a long time.
 // X means when the condition is true, the store CANNOT be selected.
 //
-// Condition    Down Offline Tomb Pause Disconn Busy RmLimit AddLimit Snap Pending Reject
-// IsTemporary  N    N       N    N     Y       Y    Y       Y        Y    Y       N
+// Condition    Down Offline Tomb Pause Disconn Busy RmLimit AddLimit Snap Pending Reject ReadOnly
+// IsTemporary  N    N       N    N     Y       Y    Y       Y        Y    Y       N      N
 //
 // LeaderSource X            X    X     X
 // RegionSource                                 X    X                X
-// LeaderTarget X    X       X    X     X       X                                  X
-// RegionTarget X    X       X          X       X            X        X    X
+// LeaderTarget X    X       X    X     X       X                                  X      X
+// RegionTarget X    X       X          X       X            X        X    X              X
 
 const (
 	leaderSource = iota
@@ -383,12 +470,12 @@ func (f *StoreStateFilter) anyConditionMatch(typ int, opt *config.PersistOptions
 		funcs = []conditionFunc{f.isBusy, f.exceedRemoveLimit, f.tooManySnapshots}
 	case leaderTarget:
 		funcs = []conditionFunc{f.isTombstone, f.isOffline, f.isDown, f.pauseLeaderTransfer,
-			f.isDisconnected, f.isBusy, f.hasRejectLeaderProperty}
+			f.isDisconnected, f.isBusy, f.hasRejectLeaderProperty, f.isReadOnly}
 	case regionTarget:
 		funcs = []conditionFunc{f.isTombstone, f.isOffline, f.isDown, f.isDisconnected, f.isBusy,
-			f.exceedAddLimit, f.tooManySnapshots, f.tooManyPendingPeers}
+			f.exceedAddLimit, f.tooManySnapshots, f.tooManyPendingPeers, f.isReadOnly}
 	case scatterRegionTarget:
-		funcs = []conditionFunc{f.isTombstone, f.isOffline, f.isDown, f.isDisconnected, f.isBusy}
+		funcs = []conditionFunc{f.isTombstone, f.isOffline, f.isDown, f.isDisconnected, f.isBusy, f.isReadOnly}
 	}
 	for _, cf := range funcs {
 		if cf(opt, store) {
@@ -192,6 +192,16 @@ func (s *testFiltersSuite) TestStoreStateFilter(c *C) {
 		{3, true, true},
 	}
 	check(store, testCases)
+
+	// ReadOnly
+	store = store.Clone(core.SetStoreStats(&pdpb.StoreStats{}), core.SetStoreReadOnly(true))
+	testCases = []testCase{
+		{0, true, false},
+		{1, true, false},
+		{2, true, false},
+		{3, true, false},
+	}
+	check(store, testCases)
 }
 
 func (s *testFiltersSuite) TestIsolationFilter(c *C) {
@@ -0,0 +1,308 @@
+// Copyright 2021 TiKV Project Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package hbstream
+
+import (
+	"encoding/json"
+	"fmt"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gogo/protobuf/proto"
+	"github.com/pingcap/kvproto/pkg/pdpb"
+	"github.com/pingcap/log"
+	"github.com/tikv/pd/pkg/errs"
+	"github.com/tikv/pd/server/core"
+	"go.uber.org/zap"
+)
+
+const (
+	// commandsPath is where the commands are persisted, one key per command
+	// under the store ID and the sequence number.
+	commandsPath = "hbstream_commands"
+	// commandSeqsPath is where the last sequence number of each store is
+	// persisted, so the sequence numbers keep increasing after the leader
+	// changes.
+	commandSeqsPath = "hbstream_command_seqs"
+)
+
+// persistedCommand is a persisted command. The message is kept in the
+// protobuf format.
+type persistedCommand struct {
+	Msg         []byte    `json:"msg"`
+	EnqueueTime time.Time `json:"enqueue_time"`
+}
+
+func commandPath(storeID, seq uint64) string {
+	return path.Join(commandsPath, fmt.Sprintf("%020d", storeID), fmt.Sprintf("%020d", seq))
+}
+
+func commandSeqPath(storeID uint64) string {
+	return path.Join(commandSeqsPath, fmt.Sprintf("%020d", storeID))
+}
+
+// CommandValidator checks whether a command recovered from the previous
+// leader is still the current step of a running operator of the region, so
+// that it can be sent.
+type CommandValidator func(msg *pdpb.RegionHeartbeatResponse) bool
+
+// SetCommandValidator sets the validator of the recovered commands. The
+// recovered commands are never sent without a validator.
+func (s *HeartbeatStreams) SetCommandValidator(validator CommandValidator) {
+	s.queueMu.Lock()
+	defer s.queueMu.Unlock()
+	s.validator = validator
+}
+
+// validateRecovered checks the recovered command before sending it, and drops
+// it if it is not the step of a running operator of the new leader.
+func (s *HeartbeatStreams) validateRecovered(cmd *command) bool {
+	s.queueMu.Lock()
+	validator := s.validator
+	s.queueMu.Unlock()
+	if validator != nil && validator(cmd.msg) {
+		return true
+	}
+	s.queueMu.Lock()
+	defer s.queueMu.Unlock()
+	regionID := cmd.msg.GetRegionId()
+	if q, ok := s.queues[cmd.storeID]; ok && q.commands[regionID] == cmd {
+		s.removeCommandLocked(regionID)
+		commandDroppedCounter.WithLabelValues(strconv.FormatUint(cmd.storeID, 10)).Inc()
+		log.Info("drop the recovered command without its operator",
+			zap.Uint64("region-id", regionID), zap.Uint64("store-id", cmd.storeID), zap.Uint64("seq", cmd.seq))
+	}
+	return false
+}
+
+// FlushCommands persists the changes of the command queues. Only the commands
+// added or removed since the last flush are written. It is called by the
+// leader periodically.
+func (s *HeartbeatStreams) FlushCommands(storage *core.Storage) {
+	s.queueMu.Lock()
+	if len(s.dirtyStores) == 0 {
+		s.queueMu.Unlock()
+		return
+	}
+	nextSeqs := make(map[uint64]uint64, len(s.dirtyStores))
+	values := make(map[uint64]map[uint64]string, len(s.dirtyStores))
+	persisted := make(map[uint64]map[uint64]struct{}, len(s.dirtyStores))
+	for storeID := range s.dirtyStores {
+		values[storeID] = make(map[uint64]string)
+		persisted[storeID] = make(map[uint64]struct{})
+		for seq := range s.persisted[storeID] {
+			persisted[storeID][seq] = struct{}{}
+		}
+		q, ok := s.queues[storeID]
+		if !ok {
+			continue
+		}
+		nextSeqs[storeID] = q.nextSeq
+		for _, cmd := range q.commands {
+			if _, ok := persisted[storeID][cmd.seq]; ok {
+				values[storeID][cmd.seq] = ""
+				continue
+			}
+			value, err := marshalCommand(cmd)
+			if err != nil {
+				log.Error("failed to marshal the command", zap.Uint64("region-id", cmd.msg.GetRegionId()), errs.ZapError(err))
+				continue
+			}
+			values[storeID][cmd.seq] = value
+		}
+	}
+	s.dirtyStores = make(map[uint64]struct{})
+	s.queueMu.Unlock()
+
+	for storeID, commands := range values {
+		saved := persisted[storeID]
+		err := flushStoreCommands(storage, storeID, nextSeqs[storeID], commands, saved)
+		s.queueMu.Lock()
+		s.persisted[storeID] = saved
+		if err != nil {
+			s.dirtyStores[storeID] = struct{}{}
+		}
+		s.queueMu.Unlock()
+		if err != nil {
+			log.Warn("failed to save the command queue", zap.Uint64("store-id", storeID), zap.Int("count", len(commands)), errs.ZapError(err))
+		}
+	}
+}
+
+func marshalCommand(cmd *command) (string, error) {
+	msg, err := proto.Marshal(cmd.msg)
+	if err != nil {
+		return "", errs.ErrProtoMarshal.Wrap(err).FastGenWithCause()
+	}
+	value, err := json.Marshal(&persistedCommand{Msg: msg, EnqueueTime: cmd.enqueueTime})
+	if err != nil {
+		return "", errs.ErrJSONMarshal.Wrap(err).FastGenWithCause()
+	}
+	return string(value), nil
+}
+
+// flushStoreCommands removes the persisted commands of the store which are
+// no longer in the queue, and saves the new ones. saved is updated with the
+// sequence numbers in the storage.
+func flushStoreCommands(storage *core.Storage, storeID, nextSeq uint64, commands map[uint64]string, saved map[uint64]struct{}) error {
+	for seq := range saved {
+		if _, ok := commands[seq]; ok {
+			continue
+		}
+		if err := storage.Remove(commandPath(storeID, seq)); err != nil {
+			return err
+		}
+		delete(saved, seq)
+	}
+	seqs := make([]uint64, 0, len(commands))
+	for seq := range commands {
+		seqs = append(seqs, seq)
+	}
+	sort.Slice(seqs, func(i, j int) bool { return seqs[i] < seqs[j] })
+	for _, seq := range seqs {
+		if _, ok := saved[seq]; ok || commands[seq] == "" {
+			continue
+		}
+		if err := storage.Save(commandPath(storeID, seq), commands[seq]); err != nil {
+			return err
+		}
+		saved[seq] = struct{}{}
+	}
+	if nextSeq == 0 {
+		return nil
+	}
+	return storage.Save(commandSeqPath(storeID), strconv.FormatUint(nextSeq, 10))
+}
+
+// LoadCommands replaces the command queues with the persisted ones when the
+// member becomes the leader, and returns the number of the loaded commands.
+// The commands enqueued before the TTL are dropped.
+//
+// The loaded commands are the steps of the operators of the previous leader,
+// which only live in its memory, so they are only sent if the validator
+// confirms that they are still the steps of the running operators of the new
+// leader, and dropped otherwise. They are also superseded by the commands of
+// the new operators of the regions.
+func (s *HeartbeatStreams) LoadCommands(storage *core.Storage, now time.Time) (int, error) {
+	nextSeqs := make(map[uint64]uint64)
+	var loadErr error
+	err := storage.LoadRangeByPrefix(commandSeqsPath+"/", func(k, v string) {
+		storeID, err := strconv.ParseUint(k, 10, 64)
+		if err != nil {
+			return
+		}
+		seq, err := strconv.ParseUint(v, 10, 64)
+		if err != nil {
+			loadErr = errs.ErrStrconvParseUint.Wrap(err).FastGenWithCause()
+			return
+		}
+		nextSeqs[storeID] = seq
+	})
+	if err == nil {
+		err = loadErr
+	}
+	if err != nil {
+		return 0, err
+	}
+
+	type loadedCommand struct {
+		storeID, seq uint64
+		value        string
+	}
+	var loaded []*loadedCommand
+	err = storage.LoadRangeByPrefix(commandsPath+"/", func(k, v string) {
+		parts := strings.Split(k, "/")
+		if len(parts) != 2 {
+			return
+		}
+		storeID, err1 := strconv.ParseUint(parts[0], 10, 64)
+		seq, err2 := strconv.ParseUint(parts[1], 10, 64)
+		if err1 != nil || err2 != nil {
+			return
+		}
+		loaded = append(loaded, &loadedCommand{storeID: storeID, seq: seq, value: v})
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	s.queueMu.Lock()
+	defer s.queueMu.Unlock()
+	for storeID, q := range s.queues {
+		if q.nextSeq > nextSeqs[storeID] {
+			nextSeqs[storeID] = q.nextSeq
+		}
+		commandQueueGauge.WithLabelValues(strconv.FormatUint(storeID, 10)).Set(0)
+	}
+	s.queues = make(map[uint64]*commandQueue)
+	s.regionStores = make(map[uint64]uint64)
+	s.persisted = make(map[uint64]map[uint64]struct{})
+	s.dirtyStores = make(map[uint64]struct{})
+	for storeID, seq := range nextSeqs {
+		s.queues[storeID] = &commandQueue{nextSeq: seq, commands: make(map[uint64]*command)}
+	}
+	var count int
+	for _, c := range loaded {
+		if _, ok := s.persisted[c.storeID]; !ok {
+			s.persisted[c.storeID] = make(map[uint64]struct{})
+		}
+		s.persisted[c.storeID][c.seq] = struct{}{}
+		q := s.queues[c.storeID]
+		if q == nil {
+			q = &commandQueue{commands: make(map[uint64]*command)}
+			s.queues[c.storeID] = q
+		}
+		if c.seq > q.nextSeq {
+			q.nextSeq = c.seq
+		}
+		// The dropped commands are removed from the storage by the next
+		// flush.
+		s.dirtyStores[c.storeID] = struct{}{}
+		pc := &persistedCommand{}
+		if err := json.Unmarshal([]byte(c.value), pc); err != nil {
+			log.Warn("drop the corrupted command", zap.Uint64("store-id", c.storeID), zap.Uint64("seq", c.seq),
+				errs.ZapError(errs.ErrJSONUnmarshal.Wrap(err).FastGenWithCause()))
+			continue
+		}
+		if now.Sub(pc.EnqueueTime) >= commandTTL {
+			continue
+		}
+		msg := &pdpb.RegionHeartbeatResponse{}
+		if err := proto.Unmarshal(pc.Msg, msg); err != nil {
+			log.Warn("drop the corrupted command", zap.Uint64("store-id", c.storeID), zap.Uint64("seq", c.seq),
+				errs.ZapError(errs.ErrProtoUnmarshal.Wrap(err).FastGenWithCause()))
+			continue
+		}
+		regionID := msg.GetRegionId()
+		if storeID, ok := s.regionStores[regionID]; ok {
+			// Keep the newer command of the region.
+			if old := s.queues[storeID].commands[regionID]; old.enqueueTime.After(pc.EnqueueTime) ||
+				(old.enqueueTime.Equal(pc.EnqueueTime) && old.seq > c.seq) {
+				continue
+			}
+			s.removeCommandLocked(regionID)
+			count--
+		}
+		q.commands[regionID] = &command{seq: c.seq, storeID: c.storeID, msg: msg, enqueueTime: pc.EnqueueTime, recovered: true}
+		s.regionStores[regionID] = c.storeID
+		count++
+	}
+	for storeID, q := range s.queues {
+		commandQueueGauge.WithLabelValues(strconv.FormatUint(storeID, 10)).Set(float64(len(q.commands)))
+	}
+	return count, nil
+}
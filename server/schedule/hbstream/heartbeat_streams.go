@@ -15,6 +15,7 @@ package hbstream
 
 import (
 	"context"
+	"sort"
 	"strconv"
 	"sync"
 	"time"
@@ -33,6 +34,9 @@ import (
 const (
 	heartbeatStreamKeepAliveInterval = time.Minute
 	heartbeatChanCapacity            = 1024
+	// commandTTL is how long a command is kept in the queue for redelivery
+	// before it is acknowledged.
+	commandTTL = 10 * time.Minute
 )
 
 type streamUpdate struct {
@@ -40,41 +44,99 @@ type streamUpdate struct {
 	stream  opt.HeartbeatStream
 }
 
+// storeStreams is the heartbeat streams of a store. The messages are sent by
+// the primary stream, and the standby stream, which is the previously bound
+// one, takes over when the primary stream fails, so that the operators can be
+// still dispatched before the store binds a new stream.
+type storeStreams struct {
+	primary opt.HeartbeatStream
+	standby opt.HeartbeatStream
+}
+
+// command is a command sent to a store, which is kept in the queue of the
+// store until it is acknowledged.
+type command struct {
+	seq         uint64
+	storeID     uint64
+	msg         *pdpb.RegionHeartbeatResponse
+	enqueueTime time.Time
+	// stream is the stream which sends the command last time, and it is nil
+	// if the command has not been sent.
+	stream opt.HeartbeatStream
+	// recovered means the command is loaded from the previous leader.
+	recovered bool
+}
+
+// commandQueue is the queue of the unacknowledged commands to a store, which
+// are redelivered when they are sent by a stream which is no longer the
+// primary one of the store, such as after the store binds a new stream or the
+// primary stream fails over. A region has at most one command in the queue,
+// and the newer one supersedes the older one.
+//
+// The queues are persisted by the leader periodically and loaded by the new
+// leader. As the operators only live in the memory of the leader, a loaded
+// command is only redelivered if it is still the step of a running operator
+// of the new leader on the unchanged region, see CommandValidator, and it is
+// dropped otherwise.
+type commandQueue struct {
+	nextSeq  uint64
+	commands map[uint64]*command // region ID -> command
+}
+
 // HeartbeatStreams is the bridge of communication with TIKV instance.
 type HeartbeatStreams struct {
-	wg             sync.WaitGroup
-	hbStreamCtx    context.Context
-	hbStreamCancel context.CancelFunc
-	clusterID      uint64
-	streams        map[uint64]opt.HeartbeatStream
-	msgCh          chan *pdpb.RegionHeartbeatResponse
-	streamCh       chan streamUpdate
-	storeInformer  core.StoreSetInformer
-	needRun        bool // For test only.
+	wg                sync.WaitGroup
+	hbStreamCtx       context.Context
+	hbStreamCancel    context.CancelFunc
+	clusterID         uint64
+	streams           map[uint64]*storeStreams
+	msgCh             chan *pdpb.RegionHeartbeatResponse
+	streamCh          chan streamUpdate
+	storeInformer     core.StoreSetInformer
+	keepAliveInterval time.Duration
+	needRun           bool // For test only.
+
+	queueMu sync.Mutex
+	queues  map[uint64]*commandQueue // store ID -> queue
+	// regionStores is the store of the command of each region in the queues.
+	regionStores map[uint64]uint64
+	// dirtyStores is the stores whose queues are changed since they are
+	// persisted.
+	dirtyStores map[uint64]struct{}
+	// persisted is the sequence numbers of the persisted commands of each
+	// store.
+	persisted map[uint64]map[uint64]struct{}
+	validator CommandValidator
 }
 
 // NewHeartbeatStreams creates a new HeartbeatStreams which enable background running by default.
-func NewHeartbeatStreams(ctx context.Context, clusterID uint64, storeInformer core.StoreSetInformer) *HeartbeatStreams {
-	return newHbStreams(ctx, clusterID, storeInformer, true)
+// The keepalive messages are sent every keepAliveInterval to detect the broken streams.
+func NewHeartbeatStreams(ctx context.Context, clusterID uint64, storeInformer core.StoreSetInformer, keepAliveInterval time.Duration) *HeartbeatStreams {
+	return newHbStreams(ctx, clusterID, storeInformer, keepAliveInterval, true)
 }
 
 // NewTestHeartbeatStreams creates a new HeartbeatStreams for test purpose only.
 // Please use NewHeartbeatStreams for other usage.
 func NewTestHeartbeatStreams(ctx context.Context, clusterID uint64, storeInformer core.StoreSetInformer, needRun bool) *HeartbeatStreams {
-	return newHbStreams(ctx, clusterID, storeInformer, needRun)
+	return newHbStreams(ctx, clusterID, storeInformer, heartbeatStreamKeepAliveInterval, needRun)
 }
 
-func newHbStreams(ctx context.Context, clusterID uint64, storeInformer core.StoreSetInformer, needRun bool) *HeartbeatStreams {
+func newHbStreams(ctx context.Context, clusterID uint64, storeInformer core.StoreSetInformer, keepAliveInterval time.Duration, needRun bool) *HeartbeatStreams {
 	hbStreamCtx, hbStreamCancel := context.WithCancel(ctx)
 	hs := &HeartbeatStreams{
-		hbStreamCtx:    hbStreamCtx,
-		hbStreamCancel: hbStreamCancel,
-		clusterID:      clusterID,
-		streams:        make(map[uint64]opt.HeartbeatStream),
-		msgCh:          make(chan *pdpb.RegionHeartbeatResponse, heartbeatChanCapacity),
-		streamCh:       make(chan streamUpdate, 1),
-		storeInformer:  storeInformer,
-		needRun:        needRun,
+		hbStreamCtx:       hbStreamCtx,
+		hbStreamCancel:    hbStreamCancel,
+		clusterID:         clusterID,
+		streams:           make(map[uint64]*storeStreams),
+		msgCh:             make(chan *pdpb.RegionHeartbeatResponse, heartbeatChanCapacity),
+		streamCh:          make(chan streamUpdate, 1),
+		storeInformer:     storeInformer,
+		keepAliveInterval: keepAliveInterval,
+		needRun:           needRun,
+		queues:            make(map[uint64]*commandQueue),
+		regionStores:      make(map[uint64]uint64),
+		dirtyStores:       make(map[uint64]struct{}),
+		persisted:         make(map[uint64]map[uint64]struct{}),
 	}
 	if needRun {
 		hs.wg.Add(1)
@@ -88,7 +150,7 @@ func (s *HeartbeatStreams) run() {
 
 	defer s.wg.Done()
 
-	keepAliveTicker := time.NewTicker(heartbeatStreamKeepAliveInterval)
+	keepAliveTicker := time.NewTicker(s.keepAliveInterval)
 	defer keepAliveTicker.Stop()
 
 	keepAlive := &pdpb.RegionHeartbeatResponse{Header: &pdpb.ResponseHeader{ClusterId: s.clusterID}}
@@ -96,7 +158,9 @@ func (s *HeartbeatStreams) run() {
 	for {
 		select {
 		case update := <-s.streamCh:
-			s.streams[update.storeID] = update.stream
+			if s.bind(update.storeID, update.stream) {
+				s.redeliver(update.storeID, true)
+			}
 		case msg := <-s.msgCh:
 			storeID := msg.GetTargetPeer().GetStoreId()
 			storeLabel := strconv.FormatUint(storeID, 10)
@@ -109,13 +173,13 @@ func (s *HeartbeatStreams) run() {
 				continue
 			}
 			storeAddress := store.GetAddress()
-			if stream, ok := s.streams[storeID]; ok {
-				if err := stream.Send(msg); err != nil {
+			if _, ok := s.streams[storeID]; ok {
+				if err := s.send(storeID, storeAddress, msg); err != nil {
 					log.Error("send heartbeat message fail",
 						zap.Uint64("region-id", msg.RegionId), errs.ZapError(errs.ErrGRPCSend.Wrap(err).GenWithStackByArgs()))
-					delete(s.streams, storeID)
 					heartbeatStreamCounter.WithLabelValues(storeAddress, storeLabel, "push", "err").Inc()
 				} else {
+					s.markSent(storeID, msg)
 					heartbeatStreamCounter.WithLabelValues(storeAddress, storeLabel, "push", "ok").Inc()
 				}
 			} else {
@@ -125,7 +189,9 @@ func (s *HeartbeatStreams) run() {
 				heartbeatStreamCounter.WithLabelValues(storeAddress, storeLabel, "push", "skip").Inc()
 			}
 		case <-keepAliveTicker.C:
-			for storeID, stream := range s.streams {
+			now := time.Now()
+			s.pruneCommands(now.Add(-commandTTL))
+			for storeID, streams := range s.streams {
 				store := s.storeInformer.GetStore(storeID)
 				if store == nil {
 					log.Error("failed to get store", zap.Uint64("store-id", storeID), errs.ZapError(errs.ErrGetSourceStore))
@@ -134,22 +200,236 @@ func (s *HeartbeatStreams) run() {
 				}
 				storeAddress := store.GetAddress()
 				storeLabel := strconv.FormatUint(storeID, 10)
-				if err := stream.Send(keepAlive); err != nil {
+				// Detect the broken standby stream, so that it won't be failed
+				// over to when the primary stream fails.
+				if streams.standby != nil {
+					if err := streams.standby.Send(keepAlive); err != nil {
+						log.Warn("send keepalive message by the standby stream fail",
+							zap.Uint64("target-store-id", storeID),
+							errs.ZapError(err))
+						streams.standby = nil
+					}
+				}
+				if err := s.send(storeID, storeAddress, keepAlive); err != nil {
 					log.Warn("send keepalive message fail, store maybe disconnected",
 						zap.Uint64("target-store-id", storeID),
 						errs.ZapError(err))
-					delete(s.streams, storeID)
 					heartbeatStreamCounter.WithLabelValues(storeAddress, storeLabel, "keepalive", "err").Inc()
 				} else {
 					heartbeatStreamCounter.WithLabelValues(storeAddress, storeLabel, "keepalive", "ok").Inc()
 				}
 			}
+			// The commands sent by a failed stream may be lost, so they are
+			// redelivered by the stream failed over to.
+			for storeID := range s.streams {
+				s.redeliver(storeID, false)
+			}
 		case <-s.hbStreamCtx.Done():
 			return
 		}
 	}
 }
 
+// bind makes the stream the primary stream of the store, and the previous
+// primary stream becomes the standby one. It returns true if the primary
+// stream is changed.
+func (s *HeartbeatStreams) bind(storeID uint64, stream opt.HeartbeatStream) bool {
+	streams, ok := s.streams[storeID]
+	if !ok {
+		s.streams[storeID] = &storeStreams{primary: stream}
+		return true
+	}
+	if streams.primary == stream {
+		return false
+	}
+	streams.primary, streams.standby = stream, streams.primary
+	return true
+}
+
+// send sends the message by the primary stream of the store, and fails over
+// to the standby stream if the primary one fails. The streams of the store are
+// removed if both of them fail.
+func (s *HeartbeatStreams) send(storeID uint64, storeAddress string, msg *pdpb.RegionHeartbeatResponse) error {
+	streams := s.streams[storeID]
+	err := streams.primary.Send(msg)
+	if err == nil {
+		return nil
+	}
+	if streams.standby == nil {
+		delete(s.streams, storeID)
+		return err
+	}
+	log.Warn("heartbeat stream fails, fail over to the standby stream",
+		zap.Uint64("store-id", storeID), errs.ZapError(err))
+	heartbeatStreamCounter.WithLabelValues(storeAddress, strconv.FormatUint(storeID, 10), "failover", "ok").Inc()
+	streams.primary, streams.standby = streams.standby, nil
+	if err = streams.primary.Send(msg); err != nil {
+		delete(s.streams, storeID)
+		return err
+	}
+	return nil
+}
+
+// redeliver sends the unacknowledged commands of the store in order, which
+// are sent by the streams other than the primary one, since they may be lost.
+// The commands which have not been sent are only redelivered after binding,
+// and the other ones are still waiting to be sent.
+func (s *HeartbeatStreams) redeliver(storeID uint64, afterBind bool) {
+	streams, ok := s.streams[storeID]
+	if !ok {
+		return
+	}
+	commands := s.getLostCommands(storeID, streams.primary, afterBind)
+	if len(commands) == 0 {
+		return
+	}
+	store := s.storeInformer.GetStore(storeID)
+	if store == nil {
+		return
+	}
+	storeAddress, storeLabel := store.GetAddress(), strconv.FormatUint(storeID, 10)
+	log.Info("redeliver the unacknowledged commands", zap.Uint64("store-id", storeID), zap.Int("count", len(commands)))
+	for _, cmd := range commands {
+		if _, ok := s.streams[storeID]; !ok {
+			return
+		}
+		if cmd.recovered && !s.validateRecovered(cmd) {
+			continue
+		}
+		if err := s.send(storeID, storeAddress, cmd.msg); err != nil {
+			log.Error("redeliver command fail",
+				zap.Uint64("region-id", cmd.msg.GetRegionId()),
+				zap.Uint64("seq", cmd.seq),
+				errs.ZapError(errs.ErrGRPCSend.Wrap(err).GenWithStackByArgs()))
+			heartbeatStreamCounter.WithLabelValues(storeAddress, storeLabel, "redeliver", "err").Inc()
+		} else {
+			s.markSent(storeID, cmd.msg)
+			heartbeatStreamCounter.WithLabelValues(storeAddress, storeLabel, "redeliver", "ok").Inc()
+		}
+	}
+}
+
+// enqueue adds the command to the queue of the target store, and supersedes
+// the previous command of the region.
+func (s *HeartbeatStreams) enqueue(msg *pdpb.RegionHeartbeatResponse) {
+	storeID, regionID := msg.GetTargetPeer().GetStoreId(), msg.GetRegionId()
+	s.queueMu.Lock()
+	defer s.queueMu.Unlock()
+	s.removeCommandLocked(regionID)
+	q, ok := s.queues[storeID]
+	if !ok {
+		q = &commandQueue{commands: make(map[uint64]*command)}
+		s.queues[storeID] = q
+	}
+	q.nextSeq++
+	q.commands[regionID] = &command{seq: q.nextSeq, storeID: storeID, msg: msg, enqueueTime: time.Now()}
+	s.regionStores[regionID] = storeID
+	s.dirtyStores[storeID] = struct{}{}
+	commandQueueGauge.WithLabelValues(strconv.FormatUint(storeID, 10)).Set(float64(len(q.commands)))
+}
+
+// getCommands returns the commands in the queue of the store ordered by the
+// sequence numbers.
+func (s *HeartbeatStreams) getCommands(storeID uint64) []*command {
+	s.queueMu.Lock()
+	defer s.queueMu.Unlock()
+	q, ok := s.queues[storeID]
+	if !ok {
+		return nil
+	}
+	commands := make([]*command, 0, len(q.commands))
+	for _, cmd := range q.commands {
+		commands = append(commands, cmd)
+	}
+	sort.Slice(commands, func(i, j int) bool { return commands[i].seq < commands[j].seq })
+	return commands
+}
+
+// getLostCommands returns the commands in the queue of the store which are
+// sent by the streams other than the primary one, ordered by the sequence
+// numbers. The commands which have not been sent are included if withUnsent
+// is true.
+func (s *HeartbeatStreams) getLostCommands(storeID uint64, primary opt.HeartbeatStream, withUnsent bool) []*command {
+	s.queueMu.Lock()
+	defer s.queueMu.Unlock()
+	q, ok := s.queues[storeID]
+	if !ok {
+		return nil
+	}
+	var commands []*command
+	for _, cmd := range q.commands {
+		if cmd.stream == primary || (cmd.stream == nil && !withUnsent) {
+			continue
+		}
+		commands = append(commands, cmd)
+	}
+	sort.Slice(commands, func(i, j int) bool { return commands[i].seq < commands[j].seq })
+	return commands
+}
+
+// markSent records the primary stream of the store as the stream sending the
+// command, if the message is still the command of the region in the queue.
+func (s *HeartbeatStreams) markSent(storeID uint64, msg *pdpb.RegionHeartbeatResponse) {
+	streams, ok := s.streams[storeID]
+	if !ok {
+		return
+	}
+	s.queueMu.Lock()
+	defer s.queueMu.Unlock()
+	if q, ok := s.queues[storeID]; ok {
+		if cmd, ok := q.commands[msg.GetRegionId()]; ok && cmd.msg == msg {
+			cmd.stream = streams.primary
+		}
+	}
+}
+
+func (s *HeartbeatStreams) removeCommandLocked(regionID uint64) *command {
+	storeID, ok := s.regionStores[regionID]
+	if !ok {
+		return nil
+	}
+	delete(s.regionStores, regionID)
+	s.dirtyStores[storeID] = struct{}{}
+	q := s.queues[storeID]
+	cmd := q.commands[regionID]
+	delete(q.commands, regionID)
+	commandQueueGauge.WithLabelValues(strconv.FormatUint(storeID, 10)).Set(float64(len(q.commands)))
+	return cmd
+}
+
+// pruneCommands drops the commands enqueued before the time without being
+// acknowledged, whose operators should have timed out.
+func (s *HeartbeatStreams) pruneCommands(before time.Time) {
+	s.queueMu.Lock()
+	defer s.queueMu.Unlock()
+	for regionID, storeID := range s.regionStores {
+		if s.queues[storeID].commands[regionID].enqueueTime.Before(before) {
+			s.removeCommandLocked(regionID)
+			commandDroppedCounter.WithLabelValues(strconv.FormatUint(storeID, 10)).Inc()
+			log.Warn("drop the command without being acknowledged", zap.Uint64("region-id", regionID), zap.Uint64("store-id", storeID))
+		}
+	}
+}
+
+// AckMsg acknowledges the command of the region when the region reports that
+// the command has been executed, and the dispatch latency of the command is
+// recorded.
+func (s *HeartbeatStreams) AckMsg(regionID uint64) {
+	s.queueMu.Lock()
+	defer s.queueMu.Unlock()
+	if cmd := s.removeCommandLocked(regionID); cmd != nil {
+		commandDispatchDuration.WithLabelValues(strconv.FormatUint(cmd.storeID, 10)).Observe(time.Since(cmd.enqueueTime).Seconds())
+	}
+}
+
+// ClearMsg removes the command of the region without acknowledging it, such
+// as when its operator is canceled, so that it won't be redelivered.
+func (s *HeartbeatStreams) ClearMsg(regionID uint64) {
+	s.queueMu.Lock()
+	defer s.queueMu.Unlock()
+	s.removeCommandLocked(regionID)
+}
+
 // Close closes background running.
 func (s *HeartbeatStreams) Close() {
 	s.hbStreamCancel()
@@ -178,6 +458,7 @@ func (s *HeartbeatStreams) SendMsg(region *core.RegionInfo, msg *pdpb.RegionHear
 	msg.RegionId = region.GetID()
 	msg.RegionEpoch = region.GetRegionEpoch()
 	msg.TargetPeer = region.GetLeader()
+	s.enqueue(msg)
 
 	select {
 	case s.msgCh <- msg:
@@ -210,6 +491,14 @@ func (s *HeartbeatStreams) MsgLength() int {
 	return len(s.msgCh)
 }
 
+// CommandCount returns the number of the unacknowledged commands.
+// For test only.
+func (s *HeartbeatStreams) CommandCount() int {
+	s.queueMu.Lock()
+	defer s.queueMu.Unlock()
+	return len(s.regionStores)
+}
+
 // Drain consumes message from msgCh when disable background running.
 // For test only.
 func (s *HeartbeatStreams) Drain(count int) error {
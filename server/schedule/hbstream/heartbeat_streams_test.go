@@ -16,9 +16,11 @@ package hbstream
 import (
 	"context"
 	"testing"
+	"time"
 
 	"github.com/gogo/protobuf/proto"
 	. "github.com/pingcap/check"
+	"github.com/pingcap/errors"
 	"github.com/pingcap/kvproto/pkg/eraftpb"
 	"github.com/pingcap/kvproto/pkg/metapb"
 	"github.com/pingcap/kvproto/pkg/pdpb"
@@ -26,6 +28,8 @@ import (
 	"github.com/tikv/pd/pkg/mock/mockhbstream"
 	"github.com/tikv/pd/pkg/testutil"
 	"github.com/tikv/pd/server/config"
+	"github.com/tikv/pd/server/core"
+	"github.com/tikv/pd/server/kv"
 )
 
 func TestHeaertbeatStreams(t *testing.T) {
@@ -69,6 +73,8 @@ func (s *testHeartbeatStreamSuite) TestActivity(c *C) {
 		hbs.SendMsg(region, proto.Clone(msg).(*pdpb.RegionHeartbeatResponse))
 		return stream1.Recv() != nil && stream2.Recv() == nil
 	})
+	// Acknowledge the command, so it won't be redelivered.
+	hbs.AckMsg(region.GetID())
 	// Rebind to stream2.
 	hbs.BindStream(1, stream2)
 	testutil.WaitUntil(c, func(c *C) bool {
@@ -77,9 +83,11 @@ func (s *testHeartbeatStreamSuite) TestActivity(c *C) {
 	})
 	// SendErr to stream2.
 	hbs.SendErr(pdpb.ErrorType_UNKNOWN, "test error", &metapb.Peer{Id: 1, StoreId: 1})
-	res := stream2.Recv()
-	c.Assert(res, NotNil)
-	c.Assert(res.GetHeader().GetError(), NotNil)
+	// The redelivered commands may be received before the error.
+	testutil.WaitUntil(c, func(c *C) bool {
+		res := stream2.Recv()
+		return res != nil && res.GetHeader().GetError() != nil
+	})
 	// Switch back to 1 again.
 	hbs.BindStream(1, stream1)
 	testutil.WaitUntil(c, func(c *C) bool {
@@ -87,3 +95,220 @@ func (s *testHeartbeatStreamSuite) TestActivity(c *C) {
 		return stream1.Recv() != nil && stream2.Recv() == nil
 	})
 }
+
+type testStream struct {
+	broken bool
+	msgs   []*pdpb.RegionHeartbeatResponse
+}
+
+func (s *testStream) Send(m *pdpb.RegionHeartbeatResponse) error {
+	if s.broken {
+		return errors.New("stream is broken")
+	}
+	s.msgs = append(s.msgs, m)
+	return nil
+}
+
+func (s *testHeartbeatStreamSuite) TestFailover(c *C) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	cluster := mockcluster.NewCluster(s.ctx, config.NewTestOptions())
+	hbs := NewTestHeartbeatStreams(ctx, cluster.ID, cluster, false)
+	msg := &pdpb.RegionHeartbeatResponse{RegionId: 1}
+
+	stream1, stream2 := &testStream{}, &testStream{broken: true}
+	hbs.bind(1, stream1)
+	hbs.bind(1, stream1)
+	c.Assert(hbs.streams[1].standby, IsNil)
+	// The broken stream becomes the primary one, and the message is sent by
+	// the standby stream after the failover.
+	hbs.bind(1, stream2)
+	c.Assert(hbs.send(1, "", msg), IsNil)
+	c.Assert(stream1.msgs, HasLen, 1)
+	c.Assert(hbs.streams[1].primary, Equals, stream1)
+	c.Assert(hbs.streams[1].standby, IsNil)
+
+	// The streams are removed if both of them are broken.
+	hbs.bind(1, stream2)
+	stream1.broken = true
+	c.Assert(hbs.send(1, "", msg), NotNil)
+	c.Assert(hbs.streams, HasLen, 0)
+}
+
+func (s *testHeartbeatStreamSuite) TestRedeliver(c *C) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	cluster := mockcluster.NewCluster(s.ctx, config.NewTestOptions())
+	cluster.AddRegionStore(1, 1)
+	cluster.AddLeaderRegion(1, 1)
+	cluster.AddLeaderRegion(2, 1)
+	cluster.AddLeaderRegion(3, 1)
+	hbs := NewTestHeartbeatStreams(ctx, cluster.ID, cluster, false)
+
+	// The commands are queued before the store binds a stream.
+	hbs.SendMsg(cluster.GetRegion(2), &pdpb.RegionHeartbeatResponse{})
+	hbs.SendMsg(cluster.GetRegion(1), &pdpb.RegionHeartbeatResponse{TransferLeader: &pdpb.TransferLeader{}})
+	hbs.SendMsg(cluster.GetRegion(1), &pdpb.RegionHeartbeatResponse{})
+	c.Assert(hbs.Drain(3), IsNil)
+	commands := hbs.getCommands(1)
+	c.Assert(commands, HasLen, 2)
+	c.Assert(commands[0].msg.GetRegionId(), Equals, uint64(2))
+	// The newer command of the region 1 supersedes the older one.
+	c.Assert(commands[1].msg.GetRegionId(), Equals, uint64(1))
+	c.Assert(commands[1].msg.GetTransferLeader(), IsNil)
+	c.Assert(commands[1].seq, Equals, uint64(3))
+
+	// The unacknowledged commands are redelivered in order after binding.
+	stream1, stream2 := &testStream{}, &testStream{}
+	c.Assert(hbs.bind(1, stream1), IsTrue)
+	hbs.redeliver(1, true)
+	c.Assert(stream1.msgs, HasLen, 2)
+	c.Assert(stream1.msgs[0].GetRegionId(), Equals, uint64(2))
+	c.Assert(stream1.msgs[1].GetRegionId(), Equals, uint64(1))
+	c.Assert(hbs.bind(1, stream1), IsFalse)
+
+	// The keepalive does not redeliver the commands sent by the primary
+	// stream, or the ones waiting to be sent.
+	hbs.SendMsg(cluster.GetRegion(3), &pdpb.RegionHeartbeatResponse{})
+	c.Assert(hbs.Drain(1), IsNil)
+	hbs.redeliver(1, false)
+	c.Assert(stream1.msgs, HasLen, 2)
+
+	hbs.AckMsg(2)
+	c.Assert(hbs.bind(1, stream2), IsTrue)
+	hbs.redeliver(1, true)
+	c.Assert(stream2.msgs, HasLen, 2)
+	c.Assert(stream2.msgs[0].GetRegionId(), Equals, uint64(1))
+	c.Assert(stream2.msgs[1].GetRegionId(), Equals, uint64(3))
+
+	// The commands sent by the failed stream are redelivered by the keepalive
+	// after failing over.
+	hbs.ClearMsg(3)
+	stream2.broken = true
+	c.Assert(hbs.send(1, "", &pdpb.RegionHeartbeatResponse{}), IsNil)
+	c.Assert(hbs.streams[1].primary, Equals, stream1)
+	hbs.redeliver(1, false)
+	c.Assert(stream1.msgs, HasLen, 4)
+	c.Assert(stream1.msgs[3].GetRegionId(), Equals, uint64(1))
+	hbs.redeliver(1, false)
+	c.Assert(stream1.msgs, HasLen, 4)
+
+	// The expired commands are removed.
+	hbs.pruneCommands(time.Now().Add(time.Second))
+	c.Assert(hbs.getCommands(1), HasLen, 0)
+}
+
+func (s *testHeartbeatStreamSuite) TestPersistCommands(c *C) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	cluster := mockcluster.NewCluster(s.ctx, config.NewTestOptions())
+	cluster.AddRegionStore(1, 1)
+	cluster.AddLeaderRegion(1, 1)
+	cluster.AddLeaderRegion(2, 1)
+	storage := core.NewStorage(kv.NewMemoryKV())
+	hbs := NewTestHeartbeatStreams(ctx, cluster.ID, cluster, false)
+	hbs.SendMsg(cluster.GetRegion(1), &pdpb.RegionHeartbeatResponse{TransferLeader: &pdpb.TransferLeader{}})
+	hbs.SendMsg(cluster.GetRegion(2), &pdpb.RegionHeartbeatResponse{})
+	hbs.SendMsg(cluster.GetRegion(2), &pdpb.RegionHeartbeatResponse{})
+	c.Assert(hbs.Drain(3), IsNil)
+	hbs.FlushCommands(storage)
+
+	// The new leader loads the commands and keeps the sequence numbers.
+	hbs2 := NewTestHeartbeatStreams(ctx, cluster.ID, cluster, false)
+	count, err := hbs2.LoadCommands(storage, time.Now())
+	c.Assert(err, IsNil)
+	c.Assert(count, Equals, 2)
+	commands := hbs2.getCommands(1)
+	c.Assert(commands, HasLen, 2)
+	c.Assert(commands[0].msg.GetRegionId(), Equals, uint64(1))
+	c.Assert(commands[0].msg.GetTransferLeader(), NotNil)
+	c.Assert(commands[1].seq, Equals, uint64(3))
+	hbs2.SendMsg(cluster.GetRegion(1), &pdpb.RegionHeartbeatResponse{})
+	c.Assert(hbs2.Drain(1), IsNil)
+	commands = hbs2.getCommands(1)
+	c.Assert(commands[1].msg.GetRegionId(), Equals, uint64(1))
+	c.Assert(commands[1].seq, Equals, uint64(4))
+
+	// The acknowledged commands are removed from the storage.
+	hbs2.AckMsg(1)
+	hbs2.AckMsg(2)
+	hbs2.FlushCommands(storage)
+	count, err = hbs.LoadCommands(storage, time.Now())
+	c.Assert(err, IsNil)
+	c.Assert(count, Equals, 0)
+	c.Assert(hbs.CommandCount(), Equals, 0)
+
+	// The expired commands are not loaded.
+	hbs2.SendMsg(cluster.GetRegion(1), &pdpb.RegionHeartbeatResponse{})
+	c.Assert(hbs2.Drain(1), IsNil)
+	hbs2.FlushCommands(storage)
+	count, err = hbs.LoadCommands(storage, time.Now().Add(commandTTL))
+	c.Assert(err, IsNil)
+	c.Assert(count, Equals, 0)
+}
+
+func (s *testHeartbeatStreamSuite) TestRecoveredCommands(c *C) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	cluster := mockcluster.NewCluster(s.ctx, config.NewTestOptions())
+	cluster.AddRegionStore(1, 1)
+	cluster.AddRegionStore(2, 1)
+	cluster.AddLeaderRegion(1, 1)
+	cluster.AddLeaderRegion(2, 1)
+	cluster.AddLeaderRegion(3, 2)
+	storage := core.NewStorage(kv.NewMemoryKV())
+	hbs := NewTestHeartbeatStreams(ctx, cluster.ID, cluster, false)
+	hbs.SendMsg(cluster.GetRegion(1), &pdpb.RegionHeartbeatResponse{TransferLeader: &pdpb.TransferLeader{}})
+	hbs.SendMsg(cluster.GetRegion(2), &pdpb.RegionHeartbeatResponse{})
+	hbs.SendMsg(cluster.GetRegion(3), &pdpb.RegionHeartbeatResponse{})
+	c.Assert(hbs.Drain(3), IsNil)
+	hbs.FlushCommands(storage)
+
+	// Each command is persisted in its own key.
+	var keys []string
+	c.Assert(storage.LoadRangeByPrefix(commandsPath+"/", func(k, _ string) { keys = append(keys, k) }), IsNil)
+	c.Assert(keys, DeepEquals, []string{
+		"00000000000000000001/00000000000000000001",
+		"00000000000000000001/00000000000000000002",
+		"00000000000000000002/00000000000000000001",
+	})
+
+	// Without a validator, the commands replayed after the leader changes are
+	// not sent, and they are removed from the storage.
+	hbs2 := NewTestHeartbeatStreams(ctx, cluster.ID, cluster, false)
+	count, err := hbs2.LoadCommands(storage, time.Now())
+	c.Assert(err, IsNil)
+	c.Assert(count, Equals, 3)
+	stream := &testStream{}
+	c.Assert(hbs2.bind(1, stream), IsTrue)
+	hbs2.redeliver(1, true)
+	c.Assert(stream.msgs, HasLen, 0)
+	c.Assert(hbs2.CommandCount(), Equals, 1)
+	hbs2.FlushCommands(storage)
+	keys = keys[:0]
+	c.Assert(storage.LoadRangeByPrefix(commandsPath+"/", func(k, _ string) { keys = append(keys, k) }), IsNil)
+	c.Assert(keys, DeepEquals, []string{"00000000000000000002/00000000000000000001"})
+
+	// The command is only sent if the new leader still runs its operator.
+	hbs3 := NewTestHeartbeatStreams(ctx, cluster.ID, cluster, false)
+	count, err = hbs3.LoadCommands(storage, time.Now())
+	c.Assert(err, IsNil)
+	c.Assert(count, Equals, 1)
+	hbs3.SetCommandValidator(func(msg *pdpb.RegionHeartbeatResponse) bool { return msg.GetRegionId() == 3 })
+	c.Assert(hbs3.bind(2, stream), IsTrue)
+	hbs3.redeliver(2, true)
+	c.Assert(stream.msgs, HasLen, 1)
+	c.Assert(stream.msgs[0].GetRegionId(), Equals, uint64(3))
+
+	// The sequence numbers keep increasing.
+	hbs3.SendMsg(cluster.GetRegion(1), &pdpb.RegionHeartbeatResponse{})
+	c.Assert(hbs3.Drain(1), IsNil)
+	commands := hbs3.getCommands(1)
+	c.Assert(commands, HasLen, 1)
+	c.Assert(commands[0].seq, Equals, uint64(3))
+	c.Assert(commands[0].recovered, IsFalse)
+}
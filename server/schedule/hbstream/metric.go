@@ -23,8 +23,36 @@ var (
 			Name:      "region_message",
 			Help:      "Counter of message hbstream sent.",
 		}, []string{"address", "store", "type", "status"})
+
+	commandQueueGauge = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Namespace: "pd",
+			Subsystem: "hbstream",
+			Name:      "command_queue_length",
+			Help:      "The number of the unacknowledged commands of each store.",
+		}, []string{"store"})
+
+	commandDispatchDuration = prometheus.NewHistogramVec(
+		prometheus.HistogramOpts{
+			Namespace: "pd",
+			Subsystem: "hbstream",
+			Name:      "command_dispatch_duration_seconds",
+			Help:      "Bucketed histogram of the duration from sending a command to acknowledging it.",
+			Buckets:   prometheus.ExponentialBuckets(0.01, 2, 16),
+		}, []string{"store"})
+
+	commandDroppedCounter = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace: "pd",
+			Subsystem: "hbstream",
+			Name:      "command_dropped_total",
+			Help:      "Counter of the commands dropped without being acknowledged.",
+		}, []string{"store"})
 )
 
 func init() {
 	prometheus.MustRegister(heartbeatStreamCounter)
+	prometheus.MustRegister(commandQueueGauge)
+	prometheus.MustRegister(commandDispatchDuration)
+	prometheus.MustRegister(commandDroppedCounter)
 }
@@ -0,0 +1,264 @@
+// Copyright 2021 TiKV Project Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package keyrange
+
+import (
+	"bytes"
+	"encoding/hex"
+	"encoding/json"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/pingcap/log"
+	"github.com/tikv/pd/pkg/errs"
+	"github.com/tikv/pd/server/core"
+	"go.uber.org/zap"
+)
+
+const (
+	lockPath = "key_range_lock"
+	// DefaultLockTTL is the TTL of a lock if it is not specified.
+	DefaultLockTTL = time.Minute
+	// MaxLockTTL is the max TTL of a lock. The lockers should keep the locks
+	// alive before they expire.
+	MaxLockTTL = 10 * time.Minute
+)
+
+// Lock is a lease-based lock on a key range for the administrative
+// operations, such as the restoring and the importing. While it is held, the
+// regions in the range are not merged, split or balanced by PD.
+type Lock struct {
+	ID          string    `json:"id"`
+	Owner       string    `json:"owner"`
+	StartKeyHex string    `json:"start_key"`
+	EndKeyHex   string    `json:"end_key"`
+	Expire      time.Time `json:"expire"`
+
+	startKey, endKey []byte
+}
+
+func (l *Lock) adjust() (err error) {
+	if l.Owner == "" {
+		return errs.ErrKeyRangeLockContent.FastGenByArgs("owner should not be empty")
+	}
+	if l.startKey, err = hex.DecodeString(l.StartKeyHex); err != nil {
+		return errs.ErrHexDecodingString.FastGenByArgs(l.StartKeyHex)
+	}
+	if l.endKey, err = hex.DecodeString(l.EndKeyHex); err != nil {
+		return errs.ErrHexDecodingString.FastGenByArgs(l.EndKeyHex)
+	}
+	if len(l.endKey) > 0 && bytes.Compare(l.endKey, l.startKey) <= 0 {
+		return errs.ErrKeyRangeLockContent.FastGenByArgs("endKey should be greater than startKey")
+	}
+	return nil
+}
+
+func (l *Lock) clone() *Lock {
+	nl := *l
+	return &nl
+}
+
+// overlaps returns whether the lock overlaps with the range. An empty end key
+// means the end of the key space.
+func (l *Lock) overlaps(startKey, endKey []byte) bool {
+	return (len(endKey) == 0 || bytes.Compare(l.startKey, endKey) < 0) &&
+		(len(l.endKey) == 0 || bytes.Compare(startKey, l.endKey) < 0)
+}
+
+func (l *Lock) expired(now time.Time) bool {
+	return !now.Before(l.Expire)
+}
+
+// Manager maintains the key range locks. The locks are persisted, so they
+// are kept after the leader changes, and are removed once they expire.
+type Manager struct {
+	sync.RWMutex
+	storage *core.Storage
+	locks   map[string]*Lock
+	// now is replaceable in tests.
+	now func() time.Time
+}
+
+// NewManager creates a key range lock manager and loads the locks from
+// storage.
+func NewManager(storage *core.Storage) (*Manager, error) {
+	m := &Manager{
+		storage: storage,
+		locks:   make(map[string]*Lock),
+		now:     time.Now,
+	}
+	if err := m.load(); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+func (m *Manager) load() error {
+	var toDelete []string
+	err := m.storage.LoadRangeByPrefix(lockPath+"/", func(k, v string) {
+		l := &Lock{}
+		if err := json.Unmarshal([]byte(v), l); err != nil {
+			log.Error("failed to unmarshal key range lock value", zap.String("lock-key", k), errs.ZapError(errs.ErrJSONUnmarshal.Wrap(err).FastGenWithCause()))
+			toDelete = append(toDelete, k)
+			return
+		}
+		if err := l.adjust(); err != nil {
+			log.Error("key range lock is in bad format", zap.String("lock-key", k), errs.ZapError(err))
+			toDelete = append(toDelete, k)
+			return
+		}
+		m.locks[l.ID] = l
+	})
+	if err != nil {
+		return err
+	}
+	for _, k := range toDelete {
+		if err := m.storage.Remove(lockPath + "/" + k); err != nil {
+			return err
+		}
+	}
+	return m.gcLocked(m.now())
+}
+
+// gcLocked removes the expired locks.
+func (m *Manager) gcLocked(now time.Time) error {
+	for id, l := range m.locks {
+		if !l.expired(now) {
+			continue
+		}
+		if err := m.storage.Remove(lockPath + "/" + id); err != nil {
+			return err
+		}
+		delete(m.locks, id)
+		log.Info("key range lock expired", zap.String("lock-id", id), zap.String("owner", l.Owner))
+	}
+	return nil
+}
+
+func checkTTL(ttl time.Duration) (time.Duration, error) {
+	if ttl == 0 {
+		return DefaultLockTTL, nil
+	}
+	if ttl < 0 || ttl > MaxLockTTL {
+		return 0, errs.ErrKeyRangeLockContent.FastGenByArgs("ttl should be in (0, " + MaxLockTTL.String() + "]")
+	}
+	return ttl, nil
+}
+
+// Acquire locks the key range for the owner. It fails if the range overlaps
+// with a lock held by another owner. A zero TTL means DefaultLockTTL.
+func (m *Manager) Acquire(owner, startKeyHex, endKeyHex string, ttl time.Duration) (*Lock, error) {
+	ttl, err := checkTTL(ttl)
+	if err != nil {
+		return nil, err
+	}
+	l := &Lock{Owner: owner, StartKeyHex: startKeyHex, EndKeyHex: endKeyHex}
+	if err := l.adjust(); err != nil {
+		return nil, err
+	}
+	m.Lock()
+	defer m.Unlock()
+	now := m.now()
+	if err := m.gcLocked(now); err != nil {
+		return nil, err
+	}
+	for _, other := range m.locks {
+		if other.Owner != owner && other.overlaps(l.startKey, l.endKey) {
+			return nil, errs.ErrKeyRangeLocked.FastGenByArgs(other.Owner)
+		}
+	}
+	id := now.UnixNano()
+	for m.locks[strconv.FormatInt(id, 10)] != nil {
+		id++
+	}
+	l.ID = strconv.FormatInt(id, 10)
+	l.Expire = now.Add(ttl)
+	if err := m.storage.SaveJSON(lockPath, l.ID, l); err != nil {
+		return nil, err
+	}
+	m.locks[l.ID] = l
+	log.Info("key range locked", zap.String("lock-id", l.ID), zap.String("owner", owner),
+		zap.String("start-key", startKeyHex), zap.String("end-key", endKeyHex), zap.Duration("ttl", ttl))
+	return l.clone(), nil
+}
+
+// KeepAlive extends the lease of the lock by the TTL from now. A zero TTL
+// means DefaultLockTTL.
+func (m *Manager) KeepAlive(id string, ttl time.Duration) (*Lock, error) {
+	ttl, err := checkTTL(ttl)
+	if err != nil {
+		return nil, err
+	}
+	m.Lock()
+	defer m.Unlock()
+	now := m.now()
+	l, ok := m.locks[id]
+	if !ok || l.expired(now) {
+		return nil, errs.ErrKeyRangeLockNotFound.FastGenByArgs(id)
+	}
+	nl := l.clone()
+	nl.Expire = now.Add(ttl)
+	if err := m.storage.SaveJSON(lockPath, id, nl); err != nil {
+		return nil, err
+	}
+	m.locks[id] = nl
+	return nl.clone(), nil
+}
+
+// Release removes the lock.
+func (m *Manager) Release(id string) error {
+	m.Lock()
+	defer m.Unlock()
+	l, ok := m.locks[id]
+	if !ok || l.expired(m.now()) {
+		return errs.ErrKeyRangeLockNotFound.FastGenByArgs(id)
+	}
+	if err := m.storage.Remove(lockPath + "/" + id); err != nil {
+		return err
+	}
+	delete(m.locks, id)
+	log.Info("key range unlocked", zap.String("lock-id", id), zap.String("owner", l.Owner))
+	return nil
+}
+
+// GetLocks returns the locks which are not expired, sorted by ID.
+func (m *Manager) GetLocks() []*Lock {
+	m.RLock()
+	defer m.RUnlock()
+	now := m.now()
+	locks := make([]*Lock, 0, len(m.locks))
+	for _, l := range m.locks {
+		if !l.expired(now) {
+			locks = append(locks, l.clone())
+		}
+	}
+	sort.Slice(locks, func(i, j int) bool { return locks[i].ID < locks[j].ID })
+	return locks
+}
+
+// IsRangeLocked returns whether the range overlaps with any lock which is
+// not expired.
+func (m *Manager) IsRangeLocked(startKey, endKey []byte) bool {
+	m.RLock()
+	defer m.RUnlock()
+	now := m.now()
+	for _, l := range m.locks {
+		if !l.expired(now) && l.overlaps(startKey, endKey) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,108 @@
+// Copyright 2021 TiKV Project Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package keyrange
+
+import (
+	"encoding/hex"
+	"testing"
+	"time"
+
+	. "github.com/pingcap/check"
+	"github.com/tikv/pd/pkg/errs"
+	"github.com/tikv/pd/server/core"
+	"github.com/tikv/pd/server/kv"
+)
+
+func TestKeyRange(t *testing.T) {
+	TestingT(t)
+}
+
+var _ = Suite(&testManagerSuite{})
+
+type testManagerSuite struct{}
+
+func hexKey(key string) string {
+	return hex.EncodeToString([]byte(key))
+}
+
+func (s *testManagerSuite) TestValidation(c *C) {
+	m, err := NewManager(core.NewStorage(kv.NewMemoryKV()))
+	c.Assert(err, IsNil)
+	_, err = m.Acquire("", hexKey("a"), hexKey("b"), 0)
+	c.Assert(errs.ErrKeyRangeLockContent.Equal(err), IsTrue)
+	_, err = m.Acquire("br", "xyz", hexKey("b"), 0)
+	c.Assert(errs.ErrHexDecodingString.Equal(err), IsTrue)
+	_, err = m.Acquire("br", hexKey("b"), hexKey("a"), 0)
+	c.Assert(errs.ErrKeyRangeLockContent.Equal(err), IsTrue)
+	_, err = m.Acquire("br", hexKey("a"), hexKey("b"), MaxLockTTL+time.Second)
+	c.Assert(errs.ErrKeyRangeLockContent.Equal(err), IsTrue)
+	_, err = m.KeepAlive("unknown", 0)
+	c.Assert(errs.ErrKeyRangeLockNotFound.Equal(err), IsTrue)
+	c.Assert(errs.ErrKeyRangeLockNotFound.Equal(m.Release("unknown")), IsTrue)
+}
+
+func (s *testManagerSuite) TestLock(c *C) {
+	storage := core.NewStorage(kv.NewMemoryKV())
+	m, err := NewManager(storage)
+	c.Assert(err, IsNil)
+	now := time.Now()
+	m.now = func() time.Time { return now }
+
+	l1, err := m.Acquire("br", hexKey("b"), hexKey("d"), 0)
+	c.Assert(err, IsNil)
+	c.Assert(l1.Expire, Equals, now.Add(DefaultLockTTL))
+	// The same owner can lock the overlapped ranges, while the others can't.
+	l2, err := m.Acquire("br", hexKey("c"), hexKey("e"), time.Second)
+	c.Assert(err, IsNil)
+	c.Assert(l2.ID, Not(Equals), l1.ID)
+	_, err = m.Acquire("lightning", hexKey("a"), hexKey("c"), 0)
+	c.Assert(errs.ErrKeyRangeLocked.Equal(err), IsTrue)
+	_, err = m.Acquire("lightning", hexKey("d"), "", 0)
+	c.Assert(errs.ErrKeyRangeLocked.Equal(err), IsTrue)
+	l3, err := m.Acquire("lightning", hexKey("a"), hexKey("b"), 0)
+	c.Assert(err, IsNil)
+	c.Assert(m.GetLocks(), HasLen, 3)
+
+	c.Assert(m.IsRangeLocked([]byte("a"), []byte("aa")), IsTrue)
+	c.Assert(m.IsRangeLocked([]byte("d"), []byte("da")), IsTrue)
+	c.Assert(m.IsRangeLocked([]byte("e"), nil), IsFalse)
+	c.Assert(m.IsRangeLocked([]byte(""), []byte("a")), IsFalse)
+
+	// The locks are loaded after the leader changes.
+	m2, err := NewManager(storage)
+	c.Assert(err, IsNil)
+	m2.now = m.now
+	locks := m2.GetLocks()
+	c.Assert(locks, HasLen, 3)
+	for i, l := range m.GetLocks() {
+		c.Assert(locks[i].ID, Equals, l.ID)
+		c.Assert(locks[i].Expire.Equal(l.Expire), IsTrue)
+	}
+	c.Assert(m2.IsRangeLocked([]byte("a"), []byte("aa")), IsTrue)
+
+	// The lock expires unless it is kept alive.
+	now = now.Add(time.Second)
+	c.Assert(m.IsRangeLocked([]byte("d"), []byte("da")), IsFalse)
+	_, err = m.KeepAlive(l2.ID, 0)
+	c.Assert(errs.ErrKeyRangeLockNotFound.Equal(err), IsTrue)
+	l1, err = m.KeepAlive(l1.ID, time.Minute)
+	c.Assert(err, IsNil)
+	c.Assert(l1.Expire, Equals, now.Add(time.Minute))
+	_, err = m.Acquire("lightning", hexKey("d"), "", 0)
+	c.Assert(err, IsNil)
+
+	c.Assert(m.Release(l3.ID), IsNil)
+	c.Assert(m.IsRangeLocked([]byte("a"), []byte("aa")), IsFalse)
+	c.Assert(m.GetLocks(), HasLen, 2)
+}
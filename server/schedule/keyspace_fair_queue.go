@@ -0,0 +1,203 @@
+// Copyright 2021 TiKV Project Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package schedule
+
+import (
+	"sort"
+	"strconv"
+
+	"github.com/tikv/pd/server/schedule/operator"
+)
+
+const (
+	// noKeyspace is the flow of the operators whose regions don't belong to
+	// any keyspace.
+	noKeyspace int64 = -1
+	// keyspaceInfoKey is the key of the keyspace in the additional infos of
+	// the operators.
+	keyspaceInfoKey = "keyspace"
+	// otherKeyspaces is the keyspace label in the metrics of the keyspaces
+	// without a configured weight, which bounds the number of the label
+	// values.
+	otherKeyspaces = "other"
+)
+
+// KeyspaceFairQueue is an implementation of waiting operators. The operators
+// are queued by the keyspaces of their regions, and the keyspaces are served
+// with the weighted fairness, so that the largest tenant cannot take all the
+// scheduling when the keyspaces share the stores. Each keyspace has its own
+// inner queue, which decides the order of the operators in the keyspace.
+//
+// A keyspace is charged when an operator of it is promoted, so the operators
+// promoted in the same round are counted one by one.
+type KeyspaceFairQueue struct {
+	// keyspace returns the keyspace of the region of the operator.
+	keyspace func(op *operator.Operator) int64
+	// weight returns the weight of the keyspace.
+	weight func(keyspaceID uint32) float64
+	// newQueue creates the inner queue of a keyspace.
+	newQueue func() WaitingOperator
+	flows    map[int64]*keyspaceFlow
+	// vtime is the pass of the last served flow. A flow becoming busy starts
+	// from it, so that an idle keyspace can't accumulate the credits.
+	vtime float64
+	// mergeFlow is the flow of the first operator of a merge, where the
+	// second one is put.
+	mergeFlow *keyspaceFlow
+}
+
+// keyspaceFlow is the operators of a keyspace. The flow with the lowest pass
+// is served first, and the pass is increased by 1/weight after each operator
+// is promoted.
+type keyspaceFlow struct {
+	keyspace int64
+	pass     float64
+	waiting  int
+	queue    WaitingOperator
+}
+
+// NewKeyspaceFairQueue creates a keyspace fair queue.
+func NewKeyspaceFairQueue(keyspace func(op *operator.Operator) int64, weight func(keyspaceID uint32) float64, newQueue func() WaitingOperator) *KeyspaceFairQueue {
+	return &KeyspaceFairQueue{
+		keyspace: keyspace,
+		weight:   weight,
+		newQueue: newQueue,
+		flows:    make(map[int64]*keyspaceFlow),
+	}
+}
+
+// keyspaceLabel returns the keyspace in the metrics and the additional infos.
+func keyspaceLabel(keyspace int64) string {
+	if keyspace == noKeyspace {
+		return ""
+	}
+	return strconv.FormatInt(keyspace, 10)
+}
+
+// PutOperator puts an operator into the queue of its keyspace. The second
+// operator of a merge is put into the same queue of the first one.
+func (q *KeyspaceFairQueue) PutOperator(op *operator.Operator) {
+	if flow := q.mergeFlow; flow != nil && op.Kind()&operator.OpMerge != 0 {
+		q.mergeFlow = nil
+		flow.queue.PutOperator(op)
+		return
+	}
+	keyspace := q.keyspace(op)
+	flow := q.flows[keyspace]
+	if flow == nil {
+		flow = &keyspaceFlow{keyspace: keyspace, queue: q.newQueue()}
+		q.flows[keyspace] = flow
+	}
+	if flow.waiting == 0 && flow.pass < q.vtime {
+		flow.pass = q.vtime
+	}
+	flow.waiting++
+	flow.queue.PutOperator(op)
+	if op.Kind()&operator.OpMerge != 0 {
+		q.mergeFlow = flow
+	}
+}
+
+// GetOperator gets the operators from the keyspace with the lowest pass which
+// has an available operator.
+func (q *KeyspaceFairQueue) GetOperator() []*operator.Operator {
+	for _, flow := range q.busyFlows() {
+		ops := flow.queue.GetOperator()
+		if ops == nil {
+			continue
+		}
+		flow.waiting--
+		q.vtime = flow.pass
+		flow.pass += 1 / q.flowWeight(flow)
+		q.gc()
+		return ops
+	}
+	return nil
+}
+
+func (q *KeyspaceFairQueue) flowWeight(flow *keyspaceFlow) float64 {
+	if flow.keyspace == noKeyspace {
+		return 1
+	}
+	return q.weight(uint32(flow.keyspace))
+}
+
+// busyFlows returns the busy flows ordered by their passes.
+func (q *KeyspaceFairQueue) busyFlows() []*keyspaceFlow {
+	var flows []*keyspaceFlow
+	for _, flow := range q.flows {
+		if flow.waiting > 0 {
+			flows = append(flows, flow)
+		}
+	}
+	sort.Slice(flows, func(i, j int) bool {
+		if flows[i].pass != flows[j].pass {
+			return flows[i].pass < flows[j].pass
+		}
+		return flows[i].keyspace < flows[j].keyspace
+	})
+	return flows
+}
+
+// gc removes the idle flows whose pass is not ahead of the virtual time, as
+// they start from the virtual time anyway.
+func (q *KeyspaceFairQueue) gc() {
+	for keyspace, flow := range q.flows {
+		if flow.waiting == 0 && flow.pass <= q.vtime {
+			delete(q.flows, keyspace)
+		}
+	}
+}
+
+func (q *KeyspaceFairQueue) sortedFlows() []*keyspaceFlow {
+	flows := make([]*keyspaceFlow, 0, len(q.flows))
+	for _, flow := range q.flows {
+		flows = append(flows, flow)
+	}
+	sort.Slice(flows, func(i, j int) bool { return flows[i].keyspace < flows[j].keyspace })
+	return flows
+}
+
+// ListOperator lists all operators in the queue.
+func (q *KeyspaceFairQueue) ListOperator() []*operator.Operator {
+	var ops []*operator.Operator
+	for _, flow := range q.sortedFlows() {
+		ops = append(ops, flow.queue.ListOperator()...)
+	}
+	return ops
+}
+
+// WaitingKeyspaceStatus is the status of the waiting operators of a keyspace.
+// The keyspace is empty for the regions which don't belong to any keyspace.
+type WaitingKeyspaceStatus struct {
+	Keyspace string  `json:"keyspace"`
+	Weight   float64 `json:"weight"`
+	Waiting  int     `json:"waiting"`
+}
+
+// Status returns the status of the queues of the keyspaces.
+func (q *KeyspaceFairQueue) Status() []*WaitingKeyspaceStatus {
+	var res []*WaitingKeyspaceStatus
+	for _, flow := range q.sortedFlows() {
+		if flow.waiting == 0 {
+			continue
+		}
+		res = append(res, &WaitingKeyspaceStatus{
+			Keyspace: keyspaceLabel(flow.keyspace),
+			Weight:   q.flowWeight(flow),
+			Waiting:  flow.waiting,
+		})
+	}
+	return res
+}
@@ -0,0 +1,270 @@
+// Copyright 2021 TiKV Project Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package labeler
+
+import (
+	"bytes"
+	"encoding/hex"
+	"encoding/json"
+	"sort"
+	"sync"
+
+	"github.com/pingcap/log"
+	"github.com/tikv/pd/pkg/errs"
+	"github.com/tikv/pd/server/core"
+	"go.uber.org/zap"
+)
+
+const (
+	labelRulePath = "region_label"
+	// CostCenterLabel is the label key to attribute the scheduling traffic of
+	// the regions to a tenant. The operators of the regions carrying it are
+	// tagged in the metrics and the operator logs.
+	CostCenterLabel = "cost-center"
+	// SystemRangeLabel is the label key of the regions in the system ranges.
+	// The label rules carrying it are generated from the config.
+	SystemRangeLabel = "system-range"
+	// CheckerPriorityLabel is the label key of the checker priority class of
+	// the regions. The regions of a higher class are patrolled first, and
+	// repaired with the urgent operator priority.
+	CheckerPriorityLabel = "checker-priority"
+)
+
+// The checker priority classes, from the highest to the lowest. The regions
+// without the label are patrolled as usual.
+const (
+	// CheckerPriorityCritical makes the repairs of the regions bypass the
+	// replica schedule limit.
+	CheckerPriorityCritical = "critical"
+	CheckerPriorityHigh     = "high"
+)
+
+var checkerPriorityClasses = []string{CheckerPriorityCritical, CheckerPriorityHigh}
+
+// RegionLabel is a key-value label attached to the regions.
+type RegionLabel struct {
+	Key   string `json:"key"`
+	Value string `json:"value"`
+}
+
+// LabelRule attaches the labels to the regions in the key range. A region
+// carries the labels if it is fully covered by the range.
+type LabelRule struct {
+	ID          string        `json:"id"`
+	Labels      []RegionLabel `json:"labels"`
+	StartKeyHex string        `json:"start_key"`
+	EndKeyHex   string        `json:"end_key"`
+
+	startKey, endKey []byte
+}
+
+func (r *LabelRule) adjust() error {
+	if fieldErrs := r.validate(); len(fieldErrs) > 0 {
+		return errs.ErrRegionLabelRuleContent.FastGenByArgs(fieldErrs[0].String())
+	}
+	r.startKey, _ = hex.DecodeString(r.StartKeyHex)
+	r.endKey, _ = hex.DecodeString(r.EndKeyHex)
+	return nil
+}
+
+func (r *LabelRule) clone() *LabelRule {
+	nr := *r
+	nr.Labels = append(r.Labels[:0:0], r.Labels...)
+	return &nr
+}
+
+func (r *LabelRule) contains(region *core.RegionInfo) bool {
+	if bytes.Compare(region.GetStartKey(), r.startKey) < 0 {
+		return false
+	}
+	if len(r.endKey) == 0 {
+		return true
+	}
+	return len(region.GetEndKey()) > 0 && bytes.Compare(region.GetEndKey(), r.endKey) <= 0
+}
+
+// RegionLabeler maintains the label rules and looks up the labels of regions.
+type RegionLabeler struct {
+	sync.RWMutex
+	storage *core.Storage
+	rules   map[string]*LabelRule
+	// sorted is the rules sorted by ID, so that the overlapping rules are
+	// resolved in a stable order.
+	sorted []*LabelRule
+}
+
+// NewRegionLabeler creates a region labeler and loads the rules from storage.
+func NewRegionLabeler(storage *core.Storage) (*RegionLabeler, error) {
+	l := &RegionLabeler{
+		storage: storage,
+		rules:   make(map[string]*LabelRule),
+	}
+	if err := l.load(); err != nil {
+		return nil, err
+	}
+	return l, nil
+}
+
+func (l *RegionLabeler) load() error {
+	var toDelete []string
+	err := l.storage.LoadRangeByPrefix(labelRulePath+"/", func(k, v string) {
+		r := &LabelRule{}
+		if err := json.Unmarshal([]byte(v), r); err != nil {
+			log.Error("failed to unmarshal label rule value", zap.String("rule-key", k), errs.ZapError(errs.ErrJSONUnmarshal.Wrap(err).FastGenWithCause()))
+			toDelete = append(toDelete, k)
+			return
+		}
+		if err := r.adjust(); err != nil {
+			log.Error("label rule is in bad format", zap.String("rule-key", k), errs.ZapError(err))
+			toDelete = append(toDelete, k)
+			return
+		}
+		l.rules[r.ID] = r
+	})
+	if err != nil {
+		return err
+	}
+	for _, k := range toDelete {
+		if err := l.storage.Remove(labelRulePath + "/" + k); err != nil {
+			return err
+		}
+	}
+	l.buildSortedLocked()
+	return nil
+}
+
+func (l *RegionLabeler) buildSortedLocked() {
+	sorted := make([]*LabelRule, 0, len(l.rules))
+	for _, r := range l.rules {
+		sorted = append(sorted, r)
+	}
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i].ID < sorted[j].ID })
+	l.sorted = sorted
+}
+
+// GetLabelRule returns the rule with the given ID.
+func (l *RegionLabeler) GetLabelRule(id string) *LabelRule {
+	l.RLock()
+	defer l.RUnlock()
+	if r, ok := l.rules[id]; ok {
+		return r.clone()
+	}
+	return nil
+}
+
+// GetAllLabelRules returns all rules sorted by ID.
+func (l *RegionLabeler) GetAllLabelRules() []*LabelRule {
+	l.RLock()
+	defer l.RUnlock()
+	rules := make([]*LabelRule, 0, len(l.sorted))
+	for _, r := range l.sorted {
+		rules = append(rules, r.clone())
+	}
+	return rules
+}
+
+// SetLabelRule creates or updates a rule.
+func (l *RegionLabeler) SetLabelRule(rule *LabelRule) error {
+	if err := rule.adjust(); err != nil {
+		return err
+	}
+	rule = rule.clone()
+	l.Lock()
+	defer l.Unlock()
+	if err := l.storage.SaveJSON(labelRulePath, rule.ID, rule); err != nil {
+		return err
+	}
+	l.rules[rule.ID] = rule
+	l.buildSortedLocked()
+	return nil
+}
+
+// DeleteLabelRule removes a rule.
+func (l *RegionLabeler) DeleteLabelRule(id string) error {
+	l.Lock()
+	defer l.Unlock()
+	if _, ok := l.rules[id]; !ok {
+		return errs.ErrRegionLabelRuleNotFound.FastGenByArgs(id)
+	}
+	if err := l.storage.Remove(labelRulePath + "/" + id); err != nil {
+		return err
+	}
+	delete(l.rules, id)
+	l.buildSortedLocked()
+	return nil
+}
+
+// GetRegionLabel returns the value of the label of the region, or an empty
+// string if the region does not carry it. If several rules label the region
+// with the key, the one with the smallest ID wins.
+func (l *RegionLabeler) GetRegionLabel(region *core.RegionInfo, key string) string {
+	l.RLock()
+	defer l.RUnlock()
+	for _, r := range l.sorted {
+		if !r.contains(region) {
+			continue
+		}
+		for _, label := range r.Labels {
+			if label.Key == key {
+				return label.Value
+			}
+		}
+	}
+	return ""
+}
+
+// GetRegionLabels returns all labels of the region.
+func (l *RegionLabeler) GetRegionLabels(region *core.RegionInfo) []RegionLabel {
+	l.RLock()
+	defer l.RUnlock()
+	var labels []RegionLabel
+	seen := make(map[string]struct{})
+	for _, r := range l.sorted {
+		if !r.contains(region) {
+			continue
+		}
+		for _, label := range r.Labels {
+			if _, ok := seen[label.Key]; ok {
+				continue
+			}
+			seen[label.Key] = struct{}{}
+			labels = append(labels, label)
+		}
+	}
+	return labels
+}
+
+// PriorityRange is a key range labeled with a checker priority class.
+type PriorityRange struct {
+	Class            string
+	StartKey, EndKey []byte
+}
+
+// GetCheckerPriorityRanges returns the key ranges labeled with the checker
+// priority classes, the higher classes first.
+func (l *RegionLabeler) GetCheckerPriorityRanges() []*PriorityRange {
+	l.RLock()
+	defer l.RUnlock()
+	var ranges []*PriorityRange
+	for _, class := range checkerPriorityClasses {
+		for _, r := range l.sorted {
+			for _, label := range r.Labels {
+				if label.Key == CheckerPriorityLabel && label.Value == class {
+					ranges = append(ranges, &PriorityRange{Class: class, StartKey: r.startKey, EndKey: r.endKey})
+				}
+			}
+		}
+	}
+	return ranges
+}
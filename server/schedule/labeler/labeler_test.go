@@ -0,0 +1,156 @@
+// Copyright 2021 TiKV Project Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package labeler
+
+import (
+	"encoding/hex"
+	"testing"
+
+	. "github.com/pingcap/check"
+	"github.com/pingcap/kvproto/pkg/metapb"
+	"github.com/tikv/pd/server/core"
+	"github.com/tikv/pd/server/kv"
+)
+
+func TestLabeler(t *testing.T) {
+	TestingT(t)
+}
+
+var _ = Suite(&testLabelerSuite{})
+
+type testLabelerSuite struct{}
+
+func newRegion(start, end string) *core.RegionInfo {
+	return core.NewRegionInfo(&metapb.Region{Id: 1, StartKey: []byte(start), EndKey: []byte(end)}, nil)
+}
+
+func newRule(id, start, end string, labels ...RegionLabel) *LabelRule {
+	return &LabelRule{
+		ID:          id,
+		Labels:      labels,
+		StartKeyHex: hex.EncodeToString([]byte(start)),
+		EndKeyHex:   hex.EncodeToString([]byte(end)),
+	}
+}
+
+func (s *testLabelerSuite) TestValidation(c *C) {
+	l, err := NewRegionLabeler(core.NewStorage(kv.NewMemoryKV()))
+	c.Assert(err, IsNil)
+	label := RegionLabel{Key: CostCenterLabel, Value: "t1"}
+	for _, rule := range []*LabelRule{
+		newRule("", "a", "b", label),
+		newRule("r1", "a", "b"),
+		newRule("r1", "a", "b", RegionLabel{Key: CostCenterLabel}),
+		newRule("r1", "b", "a", label),
+		{ID: "r1", Labels: []RegionLabel{label}, StartKeyHex: "zz"},
+	} {
+		c.Assert(l.SetLabelRule(rule), NotNil)
+	}
+	c.Assert(l.GetAllLabelRules(), HasLen, 0)
+	c.Assert(l.DeleteLabelRule("r1"), NotNil)
+}
+
+func (s *testLabelerSuite) TestRegionLabel(c *C) {
+	storage := core.NewStorage(kv.NewMemoryKV())
+	l, err := NewRegionLabeler(storage)
+	c.Assert(err, IsNil)
+	c.Assert(l.SetLabelRule(newRule("r2", "a", "c", RegionLabel{Key: CostCenterLabel, Value: "t2"}, RegionLabel{Key: "k", Value: "v"})), IsNil)
+	c.Assert(l.SetLabelRule(newRule("r1", "b", "", RegionLabel{Key: CostCenterLabel, Value: "t1"})), IsNil)
+
+	c.Assert(l.GetRegionLabel(newRegion("a", "b"), CostCenterLabel), Equals, "t2")
+	// The rule with the smallest ID wins if the rules overlap.
+	c.Assert(l.GetRegionLabel(newRegion("b", "c"), CostCenterLabel), Equals, "t1")
+	c.Assert(l.GetRegionLabels(newRegion("b", "c")), DeepEquals, []RegionLabel{{Key: CostCenterLabel, Value: "t1"}, {Key: "k", Value: "v"}})
+	c.Assert(l.GetRegionLabel(newRegion("d", ""), CostCenterLabel), Equals, "t1")
+	// A region partially covered by a rule does not carry the labels.
+	c.Assert(l.GetRegionLabel(newRegion("", "b"), CostCenterLabel), Equals, "")
+	c.Assert(l.GetRegionLabel(newRegion("a", "b"), "unknown"), Equals, "")
+
+	// The rules are persisted.
+	l, err = NewRegionLabeler(storage)
+	c.Assert(err, IsNil)
+	rules := l.GetAllLabelRules()
+	c.Assert(rules, HasLen, 2)
+	c.Assert(rules[0].ID, Equals, "r1")
+	c.Assert(l.DeleteLabelRule("r1"), IsNil)
+	c.Assert(l.GetLabelRule("r1"), IsNil)
+	c.Assert(l.GetRegionLabel(newRegion("b", "c"), CostCenterLabel), Equals, "t2")
+}
+
+func (s *testLabelerSuite) TestDecodeLabelRule(c *C) {
+	rule, fieldErr := DecodeLabelRule([]byte(`{"id":"r1","labels":[{"key":"k","value":"v"}],"start_key":"","end_key":""}`))
+	c.Assert(fieldErr, IsNil)
+	c.Assert(rule.ID, Equals, "r1")
+	for _, t := range []struct {
+		data  string
+		field string
+	}{
+		{`{"id":"r1",}`, ""},
+		{`{"id":1}`, "id"},
+		{`{"id":"r1","labels":[{"key":"k","value":"v","foo":"bar"}]}`, "foo"},
+		{`{"id":"r1"} {}`, ""},
+	} {
+		_, fieldErr = DecodeLabelRule([]byte(t.data))
+		c.Assert(fieldErr, NotNil, Commentf("data: %s", t.data))
+		c.Assert(fieldErr.Field, Equals, t.field, Commentf("data: %s", t.data))
+		c.Assert(fieldErr.Offset, Greater, int64(0), Commentf("data: %s", t.data))
+	}
+}
+
+func (s *testLabelerSuite) TestCheckLabelRule(c *C) {
+	l, err := NewRegionLabeler(core.NewStorage(kv.NewMemoryKV()))
+	c.Assert(err, IsNil)
+	c.Assert(l.SetLabelRule(newRule("r1", "a", "c", RegionLabel{Key: CostCenterLabel, Value: "t1"})), IsNil)
+	c.Assert(l.SetLabelRule(newRule("r2", "e", "", RegionLabel{Key: CostCenterLabel, Value: "t2"})), IsNil)
+
+	// All the invalid fields are reported.
+	check := l.CheckLabelRule(newRule("r3", "b", "a", RegionLabel{Key: "k"}, RegionLabel{Key: "k", Value: "v"}))
+	c.Assert(check.Valid, IsFalse)
+	c.Assert(check.Errors, DeepEquals, []*FieldError{
+		{Field: "labels[0].value", Message: "should not be empty"},
+		{Field: "labels[1].key", Message: "duplicated key k"},
+	})
+	check = l.CheckLabelRule(newRule("r3", "b", "a", RegionLabel{Key: "k", Value: "v"}))
+	c.Assert(check.Errors, DeepEquals, []*FieldError{{Field: "end_key", Message: "should be greater than start_key"}})
+
+	// The rule conflicts with the overlapped rules labeling different values.
+	check = l.CheckLabelRule(newRule("r3", "b", "", RegionLabel{Key: CostCenterLabel, Value: "t2"}, RegionLabel{Key: "k", Value: "v"}))
+	c.Assert(check.Valid, IsFalse)
+	c.Assert(check.Errors, HasLen, 0)
+	c.Assert(check.Conflicts, DeepEquals, []*RuleConflict{{
+		ID: "r1", Key: CostCenterLabel, Value: "t1",
+		StartKeyHex: hex.EncodeToString([]byte("b")), EndKeyHex: hex.EncodeToString([]byte("c")),
+	}})
+	// Updating a rule does not conflict with itself.
+	c.Assert(l.CheckLabelRule(newRule("r1", "a", "", RegionLabel{Key: CostCenterLabel, Value: "t1"})).Conflicts, HasLen, 1)
+	c.Assert(l.CheckLabelRule(newRule("r1", "a", "d", RegionLabel{Key: CostCenterLabel, Value: "t3"})).Valid, IsTrue)
+	c.Assert(l.CheckLabelRule(newRule("r3", "c", "e", RegionLabel{Key: CostCenterLabel, Value: "t3"})).Valid, IsTrue)
+	// The conflicts are allowed by SetLabelRule.
+	c.Assert(l.SetLabelRule(newRule("r3", "b", "", RegionLabel{Key: CostCenterLabel, Value: "t2"})), IsNil)
+}
+
+func (s *testLabelerSuite) TestCheckerPriorityRanges(c *C) {
+	l, err := NewRegionLabeler(core.NewStorage(kv.NewMemoryKV()))
+	c.Assert(err, IsNil)
+	c.Assert(l.SetLabelRule(newRule("r1", "a", "b", RegionLabel{Key: CheckerPriorityLabel, Value: "unknown"})), NotNil)
+	c.Assert(l.SetLabelRule(newRule("r1", "a", "b", RegionLabel{Key: CheckerPriorityLabel, Value: CheckerPriorityHigh})), IsNil)
+	c.Assert(l.SetLabelRule(newRule("r2", "c", "", RegionLabel{Key: CheckerPriorityLabel, Value: CheckerPriorityCritical})), IsNil)
+	c.Assert(l.SetLabelRule(newRule("r3", "d", "e", RegionLabel{Key: CostCenterLabel, Value: "t1"})), IsNil)
+
+	// The higher classes come first.
+	c.Assert(l.GetCheckerPriorityRanges(), DeepEquals, []*PriorityRange{
+		{Class: CheckerPriorityCritical, StartKey: []byte("c"), EndKey: []byte{}},
+		{Class: CheckerPriorityHigh, StartKey: []byte("a"), EndKey: []byte("b")},
+	})
+}
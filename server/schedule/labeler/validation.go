@@ -0,0 +1,203 @@
+// Copyright 2021 TiKV Project Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package labeler
+
+import (
+	"bytes"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// FieldError describes an invalid field of a label rule.
+type FieldError struct {
+	// Field is the path of the field, such as `labels[0].key`. It is empty if
+	// the error is not about a field, such as a syntax error.
+	Field string `json:"field,omitempty"`
+	// Offset is the position in the input where the error occurs, which is
+	// only set for the decoding errors.
+	Offset  int64  `json:"offset,omitempty"`
+	Message string `json:"message"`
+}
+
+func (e *FieldError) String() string {
+	if e.Field == "" {
+		return e.Message
+	}
+	return e.Field + ": " + e.Message
+}
+
+// RuleConflict is an existing rule overlapping with the checked one, which
+// attaches a different value of the same label key to the regions in the
+// overlapped key range.
+type RuleConflict struct {
+	ID          string `json:"id"`
+	Key         string `json:"key"`
+	Value       string `json:"value"`
+	StartKeyHex string `json:"start_key"`
+	EndKeyHex   string `json:"end_key"`
+}
+
+// LabelRuleCheck is the result of checking a label rule.
+type LabelRuleCheck struct {
+	Valid     bool            `json:"valid"`
+	Errors    []*FieldError   `json:"errors,omitempty"`
+	Conflicts []*RuleConflict `json:"conflicts,omitempty"`
+}
+
+// DecodeLabelRule decodes a label rule strictly, which rejects the unknown
+// fields and the trailing data.
+func DecodeLabelRule(data []byte) (*LabelRule, *FieldError) {
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.DisallowUnknownFields()
+	rule := &LabelRule{}
+	if err := dec.Decode(rule); err != nil {
+		return nil, decodeError(err, dec.InputOffset())
+	}
+	if dec.More() {
+		return nil, &FieldError{Offset: dec.InputOffset(), Message: "unexpected data after the rule"}
+	}
+	return rule, nil
+}
+
+func decodeError(err error, offset int64) *FieldError {
+	var syntaxErr *json.SyntaxError
+	if errors.As(err, &syntaxErr) {
+		return &FieldError{Offset: syntaxErr.Offset, Message: syntaxErr.Error()}
+	}
+	var typeErr *json.UnmarshalTypeError
+	if errors.As(err, &typeErr) {
+		return &FieldError{
+			Field:   typeErr.Field,
+			Offset:  typeErr.Offset,
+			Message: fmt.Sprintf("should be %s instead of %s", typeErr.Type, typeErr.Value),
+		}
+	}
+	// The error of an unknown field is not typed.
+	if msg := err.Error(); strings.HasPrefix(msg, "json: unknown field ") {
+		return &FieldError{
+			Field:   strings.Trim(strings.TrimPrefix(msg, "json: unknown field "), `"`),
+			Offset:  offset,
+			Message: "unknown field",
+		}
+	}
+	return &FieldError{Offset: offset, Message: err.Error()}
+}
+
+// validate returns all the invalid fields of the rule.
+func (r *LabelRule) validate() []*FieldError {
+	var fieldErrs []*FieldError
+	add := func(field, format string, args ...interface{}) {
+		fieldErrs = append(fieldErrs, &FieldError{Field: field, Message: fmt.Sprintf(format, args...)})
+	}
+	if r.ID == "" {
+		add("id", "should not be empty")
+	}
+	if len(r.Labels) == 0 {
+		add("labels", "should not be empty")
+	}
+	for i, l := range r.Labels {
+		if l.Key == "" {
+			add(fmt.Sprintf("labels[%d].key", i), "should not be empty")
+		}
+		if l.Value == "" {
+			add(fmt.Sprintf("labels[%d].value", i), "should not be empty")
+		} else if l.Key == CheckerPriorityLabel && l.Value != CheckerPriorityCritical && l.Value != CheckerPriorityHigh {
+			add(fmt.Sprintf("labels[%d].value", i), "should be %s or %s", CheckerPriorityCritical, CheckerPriorityHigh)
+		}
+	}
+	startKey, err := hex.DecodeString(r.StartKeyHex)
+	if err != nil {
+		add("start_key", "should be a hex string")
+	}
+	endKey, err := hex.DecodeString(r.EndKeyHex)
+	if err != nil {
+		add("end_key", "should be a hex string")
+	}
+	if len(fieldErrs) == 0 && len(endKey) > 0 && bytes.Compare(endKey, startKey) <= 0 {
+		add("end_key", "should be greater than start_key")
+	}
+	return fieldErrs
+}
+
+// CheckLabelRule checks the rule without applying it. Besides the invalid
+// fields, it reports the duplicated label keys, and the existing rules which
+// attach different values of the same label keys to the overlapped ranges.
+// The conflicts do not make the rule invalid for SetLabelRule, where the rule
+// with the smallest ID wins.
+func (l *RegionLabeler) CheckLabelRule(rule *LabelRule) *LabelRuleCheck {
+	check := &LabelRuleCheck{Errors: rule.validate()}
+	seen := make(map[string]struct{}, len(rule.Labels))
+	for i, label := range rule.Labels {
+		if _, ok := seen[label.Key]; ok && label.Key != "" {
+			check.Errors = append(check.Errors, &FieldError{Field: fmt.Sprintf("labels[%d].key", i), Message: "duplicated key " + label.Key})
+		}
+		seen[label.Key] = struct{}{}
+	}
+	if len(check.Errors) == 0 {
+		check.Conflicts = l.getConflicts(rule)
+	}
+	check.Valid = len(check.Errors) == 0 && len(check.Conflicts) == 0
+	return check
+}
+
+func (l *RegionLabeler) getConflicts(rule *LabelRule) []*RuleConflict {
+	startKey, _ := hex.DecodeString(rule.StartKeyHex)
+	endKey, _ := hex.DecodeString(rule.EndKeyHex)
+	l.RLock()
+	defer l.RUnlock()
+	var conflicts []*RuleConflict
+	for _, r := range l.sorted {
+		if r.ID == rule.ID {
+			continue
+		}
+		start, end, ok := overlap(startKey, endKey, r.startKey, r.endKey)
+		if !ok {
+			continue
+		}
+		for _, label := range rule.Labels {
+			for _, old := range r.Labels {
+				if old.Key == label.Key && old.Value != label.Value {
+					conflicts = append(conflicts, &RuleConflict{
+						ID:          r.ID,
+						Key:         old.Key,
+						Value:       old.Value,
+						StartKeyHex: hex.EncodeToString(start),
+						EndKeyHex:   hex.EncodeToString(end),
+					})
+				}
+			}
+		}
+	}
+	return conflicts
+}
+
+// overlap returns the overlapped range of two ranges, where an empty end key
+// means the range is unbounded.
+func overlap(start1, end1, start2, end2 []byte) ([]byte, []byte, bool) {
+	start := start1
+	if bytes.Compare(start2, start1) > 0 {
+		start = start2
+	}
+	end := end1
+	if len(end1) == 0 || (len(end2) > 0 && bytes.Compare(end2, end1) < 0) {
+		end = end2
+	}
+	if len(end) > 0 && bytes.Compare(start, end) >= 0 {
+		return nil, nil, false
+	}
+	return start, end, true
+}
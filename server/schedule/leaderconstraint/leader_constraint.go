@@ -0,0 +1,120 @@
+// Copyright 2021 TiKV Project Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package leaderconstraint
+
+import (
+	"github.com/tikv/pd/server/config"
+	"github.com/tikv/pd/server/core"
+)
+
+// Status is the leader distribution of the stores of a leader constraint.
+type Status struct {
+	config.LeaderConstraint
+	LeaderCount      int     `json:"leader-count"`
+	TotalLeaderCount int     `json:"total-leader-count"`
+	Ratio            float64 `json:"ratio"`
+	Violated         bool    `json:"violated"`
+}
+
+func (s *Status) matchStore(store *core.StoreInfo) bool {
+	return store.GetLabelValue(s.LabelKey) == s.LabelValue
+}
+
+func (s *Status) update() {
+	s.Ratio = 0
+	if s.TotalLeaderCount > 0 {
+		s.Ratio = float64(s.LeaderCount) / float64(s.TotalLeaderCount)
+	}
+	s.Violated = s.Ratio > s.MaxRatio
+}
+
+// State is a snapshot of the leader distribution of all leader constraints.
+// A nil State has no constraint.
+type State struct {
+	statuses []*Status
+}
+
+// NewState creates a State of the constraints with the leader counts of the
+// stores. It returns nil if there is no constraint.
+func NewState(constraints []config.LeaderConstraint, stores []*core.StoreInfo) *State {
+	if len(constraints) == 0 {
+		return nil
+	}
+	var total int
+	for _, store := range stores {
+		if !store.IsTombstone() {
+			total += store.GetLeaderCount()
+		}
+	}
+	statuses := make([]*Status, 0, len(constraints))
+	for _, lc := range constraints {
+		status := &Status{LeaderConstraint: lc, TotalLeaderCount: total}
+		for _, store := range stores {
+			if !store.IsTombstone() && status.matchStore(store) {
+				status.LeaderCount += store.GetLeaderCount()
+			}
+		}
+		status.update()
+		statuses = append(statuses, status)
+	}
+	return &State{statuses: statuses}
+}
+
+// GetStatuses returns the statuses of the constraints.
+func (s *State) GetStatuses() []*Status {
+	if s == nil {
+		return nil
+	}
+	return s.statuses
+}
+
+// AllowTransfer returns whether a leader can be transferred from the source
+// store to the target store without exceeding the max ratio of any constraint.
+// The transfers within the stores of a constraint are always allowed.
+func (s *State) AllowTransfer(source, target *core.StoreInfo) bool {
+	for _, status := range s.GetStatuses() {
+		if !status.matchStore(target) || status.matchStore(source) {
+			continue
+		}
+		if float64(status.LeaderCount+1) > status.MaxRatio*float64(status.TotalLeaderCount) {
+			return false
+		}
+	}
+	return true
+}
+
+// IsViolated returns whether the store is in the stores of a violated
+// constraint. Only the hard constraints are considered if hardOnly is true.
+func (s *State) IsViolated(store *core.StoreInfo, hardOnly bool) bool {
+	for _, status := range s.GetStatuses() {
+		if status.Violated && (!hardOnly || status.IsHard()) && status.matchStore(store) {
+			return true
+		}
+	}
+	return false
+}
+
+// Transfer updates the state as if a leader is transferred from the source
+// store to the target store.
+func (s *State) Transfer(source, target *core.StoreInfo) {
+	for _, status := range s.GetStatuses() {
+		if status.matchStore(source) {
+			status.LeaderCount--
+		}
+		if status.matchStore(target) {
+			status.LeaderCount++
+		}
+		status.update()
+	}
+}
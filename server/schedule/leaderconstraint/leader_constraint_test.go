@@ -0,0 +1,78 @@
+// Copyright 2021 TiKV Project Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package leaderconstraint
+
+import (
+	"testing"
+
+	. "github.com/pingcap/check"
+	"github.com/tikv/pd/server/config"
+	"github.com/tikv/pd/server/core"
+)
+
+func TestLeaderConstraint(t *testing.T) {
+	TestingT(t)
+}
+
+var _ = Suite(&testLeaderConstraintSuite{})
+
+type testLeaderConstraintSuite struct{}
+
+func newTestStore(id uint64, zone string, leaderCount int) *core.StoreInfo {
+	return core.NewStoreInfoWithLabel(id, leaderCount, map[string]string{"zone": zone}).Clone(core.SetLeaderCount(leaderCount))
+}
+
+func (s *testLeaderConstraintSuite) TestState(c *C) {
+	c.Assert(NewState(nil, nil), IsNil)
+	var empty *State
+	c.Assert(empty.GetStatuses(), HasLen, 0)
+
+	z1 := newTestStore(1, "z1", 5)
+	z1b := newTestStore(2, "z1", 0)
+	z2 := newTestStore(3, "z2", 3)
+	z3 := newTestStore(4, "z3", 2)
+	stores := []*core.StoreInfo{z1, z1b, z2, z3}
+	c.Assert(empty.AllowTransfer(z2, z1), IsTrue)
+
+	constraints := []config.LeaderConstraint{
+		{LabelKey: "zone", LabelValue: "z1", MaxRatio: 0.4, Mode: config.LeaderConstraintHard},
+		{LabelKey: "zone", LabelValue: "z2", MaxRatio: 0.3},
+	}
+	state := NewState(constraints, stores)
+	statuses := state.GetStatuses()
+	c.Assert(statuses, HasLen, 2)
+	c.Assert(statuses[0].LeaderCount, Equals, 5)
+	c.Assert(statuses[0].TotalLeaderCount, Equals, 10)
+	c.Assert(statuses[0].Ratio, Equals, 0.5)
+	c.Assert(statuses[0].Violated, IsTrue)
+	c.Assert(statuses[1].Violated, IsFalse)
+
+	c.Assert(state.IsViolated(z1, true), IsTrue)
+	c.Assert(state.IsViolated(z1b, false), IsTrue)
+	c.Assert(state.IsViolated(z2, false), IsFalse)
+	// The leaders are not moved into the stores beyond the max ratio, except
+	// within the stores of the constraint.
+	c.Assert(state.AllowTransfer(z3, z1), IsFalse)
+	c.Assert(state.AllowTransfer(z1, z1b), IsTrue)
+	c.Assert(state.AllowTransfer(z1, z2), IsFalse)
+	c.Assert(state.AllowTransfer(z2, z3), IsTrue)
+
+	state.Transfer(z1, z3)
+	c.Assert(statuses[0].LeaderCount, Equals, 4)
+	c.Assert(statuses[0].Violated, IsFalse)
+	c.Assert(state.IsViolated(z1, false), IsFalse)
+	c.Assert(state.AllowTransfer(z3, z1), IsFalse)
+	state.Transfer(z1, z3)
+	c.Assert(state.AllowTransfer(z3, z1), IsTrue)
+}
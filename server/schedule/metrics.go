@@ -50,6 +50,54 @@ var (
 			Buckets:   prometheus.ExponentialBuckets(0.01, 2, 16),
 		}, []string{"type"})
 
+	operatorCostCenterCounter = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace: "pd",
+			Subsystem: "schedule",
+			Name:      "cost_center_operators_count",
+			Help:      "Counter of schedule operators by the cost center of the regions.",
+		}, []string{"cost_center", "type", "event"})
+
+	keyspaceOperatorCounter = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace: "pd",
+			Subsystem: "schedule",
+			Name:      "keyspace_operators_count",
+			Help:      "Counter of schedule operators by the keyspace of the regions.",
+		}, []string{"keyspace", "type", "event"})
+
+	keyspaceRunningOperatorGauge = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Namespace: "pd",
+			Subsystem: "schedule",
+			Name:      "keyspace_running_operators",
+			Help:      "Gauge of the running operators by the keyspace of the regions.",
+		}, []string{"keyspace"})
+
+	keyspaceMovedSizeCounter = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace: "pd",
+			Subsystem: "schedule",
+			Name:      "keyspace_moved_size",
+			Help:      "Counter of the size in MB of the data moved by the finished operators by the keyspace of the regions.",
+		}, []string{"keyspace"})
+
+	zoneTransferBytesCounter = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace: "pd",
+			Subsystem: "schedule",
+			Name:      "zone_transfer_bytes",
+			Help:      "Counter of the bytes moved between the zones by the finished operators.",
+		}, []string{"source_zone", "target_zone"})
+
+	operatorConflictCounter = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace: "pd",
+			Subsystem: "schedule",
+			Name:      "operator_conflicts_total",
+			Help:      "Counter of the operators which revert the recent ones on the same region.",
+		}, []string{"type", "reverted"})
+
 	storeLimitCostCounter = prometheus.NewCounterVec(
 		prometheus.CounterOpts{
 			Namespace: "pd",
@@ -73,14 +121,29 @@ var (
 			Name:      "scatter_distribution",
 			Help:      "Counter of the distribution in scatter.",
 		}, []string{"store", "is_leader", "engine"})
+
+	patrolBudgetExhaustedCounter = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace: "pd",
+			Subsystem: "schedule",
+			Name:      "patrol_budget_exhausted_total",
+			Help:      "Counter of the operators not created since the checker exhausts the budget of the patrol round.",
+		}, []string{"type"})
 )
 
 func init() {
 	prometheus.MustRegister(operatorCounter)
 	prometheus.MustRegister(operatorDuration)
+	prometheus.MustRegister(operatorCostCenterCounter)
+	prometheus.MustRegister(keyspaceOperatorCounter)
+	prometheus.MustRegister(keyspaceRunningOperatorGauge)
+	prometheus.MustRegister(keyspaceMovedSizeCounter)
+	prometheus.MustRegister(zoneTransferBytesCounter)
 	prometheus.MustRegister(operatorWaitDuration)
+	prometheus.MustRegister(operatorConflictCounter)
 	prometheus.MustRegister(storeLimitCostCounter)
 	prometheus.MustRegister(operatorWaitCounter)
 	prometheus.MustRegister(scatterCounter)
 	prometheus.MustRegister(scatterDistributionCounter)
+	prometheus.MustRegister(patrolBudgetExhaustedCounter)
 }
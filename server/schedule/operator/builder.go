@@ -58,6 +58,7 @@ type Builder struct {
 	// build flags
 	allowDemote       bool
 	useJointConsensus bool
+	parallelAdd       bool
 	lightWeight       bool
 	forceTargetLeader bool
 
@@ -310,6 +311,13 @@ func (b *Builder) EnableForceTargetLeader() *Builder {
 	return b
 }
 
+// EnableParallelAdd makes the learners added without waiting for each other
+// to catch up, if they are promoted with joint consensus.
+func (b *Builder) EnableParallelAdd() *Builder {
+	b.parallelAdd = true
+	return b
+}
+
 // Build creates the Operator.
 func (b *Builder) Build(kind OpKind) (*Operator, error) {
 	var brief string
@@ -463,12 +471,14 @@ func (b *Builder) buildStepsWithJointConsensus(kind OpKind) (OpKind, error) {
 	for _, add := range b.toAdd.IDs() {
 		peer := b.toAdd[add]
 		if !core.IsLearner(peer) {
+			// The promoted learners are waited to catch up before entering
+			// the joint state, so they can be added in parallel.
+			b.toPromote.Set(peer)
 			b.execAddPeer(&metapb.Peer{
 				Id:      peer.GetId(),
 				StoreId: peer.GetStoreId(),
 				Role:    metapb.PeerRole_Learner,
 			})
-			b.toPromote.Set(peer)
 		} else {
 			b.execAddPeer(peer)
 		}
@@ -652,7 +662,13 @@ func (b *Builder) execAddPeer(peer *metapb.Peer) {
 	if b.lightWeight {
 		b.steps = append(b.steps, AddLightLearner{ToStore: peer.GetStoreId(), PeerID: peer.GetId()})
 	} else {
-		b.steps = append(b.steps, AddLearner{ToStore: peer.GetStoreId(), PeerID: peer.GetId()})
+		_, promoted := b.toPromote[peer.GetStoreId()]
+		b.steps = append(b.steps, AddLearner{
+			ToStore:                peer.GetStoreId(),
+			PeerID:                 peer.GetId(),
+			SkipWaitingForSnapshot: b.parallelAdd && b.useJointConsensus && promoted,
+			SnapshotSource:         b.snapshotSource(peer.GetStoreId()),
+		})
 	}
 	if !core.IsLearner(peer) {
 		b.steps = append(b.steps, PromoteLearner{ToStore: peer.GetStoreId(), PeerID: peer.GetId()})
@@ -662,6 +678,41 @@ func (b *Builder) execAddPeer(peer *metapb.Peer) {
 	delete(b.toAdd, peer.GetStoreId())
 }
 
+// snapshotSource returns the store of a healthy follower in the same zone as
+// the target store, which is suggested to the leader to send the snapshot
+// from, so the snapshot does not cross the zones. It returns 0 if the leader
+// is in the same zone, or there is no such follower.
+func (b *Builder) snapshotSource(target uint64) uint64 {
+	key := b.cluster.GetOpts().GetZoneLabelKey()
+	if key == "" {
+		return 0
+	}
+	to, leader := b.cluster.GetStore(target), b.cluster.GetStore(b.currentLeaderStoreID)
+	if to == nil || leader == nil {
+		return 0
+	}
+	zone := to.GetLabelValue(key)
+	if zone == "" || leader.GetLabelValue(key) == zone {
+		return 0
+	}
+	for _, id := range b.originPeers.IDs() {
+		if id == b.currentLeaderStoreID || core.IsLearner(b.originPeers[id]) {
+			continue
+		}
+		if _, ok := b.unhealthyPeers[id]; ok {
+			continue
+		}
+		if _, ok := b.currentPeers[id]; !ok {
+			continue
+		}
+		store := b.cluster.GetStore(id)
+		if store != nil && store.IsUp() && !store.IsDisconnected() && store.GetLabelValue(key) == zone {
+			return id
+		}
+	}
+	return 0
+}
+
 func (b *Builder) execRemovePeer(peer *metapb.Peer) {
 	b.steps = append(b.steps, RemovePeer{FromStore: peer.GetStoreId(), PeerID: peer.GetId()})
 	delete(b.currentPeers, peer.GetStoreId())
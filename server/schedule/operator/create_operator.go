@@ -36,6 +36,17 @@ func CreateAddPeerOperator(desc string, cluster opt.Cluster, region *core.Region
 		Build(kind)
 }
 
+// CreateAddPeersOperator creates an operator that adds several new peers. The
+// learners are added in parallel and promoted together if joint consensus is
+// used.
+func CreateAddPeersOperator(desc string, cluster opt.Cluster, region *core.RegionInfo, peers []*metapb.Peer, kind OpKind) (*Operator, error) {
+	b := NewBuilder(desc, cluster, region).EnableParallelAdd()
+	for _, peer := range peers {
+		b.AddPeer(peer)
+	}
+	return b.Build(kind)
+}
+
 // CreatePromoteLearnerOperator creates an operator that promotes a learner.
 func CreatePromoteLearnerOperator(desc string, cluster opt.Cluster, region *core.RegionInfo, peer *metapb.Peer) (*Operator, error) {
 	return NewBuilder(desc, cluster, region).
@@ -128,6 +139,21 @@ func CreateSplitRegionOperator(desc string, region *core.RegionInfo, kind OpKind
 	return NewOperator(desc, brief, region.GetID(), region.GetRegionEpoch(), kind|OpSplit, step), nil
 }
 
+// CreateSplitRegionWithTransferLeaderOperator creates an operator that splits a region and then
+// transfers the leader of the half which keeps the region ID to the target store.
+func CreateSplitRegionWithTransferLeaderOperator(desc string, region *core.RegionInfo, kind OpKind, policy pdpb.CheckPolicy, keys [][]byte, targetStoreID uint64) (*Operator, error) {
+	split, err := CreateSplitRegionOperator(desc, region, kind, policy, keys)
+	if err != nil {
+		return nil, err
+	}
+	if region.GetStoreVoter(targetStoreID) == nil {
+		return nil, errors.Errorf("cannot transfer leader to store %d which has no voter of region %d", targetStoreID, region.GetID())
+	}
+	step := TransferLeader{FromStore: region.GetLeader().GetStoreId(), ToStore: targetStoreID}
+	brief := fmt.Sprintf("%s, then transfer leader to store %d", split.brief, targetStoreID)
+	return NewOperator(desc, brief, region.GetID(), region.GetRegionEpoch(), split.kind|OpLeader, split.steps[0], step), nil
+}
+
 // CreateMergeRegionOperator creates an operator that merge two region into one.
 func CreateMergeRegionOperator(desc string, cluster opt.Cluster, source *core.RegionInfo, target *core.RegionInfo, kind OpKind) ([]*Operator, error) {
 	if core.IsInJointState(source.GetPeers()...) || core.IsInJointState(target.GetPeers()...) {
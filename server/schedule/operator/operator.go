@@ -53,6 +53,13 @@ type Operator struct {
 	Counters         []prometheus.Counter
 	FinishedCounters []prometheus.Counter
 	AdditionalInfos  map[string]string
+
+	// owner is the name of the scheduler or the type of the checker which
+	// creates the operator.
+	owner string
+	// cancelRequested is set when the owner asks the operator to be canceled
+	// once it is safe to stop.
+	cancelRequested int32
 }
 
 // NewOperator creates a new operator.
@@ -105,6 +112,90 @@ func (o *Operator) SetDesc(desc string) {
 	o.desc = desc
 }
 
+// checkerOwnerPrefix is the prefix of the owners of the operators created by
+// the checkers, which keeps them apart from the names of the schedulers.
+const checkerOwnerPrefix = "checker/"
+
+// CheckerOwner returns the owner of the operators created by the checker.
+func CheckerOwner(checkerType string) string {
+	return checkerOwnerPrefix + checkerType
+}
+
+// IsCheckerOwner returns whether the owner is a checker.
+func IsCheckerOwner(owner string) bool {
+	return strings.HasPrefix(owner, checkerOwnerPrefix)
+}
+
+// SetOwner sets the name of the scheduler which creates the operator, or the
+// CheckerOwner of the checker.
+func (o *Operator) SetOwner(owner string) {
+	o.owner = owner
+}
+
+// Owner returns the name of the scheduler which creates the operator, or the
+// CheckerOwner of the checker.
+func (o *Operator) Owner() string {
+	return o.owner
+}
+
+// RequestCancel asks the operator to be canceled once it is safe to stop.
+func (o *Operator) RequestCancel() {
+	atomic.StoreInt32(&o.cancelRequested, 1)
+}
+
+// IsCancelRequested returns whether the operator is asked to be canceled.
+func (o *Operator) IsCancelRequested() bool {
+	return atomic.LoadInt32(&o.cancelRequested) == 1
+}
+
+// IsSafeToStop returns whether the operator can be stopped before the current
+// step. A started merge cannot be stopped, and neither can a region in joint
+// consensus, which has to finish leaving the joint state first.
+func (o *Operator) IsSafeToStop() bool {
+	if o.kind&OpMerge != 0 && o.HasStarted() {
+		return false
+	}
+	_, leaving := o.Step(o.CurrentStepIndex()).(ChangePeerV2Leave)
+	return !leaving
+}
+
+// UnpromotedLearners returns the stores of the learners which are added by the
+// finished steps and to be promoted by the unfinished ones. They need to be
+// rolled back if the operator is stopped.
+func (o *Operator) UnpromotedLearners() []uint64 {
+	current := o.CurrentStepIndex()
+	if current > len(o.steps) {
+		current = len(o.steps)
+	}
+	added := make(map[uint64]struct{})
+	for _, step := range o.steps[:current] {
+		switch s := step.(type) {
+		case AddLearner:
+			added[s.ToStore] = struct{}{}
+		case AddLightLearner:
+			added[s.ToStore] = struct{}{}
+		}
+	}
+	var stores []uint64
+	promote := func(storeID uint64) {
+		if _, ok := added[storeID]; ok {
+			stores = append(stores, storeID)
+			delete(added, storeID)
+		}
+	}
+	for _, step := range o.steps[current:] {
+		switch s := step.(type) {
+		case PromoteLearner:
+			promote(s.ToStore)
+		case ChangePeerV2Enter:
+			for _, pl := range s.PromoteLearners {
+				promote(pl.ToStore)
+			}
+		}
+	}
+	return stores
+}
+
 // AttachKind attaches an operator kind for the operator.
 func (o *Operator) AttachKind(kind OpKind) {
 	o.kind |= kind
@@ -191,6 +282,11 @@ func (o *Operator) CheckSuccess() bool {
 	return false
 }
 
+// CurrentStepIndex returns the index of the current step.
+func (o *Operator) CurrentStepIndex() int {
+	return int(atomic.LoadInt32(&o.currentStep))
+}
+
 // Cancel marks the operator canceled.
 func (o *Operator) Cancel() bool {
 	return o.status.To(CANCELED)
@@ -129,6 +129,15 @@ func (ap AddPeer) CheckSafety(region *core.RegionInfo) error {
 // AddLearner is an OpStep that adds a region learner peer.
 type AddLearner struct {
 	ToStore, PeerID uint64
+	// SkipWaitingForSnapshot makes the step finish once the learner is added,
+	// so that the following learners are added without waiting for it to
+	// catch up. It is used to add the learners in parallel, which are then
+	// promoted after all of them catch up.
+	SkipWaitingForSnapshot bool
+	// SnapshotSource is the store of the peer suggested to the leader to send
+	// the snapshot from, like a follower in the same zone as the learner. 0
+	// means the leader sends the snapshot.
+	SnapshotSource uint64
 }
 
 // ConfVerChanged returns the delta value for version increased by this step.
@@ -138,6 +147,9 @@ func (al AddLearner) ConfVerChanged(region *core.RegionInfo) uint64 {
 }
 
 func (al AddLearner) String() string {
+	if al.SnapshotSource != 0 {
+		return fmt.Sprintf("add learner peer %v on store %v with snapshot from store %v", al.PeerID, al.ToStore, al.SnapshotSource)
+	}
 	return fmt.Sprintf("add learner peer %v on store %v", al.PeerID, al.ToStore)
 }
 
@@ -148,7 +160,7 @@ func (al AddLearner) IsFinish(region *core.RegionInfo) bool {
 			log.Warn("obtain unexpected peer", zap.String("expect", al.String()), zap.Uint64("obtain-learner", peer.GetId()))
 			return false
 		}
-		return region.GetPendingLearner(peer.GetId()) == nil
+		return al.SkipWaitingForSnapshot || region.GetPendingLearner(peer.GetId()) == nil
 	}
 	return false
 }
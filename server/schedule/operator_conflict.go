@@ -0,0 +1,257 @@
+// Copyright 2021 TiKV Project Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package schedule
+
+import (
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/pingcap/log"
+	"github.com/tikv/pd/server/schedule/operator"
+	"go.uber.org/zap"
+)
+
+var (
+	// OperatorConflictWindow is how long a finished operator is remembered to
+	// detect the operators which revert it.
+	OperatorConflictWindow = 10 * time.Minute
+	// OperatorConflictThreshold is the number of the conflicts in the window
+	// which make a region oscillate.
+	OperatorConflictThreshold = 2
+	// OperatorConflictBackoffTime is how long the operators reverting the
+	// recent ones are rejected once a region oscillates.
+	OperatorConflictBackoffTime = 10 * time.Minute
+)
+
+// OperatorConflictSource is a pair of the operators which conflict.
+type OperatorConflictSource struct {
+	// Operator is the description of the operator which reverts the other.
+	Operator string `json:"operator"`
+	Reverted string `json:"reverted"`
+	Count    int    `json:"count"`
+}
+
+// OperatorConflict is the conflicts of the operators on a region.
+type OperatorConflict struct {
+	RegionID uint64 `json:"region_id"`
+	// Count is the number of the conflicts in the window.
+	Count        int                       `json:"count"`
+	Sources      []*OperatorConflictSource `json:"sources"`
+	LastTime     time.Time                 `json:"last_time"`
+	BackoffUntil time.Time                 `json:"backoff_until,omitempty"`
+}
+
+// peerMove is the peers added and removed by a finished operator.
+type peerMove struct {
+	desc    string
+	time    time.Time
+	added   []uint64
+	removed []uint64
+}
+
+func newPeerMove(op *operator.Operator, now time.Time) *peerMove {
+	m := &peerMove{desc: op.Desc(), time: now}
+	for i := 0; i < op.Len(); i++ {
+		switch step := op.Step(i).(type) {
+		case operator.AddPeer:
+			m.added = append(m.added, step.ToStore)
+		case operator.AddLearner:
+			m.added = append(m.added, step.ToStore)
+		case operator.AddLightPeer:
+			m.added = append(m.added, step.ToStore)
+		case operator.AddLightLearner:
+			m.added = append(m.added, step.ToStore)
+		case operator.RemovePeer:
+			m.removed = append(m.removed, step.FromStore)
+		}
+	}
+	return m
+}
+
+func (m *peerMove) isEmpty() bool {
+	return len(m.added) == 0 && len(m.removed) == 0
+}
+
+// reverts returns whether the move adds a peer the other has removed, or
+// removes a peer the other has added.
+func (m *peerMove) reverts(other *peerMove) bool {
+	return containsAny(m.added, other.removed) || containsAny(m.removed, other.added)
+}
+
+func containsAny(a, b []uint64) bool {
+	for _, x := range a {
+		for _, y := range b {
+			if x == y {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+type conflictEvent struct {
+	time     time.Time
+	operator string
+	reverted string
+}
+
+type regionConflicts struct {
+	moves        []*peerMove
+	events       []*conflictEvent
+	backoffUntil time.Time
+}
+
+// prune drops the moves and the conflicts out of the window, and returns
+// whether there is nothing left.
+func (r *regionConflicts) prune(now time.Time) bool {
+	i := 0
+	for i < len(r.moves) && now.Sub(r.moves[i].time) > OperatorConflictWindow {
+		i++
+	}
+	r.moves = r.moves[i:]
+	i = 0
+	for i < len(r.events) && now.Sub(r.events[i].time) > OperatorConflictWindow {
+		i++
+	}
+	r.events = r.events[i:]
+	return len(r.moves) == 0 && len(r.events) == 0 && now.After(r.backoffUntil)
+}
+
+// conflictDetector detects the operators on a region which revert each other,
+// such as a checker adding back the peer a scheduler has just removed. Once a
+// region oscillates, the operators reverting the recent ones are backed off.
+type conflictDetector struct {
+	sync.RWMutex
+	regions map[uint64]*regionConflicts
+}
+
+func newConflictDetector() *conflictDetector {
+	return &conflictDetector{regions: make(map[uint64]*regionConflicts)}
+}
+
+// onFinish records the finished operator, and the conflicts between it and the
+// recent ones.
+func (d *conflictDetector) onFinish(op *operator.Operator) {
+	now := time.Now()
+	move := newPeerMove(op, now)
+	if move.isEmpty() {
+		return
+	}
+	d.Lock()
+	defer d.Unlock()
+	regionID := op.RegionID()
+	r, ok := d.regions[regionID]
+	if !ok {
+		r = &regionConflicts{}
+		d.regions[regionID] = r
+	}
+	r.prune(now)
+	for _, m := range r.moves {
+		if !move.reverts(m) {
+			continue
+		}
+		r.events = append(r.events, &conflictEvent{time: now, operator: move.desc, reverted: m.desc})
+		operatorConflictCounter.WithLabelValues(move.desc, m.desc).Inc()
+		log.Info("operator reverts the recent one",
+			zap.Uint64("region-id", regionID),
+			zap.String("operator", move.desc),
+			zap.String("reverted", m.desc))
+	}
+	r.moves = append(r.moves, move)
+	if len(r.events) >= OperatorConflictThreshold && now.After(r.backoffUntil) {
+		r.backoffUntil = now.Add(OperatorConflictBackoffTime)
+		log.Warn("operators oscillate on region, back off",
+			zap.Uint64("region-id", regionID),
+			zap.Int("conflicts", len(r.events)),
+			zap.Time("until", r.backoffUntil))
+	}
+}
+
+// isBackedOff returns whether the operator reverts a recent one on a region
+// which oscillates. The operators created by the admin are never backed off.
+func (d *conflictDetector) isBackedOff(op *operator.Operator) bool {
+	if op.Kind()&operator.OpAdmin != 0 {
+		return false
+	}
+	d.RLock()
+	defer d.RUnlock()
+	r, ok := d.regions[op.RegionID()]
+	if !ok {
+		return false
+	}
+	now := time.Now()
+	if now.After(r.backoffUntil) {
+		return false
+	}
+	move := newPeerMove(op, now)
+	for _, m := range r.moves {
+		if now.Sub(m.time) <= OperatorConflictWindow && move.reverts(m) {
+			return true
+		}
+	}
+	return false
+}
+
+func (d *conflictDetector) prune() {
+	d.Lock()
+	defer d.Unlock()
+	now := time.Now()
+	for regionID, r := range d.regions {
+		if r.prune(now) {
+			delete(d.regions, regionID)
+		}
+	}
+}
+
+func (d *conflictDetector) list() []*OperatorConflict {
+	d.RLock()
+	defer d.RUnlock()
+	now := time.Now()
+	var conflicts []*OperatorConflict
+	for regionID, r := range d.regions {
+		c := &OperatorConflict{RegionID: regionID}
+		sources := make(map[[2]string]*OperatorConflictSource)
+		for _, e := range r.events {
+			if now.Sub(e.time) > OperatorConflictWindow {
+				continue
+			}
+			c.Count++
+			c.LastTime = e.time
+			key := [2]string{e.operator, e.reverted}
+			s, ok := sources[key]
+			if !ok {
+				s = &OperatorConflictSource{Operator: e.operator, Reverted: e.reverted}
+				sources[key] = s
+				c.Sources = append(c.Sources, s)
+			}
+			s.Count++
+		}
+		if c.Count == 0 {
+			continue
+		}
+		if now.Before(r.backoffUntil) {
+			c.BackoffUntil = r.backoffUntil
+		}
+		conflicts = append(conflicts, c)
+	}
+	sort.Slice(conflicts, func(i, j int) bool { return conflicts[i].RegionID < conflicts[j].RegionID })
+	return conflicts
+}
+
+// GetOperatorConflicts returns the regions on which the operators have
+// reverted each other recently.
+func (oc *OperatorController) GetOperatorConflicts() []*OperatorConflict {
+	return oc.conflicts.list()
+}
@@ -22,16 +22,20 @@ import (
 	"sync"
 	"time"
 
+	"github.com/gogo/protobuf/proto"
 	"github.com/pingcap/failpoint"
 	"github.com/pingcap/kvproto/pkg/eraftpb"
 	"github.com/pingcap/kvproto/pkg/metapb"
 	"github.com/pingcap/kvproto/pkg/pdpb"
 	"github.com/pingcap/log"
 	"github.com/tikv/pd/pkg/cache"
+	"github.com/tikv/pd/pkg/codec"
 	"github.com/tikv/pd/pkg/errs"
+	"github.com/tikv/pd/server/config"
 	"github.com/tikv/pd/server/core"
 	"github.com/tikv/pd/server/core/storelimit"
 	"github.com/tikv/pd/server/schedule/hbstream"
+	"github.com/tikv/pd/server/schedule/labeler"
 	"github.com/tikv/pd/server/schedule/operator"
 	"github.com/tikv/pd/server/schedule/opt"
 	"go.uber.org/zap"
@@ -54,6 +58,8 @@ var (
 	StoreBalanceBaseTime float64 = 60
 	// FastOperatorFinishTime min finish time, if finish duration less than it,op will be pushed to fast operator queue
 	FastOperatorFinishTime = 10 * time.Second
+	// TransferLeaderBackoffTime is the time that an unready target is not retried to transfer the region's leader to.
+	TransferLeaderBackoffTime = time.Minute
 )
 
 // OperatorController is used to limit the speed of scheduling.
@@ -71,6 +77,15 @@ type OperatorController struct {
 	wop             WaitingOperator
 	wopStatus       *WaitingOperatorStatus
 	opNotifierQueue operatorQueue
+	// unreadyTargets records the region and store pairs which are backed off
+	// to transfer leader to.
+	unreadyTargets *cache.TTLString
+	conflicts      *conflictDetector
+	intents        *PlacementIntents
+	// snapshotHints is the snapshot sources suggested to the leaders.
+	snapshotHints *snapshotHints
+	// keyspaceFair and storeFair are the kinds of the queues in wop.
+	keyspaceFair, storeFair bool
 }
 
 // NewOperatorController creates a OperatorController.
@@ -88,6 +103,10 @@ func NewOperatorController(ctx context.Context, cluster opt.Cluster, hbStreams *
 		wop:             NewRandBuckets(),
 		wopStatus:       NewWaitingOperatorStatus(),
 		opNotifierQueue: make(operatorQueue, 0),
+		unreadyTargets:  cache.NewStringTTL(ctx, time.Minute, TransferLeaderBackoffTime),
+		conflicts:       newConflictDetector(),
+		intents:         NewPlacementIntents(ctx),
+		snapshotHints:   newSnapshotHints(),
 	}
 }
 
@@ -97,6 +116,12 @@ func (oc *OperatorController) Ctx() context.Context {
 	return oc.ctx
 }
 
+// GetPlacementIntents returns the distributions of the regions scattered
+// recently.
+func (oc *OperatorController) GetPlacementIntents() *PlacementIntents {
+	return oc.intents
+}
+
 // GetCluster exports cluster to evict-scheduler for check store status.
 func (oc *OperatorController) GetCluster() opt.Cluster {
 	oc.RLock()
@@ -115,14 +140,25 @@ func (oc *OperatorController) Dispatch(region *core.RegionInfo, source string) {
 		// Update operator status:
 		// The operator status should be STARTED.
 		// Check will call CheckSuccess and CheckTimeout.
+		stepIndex := op.CurrentStepIndex()
 		step := op.Check(region)
+		// The command of the previous step is executed if the step is finished.
+		if op.CurrentStepIndex() > stepIndex && oc.hbStreams != nil {
+			oc.hbStreams.AckMsg(region.GetID())
+		}
 
 		switch op.Status() {
 		case operator.STARTED:
+			if oc.stopCanceledOperator(op, region) {
+				return
+			}
 			operatorCounter.WithLabelValues(op.Desc(), "check").Inc()
 			if source == DispatchFromHeartBeat && oc.checkStaleOperator(op, step, region) {
 				return
 			}
+			if oc.checkUnreadyTarget(op, step, region) {
+				return
+			}
 			oc.SendScheduleCommand(region, step, source)
 		case operator.SUCCESS:
 			oc.pushHistory(op)
@@ -194,6 +230,73 @@ func (oc *OperatorController) checkStaleOperator(op *operator.Operator, step ope
 	return false
 }
 
+// checkUnreadyTarget checks if the target of the transfer leader step is
+// ready, and cancels the operator if not. The target is backed off for a
+// while to avoid being retried soon.
+func (oc *OperatorController) checkUnreadyTarget(op *operator.Operator, step operator.OpStep, region *core.RegionInfo) bool {
+	tl, ok := step.(operator.TransferLeader)
+	if !ok || !oc.cluster.GetOpts().IsTransferLeaderReadyCheckEnabled() {
+		return false
+	}
+	reason := oc.getUnreadyReason(region, tl.ToStore)
+	if reason == "" {
+		return false
+	}
+	op.AdditionalInfos["reject-reason"] = reason
+	oc.unreadyTargets.Put(unreadyTargetKey(region.GetID(), tl.ToStore), reason)
+	if oc.RemoveOperator(op, zap.String("reason", reason)) {
+		operatorCounter.WithLabelValues(op.Desc(), "unready-target").Inc()
+		operatorWaitCounter.WithLabelValues(op.Desc(), "promote-unready-target").Inc()
+		oc.PromoteWaitingOperator()
+	}
+	return true
+}
+
+// getUnreadyReason returns why the peer on the store is not ready to become
+// the leader, or an empty string if it is ready.
+func (oc *OperatorController) getUnreadyReason(region *core.RegionInfo, storeID uint64) string {
+	peer := region.GetStorePeer(storeID)
+	if peer == nil {
+		return "target peer does not exist"
+	}
+	if region.GetPendingPeer(peer.GetId()) != nil {
+		return "target peer is pending"
+	}
+	if region.GetDownPeer(peer.GetId()) != nil {
+		return "target peer is down"
+	}
+	if store := oc.cluster.GetStore(storeID); store == nil || store.IsDisconnected() {
+		return "target store is disconnected"
+	}
+	return ""
+}
+
+func unreadyTargetKey(regionID, storeID uint64) string {
+	return fmt.Sprintf("%d-%d", regionID, storeID)
+}
+
+// hasUnreadyTarget checks if the operator transfers leader to any target
+// which is backed off, or its first step transfers leader to an unready target.
+func (oc *OperatorController) hasUnreadyTarget(op *operator.Operator, region *core.RegionInfo) bool {
+	if !oc.cluster.GetOpts().IsTransferLeaderReadyCheckEnabled() {
+		return false
+	}
+	if tl, ok := op.Step(0).(operator.TransferLeader); ok {
+		if reason := oc.getUnreadyReason(region, tl.ToStore); reason != "" {
+			oc.unreadyTargets.Put(unreadyTargetKey(region.GetID(), tl.ToStore), reason)
+			return true
+		}
+	}
+	for i := 0; i < op.Len(); i++ {
+		if tl, ok := op.Step(i).(operator.TransferLeader); ok {
+			if _, ok := oc.unreadyTargets.Get(unreadyTargetKey(op.RegionID(), tl.ToStore)); ok {
+				return true
+			}
+		}
+	}
+	return false
+}
+
 func (oc *OperatorController) getNextPushOperatorTime(step operator.OpStep, now time.Time) time.Time {
 	nextTime := slowNotifyInterval
 	switch step.(type) {
@@ -261,9 +364,51 @@ func (oc *OperatorController) PushOperators() {
 	}
 }
 
+// syncWaitingQueueLocked switches the implementation of the waiting operators
+// if the keyspace fair queue or the store fair queue is enabled or disabled,
+// and moves the waiting operators to the new one.
+func (oc *OperatorController) syncWaitingQueueLocked() {
+	opts := oc.cluster.GetOpts()
+	keyspaceFair, storeFair := opts.IsKeyspaceFairQueueEnabled(), opts.IsStoreFairQueueEnabled()
+	if keyspaceFair == oc.keyspaceFair && storeFair == oc.storeFair {
+		return
+	}
+	var wop WaitingOperator
+	if keyspaceFair {
+		wop = NewKeyspaceFairQueue(oc.getKeyspace, func(keyspaceID uint32) float64 {
+			return oc.cluster.GetOpts().GetKeyspaceWeight(keyspaceID)
+		}, func() WaitingOperator { return oc.newWaitingQueue(storeFair) })
+	} else {
+		wop = oc.newWaitingQueue(storeFair)
+	}
+	for _, op := range oc.wop.ListOperator() {
+		wop.PutOperator(op)
+	}
+	oc.wop = wop
+	oc.keyspaceFair, oc.storeFair = keyspaceFair, storeFair
+}
+
+// newWaitingQueue creates the queue which orders the waiting operators.
+func (oc *OperatorController) newWaitingQueue(storeFair bool) WaitingOperator {
+	if !storeFair {
+		return NewRandBuckets()
+	}
+	return NewStoreFairQueue(func(ops []*operator.Operator) bool {
+		// The expired operators are returned to be canceled, so that they
+		// don't occupy the waiting quota of their schedulers.
+		for _, op := range ops {
+			if op.ElapsedTime() >= operator.OperatorExpireTime {
+				return true
+			}
+		}
+		return !oc.exceedStoreLimitLocked(ops...)
+	})
+}
+
 // AddWaitingOperator adds operators to waiting operators.
 func (oc *OperatorController) AddWaitingOperator(ops ...*operator.Operator) int {
 	oc.Lock()
+	oc.syncWaitingQueueLocked()
 	added := 0
 
 	for i := 0; i < len(ops); i++ {
@@ -340,6 +485,7 @@ func (oc *OperatorController) AddOperator(ops ...*operator.Operator) bool {
 func (oc *OperatorController) PromoteWaitingOperator() {
 	oc.Lock()
 	defer oc.Unlock()
+	oc.syncWaitingQueueLocked()
 	var ops []*operator.Operator
 	for {
 		// GetOperator returns one operator or two merge operators
@@ -385,6 +531,13 @@ func (oc *OperatorController) checkAddOperator(ops ...*operator.Operator) bool {
 			operatorWaitCounter.WithLabelValues(op.Desc(), "not-found").Inc()
 			return false
 		}
+		if op.IsCancelRequested() {
+			log.Debug("operator is canceled by its owner, cancel add operator",
+				zap.Uint64("region-id", op.RegionID()),
+				zap.String("owner", op.Owner()))
+			operatorWaitCounter.WithLabelValues(op.Desc(), "owner-canceled").Inc()
+			return false
+		}
 		if region.GetRegionEpoch().GetVersion() != op.RegionEpoch().GetVersion() ||
 			region.GetRegionEpoch().GetConfVer() != op.RegionEpoch().GetConfVer() {
 			log.Debug("region epoch not match, cancel add operator",
@@ -401,6 +554,36 @@ func (oc *OperatorController) checkAddOperator(ops ...*operator.Operator) bool {
 			operatorWaitCounter.WithLabelValues(op.Desc(), "already-have").Inc()
 			return false
 		}
+		if isHalted(oc.cluster, op) {
+			log.Debug("scheduling is halted, cancel add operator",
+				zap.Uint64("region-id", op.RegionID()))
+			operatorWaitCounter.WithLabelValues(op.Desc(), "halted").Inc()
+			return false
+		}
+		if oc.isSuppressedByKeyRangeLock(op, region) {
+			log.Debug("key range is locked, cancel add operator",
+				zap.Uint64("region-id", op.RegionID()))
+			operatorWaitCounter.WithLabelValues(op.Desc(), "key-range-locked").Inc()
+			return false
+		}
+		if isSuppressedBySystemRange(oc.cluster, op, region) {
+			log.Debug("region is in a system range, cancel add operator",
+				zap.Uint64("region-id", op.RegionID()))
+			operatorWaitCounter.WithLabelValues(op.Desc(), "system-range").Inc()
+			return false
+		}
+		if oc.hasUnreadyTarget(op, region) {
+			log.Debug("transfer leader to an unready target, cancel add operator",
+				zap.Uint64("region-id", op.RegionID()))
+			operatorWaitCounter.WithLabelValues(op.Desc(), "unready-target").Inc()
+			return false
+		}
+		if oc.conflicts.isBackedOff(op) {
+			log.Debug("operators oscillate on region, cancel add operator",
+				zap.Uint64("region-id", op.RegionID()))
+			operatorWaitCounter.WithLabelValues(op.Desc(), "conflict-backoff").Inc()
+			return false
+		}
 		if op.Status() != operator.CREATED {
 			log.Error("trying to add operator with unexpected status",
 				zap.Uint64("region-id", op.RegionID()),
@@ -428,12 +611,27 @@ func (oc *OperatorController) checkAddOperator(ops ...*operator.Operator) bool {
 	return !expired
 }
 
+// isHalted returns whether the operator is rejected because the scheduling is
+// halted. Only the operators added by the admin are allowed in pause-new.
+func isHalted(cluster opt.Cluster, op *operator.Operator) bool {
+	switch cluster.GetOpts().GetHaltScheduling() {
+	case "":
+		return false
+	case config.HaltPauseNew:
+		return op.Kind()&operator.OpAdmin == 0
+	default:
+		return true
+	}
+}
+
 func isHigherPriorityOperator(new, old *operator.Operator) bool {
 	return new.GetPriorityLevel() > old.GetPriorityLevel()
 }
 
 func (oc *OperatorController) addOperatorLocked(op *operator.Operator) bool {
 	regionID := op.RegionID()
+	oc.tagCostCenter(op)
+	oc.tagKeyspace(op)
 
 	log.Info("add operator",
 		zap.Uint64("region-id", regionID),
@@ -497,6 +695,94 @@ func (oc *OperatorController) addOperatorLocked(op *operator.Operator) bool {
 	return true
 }
 
+// CancelOperatorsByOwner asks the operators created by the scheduler to be
+// canceled gracefully, and returns the number of them. The waiting ones are
+// dropped when they are promoted. The running ones are stopped once it is
+// safe to stop them, and the learners they have added for promotion are
+// removed.
+func (oc *OperatorController) CancelOperatorsByOwner(owner string) int {
+	canceled := oc.cancelOperators(func(op *operator.Operator) bool { return op.Owner() == owner })
+	if canceled > 0 {
+		log.Info("operators are canceled by their owner", zap.String("owner", owner), zap.Int("count", canceled))
+	}
+	return canceled
+}
+
+// CancelAllOperators asks all the waiting and running operators to be
+// canceled gracefully like CancelOperatorsByOwner, and returns the number of
+// them.
+func (oc *OperatorController) CancelAllOperators() int {
+	canceled := oc.cancelOperators(func(op *operator.Operator) bool { return !op.IsCancelRequested() })
+	if canceled > 0 {
+		log.Info("all operators are canceled", zap.Int("count", canceled))
+	}
+	return canceled
+}
+
+func (oc *OperatorController) cancelOperators(match func(op *operator.Operator) bool) int {
+	oc.RLock()
+	var running []*operator.Operator
+	for _, op := range oc.operators {
+		if match(op) {
+			running = append(running, op)
+		}
+	}
+	canceled := len(running)
+	for _, op := range oc.wop.ListOperator() {
+		if match(op) {
+			op.RequestCancel()
+			canceled++
+		}
+	}
+	oc.RUnlock()
+
+	for _, op := range running {
+		op.RequestCancel()
+		oc.stopCanceledOperator(op, oc.cluster.GetRegion(op.RegionID()))
+	}
+	return canceled
+}
+
+// stopCanceledOperator stops the running operator if it is asked to be
+// canceled and is safe to stop, and returns whether it is stopped.
+func (oc *OperatorController) stopCanceledOperator(op *operator.Operator, region *core.RegionInfo) bool {
+	if !op.IsCancelRequested() || !op.IsSafeToStop() {
+		return false
+	}
+	if !oc.RemoveOperator(op, zap.String("reason", "canceled by its owner")) {
+		return false
+	}
+	operatorCounter.WithLabelValues(op.Desc(), "owner-canceled").Inc()
+	if region != nil {
+		oc.rollbackLearners(op, region)
+	}
+	oc.PromoteWaitingOperator()
+	return true
+}
+
+// rollbackLearners removes the learners which are added by the stopped
+// operator but not promoted yet.
+func (oc *OperatorController) rollbackLearners(op *operator.Operator, region *core.RegionInfo) {
+	b := operator.NewBuilder("rollback-"+op.Desc(), oc.cluster, region)
+	rollback := false
+	for _, storeID := range op.UnpromotedLearners() {
+		if region.GetStoreLearner(storeID) != nil {
+			b.RemovePeer(storeID)
+			rollback = true
+		}
+	}
+	if !rollback {
+		return
+	}
+	rop, err := b.Build(operator.OpRegion)
+	if err != nil {
+		log.Warn("failed to roll back the learners of the canceled operator",
+			zap.Uint64("region-id", op.RegionID()), errs.ZapError(err))
+		return
+	}
+	oc.AddOperator(rop)
+}
+
 // RemoveOperator removes a operator from the running operators.
 func (oc *OperatorController) RemoveOperator(op *operator.Operator, extraFields ...zap.Field) bool {
 	oc.Lock()
@@ -524,6 +810,11 @@ func (oc *OperatorController) removeOperatorLocked(op *operator.Operator) bool {
 	regionID := op.RegionID()
 	if cur := oc.operators[regionID]; cur == op {
 		delete(oc.operators, regionID)
+		// The command of the removed operator should not be redelivered.
+		if oc.hbStreams != nil {
+			oc.hbStreams.ClearMsg(regionID)
+		}
+		oc.snapshotHints.remove(regionID)
 		oc.updateCounts(oc.operators)
 		operatorCounter.WithLabelValues(op.Desc(), "remove").Inc()
 		return true
@@ -554,28 +845,39 @@ func (oc *OperatorController) buryOperator(op *operator.Operator, extraFields ..
 			zap.Reflect("operator", op),
 			zap.String("additional-info", op.GetAdditionalInfo()))
 		operatorCounter.WithLabelValues(op.Desc(), "finish").Inc()
+		oc.countCostCenter(op, "finish")
+		oc.countKeyspace(op, "finish")
+		oc.countKeyspaceMovement(op)
+		oc.countZoneTransfer(op)
 		operatorDuration.WithLabelValues(op.Desc()).Observe(op.RunningTime().Seconds())
 		for _, counter := range op.FinishedCounters {
 			counter.Inc()
 		}
+		oc.conflicts.onFinish(op)
 	case operator.REPLACED:
 		log.Info("replace old operator",
 			zap.Uint64("region-id", op.RegionID()),
 			zap.Duration("takes", op.RunningTime()),
 			zap.Reflect("operator", op))
 		operatorCounter.WithLabelValues(op.Desc(), "replace").Inc()
+		oc.countCostCenter(op, "replace")
+		oc.countKeyspace(op, "replace")
 	case operator.EXPIRED:
 		log.Info("operator expired",
 			zap.Uint64("region-id", op.RegionID()),
 			zap.Duration("lives", op.ElapsedTime()),
 			zap.Reflect("operator", op))
 		operatorCounter.WithLabelValues(op.Desc(), "expire").Inc()
+		oc.countCostCenter(op, "expire")
+		oc.countKeyspace(op, "expire")
 	case operator.TIMEOUT:
 		log.Info("operator timeout",
 			zap.Uint64("region-id", op.RegionID()),
 			zap.Duration("takes", op.RunningTime()),
 			zap.Reflect("operator", op))
 		operatorCounter.WithLabelValues(op.Desc(), "timeout").Inc()
+		oc.countCostCenter(op, "timeout")
+		oc.countKeyspace(op, "timeout")
 	case operator.CANCELED:
 		fields := []zap.Field{
 			zap.Uint64("region-id", op.RegionID()),
@@ -587,11 +889,174 @@ func (oc *OperatorController) buryOperator(op *operator.Operator, extraFields ..
 			fields...,
 		)
 		operatorCounter.WithLabelValues(op.Desc(), "cancel").Inc()
+		oc.countCostCenter(op, "cancel")
+		oc.countKeyspace(op, "cancel")
 	}
 
 	oc.opRecords.Put(op)
 }
 
+// isSuppressedByKeyRangeLock returns whether the operator is suppressed since
+// the region is in a locked key range. Only the operators to repair the
+// replicas and the ones created by the admin are allowed in the locked range.
+func (oc *OperatorController) isSuppressedByKeyRangeLock(op *operator.Operator, region *core.RegionInfo) bool {
+	if op.Kind()&(operator.OpAdmin|operator.OpReplica) != 0 {
+		return false
+	}
+	m := oc.cluster.GetKeyRangeManager()
+	return m != nil && m.IsRangeLocked(region.GetStartKey(), region.GetEndKey())
+}
+
+// isSystemRangeRegion returns whether the region is in an isolated system
+// range.
+func isSystemRangeRegion(cluster opt.Cluster, region *core.RegionInfo) bool {
+	if !cluster.GetOpts().IsSystemRangeIsolationEnabled() {
+		return false
+	}
+	l := cluster.GetRegionLabeler()
+	return l != nil && l.GetRegionLabel(region, labeler.SystemRangeLabel) == "true"
+}
+
+// isSuppressedBySystemRange returns whether the operator is suppressed since
+// the region is in an isolated system range, which is not balanced or merged.
+// Only the operators to repair the replicas, the splits and the ones created
+// by the admin are allowed.
+func isSuppressedBySystemRange(cluster opt.Cluster, op *operator.Operator, region *core.RegionInfo) bool {
+	if op.Kind()&(operator.OpAdmin|operator.OpReplica|operator.OpSplit) != 0 {
+		return false
+	}
+	return isSystemRangeRegion(cluster, region)
+}
+
+// getCostCenter returns the cost center of the region of the operator, or an
+// empty string if it has none.
+func (oc *OperatorController) getCostCenter(op *operator.Operator) string {
+	if costCenter, ok := op.AdditionalInfos[labeler.CostCenterLabel]; ok {
+		return costCenter
+	}
+	l := oc.cluster.GetRegionLabeler()
+	region := oc.cluster.GetRegion(op.RegionID())
+	if l == nil || region == nil {
+		return ""
+	}
+	return l.GetRegionLabel(region, labeler.CostCenterLabel)
+}
+
+// tagCostCenter records the cost center of the region in the additional
+// infos of the operator, so that it shows in the operator logs and records.
+func (oc *OperatorController) tagCostCenter(op *operator.Operator) {
+	if costCenter := oc.getCostCenter(op); costCenter != "" {
+		op.AdditionalInfos[labeler.CostCenterLabel] = costCenter
+		oc.countCostCenter(op, "create")
+	}
+}
+
+func (oc *OperatorController) countCostCenter(op *operator.Operator, event string) {
+	if costCenter := op.AdditionalInfos[labeler.CostCenterLabel]; costCenter != "" {
+		operatorCostCenterCounter.WithLabelValues(costCenter, op.Desc(), event).Inc()
+	}
+}
+
+// getKeyspace returns the keyspace of the region of the operator, which is
+// decoded from the start key in API v2, or noKeyspace if it has none.
+func (oc *OperatorController) getKeyspace(op *operator.Operator) int64 {
+	region := oc.cluster.GetRegion(op.RegionID())
+	if region == nil {
+		return noKeyspace
+	}
+	if id, ok := codec.Key(region.GetStartKey()).KeyspaceID(); ok {
+		return int64(id)
+	}
+	return noKeyspace
+}
+
+// tagKeyspace records the keyspace of the region in the additional infos of
+// the operator. The keys are only classified by the keyspaces if the keyspace
+// fair queue is enabled, since the raw keys in API v1 may look like the ones
+// of the keyspaces.
+func (oc *OperatorController) tagKeyspace(op *operator.Operator) {
+	if !oc.cluster.GetOpts().IsKeyspaceFairQueueEnabled() {
+		return
+	}
+	if keyspace := keyspaceLabel(oc.getKeyspace(op)); keyspace != "" {
+		op.AdditionalInfos[keyspaceInfoKey] = keyspace
+		oc.countKeyspace(op, "create")
+	}
+}
+
+// keyspaceMetricLabel returns the label of the keyspace in the metrics. Only
+// the keyspaces with a configured weight have their own label values, and the
+// others are aggregated into otherKeyspaces.
+func (oc *OperatorController) keyspaceMetricLabel(keyspace string) string {
+	id, err := strconv.ParseUint(keyspace, 10, 32)
+	if err != nil || !oc.cluster.GetOpts().IsKeyspaceWeighted(uint32(id)) {
+		return otherKeyspaces
+	}
+	return keyspace
+}
+
+func (oc *OperatorController) countKeyspace(op *operator.Operator, event string) {
+	if keyspace := op.AdditionalInfos[keyspaceInfoKey]; keyspace != "" {
+		keyspaceOperatorCounter.WithLabelValues(oc.keyspaceMetricLabel(keyspace), op.Desc(), event).Inc()
+	}
+}
+
+// countKeyspaceMovement records the size of the data moved by the finished
+// operator for its keyspace.
+func (oc *OperatorController) countKeyspaceMovement(op *operator.Operator) {
+	keyspace := op.AdditionalInfos[keyspaceInfoKey]
+	region := oc.cluster.GetRegion(op.RegionID())
+	if keyspace == "" || region == nil {
+		return
+	}
+	if size := movedSize(region, []*operator.Operator{op}); size > 0 {
+		keyspaceMovedSizeCounter.WithLabelValues(oc.keyspaceMetricLabel(keyspace)).Add(float64(size))
+	}
+}
+
+// updateKeyspaceCounts counts the running operators of each keyspace.
+func (oc *OperatorController) updateKeyspaceCounts(operators map[uint64]*operator.Operator) {
+	counts := make(map[string]int)
+	for _, op := range operators {
+		if keyspace := op.AdditionalInfos[keyspaceInfoKey]; keyspace != "" {
+			counts[oc.keyspaceMetricLabel(keyspace)]++
+		}
+	}
+	keyspaceRunningOperatorGauge.Reset()
+	for keyspace, count := range counts {
+		keyspaceRunningOperatorGauge.WithLabelValues(keyspace).Set(float64(count))
+	}
+}
+
+// countZoneTransfer records the bytes moved between the zones by the peers
+// added by the finished operator, which are sent from the leader.
+func (oc *OperatorController) countZoneTransfer(op *operator.Operator) {
+	key := oc.cluster.GetOpts().GetZoneLabelKey()
+	region := oc.cluster.GetRegion(op.RegionID())
+	if key == "" || region == nil {
+		return
+	}
+	source := oc.cluster.GetStore(region.GetLeader().GetStoreId())
+	if source == nil {
+		return
+	}
+	for i := 0; i < op.Len(); i++ {
+		var toStore uint64
+		switch step := op.Step(i).(type) {
+		case operator.AddPeer:
+			toStore = step.ToStore
+		case operator.AddLearner:
+			toStore = step.ToStore
+		default:
+			continue
+		}
+		if target := oc.cluster.GetStore(toStore); target != nil {
+			zoneTransferBytesCounter.WithLabelValues(source.GetLabelValue(key), target.GetLabelValue(key)).
+				Add(float64(region.GetApproximateSize() * (1 << 20)))
+		}
+	}
+}
+
 // GetOperatorStatus gets the operator and its status with the specify id.
 func (oc *OperatorController) GetOperatorStatus(id uint64) *OperatorWithStatus {
 	oc.Lock()
@@ -629,6 +1094,39 @@ func (oc *OperatorController) GetWaitingOperators() []*operator.Operator {
 	return oc.wop.ListOperator()
 }
 
+// GetWaitingQueueStatus returns the status of the waiting operators of each
+// store. It is nil if the store fair queue is disabled.
+func (oc *OperatorController) GetWaitingQueueStatus() []*WaitingStoreStatus {
+	oc.RLock()
+	defer oc.RUnlock()
+	switch q := oc.wop.(type) {
+	case *StoreFairQueue:
+		return q.Status()
+	case *KeyspaceFairQueue:
+		// The stores are queued in each keyspace, so they are gathered to
+		// show the stores across the keyspaces.
+		if oc.storeFair {
+			sq := NewStoreFairQueue(nil)
+			for _, op := range q.ListOperator() {
+				sq.PutOperator(op)
+			}
+			return sq.Status()
+		}
+	}
+	return nil
+}
+
+// GetWaitingKeyspaceStatus returns the status of the waiting operators of each
+// keyspace. It is nil if the keyspace fair queue is disabled.
+func (oc *OperatorController) GetWaitingKeyspaceStatus() []*WaitingKeyspaceStatus {
+	oc.RLock()
+	defer oc.RUnlock()
+	if q, ok := oc.wop.(*KeyspaceFairQueue); ok {
+		return q.Status()
+	}
+	return nil
+}
+
 // SendScheduleCommand sends a command to the region.
 func (oc *OperatorController) SendScheduleCommand(region *core.RegionInfo, step operator.OpStep, source string) {
 	log.Info("send schedule command",
@@ -636,6 +1134,57 @@ func (oc *OperatorController) SendScheduleCommand(region *core.RegionInfo, step
 		zap.Stringer("step", step),
 		zap.String("source", source))
 
+	cmd := buildScheduleCommand(region, step)
+	if cmd == nil {
+		return
+	}
+	if st, ok := step.(operator.AddLearner); ok {
+		if source := region.GetStorePeer(st.SnapshotSource); source != nil {
+			oc.snapshotHints.put(&SnapshotHint{
+				RegionID:     region.GetID(),
+				PeerID:       st.PeerID,
+				ToStore:      st.ToStore,
+				SourcePeerID: source.GetId(),
+				SourceStore:  st.SnapshotSource,
+				leaderStore:  region.GetLeader().GetStoreId(),
+				expireTime:   time.Now().Add(snapshotHintTTL),
+			})
+		}
+	}
+	oc.hbStreams.SendMsg(region, cmd)
+}
+
+// CheckRecoveredCommand checks whether the command recovered from the
+// previous leader is still the current step of the running operator of the
+// region, and the region is not changed since the command is sent.
+func (oc *OperatorController) CheckRecoveredCommand(region *core.RegionInfo, msg *pdpb.RegionHeartbeatResponse) bool {
+	op := oc.GetOperator(region.GetID())
+	if op == nil || op.IsEnd() {
+		return false
+	}
+	epoch := msg.GetRegionEpoch()
+	if epoch.GetVersion() != region.GetRegionEpoch().GetVersion() || epoch.GetConfVer() != region.GetRegionEpoch().GetConfVer() {
+		return false
+	}
+	if msg.GetTargetPeer().GetId() != region.GetLeader().GetId() {
+		return false
+	}
+	step := op.Step(op.CurrentStepIndex())
+	if step == nil {
+		return false
+	}
+	cmd := buildScheduleCommand(region, step)
+	if cmd == nil {
+		return false
+	}
+	expected := proto.Clone(msg).(*pdpb.RegionHeartbeatResponse)
+	expected.Header, expected.RegionId, expected.RegionEpoch, expected.TargetPeer = nil, 0, nil, nil
+	return proto.Equal(expected, cmd)
+}
+
+// buildScheduleCommand builds the command of the step. It returns nil if the
+// step needs no command to be sent.
+func buildScheduleCommand(region *core.RegionInfo, step operator.OpStep) *pdpb.RegionHeartbeatResponse {
 	var cmd *pdpb.RegionHeartbeatResponse
 	switch st := step.(type) {
 	case operator.TransferLeader:
@@ -647,25 +1196,25 @@ func (oc *OperatorController) SendScheduleCommand(region *core.RegionInfo, step
 	case operator.AddPeer:
 		if region.GetStorePeer(st.ToStore) != nil {
 			// The newly added peer is pending.
-			return
+			return nil
 		}
 		cmd = addNode(st.PeerID, st.ToStore)
 	case operator.AddLightPeer:
 		if region.GetStorePeer(st.ToStore) != nil {
 			// The newly added peer is pending.
-			return
+			return nil
 		}
 		cmd = addNode(st.PeerID, st.ToStore)
 	case operator.AddLearner:
 		if region.GetStorePeer(st.ToStore) != nil {
 			// The newly added peer is pending.
-			return
+			return nil
 		}
 		cmd = addLearnerNode(st.PeerID, st.ToStore)
 	case operator.AddLightLearner:
 		if region.GetStorePeer(st.ToStore) != nil {
 			// The newly added peer is pending.
-			return
+			return nil
 		}
 		cmd = addLearnerNode(st.PeerID, st.ToStore)
 	case operator.PromoteLearner:
@@ -681,7 +1230,7 @@ func (oc *OperatorController) SendScheduleCommand(region *core.RegionInfo, step
 		}
 	case operator.MergeRegion:
 		if st.IsPassive {
-			return
+			return nil
 		}
 		cmd = &pdpb.RegionHeartbeatResponse{
 			Merge: &pdpb.Merge{
@@ -696,6 +1245,12 @@ func (oc *OperatorController) SendScheduleCommand(region *core.RegionInfo, step
 			},
 		}
 	case operator.ChangePeerV2Enter:
+		for _, pl := range st.PromoteLearners {
+			if region.GetPendingLearner(pl.PeerID) != nil {
+				// The learners added in parallel are catching up.
+				return nil
+			}
+		}
 		cmd = &pdpb.RegionHeartbeatResponse{
 			ChangePeerV2: st.GetRequest(),
 		}
@@ -705,9 +1260,9 @@ func (oc *OperatorController) SendScheduleCommand(region *core.RegionInfo, step
 		}
 	default:
 		log.Error("unknown operator step", zap.Reflect("step", step), errs.ZapError(errs.ErrUnknownOperatorStep))
-		return
+		return nil
 	}
-	oc.hbStreams.SendMsg(region, cmd)
+	return cmd
 }
 
 func addNode(id, storeID uint64) *pdpb.RegionHeartbeatResponse {
@@ -758,6 +1313,7 @@ func (oc *OperatorController) PruneHistory() {
 		oc.histories.Remove(p)
 		p = prev
 	}
+	oc.conflicts.prune()
 }
 
 // GetHistory gets operators' history.
@@ -783,6 +1339,7 @@ func (oc *OperatorController) updateCounts(operators map[uint64]*operator.Operat
 	for _, op := range operators {
 		oc.counts[op.SchedulerKind()]++
 	}
+	oc.updateKeyspaceCounts(operators)
 }
 
 // OperatorCount gets the count of operators filtered by kind.
@@ -939,7 +1496,7 @@ func (oc *OperatorController) newStoreLimit(storeID uint64, ratePerSec float64,
 // getOrCreateStoreLimit is used to get or create the limit of a store.
 func (oc *OperatorController) getOrCreateStoreLimit(storeID uint64, limitType storelimit.Type) *storelimit.StoreLimit {
 	if oc.storesLimit[storeID][limitType] == nil {
-		ratePerSec := oc.cluster.GetOpts().GetStoreLimitByType(storeID, limitType) / StoreBalanceBaseTime
+		ratePerSec := oc.getStoreLimitRate(storeID, limitType)
 		oc.newStoreLimit(storeID, ratePerSec, limitType)
 		oc.cluster.AttachAvailableFunc(storeID, limitType, func() bool {
 			oc.RLock()
@@ -950,7 +1507,7 @@ func (oc *OperatorController) getOrCreateStoreLimit(storeID uint64, limitType st
 			return oc.storesLimit[storeID][limitType].Available() >= storelimit.RegionInfluence[limitType]
 		})
 	}
-	ratePerSec := oc.cluster.GetOpts().GetStoreLimitByType(storeID, limitType) / StoreBalanceBaseTime
+	ratePerSec := oc.getStoreLimitRate(storeID, limitType)
 	if ratePerSec != oc.storesLimit[storeID][limitType].Rate() {
 		oc.newStoreLimit(storeID, ratePerSec, limitType)
 	}
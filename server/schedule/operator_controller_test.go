@@ -16,13 +16,16 @@ package schedule
 import (
 	"container/heap"
 	"context"
+	"encoding/hex"
 	"fmt"
 	"sync"
 	"testing"
 	"time"
 
+	"github.com/gogo/protobuf/proto"
 	. "github.com/pingcap/check"
 	"github.com/pingcap/failpoint"
+	"github.com/pingcap/kvproto/pkg/eraftpb"
 	"github.com/pingcap/kvproto/pkg/metapb"
 	"github.com/pingcap/kvproto/pkg/pdpb"
 	"github.com/tikv/pd/pkg/mock/mockcluster"
@@ -31,6 +34,7 @@ import (
 	"github.com/tikv/pd/server/core/storelimit"
 	"github.com/tikv/pd/server/schedule/checker"
 	"github.com/tikv/pd/server/schedule/hbstream"
+	"github.com/tikv/pd/server/schedule/labeler"
 	"github.com/tikv/pd/server/schedule/operator"
 )
 
@@ -96,6 +100,86 @@ func (t *testOperatorControllerSuite) TestGetOpInfluence(c *C) {
 	c.Assert(oc.GetOperator(2), NotNil)
 }
 
+func (t *testOperatorControllerSuite) TestAckCommand(c *C) {
+	opt := config.NewTestOptions()
+	tc := mockcluster.NewCluster(t.ctx, opt)
+	stream := hbstream.NewTestHeartbeatStreams(t.ctx, tc.ID, tc, false /* no need to run */)
+	oc := NewOperatorController(t.ctx, tc, stream)
+	tc.AddLeaderStore(1, 2)
+	tc.AddLeaderStore(2, 0)
+	tc.AddLeaderStore(3, 0)
+	tc.AddLeaderRegion(1, 1, 2)
+	tc.AddLeaderRegion(2, 1, 2)
+	steps := []operator.OpStep{
+		operator.RemovePeer{FromStore: 2},
+		operator.AddPeer{ToStore: 3, PeerID: 4},
+	}
+	op1 := operator.NewOperator("test", "test", 1, &metapb.RegionEpoch{}, operator.OpRegion, steps...)
+	op2 := operator.NewOperator("test", "test", 2, &metapb.RegionEpoch{}, operator.OpRegion, steps...)
+	c.Assert(op1.Start(), IsTrue)
+	oc.SetOperator(op1)
+	c.Assert(op2.Start(), IsTrue)
+	oc.SetOperator(op2)
+	oc.Dispatch(tc.GetRegion(1), "test")
+	oc.Dispatch(tc.GetRegion(2), "test")
+	c.Assert(stream.CommandCount(), Equals, 2)
+
+	// The command is acknowledged when the step is finished, and the command
+	// of the next step is queued.
+	region1 := ApplyOperatorStep(tc.GetRegion(1), op1)
+	tc.PutRegion(region1)
+	oc.Dispatch(region1, "test")
+	c.Assert(op1.CurrentStepIndex(), Equals, 1)
+	c.Assert(stream.CommandCount(), Equals, 2)
+	ApplyOperator(tc, op1)
+	oc.Dispatch(tc.GetRegion(1), "test")
+	c.Assert(oc.GetOperatorStatus(1).Status, Equals, pdpb.OperatorStatus_SUCCESS)
+	c.Assert(stream.CommandCount(), Equals, 1)
+
+	// The command of the removed operator is cleared.
+	c.Assert(oc.RemoveOperator(op2), IsTrue)
+	c.Assert(stream.CommandCount(), Equals, 0)
+	c.Assert(stream.Drain(3), IsNil)
+}
+
+func (t *testOperatorControllerSuite) TestCheckRecoveredCommand(c *C) {
+	opt := config.NewTestOptions()
+	tc := mockcluster.NewCluster(t.ctx, opt)
+	stream := hbstream.NewTestHeartbeatStreams(t.ctx, tc.ID, tc, false /* no need to run */)
+	oc := NewOperatorController(t.ctx, tc, stream)
+	tc.AddLeaderStore(1, 1)
+	tc.AddLeaderStore(2, 0)
+	tc.AddLeaderRegion(1, 1, 2)
+	region := tc.GetRegion(1)
+	msg := &pdpb.RegionHeartbeatResponse{
+		Header:      &pdpb.ResponseHeader{ClusterId: tc.ID},
+		RegionId:    1,
+		RegionEpoch: proto.Clone(region.GetRegionEpoch()).(*metapb.RegionEpoch),
+		TargetPeer:  region.GetLeader(),
+		ChangePeer: &pdpb.ChangePeer{
+			ChangeType: eraftpb.ConfChangeType_RemoveNode,
+			Peer:       region.GetStorePeer(2),
+		},
+	}
+
+	// The command of the previous leader has no operator.
+	c.Assert(oc.CheckRecoveredCommand(region, msg), IsFalse)
+
+	op := operator.NewOperator("test", "test", 1, region.GetRegionEpoch(), operator.OpRegion, operator.RemovePeer{FromStore: 2})
+	c.Assert(op.Start(), IsTrue)
+	oc.SetOperator(op)
+	c.Assert(oc.CheckRecoveredCommand(region, msg), IsTrue)
+
+	// The command is not the current step of the operator.
+	other := proto.Clone(msg).(*pdpb.RegionHeartbeatResponse)
+	other.ChangePeer, other.TransferLeader = nil, &pdpb.TransferLeader{Peer: region.GetStorePeer(2)}
+	c.Assert(oc.CheckRecoveredCommand(region, other), IsFalse)
+
+	// The region is changed since the command is sent.
+	region = region.Clone(core.SetRegionConfVer(region.GetRegionEpoch().GetConfVer() + 1))
+	c.Assert(oc.CheckRecoveredCommand(region, msg), IsFalse)
+}
+
 func (t *testOperatorControllerSuite) TestOperatorStatus(c *C) {
 	opt := config.NewTestOptions()
 	tc := mockcluster.NewCluster(t.ctx, opt)
@@ -131,6 +215,355 @@ func (t *testOperatorControllerSuite) TestOperatorStatus(c *C) {
 	c.Assert(oc.GetOperatorStatus(2).Status, Equals, pdpb.OperatorStatus_SUCCESS)
 }
 
+func (t *testOperatorControllerSuite) TestCostCenter(c *C) {
+	opt := config.NewTestOptions()
+	tc := mockcluster.NewCluster(t.ctx, opt)
+	stream := hbstream.NewTestHeartbeatStreams(t.ctx, tc.ID, tc, false /* no need to run */)
+	oc := NewOperatorController(t.ctx, tc, stream)
+	tc.AddLeaderStore(1, 2)
+	tc.AddLeaderStore(2, 0)
+	tc.AddLeaderRegion(1, 1, 2)
+	tc.AddLeaderRegion(2, 1, 2)
+	c.Assert(tc.SetLabelRule(&labeler.LabelRule{
+		ID:          "tenant",
+		Labels:      []labeler.RegionLabel{{Key: labeler.CostCenterLabel, Value: "t1"}},
+		StartKeyHex: hex.EncodeToString(tc.GetRegion(1).GetStartKey()),
+		EndKeyHex:   hex.EncodeToString(tc.GetRegion(1).GetEndKey()),
+	}), IsNil)
+
+	op1 := operator.NewOperator("test", "test", 1, &metapb.RegionEpoch{}, operator.OpLeader, operator.TransferLeader{FromStore: 1, ToStore: 2})
+	op2 := operator.NewOperator("test", "test", 2, &metapb.RegionEpoch{}, operator.OpLeader, operator.TransferLeader{FromStore: 1, ToStore: 2})
+	c.Assert(oc.AddOperator(op1, op2), IsTrue)
+	c.Assert(op1.AdditionalInfos[labeler.CostCenterLabel], Equals, "t1")
+	c.Assert(op1.GetAdditionalInfo(), Matches, `.*"cost-center":"t1".*`)
+	c.Assert(op2.AdditionalInfos, Not(HasKey), labeler.CostCenterLabel)
+}
+
+func (t *testOperatorControllerSuite) TestKeyspaceFairQueue(c *C) {
+	tc := mockcluster.NewCluster(t.ctx, config.NewTestOptions())
+	stream := hbstream.NewTestHeartbeatStreams(t.ctx, tc.ID, tc, false /* no need to run */)
+	oc := NewOperatorController(t.ctx, tc, stream)
+	tc.SetEnableKeyspaceFairQueue(true)
+	tc.AddLeaderStore(1, 5)
+	tc.AddLeaderStore(2, 0)
+	keyspaces := map[uint64]string{1: "\x00\x00\x01", 2: "\x00\x00\x01", 3: "\x00\x00\x01", 5: "\x00\x00\x02", 6: "\x00\x00\x02"}
+	for id, keyspace := range keyspaces {
+		tc.AddLeaderRegionWithRange(id, fmt.Sprintf("r%s%d", keyspace, id), fmt.Sprintf("r%s%d", keyspace, id+1), 1, 2)
+	}
+	newOp := func(regionID uint64) *operator.Operator {
+		return operator.NewOperator("test", "test", regionID, tc.GetRegion(regionID).GetRegionEpoch(), operator.OpLeader, operator.TransferLeader{FromStore: 1, ToStore: 2})
+	}
+
+	// Each batch promotes one operator, and the keyspace queued later is not
+	// starved behind the earlier one.
+	c.Assert(oc.AddWaitingOperator(newOp(1), newOp(2), newOp(3)), Equals, 3)
+	c.Assert(oc.GetOperator(1), NotNil)
+	c.Assert(oc.GetOperator(1).AdditionalInfos[keyspaceInfoKey], Equals, "1")
+	c.Assert(oc.AddWaitingOperator(newOp(5), newOp(6)), Equals, 2)
+	c.Assert(oc.GetOperator(5), NotNil)
+	c.Assert(oc.GetOperator(5).AdditionalInfos[keyspaceInfoKey], Equals, "2")
+	c.Assert(oc.GetWaitingKeyspaceStatus(), DeepEquals, []*WaitingKeyspaceStatus{
+		{Keyspace: "1", Weight: 1, Waiting: 2},
+		{Keyspace: "2", Weight: 1, Waiting: 1},
+	})
+	oc.PromoteWaitingOperator()
+	c.Assert(oc.GetOperator(2), NotNil)
+	oc.PromoteWaitingOperator()
+	c.Assert(oc.GetOperator(6), NotNil)
+	c.Assert(oc.GetWaitingKeyspaceStatus(), DeepEquals, []*WaitingKeyspaceStatus{{Keyspace: "1", Weight: 1, Waiting: 1}})
+
+	// The stores are gathered across the keyspaces in the store fair queue.
+	tc.SetEnableStoreFairQueue(true)
+	oc.Lock()
+	oc.syncWaitingQueueLocked()
+	oc.Unlock()
+	c.Assert(oc.GetWaitingOperators(), HasLen, 1)
+	c.Assert(oc.GetWaitingQueueStatus(), DeepEquals, []*WaitingStoreStatus{
+		{StoreID: 0, Origins: []*WaitingOriginStatus{{Desc: "test", Priority: "normal", Weight: 4, Waiting: 1}}},
+	})
+
+	// The waiting operators are moved if the queue is disabled.
+	tc.SetEnableKeyspaceFairQueue(false)
+	oc.Lock()
+	oc.syncWaitingQueueLocked()
+	oc.Unlock()
+	c.Assert(oc.GetWaitingOperators(), HasLen, 1)
+	c.Assert(oc.GetWaitingKeyspaceStatus(), IsNil)
+	c.Assert(oc.GetWaitingQueueStatus(), HasLen, 1)
+
+	// The keys are not classified by the keyspaces if the queue is disabled,
+	// as they may be the raw keys in API v1.
+	tc.AddLeaderRegionWithRange(7, "r\x00\x00\x037", "r\x00\x00\x038", 1, 2)
+	c.Assert(oc.AddOperator(newOp(7)), IsTrue)
+	_, ok := oc.GetOperator(7).AdditionalInfos[keyspaceInfoKey]
+	c.Assert(ok, IsFalse)
+
+	// Only the keyspaces with a configured weight have their own metric labels.
+	tc.SetKeyspaceWeights([]config.KeyspaceWeight{{KeyspaceID: 1, Weight: 2}})
+	c.Assert(oc.keyspaceMetricLabel("1"), Equals, "1")
+	c.Assert(oc.keyspaceMetricLabel("2"), Equals, otherKeyspaces)
+}
+
+func (t *testOperatorControllerSuite) TestSnapshotHints(c *C) {
+	opt := config.NewTestOptions()
+	tc := mockcluster.NewCluster(t.ctx, opt)
+	stream := hbstream.NewTestHeartbeatStreams(t.ctx, tc.ID, tc, false /* no need to run */)
+	oc := NewOperatorController(t.ctx, tc, stream)
+	tc.AddLabelsStore(1, 1, map[string]string{"zone": "z1"})
+	tc.AddLabelsStore(2, 1, map[string]string{"zone": "z2"})
+	tc.AddLabelsStore(3, 0, map[string]string{"zone": "z2"})
+	tc.AddLabelsStore(4, 0, map[string]string{"zone": "z1"})
+	tc.AddLeaderRegion(1, 1, 2)
+	region := tc.GetRegion(1)
+
+	// No hint without the zone label key.
+	op, err := operator.CreateAddPeerOperator("test", tc, region, &metapb.Peer{Id: 100, StoreId: 3}, operator.OpRegion)
+	c.Assert(err, IsNil)
+	c.Assert(op.Step(0).(operator.AddLearner).SnapshotSource, Equals, uint64(0))
+
+	cfg := opt.GetScheduleConfig().Clone()
+	cfg.ZoneLabelKey = "zone"
+	opt.SetScheduleConfig(cfg)
+	// The leader is in the same zone as the learner.
+	op, err = operator.CreateAddPeerOperator("test", tc, region, &metapb.Peer{Id: 100, StoreId: 4}, operator.OpRegion)
+	c.Assert(err, IsNil)
+	c.Assert(op.Step(0).(operator.AddLearner).SnapshotSource, Equals, uint64(0))
+	// The follower in the same zone is suggested.
+	op, err = operator.CreateAddPeerOperator("test", tc, region, &metapb.Peer{Id: 100, StoreId: 3}, operator.OpRegion)
+	c.Assert(err, IsNil)
+	c.Assert(op.Step(0).(operator.AddLearner).SnapshotSource, Equals, uint64(2))
+
+	c.Assert(oc.AddOperator(op), IsTrue)
+	hints := oc.GetSnapshotHints(1)
+	c.Assert(hints, HasLen, 1)
+	c.Assert(*hints[0], DeepEquals, SnapshotHint{
+		RegionID:     1,
+		PeerID:       100,
+		ToStore:      3,
+		SourcePeerID: region.GetStorePeer(2).GetId(),
+		SourceStore:  2,
+		leaderStore:  1,
+		expireTime:   hints[0].expireTime,
+	})
+	c.Assert(oc.GetSnapshotHints(2), HasLen, 0)
+	c.Assert(oc.snapshotHints.get(1, time.Now().Add(snapshotHintTTL+time.Second)), HasLen, 0)
+
+	oc.SendScheduleCommand(region, op.Step(0), DispatchFromHeartBeat)
+	c.Assert(oc.GetSnapshotHints(1), HasLen, 1)
+	c.Assert(oc.RemoveOperator(op), IsTrue)
+	c.Assert(oc.GetSnapshotHints(1), HasLen, 0)
+}
+
+func (t *testOperatorControllerSuite) TestKeyRangeLock(c *C) {
+	opt := config.NewTestOptions()
+	tc := mockcluster.NewCluster(t.ctx, opt)
+	stream := hbstream.NewTestHeartbeatStreams(t.ctx, tc.ID, tc, false /* no need to run */)
+	oc := NewOperatorController(t.ctx, tc, stream)
+	tc.AddLeaderStore(1, 2)
+	tc.AddLeaderStore(2, 0)
+	tc.AddLeaderRegion(1, 1, 2)
+	tc.AddLeaderRegion(2, 1, 2)
+	region := tc.GetRegion(1)
+	lock, err := tc.GetKeyRangeManager().Acquire("br", hex.EncodeToString(region.GetStartKey()), hex.EncodeToString(region.GetEndKey()), 0)
+	c.Assert(err, IsNil)
+
+	// The balance operators and the merges are suppressed in the locked range.
+	op := operator.NewOperator("test", "test", 1, &metapb.RegionEpoch{}, operator.OpLeader, operator.TransferLeader{FromStore: 1, ToStore: 2})
+	c.Assert(oc.AddOperator(op), IsFalse)
+	op = operator.NewOperator("test", "test", 1, &metapb.RegionEpoch{}, operator.OpMerge, operator.MergeRegion{})
+	c.Assert(oc.AddOperator(op), IsFalse)
+	op = operator.NewOperator("test", "test", 2, &metapb.RegionEpoch{}, operator.OpLeader, operator.TransferLeader{FromStore: 1, ToStore: 2})
+	c.Assert(oc.AddOperator(op), IsTrue)
+	// The repairing and the admin operators are allowed.
+	op = operator.NewOperator("test", "test", 1, &metapb.RegionEpoch{}, operator.OpReplica|operator.OpRegion, operator.RemovePeer{FromStore: 2})
+	c.Assert(oc.AddOperator(op), IsTrue)
+	c.Assert(oc.RemoveOperator(op), IsTrue)
+	op = operator.NewOperator("test", "test", 1, &metapb.RegionEpoch{}, operator.OpAdmin|operator.OpLeader, operator.TransferLeader{FromStore: 1, ToStore: 2})
+	c.Assert(oc.AddOperator(op), IsTrue)
+	c.Assert(oc.RemoveOperator(op), IsTrue)
+
+	c.Assert(tc.GetKeyRangeManager().Release(lock.ID), IsNil)
+	op = operator.NewOperator("test", "test", 1, &metapb.RegionEpoch{}, operator.OpLeader, operator.TransferLeader{FromStore: 1, ToStore: 2})
+	c.Assert(oc.AddOperator(op), IsTrue)
+}
+
+func (t *testOperatorControllerSuite) TestOperatorConflict(c *C) {
+	opt := config.NewTestOptions()
+	tc := mockcluster.NewCluster(t.ctx, opt)
+	stream := hbstream.NewTestHeartbeatStreams(t.ctx, tc.ID, tc, false /* no need to run */)
+	oc := NewOperatorController(t.ctx, tc, stream)
+	tc.AddLeaderStore(1, 1)
+	tc.AddLeaderStore(2, 0)
+	tc.AddLeaderStore(3, 0)
+	tc.AddLeaderRegion(1, 1, 2)
+	moveTo3 := func(desc string, kind operator.OpKind) *operator.Operator {
+		return operator.NewOperator(desc, desc, 1, &metapb.RegionEpoch{}, kind, operator.AddPeer{ToStore: 3, PeerID: 10}, operator.RemovePeer{FromStore: 2})
+	}
+	moveTo2 := func(desc string, kind operator.OpKind) *operator.Operator {
+		return operator.NewOperator(desc, desc, 1, &metapb.RegionEpoch{}, kind, operator.AddPeer{ToStore: 2, PeerID: 11}, operator.RemovePeer{FromStore: 3})
+	}
+
+	// The first revert is tolerated.
+	oc.conflicts.onFinish(moveTo3("balance-region", operator.OpRegion))
+	oc.conflicts.onFinish(moveTo2("rule-checker", operator.OpReplica))
+	c.Assert(oc.GetOperatorConflicts(), HasLen, 1)
+	c.Assert(oc.GetOperatorConflicts()[0].BackoffUntil.IsZero(), IsTrue)
+	c.Assert(oc.checkAddOperator(moveTo3("balance-region", operator.OpRegion)), IsTrue)
+
+	// The region oscillates.
+	oc.conflicts.onFinish(moveTo3("balance-region", operator.OpRegion))
+	conflicts := oc.GetOperatorConflicts()
+	c.Assert(conflicts, HasLen, 1)
+	c.Assert(conflicts[0].RegionID, Equals, uint64(1))
+	c.Assert(conflicts[0].Count, Equals, 2)
+	c.Assert(conflicts[0].BackoffUntil.IsZero(), IsFalse)
+	c.Assert(conflicts[0].Sources, DeepEquals, []*OperatorConflictSource{
+		{Operator: "rule-checker", Reverted: "balance-region", Count: 1},
+		{Operator: "balance-region", Reverted: "rule-checker", Count: 1},
+	})
+	c.Assert(oc.checkAddOperator(moveTo2("rule-checker", operator.OpReplica)), IsFalse)
+	// The operators not reverting the recent ones and the admin operators
+	// are allowed.
+	op := operator.NewOperator("test", "test", 1, &metapb.RegionEpoch{}, operator.OpLeader, operator.TransferLeader{FromStore: 1, ToStore: 2})
+	c.Assert(oc.checkAddOperator(op), IsTrue)
+	c.Assert(oc.checkAddOperator(moveTo2("admin", operator.OpAdmin|operator.OpRegion)), IsTrue)
+
+	// The backoff expires.
+	oc.conflicts.regions[1].backoffUntil = time.Now()
+	c.Assert(oc.checkAddOperator(moveTo2("rule-checker", operator.OpReplica)), IsTrue)
+}
+
+func (t *testOperatorControllerSuite) TestSystemRange(c *C) {
+	opt := config.NewTestOptions()
+	tc := mockcluster.NewCluster(t.ctx, opt)
+	stream := hbstream.NewTestHeartbeatStreams(t.ctx, tc.ID, tc, false /* no need to run */)
+	oc := NewOperatorController(t.ctx, tc, stream)
+	tc.SetEnablePlacementRules(true)
+	tc.AddLeaderStore(1, 2)
+	tc.AddLeaderStore(2, 0)
+	tc.AddLeaderStore(3, 0)
+	tc.AddLeaderRegionWithRange(1, "a", "b", 1, 2)
+	tc.AddLeaderRegionWithRange(2, "b", "c", 1, 2)
+	c.Assert(tc.GetRegionLabeler().SetLabelRule(&labeler.LabelRule{
+		ID:          "system-range-0",
+		Labels:      []labeler.RegionLabel{{Key: labeler.SystemRangeLabel, Value: "true"}},
+		StartKeyHex: hex.EncodeToString([]byte("a")),
+		EndKeyHex:   hex.EncodeToString([]byte("b")),
+	}), IsNil)
+
+	// The label takes no effect if the isolation is disabled.
+	op := operator.NewOperator("test", "test", 1, &metapb.RegionEpoch{}, operator.OpLeader, operator.TransferLeader{FromStore: 1, ToStore: 2})
+	c.Assert(oc.AddOperator(op), IsTrue)
+	c.Assert(oc.RemoveOperator(op), IsTrue)
+
+	tc.SetEnableSystemRangeIsolation(true)
+	// The balance operators and the merges are suppressed in the system ranges.
+	op = operator.NewOperator("test", "test", 1, &metapb.RegionEpoch{}, operator.OpLeader, operator.TransferLeader{FromStore: 1, ToStore: 2})
+	c.Assert(oc.AddOperator(op), IsFalse)
+	op = operator.NewOperator("test", "test", 1, &metapb.RegionEpoch{}, operator.OpMerge, operator.MergeRegion{})
+	c.Assert(oc.AddOperator(op), IsFalse)
+	op = operator.NewOperator("test", "test", 2, &metapb.RegionEpoch{}, operator.OpLeader, operator.TransferLeader{FromStore: 1, ToStore: 2})
+	c.Assert(oc.AddOperator(op), IsTrue)
+	c.Assert(oc.RemoveOperator(op), IsTrue)
+	op = operator.NewOperator("test", "test", 1, &metapb.RegionEpoch{}, operator.OpAdmin|operator.OpLeader, operator.TransferLeader{FromStore: 1, ToStore: 2})
+	c.Assert(oc.AddOperator(op), IsTrue)
+	c.Assert(oc.RemoveOperator(op), IsTrue)
+
+	// The system ranges are repaired first, regardless of the replica schedule limit.
+	cfg := tc.GetScheduleConfig().Clone()
+	cfg.ReplicaScheduleLimit = 0
+	tc.SetScheduleConfig(cfg)
+	co := NewCheckerController(t.ctx, tc, tc.RuleManager, oc)
+	ops := co.CheckRegion(tc.GetRegion(1))
+	c.Assert(ops, HasLen, 1)
+	c.Assert(ops[0].GetPriorityLevel(), Equals, core.HighPriority)
+	c.Assert(oc.AddOperator(ops...), IsTrue)
+	c.Assert(co.CheckRegion(tc.GetRegion(2)), HasLen, 0)
+}
+
+func (t *testOperatorControllerSuite) TestHaltScheduling(c *C) {
+	tc := mockcluster.NewCluster(t.ctx, config.NewTestOptions())
+	stream := hbstream.NewTestHeartbeatStreams(t.ctx, tc.ID, tc, false /* no need to run */)
+	oc := NewOperatorController(t.ctx, tc, stream)
+	tc.AddLeaderStore(1, 2)
+	tc.AddLeaderStore(2, 0)
+	tc.AddLeaderRegion(1, 1, 2)
+	tc.AddLeaderRegion(2, 1, 2)
+	setHalt := func(mode string) {
+		cfg := tc.GetScheduleConfig().Clone()
+		cfg.HaltScheduling = mode
+		tc.SetScheduleConfig(cfg)
+	}
+	newOp := func(regionID uint64, kind operator.OpKind) *operator.Operator {
+		return operator.NewOperator("test", "test", regionID, &metapb.RegionEpoch{}, kind, operator.TransferLeader{FromStore: 1, ToStore: 2})
+	}
+
+	running := newOp(1, operator.OpLeader)
+	c.Assert(oc.AddOperator(running), IsTrue)
+	// Only the operators added by the admin are allowed in pause-new.
+	setHalt(config.HaltPauseNew)
+	c.Assert(oc.AddOperator(newOp(2, operator.OpLeader)), IsFalse)
+	c.Assert(oc.AddWaitingOperator(newOp(2, operator.OpLeader)), Equals, 0)
+	admin := newOp(2, operator.OpAdmin|operator.OpLeader)
+	c.Assert(oc.AddOperator(admin), IsTrue)
+	c.Assert(oc.RemoveOperator(admin), IsTrue)
+	// No operator is allowed in drain, and the running ones are kept.
+	setHalt(config.HaltDrain)
+	c.Assert(oc.AddOperator(newOp(2, operator.OpAdmin|operator.OpLeader)), IsFalse)
+	c.Assert(oc.GetOperator(1), Equals, running)
+	c.Assert(running.Status(), Equals, operator.STARTED)
+	// The outstanding operators are canceled in abort-all.
+	setHalt(config.HaltAbortAll)
+	c.Assert(oc.CancelAllOperators(), Equals, 1)
+	c.Assert(running.Status(), Equals, operator.CANCELED)
+	c.Assert(oc.GetOperator(1), IsNil)
+	c.Assert(oc.CancelAllOperators(), Equals, 0)
+
+	setHalt("")
+	c.Assert(oc.AddOperator(newOp(2, operator.OpLeader)), IsTrue)
+}
+
+func (t *testOperatorControllerSuite) TestCheckerPriority(c *C) {
+	opt := config.NewTestOptions()
+	tc := mockcluster.NewCluster(t.ctx, opt)
+	stream := hbstream.NewTestHeartbeatStreams(t.ctx, tc.ID, tc, false /* no need to run */)
+	oc := NewOperatorController(t.ctx, tc, stream)
+	tc.SetEnablePlacementRules(true)
+	tc.AddLeaderStore(1, 3)
+	tc.AddLeaderStore(2, 0)
+	tc.AddLeaderStore(3, 0)
+	tc.AddLeaderRegionWithRange(1, "a", "b", 1, 2)
+	tc.AddLeaderRegionWithRange(2, "b", "c", 1, 2)
+	tc.AddLeaderRegionWithRange(3, "c", "d", 1, 2)
+	for i, class := range []string{labeler.CheckerPriorityCritical, labeler.CheckerPriorityHigh} {
+		c.Assert(tc.GetRegionLabeler().SetLabelRule(&labeler.LabelRule{
+			ID:          class,
+			Labels:      []labeler.RegionLabel{{Key: labeler.CheckerPriorityLabel, Value: class}},
+			StartKeyHex: hex.EncodeToString([]byte{'a' + byte(i)}),
+			EndKeyHex:   hex.EncodeToString([]byte{'b' + byte(i)}),
+		}), IsNil)
+	}
+
+	// Only the critical regions are repaired regardless of the limit.
+	cfg := tc.GetScheduleConfig().Clone()
+	cfg.ReplicaScheduleLimit = 0
+	tc.SetScheduleConfig(cfg)
+	co := NewCheckerController(t.ctx, tc, tc.RuleManager, oc)
+	ops := co.CheckRegion(tc.GetRegion(1))
+	c.Assert(ops, HasLen, 1)
+	c.Assert(ops[0].GetPriorityLevel(), Equals, core.UrgentPriority)
+	c.Assert(co.CheckRegion(tc.GetRegion(2)), HasLen, 0)
+
+	cfg.ReplicaScheduleLimit = 4
+	tc.SetScheduleConfig(cfg)
+	ops = co.CheckRegion(tc.GetRegion(2))
+	c.Assert(ops, HasLen, 1)
+	c.Assert(ops[0].GetPriorityLevel(), Equals, core.UrgentPriority)
+	ops = co.CheckRegion(tc.GetRegion(3))
+	c.Assert(ops, HasLen, 1)
+	c.Assert(ops[0].GetPriorityLevel(), Not(Equals), core.UrgentPriority)
+}
+
 func (t *testOperatorControllerSuite) TestFastFailOperator(c *C) {
 	opt := config.NewTestOptions()
 	tc := mockcluster.NewCluster(t.ctx, opt)
@@ -409,6 +842,31 @@ func (t *testOperatorControllerSuite) TestStoreLimit(c *C) {
 	c.Assert(oc.RemoveOperator(op), IsFalse)
 }
 
+func (t *testOperatorControllerSuite) TestStoreWarmUp(c *C) {
+	now := time.Now()
+	c.Assert(warmUpRatio(now, 0, now), Equals, 1.0)
+	c.Assert(warmUpRatio(time.Time{}, 10*time.Minute, now), Equals, 1.0)
+	c.Assert(warmUpRatio(now, 10*time.Minute, now), Equals, 0.1)
+	c.Assert(warmUpRatio(now.Add(-5*time.Minute), 10*time.Minute, now), Equals, 0.6)
+	c.Assert(warmUpRatio(now.Add(-10*time.Minute), 10*time.Minute, now), Equals, 1.0)
+
+	opt := config.NewTestOptions()
+	tc := mockcluster.NewCluster(t.ctx, opt)
+	stream := hbstream.NewTestHeartbeatStreams(t.ctx, tc.ID, tc, false /* no need to run */)
+	oc := NewOperatorController(t.ctx, tc, stream)
+	tc.SetStoreWarmUpDuration(10 * time.Minute)
+	tc.AddLeaderStore(1, 0)
+	tc.SetStoreLimit(1, storelimit.AddPeer, 600)
+	tc.SetStoreLimit(1, storelimit.RemovePeer, 600)
+	tc.PutStore(tc.GetStore(1).Clone(core.SetJoinTime(time.Now())))
+	c.Assert(oc.getOrCreateStoreLimit(1, storelimit.AddPeer).Rate(), Equals, 1.0)
+	// The remove-peer limit is not paced.
+	c.Assert(oc.getOrCreateStoreLimit(1, storelimit.RemovePeer).Rate(), Equals, 10.0)
+
+	tc.PutStore(tc.GetStore(1).Clone(core.SetJoinTime(time.Now().Add(-10 * time.Minute))))
+	c.Assert(oc.getOrCreateStoreLimit(1, storelimit.AddPeer).Rate(), Equals, 10.0)
+}
+
 // #1652
 func (t *testOperatorControllerSuite) TestDispatchOutdatedRegion(c *C) {
 	cluster := mockcluster.NewCluster(t.ctx, config.NewTestOptions())
@@ -463,6 +921,77 @@ func (t *testOperatorControllerSuite) TestDispatchOutdatedRegion(c *C) {
 	c.Assert(stream.MsgLength(), Equals, 3)
 }
 
+func (t *testOperatorControllerSuite) TestTransferLeaderReadyCheck(c *C) {
+	cluster := mockcluster.NewCluster(t.ctx, config.NewTestOptions())
+	cluster.SetEnableTransferLeaderReadyCheck(true)
+	stream := hbstream.NewTestHeartbeatStreams(t.ctx, cluster.ID, cluster, false /* no need to run */)
+	controller := NewOperatorController(t.ctx, cluster, stream)
+
+	cluster.AddLeaderStore(1, 2)
+	cluster.AddLeaderStore(2, 0)
+	cluster.AddLeaderStore(3, 0)
+	cluster.AddLeaderRegion(1, 1, 2, 3)
+	region := cluster.GetRegion(1)
+	newOp := func(storeID uint64) *operator.Operator {
+		return operator.NewOperator("test", "test", 1, region.GetRegionEpoch(), operator.OpLeader,
+			operator.TransferLeader{FromStore: 1, ToStore: storeID})
+	}
+
+	// The pending target is rejected and backed off.
+	cluster.PutRegion(region.Clone(core.WithPendingPeers([]*metapb.Peer{region.GetStorePeer(2)})))
+	c.Assert(controller.AddOperator(newOp(2)), IsFalse)
+	cluster.PutRegion(region)
+	c.Assert(controller.AddOperator(newOp(2)), IsFalse)
+	c.Assert(stream.MsgLength(), Equals, 0)
+
+	// The operator is canceled when the target becomes unready.
+	op := newOp(3)
+	c.Assert(controller.AddOperator(op), IsTrue)
+	c.Assert(stream.MsgLength(), Equals, 1)
+	controller.Dispatch(region.Clone(core.WithPendingPeers([]*metapb.Peer{region.GetStorePeer(3)})), DispatchFromHeartBeat)
+	c.Assert(op.Status(), Equals, operator.CANCELED)
+	c.Assert(op.AdditionalInfos["reject-reason"], Equals, "target peer is pending")
+	c.Assert(controller.GetOperatorStatus(1).Status, Equals, pdpb.OperatorStatus_CANCEL)
+	c.Assert(stream.MsgLength(), Equals, 1)
+	c.Assert(controller.AddOperator(newOp(3)), IsFalse)
+
+	// The check is skipped when disabled.
+	cluster.SetEnableTransferLeaderReadyCheck(false)
+	c.Assert(controller.AddOperator(newOp(2)), IsTrue)
+}
+
+func (t *testOperatorControllerSuite) TestDispatchParallelLearners(c *C) {
+	cluster := mockcluster.NewCluster(t.ctx, config.NewTestOptions())
+	stream := hbstream.NewTestHeartbeatStreams(t.ctx, cluster.ID, cluster, false /* no need to run */)
+	controller := NewOperatorController(t.ctx, cluster, stream)
+	epoch := &metapb.RegionEpoch{ConfVer: 0, Version: 0}
+	region := cluster.MockRegionInfo(1, 1, []uint64{}, []uint64{}, epoch)
+	cluster.PutRegion(region)
+
+	op := operator.NewOperator("test", "test", 1, epoch, operator.OpRegion,
+		operator.AddLearner{ToStore: 2, PeerID: 10, SkipWaitingForSnapshot: true},
+		operator.AddLearner{ToStore: 3, PeerID: 11, SkipWaitingForSnapshot: true},
+		operator.ChangePeerV2Enter{PromoteLearners: []operator.PromoteLearner{{ToStore: 2, PeerID: 10}, {ToStore: 3, PeerID: 11}}},
+	)
+	c.Assert(controller.AddOperator(op), IsTrue)
+	c.Assert(stream.MsgLength(), Equals, 1)
+
+	// The next learner is added while the previous one is pending.
+	learners := []*metapb.Peer{{Id: 10, StoreId: 2, Role: metapb.PeerRole_Learner}, {Id: 11, StoreId: 3, Role: metapb.PeerRole_Learner}}
+	region = region.Clone(core.WithAddPeer(learners[0]), core.WithPendingPeers(learners[:1]), core.WithIncConfVer())
+	controller.Dispatch(region, DispatchFromHeartBeat)
+	c.Assert(stream.MsgLength(), Equals, 2)
+
+	// The learners are not promoted until all of them catch up.
+	region = region.Clone(core.WithAddPeer(learners[1]), core.WithPendingPeers(learners[1:]), core.WithIncConfVer())
+	controller.Dispatch(region, DispatchFromHeartBeat)
+	c.Assert(op.Check(region), FitsTypeOf, operator.ChangePeerV2Enter{})
+	c.Assert(stream.MsgLength(), Equals, 2)
+	region = region.Clone(core.WithPendingPeers(nil))
+	controller.Dispatch(region, DispatchFromHeartBeat)
+	c.Assert(stream.MsgLength(), Equals, 3)
+}
+
 func (t *testOperatorControllerSuite) TestDispatchUnfinishedStep(c *C) {
 	cluster := mockcluster.NewCluster(t.ctx, config.NewTestOptions())
 	stream := hbstream.NewTestHeartbeatStreams(t.ctx, cluster.ID, cluster, false /* no need to run */)
@@ -659,6 +1188,53 @@ func checkRemoveOperatorSuccess(c *C, oc *OperatorController, op *operator.Opera
 	c.Assert(oc.GetOperatorStatus(op.RegionID()).Op, DeepEquals, op)
 }
 
+func (t *testOperatorControllerSuite) TestStoreFairQueue(c *C) {
+	tc := mockcluster.NewCluster(t.ctx, config.NewTestOptions())
+	stream := hbstream.NewTestHeartbeatStreams(t.ctx, tc.ID, tc, false /* no need to run */)
+	oc := NewOperatorController(t.ctx, tc, stream)
+	tc.SetEnableStoreFairQueue(true)
+	tc.AddLeaderStore(1, 0)
+	tc.AddLeaderStore(2, 0)
+	tc.SetStoreLimit(2, storelimit.AddPeer, 60)
+	addPeerOp := func(regionID uint64) *operator.Operator {
+		tc.AddLeaderRegion(regionID, 1)
+		tc.PutRegion(tc.GetRegion(regionID).Clone(core.SetApproximateSize(10)))
+		return operator.NewOperator("test", "test", regionID, tc.GetRegion(regionID).GetRegionEpoch(), operator.OpRegion, operator.AddPeer{ToStore: 2, PeerID: regionID + 100})
+	}
+
+	// The operators exceeding the store limit are kept in the queue.
+	for i := uint64(1); i <= 6; i++ {
+		c.Assert(oc.AddWaitingOperator(addPeerOp(i)), Equals, 1)
+	}
+	c.Assert(oc.GetOperators(), HasLen, 5)
+	c.Assert(oc.GetWaitingOperators(), HasLen, 1)
+	c.Assert(oc.GetWaitingQueueStatus(), DeepEquals, []*WaitingStoreStatus{
+		{StoreID: 2, Origins: []*WaitingOriginStatus{{Desc: "test", Priority: "normal", Weight: 4, Waiting: 1}}},
+	})
+	// The kept operator holds the waiting quota until it expires, and then it
+	// is canceled when promoting.
+	c.Assert(oc.wopStatus.ops["test"], Equals, uint64(1))
+	waiting := oc.GetWaitingOperators()[0]
+	oc.PromoteWaitingOperator()
+	c.Assert(oc.wopStatus.ops["test"], Equals, uint64(1))
+	operator.SetOperatorStatusReachTime(waiting, operator.CREATED, time.Now().Add(-operator.OperatorExpireTime))
+	oc.PromoteWaitingOperator()
+	c.Assert(oc.wopStatus.ops["test"], Equals, uint64(0))
+	c.Assert(oc.GetWaitingOperators(), HasLen, 0)
+	c.Assert(waiting.Status(), Equals, operator.CANCELED)
+
+	c.Assert(oc.AddWaitingOperator(addPeerOp(7)), Equals, 1)
+	c.Assert(oc.GetWaitingOperators(), HasLen, 1)
+
+	// The waiting operators are moved if the queue is disabled.
+	tc.SetEnableStoreFairQueue(false)
+	oc.Lock()
+	oc.syncWaitingQueueLocked()
+	oc.Unlock()
+	c.Assert(oc.GetWaitingOperators(), HasLen, 1)
+	c.Assert(oc.GetWaitingQueueStatus(), IsNil)
+}
+
 func (t *testOperatorControllerSuite) TestAddWaitingOperator(c *C) {
 	cluster := mockcluster.NewCluster(t.ctx, config.NewTestOptions())
 	stream := hbstream.NewTestHeartbeatStreams(t.ctx, cluster.ID, cluster, false /* no need to run */)
@@ -700,3 +1276,76 @@ func (t *testOperatorControllerSuite) TestAddWaitingOperator(c *C) {
 	// no space left, new operator can not be added.
 	c.Assert(controller.AddWaitingOperator(addPeerOp(0)), Equals, 0)
 }
+
+func (t *testOperatorControllerSuite) TestCancelOperatorsByOwner(c *C) {
+	tc := mockcluster.NewCluster(t.ctx, config.NewTestOptions())
+	stream := hbstream.NewTestHeartbeatStreams(t.ctx, tc.ID, tc, false /* no need to run */)
+	oc := NewOperatorController(t.ctx, tc, stream)
+	for storeID := uint64(1); storeID <= 4; storeID++ {
+		tc.AddLeaderStore(storeID, 0)
+	}
+
+	// The operator is stopped after the learner is added, and the learner is
+	// rolled back.
+	tc.AddLeaderRegion(1, 1, 2, 3)
+	region := tc.GetRegion(1)
+	op := operator.NewOperator("test", "test", 1, region.GetRegionEpoch(), operator.OpRegion,
+		operator.AddLearner{ToStore: 4, PeerID: 100},
+		operator.PromoteLearner{ToStore: 4, PeerID: 100},
+		operator.RemovePeer{FromStore: 3, PeerID: region.GetStorePeer(3).GetId()})
+	op.SetOwner("test-scheduler")
+	c.Assert(oc.AddOperator(op), IsTrue)
+	region = region.Clone(core.WithAddPeer(&metapb.Peer{Id: 100, StoreId: 4, Role: metapb.PeerRole_Learner}), core.WithIncConfVer())
+	tc.PutRegion(region)
+	oc.Dispatch(region, DispatchFromHeartBeat)
+	c.Assert(op.CurrentStepIndex(), Equals, 1)
+	c.Assert(op.UnpromotedLearners(), DeepEquals, []uint64{4})
+
+	c.Assert(oc.CancelOperatorsByOwner("other-scheduler"), Equals, 0)
+	c.Assert(op.Status(), Equals, operator.STARTED)
+	c.Assert(oc.CancelOperatorsByOwner("test-scheduler"), Equals, 1)
+	c.Assert(op.Status(), Equals, operator.CANCELED)
+	rollback := oc.GetOperator(1)
+	c.Assert(rollback, NotNil)
+	c.Assert(rollback.Desc(), Equals, "rollback-test")
+	c.Assert(rollback.Owner(), Equals, "")
+	c.Assert(rollback.Step(0), DeepEquals, operator.RemovePeer{FromStore: 4, PeerID: 100})
+
+	// The operator in joint consensus finishes leaving the joint state before
+	// it is stopped.
+	tc.AddLeaderRegion(2, 1, 2)
+	region = tc.GetRegion(2).Clone(core.WithAddPeer(&metapb.Peer{Id: 200, StoreId: 3, Role: metapb.PeerRole_Learner}))
+	tc.PutRegion(region)
+	promote := []operator.PromoteLearner{{ToStore: 3, PeerID: 200}}
+	op = operator.NewOperator("test", "test", 2, region.GetRegionEpoch(), operator.OpRegion,
+		operator.ChangePeerV2Enter{PromoteLearners: promote},
+		operator.ChangePeerV2Leave{PromoteLearners: promote},
+		operator.RemovePeer{FromStore: 2, PeerID: region.GetStorePeer(2).GetId()})
+	op.SetOwner("test-scheduler")
+	c.Assert(oc.AddOperator(op), IsTrue)
+	setRole := func(region *core.RegionInfo, role metapb.PeerRole) *core.RegionInfo {
+		return region.Clone(core.WithRemoveStorePeer(3), core.WithAddPeer(&metapb.Peer{Id: 200, StoreId: 3, Role: role}), core.WithIncConfVer())
+	}
+	region = setRole(region, metapb.PeerRole_IncomingVoter)
+	tc.PutRegion(region)
+	oc.Dispatch(region, DispatchFromHeartBeat)
+	c.Assert(op.CurrentStepIndex(), Equals, 1)
+	c.Assert(op.IsSafeToStop(), IsFalse)
+
+	c.Assert(oc.CancelOperatorsByOwner("test-scheduler"), Equals, 1)
+	c.Assert(op.Status(), Equals, operator.STARTED)
+	region = setRole(region, metapb.PeerRole_Voter)
+	tc.PutRegion(region)
+	oc.Dispatch(region, DispatchFromHeartBeat)
+	c.Assert(op.CurrentStepIndex(), Equals, 2)
+	c.Assert(op.Status(), Equals, operator.CANCELED)
+	c.Assert(oc.GetOperator(2), IsNil)
+
+	// The waiting operator is dropped when it is promoted.
+	tc.AddLeaderRegion(3, 1, 2)
+	op = operator.NewOperator("test", "test", 3, tc.GetRegion(3).GetRegionEpoch(), operator.OpRegion, operator.RemovePeer{FromStore: 2})
+	op.SetOwner("test-scheduler")
+	op.RequestCancel()
+	c.Assert(oc.AddWaitingOperator(op), Equals, 0)
+	c.Assert(op.Status(), Equals, operator.CANCELED)
+}
@@ -17,6 +17,9 @@ import (
 	"github.com/pingcap/kvproto/pkg/pdpb"
 	"github.com/tikv/pd/server/config"
 	"github.com/tikv/pd/server/core"
+	"github.com/tikv/pd/server/schedule/affinity"
+	"github.com/tikv/pd/server/schedule/keyrange"
+	"github.com/tikv/pd/server/schedule/labeler"
 	"github.com/tikv/pd/server/schedule/placement"
 	"github.com/tikv/pd/server/statistics"
 	"github.com/tikv/pd/server/versioninfo"
@@ -44,6 +47,9 @@ type Cluster interface {
 	RemoveScheduler(name string) error
 	IsFeatureSupported(f versioninfo.Feature) bool
 	AddSuspectRegions(ids ...uint64)
+	GetRegionLabeler() *labeler.RegionLabeler
+	GetKeyRangeManager() *keyrange.Manager
+	GetAffinityManager() *affinity.Manager
 }
 
 // HeartbeatStream is an interface.
@@ -0,0 +1,104 @@
+// Copyright 2021 TiKV Project Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package schedule
+
+import (
+	"sync"
+
+	"github.com/tikv/pd/server/config"
+	"github.com/tikv/pd/server/core"
+	"github.com/tikv/pd/server/schedule/operator"
+	"github.com/tikv/pd/server/schedule/opt"
+)
+
+// PatrolBudget limits the operators created by the checkers or the schedulers
+// in a patrol round, so a misbehaving change, like a wrong placement rule,
+// cannot flood the operator controller in a single pass. The operators are
+// charged to their owners only after they are added, so the ones rejected by
+// the operator controller don't take the budget.
+type PatrolBudget struct {
+	sync.Mutex
+	// operatorLimit returns the max number of the operators of each owner.
+	operatorLimit func(opts *config.PersistOptions) uint64
+	// operators is the number of the operators added by each owner.
+	operators map[string]uint64
+	// influence is the size in MB of the data moved by the operators.
+	influence uint64
+}
+
+// NewPatrolBudget creates a budget. operatorLimit returns the max number of
+// the operators of each owner in a round.
+func NewPatrolBudget(operatorLimit func(opts *config.PersistOptions) uint64) *PatrolBudget {
+	return &PatrolBudget{
+		operatorLimit: operatorLimit,
+		operators:     make(map[string]uint64),
+	}
+}
+
+// Allow returns false if the owner has exhausted the budget.
+func (b *PatrolBudget) Allow(opts *config.PersistOptions, owner string) bool {
+	operatorLimit, influenceLimit := b.operatorLimit(opts), opts.GetPatrolInfluenceLimit()
+	b.Lock()
+	defer b.Unlock()
+	if (operatorLimit > 0 && b.operators[owner] >= operatorLimit) || (influenceLimit > 0 && b.influence >= influenceLimit) {
+		patrolBudgetExhaustedCounter.WithLabelValues(owner).Inc()
+		return false
+	}
+	return true
+}
+
+// Charge charges the added operators to their owner. The urgent operators are
+// not charged.
+func (b *PatrolBudget) Charge(cluster opt.Cluster, ops []*operator.Operator) {
+	if len(ops) == 0 {
+		return
+	}
+	var influence uint64
+	for _, op := range ops {
+		if op.GetPriorityLevel() >= core.UrgentPriority {
+			return
+		}
+		if region := cluster.GetRegion(op.RegionID()); region != nil {
+			influence += movedSize(region, []*operator.Operator{op})
+		}
+	}
+	b.Lock()
+	defer b.Unlock()
+	b.operators[ops[0].Owner()] += uint64(len(ops))
+	b.influence += influence
+}
+
+// Reset resets the budget when a patrol round finishes.
+func (b *PatrolBudget) Reset() {
+	b.Lock()
+	defer b.Unlock()
+	b.operators = make(map[string]uint64)
+	b.influence = 0
+}
+
+// movedSize returns the size in MB of the data added to the stores by the
+// operators.
+func movedSize(region *core.RegionInfo, ops []*operator.Operator) uint64 {
+	opInfluence := operator.OpInfluence{StoresInfluence: make(map[uint64]*operator.StoreInfluence)}
+	for _, op := range ops {
+		op.TotalInfluence(opInfluence, region)
+	}
+	var size uint64
+	for _, influence := range opInfluence.StoresInfluence {
+		if influence.RegionSize > 0 {
+			size += uint64(influence.RegionSize)
+		}
+	}
+	return size
+}
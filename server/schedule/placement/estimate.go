@@ -0,0 +1,141 @@
+// Copyright 2021 TiKV Project Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package placement
+
+import (
+	"sort"
+
+	"github.com/tikv/pd/server/core"
+)
+
+// StoreReplicaDelta is the expected change of the replicas on a store.
+type StoreReplicaDelta struct {
+	StoreID      uint64  `json:"store_id"`
+	ReplicaDelta float64 `json:"replica_delta"`
+	// SizeDelta is the expected change of the approximate size in MB.
+	SizeDelta float64 `json:"size_delta"`
+}
+
+// RuleChangeEstimate is the expected result of a rule change once the
+// cluster converges. The missing replicas are spread evenly over the stores
+// matching the rule, so the deltas can be fractional.
+type RuleChangeEstimate struct {
+	RegionCount int                  `json:"region_count"`
+	Stores      []*StoreReplicaDelta `json:"stores"`
+	// UnplaceableReplicas is the number of the replicas that no store can
+	// hold under the proposed rules.
+	UnplaceableReplicas int `json:"unplaceable_replicas"`
+}
+
+type ruleChangeEstimator struct {
+	stores      StoreSet
+	candidates  []*core.StoreInfo
+	deltas      map[uint64]*StoreReplicaDelta
+	regions     int
+	unplaceable int
+}
+
+func newRuleChangeEstimator(stores StoreSet) *ruleChangeEstimator {
+	var candidates []*core.StoreInfo
+	for _, s := range stores.GetStores() {
+		if s.IsUp() {
+			candidates = append(candidates, s)
+		}
+	}
+	return &ruleChangeEstimator{
+		stores:     stores,
+		candidates: candidates,
+		deltas:     make(map[uint64]*StoreReplicaDelta),
+	}
+}
+
+func (e *ruleChangeEstimator) addRegion(region *core.RegionInfo, current, proposed []*Rule) {
+	e.regions++
+	before, _ := e.estimateReplicas(region, current)
+	after, unplaceable := e.estimateReplicas(region, proposed)
+	e.unplaceable += unplaceable
+	for id, n := range after {
+		e.add(id, n-before[id], region.GetApproximateSize())
+	}
+	for id, n := range before {
+		if _, ok := after[id]; !ok {
+			e.add(id, -n, region.GetApproximateSize())
+		}
+	}
+}
+
+func (e *ruleChangeEstimator) add(storeID uint64, delta float64, size int64) {
+	if delta == 0 {
+		return
+	}
+	d := e.deltas[storeID]
+	if d == nil {
+		d = &StoreReplicaDelta{StoreID: storeID}
+		e.deltas[storeID] = d
+	}
+	d.ReplicaDelta += delta
+	d.SizeDelta += delta * float64(size)
+}
+
+// estimateReplicas returns the expected replicas of the region on each store
+// once the rules are satisfied, and the number of the replicas which can not
+// be placed. The peers fitting the rules stay, the orphan peers are removed
+// and the missing replicas are spread over the matched stores without a peer
+// of the region.
+func (e *ruleChangeEstimator) estimateReplicas(region *core.RegionInfo, rules []*Rule) (map[uint64]float64, int) {
+	replicas := make(map[uint64]float64)
+	if len(rules) == 0 {
+		return replicas, 0
+	}
+	fit := FitRegion(e.stores, region, rules)
+	for _, rf := range fit.RuleFits {
+		for _, p := range rf.Peers {
+			replicas[p.GetStoreId()]++
+		}
+	}
+	var unplaceable int
+	for _, rf := range fit.RuleFits {
+		missing := rf.Rule.Count - len(rf.Peers)
+		if missing <= 0 {
+			continue
+		}
+		var matched []*core.StoreInfo
+		for _, s := range e.candidates {
+			if region.GetStorePeer(s.GetID()) == nil && MatchLabelConstraints(s, rf.Rule.LabelConstraints) {
+				matched = append(matched, s)
+			}
+		}
+		if len(matched) < missing {
+			unplaceable += missing - len(matched)
+			missing = len(matched)
+		}
+		for _, s := range matched {
+			replicas[s.GetID()] += float64(missing) / float64(len(matched))
+		}
+	}
+	return replicas, unplaceable
+}
+
+func (e *ruleChangeEstimator) result() *RuleChangeEstimate {
+	r := &RuleChangeEstimate{
+		RegionCount:         e.regions,
+		Stores:              make([]*StoreReplicaDelta, 0, len(e.deltas)),
+		UnplaceableReplicas: e.unplaceable,
+	}
+	for _, d := range e.deltas {
+		r.Stores = append(r.Stores, d)
+	}
+	sort.Slice(r.Stores, func(i, j int) bool { return r.Stores[i].StoreID < r.Stores[j].StoreID })
+	return r
+}
@@ -269,6 +269,11 @@ type fitPeer struct {
 }
 
 func (p *fitPeer) matchRoleStrict(role PeerRoleType) bool {
+	// The role of a peer in joint consensus is not settled yet, so it matches
+	// no role until the region leaves the joint state.
+	if core.IsInJointState(p.Peer) {
+		return false
+	}
 	switch role {
 	case Voter: // Voter matches either Leader or Follower.
 		return !core.IsLearner(p.Peer)
@@ -0,0 +1,246 @@
+// Copyright 2021 TiKV Project Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package placement
+
+import (
+	"sync"
+	"time"
+	"unsafe"
+
+	"github.com/pingcap/kvproto/pkg/metapb"
+	"github.com/tikv/pd/server/core"
+)
+
+// LabelVersionedStoreSet is a StoreSet which tracks the changes of the labels
+// of the stores, so the fits with it can be cached.
+type LabelVersionedStoreSet interface {
+	StoreSet
+	// GetLabelVersion returns the version which is increased when the labels
+	// of any store change, or a store is added or deleted.
+	GetLabelVersion() uint64
+	// GetStoreLabelVersion returns the label version when the labels of the
+	// store change.
+	GetStoreLabelVersion(storeID uint64) uint64
+}
+
+// fitCacheEntry is a cached fit with what it depends on.
+type fitCacheEntry struct {
+	stores LabelVersionedStoreSet
+	leader uint64
+	peers  []*metapb.Peer
+	rules  []*Rule
+	// ruleVersions is the versions of the rules.
+	ruleVersions []uint64
+	// storeVersions is the label versions of the stores of the peers.
+	storeVersions []uint64
+	// ruleMatches is whether any store matches the label constraints of each
+	// rule, which decides whether the rule is fitted.
+	ruleMatches []bool
+	fit         *RegionFit
+	// lastUse is when the fit is cached or got last time.
+	lastUse time.Time
+}
+
+var (
+	fitCacheEntrySize = int64(unsafe.Sizeof(fitCacheEntry{}) + unsafe.Sizeof(RegionFit{}))
+	ruleFitSize       = int64(unsafe.Sizeof(RuleFit{}))
+	pointerSize       = int64(unsafe.Sizeof(uintptr(0)))
+)
+
+// estimateMemory returns the estimated memory used by the entry.
+func (e *fitCacheEntry) estimateMemory() int64 {
+	size := fitCacheEntrySize
+	size += int64(len(e.peers)) * 2 * pointerSize
+	size += int64(len(e.rules)) * (2*pointerSize + 1)
+	for _, rf := range e.fit.RuleFits {
+		size += ruleFitSize + int64(len(rf.Peers)+len(rf.PeersWithDifferentRole))*pointerSize
+	}
+	return size + int64(len(e.fit.OrphanPeers))*pointerSize
+}
+
+// regionFitCache caches the fits of the regions. A fit is only invalidated
+// if the peers of the region, the rules applied to it, the labels of the
+// stores of its peers, or whether any store matches its rules change, so
+// the changes of the labels of the other stores do not invalidate it.
+type regionFitCache struct {
+	sync.Mutex
+	entries map[uint64]*fitCacheEntry
+	// matches caches whether any store matches the label constraints of the
+	// rules, which is reset when the labels of any store change.
+	matches        map[*Rule]bool
+	matchesStores  LabelVersionedStoreSet
+	matchesVersion uint64
+	// ruleVersions is increased when a rule is set, since the rules may be
+	// modified in place.
+	ruleVersions    map[*Rule]uint64
+	nextRuleVersion uint64
+}
+
+func newRegionFitCache() *regionFitCache {
+	return &regionFitCache{
+		entries:      make(map[uint64]*fitCacheEntry),
+		matches:      make(map[*Rule]bool),
+		ruleVersions: make(map[*Rule]uint64),
+	}
+}
+
+// ruleMatchLocked returns whether any store matches the label constraints of
+// the rule.
+func (c *regionFitCache) ruleMatchLocked(stores LabelVersionedStoreSet, rule *Rule) bool {
+	if version := stores.GetLabelVersion(); c.matchesStores != stores || c.matchesVersion != version {
+		c.matches = make(map[*Rule]bool)
+		c.matchesStores, c.matchesVersion = stores, version
+	}
+	match, ok := c.matches[rule]
+	if !ok {
+		match = checkRule(rule, stores.GetStores())
+		c.matches[rule] = match
+	}
+	return match
+}
+
+func samePeers(a, b []*metapb.Peer) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i].GetId() != b[i].GetId() || a[i].GetStoreId() != b[i].GetStoreId() || a[i].GetRole() != b[i].GetRole() {
+			return false
+		}
+	}
+	return true
+}
+
+func sameRules(a, b []*Rule) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// get returns the cached fit of the region, or nil if it is invalidated.
+func (c *regionFitCache) get(stores LabelVersionedStoreSet, region *core.RegionInfo, rules []*Rule) *RegionFit {
+	c.Lock()
+	defer c.Unlock()
+	entry, ok := c.entries[region.GetID()]
+	if !ok || entry.stores != stores || entry.leader != region.GetLeader().GetId() ||
+		!samePeers(entry.peers, region.GetPeers()) || !sameRules(entry.rules, rules) {
+		return nil
+	}
+	for i, p := range entry.peers {
+		if stores.GetStoreLabelVersion(p.GetStoreId()) != entry.storeVersions[i] {
+			return nil
+		}
+	}
+	for i, rule := range entry.rules {
+		if c.ruleVersions[rule] != entry.ruleVersions[i] || c.ruleMatchLocked(stores, rule) != entry.ruleMatches[i] {
+			return nil
+		}
+	}
+	entry.lastUse = time.Now()
+	return entry.fit
+}
+
+// put caches the fit of the region. The versions are got before the fit, so
+// the fit is invalidated if the labels or the rules change during fitting.
+func (c *regionFitCache) put(entry *fitCacheEntry, region *core.RegionInfo) {
+	c.Lock()
+	defer c.Unlock()
+	entry.lastUse = time.Now()
+	c.entries[region.GetID()] = entry
+}
+
+// fit returns the fit of the region from the cache, or fits it and caches
+// the result.
+func (c *regionFitCache) fit(stores LabelVersionedStoreSet, region *core.RegionInfo, rules []*Rule) *RegionFit {
+	if fit := c.get(stores, region, rules); fit != nil {
+		return fit
+	}
+	entry := &fitCacheEntry{
+		stores:        stores,
+		leader:        region.GetLeader().GetId(),
+		peers:         region.GetPeers(),
+		rules:         rules,
+		ruleVersions:  make([]uint64, len(rules)),
+		storeVersions: make([]uint64, len(region.GetPeers())),
+		ruleMatches:   make([]bool, len(rules)),
+	}
+	for i, p := range entry.peers {
+		entry.storeVersions[i] = stores.GetStoreLabelVersion(p.GetStoreId())
+	}
+	c.Lock()
+	for i, rule := range rules {
+		entry.ruleVersions[i] = c.ruleVersions[rule]
+		entry.ruleMatches[i] = c.ruleMatchLocked(stores, rule)
+	}
+	c.Unlock()
+	entry.fit = FitRegion(stores, region, rules)
+	c.put(entry, region)
+	return entry.fit
+}
+
+// remove drops the cached fit of the region.
+func (c *regionFitCache) remove(regionID uint64) {
+	c.Lock()
+	defer c.Unlock()
+	delete(c.entries, regionID)
+}
+
+// evict drops the cached fits which are not used since the time, and returns
+// the number of them.
+func (c *regionFitCache) evict(before time.Time) int {
+	c.Lock()
+	defer c.Unlock()
+	var evicted int
+	for id, entry := range c.entries {
+		if entry.lastUse.Before(before) {
+			delete(c.entries, id)
+			evicted++
+		}
+	}
+	return evicted
+}
+
+// memoryUsage returns the estimated memory used by the cached fits.
+func (c *regionFitCache) memoryUsage() int64 {
+	c.Lock()
+	defer c.Unlock()
+	var size int64
+	for _, entry := range c.entries {
+		size += entry.estimateMemory()
+	}
+	return size
+}
+
+// updateRules increases the versions of the rules which are set, and drops
+// the versions and the cached matches of the rules which are removed.
+func (c *regionFitCache) updateRules(set []*Rule, rules map[[2]string]*Rule) {
+	c.Lock()
+	defer c.Unlock()
+	for _, rule := range set {
+		c.nextRuleVersion++
+		c.ruleVersions[rule] = c.nextRuleVersion
+	}
+	for rule := range c.ruleVersions {
+		if rules[rule.Key()] != rule {
+			delete(c.ruleVersions, rule)
+		}
+	}
+	c.matches = make(map[*Rule]bool)
+}
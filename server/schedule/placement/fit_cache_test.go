@@ -0,0 +1,124 @@
+// Copyright 2021 TiKV Project Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package placement
+
+import (
+	"time"
+
+	. "github.com/pingcap/check"
+	"github.com/pingcap/kvproto/pkg/metapb"
+	"github.com/tikv/pd/server/core"
+	"github.com/tikv/pd/server/kv"
+)
+
+var _ = Suite(&testFitCacheSuite{})
+
+type testFitCacheSuite struct{}
+
+// unversionedStores hides the label versions of the stores.
+type unversionedStores struct {
+	StoreSet
+}
+
+func (s *testFitCacheSuite) TestFitCache(c *C) {
+	manager := NewRuleManager(core.NewStorage(kv.NewMemoryKV()), nil)
+	c.Assert(manager.Initialize(3, []string{"zone"}), IsNil)
+	stores := core.NewStoresInfo()
+	setZone := func(id uint64, zone string) {
+		stores.SetStore(core.NewStoreInfoWithLabel(id, 0, map[string]string{"zone": zone}))
+	}
+	for id, zone := range []string{"z1", "z2", "z3", "z4"} {
+		setZone(uint64(id+1), zone)
+	}
+	peers := []*metapb.Peer{{Id: 11, StoreId: 1}, {Id: 12, StoreId: 2}, {Id: 13, StoreId: 3}}
+	region := core.NewRegionInfo(&metapb.Region{Id: 1, StartKey: []byte("a"), EndKey: []byte("b"), Peers: peers}, peers[0])
+
+	fit := manager.FitRegion(stores, region)
+	c.Assert(fit.IsSatisfied(), IsTrue)
+	c.Assert(manager.FitRegion(stores, region), Equals, fit)
+	// The changes of the stats do not invalidate the fit.
+	c.Assert(manager.FitRegion(stores, region.Clone(core.SetApproximateSize(100))), Equals, fit)
+	// The changes of the labels of the other stores do not invalidate the fit.
+	setZone(4, "z5")
+	c.Assert(manager.FitRegion(stores, region), Equals, fit)
+	// The changes of the rules out of the range do not invalidate the fit.
+	c.Assert(manager.SetRule(&Rule{GroupID: "other", ID: "other", StartKeyHex: "63", EndKeyHex: "64", Role: Voter, Count: 1}), IsNil)
+	c.Assert(manager.FitRegion(stores, region), Equals, fit)
+
+	// The changes of the labels of the stores of the peers invalidate the fit.
+	setZone(1, "z2")
+	newFit := manager.FitRegion(stores, region)
+	c.Assert(newFit, Not(Equals), fit)
+	c.Assert(newFit.RuleFits[0].IsolationScore < fit.RuleFits[0].IsolationScore, IsTrue)
+	fit = newFit
+	// The changes of the leader invalidate the fit.
+	fit = s.checkInvalidated(c, fit, manager.FitRegion(stores, region.Clone(core.WithLeader(peers[1]))))
+	c.Assert(manager.FitRegion(stores, region.Clone(core.WithLeader(peers[1]))), Equals, fit)
+	fit = s.checkInvalidated(c, fit, manager.FitRegion(stores, region))
+
+	// The rules modified in place invalidate the fit.
+	rule := manager.GetRule("pd", "default")
+	rule.Count = 4
+	c.Assert(manager.SetRule(rule), IsNil)
+	fit = s.checkInvalidated(c, fit, manager.FitRegion(stores, region))
+	c.Assert(fit.IsSatisfied(), IsFalse)
+
+	// The changes of whether any store matches a rule invalidate the fit.
+	c.Assert(manager.SetRule(&Rule{GroupID: "pd", ID: "z9", StartKeyHex: "61", EndKeyHex: "62", Role: Learner, Count: 1,
+		LabelConstraints: []LabelConstraint{{Key: "zone", Op: In, Values: []string{"z9"}}}}), IsNil)
+	fit = s.checkInvalidated(c, fit, manager.FitRegion(stores, region))
+	c.Assert(manager.FitRegion(stores, region), Equals, fit)
+	setZone(4, "z9")
+	fit = s.checkInvalidated(c, fit, manager.FitRegion(stores, region))
+
+	manager.RemoveRegionFit(1)
+	fit = s.checkInvalidated(c, fit, manager.FitRegion(stores, region))
+	// The fits with the stores which do not track the labels are not cached.
+	c.Assert(manager.FitRegion(unversionedStores{stores}, region), Not(Equals), manager.FitRegion(unversionedStores{stores}, region))
+	c.Assert(manager.FitRegion(stores, region), Equals, fit)
+}
+
+func (s *testFitCacheSuite) TestEvictFits(c *C) {
+	manager := NewRuleManager(core.NewStorage(kv.NewMemoryKV()), nil)
+	c.Assert(manager.Initialize(3, []string{"zone"}), IsNil)
+	stores := core.NewStoresInfo()
+	for id, zone := range []string{"z1", "z2", "z3"} {
+		stores.SetStore(core.NewStoreInfoWithLabel(uint64(id+1), 0, map[string]string{"zone": zone}))
+	}
+	newRegion := func(id uint64) *core.RegionInfo {
+		peers := []*metapb.Peer{{Id: id*10 + 1, StoreId: 1}, {Id: id*10 + 2, StoreId: 2}, {Id: id*10 + 3, StoreId: 3}}
+		return core.NewRegionInfo(&metapb.Region{Id: id, Peers: peers}, peers[0])
+	}
+	c.Assert(manager.GetRegionFitsMemoryUsage(), Equals, int64(0))
+	fit1 := manager.FitRegion(stores, newRegion(1))
+	usage := manager.GetRegionFitsMemoryUsage()
+	c.Assert(usage, Greater, int64(0))
+	time.Sleep(10 * time.Millisecond)
+	before := time.Now()
+	fit2 := manager.FitRegion(stores, newRegion(2))
+	c.Assert(manager.GetRegionFitsMemoryUsage(), Equals, 2*usage)
+
+	// Only the fits which are not used since the time are evicted.
+	c.Assert(manager.EvictRegionFits(before), Equals, 1)
+	c.Assert(manager.GetRegionFitsMemoryUsage(), Equals, usage)
+	c.Assert(manager.FitRegion(stores, newRegion(2)), Equals, fit2)
+	c.Assert(manager.FitRegion(stores, newRegion(1)), Not(Equals), fit1)
+	c.Assert(manager.EvictRegionFits(time.Now().Add(time.Second)), Equals, 2)
+	c.Assert(manager.GetRegionFitsMemoryUsage(), Equals, int64(0))
+}
+
+func (s *testFitCacheSuite) checkInvalidated(c *C, old, fit *RegionFit) *RegionFit {
+	c.Assert(fit, Not(Equals), old)
+	return fit
+}
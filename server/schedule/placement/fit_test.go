@@ -155,6 +155,23 @@ func (s *testFitSuite) TestFitRegion(c *C) {
 	}
 }
 
+func (s *testFitSuite) TestFitJointState(c *C) {
+	stores := s.makeStores()
+	rules := []*Rule{s.makeRule("3/voter//")}
+
+	region := s.makeRegion("1111_leader,1112,1113")
+	c.Assert(FitRegion(stores, region, rules).IsSatisfied(), IsTrue)
+
+	// The peers in joint consensus do not match any role.
+	for _, role := range []metapb.PeerRole{metapb.PeerRole_IncomingVoter, metapb.PeerRole_DemotingVoter} {
+		peers := region.GetPeers()
+		peers[2].Role = role
+		rf := FitRegion(stores, region, rules)
+		c.Assert(rf.IsSatisfied(), IsFalse)
+		c.Assert(s.checkPeerMatch(rf.RuleFits[0].PeersWithDifferentRole, "1113"), IsTrue)
+	}
+}
+
 func (s *testFitSuite) TestIsolationScore(c *C) {
 	stores := s.makeStores()
 	testCases := []struct {
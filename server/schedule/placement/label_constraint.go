@@ -42,25 +42,32 @@ func validateOp(op LabelConstraintOp) bool {
 }
 
 // LabelConstraint is used to filter store when trying to place peer of a region.
+// If ResourceTag is true, the constraint matches the resource tag of the store
+// instead of the label.
 type LabelConstraint struct {
-	Key    string            `json:"key,omitempty"`
-	Op     LabelConstraintOp `json:"op,omitempty"`
-	Values []string          `json:"values,omitempty"`
+	Key         string            `json:"key,omitempty"`
+	Op          LabelConstraintOp `json:"op,omitempty"`
+	Values      []string          `json:"values,omitempty"`
+	ResourceTag bool              `json:"resource_tag,omitempty"`
 }
 
 // MatchStore checks if a store matches the constraint.
 func (c *LabelConstraint) MatchStore(store *core.StoreInfo) bool {
+	var value string
+	if c.ResourceTag {
+		value = store.GetResourceTag(c.Key)
+	} else {
+		value = store.GetLabelValue(c.Key)
+	}
 	switch c.Op {
 	case In:
-		label := store.GetLabelValue(c.Key)
-		return label != "" && slice.AnyOf(c.Values, func(i int) bool { return c.Values[i] == label })
+		return value != "" && slice.AnyOf(c.Values, func(i int) bool { return c.Values[i] == value })
 	case NotIn:
-		label := store.GetLabelValue(c.Key)
-		return label == "" || slice.NoneOf(c.Values, func(i int) bool { return c.Values[i] == label })
+		return value == "" || slice.NoneOf(c.Values, func(i int) bool { return c.Values[i] == value })
 	case Exists:
-		return store.GetLabelValue(c.Key) != ""
+		return value != ""
 	case NotExists:
-		return store.GetLabelValue(c.Key) == ""
+		return value == ""
 	}
 	return false
 }
@@ -84,7 +91,7 @@ func MatchLabelConstraints(store *core.StoreInfo, constraints []LabelConstraint)
 
 	for _, l := range store.GetLabels() {
 		if isExclusiveLabel(l.GetKey()) &&
-			slice.NoneOf(constraints, func(i int) bool { return constraints[i].Key == l.GetKey() }) {
+			slice.NoneOf(constraints, func(i int) bool { return !constraints[i].ResourceTag && constraints[i].Key == l.GetKey() }) {
 			return false
 		}
 	}
@@ -64,6 +64,20 @@ func (s *testLabelConstraintsSuite) TestLabelConstraint(c *C) {
 	}
 }
 
+func (s *testLabelConstraintsSuite) TestResourceTagConstraint(c *C) {
+	store := core.NewStoreInfoWithLabel(1, 0, map[string]string{"disk": "hdd", "engine": "tiflash"})
+	store = store.Clone(core.SetResourceTags(map[string]string{"disk": "nvme"}))
+	c.Assert((&LabelConstraint{Key: "disk", Op: "in", Values: []string{"nvme"}, ResourceTag: true}).MatchStore(store), IsTrue)
+	c.Assert((&LabelConstraint{Key: "disk", Op: "in", Values: []string{"nvme"}}).MatchStore(store), IsFalse)
+	c.Assert((&LabelConstraint{Key: "cpu", Op: "notExists", ResourceTag: true}).MatchStore(store), IsTrue)
+	// A resource tag constraint doesn't select the store with an exclusive label.
+	constraints := []LabelConstraint{{Key: "engine", Op: "exists", ResourceTag: true}}
+	c.Assert(MatchLabelConstraints(store, constraints), IsFalse)
+	constraints = append(constraints, LabelConstraint{Key: "engine", Op: "in", Values: []string{"tiflash"}})
+	store = store.Clone(core.SetResourceTags(map[string]string{"engine": "x"}))
+	c.Assert(MatchLabelConstraints(store, constraints), IsTrue)
+}
+
 func (s *testLabelConstraintsSuite) TestLabelConstraints(c *C) {
 	stores := []map[string]string{
 		{},                                       // 1
@@ -22,6 +22,7 @@ import (
 	"sort"
 	"strings"
 	"sync"
+	"time"
 
 	"github.com/pingcap/errors"
 	"github.com/pingcap/log"
@@ -43,6 +44,12 @@ type RuleManager struct {
 	// used for rule validation
 	keyType          string
 	storeSetInformer core.StoreSetInformer
+
+	// changeObserver is notified with the keys of changed rules and the IDs
+	// of changed groups after the changes are committed.
+	changeObserver func(rules [][2]string, groups []string)
+
+	fitCache *regionFitCache
 }
 
 // NewRuleManager creates a RuleManager instance.
@@ -51,6 +58,7 @@ func NewRuleManager(storage *core.Storage, storeSetInformer core.StoreSetInforme
 		storage:          storage,
 		storeSetInformer: storeSetInformer,
 		ruleConfig:       newRuleConfig(),
+		fitCache:         newRegionFitCache(),
 	}
 }
 
@@ -295,12 +303,33 @@ func (m *RuleManager) GetRulesForApplyRegion(region *core.RegionInfo) []*Rule {
 	return m.ruleList.getRulesForApplyRegion(region.GetStartKey(), region.GetEndKey())
 }
 
-// FitRegion fits a region to the rules it matches.
+// FitRegion fits a region to the rules it matches. The fit is cached if the
+// stores track the changes of their labels.
 func (m *RuleManager) FitRegion(stores StoreSet, region *core.RegionInfo) *RegionFit {
 	rules := m.GetRulesForApplyRegion(region)
+	if versioned, ok := stores.(LabelVersionedStoreSet); ok {
+		return m.fitCache.fit(versioned, region, rules)
+	}
 	return FitRegion(stores, region, rules)
 }
 
+// RemoveRegionFit drops the cached fit of a region which is removed.
+func (m *RuleManager) RemoveRegionFit(regionID uint64) {
+	m.fitCache.remove(regionID)
+}
+
+// EvictRegionFits drops the cached fits which are not used since the time,
+// and returns the number of them. They are fitted again when needed.
+func (m *RuleManager) EvictRegionFits(before time.Time) int {
+	return m.fitCache.evict(before)
+}
+
+// GetRegionFitsMemoryUsage returns the estimated memory used by the cached
+// fits.
+func (m *RuleManager) GetRegionFitsMemoryUsage() int64 {
+	return m.fitCache.memoryUsage()
+}
+
 func (m *RuleManager) beginPatch() *ruleConfigPatch {
 	return m.ruleConfig.beginPatch()
 }
@@ -313,6 +342,14 @@ func (m *RuleManager) tryCommitPatch(patch *ruleConfigPatch) error {
 		return err
 	}
 
+	// The rules are collected before trimming, since a rule modified in place
+	// equals to itself.
+	var set []*Rule
+	for _, r := range patch.mut.rules {
+		if r != nil {
+			set = append(set, r)
+		}
+	}
 	patch.trim()
 
 	// save updates
@@ -324,9 +361,35 @@ func (m *RuleManager) tryCommitPatch(patch *ruleConfigPatch) error {
 	// update in-memory state
 	patch.commit()
 	m.ruleList = ruleList
+	m.fitCache.updateRules(set, m.ruleConfig.rules)
+	m.notifyChange(patch.mut)
 	return nil
 }
 
+// SetChangeObserver sets a function which is called with the changed rules
+// and groups once the changes are committed. It is called with the lock held,
+// so it must not call back into the RuleManager.
+func (m *RuleManager) SetChangeObserver(f func(rules [][2]string, groups []string)) {
+	m.Lock()
+	defer m.Unlock()
+	m.changeObserver = f
+}
+
+func (m *RuleManager) notifyChange(p *ruleConfig) {
+	if m.changeObserver == nil || (len(p.rules) == 0 && len(p.groups) == 0) {
+		return
+	}
+	rules := make([][2]string, 0, len(p.rules))
+	for key := range p.rules {
+		rules = append(rules, key)
+	}
+	groups := make([]string, 0, len(p.groups))
+	for id := range p.groups {
+		groups = append(groups, id)
+	}
+	m.changeObserver(rules, groups)
+}
+
 func (m *RuleManager) savePatch(p *ruleConfig) error {
 	// TODO: it is not completely safe
 	// 1. in case that half of rules applied, error.. we have to cancel persisted rules
@@ -580,6 +643,67 @@ func (m *RuleManager) SetAllGroupBundles(groups []GroupBundle, override bool) er
 	return nil
 }
 
+// previewGroupBundles builds the rule list as if the bundles were set by
+// SetAllGroupBundles, without changing the current configuration.
+func (m *RuleManager) previewGroupBundles(groups []GroupBundle, override bool) (ruleList, error) {
+	m.RLock()
+	defer m.RUnlock()
+	matchID := func(a string) bool {
+		for _, g := range groups {
+			if g.ID == a {
+				return true
+			}
+		}
+		return false
+	}
+	// The rules are copied because `adjust` resets the groups of them.
+	c := newRuleConfig()
+	for k, r := range m.ruleConfig.rules {
+		if !override && !matchID(k[0]) {
+			rule := *r
+			c.setRule(&rule)
+		}
+	}
+	for id, g := range m.ruleConfig.groups {
+		if !override && !matchID(id) {
+			group := *g
+			c.setGroup(&group)
+		}
+	}
+	for _, g := range groups {
+		c.setGroup(&RuleGroup{
+			ID:       g.ID,
+			Index:    g.Index,
+			Override: g.Override,
+		})
+		for _, r := range g.Rules {
+			rule := *r
+			if err := m.adjustRule(&rule, g.ID); err != nil {
+				return ruleList{}, err
+			}
+			c.setRule(&rule)
+		}
+	}
+	c.adjust()
+	return buildRuleList(c)
+}
+
+// EstimateGroupBundles estimates the replica changes of the stores once the
+// cluster converges to the rules set by SetAllGroupBundles with the same
+// arguments. It is computed offline from the regions and the rule fits.
+func (m *RuleManager) EstimateGroupBundles(stores StoreSet, regions []*core.RegionInfo, groups []GroupBundle, override bool) (*RuleChangeEstimate, error) {
+	proposed, err := m.previewGroupBundles(groups, override)
+	if err != nil {
+		return nil, err
+	}
+	e := newRuleChangeEstimator(stores)
+	for _, region := range regions {
+		start, end := region.GetStartKey(), region.GetEndKey()
+		e.addRegion(region, m.GetRulesForApplyRegion(region), proposed.getRulesForApplyRegion(start, end))
+	}
+	return e.result(), nil
+}
+
 // SetGroupBundle resets a Group and all rules belong to it. All old rules
 // belong to the Group are dropped.
 func (m *RuleManager) SetGroupBundle(group GroupBundle) error {
@@ -352,6 +352,41 @@ func (s *testManagerSuite) TestCheckApplyRules(c *C) {
 	c.Assert(err, ErrorMatches, "needs at least one leader or voter")
 }
 
+func (s *testManagerSuite) TestEstimateGroupBundles(c *C) {
+	stores := core.NewStoresInfo()
+	for id, zone := range map[uint64]string{1: "z1", 2: "z1", 3: "z1", 4: "z2"} {
+		stores.SetStore(core.NewStoreInfoWithLabel(id, 0, map[string]string{"zone": zone}))
+	}
+	meta := &metapb.Region{Id: 1, Peers: []*metapb.Peer{{Id: 1, StoreId: 1}, {Id: 2, StoreId: 2}, {Id: 3, StoreId: 3}}}
+	regions := []*core.RegionInfo{core.NewRegionInfo(meta, meta.Peers[0], core.SetApproximateSize(10))}
+
+	// Add a learner in z2 and a learner in z3, which has no store.
+	bundle := GroupBundle{ID: "pd", Rules: []*Rule{
+		{ID: "default", Role: Voter, Count: 3},
+		{ID: "z2", Role: Learner, Count: 1, LabelConstraints: []LabelConstraint{{Key: "zone", Op: In, Values: []string{"z2"}}}},
+		{ID: "z3", Role: Learner, Count: 1, LabelConstraints: []LabelConstraint{{Key: "zone", Op: In, Values: []string{"z3"}}}},
+	}}
+	estimate, err := s.manager.EstimateGroupBundles(stores, regions, []GroupBundle{bundle}, false)
+	c.Assert(err, IsNil)
+	c.Assert(estimate.RegionCount, Equals, 1)
+	c.Assert(estimate.UnplaceableReplicas, Equals, 1)
+	c.Assert(estimate.Stores, DeepEquals, []*StoreReplicaDelta{{StoreID: 4, ReplicaDelta: 1, SizeDelta: 10}})
+	// The current rules are not changed.
+	c.Assert(s.manager.GetAllRules(), HasLen, 1)
+
+	// Reduce the voters to 2, so the orphan peer is removed.
+	bundle = GroupBundle{ID: "pd", Rules: []*Rule{{ID: "default", Role: Voter, Count: 2}}}
+	estimate, err = s.manager.EstimateGroupBundles(stores, regions, []GroupBundle{bundle}, true)
+	c.Assert(err, IsNil)
+	c.Assert(estimate.Stores, HasLen, 1)
+	c.Assert(estimate.Stores[0].ReplicaDelta, Equals, -1.0)
+	c.Assert(estimate.Stores[0].SizeDelta, Equals, -10.0)
+
+	bundle.Rules[0].Count = 0
+	_, err = s.manager.EstimateGroupBundles(stores, regions, []GroupBundle{bundle}, true)
+	c.Assert(err, NotNil)
+}
+
 func (s *testManagerSuite) dhex(hk string) []byte {
 	k, err := hex.DecodeString(hk)
 	if err != nil {
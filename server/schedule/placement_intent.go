@@ -0,0 +1,95 @@
+// Copyright 2021 TiKV Project Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package schedule
+
+import (
+	"context"
+	"time"
+
+	"github.com/pingcap/kvproto/pkg/metapb"
+	"github.com/tikv/pd/pkg/cache"
+)
+
+// ScatterCoolDownTime is how long the balance schedulers keep away from a
+// region after it is scattered.
+var ScatterCoolDownTime = 10 * time.Minute
+
+// placementIntent is the distribution a region is intended to have.
+type placementIntent struct {
+	stores map[uint64]struct{}
+	leader uint64
+}
+
+// PlacementIntents records the distributions the scatterer has chosen for the
+// regions. The balance schedulers consult it to avoid moving the peers and the
+// leaders of the freshly scattered regions away from the stores they are
+// scattered to, otherwise the scatter and the balance could fight each other.
+type PlacementIntents struct {
+	intents *cache.TTLUint64 // region ID -> *placementIntent
+}
+
+// NewPlacementIntents creates a PlacementIntents.
+func NewPlacementIntents(ctx context.Context) *PlacementIntents {
+	return &PlacementIntents{
+		intents: cache.NewIDTTL(ctx, time.Minute, ScatterCoolDownTime),
+	}
+}
+
+// Register records the intended distribution of a region for the cool-down
+// period.
+func (p *PlacementIntents) Register(regionID uint64, peers map[uint64]*metapb.Peer, leaderStoreID uint64) {
+	intent := &placementIntent{
+		stores: make(map[uint64]struct{}, len(peers)),
+		leader: leaderStoreID,
+	}
+	for storeID := range peers {
+		intent.stores[storeID] = struct{}{}
+	}
+	p.intents.PutWithTTL(regionID, intent, ScatterCoolDownTime)
+}
+
+func (p *PlacementIntents) get(regionID uint64) *placementIntent {
+	if v, ok := p.intents.Get(regionID); ok {
+		return v.(*placementIntent)
+	}
+	return nil
+}
+
+// IsCoolingDown returns whether the region is scattered recently.
+func (p *PlacementIntents) IsCoolingDown(regionID uint64) bool {
+	return p.intents.Exists(regionID)
+}
+
+// IsPeerIntended returns whether the region is scattered to the store recently,
+// so the peer on the store should not be moved away.
+func (p *PlacementIntents) IsPeerIntended(regionID, storeID uint64) bool {
+	intent := p.get(regionID)
+	if intent == nil {
+		return false
+	}
+	_, ok := intent.stores[storeID]
+	return ok
+}
+
+// IsLeaderIntended returns whether the leader of the region is scattered to the
+// store recently, so the leader should not be transferred away.
+func (p *PlacementIntents) IsLeaderIntended(regionID, storeID uint64) bool {
+	intent := p.get(regionID)
+	return intent != nil && intent.leader == storeID
+}
+
+// Remove removes the intent of the region.
+func (p *PlacementIntents) Remove(regionID uint64) {
+	p.intents.Remove(regionID)
+}
@@ -120,17 +120,22 @@ type RegionScatterer struct {
 	cluster        opt.Cluster
 	ordinaryEngine engineContext
 	specialEngines map[string]engineContext
+	// intents is used to keep the balance schedulers away from the regions
+	// scattered recently. It may be nil.
+	intents *PlacementIntents
 }
 
 // NewRegionScatterer creates a region scatterer.
 // RegionScatter is used for the `Lightning`, it will scatter the specified regions before import data.
-func NewRegionScatterer(ctx context.Context, cluster opt.Cluster) *RegionScatterer {
+// The distributions of the scattered regions are registered into the intents if it is not nil.
+func NewRegionScatterer(ctx context.Context, cluster opt.Cluster, intents *PlacementIntents) *RegionScatterer {
 	return &RegionScatterer{
 		ctx:            ctx,
 		name:           regionScatterName,
 		cluster:        cluster,
 		ordinaryEngine: newEngineContext(ctx, filter.NewOrdinaryEngineFilter(regionScatterName)),
 		specialEngines: make(map[string]engineContext),
+		intents:        intents,
 	}
 }
 
@@ -328,6 +333,9 @@ func (r *RegionScatterer) scatterRegion(region *core.RegionInfo, group string) *
 	if op != nil {
 		scatterCounter.WithLabelValues("success", "").Inc()
 		r.Put(targetPeers, targetLeader, group)
+		if r.intents != nil {
+			r.intents.Register(region.GetID(), targetPeers, targetLeader)
+		}
 		op.SetPriorityLevel(core.HighPriority)
 	}
 	return op
@@ -102,7 +102,7 @@ func (s *testScatterRegionSuite) scatter(c *C, numStores, numRegions uint64, use
 		// region distributed in same stores.
 		tc.AddLeaderRegion(i, 1, 2, 3)
 	}
-	scatterer := NewRegionScatterer(ctx, tc)
+	scatterer := NewRegionScatterer(ctx, tc, nil)
 
 	for i := uint64(1); i <= numRegions; i++ {
 		region := tc.GetRegion(i)
@@ -170,7 +170,7 @@ func (s *testScatterRegionSuite) scatterSpecial(c *C, numOrdinaryStores, numSpec
 			[]uint64{numOrdinaryStores + 1, numOrdinaryStores + 2, numOrdinaryStores + 3},
 		)
 	}
-	scatterer := NewRegionScatterer(ctx, tc)
+	scatterer := NewRegionScatterer(ctx, tc, nil)
 
 	for i := uint64(1); i <= numRegions; i++ {
 		region := tc.GetRegion(i)
@@ -236,7 +236,7 @@ func (s *testScatterRegionSuite) TestStoreLimit(c *C) {
 		tc.AddLeaderRegion(i, seq.next(), seq.next(), seq.next())
 	}
 
-	scatterer := NewRegionScatterer(ctx, tc)
+	scatterer := NewRegionScatterer(ctx, tc, nil)
 
 	for i := uint64(1); i <= 5; i++ {
 		region := tc.GetRegion(i)
@@ -278,7 +278,7 @@ func (s *testScatterRegionSuite) TestScatterCheck(c *C) {
 	}
 	for _, testcase := range testcases {
 		c.Logf(testcase.name)
-		scatterer := NewRegionScatterer(ctx, tc)
+		scatterer := NewRegionScatterer(ctx, tc, nil)
 		_, err := scatterer.Scatter(testcase.checkRegion, "")
 		if testcase.needFix {
 			c.Assert(err, NotNil)
@@ -322,7 +322,7 @@ func (s *testScatterRegionSuite) TestScatterGroupInConcurrency(c *C) {
 	// We send scatter interweave request for each group to simulate scattering multiple region groups in concurrency.
 	for _, testcase := range testcases {
 		c.Logf(testcase.name)
-		scatterer := NewRegionScatterer(ctx, tc)
+		scatterer := NewRegionScatterer(ctx, tc, nil)
 		regionID := 1
 		for i := 0; i < 100; i++ {
 			for j := 0; j < testcase.groupCount; j++ {
@@ -382,7 +382,7 @@ func (s *testScatterRegionSuite) TestScattersGroup(c *C) {
 	}
 	group := "group"
 	for _, testcase := range testcases {
-		scatterer := NewRegionScatterer(ctx, tc)
+		scatterer := NewRegionScatterer(ctx, tc, nil)
 		regions := map[uint64]*core.RegionInfo{}
 		for i := 1; i <= 100; i++ {
 			regions[uint64(i)] = tc.AddLeaderRegion(uint64(i), 1, 2, 3)
@@ -452,7 +452,7 @@ func (s *testScatterRegionSuite) TestRegionFromDifferentGroups(c *C) {
 	for i := uint64(1); i <= uint64(storeCount); i++ {
 		tc.AddRegionStore(i, 0)
 	}
-	scatterer := NewRegionScatterer(ctx, tc)
+	scatterer := NewRegionScatterer(ctx, tc, nil)
 	regionCount := 50
 	for i := 1; i <= regionCount; i++ {
 		p := rand.Perm(storeCount)
@@ -474,3 +474,31 @@ func (s *testScatterRegionSuite) TestRegionFromDifferentGroups(c *C) {
 	}
 	check(scatterer.ordinaryEngine.selectedPeer)
 }
+
+func (s *testScatterRegionSuite) TestPlacementIntents(c *C) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	opt := config.NewTestOptions()
+	tc := mockcluster.NewCluster(ctx, opt)
+	tc.DisableFeature(versioninfo.JointConsensus)
+	for i := uint64(1); i <= 6; i++ {
+		tc.AddRegionStore(i, 0)
+	}
+	intents := NewPlacementIntents(ctx)
+	scatterer := NewRegionScatterer(ctx, tc, intents)
+	for i := uint64(1); i <= 10; i++ {
+		region := tc.AddLeaderRegion(i, 1, 2, 3)
+		op := scatterer.scatterRegion(region, "")
+		if op == nil {
+			c.Assert(intents.IsCoolingDown(i), IsFalse)
+			continue
+		}
+		c.Assert(intents.IsCoolingDown(i), IsTrue)
+		ApplyOperator(tc, op)
+		region = tc.GetRegion(i)
+		for _, peer := range region.GetPeers() {
+			c.Assert(intents.IsPeerIntended(i, peer.GetStoreId()), IsTrue)
+		}
+		c.Assert(intents.IsLeaderIntended(i, region.GetLeader().GetStoreId()), IsTrue)
+	}
+}
@@ -26,6 +26,7 @@ import (
 	"github.com/tikv/pd/pkg/logutil"
 	"github.com/tikv/pd/pkg/typeutil"
 	"github.com/tikv/pd/server/core"
+	"github.com/tikv/pd/server/schedule/filter"
 	"github.com/tikv/pd/server/schedule/operator"
 	"github.com/tikv/pd/server/schedule/opt"
 	"go.uber.org/zap"
@@ -175,13 +176,59 @@ func (r *RegionSplitter) checkRegionValid(region *core.RegionInfo) bool {
 	return true
 }
 
+// CreateSplitRegionOperator creates an operator to split the region. When split leader
+// transfer is enabled, the operator also transfers the leader of one half to the
+// least-loaded follower store, so that both halves don't stay on the same hot store.
+func CreateSplitRegionOperator(desc string, cluster opt.Cluster, region *core.RegionInfo, kind operator.OpKind, policy pdpb.CheckPolicy, keys [][]byte) (*operator.Operator, error) {
+	if cluster.GetOpts().IsSplitLeaderTransferEnabled() {
+		if target := selectSplitLeaderTarget(cluster, region); target != nil {
+			return operator.CreateSplitRegionWithTransferLeaderOperator(desc, region, kind, policy, keys, target.GetID())
+		}
+	}
+	return operator.CreateSplitRegionOperator(desc, region, kind, policy, keys)
+}
+
+// selectSplitLeaderTarget picks the follower store with the lowest leader score, and
+// only returns it if it is less loaded than the current leader store. The followers
+// whose peers are down or pending are skipped, as they are not able to take over
+// the leadership in time.
+func selectSplitLeaderTarget(cluster opt.Cluster, region *core.RegionInfo) *core.StoreInfo {
+	leaderStore := cluster.GetStore(region.GetLeader().GetStoreId())
+	if leaderStore == nil {
+		return nil
+	}
+	unhealthy := make(map[uint64]struct{})
+	for _, p := range region.GetDownPeers() {
+		unhealthy[p.GetPeer().GetStoreId()] = struct{}{}
+	}
+	for _, p := range region.GetPendingPeers() {
+		unhealthy[p.GetStoreId()] = struct{}{}
+	}
+	filters := []filter.Filter{
+		&filter.StoreStateFilter{ActionScope: "split-leader", TransferLeader: true},
+		filter.NewExcludedFilter("split-leader", nil, unhealthy),
+	}
+	if f := filter.NewPlacementLeaderSafeguard("split-leader", cluster, region, leaderStore); f != nil {
+		filters = append(filters, f)
+	}
+	less := filter.LeaderScoreComparer(cluster.GetOpts())
+	target := filter.NewCandidates(cluster.GetFollowerStores(region)).
+		FilterTarget(cluster.GetOpts(), filters...).
+		Sort(less).
+		PickFirst()
+	if target == nil || less(target, leaderStore) >= 0 {
+		return nil
+	}
+	return target
+}
+
 type splitRegionsHandler struct {
 	cluster opt.Cluster
 	oc      *OperatorController
 }
 
 func (h *splitRegionsHandler) SplitRegionByKeys(region *core.RegionInfo, splitKeys [][]byte) error {
-	op, err := operator.CreateSplitRegionOperator("region-splitter", region, 0, pdpb.CheckPolicy_USEKEY, splitKeys)
+	op, err := CreateSplitRegionOperator("region-splitter", h.cluster, region, 0, pdpb.CheckPolicy_USEKEY, splitKeys)
 	if err != nil {
 		return err
 	}
@@ -18,9 +18,12 @@ import (
 	"context"
 
 	. "github.com/pingcap/check"
+	"github.com/pingcap/kvproto/pkg/metapb"
+	"github.com/pingcap/kvproto/pkg/pdpb"
 	"github.com/tikv/pd/pkg/mock/mockcluster"
 	"github.com/tikv/pd/server/config"
 	"github.com/tikv/pd/server/core"
+	"github.com/tikv/pd/server/schedule/operator"
 )
 
 type mockSplitRegionsHandler struct {
@@ -131,3 +134,48 @@ func (s *testRegionSplitterSuite) TestGroupKeysByRegion(c *C) {
 		}
 	}
 }
+
+func (s *testRegionSplitterSuite) TestCreateSplitRegionOperator(c *C) {
+	opt := config.NewTestOptions()
+	opt.SetPlacementRuleEnabled(false)
+	tc := mockcluster.NewCluster(s.ctx, opt)
+	tc.AddLeaderStore(1, 10)
+	tc.AddLeaderStore(2, 5)
+	tc.AddLeaderStore(3, 1)
+	region := tc.AddLeaderRegion(1, 1, 2, 3)
+
+	// Split leader transfer is disabled by default.
+	op, err := CreateSplitRegionOperator("test", tc, region, 0, pdpb.CheckPolicy_APPROXIMATE, nil)
+	c.Assert(err, IsNil)
+	c.Assert(op.Len(), Equals, 1)
+
+	tc.SetEnableSplitLeaderTransfer(true)
+	op, err = CreateSplitRegionOperator("test", tc, region, 0, pdpb.CheckPolicy_APPROXIMATE, nil)
+	c.Assert(err, IsNil)
+	c.Assert(op.Len(), Equals, 2)
+	c.Assert(op.Kind()&operator.OpLeader, Equals, operator.OpLeader)
+	c.Assert(op.Step(1), DeepEquals, operator.TransferLeader{FromStore: 1, ToStore: 3})
+
+	// The least-loaded follower is not eligible.
+	tc.SetStoreDown(3)
+	op, err = CreateSplitRegionOperator("test", tc, region, 0, pdpb.CheckPolicy_APPROXIMATE, nil)
+	c.Assert(err, IsNil)
+	c.Assert(op.Step(1), DeepEquals, operator.TransferLeader{FromStore: 1, ToStore: 2})
+
+	// The followers with down or pending peers are skipped.
+	tc.SetStoreUp(3)
+	downRegion := region.Clone(core.WithDownPeers([]*pdpb.PeerStats{{Peer: region.GetStorePeer(3), DownSeconds: 60}}))
+	op, err = CreateSplitRegionOperator("test", tc, downRegion, 0, pdpb.CheckPolicy_APPROXIMATE, nil)
+	c.Assert(err, IsNil)
+	c.Assert(op.Step(1), DeepEquals, operator.TransferLeader{FromStore: 1, ToStore: 2})
+	pendingRegion := region.Clone(core.WithPendingPeers([]*metapb.Peer{region.GetStorePeer(2), region.GetStorePeer(3)}))
+	op, err = CreateSplitRegionOperator("test", tc, pendingRegion, 0, pdpb.CheckPolicy_APPROXIMATE, nil)
+	c.Assert(err, IsNil)
+	c.Assert(op.Len(), Equals, 1)
+
+	// No follower is less loaded than the leader.
+	tc.UpdateLeaderCount(1, 0)
+	op, err = CreateSplitRegionOperator("test", tc, region, 0, pdpb.CheckPolicy_APPROXIMATE, nil)
+	c.Assert(err, IsNil)
+	c.Assert(op.Len(), Equals, 1)
+}
@@ -0,0 +1,83 @@
+// Copyright 2021 TiKV Project Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package schedule
+
+import (
+	"sort"
+	"sync"
+	"time"
+)
+
+// snapshotHintTTL is how long a snapshot hint is published if the operator
+// is not finished.
+const snapshotHintTTL = 10 * time.Minute
+
+// SnapshotHint suggests the leader of a region to send the snapshot of the
+// new learner from the source peer, which is in the same zone as the learner.
+type SnapshotHint struct {
+	RegionID     uint64 `json:"region_id"`
+	PeerID       uint64 `json:"peer_id"`
+	ToStore      uint64 `json:"to_store"`
+	SourcePeerID uint64 `json:"source_peer_id"`
+	SourceStore  uint64 `json:"source_store"`
+	leaderStore  uint64
+	expireTime   time.Time
+}
+
+// snapshotHints records the snapshot hints of the running operators.
+type snapshotHints struct {
+	sync.Mutex
+	hints map[uint64]*SnapshotHint
+}
+
+func newSnapshotHints() *snapshotHints {
+	return &snapshotHints{hints: make(map[uint64]*SnapshotHint)}
+}
+
+func (h *snapshotHints) put(hint *SnapshotHint) {
+	h.Lock()
+	defer h.Unlock()
+	h.hints[hint.RegionID] = hint
+}
+
+func (h *snapshotHints) remove(regionID uint64) {
+	h.Lock()
+	defer h.Unlock()
+	delete(h.hints, regionID)
+}
+
+// get returns the hints of the regions led by the store ordered by the region
+// IDs. The expired hints are removed.
+func (h *snapshotHints) get(storeID uint64, now time.Time) []*SnapshotHint {
+	h.Lock()
+	defer h.Unlock()
+	var hints []*SnapshotHint
+	for regionID, hint := range h.hints {
+		if now.After(hint.expireTime) {
+			delete(h.hints, regionID)
+			continue
+		}
+		if hint.leaderStore == storeID {
+			hints = append(hints, hint)
+		}
+	}
+	sort.Slice(hints, func(i, j int) bool { return hints[i].RegionID < hints[j].RegionID })
+	return hints
+}
+
+// GetSnapshotHints returns the snapshot hints of the regions whose leaders are
+// on the store.
+func (oc *OperatorController) GetSnapshotHints(storeID uint64) []*SnapshotHint {
+	return oc.snapshotHints.get(storeID, time.Now())
+}
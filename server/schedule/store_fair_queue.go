@@ -0,0 +1,255 @@
+// Copyright 2021 TiKV Project Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package schedule
+
+import (
+	"sort"
+
+	"github.com/tikv/pd/server/core"
+	"github.com/tikv/pd/server/schedule/operator"
+)
+
+// StoreFairQueue is an implementation of waiting operators. The operators are
+// queued by the stores they add peers to, and the operators of different
+// origins in a store are interleaved with the weighted fairness, so that the
+// urgent fixes are not starved behind the heavy balance. The stores are
+// served in turn, and the flows of a store are tried in the order of their
+// passes, so a blocked operator doesn't block the other origins.
+//
+// Unlike RandBuckets, the operators are kept in the queue instead of being
+// canceled while they are not available, such as when the store limit is
+// exceeded, until they expire after operator.OperatorExpireTime. They still
+// hold the waiting quota of their schedulers meanwhile.
+type StoreFairQueue struct {
+	// available checks if the operators can be promoted now.
+	available func(ops []*operator.Operator) bool
+	stores    map[uint64]*storeQueue
+	// next is the store to be served first by the next GetOperator.
+	next uint64
+}
+
+// storeQueue is the queue of a store. The operators which don't add any peer
+// are queued in the store 0.
+type storeQueue struct {
+	flows map[string]*originFlow
+	// vtime is the virtual time of the store, which is the pass of the last
+	// served flow. A flow becoming busy starts from it, so that an idle flow
+	// can't accumulate the credits.
+	vtime float64
+}
+
+// originFlow is the operators of an origin in a store queue. The flow with the
+// lowest pass is served first, and the pass is increased by 1/weight after
+// each operator is served.
+type originFlow struct {
+	desc     string
+	priority core.PriorityLevel
+	pass     float64
+	// items are the operators to be promoted together, which are one operator
+	// or two merge operators.
+	items [][]*operator.Operator
+}
+
+// NewStoreFairQueue creates a store fair queue. available checks if the
+// operators can be promoted now, such as whether the store limits are exceeded.
+func NewStoreFairQueue(available func(ops []*operator.Operator) bool) *StoreFairQueue {
+	return &StoreFairQueue{
+		available: available,
+		stores:    make(map[uint64]*storeQueue),
+	}
+}
+
+// operatorTargetStore returns the first store which the operator adds a peer to.
+func operatorTargetStore(op *operator.Operator) uint64 {
+	for i := 0; i < op.Len(); i++ {
+		switch s := op.Step(i).(type) {
+		case operator.AddPeer:
+			return s.ToStore
+		case operator.AddLearner:
+			return s.ToStore
+		case operator.AddLightPeer:
+			return s.ToStore
+		case operator.AddLightLearner:
+			return s.ToStore
+		}
+	}
+	return 0
+}
+
+func originKey(op *operator.Operator) string {
+	return op.Desc() + "/" + op.GetPriorityLevel().String()
+}
+
+// PutOperator puts an operator into the queue. The second operator of a merge
+// is put into the same flow of the first one.
+func (q *StoreFairQueue) PutOperator(op *operator.Operator) {
+	storeID := operatorTargetStore(op)
+	sq := q.stores[storeID]
+	if sq == nil {
+		sq = &storeQueue{flows: make(map[string]*originFlow)}
+		q.stores[storeID] = sq
+	}
+	key := originKey(op)
+	flow := sq.flows[key]
+	if flow == nil {
+		flow = &originFlow{desc: op.Desc(), priority: op.GetPriorityLevel()}
+		sq.flows[key] = flow
+	}
+	if n := len(flow.items); n > 0 && op.Kind()&operator.OpMerge != 0 {
+		last := flow.items[n-1]
+		if len(last) == 1 && last[0].Kind()&operator.OpMerge != 0 {
+			flow.items[n-1] = append(last, op)
+			return
+		}
+	}
+	if len(flow.items) == 0 && flow.pass < sq.vtime {
+		flow.pass = sq.vtime
+	}
+	flow.items = append(flow.items, []*operator.Operator{op})
+}
+
+// GetOperator gets the operators from the next store which has an available
+// operator.
+func (q *StoreFairQueue) GetOperator() []*operator.Operator {
+	storeIDs := q.sortedStores()
+	if len(storeIDs) == 0 {
+		return nil
+	}
+	start := sort.Search(len(storeIDs), func(i int) bool { return storeIDs[i] >= q.next })
+	for i := 0; i < len(storeIDs); i++ {
+		storeID := storeIDs[(start+i)%len(storeIDs)]
+		sq := q.stores[storeID]
+		for _, flow := range sq.busyFlows() {
+			if !q.available(flow.items[0]) {
+				continue
+			}
+			ops := flow.items[0]
+			flow.items = flow.items[1:]
+			sq.vtime = flow.pass
+			flow.pass += 1 / PriorityWeight[flow.priority]
+			q.gc(storeID)
+			q.next = storeID + 1
+			return ops
+		}
+	}
+	return nil
+}
+
+// busyFlows returns the busy flows ordered by their passes.
+func (sq *storeQueue) busyFlows() []*originFlow {
+	var flows []*originFlow
+	for _, flow := range sq.flows {
+		if len(flow.items) > 0 {
+			flows = append(flows, flow)
+		}
+	}
+	sort.Slice(flows, func(i, j int) bool {
+		if flows[i].pass != flows[j].pass {
+			return flows[i].pass < flows[j].pass
+		}
+		if flows[i].desc != flows[j].desc {
+			return flows[i].desc < flows[j].desc
+		}
+		return flows[i].priority < flows[j].priority
+	})
+	return flows
+}
+
+// gc removes the idle flows whose pass is not ahead of the virtual time, as
+// they start from the virtual time anyway, and removes the empty store queue.
+func (q *StoreFairQueue) gc(storeID uint64) {
+	sq := q.stores[storeID]
+	for key, flow := range sq.flows {
+		if len(flow.items) == 0 && flow.pass <= sq.vtime {
+			delete(sq.flows, key)
+		}
+	}
+	if len(sq.flows) == 0 {
+		delete(q.stores, storeID)
+	}
+}
+
+func (q *StoreFairQueue) sortedStores() []uint64 {
+	storeIDs := make([]uint64, 0, len(q.stores))
+	for id := range q.stores {
+		storeIDs = append(storeIDs, id)
+	}
+	sort.Slice(storeIDs, func(i, j int) bool { return storeIDs[i] < storeIDs[j] })
+	return storeIDs
+}
+
+func (sq *storeQueue) sortedFlows() []*originFlow {
+	flows := make([]*originFlow, 0, len(sq.flows))
+	for _, flow := range sq.flows {
+		flows = append(flows, flow)
+	}
+	sort.Slice(flows, func(i, j int) bool {
+		if flows[i].desc != flows[j].desc {
+			return flows[i].desc < flows[j].desc
+		}
+		return flows[i].priority < flows[j].priority
+	})
+	return flows
+}
+
+// ListOperator lists all operators in the queue.
+func (q *StoreFairQueue) ListOperator() []*operator.Operator {
+	var ops []*operator.Operator
+	for _, storeID := range q.sortedStores() {
+		for _, flow := range q.stores[storeID].sortedFlows() {
+			for _, item := range flow.items {
+				ops = append(ops, item...)
+			}
+		}
+	}
+	return ops
+}
+
+// WaitingOriginStatus is the status of the waiting operators of an origin.
+type WaitingOriginStatus struct {
+	Desc     string  `json:"desc"`
+	Priority string  `json:"priority"`
+	Weight   float64 `json:"weight"`
+	Waiting  int     `json:"waiting"`
+}
+
+// WaitingStoreStatus is the status of the waiting operators of a store. The
+// operators which don't add any peer are in the store 0.
+type WaitingStoreStatus struct {
+	StoreID uint64                 `json:"store_id"`
+	Origins []*WaitingOriginStatus `json:"origins"`
+}
+
+// Status returns the status of the queues of the stores.
+func (q *StoreFairQueue) Status() []*WaitingStoreStatus {
+	var res []*WaitingStoreStatus
+	for _, storeID := range q.sortedStores() {
+		s := &WaitingStoreStatus{StoreID: storeID}
+		for _, flow := range q.stores[storeID].sortedFlows() {
+			if len(flow.items) == 0 {
+				continue
+			}
+			s.Origins = append(s.Origins, &WaitingOriginStatus{
+				Desc:     flow.desc,
+				Priority: flow.priority.String(),
+				Weight:   PriorityWeight[flow.priority],
+				Waiting:  len(flow.items),
+			})
+		}
+		if len(s.Origins) > 0 {
+			res = append(res, s)
+		}
+	}
+	return res
+}
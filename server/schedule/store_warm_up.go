@@ -0,0 +1,59 @@
+// Copyright 2021 TiKV Project Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package schedule
+
+import (
+	"time"
+
+	"github.com/tikv/pd/server/core/storelimit"
+)
+
+// warmUpSteps is the number of the steps to ramp up the add-peer limit of a
+// newly joined store. The limit starts at 1/warmUpSteps of the configured one
+// and is raised step by step, so that the limiter is not rebuilt too often.
+const warmUpSteps = 10
+
+// warmUpRatio returns the ratio of the effective add-peer limit to the
+// configured one for a store joined at joinTime.
+func warmUpRatio(joinTime time.Time, warmUp time.Duration, now time.Time) float64 {
+	if warmUp <= 0 || joinTime.IsZero() {
+		return 1
+	}
+	elapsed := now.Sub(joinTime)
+	if elapsed >= warmUp {
+		return 1
+	}
+	if elapsed < 0 {
+		elapsed = 0
+	}
+	step := int64(elapsed)*warmUpSteps/int64(warmUp) + 1
+	return float64(step) / warmUpSteps
+}
+
+// getStoreLimitRate returns the effective rate per second of the store limit.
+// The add-peer limits of the newly joined stores are paced by the warm-up, so
+// that the checkers and the schedulers don't target them all at once when
+// scaling out.
+func (oc *OperatorController) getStoreLimitRate(storeID uint64, limitType storelimit.Type) float64 {
+	opts := oc.cluster.GetOpts()
+	rate := opts.GetStoreLimitByType(storeID, limitType) / StoreBalanceBaseTime
+	if limitType != storelimit.AddPeer {
+		return rate
+	}
+	store := oc.cluster.GetStore(storeID)
+	if store == nil {
+		return rate
+	}
+	return rate * warmUpRatio(store.GetJoinTime(), opts.GetStoreWarmUpDuration(), time.Now())
+}
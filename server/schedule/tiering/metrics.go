@@ -0,0 +1,39 @@
+// Copyright 2021 TiKV Project Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package tiering
+
+import "github.com/prometheus/client_golang/prometheus"
+
+var (
+	tieringTransitionCounter = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace: "pd",
+			Subsystem: "tiering",
+			Name:      "transition_count",
+			Help:      "Counter of cold data tiering transitions.",
+		}, []string{"policy", "direction"})
+
+	tieringColdPolicyGauge = prometheus.NewGauge(
+		prometheus.GaugeOpts{
+			Namespace: "pd",
+			Subsystem: "tiering",
+			Name:      "cold_policies",
+			Help:      "The number of tiering policies whose range is placed on the capacity tier.",
+		})
+)
+
+func init() {
+	prometheus.MustRegister(tieringTransitionCounter)
+	prometheus.MustRegister(tieringColdPolicyGauge)
+}
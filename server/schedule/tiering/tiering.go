@@ -0,0 +1,415 @@
+// Copyright 2021 TiKV Project Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package tiering
+
+import (
+	"bytes"
+	"encoding/hex"
+	"encoding/json"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/pingcap/log"
+	"github.com/tikv/pd/pkg/errs"
+	"github.com/tikv/pd/pkg/typeutil"
+	"github.com/tikv/pd/server/core"
+	"github.com/tikv/pd/server/schedule/opt"
+	"github.com/tikv/pd/server/schedule/placement"
+	"go.uber.org/zap"
+)
+
+const (
+	tieringPolicyPath = "tiering_policy"
+	// GroupPrefix is the prefix of the placement rule group that is used to
+	// move a cold range to the capacity tier.
+	GroupPrefix = "tiering-"
+	// groupIndex makes the tiering groups be applied after all other groups.
+	groupIndex = 1000
+	// checkInterval is the minimal interval between two rounds of checks,
+	// because each round needs to scan all regions covered by the policies.
+	checkInterval = time.Minute
+	scanLimit     = 1024
+
+	capacityTierRuleID = "capacity-tier"
+)
+
+// Policy moves a key range to the stores matched by the label constraints
+// (the capacity tier) once the range stays cold for ColdDuration, and moves
+// it back as soon as it is accessed again.
+type Policy struct {
+	ID               string                      `json:"id"`
+	StartKeyHex      string                      `json:"start_key"`
+	EndKeyHex        string                      `json:"end_key"`
+	ColdDuration     typeutil.Duration           `json:"cold_duration"`
+	ActiveByteRate   float64                     `json:"active_byte_rate,omitempty"` // a region is active if its read and write byte rate reaches it.
+	LabelConstraints []placement.LabelConstraint `json:"label_constraints"`
+	// The following fields are maintained by PD.
+	LastActive time.Time `json:"last_active"`
+	Cold       bool      `json:"cold"`
+
+	startKey, endKey []byte
+}
+
+// GroupID returns the placement rule group ID used by the policy.
+func (p *Policy) GroupID() string {
+	return GroupPrefix + p.ID
+}
+
+func (p *Policy) adjust() (err error) {
+	if p.ID == "" {
+		return errs.ErrTieringPolicyContent.FastGenByArgs("id should not be empty")
+	}
+	if p.startKey, err = hex.DecodeString(p.StartKeyHex); err != nil {
+		return errs.ErrHexDecodingString.FastGenByArgs(p.StartKeyHex)
+	}
+	if p.endKey, err = hex.DecodeString(p.EndKeyHex); err != nil {
+		return errs.ErrHexDecodingString.FastGenByArgs(p.EndKeyHex)
+	}
+	if len(p.endKey) > 0 && bytes.Compare(p.endKey, p.startKey) <= 0 {
+		return errs.ErrTieringPolicyContent.FastGenByArgs("endKey should be greater than startKey")
+	}
+	if p.ColdDuration.Duration <= 0 {
+		return errs.ErrTieringPolicyContent.FastGenByArgs("cold duration should be positive")
+	}
+	if p.ActiveByteRate < 0 {
+		return errs.ErrTieringPolicyContent.FastGenByArgs("active byte rate should not be negative")
+	}
+	if len(p.LabelConstraints) == 0 {
+		return errs.ErrTieringPolicyContent.FastGenByArgs("label constraints should not be empty")
+	}
+	return nil
+}
+
+func (p *Policy) clone() *Policy {
+	np := *p
+	np.LabelConstraints = append(p.LabelConstraints[:0:0], p.LabelConstraints...)
+	return &np
+}
+
+// Manager maintains the tiering policies and drives the transitions between
+// the normal placement and the capacity tier according to the access statistics.
+type Manager struct {
+	sync.RWMutex
+	storage     *core.Storage
+	ruleManager *placement.RuleManager
+	policies    map[string]*Policy
+	lastCheck   time.Time
+}
+
+// NewManager creates a tiering manager.
+func NewManager(storage *core.Storage, ruleManager *placement.RuleManager) *Manager {
+	return &Manager{
+		storage:     storage,
+		ruleManager: ruleManager,
+		policies:    make(map[string]*Policy),
+	}
+}
+
+// Load loads the tiering policies from storage.
+func (m *Manager) Load() error {
+	m.Lock()
+	defer m.Unlock()
+	// The access state is not persisted on every check, so the time of loading
+	// is treated as the last activity to avoid moving a recently active range
+	// to the capacity tier right after the leader changes.
+	now := time.Now()
+	var toDelete []string
+	err := m.storage.LoadRangeByPrefix(tieringPolicyPath+"/", func(k, v string) {
+		p := &Policy{}
+		if err := json.Unmarshal([]byte(v), p); err != nil {
+			log.Error("failed to unmarshal tiering policy value", zap.String("policy-key", k), errs.ZapError(errs.ErrJSONUnmarshal.Wrap(err).FastGenWithCause()))
+			toDelete = append(toDelete, k)
+			return
+		}
+		if err := p.adjust(); err != nil {
+			log.Error("tiering policy is in bad format", zap.String("policy-key", k), errs.ZapError(err))
+			toDelete = append(toDelete, k)
+			return
+		}
+		if !p.Cold && p.LastActive.Before(now) {
+			p.LastActive = now
+		}
+		m.policies[p.ID] = p
+	})
+	if err != nil {
+		return err
+	}
+	for _, k := range toDelete {
+		if err := m.storage.Remove(tieringPolicyPath + "/" + k); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// GetPolicy returns the policy with the given ID.
+func (m *Manager) GetPolicy(id string) *Policy {
+	m.RLock()
+	defer m.RUnlock()
+	if p, ok := m.policies[id]; ok {
+		return p.clone()
+	}
+	return nil
+}
+
+// GetPolicies returns all policies sorted by ID.
+func (m *Manager) GetPolicies() []*Policy {
+	m.RLock()
+	defer m.RUnlock()
+	policies := make([]*Policy, 0, len(m.policies))
+	for _, p := range m.policies {
+		policies = append(policies, p.clone())
+	}
+	sort.Slice(policies, func(i, j int) bool { return policies[i].ID < policies[j].ID })
+	return policies
+}
+
+// SetPolicy creates or updates a policy. The tiering state of an existing
+// policy is kept, and the capacity tier rules are refreshed if it is cold.
+func (m *Manager) SetPolicy(p *Policy) error {
+	if err := p.adjust(); err != nil {
+		return err
+	}
+	m.Lock()
+	defer m.Unlock()
+	p = p.clone()
+	if old, ok := m.policies[p.ID]; ok {
+		p.LastActive, p.Cold = old.LastActive, old.Cold
+	} else {
+		p.LastActive, p.Cold = time.Now(), false
+	}
+	if p.Cold {
+		if err := m.ruleManager.SetGroupBundle(m.buildBundle(p, 0)); err != nil {
+			return err
+		}
+	}
+	return m.savePolicyLocked(p)
+}
+
+// DeletePolicy removes a policy and moves its range back if it is cold.
+func (m *Manager) DeletePolicy(id string) error {
+	m.Lock()
+	defer m.Unlock()
+	p, ok := m.policies[id]
+	if !ok {
+		return errs.ErrTieringPolicyNotFound.FastGenByArgs(id)
+	}
+	if p.Cold {
+		if err := m.ruleManager.DeleteGroupBundle(p.GroupID(), false); err != nil {
+			return err
+		}
+	}
+	if err := m.storage.Remove(tieringPolicyPath + "/" + id); err != nil {
+		return err
+	}
+	delete(m.policies, id)
+	m.updateMetricsLocked()
+	return nil
+}
+
+// Check updates the access state of all policies and performs the tier
+// transitions. It only takes effect when placement rules are enabled.
+func (m *Manager) Check(cluster opt.Cluster, now time.Time) {
+	if !cluster.GetOpts().IsPlacementRulesEnabled() {
+		return
+	}
+	m.Lock()
+	defer m.Unlock()
+	if now.Sub(m.lastCheck) < checkInterval {
+		return
+	}
+	m.lastCheck = now
+	for _, p := range m.policies {
+		if m.isRangeActive(cluster, p) {
+			p.LastActive = now
+			if p.Cold {
+				m.transferLocked(p, false, cluster.GetOpts().GetMaxReplicas())
+			}
+			continue
+		}
+		if p.Cold {
+			m.refreshLocked(p)
+		} else if now.Sub(p.LastActive) >= p.ColdDuration.Duration {
+			m.transferLocked(p, true, cluster.GetOpts().GetMaxReplicas())
+		}
+	}
+	m.updateMetricsLocked()
+}
+
+// transferLocked moves the range of the policy to (cold is true) or back from
+// the capacity tier. The state is only changed if the rules are updated.
+func (m *Manager) transferLocked(p *Policy, cold bool, maxReplicas int) {
+	var err error
+	direction := "to-capacity-tier"
+	if cold {
+		err = m.ruleManager.SetGroupBundle(m.buildBundle(p, maxReplicas))
+	} else {
+		direction = "to-normal-tier"
+		err = m.ruleManager.DeleteGroupBundle(p.GroupID(), false)
+	}
+	if err != nil {
+		log.Error("failed to update tiering rules", zap.String("policy", p.ID), zap.String("direction", direction), errs.ZapError(err))
+		return
+	}
+	p.Cold = cold
+	if err := m.savePolicyLocked(p); err != nil {
+		log.Error("failed to persist tiering policy", zap.String("policy", p.ID), errs.ZapError(err))
+	}
+	tieringTransitionCounter.WithLabelValues(p.ID, direction).Inc()
+	log.Info("tiering policy transferred", zap.String("policy", p.ID), zap.String("direction", direction), zap.Time("last-active", p.LastActive))
+}
+
+// refreshLocked updates the rules of a cold policy if the learner rules it
+// carries have been changed since the range was moved.
+func (m *Manager) refreshLocked(p *Policy) {
+	bundle := m.buildBundle(p, 0)
+	if rulesEqual(m.ruleManager.GetRulesByGroup(p.GroupID()), bundle.Rules) {
+		return
+	}
+	if err := m.ruleManager.SetGroupBundle(bundle); err != nil {
+		log.Error("failed to refresh tiering rules", zap.String("policy", p.ID), errs.ZapError(err))
+	}
+}
+
+// buildBundle builds the rule group which places the voters of the range to
+// the capacity tier. As the group overrides all groups with lower indexes, the
+// learner rules (e.g. TiFlash replicas) overlapping the range are carried
+// into the group, clipped to the range of the policy.
+func (m *Manager) buildBundle(p *Policy, maxReplicas int) placement.GroupBundle {
+	if maxReplicas == 0 {
+		// keep the replica count of the existing bundle.
+		for _, r := range m.ruleManager.GetRulesByGroup(p.GroupID()) {
+			if r.ID == capacityTierRuleID {
+				maxReplicas = r.Count
+			}
+		}
+	}
+	rules := []*placement.Rule{{
+		GroupID:          p.GroupID(),
+		ID:               capacityTierRuleID,
+		StartKeyHex:      p.StartKeyHex,
+		EndKeyHex:        p.EndKeyHex,
+		Role:             placement.Voter,
+		Count:            maxReplicas,
+		LabelConstraints: append(p.LabelConstraints[:0:0], p.LabelConstraints...),
+	}}
+	for _, r := range m.ruleManager.GetAllRules() {
+		if r.Role != placement.Learner || strings.HasPrefix(r.GroupID, GroupPrefix) {
+			continue
+		}
+		startKey, endKey := p.startKey, p.endKey
+		if bytes.Compare(r.StartKey, startKey) > 0 {
+			startKey = r.StartKey
+		}
+		if len(r.EndKey) > 0 && (len(endKey) == 0 || bytes.Compare(r.EndKey, endKey) < 0) {
+			endKey = r.EndKey
+		}
+		if len(endKey) > 0 && bytes.Compare(startKey, endKey) >= 0 {
+			continue
+		}
+		rules = append(rules, &placement.Rule{
+			GroupID:          p.GroupID(),
+			ID:               r.GroupID + "-" + r.ID,
+			Index:            r.Index,
+			StartKeyHex:      hex.EncodeToString(startKey),
+			EndKeyHex:        hex.EncodeToString(endKey),
+			Role:             r.Role,
+			Count:            r.Count,
+			LabelConstraints: append(r.LabelConstraints[:0:0], r.LabelConstraints...),
+			LocationLabels:   append(r.LocationLabels[:0:0], r.LocationLabels...),
+			IsolationLevel:   r.IsolationLevel,
+		})
+	}
+	return placement.GroupBundle{
+		ID:       p.GroupID(),
+		Index:    groupIndex,
+		Override: true,
+		Rules:    rules,
+	}
+}
+
+func rulesEqual(a, b []*placement.Rule) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	key := func(r *placement.Rule) string {
+		data, _ := json.Marshal(r)
+		return string(data)
+	}
+	keys := make(map[string]int, len(a))
+	for _, r := range a {
+		keys[key(r)]++
+	}
+	for _, r := range b {
+		if keys[key(r)] == 0 {
+			return false
+		}
+		keys[key(r)]--
+	}
+	return true
+}
+
+// isRangeActive checks whether any region in the range of the policy is hot
+// or its flow reaches the active byte rate.
+func (m *Manager) isRangeActive(cluster opt.Cluster, p *Policy) bool {
+	startKey := p.startKey
+	for {
+		regions := cluster.ScanRegions(startKey, p.endKey, scanLimit)
+		for _, region := range regions {
+			if cluster.IsRegionHot(region) || isRegionActive(region, p.ActiveByteRate) {
+				return true
+			}
+		}
+		if len(regions) < scanLimit {
+			return false
+		}
+		startKey = regions[len(regions)-1].GetEndKey()
+		if len(startKey) == 0 || (len(p.endKey) > 0 && bytes.Compare(startKey, p.endKey) >= 0) {
+			return false
+		}
+	}
+}
+
+func isRegionActive(region *core.RegionInfo, activeByteRate float64) bool {
+	flow := region.GetBytesRead() + region.GetBytesWritten()
+	if activeByteRate == 0 {
+		return flow > 0
+	}
+	interval := region.GetInterval().GetEndTimestamp() - region.GetInterval().GetStartTimestamp()
+	if interval == 0 {
+		return false
+	}
+	return float64(flow)/float64(interval) >= activeByteRate
+}
+
+func (m *Manager) savePolicyLocked(p *Policy) error {
+	if err := m.storage.SaveJSON(tieringPolicyPath, p.ID, p); err != nil {
+		return err
+	}
+	m.policies[p.ID] = p
+	m.updateMetricsLocked()
+	return nil
+}
+
+func (m *Manager) updateMetricsLocked() {
+	var cold int
+	for _, p := range m.policies {
+		if p.Cold {
+			cold++
+		}
+	}
+	tieringColdPolicyGauge.Set(float64(cold))
+}
@@ -0,0 +1,168 @@
+// Copyright 2021 TiKV Project Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package tiering
+
+import (
+	"context"
+	"encoding/hex"
+	"testing"
+	"time"
+
+	. "github.com/pingcap/check"
+	"github.com/tikv/pd/pkg/mock/mockcluster"
+	"github.com/tikv/pd/pkg/typeutil"
+	"github.com/tikv/pd/server/config"
+	"github.com/tikv/pd/server/core"
+	"github.com/tikv/pd/server/kv"
+	"github.com/tikv/pd/server/schedule/placement"
+)
+
+func TestTiering(t *testing.T) {
+	TestingT(t)
+}
+
+var _ = Suite(&testTieringSuite{})
+
+type testTieringSuite struct {
+	ctx    context.Context
+	cancel context.CancelFunc
+}
+
+func (s *testTieringSuite) SetUpTest(c *C) {
+	s.ctx, s.cancel = context.WithCancel(context.Background())
+}
+
+func (s *testTieringSuite) TearDownTest(c *C) {
+	s.cancel()
+}
+
+func newTestPolicy() *Policy {
+	return &Policy{
+		ID:               "t1",
+		StartKeyHex:      hex.EncodeToString([]byte("a")),
+		EndKeyHex:        hex.EncodeToString([]byte("c")),
+		ColdDuration:     typeutil.NewDuration(time.Hour),
+		LabelConstraints: []placement.LabelConstraint{{Key: "disk", Op: placement.In, Values: []string{"hdd"}}},
+	}
+}
+
+func (s *testTieringSuite) TestPolicyValidation(c *C) {
+	m := NewManager(core.NewStorage(kv.NewMemoryKV()), nil)
+	p := newTestPolicy()
+	p.ID = ""
+	c.Assert(m.SetPolicy(p), NotNil)
+	p = newTestPolicy()
+	p.EndKeyHex = p.StartKeyHex
+	c.Assert(m.SetPolicy(p), NotNil)
+	p = newTestPolicy()
+	p.ColdDuration = typeutil.NewDuration(0)
+	c.Assert(m.SetPolicy(p), NotNil)
+	p = newTestPolicy()
+	p.LabelConstraints = nil
+	c.Assert(m.SetPolicy(p), NotNil)
+	c.Assert(m.SetPolicy(newTestPolicy()), IsNil)
+	c.Assert(m.GetPolicies(), HasLen, 1)
+}
+
+func (s *testTieringSuite) TestTransition(c *C) {
+	opt := config.NewTestOptions()
+	tc := mockcluster.NewCluster(s.ctx, opt)
+	tc.SetEnablePlacementRules(true)
+	tc.AddLeaderRegionWithRange(1, "a", "b", 1)
+	tc.AddLeaderRegionWithRange(2, "b", "c", 1)
+	storage := core.NewStorage(kv.NewMemoryKV())
+	m := NewManager(storage, tc.GetRuleManager())
+	c.Assert(m.SetPolicy(newTestPolicy()), IsNil)
+
+	start := m.GetPolicy("t1").LastActive
+	// Not cold enough.
+	m.Check(tc, start.Add(30*time.Minute))
+	c.Assert(m.GetPolicy("t1").Cold, IsFalse)
+	c.Assert(tc.GetRuleManager().GetRuleGroup("tiering-t1"), IsNil)
+
+	// The range stays cold for the whole duration.
+	m.Check(tc, start.Add(2*time.Hour))
+	c.Assert(m.GetPolicy("t1").Cold, IsTrue)
+	rules := tc.GetRuleManager().GetRulesByGroup("tiering-t1")
+	c.Assert(rules, HasLen, 1)
+	c.Assert(rules[0].Count, Equals, opt.GetMaxReplicas())
+	c.Assert(tc.GetRuleManager().GetRulesForApplyRegion(tc.GetRegion(1)), DeepEquals, rules)
+
+	// The state survives reloading.
+	m2 := NewManager(storage, tc.GetRuleManager())
+	c.Assert(m2.Load(), IsNil)
+	c.Assert(m2.GetPolicy("t1").Cold, IsTrue)
+
+	// Renewed access moves the range back.
+	tc.PutRegion(tc.GetRegion(2).Clone(core.SetWrittenBytes(1024)))
+	m.Check(tc, start.Add(3*time.Hour))
+	c.Assert(m.GetPolicy("t1").Cold, IsFalse)
+	c.Assert(tc.GetRuleManager().GetRuleGroup("tiering-t1"), IsNil)
+
+	// Deleting a cold policy removes its rules.
+	tc.PutRegion(tc.GetRegion(2).Clone(core.SetWrittenBytes(0)))
+	m.Check(tc, start.Add(5*time.Hour))
+	c.Assert(m.GetPolicy("t1").Cold, IsTrue)
+	c.Assert(m.DeletePolicy("t1"), IsNil)
+	c.Assert(tc.GetRuleManager().GetRuleGroup("tiering-t1"), IsNil)
+	c.Assert(m.GetPolicies(), HasLen, 0)
+}
+
+func (s *testTieringSuite) TestLoadResetsLastActive(c *C) {
+	storage := core.NewStorage(kv.NewMemoryKV())
+	m := NewManager(storage, nil)
+	c.Assert(m.SetPolicy(newTestPolicy()), IsNil)
+	p := m.GetPolicy("t1")
+	p.LastActive = p.LastActive.Add(-2 * time.Hour)
+	c.Assert(storage.SaveJSON(tieringPolicyPath, p.ID, p), IsNil)
+
+	m2 := NewManager(storage, nil)
+	c.Assert(m2.Load(), IsNil)
+	c.Assert(m2.GetPolicy("t1").LastActive.After(p.LastActive.Add(time.Hour)), IsTrue)
+}
+
+func (s *testTieringSuite) TestCarryLearnerRules(c *C) {
+	opt := config.NewTestOptions()
+	tc := mockcluster.NewCluster(s.ctx, opt)
+	tc.SetEnablePlacementRules(true)
+	tc.AddLeaderRegionWithRange(1, "a", "b", 1)
+	tc.AddLeaderRegionWithRange(2, "b", "c", 1)
+	ruleManager := tc.GetRuleManager()
+	c.Assert(ruleManager.SetRule(&placement.Rule{
+		GroupID:          "tiflash",
+		ID:               "table-1",
+		StartKeyHex:      hex.EncodeToString([]byte("b")),
+		EndKeyHex:        hex.EncodeToString([]byte("d")),
+		Role:             placement.Learner,
+		Count:            1,
+		LabelConstraints: []placement.LabelConstraint{{Key: "engine", Op: placement.In, Values: []string{"tiflash"}}},
+	}), IsNil)
+	m := NewManager(core.NewStorage(kv.NewMemoryKV()), ruleManager)
+	c.Assert(m.SetPolicy(newTestPolicy()), IsNil)
+
+	start := m.GetPolicy("t1").LastActive
+	m.Check(tc, start.Add(2*time.Hour))
+	c.Assert(m.GetPolicy("t1").Cold, IsTrue)
+	c.Assert(ruleManager.GetRulesForApplyRegion(tc.GetRegion(1)), HasLen, 1)
+	rules := ruleManager.GetRulesForApplyRegion(tc.GetRegion(2))
+	c.Assert(rules, HasLen, 2)
+	c.Assert(rules[1].Role, Equals, placement.Learner)
+	c.Assert(rules[1].StartKeyHex, Equals, hex.EncodeToString([]byte("b")))
+	c.Assert(rules[1].EndKeyHex, Equals, hex.EncodeToString([]byte("c")))
+
+	// The learner rules changed later are refreshed.
+	c.Assert(ruleManager.DeleteRule("tiflash", "table-1"), IsNil)
+	m.Check(tc, start.Add(3*time.Hour))
+	c.Assert(ruleManager.GetRulesForApplyRegion(tc.GetRegion(2)), HasLen, 1)
+}
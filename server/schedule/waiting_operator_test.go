@@ -106,3 +106,158 @@ func (s *testWaitingOperatorSuite) TestRandomBucketsWithMergeRegion(c *C) {
 		c.Assert(rb.GetOperator(), IsNil)
 	}
 }
+
+func newAddPeerOperator(desc string, regionID, storeID uint64, priority core.PriorityLevel) *operator.Operator {
+	op := operator.NewOperator(desc, "test", regionID, &metapb.RegionEpoch{}, operator.OpRegion, operator.AddPeer{ToStore: storeID, PeerID: regionID})
+	op.SetPriorityLevel(priority)
+	return op
+}
+
+func (s *testWaitingOperatorSuite) TestStoreFairQueue(c *C) {
+	blocked, blockedRegions := make(map[uint64]bool), make(map[uint64]bool)
+	q := NewStoreFairQueue(func(ops []*operator.Operator) bool {
+		return !blocked[operatorTargetStore(ops[0])] && !blockedRegions[ops[0].RegionID()]
+	})
+	for i := uint64(1); i <= 4; i++ {
+		q.PutOperator(newAddPeerOperator("balance-region", i, 1, core.NormalPriority))
+	}
+	for i := uint64(5); i <= 6; i++ {
+		q.PutOperator(newAddPeerOperator("replace-rule-down-peer", i, 1, core.HighPriority))
+	}
+	q.PutOperator(newAddPeerOperator("balance-region", 7, 2, core.NormalPriority))
+	c.Assert(q.Status(), DeepEquals, []*WaitingStoreStatus{
+		{StoreID: 1, Origins: []*WaitingOriginStatus{
+			{Desc: "balance-region", Priority: "normal", Weight: 4, Waiting: 4},
+			{Desc: "replace-rule-down-peer", Priority: "high", Weight: 9, Waiting: 2},
+		}},
+		{StoreID: 2, Origins: []*WaitingOriginStatus{
+			{Desc: "balance-region", Priority: "normal", Weight: 4, Waiting: 1},
+		}},
+	})
+
+	// The stores are served in turn, and the urgent fixes are not queued
+	// behind all balance operators.
+	blocked[2] = true
+	var regions []uint64
+	for ops := q.GetOperator(); ops != nil; ops = q.GetOperator() {
+		c.Assert(ops, HasLen, 1)
+		regions = append(regions, ops[0].RegionID())
+	}
+	c.Assert(regions, DeepEquals, []uint64{1, 5, 6, 2, 3, 4})
+	// The operator of the blocked store is kept.
+	c.Assert(q.ListOperator(), HasLen, 1)
+	blocked[2] = false
+	c.Assert(q.GetOperator()[0].RegionID(), Equals, uint64(7))
+	c.Assert(q.GetOperator(), IsNil)
+	c.Assert(q.Status(), HasLen, 0)
+
+	// An idle flow doesn't accumulate credits.
+	q.PutOperator(newAddPeerOperator("balance-region", 1, 1, core.NormalPriority))
+	q.PutOperator(newAddPeerOperator("balance-region", 2, 1, core.NormalPriority))
+	c.Assert(q.GetOperator()[0].RegionID(), Equals, uint64(1))
+	q.PutOperator(newAddPeerOperator("balance-leader", 3, 1, core.NormalPriority))
+	c.Assert(q.GetOperator()[0].RegionID(), Equals, uint64(3))
+	c.Assert(q.GetOperator()[0].RegionID(), Equals, uint64(2))
+
+	// A blocked operator doesn't block the other origins of the store.
+	blockedRegions[8] = true
+	q.PutOperator(newAddPeerOperator("balance-region", 8, 1, core.NormalPriority))
+	q.PutOperator(newAddPeerOperator("replace-rule-down-peer", 9, 1, core.HighPriority))
+	c.Assert(q.GetOperator()[0].RegionID(), Equals, uint64(9))
+	c.Assert(q.GetOperator(), IsNil)
+	blockedRegions[8] = false
+	c.Assert(q.GetOperator()[0].RegionID(), Equals, uint64(8))
+
+	// The merge operators are promoted together.
+	merge := func(regionID uint64) *operator.Operator {
+		return operator.NewOperator("merge-region", "test", regionID, &metapb.RegionEpoch{}, operator.OpRegion|operator.OpMerge, operator.MergeRegion{
+			FromRegion: &metapb.Region{Id: 1, RegionEpoch: &metapb.RegionEpoch{}},
+			ToRegion:   &metapb.Region{Id: 2, RegionEpoch: &metapb.RegionEpoch{}},
+		})
+	}
+	q.PutOperator(merge(1))
+	q.PutOperator(merge(2))
+	c.Assert(q.ListOperator(), HasLen, 2)
+	c.Assert(q.GetOperator(), HasLen, 2)
+}
+
+func (s *testWaitingOperatorSuite) TestKeyspaceFairQueue(c *C) {
+	keyspaces := map[uint64]int64{1: 1, 2: 1, 3: 1, 4: 1, 5: 1, 6: 1, 11: 2, 12: 2, 13: 2}
+	weights := map[uint32]float64{1: 2}
+	blocked := make(map[uint64]bool)
+	q := NewKeyspaceFairQueue(func(op *operator.Operator) int64 {
+		if keyspace, ok := keyspaces[op.RegionID()]; ok {
+			return keyspace
+		}
+		return noKeyspace
+	}, func(keyspaceID uint32) float64 {
+		if w, ok := weights[keyspaceID]; ok {
+			return w
+		}
+		return 1
+	}, func() WaitingOperator {
+		return NewStoreFairQueue(func(ops []*operator.Operator) bool {
+			return !blocked[operatorTargetStore(ops[0])]
+		})
+	})
+	for i := uint64(1); i <= 6; i++ {
+		q.PutOperator(newAddPeerOperator("balance-region", i, 1, core.NormalPriority))
+	}
+	for i := uint64(11); i <= 13; i++ {
+		q.PutOperator(newAddPeerOperator("balance-region", i, 1, core.NormalPriority))
+	}
+	c.Assert(q.Status(), DeepEquals, []*WaitingKeyspaceStatus{
+		{Keyspace: "1", Weight: 2, Waiting: 6},
+		{Keyspace: "2", Weight: 1, Waiting: 3},
+	})
+
+	// The keyspaces are served in proportion to their weights, and each
+	// promoted operator is charged.
+	var regions []uint64
+	for ops := q.GetOperator(); ops != nil; ops = q.GetOperator() {
+		c.Assert(ops, HasLen, 1)
+		regions = append(regions, ops[0].RegionID())
+	}
+	c.Assert(regions, DeepEquals, []uint64{1, 11, 2, 3, 12, 4, 5, 13, 6})
+	c.Assert(q.Status(), HasLen, 0)
+
+	// An idle keyspace doesn't accumulate credits.
+	for i := uint64(1); i <= 4; i++ {
+		q.PutOperator(newAddPeerOperator("balance-region", i, 1, core.NormalPriority))
+	}
+	for i := uint64(1); i <= 3; i++ {
+		c.Assert(q.GetOperator()[0].RegionID(), Equals, i)
+	}
+	q.PutOperator(newAddPeerOperator("balance-region", 11, 1, core.NormalPriority))
+	q.PutOperator(newAddPeerOperator("balance-region", 12, 1, core.NormalPriority))
+	regions = regions[:0]
+	for ops := q.GetOperator(); ops != nil; ops = q.GetOperator() {
+		regions = append(regions, ops[0].RegionID())
+	}
+	c.Assert(regions, DeepEquals, []uint64{11, 4, 12})
+
+	// A blocked keyspace doesn't block the others, and the regions without a
+	// keyspace have their own flow.
+	blocked[1] = true
+	q.PutOperator(newAddPeerOperator("balance-region", 3, 1, core.NormalPriority))
+	q.PutOperator(newAddPeerOperator("balance-region", 21, 2, core.NormalPriority))
+	c.Assert(q.GetOperator()[0].RegionID(), Equals, uint64(21))
+	c.Assert(q.GetOperator(), IsNil)
+	c.Assert(q.Status(), DeepEquals, []*WaitingKeyspaceStatus{{Keyspace: "1", Weight: 2, Waiting: 1}})
+	blocked[1] = false
+	c.Assert(q.GetOperator()[0].RegionID(), Equals, uint64(3))
+
+	// The merge operators are queued together in the keyspace of the first
+	// one.
+	merge := func(regionID uint64) *operator.Operator {
+		return operator.NewOperator("merge-region", "test", regionID, &metapb.RegionEpoch{}, operator.OpRegion|operator.OpMerge, operator.MergeRegion{
+			FromRegion: &metapb.Region{Id: 1, RegionEpoch: &metapb.RegionEpoch{}},
+			ToRegion:   &metapb.Region{Id: 11, RegionEpoch: &metapb.RegionEpoch{}},
+		})
+	}
+	q.PutOperator(merge(1))
+	q.PutOperator(merge(11))
+	c.Assert(q.Status(), DeepEquals, []*WaitingKeyspaceStatus{{Keyspace: "1", Weight: 2, Waiting: 1}})
+	c.Assert(q.ListOperator(), HasLen, 2)
+	c.Assert(q.GetOperator(), HasLen, 2)
+}
@@ -14,17 +14,25 @@
 package schedulers
 
 import (
+	"net/http"
 	"sort"
 	"strconv"
+	"sync"
 
+	"github.com/gorilla/mux"
 	"github.com/pingcap/log"
 	"github.com/prometheus/client_golang/prometheus"
+	"github.com/tikv/pd/pkg/apiutil"
 	"github.com/tikv/pd/pkg/errs"
+	"github.com/tikv/pd/pkg/slice"
 	"github.com/tikv/pd/server/core"
 	"github.com/tikv/pd/server/schedule"
 	"github.com/tikv/pd/server/schedule/filter"
+	"github.com/tikv/pd/server/schedule/leaderconstraint"
 	"github.com/tikv/pd/server/schedule/operator"
 	"github.com/tikv/pd/server/schedule/opt"
+	"github.com/tikv/pd/server/statistics"
+	"github.com/unrolled/render"
 	"go.uber.org/zap"
 )
 
@@ -37,6 +45,19 @@ const (
 	balanceLeaderRetryLimit = 10
 )
 
+// The modes of measuring the leader load of a store.
+const (
+	// leaderLoadByPolicy follows the leader-schedule-policy of the cluster.
+	leaderLoadByPolicy = ""
+	leaderLoadByCount  = "count"
+	leaderLoadBySize   = "size"
+	// leaderLoadByQPS weights the leaders by their recent QPS in the hot
+	// statistics, so the hot leaders count more than the cold ones.
+	leaderLoadByQPS = "qps"
+)
+
+var leaderLoadModes = []string{leaderLoadByPolicy, leaderLoadByCount, leaderLoadBySize, leaderLoadByQPS}
+
 func init() {
 	schedule.RegisterSliceDecoderBuilder(BalanceLeaderType, func(args []string) schedule.ConfigDecoder {
 		return func(v interface{}) error {
@@ -55,7 +76,7 @@ func init() {
 	})
 
 	schedule.RegisterScheduler(BalanceLeaderType, func(opController *schedule.OperatorController, storage *core.Storage, decoder schedule.ConfigDecoder) (schedule.Scheduler, error) {
-		conf := &balanceLeaderSchedulerConfig{}
+		conf := &balanceLeaderSchedulerConfig{storage: storage}
 		if err := decoder(conf); err != nil {
 			return nil, err
 		}
@@ -64,8 +85,77 @@ func init() {
 }
 
 type balanceLeaderSchedulerConfig struct {
+	sync.RWMutex
+	storage *core.Storage
+
 	Name   string          `json:"name"`
 	Ranges []core.KeyRange `json:"ranges"`
+	// Mode is how the leader load of a store is measured, which can be
+	// `count`, `size` or `qps`. It follows the leader-schedule-policy if empty.
+	Mode string `json:"mode,omitempty"`
+}
+
+func (conf *balanceLeaderSchedulerConfig) EncodeConfig() ([]byte, error) {
+	conf.RLock()
+	defer conf.RUnlock()
+	return schedule.EncodeConfig(conf)
+}
+
+func (conf *balanceLeaderSchedulerConfig) GetMode() string {
+	conf.RLock()
+	defer conf.RUnlock()
+	return conf.Mode
+}
+
+func (conf *balanceLeaderSchedulerConfig) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	router := mux.NewRouter()
+	router.HandleFunc("/list", conf.handleGetConfig).Methods("GET")
+	router.HandleFunc("/config", conf.handleSetConfig).Methods("POST")
+	router.ServeHTTP(w, r)
+}
+
+func (conf *balanceLeaderSchedulerConfig) handleGetConfig(w http.ResponseWriter, r *http.Request) {
+	conf.RLock()
+	defer conf.RUnlock()
+	rd := render.New(render.Options{IndentJSON: true})
+	rd.JSON(w, http.StatusOK, conf)
+}
+
+func (conf *balanceLeaderSchedulerConfig) handleSetConfig(w http.ResponseWriter, r *http.Request) {
+	rd := render.New(render.Options{IndentJSON: true})
+	input := make(map[string]interface{})
+	if err := apiutil.ReadJSONRespondError(rd, w, r.Body, &input); err != nil {
+		return
+	}
+	v, ok := input["mode"]
+	if !ok {
+		rd.Text(w, http.StatusBadRequest, "config item not found")
+		return
+	}
+	mode, ok := v.(string)
+	if !ok || slice.NoneOf(leaderLoadModes, func(i int) bool { return leaderLoadModes[i] == mode }) {
+		rd.Text(w, http.StatusBadRequest, "invalid mode, it should be one of count, size and qps")
+		return
+	}
+
+	conf.Lock()
+	defer conf.Unlock()
+	old := conf.Mode
+	conf.Mode = mode
+	if err := conf.persist(); err != nil {
+		conf.Mode = old // revert
+		rd.Text(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	rd.Text(w, http.StatusOK, "success")
+}
+
+func (conf *balanceLeaderSchedulerConfig) persist() error {
+	data, err := schedule.EncodeConfig(conf)
+	if err != nil {
+		return err
+	}
+	return conf.storage.SaveScheduleConfig(conf.Name, data)
 }
 
 type balanceLeaderScheduler struct {
@@ -123,7 +213,11 @@ func (l *balanceLeaderScheduler) GetType() string {
 }
 
 func (l *balanceLeaderScheduler) EncodeConfig() ([]byte, error) {
-	return schedule.EncodeConfig(l.conf)
+	return l.conf.EncodeConfig()
+}
+
+func (l *balanceLeaderScheduler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	l.conf.ServeHTTP(w, r)
 }
 
 func (l *balanceLeaderScheduler) IsScheduleAllowed(cluster opt.Cluster) bool {
@@ -137,25 +231,30 @@ func (l *balanceLeaderScheduler) IsScheduleAllowed(cluster opt.Cluster) bool {
 func (l *balanceLeaderScheduler) Schedule(cluster opt.Cluster) []*operator.Operator {
 	schedulerCounter.WithLabelValues(l.GetName(), "schedule").Inc()
 
-	leaderSchedulePolicy := l.opController.GetLeaderSchedulePolicy()
 	opInfluence := l.opController.GetOpInfluence(cluster)
-	kind := core.NewScheduleKind(core.LeaderKind, leaderSchedulePolicy)
-	plan := newBalancePlan(kind, cluster, opInfluence)
+	plan := newBalancePlan(l.getScheduleKind(), cluster, opInfluence)
+	if l.conf.GetMode() == leaderLoadByQPS {
+		plan.hotLeaderLoads = getHotLeaderLoads(cluster)
+	}
 
 	stores := cluster.GetStores()
+	plan.leaderConstraints = leaderconstraint.NewState(cluster.GetOpts().GetLeaderConstraints(), stores)
 	sources := filter.SelectSourceStores(stores, l.filters, cluster.GetOpts())
 	targets := filter.SelectTargetStores(stores, l.filters, cluster.GetOpts())
 	sort.Slice(sources, func(i, j int) bool {
+		// The stores beyond the max ratio of a leader constraint come first.
+		iViolated := plan.leaderConstraints.IsViolated(sources[i], false)
+		if jViolated := plan.leaderConstraints.IsViolated(sources[j], false); iViolated != jViolated {
+			return iViolated
+		}
 		iOp := plan.GetOpInfluence(sources[i].GetID())
 		jOp := plan.GetOpInfluence(sources[j].GetID())
-		return sources[i].LeaderScore(leaderSchedulePolicy, iOp) >
-			sources[j].LeaderScore(leaderSchedulePolicy, jOp)
+		return plan.leaderScore(sources[i], iOp) > plan.leaderScore(sources[j], jOp)
 	})
 	sort.Slice(targets, func(i, j int) bool {
 		iOp := plan.GetOpInfluence(targets[i].GetID())
 		jOp := plan.GetOpInfluence(targets[i].GetID())
-		return targets[i].LeaderScore(leaderSchedulePolicy, iOp) <
-			targets[j].LeaderScore(leaderSchedulePolicy, jOp)
+		return plan.leaderScore(targets[i], iOp) < plan.leaderScore(targets[j], jOp)
 	})
 
 	for i := 0; i < len(sources) || i < len(targets); i++ {
@@ -190,6 +289,52 @@ func (l *balanceLeaderScheduler) Schedule(cluster opt.Cluster) []*operator.Opera
 	return nil
 }
 
+// getScheduleKind returns the schedule kind according to the mode.
+func (l *balanceLeaderScheduler) getScheduleKind() core.ScheduleKind {
+	policy := l.opController.GetLeaderSchedulePolicy()
+	switch l.conf.GetMode() {
+	case leaderLoadByCount, leaderLoadByQPS:
+		policy = core.ByCount
+	case leaderLoadBySize:
+		policy = core.BySize
+	}
+	return core.NewScheduleKind(core.LeaderKind, policy)
+}
+
+// getHotLeaderLoads converts the recent QPS of the hot leaders on each store
+// to the leader count. The QPS is divided by the average QPS of all leaders,
+// so the leader count and the QPS are weighted equally in the leader load.
+func getHotLeaderLoads(cluster opt.Cluster) map[uint64]float64 {
+	loads := make(map[uint64]float64)
+	var totalQPS float64
+	collect := func(stats map[uint64][]*statistics.HotPeerStat, kind statistics.RegionStatKind) {
+		for storeID, peers := range stats {
+			for _, peer := range peers {
+				if !peer.IsLeader() {
+					continue
+				}
+				qps := peer.GetLoad(kind)
+				loads[storeID] += qps
+				totalQPS += qps
+			}
+		}
+	}
+	collect(cluster.RegionReadStats(), statistics.RegionReadQuery)
+	collect(cluster.RegionWriteStats(), statistics.RegionWriteQuery)
+	var leaderCount int
+	for _, store := range cluster.GetStores() {
+		leaderCount += store.GetLeaderCount()
+	}
+	if totalQPS == 0 || leaderCount == 0 {
+		return nil
+	}
+	avgQPS := totalQPS / float64(leaderCount)
+	for storeID := range loads {
+		loads[storeID] /= avgQPS
+	}
+	return loads
+}
+
 // transferLeaderOut transfers leader from the source store.
 // It randomly selects a health region from the source store, then picks
 // the best follower peer and transfers the leader.
@@ -201,16 +346,12 @@ func (l *balanceLeaderScheduler) transferLeaderOut(plan *balancePlan) []*operato
 		return nil
 	}
 	targets := plan.cluster.GetFollowerStores(plan.region)
-	finalFilters := l.filters
-	if leaderFilter := filter.NewPlacementLeaderSafeguard(l.GetName(), plan.cluster, plan.region, plan.source); leaderFilter != nil {
-		finalFilters = append(l.filters, leaderFilter)
-	}
+	finalFilters := l.getFinalFilters(plan)
 	targets = filter.SelectTargetStores(targets, finalFilters, plan.cluster.GetOpts())
-	leaderSchedulePolicy := l.opController.GetLeaderSchedulePolicy()
 	sort.Slice(targets, func(i, j int) bool {
 		iOp := plan.GetOpInfluence(targets[i].GetID())
 		jOp := plan.GetOpInfluence(targets[j].GetID())
-		return targets[i].LeaderScore(leaderSchedulePolicy, iOp) < targets[j].LeaderScore(leaderSchedulePolicy, jOp)
+		return plan.leaderScore(targets[i], iOp) < plan.leaderScore(targets[j], jOp)
 	})
 	for _, plan.target = range targets {
 		if op := l.createOperator(plan); len(op) > 0 {
@@ -243,10 +384,7 @@ func (l *balanceLeaderScheduler) transferLeaderIn(plan *balancePlan) []*operator
 		schedulerCounter.WithLabelValues(l.GetName(), "no-leader").Inc()
 		return nil
 	}
-	finalFilters := l.filters
-	if leaderFilter := filter.NewPlacementLeaderSafeguard(l.GetName(), plan.cluster, plan.region, plan.source); leaderFilter != nil {
-		finalFilters = append(l.filters, leaderFilter)
-	}
+	finalFilters := l.getFinalFilters(plan)
 	target := filter.NewCandidates([]*core.StoreInfo{plan.target}).
 		FilterTarget(plan.cluster.GetOpts(), finalFilters...).
 		PickFirst()
@@ -258,6 +396,19 @@ func (l *balanceLeaderScheduler) transferLeaderIn(plan *balancePlan) []*operator
 	return l.createOperator(plan)
 }
 
+// getFinalFilters returns the filters of the targets to transfer the leader of
+// the region in the plan to.
+func (l *balanceLeaderScheduler) getFinalFilters(plan *balancePlan) []filter.Filter {
+	finalFilters := l.filters
+	if leaderFilter := filter.NewPlacementLeaderSafeguard(l.GetName(), plan.cluster, plan.region, plan.source); leaderFilter != nil {
+		finalFilters = append(finalFilters, leaderFilter)
+	}
+	if constraintFilter := filter.NewLeaderConstraintFilter(l.GetName(), plan.leaderConstraints, plan.source); constraintFilter != nil {
+		finalFilters = append(finalFilters, constraintFilter)
+	}
+	return finalFilters
+}
+
 // createOperator creates the operator according to the source and target store.
 // If the region is hot or the difference between the two stores is tolerable, then
 // no new operator need to be created, otherwise create an operator that transfers
@@ -269,6 +420,20 @@ func (l *balanceLeaderScheduler) createOperator(plan *balancePlan) []*operator.O
 		return nil
 	}
 
+	// Keep the leader scattered recently on its store.
+	if l.opController.GetPlacementIntents().IsLeaderIntended(plan.region.GetID(), plan.SourceStoreID()) {
+		log.Debug("region is scattered recently, ignore it", zap.String("scheduler", l.GetName()), zap.Uint64("region-id", plan.region.GetID()))
+		schedulerCounter.WithLabelValues(l.GetName(), "region-scattered").Inc()
+		return nil
+	}
+
+	// Keep the leader co-located with its affinity group.
+	if m := plan.cluster.GetAffinityManager(); m != nil && m.IsLeaderAnchored(plan.region, plan.SourceStoreID()) {
+		log.Debug("region leader is kept by affinity group, ignore it", zap.String("scheduler", l.GetName()), zap.Uint64("region-id", plan.region.GetID()))
+		schedulerCounter.WithLabelValues(l.GetName(), "region-affinity").Inc()
+		return nil
+	}
+
 	if !plan.shouldBalance(l.GetName()) {
 		schedulerCounter.WithLabelValues(l.GetName(), "skip").Inc()
 		return nil
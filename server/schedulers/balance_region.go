@@ -143,7 +143,12 @@ func (s *balanceRegionScheduler) Schedule(cluster opt.Cluster) []*operator.Opera
 	kind := core.NewScheduleKind(core.RegionKind, core.BySize)
 	plan := newBalancePlan(kind, cluster, opInfluence)
 
+	criticalDays := opts.GetCapacityForecastCriticalDays()
 	sort.Slice(stores, func(i, j int) bool {
+		// The stores predicted to be full soon are balanced first.
+		if iFull, jFull := stores[i].IsPredictedFull(criticalDays), stores[j].IsPredictedFull(criticalDays); iFull != jFull {
+			return iFull
+		}
 		iOp := plan.GetOpInfluence(stores[i].GetID())
 		jOp := plan.GetOpInfluence(stores[j].GetID())
 		return stores[i].RegionScore(opts.GetRegionScoreFormulaVersion(), opts.GetHighSpaceRatio(), opts.GetLowSpaceRatio(), iOp) >
@@ -179,6 +184,18 @@ func (s *balanceRegionScheduler) Schedule(cluster opt.Cluster) []*operator.Opera
 				schedulerCounter.WithLabelValues(s.GetName(), "region-hot").Inc()
 				continue
 			}
+			// Skip the regions scattered to the source store recently.
+			if s.opController.GetPlacementIntents().IsPeerIntended(plan.region.GetID(), plan.SourceStoreID()) {
+				log.Debug("region is scattered recently", zap.String("scheduler", s.GetName()), zap.Uint64("region-id", plan.region.GetID()))
+				schedulerCounter.WithLabelValues(s.GetName(), "region-scattered").Inc()
+				continue
+			}
+			// Skip the regions co-located with their affinity groups on the source store.
+			if m := cluster.GetAffinityManager(); m != nil && m.IsPeerAnchored(plan.region, plan.SourceStoreID()) {
+				log.Debug("region is kept by affinity group", zap.String("scheduler", s.GetName()), zap.Uint64("region-id", plan.region.GetID()))
+				schedulerCounter.WithLabelValues(s.GetName(), "region-affinity").Inc()
+				continue
+			}
 			// Check region whether have leader
 			if plan.region.GetLeader() == nil {
 				log.Warn("region have no leader", zap.String("scheduler", s.GetName()), zap.Uint64("region-id", plan.region.GetID()))
@@ -202,6 +219,8 @@ func (s *balanceRegionScheduler) transferPeer(plan *balancePlan) *operator.Opera
 		filter.NewPlacementSafeguard(s.GetName(), plan.cluster, plan.region, plan.source),
 		filter.NewRegionScoreFilter(s.GetName(), plan.source, plan.cluster.GetOpts()),
 		filter.NewSpecialUseFilter(s.GetName()),
+		filter.NewCompactionPressureFilter(s.GetName()),
+		filter.NewCapacityForecastFilter(s.GetName()),
 		&filter.StoreStateFilter{ActionScope: s.GetName(), MoveRegion: true},
 	}
 
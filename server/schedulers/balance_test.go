@@ -27,8 +27,10 @@ import (
 	"github.com/tikv/pd/server/core"
 	"github.com/tikv/pd/server/kv"
 	"github.com/tikv/pd/server/schedule"
+	"github.com/tikv/pd/server/schedule/affinity"
 	"github.com/tikv/pd/server/schedule/hbstream"
 	"github.com/tikv/pd/server/schedule/operator"
+	"github.com/tikv/pd/server/statistics"
 	"github.com/tikv/pd/server/versioninfo"
 )
 
@@ -378,6 +380,36 @@ func (s *testBalanceLeaderSchedulerSuite) TestTransferLeaderOut(c *C) {
 	}
 }
 
+func (s *testBalanceLeaderSchedulerSuite) TestScatteredRegion(c *C) {
+	// Stores:     1    2
+	// Leaders:    0    16
+	// Region1:    F    L
+	s.tc.SetTolerantSizeRatio(2.5)
+	s.tc.AddLeaderStore(1, 0)
+	s.tc.AddLeaderStore(2, 16)
+	s.tc.AddLeaderRegion(1, 2, 1)
+	s.oc.GetPlacementIntents().Register(1, map[uint64]*metapb.Peer{1: {StoreId: 1}, 2: {StoreId: 2}}, 2)
+	c.Assert(s.schedule(), HasLen, 0)
+	s.oc.GetPlacementIntents().Remove(1)
+	testutil.CheckTransferLeader(c, s.schedule()[0], operator.OpKind(0), 2, 1)
+}
+
+func (s *testBalanceLeaderSchedulerSuite) TestAffinityRegion(c *C) {
+	// Stores:     1    2
+	// Leaders:    0    16
+	// Region1:    F    L
+	s.tc.SetTolerantSizeRatio(2.5)
+	s.tc.AddLeaderStore(1, 0)
+	s.tc.AddLeaderStore(2, 16)
+	s.tc.AddLeaderRegion(1, 2, 1)
+	m := s.tc.GetAffinityManager()
+	c.Assert(m.SetGroup(&affinity.Group{ID: "g", Ranges: []*affinity.KeyRange{{}}}), IsNil)
+	c.Assert(m.SetAnchor("g", 2, []uint64{2}), IsNil)
+	c.Assert(s.schedule(), HasLen, 0)
+	c.Assert(m.DeleteGroup("g"), IsNil)
+	testutil.CheckTransferLeader(c, s.schedule()[0], operator.OpKind(0), 2, 1)
+}
+
 func (s *testBalanceLeaderSchedulerSuite) TestBalanceFilter(c *C) {
 	// Stores:     1    2    3    4
 	// Leaders:    1    2    3   16
@@ -405,12 +437,54 @@ func (s *testBalanceLeaderSchedulerSuite) TestBalanceFilter(c *C) {
 	s.tc.SetStoreBusy(2, true)
 	testutil.CheckTransferLeader(c, s.schedule()[0], operator.OpKind(0), 4, 3)
 
+	// Test readOnlyFilter.
+	// If store 3 is read-only, no operator can be created.
+	s.tc.PutStore(s.tc.GetStore(3).Clone(core.SetStoreReadOnly(true)))
+	c.Assert(s.schedule(), HasLen, 0)
+	s.tc.PutStore(s.tc.GetStore(3).Clone(core.SetStoreReadOnly(false)))
+
 	// Test disconnectFilter.
 	// If store 3 is disconnected, no operator can be created.
 	s.tc.SetStoreDisconnect(3)
 	c.Assert(s.schedule(), HasLen, 0)
 }
 
+func (s *testBalanceLeaderSchedulerSuite) TestLeaderConstraint(c *C) {
+	// Stores:     1       2       3
+	// Zones:      z1      z2      z3
+	// Leaders:    2       10      5
+	// Region1:    F       L       F
+	s.tc.SetTolerantSizeRatio(1)
+	for id, zone := range map[uint64]string{1: "z1", 2: "z2", 3: "z3"} {
+		s.tc.AddLabelsStore(id, 20, map[string]string{"zone": zone})
+	}
+	s.tc.UpdateLeaderCount(1, 2)
+	s.tc.UpdateLeaderCount(2, 10)
+	s.tc.UpdateLeaderCount(3, 5)
+	s.tc.AddLeaderRegion(1, 2, 1, 3)
+	testutil.CheckTransferLeader(c, s.schedule()[0], operator.OpKind(0), 2, 1)
+
+	// The leaders are not moved into z1 beyond the max ratio.
+	s.tc.SetLeaderConstraints([]config.LeaderConstraint{{LabelKey: "zone", LabelValue: "z1", MaxRatio: 0.15}})
+	testutil.CheckTransferLeader(c, s.schedule()[0], operator.OpKind(0), 2, 3)
+
+	// Stores:     1       2       3
+	// Leaders:    10      10      9
+	// Region1:    L       F       F
+	// Region2:    F       L       F
+	// The leaders are balanced within the tolerance, but those in z1 are
+	// beyond the max ratio, so they are moved out.
+	s.tc.SetTolerantSizeRatio(2.5)
+	s.tc.UpdateLeaderCount(1, 10)
+	s.tc.UpdateLeaderCount(3, 9)
+	s.tc.AddLeaderRegion(1, 1, 2, 3)
+	s.tc.AddLeaderRegion(2, 2, 1, 3)
+	s.tc.SetLeaderConstraints(nil)
+	c.Assert(s.schedule(), HasLen, 0)
+	s.tc.SetLeaderConstraints([]config.LeaderConstraint{{LabelKey: "zone", LabelValue: "z1", MaxRatio: 0.3}})
+	testutil.CheckTransferLeader(c, s.schedule()[0], operator.OpKind(0), 1, 3)
+}
+
 func (s *testBalanceLeaderSchedulerSuite) TestLeaderWeight(c *C) {
 	// Stores:     1       2       3       4
 	// Leaders:    10      10      10      10
@@ -446,6 +520,50 @@ func (s *testBalanceLeaderSchedulerSuite) TestBalancePolicy(c *C) {
 	testutil.CheckTransferLeader(c, s.schedule()[0], operator.OpKind(0), 1, 4)
 }
 
+func (s *testBalanceLeaderSchedulerSuite) TestBalanceLeaderMode(c *C) {
+	// Stores:          1       2       3       4
+	// Leader Count:    10      10      10      10
+	// Leader Size :    100     100     100     10000
+	// Read QPS:        1000    0       0       0
+	// Region1:         L       F       F       F
+	// Region2:         L(hot)  F       F       F
+	// Region3:         F       F       F       L
+	s.tc.SetHotRegionCacheHitsThreshold(0)
+	s.tc.AddLeaderStore(1, 10, 100*MB)
+	s.tc.AddLeaderStore(2, 10, 100*MB)
+	s.tc.AddLeaderStore(3, 10, 100*MB)
+	s.tc.AddLeaderStore(4, 10, 10000*MB)
+	s.tc.AddLeaderRegion(1, 1, 2, 3, 4)
+	s.tc.AddRegionLeaderWithReadQuery(2, 1, 512*KB*statistics.ReadReportInterval, 0, 1000*statistics.ReadReportInterval,
+		statistics.ReadReportInterval, []uint64{2, 3, 4})
+	s.tc.AddLeaderRegion(3, 4, 1, 2, 3)
+	conf := s.lb.(*balanceLeaderScheduler).conf
+	c.Assert(conf.GetMode(), Equals, leaderLoadByPolicy)
+	c.Check(s.schedule(), IsNil)
+
+	// The leaders of store 4 are larger.
+	conf.Mode = leaderLoadBySize
+	ops := s.schedule()
+	c.Assert(ops, HasLen, 1)
+	testutil.CheckTransferLeaderFrom(c, ops[0], operator.OpKind(0), 4)
+
+	// The leaders of store 1 are hotter. The hot region is not moved.
+	conf.Mode = leaderLoadByQPS
+	loads := getHotLeaderLoads(s.tc)
+	c.Assert(loads, HasLen, 1)
+	c.Assert(loads[1], Equals, float64(40))
+	ops = s.schedule()
+	c.Assert(ops, HasLen, 1)
+	testutil.CheckTransferLeaderFrom(c, ops[0], operator.OpKind(0), 1)
+	c.Assert(ops[0].RegionID(), Equals, uint64(1))
+
+	conf.Mode = leaderLoadByCount
+	c.Check(s.schedule(), IsNil)
+	data, err := s.lb.EncodeConfig()
+	c.Assert(err, IsNil)
+	c.Assert(string(data), Matches, `.*"mode":"count".*`)
+}
+
 func (s *testBalanceLeaderSchedulerSuite) TestBalanceSelector(c *C) {
 	// Stores:     1    2    3    4
 	// Leaders:    1    2    3   16
@@ -648,6 +766,48 @@ func (s *testBalanceRegionSchedulerSuite) TestBalance(c *C) {
 	c.Assert(sb.Schedule(tc), NotNil)
 }
 
+func (s *testBalanceRegionSchedulerSuite) TestScatteredRegion(c *C) {
+	opt := config.NewTestOptions()
+	opt.SetPlacementRuleEnabled(false)
+	tc := mockcluster.NewCluster(s.ctx, opt)
+	tc.DisableFeature(versioninfo.JointConsensus)
+	oc := schedule.NewOperatorController(s.ctx, nil, nil)
+	sb, err := schedule.CreateScheduler(BalanceRegionType, oc, core.NewStorage(kv.NewMemoryKV()), schedule.ConfigSliceDecoder(BalanceRegionType, []string{"", ""}))
+	c.Assert(err, IsNil)
+	opt.SetMaxReplicas(1)
+
+	tc.AddRegionStore(1, 6)
+	tc.AddRegionStore(2, 16)
+	tc.AddLeaderRegion(1, 2)
+	// The region is scattered to store 2 recently, so it is kept there.
+	oc.GetPlacementIntents().Register(1, map[uint64]*metapb.Peer{2: {StoreId: 2}}, 2)
+	c.Assert(sb.Schedule(tc), HasLen, 0)
+	oc.GetPlacementIntents().Remove(1)
+	testutil.CheckTransferPeerWithLeaderTransfer(c, sb.Schedule(tc)[0], operator.OpKind(0), 2, 1)
+}
+
+func (s *testBalanceRegionSchedulerSuite) TestAffinityRegion(c *C) {
+	opt := config.NewTestOptions()
+	opt.SetPlacementRuleEnabled(false)
+	tc := mockcluster.NewCluster(s.ctx, opt)
+	tc.DisableFeature(versioninfo.JointConsensus)
+	oc := schedule.NewOperatorController(s.ctx, nil, nil)
+	sb, err := schedule.CreateScheduler(BalanceRegionType, oc, core.NewStorage(kv.NewMemoryKV()), schedule.ConfigSliceDecoder(BalanceRegionType, []string{"", ""}))
+	c.Assert(err, IsNil)
+	opt.SetMaxReplicas(1)
+
+	tc.AddRegionStore(1, 6)
+	tc.AddRegionStore(2, 16)
+	tc.AddLeaderRegion(1, 2)
+	// The region is co-located with its affinity group on store 2.
+	m := tc.GetAffinityManager()
+	c.Assert(m.SetGroup(&affinity.Group{ID: "g", Mode: affinity.ReplicaMode, Ranges: []*affinity.KeyRange{{}}}), IsNil)
+	c.Assert(m.SetAnchor("g", 2, []uint64{2}), IsNil)
+	c.Assert(sb.Schedule(tc), HasLen, 0)
+	c.Assert(m.DeleteGroup("g"), IsNil)
+	testutil.CheckTransferPeerWithLeaderTransfer(c, sb.Schedule(tc)[0], operator.OpKind(0), 2, 1)
+}
+
 func (s *testBalanceRegionSchedulerSuite) TestReplicas3(c *C) {
 	opt := config.NewTestOptions()
 	//TODO: enable placementrules
@@ -883,6 +1043,95 @@ func (s *testBalanceRegionSchedulerSuite) TestStoreWeight(c *C) {
 	testutil.CheckTransferPeer(c, sb.Schedule(tc)[0], operator.OpKind(0), 1, 3)
 }
 
+func (s *testBalanceRegionSchedulerSuite) TestDiskClass(c *C) {
+	opt := config.NewTestOptions()
+	tc := mockcluster.NewCluster(s.ctx, opt)
+	// TODO: enable placementrules
+	tc.SetPlacementRuleEnabled(false)
+	tc.DisableFeature(versioninfo.JointConsensus)
+	oc := schedule.NewOperatorController(s.ctx, nil, nil)
+
+	sb, err := schedule.CreateScheduler(BalanceRegionType, oc, core.NewStorage(kv.NewMemoryKV()), schedule.ConfigSliceDecoder(BalanceRegionType, []string{"", ""}))
+	c.Assert(err, IsNil)
+	opt.SetMaxReplicas(1)
+
+	// Moving a region to the HDD-backed store costs more, so the difference
+	// is not big enough.
+	tc.AddRegionStore(1, 16)
+	tc.AddLabelsStore(2, 13, map[string]string{core.DiskClassLabel: core.DiskClassHDD})
+	tc.AddLeaderRegion(1, 1)
+	c.Assert(sb.Schedule(tc), IsNil)
+
+	// The same difference is big enough for the SSD-backed store.
+	tc.AddLabelsStore(3, 13, map[string]string{core.DiskClassLabel: core.DiskClassSSD})
+	testutil.CheckTransferPeer(c, sb.Schedule(tc)[0], operator.OpKind(0), 1, 3)
+
+	// The HDD-backed store is still a target if the difference is bigger.
+	tc.UpdateRegionCount(2, 8)
+	testutil.CheckTransferPeer(c, sb.Schedule(tc)[0], operator.OpKind(0), 1, 2)
+}
+
+func (s *testBalanceRegionSchedulerSuite) TestCapacityForecast(c *C) {
+	opt := config.NewTestOptions()
+	tc := mockcluster.NewCluster(s.ctx, opt)
+	tc.SetPlacementRuleEnabled(false)
+	tc.DisableFeature(versioninfo.JointConsensus)
+	oc := schedule.NewOperatorController(s.ctx, nil, nil)
+
+	sb, err := schedule.CreateScheduler(BalanceRegionType, oc, core.NewStorage(kv.NewMemoryKV()), schedule.ConfigSliceDecoder(BalanceRegionType, []string{"", ""}))
+	c.Assert(err, IsNil)
+	opt.SetMaxReplicas(1)
+
+	// The difference is not big enough.
+	tc.AddRegionStore(1, 10)
+	tc.AddRegionStore(2, 9)
+	tc.AddLeaderRegion(1, 1)
+	c.Assert(sb.Schedule(tc), IsNil)
+
+	// The regions are moved away from the store predicted to be full soon.
+	tc.PutStore(tc.GetStore(1).Clone(core.SetDaysToFull(3)))
+	testutil.CheckTransferPeer(c, sb.Schedule(tc)[0], operator.OpKind(0), 1, 2)
+
+	// The store predicted to be full soon is not a target.
+	tc.PutStore(tc.GetStore(1).Clone(core.SetDaysToFull(0)))
+	tc.PutStore(tc.GetStore(2).Clone(core.SetDaysToFull(3)))
+	tc.UpdateRegionCount(1, 20)
+	c.Assert(sb.Schedule(tc), IsNil)
+}
+
+func (s *testBalanceRegionSchedulerSuite) TestZoneTransferCost(c *C) {
+	opt := config.NewTestOptions()
+	tc := mockcluster.NewCluster(s.ctx, opt)
+	tc.SetPlacementRuleEnabled(false)
+	tc.DisableFeature(versioninfo.JointConsensus)
+	oc := schedule.NewOperatorController(s.ctx, nil, nil)
+
+	sb, err := schedule.CreateScheduler(BalanceRegionType, oc, core.NewStorage(kv.NewMemoryKV()), schedule.ConfigSliceDecoder(BalanceRegionType, []string{"", ""}))
+	c.Assert(err, IsNil)
+	opt.SetMaxReplicas(1)
+	cfg := opt.GetScheduleConfig().Clone()
+	cfg.ZoneLabelKey = "zone"
+	opt.SetScheduleConfig(cfg)
+
+	// Moving a region across the zones costs more, so the difference is not
+	// big enough.
+	tc.AddLabelsStore(1, 16, map[string]string{"zone": "z1"})
+	tc.AddLabelsStore(2, 13, map[string]string{"zone": "z2"})
+	tc.AddLeaderRegion(1, 1)
+	c.Assert(sb.Schedule(tc), IsNil)
+
+	// The same difference is big enough within the zone.
+	tc.AddLabelsStore(3, 13, map[string]string{"zone": "z1"})
+	testutil.CheckTransferPeer(c, sb.Schedule(tc)[0], operator.OpKind(0), 1, 3)
+
+	// The cost of the zone pair can be overridden.
+	cfg = opt.GetScheduleConfig().Clone()
+	cfg.ZoneTransferCosts = []config.ZoneTransferCost{{FromZone: "z1", ToZone: "z2", Cost: 1}}
+	opt.SetScheduleConfig(cfg)
+	tc.UpdateRegionCount(3, 14)
+	testutil.CheckTransferPeer(c, sb.Schedule(tc)[0], operator.OpKind(0), 1, 2)
+}
+
 func (s *testBalanceRegionSchedulerSuite) TestReplacePendingRegion(c *C) {
 	opt := config.NewTestOptions()
 	tc := mockcluster.NewCluster(s.ctx, opt)
@@ -155,6 +155,36 @@ func (conf *evictLeaderSchedulerConfig) removeStore(id uint64) (succ bool, last
 	return succ, last
 }
 
+// deleteStore stops evicting leaders from the store and persists the config.
+// The scheduler is removed if the store is the last one.
+func (conf *evictLeaderSchedulerConfig) deleteStore(id uint64) (last bool, err error) {
+	keyRanges := conf.getKeyRangesByID(id)
+	succ, last := conf.removeStore(id)
+	if !succ {
+		return false, errs.ErrScheduleConfigNotExist.FastGenByArgs()
+	}
+	if err := conf.Persist(); err != nil {
+		conf.resetStore(id, keyRanges)
+		return false, err
+	}
+	if last {
+		if err := conf.cluster.RemoveScheduler(EvictLeaderName); err != nil {
+			if !errors.ErrorEqual(err, errs.ErrSchedulerNotFound.FastGenByArgs()) {
+				conf.resetStore(id, keyRanges)
+			}
+			return true, err
+		}
+	}
+	return last, nil
+}
+
+func (conf *evictLeaderSchedulerConfig) hasStore(id uint64) bool {
+	conf.mu.RLock()
+	defer conf.mu.RUnlock()
+	_, ok := conf.StoreIDWithRanges[id]
+	return ok
+}
+
 func (conf *evictLeaderSchedulerConfig) resetStore(id uint64, keyRange []core.KeyRange) {
 	conf.mu.Lock()
 	defer conf.mu.Unlock()
@@ -193,6 +223,18 @@ func (s *evictLeaderScheduler) ServeHTTP(w http.ResponseWriter, r *http.Request)
 	s.handler.ServeHTTP(w, r)
 }
 
+// HasStore returns whether the leaders of the store are being evicted.
+func (s *evictLeaderScheduler) HasStore(storeID uint64) bool {
+	return s.conf.hasStore(storeID)
+}
+
+// RemoveStore stops evicting leaders from the store. The scheduler is removed
+// if the store is the last one.
+func (s *evictLeaderScheduler) RemoveStore(storeID uint64) error {
+	_, err := s.conf.deleteStore(storeID)
+	return err
+}
+
 func (s *evictLeaderScheduler) GetName() string {
 	return EvictLeaderName
 }
@@ -359,32 +401,20 @@ func (handler *evictLeaderHandler) DeleteConfig(w http.ResponseWriter, r *http.R
 	}
 
 	var resp interface{}
-	keyRanges := handler.config.getKeyRangesByID(id)
-	succ, last := handler.config.removeStore(id)
-	if succ {
-		err = handler.config.Persist()
-		if err != nil {
-			handler.config.resetStore(id, keyRanges)
-			handler.rd.JSON(w, http.StatusInternalServerError, err.Error())
-			return
-		}
+	last, err := handler.config.deleteStore(id)
+	switch {
+	case errs.ErrScheduleConfigNotExist.Equal(err):
+		handler.rd.JSON(w, http.StatusNotFound, err.Error())
+	case err != nil && errors.ErrorEqual(err, errs.ErrSchedulerNotFound.FastGenByArgs()):
+		handler.rd.JSON(w, http.StatusNotFound, err.Error())
+	case err != nil:
+		handler.rd.JSON(w, http.StatusInternalServerError, err.Error())
+	default:
 		if last {
-			if err := handler.config.cluster.RemoveScheduler(EvictLeaderName); err != nil {
-				if errors.ErrorEqual(err, errs.ErrSchedulerNotFound.FastGenByArgs()) {
-					handler.rd.JSON(w, http.StatusNotFound, err.Error())
-				} else {
-					handler.config.resetStore(id, keyRanges)
-					handler.rd.JSON(w, http.StatusInternalServerError, err.Error())
-				}
-				return
-			}
 			resp = lastStoreDeleteInfo
 		}
 		handler.rd.JSON(w, http.StatusOK, resp)
-		return
 	}
-
-	handler.rd.JSON(w, http.StatusNotFound, errs.ErrScheduleConfigNotExist.FastGenByArgs().Error())
 }
 
 func newEvictLeaderHandler(config *evictLeaderSchedulerConfig) http.Handler {
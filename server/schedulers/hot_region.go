@@ -65,6 +65,12 @@ const (
 
 	minHotScheduleInterval = time.Second
 	maxHotScheduleInterval = 20 * time.Second
+
+	// predictiveHorizon is how far the loads are extrapolated by the trends.
+	predictiveHorizon = 30 * time.Second
+	// trendFlatRatio is the ratio of the change of the load over the horizon,
+	// under which the load is considered flat.
+	trendFlatRatio = 0.05
 )
 
 // schedulePeerPr the probability of schedule the hot peer.
@@ -528,6 +534,7 @@ func (bs *balanceSolver) solve() []*operator.Operator {
 	if best == nil || !bs.sche.addPendingInfluence(op, best.srcStoreID, best.dstStoreID, infl) {
 		return nil
 	}
+	bs.countTrend(best.srcPeerStat)
 
 	return []*operator.Operator{op}
 }
@@ -596,13 +603,13 @@ func (bs *balanceSolver) sortHotPeers(ret []*statistics.HotPeerStat, maxPeerNum
 	copy(byteSort, ret)
 	sort.Slice(byteSort, func(i, j int) bool {
 		k := getRegionStatKind(bs.rwTy, statistics.ByteDim)
-		return byteSort[i].GetLoad(k) > byteSort[j].GetLoad(k)
+		return bs.peerLoad(byteSort[i], k) > bs.peerLoad(byteSort[j], k)
 	})
 	keySort := make([]*statistics.HotPeerStat, len(ret))
 	copy(keySort, ret)
 	sort.Slice(keySort, func(i, j int) bool {
 		k := getRegionStatKind(bs.rwTy, statistics.KeyDim)
-		return keySort[i].GetLoad(k) > keySort[j].GetLoad(k)
+		return bs.peerLoad(keySort[i], k) > bs.peerLoad(keySort[j], k)
 	})
 
 	union := make(map[*statistics.HotPeerStat]struct{}, maxPeerNum)
@@ -841,20 +848,27 @@ func (bs *balanceSolver) betterThan(old *solution) bool {
 		return false
 	}
 
+	// Prefer the cheaper move if the stores are comparable.
+	if bs.opTy == movePeer {
+		if cost, oldCost := bs.zoneTransferCost(bs.cur), bs.zoneTransferCost(old); cost != oldCost {
+			return cost < oldCost
+		}
+	}
+
 	if bs.cur.srcPeerStat != old.srcPeerStat {
 		// compare region
 
 		if bs.rwTy == write && bs.opTy == transferLeader {
 			switch {
-			case bs.cur.srcPeerStat.GetLoad(statistics.RegionWriteKeys) > old.srcPeerStat.GetLoad(statistics.RegionWriteKeys):
+			case bs.peerLoad(bs.cur.srcPeerStat, statistics.RegionWriteKeys) > bs.peerLoad(old.srcPeerStat, statistics.RegionWriteKeys):
 				return true
-			case bs.cur.srcPeerStat.GetLoad(statistics.RegionWriteKeys) < old.srcPeerStat.GetLoad(statistics.RegionWriteKeys):
+			case bs.peerLoad(bs.cur.srcPeerStat, statistics.RegionWriteKeys) < bs.peerLoad(old.srcPeerStat, statistics.RegionWriteKeys):
 				return false
 			}
 		} else {
 			bk, kk := getRegionStatKind(bs.rwTy, statistics.ByteDim), getRegionStatKind(bs.rwTy, statistics.KeyDim)
-			byteRkCmp := rankCmp(bs.cur.srcPeerStat.GetLoad(bk), old.srcPeerStat.GetLoad(bk), stepRank(0, 100))
-			keyRkCmp := rankCmp(bs.cur.srcPeerStat.GetLoad(kk), old.srcPeerStat.GetLoad(kk), stepRank(0, 10))
+			byteRkCmp := rankCmp(bs.peerLoad(bs.cur.srcPeerStat, bk), bs.peerLoad(old.srcPeerStat, bk), stepRank(0, 100))
+			keyRkCmp := rankCmp(bs.peerLoad(bs.cur.srcPeerStat, kk), bs.peerLoad(old.srcPeerStat, kk), stepRank(0, 10))
 
 			switch bs.cur.progressiveRank {
 			case -2: // greatDecRatio < byteDecRatio <= minorDecRatio && keyDecRatio <= greatDecRatio
@@ -882,6 +896,42 @@ func (bs *balanceSolver) betterThan(old *solution) bool {
 	return false
 }
 
+func (bs *balanceSolver) zoneTransferCost(sol *solution) float64 {
+	return getZoneTransferCost(bs.cluster, bs.cluster.GetStore(sol.srcStoreID), bs.cluster.GetStore(sol.dstStoreID))
+}
+
+// peerLoad returns the load of the peer to compare the peers. If the
+// predictive scheduling is enabled, the load is extrapolated by its trend over
+// predictiveHorizon, so that a rising peer is preferred to a declining one
+// with a higher current load.
+func (bs *balanceSolver) peerLoad(peer *statistics.HotPeerStat, k statistics.RegionStatKind) float64 {
+	load := peer.GetLoad(k)
+	if !bs.sche.conf.IsPredictiveEnabled() {
+		return load
+	}
+	return math.Max(load+peer.GetTrend(k)*predictiveHorizon.Seconds(), 0)
+}
+
+// countTrend records the trend of the moved peer, so that the predictive and
+// the reactive modes can be compared.
+func (bs *balanceSolver) countTrend(peer *statistics.HotPeerStat) {
+	mode := "reactive"
+	if bs.sche.conf.IsPredictiveEnabled() {
+		mode = "predictive"
+	}
+	k := getRegionStatKind(bs.rwTy, statistics.ByteDim)
+	if bs.rwTy == write && bs.opTy == transferLeader {
+		k = statistics.RegionWriteKeys
+	}
+	trend := "flat"
+	if change := peer.GetTrend(k) * predictiveHorizon.Seconds(); change > peer.GetLoad(k)*trendFlatRatio {
+		trend = "rising"
+	} else if change < -peer.GetLoad(k)*trendFlatRatio {
+		trend = "declining"
+	}
+	hotPredictiveCounter.WithLabelValues(bs.rwTy.String(), mode, trend).Inc()
+}
+
 // smaller is better
 func (bs *balanceSolver) compareSrcStore(st1, st2 uint64) int {
 	if st1 != st2 {
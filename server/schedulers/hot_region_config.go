@@ -67,6 +67,9 @@ type hotRegionSchedulerConfig struct {
 	MinorDecRatio          float64 `json:"minor-dec-ratio"`
 	SrcToleranceRatio      float64 `json:"src-tolerance-ratio"`
 	DstToleranceRatio      float64 `json:"dst-tolerance-ratio"`
+	// EnablePredictive makes the scheduler compare the hot peers with their
+	// loads extrapolated by the trends, so the rising peers are preferred.
+	EnablePredictive bool `json:"enable-predictive,string"`
 }
 
 func (conf *hotRegionSchedulerConfig) EncodeConfig() ([]byte, error) {
@@ -147,6 +150,12 @@ func (conf *hotRegionSchedulerConfig) GetMinorGreatDecRatio() float64 {
 	return conf.MinorDecRatio
 }
 
+func (conf *hotRegionSchedulerConfig) IsPredictiveEnabled() bool {
+	conf.RLock()
+	defer conf.RUnlock()
+	return conf.EnablePredictive
+}
+
 func (conf *hotRegionSchedulerConfig) GetMinHotKeyRate() float64 {
 	conf.RLock()
 	defer conf.RUnlock()
@@ -1323,6 +1323,49 @@ func (s *testHotCacheSuite) TestSortHotPeer(c *C) {
 	checkSortResult(c, []uint64{1, 2}, u)
 }
 
+func (s *testHotCacheSuite) TestSortHotPeerPredictive(c *C) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	opt := config.NewTestOptions()
+	tc := mockcluster.NewCluster(ctx, opt)
+	sche, err := schedule.CreateScheduler(HotRegionType, schedule.NewOperatorController(ctx, tc, nil), core.NewStorage(kv.NewMemoryKV()), schedule.ConfigJSONDecoder([]byte("null")))
+	c.Assert(err, IsNil)
+	hb := sche.(*hotScheduler)
+	leaderSolver := newBalanceSolver(hb, tc, read, transferLeader)
+
+	// The region 1 is hotter now, but the region 2 is rising fast.
+	hotPeers := []*statistics.HotPeerStat{{
+		RegionID: 1,
+		Loads: []float64{
+			statistics.RegionReadBytes: 100,
+			statistics.RegionReadKeys:  10,
+		},
+		Trends: []float64{
+			statistics.RegionReadBytes: -1,
+			statistics.RegionReadKeys:  0,
+		},
+	}, {
+		RegionID: 2,
+		Loads: []float64{
+			statistics.RegionReadBytes: 60,
+			statistics.RegionReadKeys:  6,
+		},
+		Trends: []float64{
+			statistics.RegionReadBytes: 2,
+			statistics.RegionReadKeys:  0.2,
+		},
+	}}
+
+	checkSortResult(c, []uint64{1}, leaderSolver.sortHotPeers(hotPeers, 1))
+	hb.conf.EnablePredictive = true
+	checkSortResult(c, []uint64{2}, leaderSolver.sortHotPeers(hotPeers, 1))
+	// The extrapolated load is never negative.
+	c.Assert(leaderSolver.peerLoad(&statistics.HotPeerStat{
+		Loads:  []float64{statistics.RegionReadBytes: 10},
+		Trends: []float64{statistics.RegionReadBytes: -10},
+	}, statistics.RegionReadBytes), Equals, 0.0)
+}
+
 func checkSortResult(c *C, regions []uint64, hotPeers map[*statistics.HotPeerStat]struct{}) {
 	c.Assert(len(regions), Equals, len(hotPeers))
 	for _, region := range regions {
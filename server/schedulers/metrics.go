@@ -95,6 +95,14 @@ var hotDirectionCounter = prometheus.NewCounterVec(
 		Help:      "Counter of hot region scheduler.",
 	}, []string{"type", "rw", "store", "direction"})
 
+var hotPredictiveCounter = prometheus.NewCounterVec(
+	prometheus.CounterOpts{
+		Namespace: "pd",
+		Subsystem: "scheduler",
+		Name:      "hot_region_trend",
+		Help:      "Counter of the trends of the peers moved by hot region scheduler in each mode.",
+	}, []string{"rw", "mode", "trend"})
+
 var scatterRangeLeaderCounter = prometheus.NewCounterVec(
 	prometheus.CounterOpts{
 		Namespace: "pd",
@@ -119,6 +127,7 @@ func init() {
 	prometheus.MustRegister(balanceRegionCounter)
 	prometheus.MustRegister(hotSchedulerResultCounter)
 	prometheus.MustRegister(hotDirectionCounter)
+	prometheus.MustRegister(hotPredictiveCounter)
 	prometheus.MustRegister(balanceDirectionCounter)
 	prometheus.MustRegister(scatterRangeLeaderCounter)
 	prometheus.MustRegister(scatterRangeRegionCounter)
@@ -23,6 +23,7 @@ import (
 	"github.com/tikv/pd/pkg/errs"
 	"github.com/tikv/pd/pkg/typeutil"
 	"github.com/tikv/pd/server/core"
+	"github.com/tikv/pd/server/schedule/leaderconstraint"
 	"github.com/tikv/pd/server/schedule/operator"
 	"github.com/tikv/pd/server/schedule/opt"
 	"github.com/tikv/pd/server/statistics"
@@ -35,8 +36,38 @@ const (
 	leaderTolerantSizeRatio float64 = 5.0
 	minTolerantSizeRatio    float64 = 1.0
 	influenceAmp            int64   = 100
+	// minLeaderWeight is the same as the one used in the leader score.
+	minLeaderWeight float64 = 1e-6
 )
 
+// diskClassMoveCost amplifies the cost of moving a region to a store by its
+// disk throughput class. The snapshots are received and applied slowly on
+// HDD-backed stores, so the regions should be moved to them only if it makes
+// a bigger difference, otherwise the snapshots queue up there in a mixed cluster.
+var diskClassMoveCost = map[string]float64{
+	core.DiskClassHDD:  2,
+	core.DiskClassSSD:  1,
+	core.DiskClassNVMe: 1,
+}
+
+func getMoveCost(store *core.StoreInfo) float64 {
+	if cost, ok := diskClassMoveCost[store.GetDiskClass()]; ok {
+		return cost
+	}
+	return 1
+}
+
+// getZoneTransferCost returns the cost weight of moving data between the
+// zones of the stores.
+func getZoneTransferCost(cluster opt.Cluster, source, target *core.StoreInfo) float64 {
+	opts := cluster.GetOpts()
+	key := opts.GetZoneLabelKey()
+	if key == "" || source == nil || target == nil {
+		return 1
+	}
+	return opts.GetZoneTransferCost(source.GetLabelValue(key), target.GetLabelValue(key))
+}
+
 type balancePlan struct {
 	kind              core.ScheduleKind
 	cluster           opt.Cluster
@@ -49,6 +80,13 @@ type balancePlan struct {
 
 	sourceScore float64
 	targetScore float64
+
+	// hotLeaderLoads is the extra leader load of each store converted from
+	// the QPS of its hot leaders, which is only used for the leader kind.
+	hotLeaderLoads map[uint64]float64
+	// leaderConstraints is the leader distribution of the leader
+	// constraints, which is only used for the leader kind.
+	leaderConstraints *leaderconstraint.State
 }
 
 func newBalancePlan(kind core.ScheduleKind, cluster opt.Cluster, opInfluence operator.OpInfluence) *balancePlan {
@@ -64,6 +102,16 @@ func (p *balancePlan) GetOpInfluence(storeID uint64) int64 {
 	return p.opInfluence.GetStoreInfluence(storeID).ResourceProperty(p.kind)
 }
 
+// leaderScore returns the leader score of the store, including the load of
+// its hot leaders if any.
+func (p *balancePlan) leaderScore(store *core.StoreInfo, delta int64) float64 {
+	score := store.LeaderScore(p.kind.Policy, delta)
+	if load, ok := p.hotLeaderLoads[store.GetID()]; ok {
+		score += load / math.Max(store.GetLeaderWeight(), minLeaderWeight)
+	}
+	return score
+}
+
 func (p *balancePlan) SourceStoreID() uint64 {
 	return p.source.GetID()
 }
@@ -103,13 +151,27 @@ func (p *balancePlan) shouldBalance(scheduleName string) bool {
 		targetInfluence = -targetInfluence
 	}
 	opts := p.cluster.GetOpts()
+	// The regions are moved away from the store predicted to be full soon
+	// preemptively, so it is not tolerated to be a bit more than the target.
+	if p.kind.Resource == core.RegionKind && p.source.IsPredictedFull(opts.GetCapacityForecastCriticalDays()) {
+		tolerantResource = 0
+	}
+	// Likewise, the leaders are moved out of the stores beyond the max ratio
+	// of a leader constraint.
+	if p.kind.Resource == core.LeaderKind && p.leaderConstraints.IsViolated(p.source, false) && !p.leaderConstraints.IsViolated(p.target, false) {
+		tolerantResource = 0
+	}
 	switch p.kind.Resource {
 	case core.LeaderKind:
 		sourceDelta, targetDelta := sourceInfluence-tolerantResource, targetInfluence+tolerantResource
-		p.sourceScore = p.source.LeaderScore(p.kind.Policy, sourceDelta)
-		p.targetScore = p.target.LeaderScore(p.kind.Policy, targetDelta)
+		p.sourceScore = p.leaderScore(p.source, sourceDelta)
+		p.targetScore = p.leaderScore(p.target, targetDelta)
 	case core.RegionKind:
-		sourceDelta, targetDelta := sourceInfluence*influenceAmp-tolerantResource, targetInfluence*influenceAmp+tolerantResource
+		sourceDelta := sourceInfluence*influenceAmp - tolerantResource
+		// The target is penalized by the cost of moving the data, so the
+		// cheaper moves are preferred when the scores are comparable.
+		moveCost := getMoveCost(p.target) * getZoneTransferCost(p.cluster, p.source, p.target)
+		targetDelta := targetInfluence*influenceAmp + int64(float64(tolerantResource)*moveCost)
 		p.sourceScore = p.source.RegionScore(opts.GetRegionScoreFormulaVersion(), opts.GetHighSpaceRatio(), opts.GetLowSpaceRatio(), sourceDelta)
 		p.targetScore = p.target.RegionScore(opts.GetRegionScoreFormulaVersion(), opts.GetHighSpaceRatio(), opts.GetLowSpaceRatio(), targetDelta)
 	}
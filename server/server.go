@@ -16,6 +16,7 @@ package server
 import (
 	"bytes"
 	"context"
+	"crypto/tls"
 	"fmt"
 	"math/rand"
 	"net/http"
@@ -43,19 +44,28 @@ import (
 	"github.com/tikv/pd/pkg/etcdutil"
 	"github.com/tikv/pd/pkg/grpcutil"
 	"github.com/tikv/pd/pkg/logutil"
+	"github.com/tikv/pd/pkg/regionpb"
 	"github.com/tikv/pd/pkg/systimemon"
 	"github.com/tikv/pd/pkg/typeutil"
 	"github.com/tikv/pd/server/cluster"
 	"github.com/tikv/pd/server/config"
 	"github.com/tikv/pd/server/core"
+	"github.com/tikv/pd/server/degradation"
+	"github.com/tikv/pd/server/diagnosis"
 	"github.com/tikv/pd/server/encryptionkm"
+	"github.com/tikv/pd/server/events"
+	"github.com/tikv/pd/server/heatmap"
 	"github.com/tikv/pd/server/id"
 	"github.com/tikv/pd/server/kv"
 	"github.com/tikv/pd/server/member"
+	"github.com/tikv/pd/server/metasnapshot"
+	"github.com/tikv/pd/server/rbac"
 	syncer "github.com/tikv/pd/server/region_syncer"
+	"github.com/tikv/pd/server/replay"
 	"github.com/tikv/pd/server/schedule"
 	"github.com/tikv/pd/server/schedule/hbstream"
 	"github.com/tikv/pd/server/schedule/placement"
+	"github.com/tikv/pd/server/statsexporter"
 	"github.com/tikv/pd/server/tso"
 	"github.com/tikv/pd/server/versioninfo"
 	"github.com/urfave/negroni"
@@ -89,6 +99,8 @@ type Server struct {
 
 	// Server state.
 	isServing int64
+	// standalone is true if the server runs without etcd.
+	standalone bool
 
 	// Server start timestamp
 	startTimestamp int64
@@ -113,6 +125,13 @@ type Server struct {
 	clusterID  uint64 // pd cluster id.
 	rootPath   string
 
+	// certReloader keeps the certificates of the clients up to date with the
+	// files. It is nil if TLS is not enabled. The certificates of the
+	// listeners are loaded by etcd on each handshake.
+	certReloader *grpcutil.CertReloader
+	// readAPIServer serves the read-only API on the separate listener.
+	readAPIServer *http.Server
+
 	// Server services.
 	// for id allocator, we can use one allocator for
 	// store, region and peer, because we just need
@@ -130,6 +149,24 @@ type Server struct {
 	cluster *cluster.RaftCluster
 	// For async region heartbeat.
 	hbStreams *hbstream.HeartbeatStreams
+	// for the degraded mode under memory pressure.
+	degradationController *degradation.Controller
+	// for the key heatmap.
+	heatmapAggregator *heatmap.Aggregator
+	// for exporting the region statistics, nil if it is disabled.
+	statsExporter *statsexporter.Exporter
+	// for exporting the meta snapshots, nil if it is disabled.
+	metaSnapshotter *metasnapshot.Snapshotter
+	// for capturing the diagnosis bundles.
+	diagnosisCapturer *diagnosis.Capturer
+	// for recording the heartbeats to replay.
+	heartbeatRecorder *replay.Recorder
+	// serializes the updates of the safe mode.
+	safeModeMu sync.Mutex
+	// for the role based access control.
+	rbacManager *rbac.Manager
+	// for the follower gateway.
+	gatewayConfig gatewayConfigCache
 	// Zap logger
 	lg       *zap.Logger
 	logProps *log.ZapProperties
@@ -140,6 +177,8 @@ type Server struct {
 
 	// serviceSafePointLock is a lock for UpdateServiceGCSafePoint
 	serviceSafePointLock sync.Mutex
+	// standaloneBootstrapMu serializes the bootstraps of the standalone server.
+	standaloneBootstrapMu sync.Mutex
 
 	// Store as map[string]*grpc.ClientConn
 	clientConns sync.Map
@@ -219,19 +258,7 @@ func combineBuilderServerHTTPService(ctx context.Context, svr *Server, serviceBu
 
 // CreateServer creates the UNINITIALIZED pd server with given configuration.
 func CreateServer(ctx context.Context, cfg *config.Config, serviceBuilders ...HandlerBuilder) (*Server, error) {
-	log.Info("PD Config", zap.Reflect("config", cfg))
-	rand.Seed(time.Now().UnixNano())
-
-	s := &Server{
-		cfg:               cfg,
-		persistOptions:    config.NewPersistOptions(cfg),
-		member:            &member.Member{},
-		ctx:               ctx,
-		startTimestamp:    time.Now().Unix(),
-		DiagnosticsServer: sysutil.NewDiagnosticsServer(cfg.Log.File.Filename),
-	}
-
-	s.handler = newHandler(s)
+	s := newServer(ctx, cfg)
 
 	// Adjust etcd config.
 	etcdCfg, err := s.cfg.GenEmbedEtcdConfig()
@@ -248,6 +275,10 @@ func CreateServer(ctx context.Context, cfg *config.Config, serviceBuilders ...Ha
 	etcdCfg.ServiceRegister = func(gs *grpc.Server) {
 		pdpb.RegisterPDServer(gs, s)
 		diagnosticspb.RegisterDiagnosticsServer(gs, s)
+		events.RegisterWatchService(gs, events.NewWatchService(s.getEventBus, func(ctx context.Context) error {
+			return s.checkRole(ctx, rbac.RoleViewer)
+		}))
+		regionpb.RegisterRegionsServer(gs, &regionService{s: s})
 	}
 	s.etcdCfg = etcdCfg
 	if EnableZap {
@@ -258,9 +289,39 @@ func CreateServer(ctx context.Context, cfg *config.Config, serviceBuilders ...Ha
 		s.etcdCfg.Logger = "zap"
 		s.etcdCfg.LogOutputs = []string{"stdout"}
 	}
+	return s, nil
+}
+
+// newServer creates a server with the components which don't depend on etcd.
+func newServer(ctx context.Context, cfg *config.Config) *Server {
+	log.Info("PD Config", zap.Reflect("config", cfg))
+	rand.Seed(time.Now().UnixNano())
+
+	s := &Server{
+		cfg:               cfg,
+		persistOptions:    config.NewPersistOptions(cfg),
+		member:            &member.Member{},
+		ctx:               ctx,
+		startTimestamp:    time.Now().Unix(),
+		DiagnosticsServer: sysutil.NewDiagnosticsServer(cfg.Log.File.Filename),
+	}
+
+	s.handler = newHandler(s)
+	s.degradationController = degradation.NewController(s.persistOptions.GetPDServerConfig)
+	s.diagnosisCapturer = diagnosis.NewCapturer(filepath.Join(cfg.DataDir, "diagnosis"), s.persistOptions.GetPDServerConfig,
+		func() interface{} { return s.GetConfig() }, s.getQueueDepths)
+	s.degradationController.SetDegradedCallback(func() {
+		s.diagnosisCapturer.Trigger("memory usage crosses the degraded threshold")
+		if ttl := s.persistOptions.GetPDServerConfig().ColdCacheTTL.Duration; ttl > 0 {
+			if rc := s.GetRaftCluster(); rc != nil {
+				rc.EvictColdCaches(ttl)
+			}
+		}
+	})
+	s.heartbeatRecorder = replay.NewRecorder(filepath.Join(cfg.DataDir, "heartbeat-records"))
 	s.lg = cfg.GetZapLogger()
 	s.logProps = cfg.GetZapLogProperties()
-	return s, nil
+	return s
 }
 
 func (s *Server) startEtcd(ctx context.Context) error {
@@ -277,10 +338,11 @@ func (s *Server) startEtcd(ctx context.Context) error {
 	if err != nil {
 		return errs.ErrEtcdURLMap.Wrap(err).GenWithStackByCause()
 	}
-	tlsConfig, err := s.cfg.Security.ToTLSConfig()
+	s.certReloader, err = grpcutil.NewCertReloader(s.cfg.Security.TLSConfig)
 	if err != nil {
 		return err
 	}
+	tlsConfig := s.certReloader.ClientTLSConfig()
 
 	if err = etcdutil.CheckClusterID(etcd.Server.Cluster().ID(), urlMap, tlsConfig); err != nil {
 		return err
@@ -388,9 +450,17 @@ func (s *Server) startServer(ctx context.Context) error {
 		core.WithRegionStorage(regionStorage),
 		core.WithEncryptionKeyManager(encryptionKeyManager),
 	)
+	s.rbacManager = rbac.NewManager(s.storage, s.getMemberCommonName)
 	s.basicCluster = core.NewBasicCluster()
+	s.heatmapAggregator = heatmap.NewAggregator(regionStorage, s.GetBasicCluster)
+	if s.statsExporter, err = statsexporter.NewExporter(&s.cfg.StatsExporter, s.collectRegionStats); err != nil {
+		return err
+	}
+	if s.metaSnapshotter, err = metasnapshot.NewSnapshotter(&s.cfg.MetaSnapshot, s.storage, s.clusterID, s.member.IsLeader); err != nil {
+		return err
+	}
 	s.cluster = cluster.NewRaftCluster(ctx, s.GetClusterRootPath(), s.clusterID, syncer.NewRegionSyncer(s), s.client, s.httpClient)
-	s.hbStreams = hbstream.NewHeartbeatStreams(ctx, s.clusterID, s.cluster)
+	s.hbStreams = hbstream.NewHeartbeatStreams(ctx, s.clusterID, s.cluster, s.cfg.HeartbeatStreamKeepAliveInterval.Duration)
 
 	// Run callbacks
 	for _, cb := range s.startCallbacks {
@@ -433,6 +503,11 @@ func (s *Server) Close() {
 	log.Info("closing server")
 
 	s.stopServerLoop()
+	s.stopReadAPIServer()
+	if s.isStandalone() {
+		// There is no leader loop to stop the raft cluster.
+		s.stopRaftCluster()
+	}
 
 	if s.client != nil {
 		if err := s.client.Close(); err != nil {
@@ -454,6 +529,9 @@ func (s *Server) Close() {
 	if err := s.storage.Close(); err != nil {
 		log.Error("close storage meet error", errs.ZapError(err))
 	}
+	if _, err := s.heartbeatRecorder.Stop(); err != nil && !errs.ErrHeartbeatNotRecording.Equal(err) {
+		log.Error("stop recording the heartbeats meet error", errs.ZapError(err))
+	}
 
 	// Run callbacks
 	for _, cb := range s.closeCallbacks {
@@ -480,6 +558,9 @@ func (s *Server) Run() error {
 	if err := s.startServer(s.ctx); err != nil {
 		return err
 	}
+	if err := s.startReadAPIServer(); err != nil {
+		return err
+	}
 
 	s.startServerLoop(s.ctx)
 
@@ -498,12 +579,18 @@ func (s *Server) LoopContext() context.Context {
 
 func (s *Server) startServerLoop(ctx context.Context) {
 	s.serverLoopCtx, s.serverLoopCancel = context.WithCancel(ctx)
-	s.serverLoopWg.Add(5)
+	s.serverLoopWg.Add(11)
 	go s.leaderLoop()
 	go s.etcdLeaderLoop()
 	go s.serverMetricsLoop()
 	go s.tsoAllocatorLoop()
 	go s.encryptionKeyManagerLoop()
+	go s.degradationLoop()
+	go s.heatmapLoop()
+	go s.certReloadLoop()
+	go s.statsExportLoop()
+	go s.metaSnapshotLoop()
+	go s.gatewayConfigLoop()
 }
 
 func (s *Server) stopServerLoop() {
@@ -550,6 +637,67 @@ func (s *Server) encryptionKeyManagerLoop() {
 	log.Info("server is closed, exist encryption key manager loop")
 }
 
+// degradationLoop is used to switch the degraded mode under memory pressure.
+func (s *Server) degradationLoop() {
+	defer logutil.LogPanic()
+	defer s.serverLoopWg.Done()
+
+	s.degradationController.Run(s.serverLoopCtx)
+	log.Info("server is closed, exit degradation loop")
+}
+
+// heatmapLoop is used to roll up the key heatmap periodically.
+func (s *Server) heatmapLoop() {
+	defer logutil.LogPanic()
+	defer s.serverLoopWg.Done()
+
+	s.heatmapAggregator.Run(s.serverLoopCtx)
+	log.Info("server is closed, exit heatmap loop")
+}
+
+// certReloadLoop is used to reload the certificates once the files change.
+func (s *Server) certReloadLoop() {
+	defer logutil.LogPanic()
+	defer s.serverLoopWg.Done()
+
+	if s.certReloader != nil {
+		s.certReloader.Run(s.serverLoopCtx, grpcutil.DefaultCertReloadInterval)
+	}
+	log.Info("server is closed, exit cert reload loop")
+}
+
+// statsExportLoop is used to export the region statistics periodically.
+func (s *Server) statsExportLoop() {
+	defer logutil.LogPanic()
+	defer s.serverLoopWg.Done()
+
+	if s.statsExporter != nil {
+		s.statsExporter.Run(s.serverLoopCtx)
+	}
+	log.Info("server is closed, exit stats export loop")
+}
+
+// metaSnapshotLoop is used to export the meta snapshots periodically.
+func (s *Server) metaSnapshotLoop() {
+	defer logutil.LogPanic()
+	defer s.serverLoopWg.Done()
+
+	if s.metaSnapshotter != nil {
+		s.metaSnapshotter.Run(s.serverLoopCtx)
+	}
+	log.Info("server is closed, exit meta snapshot loop")
+}
+
+// collectRegionStats returns the snapshot of the region statistics to export,
+// or nil if the server is not the leader.
+func (s *Server) collectRegionStats() interface{} {
+	rc := s.GetRaftCluster()
+	if rc == nil {
+		return nil
+	}
+	return rc.GetRegionStatsSnapshot()
+}
+
 func (s *Server) collectEtcdStateMetrics() {
 	etcdStateGauge.WithLabelValues("term").Set(float64(s.member.Etcd().Server.Term()))
 	etcdStateGauge.WithLabelValues("appliedIndex").Set(float64(s.member.Etcd().Server.AppliedIndex()))
@@ -563,7 +711,10 @@ func (s *Server) bootstrapCluster(req *pdpb.BootstrapRequest) (*pdpb.BootstrapRe
 		zap.Uint64("cluster-id", clusterID),
 		zap.String("request", fmt.Sprintf("%v", req)))
 
-	if err := checkBootstrapRequest(clusterID, req); err != nil {
+	if err := cluster.CheckBootstrapRequest(clusterID, req); err != nil {
+		return nil, err
+	}
+	if err := s.checkBootstrapData(); err != nil {
 		return nil, err
 	}
 
@@ -609,13 +760,19 @@ func (s *Server) bootstrapCluster(req *pdpb.BootstrapRequest) (*pdpb.BootstrapRe
 
 	// TODO: we must figure out a better way to handle bootstrap failed, maybe intervene manually.
 	bootstrapCmp := clientv3.Compare(clientv3.CreateRevision(clusterRootPath), "=", 0)
-	resp, err := kv.NewSlowLogTxn(s.client).If(bootstrapCmp).Then(ops...).Commit()
-	if err != nil {
-		return nil, errs.ErrEtcdTxnInternal.Wrap(err).GenWithStackByCause()
-	}
-	if !resp.Succeeded {
-		log.Warn("cluster already bootstrapped", zap.Uint64("cluster-id", clusterID))
-		return nil, errs.ErrEtcdTxnConflict.FastGenByArgs()
+	if s.isStandalone() {
+		if err := s.commitStandaloneBootstrap(ops); err != nil {
+			return nil, err
+		}
+	} else {
+		resp, err := kv.NewSlowLogTxn(s.client).If(bootstrapCmp).Then(ops...).Commit()
+		if err != nil {
+			return nil, errs.ErrEtcdTxnInternal.Wrap(err).GenWithStackByCause()
+		}
+		if !resp.Succeeded {
+			log.Warn("cluster already bootstrapped", zap.Uint64("cluster-id", clusterID))
+			return nil, errs.ErrEtcdTxnConflict.FastGenByArgs()
+		}
 	}
 
 	log.Info("bootstrap cluster ok", zap.Uint64("cluster-id", clusterID))
@@ -637,6 +794,27 @@ func (s *Server) bootstrapCluster(req *pdpb.BootstrapRequest) (*pdpb.BootstrapRe
 	}, nil
 }
 
+// checkBootstrapData refuses to bootstrap the cluster if there are stores
+// without the cluster meta, which means the metadata is restored partially or
+// from another cluster.
+func (s *Server) checkBootstrapData() error {
+	if s.cfg.AllowClusterMismatch {
+		return nil
+	}
+	if ok, err := s.storage.LoadMeta(&metapb.Cluster{}); err != nil || ok {
+		// The bootstrap fails later if the cluster meta exists.
+		return err
+	}
+	var count int
+	if err := s.storage.LoadStores(func(*core.StoreInfo) { count++ }); err != nil {
+		return err
+	}
+	if count > 0 {
+		return errs.ErrClusterDataMismatch.FastGenByArgs(s.clusterID, fmt.Sprintf("%d stores exist before bootstrap", count))
+	}
+	return nil
+}
+
 func (s *Server) createRaftCluster() error {
 	if s.cluster.IsRunning() {
 		return nil
@@ -724,6 +902,40 @@ func (s *Server) GetHBStreams() *hbstream.HeartbeatStreams {
 	return s.hbStreams
 }
 
+// GetDegradationController returns the controller of the degraded mode.
+func (s *Server) GetDegradationController() *degradation.Controller {
+	return s.degradationController
+}
+
+// GetDiagnosisCapturer returns the capturer of the diagnosis bundles.
+func (s *Server) GetDiagnosisCapturer() *diagnosis.Capturer {
+	return s.diagnosisCapturer
+}
+
+// GetHeartbeatRecorder returns the recorder of the heartbeats.
+func (s *Server) GetHeartbeatRecorder() *replay.Recorder {
+	return s.heartbeatRecorder
+}
+
+// getQueueDepths returns the queue depths of the runners for the diagnosis.
+func (s *Server) getQueueDepths() map[string]int {
+	depths := make(map[string]int)
+	if s.hbStreams != nil {
+		depths["heartbeat-stream-messages"] = s.hbStreams.MsgLength()
+	}
+	if rc := s.GetRaftCluster(); rc != nil {
+		oc := rc.GetOperatorController()
+		depths["running-operators"] = len(oc.GetOperators())
+		depths["waiting-operators"] = len(oc.GetWaitingOperators())
+	}
+	return depths
+}
+
+// GetHeatmapAggregator returns the aggregator of the key heatmap.
+func (s *Server) GetHeatmapAggregator() *heatmap.Aggregator {
+	return s.heatmapAggregator
+}
+
 // GetAllocator returns the ID allocator of server.
 func (s *Server) GetAllocator() id.Allocator {
 	return s.idAllocator
@@ -749,6 +961,11 @@ func (s *Server) StartTimestamp() int64 {
 	return s.startTimestamp
 }
 
+// GetEffectiveConfig gets the effective configs along with their sources.
+func (s *Server) GetEffectiveConfig() (map[string]*config.EffectiveItem, error) {
+	return s.persistOptions.ExplainEffective(s.cfg)
+}
+
 // GetConfig gets the config information.
 func (s *Server) GetConfig() *config.Config {
 	cfg := s.cfg.Clone()
@@ -1024,6 +1241,13 @@ func (s *Server) GetTLSConfig() *grpcutil.TLSConfig {
 	return &s.cfg.Security.TLSConfig
 }
 
+// GetClientTLSConfig returns the TLS config to connect to the other
+// components, which always uses the latest certificates. It returns nil if TLS
+// is not enabled.
+func (s *Server) GetClientTLSConfig() *tls.Config {
+	return s.certReloader.ClientTLSConfig()
+}
+
 // GetServerRootPath returns the server root path.
 func (s *Server) GetServerRootPath() string {
 	return s.rootPath
@@ -1043,6 +1267,13 @@ func (s *Server) GetRaftCluster() *cluster.RaftCluster {
 	return s.cluster
 }
 
+func (s *Server) getEventBus() *events.Bus {
+	if rc := s.GetRaftCluster(); rc != nil {
+		return rc.GetEventBus()
+	}
+	return nil
+}
+
 // GetCluster gets cluster.
 func (s *Server) GetCluster() *metapb.Cluster {
 	return &metapb.Cluster{
@@ -1108,8 +1339,8 @@ func (s *Server) GetReplicationModeConfig() *config.ReplicationModeConfig {
 
 // SetReplicationModeConfig sets the replication mode.
 func (s *Server) SetReplicationModeConfig(cfg config.ReplicationModeConfig) error {
-	if config.NormalizeReplicationMode(cfg.ReplicationMode) == "" {
-		return errors.Errorf("invalid replication mode: %v", cfg.ReplicationMode)
+	if err := cfg.Validate(); err != nil {
+		return err
 	}
 
 	old := s.persistOptions.GetReplicationModeConfig()
@@ -1331,6 +1562,10 @@ func (s *Server) reloadConfigFromKV() error {
 // ReplicateFileToAllMembers is used to synchronize state among all members.
 // Each member will write `data` to a local file named `name`.
 func (s *Server) ReplicateFileToAllMembers(ctx context.Context, name string, data []byte) error {
+	if s.isStandalone() {
+		// The only member persists nothing.
+		return nil
+	}
 	resp, err := s.GetMembers(ctx, nil)
 	if err != nil {
 		return err
@@ -21,7 +21,11 @@ import (
 	"testing"
 
 	. "github.com/pingcap/check"
+	"github.com/pingcap/kvproto/pkg/metapb"
+	"github.com/pingcap/kvproto/pkg/pdpb"
+	"github.com/tikv/pd/pkg/errs"
 	"github.com/tikv/pd/pkg/etcdutil"
+	"github.com/tikv/pd/pkg/tempurl"
 	"github.com/tikv/pd/pkg/testutil"
 	"github.com/tikv/pd/server/config"
 	"go.etcd.io/etcd/embed"
@@ -185,6 +189,30 @@ func (s *testServerSuite) TestCheckClusterID(c *C) {
 	testutil.CleanServer(cfgA.DataDir)
 }
 
+func (s *testServerSuite) TestCheckBootstrapData(c *C) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	svrs, cleanup := newTestServersWithCfgs(ctx, c, NewTestMultiConfig(c, 1))
+	defer cleanup()
+	svr := svrs[0]
+
+	// The store is left without the cluster meta.
+	c.Assert(svr.storage.SaveStore(&metapb.Store{Id: 5, Address: "tikv5"}), IsNil)
+	req := &pdpb.BootstrapRequest{
+		Header: &pdpb.RequestHeader{ClusterId: svr.ClusterID()},
+		Store:  &metapb.Store{Id: 1, Address: "tikv1"},
+		Region: &metapb.Region{Id: 3, Peers: []*metapb.Peer{{Id: 2, StoreId: 1}}},
+	}
+	_, err := svr.bootstrapCluster(req)
+	c.Assert(errs.ErrClusterDataMismatch.Equal(err), IsTrue)
+	c.Assert(svr.GetRaftCluster(), IsNil)
+
+	svr.cfg.AllowClusterMismatch = true
+	_, err = svr.bootstrapCluster(req)
+	c.Assert(err, IsNil)
+	c.Assert(svr.GetRaftCluster(), NotNil)
+}
+
 var _ = Suite(&testServerHandlerSuite{})
 
 type testServerHandlerSuite struct{}
@@ -226,3 +254,45 @@ func (s *testServerHandlerSuite) TestRegisterServerHandler(c *C) {
 	bodyString := string(bodyBytes)
 	c.Assert(bodyString, Equals, "Hello World\n")
 }
+
+func (s *testServerHandlerSuite) TestReadAPIServer(c *C) {
+	mokHandler := func(ctx context.Context, s *Server) (http.Handler, ServiceGroup, error) {
+		mux := http.NewServeMux()
+		mux.HandleFunc("/pd/apis/mok/v1/hello", func(w http.ResponseWriter, r *http.Request) {
+			fmt.Fprintln(w, "Hello World")
+		})
+		return mux, ServiceGroup{Name: "mok", Version: "v1"}, nil
+	}
+	cfg := NewTestSingleConfig(c)
+	cfg.ReadAPI.ListenURL = tempurl.Alloc()
+	cfg.ReadAPI.RateLimit = 1
+	ctx, cancel := context.WithCancel(context.Background())
+	svr, err := CreateServer(ctx, cfg, mokHandler)
+	c.Assert(err, IsNil)
+	defer func() {
+		cancel()
+		svr.Close()
+		testutil.CleanServer(svr.cfg.DataDir)
+	}()
+	c.Assert(svr.Run(), IsNil)
+	addr := fmt.Sprintf("%s/pd/apis/mok/v1/hello", cfg.ReadAPI.ListenURL)
+
+	// Only the read-only requests are served.
+	resp, err := http.Post(addr, "", nil)
+	c.Assert(err, IsNil)
+	resp.Body.Close()
+	c.Assert(resp.StatusCode, Equals, http.StatusMethodNotAllowed)
+	resp, err = http.Get(addr)
+	c.Assert(err, IsNil)
+	bodyBytes, err := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	c.Assert(err, IsNil)
+	c.Assert(resp.StatusCode, Equals, http.StatusOK)
+	c.Assert(string(bodyBytes), Equals, "Hello World\n")
+
+	// The requests beyond the rate limit are rejected.
+	resp, err = http.Get(addr)
+	c.Assert(err, IsNil)
+	resp.Body.Close()
+	c.Assert(resp.StatusCode, Equals, http.StatusTooManyRequests)
+}
@@ -0,0 +1,113 @@
+// Copyright 2021 TiKV Project Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package server
+
+import (
+	"context"
+	"math/rand"
+	"net/http"
+	"path"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/pingcap/kvproto/pkg/metapb"
+	"github.com/pingcap/kvproto/pkg/pdpb"
+	"github.com/pingcap/log"
+	"github.com/tikv/pd/pkg/errs"
+	"github.com/tikv/pd/server/cluster"
+	"github.com/tikv/pd/server/config"
+	"github.com/tikv/pd/server/core"
+	"github.com/tikv/pd/server/id"
+	"github.com/tikv/pd/server/kv"
+	"github.com/tikv/pd/server/member"
+	"github.com/tikv/pd/server/rbac"
+	"github.com/tikv/pd/server/schedule/hbstream"
+	"github.com/tikv/pd/server/tso"
+	"go.etcd.io/etcd/clientv3"
+	"go.uber.org/zap"
+)
+
+const standaloneClientTimeout = 3 * time.Second
+
+// CreateStandaloneServer creates and starts a PD server which runs without
+// etcd. It is the only member and always the leader, keeps the metadata in an
+// in-memory kv, and allocates the IDs and the timestamps in memory, so it
+// serves the same gRPC handlers as a PD cluster but persists nothing. The
+// caller serves the gRPC services on its own listener.
+func CreateStandaloneServer(ctx context.Context, cfg *config.Config) *Server {
+	s := newServer(ctx, cfg)
+	s.standalone = true
+	s.clusterID = (uint64(time.Now().Unix()) << 32) + uint64(rand.Uint32())
+	s.rootPath = path.Join(pdRootPath, strconv.FormatUint(s.clusterID, 10))
+	s.member = member.NewStandaloneMember(s.cfg, uint64(rand.Int63())+1, s.rootPath)
+	s.httpClient = &http.Client{Timeout: standaloneClientTimeout}
+	s.idAllocator = id.NewMemoryAllocator()
+	s.tsoAllocatorManager = tso.NewAllocatorManager(
+		s.member, s.rootPath, s.cfg,
+		func() time.Duration { return s.persistOptions.GetMaxResetTSGap() })
+	s.tsoAllocatorManager.SetUpMemoryAllocator(ctx, s.member.GetLeadership())
+	s.storage = core.NewStorage(kv.NewMemoryKV())
+	s.rbacManager = rbac.NewManager(s.storage, s.getMemberCommonName)
+	s.basicCluster = core.NewBasicCluster()
+	s.cluster = cluster.NewRaftCluster(ctx, s.GetClusterRootPath(), s.clusterID, nil, nil, s.httpClient)
+	s.hbStreams = hbstream.NewHeartbeatStreams(ctx, s.clusterID, s.cluster, s.cfg.HeartbeatStreamKeepAliveInterval.Duration)
+
+	// Only the degradation loop is needed, the others work with etcd or the
+	// data directory.
+	s.serverLoopCtx, s.serverLoopCancel = context.WithCancel(ctx)
+	s.serverLoopWg.Add(1)
+	go s.degradationLoop()
+
+	atomic.StoreInt64(&s.isServing, 1)
+	log.Info("standalone server is started", zap.Uint64("cluster-id", s.clusterID), zap.String("addr", s.cfg.AdvertiseClientUrls))
+	return s
+}
+
+// isStandalone returns whether the server runs without etcd, see
+// CreateStandaloneServer.
+func (s *Server) isStandalone() bool {
+	return s.standalone
+}
+
+// commitStandaloneBootstrap commits the bootstrap ops into the in-memory kv,
+// whose keys are relative to the root path. Like the etcd transaction, it
+// fails if the cluster meta exists.
+func (s *Server) commitStandaloneBootstrap(ops []clientv3.Op) error {
+	s.standaloneBootstrapMu.Lock()
+	defer s.standaloneBootstrapMu.Unlock()
+	if ok, err := s.storage.LoadMeta(&metapb.Cluster{}); err != nil {
+		return err
+	} else if ok {
+		log.Warn("cluster already bootstrapped", zap.Uint64("cluster-id", s.clusterID))
+		return errs.ErrEtcdTxnConflict.FastGenByArgs()
+	}
+	for _, op := range ops {
+		key := strings.TrimPrefix(string(op.KeyBytes()), s.rootPath+"/")
+		if err := s.storage.Save(key, string(op.ValueBytes())); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// listMembers lists the members in the etcd cluster. The standalone server is
+// the only member.
+func (s *Server) listMembers() ([]*pdpb.Member, error) {
+	if s.isStandalone() {
+		return []*pdpb.Member{s.member.Member()}, nil
+	}
+	return cluster.GetMembers(s.client)
+}
@@ -0,0 +1,173 @@
+// Copyright 2021 TiKV Project Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package statistics
+
+import (
+	"sort"
+	"sync"
+	"time"
+)
+
+const (
+	// capacitySampleInterval is the min interval between the samples of the
+	// available capacity of a store.
+	capacitySampleInterval = 10 * time.Minute
+	// capacityForecastWindow is how long the samples are used to forecast.
+	capacityForecastWindow = 3 * 24 * time.Hour
+	// minCapacitySamples is the min number of the samples to forecast, so the
+	// short bursts of the writes are not extrapolated.
+	minCapacitySamples = 6
+)
+
+// The levels of the capacity forecasts.
+const (
+	CapacityLevelNormal   = "normal"
+	CapacityLevelWarning  = "warning"
+	CapacityLevelCritical = "critical"
+)
+
+type capacitySample struct {
+	time      time.Time
+	available float64
+}
+
+// CapacityForecast is the forecast of when a store is full.
+type CapacityForecast struct {
+	StoreID   uint64 `json:"store_id"`
+	Samples   int    `json:"samples"`
+	Capacity  uint64 `json:"capacity"`
+	Available uint64 `json:"available"`
+	// GrowthPerDay is the bytes consumed per day. It is negative if the
+	// available capacity is increasing.
+	GrowthPerDay float64 `json:"growth_bytes_per_day"`
+	// DaysToFull is the predicted days before the store is full. It is 0 if
+	// the store is not filling or there are not enough samples.
+	DaysToFull float64 `json:"days_to_full"`
+	Level      string  `json:"level,omitempty"`
+}
+
+// GetLevel returns the level of the forecast with the thresholds in days. A
+// zero threshold is never reached.
+func (f *CapacityForecast) GetLevel(warningDays, criticalDays float64) string {
+	switch {
+	case f.DaysToFull <= 0:
+		return CapacityLevelNormal
+	case f.DaysToFull < criticalDays:
+		return CapacityLevelCritical
+	case f.DaysToFull < warningDays:
+		return CapacityLevelWarning
+	default:
+		return CapacityLevelNormal
+	}
+}
+
+// CapacityForecaster extrapolates the trends of the available capacity of the
+// stores from their heartbeats to predict when they are full.
+type CapacityForecaster struct {
+	sync.RWMutex
+	samples   map[uint64][]capacitySample
+	forecasts map[uint64]*CapacityForecast
+}
+
+// NewCapacityForecaster creates a CapacityForecaster.
+func NewCapacityForecaster() *CapacityForecaster {
+	return &CapacityForecaster{
+		samples:   make(map[uint64][]capacitySample),
+		forecasts: make(map[uint64]*CapacityForecast),
+	}
+}
+
+// Observe records the capacity of the store reported at the time, and returns
+// the updated forecast of the store.
+func (f *CapacityForecaster) Observe(storeID uint64, now time.Time, capacity, available uint64) *CapacityForecast {
+	f.Lock()
+	defer f.Unlock()
+	samples := f.samples[storeID]
+	if n := len(samples); n == 0 || now.Sub(samples[n-1].time) >= capacitySampleInterval {
+		samples = append(samples, capacitySample{time: now, available: float64(available)})
+	}
+	expired := 0
+	for expired < len(samples) && now.Sub(samples[expired].time) > capacityForecastWindow {
+		expired++
+	}
+	if expired > 0 {
+		samples = append(samples[:0:0], samples[expired:]...)
+	}
+	f.samples[storeID] = samples
+
+	forecast := &CapacityForecast{
+		StoreID:   storeID,
+		Samples:   len(samples),
+		Capacity:  capacity,
+		Available: available,
+	}
+	if len(samples) >= minCapacitySamples {
+		forecast.GrowthPerDay = -availableSlope(samples) * float64(24*time.Hour/time.Second)
+		if forecast.GrowthPerDay > 0 {
+			forecast.DaysToFull = float64(available) / forecast.GrowthPerDay
+		}
+	}
+	f.forecasts[storeID] = forecast
+	return forecast
+}
+
+// availableSlope returns the slope of the available capacity in bytes per
+// second by the least squares.
+func availableSlope(samples []capacitySample) float64 {
+	var sumX, sumY float64
+	for _, s := range samples {
+		sumX += s.time.Sub(samples[0].time).Seconds()
+		sumY += s.available
+	}
+	n := float64(len(samples))
+	meanX, meanY := sumX/n, sumY/n
+	var cov, variance float64
+	for _, s := range samples {
+		dx := s.time.Sub(samples[0].time).Seconds() - meanX
+		cov += dx * (s.available - meanY)
+		variance += dx * dx
+	}
+	if variance == 0 {
+		return 0
+	}
+	return cov / variance
+}
+
+// GetForecast returns the latest forecast of the store, or nil if the store
+// has not been observed.
+func (f *CapacityForecaster) GetForecast(storeID uint64) *CapacityForecast {
+	f.RLock()
+	defer f.RUnlock()
+	return f.forecasts[storeID]
+}
+
+// GetForecasts returns the latest forecasts of all stores ordered by the IDs.
+func (f *CapacityForecaster) GetForecasts() []*CapacityForecast {
+	f.RLock()
+	defer f.RUnlock()
+	forecasts := make([]*CapacityForecast, 0, len(f.forecasts))
+	for _, forecast := range f.forecasts {
+		forecasts = append(forecasts, forecast)
+	}
+	sort.Slice(forecasts, func(i, j int) bool { return forecasts[i].StoreID < forecasts[j].StoreID })
+	return forecasts
+}
+
+// RemoveStore removes the samples of the store.
+func (f *CapacityForecaster) RemoveStore(storeID uint64) {
+	f.Lock()
+	defer f.Unlock()
+	delete(f.samples, storeID)
+	delete(f.forecasts, storeID)
+}
@@ -0,0 +1,66 @@
+// Copyright 2021 TiKV Project Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package statistics
+
+import (
+	"time"
+
+	. "github.com/pingcap/check"
+)
+
+var _ = Suite(&testCapacityForecastSuite{})
+
+type testCapacityForecastSuite struct{}
+
+func (t *testCapacityForecastSuite) TestForecast(c *C) {
+	const gb = uint64(1 << 30)
+	f := NewCapacityForecaster()
+	start := time.Now()
+
+	// Store 1 consumes 1GB per hour with 240GB available at first, and store 2
+	// does not grow.
+	var forecast *CapacityForecast
+	for i := 0; i < minCapacitySamples; i++ {
+		now := start.Add(time.Duration(i) * time.Hour)
+		forecast = f.Observe(1, now, 1000*gb, 240*gb-uint64(i)*gb)
+		c.Assert(forecast.Samples, Equals, i+1)
+		if i < minCapacitySamples-1 {
+			c.Assert(forecast.DaysToFull, Equals, 0.0)
+		}
+		f.Observe(2, now, 1000*gb, 240*gb)
+		// The heartbeats within the sample interval are not sampled.
+		c.Assert(f.Observe(2, now.Add(time.Minute), 1000*gb, 240*gb).Samples, Equals, i+1)
+	}
+	c.Assert(forecast.GrowthPerDay, Equals, float64(24*gb))
+	c.Assert(forecast.DaysToFull, Equals, float64(235)/24)
+	c.Assert(forecast.GetLevel(30, 7), Equals, CapacityLevelWarning)
+	c.Assert(forecast.GetLevel(30, 10), Equals, CapacityLevelCritical)
+	c.Assert(forecast.GetLevel(0, 0), Equals, CapacityLevelNormal)
+	c.Assert(f.GetForecast(2).DaysToFull, Equals, 0.0)
+	c.Assert(f.GetForecast(2).GetLevel(30, 7), Equals, CapacityLevelNormal)
+
+	// The samples out of the window are expired, and there are not enough
+	// samples to forecast.
+	forecast = f.Observe(1, start.Add(capacityForecastWindow+2*time.Hour), 1000*gb, 240*gb)
+	c.Assert(forecast.Samples, Equals, minCapacitySamples-1)
+	c.Assert(forecast.GrowthPerDay, Equals, 0.0)
+	c.Assert(forecast.DaysToFull, Equals, 0.0)
+
+	forecasts := f.GetForecasts()
+	c.Assert(forecasts, HasLen, 2)
+	c.Assert(forecasts[0].StoreID, Equals, uint64(1))
+	f.RemoveStore(1)
+	c.Assert(f.GetForecast(1), IsNil)
+	c.Assert(f.GetForecasts(), HasLen, 1)
+}
@@ -36,10 +36,20 @@ func IsSelectedDim(dim int) bool {
 	return dim == ByteDim || dim == KeyDim
 }
 
+// trendSmoothFactor is the weight of the latest rate of change when
+// estimating the trend of a load.
+const trendSmoothFactor = 0.5
+
 type dimStat struct {
 	typ         RegionStatKind
 	Rolling     *movingaverage.TimeMedian  // it's used to statistic hot degree and average speed.
 	LastAverage *movingaverage.AvgOverTime // it's used to obtain the average speed in last second as instantaneous speed.
+
+	// lastInstant is the instantaneous speed of the last full window, and
+	// trend is the smoothed rate of change of the speed per second.
+	lastInstant    float64
+	hasLastInstant bool
+	trend          float64
 }
 
 func newDimStat(typ RegionStatKind, reportInterval time.Duration) *dimStat {
@@ -67,6 +77,17 @@ func (d *dimStat) isFull() bool {
 	return d.LastAverage.IsFull()
 }
 
+// updateTrend estimates the trend with the instantaneous speeds of two
+// adjacent full windows. It should be called before clearing the last average.
+func (d *dimStat) updateTrend(windowSecs float64) {
+	instant := d.LastAverage.Get()
+	if d.hasLastInstant {
+		rate := (instant - d.lastInstant) / windowSecs
+		d.trend = trendSmoothFactor*rate + (1-trendSmoothFactor)*d.trend
+	}
+	d.lastInstant, d.hasLastInstant = instant, true
+}
+
 func (d *dimStat) clearLastAverage() {
 	d.LastAverage.Clear()
 }
@@ -87,6 +108,9 @@ type HotPeerStat struct {
 
 	Kind  FlowKind  `json:"-"`
 	Loads []float64 `json:"loads"`
+	// Trends is the estimated rate of change of the loads per second, which
+	// is only set for the cloned stats.
+	Trends []float64 `json:"trends,omitempty"`
 
 	// rolling statistics, recording some recently added records.
 	rollingLoads []*dimStat
@@ -174,6 +198,20 @@ func (stat *HotPeerStat) GetLoads() []float64 {
 	return loads
 }
 
+// GetTrend returns the estimated rate of change of the load per second. A
+// positive trend means the load is rising.
+func (stat *HotPeerStat) GetTrend(k RegionStatKind) float64 {
+	for _, d := range stat.rollingLoads {
+		if d.typ == k {
+			return d.trend
+		}
+	}
+	if len(stat.Trends) > int(k) {
+		return stat.Trends[int(k)]
+	}
+	return 0
+}
+
 // GetThresholds returns thresholds
 func (stat *HotPeerStat) GetThresholds() []float64 {
 	return stat.thresholds
@@ -183,8 +221,10 @@ func (stat *HotPeerStat) GetThresholds() []float64 {
 func (stat *HotPeerStat) Clone() *HotPeerStat {
 	ret := *stat
 	ret.Loads = make([]float64, RegionStatCount)
+	ret.Trends = make([]float64, RegionStatCount)
 	for i := RegionStatKind(0); i < RegionStatCount; i++ {
 		ret.Loads[i] = stat.GetLoad(i) // replace with denoised loads
+		ret.Trends[i] = stat.GetTrend(i)
 	}
 	ret.rollingLoads = nil
 	return &ret
@@ -196,6 +236,13 @@ func (stat *HotPeerStat) isFullAndHot() bool {
 	})
 }
 
+func (stat *HotPeerStat) updateTrends() {
+	windowSecs := float64(stat.hotStatReportInterval())
+	for _, l := range stat.rollingLoads {
+		l.updateTrend(windowSecs)
+	}
+}
+
 func (stat *HotPeerStat) clearLastAverage() {
 	for _, l := range stat.rollingLoads {
 		l.clearLastAverage()
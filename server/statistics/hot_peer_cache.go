@@ -422,6 +422,7 @@ func (f *hotPeerCache) updateHotPeerStat(newItem, oldItem *HotPeerStat, deltaLoa
 				}
 			}
 		}
+		newItem.updateTrends()
 		newItem.clearLastAverage()
 	}
 	return newItem
@@ -450,6 +451,7 @@ func (f *hotPeerCache) updateNewHotPeerStat(newItem *HotPeerStat, deltaLoads []f
 		ds := newDimStat(k, time.Duration(newItem.hotStatReportInterval())*time.Second)
 		ds.Add(deltaLoads[k], interval)
 		if ds.isFull() {
+			ds.updateTrend(float64(newItem.hotStatReportInterval()))
 			ds.clearLastAverage()
 		}
 		newItem.rollingLoads[i] = ds
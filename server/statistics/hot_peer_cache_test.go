@@ -278,6 +278,31 @@ func (t *testHotPeerCache) TestUpdateHotPeerStat(c *C) {
 	c.Check(newItem.needDelete, Equals, true)
 }
 
+func (t *testHotPeerCache) TestTrend(c *C) {
+	cache := NewHotStoresStats(ReadFlow)
+	newPeerStat := func() *HotPeerStat {
+		return &HotPeerStat{thresholds: []float64{0.0, 0.0, 0.0}, Loads: make([]float64, RegionStatCount), Kind: ReadFlow}
+	}
+	newItem := newPeerStat()
+	// The rate of the bytes rises from 100 to 300 per second, and the keys stay.
+	newItem = cache.updateHotPeerStat(newItem, nil, []float64{1000.0, 100.0, 0.0}, 10*time.Second)
+	c.Assert(newItem.GetTrend(RegionReadBytes), Equals, 0.0)
+	for _, bytes := range []float64{2000.0, 3000.0} {
+		oldItem := newItem
+		newItem = cache.updateHotPeerStat(newPeerStat(), oldItem, []float64{bytes, 100.0, 0.0}, 10*time.Second)
+	}
+	// The rates of change are 10 per second twice, smoothed from 0.
+	c.Assert(newItem.GetTrend(RegionReadBytes), Equals, 7.5)
+	c.Assert(newItem.GetTrend(RegionReadKeys), Equals, 0.0)
+	clone := newItem.Clone()
+	c.Assert(clone.GetTrend(RegionReadBytes), Equals, 7.5)
+
+	// The trend becomes negative when the rate declines.
+	oldItem := newItem
+	newItem = cache.updateHotPeerStat(newPeerStat(), oldItem, []float64{0.0, 100.0, 0.0}, 10*time.Second)
+	c.Assert(newItem.GetTrend(RegionReadBytes) < 0, IsTrue)
+}
+
 func (t *testHotPeerCache) TestThresholdWithUpdateHotPeerStat(c *C) {
 	byteRate := minHotThresholds[RegionReadBytes] * 2
 	expectThreshold := byteRate * HotThresholdRatio
@@ -40,6 +40,14 @@ var (
 			Help:      "Status of the regions.",
 		}, []string{"type"})
 
+	placementDriftGauge = prometheus.NewGauge(
+		prometheus.GaugeOpts{
+			Namespace: "pd",
+			Subsystem: "regions",
+			Name:      "placement_drift",
+			Help:      "The fraction of the regions not fully fitting the placement rules, weighted by size.",
+		})
+
 	offlineRegionStatusGauge = prometheus.NewGaugeVec(
 		prometheus.GaugeOpts{
 			Namespace: "pd",
@@ -182,6 +190,7 @@ func init() {
 	prometheus.MustRegister(storeStatusGauge)
 	prometheus.MustRegister(regionStatusGauge)
 	prometheus.MustRegister(offlineRegionStatusGauge)
+	prometheus.MustRegister(placementDriftGauge)
 	prometheus.MustRegister(clusterStatusGauge)
 	prometheus.MustRegister(placementStatusGauge)
 	prometheus.MustRegister(configStatusGauge)
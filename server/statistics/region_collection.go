@@ -14,6 +14,7 @@
 package statistics
 
 import (
+	"sync"
 	"time"
 
 	"github.com/pingcap/log"
@@ -34,8 +35,24 @@ const (
 	OfflinePeer
 	LearnerPeer
 	EmptyRegion
+	// The types below classify the regions by how they fit the placement
+	// rules, and are only maintained when the placement rules are enabled.
+	RuleFitFully
+	RuleFitMissPeer
+	RuleFitWrongRole
+	RuleFitOrphanPeer
+	RuleFitIsolationViolated
 )
 
+// RuleFitClasses maps the names of the rule fit classes to the region status types.
+var RuleFitClasses = map[string]RegionStatisticType{
+	"fully-fit":          RuleFitFully,
+	"miss-peer":          RuleFitMissPeer,
+	"wrong-role":         RuleFitWrongRole,
+	"orphan-peer":        RuleFitOrphanPeer,
+	"isolation-violated": RuleFitIsolationViolated,
+}
+
 const nonIsolation = "none"
 
 // RegionInfo is used to record the status of region.
@@ -53,10 +70,29 @@ type RegionStatistics struct {
 	index        map[uint64]RegionStatisticType
 	offlineIndex map[uint64]RegionStatisticType
 	ruleManager  *placement.RuleManager
+	storeSet     placement.StoreSet
+
+	// driftMu protects drift, which is updated by Collect.
+	driftMu sync.RWMutex
+	drift   PlacementDrift
 }
 
-// NewRegionStatistics creates a new RegionStatistics.
-func NewRegionStatistics(opt *config.PersistOptions, ruleManager *placement.RuleManager) *RegionStatistics {
+// PlacementDrift is how far the regions are from fitting the placement rules,
+// so the convergence of the placement can be measured after the changes.
+type PlacementDrift struct {
+	// Drift is the fraction of the regions not fully fitting the placement
+	// rules, weighted by the approximate sizes.
+	Drift              float64   `json:"drift"`
+	DriftedRegionCount int       `json:"drifted_region_count"`
+	DriftedSize        int64     `json:"drifted_size"`
+	TotalRegionCount   int       `json:"total_region_count"`
+	TotalSize          int64     `json:"total_size"`
+	UpdateTime         time.Time `json:"update_time"`
+}
+
+// NewRegionStatistics creates a new RegionStatistics. The stores in storeSet
+// are used to fit the regions to the placement rules.
+func NewRegionStatistics(opt *config.PersistOptions, ruleManager *placement.RuleManager, storeSet placement.StoreSet) *RegionStatistics {
 	r := &RegionStatistics{
 		opt:          opt,
 		stats:        make(map[RegionStatisticType]map[uint64]*RegionInfo),
@@ -70,6 +106,10 @@ func NewRegionStatistics(opt *config.PersistOptions, ruleManager *placement.Rule
 	r.stats[PendingPeer] = make(map[uint64]*RegionInfo)
 	r.stats[LearnerPeer] = make(map[uint64]*RegionInfo)
 	r.stats[EmptyRegion] = make(map[uint64]*RegionInfo)
+	for _, typ := range RuleFitClasses {
+		r.stats[typ] = make(map[uint64]*RegionInfo)
+		r.offlineStats[typ] = make(map[uint64]*core.RegionInfo)
+	}
 
 	r.offlineStats[MissPeer] = make(map[uint64]*core.RegionInfo)
 	r.offlineStats[ExtraPeer] = make(map[uint64]*core.RegionInfo)
@@ -79,6 +119,7 @@ func NewRegionStatistics(opt *config.PersistOptions, ruleManager *placement.Rule
 	r.offlineStats[EmptyRegion] = make(map[uint64]*core.RegionInfo)
 	r.offlineStats[OfflinePeer] = make(map[uint64]*core.RegionInfo)
 	r.ruleManager = ruleManager
+	r.storeSet = storeSet
 	return r
 }
 
@@ -118,6 +159,13 @@ func (r *RegionStatistics) deleteOfflineEntry(deleteIndex RegionStatisticType, r
 
 // Observe records the current regions' status.
 func (r *RegionStatistics) Observe(region *core.RegionInfo, stores []*core.StoreInfo) {
+	r.ObserveWithRuleFit(region, stores, r.ClassifyRuleFit(region))
+}
+
+// ObserveWithRuleFit records the current regions' status with the rule fit
+// classes returned by ClassifyRuleFit, so the costly fit can be done without
+// the lock which protects the statistics.
+func (r *RegionStatistics) ObserveWithRuleFit(region *core.RegionInfo, stores []*core.StoreInfo, ruleFit RegionStatisticType) {
 	// Region state.
 	regionID := region.GetID()
 	var (
@@ -163,6 +211,11 @@ func (r *RegionStatistics) Observe(region *core.RegionInfo, stores []*core.Store
 		LearnerPeer: len(region.GetLearners()) > 0,
 		EmptyRegion: region.GetApproximateSize() <= core.EmptyRegionApproximateSize,
 	}
+	for _, typ := range RuleFitClasses {
+		if ruleFit&typ != 0 {
+			conditions[typ] = true
+		}
+	}
 
 	for typ, c := range conditions {
 		if c {
@@ -172,10 +225,9 @@ func (r *RegionStatistics) Observe(region *core.RegionInfo, stores []*core.Store
 			}
 			info := r.stats[typ][regionID]
 			if info == nil {
-				info = &RegionInfo{
-					RegionInfo: region,
-				}
+				info = &RegionInfo{}
 			}
+			info.RegionInfo = region
 			if typ == DownPeer {
 				if info.startDownPeerTS != 0 {
 					regionDownPeerDuration.Observe(float64(time.Now().Unix() - info.startDownPeerTS))
@@ -213,6 +265,65 @@ func (r *RegionStatistics) Observe(region *core.RegionInfo, stores []*core.Store
 	r.index[regionID] = peerTypeIndex
 }
 
+// ClassifyRuleFit returns the rule fit classes of the region. It does not
+// touch the statistics, so it is safe to be called concurrently.
+func (r *RegionStatistics) ClassifyRuleFit(region *core.RegionInfo) RegionStatisticType {
+	if !r.opt.IsPlacementRulesEnabled() || r.storeSet == nil || !r.ruleManager.IsInitialized() {
+		return 0
+	}
+	var ruleFit RegionStatisticType
+	fit := r.ruleManager.FitRegion(r.storeSet, region)
+	for _, rf := range fit.RuleFits {
+		if len(rf.Peers) < rf.Rule.Count {
+			ruleFit |= RuleFitMissPeer
+		}
+		if len(rf.PeersWithDifferentRole) > 0 {
+			ruleFit |= RuleFitWrongRole
+		}
+		if isIsolationViolated(rf, r.storeSet) {
+			ruleFit |= RuleFitIsolationViolated
+		}
+	}
+	if len(fit.OrphanPeers) > 0 {
+		ruleFit |= RuleFitOrphanPeer
+	}
+	if fit.IsSatisfied() && ruleFit&RuleFitIsolationViolated == 0 {
+		ruleFit |= RuleFitFully
+	}
+	return ruleFit
+}
+
+// isIsolationViolated checks if any two peers of the rule are placed at the
+// same location of the isolation level of the rule.
+func isIsolationViolated(rf *placement.RuleFit, stores placement.StoreSet) bool {
+	level := -1
+	for i, label := range rf.Rule.LocationLabels {
+		if label == rf.Rule.IsolationLevel {
+			level = i
+			break
+		}
+	}
+	if level == -1 {
+		return false
+	}
+	for i, p1 := range rf.Peers {
+		s1 := stores.GetStore(p1.GetStoreId())
+		if s1 == nil {
+			continue
+		}
+		for _, p2 := range rf.Peers[i+1:] {
+			s2 := stores.GetStore(p2.GetStoreId())
+			if s2 == nil {
+				continue
+			}
+			if index := s1.CompareLocation(s2, rf.Rule.LocationLabels); index == -1 || index > level {
+				return true
+			}
+		}
+	}
+	return false
+}
+
 // ClearDefunctRegion is used to handle the overlap region.
 func (r *RegionStatistics) ClearDefunctRegion(regionID uint64) {
 	if oldIndex, ok := r.index[regionID]; ok {
@@ -231,6 +342,9 @@ func (r *RegionStatistics) Collect() {
 	regionStatusGauge.WithLabelValues("pending-peer-region-count").Set(float64(len(r.stats[PendingPeer])))
 	regionStatusGauge.WithLabelValues("learner-peer-region-count").Set(float64(len(r.stats[LearnerPeer])))
 	regionStatusGauge.WithLabelValues("empty-region-count").Set(float64(len(r.stats[EmptyRegion])))
+	for class, typ := range RuleFitClasses {
+		regionStatusGauge.WithLabelValues("rule-fit-" + class + "-region-count").Set(float64(len(r.stats[typ])))
+	}
 
 	offlineRegionStatusGauge.WithLabelValues("miss-peer-region-count").Set(float64(len(r.offlineStats[MissPeer])))
 	offlineRegionStatusGauge.WithLabelValues("extra-peer-region-count").Set(float64(len(r.offlineStats[ExtraPeer])))
@@ -239,12 +353,62 @@ func (r *RegionStatistics) Collect() {
 	offlineRegionStatusGauge.WithLabelValues("learner-peer-region-count").Set(float64(len(r.offlineStats[LearnerPeer])))
 	offlineRegionStatusGauge.WithLabelValues("empty-region-count").Set(float64(len(r.offlineStats[EmptyRegion])))
 	offlineRegionStatusGauge.WithLabelValues("offline-peer-region-count").Set(float64(len(r.offlineStats[OfflinePeer])))
+	r.collectPlacementDrift()
+}
+
+// collectPlacementDrift computes the placement drift from the rule fit classes
+// of the regions. Each region is weighted by its approximate size, which is at
+// least 1 so the empty regions are counted as well.
+func (r *RegionStatistics) collectPlacementDrift() {
+	drift := PlacementDrift{UpdateTime: time.Now()}
+	if r.opt.IsPlacementRulesEnabled() {
+		for _, info := range r.stats[RuleFitFully] {
+			drift.TotalRegionCount++
+			drift.TotalSize += driftWeight(info.RegionInfo)
+		}
+		drifted := make(map[uint64]struct{})
+		for _, typ := range []RegionStatisticType{RuleFitMissPeer, RuleFitWrongRole, RuleFitOrphanPeer, RuleFitIsolationViolated} {
+			for regionID, info := range r.stats[typ] {
+				if _, ok := drifted[regionID]; ok {
+					continue
+				}
+				drifted[regionID] = struct{}{}
+				drift.DriftedRegionCount++
+				drift.DriftedSize += driftWeight(info.RegionInfo)
+			}
+		}
+		drift.TotalRegionCount += drift.DriftedRegionCount
+		drift.TotalSize += drift.DriftedSize
+		if drift.TotalSize > 0 {
+			drift.Drift = float64(drift.DriftedSize) / float64(drift.TotalSize)
+		}
+	}
+	placementDriftGauge.Set(drift.Drift)
+
+	r.driftMu.Lock()
+	defer r.driftMu.Unlock()
+	r.drift = drift
+}
+
+func driftWeight(region *core.RegionInfo) int64 {
+	if size := region.GetApproximateSize(); size > 1 {
+		return size
+	}
+	return 1
+}
+
+// GetPlacementDrift returns the placement drift computed by the last Collect.
+func (r *RegionStatistics) GetPlacementDrift() PlacementDrift {
+	r.driftMu.RLock()
+	defer r.driftMu.RUnlock()
+	return r.drift
 }
 
 // Reset resets the metrics of the regions' status.
 func (r *RegionStatistics) Reset() {
 	regionStatusGauge.Reset()
 	offlineRegionStatusGauge.Reset()
+	placementDriftGauge.Set(0)
 }
 
 // LabelStatistics is the statistics of the level of labels.
@@ -78,7 +78,7 @@ func (t *testRegionStatisticsSuite) TestRegionStatistics(c *C) {
 	r2 := &metapb.Region{Id: 2, Peers: peers[0:2], StartKey: []byte("cc"), EndKey: []byte("dd")}
 	region1 := core.NewRegionInfo(r1, peers[0])
 	region2 := core.NewRegionInfo(r2, peers[0])
-	regionStats := NewRegionStatistics(opt, t.manager)
+	regionStats := NewRegionStatistics(opt, t.manager, nil)
 	regionStats.Observe(region1, stores)
 	c.Assert(len(regionStats.stats[ExtraPeer]), Equals, 1)
 	c.Assert(len(regionStats.stats[LearnerPeer]), Equals, 1)
@@ -169,7 +169,7 @@ func (t *testRegionStatisticsSuite) TestRegionStatisticsWithPlacementRule(c *C)
 	region2 := core.NewRegionInfo(r2, peers[0])
 	region3 := core.NewRegionInfo(r3, peers[0])
 	region4 := core.NewRegionInfo(r4, peers[0])
-	regionStats := NewRegionStatistics(opt, t.manager)
+	regionStats := NewRegionStatistics(opt, t.manager, nil)
 	// r2 didn't match the rules
 	regionStats.Observe(region2, stores)
 	c.Assert(len(regionStats.stats[MissPeer]), Equals, 1)
@@ -182,6 +182,69 @@ func (t *testRegionStatisticsSuite) TestRegionStatisticsWithPlacementRule(c *C)
 	c.Assert(len(regionStats.stats[ExtraPeer]), Equals, 1)
 }
 
+func (t *testRegionStatisticsSuite) TestRegionStatisticsWithRuleFit(c *C) {
+	opt := config.NewTestOptions()
+	opt.SetPlacementRuleEnabled(true)
+	storeSet := core.NewBasicCluster()
+	for id, zone := range map[uint64]string{1: "z1", 2: "z2", 3: "z3", 4: "z3"} {
+		storeSet.PutStore(core.NewStoreInfo(&metapb.Store{
+			Id:     id,
+			Labels: []*metapb.StoreLabel{{Key: "zone", Value: zone}},
+		}))
+	}
+	rule := t.manager.GetRule("pd", "default")
+	rule.IsolationLevel = "zone"
+	c.Assert(t.manager.SetRule(rule), IsNil)
+	regionStats := NewRegionStatistics(opt, t.manager, storeSet)
+
+	newRegion := func(id uint64, storeIDs ...uint64) *core.RegionInfo {
+		var peers []*metapb.Peer
+		for _, storeID := range storeIDs {
+			peers = append(peers, &metapb.Peer{Id: id*10 + storeID, StoreId: storeID})
+		}
+		return core.NewRegionInfo(&metapb.Region{Id: id, Peers: peers}, peers[0])
+	}
+	regionStats.Observe(newRegion(1, 1, 2, 3), nil)
+	regionStats.Observe(newRegion(2, 1, 2), nil)
+	regionStats.Observe(newRegion(3, 1, 2, 3, 4), nil)
+	regionStats.Observe(newRegion(4, 1, 3, 4), nil)
+	learner := newRegion(5, 1, 2, 3)
+	learner = learner.Clone(core.WithLearners(learner.GetPeers()[2:]))
+	regionStats.Observe(learner, nil)
+
+	check := func(typ RegionStatisticType, regionIDs ...uint64) {
+		c.Assert(regionStats.stats[typ], HasLen, len(regionIDs))
+		for _, id := range regionIDs {
+			c.Assert(regionStats.stats[typ][id], NotNil)
+		}
+	}
+	check(RuleFitFully, 1)
+	check(RuleFitMissPeer, 2)
+	check(RuleFitOrphanPeer, 3)
+	check(RuleFitIsolationViolated, 4)
+	check(RuleFitWrongRole, 5)
+
+	// The region leaves the class after being fixed.
+	regionStats.Observe(newRegion(2, 1, 2, 3), nil)
+	check(RuleFitFully, 1, 2)
+	check(RuleFitMissPeer)
+
+	// The placement drift is weighted by the sizes, which are at least 1.
+	regionStats.Collect()
+	drift := regionStats.GetPlacementDrift()
+	c.Assert(drift.TotalRegionCount, Equals, 5)
+	c.Assert(drift.DriftedRegionCount, Equals, 3)
+	c.Assert(drift.Drift, Equals, 0.6)
+	regionStats.Observe(newRegion(3, 1, 2, 3, 4).Clone(core.SetApproximateSize(100)), nil)
+	regionStats.Collect()
+	drift = regionStats.GetPlacementDrift()
+	c.Assert(drift.DriftedSize, Equals, int64(102))
+	c.Assert(drift.TotalSize, Equals, int64(104))
+	opt.SetPlacementRuleEnabled(false)
+	regionStats.Collect()
+	c.Assert(regionStats.GetPlacementDrift().Drift, Equals, 0.0)
+}
+
 func (t *testRegionStatisticsSuite) TestRegionLabelIsolationLevel(c *C) {
 	locationLabels := []string{"zone", "rack", "host"}
 	labelLevelStats := NewLabelStatistics()
@@ -36,6 +36,9 @@ type storeStatistics struct {
 	Offline         int
 	Tombstone       int
 	LowSpace        int
+	Compaction      int
+	FullWarning     int
+	FullCritical    int
 	StorageSize     uint64
 	StorageCapacity uint64
 	RegionCount     int
@@ -86,6 +89,14 @@ func (s *storeStatistics) Observe(store *core.StoreInfo, stats *StoresStats) {
 	if store.IsLowSpace(s.opt.GetLowSpaceRatio()) {
 		s.LowSpace++
 	}
+	if store.IsCompactionPressured(s.opt.GetCompactionPressureThreshold()) {
+		s.Compaction++
+	}
+	if store.IsPredictedFull(s.opt.GetCapacityForecastCriticalDays()) {
+		s.FullCritical++
+	} else if store.IsPredictedFull(s.opt.GetCapacityForecastWarningDays()) {
+		s.FullWarning++
+	}
 
 	// Store stats.
 	s.StorageSize += store.StorageSize()
@@ -104,6 +115,8 @@ func (s *storeStatistics) Observe(store *core.StoreInfo, stats *StoresStats) {
 	storeStatusGauge.WithLabelValues(storeAddress, id, "store_capacity").Set(float64(store.GetCapacity()))
 	storeStatusGauge.WithLabelValues(storeAddress, id, "store_available_avg").Set(float64(store.GetAvgAvailable()))
 	storeStatusGauge.WithLabelValues(storeAddress, id, "store_available_deviation").Set(float64(store.GetAvailableDeviation()))
+	storeStatusGauge.WithLabelValues(storeAddress, id, "store_pending_compaction_bytes").Set(float64(store.GetPendingCompactionBytes()))
+	storeStatusGauge.WithLabelValues(storeAddress, id, "store_days_to_full").Set(store.GetDaysToFull())
 
 	// Store flows.
 	storeFlowStats := stats.GetRollingStoreStats(store.GetID())
@@ -138,6 +151,9 @@ func (s *storeStatistics) Collect() {
 	metrics["store_offline_count"] = float64(s.Offline)
 	metrics["store_tombstone_count"] = float64(s.Tombstone)
 	metrics["store_low_space_count"] = float64(s.LowSpace)
+	metrics["store_compaction_pressured_count"] = float64(s.Compaction)
+	metrics["store_full_warning_count"] = float64(s.FullWarning)
+	metrics["store_full_critical_count"] = float64(s.FullCritical)
 	metrics["region_count"] = float64(s.RegionCount)
 	metrics["leader_count"] = float64(s.LeaderCount)
 	metrics["storage_size"] = float64(s.StorageSize)
@@ -209,6 +225,8 @@ func (s *storeStatistics) resetStoreStatistics(storeAddress string, id string) {
 		"store_available",
 		"store_used",
 		"store_capacity",
+		"store_pending_compaction_bytes",
+		"store_days_to_full",
 		"store_write_rate_bytes",
 		"store_read_rate_bytes",
 		"store_write_rate_keys",
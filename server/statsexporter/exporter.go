@@ -0,0 +1,83 @@
+// Copyright 2021 TiKV Project Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package statsexporter
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+
+	"github.com/pingcap/log"
+	"github.com/tikv/pd/pkg/errs"
+	"github.com/tikv/pd/server/config"
+	"go.uber.org/zap"
+)
+
+// Exporter exports the snapshots of the region statistics to a sink
+// periodically, so that they can be analyzed offline without querying the
+// HTTP API.
+type Exporter struct {
+	sink     Sink
+	interval time.Duration
+	// collect returns the snapshot to export, or nil if there is nothing to
+	// export, such as when the server is not the leader.
+	collect func() interface{}
+}
+
+// NewExporter creates an Exporter with the config. It returns nil if the
+// exporter is disabled.
+func NewExporter(cfg *config.StatsExporterConfig, collect func() interface{}) (*Exporter, error) {
+	sink, err := NewSink(cfg)
+	if err != nil || sink == nil {
+		return nil, err
+	}
+	return &Exporter{sink: sink, interval: cfg.Interval.Duration, collect: collect}, nil
+}
+
+// Run exports the snapshots periodically until the context is done.
+func (e *Exporter) Run(ctx context.Context) {
+	ticker := time.NewTicker(e.interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := e.Export(ctx); err != nil {
+				log.Warn("failed to export the region statistics", zap.String("sink", e.sink.Name()), errs.ZapError(err))
+			}
+		}
+	}
+}
+
+// Export exports a snapshot if there is one.
+func (e *Exporter) Export(ctx context.Context) error {
+	snapshot := e.collect()
+	if snapshot == nil {
+		return nil
+	}
+	data, err := json.Marshal(snapshot)
+	if err != nil {
+		return errs.ErrJSONMarshal.Wrap(err).GenWithStackByCause()
+	}
+	start := time.Now()
+	err = e.sink.Write(ctx, data)
+	exportDuration.WithLabelValues(e.sink.Name()).Observe(time.Since(start).Seconds())
+	if err != nil {
+		exportCounter.WithLabelValues(e.sink.Name(), "failure").Inc()
+		return err
+	}
+	exportCounter.WithLabelValues(e.sink.Name(), "success").Inc()
+	return nil
+}
@@ -0,0 +1,110 @@
+// Copyright 2021 TiKV Project Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package statsexporter
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	. "github.com/pingcap/check"
+	"github.com/tikv/pd/pkg/typeutil"
+	"github.com/tikv/pd/server/config"
+)
+
+func TestStatsExporter(t *testing.T) {
+	TestingT(t)
+}
+
+var _ = Suite(&testExporterSuite{})
+
+type testExporterSuite struct{}
+
+type testSnapshot struct {
+	RegionCount int `json:"region_count"`
+}
+
+func (s *testExporterSuite) TestDisabled(c *C) {
+	e, err := NewExporter(&config.StatsExporterConfig{}, nil)
+	c.Assert(err, IsNil)
+	c.Assert(e, IsNil)
+}
+
+func (s *testExporterSuite) TestFileSink(c *C) {
+	path := filepath.Join(c.MkDir(), "stats.json")
+	var snapshot interface{}
+	cfg := &config.StatsExporterConfig{Sink: config.StatsSinkFile, FilePath: path, Interval: typeutil.NewDuration(time.Minute)}
+	e, err := NewExporter(cfg, func() interface{} { return snapshot })
+	c.Assert(err, IsNil)
+
+	// Nothing is exported without a snapshot.
+	c.Assert(e.Export(context.Background()), IsNil)
+	_, err = os.Stat(path)
+	c.Assert(os.IsNotExist(err), IsTrue)
+
+	for i := 1; i <= 2; i++ {
+		snapshot = &testSnapshot{RegionCount: i}
+		c.Assert(e.Export(context.Background()), IsNil)
+	}
+	data, err := os.ReadFile(path)
+	c.Assert(err, IsNil)
+	lines := strings.Split(strings.TrimSpace(string(data)), "\n")
+	c.Assert(lines, HasLen, 2)
+	for i, line := range lines {
+		var got testSnapshot
+		c.Assert(json.Unmarshal([]byte(line), &got), IsNil)
+		c.Assert(got.RegionCount, Equals, i+1)
+	}
+
+	// The file cannot be written.
+	cfg.FilePath = filepath.Join(path, "stats.json")
+	e, err = NewExporter(cfg, func() interface{} { return snapshot })
+	c.Assert(err, IsNil)
+	c.Assert(e.Export(context.Background()), NotNil)
+}
+
+func (s *testExporterSuite) TestKafkaSink(c *C) {
+	var records struct {
+		Records []struct {
+			Value testSnapshot `json:"value"`
+		} `json:"records"`
+	}
+	status := http.StatusOK
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		c.Assert(r.URL.Path, Equals, "/topics/region-stats")
+		c.Assert(r.Header.Get("Content-Type"), Equals, "application/vnd.kafka.json.v2+json")
+		data, err := io.ReadAll(r.Body)
+		c.Assert(err, IsNil)
+		c.Assert(json.Unmarshal(data, &records), IsNil)
+		w.WriteHeader(status)
+	}))
+	defer server.Close()
+
+	cfg := &config.StatsExporterConfig{Sink: config.StatsSinkKafka, KafkaRESTURL: server.URL + "/", KafkaTopic: "region-stats", Interval: typeutil.NewDuration(time.Minute)}
+	e, err := NewExporter(cfg, func() interface{} { return &testSnapshot{RegionCount: 3} })
+	c.Assert(err, IsNil)
+	c.Assert(e.Export(context.Background()), IsNil)
+	c.Assert(records.Records, HasLen, 1)
+	c.Assert(records.Records[0].Value.RegionCount, Equals, 3)
+
+	status = http.StatusInternalServerError
+	c.Assert(e.Export(context.Background()), NotNil)
+}
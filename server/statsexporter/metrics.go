@@ -0,0 +1,40 @@
+// Copyright 2021 TiKV Project Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package statsexporter
+
+import "github.com/prometheus/client_golang/prometheus"
+
+var (
+	exportCounter = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace: "pd",
+			Subsystem: "stats_exporter",
+			Name:      "export_total",
+			Help:      "Counter of exporting the region statistics.",
+		}, []string{"sink", "result"})
+
+	exportDuration = prometheus.NewHistogramVec(
+		prometheus.HistogramOpts{
+			Namespace: "pd",
+			Subsystem: "stats_exporter",
+			Name:      "export_duration_seconds",
+			Help:      "Bucketed histogram of the duration of exporting the region statistics.",
+			Buckets:   prometheus.ExponentialBuckets(0.001, 2, 16),
+		}, []string{"sink"})
+)
+
+func init() {
+	prometheus.MustRegister(exportCounter)
+	prometheus.MustRegister(exportDuration)
+}
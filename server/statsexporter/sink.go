@@ -0,0 +1,160 @@
+// Copyright 2021 TiKV Project Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package statsexporter
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/s3"
+	"github.com/tikv/pd/pkg/errs"
+	"github.com/tikv/pd/server/config"
+)
+
+const sinkTimeout = 30 * time.Second
+
+// Sink is where the snapshots are exported to.
+type Sink interface {
+	// Name returns the name of the sink.
+	Name() string
+	// Write exports a snapshot encoded in JSON.
+	Write(ctx context.Context, data []byte) error
+}
+
+// NewSink creates the sink of the config. It returns nil if no sink is set.
+func NewSink(cfg *config.StatsExporterConfig) (Sink, error) {
+	switch cfg.Sink {
+	case config.StatsSinkFile:
+		return &fileSink{path: cfg.FilePath}, nil
+	case config.StatsSinkS3:
+		return newS3Sink(cfg)
+	case config.StatsSinkKafka:
+		return &kafkaSink{
+			url:    strings.TrimSuffix(cfg.KafkaRESTURL, "/") + "/topics/" + cfg.KafkaTopic,
+			client: &http.Client{Timeout: sinkTimeout},
+		}, nil
+	}
+	return nil, nil
+}
+
+// fileSink appends the snapshots to a file, one per line.
+type fileSink struct {
+	sync.Mutex
+	path string
+}
+
+func (s *fileSink) Name() string {
+	return config.StatsSinkFile
+}
+
+func (s *fileSink) Write(_ context.Context, data []byte) error {
+	s.Lock()
+	defer s.Unlock()
+	f, err := os.OpenFile(s.path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0644)
+	if err != nil {
+		return errs.ErrStatsExport.Wrap(err).GenWithStackByArgs(s.path)
+	}
+	defer f.Close()
+	if _, err := f.Write(append(data, '\n')); err != nil {
+		return errs.ErrStatsExport.Wrap(err).GenWithStackByArgs(s.path)
+	}
+	return nil
+}
+
+// s3Sink uploads each snapshot as an object named by its time.
+type s3Sink struct {
+	client *s3.S3
+	bucket string
+	prefix string
+}
+
+func newS3Sink(cfg *config.StatsExporterConfig) (*s3Sink, error) {
+	awsCfg := &aws.Config{Region: aws.String(cfg.S3Region)}
+	if cfg.S3Endpoint != "" {
+		// The S3-compatible storages usually don't support the virtual hosted
+		// style.
+		awsCfg.Endpoint = aws.String(cfg.S3Endpoint)
+		awsCfg.S3ForcePathStyle = aws.Bool(true)
+	}
+	sess, err := session.NewSession(awsCfg)
+	if err != nil {
+		return nil, errs.ErrStatsExport.Wrap(err).GenWithStackByArgs("s3")
+	}
+	return &s3Sink{client: s3.New(sess), bucket: cfg.S3Bucket, prefix: cfg.S3Prefix}, nil
+}
+
+func (s *s3Sink) Name() string {
+	return config.StatsSinkS3
+}
+
+func (s *s3Sink) Write(ctx context.Context, data []byte) error {
+	key := path.Join(s.prefix, fmt.Sprintf("region-stats-%d.json", time.Now().UnixNano()))
+	ctx, cancel := context.WithTimeout(ctx, sinkTimeout)
+	defer cancel()
+	_, err := s.client.PutObjectWithContext(ctx, &s3.PutObjectInput{
+		Bucket:      aws.String(s.bucket),
+		Key:         aws.String(key),
+		Body:        bytes.NewReader(data),
+		ContentType: aws.String("application/json"),
+	})
+	if err != nil {
+		return errs.ErrStatsExport.Wrap(err).GenWithStackByArgs("s3://" + path.Join(s.bucket, key))
+	}
+	return nil
+}
+
+// kafkaSink produces the snapshots to a topic through the Kafka REST proxy.
+type kafkaSink struct {
+	url    string
+	client *http.Client
+}
+
+func (s *kafkaSink) Name() string {
+	return config.StatsSinkKafka
+}
+
+func (s *kafkaSink) Write(ctx context.Context, data []byte) error {
+	body, err := json.Marshal(map[string]interface{}{
+		"records": []map[string]json.RawMessage{{"value": data}},
+	})
+	if err != nil {
+		return errs.ErrStatsExport.Wrap(err).GenWithStackByArgs(s.url)
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, s.url, bytes.NewReader(body))
+	if err != nil {
+		return errs.ErrStatsExport.Wrap(err).GenWithStackByArgs(s.url)
+	}
+	req.Header.Set("Content-Type", "application/vnd.kafka.json.v2+json")
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return errs.ErrStatsExport.Wrap(err).GenWithStackByArgs(s.url)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		msg, _ := io.ReadAll(resp.Body)
+		return errs.ErrStatsExport.GenWithStack("failed to export the stats to %s, [%d] %s", s.url, resp.StatusCode, msg)
+	}
+	return nil
+}
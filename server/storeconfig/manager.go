@@ -0,0 +1,234 @@
+// Copyright 2021 TiKV Project Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package storeconfig
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/pingcap/errors"
+	"github.com/pingcap/log"
+	"github.com/tikv/pd/pkg/errs"
+	"github.com/tikv/pd/pkg/netutil"
+	"github.com/tikv/pd/server/core"
+	"github.com/tikv/pd/server/schedule/filter"
+	"go.uber.org/zap"
+)
+
+const (
+	fetchTimeout = 3 * time.Second
+	// syncConcurrency is the maximum number of stores fetched at the same time.
+	syncConcurrency = 16
+)
+
+// Fetcher fetches the config of a store. Each storage engine can have its own
+// fetcher.
+type Fetcher interface {
+	Fetch(ctx context.Context, store *core.StoreInfo) ([]byte, error)
+}
+
+// httpFetcher fetches the config from the `/config` API of the store status
+// server, which is provided by both TiKV and the TiFlash proxy.
+type httpFetcher struct {
+	client *http.Client
+	scheme string
+}
+
+// NewHTTPFetcher creates a fetcher which fetches the config from the status
+// server of the store.
+func NewHTTPFetcher(client *http.Client, scheme string) Fetcher {
+	return &httpFetcher{client: client, scheme: scheme}
+}
+
+func (f *httpFetcher) Fetch(ctx context.Context, store *core.StoreInfo) ([]byte, error) {
+	if store.GetMeta().GetStatusAddress() == "" {
+		return nil, errors.Errorf("store %d has no status address", store.GetID())
+	}
+	// The status server may listen on the unspecified address, such as [::].
+	statusAddress := netutil.ResolveLoopBackAddr(store.GetMeta().GetStatusAddress(), store.GetAddress())
+	url := fmt.Sprintf("%s://%s/config", f.scheme, statusAddress)
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	resp, err := f.client.Do(req)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, errors.Errorf("failed to fetch config from %s, status: %d, body: %s", url, resp.StatusCode, body)
+	}
+	return body, nil
+}
+
+// Config is the config of a store observed by PD.
+type Config struct {
+	StoreID uint64 `json:"store_id"`
+	Engine  string `json:"engine"`
+	// Version is increased each time the config is changed. It is kept in
+	// memory only, so it restarts from 1 after the PD leader changes.
+	Version    uint64          `json:"version"`
+	Config     json.RawMessage `json:"config,omitempty"`
+	UpdateTime time.Time       `json:"update_time"`
+	// Error is the error of the last fetch, the config is kept if it fails.
+	Error string `json:"error,omitempty"`
+}
+
+// Manager synchronizes the configs of stores with the fetchers of their
+// engines, and tracks the versions of the configs.
+type Manager struct {
+	sync.RWMutex
+	fetchers map[string]Fetcher
+	configs  map[uint64]*Config
+}
+
+// NewManager creates a Manager with the fetchers of TiKV and TiFlash.
+func NewManager(client *http.Client, scheme string) *Manager {
+	fetcher := NewHTTPFetcher(client, scheme)
+	return &Manager{
+		fetchers: map[string]Fetcher{
+			filter.EngineTiKV:    fetcher,
+			filter.EngineTiFlash: fetcher,
+		},
+		configs: make(map[uint64]*Config),
+	}
+}
+
+// RegisterFetcher registers the fetcher of an engine. It replaces the existing
+// one of the engine.
+func (m *Manager) RegisterFetcher(engine string, fetcher Fetcher) {
+	m.Lock()
+	defer m.Unlock()
+	m.fetchers[engine] = fetcher
+}
+
+// GetEngine returns the storage engine of the store.
+func GetEngine(store *core.StoreInfo) string {
+	if engine := store.GetLabelValue(filter.EngineKey); engine != "" {
+		return engine
+	}
+	return filter.EngineTiKV
+}
+
+// Sync fetches the configs of the given stores. The stores which are not up
+// are skipped, and the configs of the stores which no longer exist are removed.
+// At most syncConcurrency stores are fetched at the same time, so that a few
+// slow stores do not delay the others.
+func (m *Manager) Sync(ctx context.Context, stores []*core.StoreInfo) {
+	alive := make(map[uint64]struct{}, len(stores))
+	var wg sync.WaitGroup
+	sem := make(chan struct{}, syncConcurrency)
+	for _, store := range stores {
+		if store.IsTombstone() {
+			continue
+		}
+		alive[store.GetID()] = struct{}{}
+		if !store.IsUp() || store.IsDisconnected() {
+			continue
+		}
+		engine := GetEngine(store)
+		m.RLock()
+		fetcher, ok := m.fetchers[engine]
+		m.RUnlock()
+		if !ok {
+			continue
+		}
+		wg.Add(1)
+		sem <- struct{}{}
+		go func(store *core.StoreInfo) {
+			defer func() {
+				<-sem
+				wg.Done()
+			}()
+			fetchCtx, cancel := context.WithTimeout(ctx, fetchTimeout)
+			cfg, err := fetcher.Fetch(fetchCtx, store)
+			cancel()
+			m.update(store.GetID(), engine, cfg, err)
+		}(store)
+	}
+	wg.Wait()
+
+	m.Lock()
+	defer m.Unlock()
+	for id := range m.configs {
+		if _, ok := alive[id]; !ok {
+			delete(m.configs, id)
+		}
+	}
+}
+
+func (m *Manager) update(storeID uint64, engine string, cfg []byte, err error) {
+	m.Lock()
+	defer m.Unlock()
+	old, ok := m.configs[storeID]
+	if !ok {
+		old = &Config{StoreID: storeID, Engine: engine}
+		m.configs[storeID] = old
+	}
+	if err != nil {
+		old.Error = err.Error()
+		log.Warn("failed to fetch store config", zap.Uint64("store-id", storeID), zap.String("engine", engine), errs.ZapError(err))
+		return
+	}
+	var compacted bytes.Buffer
+	if err := json.Compact(&compacted, cfg); err != nil {
+		old.Error = errs.ErrJSONUnmarshal.Wrap(err).FastGenWithCause().Error()
+		return
+	}
+	old.Error = ""
+	if old.Engine == engine && bytes.Equal(old.Config, compacted.Bytes()) {
+		return
+	}
+	old.Engine = engine
+	old.Config = compacted.Bytes()
+	old.Version++
+	old.UpdateTime = time.Now()
+	log.Info("store config is changed", zap.Uint64("store-id", storeID), zap.String("engine", engine), zap.Uint64("version", old.Version))
+}
+
+// GetConfig returns the config of the store.
+func (m *Manager) GetConfig(storeID uint64) *Config {
+	m.RLock()
+	defer m.RUnlock()
+	if cfg, ok := m.configs[storeID]; ok {
+		c := *cfg
+		return &c
+	}
+	return nil
+}
+
+// GetConfigs returns the configs of all stores, sorted by store ID.
+func (m *Manager) GetConfigs() []*Config {
+	m.RLock()
+	defer m.RUnlock()
+	configs := make([]*Config, 0, len(m.configs))
+	for _, cfg := range m.configs {
+		c := *cfg
+		configs = append(configs, &c)
+	}
+	sort.Slice(configs, func(i, j int) bool { return configs[i].StoreID < configs[j].StoreID })
+	return configs
+}
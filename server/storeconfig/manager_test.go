@@ -0,0 +1,119 @@
+// Copyright 2021 TiKV Project Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package storeconfig
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	. "github.com/pingcap/check"
+	"github.com/pingcap/kvproto/pkg/metapb"
+	"github.com/tikv/pd/server/core"
+	"github.com/tikv/pd/server/schedule/filter"
+)
+
+func TestStoreConfig(t *testing.T) {
+	TestingT(t)
+}
+
+var _ = Suite(&testManagerSuite{})
+
+type testManagerSuite struct{}
+
+func newTestStore(id uint64, statusAddr string, labels ...*metapb.StoreLabel) *core.StoreInfo {
+	return core.NewStoreInfo(&metapb.Store{
+		Id:            id,
+		StatusAddress: statusAddr,
+		Labels:        labels,
+	}, core.SetLastHeartbeatTS(time.Now()))
+}
+
+func (s *testManagerSuite) TestSync(c *C) {
+	var cfg atomic.Value
+	cfg.Store(`{"a": 1}`)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(cfg.Load().(string)))
+	}))
+	defer server.Close()
+	addr := strings.TrimPrefix(server.URL, "http://")
+
+	m := NewManager(server.Client(), "http")
+	tikv := newTestStore(1, addr)
+	tiflash := newTestStore(2, addr, &metapb.StoreLabel{Key: "engine", Value: "tiflash"})
+	unknown := newTestStore(3, addr, &metapb.StoreLabel{Key: "engine", Value: "unknown"})
+	stores := []*core.StoreInfo{tikv, tiflash, unknown}
+	m.Sync(context.Background(), stores)
+
+	configs := m.GetConfigs()
+	c.Assert(configs, HasLen, 2)
+	c.Assert(configs[0].Engine, Equals, filter.EngineTiKV)
+	c.Assert(configs[1].Engine, Equals, filter.EngineTiFlash)
+	c.Assert(string(configs[1].Config), Equals, `{"a":1}`)
+	c.Assert(configs[1].Version, Equals, uint64(1))
+	c.Assert(m.GetConfig(3), IsNil)
+
+	// The version is only increased when the config is changed.
+	m.Sync(context.Background(), stores)
+	c.Assert(m.GetConfig(2).Version, Equals, uint64(1))
+	cfg.Store(`{"a": 2}`)
+	m.Sync(context.Background(), stores)
+	c.Assert(m.GetConfig(2).Version, Equals, uint64(2))
+	c.Assert(string(m.GetConfig(2).Config), Equals, `{"a":2}`)
+
+	// The config is kept if it fails to be fetched.
+	cfg.Store(`not json`)
+	m.Sync(context.Background(), stores)
+	c.Assert(m.GetConfig(2).Version, Equals, uint64(2))
+	c.Assert(m.GetConfig(2).Error, Not(Equals), "")
+
+	// The config of a removed store is dropped.
+	m.Sync(context.Background(), []*core.StoreInfo{tikv})
+	c.Assert(m.GetConfigs(), HasLen, 1)
+}
+
+type blockingFetcher struct {
+	running, maxRunning int32
+}
+
+func (f *blockingFetcher) Fetch(ctx context.Context, store *core.StoreInfo) ([]byte, error) {
+	running := atomic.AddInt32(&f.running, 1)
+	defer atomic.AddInt32(&f.running, -1)
+	for {
+		max := atomic.LoadInt32(&f.maxRunning)
+		if running <= max || atomic.CompareAndSwapInt32(&f.maxRunning, max, running) {
+			break
+		}
+	}
+	time.Sleep(10 * time.Millisecond)
+	return []byte(`{}`), nil
+}
+
+func (s *testManagerSuite) TestSyncConcurrency(c *C) {
+	m := NewManager(http.DefaultClient, "http")
+	fetcher := &blockingFetcher{}
+	m.RegisterFetcher(filter.EngineTiKV, fetcher)
+	var stores []*core.StoreInfo
+	for i := uint64(1); i <= 3*syncConcurrency; i++ {
+		stores = append(stores, newTestStore(i, "127.0.0.1:20180"))
+	}
+	m.Sync(context.Background(), stores)
+	c.Assert(m.GetConfigs(), HasLen, len(stores))
+	c.Assert(fetcher.maxRunning, Greater, int32(1))
+	c.Assert(fetcher.maxRunning, LessEqual, int32(syncConcurrency))
+}
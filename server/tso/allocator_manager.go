@@ -322,6 +322,24 @@ func (am *AllocatorManager) SetUpAllocator(parentCtx context.Context, dcLocation
 	go am.allocatorLeaderLoop(parentCtx, localTSOAllocator)
 }
 
+// SetUpMemoryAllocator sets up a MemoryTSOAllocator as the Global TSO Allocator.
+// It is used by the standalone PD, which has no etcd to persist the timestamps.
+func (am *AllocatorManager) SetUpMemoryAllocator(parentCtx context.Context, leadership *election.Leadership) {
+	am.mu.Lock()
+	defer am.mu.Unlock()
+	if _, exist := am.mu.allocatorGroups[GlobalDCLocation]; exist {
+		return
+	}
+	ctx, cancel := context.WithCancel(parentCtx)
+	am.mu.allocatorGroups[GlobalDCLocation] = &allocatorGroup{
+		dcLocation: GlobalDCLocation,
+		ctx:        ctx,
+		cancel:     cancel,
+		leadership: leadership,
+		allocator:  NewMemoryTSOAllocator(),
+	}
+}
+
 func (am *AllocatorManager) getAllocatorPath(dcLocation string) string {
 	// For backward compatibility, the global timestamp's store path will still use the old one
 	if dcLocation == GlobalDCLocation {
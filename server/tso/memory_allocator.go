@@ -0,0 +1,88 @@
+// Copyright 2021 TiKV Project Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package tso
+
+import (
+	"sync"
+	"time"
+
+	"github.com/pingcap/kvproto/pkg/pdpb"
+	"github.com/tikv/pd/pkg/errs"
+	"github.com/tikv/pd/pkg/tsoutil"
+)
+
+// MemoryTSOAllocator is the Global TSO Allocator of the standalone PD, which
+// generates the timestamps from the local clock. As there is only one member
+// and nothing is persisted, the timestamps only need to be monotonic in the
+// process.
+type MemoryTSOAllocator struct {
+	mu       sync.Mutex
+	physical int64 // in milliseconds
+	logical  int64
+}
+
+// NewMemoryTSOAllocator creates a new memory TSO allocator.
+func NewMemoryTSOAllocator() Allocator {
+	return &MemoryTSOAllocator{}
+}
+
+// Initialize does nothing as there is nothing to synchronize.
+func (mta *MemoryTSOAllocator) Initialize(int) error {
+	return nil
+}
+
+// IsInitialize always returns true.
+func (mta *MemoryTSOAllocator) IsInitialize() bool {
+	return true
+}
+
+// UpdateTSO does nothing as the physical part is updated on generation.
+func (mta *MemoryTSOAllocator) UpdateTSO() error {
+	return nil
+}
+
+// SetTSO sets the physical part with given TSO. It can not set the TSO
+// smaller than now.
+func (mta *MemoryTSOAllocator) SetTSO(tso uint64) error {
+	physicalTime, logical := tsoutil.ParseTS(tso)
+	physical := physicalTime.UnixNano() / int64(time.Millisecond)
+	mta.mu.Lock()
+	defer mta.mu.Unlock()
+	if physical < mta.physical || (physical == mta.physical && int64(logical) <= mta.logical) {
+		return errs.ErrResetUserTimestamp.FastGenByArgs("the specified ts is smaller than now")
+	}
+	mta.physical, mta.logical = physical, int64(logical)
+	return nil
+}
+
+// GenerateTSO is used to generate the given number of TSOs, and returns the
+// last one like the Global TSO Allocator.
+func (mta *MemoryTSOAllocator) GenerateTSO(count uint32) (pdpb.Timestamp, error) {
+	if count == 0 || int64(count) >= maxLogical {
+		return pdpb.Timestamp{}, errs.ErrGenerateTimestamp.FastGenByArgs("invalid count")
+	}
+	mta.mu.Lock()
+	defer mta.mu.Unlock()
+	if now := time.Now().UnixNano() / int64(time.Millisecond); now > mta.physical {
+		mta.physical, mta.logical = now, 0
+	}
+	if mta.logical+int64(count) >= maxLogical {
+		mta.physical, mta.logical = mta.physical+1, 0
+	}
+	mta.logical += int64(count)
+	return pdpb.Timestamp{Physical: mta.physical, Logical: mta.logical}, nil
+}
+
+// Reset does nothing as the allocator is never reset.
+func (mta *MemoryTSOAllocator) Reset() {}
@@ -20,8 +20,6 @@ import (
 	"path"
 	"time"
 
-	"github.com/pingcap/errors"
-	"github.com/pingcap/kvproto/pkg/pdpb"
 	"github.com/pingcap/log"
 	"github.com/tikv/pd/pkg/errs"
 	"github.com/tikv/pd/pkg/etcdutil"
@@ -137,39 +135,3 @@ func makeRaftClusterStatusPrefix(clusterRootPath string) string {
 func makeBootstrapTimeKey(clusterRootPath string) string {
 	return path.Join(makeRaftClusterStatusPrefix(clusterRootPath), "raft_bootstrap_time")
 }
-
-func checkBootstrapRequest(clusterID uint64, req *pdpb.BootstrapRequest) error {
-	// TODO: do more check for request fields validation.
-
-	storeMeta := req.GetStore()
-	if storeMeta == nil {
-		return errors.Errorf("missing store meta for bootstrap %d", clusterID)
-	} else if storeMeta.GetId() == 0 {
-		return errors.New("invalid zero store id")
-	}
-
-	regionMeta := req.GetRegion()
-	if regionMeta == nil {
-		return errors.Errorf("missing region meta for bootstrap %d", clusterID)
-	} else if len(regionMeta.GetStartKey()) > 0 || len(regionMeta.GetEndKey()) > 0 {
-		// first region start/end key must be empty
-		return errors.Errorf("invalid first region key range, must all be empty for bootstrap %d", clusterID)
-	} else if regionMeta.GetId() == 0 {
-		return errors.New("invalid zero region id")
-	}
-
-	peers := regionMeta.GetPeers()
-	if len(peers) != 1 {
-		return errors.Errorf("invalid first region peer count %d, must be 1 for bootstrap %d", len(peers), clusterID)
-	}
-
-	peer := peers[0]
-	if peer.GetStoreId() != storeMeta.GetId() {
-		return errors.Errorf("invalid peer store id %d != %d for bootstrap %d", peer.GetStoreId(), storeMeta.GetId(), clusterID)
-	}
-	if peer.GetId() == 0 {
-		return errors.New("invalid zero peer id")
-	}
-
-	return nil
-}
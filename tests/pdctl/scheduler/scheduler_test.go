@@ -269,6 +269,7 @@ func (s *schedulerTestSuite) TestScheduler(c *C) {
 		"minor-dec-ratio":            0.99,
 		"src-tolerance-ratio":        1.05,
 		"dst-tolerance-ratio":        1.05,
+		"enable-predictive":          "false",
 	}
 	c.Assert(conf, DeepEquals, expected1)
 	mustExec([]string{"-u", pdAddr, "scheduler", "config", "balance-hot-region-scheduler", "set", "src-tolerance-ratio", "1.02"}, nil)
@@ -277,6 +278,19 @@ func (s *schedulerTestSuite) TestScheduler(c *C) {
 	mustExec([]string{"-u", pdAddr, "scheduler", "config", "balance-hot-region-scheduler"}, &conf1)
 	c.Assert(conf1, DeepEquals, expected1)
 
+	// test balance leader config
+	var conf2 map[string]interface{}
+	mustExec([]string{"-u", pdAddr, "scheduler", "config", "balance-leader-scheduler", "list"}, &conf2)
+	c.Assert(conf2["mode"], IsNil)
+	echo = mustExec([]string{"-u", pdAddr, "scheduler", "config", "balance-leader-scheduler", "set", "mode", "qps"}, nil)
+	c.Assert(strings.Contains(echo, "Success!"), IsTrue)
+	mustExec([]string{"-u", pdAddr, "scheduler", "config", "balance-leader-scheduler"}, &conf2)
+	c.Assert(conf2["mode"], Equals, "qps")
+	echo = mustExec([]string{"-u", pdAddr, "scheduler", "config", "balance-leader-scheduler", "set", "mode", "foo"}, nil)
+	c.Assert(strings.Contains(echo, "invalid mode"), IsTrue)
+	mustExec([]string{"-u", pdAddr, "scheduler", "config", "balance-leader-scheduler"}, &conf2)
+	c.Assert(conf2["mode"], Equals, "qps")
+
 	// test show scheduler with paused and disabled status.
 	checkSchedulerWithStatusCommand := func(args []string, status string, expected []string) {
 		if args != nil {
@@ -293,6 +307,8 @@ func (s *schedulerTestSuite) TestScheduler(c *C) {
 	})
 	mustExec([]string{"-u", pdAddr, "scheduler", "resume", "balance-leader-scheduler"}, nil)
 	checkSchedulerWithStatusCommand(nil, "paused", nil)
+	echo = mustExec([]string{"-u", pdAddr, "scheduler", "cancel-operators", "balance-leader-scheduler"}, nil)
+	c.Assert(strings.Contains(echo, "operators are canceled"), IsTrue)
 
 	// set label scheduler to disabled manually.
 	echo = mustExec([]string{"-u", pdAddr, "scheduler", "add", "label-scheduler"}, nil)
@@ -17,6 +17,7 @@ import (
 	"context"
 	"io"
 	"net/http"
+	"strings"
 	"testing"
 	"time"
 
@@ -203,6 +204,78 @@ func (s *testRedirectorSuite) TestNotLeader(c *C) {
 	c.Assert(err, IsNil)
 }
 
+var _ = Suite(&testFollowerGatewaySuite{})
+
+type testFollowerGatewaySuite struct {
+	cleanup func()
+	cluster *tests.TestCluster
+}
+
+func (s *testFollowerGatewaySuite) SetUpSuite(c *C) {
+	ctx, cancel := context.WithCancel(context.Background())
+	s.cleanup = cancel
+	cluster, err := tests.NewTestCluster(ctx, 2, func(conf *config.Config, serverName string) {
+		conf.PDServerCfg.EnableFollowerGateway = true
+		conf.PDServerCfg.GatewayPolicies = []config.GatewayPolicy{
+			{PathPrefix: "/pd/api/v1/config/schedule", Methods: []string{"GET"}, Action: config.GatewayForward},
+		}
+	})
+	c.Assert(err, IsNil)
+	c.Assert(cluster.RunInitialServers(), IsNil)
+	c.Assert(len(cluster.WaitLeader()), Not(Equals), 0)
+	c.Assert(cluster.GetServer(cluster.GetLeader()).BootstrapCluster(), IsNil)
+	s.cluster = cluster
+}
+
+func (s *testFollowerGatewaySuite) TearDownSuite(c *C) {
+	s.cleanup()
+	s.cluster.Destroy()
+}
+
+func (s *testFollowerGatewaySuite) TestGateway(c *C) {
+	leader := s.cluster.GetServer(s.cluster.GetLeader())
+	var follower *server.Server
+	for _, svr := range s.cluster.GetServers() {
+		if svr != leader {
+			follower = svr.GetServer()
+		}
+	}
+
+	// The read APIs are handled by the follower.
+	for _, path := range []string{"/pd/api/v1/version", "/pd/api/v1/status", "/pd/api/v1/cluster"} {
+		resp, err := dialClient.Get(follower.GetAddr() + path)
+		c.Assert(err, IsNil)
+		resp.Body.Close()
+		c.Assert(resp.StatusCode, Equals, http.StatusOK, Commentf("path %s", path))
+		c.Assert(resp.Header.Get(serverapi.FollowerHandle), Equals, "true", Commentf("path %s", path))
+	}
+
+	// The read APIs which need the running cluster are forwarded to the
+	// leader.
+	for _, path := range []string{"/pd/api/v1/stores", "/pd/api/v1/regions", "/pd/api/v1/operators", "/pd/api/v1/config/cluster-version"} {
+		resp, err := dialClient.Get(follower.GetAddr() + path)
+		c.Assert(err, IsNil)
+		resp.Body.Close()
+		c.Assert(resp.StatusCode, Equals, http.StatusOK, Commentf("path %s", path))
+		c.Assert(resp.Header.Get(serverapi.FollowerHandle), Equals, "", Commentf("path %s", path))
+	}
+
+	// The policy forwards the matched read API to the leader.
+	resp, err := dialClient.Get(follower.GetAddr() + "/pd/api/v1/config/schedule")
+	c.Assert(err, IsNil)
+	resp.Body.Close()
+	c.Assert(resp.StatusCode, Equals, http.StatusOK)
+	c.Assert(resp.Header.Get(serverapi.FollowerHandle), Equals, "")
+
+	// The mutations are forwarded to the leader.
+	resp, err = dialClient.Post(follower.GetAddr()+"/pd/api/v1/config", "application/json", strings.NewReader(`{"max-snapshot-count": 13}`))
+	c.Assert(err, IsNil)
+	resp.Body.Close()
+	c.Assert(resp.StatusCode, Equals, http.StatusOK)
+	c.Assert(resp.Header.Get(serverapi.FollowerHandle), Equals, "")
+	c.Assert(leader.GetServer().GetScheduleConfig().MaxSnapshotCount, Equals, uint64(13))
+}
+
 func mustRequestSuccess(c *C, s *server.Server) http.Header {
 	resp, err := dialClient.Get(s.GetAddr() + "/pd/api/v1/version")
 	c.Assert(err, IsNil)
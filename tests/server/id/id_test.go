@@ -95,6 +95,30 @@ func (s *testAllocIDSuite) TestID(c *C) {
 	wg.Wait()
 }
 
+func (s *testAllocIDSuite) TestAllocN(c *C) {
+	cluster, err := tests.NewTestCluster(s.ctx, 1)
+	c.Assert(err, IsNil)
+	defer cluster.Destroy()
+
+	err = cluster.RunInitialServers()
+	c.Assert(err, IsNil)
+	cluster.WaitLeader()
+
+	alloc := cluster.GetServer(cluster.GetLeader()).GetAllocator()
+	last, err := alloc.Alloc()
+	c.Assert(err, IsNil)
+	// The ranges larger than the window are allocated as well.
+	for _, n := range []uint64{3, allocStep - 10, 2 * allocStep} {
+		first, err := alloc.AllocN(n)
+		c.Assert(err, IsNil)
+		c.Assert(first, Greater, last)
+		last = first + n - 1
+	}
+	id, err := alloc.Alloc()
+	c.Assert(err, IsNil)
+	c.Assert(id, Greater, last)
+}
+
 func (s *testAllocIDSuite) TestCommand(c *C) {
 	cluster, err := tests.NewTestCluster(s.ctx, 1)
 	c.Assert(err, IsNil)
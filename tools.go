@@ -16,6 +16,7 @@
 package tools
 
 import (
+	_ "github.com/gogo/protobuf/protoc-gen-gogo"
 	_ "github.com/mgechev/revive"
 	_ "github.com/pingcap/errors/errdoc-gen"
 	_ "github.com/pingcap/failpoint/failpoint-ctl"
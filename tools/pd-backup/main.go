@@ -14,6 +14,7 @@
 package main
 
 import (
+	"context"
 	"flag"
 	"fmt"
 	"os"
@@ -31,6 +32,9 @@ var (
 	caPath   = flag.String("cacert", "", "path of file that contains list of trusted SSL CAs")
 	certPath = flag.String("cert", "", "path of file that contains X509 certificate in PEM format")
 	keyPath  = flag.String("key", "", "path of file that contains X509 key in PEM format")
+
+	restoreMeta  = flag.String("restore-meta", "", "restore the meta snapshot from the storage URL, like s3://bucket/prefix, instead of backing up")
+	metaSnapshot = flag.String("meta-snapshot", "", "name of the meta snapshot to restore, the latest one is restored if it is empty")
 )
 
 const (
@@ -39,9 +43,6 @@ const (
 
 func main() {
 	flag.Parse()
-	f, err := os.Create(*filePath)
-	checkErr(err)
-	defer f.Close()
 	urls := strings.Split(*pdAddr, ",")
 
 	tlsInfo := transport.TLSInfo{
@@ -59,6 +60,16 @@ func main() {
 	})
 	checkErr(err)
 
+	if *restoreMeta != "" {
+		name, err := pdbackup.RestoreMeta(context.Background(), client, *restoreMeta, *metaSnapshot)
+		checkErr(err)
+		fmt.Println("pd meta restore successful! restored snapshot is:", name)
+		return
+	}
+
+	f, err := os.Create(*filePath)
+	checkErr(err)
+	defer f.Close()
 	backInfo, err := pdbackup.GetBackupInfo(client, *pdAddr)
 	checkErr(err)
 	pdbackup.OutputToFile(backInfo, f)
@@ -0,0 +1,73 @@
+// Copyright 2021 TiKV Project Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package pdbackup
+
+import (
+	"context"
+	"path"
+	"strconv"
+
+	"github.com/pingcap/errors"
+	"github.com/tikv/pd/pkg/etcdutil"
+	"github.com/tikv/pd/pkg/typeutil"
+	"github.com/tikv/pd/server/core"
+	"github.com/tikv/pd/server/kv"
+	"github.com/tikv/pd/server/metasnapshot"
+	"go.etcd.io/etcd/clientv3"
+)
+
+// RestoreMeta restores the meta snapshot of the name from the storage URL to
+// etcd. The latest snapshot is restored if the name is empty. It returns the
+// name of the restored snapshot.
+func RestoreMeta(ctx context.Context, client *clientv3.Client, storageURL, name string) (string, error) {
+	backend, err := metasnapshot.NewBackend(storageURL)
+	if err != nil {
+		return "", err
+	}
+	if name == "" {
+		names, err := metasnapshot.ListSnapshots(ctx, backend)
+		if err != nil {
+			return "", err
+		}
+		if len(names) == 0 {
+			return "", errors.Errorf("no meta snapshot is found in %s", storageURL)
+		}
+		name = names[len(names)-1]
+	}
+	manifest, _, err := metasnapshot.LoadSnapshot(ctx, backend, name)
+	if err != nil {
+		return "", err
+	}
+	// The meta must be restored to the same cluster, which can be recovered
+	// by pd-recover first.
+	resp, err := etcdutil.EtcdKVGet(client, pdClusterIDPath)
+	if err != nil {
+		return "", err
+	}
+	if len(resp.Kvs) > 0 {
+		clusterID, err := typeutil.BytesToUint64(resp.Kvs[0].Value)
+		if err != nil {
+			return "", err
+		}
+		if clusterID != manifest.ClusterID {
+			return "", errors.Errorf("cluster id %d mismatches the cluster id %d of the snapshot", clusterID, manifest.ClusterID)
+		}
+	}
+	rootPath := path.Join(pdRootPath, strconv.FormatUint(manifest.ClusterID, 10))
+	storage := core.NewStorage(kv.NewEtcdKVBase(client, rootPath))
+	if _, err := metasnapshot.Restore(ctx, backend, name, storage); err != nil {
+		return "", err
+	}
+	return name, nil
+}
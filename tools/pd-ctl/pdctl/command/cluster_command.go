@@ -15,12 +15,14 @@ package command
 
 import (
 	"net/http"
+	"strconv"
 
 	"github.com/spf13/cobra"
 )
 
 const clusterPrefix = "pd/api/v1/cluster"
 const clusterStatusPrefix = "pd/api/v1/cluster/status"
+const clusterPreparePrefix = "pd/api/v1/cluster/prepare"
 
 // NewClusterCommand return a cluster subcommand of rootCmd
 func NewClusterCommand() *cobra.Command {
@@ -30,6 +32,7 @@ func NewClusterCommand() *cobra.Command {
 		Run:   showClusterCommandFunc,
 	}
 	cmd.AddCommand(NewClusterStatusCommand())
+	cmd.AddCommand(NewClusterPrepareCommand())
 	return cmd
 }
 
@@ -43,6 +46,16 @@ func NewClusterStatusCommand() *cobra.Command {
 	return r
 }
 
+// NewClusterPrepareCommand return a cluster prepare subcommand of clusterCmd
+func NewClusterPrepareCommand() *cobra.Command {
+	r := &cobra.Command{
+		Use:   "prepare [<expected_store_count>]",
+		Short: "show the progress of collecting the cluster information before scheduling, or declare the expected store count",
+		Run:   clusterPrepareCommandFunc,
+	}
+	return r
+}
+
 func showClusterCommandFunc(cmd *cobra.Command, args []string) {
 	r, err := doRequest(cmd, clusterPrefix, http.MethodGet)
 	if err != nil {
@@ -60,3 +73,24 @@ func showClusterStatusCommandFunc(cmd *cobra.Command, args []string) {
 	}
 	cmd.Println(r)
 }
+
+func clusterPrepareCommandFunc(cmd *cobra.Command, args []string) {
+	switch len(args) {
+	case 0:
+		r, err := doRequest(cmd, clusterPreparePrefix, http.MethodGet)
+		if err != nil {
+			cmd.Printf("Failed to get the cluster prepare status: %s\n", err)
+			return
+		}
+		cmd.Println(r)
+	case 1:
+		count, err := strconv.ParseUint(args[0], 10, 64)
+		if err != nil {
+			cmd.Println("expected_store_count should be a number")
+			return
+		}
+		postJSON(cmd, clusterPreparePrefix, map[string]interface{}{"expected-store-count": count})
+	default:
+		cmd.Println(cmd.UsageString())
+	}
+}
@@ -0,0 +1,64 @@
+// Copyright 2021 TiKV Project Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package command
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+
+	"github.com/spf13/cobra"
+	"github.com/tikv/pd/server/cluster"
+)
+
+var (
+	doctorPrefix = "pd/api/v1/doctor"
+)
+
+// NewDoctorCommand return a doctor subcommand of rootCmd
+func NewDoctorCommand() *cobra.Command {
+	d := &cobra.Command{
+		Use:   "doctor [--json]",
+		Short: "check the common misconfigurations of the cluster and show how to fix them",
+		Run:   doctorCommandFunc,
+	}
+	d.Flags().Bool("json", false, "show the raw report")
+	return d
+}
+
+func doctorCommandFunc(cmd *cobra.Command, args []string) {
+	r, err := doRequest(cmd, doctorPrefix, http.MethodGet)
+	if err != nil {
+		cmd.Printf("Failed to diagnose the cluster: %s\n", err)
+		return
+	}
+	if raw, _ := cmd.Flags().GetBool("json"); raw {
+		cmd.Println(r)
+		return
+	}
+	var report cluster.DoctorReport
+	if err := json.Unmarshal([]byte(r), &report); err != nil {
+		cmd.Printf("Failed to parse the report: %s\n", err)
+		return
+	}
+	cmd.Printf("Checked: %s\n", strings.Join(report.Checks, ", "))
+	if len(report.Findings) == 0 {
+		cmd.Println("No problem is found.")
+		return
+	}
+	for _, finding := range report.Findings {
+		cmd.Printf("[%s] %s: %s\n", strings.ToUpper(finding.Severity), finding.Check, finding.Message)
+		cmd.Printf("    suggestion: %s\n", finding.Suggestion)
+	}
+}
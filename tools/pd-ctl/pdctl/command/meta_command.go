@@ -0,0 +1,98 @@
+// Copyright 2021 TiKV Project Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package command
+
+import (
+	"bytes"
+	"net/http"
+	"os"
+
+	"github.com/spf13/cobra"
+)
+
+var (
+	metaBackupPrefix  = "pd/api/v1/meta/backup"
+	metaRestorePrefix = "pd/api/v1/meta/restore"
+)
+
+// NewMetaCommand return a meta subcommand of rootCmd
+func NewMetaCommand() *cobra.Command {
+	m := &cobra.Command{
+		Use:   "meta <subcommand>",
+		Short: "backup or restore the PD-owned meta, excluding the regions and the stores",
+	}
+	m.AddCommand(NewMetaBackupCommand())
+	m.AddCommand(NewMetaRestoreCommand())
+	return m
+}
+
+// NewMetaBackupCommand return a subcommand to backup the meta
+func NewMetaBackupCommand() *cobra.Command {
+	b := &cobra.Command{
+		Use:   "backup [--out=<file>]",
+		Short: "dump the meta into an archive",
+		Run:   backupMetaCommandFunc,
+	}
+	b.Flags().String("out", "", "the output file, print the archive if it is empty")
+	return b
+}
+
+// NewMetaRestoreCommand return a subcommand to restore the meta
+func NewMetaRestoreCommand() *cobra.Command {
+	r := &cobra.Command{
+		Use:   "restore --in=<file> [--dry-run]",
+		Short: "restore the meta from an archive into a cluster which is not bootstrapped",
+		Run:   restoreMetaCommandFunc,
+	}
+	r.Flags().String("in", "meta.json", "the file contains the archive")
+	r.Flags().Bool("dry-run", false, "show the changes without applying them")
+	return r
+}
+
+func backupMetaCommandFunc(cmd *cobra.Command, args []string) {
+	res, err := doRequest(cmd, metaBackupPrefix, http.MethodGet)
+	if err != nil {
+		cmd.Printf("Failed to backup meta: %s\n", err)
+		return
+	}
+	file, _ := cmd.Flags().GetString("out")
+	if file == "" {
+		cmd.Println(res)
+		return
+	}
+	if err := os.WriteFile(file, []byte(res), 0644); err != nil {
+		cmd.Println(err)
+		return
+	}
+	cmd.Printf("meta saved to file %s\n", file)
+}
+
+func restoreMetaCommandFunc(cmd *cobra.Command, args []string) {
+	file, _ := cmd.Flags().GetString("in")
+	content, err := os.ReadFile(file)
+	if err != nil {
+		cmd.Println(err)
+		return
+	}
+	prefix := metaRestorePrefix
+	if dryRun, _ := cmd.Flags().GetBool("dry-run"); dryRun {
+		prefix += "?dry-run=true"
+	}
+	res, err := doRequest(cmd, prefix, http.MethodPost, WithBody("application/json", bytes.NewReader(content)))
+	if err != nil {
+		cmd.Printf("Failed to restore meta: %s\n", err)
+		return
+	}
+	cmd.Println(res)
+}
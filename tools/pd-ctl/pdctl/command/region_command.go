@@ -34,6 +34,7 @@ var (
 	regionsPrefix          = "pd/api/v1/regions"
 	regionsStorePrefix     = "pd/api/v1/regions/store"
 	regionsCheckPrefix     = "pd/api/v1/regions/check"
+	regionsWatchlistPrefix = "pd/api/v1/regions/watchlist"
 	regionsWriteFlowPrefix = "pd/api/v1/regions/writeflow"
 	regionsReadFlowPrefix  = "pd/api/v1/regions/readflow"
 	regionsConfVerPrefix   = "pd/api/v1/regions/confver"
@@ -98,6 +99,14 @@ func NewRegionCommand() *cobra.Command {
 	topSize.Flags().String("jq", "", "jq query")
 	r.AddCommand(topSize)
 
+	watchlist := &cobra.Command{
+		Use:   `watchlist [--jq="<query string>"]`,
+		Short: "show regions in trouble for too long, the ones to triage first are shown first",
+		Run:   showRegionWatchlistCommandFunc,
+	}
+	watchlist.Flags().String("jq", "", "jq query")
+	r.AddCommand(watchlist)
+
 	scanRegion := &cobra.Command{
 		Use:   `scan [--jq="<query string>"]`,
 		Short: "scan all regions",
@@ -133,6 +142,23 @@ func showRegionCommandFunc(cmd *cobra.Command, args []string) {
 	cmd.Println(r)
 }
 
+func showRegionWatchlistCommandFunc(cmd *cobra.Command, args []string) {
+	if len(args) != 0 {
+		cmd.Println(cmd.UsageString())
+		return
+	}
+	r, err := doRequest(cmd, regionsWatchlistPrefix, http.MethodGet)
+	if err != nil {
+		cmd.Printf("Failed to get region watchlist: %s\n", err)
+		return
+	}
+	if flag := cmd.Flag("jq"); flag != nil && flag.Value.String() != "" {
+		printWithJQFilter(r, flag.Value.String())
+		return
+	}
+	cmd.Println(r)
+}
+
 func scanRegionCommandFunc(cmd *cobra.Command, args []string) {
 	const limit = 1024
 	var key []byte
@@ -45,6 +45,10 @@ func NewSchedulerCommand() *cobra.Command {
 	c.AddCommand(NewRemoveSchedulerCommand())
 	c.AddCommand(NewPauseSchedulerCommand())
 	c.AddCommand(NewResumeSchedulerCommand())
+	c.AddCommand(NewSetSchedulerWindowCommand())
+	c.AddCommand(NewSetSchedulerShadowCommand())
+	c.AddCommand(NewShowSchedulerShadowCommand())
+	c.AddCommand(NewCancelSchedulerOperatorsCommand())
 	c.AddCommand(NewConfigSchedulerCommand())
 	return c
 }
@@ -56,6 +60,7 @@ func NewPauseSchedulerCommand() *cobra.Command {
 		Short: "pause a scheduler",
 		Run:   pauseOrResumeSchedulerCommandFunc,
 	}
+	c.Flags().Bool("cancel-operators", false, "cancel the operators created by the scheduler")
 	return c
 }
 
@@ -64,7 +69,7 @@ func pauseOrResumeSchedulerCommandFunc(cmd *cobra.Command, args []string) {
 		cmd.Usage()
 		return
 	}
-	path := schedulersPrefix + "/" + args[0]
+	path := withCancelOperators(cmd, schedulersPrefix+"/"+args[0])
 	input := make(map[string]interface{})
 	input["delay"] = 0
 	if len(args) == 2 {
@@ -78,6 +83,106 @@ func pauseOrResumeSchedulerCommandFunc(cmd *cobra.Command, args []string) {
 	postJSON(cmd, path, input)
 }
 
+// NewSetSchedulerWindowCommand returns a command to set the daily window of a scheduler.
+func NewSetSchedulerWindowCommand() *cobra.Command {
+	c := &cobra.Command{
+		Use:   "set-window <scheduler> [<HH:MM-HH:MM>]",
+		Short: "set the daily window in which a scheduler is allowed to schedule, the window is removed if it is omitted",
+		Run:   setSchedulerWindowCommandFunc,
+	}
+	return c
+}
+
+func setSchedulerWindowCommandFunc(cmd *cobra.Command, args []string) {
+	if len(args) != 2 && len(args) != 1 {
+		cmd.Usage()
+		return
+	}
+	input := map[string]interface{}{"window": ""}
+	if len(args) == 2 {
+		input["window"] = args[1]
+	}
+	postJSON(cmd, schedulersPrefix+"/"+args[0]+"/window", input)
+}
+
+// NewSetSchedulerShadowCommand returns a command to switch the shadow mode of a scheduler.
+func NewSetSchedulerShadowCommand() *cobra.Command {
+	c := &cobra.Command{
+		Use:   "set-shadow <scheduler> <true|false>",
+		Short: "switch the shadow mode of a scheduler, in which it computes the operators without dispatching them",
+		Run:   setSchedulerShadowCommandFunc,
+	}
+	return c
+}
+
+func setSchedulerShadowCommandFunc(cmd *cobra.Command, args []string) {
+	if len(args) != 2 {
+		cmd.Usage()
+		return
+	}
+	shadow, err := strconv.ParseBool(args[1])
+	if err != nil {
+		cmd.Usage()
+		return
+	}
+	postJSON(cmd, schedulersPrefix+"/"+args[0]+"/shadow", map[string]interface{}{"shadow": shadow})
+}
+
+// NewShowSchedulerShadowCommand returns a command to show the operators produced by a scheduler in the shadow mode.
+func NewShowSchedulerShadowCommand() *cobra.Command {
+	c := &cobra.Command{
+		Use:   "show-shadow <scheduler>",
+		Short: "show the operators produced by a scheduler in the shadow mode",
+		Run:   showSchedulerShadowCommandFunc,
+	}
+	return c
+}
+
+func showSchedulerShadowCommandFunc(cmd *cobra.Command, args []string) {
+	if len(args) != 1 {
+		cmd.Usage()
+		return
+	}
+	r, err := doRequest(cmd, schedulersPrefix+"/"+args[0]+"/shadow", http.MethodGet)
+	if err != nil {
+		cmd.Printf("Failed to get the shadow status of the scheduler: %s\n", err)
+		return
+	}
+	cmd.Println(r)
+}
+
+// NewCancelSchedulerOperatorsCommand returns a command to cancel the operators of a scheduler.
+func NewCancelSchedulerOperatorsCommand() *cobra.Command {
+	c := &cobra.Command{
+		Use:   "cancel-operators <scheduler>",
+		Short: "cancel the operators created by a scheduler gracefully, or by all schedulers with \"all\"",
+		Run:   cancelSchedulerOperatorsCommandFunc,
+	}
+	return c
+}
+
+func cancelSchedulerOperatorsCommandFunc(cmd *cobra.Command, args []string) {
+	if len(args) != 1 {
+		cmd.Usage()
+		return
+	}
+	r, err := doRequest(cmd, schedulersPrefix+"/"+args[0]+"/cancel-operators", http.MethodPost)
+	if err != nil {
+		cmd.Printf("Failed to cancel the operators: %s\n", err)
+		return
+	}
+	cmd.Println(r)
+}
+
+// withCancelOperators appends the query to cancel the operators of the
+// scheduler if the flag is set.
+func withCancelOperators(cmd *cobra.Command, path string) string {
+	if cancel, err := cmd.Flags().GetBool("cancel-operators"); err == nil && cancel {
+		return path + "?cancel-operators=true"
+	}
+	return path
+}
+
 // NewShowSchedulerCommand returns a command to show schedulers.
 func NewShowSchedulerCommand() *cobra.Command {
 	c := &cobra.Command{
@@ -359,6 +464,7 @@ func NewRemoveSchedulerCommand() *cobra.Command {
 		Short: "remove a scheduler",
 		Run:   removeSchedulerCommandFunc,
 	}
+	c.Flags().Bool("cancel-operators", false, "cancel the operators created by the scheduler")
 	return c
 }
 
@@ -380,7 +486,7 @@ func removeSchedulerCommandFunc(cmd *cobra.Command, args []string) {
 	case strings.HasPrefix(args[0], grantLeaderSchedulerName) && args[0] != grantLeaderSchedulerName:
 		redirectRemoveSchedulerToDeleteConfig(cmd, grantLeaderSchedulerName, args)
 	default:
-		path := schedulersPrefix + "/" + args[0]
+		path := withCancelOperators(cmd, schedulersPrefix+"/"+args[0])
 		_, err := doRequest(cmd, path, http.MethodDelete)
 		if err != nil {
 			cmd.Println(err)
@@ -402,6 +508,7 @@ func NewConfigSchedulerCommand() *cobra.Command {
 		newConfigGrantLeaderCommand(),
 		newConfigHotRegionCommand(),
 		newConfigShuffleRegionCommand(),
+		newConfigBalanceLeaderCommand(),
 	)
 	return c
 }
@@ -423,6 +530,23 @@ func newConfigHotRegionCommand() *cobra.Command {
 	return c
 }
 
+func newConfigBalanceLeaderCommand() *cobra.Command {
+	c := &cobra.Command{
+		Use:   "balance-leader-scheduler",
+		Short: "balance-leader-scheduler config",
+		Run:   listSchedulerConfigCommandFunc,
+	}
+	c.AddCommand(&cobra.Command{
+		Use:   "list",
+		Short: "list the config item",
+		Run:   listSchedulerConfigCommandFunc})
+	c.AddCommand(&cobra.Command{
+		Use:   "set mode <count|size|qps>",
+		Short: "set how the leader load of a store is measured",
+		Run:   func(cmd *cobra.Command, args []string) { postSchedulerConfigCommandFunc(cmd, c.Name(), args) }})
+	return c
+}
+
 func newConfigEvictLeaderCommand() *cobra.Command {
 	c := &cobra.Command{
 		Use:   "evict-leader-scheduler",
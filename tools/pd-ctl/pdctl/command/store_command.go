@@ -41,10 +41,13 @@ func NewStoreCommand() *cobra.Command {
 	s.AddCommand(NewDeleteStoreCommand())
 	s.AddCommand(NewLabelStoreCommand())
 	s.AddCommand(NewSetStoreWeightCommand())
+	s.AddCommand(NewSetStoreReadOnlyCommand())
 	s.AddCommand(NewStoreLimitCommand())
 	s.AddCommand(NewRemoveTombStoneCommand())
 	s.AddCommand(NewStoreLimitSceneCommand())
 	s.AddCommand(NewStoreCheckCommand())
+	s.AddCommand(NewStoreArchiveCommand())
+	s.AddCommand(NewRestoreStoreArchiveCommand())
 	s.Flags().String("jq", "", "jq query")
 	s.Flags().StringSlice("state", nil, "state filter")
 	return s
@@ -91,6 +94,33 @@ func NewSetStoreWeightCommand() *cobra.Command {
 	}
 }
 
+// NewSetStoreReadOnlyCommand returns a read-only subcommand of storeCmd.
+func NewSetStoreReadOnlyCommand() *cobra.Command {
+	return &cobra.Command{
+		Use:   "read-only <store_id> <true|false>",
+		Short: "set whether a store is read-only, a read-only store is not selected as the target of transfer leader or add peer",
+		Run:   setStoreReadOnlyCommandFunc,
+	}
+}
+
+// NewStoreArchiveCommand returns an archive subcommand of storeCmd.
+func NewStoreArchiveCommand() *cobra.Command {
+	return &cobra.Command{
+		Use:   "archive <store_id>",
+		Short: "show the archive of a removed tombstone store",
+		Run:   showStoreArchiveCommandFunc,
+	}
+}
+
+// NewRestoreStoreArchiveCommand returns a restore-archive subcommand of storeCmd.
+func NewRestoreStoreArchiveCommand() *cobra.Command {
+	return &cobra.Command{
+		Use:   "restore-archive <store_id>",
+		Short: "restore a removed tombstone store from its archive with the same meta and weights",
+		Run:   restoreStoreArchiveCommandFunc,
+	}
+}
+
 // NewStoreLimitCommand returns a limit subcommand of storeCmd.
 func NewStoreLimitCommand() *cobra.Command {
 	c := &cobra.Command{
@@ -302,6 +332,42 @@ func deleteStoreCommandFunc(cmd *cobra.Command, args []string) {
 	cmd.Println("Success!")
 }
 
+func showStoreArchiveCommandFunc(cmd *cobra.Command, args []string) {
+	if len(args) != 1 {
+		cmd.Usage()
+		return
+	}
+	if _, err := strconv.Atoi(args[0]); err != nil {
+		cmd.Println("store_id should be a number")
+		return
+	}
+	prefix := fmt.Sprintf(path.Join(storePrefix, "archive"), args[0])
+	r, err := doRequest(cmd, prefix, http.MethodGet)
+	if err != nil {
+		cmd.Printf("Failed to get the archive of store %s: %s\n", args[0], err)
+		return
+	}
+	cmd.Println(r)
+}
+
+func restoreStoreArchiveCommandFunc(cmd *cobra.Command, args []string) {
+	if len(args) != 1 {
+		cmd.Usage()
+		return
+	}
+	if _, err := strconv.Atoi(args[0]); err != nil {
+		cmd.Println("store_id should be a number")
+		return
+	}
+	prefix := fmt.Sprintf(path.Join(storePrefix, "restore-archive"), args[0])
+	_, err := doRequest(cmd, prefix, http.MethodPost)
+	if err != nil {
+		cmd.Printf("Failed to restore the archive of store %s: %s\n", args[0], err)
+		return
+	}
+	cmd.Println("Success!")
+}
+
 func deleteStoreCommandByAddrFunc(cmd *cobra.Command, args []string) {
 	if len(args) != 1 {
 		cmd.Usage()
@@ -398,6 +464,22 @@ func setStoreWeightCommandFunc(cmd *cobra.Command, args []string) {
 	})
 }
 
+func setStoreReadOnlyCommandFunc(cmd *cobra.Command, args []string) {
+	if len(args) != 2 {
+		cmd.Usage()
+		return
+	}
+	readOnly, err := strconv.ParseBool(args[1])
+	if err != nil {
+		cmd.Println("read-only should be true or false.")
+		return
+	}
+	prefix := fmt.Sprintf(path.Join(storePrefix, "read-only"), args[0])
+	postJSON(cmd, prefix, map[string]interface{}{
+		"read-only": readOnly,
+	})
+}
+
 func storeLimitCommandFunc(cmd *cobra.Command, args []string) {
 	argsCount := len(args)
 	if argsCount <= 1 {
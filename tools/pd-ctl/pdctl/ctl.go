@@ -61,6 +61,8 @@ func GetRootCmd() *cobra.Command {
 		command.NewLogCommand(),
 		command.NewPluginCommand(),
 		command.NewServiceGCSafepointCommand(),
+		command.NewMetaCommand(),
+		command.NewDoctorCommand(),
 		command.NewCompletionCommand(),
 	)
 
@@ -0,0 +1,80 @@
+// Copyright 2021 TiKV Project Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"os/signal"
+	"syscall"
+
+	"github.com/BurntSushi/toml"
+	"github.com/tikv/pd/server/cluster"
+	"github.com/tikv/pd/server/config"
+	"github.com/tikv/pd/server/replay"
+)
+
+var (
+	filePath   = flag.String("file", "", "the heartbeat record file to replay")
+	configPath = flag.String("config", "", "the config file of PD, the default config is used if it is empty")
+	speed      = flag.Float64("speed", 0, "the speed relative to the recorded pace, 0 means as fast as possible")
+)
+
+func checkErr(err error) {
+	if err != nil {
+		fmt.Println(err.Error())
+		os.Exit(1)
+	}
+}
+
+func main() {
+	flag.Parse()
+	if *filePath == "" {
+		checkErr(fmt.Errorf("the record file is required"))
+	}
+	cfg := config.NewConfig()
+	var meta *toml.MetaData
+	if *configPath != "" {
+		m, err := toml.DecodeFile(*configPath, cfg)
+		checkErr(err)
+		meta = &m
+	}
+	checkErr(cfg.Adjust(meta, false))
+
+	f, err := os.Open(*filePath)
+	checkErr(err)
+	defer f.Close()
+	r, err := replay.NewReader(f)
+	checkErr(err)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	sc := make(chan os.Signal, 1)
+	signal.Notify(sc, syscall.SIGINT, syscall.SIGTERM)
+	go func() {
+		<-sc
+		cancel()
+	}()
+
+	// The shadow cluster writes nothing to etcd and dispatches no operator.
+	rc := cluster.NewShadowCluster(ctx, config.NewPersistOptions(cfg))
+	res, err := replay.Replay(ctx, rc, r, *speed)
+	checkErr(err)
+	out, err := json.MarshalIndent(res, "", "  ")
+	checkErr(err)
+	fmt.Println(string(out))
+}